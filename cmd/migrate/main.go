@@ -1,18 +1,23 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 
 	"github.com/a5c-ai/hub/internal/config"
 	"github.com/a5c-ai/hub/internal/db"
 	"github.com/a5c-ai/hub/internal/db/seeds"
+	"github.com/a5c-ai/hub/internal/encryption"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/sirupsen/logrus"
 )
 
 func main() {
 	var (
 		rollback = flag.Bool("rollback", false, "Rollback the last migration")
 		seed     = flag.Bool("seed", false, "Seed the database with development data")
+		rotate   = flag.Bool("rotate-keys", false, "Re-encrypt stored secrets with the current master key")
 	)
 	flag.Parse()
 
@@ -49,4 +54,23 @@ func main() {
 		}
 		log.Println("Database seeding completed successfully")
 	}
+
+	if *rotate {
+		keyring, err := encryption.NewKeyring(cfg.Encryption)
+		if err != nil {
+			log.Fatal("Failed to initialize secret encryption:", err)
+		}
+		encryption.SetDefault(keyring)
+
+		log.Println("Re-encrypting stored secrets...")
+		svc := services.NewSecretEncryptionService(database.DB, keyring, cfg.Encryption.RotationBatchSize, logrus.StandardLogger())
+		result, err := svc.Rotate(context.Background())
+		if err != nil {
+			log.Fatal("Failed to rotate encryption keys:", err)
+		}
+		for _, failure := range result.Failures {
+			log.Printf("Could not decrypt %s.%s of %s: %s", failure.Table, failure.Column, failure.ID, failure.Error)
+		}
+		log.Printf("Key rotation completed: %d secrets checked, %d re-encrypted, %d failed", result.Checked, result.Rotated, len(result.Failures))
+	}
 }
@@ -1,18 +1,29 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
+	"strings"
 
 	"github.com/a5c-ai/hub/internal/config"
 	"github.com/a5c-ai/hub/internal/db"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
 	"github.com/a5c-ai/hub/internal/services"
 	"github.com/sirupsen/logrus"
 )
 
+// reindex rebuilds the code search index of every repository, or of the
+// one given with -repository. Pushes keep the index current afterwards, so
+// this is only needed to backfill existing repositories or to recover
+// from a lost index.
 func main() {
-	var configPath string
+	var configPath, repository string
+	var incremental bool
 	flag.StringVar(&configPath, "config", "", "Path to config file")
+	flag.StringVar(&repository, "repository", "", "Only reindex this repository (owner/name)")
+	flag.BoolVar(&incremental, "incremental", false, "Only index files changed since the last indexed commit")
 	flag.Parse()
 
 	// Load configuration
@@ -33,18 +44,65 @@ func main() {
 	}
 	defer database.Close()
 
-	// Initialize Elasticsearch service
-	elasticsearchService, err := services.NewElasticsearchService(&cfg.Elasticsearch, logger)
+	repoBasePath := cfg.Storage.RepositoryPath
+	if repoBasePath == "" {
+		repoBasePath = "./repositories"
+	}
+	// Offloaded packs are restored before a repository is read
+	packStore, err := git.NewPackStore(cfg.Storage.Packs, repoBasePath, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize pack offloading")
+	}
+	gitService := git.NewGitServiceWithPackStore(packStore, logger)
+	repositoryService := services.NewRepositoryService(database.DB, gitService, logger, repoBasePath)
+
+	codeSearchService, err := services.NewCodeSearchService(database.DB, gitService, repositoryService, cfg.CodeSearch, cfg.Elasticsearch, logger)
 	if err != nil {
-		logger.WithError(err).Fatal("Failed to initialize Elasticsearch service")
+		logger.WithError(err).Fatal("Failed to initialize code search")
+	}
+	if !codeSearchService.Enabled() {
+		logger.Fatal("Code search is not enabled in configuration")
+	}
+
+	ctx := context.Background()
+	var repos []*models.Repository
+	if repository != "" {
+		owner, name, ok := strings.Cut(repository, "/")
+		if !ok {
+			logger.Fatal("Repository must be given as owner/name")
+		}
+		repo, err := repositoryService.Get(ctx, owner, name)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to get repository")
+		}
+		repos = append(repos, repo)
+	} else if err := database.DB.Select("id", "name").Order("created_at").Find(&repos).Error; err != nil {
+		logger.WithError(err).Fatal("Failed to list repositories")
 	}
 
-	if !elasticsearchService.IsEnabled() {
-		logger.Fatal("Elasticsearch is not enabled in configuration")
+	failed := 0
+	for _, repo := range repos {
+		index := codeSearchService.ReindexRepository
+		if incremental {
+			index = codeSearchService.IndexRepository
+		}
+		status, err := index(ctx, repo.ID)
+		entry := logger.WithFields(logrus.Fields{"repository_id": repo.ID, "repository": repo.Name})
+		if err != nil {
+			failed++
+			entry.WithError(err).Error("Failed to index repository")
+			continue
+		}
+		entry.WithFields(logrus.Fields{
+			"state":       status.State,
+			"commit":      status.CommitSHA,
+			"files":       status.Files,
+			"duration_ms": status.DurationMs,
+		}).Info("Indexed repository")
 	}
 
-	// Initialize search service
-	// Reindex is no longer needed as we're using database-only search
-	logger.Info("Reindex operation is not applicable for database-only search")
-	logger.Info("Search is now performed directly on the database")
+	logger.WithFields(logrus.Fields{"repositories": len(repos), "failed": failed}).Info("Code search reindex complete")
+	if failed > 0 {
+		log.Fatalf("%d repositories failed to index", failed)
+	}
 }
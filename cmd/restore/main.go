@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/db"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// restore rebuilds a repository from its disaster recovery replica. By
+// default the last replicated snapshot is restored to the repository's own
+// path, which must not exist; -sequence or -before restore an earlier
+// snapshot and -target restores elsewhere. -list prints the replication
+// log instead of restoring.
+func main() {
+	var configPath, repository, id, target, before string
+	var sequence int64
+	var list bool
+	flag.StringVar(&configPath, "config", "", "Path to config file")
+	flag.StringVar(&repository, "repository", "", "Repository to restore (owner/name)")
+	flag.StringVar(&id, "id", "", "ID of the repository to restore, when it is no longer in the database")
+	flag.StringVar(&target, "target", "", "Restore into this directory instead of the repository path")
+	flag.Int64Var(&sequence, "sequence", 0, "Restore this snapshot")
+	flag.StringVar(&before, "before", "", "Restore the last snapshot at or before this time (RFC 3339)")
+	flag.BoolVar(&list, "list", false, "List the snapshots of the replica")
+	flag.Parse()
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Setup logger
+	logger := logrus.New()
+	logger.SetLevel(logrus.Level(cfg.LogLevel))
+	logger.SetFormatter(&logrus.JSONFormatter{})
+
+	replicator, err := git.NewReplicatorFromConfig(cfg.Storage.DisasterRecovery, nil, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize disaster recovery storage")
+	}
+	if replicator == nil {
+		logger.Fatal("Disaster recovery replication is not enabled in configuration")
+	}
+
+	opts := git.RestoreOptions{Sequence: sequence}
+	if before != "" {
+		if opts.Before, err = time.Parse(time.RFC3339, before); err != nil {
+			logger.WithError(err).Fatal("Invalid -before time")
+		}
+	}
+
+	ctx := context.Background()
+	var repoID uuid.UUID
+	switch {
+	case id != "":
+		if repoID, err = uuid.Parse(id); err != nil {
+			logger.WithError(err).Fatal("Invalid repository ID")
+		}
+		if target == "" && !list {
+			logger.Fatal("-target is required with -id")
+		}
+	case repository != "":
+		owner, name, ok := strings.Cut(repository, "/")
+		if !ok {
+			logger.Fatal("Repository must be given as owner/name")
+		}
+		database, err := db.Connect(cfg.Database)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to connect to database")
+		}
+		defer database.Close()
+
+		repoBasePath := cfg.Storage.RepositoryPath
+		if repoBasePath == "" {
+			repoBasePath = "./repositories"
+		}
+		repositoryService := services.NewRepositoryService(database.DB, git.NewGitService(logger), logger, repoBasePath)
+		repo, err := repositoryService.Get(ctx, owner, name)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to get repository")
+		}
+		repoID = repo.ID
+		if target == "" {
+			if target, err = repositoryService.GetRepositoryPath(ctx, repo.ID); err != nil {
+				logger.WithError(err).Fatal("Failed to get repository path")
+			}
+		}
+	default:
+		logger.Fatal("Either -repository or -id is required")
+	}
+
+	prefix := "repositories/" + repoID.String()
+	if list {
+		snapshots, err := replicator.Snapshots(ctx, prefix)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to list snapshots")
+		}
+		for _, snapshot := range snapshots {
+			fmt.Fprintf(os.Stdout, "%d\t%s\t%s\t%d refs\t%d objects\n", snapshot.Sequence,
+				snapshot.CreatedAt.Format(time.RFC3339), snapshot.Repository, len(snapshot.Refs), snapshot.Objects)
+		}
+		return
+	}
+
+	restored, err := replicator.Restore(ctx, prefix, target, opts)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to restore repository")
+	}
+	logger.WithFields(logrus.Fields{
+		"repository_id": repoID,
+		"repository":    restored.Repository,
+		"sequence":      restored.Sequence,
+		"replicated_at": restored.CreatedAt,
+		"refs":          len(restored.Refs),
+		"target":        target,
+	}).Info("Restored repository")
+}
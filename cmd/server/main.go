@@ -11,13 +11,17 @@ import (
 	"time"
 
 	"github.com/a5c-ai/hub/internal/api"
+	"github.com/a5c-ai/hub/internal/auth"
 	"github.com/a5c-ai/hub/internal/config"
 	"github.com/a5c-ai/hub/internal/db"
+	"github.com/a5c-ai/hub/internal/encryption"
+	"github.com/a5c-ai/hub/internal/errorreporting"
 	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/logging"
+	"github.com/a5c-ai/hub/internal/middleware"
 	"github.com/a5c-ai/hub/internal/services"
 	"github.com/a5c-ai/hub/internal/ssh"
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
 )
 
 func main() {
@@ -27,10 +31,37 @@ func main() {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Setup logger
-	logger := logrus.New()
-	logger.SetLevel(logrus.Level(cfg.LogLevel))
-	logger.SetFormatter(&logrus.JSONFormatter{})
+	// Setup loggers; levels can be tuned per module
+	logs, err := logging.New(cfg.Logging, cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("Failed to configure logging: %v", err)
+	}
+	defer logs.Close()
+	logging.SetDefault(logs)
+	logger := logs.Root()
+
+	// Setup error reporting
+	if cfg.ErrorReporting.Environment == "" {
+		cfg.ErrorReporting.Environment = cfg.Environment
+	}
+	reporter, err := errorreporting.NewReporter(cfg.ErrorReporting, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize error reporting")
+	}
+	errorreporting.SetDefault(reporter)
+
+	// Setup packfile offloading to object storage
+	packStore, err := git.NewPackStore(cfg.Storage.Packs, cfg.Storage.RepositoryPath, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize pack offloading")
+	}
+
+	// Setup encryption of secrets stored in the database
+	keyring, err := encryption.NewKeyring(cfg.Encryption)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize secret encryption")
+	}
+	encryption.SetDefault(keyring)
 
 	// Initialize database
 	database, err := db.Connect(cfg.Database)
@@ -50,7 +81,8 @@ func main() {
 	}
 
 	// Setup HTTP router
-	router := gin.Default()
+	router := gin.New()
+	router.Use(gin.Logger(), middleware.Recovery(reporter))
 
 	// Setup CORS middleware
 	router.Use(func(c *gin.Context) {
@@ -62,7 +94,8 @@ func main() {
 			}
 		}
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization, X-Requested-With, If-Match")
+		c.Header("Access-Control-Expose-Headers", "ETag")
 		c.Header("Access-Control-Allow-Credentials", "true")
 
 		if c.Request.Method == "OPTIONS" {
@@ -73,8 +106,8 @@ func main() {
 		c.Next()
 	})
 
-	// Setup API routes
-	api.SetupRoutes(router, database, logger)
+	// Setup API routes; their background schedulers start with the server
+	background := api.SetupRoutes(router, database, packStore, logger)
 
 	// Create HTTP server
 	httpServer := &http.Server{
@@ -86,20 +119,25 @@ func main() {
 	var sshServer *ssh.SSHServer
 	if cfg.SSH.Enabled {
 		// Initialize services
-		gitService := git.NewGitService(logger)
+		gitService := git.NewGitServiceWithPackStore(packStore, logs.Module(logging.ModuleGit, logger))
 		repoBasePath := cfg.Storage.RepositoryPath
 		if repoBasePath == "" {
 			repoBasePath = "./repositories"
 		}
 
-		repositoryService := services.NewRepositoryService(database.DB, gitService, logger, repoBasePath)
+		sshLogger := logs.Module(logging.ModuleSSH, logger)
+		repositoryService := services.NewRepositoryService(database.DB, gitService, sshLogger, repoBasePath)
 
 		// Initialize git shell service
-		gitShell := ssh.NewGitShellService(logger)
+		gitShell := ssh.NewGitShellService(packStore, sshLogger)
 
 		sshConfig := ssh.SSHServerConfig{
 			Port:        cfg.SSH.Port,
 			HostKeyPath: cfg.SSH.HostKeyPath,
+			SFTPEnabled: cfg.SSH.SFTPEnabled,
+			// Replicas of a shared-nothing deployment present the same
+			// host key
+			SharedHostKeys: cfg.Cluster.SharedNothing,
 		}
 
 		// Create SSH server adapter
@@ -109,18 +147,32 @@ func main() {
 			sshConfig,
 			sshRepoService,
 			gitShell,
-			logger,
+			sshLogger,
 			database.DB,
 		)
 		if err != nil {
 			logger.WithError(err).Fatal("Failed to initialize SSH server")
 		}
+		permissionService := services.NewPermissionService(database.DB, nil)
+		if cfg.SSH.SFTPEnabled {
+			sshServer.SetRepositoryBrowser(ssh.NewRepositoryBrowserAdapter(gitService, permissionService))
+		}
+		lfsTTL := time.Duration(cfg.LFS.TokenMinutes) * time.Minute
+		sshServer.SetLFSAuthenticator(ssh.NewLFSAuthenticatorAdapter(database.DB, auth.NewJWTManager(cfg.JWT), permissionService, cfg.Application.BaseURL, lfsTTL))
 	}
 
 	// Context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	if packStore != nil && cfg.Storage.Packs.IntervalMinutes > 0 {
+		interval := time.Duration(cfg.Storage.Packs.IntervalMinutes) * time.Minute
+		background.Elected("pack_offload", func(ctx context.Context) {
+			packStore.StartScheduler(ctx, interval)
+		})
+	}
+	background.Start(ctx)
+
 	// Start SSH server if enabled
 	if sshServer != nil {
 		go func() {
@@ -146,7 +198,7 @@ func main() {
 
 	logger.Info("Shutting down servers...")
 
-	// Cancel context to stop SSH server
+	// Cancel context to stop the SSH server and background schedulers
 	cancel()
 
 	// Graceful shutdown of HTTP server
@@ -164,5 +216,12 @@ func main() {
 		}
 	}
 
+	// Let schedulers and job workers finish what they are running
+	if err := background.Wait(shutdownCtx); err != nil {
+		logger.WithError(err).Warn("Background schedulers did not stop in time")
+	}
+
+	reporter.Flush(5 * time.Second)
+
 	logger.Info("Servers stopped")
 }
@@ -48,12 +48,13 @@ func main() {
 	repositoryService := services.NewRepositoryService(database.DB, gitService, logger, repoBasePath)
 
 	// Initialize git shell service
-	gitShell := ssh.NewGitShellService(logger)
+	gitShell := ssh.NewGitShellService(nil, logger)
 
 	// Configure SSH server
 	sshConfig := ssh.SSHServerConfig{
 		Port:        cfg.SSH.Port,
 		HostKeyPath: cfg.SSH.HostKeyPath,
+		SFTPEnabled: cfg.SSH.SFTPEnabled,
 	}
 
 	// Create SSH server adapter
@@ -70,6 +71,10 @@ func main() {
 	if err != nil {
 		logger.WithError(err).Fatal("Failed to initialize SSH server")
 	}
+	if cfg.SSH.SFTPEnabled {
+		permissionService := services.NewPermissionService(database.DB, nil)
+		sshServer.SetRepositoryBrowser(ssh.NewRepositoryBrowserAdapter(gitService, permissionService))
+	}
 
 	// Context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
@@ -18,6 +18,7 @@ export default function RepositorySettingsPage() {
   const [, setRepository] = useState<Repository | null>(null);
   const [loading, setLoading] = useState(true);
   const [saving, setSaving] = useState(false);
+  const [settingsEtag, setSettingsEtag] = useState<string | undefined>();
   const [activeTab, setActiveTab] = useState('general');
   const [formData, setFormData] = useState({
     name: '',
@@ -32,6 +33,7 @@ export default function RepositorySettingsPage() {
         setLoading(true);
         const response = await repoApi.getRepositorySettings(owner, repo);
         const settings = response.data;
+        setSettingsEtag(response.etag);
         setFormData({
           name: settings.name,
           description: settings.description || '',
@@ -51,7 +53,8 @@ export default function RepositorySettingsPage() {
   const handleSave = async () => {
     try {
       setSaving(true);
-      await repoApi.updateRepositorySettings(owner, repo, formData);
+      const response = await repoApi.updateRepositorySettings(owner, repo, formData, settingsEtag);
+      setSettingsEtag(response.etag);
       // Show success message
     } catch (err) {
       console.error('Failed to save repository settings', err);
@@ -13,15 +13,15 @@ export default function ImportPage() {
     setStatus('')
     setJobId(null)
     try {
-      const res = await fetch('/api/v1/repositories/import', {
+      const res = await fetch('/api/v1/import', {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ url, token }),
       })
       if (!res.ok) throw new Error((await res.json()).error || res.statusText)
       const data = await res.json()
-      setJobId(data.job_id)
-      setStatus('pending')
+      setJobId(data.id)
+      setStatus(data.status)
     } catch (err: any) {
       setStatus(err.message)
     }
@@ -30,10 +30,10 @@ export default function ImportPage() {
   const checkStatus = async () => {
     if (!jobId) return
     try {
-      const res = await fetch(`/api/v1/repositories/import/${jobId}`)
+      const res = await fetch(`/api/v1/import/${jobId}`)
       if (!res.ok) throw new Error(res.statusText)
       const data = await res.json()
-      setStatus(data.status)
+      setStatus(data.status === 'failed' ? `failed: ${data.error_message}` : `${data.status} ${data.stage || ''} (${data.progress}%)`)
     } catch (err: any) {
       setStatus(err.message)
     }
@@ -55,7 +55,7 @@ export default function ImportPage() {
       </form>
       {jobId && (
         <div>
-          <p>Job ID: {jobId}</p>
+          <p>Import ID: {jobId}</p>
           <button onClick={checkStatus}>Check Status</button>
           <p>Status: {status}</p>
         </div>
@@ -104,23 +104,38 @@ interface MemberActivityMetrics {
   }>;
 }
 
+interface SecurityAlertCounts {
+  open: number;
+  critical: number;
+  high: number;
+  medium: number;
+  low: number;
+}
+
 interface SecurityMetrics {
-  period: string;
-  security_score: number;
-  vulnerabilities_found: number;
-  vulnerabilities_fixed: number;
-  security_alerts: Array<{
-    type: string;
-    severity: string;
-    title: string;
-    created_at: string;
-  }>;
-  compliance_status: Record<string, boolean>;
-  policy_violations: Array<{
-    policy_name: string;
-    count: number;
-    last_occurred: string;
+  score: number;
+  components: Array<{
+    name: string;
+    weight: number;
+    ratio: number;
+    points: number;
+    detail: string;
   }>;
+  alerts: Record<string, SecurityAlertCounts>;
+  repositories: number;
+  unprotected_default_branches: string[];
+  two_factor: {
+    required: boolean;
+    members: number;
+    enabled: number;
+    missing: string[];
+  };
+  credentials: {
+    inactive_days: number;
+    total: number;
+    inactive: number;
+    never_used: number;
+  };
 }
 
 export function OrganizationAnalyticsDashboard({ orgName }: AnalyticsDashboardProps) {
@@ -142,7 +157,7 @@ export function OrganizationAnalyticsDashboard({ orgName }: AnalyticsDashboardPr
       const [dashboardResponse, memberResponse, securityResponse] = await Promise.all([
         api.get(`/organizations/${orgName}/analytics/overview`),
         api.get(`/organizations/${orgName}/analytics/members?period=${period}`),
-        api.get(`/organizations/${orgName}/analytics/security?period=${period}`)
+        api.get(`/organizations/${orgName}/analytics/security`)
       ]);
 
       setDashboardMetrics(dashboardResponse.data);
@@ -490,42 +505,49 @@ export function OrganizationAnalyticsDashboard({ orgName }: AnalyticsDashboardPr
           <div className="grid grid-cols-1 md:grid-cols-4 gap-6">
             <Card>
               <div className="p-6 text-center">
-                <p className="text-2xl font-bold text-foreground">{formatPercentage(securityMetrics.security_score)}</p>
+                <p className="text-2xl font-bold text-foreground">{securityMetrics.score.toFixed(1)}</p>
                 <p className="text-sm text-muted-foreground">Security Score</p>
-                <Badge variant={securityMetrics.security_score >= 80 ? 'default' : 'secondary'} className="mt-2">
-                  {securityMetrics.security_score >= 80 ? 'Good' : 'Needs Attention'}
+                <Badge variant={securityMetrics.score >= 80 ? 'default' : 'secondary'} className="mt-2">
+                  {securityMetrics.score >= 80 ? 'Good' : 'Needs Attention'}
                 </Badge>
               </div>
             </Card>
             <Card>
               <div className="p-6 text-center">
-                <p className="text-2xl font-bold text-foreground">{securityMetrics.vulnerabilities_found}</p>
-                <p className="text-sm text-muted-foreground">Vulnerabilities Found</p>
+                <p className="text-2xl font-bold text-foreground">
+                  {Object.values(securityMetrics.alerts).reduce((total, counts) => total + counts.open, 0)}
+                </p>
+                <p className="text-sm text-muted-foreground">Open Alerts</p>
               </div>
             </Card>
             <Card>
               <div className="p-6 text-center">
-                <p className="text-2xl font-bold text-foreground">{securityMetrics.vulnerabilities_fixed}</p>
-                <p className="text-sm text-muted-foreground">Vulnerabilities Fixed</p>
+                <p className="text-2xl font-bold text-foreground">{securityMetrics.unprotected_default_branches.length}</p>
+                <p className="text-sm text-muted-foreground">Unprotected Default Branches</p>
               </div>
             </Card>
             <Card>
               <div className="p-6 text-center">
-                <p className="text-2xl font-bold text-foreground">{securityMetrics.policy_violations.length}</p>
-                <p className="text-sm text-muted-foreground">Policy Violations</p>
+                <p className="text-2xl font-bold text-foreground">
+                  {securityMetrics.two_factor.enabled}/{securityMetrics.two_factor.members}
+                </p>
+                <p className="text-sm text-muted-foreground">Members with 2FA</p>
               </div>
             </Card>
           </div>
 
           <Card>
             <div className="p-6">
-              <h3 className="text-lg font-medium text-foreground mb-4">Compliance Status</h3>
-              <div className="grid grid-cols-1 md:grid-cols-3 gap-4">
-                {Object.entries(securityMetrics.compliance_status).map(([standard, compliant]) => (
-                  <div key={standard} className="flex items-center justify-between p-4 border border-border rounded-lg">
-                    <span className="font-medium text-foreground">{standard}</span>
-                    <Badge variant={compliant ? 'default' : 'secondary'}>
-                      {compliant ? 'Compliant' : 'Non-Compliant'}
+              <h3 className="text-lg font-medium text-foreground mb-4">Score Breakdown</h3>
+              <div className="space-y-4">
+                {securityMetrics.components.map((component) => (
+                  <div key={component.name} className="flex items-center justify-between p-4 border border-border rounded-lg">
+                    <div>
+                      <p className="font-medium text-foreground">{component.name.replace(/_/g, ' ')}</p>
+                      <p className="text-sm text-muted-foreground mt-1">{component.detail}</p>
+                    </div>
+                    <Badge variant={component.ratio >= 0.8 ? 'default' : 'secondary'}>
+                      {component.points} / {component.weight}
                     </Badge>
                   </div>
                 ))}
@@ -533,31 +555,21 @@ export function OrganizationAnalyticsDashboard({ orgName }: AnalyticsDashboardPr
             </div>
           </Card>
 
-          {securityMetrics.security_alerts.length > 0 && (
-            <Card>
-              <div className="p-6">
-                <h3 className="text-lg font-medium text-foreground mb-4">Recent Security Alerts</h3>
-                <div className="space-y-4">
-                  {securityMetrics.security_alerts.slice(0, 5).map((alert, index) => (
-                    <div key={index} className="flex items-start space-x-4 p-4 border border-border rounded-lg">
-                      <div className="flex-shrink-0">
-                        <Badge variant={alert.severity === 'high' ? 'destructive' : alert.severity === 'medium' ? 'default' : 'secondary'}>
-                          {alert.severity}
-                        </Badge>
-                      </div>
-                      <div className="flex-1">
-                        <p className="font-medium text-foreground">{alert.title}</p>
-                        <p className="text-sm text-muted-foreground mt-1">{alert.type}</p>
-                        <p className="text-xs text-muted-foreground mt-2">
-                          {new Date(alert.created_at).toLocaleDateString()}
-                        </p>
-                      </div>
-                    </div>
-                  ))}
-                </div>
+          <Card>
+            <div className="p-6">
+              <h3 className="text-lg font-medium text-foreground mb-4">Open Alerts</h3>
+              <div className="grid grid-cols-1 md:grid-cols-3 gap-4">
+                {Object.entries(securityMetrics.alerts).map(([category, counts]) => (
+                  <div key={category} className="p-4 border border-border rounded-lg">
+                    <p className="font-medium text-foreground">{category.replace(/_/g, ' ')}</p>
+                    <p className="text-sm text-muted-foreground mt-1">
+                      {counts.critical} critical, {counts.high} high, {counts.medium} medium, {counts.low} low
+                    </p>
+                  </div>
+                ))}
               </div>
-            </Card>
-          )}
+            </div>
+          </Card>
         </div>
       )}
     </div>
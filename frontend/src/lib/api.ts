@@ -45,6 +45,11 @@ api.interceptors.response.use(
   }
 );
 
+// ifMatch sends the version an edit is based on so concurrent edits are
+// detected instead of silently overwritten
+const ifMatch = (version?: number): AxiosRequestConfig | undefined =>
+  version ? { headers: { 'If-Match': `"${version}"` } } : undefined;
+
 // Generic API methods
 export const apiClient = {
   get: async <T = any>(url: string, config?: AxiosRequestConfig): Promise<ApiResponse<T>> => {
@@ -164,12 +169,14 @@ export const repoApi = {
     return apiClient.post('/repositories', payload);
   },
 
+  // version is the repository version the edit is based on; a stale one is
+  // rejected with 409 and the current repository
   updateRepository: (owner: string, repo: string, data: Partial<{
     name: string;
     description: string;
     private: boolean;
     default_branch: string;
-  }>) => apiClient.patch(`/repositories/${owner}/${repo}`, data),
+  }>, version?: number) => apiClient.patch(`/repositories/${owner}/${repo}`, data, ifMatch(version)),
 
   deleteRepository: (owner: string, repo: string) =>
     apiClient.delete(`/repositories/${owner}/${repo}`),
@@ -204,6 +211,9 @@ export const repoApi = {
   getBranches: (owner: string, repo: string) => 
     api.get(`/repositories/${owner}/${repo}/branches`),
 
+  renameBranch: (owner: string, repo: string, branch: string, newName: string) =>
+    api.post(`/repositories/${owner}/${repo}/branches/${encodeURIComponent(branch)}/rename`, { new_name: newName }),
+
   getCommits: (owner: string, repo: string, ref?: string, path?: string) => {
     const params: Record<string, string> = {};
     if (ref) params.ref = ref;
@@ -227,13 +237,22 @@ export const repoApi = {
   updateRepositoryStats: (owner: string, repo: string) =>
     apiClient.post(`/repositories/${owner}/${repo}/stats/update`),
   // Repository settings on dedicated branch
-  getRepositorySettings: (owner: string, repo: string) =>
-    apiClient.get(`/repositories/${owner}/${repo}/settings`),
-  updateRepositorySettings: (
+  // The settings ETag must be sent back when saving
+  getRepositorySettings: async (owner: string, repo: string) => {
+    const response = await api.get(`/repositories/${owner}/${repo}/settings`);
+    return { ...response.data, etag: response.headers['etag'] as string | undefined };
+  },
+  updateRepositorySettings: async (
     owner: string,
     repo: string,
-    data: Record<string, any>
-  ) => apiClient.put(`/repositories/${owner}/${repo}/settings`, data),
+    data: Record<string, any>,
+    etag?: string
+  ) => {
+    const response = await api.put(`/repositories/${owner}/${repo}/settings`, data, {
+      headers: etag ? { 'If-Match': etag } : undefined,
+    });
+    return { ...response.data, etag: response.headers['etag'] as string | undefined };
+  },
 };
 
 // Organization API methods
@@ -251,7 +270,7 @@ export const orgApi = {
     description: string;
     location: string;
     website: string;
-  }>) => apiClient.patch(`/organizations/${org}`, data),
+  }>, version?: number) => apiClient.patch(`/organizations/${org}`, data, ifMatch(version)),
 };
 
 // Search API methods
@@ -29,7 +29,7 @@ interface OrganizationActions {
   clearOrganizations: () => void;
 }
 
-export const useOrganizationStore = create<OrganizationState & OrganizationActions>((set) => ({
+export const useOrganizationStore = create<OrganizationState & OrganizationActions>((set, get) => ({
   // State
   organizations: [],
   currentOrganization: null,
@@ -122,7 +122,10 @@ export const useOrganizationStore = create<OrganizationState & OrganizationActio
   updateOrganization: async (org: string, data) => {
     set({ isLoading: true, error: null });
     try {
-      const response = await orgApi.updateOrganization(org, data);
+      const known = get().currentOrganization?.login === org
+        ? get().currentOrganization
+        : get().organizations.find(o => o.login === org);
+      const response = await orgApi.updateOrganization(org, data, known?.version);
       if (response.success && response.data) {
         const updatedOrg = response.data as Organization;
         set((state) => ({
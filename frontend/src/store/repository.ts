@@ -32,7 +32,7 @@ interface RepositoryActions {
   resetRepositories: () => void;
 }
 
-export const useRepositoryStore = create<RepositoryState & RepositoryActions>((set) => ({
+export const useRepositoryStore = create<RepositoryState & RepositoryActions>((set, get) => ({
   // State
   repositories: [],
   currentRepository: null,
@@ -206,7 +206,11 @@ export const useRepositoryStore = create<RepositoryState & RepositoryActions>((s
   updateRepository: async (owner: string, repo: string, data) => {
     set({ isLoading: true, error: null });
     try {
-      const response = await repoApi.updateRepository(owner, repo, data);
+      const fullName = `${owner}/${repo}`;
+      const known = get().currentRepository?.full_name === fullName
+        ? get().currentRepository
+        : get().repositories.find(r => r.full_name === fullName);
+      const response = await repoApi.updateRepository(owner, repo, data, known?.version);
       
       // Handle response - apiClient returns data directly or wrapped response
       let updatedRepo: Repository | null = null;
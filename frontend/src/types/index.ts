@@ -30,6 +30,8 @@ export interface Repository {
   created_at: string;
   updated_at: string;
   pushed_at: string;
+  /** Bumped on every settings change; sent back as If-Match when editing */
+  version?: number;
 }
 
 export interface Organization {
@@ -45,6 +47,8 @@ export interface Organization {
   following: number;
   created_at: string;
   updated_at: string;
+  /** Bumped on every settings change; sent back as If-Match when editing */
+  version?: number;
 }
 
 /**
@@ -82,6 +86,7 @@ export interface PullRequest {
   additions: number;
   deletions: number;
   changed_files: number;
+  unresolved_review_threads: number;
   created_at: string;
   updated_at: string;
   issue: Issue;
@@ -195,9 +200,13 @@ export interface ReviewComment {
   start_side: 'LEFT' | 'RIGHT';
   body: string;
   in_reply_to_id?: string;
+  resolved: boolean;
+  resolved_by_id?: string;
+  resolved_at?: string;
   created_at: string;
   updated_at: string;
   user?: User;
+  resolved_by?: User;
   review?: Review;
   in_reply_to?: ReviewComment;
   replies: ReviewComment[];
@@ -225,6 +234,9 @@ export interface CreatePullRequestRequest {
   head_repository_id?: string;
   draft: boolean;
   maintainer_can_modify: boolean;
+  reviewers?: string[];
+  assignees?: string[];
+  labels?: string[];
 }
 
 export interface CreateReviewRequest {
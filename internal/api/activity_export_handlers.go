@@ -0,0 +1,88 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ActivityExportHandlers serves repository activity archives
+type ActivityExportHandlers struct {
+	activityExportService services.ActivityExportService
+	repositoryService     services.RepositoryService
+	logger                *logrus.Logger
+}
+
+func NewActivityExportHandlers(activityExportService services.ActivityExportService, repositoryService services.RepositoryService, logger *logrus.Logger) *ActivityExportHandlers {
+	return &ActivityExportHandlers{
+		activityExportService: activityExportService,
+		repositoryService:     repositoryService,
+		logger:                logger,
+	}
+}
+
+// ExportActivity handles GET /api/v1/repositories/{owner}/{repo}/events/export
+// It streams the repository's history as newline-delimited webhook payloads,
+// optionally limited with events=push,issues and since/until (RFC 3339).
+func (h *ActivityExportHandlers) ExportActivity(c *gin.Context) {
+	owner, name := c.Param("owner"), c.Param("repo")
+	repo, err := h.repositoryService.Get(c.Request.Context(), owner, name)
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return
+	}
+	if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Repository admin permission required"})
+		return
+	}
+
+	var opts services.ActivityExportOptions
+	if events := c.Query("events"); events != "" {
+		for _, event := range strings.Split(events, ",") {
+			event = strings.TrimSpace(event)
+			if !isActivityExportEvent(event) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unsupported event %q, expected one of %s", event, strings.Join(services.ActivityExportEvents, ", "))})
+				return
+			}
+			opts.Events = append(opts.Events, event)
+		}
+	}
+	for param, target := range map[string]**time.Time{"since": &opts.Since, "until": &opts.Until} {
+		if value := c.Query(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s, expected an RFC 3339 timestamp", param)})
+				return
+			}
+			*target = &parsed
+		}
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s-events.ndjson"`, owner, name))
+	c.Status(http.StatusOK)
+	if _, err := h.activityExportService.Export(c.Request.Context(), repo, owner+"/"+name, opts, c.Writer); err != nil {
+		// Headers are already sent, so the truncated archive is all the client sees
+		h.logger.WithError(err).WithField("repository_id", repo.ID).Error("Failed to export repository activity")
+	}
+}
+
+func isActivityExportEvent(event string) bool {
+	for _, supported := range services.ActivityExportEvents {
+		if event == supported {
+			return true
+		}
+	}
+	return false
+}
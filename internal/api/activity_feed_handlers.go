@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ActivityFeedHandlers serves the user and organization event timelines
+type ActivityFeedHandlers struct {
+	feedService services.ActivityFeedService
+	logger      *logrus.Logger
+}
+
+// NewActivityFeedHandlers creates a new ActivityFeedHandlers
+func NewActivityFeedHandlers(feedService services.ActivityFeedService, logger *logrus.Logger) *ActivityFeedHandlers {
+	return &ActivityFeedHandlers{feedService: feedService, logger: logger}
+}
+
+// feedOptions parses the page, per_page and type query parameters; type
+// is a comma separated list of event types
+func feedOptions(c *gin.Context) services.FeedOptions {
+	opts := services.FeedOptions{Page: 1, PerPage: 30}
+	if val, err := strconv.Atoi(c.Query("page")); err == nil && val > 0 {
+		opts.Page = val
+	}
+	if val, err := strconv.Atoi(c.Query("per_page")); err == nil && val > 0 && val <= 100 {
+		opts.PerPage = val
+	}
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			opts.Types = append(opts.Types, t)
+		}
+	}
+	return opts
+}
+
+func (h *ActivityFeedHandlers) respond(c *gin.Context, opts services.FeedOptions, events []*services.FeedEvent, hasMore bool, err error) {
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFeedNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidFeedEventType):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "types": services.FeedEventTypes})
+		default:
+			h.logger.WithError(err).Error("Failed to get events")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get events"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events": events,
+		"pagination": gin.H{
+			"page":     opts.Page,
+			"per_page": opts.PerPage,
+			"has_more": hasMore,
+		},
+		"filters": gin.H{
+			"type": opts.Types,
+		},
+	})
+}
+
+// GetUserEvents handles GET /api/v1/users/{username}/events
+//
+// Lists the user's pushes, stars, releases and new repositories, newest
+// first, leaving out repositories the caller cannot read. Only the latest
+// 300 events can be paged.
+func (h *ActivityFeedHandlers) GetUserEvents(c *gin.Context) {
+	opts := feedOptions(c)
+	events, hasMore, err := h.feedService.UserEvents(c.Request.Context(), c.Param("username"), opts)
+	h.respond(c, opts, events, hasMore, err)
+}
+
+// GetOrganizationEvents handles GET /api/v1/organizations/{org}/events
+//
+// Lists the pushes, stars, releases and new repositories in the
+// organization's repositories the caller can read, newest first.
+func (h *ActivityFeedHandlers) GetOrganizationEvents(c *gin.Context) {
+	opts := feedOptions(c)
+	events, hasMore, err := h.feedService.OrganizationEvents(c.Request.Context(), c.Param("org"), opts)
+	h.respond(c, opts, events, hasMore, err)
+}
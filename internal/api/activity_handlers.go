@@ -2,12 +2,16 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/a5c-ai/hub/internal/git"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/a5c-ai/hub/internal/services"
 	"github.com/gin-gonic/gin"
@@ -20,15 +24,17 @@ import (
 type ActivityHandlers struct {
 	repositoryService services.RepositoryService
 	activityService   services.ActivityService
+	watchService      services.WatchService
 	db                *gorm.DB
 	logger            *logrus.Logger
 }
 
 // NewActivityHandlers creates a new activity handlers instance
-func NewActivityHandlers(repositoryService services.RepositoryService, activityService services.ActivityService, db *gorm.DB, logger *logrus.Logger) *ActivityHandlers {
+func NewActivityHandlers(repositoryService services.RepositoryService, activityService services.ActivityService, watchService services.WatchService, db *gorm.DB, logger *logrus.Logger) *ActivityHandlers {
 	return &ActivityHandlers{
 		repositoryService: repositoryService,
 		activityService:   activityService,
+		watchService:      watchService,
 		db:                db,
 		logger:            logger,
 	}
@@ -153,12 +159,18 @@ func (h *ActivityHandlers) WatchRepository(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
+	userIDInterface, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
+	userID, err := parseUserID(userIDInterface)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
 	// Get repository first
 	repo, err := h.repositoryService.Get(c.Request.Context(), owner, repoName)
 	if err != nil {
@@ -174,6 +186,8 @@ func (h *ActivityHandlers) WatchRepository(c *gin.Context) {
 		Subscribed bool   `json:"subscribed"`
 		Ignored    bool   `json:"ignored"`
 		Reason     string `json:"reason,omitempty"`
+		// Events makes the watch custom; an empty list resets it
+		Events *[]string `json:"events,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -181,24 +195,29 @@ func (h *ActivityHandlers) WatchRepository(c *gin.Context) {
 		return
 	}
 
-	// For now, just return success
-	// In a full implementation, this would create/update a subscription record
+	var watch *models.RepositoryWatch
+	if req.Events != nil && !req.Ignored {
+		watch, err = h.watchService.SetEvents(c.Request.Context(), userID, repo.ID, *req.Events)
+	} else {
+		watch, err = h.watchService.Watch(c.Request.Context(), userID, repo.ID, req.Ignored, req.Reason)
+	}
+	if errors.Is(err, services.ErrInvalidWatchEvent) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update repository subscription")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update repository subscription"})
+		return
+	}
+
 	h.logger.WithFields(logrus.Fields{
-		"user_id":    userID,
-		"repo_id":    repo.ID,
-		"subscribed": req.Subscribed,
-		"ignored":    req.Ignored,
-		"reason":     req.Reason,
+		"user_id": userID,
+		"repo_id": repo.ID,
+		"ignored": watch.Ignored,
 	}).Info("Repository subscription updated")
 
-	c.JSON(http.StatusOK, gin.H{
-		"subscribed":     req.Subscribed,
-		"ignored":        req.Ignored,
-		"reason":         req.Reason,
-		"created_at":     "2024-01-15T10:30:00Z",
-		"url":            "/api/v1/repositories/" + owner + "/" + repoName + "/subscription",
-		"repository_url": "/api/v1/repositories/" + owner + "/" + repoName,
-	})
+	c.JSON(http.StatusOK, subscriptionResponse(watch, owner, repoName))
 }
 
 // UnwatchRepository handles DELETE /api/v1/repositories/{owner}/{repo}/subscription
@@ -211,12 +230,18 @@ func (h *ActivityHandlers) UnwatchRepository(c *gin.Context) {
 		return
 	}
 
-	userID, exists := c.Get("user_id")
+	userIDInterface, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
 		return
 	}
 
+	userID, err := parseUserID(userIDInterface)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
 	// Get repository first
 	repo, err := h.repositoryService.Get(c.Request.Context(), owner, repoName)
 	if err != nil {
@@ -228,8 +253,12 @@ func (h *ActivityHandlers) UnwatchRepository(c *gin.Context) {
 		return
 	}
 
-	// For now, just return success
-	// In a full implementation, this would delete the subscription record
+	if err := h.watchService.Unwatch(c.Request.Context(), userID, repo.ID); err != nil {
+		h.logger.WithError(err).Error("Failed to remove repository subscription")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove repository subscription"})
+		return
+	}
+
 	h.logger.WithFields(logrus.Fields{
 		"user_id": userID,
 		"repo_id": repo.ID,
@@ -271,15 +300,49 @@ func (h *ActivityHandlers) GetRepositorySubscription(c *gin.Context) {
 		return
 	}
 
-	// Get real subscription data
-	subscription, err := h.getRepositorySubscription(c.Request.Context(), repo.ID, userID)
+	watch, err := h.watchService.Get(c.Request.Context(), userID, repo.ID)
+	if errors.Is(err, services.ErrWatchNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not watching this repository"})
+		return
+	}
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get repository subscription")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository subscription"})
 		return
 	}
 
-	c.JSON(http.StatusOK, subscription)
+	c.JSON(http.StatusOK, subscriptionResponse(watch, owner, repoName))
+}
+
+// GetWatchingDigest handles GET /api/v1/user/watching/digest. It summarizes
+// new commits, issues, pull requests and releases in watched repositories
+// since the poll that returned cursor; clients pass next_cursor back on the
+// following poll.
+func (h *ActivityHandlers) GetWatchingDigest(c *gin.Context) {
+	userIDInterface, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	userID, err := parseUserID(userIDInterface)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	digest, err := h.watchService.Digest(c.Request.Context(), userID, c.Query("cursor"), time.Now())
+	if errors.Is(err, services.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid cursor"})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to build watching digest")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build watching digest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, digest)
 }
 
 // Helper methods for real data operations
@@ -416,47 +479,103 @@ func (h *ActivityHandlers) getRepositoryContributors(ctx context.Context, repoID
 		return nil, err
 	}
 
+	// Co-authors named in Co-authored-by trailers are credited too
+	var coAuthors []struct {
+		Name        string
+		Email       string
+		CommitCount int64
+	}
+	err = h.db.WithContext(ctx).Model(&models.CommitTrailer{}).
+		Select("MAX(name) as name, email, COUNT(DISTINCT commit_id) as commit_count").
+		Where("repository_id = ? AND key = ? AND email <> ''", repoID, git.TrailerCoAuthoredBy).
+		Group("email").
+		Scan(&coAuthors).Error
+	if err != nil {
+		return nil, err
+	}
+
 	// Convert to contributor format
 	var contributors []gin.H
+	byEmail := make(map[string]gin.H)
 	for i, result := range results {
 		contributor := gin.H{
-			"id":            i + 1, // Simple ID for now
-			"name":          result.AuthorName,
-			"email":         result.AuthorEmail,
-			"avatar_url":    h.generateAvatarURL(result.AuthorEmail),
-			"contributions": result.CommitCount,
-			"additions":     result.Additions,
-			"deletions":     result.Deletions,
-			"type":          "user",
+			"id":                  i + 1, // Simple ID for now
+			"name":                result.AuthorName,
+			"email":               result.AuthorEmail,
+			"avatar_url":          h.generateAvatarURL(result.AuthorEmail),
+			"contributions":       result.CommitCount,
+			"co_authored_commits": int64(0),
+			"additions":           result.Additions,
+			"deletions":           result.Deletions,
+			"type":                "user",
 		}
+		contributors = append(contributors, contributor)
+		if _, seen := byEmail[strings.ToLower(result.AuthorEmail)]; !seen {
+			byEmail[strings.ToLower(result.AuthorEmail)] = contributor
+		}
+	}
+	for _, coAuthor := range coAuthors {
+		contributor, ok := byEmail[coAuthor.Email]
+		if !ok {
+			contributor = gin.H{
+				"id":            len(contributors) + 1,
+				"name":          coAuthor.Name,
+				"email":         coAuthor.Email,
+				"avatar_url":    h.generateAvatarURL(coAuthor.Email),
+				"contributions": int64(0),
+				"additions":     int64(0),
+				"deletions":     int64(0),
+				"type":          "user",
+			}
+			contributors = append(contributors, contributor)
+			byEmail[coAuthor.Email] = contributor
+		}
+		contributor["co_authored_commits"] = coAuthor.CommitCount
+		contributor["contributions"] = contributor["contributions"].(int64) + coAuthor.CommitCount
+	}
+	sort.SliceStable(contributors, func(i, j int) bool {
+		return contributors[i]["contributions"].(int64) > contributors[j]["contributions"].(int64)
+	})
+	if len(contributors) > 100 {
+		contributors = contributors[:100]
+	}
 
-		// Try to find matching user in database
+	// Try to find matching users in database
+	for _, contributor := range contributors {
 		var user models.User
-		if err := h.db.WithContext(ctx).Where("email = ?", result.AuthorEmail).First(&user).Error; err == nil {
+		if err := h.db.WithContext(ctx).Where("email = ?", contributor["email"]).First(&user).Error; err == nil {
 			contributor["id"] = user.ID
 			contributor["username"] = user.Username
 			if user.AvatarURL != "" {
 				contributor["avatar_url"] = user.AvatarURL
 			}
 		}
-
-		contributors = append(contributors, contributor)
 	}
 
 	return contributors, nil
 }
 
-func (h *ActivityHandlers) getRepositorySubscription(ctx context.Context, repoID, userID interface{}) (gin.H, error) {
-	// For now, return a basic subscription structure
-	// In a full implementation, this would query a repository_subscriptions table
+func subscriptionResponse(watch *models.RepositoryWatch, owner, repoName string) gin.H {
 	return gin.H{
-		"subscribed":     true,
-		"ignored":        false,
-		"reason":         "subscribed",
-		"created_at":     time.Now().Format(time.RFC3339),
-		"url":            fmt.Sprintf("/api/v1/repositories/%s/subscription", repoID),
-		"repository_url": fmt.Sprintf("/api/v1/repositories/%s", repoID),
-	}, nil
+		"subscribed":     !watch.Ignored,
+		"ignored":        watch.Ignored,
+		"reason":         watch.Reason,
+		"events":         watchEvents(watch),
+		"created_at":     watch.CreatedAt,
+		"url":            "/api/v1/repositories/" + owner + "/" + repoName + "/subscription",
+		"repository_url": "/api/v1/repositories/" + owner + "/" + repoName,
+	}
+}
+
+// watchEvents lists the events a watch notifies about
+func watchEvents(watch *models.RepositoryWatch) []string {
+	events := []string{}
+	for _, event := range []string{models.WatchEventIssues, models.WatchEventPullRequests, models.WatchEventCI} {
+		if watch.Watches(event) {
+			events = append(events, event)
+		}
+	}
+	return events
 }
 
 // Helper functions
@@ -484,17 +603,22 @@ func (h *ActivityHandlers) buildActivityPayload(event models.AnalyticsEvent) gin
 
 	switch event.EventType {
 	case models.EventRepositoryPush:
-		payload["ref"] = "refs/heads/main" // Default, could be parsed from metadata
-		// In a real implementation, you'd parse commits from metadata
-		payload["commits"] = []gin.H{
-			{
-				"sha":     "example_sha",
-				"message": "Commit message",
-				"author": gin.H{
-					"name":  event.Actor.Username,
-					"email": event.Actor.Email,
-				},
-			},
+		// Pushes carry the grouped ref updates recorded by the push aggregator
+		var group services.PushGroup
+		if event.Metadata == "" || json.Unmarshal([]byte(event.Metadata), &group) != nil {
+			break
+		}
+		payload["push_id"] = group.PushID
+		payload["ref_count"] = group.RefCount
+		payload["created"] = group.Created
+		payload["updated"] = group.Updated
+		payload["deleted"] = group.Deleted
+		payload["bulk"] = group.Bulk
+		payload["branches"] = group.Branches
+		payload["tags"] = group.Tags
+		payload["other"] = group.Other
+		if refs := group.Refs(); len(refs) > 0 {
+			payload["ref"] = refs[0].Ref
 		}
 
 	case models.EventRepositoryPullRequest:
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/a5c-ai/hub/internal/auth"
+	"github.com/a5c-ai/hub/internal/logging"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -19,6 +20,8 @@ type AdminHandlers struct {
 	authService auth.AuthService
 	db          *gorm.DB
 	logger      *logrus.Logger
+	// audit records changes to users in the audit log
+	audit *logrus.Logger
 }
 
 // NewAdminHandlers creates a new admin handlers instance
@@ -27,6 +30,7 @@ func NewAdminHandlers(authService auth.AuthService, db *gorm.DB, logger *logrus.
 		authService: authService,
 		db:          db,
 		logger:      logger,
+		audit:       logging.Default().Audit(logger),
 	}
 }
 
@@ -56,6 +60,8 @@ type AdminUserResponse struct {
 	TwoFactorEnabled bool       `json:"two_factor_enabled"`
 	IsActive         bool       `json:"is_active"`
 	IsAdmin          bool       `json:"is_admin"`
+	IsServiceAccount bool       `json:"is_service_account"`
+	IsExternal       bool       `json:"is_external"`
 	CreatedAt        time.Time  `json:"created_at"`
 	UpdatedAt        time.Time  `json:"updated_at"`
 	LastLoginAt      *time.Time `json:"last_login_at"`
@@ -65,30 +71,34 @@ type AdminUserResponse struct {
 
 // UserUpdateRequest represents the request body for updating a user
 type UserUpdateRequest struct {
-	FullName    *string `json:"full_name,omitempty"`
-	Email       *string `json:"email,omitempty"`
-	Bio         *string `json:"bio,omitempty"`
-	Location    *string `json:"location,omitempty"`
-	Website     *string `json:"website,omitempty"`
-	Company     *string `json:"company,omitempty"`
-	AvatarURL   *string `json:"avatar_url,omitempty"`
-	PhoneNumber *string `json:"phone_number,omitempty"`
-	IsActive    *bool   `json:"is_active,omitempty"`
-	IsAdmin     *bool   `json:"is_admin,omitempty"`
+	FullName         *string `json:"full_name,omitempty"`
+	Email            *string `json:"email,omitempty"`
+	Bio              *string `json:"bio,omitempty"`
+	Location         *string `json:"location,omitempty"`
+	Website          *string `json:"website,omitempty"`
+	Company          *string `json:"company,omitempty"`
+	AvatarURL        *string `json:"avatar_url,omitempty"`
+	PhoneNumber      *string `json:"phone_number,omitempty"`
+	IsActive         *bool   `json:"is_active,omitempty"`
+	IsAdmin          *bool   `json:"is_admin,omitempty"`
+	IsServiceAccount *bool   `json:"is_service_account,omitempty"`
 }
 
 // UserCreateRequest represents the request body for creating a user
 type UserCreateRequest struct {
-	Username    string `json:"username" binding:"required,min=3,max=50"`
-	Email       string `json:"email" binding:"required,email"`
-	Password    string `json:"password" binding:"required,min=12"`
-	FullName    string `json:"full_name" binding:"required,min=1,max=255"`
-	Bio         string `json:"bio,omitempty"`
-	Location    string `json:"location,omitempty"`
-	Website     string `json:"website,omitempty"`
-	Company     string `json:"company,omitempty"`
-	PhoneNumber string `json:"phone_number,omitempty"`
-	IsAdmin     bool   `json:"is_admin,omitempty"`
+	Username         string `json:"username" binding:"required,min=3,max=50"`
+	Email            string `json:"email" binding:"required,email"`
+	Password         string `json:"password" binding:"required,min=12"`
+	FullName         string `json:"full_name" binding:"required,min=1,max=255"`
+	Bio              string `json:"bio,omitempty"`
+	Location         string `json:"location,omitempty"`
+	Website          string `json:"website,omitempty"`
+	Company          string `json:"company,omitempty"`
+	PhoneNumber      string `json:"phone_number,omitempty"`
+	IsAdmin          bool   `json:"is_admin,omitempty"`
+	IsServiceAccount bool   `json:"is_service_account,omitempty"`
+	// IsExternal creates an external collaborator account
+	IsExternal bool `json:"is_external,omitempty"`
 }
 
 // toAdminUserResponse converts a user model to admin user response
@@ -107,6 +117,8 @@ func toAdminUserResponse(user *models.User) AdminUserResponse {
 		TwoFactorEnabled: user.TwoFactorEnabled,
 		IsActive:         user.IsActive,
 		IsAdmin:          user.IsAdmin,
+		IsServiceAccount: user.IsServiceAccount,
+		IsExternal:       user.IsExternal,
 		CreatedAt:        user.CreatedAt,
 		UpdatedAt:        user.UpdatedAt,
 		LastLoginAt:      user.LastLoginAt,
@@ -273,20 +285,22 @@ func (h *AdminHandlers) CreateUser(c *gin.Context) {
 
 	// Create new user
 	user := models.User{
-		ID:           uuid.New(),
-		Username:     req.Username,
-		Email:        req.Email,
-		PasswordHash: string(hashedPassword),
-		FullName:     req.FullName,
-		Bio:          req.Bio,
-		Location:     req.Location,
-		Website:      req.Website,
-		Company:      req.Company,
-		PhoneNumber:  req.PhoneNumber,
-		IsActive:     true, // Admin-created users are active by default
-		IsAdmin:      req.IsAdmin,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
+		ID:               uuid.New(),
+		Username:         req.Username,
+		Email:            req.Email,
+		PasswordHash:     string(hashedPassword),
+		FullName:         req.FullName,
+		Bio:              req.Bio,
+		Location:         req.Location,
+		Website:          req.Website,
+		Company:          req.Company,
+		PhoneNumber:      req.PhoneNumber,
+		IsActive:         true, // Admin-created users are active by default
+		IsAdmin:          req.IsAdmin,
+		IsServiceAccount: req.IsServiceAccount,
+		IsExternal:       req.IsExternal && !req.IsAdmin,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
 	}
 
 	if err := h.db.Create(&user).Error; err != nil {
@@ -295,7 +309,7 @@ func (h *AdminHandlers) CreateUser(c *gin.Context) {
 		return
 	}
 
-	h.logger.WithFields(logrus.Fields{
+	h.audit.WithFields(logrus.Fields{
 		"user_id":  user.ID,
 		"username": user.Username,
 		"email":    user.Email,
@@ -378,6 +392,9 @@ func (h *AdminHandlers) UpdateUser(c *gin.Context) {
 	if req.IsAdmin != nil {
 		updates["is_admin"] = *req.IsAdmin
 	}
+	if req.IsServiceAccount != nil {
+		updates["is_service_account"] = *req.IsServiceAccount
+	}
 
 	// Update user
 	if err := h.db.Model(&user).Where("id = ?", userID).Updates(updates).Error; err != nil {
@@ -393,7 +410,7 @@ func (h *AdminHandlers) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	h.logger.WithFields(logrus.Fields{
+	h.audit.WithFields(logrus.Fields{
 		"user_id":  user.ID,
 		"username": user.Username,
 		"updates":  updates,
@@ -442,7 +459,7 @@ func (h *AdminHandlers) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	h.logger.WithFields(logrus.Fields{
+	h.audit.WithFields(logrus.Fields{
 		"user_id":  user.ID,
 		"username": user.Username,
 		"admin_id": currentUserID,
@@ -514,7 +531,7 @@ func (h *AdminHandlers) setUserStatus(c *gin.Context, isActive bool) {
 		action = "enabled"
 	}
 
-	h.logger.WithFields(logrus.Fields{
+	h.audit.WithFields(logrus.Fields{
 		"user_id":   user.ID,
 		"username":  user.Username,
 		"is_active": isActive,
@@ -593,7 +610,7 @@ func (h *AdminHandlers) SetUserRole(c *gin.Context) {
 		role = "admin"
 	}
 
-	h.logger.WithFields(logrus.Fields{
+	h.audit.WithFields(logrus.Fields{
 		"user_id":  user.ID,
 		"username": user.Username,
 		"is_admin": req.IsAdmin,
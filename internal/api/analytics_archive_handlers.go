@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"path/filepath"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// newAnalyticsArchiveBackend builds the storage backend for archived
+// analytics snapshots; the filesystem backend keeps them next to the
+// repositories
+func newAnalyticsArchiveBackend(cfg config.AnalyticsArchive, repoBasePath string) (storage.Backend, error) {
+	var stCfg storage.Config
+	stCfg.Backend = cfg.Backend
+	stCfg.Azure.AccountName = cfg.Azure.AccountName
+	stCfg.Azure.AccountKey = cfg.Azure.AccountKey
+	stCfg.Azure.ContainerName = cfg.Azure.ContainerName
+	stCfg.Filesystem.BasePath = filepath.Join(repoBasePath, "analytics-archive")
+	return storage.NewBackend(stCfg)
+}
+
+// AnalyticsArchiveHandlers serves the admin analytics compaction endpoints
+type AnalyticsArchiveHandlers struct {
+	archiveService services.AnalyticsArchiveService
+	logger         *logrus.Logger
+}
+
+func NewAnalyticsArchiveHandlers(archiveService services.AnalyticsArchiveService, logger *logrus.Logger) *AnalyticsArchiveHandlers {
+	return &AnalyticsArchiveHandlers{
+		archiveService: archiveService,
+		logger:         logger,
+	}
+}
+
+// GetArchivePolicy handles GET /api/v1/admin/analytics/archive
+func (h *AnalyticsArchiveHandlers) GetArchivePolicy(c *gin.Context) {
+	policy := h.archiveService.Policy()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":              policy.Enabled,
+		"interval_hours":       policy.IntervalHours,
+		"compact_after_months": policy.CompactAfterMonths,
+		"backend":              policy.Backend,
+	})
+}
+
+// Compact handles POST /api/v1/admin/analytics/archive/compact
+func (h *AnalyticsArchiveHandlers) Compact(c *gin.Context) {
+	result, err := h.archiveService.Compact(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to compact repository analytics")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compact repository analytics", "result": result})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
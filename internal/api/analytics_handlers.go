@@ -2,13 +2,16 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -17,17 +20,19 @@ import (
 
 // AnalyticsHandlers contains handlers for analytics-related endpoints
 type AnalyticsHandlers struct {
-	analyticsService services.AnalyticsService
-	logger           *logrus.Logger
-	db               *gorm.DB
+	analyticsService   services.AnalyticsService
+	preferencesService services.UserPreferencesService
+	logger             *logrus.Logger
+	db                 *gorm.DB
 }
 
 // NewAnalyticsHandlers creates a new analytics handlers instance
-func NewAnalyticsHandlers(analyticsService services.AnalyticsService, logger *logrus.Logger, db *gorm.DB) *AnalyticsHandlers {
+func NewAnalyticsHandlers(analyticsService services.AnalyticsService, preferencesService services.UserPreferencesService, logger *logrus.Logger, db *gorm.DB) *AnalyticsHandlers {
 	return &AnalyticsHandlers{
-		analyticsService: analyticsService,
-		logger:           logger,
-		db:               db,
+		analyticsService:   analyticsService,
+		preferencesService: preferencesService,
+		logger:             logger,
+		db:                 db,
 	}
 }
 
@@ -69,6 +74,7 @@ func (h *AnalyticsHandlers) GetRepositoryAnalytics(c *gin.Context) {
 	}
 
 	filters := services.InsightFilters{
+		Location:  h.location(c),
 		StartDate: startDate,
 		EndDate:   endDate,
 		Period:    period,
@@ -148,6 +154,7 @@ func (h *AnalyticsHandlers) GetRepositoryContributors(c *gin.Context) {
 	}
 
 	filters := services.InsightFilters{
+		Location:  h.location(c),
 		StartDate: startDate,
 		EndDate:   endDate,
 		Period:    period,
@@ -199,6 +206,7 @@ func (h *AnalyticsHandlers) GetRepositoryActivity(c *gin.Context) {
 	}
 
 	filters := services.InsightFilters{
+		Location:  h.location(c),
 		StartDate: startDate,
 		EndDate:   endDate,
 		Period:    period,
@@ -214,6 +222,57 @@ func (h *AnalyticsHandlers) GetRepositoryActivity(c *gin.Context) {
 	c.JSON(http.StatusOK, activityStats)
 }
 
+// GetRepositoryReviews handles GET /api/v1/repositories/:owner/:repo/analytics/reviews
+func (h *AnalyticsHandlers) GetRepositoryReviews(c *gin.Context) {
+	repoID, err := h.getRepositoryID(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		return
+	}
+	if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionRead) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		return
+	}
+
+	filters, ok := reviewAnalyticsFilters(c)
+	if !ok {
+		return
+	}
+	report, err := h.analyticsService.GetRepositoryReviewAnalytics(c.Request.Context(), repoID, filters)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get repository review analytics")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get review analytics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// reviewAnalyticsFilters reads the start_date and end_date of a review
+// analytics request, answering 400 when they are invalid
+func reviewAnalyticsFilters(c *gin.Context) (services.ReviewAnalyticsFilters, bool) {
+	var filters services.ReviewAnalyticsFilters
+	for param, dest := range map[string]**time.Time{"start_date": &filters.StartDate, "end_date": &filters.EndDate} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			if parsed, err = time.Parse(time.RFC3339, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a date (YYYY-MM-DD) or RFC 3339 timestamp", param)})
+				return filters, false
+			}
+		}
+		*dest = &parsed
+	}
+	if filters.StartDate != nil && filters.EndDate != nil && filters.StartDate.After(*filters.EndDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must not be after end_date"})
+		return filters, false
+	}
+	return filters, true
+}
+
 // GetRepositoryPerformance handles GET /api/v1/repositories/:owner/:repo/analytics/performance
 func (h *AnalyticsHandlers) GetRepositoryPerformance(c *gin.Context) {
 	owner := c.Param("owner")
@@ -250,6 +309,7 @@ func (h *AnalyticsHandlers) GetRepositoryPerformance(c *gin.Context) {
 	}
 
 	filters := services.InsightFilters{
+		Location:  h.location(c),
 		StartDate: startDate,
 		EndDate:   endDate,
 		Period:    period,
@@ -314,6 +374,7 @@ func (h *AnalyticsHandlers) GetRepositoryPulls(c *gin.Context) {
 	}
 
 	filters := services.InsightFilters{
+		Location:  h.location(c),
 		StartDate: startDate,
 		EndDate:   endDate,
 		Period:    period,
@@ -363,6 +424,7 @@ func (h *AnalyticsHandlers) GetUserAnalytics(c *gin.Context) {
 	}
 
 	filters := services.InsightFilters{
+		Location:  h.location(c),
 		StartDate: startDate,
 		EndDate:   endDate,
 		Period:    period,
@@ -441,6 +503,7 @@ func (h *AnalyticsHandlers) GetUserRepositories(c *gin.Context) {
 	}
 
 	filters := services.InsightFilters{
+		Location:  h.location(c),
 		StartDate: startDate,
 		EndDate:   endDate,
 		Period:    period,
@@ -495,6 +558,7 @@ func (h *AnalyticsHandlers) GetPublicUserAnalytics(c *gin.Context) {
 	}
 
 	filters := services.InsightFilters{
+		Location:  h.location(c),
 		StartDate: startDate,
 		EndDate:   endDate,
 		Period:    period,
@@ -558,6 +622,7 @@ func (h *AnalyticsHandlers) GetOrganizationAnalytics(c *gin.Context) {
 	}
 
 	filters := services.InsightFilters{
+		Location:  h.location(c),
 		StartDate: startDate,
 		EndDate:   endDate,
 		Period:    period,
@@ -607,6 +672,7 @@ func (h *AnalyticsHandlers) GetOrganizationMembers(c *gin.Context) {
 	}
 
 	filters := services.InsightFilters{
+		Location:  h.location(c),
 		StartDate: startDate,
 		EndDate:   endDate,
 		Period:    period,
@@ -623,6 +689,137 @@ func (h *AnalyticsHandlers) GetOrganizationMembers(c *gin.Context) {
 	c.JSON(http.StatusOK, insights.MemberStats)
 }
 
+// GetInactiveMembers handles GET /api/v1/organizations/:org/analytics/inactive-members
+func (h *AnalyticsHandlers) GetInactiveMembers(c *gin.Context) {
+	orgID, err := h.getOrganizationID(c.Request.Context(), c.Param("org"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	// Seat reports expose member emails and are limited to organization owners and admins
+	if !h.isAdmin(c) && !h.isOrganizationAdmin(c, orgID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Organization owner or admin access required"})
+		return
+	}
+
+	filters := services.InactiveMemberFilters{
+		IncludeServiceAccounts: c.Query("include_service_accounts") == "true",
+	}
+	if daysStr := c.Query("days"); daysStr != "" {
+		days, err := strconv.Atoi(daysStr)
+		if err != nil || days < 1 || days > 3650 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 3650"})
+			return
+		}
+		filters.Days = days
+	}
+
+	report, err := h.analyticsService.GetInactiveMembers(c.Request.Context(), orgID, filters)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get inactive members")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get inactive members"})
+		return
+	}
+
+	if c.DefaultQuery("format", "json") == "csv" {
+		data, err := report.CSV()
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to export inactive members")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export inactive members"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s-inactive-members.csv", c.Param("org")))
+		c.Data(http.StatusOK, "text/csv", data)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetOrganizationLanguages handles GET /api/v1/organizations/:org/analytics/languages
+func (h *AnalyticsHandlers) GetOrganizationLanguages(c *gin.Context) {
+	userIDInterface, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	viewerID, err := parseUserID(userIDInterface)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	orgID, err := h.getOrganizationID(c.Request.Context(), c.Param("org"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	filters := services.LanguageReportFilters{Interval: c.DefaultQuery("interval", services.LanguageIntervalWeek)}
+	switch filters.Interval {
+	case services.LanguageIntervalDay, services.LanguageIntervalWeek, services.LanguageIntervalMonth:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "interval must be one of day, week or month"})
+		return
+	}
+	for param, dest := range map[string]**time.Time{"start_date": &filters.StartDate, "end_date": &filters.EndDate} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			if parsed, err = time.Parse(time.RFC3339, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a date (YYYY-MM-DD) or RFC 3339 timestamp", param)})
+				return
+			}
+		}
+		*dest = &parsed
+	}
+	if filters.StartDate != nil && filters.EndDate != nil && filters.StartDate.After(*filters.EndDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must not be after end_date"})
+		return
+	}
+
+	report, err := h.analyticsService.GetOrganizationLanguages(c.Request.Context(), orgID, viewerID, filters)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get organization languages")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get organization languages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetOrganizationReviews handles GET /api/v1/organizations/:org/analytics/reviews
+func (h *AnalyticsHandlers) GetOrganizationReviews(c *gin.Context) {
+	orgID, err := h.getOrganizationID(c.Request.Context(), c.Param("org"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	}
+
+	// Reviewer load is reported per person and limited to organization owners and admins
+	if !h.isAdmin(c) && !h.isOrganizationAdmin(c, orgID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Organization owner or admin access required"})
+		return
+	}
+
+	filters, ok := reviewAnalyticsFilters(c)
+	if !ok {
+		return
+	}
+	report, err := h.analyticsService.GetOrganizationReviewAnalytics(c.Request.Context(), orgID, filters)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get organization review analytics")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get review analytics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
 // GetOrganizationRepositories handles GET /api/v1/organizations/:org/analytics/repositories
 func (h *AnalyticsHandlers) GetOrganizationRepositories(c *gin.Context) {
 	orgName := c.Param("org")
@@ -657,6 +854,7 @@ func (h *AnalyticsHandlers) GetOrganizationRepositories(c *gin.Context) {
 	}
 
 	filters := services.InsightFilters{
+		Location:  h.location(c),
 		StartDate: startDate,
 		EndDate:   endDate,
 		Period:    period,
@@ -719,94 +917,6 @@ func (h *AnalyticsHandlers) GetOrganizationTeams(c *gin.Context) {
 	})
 }
 
-// GetOrganizationSecurity handles GET /api/v1/organizations/:org/analytics/security
-func (h *AnalyticsHandlers) GetOrganizationSecurity(c *gin.Context) {
-	orgName := c.Param("org")
-	if orgName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Organization name is required"})
-		return
-	}
-
-	// Get organization ID from name
-	orgID, err := h.getOrganizationID(c.Request.Context(), orgName)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to resolve organization")
-		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
-		return
-	}
-
-	// Parse query parameters for filtering
-	startDateStr := c.Query("start_date")
-	endDateStr := c.Query("end_date")
-
-	var startDate, endDate *time.Time
-	if startDateStr != "" {
-		if parsed, err := time.Parse(time.RFC3339, startDateStr); err == nil {
-			startDate = &parsed
-		}
-	}
-	if endDateStr != "" {
-		if parsed, err := time.Parse(time.RFC3339, endDateStr); err == nil {
-			endDate = &parsed
-		}
-	}
-
-	// Get security events and metrics
-	query := h.db.WithContext(c.Request.Context()).Model(&models.AnalyticsEvent{}).Where("organization_id = ?", orgID)
-
-	if startDate != nil {
-		query = query.Where("created_at >= ?", *startDate)
-	}
-	if endDate != nil {
-		query = query.Where("created_at <= ?", *endDate)
-	}
-
-	// Count security-related events
-	var securityEvents int64
-	query.Where("event_type LIKE ?", "security.%").Count(&securityEvents)
-
-	// Count access denied events
-	var accessDeniedEvents int64
-	query.Where("event_type = ?", "security.access_denied").Count(&accessDeniedEvents)
-
-	// Count MFA events
-	var mfaEvents int64
-	query.Where("event_type = ?", "security.mfa_enabled").Count(&mfaEvents)
-
-	// Get recent security alerts (placeholder data)
-	securityAlerts := []gin.H{
-		{
-			"type":        "vulnerability",
-			"severity":    "medium",
-			"title":       "Outdated dependency detected",
-			"description": "A repository contains outdated dependencies with known vulnerabilities",
-			"created_at":  time.Now().Add(-24 * time.Hour),
-		},
-	}
-
-	// Calculate security score (simplified)
-	securityScore := 85.0
-	if accessDeniedEvents > 10 {
-		securityScore -= 10.0
-	}
-	if mfaEvents > 0 {
-		securityScore += 5.0
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"security_score":        securityScore,
-		"total_security_events": securityEvents,
-		"access_denied_events":  accessDeniedEvents,
-		"mfa_enabled_events":    mfaEvents,
-		"security_alerts":       securityAlerts,
-		"compliance_status": gin.H{
-			"two_factor_required": true,
-			"sso_enabled":         false,
-			"audit_logs_enabled":  true,
-		},
-	})
-}
-
 // Admin Analytics Endpoints
 
 // GetPlatformAnalytics handles GET /api/v1/admin/analytics/platform
@@ -835,6 +945,7 @@ func (h *AnalyticsHandlers) GetPlatformAnalytics(c *gin.Context) {
 	}
 
 	filters := services.InsightFilters{
+		Location:  h.location(c),
 		StartDate: startDate,
 		EndDate:   endDate,
 		Period:    period,
@@ -875,6 +986,7 @@ func (h *AnalyticsHandlers) GetUsageAnalytics(c *gin.Context) {
 	}
 
 	filters := services.InsightFilters{
+		Location:  h.location(c),
 		StartDate: startDate,
 		EndDate:   endDate,
 		Period:    period,
@@ -1091,6 +1203,37 @@ func (h *AnalyticsHandlers) ExportAnalytics(c *gin.Context) {
 	c.Data(http.StatusOK, contentType, data)
 }
 
+// QueryEvents handles POST /api/v1/analytics/query
+func (h *AnalyticsHandlers) QueryEvents(c *gin.Context) {
+	if !h.isAdmin(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	var query services.EventQuery
+	if err := c.ShouldBindJSON(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid query", "details": err.Error()})
+		return
+	}
+	query.Location = h.location(c)
+
+	result, err := h.analyticsService.QueryEvents(c.Request.Context(), query)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidEventQuery):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrEventQueryTimeout):
+			c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error()})
+		default:
+			h.logger.WithError(err).Error("Failed to query analytics events")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to query events"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
 // Event Recording Endpoints (for internal use)
 
 // RecordEvent handles POST /api/v1/analytics/events (internal)
@@ -1158,6 +1301,25 @@ func parseUserID(userID interface{}) (uuid.UUID, error) {
 	}
 }
 
+// location returns the timezone daily series are bucketed in: the timezone
+// query parameter, else the signed-in user's preference, else UTC
+func (h *AnalyticsHandlers) location(c *gin.Context) *time.Location {
+	if tz := c.Query("timezone"); tz != "" {
+		if loc, err := time.LoadLocation(tz); err == nil {
+			return loc
+		}
+	}
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return time.UTC
+	}
+	uid, err := parseUserID(userID)
+	if err != nil {
+		return time.UTC
+	}
+	return h.preferencesService.Location(c.Request.Context(), uid)
+}
+
 // isAdmin checks if the current user is an admin
 func (h *AnalyticsHandlers) isAdmin(c *gin.Context) bool {
 	userID, exists := c.Get("user_id")
@@ -1180,6 +1342,25 @@ func (h *AnalyticsHandlers) isAdmin(c *gin.Context) bool {
 	return user.IsAdmin
 }
 
+// isOrganizationAdmin checks if the current user is an owner or admin of the organization
+func (h *AnalyticsHandlers) isOrganizationAdmin(c *gin.Context, orgID uuid.UUID) bool {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		return false
+	}
+
+	uid, err := parseUserID(userID)
+	if err != nil {
+		return false
+	}
+
+	var member models.OrganizationMember
+	if err := h.db.WithContext(c.Request.Context()).Where("organization_id = ? AND user_id = ?", orgID, uid).First(&member).Error; err != nil {
+		return false
+	}
+	return member.Role == models.OrgRoleOwner || member.Role == models.OrgRoleAdmin
+}
+
 // getRepositoryID resolves repository ID from owner and repository name
 func (h *AnalyticsHandlers) getRepositoryID(ctx context.Context, owner, name string) (uuid.UUID, error) {
 	// This needs to integrate with repository service
@@ -1275,23 +1456,30 @@ func (h *AnalyticsHandlers) getUserContributions(ctx context.Context, userID uui
 		TotalDeletions int64 `json:"total_deletions"`
 	}
 
+	// Line counts only cover commits the user authored
 	err := h.db.WithContext(ctx).Model(&models.Commit{}).
 		Select("COUNT(*) as total_commits, COALESCE(SUM(additions), 0) as total_additions, COALESCE(SUM(deletions), 0) as total_deletions").
-		Where("author_email = ?", user.Email).
+		Where("LOWER(author_email) = ?", strings.ToLower(user.Email)).
 		Scan(&commitStats).Error
 
 	if err != nil {
 		return nil, fmt.Errorf("failed to get commit stats: %w", err)
 	}
 
+	// Commits crediting the user as a co-author count as contributions
+	var attributedCommits int64
+	if err := services.AttributedCommits(h.db.WithContext(ctx).Model(&models.Commit{}), user.Email).
+		Count(&attributedCommits).Error; err != nil {
+		return nil, fmt.Errorf("failed to count co-authored commits: %w", err)
+	}
+
 	// Get user's pull requests count
 	var prCount int64
 	h.db.WithContext(ctx).Model(&models.PullRequest{}).Where("user_id = ?", userID).Count(&prCount)
 
 	// Get repositories user has contributed to
 	var repoCount int64
-	h.db.WithContext(ctx).Model(&models.Commit{}).
-		Where("author_email = ?", user.Email).
+	services.AttributedCommits(h.db.WithContext(ctx).Model(&models.Commit{}), user.Email).
 		Distinct("repository_id").Count(&repoCount)
 
 	// Get contribution activity for the last 12 months
@@ -1301,9 +1489,9 @@ func (h *AnalyticsHandlers) getUserContributions(ctx context.Context, userID uui
 		Count int64  `json:"count"`
 	}
 
-	err = h.db.WithContext(ctx).Model(&models.Commit{}).
+	err = services.AttributedCommits(h.db.WithContext(ctx).Model(&models.Commit{}), user.Email).
 		Select("DATE_TRUNC('month', created_at) as month, COUNT(*) as count").
-		Where("author_email = ? AND created_at >= ?", user.Email, since).
+		Where("created_at >= ?", since).
 		Group("DATE_TRUNC('month', created_at)").
 		Order("month ASC").
 		Scan(&monthlyContributions).Error
@@ -1317,9 +1505,10 @@ func (h *AnalyticsHandlers) getUserContributions(ctx context.Context, userID uui
 	}
 
 	return gin.H{
-		"total_commits":   commitStats.TotalCommits,
-		"total_additions": commitStats.TotalAdditions,
-		"total_deletions": commitStats.TotalDeletions,
+		"total_commits":       attributedCommits,
+		"co_authored_commits": attributedCommits - commitStats.TotalCommits,
+		"total_additions":     commitStats.TotalAdditions,
+		"total_deletions":     commitStats.TotalDeletions,
 
 		"total_pull_requests":      prCount,
 		"repositories_contributed": repoCount,
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// AuditHandlers serves the audit trail of security-relevant actions to
+// instance admins and organization owners
+type AuditHandlers struct {
+	auditService services.AuditService
+	logger       *logrus.Logger
+}
+
+func NewAuditHandlers(auditService services.AuditService, logger *logrus.Logger) *AuditHandlers {
+	return &AuditHandlers{
+		auditService: auditService,
+		logger:       logger,
+	}
+}
+
+func (h *AuditHandlers) auditError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrAuditForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAuditStreamDisabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// query reads the action, actor_id, repository_id, since, until, page and
+// per_page query parameters
+func (h *AuditHandlers) query(c *gin.Context) (services.AuditQuery, int, int, bool) {
+	query := services.AuditQuery{Action: c.Query("action")}
+	for param, target := range map[string]**uuid.UUID{
+		"actor_id":      &query.ActorID,
+		"repository_id": &query.RepositoryID,
+	} {
+		if value := c.Query(param); value != "" {
+			id, err := uuid.Parse(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param})
+				return query, 0, 0, false
+			}
+			*target = &id
+		}
+	}
+	for param, target := range map[string]**time.Time{
+		"since": &query.Since,
+		"until": &query.Until,
+	} {
+		if value := c.Query(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + ", expected an RFC 3339 time"})
+				return query, 0, 0, false
+			}
+			*target = &t
+		}
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "30"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 30
+	}
+	query.Limit, query.Offset = perPage, (page-1)*perPage
+	return query, page, perPage, true
+}
+
+// ListEntries handles GET /api/v1/admin/audit-log; organization_id narrows
+// the trail to one organization
+func (h *AuditHandlers) ListEntries(c *gin.Context) {
+	query, page, perPage, ok := h.query(c)
+	if !ok {
+		return
+	}
+	if value := c.Query("organization_id"); value != "" {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization_id"})
+			return
+		}
+		query.OrganizationID = &id
+	}
+
+	entries, total, err := h.auditService.Query(c.Request.Context(), query)
+	if err != nil {
+		h.auditError(c, err, "Failed to list audit entries")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"entries":     entries,
+		"total_count": total,
+		"page":        page,
+		"per_page":    perPage,
+	})
+}
+
+// ListOrganizationEntries handles GET /api/v1/organizations/{org}/audit-log
+func (h *AuditHandlers) ListOrganizationEntries(c *gin.Context) {
+	actorID, ok := actor(c)
+	if !ok {
+		return
+	}
+	query, page, perPage, ok := h.query(c)
+	if !ok {
+		return
+	}
+
+	entries, total, err := h.auditService.QueryOrganization(c.Request.Context(), c.Param("org"), actorID, query)
+	if err != nil {
+		h.auditError(c, err, "Failed to list audit entries")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"entries":     entries,
+		"total_count": total,
+		"page":        page,
+		"per_page":    perPage,
+	})
+}
+
+// Verify handles POST /api/v1/admin/audit-log/verify, checking the hash
+// chain of the whole trail
+func (h *AuditHandlers) Verify(c *gin.Context) {
+	result, err := h.auditService.Verify(c.Request.Context())
+	if err != nil {
+		h.auditError(c, err, "Failed to verify audit trail")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetStreamStatus handles GET /api/v1/admin/audit-log/stream
+func (h *AuditHandlers) GetStreamStatus(c *gin.Context) {
+	status, err := h.auditService.StreamStatus(c.Request.Context())
+	if err != nil {
+		h.auditError(c, err, "Failed to get audit stream status")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// FlushStream handles POST /api/v1/admin/audit-log/stream/flush, sending
+// pending entries to the sink now
+func (h *AuditHandlers) FlushStream(c *gin.Context) {
+	sent, err := h.auditService.Flush(c.Request.Context())
+	if err != nil && !errors.Is(err, services.ErrAuditStreamDisabled) {
+		h.logger.WithError(err).Warn("Failed to stream audit entries")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to stream audit entries", "sent": sent})
+		return
+	}
+	if err != nil {
+		h.auditError(c, err, "Failed to stream audit entries")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sent": sent})
+}
@@ -3,12 +3,14 @@ package api
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/a5c-ai/hub/internal/auth"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type AuthHandlers struct {
@@ -35,6 +37,16 @@ func (h *AuthHandlers) Login(c *gin.Context) {
 
 	response, err := h.authService.Login(c.Request.Context(), req)
 	if err != nil {
+		var mfaRequired *auth.MFARequiredError
+		if errors.As(err, &mfaRequired) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":        err.Error(),
+				"mfa_required": true,
+				"methods":      mfaRequired.Methods,
+				"webauthn":     mfaRequired.WebAuthn,
+			})
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 		return
 	}
@@ -225,6 +237,23 @@ func (h *AuthHandlers) OAuthCallback(c *gin.Context) {
 
 // MFA Handlers
 
+// GET /api/v1/auth/mfa
+func (h *AuthHandlers) GetMFAStatus(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	status, err := h.mfaService.Status(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
 // POST /api/v1/auth/mfa/setup
 func (h *AuthHandlers) SetupMFA(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -233,7 +262,13 @@ func (h *AuthHandlers) SetupMFA(c *gin.Context) {
 		return
 	}
 
-	response, err := h.mfaService.SetupTOTP(userID.(uuid.UUID), "A5C Hub", "user@example.com")
+	user, err := h.authService.GetUserByID(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	response, err := h.mfaService.SetupTOTP(user.ID, "A5C Hub", user.Username)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -245,8 +280,7 @@ func (h *AuthHandlers) SetupMFA(c *gin.Context) {
 // POST /api/v1/auth/mfa/verify
 func (h *AuthHandlers) VerifyMFA(c *gin.Context) {
 	var req struct {
-		Secret string `json:"secret" binding:"required"`
-		Code   string `json:"code" binding:"required"`
+		Code string `json:"code" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -260,9 +294,9 @@ func (h *AuthHandlers) VerifyMFA(c *gin.Context) {
 		return
 	}
 
-	valid, err := h.mfaService.VerifyTOTP(userID.(uuid.UUID), req.Secret, req.Code)
+	valid, err := h.mfaService.ConfirmTOTP(userID.(uuid.UUID), req.Code)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -274,6 +308,136 @@ func (h *AuthHandlers) VerifyMFA(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "MFA enabled successfully"})
 }
 
+// POST /api/v1/auth/mfa/webauthn/register/begin
+func (h *AuthHandlers) BeginWebAuthnRegistration(c *gin.Context) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	options, err := h.mfaService.BeginWebAuthnRegistration(userID.(uuid.UUID), req.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// POST /api/v1/auth/mfa/webauthn/register/finish
+func (h *AuthHandlers) FinishWebAuthnRegistration(c *gin.Context) {
+	var req auth.WebAuthnAssertion
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	credential, err := h.mfaService.FinishWebAuthnRegistration(userID.(uuid.UUID), req.SessionID, &req.Credential)
+	if err != nil {
+		webAuthnError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, credential)
+}
+
+// GET /api/v1/auth/mfa/webauthn/credentials
+func (h *AuthHandlers) ListWebAuthnCredentials(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	credentials, err := h.mfaService.GetWebAuthnCredentials(userID.(uuid.UUID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, credentials)
+}
+
+// DELETE /api/v1/auth/mfa/webauthn/credentials/:id
+func (h *AuthHandlers) DeleteWebAuthnCredential(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	err := h.mfaService.DeleteWebAuthnCredential(userID.(uuid.UUID), c.Param("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Credential not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// POST /api/v1/auth/passkeys/login/begin
+func (h *AuthHandlers) BeginPasskeyLogin(c *gin.Context) {
+	options, err := h.authService.BeginPasskeyLogin(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, options)
+}
+
+// POST /api/v1/auth/passkeys/login/finish
+func (h *AuthHandlers) FinishPasskeyLogin(c *gin.Context) {
+	var req auth.PasskeyLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := h.authService.LoginWithPasskey(c.Request.Context(), req)
+	if err != nil {
+		if errors.Is(err, auth.ErrWebAuthnSessionInvalid) || errors.Is(err, auth.ErrInvalidWebAuthnResponse) || errors.Is(err, auth.ErrAccountLocked) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// webAuthnError maps WebAuthn ceremony failures to responses
+func webAuthnError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, auth.ErrWebAuthnSessionInvalid), errors.Is(err, auth.ErrInvalidWebAuthnResponse):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, auth.ErrWebAuthnCredentialInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
 // POST /api/v1/auth/mfa/disable
 func (h *AuthHandlers) DisableMFA(c *gin.Context) {
 	userID, exists := c.Get("user_id")
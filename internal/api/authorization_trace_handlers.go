@@ -0,0 +1,100 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// AuthorizationTraceHandlers serves the admin endpoints for debugging
+// repository permission decisions
+type AuthorizationTraceHandlers struct {
+	traceService services.AuthorizationTraceService
+	logger       *logrus.Logger
+}
+
+func NewAuthorizationTraceHandlers(traceService services.AuthorizationTraceService, logger *logrus.Logger) *AuthorizationTraceHandlers {
+	return &AuthorizationTraceHandlers{
+		traceService: traceService,
+		logger:       logger,
+	}
+}
+
+// ListAuthorizationTraces handles GET /api/v1/admin/debug/authorization/traces
+func (h *AuthorizationTraceHandlers) ListAuthorizationTraces(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "30"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 30
+	}
+
+	traces, total, err := h.traceService.List(c.Request.Context(), services.AuthorizationTraceListOptions{
+		Username:   c.Query("user"),
+		Repository: c.Query("repository"),
+		RequestID:  c.Query("request_id"),
+		Limit:      perPage,
+		Offset:     (page - 1) * perPage,
+	})
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list authorization traces")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list authorization traces"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"settings":    h.traceService.Settings(),
+		"traces":      traces,
+		"total_count": total,
+		"page":        page,
+		"per_page":    perPage,
+	})
+}
+
+// GetAuthorizationTrace handles GET /api/v1/admin/debug/authorization/traces/{trace_id}
+func (h *AuthorizationTraceHandlers) GetAuthorizationTrace(c *gin.Context) {
+	traceID, err := uuid.Parse(c.Param("trace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid trace ID"})
+		return
+	}
+
+	trace, err := h.traceService.Get(c.Request.Context(), traceID)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, trace)
+	case errors.Is(err, services.ErrAuthorizationTraceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("Failed to get authorization trace")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get authorization trace"})
+	}
+}
+
+// ExplainAuthorization handles POST /api/v1/admin/debug/authorization/explain,
+// tracing a user's access to a repository on demand
+func (h *AuthorizationTraceHandlers) ExplainAuthorization(c *gin.Context) {
+	var req services.ExplainAuthorizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	trace, err := h.traceService.Explain(c.Request.Context(), req)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, trace)
+	case errors.Is(err, services.ErrInvalidAuthorizationExplain):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("Failed to explain authorization")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to explain authorization"})
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// badgeMaxAge is how long clients and README image proxies may cache a badge
+const badgeMaxAge = "max-age=300"
+
+// BadgeHandlers serves SVG status badges of public repositories
+type BadgeHandlers struct {
+	badgeService      services.BadgeService
+	repositoryService services.RepositoryService
+	logger            *logrus.Logger
+}
+
+func NewBadgeHandlers(badgeService services.BadgeService, repositoryService services.RepositoryService, logger *logrus.Logger) *BadgeHandlers {
+	return &BadgeHandlers{
+		badgeService:      badgeService,
+		repositoryService: repositoryService,
+		logger:            logger,
+	}
+}
+
+// GetBadge handles GET /badges/{owner}/{repo}/{badge}.svg for the stars,
+// build and coverage badges. Only public repositories have badges, so
+// private repositories are reported as not found.
+func (h *BadgeHandlers) GetBadge(c *gin.Context) {
+	name, ok := strings.CutSuffix(c.Param("badge"), ".svg")
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Badge not found"})
+		return
+	}
+
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil || repo.Visibility != models.VisibilityPublic {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		return
+	}
+
+	badge, err := h.badgeService.Badge(c.Request.Context(), repo, name, c.Query("branch"))
+	if errors.Is(err, services.ErrUnknownBadge) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Badge not found"})
+		return
+	}
+	if err != nil {
+		// The badge still renders, as "unknown"
+		h.logger.WithError(err).WithField("repository_id", repo.ID).Warn("Failed to compute badge")
+	}
+
+	svg := services.RenderBadgeSVG(badge)
+	sum := sha256.Sum256(svg)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	c.Header("Cache-Control", "public, "+badgeMaxAge)
+	c.Header("ETag", etag)
+	if c.GetHeader("If-None-Match") == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "image/svg+xml; charset=utf-8", svg)
+}
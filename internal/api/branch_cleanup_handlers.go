@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// BranchCleanupHandlers serves branch cleanup suggestions and bulk deletion
+type BranchCleanupHandlers struct {
+	cleanupService services.BranchCleanupService
+	logger         *logrus.Logger
+}
+
+func NewBranchCleanupHandlers(cleanupService services.BranchCleanupService, logger *logrus.Logger) *BranchCleanupHandlers {
+	return &BranchCleanupHandlers{
+		cleanupService: cleanupService,
+		logger:         logger,
+	}
+}
+
+// GetBranchCleanup handles GET /api/v1/repositories/{owner}/{repo}/branch-cleanup
+func (h *BranchCleanupHandlers) GetBranchCleanup(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	staleDays := 0
+	if value := c.Query("stale_days"); value != "" {
+		days, err := strconv.Atoi(value)
+		if err != nil || days < 1 || days > 3650 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "stale_days must be between 1 and 3650"})
+			return
+		}
+		staleDays = days
+	}
+
+	report, err := h.cleanupService.Suggestions(c.Request.Context(), repo, staleDays)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get branch cleanup suggestions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get branch cleanup suggestions"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// DeleteBranches handles POST /api/v1/repositories/{owner}/{repo}/branch-cleanup
+func (h *BranchCleanupHandlers) DeleteBranches(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	actorID, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Branches []string `json:"branches" binding:"required,min=1,max=100"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	result, err := h.cleanupService.DeleteBranches(c.Request.Context(), repo, req.Branches, actorID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to delete branches")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete branches"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
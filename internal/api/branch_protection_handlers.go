@@ -37,6 +37,7 @@ type BranchProtection struct {
 	AllowForcePushes              bool                        `json:"allow_force_pushes"`
 	AllowDeletions                bool                        `json:"allow_deletions"`
 	RequireConversationResolution bool                        `json:"require_conversation_resolution"`
+	RequireSignedCommits          bool                        `json:"require_signed_commits"`
 }
 
 // RequiredStatusChecks represents required status checks configuration
@@ -174,7 +175,8 @@ func (h *BranchProtectionHandlers) GetBranchProtection(c *gin.Context) {
 		RequireLinearHistory:          false, // Not yet implemented in model
 		AllowForcePushes:              false, // Not yet implemented in model
 		AllowDeletions:                false, // Not yet implemented in model
-		RequireConversationResolution: false, // Not yet implemented in model
+		RequireConversationResolution: rule.RequireConversationResolution,
+		RequireSignedCommits:          rule.RequireSignedCommits,
 		Restrictions:                  restrictions,
 	}
 
@@ -217,6 +219,7 @@ func (h *BranchProtectionHandlers) UpdateBranchProtection(c *gin.Context) {
 		AllowForcePushes              *bool                       `json:"allow_force_pushes,omitempty"`
 		AllowDeletions                *bool                       `json:"allow_deletions,omitempty"`
 		RequireConversationResolution *bool                       `json:"require_conversation_resolution,omitempty"`
+		RequireSignedCommits          *bool                       `json:"require_signed_commits,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -231,11 +234,13 @@ func (h *BranchProtectionHandlers) UpdateBranchProtection(c *gin.Context) {
 	if err != nil && err.Error() == "no protection rule found for branch '"+branch+"'" {
 		// Create new protection rule
 		createReq := services.CreateBranchProtectionRequest{
-			Pattern:                    branch, // Use exact branch name as pattern
-			RequiredStatusChecks:       convertToServiceStatusChecks(req.RequiredStatusChecks),
-			EnforceAdmins:              req.EnforceAdmins != nil && *req.EnforceAdmins,
-			RequiredPullRequestReviews: convertToServicePRReviews(req.RequiredPullRequestReviews),
-			Restrictions:               convertToServiceRestrictions(req.Restrictions),
+			Pattern:                       branch, // Use exact branch name as pattern
+			RequiredStatusChecks:          convertToServiceStatusChecks(req.RequiredStatusChecks),
+			EnforceAdmins:                 req.EnforceAdmins != nil && *req.EnforceAdmins,
+			RequiredPullRequestReviews:    convertToServicePRReviews(req.RequiredPullRequestReviews),
+			Restrictions:                  convertToServiceRestrictions(req.Restrictions),
+			RequireConversationResolution: req.RequireConversationResolution != nil && *req.RequireConversationResolution,
+			RequireSignedCommits:          req.RequireSignedCommits != nil && *req.RequireSignedCommits,
 		}
 
 		rule, err = h.branchService.CreateProtectionRule(c.Request.Context(), repo.ID, createReq)
@@ -251,10 +256,12 @@ func (h *BranchProtectionHandlers) UpdateBranchProtection(c *gin.Context) {
 	} else {
 		// Update existing protection rule
 		updateReq := services.UpdateBranchProtectionRequest{
-			RequiredStatusChecks:       convertToServiceStatusChecks(req.RequiredStatusChecks),
-			EnforceAdmins:              req.EnforceAdmins,
-			RequiredPullRequestReviews: convertToServicePRReviews(req.RequiredPullRequestReviews),
-			Restrictions:               convertToServiceRestrictions(req.Restrictions),
+			RequiredStatusChecks:          convertToServiceStatusChecks(req.RequiredStatusChecks),
+			EnforceAdmins:                 req.EnforceAdmins,
+			RequiredPullRequestReviews:    convertToServicePRReviews(req.RequiredPullRequestReviews),
+			Restrictions:                  convertToServiceRestrictions(req.Restrictions),
+			RequireConversationResolution: req.RequireConversationResolution,
+			RequireSignedCommits:          req.RequireSignedCommits,
 		}
 
 		rule, err = h.branchService.UpdateProtectionRule(c.Request.Context(), existingRule.ID, updateReq)
@@ -296,7 +303,8 @@ func (h *BranchProtectionHandlers) UpdateBranchProtection(c *gin.Context) {
 		RequireLinearHistory:          false, // Not yet implemented in model
 		AllowForcePushes:              false, // Not yet implemented in model
 		AllowDeletions:                false, // Not yet implemented in model
-		RequireConversationResolution: false, // Not yet implemented in model
+		RequireConversationResolution: rule.RequireConversationResolution,
+		RequireSignedCommits:          rule.RequireSignedCommits,
 		Restrictions:                  restrictions,
 	}
 
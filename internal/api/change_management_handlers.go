@@ -0,0 +1,127 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ChangeManagementHandlers serves repository change management policies and
+// the change tickets linked to pull requests
+type ChangeManagementHandlers struct {
+	changeService      services.ChangeManagementService
+	pullRequestService services.PullRequestService
+	logger             *logrus.Logger
+}
+
+func NewChangeManagementHandlers(changeService services.ChangeManagementService, pullRequestService services.PullRequestService, logger *logrus.Logger) *ChangeManagementHandlers {
+	return &ChangeManagementHandlers{
+		changeService:      changeService,
+		pullRequestService: pullRequestService,
+		logger:             logger,
+	}
+}
+
+func (h *ChangeManagementHandlers) changeManagementError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrChangeManagementPolicyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidChangeManagementPolicy):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetChangeManagementPolicy handles GET /api/v1/repositories/{owner}/{repo}/change-management
+func (h *ChangeManagementHandlers) GetChangeManagementPolicy(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+
+	policy, err := h.changeService.GetPolicy(c.Request.Context(), repo.ID)
+	if err != nil {
+		h.changeManagementError(c, err, "Failed to get change management policy")
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// UpdateChangeManagementPolicy handles PUT /api/v1/repositories/{owner}/{repo}/change-management
+func (h *ChangeManagementHandlers) UpdateChangeManagementPolicy(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+
+	var req services.ChangeManagementPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.changeService.UpdatePolicy(c.Request.Context(), repo.ID, req)
+	if err != nil {
+		h.changeManagementError(c, err, "Failed to update change management policy")
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// DeleteChangeManagementPolicy handles DELETE /api/v1/repositories/{owner}/{repo}/change-management
+func (h *ChangeManagementHandlers) DeleteChangeManagementPolicy(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+
+	if err := h.changeService.DeletePolicy(c.Request.Context(), repo.ID); err != nil {
+		h.changeManagementError(c, err, "Failed to delete change management policy")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetPullRequestChangeTicket handles GET /api/v1/repositories/{owner}/{repo}/pulls/{number}/change-ticket
+func (h *ChangeManagementHandlers) GetPullRequestChangeTicket(c *gin.Context) {
+	pr, ok := tenantPullRequest(c, h.pullRequestService, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	evaluation, err := h.changeService.Evaluate(c.Request.Context(), pr)
+	if err != nil {
+		h.changeManagementError(c, err, "Failed to get pull request change ticket")
+		return
+	}
+	if evaluation == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No change management policy applies to this pull request"})
+		return
+	}
+	c.JSON(http.StatusOK, evaluation)
+}
+
+// ValidatePullRequestChangeTicket handles POST /api/v1/repositories/{owner}/{repo}/pulls/{number}/change-ticket/validate
+func (h *ChangeManagementHandlers) ValidatePullRequestChangeTicket(c *gin.Context) {
+	pr, ok := tenantPullRequest(c, h.pullRequestService, models.PermissionWrite)
+	if !ok {
+		return
+	}
+
+	evaluation, err := h.changeService.Revalidate(c.Request.Context(), pr)
+	if err != nil {
+		h.changeManagementError(c, err, "Failed to validate pull request change ticket")
+		return
+	}
+	if evaluation == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No change management policy applies to this pull request"})
+		return
+	}
+	c.JSON(http.StatusOK, evaluation)
+}
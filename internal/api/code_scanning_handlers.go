@@ -0,0 +1,173 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// maxSARIFRequestBody bounds SARIF upload requests; the payload is base64
+// encoded and usually gzipped
+const maxSARIFRequestBody = 32 << 20
+
+// CodeScanningHandlers serves SARIF uploads and code scanning alerts
+type CodeScanningHandlers struct {
+	codeScanningService services.CodeScanningService
+	pullRequestService  services.PullRequestService
+	logger              *logrus.Logger
+}
+
+func NewCodeScanningHandlers(codeScanningService services.CodeScanningService, pullRequestService services.PullRequestService, logger *logrus.Logger) *CodeScanningHandlers {
+	return &CodeScanningHandlers{
+		codeScanningService: codeScanningService,
+		pullRequestService:  pullRequestService,
+		logger:              logger,
+	}
+}
+
+func (h *CodeScanningHandlers) scanningError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrCodeScanningAlertNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSARIF), errors.Is(err, services.ErrInvalidCodeScanningAlert):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func (h *CodeScanningHandlers) getAlert(c *gin.Context, permission models.Permission) (*models.CodeScanningAlert, bool) {
+	repo, ok := tenantRepository(c, permission)
+	if !ok {
+		return nil, false
+	}
+	number, err := strconv.Atoi(c.Param("alert_number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert number"})
+		return nil, false
+	}
+	alert, err := h.codeScanningService.GetAlert(c.Request.Context(), repo.ID, number)
+	if err != nil {
+		h.scanningError(c, err, "Failed to get code scanning alert")
+		return nil, false
+	}
+	return alert, true
+}
+
+// UploadSARIF handles POST /api/v1/repositories/{owner}/{repo}/code-scanning/sarifs
+func (h *CodeScanningHandlers) UploadSARIF(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSARIFRequestBody)
+	var req services.UploadSARIFRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	analyses, err := h.codeScanningService.UploadSARIF(c.Request.Context(), repo, optionalActor(c), req)
+	if err != nil {
+		h.scanningError(c, err, "Failed to process SARIF upload")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"analyses": analyses})
+}
+
+// ListCodeScanningAlerts handles GET /api/v1/repositories/{owner}/{repo}/code-scanning/alerts
+func (h *CodeScanningHandlers) ListCodeScanningAlerts(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	filter := services.CodeScanningAlertFilter{Ref: c.Query("ref"), Tool: c.Query("tool")}
+	if state := c.Query("state"); state != "" {
+		alertState := models.CodeScanningAlertState(state)
+		filter.State = &alertState
+	}
+	if severity := c.Query("severity"); severity != "" {
+		alertSeverity := models.CodeScanningSeverity(severity)
+		filter.Severity = &alertSeverity
+	}
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+		filter.Page = page
+	}
+	if perPage, err := strconv.Atoi(c.Query("per_page")); err == nil && perPage > 0 && perPage <= 100 {
+		filter.PageSize = perPage
+	}
+
+	alerts, err := h.codeScanningService.ListAlerts(c.Request.Context(), repo.ID, filter)
+	if err != nil {
+		h.scanningError(c, err, "Failed to list code scanning alerts")
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+// GetCodeScanningAlert handles GET /api/v1/repositories/{owner}/{repo}/code-scanning/alerts/{alert_number}
+func (h *CodeScanningHandlers) GetCodeScanningAlert(c *gin.Context) {
+	alert, ok := h.getAlert(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, alert)
+}
+
+// UpdateCodeScanningAlert handles PATCH /api/v1/repositories/{owner}/{repo}/code-scanning/alerts/{alert_number}
+func (h *CodeScanningHandlers) UpdateCodeScanningAlert(c *gin.Context) {
+	alert, ok := h.getAlert(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateCodeScanningAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	updated, err := h.codeScanningService.UpdateAlert(c.Request.Context(), alert, userID, req)
+	if err != nil {
+		h.scanningError(c, err, "Failed to update code scanning alert")
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// GetPullRequestAnnotations handles GET /api/v1/repositories/{owner}/{repo}/pulls/{number}/code-scanning
+func (h *CodeScanningHandlers) GetPullRequestAnnotations(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pull request number"})
+		return
+	}
+	pr, err := h.pullRequestService.GetByNumber(c.Request.Context(), repo.ID, number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pull request not found"})
+		return
+	}
+
+	annotations, err := h.codeScanningService.PullRequestAnnotations(c.Request.Context(), pr)
+	if err != nil {
+		h.scanningError(c, err, "Failed to get code scanning annotations")
+		return
+	}
+	c.JSON(http.StatusOK, annotations)
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// CodeSearchHandlers serves full-text code search and organization code
+// search indexing controls
+type CodeSearchHandlers struct {
+	codeIndexService  services.CodeIndexService
+	codeSearchService services.CodeSearchService
+	repositoryService services.RepositoryService
+	logger            *logrus.Logger
+}
+
+func NewCodeSearchHandlers(codeIndexService services.CodeIndexService, codeSearchService services.CodeSearchService, repositoryService services.RepositoryService, logger *logrus.Logger) *CodeSearchHandlers {
+	return &CodeSearchHandlers{
+		codeIndexService:  codeIndexService,
+		codeSearchService: codeSearchService,
+		repositoryService: repositoryService,
+		logger:            logger,
+	}
+}
+
+func (h *CodeSearchHandlers) codeSearchError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrCodeSearchForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCodeSearchSettings), errors.Is(err, services.ErrInvalidCodeSearchQuery):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCodeSearchDisabled):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetCodeSearchSettings handles GET /api/v1/organizations/:org/code-search/settings
+func (h *CodeSearchHandlers) GetCodeSearchSettings(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	settings, err := h.codeIndexService.GetSettings(c.Request.Context(), c.Param("org"), userID)
+	if err != nil {
+		h.codeSearchError(c, err, "Failed to get code search settings")
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateCodeSearchSettings handles PATCH /api/v1/organizations/:org/code-search/settings
+func (h *CodeSearchHandlers) UpdateCodeSearchSettings(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req services.UpdateCodeSearchSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	settings, err := h.codeIndexService.UpdateSettings(c.Request.Context(), c.Param("org"), userID, req)
+	if err != nil {
+		h.codeSearchError(c, err, "Failed to update code search settings")
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// GetCodeSearchIndex handles GET /api/v1/organizations/:org/code-search/index
+// It reports the freshness and size of every repository's index.
+func (h *CodeSearchHandlers) GetCodeSearchIndex(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	status, err := h.codeIndexService.Status(c.Request.Context(), c.Param("org"), userID)
+	if err != nil {
+		h.codeSearchError(c, err, "Failed to get code search index status")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// ReindexCodeSearch handles POST /api/v1/organizations/:org/code-search/reindex
+// The optional body {"repositories": [...]} limits the reindex to those
+// repositories; otherwise every repository of the organization is reindexed.
+func (h *CodeSearchHandlers) ReindexCodeSearch(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Repositories []string `json:"repositories"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+
+	queued, err := h.codeIndexService.Reindex(c.Request.Context(), c.Param("org"), userID, req.Repositories)
+	if err != nil {
+		h.codeSearchError(c, err, "Failed to reindex code search")
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"repositories": queued})
+}
+
+// repository resolves the repository in the path, writing a 404 when it
+// does not exist or the caller cannot read it
+func (h *CodeSearchHandlers) repository(c *gin.Context) (*models.Repository, bool) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return nil, false
+	}
+	if repo.Visibility != models.VisibilityPublic {
+		if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionRead) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+			return nil, false
+		}
+	}
+	return repo, true
+}
+
+// searchCode runs a code search with the q, mode, language, path, page and
+// per_page query parameters
+func (h *CodeSearchHandlers) searchCode(c *gin.Context, opts services.CodeSearchOptions) {
+	opts.Query = c.Query("q")
+	opts.Mode = c.Query("mode")
+	opts.Language = c.Query("language")
+	opts.Path = c.Query("path")
+	opts.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	opts.PerPage, _ = strconv.Atoi(c.DefaultQuery("per_page", "30"))
+	if opts.Query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'q' is required"})
+		return
+	}
+
+	results, err := h.codeSearchService.Search(c.Request.Context(), opts)
+	if err != nil {
+		h.codeSearchError(c, err, "Failed to search code")
+		return
+	}
+	c.Header("X-Total-Count", strconv.Itoa(results.TotalCount))
+	c.JSON(http.StatusOK, results)
+}
+
+// SearchCode handles GET /api/v1/search/code
+//
+// Query parameters: q, mode ("terms", "exact" or "regex"), language, path
+// (a glob such as "/cmd/**" or "*.go"), page and per_page. Results cover
+// the repositories the caller can read.
+func (h *CodeSearchHandlers) SearchCode(c *gin.Context) {
+	var opts services.CodeSearchOptions
+	if t, ok := tenant.FromContext(c.Request.Context()); ok {
+		opts.UserID = t.UserID
+	}
+	h.searchCode(c, opts)
+}
+
+// SearchRepositoryCode handles GET /api/v1/repositories/{owner}/{repo}/search/code
+func (h *CodeSearchHandlers) SearchRepositoryCode(c *gin.Context) {
+	repo, ok := h.repository(c)
+	if !ok {
+		return
+	}
+	h.searchCode(c, services.CodeSearchOptions{Repository: repo})
+}
+
+// GetRepositoryCodeSearchStatus handles GET /api/v1/repositories/{owner}/{repo}/search/code/status
+func (h *CodeSearchHandlers) GetRepositoryCodeSearchStatus(c *gin.Context) {
+	repo, ok := h.repository(c)
+	if !ok {
+		return
+	}
+	status, err := h.codeSearchService.IndexStatus(c.Request.Context(), repo.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository has not been indexed for code search"})
+		return
+	}
+	if err != nil {
+		h.codeSearchError(c, err, "Failed to get code search index status")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// ReindexRepositoryCode handles POST /api/v1/repos/{owner}/{repo}/search/code/reindex,
+// rebuilding the repository's code search index from scratch
+func (h *CodeSearchHandlers) ReindexRepositoryCode(c *gin.Context) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		return
+	}
+	if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Write access is required"})
+		return
+	}
+
+	status, err := h.codeSearchService.ReindexRepository(c.Request.Context(), repo.ID)
+	if err != nil && status == nil {
+		h.codeSearchError(c, err, "Failed to reindex code search")
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).WithField("repository_id", repo.ID).Warn("Failed to reindex code search")
+		c.JSON(http.StatusInternalServerError, status)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
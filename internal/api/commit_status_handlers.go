@@ -0,0 +1,210 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// CommitStatusHandlers serves the commit statuses and check runs external CI
+// reports, and the combined status branch protection gates merges on
+type CommitStatusHandlers struct {
+	commitStatusService services.CommitStatusService
+	checkRunService     services.CheckRunService
+	repositoryService   services.RepositoryService
+	gitService          git.GitService
+	logger              *logrus.Logger
+}
+
+func NewCommitStatusHandlers(commitStatusService services.CommitStatusService, checkRunService services.CheckRunService, repositoryService services.RepositoryService, gitService git.GitService, logger *logrus.Logger) *CommitStatusHandlers {
+	return &CommitStatusHandlers{
+		commitStatusService: commitStatusService,
+		checkRunService:     checkRunService,
+		repositoryService:   repositoryService,
+		gitService:          gitService,
+		logger:              logger,
+	}
+}
+
+// resolveRef turns the branch, tag or SHA in the path into a full commit SHA.
+// The ref shares the :sha wildcard of the commit routes.
+func (h *CommitStatusHandlers) resolveRef(c *gin.Context, repo *models.Repository) (string, bool) {
+	ref := c.Param("sha")
+	repoPath, err := h.repositoryService.GetRepositoryPath(c.Request.Context(), repo.ID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get repository path")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository path"})
+		return "", false
+	}
+	sha, err := h.gitService.ResolveSHA(c.Request.Context(), repoPath, ref)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No commit found for ref " + ref})
+		return "", false
+	}
+	return sha, true
+}
+
+func (h *CommitStatusHandlers) statusError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrCheckRunNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCommitStatus), errors.Is(err, services.ErrInvalidCheckRun):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// CreateStatus handles POST /api/v1/repositories/{owner}/{repo}/statuses/{sha}
+func (h *CommitStatusHandlers) CreateStatus(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	var req services.CreateCommitStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	status, err := h.commitStatusService.Create(c.Request.Context(), repo.ID, strings.ToLower(c.Param("sha")), optionalActor(c), req)
+	if err != nil {
+		h.statusError(c, err, "Failed to create commit status")
+		return
+	}
+	c.JSON(http.StatusCreated, status)
+}
+
+// ListStatuses handles GET /api/v1/repositories/{owner}/{repo}/commits/{ref}/statuses
+func (h *CommitStatusHandlers) ListStatuses(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	sha, ok := h.resolveRef(c, repo)
+	if !ok {
+		return
+	}
+	statuses, err := h.commitStatusService.List(c.Request.Context(), repo.ID, sha)
+	if err != nil {
+		h.statusError(c, err, "Failed to list commit statuses")
+		return
+	}
+	c.JSON(http.StatusOK, statuses)
+}
+
+// GetCombinedStatus handles GET /api/v1/repositories/{owner}/{repo}/commits/{ref}/status
+func (h *CommitStatusHandlers) GetCombinedStatus(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	sha, ok := h.resolveRef(c, repo)
+	if !ok {
+		return
+	}
+	combined, err := h.commitStatusService.Combined(c.Request.Context(), repo.ID, sha)
+	if err != nil {
+		h.statusError(c, err, "Failed to get combined commit status")
+		return
+	}
+	c.JSON(http.StatusOK, combined)
+}
+
+// CreateCheckRun handles POST /api/v1/repositories/{owner}/{repo}/check-runs
+func (h *CommitStatusHandlers) CreateCheckRun(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	var req services.CreateCheckRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	req.HeadSHA = strings.ToLower(req.HeadSHA)
+
+	run, err := h.checkRunService.Create(c.Request.Context(), repo.ID, optionalActor(c), req)
+	if err != nil {
+		h.statusError(c, err, "Failed to create check run")
+		return
+	}
+	c.JSON(http.StatusCreated, run)
+}
+
+func (h *CommitStatusHandlers) checkRunID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("check_run_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid check run ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// UpdateCheckRun handles PATCH /api/v1/repositories/{owner}/{repo}/check-runs/{check_run_id}
+func (h *CommitStatusHandlers) UpdateCheckRun(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	id, ok := h.checkRunID(c)
+	if !ok {
+		return
+	}
+	var req services.UpdateCheckRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	run, err := h.checkRunService.Update(c.Request.Context(), repo.ID, id, req)
+	if err != nil {
+		h.statusError(c, err, "Failed to update check run")
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// GetCheckRun handles GET /api/v1/repositories/{owner}/{repo}/check-runs/{check_run_id}
+func (h *CommitStatusHandlers) GetCheckRun(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	id, ok := h.checkRunID(c)
+	if !ok {
+		return
+	}
+	run, err := h.checkRunService.Get(c.Request.Context(), repo.ID, id)
+	if err != nil {
+		h.statusError(c, err, "Failed to get check run")
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// ListCheckRuns handles GET /api/v1/repositories/{owner}/{repo}/commits/{ref}/check-runs
+func (h *CommitStatusHandlers) ListCheckRuns(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	sha, ok := h.resolveRef(c, repo)
+	if !ok {
+		return
+	}
+	runs, err := h.checkRunService.List(c.Request.Context(), repo.ID, sha)
+	if err != nil {
+		h.statusError(c, err, "Failed to list check runs")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"total_count": len(runs), "check_runs": runs})
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// CommitVerificationHandlers serves organization reports on commit
+// signature verification coverage
+type CommitVerificationHandlers struct {
+	reportService services.CommitVerificationReportService
+	logger        *logrus.Logger
+}
+
+func NewCommitVerificationHandlers(reportService services.CommitVerificationReportService, logger *logrus.Logger) *CommitVerificationHandlers {
+	return &CommitVerificationHandlers{
+		reportService: reportService,
+		logger:        logger,
+	}
+}
+
+// GetOrganizationReport handles GET /api/v1/organizations/:org/security/commit-verification
+func (h *CommitVerificationHandlers) GetOrganizationReport(c *gin.Context) {
+	actorID, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	var opts services.CommitVerificationReportOptions
+	for param, target := range map[string]*int{"days": &opts.Days, "top_committers": &opts.TopCommitters} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be a positive number"})
+			return
+		}
+		*target = n
+	}
+
+	report, err := h.reportService.OrganizationReport(c.Request.Context(), c.Param("org"), actorID, opts)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, report)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrCommitVerificationReportForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCommitVerificationReport):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("Failed to build commit verification report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build commit verification report"})
+	}
+}
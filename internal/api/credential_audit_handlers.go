@@ -0,0 +1,154 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/logging"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// CredentialAuditHandlers serves credential usage reports and bulk
+// revocation for instance and organization security audits
+type CredentialAuditHandlers struct {
+	auditService services.CredentialAuditService
+	logger       *logrus.Logger
+}
+
+func NewCredentialAuditHandlers(auditService services.CredentialAuditService, logger *logrus.Logger) *CredentialAuditHandlers {
+	return &CredentialAuditHandlers{
+		auditService: auditService,
+		logger:       logger,
+	}
+}
+
+func (h *CredentialAuditHandlers) auditError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrCredentialAuditForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidCredentialAudit):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// auditRevocation records a bulk revocation in the audit log; org is empty
+// for instance revocations
+func (h *CredentialAuditHandlers) auditRevocation(actorID uuid.UUID, org string, result *services.CredentialRevocation) {
+	if result.DryRun {
+		return
+	}
+	fields := logrus.Fields{
+		"actor_id": actorID,
+		"revoked":  len(result.Revoked),
+		"failed":   len(result.Failed),
+	}
+	if org != "" {
+		fields["organization"] = org
+	}
+	logging.Default().Audit(h.logger).WithFields(fields).Info("Credentials revoked")
+}
+
+// reportOptions reads the inactive_days, type and inactive_only query parameters
+func (h *CredentialAuditHandlers) reportOptions(c *gin.Context) (services.CredentialReportOptions, bool) {
+	var opts services.CredentialReportOptions
+	if days := c.Query("inactive_days"); days != "" {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "inactive_days must be a positive number"})
+			return opts, false
+		}
+		opts.InactiveDays = n
+	}
+	for _, t := range c.QueryArray("type") {
+		for _, part := range strings.Split(t, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				opts.Types = append(opts.Types, part)
+			}
+		}
+	}
+	opts.InactiveOnly, _ = strconv.ParseBool(c.Query("inactive_only"))
+	return opts, true
+}
+
+// GetInstanceCredentialReport handles GET /api/v1/admin/security/credentials
+func (h *CredentialAuditHandlers) GetInstanceCredentialReport(c *gin.Context) {
+	opts, ok := h.reportOptions(c)
+	if !ok {
+		return
+	}
+	report, err := h.auditService.InstanceReport(c.Request.Context(), opts)
+	if err != nil {
+		h.auditError(c, err, "Failed to build credential report")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// RevokeInstanceCredentials handles POST /api/v1/admin/security/credentials/revoke
+func (h *CredentialAuditHandlers) RevokeInstanceCredentials(c *gin.Context) {
+	actorID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req services.CredentialRevocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	result, err := h.auditService.InstanceRevoke(c.Request.Context(), actorID, req)
+	if err != nil {
+		h.auditError(c, err, "Failed to revoke credentials")
+		return
+	}
+	h.auditRevocation(actorID, "", result)
+	c.JSON(http.StatusOK, result)
+}
+
+// GetOrganizationCredentialReport handles GET /api/v1/organizations/:org/security/credentials
+func (h *CredentialAuditHandlers) GetOrganizationCredentialReport(c *gin.Context) {
+	actorID, ok := actor(c)
+	if !ok {
+		return
+	}
+	opts, ok := h.reportOptions(c)
+	if !ok {
+		return
+	}
+	report, err := h.auditService.OrganizationReport(c.Request.Context(), c.Param("org"), actorID, opts)
+	if err != nil {
+		h.auditError(c, err, "Failed to build credential report")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// RevokeOrganizationCredentials handles POST /api/v1/organizations/:org/security/credentials/revoke
+func (h *CredentialAuditHandlers) RevokeOrganizationCredentials(c *gin.Context) {
+	actorID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req services.CredentialRevocationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	result, err := h.auditService.OrganizationRevoke(c.Request.Context(), c.Param("org"), actorID, req)
+	if err != nil {
+		h.auditError(c, err, "Failed to revoke credentials")
+		return
+	}
+	h.auditRevocation(actorID, c.Param("org"), result)
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/i18n"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// DashboardHandlers serves organization metric dashboard endpoints
+type DashboardHandlers struct {
+	dashboardService services.DashboardService
+	logger           *logrus.Logger
+}
+
+func NewDashboardHandlers(dashboardService services.DashboardService, logger *logrus.Logger) *DashboardHandlers {
+	return &DashboardHandlers{
+		dashboardService: dashboardService,
+		logger:           logger,
+	}
+}
+
+// dashboardID parses the :dashboard_id path parameter
+func (h *DashboardHandlers) dashboardID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("dashboard_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dashboard ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *DashboardHandlers) handleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrDashboardForbidden), errors.Is(err, services.ErrDashboardMembersOnly):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDashboardNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidDashboard):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.For(c.Request.Context()).T("error.organization_not_found")})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListDashboards handles GET /api/v1/organizations/:org/dashboards
+func (h *DashboardHandlers) ListDashboards(c *gin.Context) {
+	uid, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	dashboards, err := h.dashboardService.List(c.Request.Context(), c.Param("org"), uid)
+	if err != nil {
+		h.handleError(c, err, "Failed to list dashboards")
+		return
+	}
+
+	c.JSON(http.StatusOK, dashboards)
+}
+
+// CreateDashboard handles POST /api/v1/organizations/:org/dashboards
+func (h *DashboardHandlers) CreateDashboard(c *gin.Context) {
+	uid, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateDashboardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dashboard, err := h.dashboardService.Create(c.Request.Context(), c.Param("org"), uid, req)
+	if err != nil {
+		h.handleError(c, err, "Failed to create dashboard")
+		return
+	}
+
+	c.JSON(http.StatusCreated, dashboard)
+}
+
+// GetDashboard handles GET /api/v1/organizations/:org/dashboards/:dashboard_id
+func (h *DashboardHandlers) GetDashboard(c *gin.Context) {
+	uid, ok := actor(c)
+	if !ok {
+		return
+	}
+	id, ok := h.dashboardID(c)
+	if !ok {
+		return
+	}
+
+	dashboard, err := h.dashboardService.Get(c.Request.Context(), c.Param("org"), id, uid)
+	if err != nil {
+		h.handleError(c, err, "Failed to get dashboard")
+		return
+	}
+
+	c.JSON(http.StatusOK, dashboard)
+}
+
+// UpdateDashboard handles PATCH /api/v1/organizations/:org/dashboards/:dashboard_id
+func (h *DashboardHandlers) UpdateDashboard(c *gin.Context) {
+	uid, ok := actor(c)
+	if !ok {
+		return
+	}
+	id, ok := h.dashboardID(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateDashboardRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dashboard, err := h.dashboardService.Update(c.Request.Context(), c.Param("org"), id, uid, req)
+	if err != nil {
+		h.handleError(c, err, "Failed to update dashboard")
+		return
+	}
+
+	c.JSON(http.StatusOK, dashboard)
+}
+
+// DeleteDashboard handles DELETE /api/v1/organizations/:org/dashboards/:dashboard_id
+func (h *DashboardHandlers) DeleteDashboard(c *gin.Context) {
+	uid, ok := actor(c)
+	if !ok {
+		return
+	}
+	id, ok := h.dashboardID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.dashboardService.Delete(c.Request.Context(), c.Param("org"), id, uid); err != nil {
+		h.handleError(c, err, "Failed to delete dashboard")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetDashboardData handles GET /api/v1/organizations/:org/dashboards/:dashboard_id/data
+// It runs every widget's metric query and reports which thresholds are crossed.
+func (h *DashboardHandlers) GetDashboardData(c *gin.Context) {
+	uid, ok := actor(c)
+	if !ok {
+		return
+	}
+	id, ok := h.dashboardID(c)
+	if !ok {
+		return
+	}
+
+	data, err := h.dashboardService.Evaluate(c.Request.Context(), c.Param("org"), id, uid, time.Now())
+	if err != nil {
+		h.handleError(c, err, "Failed to evaluate dashboard")
+		return
+	}
+
+	c.JSON(http.StatusOK, data)
+}
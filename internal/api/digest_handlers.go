@@ -0,0 +1,152 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/i18n"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// DigestHandlers serves organization news feed digest endpoints
+type DigestHandlers struct {
+	digestService services.DigestService
+	logger        *logrus.Logger
+}
+
+func NewDigestHandlers(digestService services.DigestService, logger *logrus.Logger) *DigestHandlers {
+	return &DigestHandlers{
+		digestService: digestService,
+		logger:        logger,
+	}
+}
+
+// GetDigestSettings handles GET /api/v1/organizations/:org/digest/settings
+func (h *DigestHandlers) GetDigestSettings(c *gin.Context) {
+	settings, err := h.digestService.GetSettings(c.Request.Context(), c.Param("org"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.For(c.Request.Context()).T("error.organization_not_found")})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// UpdateDigestSettings handles PATCH /api/v1/organizations/:org/digest/settings
+func (h *DigestHandlers) UpdateDigestSettings(c *gin.Context) {
+	uid, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateDigestSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	settings, err := h.digestService.UpdateSettings(c.Request.Context(), c.Param("org"), uid, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDigestForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidDigestFrequency):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			h.logger.WithError(err).Error("Failed to update digest settings")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update digest settings"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// GetDigestSubscription handles GET /api/v1/organizations/:org/digest/subscription
+func (h *DigestHandlers) GetDigestSubscription(c *gin.Context) {
+	uid, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	sub, err := h.digestService.GetSubscription(c.Request.Context(), c.Param("org"), uid)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// UpdateDigestSubscription handles PUT /api/v1/organizations/:org/digest/subscription
+func (h *DigestHandlers) UpdateDigestSubscription(c *gin.Context) {
+	uid, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Frequency models.DigestFrequency `json:"frequency" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sub, err := h.digestService.UpdateSubscription(c.Request.Context(), c.Param("org"), uid, req.Frequency)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidDigestFrequency) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, sub)
+}
+
+// PreviewDigest handles GET /api/v1/organizations/:org/digest/preview
+func (h *DigestHandlers) PreviewDigest(c *gin.Context) {
+	uid, ok := actor(c)
+	if !ok {
+		return
+	}
+	frequency := models.DigestFrequency(c.DefaultQuery("frequency", string(models.DigestFrequencyDefault)))
+
+	digest, err := h.digestService.BuildDigest(c.Request.Context(), c.Param("org"), uid, frequency, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDigestOrgNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": i18n.For(c.Request.Context()).T("error.organization_not_found")})
+		case errors.Is(err, services.ErrDigestNotMember):
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			h.logger.WithError(err).Error("Failed to build digest preview")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build digest"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, digest)
+}
+
+// Unsubscribe handles GET /api/v1/digests/unsubscribe
+func (h *DigestHandlers) Unsubscribe(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.For(c.Request.Context()).T("error.unsubscribe_token_required")})
+		return
+	}
+
+	if err := h.digestService.Unsubscribe(c.Request.Context(), token); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": i18n.For(c.Request.Context()).T("error.invalid_unsubscribe_link")})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": i18n.For(c.Request.Context()).T("digest.unsubscribed")})
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// DisasterRecoveryHandlers lets site admins check how far disaster
+// recovery replication has come and whether replicas verify
+type DisasterRecoveryHandlers struct {
+	disasterRecoveryService services.DisasterRecoveryService
+	logger                  *logrus.Logger
+}
+
+func NewDisasterRecoveryHandlers(disasterRecoveryService services.DisasterRecoveryService, logger *logrus.Logger) *DisasterRecoveryHandlers {
+	return &DisasterRecoveryHandlers{
+		disasterRecoveryService: disasterRecoveryService,
+		logger:                  logger,
+	}
+}
+
+// GetStatus handles GET /api/v1/admin/disaster-recovery
+func (h *DisasterRecoveryHandlers) GetStatus(c *gin.Context) {
+	status, err := h.disasterRecoveryService.Status(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get disaster recovery status")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get disaster recovery status"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
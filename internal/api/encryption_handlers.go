@@ -0,0 +1,62 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// EncryptionHandlers serves the admin endpoints for secret encryption at rest
+type EncryptionHandlers struct {
+	encryptionService services.SecretEncryptionService
+	logger            *logrus.Logger
+}
+
+func NewEncryptionHandlers(encryptionService services.SecretEncryptionService, logger *logrus.Logger) *EncryptionHandlers {
+	return &EncryptionHandlers{
+		encryptionService: encryptionService,
+		logger:            logger,
+	}
+}
+
+// GetEncryptionStatus handles GET /api/v1/admin/encryption
+func (h *EncryptionHandlers) GetEncryptionStatus(c *gin.Context) {
+	status, err := h.encryptionService.Status(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get encryption status")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get encryption status"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// RotateEncryptionKeys handles POST /api/v1/admin/encryption/rotate,
+// re-encrypting every stored secret with the current master key
+func (h *EncryptionHandlers) RotateEncryptionKeys(c *gin.Context) {
+	result, err := h.encryptionService.Rotate(c.Request.Context())
+	if err != nil {
+		if errors.Is(err, services.ErrEncryptionDisabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to rotate encryption keys")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate encryption keys"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// VerifyEncryptedSecrets handles POST /api/v1/admin/encryption/verify,
+// reporting stored secrets that fail their integrity check
+func (h *EncryptionHandlers) VerifyEncryptedSecrets(c *gin.Context) {
+	result, err := h.encryptionService.Verify(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to verify encrypted secrets")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify encrypted secrets"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ExternalCollaboratorHandlers lets site admins list external collaborator
+// accounts and convert accounts between external and full
+type ExternalCollaboratorHandlers struct {
+	externalService services.ExternalCollaboratorService
+	logger          *logrus.Logger
+}
+
+func NewExternalCollaboratorHandlers(externalService services.ExternalCollaboratorService, logger *logrus.Logger) *ExternalCollaboratorHandlers {
+	return &ExternalCollaboratorHandlers{
+		externalService: externalService,
+		logger:          logger,
+	}
+}
+
+// ListExternalCollaborators handles GET /api/v1/admin/users/external
+func (h *ExternalCollaboratorHandlers) ListExternalCollaborators(c *gin.Context) {
+	collaborators, err := h.externalService.List(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list external collaborators")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list external collaborators"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"external_collaborators": collaborators, "total_count": len(collaborators)})
+}
+
+// SetAccountType handles PATCH /api/v1/admin/users/:id/account_type
+func (h *ExternalCollaboratorHandlers) SetAccountType(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+	var req struct {
+		IsExternal *bool `json:"is_external" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	actorID, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	convert := h.externalService.ConvertToFull
+	if *req.IsExternal {
+		convert = h.externalService.ConvertToExternal
+	}
+	user, err := convert(c.Request.Context(), userID, actorID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	case errors.Is(err, services.ErrExternalConversionConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		h.logger.WithError(err).WithField("user_id", userID).Error("Failed to change account type")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change account type"})
+		return
+	}
+	c.JSON(http.StatusOK, toAdminUserResponse(user))
+}
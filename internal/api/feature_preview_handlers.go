@@ -0,0 +1,85 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// FeaturePreviewHandlers serves the beta features of repositories
+type FeaturePreviewHandlers struct {
+	previewService services.FeaturePreviewService
+	logger         *logrus.Logger
+}
+
+func NewFeaturePreviewHandlers(previewService services.FeaturePreviewService, logger *logrus.Logger) *FeaturePreviewHandlers {
+	return &FeaturePreviewHandlers{
+		previewService: previewService,
+		logger:         logger,
+	}
+}
+
+// setFeaturePreviewRequest switches a preview on or off
+type setFeaturePreviewRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+func (h *FeaturePreviewHandlers) previewError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrFeaturePreviewNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListFeaturePreviews handles GET /api/v1/repositories/{owner}/{repo}/feature-previews
+func (h *FeaturePreviewHandlers) ListFeaturePreviews(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	previews, err := h.previewService.List(c.Request.Context(), repo.ID)
+	if err != nil {
+		h.previewError(c, err, "Failed to list feature previews")
+		return
+	}
+	c.JSON(http.StatusOK, previews)
+}
+
+// SetFeaturePreview handles PUT /api/v1/repositories/{owner}/{repo}/feature-previews/{feature}
+func (h *FeaturePreviewHandlers) SetFeaturePreview(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+
+	var req setFeaturePreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	preview, err := h.previewService.Set(c.Request.Context(), repo.ID, c.Param("feature"), *req.Enabled, optionalActor(c))
+	if err != nil {
+		h.previewError(c, err, "Failed to update feature preview")
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
+// GetAdoption handles GET /api/v1/admin/feature-previews
+func (h *FeaturePreviewHandlers) GetAdoption(c *gin.Context) {
+	adoption, err := h.previewService.Adoption(c.Request.Context())
+	if err != nil {
+		h.previewError(c, err, "Failed to get feature preview adoption")
+		return
+	}
+	c.JSON(http.StatusOK, adoption)
+}
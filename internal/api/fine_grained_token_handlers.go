@@ -0,0 +1,212 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// FineGrainedTokenHandlers serves fine-grained personal access tokens and
+// their organization approval flow
+type FineGrainedTokenHandlers struct {
+	tokenService services.FineGrainedTokenService
+	logger       *logrus.Logger
+}
+
+func NewFineGrainedTokenHandlers(tokenService services.FineGrainedTokenService, logger *logrus.Logger) *FineGrainedTokenHandlers {
+	return &FineGrainedTokenHandlers{
+		tokenService: tokenService,
+		logger:       logger,
+	}
+}
+
+// createdTokenResponse carries the token secret, shown only once
+type createdTokenResponse struct {
+	*models.FineGrainedToken
+	Token string `json:"token"`
+}
+
+// ReviewTokenRequest records the reason for approving or denying a token
+type ReviewTokenRequest struct {
+	Reason string `json:"reason"`
+}
+
+// IntrospectTokenRequest names the token to inspect
+type IntrospectTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// tokenID parses the :token_id path parameter
+func (h *FineGrainedTokenHandlers) tokenID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("token_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid token ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *FineGrainedTokenHandlers) tokenError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrFineGrainedTokenNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrTokenReviewForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidFineGrainedToken):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListScopes handles GET /api/v1/user/tokens/scopes
+func (h *FineGrainedTokenHandlers) ListScopes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"scopes": models.PATScopes})
+}
+
+// CreateToken handles POST /api/v1/user/tokens
+func (h *FineGrainedTokenHandlers) CreateToken(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req services.CreateFineGrainedTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	token, secret, err := h.tokenService.Create(c.Request.Context(), userID, req)
+	if err != nil {
+		h.tokenError(c, err, "Failed to create token")
+		return
+	}
+	c.JSON(http.StatusCreated, createdTokenResponse{FineGrainedToken: token, Token: secret})
+}
+
+// ListTokens handles GET /api/v1/user/tokens
+func (h *FineGrainedTokenHandlers) ListTokens(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	tokens, err := h.tokenService.List(c.Request.Context(), userID)
+	if err != nil {
+		h.tokenError(c, err, "Failed to list tokens")
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// GetToken handles GET /api/v1/user/tokens/:token_id
+func (h *FineGrainedTokenHandlers) GetToken(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	tokenID, ok := h.tokenID(c)
+	if !ok {
+		return
+	}
+	token, err := h.tokenService.Get(c.Request.Context(), userID, tokenID)
+	if err != nil {
+		h.tokenError(c, err, "Failed to get token")
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
+
+// RevokeToken handles DELETE /api/v1/user/tokens/:token_id
+func (h *FineGrainedTokenHandlers) RevokeToken(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	tokenID, ok := h.tokenID(c)
+	if !ok {
+		return
+	}
+	if err := h.tokenService.Revoke(c.Request.Context(), userID, tokenID); err != nil {
+		h.tokenError(c, err, "Failed to revoke token")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// IntrospectToken handles POST /api/v1/tokens/introspect
+func (h *FineGrainedTokenHandlers) IntrospectToken(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req IntrospectTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	result, err := h.tokenService.Introspect(c.Request.Context(), req.Token, userID, c.GetBool("is_admin"))
+	if err != nil {
+		h.tokenError(c, err, "Failed to introspect token")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// ListTokenRequests handles GET /api/v1/organizations/:org/token-requests
+func (h *FineGrainedTokenHandlers) ListTokenRequests(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	tokens, err := h.tokenService.ListRequests(c.Request.Context(), c.Param("org"), userID)
+	if err != nil {
+		h.tokenError(c, err, "Failed to list token requests")
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// ApproveTokenRequest handles POST /api/v1/organizations/:org/token-requests/:token_id/approve
+func (h *FineGrainedTokenHandlers) ApproveTokenRequest(c *gin.Context) {
+	h.reviewTokenRequest(c, true)
+}
+
+// DenyTokenRequest handles POST /api/v1/organizations/:org/token-requests/:token_id/deny
+func (h *FineGrainedTokenHandlers) DenyTokenRequest(c *gin.Context) {
+	h.reviewTokenRequest(c, false)
+}
+
+func (h *FineGrainedTokenHandlers) reviewTokenRequest(c *gin.Context, approve bool) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	tokenID, ok := h.tokenID(c)
+	if !ok {
+		return
+	}
+	var req ReviewTokenRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+
+	token, err := h.tokenService.Review(c.Request.Context(), c.Param("org"), userID, tokenID, approve, req.Reason)
+	if err != nil {
+		h.tokenError(c, err, "Failed to review token request")
+		return
+	}
+	c.JSON(http.StatusOK, token)
+}
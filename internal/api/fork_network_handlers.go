@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ForkNetworkHandlers serves the admin report of fork network storage
+type ForkNetworkHandlers struct {
+	forkNetworkService services.ForkNetworkService
+	logger             *logrus.Logger
+}
+
+func NewForkNetworkHandlers(forkNetworkService services.ForkNetworkService, logger *logrus.Logger) *ForkNetworkHandlers {
+	return &ForkNetworkHandlers{
+		forkNetworkService: forkNetworkService,
+		logger:             logger,
+	}
+}
+
+// GetReport handles GET /api/v1/admin/storage/fork-networks
+func (h *ForkNetworkHandlers) GetReport(c *gin.Context) {
+	report, err := h.forkNetworkService.Report(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to report fork network storage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to report fork network storage"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"bytes"
 	"compress/gzip"
 	"fmt"
 	"io"
@@ -8,11 +9,15 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strings"
 
 	"github.com/a5c-ai/hub/internal/auth"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/middleware"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
@@ -20,274 +25,248 @@ import (
 // GitHandlers contains handlers for Git HTTP protocol endpoints
 type GitHandlers struct {
 	repositoryService services.RepositoryService
+	packs             *git.PackStore
 	logger            *logrus.Logger
 	jwtManager        *auth.JWTManager
+	// pushDispatcher, when set, is notified of ref updates after each receive-pack
+	pushDispatcher *services.PushDispatcher
 }
 
 // NewGitHandlers creates a new Git handlers instance
-func NewGitHandlers(repositoryService services.RepositoryService, logger *logrus.Logger, jwtManager *auth.JWTManager) *GitHandlers {
+func NewGitHandlers(repositoryService services.RepositoryService, packs *git.PackStore, pushDispatcher *services.PushDispatcher, logger *logrus.Logger, jwtManager *auth.JWTManager) *GitHandlers {
 	return &GitHandlers{
 		repositoryService: repositoryService,
+		packs:             packs,
 		logger:            logger,
 		jwtManager:        jwtManager,
+		pushDispatcher:    pushDispatcher,
 	}
 }
 
-// InfoRefs handles GET /{owner}/{repo.git}/info/refs
-func (h *GitHandlers) InfoRefs(c *gin.Context) {
-	owner := c.Param("owner")
-	repoName := c.Param("repo") // Already without .git suffix since route pattern is :repo.git
-	service := c.Query("service")
+// gitAuthRealm is the Basic realm git clients are challenged with; they
+// answer with a username and a token as the password
+const gitAuthRealm = `Basic realm="Hub"`
 
-	h.logger.WithFields(logrus.Fields{
-		"owner":   owner,
-		"repo":    repoName,
-		"service": service,
-	}).Info("Git info/refs request")
+// gitProtocolPattern matches the Git-Protocol header values passed to git
+var gitProtocolPattern = regexp.MustCompile(`^[A-Za-z0-9=:._-]+$`)
 
-	if repoName == "" {
-		h.logger.Error("Repository name is empty")
-		c.Status(http.StatusBadRequest)
-		return
+// gitTenant returns the tenant of a git request. Handlers used without
+// TenantMiddleware fall back to the Bearer JWT of the request, which
+// authenticates the user without granting a repository permission.
+func (h *GitHandlers) gitTenant(c *gin.Context) *tenant.Context {
+	if t, ok := tenant.FromContext(c.Request.Context()); ok {
+		return t
+	}
+	t := &tenant.Context{}
+	if parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2); len(parts) == 2 && parts[0] == "Bearer" {
+		if claims, err := h.jwtManager.ValidateToken(parts[1]); err == nil {
+			t.UserID = &claims.UserID
+			t.Username = claims.Username
+			t.IsAdmin = claims.IsAdmin
+		}
 	}
+	return t
+}
 
-	// Get repository
+// gitAccessStatus returns 0 when t may read, or with write push to, repo
+// over git, and otherwise the status to answer with. Public repositories
+// can be read anonymously; private repositories are reported as not found
+// to users who cannot read them.
+func gitAccessStatus(c *gin.Context, t *tenant.Context, repo *models.Repository, write bool) int {
+	if !write && repo.Visibility == models.VisibilityPublic {
+		return 0
+	}
+	if t == nil || !t.IsAuthenticated() {
+		return http.StatusUnauthorized
+	}
+	if !middleware.GitTokenAllows(c, repo.ID, write) {
+		return http.StatusForbidden
+	}
+	required := models.PermissionRead
+	if write {
+		required = models.PermissionWrite
+	}
+	if t.HasPermission(required) {
+		return 0
+	}
+	if t.HasPermission(models.PermissionRead) || repo.Visibility == models.VisibilityPublic {
+		return http.StatusForbidden
+	}
+	return http.StatusNotFound
+}
+
+// gitRepository resolves the repository of a git request, checks access
+// and returns it with its filesystem path
+func (h *GitHandlers) gitRepository(c *gin.Context, write bool) (*models.Repository, *tenant.Context, string, bool) {
+	owner := c.Param("owner")
+	repoName := gitRepoName(c)
 	repo, err := h.repositoryService.Get(c.Request.Context(), owner, repoName)
 	if err != nil {
-		h.logger.WithError(err).WithFields(logrus.Fields{
-			"owner": owner,
-			"repo":  repoName,
-		}).Error("Failed to get repository in git handler")
 		if err.Error() == "repository not found" {
 			c.Status(http.StatusNotFound)
 		} else {
-			h.logger.WithError(err).Error("Failed to get repository")
+			h.logger.WithError(err).WithFields(logrus.Fields{
+				"owner": owner,
+				"repo":  repoName,
+			}).Error("Failed to get repository")
 			c.Status(http.StatusInternalServerError)
 		}
-		return
+		return nil, nil, "", false
 	}
 
-	h.logger.WithFields(logrus.Fields{
-		"repo_id": repo.ID,
-		"owner":   owner,
-		"repo":    repoName,
-	}).Info("Repository found in database")
+	t := h.gitTenant(c)
+	switch status := gitAccessStatus(c, t, repo, write); status {
+	case 0:
+	case http.StatusUnauthorized:
+		c.Header("WWW-Authenticate", gitAuthRealm)
+		c.JSON(status, gin.H{"error": "Authentication required"})
+		return nil, nil, "", false
+	case http.StatusForbidden:
+		c.JSON(status, gin.H{"error": "Permission denied"})
+		return nil, nil, "", false
+	default:
+		c.Status(status)
+		return nil, nil, "", false
+	}
 
-	// Get repository path
 	repoPath, err := h.repositoryService.GetRepositoryPath(c.Request.Context(), repo.ID)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to get repository path")
 		c.Status(http.StatusInternalServerError)
+		return nil, nil, "", false
+	}
+	return repo, t, repoPath, true
+}
+
+// InfoRefs handles GET /{owner}/{repo}.git/info/refs
+//
+// With ?service=git-upload-pack or git-receive-pack the refs are advertised
+// for the smart protocol; without it the dumb protocol's info/refs is served.
+func (h *GitHandlers) InfoRefs(c *gin.Context) {
+	service := c.Query("service")
+	if service != "" && service != "git-upload-pack" && service != "git-receive-pack" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Unsupported service"})
 		return
 	}
 
-	h.logger.WithField("path", repoPath).Info("Repository path determined")
+	_, _, repoPath, ok := h.gitRepository(c, service == "git-receive-pack")
+	if !ok {
+		return
+	}
 
-	// Check if repository exists on filesystem, create if needed
+	// Initialize repositories created without a git directory on first access
 	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
+		repo, _ := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), gitRepoName(c))
 		h.logger.WithField("path", repoPath).Info("Repository doesn't exist on filesystem, initializing")
 		if err := h.repositoryService.InitializeGitRepository(c.Request.Context(), repo.ID); err != nil {
 			h.logger.WithError(err).Error("Failed to initialize Git repository")
 			c.Status(http.StatusInternalServerError)
 			return
 		}
-		h.logger.WithField("path", repoPath).Info("Repository initialized successfully")
-	} else {
-		h.logger.WithField("path", repoPath).Info("Repository already exists on filesystem")
 	}
 
 	switch service {
 	case "git-upload-pack":
-		h.handleUploadPackInfoRefs(c, repoPath)
+		// A fetch follows, so start restoring any offloaded packs now
+		h.packs.Prefetch(repoPath)
+		h.advertiseRefs(c, repoPath, service)
 	case "git-receive-pack":
-		h.handleReceivePackInfoRefs(c, repoPath)
+		h.advertiseRefs(c, repoPath, service)
 	default:
 		h.handleDumbInfoRefs(c, repoPath)
 	}
 }
 
-// UploadPack handles POST /{owner}/{repo.git}/git-upload-pack
+// UploadPack handles POST /{owner}/{repo}.git/git-upload-pack
 func (h *GitHandlers) UploadPack(c *gin.Context) {
-	owner := c.Param("owner")
-	repoName := c.Param("repo") // Already without .git suffix since route pattern is :repo.git
-
-	h.logger.WithFields(logrus.Fields{
-		"owner": owner,
-		"repo":  repoName,
-	}).Info("Git upload-pack request")
-
-	// Get repository
-	repo, err := h.repositoryService.Get(c.Request.Context(), owner, repoName)
-	if err != nil {
-		if err.Error() == "repository not found" {
-			c.Status(http.StatusNotFound)
-		} else {
-			h.logger.WithError(err).Error("Failed to get repository")
-			c.Status(http.StatusInternalServerError)
-		}
+	_, _, repoPath, ok := h.gitRepository(c, false)
+	if !ok {
 		return
 	}
 
-	// Enforce authentication for private repositories
-	if repo.Visibility == models.VisibilityPrivate {
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			return
-		}
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
-			return
-		}
-		if _, err := h.jwtManager.ValidateToken(parts[1]); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			return
-		}
-	}
-
-	// Get repository path
-	repoPath, err := h.repositoryService.GetRepositoryPath(c.Request.Context(), repo.ID)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to get repository path")
-		c.Status(http.StatusInternalServerError)
+	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
+		c.Status(http.StatusNotFound)
 		return
 	}
 
-	// Ensure repository exists
-	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
-		h.logger.WithError(err).Error("Repository path does not exist")
-		c.Status(http.StatusNotFound)
+	// upload-pack reads the objects it sends
+	if err := h.packs.Ensure(c.Request.Context(), repoPath); err != nil {
+		h.logger.WithError(err).Error("Failed to restore offloaded packs")
+		c.Status(http.StatusServiceUnavailable)
 		return
 	}
 
-	h.handleGitCommand(c, repoPath, "git-upload-pack", "--stateless-rpc", repoPath)
+	h.serviceRPC(c, repoPath, "git-upload-pack")
 }
 
-// ReceivePack handles POST /{owner}/{repo.git}/git-receive-pack
+// ReceivePack handles POST /{owner}/{repo}.git/git-receive-pack
 func (h *GitHandlers) ReceivePack(c *gin.Context) {
-	owner := c.Param("owner")
-	repoName := c.Param("repo") // Already without .git suffix since route pattern is :repo.git
-
-	h.logger.WithFields(logrus.Fields{
-		"owner": owner,
-		"repo":  repoName,
-	}).Info("Git receive-pack request")
-
-	// Get repository
-	repo, err := h.repositoryService.Get(c.Request.Context(), owner, repoName)
-	if err != nil {
-		if err.Error() == "repository not found" {
-			c.Status(http.StatusNotFound)
-		} else {
-			h.logger.WithError(err).Error("Failed to get repository")
-			c.Status(http.StatusInternalServerError)
-		}
+	repo, t, repoPath, ok := h.gitRepository(c, true)
+	if !ok {
 		return
 	}
 
-	// Enforce authentication for push operations
-	{
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			return
-		}
-		parts := strings.SplitN(authHeader, " ", 2)
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
-			return
-		}
-		if _, err := h.jwtManager.ValidateToken(parts[1]); err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
-			return
-		}
+	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
+		h.logger.WithField("path", repoPath).Error("Repository path does not exist")
+		c.Status(http.StatusNotFound)
+		return
 	}
 
-	// Get repository path
-	repoPath, err := h.repositoryService.GetRepositoryPath(c.Request.Context(), repo.ID)
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to get repository path")
-		c.Status(http.StatusInternalServerError)
+	// receive-pack checks connectivity against existing objects
+	if err := h.packs.Ensure(c.Request.Context(), repoPath); err != nil {
+		h.logger.WithError(err).Error("Failed to restore offloaded packs")
+		c.Status(http.StatusServiceUnavailable)
 		return
 	}
 
-	// Ensure repository exists
-	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
-		h.logger.WithError(err).Error("Repository path does not exist")
-		c.Status(http.StatusNotFound)
+	before := h.snapshotRefs(repoPath)
+	if !h.serviceRPC(c, repoPath, "git-receive-pack") {
 		return
 	}
 
-	h.handleGitCommand(c, repoPath, "git-receive-pack", "--stateless-rpc", repoPath)
+	if h.pushDispatcher != nil {
+		h.pushDispatcher.Dispatch(services.PushEvent{
+			Repository: repo,
+			PusherID:   t.UserID,
+			Updates:    services.DiffRefs(before, h.snapshotRefs(repoPath)),
+		})
+	}
 }
 
-// Helper methods
-
-func (h *GitHandlers) handleUploadPackInfoRefs(c *gin.Context, repoPath string) {
-	c.Header("Content-Type", "application/x-git-upload-pack-advertisement")
-	c.Header("Cache-Control", "no-cache")
-
-	// Write packet header
-	c.Writer.Write(h.packetWrite("# service=git-upload-pack\n"))
-	c.Writer.Write([]byte("0000"))
-
-	// Execute git-upload-pack command from the repository directory
-	cmd := exec.Command("git", "upload-pack", "--stateless-rpc", "--advertise-refs", ".")
+// snapshotRefs returns every ref in the repository mapped to its SHA
+func (h *GitHandlers) snapshotRefs(repoPath string) map[string]string {
+	refs := make(map[string]string)
+	cmd := exec.Command("git", "for-each-ref", "--format=%(objectname) %(refname)")
 	cmd.Dir = repoPath
-
-	h.logger.WithFields(logrus.Fields{
-		"command": cmd.String(),
-		"dir":     repoPath,
-		"args":    cmd.Args,
-	}).Info("Executing git upload-pack command")
-
 	output, err := cmd.Output()
 	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			h.logger.WithError(err).WithField("stderr", string(exitError.Stderr)).Error("Failed to execute git-upload-pack")
-		} else {
-			h.logger.WithError(err).Error("Failed to execute git-upload-pack")
+		h.logger.WithError(err).Warn("Failed to list repository refs")
+		return refs
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		if parts := strings.SplitN(line, " ", 2); len(parts) == 2 {
+			refs[parts[1]] = parts[0]
 		}
-		c.Status(http.StatusInternalServerError)
-		return
 	}
-
-	h.logger.WithField("output_size", len(output)).Info("Git upload-pack completed successfully")
-	c.Writer.Write(output)
+	return refs
 }
 
-func (h *GitHandlers) handleReceivePackInfoRefs(c *gin.Context, repoPath string) {
-	c.Header("Content-Type", "application/x-git-receive-pack-advertisement")
-	c.Header("Cache-Control", "no-cache")
-
-	// Write packet header
-	c.Writer.Write(h.packetWrite("# service=git-receive-pack\n"))
-	c.Writer.Write([]byte("0000"))
-
-	// Execute git receive-pack command from the repository directory
-	cmd := exec.Command("git", "receive-pack", "--stateless-rpc", "--advertise-refs", ".")
-	cmd.Dir = repoPath
+// Helper methods
 
-	h.logger.WithFields(logrus.Fields{
-		"command": cmd.String(),
-		"dir":     repoPath,
-		"args":    cmd.Args,
-	}).Info("Executing git receive-pack command")
+// advertiseRefs streams the smart protocol ref advertisement of service
+func (h *GitHandlers) advertiseRefs(c *gin.Context, repoPath, service string) {
+	c.Header("Content-Type", "application/x-"+service+"-advertisement")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
 
-	output, err := cmd.Output()
-	if err != nil {
-		if exitError, ok := err.(*exec.ExitError); ok {
-			h.logger.WithError(err).WithField("stderr", string(exitError.Stderr)).Error("Failed to execute git-receive-pack")
-		} else {
-			h.logger.WithError(err).Error("Failed to execute git-receive-pack")
-		}
-		c.Status(http.StatusInternalServerError)
-		return
+	// Protocol v2 clients get the capability advertisement without the
+	// service announcement
+	if !strings.Contains(c.GetHeader("Git-Protocol"), "version=2") {
+		c.Writer.Write(h.packetWrite("# service=" + service + "\n"))
+		c.Writer.Write([]byte("0000"))
 	}
 
-	h.logger.WithField("output_size", len(output)).Info("Git receive-pack completed successfully")
-	c.Writer.Write(output)
+	h.runGit(c, repoPath, nil, strings.TrimPrefix(service, "git-"), "--stateless-rpc", "--advertise-refs", ".")
 }
 
 func (h *GitHandlers) handleDumbInfoRefs(c *gin.Context, repoPath string) {
@@ -311,107 +290,71 @@ func (h *GitHandlers) handleDumbInfoRefs(c *gin.Context, repoPath string) {
 	c.File(refsPath)
 }
 
-func (h *GitHandlers) handleGitCommand(c *gin.Context, repoPath, command string, args ...string) {
-	// Set appropriate content type
-	var contentType string
-	switch command {
-	case "git-upload-pack":
-		contentType = "application/x-git-upload-pack-result"
-	case "git-receive-pack":
-		contentType = "application/x-git-receive-pack-result"
-	default:
-		contentType = "application/octet-stream"
+// serviceRPC runs service with the request body, which may be gzip
+// compressed, as input and streams its pkt-line output. It reports whether
+// the command succeeded.
+func (h *GitHandlers) serviceRPC(c *gin.Context, repoPath, service string) bool {
+	if c.GetHeader("Content-Type") != "" && c.GetHeader("Content-Type") != "application/x-"+service+"-request" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unexpected content type"})
+		return false
 	}
 
-	c.Header("Content-Type", contentType)
-	c.Header("Cache-Control", "no-cache")
-
-	// Create command - use "git" as the base command and add proper args
-	var cmdArgs []string
-	switch command {
-	case "git-upload-pack":
-		cmdArgs = []string{"upload-pack", "--stateless-rpc", "."}
-	case "git-receive-pack":
-		cmdArgs = []string{"receive-pack", "--stateless-rpc", "."}
+	var body io.Reader = c.Request.Body
+	switch c.GetHeader("Content-Encoding") {
+	case "", "identity":
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid gzip request body"})
+			return false
+		}
+		defer gz.Close()
+		body = gz
 	default:
-		cmdArgs = append([]string{command}, args...)
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported content encoding"})
+		return false
 	}
 
-	cmd := exec.Command("git", cmdArgs...)
-	cmd.Dir = repoPath
-
-	h.logger.WithFields(logrus.Fields{
-		"command": cmd.String(),
-		"args":    cmd.Args,
-		"dir":     repoPath,
-	}).Info("Executing git command")
-
-	// Set up pipes
-	stdin, err := cmd.StdinPipe()
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to create stdin pipe")
-		c.Status(http.StatusInternalServerError)
-		return
-	}
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to create stdout pipe")
-		c.Status(http.StatusInternalServerError)
-		return
-	}
+	c.Header("Content-Type", "application/x-"+service+"-result")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	return h.runGit(c, repoPath, body, strings.TrimPrefix(service, "git-"), "--stateless-rpc", ".")
+}
 
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		h.logger.WithError(err).Error("Failed to create stderr pipe")
-		c.Status(http.StatusInternalServerError)
-		return
+// runGit runs git in repoPath and streams its output to the response as it
+// is produced, so clients see progress and large packs are not buffered.
+// Output already written cannot be retracted, so failures are only logged.
+func (h *GitHandlers) runGit(c *gin.Context, repoPath string, stdin io.Reader, args ...string) bool {
+	cmd := exec.CommandContext(c.Request.Context(), "git", args...)
+	cmd.Dir = repoPath
+	if protocol := c.GetHeader("Git-Protocol"); protocol != "" && gitProtocolPattern.MatchString(protocol) {
+		cmd.Env = append(os.Environ(), "GIT_PROTOCOL="+protocol)
 	}
+	var stderr bytes.Buffer
+	cmd.Stdin = stdin
+	cmd.Stdout = flushWriter{c.Writer}
+	cmd.Stderr = &stderr
 
-	// Start the command
-	if err := cmd.Start(); err != nil {
-		h.logger.WithError(err).Error("Failed to start git command")
-		c.Status(http.StatusInternalServerError)
-		return
+	if err := cmd.Run(); err != nil {
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"args":   cmd.Args,
+			"dir":    repoPath,
+			"stderr": stderr.String(),
+		}).Error("Git command failed")
+		return false
 	}
+	return true
+}
 
-	// Handle request body (input to git command)
-	go func() {
-		defer stdin.Close()
-
-		var reader io.Reader = c.Request.Body
-
-		// Handle gzip compression
-		if c.GetHeader("Content-Encoding") == "gzip" {
-			if gzipReader, err := gzip.NewReader(c.Request.Body); err == nil {
-				defer gzipReader.Close()
-				reader = gzipReader
-			}
-		}
-
-		io.Copy(stdin, reader)
-	}()
-
-	// Stream stdout to response
-	go func() {
-		io.Copy(c.Writer, stdout)
-	}()
-
-	// Log stderr
-	go func() {
-		stderrBytes, err := io.ReadAll(stderr)
-		if err == nil && len(stderrBytes) > 0 {
-			h.logger.WithField("stderr", string(stderrBytes)).Info("Git command stderr")
-		}
-	}()
-
-	// Wait for command to complete
-	if err := cmd.Wait(); err != nil {
-		h.logger.WithError(err).Error("Git command failed")
-		return
-	}
+// flushWriter flushes every write so pkt-lines reach the client immediately
+type flushWriter struct {
+	w gin.ResponseWriter
+}
 
-	h.logger.Info("Git command completed successfully")
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.w.Flush()
+	return n, err
 }
 
 // packetWrite formats data according to Git packet-line format
@@ -439,3 +382,9 @@ func (h *GitHandlers) GitMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// gitRepoName returns the repository name of a git or LFS request without
+// the optional .git suffix
+func gitRepoName(c *gin.Context) string {
+	return strings.TrimSuffix(c.Param("repo"), ".git")
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -13,6 +14,7 @@ import (
 	"github.com/a5c-ai/hub/internal/config"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -52,7 +54,7 @@ func setupHandler(t *testing.T, repo *models.Repository, makePath bool) (*GitHan
 	}
 	fakeSvc := &fakeRepoService{repo: repo, path: tmpDir}
 	logger := logrus.New()
-	handler := NewGitHandlers(fakeSvc, logger, jwtMgr)
+	handler := NewGitHandlers(fakeSvc, nil, nil, logger, jwtMgr)
 	return handler, tmpDir
 }
 
@@ -140,3 +142,123 @@ func TestReceivePack_ValidToken(t *testing.T) {
 		t.Errorf("expected auth success, got unauthorized")
 	}
 }
+
+// gitServer serves handler's smart HTTP routes as user, who has permission
+// on the repository
+func gitServer(t *testing.T, handler *GitHandlers, user *models.User, permission models.Permission) *httptest.Server {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		tc := &tenant.Context{Permission: permission}
+		if _, password, ok := c.Request.BasicAuth(); ok && password == "secret" {
+			tc.UserID = &user.ID
+			tc.Username = user.Username
+		}
+		c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), tc))
+		c.Next()
+	})
+	router.GET("/:owner/:repo/info/refs", handler.InfoRefs)
+	router.POST("/:owner/:repo/git-upload-pack", handler.UploadPack)
+	router.POST("/:owner/:repo/git-receive-pack", handler.ReceivePack)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func runGitCmd(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_AUTHOR_NAME=u", "GIT_AUTHOR_EMAIL=u@example.com",
+		"GIT_COMMITTER_NAME=u", "GIT_COMMITTER_EMAIL=u@example.com")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+func TestSmartHTTP_CloneAndPush(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	user := &models.User{ID: uuid.New(), Username: "u"}
+	repo := &models.Repository{ID: uuid.New(), Visibility: models.VisibilityPrivate}
+	handler, repoPath := setupHandler(t, repo, true)
+	runGitCmd(t, repoPath, "init", "--bare", "-q", "-b", "main")
+	server := gitServer(t, handler, user, models.PermissionWrite)
+
+	work := t.TempDir()
+	runGitCmd(t, work, "init", "-q", "-b", "main")
+	if err := os.WriteFile(filepath.Join(work, "README.md"), []byte("hello\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGitCmd(t, work, "add", ".")
+	runGitCmd(t, work, "commit", "-q", "-m", "initial")
+	head := runGitCmd(t, work, "rev-parse", "HEAD")
+
+	authURL := strings.Replace(server.URL, "http://", "http://u:secret@", 1) + "/owner/repo.git"
+	runGitCmd(t, work, "push", "-q", authURL, "main")
+	if got := runGitCmd(t, repoPath, "rev-parse", "refs/heads/main"); got != head {
+		t.Fatalf("pushed ref = %s, want %s", got, head)
+	}
+
+	clone := filepath.Join(t.TempDir(), "clone")
+	runGitCmd(t, work, "clone", "-q", authURL, clone)
+	if got := runGitCmd(t, clone, "rev-parse", "HEAD"); got != head {
+		t.Errorf("cloned HEAD = %s, want %s", got, head)
+	}
+
+	// Private refs are not advertised without credentials
+	resp, err := http.Get(server.URL + "/owner/repo.git/info/refs?service=git-upload-pack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Errorf("anonymous info/refs = %d, want 401 with a challenge", resp.StatusCode)
+	}
+}
+
+func TestSmartHTTP_Permissions(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	user := &models.User{ID: uuid.New(), Username: "u"}
+	repo := &models.Repository{ID: uuid.New(), Visibility: models.VisibilityPrivate}
+	handler, repoPath := setupHandler(t, repo, true)
+	runGitCmd(t, repoPath, "init", "--bare", "-q")
+	reader := gitServer(t, handler, user, models.PermissionRead)
+
+	get := func(server *httptest.Server, service string) int {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/owner/repo.git/info/refs?service="+service, nil)
+		req.SetBasicAuth("u", "secret")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := get(reader, "git-upload-pack"); code != http.StatusOK {
+		t.Errorf("read access to upload-pack = %d, want 200", code)
+	}
+	if code := get(reader, "git-receive-pack"); code != http.StatusForbidden {
+		t.Errorf("read access to receive-pack = %d, want 403", code)
+	}
+	if code := get(gitServer(t, handler, user, ""), "git-upload-pack"); code != http.StatusNotFound {
+		t.Errorf("no access to upload-pack = %d, want 404", code)
+	}
+
+	// Bodies declared as gzip must decompress
+	req, _ := http.NewRequest(http.MethodPost, reader.URL+"/owner/repo.git/git-upload-pack", strings.NewReader("not gzip"))
+	req.SetBasicAuth("u", "secret")
+	req.Header.Set("Content-Encoding", "gzip")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid gzip body = %d, want 400", resp.StatusCode)
+	}
+}
@@ -1,122 +1,271 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"path/filepath"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
 
 	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
 	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/a5c-ai/hub/internal/tenant"
 )
 
-// LFSHandlers provides Git LFS endpoint handlers.
-// Implements basic Git LFS Batch, Upload, Download, and Verify using storage backends.
+// lfsMediaType is the content type of Git LFS batch API requests and responses
+const lfsMediaType = "application/vnd.git-lfs+json"
+
+// LFSHandlers serves the Git LFS batch API and basic transfer endpoints
+// under /{owner}/{repo}.git/info/lfs, plus LFS usage and quota management
 type LFSHandlers struct {
-	backend storage.Backend
+	lfsService        services.LFSService
+	repositoryService services.RepositoryService
+	logger            *logrus.Logger
 }
 
-// NewLFSHandlers creates a new LFSHandlers with the given LFS config and repository base path.
-// repoBasePath is used as the root for filesystem-based LFS storage.
-func NewLFSHandlers(cfg config.LFS, repoBasePath string) (*LFSHandlers, error) {
-	// prepare storage configuration for LFS
+// NewLFSHandlers creates a new LFSHandlers
+func NewLFSHandlers(lfsService services.LFSService, repositoryService services.RepositoryService, logger *logrus.Logger) *LFSHandlers {
+	return &LFSHandlers{
+		lfsService:        lfsService,
+		repositoryService: repositoryService,
+		logger:            logger,
+	}
+}
+
+// newLFSBackend creates the storage backend for LFS objects from cfg.
+// The filesystem backend stores objects in an lfs directory under repoBasePath.
+func newLFSBackend(cfg config.LFS, repoBasePath string) (storage.Backend, error) {
 	var stCfg storage.Config
 	stCfg.Backend = cfg.Backend
-	// Azure settings
 	stCfg.Azure.AccountName = cfg.Azure.AccountName
 	stCfg.Azure.AccountKey = cfg.Azure.AccountKey
 	stCfg.Azure.ContainerName = cfg.Azure.ContainerName
-	// filesystem backend uses an lfs subdirectory under repoBasePath
 	stCfg.Filesystem.BasePath = filepath.Join(repoBasePath, "lfs")
-	backend, err := storage.NewBackend(stCfg)
+	return storage.NewBackend(stCfg)
+}
+
+// lfsError writes an error in the Git LFS error format
+func lfsError(c *gin.Context, status int, message string) {
+	c.Header("Content-Type", lfsMediaType)
+	c.AbortWithStatusJSON(status, gin.H{"message": message})
+}
+
+// lfsRepository resolves the repository of an LFS request and checks the
+// tenant has required on it. Public repositories can be read anonymously.
+func (h *LFSHandlers) lfsRepository(c *gin.Context, required models.Permission) (*models.Repository, bool) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), gitRepoName(c))
 	if err != nil {
-		return nil, err
+		if err.Error() == "repository not found" {
+			lfsError(c, http.StatusNotFound, "Repository not found")
+		} else {
+			h.logger.WithError(err).Error("Failed to get repository")
+			lfsError(c, http.StatusInternalServerError, "Failed to get repository")
+		}
+		return nil, false
+	}
+
+	t, _ := tenant.FromContext(c.Request.Context())
+	switch gitAccessStatus(c, t, repo, required == models.PermissionWrite) {
+	case 0:
+		return repo, true
+	case http.StatusUnauthorized:
+		c.Header("LFS-Authenticate", `Basic realm="Git LFS"`)
+		lfsError(c, http.StatusUnauthorized, "Authentication required")
+	case http.StatusForbidden:
+		lfsError(c, http.StatusForbidden, "Permission denied")
+	default:
+		lfsError(c, http.StatusNotFound, "Repository not found")
+	}
+	return nil, false
+}
+
+// lfsObjectsURL returns the URL of the objects endpoint for the request's
+// repository, as reached by the client
+func lfsObjectsURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
 	}
-	return &LFSHandlers{backend: backend}, nil
+	return fmt.Sprintf("%s://%s/%s/%s.git/info/lfs/objects", scheme, c.Request.Host, c.Param("owner"), gitRepoName(c))
 }
 
-// Batch handles Git LFS batch API requests (upload/download actions).
+// lfsStatus maps LFSService errors to HTTP status codes
+func (h *LFSHandlers) lfsStatus(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrLFSObjectNotFound):
+		lfsError(c, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrInvalidLFSObject):
+		lfsError(c, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, services.ErrLFSQuotaExceeded):
+		lfsError(c, http.StatusInsufficientStorage, err.Error())
+	case errors.Is(err, services.ErrMalwareDetected):
+		lfsError(c, http.StatusUnprocessableEntity, err.Error())
+	case errors.Is(err, services.ErrMalwareQuarantined):
+		lfsError(c, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrMalwareScanFailed):
+		h.logger.WithError(err).Errorf("Failed to %s", action)
+		lfsError(c, http.StatusServiceUnavailable, "Malware scanning is unavailable")
+	default:
+		h.logger.WithError(err).Errorf("Failed to %s", action)
+		lfsError(c, http.StatusInternalServerError, "Failed to "+action)
+	}
+}
+
+// Batch handles POST /{owner}/{repo}.git/info/lfs/objects/batch
 func (h *LFSHandlers) Batch(c *gin.Context) {
-	var req struct {
-		Operation string `json:"operation"`
-		Objects   []struct {
-			Oid  string `json:"oid"`
-			Size int64  `json:"size"`
-		} `json:"objects"`
+	var req services.LFSBatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		lfsError(c, http.StatusBadRequest, "Invalid batch request")
+		return
+	}
+
+	required := models.PermissionRead
+	if req.Operation == services.LFSOperationUpload {
+		required = models.PermissionWrite
 	}
-	if err := c.BindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid batch request"})
+	repo, ok := h.lfsRepository(c, required)
+	if !ok {
 		return
 	}
-	scheme := "http"
-	if c.Request.TLS != nil {
-		scheme = "https"
+
+	// Transfers authenticate with the credentials of the batch request
+	var header map[string]string
+	if auth := c.GetHeader("Authorization"); auth != "" {
+		header = map[string]string{"Authorization": auth}
 	}
-	base := fmt.Sprintf("%s://%s/api/v1/git-lfs/objects", scheme, c.Request.Host)
-	// build response objects
-	respObjs := make([]gin.H, 0, len(req.Objects))
-	for _, obj := range req.Objects {
-		entry := gin.H{"oid": obj.Oid, "size": obj.Size, "authenticated": false}
-		actions := gin.H{}
-		switch req.Operation {
-		case "upload":
-			actions["upload"] = gin.H{"href": fmt.Sprintf("%s/%s", base, obj.Oid)}
-		case "download":
-			exists, err := h.backend.Exists(c, obj.Oid)
-			if err != nil || !exists {
-				entry["error"] = gin.H{"code": http.StatusNotFound, "message": "object not found"}
-			} else {
-				actions["download"] = gin.H{"href": fmt.Sprintf("%s/%s", base, obj.Oid)}
-			}
-		default:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported operation"})
-			return
-		}
-		entry["actions"] = actions
-		respObjs = append(respObjs, entry)
+
+	resp, err := h.lfsService.Batch(c.Request.Context(), repo, &req, lfsObjectsURL(c), header)
+	if err != nil {
+		h.lfsStatus(c, err, "process batch request")
+		return
 	}
-	// return LFS batch response
-	c.Header("Content-Type", "application/vnd.git-lfs+json")
-	c.JSON(http.StatusOK, gin.H{"objects": respObjs})
+	c.Header("Content-Type", lfsMediaType)
+	c.JSON(http.StatusOK, resp)
 }
 
-// Upload handles Git LFS object upload requests (POST with raw content).
+// Upload handles PUT /{owner}/{repo}.git/info/lfs/objects/{oid}
 func (h *LFSHandlers) Upload(c *gin.Context) {
-	oid := c.Param("oid")
-	// determine size if provided
-	size := c.Request.ContentLength
-	if err := h.backend.Upload(c, oid, c.Request.Body, size); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "upload failed"})
+	repo, ok := h.lfsRepository(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+
+	// A missing Content-Length (-1) is checked against the hash only
+	err := h.lfsService.Upload(c.Request.Context(), repo, c.Param("oid"), c.Request.ContentLength, c.Request.Body)
+	if err != nil {
+		h.lfsStatus(c, err, "upload LFS object")
 		return
 	}
 	c.Status(http.StatusOK)
 }
 
-// Download handles Git LFS object download requests (streams raw content).
+// Download handles GET /{owner}/{repo}.git/info/lfs/objects/{oid}
 func (h *LFSHandlers) Download(c *gin.Context) {
-	oid := c.Param("oid")
-	reader, err := h.backend.Download(c, oid)
+	repo, ok := h.lfsRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	reader, size, err := h.lfsService.Download(c.Request.Context(), repo, c.Param("oid"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "object not found"})
+		h.lfsStatus(c, err, "download LFS object")
 		return
 	}
 	defer reader.Close()
-	size, _ := h.backend.GetSize(c, oid)
 	c.DataFromReader(http.StatusOK, size, "application/octet-stream", reader, nil)
 }
 
-// Verify handles Git LFS object existence verification (HEAD request).
+// Verify handles POST /{owner}/{repo}.git/info/lfs/objects/verify
 func (h *LFSHandlers) Verify(c *gin.Context) {
-	oid := c.Param("oid")
-	exists, err := h.backend.Exists(c, oid)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "verification failed"})
+	repo, ok := h.lfsRepository(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+
+	var obj services.LFSObjectSpec
+	if err := c.ShouldBindJSON(&obj); err != nil {
+		lfsError(c, http.StatusBadRequest, "Invalid verify request")
 		return
 	}
-	if !exists {
-		c.Status(http.StatusNotFound)
+	if err := h.lfsService.Verify(c.Request.Context(), repo, obj.OID, obj.Size); err != nil {
+		h.lfsStatus(c, err, "verify LFS object")
 		return
 	}
 	c.Status(http.StatusOK)
 }
+
+// GetUsage handles GET /api/v1/repositories/{owner}/{repo}/lfs
+func (h *LFSHandlers) GetUsage(c *gin.Context) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return
+	}
+	if repo.Visibility != models.VisibilityPublic {
+		if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionRead) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+			return
+		}
+	}
+
+	usage, err := h.lfsService.Usage(c.Request.Context(), repo)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get LFS usage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get LFS usage"})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+// SetQuota handles PUT /api/v1/admin/repositories/{owner}/{repo}/lfs/quota
+//
+// The body is {"quota_mb": n}; 0 removes the limit and null restores the
+// instance default.
+func (h *LFSHandlers) SetQuota(c *gin.Context) {
+	var req struct {
+		QuotaMB *int64 `json:"quota_mb"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return
+	}
+
+	if err := h.lfsService.SetQuota(c.Request.Context(), repo, req.QuotaMB); err != nil {
+		if errors.Is(err, services.ErrInvalidLFSQuota) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to set LFS quota")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set LFS quota"})
+		return
+	}
+
+	usage, err := h.lfsService.Usage(c.Request.Context(), repo)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get LFS usage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get LFS usage"})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
@@ -0,0 +1,635 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/audit"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// githubCompatRoute binds a GitHub v3 route to its handler and the
+// repository permission it needs; org routes check membership themselves
+type githubCompatRoute struct {
+	services.GitHubCompatRoute
+	permission models.Permission
+	handler    gin.HandlerFunc
+}
+
+// GitHubCompatHandlers serves a subset of the GitHub REST v3 API under
+// /api/github/v3 so CI scripts and bots written against GitHub keep working.
+// Responses use GitHub's shapes and its {"message": ...} error body.
+type GitHubCompatHandlers struct {
+	repositoryService   services.RepositoryService
+	organizationService services.OrganizationService
+	issueService        services.IssueService
+	pullRequestService  services.PullRequestService
+	commitStatusService services.CommitStatusService
+	webhookService      *services.WebhookDeliveryService
+	gitService          git.GitService
+	permissionService   services.PermissionService
+	compat              *services.GitHubCompat
+	db                  *gorm.DB
+	logger              *logrus.Logger
+}
+
+func NewGitHubCompatHandlers(repositoryService services.RepositoryService, organizationService services.OrganizationService, issueService services.IssueService, pullRequestService services.PullRequestService, commitStatusService services.CommitStatusService, webhookService *services.WebhookDeliveryService, gitService git.GitService, baseURL string, db *gorm.DB, logger *logrus.Logger) *GitHubCompatHandlers {
+	return &GitHubCompatHandlers{
+		repositoryService:   repositoryService,
+		organizationService: organizationService,
+		issueService:        issueService,
+		pullRequestService:  pullRequestService,
+		commitStatusService: commitStatusService,
+		webhookService:      webhookService,
+		gitService:          gitService,
+		permissionService:   services.NewPermissionService(db, nil),
+		compat:              services.NewGitHubCompat(baseURL),
+		db:                  db,
+		logger:              logger,
+	}
+}
+
+func (h *GitHubCompatHandlers) routes() []githubCompatRoute {
+	route := func(method, path, mapsTo string, permission models.Permission, handler gin.HandlerFunc) githubCompatRoute {
+		return githubCompatRoute{services.GitHubCompatRoute{Method: method, Path: path, MapsTo: mapsTo}, permission, handler}
+	}
+	return []githubCompatRoute{
+		route(http.MethodGet, "/orgs/:org/repos", "RepositoryService.List", "", h.ListOrgRepos),
+		route(http.MethodGet, "/repos/:owner/:repo", "RepositoryService.Get", models.PermissionRead, h.GetRepo),
+		route(http.MethodGet, "/repos/:owner/:repo/issues", "IssueService.List", models.PermissionRead, h.ListIssues),
+		route(http.MethodPost, "/repos/:owner/:repo/issues", "IssueService.Create", models.PermissionTriage, h.CreateIssue),
+		route(http.MethodGet, "/repos/:owner/:repo/issues/:number", "IssueService.Get", models.PermissionRead, h.GetIssue),
+		route(http.MethodPatch, "/repos/:owner/:repo/issues/:number", "IssueService.Update", models.PermissionTriage, h.UpdateIssue),
+		route(http.MethodGet, "/repos/:owner/:repo/pulls", "PullRequestService.List", models.PermissionRead, h.ListPulls),
+		route(http.MethodPost, "/repos/:owner/:repo/pulls", "PullRequestService.Create", models.PermissionWrite, h.CreatePull),
+		route(http.MethodGet, "/repos/:owner/:repo/pulls/:number", "PullRequestService.GetByNumber", models.PermissionRead, h.GetPull),
+		route(http.MethodPut, "/repos/:owner/:repo/pulls/:number/merge", "PullRequestService.Merge", models.PermissionWrite, h.MergePull),
+		route(http.MethodPost, "/repos/:owner/:repo/statuses/:sha", "CommitStatusService.Create", models.PermissionWrite, h.CreateStatus),
+		route(http.MethodGet, "/repos/:owner/:repo/commits/:ref/statuses", "CommitStatusService.List", models.PermissionRead, h.ListStatuses),
+		route(http.MethodGet, "/repos/:owner/:repo/commits/:ref/status", "CommitStatusService.Combined", models.PermissionRead, h.GetCombinedStatus),
+		route(http.MethodGet, "/repos/:owner/:repo/hooks", "WebhookDeliveryService.ListWebhooks", models.PermissionAdmin, h.ListHooks),
+		route(http.MethodPost, "/repos/:owner/:repo/hooks", "WebhookDeliveryService.CreateWebhook", models.PermissionAdmin, h.CreateHook),
+		route(http.MethodDelete, "/repos/:owner/:repo/hooks/:hook_id", "WebhookDeliveryService.DeleteWebhook", models.PermissionAdmin, h.DeleteHook),
+	}
+}
+
+// Register mounts every supported route on group
+func (h *GitHubCompatHandlers) Register(group *gin.RouterGroup) {
+	for _, r := range h.routes() {
+		handler := r.handler
+		if r.permission != "" {
+			handler = h.requireRepository(r.permission, r.handler)
+		}
+		group.Handle(r.Method, r.Path, handler)
+	}
+}
+
+// Capabilities handles GET /api/github/v3/capabilities and lists the
+// supported GitHub routes with the hub services they map onto
+func (h *GitHubCompatHandlers) Capabilities(c *gin.Context) {
+	routes := h.routes()
+	supported := make([]services.GitHubCompatRoute, 0, len(routes))
+	for _, r := range routes {
+		supported = append(supported, r.GitHubCompatRoute)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"api_version":    "v3",
+		"authentication": []string{"Authorization: token <token>", "Authorization: Bearer <token>"},
+		"id_format":      "uuid",
+		"routes":         supported,
+	})
+}
+
+// GitHubTokenAuth accepts GitHub's "token <token>" authorization scheme by
+// rewriting it to the Bearer scheme the rest of the API expects
+func GitHubTokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2); len(parts) == 2 && strings.EqualFold(parts[0], "token") {
+			c.Request.Header.Set("Authorization", "Bearer "+parts[1])
+		}
+		c.Next()
+	}
+}
+
+func githubError(c *gin.Context, status int, message string) {
+	c.AbortWithStatusJSON(status, gin.H{"message": message})
+}
+
+func (h *GitHubCompatHandlers) requireRepository(permission models.Permission, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+		if err != nil {
+			githubError(c, http.StatusNotFound, "Not Found")
+			return
+		}
+		t, ok := tenant.FromContext(c.Request.Context())
+		if !ok || !t.HasPermission(models.PermissionRead) {
+			// Hide repositories the caller cannot see, as GitHub does
+			githubError(c, http.StatusNotFound, "Not Found")
+			return
+		}
+		if !t.HasPermission(permission) {
+			githubError(c, http.StatusForbidden, "Resource not accessible by integration")
+			return
+		}
+		c.Set("github_compat_repository", repo)
+		next(c)
+	}
+}
+
+func (h *GitHubCompatHandlers) repository(c *gin.Context) (*models.Repository, string) {
+	repo := c.MustGet("github_compat_repository").(*models.Repository)
+	return repo, c.Param("owner") + "/" + repo.Name
+}
+
+func (h *GitHubCompatHandlers) userID(c *gin.Context) (uuid.UUID, bool) {
+	userID, ok := authenticatedUser(c)
+	if !ok {
+		githubError(c, http.StatusUnauthorized, "Requires authentication")
+	}
+	return userID, ok
+}
+
+func (h *GitHubCompatHandlers) number(c *gin.Context) (int, bool) {
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		githubError(c, http.StatusNotFound, "Not Found")
+		return 0, false
+	}
+	return number, true
+}
+
+func githubPagination(c *gin.Context) (int, int) {
+	page, perPage := 1, 30
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		page = p
+	}
+	if pp, err := strconv.Atoi(c.Query("per_page")); err == nil && pp > 0 && pp <= 100 {
+		perPage = pp
+	}
+	return page, perPage
+}
+
+func (h *GitHubCompatHandlers) internalError(c *gin.Context, err error, message string) {
+	h.logger.WithError(err).Error(message)
+	githubError(c, http.StatusInternalServerError, message)
+}
+
+// ListOrgRepos handles GET /orgs/{org}/repos. Members see every repository
+// of the organization; everyone else only sees public ones.
+func (h *GitHubCompatHandlers) ListOrgRepos(c *gin.Context) {
+	org, err := h.organizationService.Get(c.Request.Context(), c.Param("org"))
+	if err != nil {
+		githubError(c, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	ownerType := models.OwnerTypeOrganization
+	page, perPage := githubPagination(c)
+	filters := services.RepositoryFilters{OwnerID: &org.ID, OwnerType: &ownerType, Page: page, PerPage: perPage}
+
+	member := false
+	if userID, ok := authenticatedUser(c); ok {
+		var count int64
+		h.db.WithContext(c.Request.Context()).Model(&models.OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ?", org.ID, userID).Count(&count)
+		member = count > 0
+	}
+	if isAdmin, _ := c.Get("is_admin"); !member && isAdmin != true {
+		public := models.VisibilityPublic
+		filters.Visibility = &public
+	}
+
+	repos, _, err := h.repositoryService.List(c.Request.Context(), filters)
+	if err != nil {
+		h.internalError(c, err, "Failed to list repositories")
+		return
+	}
+	result := make([]services.GitHubRepository, 0, len(repos))
+	for _, repo := range repos {
+		result = append(result, h.compat.Repository(repo, org.Name))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetRepo handles GET /repos/{owner}/{repo}
+func (h *GitHubCompatHandlers) GetRepo(c *gin.Context) {
+	repo, _ := h.repository(c)
+	c.JSON(http.StatusOK, h.compat.Repository(repo, c.Param("owner")))
+}
+
+// ListIssues handles GET /repos/{owner}/{repo}/issues
+func (h *GitHubCompatHandlers) ListIssues(c *gin.Context) {
+	repo, fullName := h.repository(c)
+	page, perPage := githubPagination(c)
+	filter := services.IssueFilter{Assignee: c.Query("assignee"), Milestone: c.Query("milestone"), Page: page, PageSize: perPage}
+	switch state := c.DefaultQuery("state", "open"); state {
+	case "open", "closed":
+		issueState := models.IssueState(state)
+		filter.State = &issueState
+	case "all":
+	default:
+		githubError(c, http.StatusUnprocessableEntity, "Validation Failed: state must be open, closed or all")
+		return
+	}
+
+	for _, name := range strings.Split(c.Query("labels"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			filter.Labels = append(filter.Labels, name)
+		}
+	}
+
+	issues, err := h.issueService.List(c.Request.Context(), repo.ID, filter)
+	if err != nil {
+		h.issueError(c, err, "Failed to list issues")
+		return
+	}
+	result := make([]services.GitHubIssue, 0, len(issues))
+	for _, issue := range issues {
+		result = append(result, h.compat.Issue(issue, fullName))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetIssue handles GET /repos/{owner}/{repo}/issues/{number}
+func (h *GitHubCompatHandlers) GetIssue(c *gin.Context) {
+	repo, fullName := h.repository(c)
+	number, ok := h.number(c)
+	if !ok {
+		return
+	}
+	issue, err := h.issueService.Resolve(c.Request.Context(), repo.ID, number)
+	if err != nil {
+		h.issueError(c, err, "Failed to get issue")
+		return
+	}
+	if issue.RepositoryID != repo.ID {
+		h.issueMoved(c, issue)
+		return
+	}
+	c.JSON(http.StatusOK, h.compat.Issue(issue, fullName))
+}
+
+// issueMoved answers 301 for an issue transferred to another repository, or
+// 404 when the caller cannot read that repository
+func (h *GitHubCompatHandlers) issueMoved(c *gin.Context, issue *models.Issue) {
+	t, ok := tenant.FromContext(c.Request.Context())
+	readable := ok && t.IsAdmin
+	if !readable && ok && t.UserID != nil {
+		var err error
+		if readable, err = h.permissionService.CheckRepositoryPermission(c.Request.Context(), *t.UserID, issue.RepositoryID, models.PermissionRead); err != nil {
+			h.internalError(c, err, "Failed to check repository permissions")
+			return
+		}
+	}
+	target, err := h.repositoryService.GetByID(c.Request.Context(), issue.RepositoryID)
+	if err == nil && !readable {
+		readable = target.Visibility == models.VisibilityPublic
+	}
+	if err != nil || !readable {
+		githubError(c, http.StatusNotFound, "Not Found")
+		return
+	}
+	fullName, err := repositoryFullName(c.Request.Context(), h.db, target)
+	if err != nil {
+		h.internalError(c, err, "Failed to get issue")
+		return
+	}
+	moved := h.compat.Issue(issue, fullName)
+	c.Header("Location", moved.URL)
+	c.JSON(http.StatusMovedPermanently, gin.H{"message": "Moved Permanently", "url": moved.URL})
+}
+
+// CreateIssue handles POST /repos/{owner}/{repo}/issues
+func (h *GitHubCompatHandlers) CreateIssue(c *gin.Context) {
+	repo, fullName := h.repository(c)
+	userID, ok := h.userID(c)
+	if !ok {
+		return
+	}
+	var req services.CreateIssueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		githubError(c, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	issue, err := h.issueService.Create(c.Request.Context(), repo.ID, userID, req)
+	if err != nil {
+		h.issueError(c, err, "Failed to create issue")
+		return
+	}
+	c.JSON(http.StatusCreated, h.compat.Issue(issue, fullName))
+}
+
+// UpdateIssue handles PATCH /repos/{owner}/{repo}/issues/{number}
+func (h *GitHubCompatHandlers) UpdateIssue(c *gin.Context) {
+	repo, fullName := h.repository(c)
+	userID, ok := h.userID(c)
+	if !ok {
+		return
+	}
+	number, ok := h.number(c)
+	if !ok {
+		return
+	}
+	var req services.UpdateIssueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		githubError(c, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+
+	issue, err := h.issueService.Get(c.Request.Context(), repo.ID, number)
+	if err == nil {
+		issue, err = h.issueService.Update(c.Request.Context(), issue, userID, req)
+	}
+	if err != nil {
+		h.issueError(c, err, "Failed to update issue")
+		return
+	}
+	c.JSON(http.StatusOK, h.compat.Issue(issue, fullName))
+}
+
+func (h *GitHubCompatHandlers) issueError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrIssueNotFound):
+		githubError(c, http.StatusNotFound, "Not Found")
+	case errors.Is(err, services.ErrInvalidIssue):
+		githubError(c, http.StatusUnprocessableEntity, "Validation Failed: "+err.Error())
+	default:
+		h.internalError(c, err, message)
+	}
+}
+
+// ListPulls handles GET /repos/{owner}/{repo}/pulls
+func (h *GitHubCompatHandlers) ListPulls(c *gin.Context) {
+	repo, fullName := h.repository(c)
+	page, perPage := githubPagination(c)
+	filter := services.PullRequestFilter{Page: page, PageSize: perPage}
+	switch state := c.DefaultQuery("state", "open"); state {
+	case "open":
+		filter.State = &state
+	case "closed", "all":
+	default:
+		githubError(c, http.StatusUnprocessableEntity, "Validation Failed: state must be open, closed or all")
+		return
+	}
+
+	prs, err := h.pullRequestService.List(c.Request.Context(), repo.ID, filter)
+	if err != nil {
+		h.internalError(c, err, "Failed to list pull requests")
+		return
+	}
+	result := make([]services.GitHubPullRequest, 0, len(prs))
+	for _, pr := range prs {
+		// GitHub's closed filter includes merged pull requests
+		if c.Query("state") == "closed" && pr.State == models.PullRequestStateOpen {
+			continue
+		}
+		result = append(result, h.compat.PullRequest(pr, fullName))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetPull handles GET /repos/{owner}/{repo}/pulls/{number}
+func (h *GitHubCompatHandlers) GetPull(c *gin.Context) {
+	repo, fullName := h.repository(c)
+	number, ok := h.number(c)
+	if !ok {
+		return
+	}
+	pr, err := h.pullRequestService.GetByNumber(c.Request.Context(), repo.ID, number)
+	if err != nil {
+		githubError(c, http.StatusNotFound, "Not Found")
+		return
+	}
+	c.JSON(http.StatusOK, h.compat.PullRequest(pr, fullName))
+}
+
+// CreatePull handles POST /repos/{owner}/{repo}/pulls. A head given as
+// "owner:branch" is reduced to the branch name.
+func (h *GitHubCompatHandlers) CreatePull(c *gin.Context) {
+	repo, fullName := h.repository(c)
+	userID, ok := h.userID(c)
+	if !ok {
+		return
+	}
+	var req services.CreatePullRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		githubError(c, http.StatusUnprocessableEntity, "Validation Failed: title, head and base are required")
+		return
+	}
+	if i := strings.Index(req.Head, ":"); i >= 0 {
+		req.Head = req.Head[i+1:]
+	}
+
+	pr, err := h.pullRequestService.Create(c.Request.Context(), repo.ID, userID, req)
+	if errors.Is(err, services.ErrPullRequestTitleInvalid) {
+		githubError(c, http.StatusUnprocessableEntity, "Validation Failed: "+err.Error())
+		return
+	}
+	if err != nil {
+		h.internalError(c, err, "Failed to create pull request")
+		return
+	}
+	c.JSON(http.StatusCreated, h.compat.PullRequest(pr, fullName))
+}
+
+// MergePull handles PUT /repos/{owner}/{repo}/pulls/{number}/merge
+func (h *GitHubCompatHandlers) MergePull(c *gin.Context) {
+	repo, _ := h.repository(c)
+	number, ok := h.number(c)
+	if !ok {
+		return
+	}
+	var req services.MergePullRequestRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			githubError(c, http.StatusBadRequest, "Problems parsing JSON")
+			return
+		}
+	}
+
+	pr, err := h.pullRequestService.GetByNumber(c.Request.Context(), repo.ID, number)
+	if err != nil {
+		githubError(c, http.StatusNotFound, "Not Found")
+		return
+	}
+	if pr.State != models.PullRequestStateOpen {
+		githubError(c, http.StatusMethodNotAllowed, "Pull Request is not mergeable")
+		return
+	}
+
+	err = h.pullRequestService.Merge(c.Request.Context(), pr.ID, req)
+	var blocked *services.MergeBlockedError
+	switch {
+	case errors.As(err, &blocked):
+		githubError(c, http.StatusMethodNotAllowed, blocked.Error())
+	case errors.Is(err, services.ErrInvalidMergeMethod):
+		githubError(c, http.StatusUnprocessableEntity, "Validation Failed: "+err.Error())
+	case err != nil:
+		h.internalError(c, err, "Failed to merge pull request")
+	default:
+		c.JSON(http.StatusOK, gin.H{"merged": true, "message": "Pull Request successfully merged"})
+	}
+}
+
+// CreateStatus handles POST /repos/{owner}/{repo}/statuses/{sha}
+func (h *GitHubCompatHandlers) CreateStatus(c *gin.Context) {
+	repo, _ := h.repository(c)
+	userID, ok := h.userID(c)
+	if !ok {
+		return
+	}
+	var req services.CreateCommitStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		githubError(c, http.StatusUnprocessableEntity, "Validation Failed: state is required")
+		return
+	}
+
+	status, err := h.commitStatusService.Create(c.Request.Context(), repo.ID, strings.ToLower(c.Param("sha")), &userID, req)
+	if errors.Is(err, services.ErrInvalidCommitStatus) {
+		githubError(c, http.StatusUnprocessableEntity, "Validation Failed: "+err.Error())
+		return
+	}
+	if err != nil {
+		h.internalError(c, err, "Failed to create commit status")
+		return
+	}
+	c.JSON(http.StatusCreated, h.compat.Status(status))
+}
+
+// resolveRef turns a branch, tag or SHA into a full commit SHA
+func (h *GitHubCompatHandlers) resolveRef(c *gin.Context, repo *models.Repository) (string, bool) {
+	repoPath, err := h.repositoryService.GetRepositoryPath(c.Request.Context(), repo.ID)
+	if err != nil {
+		h.internalError(c, err, "Failed to get repository path")
+		return "", false
+	}
+	sha, err := h.gitService.ResolveSHA(c.Request.Context(), repoPath, c.Param("ref"))
+	if err != nil {
+		githubError(c, http.StatusNotFound, "No commit found for SHA: "+c.Param("ref"))
+		return "", false
+	}
+	return sha, true
+}
+
+// ListStatuses handles GET /repos/{owner}/{repo}/commits/{ref}/statuses
+func (h *GitHubCompatHandlers) ListStatuses(c *gin.Context) {
+	repo, _ := h.repository(c)
+	sha, ok := h.resolveRef(c, repo)
+	if !ok {
+		return
+	}
+	statuses, err := h.commitStatusService.List(c.Request.Context(), repo.ID, sha)
+	if err != nil {
+		h.internalError(c, err, "Failed to list commit statuses")
+		return
+	}
+	result := make([]services.GitHubStatus, 0, len(statuses))
+	for i := range statuses {
+		result = append(result, h.compat.Status(&statuses[i]))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetCombinedStatus handles GET /repos/{owner}/{repo}/commits/{ref}/status
+func (h *GitHubCompatHandlers) GetCombinedStatus(c *gin.Context) {
+	repo, fullName := h.repository(c)
+	sha, ok := h.resolveRef(c, repo)
+	if !ok {
+		return
+	}
+	combined, err := h.commitStatusService.Combined(c.Request.Context(), repo.ID, sha)
+	if err != nil {
+		h.internalError(c, err, "Failed to get combined commit status")
+		return
+	}
+	statuses := make([]services.GitHubStatus, 0, len(combined.Statuses))
+	for i := range combined.Statuses {
+		statuses = append(statuses, h.compat.Status(&combined.Statuses[i]))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"sha":         combined.SHA,
+		"state":       combined.State,
+		"total_count": combined.TotalCount,
+		"statuses":    statuses,
+		"repository":  h.compat.Repository(repo, strings.SplitN(fullName, "/", 2)[0]),
+	})
+}
+
+// ListHooks handles GET /repos/{owner}/{repo}/hooks
+func (h *GitHubCompatHandlers) ListHooks(c *gin.Context) {
+	repo, _ := h.repository(c)
+	hooks, err := h.webhookService.ListWebhooks(c.Request.Context(), repo.ID)
+	if err != nil {
+		h.internalError(c, err, "Failed to list webhooks")
+		return
+	}
+	result := make([]services.GitHubHook, 0, len(hooks))
+	for i := range hooks {
+		result = append(result, h.compat.Hook(&hooks[i]))
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// CreateHook handles POST /repos/{owner}/{repo}/hooks
+func (h *GitHubCompatHandlers) CreateHook(c *gin.Context) {
+	repo, _ := h.repository(c)
+	var req struct {
+		Name   string                    `json:"name"`
+		Config services.GitHubHookConfig `json:"config"`
+		Events []string                  `json:"events"`
+		Active *bool                     `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		githubError(c, http.StatusBadRequest, "Problems parsing JSON")
+		return
+	}
+	if req.Config.URL == "" {
+		githubError(c, http.StatusUnprocessableEntity, "Validation Failed: config.url is required")
+		return
+	}
+	if len(req.Events) == 0 {
+		req.Events = []string{"push"}
+	}
+	active := req.Active == nil || *req.Active
+
+	hook, err := h.webhookService.CreateWebhook(c.Request.Context(), repo.ID, "web", req.Config.URL, req.Config.Secret, req.Events,
+		services.GitHubHookContentType(req.Config.ContentType), req.Config.InsecureSSL == "1", active)
+	if err != nil {
+		h.internalError(c, err, "Failed to create webhook")
+		return
+	}
+	auditWebhook(c, audit.ActionWebhookCreate, hook)
+	c.JSON(http.StatusCreated, h.compat.Hook(hook))
+}
+
+// DeleteHook handles DELETE /repos/{owner}/{repo}/hooks/{hook_id}
+func (h *GitHubCompatHandlers) DeleteHook(c *gin.Context) {
+	repo, _ := h.repository(c)
+	hookID, err := uuid.Parse(c.Param("hook_id"))
+	if err != nil {
+		githubError(c, http.StatusNotFound, "Not Found")
+		return
+	}
+	hook, err := h.webhookService.GetWebhook(c.Request.Context(), hookID)
+	if err != nil || !hook.BelongsToRepository(repo.ID) {
+		githubError(c, http.StatusNotFound, "Not Found")
+		return
+	}
+	if err := h.webhookService.DeleteWebhook(c.Request.Context(), hookID); err != nil {
+		h.internalError(c, err, "Failed to delete webhook")
+		return
+	}
+	auditWebhook(c, audit.ActionWebhookDelete, hook)
+	c.Status(http.StatusNoContent)
+}
@@ -1,11 +1,17 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/a5c-ai/hub/internal/audit"
+	"github.com/a5c-ai/hub/internal/models"
 	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -31,16 +37,20 @@ func NewHooksHandlers(repositoryService services.RepositoryService, webhookDeliv
 
 // Webhook represents a repository webhook
 type Webhook struct {
-	ID           int                    `json:"id"`
-	Name         string                 `json:"name"`
-	Config       map[string]interface{} `json:"config"`
-	Events       []string               `json:"events"`
-	Active       bool                   `json:"active"`
-	CreatedAt    time.Time              `json:"created_at"`
-	UpdatedAt    time.Time              `json:"updated_at"`
-	PingURL      string                 `json:"ping_url,omitempty"`
-	TestURL      string                 `json:"test_url,omitempty"`
-	LastResponse *WebhookResponse       `json:"last_response,omitempty"`
+	ID     int                    `json:"id"`
+	Name   string                 `json:"name"`
+	Config map[string]interface{} `json:"config"`
+	Events []string               `json:"events"`
+	Active bool                   `json:"active"`
+	// PayloadVersion is the payload schema the webhook receives, shaped by
+	// PayloadTemplate when set
+	PayloadVersion  string           `json:"payload_version"`
+	PayloadTemplate string           `json:"payload_template,omitempty"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	PingURL         string           `json:"ping_url,omitempty"`
+	TestURL         string           `json:"test_url,omitempty"`
+	LastResponse    *WebhookResponse `json:"last_response,omitempty"`
 }
 
 // WebhookResponse represents a webhook delivery response
@@ -61,6 +71,87 @@ type DeployKey struct {
 	ReadOnly  bool      `json:"read_only"`
 }
 
+// WebhookDelivery is a delivery attempt as returned by the API
+type WebhookDelivery struct {
+	ID          uuid.UUID  `json:"id"`
+	GUID        string     `json:"guid"`
+	DeliveredAt time.Time  `json:"delivered_at"`
+	Redelivery  bool       `json:"redelivery"`
+	Duration    float64    `json:"duration"`
+	Status      string     `json:"status"`
+	StatusCode  int        `json:"status_code"`
+	Event       string     `json:"event"`
+	Attempts    int        `json:"attempts"`
+	NextRetryAt *time.Time `json:"next_retry_at,omitempty"`
+	URL         string     `json:"url"`
+	// Request and Response are only included for single deliveries
+	Request  *WebhookDeliveryPayload `json:"request,omitempty"`
+	Response *WebhookDeliveryPayload `json:"response,omitempty"`
+}
+
+// WebhookDeliveryPayload is the request sent or response received in a delivery
+type WebhookDeliveryPayload struct {
+	Headers map[string]string `json:"headers"`
+	Payload interface{}       `json:"payload"`
+}
+
+// webhookRepository resolves the repository of a webhook request; webhooks
+// can only be managed by repository admins
+func (h *HooksHandlers) webhookRepository(c *gin.Context) (*models.Repository, bool) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return nil, false
+	}
+
+	if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient repository permissions"})
+		return nil, false
+	}
+	return repo, true
+}
+
+// ownsWebhook reports whether hookID is a webhook of repo, answering 404
+// when it is not
+func (h *HooksHandlers) ownsWebhook(c *gin.Context, repo *models.Repository, hookID uuid.UUID) bool {
+	webhook, err := h.webhookDeliveryService.GetWebhook(c.Request.Context(), hookID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		} else {
+			h.logger.WithError(err).Error("Failed to get webhook")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook"})
+		}
+		return false
+	}
+	if !webhook.BelongsToRepository(repo.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return false
+	}
+	return true
+}
+
+// auditWebhook records a change to a repository or organization webhook
+// in the audit trail
+func auditWebhook(c *gin.Context, action string, webhook *models.Webhook) {
+	event := audit.Event{
+		Action:         action,
+		OrganizationID: webhook.OrganizationID,
+		RepositoryID:   webhook.RepositoryID,
+		TargetType:     "webhook",
+		TargetID:       webhook.ID.String(),
+		TargetName:     webhook.Name,
+	}
+	if webhook.URL != "" {
+		event.Metadata = map[string]interface{}{"url": webhook.URL, "events": webhook.GetEventsSlice(), "active": webhook.Active}
+	}
+	audit.Record(c.Request.Context(), event)
+}
+
 // ListWebhooks handles GET /api/v1/repositories/{owner}/{repo}/hooks
 func (h *HooksHandlers) ListWebhooks(c *gin.Context) {
 	owner := c.Param("owner")
@@ -71,14 +162,9 @@ func (h *HooksHandlers) ListWebhooks(c *gin.Context) {
 		return
 	}
 
-	// Get repository first
-	repo, err := h.repositoryService.Get(c.Request.Context(), owner, repoName)
-	if err != nil {
-		if err.Error() == "repository not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
-		}
+	// Webhooks are managed by repository admins
+	repo, ok := h.webhookRepository(c)
+	if !ok {
 		return
 	}
 
@@ -107,12 +193,14 @@ func (h *HooksHandlers) ListWebhooks(c *gin.Context) {
 					}
 				}(),
 			},
-			Events:    dbWebhook.GetEventsSlice(),
-			Active:    dbWebhook.Active,
-			CreatedAt: dbWebhook.CreatedAt,
-			UpdatedAt: dbWebhook.UpdatedAt,
-			PingURL:   "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/pings",
-			TestURL:   "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/test",
+			Events:          dbWebhook.GetEventsSlice(),
+			Active:          dbWebhook.Active,
+			PayloadVersion:  dbWebhook.PayloadVersion,
+			PayloadTemplate: dbWebhook.PayloadTemplate,
+			CreatedAt:       dbWebhook.CreatedAt,
+			UpdatedAt:       dbWebhook.UpdatedAt,
+			PingURL:         "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/pings",
+			TestURL:         "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/test",
 		}
 	}
 
@@ -134,28 +222,29 @@ func (h *HooksHandlers) CreateWebhook(c *gin.Context) {
 		return
 	}
 
-	// Get repository first
-	repo, err := h.repositoryService.Get(c.Request.Context(), owner, repoName)
-	if err != nil {
-		if err.Error() == "repository not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
-		}
+	// Webhooks are managed by repository admins
+	repo, ok := h.webhookRepository(c)
+	if !ok {
 		return
 	}
 
 	var req struct {
-		Name   string                 `json:"name"`
-		Config map[string]interface{} `json:"config"`
-		Events []string               `json:"events"`
-		Active *bool                  `json:"active,omitempty"`
+		Name            string                 `json:"name"`
+		Config          map[string]interface{} `json:"config"`
+		Events          []string               `json:"events"`
+		Active          *bool                  `json:"active,omitempty"`
+		PayloadVersion  *string                `json:"payload_version,omitempty"`
+		PayloadTemplate *string                `json:"payload_template,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
 		return
 	}
+	format, ok := newWebhookPayloadFormat(c, req.PayloadVersion, req.PayloadTemplate)
+	if !ok {
+		return
+	}
 
 	// Validate required fields
 	if req.Name == "" {
@@ -219,6 +308,9 @@ func (h *HooksHandlers) CreateWebhook(c *gin.Context) {
 		insecureSSL,
 		active,
 	)
+	if err == nil && len(format) > 0 {
+		dbWebhook, err = h.webhookDeliveryService.UpdateWebhook(c.Request.Context(), dbWebhook.ID, format)
+	}
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to create webhook")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
@@ -246,12 +338,14 @@ func (h *HooksHandlers) CreateWebhook(c *gin.Context) {
 				}
 			}(),
 		},
-		Events:    dbWebhook.GetEventsSlice(),
-		Active:    dbWebhook.Active,
-		CreatedAt: dbWebhook.CreatedAt,
-		UpdatedAt: dbWebhook.UpdatedAt,
-		PingURL:   "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/pings",
-		TestURL:   "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/test",
+		Events:          dbWebhook.GetEventsSlice(),
+		Active:          dbWebhook.Active,
+		PayloadVersion:  dbWebhook.PayloadVersion,
+		PayloadTemplate: dbWebhook.PayloadTemplate,
+		CreatedAt:       dbWebhook.CreatedAt,
+		UpdatedAt:       dbWebhook.UpdatedAt,
+		PingURL:         "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/pings",
+		TestURL:         "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/test",
 	}
 
 	h.logger.WithFields(logrus.Fields{
@@ -260,6 +354,7 @@ func (h *HooksHandlers) CreateWebhook(c *gin.Context) {
 		"webhook_url": req.Config["url"],
 		"events":      req.Events,
 	}).Info("Created repository webhook")
+	auditWebhook(c, audit.ActionWebhookCreate, dbWebhook)
 
 	c.JSON(http.StatusCreated, webhook)
 }
@@ -282,14 +377,12 @@ func (h *HooksHandlers) GetWebhook(c *gin.Context) {
 		return
 	}
 
-	// Get repository first
-	_, err = h.repositoryService.Get(c.Request.Context(), owner, repoName)
-	if err != nil {
-		if err.Error() == "repository not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
-		}
+	// Webhooks are managed by repository admins
+	repo, ok := h.webhookRepository(c)
+	if !ok {
+		return
+	}
+	if !h.ownsWebhook(c, repo, hookID) {
 		return
 	}
 
@@ -347,13 +440,15 @@ func (h *HooksHandlers) GetWebhook(c *gin.Context) {
 				}
 			}(),
 		},
-		Events:       dbWebhook.GetEventsSlice(),
-		Active:       dbWebhook.Active,
-		CreatedAt:    dbWebhook.CreatedAt,
-		UpdatedAt:    dbWebhook.UpdatedAt,
-		PingURL:      "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/pings",
-		TestURL:      "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/test",
-		LastResponse: lastResponse,
+		Events:          dbWebhook.GetEventsSlice(),
+		Active:          dbWebhook.Active,
+		PayloadVersion:  dbWebhook.PayloadVersion,
+		PayloadTemplate: dbWebhook.PayloadTemplate,
+		CreatedAt:       dbWebhook.CreatedAt,
+		UpdatedAt:       dbWebhook.UpdatedAt,
+		PingURL:         "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/pings",
+		TestURL:         "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/test",
+		LastResponse:    lastResponse,
 	}
 
 	c.JSON(http.StatusOK, webhook)
@@ -377,21 +472,21 @@ func (h *HooksHandlers) UpdateWebhook(c *gin.Context) {
 		return
 	}
 
-	// Get repository first
-	repo, err := h.repositoryService.Get(c.Request.Context(), owner, repoName)
-	if err != nil {
-		if err.Error() == "repository not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
-		}
+	// Webhooks are managed by repository admins
+	repo, ok := h.webhookRepository(c)
+	if !ok {
+		return
+	}
+	if !h.ownsWebhook(c, repo, hookID) {
 		return
 	}
 
 	var req struct {
-		Config map[string]interface{} `json:"config,omitempty"`
-		Events []string               `json:"events,omitempty"`
-		Active *bool                  `json:"active,omitempty"`
+		Config          map[string]interface{} `json:"config,omitempty"`
+		Events          []string               `json:"events,omitempty"`
+		Active          *bool                  `json:"active,omitempty"`
+		PayloadVersion  *string                `json:"payload_version,omitempty"`
+		PayloadTemplate *string                `json:"payload_template,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -424,20 +519,24 @@ func (h *HooksHandlers) UpdateWebhook(c *gin.Context) {
 
 	// Handle events update
 	if req.Events != nil {
-		// In a full implementation, this would properly serialize events
-		updates["events"] = strings.Join(req.Events, ",")
+		var events models.Webhook
+		events.SetEventsSlice(req.Events)
+		updates["events"] = events.Events
 	}
 
 	// Handle active status update
 	if req.Active != nil {
 		updates["active"] = *req.Active
 	}
+	webhookPayloadFormat(req.PayloadVersion, req.PayloadTemplate, updates)
 
 	// Update webhook in database
 	dbWebhook, err := h.webhookDeliveryService.UpdateWebhook(c.Request.Context(), hookID, updates)
 	if err != nil {
 		if strings.Contains(err.Error(), "not found") {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		} else if errors.Is(err, services.ErrInvalidWebhookPayloadFormat) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
 		} else {
 			h.logger.WithError(err).Error("Failed to update webhook")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
@@ -466,18 +565,21 @@ func (h *HooksHandlers) UpdateWebhook(c *gin.Context) {
 				}
 			}(),
 		},
-		Events:    dbWebhook.GetEventsSlice(),
-		Active:    dbWebhook.Active,
-		CreatedAt: dbWebhook.CreatedAt,
-		UpdatedAt: dbWebhook.UpdatedAt,
-		PingURL:   "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/pings",
-		TestURL:   "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/test",
+		Events:          dbWebhook.GetEventsSlice(),
+		Active:          dbWebhook.Active,
+		PayloadVersion:  dbWebhook.PayloadVersion,
+		PayloadTemplate: dbWebhook.PayloadTemplate,
+		CreatedAt:       dbWebhook.CreatedAt,
+		UpdatedAt:       dbWebhook.UpdatedAt,
+		PingURL:         "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/pings",
+		TestURL:         "/api/v1/repositories/" + owner + "/" + repoName + "/hooks/" + dbWebhook.ID.String() + "/test",
 	}
 
 	h.logger.WithFields(logrus.Fields{
 		"repo_id":    repo.ID,
 		"webhook_id": hookID,
 	}).Info("Updated repository webhook")
+	auditWebhook(c, audit.ActionWebhookUpdate, dbWebhook)
 
 	c.JSON(http.StatusOK, webhook)
 }
@@ -500,14 +602,12 @@ func (h *HooksHandlers) DeleteWebhook(c *gin.Context) {
 		return
 	}
 
-	// Get repository first
-	repo, err := h.repositoryService.Get(c.Request.Context(), owner, repoName)
-	if err != nil {
-		if err.Error() == "repository not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
-		}
+	// Webhooks are managed by repository admins
+	repo, ok := h.webhookRepository(c)
+	if !ok {
+		return
+	}
+	if !h.ownsWebhook(c, repo, hookID) {
 		return
 	}
 
@@ -527,6 +627,7 @@ func (h *HooksHandlers) DeleteWebhook(c *gin.Context) {
 		"repo_id":    repo.ID,
 		"webhook_id": hookID,
 	}).Info("Deleted repository webhook")
+	auditWebhook(c, audit.ActionWebhookDelete, &models.Webhook{ID: hookID, RepositoryID: &repo.ID})
 
 	c.JSON(http.StatusNoContent, nil)
 }
@@ -549,14 +650,12 @@ func (h *HooksHandlers) PingWebhook(c *gin.Context) {
 		return
 	}
 
-	// Get repository first
-	repo, err := h.repositoryService.Get(c.Request.Context(), owner, repoName)
-	if err != nil {
-		if err.Error() == "repository not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
-		}
+	// Webhooks are managed by repository admins
+	repo, ok := h.webhookRepository(c)
+	if !ok {
+		return
+	}
+	if !h.ownsWebhook(c, repo, hookID) {
 		return
 	}
 
@@ -582,6 +681,257 @@ func (h *HooksHandlers) PingWebhook(c *gin.Context) {
 	})
 }
 
+// webhookDelivery converts a delivery to its API format, with the request
+// and response when detailed
+func webhookDelivery(delivery *models.WebhookDelivery, detailed bool) WebhookDelivery {
+	status := "failed"
+	if delivery.Success {
+		status = "OK"
+	}
+	out := WebhookDelivery{
+		ID:          delivery.ID,
+		GUID:        delivery.DeliveryID,
+		DeliveredAt: delivery.UpdatedAt,
+		Redelivery:  delivery.Redelivery,
+		Duration:    float64(delivery.Duration) / 1000,
+		Status:      status,
+		StatusCode:  delivery.StatusCode,
+		Event:       delivery.EventType,
+		Attempts:    delivery.Attempts,
+		NextRetryAt: delivery.NextRetryAt,
+		URL:         delivery.URL,
+	}
+	if !detailed {
+		return out
+	}
+
+	var payload interface{}
+	if err := json.Unmarshal([]byte(delivery.Payload), &payload); err != nil {
+		payload = delivery.Payload
+	}
+	out.Request = &WebhookDeliveryPayload{
+		Headers: map[string]string{"X-Hub-Event": delivery.EventType, "X-Hub-Delivery": delivery.DeliveryID},
+		Payload: payload,
+	}
+	headers := map[string]string{}
+	json.Unmarshal([]byte(delivery.ResponseHeaders), &headers)
+	out.Response = &WebhookDeliveryPayload{Headers: headers, Payload: delivery.ResponseBody}
+	return out
+}
+
+// deliveryRequest resolves the repository and webhook of a delivery request
+func (h *HooksHandlers) deliveryRequest(c *gin.Context) (uuid.UUID, bool) {
+	hookID, err := uuid.Parse(c.Param("hook_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hook ID"})
+		return uuid.Nil, false
+	}
+	repo, ok := h.webhookRepository(c)
+	if !ok || !h.ownsWebhook(c, repo, hookID) {
+		return uuid.Nil, false
+	}
+	return hookID, true
+}
+
+// ListDeliveries handles GET /api/v1/repositories/{owner}/{repo}/hooks/{hook_id}/deliveries
+func (h *HooksHandlers) ListDeliveries(c *gin.Context) {
+	hookID, ok := h.deliveryRequest(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "30"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 30
+	}
+
+	deliveries, err := h.webhookDeliveryService.GetDeliveries(c.Request.Context(), hookID, perPage, (page-1)*perPage)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list webhook deliveries")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhook deliveries"})
+		return
+	}
+	total, err := h.webhookDeliveryService.CountDeliveries(c.Request.Context(), hookID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to count webhook deliveries")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhook deliveries"})
+		return
+	}
+
+	result := make([]WebhookDelivery, len(deliveries))
+	for i := range deliveries {
+		result[i] = webhookDelivery(&deliveries[i], false)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries":  result,
+		"total_count": total,
+		"page":        page,
+		"per_page":    perPage,
+	})
+}
+
+// GetDelivery handles GET /api/v1/repositories/{owner}/{repo}/hooks/{hook_id}/deliveries/{delivery_id}
+func (h *HooksHandlers) GetDelivery(c *gin.Context) {
+	hookID, ok := h.deliveryRequest(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(c.Param("delivery_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	delivery, err := h.webhookDeliveryService.GetDelivery(c.Request.Context(), hookID, deliveryID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		} else {
+			h.logger.WithError(err).Error("Failed to get webhook delivery")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook delivery"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, webhookDelivery(delivery, true))
+}
+
+// RedeliverDelivery handles POST /api/v1/repositories/{owner}/{repo}/hooks/{hook_id}/deliveries/{delivery_id}/attempts
+//
+// The payload is sent again right away and the attempt is returned as a new
+// delivery, whether or not the receiver accepted it.
+func (h *HooksHandlers) RedeliverDelivery(c *gin.Context) {
+	hookID, ok := h.deliveryRequest(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(c.Param("delivery_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	delivery, err := h.webhookDeliveryService.Redeliver(c.Request.Context(), hookID, deliveryID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		} else {
+			h.logger.WithError(err).Error("Failed to redeliver webhook")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to redeliver webhook"})
+		}
+		return
+	}
+	c.JSON(http.StatusAccepted, webhookDelivery(delivery, true))
+}
+
+// webhookPayloadFormat reads the payload version and template present in a
+// webhook request into updates
+func webhookPayloadFormat(version, tmpl *string, updates map[string]interface{}) {
+	if version != nil {
+		updates["payload_version"] = *version
+	}
+	if tmpl != nil {
+		updates["payload_template"] = *tmpl
+	}
+}
+
+// newWebhookPayloadFormat returns the payload format updates of a webhook
+// being created, answering 422 when they are invalid
+func newWebhookPayloadFormat(c *gin.Context, version, tmpl *string) (map[string]interface{}, bool) {
+	format := map[string]interface{}{}
+	webhookPayloadFormat(version, tmpl, format)
+	v, _ := format["payload_version"].(string)
+	if v == "" {
+		v = services.WebhookPayloadVersion1
+	}
+	t, _ := format["payload_template"].(string)
+	if err := services.ValidateWebhookPayloadFormat(v, t); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return format, true
+}
+
+// renderWebhookPayload answers with the body webhook would be sent for the
+// event of the request, using the payload version and template of the
+// request in place of the webhook's. The event payload defaults to the
+// one sent by pings.
+func renderWebhookPayload(c *gin.Context, svc *services.WebhookDeliveryService, logger *logrus.Logger, webhook models.Webhook) {
+	var req struct {
+		Event           string                 `json:"event"`
+		Payload         map[string]interface{} `json:"payload,omitempty"`
+		PayloadVersion  *string                `json:"payload_version,omitempty"`
+		PayloadTemplate *string                `json:"payload_template,omitempty"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if req.PayloadVersion != nil {
+		webhook.PayloadVersion = *req.PayloadVersion
+	}
+	if req.PayloadTemplate != nil {
+		webhook.PayloadTemplate = *req.PayloadTemplate
+	}
+	if webhook.PayloadVersion == "" {
+		webhook.PayloadVersion = services.WebhookPayloadVersion1
+	}
+	if req.Event == "" {
+		req.Event = "ping"
+	}
+	if req.Payload == nil {
+		req.Payload = svc.SamplePayload(&webhook, req.Event)
+	}
+
+	body, err := svc.RenderPayload(&webhook, req.Event, uuid.New().String(), req.Payload)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidWebhookPayloadFormat) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"valid": false, "error": err.Error()})
+		} else {
+			logger.WithError(err).Error("Failed to render webhook payload")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to render webhook payload"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"valid":           true,
+		"event":           req.Event,
+		"payload_version": webhook.PayloadVersion,
+		"content_type":    webhook.ContentType,
+		"body":            string(body),
+	})
+}
+
+// ValidatePayloadTemplate handles POST /api/v1/repositories/{owner}/{repo}/hooks/templates/validate
+//
+// The payload version and template of the request are checked by rendering
+// an event with them, before any webhook uses them.
+func (h *HooksHandlers) ValidatePayloadTemplate(c *gin.Context) {
+	repo, ok := h.webhookRepository(c)
+	if !ok {
+		return
+	}
+	renderWebhookPayload(c, h.webhookDeliveryService, h.logger, models.Webhook{RepositoryID: &repo.ID, ContentType: "application/json"})
+}
+
+// RenderPayload handles POST /api/v1/repositories/{owner}/{repo}/hooks/{hook_id}/render
+func (h *HooksHandlers) RenderPayload(c *gin.Context) {
+	hookID, ok := h.deliveryRequest(c)
+	if !ok {
+		return
+	}
+	webhook, err := h.webhookDeliveryService.GetWebhook(c.Request.Context(), hookID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get webhook")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook"})
+		return
+	}
+	renderWebhookPayload(c, h.webhookDeliveryService, h.logger, *webhook)
+}
+
 // ListDeployKeys handles GET /api/v1/repositories/{owner}/{repo}/keys
 func (h *HooksHandlers) ListDeployKeys(c *gin.Context) {
 	owner := c.Param("owner")
@@ -723,6 +1073,14 @@ func (h *HooksHandlers) CreateDeployKey(c *gin.Context) {
 		"title":         req.Title,
 		"read_only":     readOnly,
 	}).Info("Created repository deploy key")
+	audit.Record(c.Request.Context(), audit.Event{
+		Action:       audit.ActionDeployKeyAdd,
+		RepositoryID: &repo.ID,
+		TargetType:   "deploy_key",
+		TargetID:     dbDeployKey.ID.String(),
+		TargetName:   dbDeployKey.Title,
+		Metadata:     map[string]interface{}{"read_only": readOnly},
+	})
 
 	c.JSON(http.StatusCreated, deployKey)
 }
@@ -827,6 +1185,12 @@ func (h *HooksHandlers) DeleteDeployKey(c *gin.Context) {
 		"repo_id":       repo.ID,
 		"deploy_key_id": keyID,
 	}).Info("Deleted repository deploy key")
+	audit.Record(c.Request.Context(), audit.Event{
+		Action:       audit.ActionDeployKeyRemove,
+		RepositoryID: &repo.ID,
+		TargetType:   "deploy_key",
+		TargetID:     keyID.String(),
+	})
 
 	c.JSON(http.StatusNoContent, nil)
 }
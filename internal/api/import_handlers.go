@@ -2,65 +2,104 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 
 	"github.com/a5c-ai/hub/internal/db"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 )
 
-// ImportHandlers handles repository import endpoints and enqueues jobs.
+// ImportHandlers imports repositories with their issues, pull requests and
+// releases from GitHub and GitLab
 type ImportHandlers struct {
-	db *db.Database
+	importService services.ImportService
+	logger        *logrus.Logger
 }
 
-// NewImportHandlers creates a new ImportHandlers with database access.
-func NewImportHandlers(database *db.Database) *ImportHandlers {
-	return &ImportHandlers{db: database}
+// NewImportHandlers creates a new ImportHandlers
+func NewImportHandlers(importService services.ImportService, logger *logrus.Logger) *ImportHandlers {
+	return &ImportHandlers{importService: importService, logger: logger}
 }
 
-// InitiateImport starts an import job from an external Git service.
+// InitiateImport handles POST /api/v1/import. It responds 202 with the
+// import to poll while the repository is imported in the background.
 func (h *ImportHandlers) InitiateImport(c *gin.Context) {
-	type request struct {
-		URL   string `json:"url" binding:"required"`
-		Token string `json:"token"`
+	userID, ok := c.Get("user_id")
+	uid, isUUID := userID.(uuid.UUID)
+	if !ok || !isUUID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		URL         string            `json:"url" binding:"required"`
+		Provider    string            `json:"provider"` // github or gitlab; derived from the URL when empty
+		APIURL      string            `json:"api_url"`
+		Token       string            `json:"token"`
+		Name        string            `json:"name"`
+		Description string            `json:"description"`
+		Visibility  models.Visibility `json:"visibility"`
 	}
-	var req request
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
 		return
 	}
-	jobID := uuid.New().String()
-	// enqueue background import job using job queue (database fallback)
-	data, err := json.Marshal(req)
+
+	imp, err := h.importService.Start(c.Request.Context(), uid, services.StartImportRequest{
+		URL:         req.URL,
+		Provider:    req.Provider,
+		APIURL:      req.APIURL,
+		Token:       req.Token,
+		Name:        req.Name,
+		Description: req.Description,
+		Visibility:  req.Visibility,
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to marshal job data"})
+		h.importError(c, err)
 		return
 	}
-	if err := h.db.DB.Exec(
-		`INSERT INTO job_queue (job_id, workflow_run_id, data) VALUES (?, ?, ?);`,
-		jobID, jobID, data,
-	).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enqueue job"})
-		return
-	}
-	c.JSON(http.StatusAccepted, gin.H{"job_id": jobID})
+	c.Header("Location", "/api/v1/import/"+imp.ID.String())
+	c.JSON(http.StatusAccepted, imp)
 }
 
-// GetImportStatus returns the status of an import job.
+// GetImportStatus handles GET /api/v1/import/:import_id
 func (h *ImportHandlers) GetImportStatus(c *gin.Context) {
-	jobID := c.Param("job_id")
-	// fetch job status from job queue (database fallback)
-	var status string
-	err := h.db.DB.Raw(
-		"SELECT status FROM job_queue WHERE job_id = ?;",
-		jobID,
-	).Row().Scan(&status)
+	userID, ok := c.Get("user_id")
+	uid, isUUID := userID.(uuid.UUID)
+	if !ok || !isUUID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	importID, err := uuid.Parse(c.Param("import_id"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"job_id": jobID, "status": status})
+
+	imp, err := h.importService.Get(c.Request.Context(), uid, importID)
+	if err != nil {
+		h.importError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, imp)
+}
+
+func (h *ImportHandlers) importError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrRepositoryImportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository import not found"})
+	case errors.Is(err, services.ErrInvalidImportSource):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrImportRepositoryExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Repository already exists"})
+	default:
+		h.logger.WithError(err).Error("Failed to import repository")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import repository"})
+	}
 }
 
 // ExportHandlers handles repository export endpoints and enqueues jobs.
@@ -0,0 +1,399 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// IssueHandlers serves issues, their comments, timelines and transfers
+type IssueHandlers struct {
+	issueService      services.IssueService
+	issueLinkService  services.IssueLinkService
+	repositoryService services.RepositoryService
+	permissionService services.PermissionService
+	db                *gorm.DB
+	logger            *logrus.Logger
+}
+
+func NewIssueHandlers(issueService services.IssueService, issueLinkService services.IssueLinkService, repositoryService services.RepositoryService, permissionService services.PermissionService, db *gorm.DB, logger *logrus.Logger) *IssueHandlers {
+	return &IssueHandlers{
+		issueService:      issueService,
+		issueLinkService:  issueLinkService,
+		repositoryService: repositoryService,
+		permissionService: permissionService,
+		db:                db,
+		logger:            logger,
+	}
+}
+
+// repositoryFullName returns the "owner/name" of a repository
+func repositoryFullName(ctx context.Context, db *gorm.DB, repo *models.Repository) (string, error) {
+	var owner string
+	var err error
+	if repo.OwnerType == models.OwnerTypeOrganization {
+		err = db.WithContext(ctx).Model(&models.Organization{}).Where("id = ?", repo.OwnerID).Pluck("name", &owner).Error
+	} else {
+		err = db.WithContext(ctx).Model(&models.User{}).Where("id = ?", repo.OwnerID).Pluck("username", &owner).Error
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve repository owner: %w", err)
+	}
+	return owner + "/" + repo.Name, nil
+}
+
+// hasRepositoryPermission reports whether the authenticated user holds
+// permission on a repository other than the one in the path
+func (h *IssueHandlers) hasRepositoryPermission(c *gin.Context, repoID uuid.UUID, permission models.Permission) (bool, error) {
+	t, ok := tenant.FromContext(c.Request.Context())
+	if !ok || t.UserID == nil {
+		return false, nil
+	}
+	if t.IsAdmin {
+		return true, nil
+	}
+	return h.permissionService.CheckRepositoryPermission(c.Request.Context(), *t.UserID, repoID, permission)
+}
+
+// getIssue resolves the repository and issue in the path
+func (h *IssueHandlers) getIssue(c *gin.Context) (*models.Issue, *tenant.Context, bool) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return nil, nil, false
+	}
+	t, _ := tenant.FromContext(c.Request.Context())
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid issue number"})
+		return nil, nil, false
+	}
+	issue, err := h.issueService.Get(c.Request.Context(), repo.ID, number)
+	if err != nil {
+		h.issueError(c, err, "Failed to get issue")
+		return nil, nil, false
+	}
+	return issue, t, true
+}
+
+// ListIssues handles GET /api/v1/repositories/{owner}/{repo}/issues
+//
+// Lists open issues unless state is closed or all. labels is a comma
+// separated list of label names the issues must all have; assignee and
+// milestone take a username or milestone number, "none" or "*".
+func (h *IssueHandlers) ListIssues(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	page, perPage := githubPagination(c)
+	filter := services.IssueFilter{
+		Assignee:  c.Query("assignee"),
+		Milestone: c.Query("milestone"),
+		Page:      page,
+		PageSize:  perPage,
+	}
+	switch state := c.DefaultQuery("state", "open"); state {
+	case "open", "closed":
+		issueState := models.IssueState(state)
+		filter.State = &issueState
+	case "all":
+	default:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "state must be open, closed or all"})
+		return
+	}
+	for _, name := range strings.Split(c.Query("labels"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			filter.Labels = append(filter.Labels, name)
+		}
+	}
+
+	issues, err := h.issueService.List(c.Request.Context(), repo.ID, filter)
+	if err != nil {
+		h.issueError(c, err, "Failed to list issues")
+		return
+	}
+	c.JSON(http.StatusOK, issues)
+}
+
+// CreateIssue handles POST /api/v1/repositories/{owner}/{repo}/issues
+//
+// Anyone who can read the repository may open an issue. Labels, assignees
+// and the milestone are only set for callers with triage permission and
+// are ignored otherwise.
+func (h *IssueHandlers) CreateIssue(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	t, _ := tenant.FromContext(c.Request.Context())
+	if t.UserID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	var req services.CreateIssueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if !t.HasPermission(models.PermissionTriage) {
+		req.Labels, req.Assignees, req.Milestone = nil, nil, nil
+	}
+
+	issue, err := h.issueService.Create(c.Request.Context(), repo.ID, *t.UserID, req)
+	if err != nil {
+		h.issueError(c, err, "Failed to create issue")
+		return
+	}
+	if issue, err = h.issueService.Get(c.Request.Context(), repo.ID, issue.Number); err != nil {
+		h.issueError(c, err, "Failed to get issue")
+		return
+	}
+	c.JSON(http.StatusCreated, issue)
+}
+
+// GetIssue handles GET /api/v1/repositories/{owner}/{repo}/issues/{number}
+func (h *IssueHandlers) GetIssue(c *gin.Context) {
+	issue, _, ok := h.getIssue(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, issue)
+}
+
+// UpdateIssue handles PATCH /api/v1/repositories/{owner}/{repo}/issues/{number}
+//
+// Closing and reopening are state changes. The author of an issue may edit
+// its title and body and close it; everything else needs triage permission.
+func (h *IssueHandlers) UpdateIssue(c *gin.Context) {
+	issue, t, ok := h.getIssue(c)
+	if !ok {
+		return
+	}
+	if t.UserID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	var req services.UpdateIssueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if !t.HasPermission(models.PermissionTriage) {
+		isAuthor := issue.UserID != nil && *issue.UserID == *t.UserID
+		if !isAuthor || req.Labels != nil || req.Assignees != nil || req.Milestone != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient repository permissions"})
+			return
+		}
+	}
+
+	updated, err := h.issueService.Update(c.Request.Context(), issue, *t.UserID, req)
+	if err != nil {
+		h.issueError(c, err, "Failed to update issue")
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// ListIssueComments handles GET /api/v1/repositories/{owner}/{repo}/issues/{number}/comments
+func (h *IssueHandlers) ListIssueComments(c *gin.Context) {
+	issue, _, ok := h.getIssue(c)
+	if !ok {
+		return
+	}
+	page, perPage := githubPagination(c)
+	comments, err := h.issueService.ListComments(c.Request.Context(), issue, page, perPage)
+	if err != nil {
+		h.issueError(c, err, "Failed to list comments")
+		return
+	}
+	c.JSON(http.StatusOK, comments)
+}
+
+// CreateIssueComment handles POST /api/v1/repositories/{owner}/{repo}/issues/{number}/comments
+func (h *IssueHandlers) CreateIssueComment(c *gin.Context) {
+	issue, t, ok := h.getIssue(c)
+	if !ok {
+		return
+	}
+	if t.UserID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	var req services.CreateIssueCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	comment, err := h.issueService.CreateComment(c.Request.Context(), issue, *t.UserID, req)
+	if err != nil {
+		h.issueError(c, err, "Failed to create comment")
+		return
+	}
+	c.JSON(http.StatusCreated, comment)
+}
+
+// GetIssueTimeline handles GET /api/v1/repositories/{owner}/{repo}/issues/{number}/timeline
+func (h *IssueHandlers) GetIssueTimeline(c *gin.Context) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return
+	}
+	if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionRead) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid issue number"})
+		return
+	}
+
+	// Transferred issues keep their timeline under the old number for
+	// anyone who can still read them
+	issue, err := h.issueService.Resolve(c.Request.Context(), repo.ID, number)
+	if err == nil && issue.RepositoryID != repo.ID {
+		var readable bool
+		if readable, err = h.hasRepositoryPermission(c, issue.RepositoryID, models.PermissionRead); err == nil && !readable {
+			err = services.ErrIssueNotFound
+		}
+	}
+	if err != nil {
+		if errors.Is(err, services.ErrIssueNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Issue not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get issue"})
+		}
+		return
+	}
+
+	events, err := h.issueLinkService.ListEvents(c.Request.Context(), issue.ID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list issue timeline")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list issue timeline"})
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// TransferIssue handles POST /api/v1/repositories/{owner}/{repo}/issues/{number}/transfer
+//
+// Moves the issue to another repository the caller can write to. Labels are
+// kept when the target repository has a label of the same name; the others
+// are listed in dropped_labels.
+func (h *IssueHandlers) TransferIssue(c *gin.Context) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return
+	}
+	t, ok := tenant.FromContext(c.Request.Context())
+	if !ok || !t.HasPermission(models.PermissionRead) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		return
+	}
+	if t.UserID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	if !t.HasPermission(models.PermissionWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient repository permissions"})
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid issue number"})
+		return
+	}
+	var req services.TransferIssueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	owner, name, found := strings.Cut(req.Repository, "/")
+	if !found || owner == "" || name == "" {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "repository must be of the form owner/name"})
+		return
+	}
+
+	issue, err := h.issueService.Get(c.Request.Context(), repo.ID, number)
+	if err != nil {
+		h.issueError(c, err, "Failed to get issue")
+		return
+	}
+
+	// Repositories the caller cannot read are reported as missing
+	target, err := h.repositoryService.Get(c.Request.Context(), owner, name)
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Target repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return
+	}
+	readable, err := h.hasRepositoryPermission(c, target.ID, models.PermissionRead)
+	if err != nil {
+		h.issueError(c, err, "Failed to check repository permissions")
+		return
+	}
+	if !readable {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target repository not found"})
+		return
+	}
+	writable, err := h.hasRepositoryPermission(c, target.ID, models.PermissionWrite)
+	if err != nil {
+		h.issueError(c, err, "Failed to check repository permissions")
+		return
+	}
+	if !writable {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions on the target repository"})
+		return
+	}
+	// Issues of private repositories must not become public
+	if repo.Visibility != models.VisibilityPublic && target.Visibility == models.VisibilityPublic {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Issues cannot be transferred from a non-public to a public repository"})
+		return
+	}
+
+	result, err := h.issueService.Transfer(c.Request.Context(), issue, target, *t.UserID)
+	if err != nil {
+		h.issueError(c, err, "Failed to transfer issue")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *IssueHandlers) issueError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrIssueNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Issue not found"})
+	case errors.Is(err, services.ErrInvalidIssue):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// JobHandlers lets site admins inspect the background job queue and retry
+// or cancel jobs
+type JobHandlers struct {
+	queue  *jobs.Queue
+	logger *logrus.Logger
+}
+
+func NewJobHandlers(queue *jobs.Queue, logger *logrus.Logger) *JobHandlers {
+	return &JobHandlers{
+		queue:  queue,
+		logger: logger,
+	}
+}
+
+// ListJobs handles GET /api/v1/admin/jobs
+func (h *JobHandlers) ListJobs(c *gin.Context) {
+	filter := jobs.QueueFilter{
+		State: models.QueuedJobState(c.Query("state")),
+		Kind:  c.Query("kind"),
+	}
+	switch filter.State {
+	case "", models.JobPending, models.JobRunning, models.JobSucceeded, models.JobFailed, models.JobCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid state"})
+		return
+	}
+	filter.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	filter.PerPage, _ = strconv.Atoi(c.DefaultQuery("per_page", "30"))
+	if filter.PerPage < 1 || filter.PerPage > 100 {
+		filter.PerPage = 30
+	}
+
+	list, total, err := h.queue.List(c.Request.Context(), filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list jobs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list jobs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":        list,
+		"total_count": total,
+		"page":        filter.Page,
+		"per_page":    filter.PerPage,
+	})
+}
+
+// GetJobStats handles GET /api/v1/admin/jobs/stats
+func (h *JobHandlers) GetJobStats(c *gin.Context) {
+	stats, err := h.queue.Stats(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get job stats")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job stats"})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetJob handles GET /api/v1/admin/jobs/:id
+func (h *JobHandlers) GetJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+	job, err := h.queue.Get(c.Request.Context(), id)
+	if err != nil {
+		h.jobError(c, err, "Failed to get job")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// RetryJob handles POST /api/v1/admin/jobs/:id/retry
+func (h *JobHandlers) RetryJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+	job, err := h.queue.Retry(c.Request.Context(), id)
+	if err != nil {
+		h.jobError(c, err, "Failed to retry job")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CancelJob handles DELETE /api/v1/admin/jobs/:id
+func (h *JobHandlers) CancelJob(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+	job, err := h.queue.Cancel(c.Request.Context(), id)
+	if err != nil {
+		h.jobError(c, err, "Failed to cancel job")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+func (h *JobHandlers) jobError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+	case errors.Is(err, jobs.ErrJobState):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// LabelHandlers serves repository labels
+type LabelHandlers struct {
+	labelService services.LabelService
+	logger       *logrus.Logger
+}
+
+func NewLabelHandlers(labelService services.LabelService, logger *logrus.Logger) *LabelHandlers {
+	return &LabelHandlers{
+		labelService: labelService,
+		logger:       logger,
+	}
+}
+
+// getLabel resolves the repository and label in the path
+func (h *LabelHandlers) getLabel(c *gin.Context, permission models.Permission) (*models.Label, bool) {
+	repo, ok := tenantRepository(c, permission)
+	if !ok {
+		return nil, false
+	}
+	label, err := h.labelService.Get(c.Request.Context(), repo.ID, c.Param("name"))
+	if err != nil {
+		h.labelError(c, err, "Failed to get label")
+		return nil, false
+	}
+	return label, true
+}
+
+func (h *LabelHandlers) labelError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrLabelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Label not found"})
+	case errors.Is(err, services.ErrLabelExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidLabel):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListLabels handles GET /api/v1/repositories/{owner}/{repo}/labels
+func (h *LabelHandlers) ListLabels(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	labels, err := h.labelService.List(c.Request.Context(), repo.ID)
+	if err != nil {
+		h.labelError(c, err, "Failed to list labels")
+		return
+	}
+	c.JSON(http.StatusOK, labels)
+}
+
+// CreateLabel handles POST /api/v1/repositories/{owner}/{repo}/labels
+func (h *LabelHandlers) CreateLabel(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	var req services.CreateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	label, err := h.labelService.Create(c.Request.Context(), repo.ID, req)
+	if err != nil {
+		h.labelError(c, err, "Failed to create label")
+		return
+	}
+	c.JSON(http.StatusCreated, label)
+}
+
+// GetLabel handles GET /api/v1/repositories/{owner}/{repo}/labels/{name}
+func (h *LabelHandlers) GetLabel(c *gin.Context) {
+	label, ok := h.getLabel(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, label)
+}
+
+// UpdateLabel handles PATCH /api/v1/repositories/{owner}/{repo}/labels/{name}
+func (h *LabelHandlers) UpdateLabel(c *gin.Context) {
+	label, ok := h.getLabel(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	var req services.UpdateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	updated, err := h.labelService.Update(c.Request.Context(), label, req)
+	if err != nil {
+		h.labelError(c, err, "Failed to update label")
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteLabel handles DELETE /api/v1/repositories/{owner}/{repo}/labels/{name}
+func (h *LabelHandlers) DeleteLabel(c *gin.Context) {
+	label, ok := h.getLabel(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	if err := h.labelService.Delete(c.Request.Context(), label); err != nil {
+		h.labelError(c, err, "Failed to delete label")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
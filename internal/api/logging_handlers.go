@@ -0,0 +1,92 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/logging"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// LoggingHandlers serves the admin endpoints for the log pipeline and
+// configuration reloads
+type LoggingHandlers struct {
+	logger *logrus.Logger
+}
+
+func NewLoggingHandlers(logger *logrus.Logger) *LoggingHandlers {
+	return &LoggingHandlers{logger: logger}
+}
+
+// pipeline returns the process wide pipeline, writing an error response when
+// the server was started without one
+func (h *LoggingHandlers) pipeline(c *gin.Context) (*logging.Pipeline, bool) {
+	logs := logging.Default()
+	if logs == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Logging pipeline is not configured"})
+		return nil, false
+	}
+	return logs, true
+}
+
+// GetLogging handles GET /api/v1/admin/logging
+func (h *LoggingHandlers) GetLogging(c *gin.Context) {
+	logs, ok := h.pipeline(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, logs.Status())
+}
+
+// ReloadConfig handles POST /api/v1/admin/config/reload. It reads the
+// configuration file and environment again and applies the logging section;
+// other settings still need a restart.
+func (h *LoggingHandlers) ReloadConfig(c *gin.Context) {
+	logs, ok := h.pipeline(c)
+	if !ok {
+		return
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to reload configuration")
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to load configuration", "details": err.Error()})
+		return
+	}
+	if err := logs.Apply(cfg.Logging, cfg.LogLevel); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Invalid logging configuration", "details": err.Error()})
+		return
+	}
+
+	status := logs.Status()
+	adminID, _ := authenticatedUser(c)
+	logs.Audit(h.logger).WithFields(logrus.Fields{
+		"admin_id": adminID,
+		"level":    status.Level,
+		"modules":  status.Modules,
+	}).Info("Admin reloaded configuration")
+	c.JSON(http.StatusOK, gin.H{"logging": status})
+}
+
+// VerifyAuditLog handles POST /api/v1/admin/logging/audit/verify, checking
+// that no audit log line was altered or removed
+func (h *LoggingHandlers) VerifyAuditLog(c *gin.Context) {
+	logs, ok := h.pipeline(c)
+	if !ok {
+		return
+	}
+
+	result, err := logs.VerifyAudit()
+	if err != nil {
+		if errors.Is(err, logging.ErrAuditDisabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to verify audit log")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify audit log"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultCalendarSpan is the calendar range served when to is omitted
+const defaultCalendarSpan = 7 * 24 * time.Hour
+
+// MaintenanceWindowHandlers serves the admin maintenance window, job class
+// policy and calendar endpoints
+type MaintenanceWindowHandlers struct {
+	maintenanceService services.MaintenanceWindowService
+	logger             *logrus.Logger
+}
+
+func NewMaintenanceWindowHandlers(maintenanceService services.MaintenanceWindowService, logger *logrus.Logger) *MaintenanceWindowHandlers {
+	return &MaintenanceWindowHandlers{
+		maintenanceService: maintenanceService,
+		logger:             logger,
+	}
+}
+
+func (h *MaintenanceWindowHandlers) maintenanceError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrMaintenanceWindowNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidMaintenanceWindow),
+		errors.Is(err, services.ErrInvalidJobPolicy),
+		errors.Is(err, services.ErrInvalidCalendarRange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func (h *MaintenanceWindowHandlers) windowID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid maintenance window ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// ListWindows handles GET /api/v1/admin/maintenance/windows
+func (h *MaintenanceWindowHandlers) ListWindows(c *gin.Context) {
+	windows, err := h.maintenanceService.ListWindows(c.Request.Context())
+	if err != nil {
+		h.maintenanceError(c, err, "Failed to list maintenance windows")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"windows": windows})
+}
+
+// CreateWindow handles POST /api/v1/admin/maintenance/windows
+func (h *MaintenanceWindowHandlers) CreateWindow(c *gin.Context) {
+	var req services.MaintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	window, err := h.maintenanceService.CreateWindow(c.Request.Context(), optionalActor(c), req)
+	if err != nil {
+		h.maintenanceError(c, err, "Failed to create maintenance window")
+		return
+	}
+	c.JSON(http.StatusCreated, window)
+}
+
+// GetWindow handles GET /api/v1/admin/maintenance/windows/:id
+func (h *MaintenanceWindowHandlers) GetWindow(c *gin.Context) {
+	id, ok := h.windowID(c)
+	if !ok {
+		return
+	}
+	window, err := h.maintenanceService.GetWindow(c.Request.Context(), id)
+	if err != nil {
+		h.maintenanceError(c, err, "Failed to get maintenance window")
+		return
+	}
+	c.JSON(http.StatusOK, window)
+}
+
+// UpdateWindow handles PATCH /api/v1/admin/maintenance/windows/:id
+func (h *MaintenanceWindowHandlers) UpdateWindow(c *gin.Context) {
+	id, ok := h.windowID(c)
+	if !ok {
+		return
+	}
+	var req services.MaintenanceWindowRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	window, err := h.maintenanceService.UpdateWindow(c.Request.Context(), id, req)
+	if err != nil {
+		h.maintenanceError(c, err, "Failed to update maintenance window")
+		return
+	}
+	c.JSON(http.StatusOK, window)
+}
+
+// DeleteWindow handles DELETE /api/v1/admin/maintenance/windows/:id
+func (h *MaintenanceWindowHandlers) DeleteWindow(c *gin.Context) {
+	id, ok := h.windowID(c)
+	if !ok {
+		return
+	}
+	if err := h.maintenanceService.DeleteWindow(c.Request.Context(), id); err != nil {
+		h.maintenanceError(c, err, "Failed to delete maintenance window")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListPolicies handles GET /api/v1/admin/maintenance/policies
+func (h *MaintenanceWindowHandlers) ListPolicies(c *gin.Context) {
+	policies, err := h.maintenanceService.ListPolicies(c.Request.Context())
+	if err != nil {
+		h.maintenanceError(c, err, "Failed to list job class policies")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// SetPolicy handles PUT /api/v1/admin/maintenance/policies/:class
+func (h *MaintenanceWindowHandlers) SetPolicy(c *gin.Context) {
+	var req struct {
+		Mode models.JobPolicyMode `json:"mode" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	policy, err := h.maintenanceService.SetPolicy(c.Request.Context(), c.Param("class"), req.Mode, optionalActor(c))
+	if err != nil {
+		h.maintenanceError(c, err, "Failed to set job class policy")
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// GetCalendar handles GET /api/v1/admin/maintenance/calendar
+//
+// from and to are RFC 3339 times and default to the next seven days.
+func (h *MaintenanceWindowHandlers) GetCalendar(c *gin.Context) {
+	from := time.Now()
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be an RFC 3339 time"})
+			return
+		}
+		from = t
+	}
+	to := from.Add(defaultCalendarSpan)
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be an RFC 3339 time"})
+			return
+		}
+		to = t
+	}
+	calendar, err := h.maintenanceService.Calendar(c.Request.Context(), from, to)
+	if err != nil {
+		h.maintenanceError(c, err, "Failed to build maintenance calendar")
+		return
+	}
+	c.JSON(http.StatusOK, calendar)
+}
+
+// GetStatus handles GET /api/v1/admin/maintenance/status
+func (h *MaintenanceWindowHandlers) GetStatus(c *gin.Context) {
+	status, err := h.maintenanceService.Status(c.Request.Context())
+	if err != nil {
+		h.maintenanceError(c, err, "Failed to get maintenance status")
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// MalwareHandlers serves the admin review queue of uploads flagged by
+// malware scans
+type MalwareHandlers struct {
+	malwareService services.MalwareScanService
+	releaseService services.ReleaseService
+	lfsService     services.LFSService
+	logger         *logrus.Logger
+}
+
+func NewMalwareHandlers(malwareService services.MalwareScanService, releaseService services.ReleaseService, lfsService services.LFSService, logger *logrus.Logger) *MalwareHandlers {
+	return &MalwareHandlers{
+		malwareService: malwareService,
+		releaseService: releaseService,
+		lfsService:     lfsService,
+		logger:         logger,
+	}
+}
+
+func (h *MalwareHandlers) malwareError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrMalwareDetectionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidMalwareReview):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListDetections handles GET /api/v1/admin/malware/detections. The status
+// query parameter filters them, such as status=quarantined for the review
+// queue.
+func (h *MalwareHandlers) ListDetections(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "30"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 30
+	}
+
+	detections, total, err := h.malwareService.List(c.Request.Context(), c.Query("status"), perPage, (page-1)*perPage)
+	if err != nil {
+		h.malwareError(c, err, "Failed to list malware detections")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"detections":  detections,
+		"total_count": total,
+		"page":        page,
+		"per_page":    perPage,
+	})
+}
+
+// GetDetection handles GET /api/v1/admin/malware/detections/:id
+func (h *MalwareHandlers) GetDetection(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid detection ID"})
+		return
+	}
+	detection, err := h.malwareService.Get(c.Request.Context(), id)
+	if err != nil {
+		h.malwareError(c, err, "Failed to get malware detection")
+		return
+	}
+	c.JSON(http.StatusOK, detection)
+}
+
+// ReviewDetection handles POST /api/v1/admin/malware/detections/:id/review.
+// Released content can be downloaded again; deleted content is removed from
+// storage before the decision is recorded, so it never becomes downloadable.
+func (h *MalwareHandlers) ReviewDetection(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid detection ID"})
+		return
+	}
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Decision string `json:"decision" binding:"required"`
+		Note     string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	if req.Decision == models.MalwareStatusDeleted {
+		detection, err := h.malwareService.Get(c.Request.Context(), id)
+		if err == nil && detection.Status == models.MalwareStatusQuarantined {
+			err = h.removeContent(c.Request.Context(), detection)
+		}
+		if err != nil {
+			h.malwareError(c, err, "Failed to delete quarantined content")
+			return
+		}
+	}
+
+	detection, err := h.malwareService.Review(c.Request.Context(), id, userID, req.Decision, req.Note)
+	if err != nil {
+		h.malwareError(c, err, "Failed to review malware detection")
+		return
+	}
+	c.JSON(http.StatusOK, detection)
+}
+
+// removeContent deletes the stored content of a detection; content that is
+// already gone is not an error
+func (h *MalwareHandlers) removeContent(ctx context.Context, detection *models.MalwareDetection) error {
+	switch detection.ContentKind {
+	case models.MalwareContentReleaseAsset:
+		assetID, err := uuid.Parse(detection.ContentID)
+		if err != nil {
+			return err
+		}
+		if err := h.releaseService.DeleteAsset(ctx, detection.RepositoryID, assetID); err != nil && !errors.Is(err, services.ErrReleaseAssetNotFound) {
+			return err
+		}
+	case models.MalwareContentLFSObject:
+		return h.lfsService.Purge(ctx, detection.ContentID)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// MergeChecklistHandlers serves repository merge checklists and their
+// state on pull requests
+type MergeChecklistHandlers struct {
+	checklistService   services.MergeChecklistService
+	pullRequestService services.PullRequestService
+	logger             *logrus.Logger
+}
+
+func NewMergeChecklistHandlers(checklistService services.MergeChecklistService, pullRequestService services.PullRequestService, logger *logrus.Logger) *MergeChecklistHandlers {
+	return &MergeChecklistHandlers{
+		checklistService:   checklistService,
+		pullRequestService: pullRequestService,
+		logger:             logger,
+	}
+}
+
+func (h *MergeChecklistHandlers) checklistError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrChecklistItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidChecklistItem), errors.Is(err, services.ErrChecklistPullRequestClosed):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrChecklistNotAuthorized):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListMergeChecklistItems handles GET /api/v1/repositories/{owner}/{repo}/merge-checklist
+func (h *MergeChecklistHandlers) ListMergeChecklistItems(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	items, err := h.checklistService.ListItems(c.Request.Context(), repo.ID)
+	if err != nil {
+		h.checklistError(c, err, "Failed to list merge checklist items")
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// CreateMergeChecklistItem handles POST /api/v1/repositories/{owner}/{repo}/merge-checklist
+func (h *MergeChecklistHandlers) CreateMergeChecklistItem(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+
+	var req services.MergeChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := h.checklistService.CreateItem(c.Request.Context(), repo.ID, req)
+	if err != nil {
+		h.checklistError(c, err, "Failed to create merge checklist item")
+		return
+	}
+	c.JSON(http.StatusCreated, item)
+}
+
+// UpdateMergeChecklistItem handles PATCH /api/v1/repositories/{owner}/{repo}/merge-checklist/{item_id}
+func (h *MergeChecklistHandlers) UpdateMergeChecklistItem(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+	itemID, err := uuid.Parse(c.Param("item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	var req services.MergeChecklistItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := h.checklistService.UpdateItem(c.Request.Context(), repo.ID, itemID, req)
+	if err != nil {
+		h.checklistError(c, err, "Failed to update merge checklist item")
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// DeleteMergeChecklistItem handles DELETE /api/v1/repositories/{owner}/{repo}/merge-checklist/{item_id}
+func (h *MergeChecklistHandlers) DeleteMergeChecklistItem(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+	itemID, err := uuid.Parse(c.Param("item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	if err := h.checklistService.DeleteItem(c.Request.Context(), repo.ID, itemID); err != nil {
+		h.checklistError(c, err, "Failed to delete merge checklist item")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetPullRequestChecklist handles GET /api/v1/repositories/{owner}/{repo}/pulls/{number}/checklist
+func (h *MergeChecklistHandlers) GetPullRequestChecklist(c *gin.Context) {
+	pr, ok := tenantPullRequest(c, h.pullRequestService, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	statuses, err := h.checklistService.Evaluate(c.Request.Context(), pr)
+	if err != nil {
+		h.checklistError(c, err, "Failed to get pull request checklist")
+		return
+	}
+	c.JSON(http.StatusOK, statuses)
+}
+
+// GetPullRequestChecklistHistory handles GET /api/v1/repositories/{owner}/{repo}/pulls/{number}/checklist/history
+func (h *MergeChecklistHandlers) GetPullRequestChecklistHistory(c *gin.Context) {
+	pr, ok := tenantPullRequest(c, h.pullRequestService, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	events, err := h.checklistService.History(c.Request.Context(), pr)
+	if err != nil {
+		h.checklistError(c, err, "Failed to get pull request checklist history")
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
+
+// CheckPullRequestChecklistItem handles PUT /api/v1/repositories/{owner}/{repo}/pulls/{number}/checklist/{item_id}
+func (h *MergeChecklistHandlers) CheckPullRequestChecklistItem(c *gin.Context) {
+	h.setChecklistItem(c, true)
+}
+
+// UncheckPullRequestChecklistItem handles DELETE /api/v1/repositories/{owner}/{repo}/pulls/{number}/checklist/{item_id}
+func (h *MergeChecklistHandlers) UncheckPullRequestChecklistItem(c *gin.Context) {
+	h.setChecklistItem(c, false)
+}
+
+func (h *MergeChecklistHandlers) setChecklistItem(c *gin.Context, checked bool) {
+	pr, ok := tenantPullRequest(c, h.pullRequestService, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	itemID, err := uuid.Parse(c.Param("item_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+	actorID, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+
+	if !checked {
+		if err := h.checklistService.Uncheck(c.Request.Context(), pr, itemID, actorID, req.Note); err != nil {
+			h.checklistError(c, err, "Failed to uncheck merge checklist item")
+			return
+		}
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	check, err := h.checklistService.Check(c.Request.Context(), pr, itemID, actorID, req.Note)
+	if err != nil {
+		h.checklistError(c, err, "Failed to check merge checklist item")
+		return
+	}
+	c.JSON(http.StatusOK, check)
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// NotificationHandlers serves the authenticated user's notification inbox
+type NotificationHandlers struct {
+	inboxService services.NotificationInboxService
+	logger       *logrus.Logger
+}
+
+func NewNotificationHandlers(inboxService services.NotificationInboxService, logger *logrus.Logger) *NotificationHandlers {
+	return &NotificationHandlers{
+		inboxService: inboxService,
+		logger:       logger,
+	}
+}
+
+// notificationResponse renders a thread with its subject and repository
+// nested the way API clients expect
+func notificationResponse(n *models.Notification) gin.H {
+	response := gin.H{
+		"id":           n.ID,
+		"reason":       n.Reason,
+		"unread":       n.ReadAt == nil,
+		"updated_at":   n.UpdatedAt,
+		"last_read_at": n.ReadAt,
+		"repository": gin.H{
+			"id":        n.RepositoryID,
+			"full_name": n.Repository,
+		},
+		"subject": gin.H{
+			"id":     n.SubjectID,
+			"type":   n.SubjectType,
+			"number": n.Number,
+			"title":  n.Title,
+			"url":    n.URL,
+		},
+	}
+	if n.Actor != nil {
+		response["actor"] = gin.H{
+			"id":         n.Actor.ID,
+			"username":   n.Actor.Username,
+			"avatar_url": n.Actor.AvatarURL,
+		}
+	}
+	return response
+}
+
+// ListNotifications handles GET /api/v1/notifications. filter=all|unread|participating
+// selects threads; the all and participating flags are accepted as well.
+func (h *NotificationHandlers) ListNotifications(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	filter := services.NotificationFilter{
+		All:           c.Query("all") == "true",
+		Participating: c.Query("participating") == "true",
+	}
+	switch c.Query("filter") {
+	case "", "unread":
+	case "all":
+		filter.All = true
+	case "participating":
+		filter.Participating = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filter must be all, unread or participating"})
+		return
+	}
+	if since := c.Query("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 timestamp"})
+			return
+		}
+		filter.Since = &t
+	}
+	if repoID := c.Query("repository_id"); repoID != "" {
+		id, err := uuid.Parse(repoID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid repository ID"})
+			return
+		}
+		filter.RepositoryID = &id
+	}
+	filter.Page, _ = strconv.Atoi(c.DefaultQuery("page", "1"))
+	filter.PerPage, _ = strconv.Atoi(c.DefaultQuery("per_page", "50"))
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PerPage < 1 || filter.PerPage > 100 {
+		filter.PerPage = 50
+	}
+
+	notifications, total, err := h.inboxService.List(c.Request.Context(), userID, filter)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list notifications")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list notifications"})
+		return
+	}
+	response := make([]gin.H, 0, len(notifications))
+	for _, n := range notifications {
+		response = append(response, notificationResponse(n))
+	}
+	setPaginationHeaders(c, filter.Page, filter.PerPage, total)
+	c.JSON(http.StatusOK, response)
+}
+
+// GetUnreadCount handles GET /api/v1/notifications/unread_count
+func (h *NotificationHandlers) GetUnreadCount(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	count, err := h.inboxService.UnreadCount(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to count notifications")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"unread": count})
+}
+
+// MarkNotificationsAsRead handles PATCH /api/v1/notifications. Without ids
+// every thread last updated at or before last_read_at (default now) is marked read.
+func (h *NotificationHandlers) MarkNotificationsAsRead(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		LastReadAt *time.Time  `json:"last_read_at"`
+		IDs        []uuid.UUID `json:"ids"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+	lastReadAt := time.Now()
+	if req.LastReadAt != nil {
+		lastReadAt = *req.LastReadAt
+	}
+
+	marked, err := h.inboxService.MarkRead(c.Request.Context(), userID, req.IDs, lastReadAt)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to mark notifications as read")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark notifications as read"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications marked as read", "marked": marked})
+}
+
+func (h *NotificationHandlers) thread(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := actor(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return userID, id, true
+}
+
+// MarkNotificationAsRead handles PATCH /api/v1/notifications/:id
+func (h *NotificationHandlers) MarkNotificationAsRead(c *gin.Context) {
+	userID, id, ok := h.thread(c)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	if _, err := h.inboxService.Get(ctx, userID, id); err != nil {
+		h.notificationError(c, err, "Failed to mark notification as read")
+		return
+	}
+	if _, err := h.inboxService.MarkRead(ctx, userID, []uuid.UUID{id}, time.Now()); err != nil {
+		h.notificationError(c, err, "Failed to mark notification as read")
+		return
+	}
+	notification, err := h.inboxService.Get(ctx, userID, id)
+	if err != nil {
+		h.notificationError(c, err, "Failed to mark notification as read")
+		return
+	}
+	c.JSON(http.StatusOK, notificationResponse(notification))
+}
+
+// DeleteNotification handles DELETE /api/v1/notifications/:id, marking the thread done
+func (h *NotificationHandlers) DeleteNotification(c *gin.Context) {
+	userID, id, ok := h.thread(c)
+	if !ok {
+		return
+	}
+	if err := h.inboxService.Delete(c.Request.Context(), userID, id); err != nil {
+		h.notificationError(c, err, "Failed to delete notification")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *NotificationHandlers) notificationError(c *gin.Context, err error, message string) {
+	if errors.Is(err, services.ErrNotificationNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+	h.logger.WithError(err).Error(message)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
@@ -0,0 +1,434 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/middleware"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// OAuthProviderHandlers serves the hub as an OAuth 2.0 and OpenID Connect
+// provider: the application registry, the consent flow and the token,
+// introspection, revocation and userinfo endpoints
+type OAuthProviderHandlers struct {
+	oauthService services.OAuthProviderService
+	logger       *logrus.Logger
+}
+
+func NewOAuthProviderHandlers(oauthService services.OAuthProviderService, logger *logrus.Logger) *OAuthProviderHandlers {
+	return &OAuthProviderHandlers{
+		oauthService: oauthService,
+		logger:       logger,
+	}
+}
+
+// createdApplicationResponse carries the client secret, shown only once
+type createdApplicationResponse struct {
+	*models.OAuthApplication
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// AuthorizeDecision is the user's answer to an authorization request
+type AuthorizeDecision struct {
+	services.OAuthAuthorizeRequest
+	Approve bool `json:"approve"`
+}
+
+// TokenEndpointRequest names the token to introspect or revoke
+type TokenEndpointRequest struct {
+	Token         string `form:"token"`
+	TokenTypeHint string `form:"token_type_hint"`
+}
+
+// appID parses the :app_id path parameter
+func (h *OAuthProviderHandlers) appID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("app_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid application ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *OAuthProviderHandlers) applicationError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrOAuthApplicationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrOAuthRegistrationForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidOAuthApplication):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// protocolError writes an RFC 6749 error response
+func (h *OAuthProviderHandlers) protocolError(c *gin.Context, err error, message string) {
+	for _, code := range []error{
+		services.ErrOAuthInvalidRequest,
+		services.ErrOAuthInvalidClient,
+		services.ErrOAuthInvalidGrant,
+		services.ErrOAuthInvalidScope,
+		services.ErrOAuthUnsupportedGrantType,
+		services.ErrOAuthInvalidRedirectURI,
+		services.ErrOAuthInvalidClientMetadata,
+	} {
+		if !errors.Is(err, code) {
+			continue
+		}
+		status := http.StatusBadRequest
+		if code == services.ErrOAuthInvalidClient {
+			status = http.StatusUnauthorized
+			c.Header("WWW-Authenticate", `Basic realm="hub"`)
+		}
+		c.JSON(status, gin.H{
+			"error":             code.Error(),
+			"error_description": strings.TrimPrefix(err.Error(), code.Error()+": "),
+		})
+		return
+	}
+	h.logger.WithError(err).Error(message)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error", "error_description": message})
+}
+
+// clientCredentials reads client credentials from HTTP Basic authentication
+// or, failing that, the form body
+func clientCredentials(c *gin.Context) (string, string) {
+	if id, secret, ok := c.Request.BasicAuth(); ok {
+		// RFC 6749 form-encodes credentials before Basic encoding them
+		if unescaped, err := url.QueryUnescape(id); err == nil {
+			id = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(secret); err == nil {
+			secret = unescaped
+		}
+		return id, secret
+	}
+	return c.PostForm("client_id"), c.PostForm("client_secret")
+}
+
+// CreateApplication handles POST /api/v1/user/applications
+func (h *OAuthProviderHandlers) CreateApplication(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req services.OAuthApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	app, secret, err := h.oauthService.CreateApplication(c.Request.Context(), userID, c.GetBool("is_admin"), req)
+	if err != nil {
+		h.applicationError(c, err, "Failed to create application")
+		return
+	}
+	c.JSON(http.StatusCreated, createdApplicationResponse{OAuthApplication: app, ClientSecret: secret})
+}
+
+// RegisterClient handles POST /api/v1/oauth/register, RFC 7591 dynamic
+// client registration authenticated with the registering user's token
+func (h *OAuthProviderHandlers) RegisterClient(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var metadata services.OAuthClientMetadata
+	if err := c.ShouldBindJSON(&metadata); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_client_metadata", "error_description": err.Error()})
+		return
+	}
+
+	registration, err := h.oauthService.RegisterClient(c.Request.Context(), userID, c.GetBool("is_admin"), metadata)
+	if errors.Is(err, services.ErrOAuthRegistrationForbidden) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access_denied", "error_description": err.Error()})
+		return
+	}
+	if err != nil {
+		h.protocolError(c, err, "Failed to register client")
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, registration)
+}
+
+// ListApplications handles GET /api/v1/user/applications
+func (h *OAuthProviderHandlers) ListApplications(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	apps, err := h.oauthService.ListApplications(c.Request.Context(), userID)
+	if err != nil {
+		h.applicationError(c, err, "Failed to list applications")
+		return
+	}
+	c.JSON(http.StatusOK, apps)
+}
+
+// GetApplication handles GET /api/v1/user/applications/:app_id
+func (h *OAuthProviderHandlers) GetApplication(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	appID, ok := h.appID(c)
+	if !ok {
+		return
+	}
+	app, err := h.oauthService.GetApplication(c.Request.Context(), userID, appID)
+	if err != nil {
+		h.applicationError(c, err, "Failed to get application")
+		return
+	}
+	c.JSON(http.StatusOK, app)
+}
+
+// UpdateApplication handles PATCH /api/v1/user/applications/:app_id
+func (h *OAuthProviderHandlers) UpdateApplication(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	appID, ok := h.appID(c)
+	if !ok {
+		return
+	}
+	var req services.OAuthApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	app, err := h.oauthService.UpdateApplication(c.Request.Context(), userID, appID, req)
+	if err != nil {
+		h.applicationError(c, err, "Failed to update application")
+		return
+	}
+	c.JSON(http.StatusOK, app)
+}
+
+// ResetClientSecret handles POST /api/v1/user/applications/:app_id/secret
+func (h *OAuthProviderHandlers) ResetClientSecret(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	appID, ok := h.appID(c)
+	if !ok {
+		return
+	}
+	app, secret, err := h.oauthService.ResetClientSecret(c.Request.Context(), userID, appID)
+	if err != nil {
+		h.applicationError(c, err, "Failed to reset client secret")
+		return
+	}
+	c.JSON(http.StatusOK, createdApplicationResponse{OAuthApplication: app, ClientSecret: secret})
+}
+
+// DeleteApplication handles DELETE /api/v1/user/applications/:app_id
+func (h *OAuthProviderHandlers) DeleteApplication(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	appID, ok := h.appID(c)
+	if !ok {
+		return
+	}
+	if err := h.oauthService.DeleteApplication(c.Request.Context(), userID, appID); err != nil {
+		h.applicationError(c, err, "Failed to delete application")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// AdminListApplications handles GET /api/v1/admin/oauth/applications
+func (h *OAuthProviderHandlers) AdminListApplications(c *gin.Context) {
+	apps, err := h.oauthService.AdminListApplications(c.Request.Context())
+	if err != nil {
+		h.applicationError(c, err, "Failed to list applications")
+		return
+	}
+	c.JSON(http.StatusOK, apps)
+}
+
+// AdminUpdateApplication handles PATCH /api/v1/admin/oauth/applications/:app_id
+func (h *OAuthProviderHandlers) AdminUpdateApplication(c *gin.Context) {
+	appID, ok := h.appID(c)
+	if !ok {
+		return
+	}
+	var req services.AdminOAuthApplicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	app, err := h.oauthService.AdminUpdateApplication(c.Request.Context(), appID, req)
+	if err != nil {
+		h.applicationError(c, err, "Failed to update application")
+		return
+	}
+	c.JSON(http.StatusOK, app)
+}
+
+// ListAuthorizations handles GET /api/v1/user/authorizations
+func (h *OAuthProviderHandlers) ListAuthorizations(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	grants, err := h.oauthService.ListAuthorizations(c.Request.Context(), userID)
+	if err != nil {
+		h.applicationError(c, err, "Failed to list authorizations")
+		return
+	}
+	c.JSON(http.StatusOK, grants)
+}
+
+// RevokeAuthorization handles DELETE /api/v1/user/authorizations/:app_id
+func (h *OAuthProviderHandlers) RevokeAuthorization(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	appID, ok := h.appID(c)
+	if !ok {
+		return
+	}
+	if err := h.oauthService.RevokeAuthorization(c.Request.Context(), userID, appID); err != nil {
+		h.applicationError(c, err, "Failed to revoke authorization")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetAuthorization handles GET /api/v1/oauth/authorize
+//
+// The consent screen calls it with the query of the authorization request
+// to learn what the application asks for.
+func (h *OAuthProviderHandlers) GetAuthorization(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req services.OAuthAuthorizeRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	consent, err := h.oauthService.PrepareAuthorization(c.Request.Context(), userID, req)
+	if err != nil {
+		h.protocolError(c, err, "Failed to prepare authorization")
+		return
+	}
+	c.JSON(http.StatusOK, consent)
+}
+
+// Authorize handles POST /api/v1/oauth/authorize
+//
+// The response names the URL to send the user back to, carrying the
+// authorization code or the error for the application.
+func (h *OAuthProviderHandlers) Authorize(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req AuthorizeDecision
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	redirect, err := h.oauthService.Authorize(c.Request.Context(), userID, req.OAuthAuthorizeRequest, req.Approve)
+	if err != nil {
+		h.protocolError(c, err, "Failed to authorize application")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"redirect_uri": redirect})
+}
+
+// Token handles POST /api/v1/oauth/token
+func (h *OAuthProviderHandlers) Token(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	var req services.OAuthTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": err.Error()})
+		return
+	}
+	req.ClientID, req.ClientSecret = clientCredentials(c)
+
+	response, err := h.oauthService.Token(c.Request.Context(), req)
+	if err != nil {
+		h.protocolError(c, err, "Failed to issue token")
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Introspect handles POST /api/v1/oauth/introspect
+func (h *OAuthProviderHandlers) Introspect(c *gin.Context) {
+	var req TokenEndpointRequest
+	if err := c.ShouldBind(&req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "token is required"})
+		return
+	}
+	clientID, clientSecret := clientCredentials(c)
+	result, err := h.oauthService.Introspect(c.Request.Context(), clientID, clientSecret, req.Token)
+	if err != nil {
+		h.protocolError(c, err, "Failed to introspect token")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// Revoke handles POST /api/v1/oauth/revoke
+func (h *OAuthProviderHandlers) Revoke(c *gin.Context) {
+	var req TokenEndpointRequest
+	if err := c.ShouldBind(&req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "token is required"})
+		return
+	}
+	clientID, clientSecret := clientCredentials(c)
+	if err := h.oauthService.Revoke(c.Request.Context(), clientID, clientSecret, req.Token); err != nil {
+		h.protocolError(c, err, "Failed to revoke token")
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// UserInfo handles GET /api/v1/oauth/userinfo, which only accepts OAuth
+// access tokens granted the openid scope
+func (h *OAuthProviderHandlers) UserInfo(c *gin.Context) {
+	value, exists := c.Get(middleware.OAuthTokenKey)
+	if !exists {
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_token"})
+		return
+	}
+	token := value.(*models.OAuthAccessToken)
+	if !token.HasScope(models.OAuthScopeOpenID) {
+		c.Header("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient_scope"})
+		return
+	}
+	c.JSON(http.StatusOK, h.oauthService.UserInfo(token, &token.User))
+}
+
+// JWKS handles GET /api/v1/oauth/jwks
+func (h *OAuthProviderHandlers) JWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, h.oauthService.JWKS())
+}
+
+// Discovery handles GET /.well-known/openid-configuration
+func (h *OAuthProviderHandlers) Discovery(c *gin.Context) {
+	c.JSON(http.StatusOK, h.oauthService.Discovery())
+}
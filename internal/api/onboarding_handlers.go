@@ -0,0 +1,127 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// OnboardingHandlers serves the onboarding checklists of users and
+// organizations and generates their sample repositories
+type OnboardingHandlers struct {
+	onboardingService services.OnboardingService
+	db                *gorm.DB
+	logger            *logrus.Logger
+}
+
+func NewOnboardingHandlers(onboardingService services.OnboardingService, db *gorm.DB, logger *logrus.Logger) *OnboardingHandlers {
+	return &OnboardingHandlers{
+		onboardingService: onboardingService,
+		db:                db,
+		logger:            logger,
+	}
+}
+
+// organization resolves the organization of the request; its onboarding is
+// managed by organization owners and admins
+func (h *OnboardingHandlers) organization(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	userID, ok := actor(c)
+	if !ok {
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	var org models.Organization
+	if err := h.db.WithContext(c.Request.Context()).Where("name = ?", c.Param("org")).First(&org).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	var member models.OrganizationMember
+	err := h.db.WithContext(c.Request.Context()).Where("organization_id = ? AND user_id = ?", org.ID, userID).First(&member).Error
+	if err != nil || (member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only organization owners and admins can manage onboarding"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return org.ID, userID, true
+}
+
+func (h *OnboardingHandlers) progress(c *gin.Context, ownerType models.OwnerType, ownerID uuid.UUID) {
+	progress, err := h.onboardingService.Progress(c.Request.Context(), ownerType, ownerID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get onboarding progress")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get onboarding progress"})
+		return
+	}
+	c.JSON(http.StatusOK, progress)
+}
+
+func (h *OnboardingHandlers) generateSample(c *gin.Context, ownerType models.OwnerType, ownerID, actorID uuid.UUID) {
+	repo, err := h.onboardingService.GenerateSampleRepository(c.Request.Context(), ownerType, ownerID, actorID)
+	if err != nil {
+		if errors.Is(err, services.ErrSampleRepositoryExists) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to generate sample repository")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate sample repository"})
+		return
+	}
+	c.JSON(http.StatusCreated, repo)
+}
+
+func (h *OnboardingHandlers) dismiss(c *gin.Context, ownerType models.OwnerType, ownerID uuid.UUID) {
+	if err := h.onboardingService.Dismiss(c.Request.Context(), ownerType, ownerID); err != nil {
+		h.logger.WithError(err).Error("Failed to dismiss onboarding")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dismiss onboarding"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetUserOnboarding handles GET /api/v1/user/onboarding
+func (h *OnboardingHandlers) GetUserOnboarding(c *gin.Context) {
+	if userID, ok := actor(c); ok {
+		h.progress(c, models.OwnerTypeUser, userID)
+	}
+}
+
+// GenerateUserSampleRepository handles POST /api/v1/user/onboarding/sample-repository
+func (h *OnboardingHandlers) GenerateUserSampleRepository(c *gin.Context) {
+	if userID, ok := actor(c); ok {
+		h.generateSample(c, models.OwnerTypeUser, userID, userID)
+	}
+}
+
+// DismissUserOnboarding handles POST /api/v1/user/onboarding/dismiss
+func (h *OnboardingHandlers) DismissUserOnboarding(c *gin.Context) {
+	if userID, ok := actor(c); ok {
+		h.dismiss(c, models.OwnerTypeUser, userID)
+	}
+}
+
+// GetOrganizationOnboarding handles GET /api/v1/organizations/:org/onboarding
+func (h *OnboardingHandlers) GetOrganizationOnboarding(c *gin.Context) {
+	if orgID, _, ok := h.organization(c); ok {
+		h.progress(c, models.OwnerTypeOrganization, orgID)
+	}
+}
+
+// GenerateOrganizationSampleRepository handles POST /api/v1/organizations/:org/onboarding/sample-repository
+func (h *OnboardingHandlers) GenerateOrganizationSampleRepository(c *gin.Context) {
+	if orgID, userID, ok := h.organization(c); ok {
+		h.generateSample(c, models.OwnerTypeOrganization, orgID, userID)
+	}
+}
+
+// DismissOrganizationOnboarding handles POST /api/v1/organizations/:org/onboarding/dismiss
+func (h *OnboardingHandlers) DismissOrganizationOnboarding(c *gin.Context) {
+	if orgID, _, ok := h.organization(c); ok {
+		h.dismiss(c, models.OwnerTypeOrganization, orgID)
+	}
+}
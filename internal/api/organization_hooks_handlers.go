@@ -0,0 +1,432 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/audit"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// OrganizationHooksHandlers serves the webhooks of organizations, which
+// receive membership and team changes
+type OrganizationHooksHandlers struct {
+	db                     *gorm.DB
+	webhookDeliveryService *services.WebhookDeliveryService
+	logger                 *logrus.Logger
+}
+
+// NewOrganizationHooksHandlers creates a new organization hooks handlers instance
+func NewOrganizationHooksHandlers(db *gorm.DB, webhookDeliveryService *services.WebhookDeliveryService, logger *logrus.Logger) *OrganizationHooksHandlers {
+	return &OrganizationHooksHandlers{
+		db:                     db,
+		webhookDeliveryService: webhookDeliveryService,
+		logger:                 logger,
+	}
+}
+
+// organization resolves the organization of the request; its webhooks can
+// only be managed by organization owners and admins
+func (h *OrganizationHooksHandlers) organization(c *gin.Context) (*models.Organization, bool) {
+	userID, ok := actor(c)
+	if !ok {
+		return nil, false
+	}
+
+	var org models.Organization
+	if err := h.db.WithContext(c.Request.Context()).Where("name = ?", c.Param("org")).First(&org).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return nil, false
+	}
+
+	var member models.OrganizationMember
+	err := h.db.WithContext(c.Request.Context()).Where("organization_id = ? AND user_id = ?", org.ID, userID).First(&member).Error
+	if err != nil || (member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only organization owners and admins can manage webhooks"})
+		return nil, false
+	}
+	return &org, true
+}
+
+// hook resolves the organization and webhook of the request, answering 404
+// when the webhook belongs elsewhere
+func (h *OrganizationHooksHandlers) hook(c *gin.Context) (*models.Organization, *models.Webhook, bool) {
+	hookID, err := uuid.Parse(c.Param("hook_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hook ID"})
+		return nil, nil, false
+	}
+	org, ok := h.organization(c)
+	if !ok {
+		return nil, nil, false
+	}
+	webhook, err := h.webhookDeliveryService.GetWebhook(c.Request.Context(), hookID)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		} else {
+			h.logger.WithError(err).Error("Failed to get webhook")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook"})
+		}
+		return nil, nil, false
+	}
+	if !webhook.BelongsToOrganization(org.ID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return nil, nil, false
+	}
+	return org, webhook, true
+}
+
+// validEvents checks the events a webhook subscribes to, answering 422 for
+// unknown ones
+func validEvents(c *gin.Context, events []string) bool {
+	for _, event := range events {
+		if !services.ValidOrganizationWebhookEvent(event) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Unknown webhook event: " + event})
+			return false
+		}
+	}
+	return true
+}
+
+func organizationWebhook(org *models.Organization, webhook *models.Webhook) Webhook {
+	contentType := "json"
+	if webhook.ContentType != "application/json" {
+		contentType = "form"
+	}
+	insecureSSL := "0"
+	if webhook.InsecureSSL {
+		insecureSSL = "1"
+	}
+	return Webhook{
+		ID:   int(webhook.ID.ID()),
+		Name: webhook.Name,
+		Config: map[string]interface{}{
+			"url":          webhook.URL,
+			"content_type": contentType,
+			"insecure_ssl": insecureSSL,
+		},
+		Events:          webhook.GetEventsSlice(),
+		Active:          webhook.Active,
+		PayloadVersion:  webhook.PayloadVersion,
+		PayloadTemplate: webhook.PayloadTemplate,
+		CreatedAt:       webhook.CreatedAt,
+		UpdatedAt:       webhook.UpdatedAt,
+		PingURL:         "/api/v1/organizations/" + org.Name + "/hooks/" + webhook.ID.String() + "/pings",
+	}
+}
+
+// webhookConfig reads the url, content type, insecure SSL and secret
+// settings present in a webhook config into updates
+func webhookConfig(config map[string]interface{}, updates map[string]interface{}) {
+	if url, ok := config["url"].(string); ok && url != "" {
+		updates["url"] = url
+	}
+	if contentType, ok := config["content_type"].(string); ok {
+		if contentType == "form" {
+			updates["content_type"] = "application/x-www-form-urlencoded"
+		} else {
+			updates["content_type"] = "application/json"
+		}
+	}
+	if insecureSSL, ok := config["insecure_ssl"].(string); ok {
+		updates["insecure_ssl"] = insecureSSL == "1"
+	}
+	if secret, ok := config["secret"].(string); ok {
+		updates["secret"] = secret
+	}
+}
+
+// ListHooks handles GET /api/v1/organizations/{org}/hooks
+func (h *OrganizationHooksHandlers) ListHooks(c *gin.Context) {
+	org, ok := h.organization(c)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.webhookDeliveryService.ListOrganizationWebhooks(c.Request.Context(), org.ID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list organization webhooks")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhooks"})
+		return
+	}
+
+	result := make([]Webhook, len(webhooks))
+	for i := range webhooks {
+		result[i] = organizationWebhook(org, &webhooks[i])
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// CreateHook handles POST /api/v1/organizations/{org}/hooks
+//
+// Hooks subscribe to organization, team and membership events, or to a
+// single action of one as "team.created"; they default to all three.
+func (h *OrganizationHooksHandlers) CreateHook(c *gin.Context) {
+	org, ok := h.organization(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name            string                 `json:"name"`
+		Config          map[string]interface{} `json:"config"`
+		Events          []string               `json:"events"`
+		Active          *bool                  `json:"active,omitempty"`
+		PayloadVersion  *string                `json:"payload_version,omitempty"`
+		PayloadTemplate *string                `json:"payload_template,omitempty"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	format, ok := newWebhookPayloadFormat(c, req.PayloadVersion, req.PayloadTemplate)
+	if !ok {
+		return
+	}
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name is required"})
+		return
+	}
+	config := map[string]interface{}{}
+	webhookConfig(req.Config, config)
+	url, _ := config["url"].(string)
+	if url == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL is required in config"})
+		return
+	}
+	if len(req.Events) == 0 {
+		req.Events = []string{services.WebhookEventOrganization, services.WebhookEventTeam, services.WebhookEventMembership}
+	}
+	if !validEvents(c, req.Events) {
+		return
+	}
+
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+	contentType, ok := config["content_type"].(string)
+	if !ok {
+		contentType = "application/json"
+	}
+	insecureSSL, _ := config["insecure_ssl"].(bool)
+	secret, _ := config["secret"].(string)
+
+	webhook, err := h.webhookDeliveryService.CreateOrganizationWebhook(c.Request.Context(), org.ID, req.Name, url, secret,
+		req.Events, contentType, insecureSSL, active)
+	if err == nil && len(format) > 0 {
+		webhook, err = h.webhookDeliveryService.UpdateWebhook(c.Request.Context(), webhook.ID, format)
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create organization webhook")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+	auditWebhook(c, audit.ActionWebhookCreate, webhook)
+	c.JSON(http.StatusCreated, organizationWebhook(org, webhook))
+}
+
+// GetHook handles GET /api/v1/organizations/{org}/hooks/{hook_id}
+func (h *OrganizationHooksHandlers) GetHook(c *gin.Context) {
+	if org, webhook, ok := h.hook(c); ok {
+		c.JSON(http.StatusOK, organizationWebhook(org, webhook))
+	}
+}
+
+// UpdateHook handles PATCH /api/v1/organizations/{org}/hooks/{hook_id}
+func (h *OrganizationHooksHandlers) UpdateHook(c *gin.Context) {
+	org, webhook, ok := h.hook(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Config          map[string]interface{} `json:"config,omitempty"`
+		Events          []string               `json:"events,omitempty"`
+		Active          *bool                  `json:"active,omitempty"`
+		PayloadVersion  *string                `json:"payload_version,omitempty"`
+		PayloadTemplate *string                `json:"payload_template,omitempty"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	updates := make(map[string]interface{})
+	webhookConfig(req.Config, updates)
+	if req.Events != nil {
+		if !validEvents(c, req.Events) {
+			return
+		}
+		var events models.Webhook
+		events.SetEventsSlice(req.Events)
+		updates["events"] = events.Events
+	}
+	if req.Active != nil {
+		updates["active"] = *req.Active
+	}
+	webhookPayloadFormat(req.PayloadVersion, req.PayloadTemplate, updates)
+
+	webhook, err := h.webhookDeliveryService.UpdateWebhook(c.Request.Context(), webhook.ID, updates)
+	if errors.Is(err, services.ErrInvalidWebhookPayloadFormat) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to update organization webhook")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+		return
+	}
+	auditWebhook(c, audit.ActionWebhookUpdate, webhook)
+	c.JSON(http.StatusOK, organizationWebhook(org, webhook))
+}
+
+// DeleteHook handles DELETE /api/v1/organizations/{org}/hooks/{hook_id}
+func (h *OrganizationHooksHandlers) DeleteHook(c *gin.Context) {
+	_, webhook, ok := h.hook(c)
+	if !ok {
+		return
+	}
+	if err := h.webhookDeliveryService.DeleteWebhook(c.Request.Context(), webhook.ID); err != nil {
+		h.logger.WithError(err).Error("Failed to delete organization webhook")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	auditWebhook(c, audit.ActionWebhookDelete, webhook)
+	c.Status(http.StatusNoContent)
+}
+
+// PingHook handles POST /api/v1/organizations/{org}/hooks/{hook_id}/pings
+func (h *OrganizationHooksHandlers) PingHook(c *gin.Context) {
+	_, webhook, ok := h.hook(c)
+	if !ok {
+		return
+	}
+	if err := h.webhookDeliveryService.PingWebhook(c.Request.Context(), webhook.ID); err != nil {
+		h.logger.WithError(err).Error("Failed to ping organization webhook")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ping webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook ping sent successfully"})
+}
+
+// ValidatePayloadTemplate handles POST /api/v1/organizations/{org}/hooks/templates/validate
+func (h *OrganizationHooksHandlers) ValidatePayloadTemplate(c *gin.Context) {
+	org, ok := h.organization(c)
+	if !ok {
+		return
+	}
+	renderWebhookPayload(c, h.webhookDeliveryService, h.logger, models.Webhook{OrganizationID: &org.ID, ContentType: "application/json"})
+}
+
+// RenderPayload handles POST /api/v1/organizations/{org}/hooks/{hook_id}/render
+func (h *OrganizationHooksHandlers) RenderPayload(c *gin.Context) {
+	if _, webhook, ok := h.hook(c); ok {
+		renderWebhookPayload(c, h.webhookDeliveryService, h.logger, *webhook)
+	}
+}
+
+// ListDeliveries handles GET /api/v1/organizations/{org}/hooks/{hook_id}/deliveries
+func (h *OrganizationHooksHandlers) ListDeliveries(c *gin.Context) {
+	_, webhook, ok := h.hook(c)
+	if !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "30"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 30
+	}
+
+	deliveries, err := h.webhookDeliveryService.GetDeliveries(c.Request.Context(), webhook.ID, perPage, (page-1)*perPage)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list webhook deliveries")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhook deliveries"})
+		return
+	}
+	total, err := h.webhookDeliveryService.CountDeliveries(c.Request.Context(), webhook.ID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to count webhook deliveries")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhook deliveries"})
+		return
+	}
+
+	result := make([]WebhookDelivery, len(deliveries))
+	for i := range deliveries {
+		result[i] = webhookDelivery(&deliveries[i], false)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries":  result,
+		"total_count": total,
+		"page":        page,
+		"per_page":    perPage,
+	})
+}
+
+// GetDelivery handles GET /api/v1/organizations/{org}/hooks/{hook_id}/deliveries/{delivery_id}
+func (h *OrganizationHooksHandlers) GetDelivery(c *gin.Context) {
+	h.delivery(c, false)
+}
+
+// RedeliverDelivery handles POST /api/v1/organizations/{org}/hooks/{hook_id}/deliveries/{delivery_id}/attempts
+func (h *OrganizationHooksHandlers) RedeliverDelivery(c *gin.Context) {
+	h.delivery(c, true)
+}
+
+func (h *OrganizationHooksHandlers) delivery(c *gin.Context, redeliver bool) {
+	_, webhook, ok := h.hook(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := uuid.Parse(c.Param("delivery_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	status := http.StatusOK
+	var delivery *models.WebhookDelivery
+	if redeliver {
+		status = http.StatusAccepted
+		delivery, err = h.webhookDeliveryService.Redeliver(c.Request.Context(), webhook.ID, deliveryID)
+	} else {
+		delivery, err = h.webhookDeliveryService.GetDelivery(c.Request.Context(), webhook.ID, deliveryID)
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		} else {
+			h.logger.WithError(err).Error("Failed to get webhook delivery")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook delivery"})
+		}
+		return
+	}
+	c.JSON(status, webhookDelivery(delivery, true))
+}
+
+// IntegrationHealth handles GET /api/v1/organizations/{org}/integrations/health
+func (h *OrganizationHooksHandlers) IntegrationHealth(c *gin.Context) {
+	org, ok := h.organization(c)
+	if !ok {
+		return
+	}
+	health, err := h.webhookDeliveryService.IntegrationHealth(c.Request.Context(), org.ID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get integration health")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get integration health"})
+		return
+	}
+	c.JSON(http.StatusOK, health)
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// OrganizationSSOHandlers serves organization single sign-on: the login
+// flow members go through and the configuration owners manage
+type OrganizationSSOHandlers struct {
+	ssoService services.OrganizationSSOService
+	logger     *logrus.Logger
+}
+
+func NewOrganizationSSOHandlers(ssoService services.OrganizationSSOService, logger *logrus.Logger) *OrganizationSSOHandlers {
+	return &OrganizationSSOHandlers{ssoService: ssoService, logger: logger}
+}
+
+func (h *OrganizationSSOHandlers) ssoError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrSSONotConfigured):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSSOForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSSOConfig):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSSOLoginFailed):
+		h.logger.WithError(err).WithField("organization", c.Param("org")).Warn("Organization SSO login failed")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// Login handles POST /api/v1/organizations/:org/sso/login and returns the
+// identity provider URL to send the browser to. Calling it signed in links
+// the identity to the caller's account.
+func (h *OrganizationSSOHandlers) Login(c *gin.Context) {
+	var userID *uuid.UUID
+	if t, ok := tenant.FromContext(c.Request.Context()); ok {
+		userID = t.UserID
+	}
+	redirect, err := h.ssoService.BeginLogin(c.Request.Context(), c.Param("org"), userID)
+	if err != nil {
+		h.ssoError(c, err, "Failed to start single sign-on")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"redirect_url": redirect})
+}
+
+// OIDCCallback handles GET /api/v1/organizations/:org/sso/oidc/callback
+func (h *OrganizationSSOHandlers) OIDCCallback(c *gin.Context) {
+	if providerError := c.Query("error"); providerError != "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Identity provider refused the login", "details": providerError})
+		return
+	}
+	result, err := h.ssoService.CompleteOIDC(c.Request.Context(), c.Param("org"), c.Query("state"), c.Query("code"))
+	if err != nil {
+		h.ssoError(c, err, "Failed to complete single sign-on")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// SAMLACS handles POST /api/v1/organizations/:org/sso/saml/acs, the
+// assertion consumer service identity providers post responses to
+func (h *OrganizationSSOHandlers) SAMLACS(c *gin.Context) {
+	response := c.PostForm("SAMLResponse")
+	if response == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "SAMLResponse is required"})
+		return
+	}
+	result, err := h.ssoService.CompleteSAML(c.Request.Context(), c.Param("org"), response, c.PostForm("RelayState"))
+	if err != nil {
+		h.ssoError(c, err, "Failed to complete single sign-on")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// SAMLMetadata handles GET /api/v1/organizations/:org/sso/saml/metadata
+func (h *OrganizationSSOHandlers) SAMLMetadata(c *gin.Context) {
+	metadata, err := h.ssoService.Metadata(c.Request.Context(), c.Param("org"))
+	if err != nil {
+		h.ssoError(c, err, "Failed to generate SAML metadata")
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", []byte(metadata))
+}
+
+// GetConfig handles GET /api/v1/organizations/:org/sso/config
+func (h *OrganizationSSOHandlers) GetConfig(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	cfg, err := h.ssoService.GetConfig(c.Request.Context(), c.Param("org"), userID)
+	if err != nil {
+		h.ssoError(c, err, "Failed to get single sign-on configuration")
+		return
+	}
+	c.JSON(http.StatusOK, cfg)
+}
+
+// UpdateConfig handles PUT /api/v1/organizations/:org/sso/config
+func (h *OrganizationSSOHandlers) UpdateConfig(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		models.OrganizationSSOConfig
+		// ClientSecret is write-only; omitting it keeps the stored secret
+		ClientSecret string `json:"client_secret"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	cfg := req.OrganizationSSOConfig
+	cfg.ClientSecret = req.ClientSecret
+
+	saved, err := h.ssoService.SetConfig(c.Request.Context(), c.Param("org"), userID, &cfg)
+	if err != nil {
+		h.ssoError(c, err, "Failed to update single sign-on configuration")
+		return
+	}
+	c.JSON(http.StatusOK, saved)
+}
+
+// DeleteConfig handles DELETE /api/v1/organizations/:org/sso/config
+func (h *OrganizationSSOHandlers) DeleteConfig(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	if err := h.ssoService.DeleteConfig(c.Request.Context(), c.Param("org"), userID); err != nil {
+		h.ssoError(c, err, "Failed to delete single sign-on configuration")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListIdentities handles GET /api/v1/organizations/:org/sso/identities
+func (h *OrganizationSSOHandlers) ListIdentities(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	identities, err := h.ssoService.ListIdentities(c.Request.Context(), c.Param("org"), userID)
+	if err != nil {
+		h.ssoError(c, err, "Failed to list single sign-on identities")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"identities": identities})
+}
@@ -0,0 +1,470 @@
+package api
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/a5c-ai/hub/internal/tenant"
+)
+
+// PackageHandlers serves the package registry of organizations: the npm,
+// Maven and generic package protocols under /api/v1/orgs/{org}/packages,
+// and the API managing packages under /api/v1/organizations/{org}/packages
+type PackageHandlers struct {
+	packageService services.PackageService
+	cfg            config.PackageStorage
+	baseURL        string
+	logger         *logrus.Logger
+}
+
+// NewPackageHandlers creates a new PackageHandlers. baseURL is the external
+// URL of the hub, which npm tarball URLs start with.
+func NewPackageHandlers(packageService services.PackageService, cfg config.PackageStorage, baseURL string, logger *logrus.Logger) *PackageHandlers {
+	return &PackageHandlers{
+		packageService: packageService,
+		cfg:            cfg,
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		logger:         logger,
+	}
+}
+
+// newPackageBackend creates the storage backend for package files from
+// cfg. The filesystem backend defaults to a packages directory under
+// repoBasePath.
+func newPackageBackend(cfg config.PackageStorage, repoBasePath string) (storage.Backend, error) {
+	var stCfg storage.Config
+	stCfg.Backend = cfg.Backend
+	stCfg.Azure = storage.AzureConfig{
+		AccountName:   cfg.Azure.AccountName,
+		AccountKey:    cfg.Azure.AccountKey,
+		ContainerName: cfg.Azure.ContainerName,
+		EndpointURL:   cfg.Azure.EndpointURL,
+	}
+	stCfg.S3 = storage.S3Config{
+		Region:          cfg.S3.Region,
+		Bucket:          cfg.S3.Bucket,
+		AccessKeyID:     cfg.S3.AccessKeyID,
+		SecretAccessKey: cfg.S3.SecretAccessKey,
+		EndpointURL:     cfg.S3.EndpointURL,
+		UseSSL:          cfg.S3.UseSSL,
+	}
+	stCfg.Filesystem.BasePath = cfg.BasePath
+	if stCfg.Filesystem.BasePath == "" {
+		stCfg.Filesystem.BasePath = filepath.Join(repoBasePath, "packages")
+	}
+	return storage.NewBackend(stCfg)
+}
+
+// packageRequest resolves the organization of a package request and the
+// user making it, if any
+func (h *PackageHandlers) packageRequest(c *gin.Context) (*models.Organization, *uuid.UUID, bool) {
+	if !h.cfg.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The package registry is disabled"})
+		return nil, nil, false
+	}
+	t, ok := tenant.FromContext(c.Request.Context())
+	if !ok || !t.MatchesOrganization(c.Param("org")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return nil, nil, false
+	}
+	return t.Organization, t.UserID, true
+}
+
+// publisher returns the user publishing to a package registry, asking
+// clients without credentials to authenticate
+func (h *PackageHandlers) publisher(c *gin.Context, userID *uuid.UUID) (uuid.UUID, bool) {
+	if userID == nil {
+		packageChallenge(c)
+		return uuid.Nil, false
+	}
+	return *userID, true
+}
+
+// packageChallenge asks the client to authenticate; Maven only sends
+// credentials after a challenge
+func packageChallenge(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Basic realm="Package Registry"`)
+	c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+}
+
+// packageError answers an error of the package service
+func (h *PackageHandlers) packageError(c *gin.Context, err error, userID *uuid.UUID) {
+	switch {
+	case errors.Is(err, services.ErrPackageForbidden) && userID == nil:
+		packageChallenge(c)
+	case errors.Is(err, services.ErrPackageForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPackageNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Package not found"})
+	case errors.Is(err, services.ErrPackageInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPackageConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPackageTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("Package registry request failed")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Package registry request failed"})
+	}
+}
+
+// serveFile streams a package file, or only its headers for HEAD requests
+func (h *PackageHandlers) serveFile(c *gin.Context, org *models.Organization, userID *uuid.UUID, packageType, name, version, fileName string) {
+	if c.Request.Method == http.MethodHead {
+		file, err := h.packageService.StatFile(c.Request.Context(), org, userID, packageType, name, version, fileName)
+		if err != nil {
+			h.packageError(c, err, userID)
+			return
+		}
+		c.Header("Content-Length", strconv.FormatInt(file.Size, 10))
+		c.Header("ETag", `"`+file.SHA256+`"`)
+		c.Status(http.StatusOK)
+		return
+	}
+
+	file, content, err := h.packageService.OpenFile(c.Request.Context(), org, userID, packageType, name, version, fileName)
+	if err != nil {
+		h.packageError(c, err, userID)
+		return
+	}
+	defer content.Close()
+	c.DataFromReader(http.StatusOK, file.Size, "application/octet-stream", content, map[string]string{
+		"ETag": `"` + file.SHA256 + `"`,
+	})
+}
+
+// Npm serves the npm registry protocol under /api/v1/orgs/{org}/packages/npm:
+// GET and PUT /{name} read and publish a package, and GET
+// /{name}/-/{tarball} downloads a tarball. Scoped names keep their @scope/.
+func (h *PackageHandlers) Npm(c *gin.Context) {
+	org, userID, ok := h.packageRequest(c)
+	if !ok {
+		return
+	}
+	p := strings.Trim(c.Param("path"), "/")
+	if name, tarball, ok := strings.Cut(p, "/-/"); ok {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Tarballs are published with their package"})
+			return
+		}
+		h.serveFile(c, org, userID, models.PackageTypeNpm, name, "", tarball)
+		return
+	}
+
+	switch c.Request.Method {
+	case http.MethodGet:
+		tarballBaseURL := h.baseURL + "/api/v1/orgs/" + org.Name + "/packages/npm"
+		packument, err := h.packageService.NpmPackument(c.Request.Context(), org, userID, p, tarballBaseURL)
+		if err != nil {
+			h.packageError(c, err, userID)
+			return
+		}
+		c.JSON(http.StatusOK, packument)
+	case http.MethodPut:
+		actorID, ok := h.publisher(c, userID)
+		if !ok {
+			return
+		}
+		// Tarballs are base64 encoded in the document
+		limit := h.cfg.MaxFileSizeMB << 20
+		var body io.Reader = c.Request.Body
+		if limit > 0 {
+			body = io.LimitReader(body, limit*4/3+1<<20)
+		}
+		document, err := io.ReadAll(body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read the package"})
+			return
+		}
+		if _, err := h.packageService.PublishNpm(c.Request.Context(), org, actorID, p, document); err != nil {
+			h.packageError(c, err, userID)
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"ok": true, "id": p})
+	default:
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Method not allowed"})
+	}
+}
+
+// mavenChecksum computes a Maven checksum of content
+func mavenChecksum(algorithm string, content []byte) string {
+	var h hash.Hash
+	switch algorithm {
+	case "sha1":
+		h = sha1.New()
+	case "md5":
+		h = md5.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return ""
+	}
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Maven serves a Maven repository under /api/v1/orgs/{org}/packages/maven.
+// Artifact metadata is generated from the published versions, and
+// checksums from the stored files, so clients uploading them is accepted
+// without storing them.
+func (h *PackageHandlers) Maven(c *gin.Context) {
+	org, userID, ok := h.packageRequest(c)
+	if !ok {
+		return
+	}
+	mavenPath, err := services.ParseMavenPath(c.Param("path"))
+	if err != nil {
+		h.packageError(c, err, userID)
+		return
+	}
+	target, algorithm, checksum := services.SplitMavenChecksum(mavenPath.File)
+
+	switch c.Request.Method {
+	case http.MethodPut:
+		actorID, ok := h.publisher(c, userID)
+		if !ok {
+			return
+		}
+		if checksum || mavenPath.Version == "" {
+			io.Copy(io.Discard, c.Request.Body)
+			c.Status(http.StatusCreated)
+			return
+		}
+		if _, err := h.packageService.PublishFile(c.Request.Context(), org, actorID, models.PackageTypeMaven,
+			mavenPath.Name, mavenPath.Version, mavenPath.File, c.Request.Body); err != nil {
+			h.packageError(c, err, userID)
+			return
+		}
+		c.Status(http.StatusCreated)
+	case http.MethodGet, http.MethodHead:
+		if mavenPath.Version == "" {
+			metadata, err := h.packageService.MavenMetadata(c.Request.Context(), org, userID, mavenPath.Name)
+			if err != nil {
+				h.packageError(c, err, userID)
+				return
+			}
+			if checksum {
+				metadata = []byte(mavenChecksum(algorithm, metadata))
+				if len(metadata) == 0 {
+					c.JSON(http.StatusNotFound, gin.H{"error": "Checksum not available"})
+					return
+				}
+			}
+			c.Data(http.StatusOK, "text/xml", metadata)
+			return
+		}
+		if !checksum {
+			h.serveFile(c, org, userID, models.PackageTypeMaven, mavenPath.Name, mavenPath.Version, mavenPath.File)
+			return
+		}
+		file, err := h.packageService.StatFile(c.Request.Context(), org, userID, models.PackageTypeMaven, mavenPath.Name, mavenPath.Version, target)
+		if err != nil {
+			h.packageError(c, err, userID)
+			return
+		}
+		sum := services.MavenChecksum(file, algorithm)
+		if sum == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Checksum not available"})
+			return
+		}
+		c.Data(http.StatusOK, "text/plain", []byte(sum))
+	default:
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Method not allowed"})
+	}
+}
+
+// Generic serves generic file packages under
+// /api/v1/orgs/{org}/packages/generic/{name}/{version}/{file}: PUT
+// publishes a file and GET downloads it
+func (h *PackageHandlers) Generic(c *gin.Context) {
+	org, userID, ok := h.packageRequest(c)
+	if !ok {
+		return
+	}
+	parts := strings.Split(strings.Trim(c.Param("path"), "/"), "/")
+	if len(parts) != 3 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Generic package paths are {name}/{version}/{file}"})
+		return
+	}
+	name, version, fileName := parts[0], parts[1], parts[2]
+
+	switch c.Request.Method {
+	case http.MethodPut:
+		actorID, ok := h.publisher(c, userID)
+		if !ok {
+			return
+		}
+		file, err := h.packageService.PublishFile(c.Request.Context(), org, actorID, models.PackageTypeGeneric, name, version, fileName, c.Request.Body)
+		if err != nil {
+			h.packageError(c, err, userID)
+			return
+		}
+		c.JSON(http.StatusCreated, file)
+	case http.MethodGet, http.MethodHead:
+		h.serveFile(c, org, userID, models.PackageTypeGeneric, name, version, fileName)
+	default:
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"error": "Method not allowed"})
+	}
+}
+
+// managedPackage parses the package ID of a management request
+func (h *PackageHandlers) managedPackage(c *gin.Context) (*models.Organization, uuid.UUID, uuid.UUID, bool) {
+	org, userID, ok := h.packageRequest(c)
+	if !ok {
+		return nil, uuid.Nil, uuid.Nil, false
+	}
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, uuid.Nil, uuid.Nil, false
+	}
+	id, err := uuid.Parse(c.Param("package_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid package ID"})
+		return nil, uuid.Nil, uuid.Nil, false
+	}
+	return org, *userID, id, true
+}
+
+// ListPackages handles GET /api/v1/organizations/{org}/packages
+func (h *PackageHandlers) ListPackages(c *gin.Context) {
+	org, userID, ok := h.packageRequest(c)
+	if !ok {
+		return
+	}
+	packages, err := h.packageService.ListPackages(c.Request.Context(), org, userID, c.Query("type"))
+	if err != nil {
+		h.packageError(c, err, userID)
+		return
+	}
+	c.JSON(http.StatusOK, packages)
+}
+
+// GetPackage handles GET /api/v1/organizations/{org}/packages/{package_id}
+func (h *PackageHandlers) GetPackage(c *gin.Context) {
+	org, userID, id, ok := h.managedPackage(c)
+	if !ok {
+		return
+	}
+	pkg, err := h.packageService.GetPackage(c.Request.Context(), org, &userID, id)
+	if err != nil {
+		h.packageError(c, err, &userID)
+		return
+	}
+	c.JSON(http.StatusOK, pkg)
+}
+
+// UpdatePackage handles PATCH /api/v1/organizations/{org}/packages/{package_id}
+func (h *PackageHandlers) UpdatePackage(c *gin.Context) {
+	org, userID, id, ok := h.managedPackage(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Visibility models.Visibility `json:"visibility" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	pkg, err := h.packageService.SetVisibility(c.Request.Context(), org, userID, id, req.Visibility)
+	if err != nil {
+		h.packageError(c, err, &userID)
+		return
+	}
+	c.JSON(http.StatusOK, pkg)
+}
+
+// DeletePackage handles DELETE /api/v1/organizations/{org}/packages/{package_id}
+func (h *PackageHandlers) DeletePackage(c *gin.Context) {
+	org, userID, id, ok := h.managedPackage(c)
+	if !ok {
+		return
+	}
+	if err := h.packageService.DeletePackage(c.Request.Context(), org, userID, id); err != nil {
+		h.packageError(c, err, &userID)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeletePackageVersion handles DELETE /api/v1/organizations/{org}/packages/{package_id}/versions/{version}
+func (h *PackageHandlers) DeletePackageVersion(c *gin.Context) {
+	org, userID, id, ok := h.managedPackage(c)
+	if !ok {
+		return
+	}
+	if err := h.packageService.DeleteVersion(c.Request.Context(), org, userID, id, c.Param("version")); err != nil {
+		h.packageError(c, err, &userID)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetRetentionPolicy handles GET /api/v1/organizations/{org}/packages/retention-policy
+func (h *PackageHandlers) GetRetentionPolicy(c *gin.Context) {
+	org, userID, ok := h.packageRequest(c)
+	if !ok {
+		return
+	}
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	policy, err := h.packageService.GetRetentionPolicy(c.Request.Context(), org, *userID)
+	if err != nil {
+		h.packageError(c, err, userID)
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// UpdateRetentionPolicy handles PUT /api/v1/organizations/{org}/packages/retention-policy
+func (h *PackageHandlers) UpdateRetentionPolicy(c *gin.Context) {
+	org, userID, ok := h.packageRequest(c)
+	if !ok {
+		return
+	}
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	var req models.PackageRetentionPolicy
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	policy, err := h.packageService.SetRetentionPolicy(c.Request.Context(), org, *userID, &req)
+	if err != nil {
+		h.packageError(c, err, userID)
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// ApplyRetention handles POST /api/v1/admin/packages/retention
+func (h *PackageHandlers) ApplyRetention(c *gin.Context) {
+	result, err := h.packageService.ApplyRetention(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to apply package retention policies")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply package retention policies"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// PathProtectionHandlers serves sensitive path review rules
+type PathProtectionHandlers struct {
+	pathProtectionService services.PathProtectionService
+	pullRequestService    services.PullRequestService
+	logger                *logrus.Logger
+}
+
+func NewPathProtectionHandlers(pathProtectionService services.PathProtectionService, pullRequestService services.PullRequestService, logger *logrus.Logger) *PathProtectionHandlers {
+	return &PathProtectionHandlers{
+		pathProtectionService: pathProtectionService,
+		pullRequestService:    pullRequestService,
+		logger:                logger,
+	}
+}
+
+func (h *PathProtectionHandlers) ruleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrPathRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidPathRule):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListPathProtectionRules handles GET /api/v1/repositories/{owner}/{repo}/path-protection
+func (h *PathProtectionHandlers) ListPathProtectionRules(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	rules, err := h.pathProtectionService.ListRules(c.Request.Context(), repo.ID)
+	if err != nil {
+		h.ruleError(c, err, "Failed to list path protection rules")
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreatePathProtectionRule handles POST /api/v1/repositories/{owner}/{repo}/path-protection
+func (h *PathProtectionHandlers) CreatePathProtectionRule(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+
+	var req services.PathProtectionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.pathProtectionService.CreateRule(c.Request.Context(), repo.ID, req)
+	if err != nil {
+		h.ruleError(c, err, "Failed to create path protection rule")
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdatePathProtectionRule handles PATCH /api/v1/repositories/{owner}/{repo}/path-protection/{rule_id}
+func (h *PathProtectionHandlers) UpdatePathProtectionRule(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+	ruleID, err := uuid.Parse(c.Param("rule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	var req services.PathProtectionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule, err := h.pathProtectionService.UpdateRule(c.Request.Context(), repo.ID, ruleID, req)
+	if err != nil {
+		h.ruleError(c, err, "Failed to update path protection rule")
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeletePathProtectionRule handles DELETE /api/v1/repositories/{owner}/{repo}/path-protection/{rule_id}
+func (h *PathProtectionHandlers) DeletePathProtectionRule(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+	ruleID, err := uuid.Parse(c.Param("rule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	if err := h.pathProtectionService.DeleteRule(c.Request.Context(), repo.ID, ruleID); err != nil {
+		h.ruleError(c, err, "Failed to delete path protection rule")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// GetReviewRequirements handles GET /api/v1/repositories/{owner}/{repo}/pulls/{number}/review-requirements
+func (h *PathProtectionHandlers) GetReviewRequirements(c *gin.Context) {
+	if _, ok := tenantRepository(c, models.PermissionRead); !ok {
+		return
+	}
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pull request number"})
+		return
+	}
+
+	pr, err := h.pullRequestService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"), number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pull request not found"})
+		return
+	}
+
+	requirements, err := h.pathProtectionService.EvaluatePullRequest(c.Request.Context(), pr)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to evaluate review requirements")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to evaluate review requirements"})
+		return
+	}
+	c.JSON(http.StatusOK, requirements)
+}
+
+// SimulateBranchProtection handles POST /api/v1/repositories/{owner}/{repo}/branches/{branch}/protection/simulate
+// It reports the checks and reviews a pull request changing the given
+// files, or the files changed on head, would need before it could merge.
+func (h *PathProtectionHandlers) SimulateBranchProtection(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	var req services.ProtectionSimulationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	if req.Head == "" && req.Files == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either head or files is required"})
+		return
+	}
+
+	simulation, err := h.pathProtectionService.SimulateProtection(c.Request.Context(), repo, c.Param("branch"), req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to simulate branch protection")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to simulate branch protection"})
+		return
+	}
+	c.JSON(http.StatusOK, simulation)
+}
+
+// ValidateCodeOwners handles POST /api/v1/repositories/{owner}/{repo}/codeowners/validate
+// The body may carry CODEOWNERS content to check before committing it;
+// otherwise the file on ref, by default the default branch, is checked.
+func (h *PathProtectionHandlers) ValidateCodeOwners(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Content *string `json:"content"`
+		Ref     string  `json:"ref"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+
+	validation, err := h.pathProtectionService.ValidateCodeOwners(c.Request.Context(), repo, req.Ref, req.Content)
+	if errors.Is(err, services.ErrCodeOwnersNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error(), "searched": services.CodeOwnersPaths})
+		return
+	}
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to validate CODEOWNERS")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate CODEOWNERS"})
+		return
+	}
+	c.JSON(http.StatusOK, validation)
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -103,10 +104,8 @@ func (h *PullRequestHandlers) CreatePullRequest(c *gin.Context) {
 		return
 	}
 
-	// Get user ID from context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+	userID, ok := actor(c)
+	if !ok {
 		return
 	}
 
@@ -116,8 +115,15 @@ func (h *PullRequestHandlers) CreatePullRequest(c *gin.Context) {
 		return
 	}
 
-	pr, err := h.service.Create(c.Request.Context(), repoID, userID.(uuid.UUID), req)
-	if err != nil {
+	pr, err := h.service.Create(c.Request.Context(), repoID, userID, req)
+	switch {
+	case err == nil:
+	case errors.Is(err, services.ErrPullRequestTitleInvalid),
+		errors.Is(err, services.ErrPullRequestRefsInvalid),
+		errors.Is(err, services.ErrPullRequestMetadataInvalid):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	default:
 		h.logger.WithError(err).Error("Failed to create pull request")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pull request"})
 		return
@@ -153,6 +159,10 @@ func (h *PullRequestHandlers) UpdatePullRequest(c *gin.Context) {
 	}
 
 	updatedPR, err := h.service.Update(c.Request.Context(), pr.ID, req)
+	if errors.Is(err, services.ErrPullRequestTitleInvalid) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to update pull request")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update pull request"})
@@ -162,6 +172,40 @@ func (h *PullRequestHandlers) UpdatePullRequest(c *gin.Context) {
 	c.JSON(http.StatusOK, updatedPR)
 }
 
+// ListPullRequestFiles handles GET /api/v1/repositories/:owner/:repo/pulls/:number/files
+func (h *PullRequestHandlers) ListPullRequestFiles(c *gin.Context) {
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pull request number"})
+		return
+	}
+
+	pr, err := h.service.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"), number)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get pull request")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pull request not found"})
+		return
+	}
+
+	opts := services.PullRequestFilesOptions{Page: 1, PerPage: 30}
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+		opts.Page = page
+	}
+	if perPage, err := strconv.Atoi(c.Query("per_page")); err == nil && perPage > 0 && perPage <= 100 {
+		opts.PerPage = perPage
+	}
+
+	files, err := h.service.ListFiles(c.Request.Context(), pr, opts)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list pull request files")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list pull request files"})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(files.TotalFiles, 10))
+	c.JSON(http.StatusOK, files)
+}
+
 // MergePullRequest handles POST /api/v1/repositories/:owner/:repo/pulls/:number/merge
 func (h *PullRequestHandlers) MergePullRequest(c *gin.Context) {
 	owner := c.Param("owner")
@@ -188,6 +232,19 @@ func (h *PullRequestHandlers) MergePullRequest(c *gin.Context) {
 	}
 
 	err = h.service.Merge(c.Request.Context(), pr.ID, req)
+	if errors.Is(err, services.ErrInvalidMergeMethod) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var blocked *services.MergeBlockedError
+	if errors.As(err, &blocked) {
+		c.JSON(http.StatusMethodNotAllowed, gin.H{
+			"error":        "Pull request is not mergeable",
+			"reasons":      blocked.Requirements.Reasons,
+			"requirements": blocked.Requirements,
+		})
+		return
+	}
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to merge pull request")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge pull request"})
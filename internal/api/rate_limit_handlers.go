@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/middleware"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitHandlers reports callers their rate limits
+type RateLimitHandlers struct {
+	limiter services.RateLimitService
+}
+
+func NewRateLimitHandlers(limiter services.RateLimitService) *RateLimitHandlers {
+	return &RateLimitHandlers{limiter: limiter}
+}
+
+// GetRateLimit handles GET /api/v1/rate_limit. Like GitHub's, it does not
+// count against any limit.
+func (h *RateLimitHandlers) GetRateLimit(c *gin.Context) {
+	if !h.limiter.Enabled() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rate limiting is not enabled"})
+		return
+	}
+
+	resources := gin.H{}
+	var core *services.RateLimitStatus
+	for _, status := range h.limiter.Status(middleware.RateLimitCaller(c)) {
+		resources[status.Resource] = status
+		if status.Resource == services.RateLimitCore {
+			core = &status
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"resources": resources, "rate": core})
+}
@@ -0,0 +1,532 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/a5c-ai/hub/internal/tenant"
+)
+
+// registryRouteKey holds the parsed /v2 path on the gin context
+const registryRouteKey = "registry_route"
+
+// registryNamePattern is the OCI image name grammar; images also need at
+// least an owner and a repository component
+var registryNamePattern = regexp.MustCompile(`^[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*)+$`)
+
+// registryRoute is a request path of the OCI distribution API
+type registryRoute struct {
+	// Kind is "base", "tags", "manifests", "blobs" or "uploads"
+	Kind string
+	Name string
+	// Reference is the manifest tag or digest, the blob digest or the
+	// upload ID
+	Reference string
+}
+
+// parseRegistryPath splits a path below /v2 into the image name and the
+// endpoint. Names contain slashes, so endpoints are found from the end.
+func parseRegistryPath(p string) (registryRoute, bool) {
+	p = strings.TrimPrefix(p, "/")
+	if p == "" {
+		return registryRoute{Kind: "base"}, true
+	}
+	if name, ok := strings.CutSuffix(p, "/tags/list"); ok {
+		return registryRoute{Kind: "tags", Name: name}, true
+	}
+	if i := strings.LastIndex(p, "/blobs/uploads"); i > 0 {
+		rest := strings.TrimPrefix(p[i+len("/blobs/uploads"):], "/")
+		if !strings.Contains(rest, "/") {
+			return registryRoute{Kind: "uploads", Name: p[:i], Reference: rest}, true
+		}
+	}
+	for _, kind := range []string{"manifests", "blobs"} {
+		if i := strings.LastIndex(p, "/"+kind+"/"); i > 0 {
+			rest := p[i+len(kind)+2:]
+			if rest != "" && !strings.Contains(rest, "/") {
+				return registryRoute{Kind: kind, Name: p[:i], Reference: rest}, true
+			}
+		}
+	}
+	return registryRoute{}, false
+}
+
+// RegistryHandlers serves the OCI distribution API under /v2 for docker and
+// other OCI clients. Image names start with the owner and name of a
+// repository, such as acme/api/worker; pulling needs read and pushing
+// write access to that repository.
+type RegistryHandlers struct {
+	registryService   services.RegistryService
+	repositoryService services.RepositoryService
+	cfg               config.RegistryStorage
+	logger            *logrus.Logger
+}
+
+// NewRegistryHandlers creates a new RegistryHandlers
+func NewRegistryHandlers(registryService services.RegistryService, repositoryService services.RepositoryService, cfg config.RegistryStorage, logger *logrus.Logger) *RegistryHandlers {
+	return &RegistryHandlers{
+		registryService:   registryService,
+		repositoryService: repositoryService,
+		cfg:               cfg,
+		logger:            logger,
+	}
+}
+
+// newRegistryBackend creates the storage backend for container images from
+// cfg. The filesystem backend defaults to a registry directory under
+// repoBasePath.
+func newRegistryBackend(cfg config.RegistryStorage, repoBasePath string) (storage.Backend, error) {
+	var stCfg storage.Config
+	stCfg.Backend = cfg.Backend
+	stCfg.Azure = storage.AzureConfig{
+		AccountName:   cfg.Azure.AccountName,
+		AccountKey:    cfg.Azure.AccountKey,
+		ContainerName: cfg.Azure.ContainerName,
+		EndpointURL:   cfg.Azure.EndpointURL,
+	}
+	stCfg.S3 = storage.S3Config{
+		Region:          cfg.S3.Region,
+		Bucket:          cfg.S3.Bucket,
+		AccessKeyID:     cfg.S3.AccessKeyID,
+		SecretAccessKey: cfg.S3.SecretAccessKey,
+		EndpointURL:     cfg.S3.EndpointURL,
+		UseSSL:          cfg.S3.UseSSL,
+	}
+	stCfg.Filesystem.BasePath = cfg.BasePath
+	if stCfg.Filesystem.BasePath == "" {
+		stCfg.Filesystem.BasePath = filepath.Join(repoBasePath, "registry")
+	}
+	return storage.NewBackend(stCfg)
+}
+
+// registryError writes an error in the OCI distribution error format
+func registryError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, gin.H{"errors": []gin.H{{"code": code, "message": message}}})
+}
+
+// registryChallenge asks the client to authenticate; docker only sends
+// credentials after a challenge
+func registryChallenge(c *gin.Context) {
+	c.Header("WWW-Authenticate", `Basic realm="Container Registry"`)
+	registryError(c, http.StatusUnauthorized, "UNAUTHORIZED", "authentication required")
+}
+
+// RegistryMiddleware parses the /v2 path and exposes the owner and
+// repository of the image as route parameters, so TenantMiddleware, which
+// must run after it, resolves the repository permission
+func (h *RegistryHandlers) RegistryMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Docker-Distribution-API-Version", "registry/2.0")
+		if !h.cfg.Enabled {
+			registryError(c, http.StatusNotFound, "UNSUPPORTED", "the container registry is disabled")
+			return
+		}
+		route, ok := parseRegistryPath(c.Param("path"))
+		if !ok {
+			registryError(c, http.StatusNotFound, "UNSUPPORTED", "unknown registry endpoint")
+			return
+		}
+		if route.Kind != "base" {
+			if !registryNamePattern.MatchString(route.Name) {
+				registryError(c, http.StatusBadRequest, "NAME_INVALID", "image names must be lowercase and start with a repository owner and name")
+				return
+			}
+			parts := strings.SplitN(route.Name, "/", 3)
+			c.Params = append(c.Params, gin.Param{Key: "owner", Value: parts[0]}, gin.Param{Key: "repo", Value: parts[1]})
+		}
+		c.Set(registryRouteKey, route)
+		c.Next()
+	}
+}
+
+// registryRepository returns the repository of the requested image after
+// checking the tenant may pull from it, or with write push to it
+func (h *RegistryHandlers) registryRepository(c *gin.Context, write bool) (*models.Repository, bool) {
+	t, _ := tenant.FromContext(c.Request.Context())
+	if t == nil || t.Repository == nil {
+		if t == nil || !t.IsAuthenticated() {
+			registryChallenge(c)
+		} else {
+			registryError(c, http.StatusNotFound, "NAME_UNKNOWN", "repository not found")
+		}
+		return nil, false
+	}
+	switch gitAccessStatus(c, t, t.Repository, write) {
+	case 0:
+		return t.Repository, true
+	case http.StatusUnauthorized:
+		registryChallenge(c)
+	case http.StatusForbidden:
+		registryError(c, http.StatusForbidden, "DENIED", "requested access to the resource is denied")
+	default:
+		registryError(c, http.StatusNotFound, "NAME_UNKNOWN", "repository not found")
+	}
+	return nil, false
+}
+
+// registryStatus maps RegistryService errors to OCI error codes
+func (h *RegistryHandlers) registryStatus(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, services.ErrRegistryNameUnknown):
+		registryError(c, http.StatusNotFound, "NAME_UNKNOWN", err.Error())
+	case errors.Is(err, services.ErrRegistryBlobUnknown):
+		registryError(c, http.StatusNotFound, "BLOB_UNKNOWN", err.Error())
+	case errors.Is(err, services.ErrRegistryUploadUnknown):
+		registryError(c, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", err.Error())
+	case errors.Is(err, services.ErrRegistryUploadInvalid):
+		registryError(c, http.StatusRequestedRangeNotSatisfiable, "BLOB_UPLOAD_INVALID", err.Error())
+	case errors.Is(err, services.ErrRegistryBlobTooLarge):
+		registryError(c, http.StatusRequestEntityTooLarge, "SIZE_INVALID", err.Error())
+	case errors.Is(err, services.ErrRegistryDigestInvalid):
+		registryError(c, http.StatusBadRequest, "DIGEST_INVALID", err.Error())
+	case errors.Is(err, services.ErrRegistryManifestUnknown):
+		registryError(c, http.StatusNotFound, "MANIFEST_UNKNOWN", err.Error())
+	case errors.Is(err, services.ErrRegistryManifestInvalid):
+		registryError(c, http.StatusBadRequest, "MANIFEST_INVALID", err.Error())
+	case errors.Is(err, services.ErrRegistryManifestBlobUnknown):
+		registryError(c, http.StatusBadRequest, "MANIFEST_BLOB_UNKNOWN", err.Error())
+	default:
+		h.logger.WithError(err).Errorf("Failed to %s", action)
+		registryError(c, http.StatusInternalServerError, "UNKNOWN", "Failed to "+action)
+	}
+}
+
+// Serve handles every /v2 request, dispatching on the parsed path and method
+func (h *RegistryHandlers) Serve(c *gin.Context) {
+	route := c.MustGet(registryRouteKey).(registryRoute)
+	switch route.Kind + " " + c.Request.Method {
+	case "base GET", "base HEAD":
+		// Answering anonymous clients with a challenge makes docker log in
+		if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.IsAuthenticated() {
+			registryChallenge(c)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{})
+	case "tags GET":
+		h.listTags(c, route)
+	case "manifests GET", "manifests HEAD":
+		h.getManifest(c, route)
+	case "manifests PUT":
+		h.putManifest(c, route)
+	case "manifests DELETE":
+		h.deleteManifest(c, route)
+	case "blobs GET", "blobs HEAD":
+		h.getBlob(c, route)
+	case "uploads POST":
+		h.startUpload(c, route)
+	case "uploads GET":
+		h.uploadStatus(c, route)
+	case "uploads PATCH":
+		h.appendUpload(c, route)
+	case "uploads PUT":
+		h.completeUpload(c, route)
+	case "uploads DELETE":
+		h.cancelUpload(c, route)
+	default:
+		registryError(c, http.StatusMethodNotAllowed, "UNSUPPORTED", "the operation is unsupported")
+	}
+}
+
+// listTags handles GET /v2/{name}/tags/list
+func (h *RegistryHandlers) listTags(c *gin.Context, route registryRoute) {
+	repo, ok := h.registryRepository(c, false)
+	if !ok {
+		return
+	}
+	n := 0
+	if value := c.Query("n"); value != "" {
+		var err error
+		if n, err = strconv.Atoi(value); err != nil || n < 0 {
+			registryError(c, http.StatusBadRequest, "PAGINATION_NUMBER_INVALID", "n must be a positive number")
+			return
+		}
+	}
+	tags, err := h.registryService.ListTags(c.Request.Context(), repo, route.Name, n, c.Query("last"))
+	if err != nil {
+		h.registryStatus(c, err, "list tags")
+		return
+	}
+	if n > 0 && len(tags) == n {
+		next := url.Values{"n": {strconv.Itoa(n)}, "last": {tags[len(tags)-1]}}
+		c.Header("Link", fmt.Sprintf(`</v2/%s/tags/list?%s>; rel="next"`, route.Name, next.Encode()))
+	}
+	c.JSON(http.StatusOK, gin.H{"name": route.Name, "tags": tags})
+}
+
+// getManifest handles GET and HEAD /v2/{name}/manifests/{reference}
+func (h *RegistryHandlers) getManifest(c *gin.Context, route registryRoute) {
+	repo, ok := h.registryRepository(c, false)
+	if !ok {
+		return
+	}
+	manifest, content, err := h.registryService.GetManifest(c.Request.Context(), repo, route.Name, route.Reference)
+	if err != nil {
+		h.registryStatus(c, err, "get manifest")
+		return
+	}
+	c.Header("Docker-Content-Digest", manifest.Digest)
+	if c.Request.Method == http.MethodHead {
+		c.Header("Content-Type", manifest.MediaType)
+		c.Header("Content-Length", strconv.FormatInt(manifest.Size, 10))
+		c.Status(http.StatusOK)
+		return
+	}
+	c.Data(http.StatusOK, manifest.MediaType, content)
+}
+
+// putManifest handles PUT /v2/{name}/manifests/{reference}
+func (h *RegistryHandlers) putManifest(c *gin.Context, route registryRoute) {
+	repo, ok := h.registryRepository(c, true)
+	if !ok {
+		return
+	}
+	content, err := io.ReadAll(io.LimitReader(c.Request.Body, services.MaxManifestSize+1))
+	if err != nil {
+		registryError(c, http.StatusBadRequest, "MANIFEST_INVALID", "failed to read manifest")
+		return
+	}
+	if len(content) > services.MaxManifestSize {
+		registryError(c, http.StatusRequestEntityTooLarge, "SIZE_INVALID", "manifest is too large")
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+
+	manifest, err := h.registryService.PutManifest(c.Request.Context(), repo, route.Name, route.Reference, mediaType, content)
+	if err != nil {
+		h.registryStatus(c, err, "store manifest")
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/v2/%s/manifests/%s", route.Name, manifest.Digest))
+	c.Header("Docker-Content-Digest", manifest.Digest)
+	c.Status(http.StatusCreated)
+}
+
+// deleteManifest handles DELETE /v2/{name}/manifests/{reference}
+func (h *RegistryHandlers) deleteManifest(c *gin.Context, route registryRoute) {
+	repo, ok := h.registryRepository(c, true)
+	if !ok {
+		return
+	}
+	if err := h.registryService.DeleteManifest(c.Request.Context(), repo, route.Name, route.Reference); err != nil {
+		h.registryStatus(c, err, "delete manifest")
+		return
+	}
+	c.Status(http.StatusAccepted)
+}
+
+// getBlob handles GET and HEAD /v2/{name}/blobs/{digest}
+func (h *RegistryHandlers) getBlob(c *gin.Context, route registryRoute) {
+	repo, ok := h.registryRepository(c, false)
+	if !ok {
+		return
+	}
+	if c.Request.Method == http.MethodHead {
+		blob, err := h.registryService.StatBlob(c.Request.Context(), repo, route.Name, route.Reference)
+		if err != nil {
+			h.registryStatus(c, err, "get blob")
+			return
+		}
+		c.Header("Docker-Content-Digest", blob.Digest)
+		c.Header("Content-Type", "application/octet-stream")
+		c.Header("Content-Length", strconv.FormatInt(blob.Size, 10))
+		c.Status(http.StatusOK)
+		return
+	}
+
+	blob, content, err := h.registryService.OpenBlob(c.Request.Context(), repo, route.Name, route.Reference)
+	if err != nil {
+		h.registryStatus(c, err, "get blob")
+		return
+	}
+	defer content.Close()
+	c.DataFromReader(http.StatusOK, blob.Size, "application/octet-stream", content, map[string]string{
+		"Docker-Content-Digest": blob.Digest,
+	})
+}
+
+// uploadHeaders tells the client where to continue an upload and how much
+// of it was received
+func uploadHeaders(c *gin.Context, route registryRoute, upload *models.ContainerUpload) {
+	end := upload.Size
+	if end > 0 {
+		end--
+	}
+	c.Header("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", route.Name, upload.ID))
+	c.Header("Range", fmt.Sprintf("0-%d", end))
+	c.Header("Docker-Upload-UUID", upload.ID.String())
+}
+
+// startUpload handles POST /v2/{name}/blobs/uploads/. With a digest the
+// request body is the whole blob; otherwise an upload is opened for chunks.
+// Cross-repository mounts are not supported, so mount requests open an
+// upload too.
+func (h *RegistryHandlers) startUpload(c *gin.Context, route registryRoute) {
+	if route.Reference != "" {
+		registryError(c, http.StatusMethodNotAllowed, "UNSUPPORTED", "the operation is unsupported")
+		return
+	}
+	repo, ok := h.registryRepository(c, true)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	upload, err := h.registryService.StartUpload(ctx, repo, route.Name)
+	if err != nil {
+		h.registryStatus(c, err, "start blob upload")
+		return
+	}
+
+	digest := c.Query("digest")
+	if digest == "" {
+		uploadHeaders(c, route, upload)
+		c.Status(http.StatusAccepted)
+		return
+	}
+	blob, err := h.registryService.CompleteUpload(ctx, repo, route.Name, upload.ID, digest, c.Request.Body)
+	if err != nil {
+		h.registryService.CancelUpload(ctx, repo, route.Name, upload.ID)
+		h.registryStatus(c, err, "upload blob")
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/v2/%s/blobs/%s", route.Name, blob.Digest))
+	c.Header("Docker-Content-Digest", blob.Digest)
+	c.Status(http.StatusCreated)
+}
+
+// upload resolves the upload of the request
+func (h *RegistryHandlers) upload(c *gin.Context, route registryRoute) (*models.Repository, uuid.UUID, bool) {
+	repo, ok := h.registryRepository(c, true)
+	if !ok {
+		return nil, uuid.Nil, false
+	}
+	id, err := uuid.Parse(route.Reference)
+	if err != nil {
+		registryError(c, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", services.ErrRegistryUploadUnknown.Error())
+		return nil, uuid.Nil, false
+	}
+	return repo, id, true
+}
+
+// uploadStatus handles GET /v2/{name}/blobs/uploads/{id}
+func (h *RegistryHandlers) uploadStatus(c *gin.Context, route registryRoute) {
+	repo, id, ok := h.upload(c, route)
+	if !ok {
+		return
+	}
+	upload, err := h.registryService.GetUpload(c.Request.Context(), repo, route.Name, id)
+	if err != nil {
+		h.registryStatus(c, err, "get blob upload")
+		return
+	}
+	uploadHeaders(c, route, upload)
+	c.Status(http.StatusNoContent)
+}
+
+// appendUpload handles PATCH /v2/{name}/blobs/uploads/{id}. A
+// Content-Range of start-end must start where the upload left off.
+func (h *RegistryHandlers) appendUpload(c *gin.Context, route registryRoute) {
+	repo, id, ok := h.upload(c, route)
+	if !ok {
+		return
+	}
+	offset := int64(-1)
+	if value := c.GetHeader("Content-Range"); value != "" {
+		start, _, _ := strings.Cut(strings.TrimPrefix(value, "bytes="), "-")
+		n, err := strconv.ParseInt(start, 10, 64)
+		if err != nil || n < 0 {
+			registryError(c, http.StatusRequestedRangeNotSatisfiable, "BLOB_UPLOAD_INVALID", "invalid Content-Range")
+			return
+		}
+		offset = n
+	}
+	upload, err := h.registryService.AppendUpload(c.Request.Context(), repo, route.Name, id, offset, c.Request.Body)
+	if err != nil {
+		h.registryStatus(c, err, "upload blob chunk")
+		return
+	}
+	uploadHeaders(c, route, upload)
+	c.Status(http.StatusAccepted)
+}
+
+// completeUpload handles PUT /v2/{name}/blobs/uploads/{id}?digest=...
+func (h *RegistryHandlers) completeUpload(c *gin.Context, route registryRoute) {
+	repo, id, ok := h.upload(c, route)
+	if !ok {
+		return
+	}
+	blob, err := h.registryService.CompleteUpload(c.Request.Context(), repo, route.Name, id, c.Query("digest"), c.Request.Body)
+	if err != nil {
+		h.registryStatus(c, err, "complete blob upload")
+		return
+	}
+	c.Header("Location", fmt.Sprintf("/v2/%s/blobs/%s", route.Name, blob.Digest))
+	c.Header("Docker-Content-Digest", blob.Digest)
+	c.Status(http.StatusCreated)
+}
+
+// cancelUpload handles DELETE /v2/{name}/blobs/uploads/{id}
+func (h *RegistryHandlers) cancelUpload(c *gin.Context, route registryRoute) {
+	repo, id, ok := h.upload(c, route)
+	if !ok {
+		return
+	}
+	if err := h.registryService.CancelUpload(c.Request.Context(), repo, route.Name, id); err != nil {
+		h.registryStatus(c, err, "cancel blob upload")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListImages handles GET /api/v1/repositories/{owner}/{repo}/packages/container
+func (h *RegistryHandlers) ListImages(c *gin.Context) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return
+	}
+	if repo.Visibility != models.VisibilityPublic {
+		if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionRead) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+			return
+		}
+	}
+
+	images, err := h.registryService.ListImages(c.Request.Context(), repo)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list container images")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list container images"})
+		return
+	}
+	c.JSON(http.StatusOK, images)
+}
+
+// CollectGarbage handles POST /api/v1/admin/registry/gc
+func (h *RegistryHandlers) CollectGarbage(c *gin.Context) {
+	result, err := h.registryService.CollectGarbage(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to collect container registry garbage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to collect container registry garbage"})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
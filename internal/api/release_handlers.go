@@ -0,0 +1,400 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// newReleaseBackend creates the storage backend holding release assets
+func newReleaseBackend(cfg config.ReleaseStorage, repoBasePath string) (storage.Backend, error) {
+	var stCfg storage.Config
+	stCfg.Backend = cfg.Backend
+	stCfg.Azure = storage.AzureConfig{
+		AccountName:   cfg.Azure.AccountName,
+		AccountKey:    cfg.Azure.AccountKey,
+		ContainerName: cfg.Azure.ContainerName,
+		EndpointURL:   cfg.Azure.EndpointURL,
+	}
+	stCfg.S3 = storage.S3Config{
+		Region:          cfg.S3.Region,
+		Bucket:          cfg.S3.Bucket,
+		AccessKeyID:     cfg.S3.AccessKeyID,
+		SecretAccessKey: cfg.S3.SecretAccessKey,
+		EndpointURL:     cfg.S3.EndpointURL,
+		UseSSL:          cfg.S3.UseSSL,
+	}
+	stCfg.Filesystem.BasePath = cfg.BasePath
+	if stCfg.Filesystem.BasePath == "" {
+		stCfg.Filesystem.BasePath = filepath.Join(repoBasePath, "releases")
+	}
+	return storage.NewBackend(stCfg)
+}
+
+// ReleaseHandlers serves releases and their assets. Published releases are
+// readable by anyone who can read the repository; drafts and changes need
+// write access.
+type ReleaseHandlers struct {
+	releaseService services.ReleaseService
+	logger         *logrus.Logger
+}
+
+func NewReleaseHandlers(releaseService services.ReleaseService, logger *logrus.Logger) *ReleaseHandlers {
+	return &ReleaseHandlers{
+		releaseService: releaseService,
+		logger:         logger,
+	}
+}
+
+// writableRepository resolves the repository and requires write access to it
+func (h *ReleaseHandlers) writableRepository(c *gin.Context) (*models.Repository, bool) {
+	repo, ok := readableRepository(c)
+	if !ok {
+		return nil, false
+	}
+	if !h.can(c, models.PermissionWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient repository permissions"})
+		return nil, false
+	}
+	return repo, true
+}
+
+func (h *ReleaseHandlers) can(c *gin.Context, permission models.Permission) bool {
+	t, ok := tenant.FromContext(c.Request.Context())
+	return ok && t.HasPermission(permission)
+}
+
+func (h *ReleaseHandlers) paramID(c *gin.Context, name, label string) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param(name))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + label})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *ReleaseHandlers) releaseError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrReleaseNotFound), errors.Is(err, services.ErrReleaseAssetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReleaseExists), errors.Is(err, services.ErrReleaseAssetExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrReleaseAssetTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRelease), errors.Is(err, services.ErrMalwareDetected):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMalwareQuarantined):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMalwareScanFailed):
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Malware scanning is unavailable"})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// respondRelease writes a release, hiding drafts from readers who cannot push
+func (h *ReleaseHandlers) respondRelease(c *gin.Context, release *models.Release, err error) {
+	if err == nil && release.Draft && !h.can(c, models.PermissionWrite) {
+		err = services.ErrReleaseNotFound
+	}
+	if err != nil {
+		h.releaseError(c, err, "Failed to get release")
+		return
+	}
+	c.JSON(http.StatusOK, release)
+}
+
+// ListReleases handles GET /api/v1/repositories/{owner}/{repo}/releases
+func (h *ReleaseHandlers) ListReleases(c *gin.Context) {
+	repo, ok := readableRepository(c)
+	if !ok {
+		return
+	}
+	releases, err := h.releaseService.List(c.Request.Context(), repo.ID, h.can(c, models.PermissionWrite))
+	if err != nil {
+		h.releaseError(c, err, "Failed to list releases")
+		return
+	}
+	c.JSON(http.StatusOK, releases)
+}
+
+// GetLatestRelease handles GET /api/v1/repositories/{owner}/{repo}/releases/latest
+func (h *ReleaseHandlers) GetLatestRelease(c *gin.Context) {
+	repo, ok := readableRepository(c)
+	if !ok {
+		return
+	}
+	release, err := h.releaseService.Latest(c.Request.Context(), repo.ID)
+	h.respondRelease(c, release, err)
+}
+
+// GetReleaseByTag handles GET /api/v1/repositories/{owner}/{repo}/releases/tags/{tag}
+func (h *ReleaseHandlers) GetReleaseByTag(c *gin.Context) {
+	repo, ok := readableRepository(c)
+	if !ok {
+		return
+	}
+	release, err := h.releaseService.GetByTag(c.Request.Context(), repo.ID, c.Param("tag"))
+	h.respondRelease(c, release, err)
+}
+
+// GetRelease handles GET /api/v1/repositories/{owner}/{repo}/releases/{release_id}
+func (h *ReleaseHandlers) GetRelease(c *gin.Context) {
+	repo, ok := readableRepository(c)
+	if !ok {
+		return
+	}
+	id, ok := h.paramID(c, "release_id", "release ID")
+	if !ok {
+		return
+	}
+	release, err := h.releaseService.Get(c.Request.Context(), repo.ID, id)
+	h.respondRelease(c, release, err)
+}
+
+// CreateRelease handles POST /api/v1/repositories/{owner}/{repo}/releases
+func (h *ReleaseHandlers) CreateRelease(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	repo, ok := h.writableRepository(c)
+	if !ok {
+		return
+	}
+	var req services.CreateReleaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	release, err := h.releaseService.Create(c.Request.Context(), repo, userID, req)
+	if err != nil {
+		h.releaseError(c, err, "Failed to create release")
+		return
+	}
+	c.JSON(http.StatusCreated, release)
+}
+
+// GenerateReleaseNotes handles POST /api/v1/repositories/{owner}/{repo}/releases/generate-notes
+func (h *ReleaseHandlers) GenerateReleaseNotes(c *gin.Context) {
+	repo, ok := h.writableRepository(c)
+	if !ok {
+		return
+	}
+	var req services.GenerateReleaseNotesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	notes, err := h.releaseService.GenerateNotes(c.Request.Context(), repo, req)
+	if err != nil {
+		h.releaseError(c, err, "Failed to generate release notes")
+		return
+	}
+	c.JSON(http.StatusOK, notes)
+}
+
+// UpdateRelease handles PATCH /api/v1/repositories/{owner}/{repo}/releases/{release_id}
+func (h *ReleaseHandlers) UpdateRelease(c *gin.Context) {
+	repo, ok := h.writableRepository(c)
+	if !ok {
+		return
+	}
+	id, ok := h.paramID(c, "release_id", "release ID")
+	if !ok {
+		return
+	}
+	var req services.UpdateReleaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	release, err := h.releaseService.Update(c.Request.Context(), repo.ID, id, req)
+	if err != nil {
+		h.releaseError(c, err, "Failed to update release")
+		return
+	}
+	c.JSON(http.StatusOK, release)
+}
+
+// DeleteRelease handles DELETE /api/v1/repositories/{owner}/{repo}/releases/{release_id}
+func (h *ReleaseHandlers) DeleteRelease(c *gin.Context) {
+	repo, ok := h.writableRepository(c)
+	if !ok {
+		return
+	}
+	id, ok := h.paramID(c, "release_id", "release ID")
+	if !ok {
+		return
+	}
+	if err := h.releaseService.Delete(c.Request.Context(), repo.ID, id); err != nil {
+		h.releaseError(c, err, "Failed to delete release")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// UploadReleaseAsset handles POST /api/v1/repositories/{owner}/{repo}/releases/{release_id}/assets
+//
+// The multipart body carries the asset in a "file" part, which is streamed
+// to storage as it arrives. An optional "label" field must come before it;
+// the "name" query parameter overrides the uploaded file name.
+func (h *ReleaseHandlers) UploadReleaseAsset(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	repo, ok := h.writableRepository(c)
+	if !ok {
+		return
+	}
+	id, ok := h.paramID(c, "release_id", "release ID")
+	if !ok {
+		return
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request must be multipart/form-data"})
+		return
+	}
+	fields := map[string]string{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A file part is required"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart body", "details": err.Error()})
+			return
+		}
+
+		if part.FormName() != "file" {
+			value, err := io.ReadAll(io.LimitReader(part, 64*1024))
+			part.Close()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart body", "details": err.Error()})
+				return
+			}
+			fields[part.FormName()] = string(value)
+			continue
+		}
+
+		name := c.Query("name")
+		if name == "" {
+			name = part.FileName()
+		}
+		asset, err := h.releaseService.UploadAsset(c.Request.Context(), repo.ID, id, userID, services.UploadReleaseAssetRequest{
+			Name:        name,
+			Label:       fields["label"],
+			ContentType: part.Header.Get("Content-Type"),
+		}, part)
+		part.Close()
+		if err != nil {
+			h.releaseError(c, err, "Failed to upload release asset")
+			return
+		}
+		c.JSON(http.StatusCreated, asset)
+		return
+	}
+}
+
+// GetReleaseAsset handles GET /api/v1/repositories/{owner}/{repo}/releases/assets/{asset_id}
+func (h *ReleaseHandlers) GetReleaseAsset(c *gin.Context) {
+	repo, ok := readableRepository(c)
+	if !ok {
+		return
+	}
+	id, ok := h.paramID(c, "asset_id", "asset ID")
+	if !ok {
+		return
+	}
+	asset, err := h.releaseService.GetAsset(c.Request.Context(), repo.ID, id)
+	if err == nil {
+		err = h.visibleRelease(c, repo.ID, asset.ReleaseID)
+	}
+	if err != nil {
+		h.releaseError(c, err, "Failed to get release asset")
+		return
+	}
+	c.JSON(http.StatusOK, asset)
+}
+
+// DownloadReleaseAsset handles GET /api/v1/repositories/{owner}/{repo}/releases/assets/{asset_id}/download
+func (h *ReleaseHandlers) DownloadReleaseAsset(c *gin.Context) {
+	repo, ok := readableRepository(c)
+	if !ok {
+		return
+	}
+	id, ok := h.paramID(c, "asset_id", "asset ID")
+	if !ok {
+		return
+	}
+	asset, err := h.releaseService.GetAsset(c.Request.Context(), repo.ID, id)
+	if err == nil {
+		err = h.visibleRelease(c, repo.ID, asset.ReleaseID)
+	}
+	if err != nil {
+		h.releaseError(c, err, "Failed to get release asset")
+		return
+	}
+
+	asset, content, err := h.releaseService.OpenAsset(c.Request.Context(), repo.ID, id)
+	if err != nil {
+		h.releaseError(c, err, "Failed to download release asset")
+		return
+	}
+	defer content.Close()
+	c.DataFromReader(http.StatusOK, asset.Size, asset.ContentType, content, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": asset.Name}),
+	})
+}
+
+// DeleteReleaseAsset handles DELETE /api/v1/repositories/{owner}/{repo}/releases/assets/{asset_id}
+func (h *ReleaseHandlers) DeleteReleaseAsset(c *gin.Context) {
+	repo, ok := h.writableRepository(c)
+	if !ok {
+		return
+	}
+	id, ok := h.paramID(c, "asset_id", "asset ID")
+	if !ok {
+		return
+	}
+	if err := h.releaseService.DeleteAsset(c.Request.Context(), repo.ID, id); err != nil {
+		h.releaseError(c, err, "Failed to delete release asset")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// visibleRelease hides the assets of drafts from readers who cannot push
+func (h *ReleaseHandlers) visibleRelease(c *gin.Context, repoID, releaseID uuid.UUID) error {
+	if h.can(c, models.PermissionWrite) {
+		return nil
+	}
+	release, err := h.releaseService.Get(c.Request.Context(), repoID, releaseID)
+	if err != nil {
+		return err
+	}
+	if release.Draft {
+		return services.ErrReleaseAssetNotFound
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RepositoryApprovalHandlers serves the repository creations and deletions
+// waiting for an organization owner or admin to approve them
+type RepositoryApprovalHandlers struct {
+	approvalService services.RepositoryApprovalService
+	logger          *logrus.Logger
+}
+
+func NewRepositoryApprovalHandlers(approvalService services.RepositoryApprovalService, logger *logrus.Logger) *RepositoryApprovalHandlers {
+	return &RepositoryApprovalHandlers{
+		approvalService: approvalService,
+		logger:          logger,
+	}
+}
+
+func (h *RepositoryApprovalHandlers) requestID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("request_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid approval request ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *RepositoryApprovalHandlers) approvalError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrRepositoryApprovalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRepositoryApprovalForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRepositoryApprovalNotPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
+
+// ListRepositoryApprovals handles GET /api/v1/organizations/:org/repository-approvals
+func (h *RepositoryApprovalHandlers) ListRepositoryApprovals(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	requests, err := h.approvalService.List(c.Request.Context(), c.Param("org"), userID, models.RepositoryApprovalStatus(c.Query("status")))
+	if err != nil {
+		h.approvalError(c, err, "Failed to list repository approval requests")
+		return
+	}
+	c.JSON(http.StatusOK, requests)
+}
+
+// GetRepositoryApproval handles GET /api/v1/organizations/:org/repository-approvals/:request_id
+func (h *RepositoryApprovalHandlers) GetRepositoryApproval(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	id, ok := h.requestID(c)
+	if !ok {
+		return
+	}
+	request, err := h.approvalService.Get(c.Request.Context(), c.Param("org"), userID, id)
+	if err != nil {
+		h.approvalError(c, err, "Failed to get repository approval request")
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
+
+// approvalReviewRequest is the optional body of approve and reject
+type approvalReviewRequest struct {
+	Comment string `json:"comment"`
+}
+
+// ApproveRepositoryApproval handles POST /api/v1/organizations/:org/repository-approvals/:request_id/approve
+func (h *RepositoryApprovalHandlers) ApproveRepositoryApproval(c *gin.Context) {
+	h.review(c, h.approvalService.Approve, "Failed to approve repository approval request")
+}
+
+// RejectRepositoryApproval handles POST /api/v1/organizations/:org/repository-approvals/:request_id/reject
+func (h *RepositoryApprovalHandlers) RejectRepositoryApproval(c *gin.Context) {
+	h.review(c, h.approvalService.Reject, "Failed to reject repository approval request")
+}
+
+type approvalReviewFunc func(ctx context.Context, orgName string, actorID, requestID uuid.UUID, comment string) (*models.RepositoryApprovalRequest, error)
+
+func (h *RepositoryApprovalHandlers) review(c *gin.Context, review approvalReviewFunc, message string) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	id, ok := h.requestID(c)
+	if !ok {
+		return
+	}
+	var req approvalReviewRequest
+	// The body is optional
+	_ = c.ShouldBindJSON(&req)
+
+	request, err := review(c.Request.Context(), c.Param("org"), userID, id, req.Comment)
+	if err != nil {
+		h.approvalError(c, err, message)
+		return
+	}
+	c.JSON(http.StatusOK, request)
+}
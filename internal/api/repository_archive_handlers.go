@@ -0,0 +1,95 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RepositoryArchiveHandlers serves source archives of repositories
+type RepositoryArchiveHandlers struct {
+	repositoryService services.RepositoryService
+	gitService        git.GitService
+	logger            *logrus.Logger
+}
+
+func NewRepositoryArchiveHandlers(repositoryService services.RepositoryService, gitService git.GitService, logger *logrus.Logger) *RepositoryArchiveHandlers {
+	return &RepositoryArchiveHandlers{
+		repositoryService: repositoryService,
+		gitService:        gitService,
+		logger:            logger,
+	}
+}
+
+// GetTarball handles GET /api/v1/repositories/{owner}/{repo}/tarball/{ref}
+func (h *RepositoryArchiveHandlers) GetTarball(c *gin.Context) {
+	h.archive(c, git.ArchiveTarGz, "application/gzip")
+}
+
+// GetZipball handles GET /api/v1/repositories/{owner}/{repo}/zipball/{ref}
+func (h *RepositoryArchiveHandlers) GetZipball(c *gin.Context) {
+	h.archive(c, git.ArchiveZip, "application/zip")
+}
+
+// archive streams the tree of the requested ref, or of the default branch
+// when no ref is given. Files are placed under an {owner}-{repo}-{sha}
+// directory, which also names the download.
+func (h *RepositoryArchiveHandlers) archive(c *gin.Context, format git.ArchiveFormat, contentType string) {
+	owner, name := c.Param("owner"), c.Param("repo")
+	repo, err := h.repositoryService.Get(c.Request.Context(), owner, name)
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return
+	}
+	if repo.Visibility != models.VisibilityPublic {
+		if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionRead) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+			return
+		}
+	}
+
+	repoPath, err := h.repositoryService.GetRepositoryPath(c.Request.Context(), repo.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository path"})
+		return
+	}
+
+	ref := strings.TrimPrefix(c.Param("ref"), "/")
+	if ref == "" {
+		ref = repo.DefaultBranch
+	}
+	// Full SHAs resolve without a lookup, so the commit is checked before
+	// any of the archive is sent
+	sha, err := h.gitService.ResolveSHA(c.Request.Context(), repoPath, ref)
+	if err == nil {
+		_, err = h.gitService.GetCommit(c.Request.Context(), repoPath, sha)
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reference not found"})
+		return
+	}
+
+	prefix := fmt.Sprintf("%s-%s-%s", owner, name, sha[:7])
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, prefix, format))
+	c.Header("ETag", fmt.Sprintf(`"%s.%s"`, sha, format))
+	c.Status(http.StatusOK)
+	if err := h.gitService.Archive(c.Request.Context(), repoPath, sha, format, prefix, c.Writer); err != nil {
+		// Headers are already sent, so the truncated archive is all the client sees
+		h.logger.WithError(err).WithFields(logrus.Fields{
+			"repository_id": repo.ID,
+			"ref":           ref,
+		}).Error("Failed to stream repository archive")
+	}
+}
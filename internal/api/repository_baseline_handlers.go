@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RepositoryBaselineHandlers serves organization repository baselines and
+// the drift of repositories from them
+type RepositoryBaselineHandlers struct {
+	baselineService services.RepositoryBaselineService
+	logger          *logrus.Logger
+}
+
+func NewRepositoryBaselineHandlers(baselineService services.RepositoryBaselineService, logger *logrus.Logger) *RepositoryBaselineHandlers {
+	return &RepositoryBaselineHandlers{
+		baselineService: baselineService,
+		logger:          logger,
+	}
+}
+
+func (h *RepositoryBaselineHandlers) baselineError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrRepositoryBaselineForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRepositoryBaseline), errors.Is(err, services.ErrRepositoryBaselineNotOwned):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetBaseline handles GET /api/v1/organizations/:org/repository-baseline
+func (h *RepositoryBaselineHandlers) GetBaseline(c *gin.Context) {
+	baseline, err := h.baselineService.Get(c.Request.Context(), c.Param("org"))
+	if err != nil {
+		h.baselineError(c, err, "Failed to get repository baseline")
+		return
+	}
+	c.JSON(http.StatusOK, baseline)
+}
+
+// UpdateBaseline handles PUT /api/v1/organizations/:org/repository-baseline
+func (h *RepositoryBaselineHandlers) UpdateBaseline(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req models.RepositoryBaseline
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	baseline, err := h.baselineService.Set(c.Request.Context(), c.Param("org"), userID, &req)
+	if err != nil {
+		h.baselineError(c, err, "Failed to update repository baseline")
+		return
+	}
+	c.JSON(http.StatusOK, baseline)
+}
+
+// GetDrift handles GET /api/v1/repositories/:owner/:repo/baseline-drift.
+// Drift reports include webhook URLs, so they need admin permission.
+func (h *RepositoryBaselineHandlers) GetDrift(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+	report, err := h.baselineService.Drift(c.Request.Context(), repo)
+	if err != nil {
+		h.baselineError(c, err, "Failed to compare repository with baseline")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// RemediateDrift handles POST /api/v1/repositories/:owner/:repo/baseline-drift/remediate.
+// Fixes are applied for the categories given, or for all of them.
+func (h *RepositoryBaselineHandlers) RemediateDrift(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Categories []string `json:"categories"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+
+	report, err := h.baselineService.Remediate(c.Request.Context(), repo, userID, req.Categories)
+	if err != nil {
+		h.baselineError(c, err, "Failed to remediate repository drift")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -2,12 +2,15 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
 
 	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/middleware"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/a5c-ai/hub/internal/services"
 	"github.com/gin-gonic/gin"
@@ -36,6 +39,9 @@ type RepositoryResponse struct {
 	SSHURL          string     `json:"ssh_url"`
 	Size            int64      `json:"size"`
 	PushedAt        *string    `json:"pushed_at,omitempty"`
+	// CodeIndex tells search consumers whether code search results for the
+	// repository may be stale; it is only included for a single repository
+	CodeIndex *services.RepositoryCodeIndex `json:"code_index,omitempty"`
 }
 
 // OwnerInfo represents repository owner information
@@ -50,17 +56,25 @@ type OwnerInfo struct {
 type RepositoryHandlers struct {
 	repositoryService services.RepositoryService
 	branchService     services.BranchService
+	symbolService     services.SymbolService
 	gitService        git.GitService
+	signingKeyService services.SigningKeyService
+	importService     services.RepositoryImportService
+	approvalService   services.RepositoryApprovalService
 	logger            *logrus.Logger
 	db                *gorm.DB
 }
 
 // NewRepositoryHandlers creates a new repository handlers instance
-func NewRepositoryHandlers(repositoryService services.RepositoryService, branchService services.BranchService, gitService git.GitService, logger *logrus.Logger, db *gorm.DB) *RepositoryHandlers {
+func NewRepositoryHandlers(repositoryService services.RepositoryService, branchService services.BranchService, symbolService services.SymbolService, gitService git.GitService, signingKeyService services.SigningKeyService, importService services.RepositoryImportService, approvalService services.RepositoryApprovalService, logger *logrus.Logger, db *gorm.DB) *RepositoryHandlers {
 	return &RepositoryHandlers{
 		repositoryService: repositoryService,
 		branchService:     branchService,
+		symbolService:     symbolService,
 		gitService:        gitService,
+		signingKeyService: signingKeyService,
+		importService:     importService,
+		approvalService:   approvalService,
 		logger:            logger,
 		db:                db,
 	}
@@ -152,11 +166,21 @@ func (h *RepositoryHandlers) getOwnerInfo(ownerID uuid.UUID, ownerType models.Ow
 
 // CreateRepository handles POST /api/v1/repositories
 func (h *RepositoryHandlers) CreateRepository(c *gin.Context) {
-	var req services.CreateRepositoryRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if h.importService != nil && strings.HasPrefix(c.ContentType(), "multipart/") {
+		h.importRepository(c)
+		return
+	}
+
+	// The reason is only kept when the organization requires approval
+	var body struct {
+		services.CreateRepositoryRequest
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
 		return
 	}
+	req := body.CreateRepositoryRequest
 
 	// Get user ID from context (set by auth middleware)
 	userID, exists := c.Get("user_id")
@@ -173,7 +197,20 @@ func (h *RepositoryHandlers) CreateRepository(c *gin.Context) {
 		}
 	}
 
+	if uid, ok := userID.(uuid.UUID); ok && h.approvalService != nil {
+		pending, err := h.requestApproval(c, uid, req.OwnerID, req.OwnerType, models.RepositoryApprovalCreate, func() (*models.RepositoryApprovalRequest, error) {
+			return h.approvalService.RequestCreation(c.Request.Context(), uid, req, body.Reason)
+		})
+		if err != nil || pending {
+			return
+		}
+	}
+
 	repo, err := h.repositoryService.Create(c.Request.Context(), req)
+	if errors.Is(err, services.ErrExternalCollaboratorRestricted) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to create repository")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create repository", "details": err.Error()})
@@ -191,6 +228,168 @@ func (h *RepositoryHandlers) CreateRepository(c *gin.Context) {
 	c.JSON(http.StatusCreated, repoResponse)
 }
 
+// importRepository handles POST /api/v1/repositories with a multipart body
+// holding an archive of an existing project. The form fields name,
+// description, visibility, default_branch and preserve_history must come
+// before the "archive" file part, which is streamed to disk as it arrives.
+// Small archives are imported right away; larger ones respond 202 with an
+// import to poll.
+func (h *RepositoryHandlers) importRepository(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	uid, isUUID := userID.(uuid.UUID)
+	if !ok || !isUUID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request must be multipart/form-data"})
+		return
+	}
+
+	fields := map[string]string{}
+	var imp *models.RepositoryArchiveImport
+	for imp == nil {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "An archive file part is required"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart body", "details": err.Error()})
+			return
+		}
+
+		if part.FormName() != "archive" {
+			value, err := io.ReadAll(io.LimitReader(part, 64*1024))
+			part.Close()
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart body", "details": err.Error()})
+				return
+			}
+			fields[part.FormName()] = string(value)
+			continue
+		}
+
+		if fields["name"] == "" {
+			part.Close()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The name field must come before the archive"})
+			return
+		}
+		visibility := models.Visibility(fields["visibility"])
+		if visibility == "" {
+			visibility = models.VisibilityPrivate
+		}
+		preserve, _ := strconv.ParseBool(fields["preserve_history"])
+		imp, err = h.importService.Start(c.Request.Context(), uid, services.StartRepositoryImportRequest{
+			Repository: services.CreateRepositoryRequest{
+				OwnerID:       uid,
+				OwnerType:     models.OwnerTypeUser,
+				Name:          fields["name"],
+				Description:   fields["description"],
+				DefaultBranch: fields["default_branch"],
+				Visibility:    visibility,
+				HasIssues:     true,
+			},
+			PreserveHistory: preserve,
+		}, part)
+		part.Close()
+		if err != nil {
+			h.repositoryImportError(c, err)
+			return
+		}
+	}
+	h.respondRepositoryImport(c, imp)
+}
+
+// GetRepositoryImport handles GET /api/v1/repository-imports/{import_id}
+func (h *RepositoryHandlers) GetRepositoryImport(c *gin.Context) {
+	userID, ok := c.Get("user_id")
+	uid, isUUID := userID.(uuid.UUID)
+	if !ok || !isUUID {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	importID, err := uuid.Parse(c.Param("import_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
+		return
+	}
+
+	imp, err := h.importService.Get(c.Request.Context(), uid, importID)
+	if err != nil {
+		h.repositoryImportError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, imp)
+}
+
+func (h *RepositoryHandlers) respondRepositoryImport(c *gin.Context, imp *models.RepositoryArchiveImport) {
+	switch imp.Status {
+	case models.ArchiveImportCompleted:
+		repoResponse, err := h.convertToRepositoryResponse(imp.Repository)
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to convert repository to response")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process repository"})
+			return
+		}
+		c.JSON(http.StatusCreated, repoResponse)
+	case models.ArchiveImportFailed:
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to import repository", "details": imp.Error, "import": imp})
+	default:
+		c.Header("Location", "/api/v1/repository-imports/"+imp.ID.String())
+		c.JSON(http.StatusAccepted, imp)
+	}
+}
+
+func (h *RepositoryHandlers) repositoryImportError(c *gin.Context, err error) {
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.Is(err, services.ErrRepositoryImportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository import not found"})
+	case errors.Is(err, services.ErrImportArchiveTooLarge), errors.As(err, &maxBytes):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidImportArchive):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrImportRepositoryExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Repository already exists"})
+	default:
+		h.logger.WithError(err).Error("Failed to import repository")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import repository", "details": err.Error()})
+	}
+}
+
+// requestApproval files an approval request instead of acting when the
+// organization's policy requires one of userID, answering 202 with it.
+// It reports whether a request was filed; on error the response is written.
+func (h *RepositoryHandlers) requestApproval(c *gin.Context, userID, ownerID uuid.UUID, ownerType models.OwnerType, action models.RepositoryApprovalAction, file func() (*models.RepositoryApprovalRequest, error)) (bool, error) {
+	required, err := h.approvalService.RequiresApproval(c.Request.Context(), ownerID, ownerType, userID, action)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to check repository approval policy")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check repository approval policy"})
+		return false, err
+	}
+	if !required {
+		return false, nil
+	}
+
+	request, err := file()
+	if err != nil {
+		if errors.Is(err, services.ErrRepositoryApprovalDuplicate) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Failed to request approval", "details": err.Error()})
+		}
+		return false, err
+	}
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":          "The organization requires an owner or admin to approve this",
+		"approval_request": request,
+	})
+	return true, nil
+}
+
 // GetRepository handles GET /api/v1/repositories/{owner}/{repo}
 func (h *RepositoryHandlers) GetRepository(c *gin.Context) {
 	owner := c.Param("owner")
@@ -223,7 +422,13 @@ func (h *RepositoryHandlers) GetRepository(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process repository"})
 		return
 	}
+	if index, err := h.symbolService.IndexStatus(c.Request.Context(), repo); err != nil {
+		h.logger.WithError(err).WithField("repository_id", repo.ID).Warn("Failed to get code index status")
+	} else {
+		repoResponse.CodeIndex = index
+	}
 
+	middleware.SetVersionETag(c, repo.Version)
 	c.JSON(http.StatusOK, repoResponse)
 }
 
@@ -248,22 +453,54 @@ func (h *RepositoryHandlers) UpdateRepository(c *gin.Context) {
 		return
 	}
 
+	ifVersion, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
 	var req services.UpdateRepositoryRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
 		return
 	}
+	req.IfVersion = ifVersion
 
 	updatedRepo, err := h.repositoryService.Update(c.Request.Context(), repo.ID, req)
+	if errors.Is(err, services.ErrVersionConflict) {
+		h.repositoryConflict(c, repo.ID, err)
+		return
+	}
+	if errors.Is(err, services.ErrInvalidMergeTemplate) || errors.Is(err, services.ErrInvalidTitlePattern) || errors.Is(err, services.ErrInvalidBranchCleanup) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrVisibilityChangeRestricted) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to update repository")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update repository", "details": err.Error()})
 		return
 	}
 
+	middleware.SetVersionETag(c, updatedRepo.Version)
 	c.JSON(http.StatusOK, updatedRepo)
 }
 
+// repositoryConflict answers a stale update with 409 and the current
+// repository so the client can merge its edit and retry
+func (h *RepositoryHandlers) repositoryConflict(c *gin.Context, repoID uuid.UUID, err error) {
+	current, getErr := h.repositoryService.GetByID(c.Request.Context(), repoID)
+	if getErr != nil {
+		h.logger.WithError(getErr).Error("Failed to reload repository after conflict")
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	middleware.SetVersionETag(c, current.Version)
+	c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "current": current})
+}
+
 // GetRepositorySettings handles GET /api/v1/repositories/{owner}/{repo}/settings
 func (h *RepositoryHandlers) GetRepositorySettings(c *gin.Context) {
 	owner := c.Param("owner")
@@ -292,6 +529,7 @@ func (h *RepositoryHandlers) GetRepositorySettings(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to parse settings"})
 		return
 	}
+	c.Header("ETag", `"`+file.SHA+`"`)
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": settings})
 }
 
@@ -319,12 +557,21 @@ func (h *RepositoryHandlers) UpdateRepositorySettings(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid settings payload"})
 		return
 	}
+	ifMatch := strings.TrimSpace(c.GetHeader("If-Match"))
+	if ifMatch == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"success": false, "error": "If-Match header with the current ETag is required"})
+		return
+	}
 	// Read existing file to get current SHA for conflict detection
 	file, err := h.gitService.GetFile(c.Request.Context(), repoPath, "settings", "repository.yaml")
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Settings not found"})
 		return
 	}
+	if ifMatch != "*" && strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`) != file.SHA {
+		settingsConflict(c, file)
+		return
+	}
 	// Marshal updated settings to YAML
 	data, err := yaml.Marshal(settings)
 	if err != nil {
@@ -340,14 +587,37 @@ func (h *RepositoryHandlers) UpdateRepositorySettings(c *gin.Context) {
 		SHA:     file.SHA,
 		Author:  git.CommitAuthor{Name: "system", Email: "system@localhost", Date: time.Now()},
 	}
+	// The settings branch is only moved if it still holds the version checked above
 	commit, err := h.gitService.UpdateFile(c.Request.Context(), repoPath, req)
+	if errors.Is(err, git.ErrRefChanged) {
+		// Another update landed between the check above and the commit
+		if file, err = h.gitService.GetFile(c.Request.Context(), repoPath, "settings", "repository.yaml"); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to read repository settings"})
+			return
+		}
+		settingsConflict(c, file)
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+		h.logger.WithError(err).Error("Failed to update repository settings")
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update repository settings"})
 		return
 	}
+	if updated, err := h.gitService.GetFile(c.Request.Context(), repoPath, "settings", "repository.yaml"); err == nil {
+		c.Header("ETag", `"`+updated.SHA+`"`)
+	}
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"sha": commit.SHA}})
 }
 
+// settingsConflict answers a settings update built on a stale version with
+// the settings as they are now
+func settingsConflict(c *gin.Context, file *git.File) {
+	var current map[string]interface{}
+	_ = yaml.Unmarshal([]byte(file.Content), &current)
+	c.Header("ETag", `"`+file.SHA+`"`)
+	c.JSON(http.StatusConflict, gin.H{"success": false, "error": services.ErrVersionConflict.Error(), "current": current})
+}
+
 // DeleteRepository handles DELETE /api/v1/repositories/{owner}/{repo}
 func (h *RepositoryHandlers) DeleteRepository(c *gin.Context) {
 	owner := c.Param("owner")
@@ -369,7 +639,27 @@ func (h *RepositoryHandlers) DeleteRepository(c *gin.Context) {
 		return
 	}
 
+	if uid, ok := c.Get("user_id"); ok && h.approvalService != nil {
+		if uid, ok := uid.(uuid.UUID); ok {
+			var body struct {
+				Reason string `json:"reason"`
+			}
+			// The body is optional
+			_ = c.ShouldBindJSON(&body)
+			pending, err := h.requestApproval(c, uid, repo.OwnerID, repo.OwnerType, models.RepositoryApprovalDelete, func() (*models.RepositoryApprovalRequest, error) {
+				return h.approvalService.RequestDeletion(c.Request.Context(), uid, repo, body.Reason)
+			})
+			if err != nil || pending {
+				return
+			}
+		}
+	}
+
 	if err := h.repositoryService.Delete(c.Request.Context(), repo.ID); err != nil {
+		if errors.Is(err, services.ErrRepositoryObjectsShared) || errors.Is(err, git.ErrObjectPoolUnhealthy) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		h.logger.WithError(err).Error("Failed to delete repository")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete repository", "details": err.Error()})
 		return
@@ -479,13 +769,31 @@ func (h *RepositoryHandlers) GetBranches(c *gin.Context) {
 		return
 	}
 
-	branches, err := h.branchService.List(c.Request.Context(), repo.ID)
+	opts := services.BranchListOptions{Page: 1, PerPage: 30}
+	if page := c.Query("page"); page != "" {
+		if val, err := strconv.Atoi(page); err == nil && val > 0 {
+			opts.Page = val
+		}
+	}
+	if perPage := c.Query("per_page"); perPage != "" {
+		if val, err := strconv.Atoi(perPage); err == nil && val > 0 && val <= 100 {
+			opts.PerPage = val
+		}
+	}
+	if divergence := c.Query("ahead_behind"); divergence != "" {
+		if val, err := strconv.ParseBool(divergence); err == nil {
+			opts.IncludeDivergence = val
+		}
+	}
+
+	branches, total, err := h.branchService.ListDetailed(c.Request.Context(), repo.ID, opts)
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to list branches")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list branches"})
 		return
 	}
 
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
 	c.JSON(http.StatusOK, branches)
 }
 
@@ -513,8 +821,8 @@ func (h *RepositoryHandlers) GetBranch(c *gin.Context) {
 
 	branch, err := h.branchService.Get(c.Request.Context(), repo.ID, branchName)
 	if err != nil {
-		if err.Error() == "branch not found" {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
+		if errors.Is(err, services.ErrBranchNotFound) {
+			h.redirectRenamedBranch(c, repo.ID, owner, repoName, branchName)
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get branch"})
 		}
@@ -524,6 +832,27 @@ func (h *RepositoryHandlers) GetBranch(c *gin.Context) {
 	c.JSON(http.StatusOK, branch)
 }
 
+// redirectRenamedBranch answers a request for a missing branch with a
+// permanent redirect when the branch was renamed, and with 404 otherwise
+func (h *RepositoryHandlers) redirectRenamedBranch(c *gin.Context, repoID uuid.UUID, owner, repoName, branchName string) {
+	rename, err := h.branchService.ResolveRename(c.Request.Context(), repoID, branchName)
+	if err != nil {
+		if !errors.Is(err, services.ErrBranchNotFound) {
+			h.logger.WithError(err).Error("Failed to resolve branch rename")
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
+		return
+	}
+
+	location := fmt.Sprintf("/api/v1/repositories/%s/%s/branches/%s", owner, repoName, rename.NewName)
+	c.Header("Location", location)
+	c.JSON(http.StatusMovedPermanently, gin.H{
+		"message":    fmt.Sprintf("Branch %s was renamed to %s", branchName, rename.NewName),
+		"renamed_to": rename.NewName,
+		"url":        location,
+	})
+}
+
 // CreateBranch handles POST /api/v1/repositories/{owner}/{repo}/branches
 func (h *RepositoryHandlers) CreateBranch(c *gin.Context) {
 	owner := c.Param("owner")
@@ -592,6 +921,49 @@ func (h *RepositoryHandlers) DeleteBranch(c *gin.Context) {
 	c.JSON(http.StatusNoContent, nil)
 }
 
+// RenameBranch handles POST /api/v1/repositories/{owner}/{repo}/branches/{branch}/rename
+func (h *RepositoryHandlers) RenameBranch(c *gin.Context) {
+	owner := c.Param("owner")
+	repoName := c.Param("repo")
+	branchName := c.Param("branch")
+
+	repo, err := h.repositoryService.Get(c.Request.Context(), owner, repoName)
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return
+	}
+
+	var req services.RenameBranchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	if userID, exists := c.Get("user_id"); exists {
+		if uid, err := parseUserID(userID); err == nil {
+			req.RenamedByID = &uid
+		}
+	}
+
+	rename, err := h.branchService.Rename(c.Request.Context(), repo.ID, branchName, req)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, rename)
+	case errors.Is(err, services.ErrBranchNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Branch not found"})
+	case errors.Is(err, services.ErrInvalidBranchName):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrBranchExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("Failed to rename branch")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rename branch"})
+	}
+}
+
 // Helper functions
 func parseOwnerType(s string) models.OwnerType {
 	switch s {
@@ -674,6 +1046,7 @@ func (h *RepositoryHandlers) GetCommits(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get commits"})
 		return
 	}
+	h.signingKeyService.VerifyCommits(c.Request.Context(), commits)
 
 	c.JSON(http.StatusOK, commits)
 }
@@ -713,6 +1086,7 @@ func (h *RepositoryHandlers) GetCommit(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Commit not found"})
 		return
 	}
+	h.signingKeyService.VerifyCommits(c.Request.Context(), []*git.Commit{commit})
 
 	c.JSON(http.StatusOK, commit)
 }
@@ -1162,6 +1536,7 @@ func (h *RepositoryHandlers) GetRepositoryTags(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository tags"})
 		return
 	}
+	h.signingKeyService.VerifyTags(c.Request.Context(), tags)
 
 	c.JSON(http.StatusOK, tags)
 }
@@ -1425,8 +1800,28 @@ func (h *RepositoryHandlers) ForkRepository(c *gin.Context) {
 		OwnerType: models.OwnerTypeUser,
 	}
 
+	// Fork into an organization the user belongs to
+	if forkReq.Organization != "" {
+		var org models.Organization
+		if err := h.db.Where("name = ?", forkReq.Organization).First(&org).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		}
+		var member models.OrganizationMember
+		if err := h.db.Where("organization_id = ? AND user_id = ?", org.ID, forkRequest.OwnerID).First(&member).Error; err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this organization"})
+			return
+		}
+		forkRequest.OwnerID = org.ID
+		forkRequest.OwnerType = models.OwnerTypeOrganization
+	}
+
 	// Use the repository service to fork the repository
 	fork, err := h.repositoryService.Fork(c.Request.Context(), repo.ID, forkRequest)
+	if errors.Is(err, services.ErrForkingRestricted) || errors.Is(err, services.ErrExternalCollaboratorRestricted) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to fork repository")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fork repository: " + err.Error()})
@@ -1481,6 +1876,10 @@ func (h *RepositoryHandlers) TransferRepository(c *gin.Context) {
 
 	// Transfer the repository
 	if err := h.repositoryService.Transfer(c.Request.Context(), repo.ID, transferRequest); err != nil {
+		if errors.Is(err, services.ErrExternalCollaboratorRestricted) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		h.logger.WithError(err).Error("Failed to transfer repository")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer repository: " + err.Error()})
 		return
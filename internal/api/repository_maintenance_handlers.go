@@ -0,0 +1,83 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RepositoryMaintenanceHandlers serves the admin pack statistics and repack
+// endpoints
+type RepositoryMaintenanceHandlers struct {
+	maintenanceService services.RepositoryMaintenanceService
+	repositoryService  services.RepositoryService
+	logger             *logrus.Logger
+}
+
+func NewRepositoryMaintenanceHandlers(maintenanceService services.RepositoryMaintenanceService, repositoryService services.RepositoryService, logger *logrus.Logger) *RepositoryMaintenanceHandlers {
+	return &RepositoryMaintenanceHandlers{
+		maintenanceService: maintenanceService,
+		repositoryService:  repositoryService,
+		logger:             logger,
+	}
+}
+
+func (h *RepositoryMaintenanceHandlers) getRepository(c *gin.Context) (*models.Repository, bool) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return nil, false
+	}
+	return repo, true
+}
+
+func (h *RepositoryMaintenanceHandlers) maintenanceError(c *gin.Context, err error, message string) {
+	if errors.Is(err, services.ErrRepositoryNotOnDisk) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	h.logger.WithError(err).Error(message)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+}
+
+// GetPackStatistics handles GET /api/v1/admin/repositories/:owner/:repo/packs
+//
+// Pass deltas=true to also measure delta chain depths, which reads every
+// pack index of the repository.
+func (h *RepositoryMaintenanceHandlers) GetPackStatistics(c *gin.Context) {
+	repo, ok := h.getRepository(c)
+	if !ok {
+		return
+	}
+	report, err := h.maintenanceService.PackStatistics(c.Request.Context(), repo.ID, c.Query("deltas") == "true")
+	if err != nil {
+		h.maintenanceError(c, err, "Failed to get pack statistics")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// RunMaintenance handles POST /api/v1/admin/repositories/:owner/:repo/maintenance
+//
+// The repository is only repacked when its layout calls for it unless
+// force=true is passed.
+func (h *RepositoryMaintenanceHandlers) RunMaintenance(c *gin.Context) {
+	repo, ok := h.getRepository(c)
+	if !ok {
+		return
+	}
+	result, err := h.maintenanceService.Maintain(c.Request.Context(), repo.ID, c.Query("force") == "true")
+	if err != nil {
+		h.maintenanceError(c, err, "Failed to maintain repository")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RepositoryPolicyHandlers serves organization forking and visibility policies
+type RepositoryPolicyHandlers struct {
+	policyService services.RepositoryPolicyService
+	logger        *logrus.Logger
+}
+
+func NewRepositoryPolicyHandlers(policyService services.RepositoryPolicyService, logger *logrus.Logger) *RepositoryPolicyHandlers {
+	return &RepositoryPolicyHandlers{
+		policyService: policyService,
+		logger:        logger,
+	}
+}
+
+func (h *RepositoryPolicyHandlers) policyError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrRepositoryPolicyForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRepositoryPolicy):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetRepositoryPolicy handles GET /api/v1/organizations/:org/policies/repository
+func (h *RepositoryPolicyHandlers) GetRepositoryPolicy(c *gin.Context) {
+	policy, err := h.policyService.Get(c.Request.Context(), c.Param("org"))
+	if err != nil {
+		h.policyError(c, err, "Failed to get repository policy")
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// UpdateRepositoryPolicy handles PATCH /api/v1/organizations/:org/policies/repository
+func (h *RepositoryPolicyHandlers) UpdateRepositoryPolicy(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req services.UpdateRepositoryPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	policy, err := h.policyService.Update(c.Request.Context(), c.Param("org"), userID, req)
+	if err != nil {
+		h.policyError(c, err, "Failed to update repository policy")
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// GetPolicyCompliance handles GET /api/v1/organizations/:org/policies/compliance
+func (h *RepositoryPolicyHandlers) GetPolicyCompliance(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	compliance, err := h.policyService.Compliance(c.Request.Context(), c.Param("org"), userID)
+	if err != nil {
+		h.policyError(c, err, "Failed to check policy compliance")
+		return
+	}
+	c.JSON(http.StatusOK, compliance)
+}
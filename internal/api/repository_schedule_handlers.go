@@ -0,0 +1,165 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// RepositoryScheduleHandlers serves repository cron schedules and their run history
+type RepositoryScheduleHandlers struct {
+	scheduleService services.RepositoryScheduleService
+	logger          *logrus.Logger
+}
+
+func NewRepositoryScheduleHandlers(scheduleService services.RepositoryScheduleService, logger *logrus.Logger) *RepositoryScheduleHandlers {
+	return &RepositoryScheduleHandlers{
+		scheduleService: scheduleService,
+		logger:          logger,
+	}
+}
+
+func (h *RepositoryScheduleHandlers) scheduleID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("schedule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid schedule ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *RepositoryScheduleHandlers) scheduleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrScheduleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSchedule):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListSchedules handles GET /api/v1/repositories/{owner}/{repo}/schedules
+func (h *RepositoryScheduleHandlers) ListSchedules(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	schedules, err := h.scheduleService.ListSchedules(c.Request.Context(), repo.ID)
+	if err != nil {
+		h.scheduleError(c, err, "Failed to list schedules")
+		return
+	}
+	c.JSON(http.StatusOK, schedules)
+}
+
+// GetSchedule handles GET /api/v1/repositories/{owner}/{repo}/schedules/{schedule_id}
+func (h *RepositoryScheduleHandlers) GetSchedule(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	id, ok := h.scheduleID(c)
+	if !ok {
+		return
+	}
+
+	schedule, err := h.scheduleService.GetSchedule(c.Request.Context(), repo.ID, id)
+	if err != nil {
+		h.scheduleError(c, err, "Failed to get schedule")
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// CreateSchedule handles POST /api/v1/repositories/{owner}/{repo}/schedules
+func (h *RepositoryScheduleHandlers) CreateSchedule(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+
+	var req services.RepositoryScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := h.scheduleService.CreateSchedule(c.Request.Context(), repo.ID, optionalActor(c), req)
+	if err != nil {
+		h.scheduleError(c, err, "Failed to create schedule")
+		return
+	}
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// UpdateSchedule handles PATCH /api/v1/repositories/{owner}/{repo}/schedules/{schedule_id}
+func (h *RepositoryScheduleHandlers) UpdateSchedule(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+	id, ok := h.scheduleID(c)
+	if !ok {
+		return
+	}
+
+	var req services.RepositoryScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := h.scheduleService.UpdateSchedule(c.Request.Context(), repo.ID, id, req)
+	if err != nil {
+		h.scheduleError(c, err, "Failed to update schedule")
+		return
+	}
+	c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteSchedule handles DELETE /api/v1/repositories/{owner}/{repo}/schedules/{schedule_id}
+func (h *RepositoryScheduleHandlers) DeleteSchedule(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+	id, ok := h.scheduleID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.scheduleService.DeleteSchedule(c.Request.Context(), repo.ID, id); err != nil {
+		h.scheduleError(c, err, "Failed to delete schedule")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListScheduleRuns handles GET /api/v1/repositories/{owner}/{repo}/schedules/{schedule_id}/runs
+func (h *RepositoryScheduleHandlers) ListScheduleRuns(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	id, ok := h.scheduleID(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	runs, err := h.scheduleService.ListRuns(c.Request.Context(), repo.ID, id, limit)
+	if err != nil {
+		h.scheduleError(c, err, "Failed to list schedule runs")
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
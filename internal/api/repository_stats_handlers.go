@@ -0,0 +1,210 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// statsRetryAfterSeconds is suggested to clients while stats are computed
+const statsRetryAfterSeconds = "5"
+
+// RepositoryStatsHandlers serves the repository statistics endpoints
+type RepositoryStatsHandlers struct {
+	statsService services.RepositoryStatsService
+	logger       *logrus.Logger
+}
+
+func NewRepositoryStatsHandlers(statsService services.RepositoryStatsService, logger *logrus.Logger) *RepositoryStatsHandlers {
+	return &RepositoryStatsHandlers{
+		statsService: statsService,
+		logger:       logger,
+	}
+}
+
+// statsPending answers 202 while the statistics are computed in the background
+func statsPending(c *gin.Context) {
+	c.Header("Retry-After", statsRetryAfterSeconds)
+	c.JSON(http.StatusAccepted, gin.H{})
+}
+
+// GetCodeFrequency handles GET /api/v1/repositories/{owner}/{repo}/stats/code_frequency
+//
+// Returns weekly [unix week start, additions, -deletions] tuples, or 202
+// Accepted with an empty body while the statistics are being computed.
+func (h *RepositoryStatsHandlers) GetCodeFrequency(c *gin.Context) {
+	repo, ok := readableRepository(c)
+	if !ok {
+		return
+	}
+
+	weeks, ready, err := h.statsService.CodeFrequency(c.Request.Context(), repo.ID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get code frequency")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get code frequency"})
+		return
+	}
+	if !ready {
+		statsPending(c)
+		return
+	}
+	if len(weeks) == 0 {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, weeks)
+}
+
+// GetParticipation handles GET /api/v1/repositories/{owner}/{repo}/stats/participation
+//
+// Returns the owner and total weekly commit counts of the last 52 weeks, or
+// 202 Accepted with an empty body while the statistics are being computed.
+func (h *RepositoryStatsHandlers) GetParticipation(c *gin.Context) {
+	repo, ok := readableRepository(c)
+	if !ok {
+		return
+	}
+
+	participation, ready, err := h.statsService.Participation(c.Request.Context(), repo.ID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get participation")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get participation"})
+		return
+	}
+	if !ready {
+		statsPending(c)
+		return
+	}
+	c.JSON(http.StatusOK, participation)
+}
+
+// GetInsightsSummary handles GET /api/v1/repositories/{owner}/{repo}/insights/summary
+//
+// Returns the pre-aggregated numbers shown on dashboard cards from cache, or
+// 202 Accepted with an empty body until the summary is first computed.
+func (h *RepositoryStatsHandlers) GetInsightsSummary(c *gin.Context) {
+	repo, ok := readableRepository(c)
+	if !ok {
+		return
+	}
+
+	summary, ready, err := h.statsService.Summary(c.Request.Context(), repo.ID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get insights summary")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get insights summary"})
+		return
+	}
+	if !ready {
+		statsPending(c)
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// authorStatsRange reads the start_date and end_date of an author
+// statistics request; zero times leave the defaults to the service
+func authorStatsRange(c *gin.Context) (since, until time.Time, ok bool) {
+	for param, dest := range map[string]*time.Time{"start_date": &since, "end_date": &until} {
+		value := c.Query(param)
+		if value == "" {
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			if parsed, err = time.Parse(time.RFC3339, value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be a date (YYYY-MM-DD) or RFC 3339 timestamp", param)})
+				return since, until, false
+			}
+		}
+		*dest = parsed
+	}
+	if !since.IsZero() && !until.IsZero() && since.After(until) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start_date must not be after end_date"})
+		return since, until, false
+	}
+	return since, until, true
+}
+
+// writeAuthorStats answers with the report as JSON, or as a CSV download
+// with format=csv
+func (h *RepositoryStatsHandlers) writeAuthorStats(c *gin.Context, report *services.AuthorCodeFrequency, filename string) {
+	if c.DefaultQuery("format", "json") != "csv" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	data, err := report.CSV()
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to export author statistics")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export author statistics"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s-code-frequency.csv", filename))
+	c.Data(http.StatusOK, "text/csv", data)
+}
+
+// GetAuthorCodeFrequency handles GET /api/v1/repositories/{owner}/{repo}/stats/code_frequency/authors
+//
+// Returns each author's weekly additions, deletions and commits between
+// start_date and end_date, or 202 Accepted while they are being computed.
+func (h *RepositoryStatsHandlers) GetAuthorCodeFrequency(c *gin.Context) {
+	repo, ok := readableRepository(c)
+	if !ok {
+		return
+	}
+	since, until, ok := authorStatsRange(c)
+	if !ok {
+		return
+	}
+
+	report, ready, err := h.statsService.AuthorCodeFrequency(c.Request.Context(), repo.ID, since, until)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get author code frequency")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get author code frequency"})
+		return
+	}
+	if !ready {
+		statsPending(c)
+		return
+	}
+	h.writeAuthorStats(c, report, repo.Name)
+}
+
+// GetOrganizationAuthorCodeFrequency handles GET /api/v1/organizations/{org}/stats/code_frequency/authors
+//
+// Like GetAuthorCodeFrequency across every repository of the organization;
+// limited to organization owners and admins.
+func (h *RepositoryStatsHandlers) GetOrganizationAuthorCodeFrequency(c *gin.Context) {
+	actorID, ok := actor(c)
+	if !ok {
+		return
+	}
+	since, until, ok := authorStatsRange(c)
+	if !ok {
+		return
+	}
+
+	report, ready, err := h.statsService.OrganizationAuthorCodeFrequency(c.Request.Context(), c.Param("org"), actorID, since, until)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return
+	case errors.Is(err, services.ErrAuthorStatsForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		h.logger.WithError(err).Error("Failed to get organization author code frequency")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get author code frequency"})
+		return
+	}
+	if !ready {
+		statsPending(c)
+		return
+	}
+	h.writeAuthorStats(c, report, c.Param("org"))
+}
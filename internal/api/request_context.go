@@ -0,0 +1,104 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/i18n"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// actor returns the authenticated user, writing an error response when there is none
+func actor(c *gin.Context) (uuid.UUID, bool) {
+	userIDInterface, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.For(c.Request.Context()).T("error.not_authenticated")})
+		return uuid.Nil, false
+	}
+	userID, err := parseUserID(userIDInterface)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.For(c.Request.Context()).T("error.invalid_user_id")})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// authenticatedUser returns the authenticated user without writing a
+// response, for handlers that report a missing user their own way
+func authenticatedUser(c *gin.Context) (uuid.UUID, bool) {
+	userIDInterface, exists := c.Get("user_id")
+	if !exists {
+		return uuid.Nil, false
+	}
+	userID, err := parseUserID(userIDInterface)
+	if err != nil {
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// optionalActor returns the authenticated user on routes that also serve
+// anonymous requests, or nil
+func optionalActor(c *gin.Context) *uuid.UUID {
+	if userID, ok := authenticatedUser(c); ok {
+		return &userID
+	}
+	return nil
+}
+
+// tenantRepository returns the repository the tenant middleware resolved
+// from the path. Private repositories are reported missing to callers who
+// cannot read them; readers without permission are refused.
+func tenantRepository(c *gin.Context, permission models.Permission) (*models.Repository, bool) {
+	t, ok := tenant.FromContext(c.Request.Context())
+	if !ok || !t.MatchesRepository(c.Param("owner"), strings.TrimSuffix(c.Param("repo"), ".git")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		return nil, false
+	}
+	if !t.HasPermission(models.PermissionRead) && t.Repository.Visibility != models.VisibilityPublic {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		return nil, false
+	}
+	if !t.HasPermission(permission) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient repository permissions"})
+		return nil, false
+	}
+	return t.Repository, true
+}
+
+// readableRepository is tenantRepository for endpoints anyone may read on
+// public repositories
+func readableRepository(c *gin.Context) (*models.Repository, bool) {
+	t, ok := tenant.FromContext(c.Request.Context())
+	if ok && t.MatchesRepository(c.Param("owner"), strings.TrimSuffix(c.Param("repo"), ".git")) &&
+		t.Repository.Visibility == models.VisibilityPublic {
+		return t.Repository, true
+	}
+	return tenantRepository(c, models.PermissionRead)
+}
+
+// tenantPullRequest returns the pull request numbered in the path of the
+// tenant repository, checking permission as tenantRepository does
+func tenantPullRequest(c *gin.Context, pullRequests services.PullRequestService, permission models.Permission) (*models.PullRequest, bool) {
+	repo, ok := tenantRepository(c, permission)
+	if !ok {
+		return nil, false
+	}
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pull request number"})
+		return nil, false
+	}
+
+	pr, err := pullRequests.GetByNumber(c.Request.Context(), repo.ID, number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pull request not found"})
+		return nil, false
+	}
+	return pr, true
+}
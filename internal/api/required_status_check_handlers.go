@@ -0,0 +1,141 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// RequiredStatusCheckHandlers serves repository-level required status check patterns
+type RequiredStatusCheckHandlers struct {
+	statusCheckService services.RequiredStatusCheckService
+	logger             *logrus.Logger
+}
+
+func NewRequiredStatusCheckHandlers(statusCheckService services.RequiredStatusCheckService, logger *logrus.Logger) *RequiredStatusCheckHandlers {
+	return &RequiredStatusCheckHandlers{
+		statusCheckService: statusCheckService,
+		logger:             logger,
+	}
+}
+
+func (h *RequiredStatusCheckHandlers) checkError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrRequiredStatusCheckNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRequiredStatusCheck):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListRequiredStatusChecks handles GET /api/v1/repositories/{owner}/{repo}/required-status-checks
+func (h *RequiredStatusCheckHandlers) ListRequiredStatusChecks(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	checks, err := h.statusCheckService.List(c.Request.Context(), repo.ID)
+	if err != nil {
+		h.checkError(c, err, "Failed to list required status checks")
+		return
+	}
+	c.JSON(http.StatusOK, checks)
+}
+
+// CreateRequiredStatusCheck handles POST /api/v1/repositories/{owner}/{repo}/required-status-checks
+func (h *RequiredStatusCheckHandlers) CreateRequiredStatusCheck(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+
+	var req services.RequiredStatusCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	check, err := h.statusCheckService.Create(c.Request.Context(), repo.ID, req)
+	if err != nil {
+		h.checkError(c, err, "Failed to create required status check")
+		return
+	}
+	c.JSON(http.StatusCreated, check)
+}
+
+// UpdateRequiredStatusCheck handles PATCH /api/v1/repositories/{owner}/{repo}/required-status-checks/{check_id}
+func (h *RequiredStatusCheckHandlers) UpdateRequiredStatusCheck(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+	checkID, err := uuid.Parse(c.Param("check_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid check ID"})
+		return
+	}
+
+	var req services.RequiredStatusCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	check, err := h.statusCheckService.Update(c.Request.Context(), repo.ID, checkID, req)
+	if err != nil {
+		h.checkError(c, err, "Failed to update required status check")
+		return
+	}
+	c.JSON(http.StatusOK, check)
+}
+
+// DeleteRequiredStatusCheck handles DELETE /api/v1/repositories/{owner}/{repo}/required-status-checks/{check_id}
+func (h *RequiredStatusCheckHandlers) DeleteRequiredStatusCheck(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionAdmin)
+	if !ok {
+		return
+	}
+	checkID, err := uuid.Parse(c.Param("check_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid check ID"})
+		return
+	}
+
+	if err := h.statusCheckService.Delete(c.Request.Context(), repo.ID, checkID); err != nil {
+		h.checkError(c, err, "Failed to delete required status check")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// TestRequiredStatusCheckPattern handles POST /api/v1/repositories/{owner}/{repo}/required-status-checks/test
+// It reports the status contexts a pattern matches, among those reported
+// for sha when given or ever reported in the repository otherwise.
+func (h *RequiredStatusCheckHandlers) TestRequiredStatusCheckPattern(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	var req services.StatusCheckPatternTest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.statusCheckService.TestPattern(c.Request.Context(), repo.ID, req)
+	if err != nil {
+		h.checkError(c, err, "Failed to test status check pattern")
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// RetentionHandlers serves the admin analytics retention endpoints
+type RetentionHandlers struct {
+	retentionService services.RetentionService
+	logger           *logrus.Logger
+}
+
+func NewRetentionHandlers(retentionService services.RetentionService, logger *logrus.Logger) *RetentionHandlers {
+	return &RetentionHandlers{
+		retentionService: retentionService,
+		logger:           logger,
+	}
+}
+
+// GetRetentionPolicy handles GET /api/v1/admin/analytics/retention
+func (h *RetentionHandlers) GetRetentionPolicy(c *gin.Context) {
+	runs, _, err := h.retentionService.ListRuns(c.Request.Context(), 1, 0)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get last purge run")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get retention policy"})
+		return
+	}
+
+	var lastRun *models.RetentionPurgeRun
+	if len(runs) > 0 {
+		lastRun = runs[0]
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"policy":   h.retentionService.Policy(),
+		"last_run": lastRun,
+	})
+}
+
+// ListPurgeRuns handles GET /api/v1/admin/analytics/retention/runs
+func (h *RetentionHandlers) ListPurgeRuns(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "30"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 30
+	}
+
+	runs, total, err := h.retentionService.ListRuns(c.Request.Context(), perPage, (page-1)*perPage)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list purge runs")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list purge runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs":        runs,
+		"total_count": total,
+		"page":        page,
+		"per_page":    perPage,
+	})
+}
+
+// RunPurge handles POST /api/v1/admin/analytics/retention/purge
+func (h *RetentionHandlers) RunPurge(c *gin.Context) {
+	run, err := h.retentionService.RunPurge(c.Request.Context(), models.RetentionPurgeManual, optionalActor(c))
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to apply retention policy")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply retention policy", "run": run})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// ReviewCommentHandlers serves pull request line comments and suggested changes
+type ReviewCommentHandlers struct {
+	reviewCommentService services.ReviewCommentService
+	repositoryService    services.RepositoryService
+	pullRequestService   services.PullRequestService
+	logger               *logrus.Logger
+}
+
+func NewReviewCommentHandlers(reviewCommentService services.ReviewCommentService, repositoryService services.RepositoryService, pullRequestService services.PullRequestService, logger *logrus.Logger) *ReviewCommentHandlers {
+	return &ReviewCommentHandlers{
+		reviewCommentService: reviewCommentService,
+		repositoryService:    repositoryService,
+		pullRequestService:   pullRequestService,
+		logger:               logger,
+	}
+}
+
+// getPullRequest resolves the pull request in the path and checks the caller's permission
+func (h *ReviewCommentHandlers) getPullRequest(c *gin.Context, permission models.Permission) (*models.PullRequest, bool) {
+	if _, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo")); err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return nil, false
+	}
+	if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(permission) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient repository permissions"})
+		return nil, false
+	}
+
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid pull request number"})
+		return nil, false
+	}
+	pr, err := h.pullRequestService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"), number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pull request not found"})
+		return nil, false
+	}
+	return pr, true
+}
+
+func (h *ReviewCommentHandlers) handleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrReviewCommentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSuggestionOutdated):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidReviewComment), errors.Is(err, services.ErrInvalidSuggestion):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListReviewComments handles GET /api/v1/repositories/{owner}/{repo}/pulls/{number}/comments
+func (h *ReviewCommentHandlers) ListReviewComments(c *gin.Context) {
+	pr, ok := h.getPullRequest(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	comments, err := h.reviewCommentService.List(c.Request.Context(), pr)
+	if err != nil {
+		h.handleError(c, err, "Failed to list review comments")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"comments": comments})
+}
+
+// CreateReviewComment handles POST /api/v1/repositories/{owner}/{repo}/pulls/{number}/comments
+// A body containing a ```suggestion block proposes replacing the commented lines.
+func (h *ReviewCommentHandlers) CreateReviewComment(c *gin.Context) {
+	pr, ok := h.getPullRequest(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	var req services.CreateReviewCommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	comment, err := h.reviewCommentService.Create(c.Request.Context(), pr, userID, req)
+	if err != nil {
+		h.handleError(c, err, "Failed to create review comment")
+		return
+	}
+	c.JSON(http.StatusCreated, comment)
+}
+
+// ApplySuggestions handles POST /api/v1/repositories/{owner}/{repo}/pulls/{number}/suggestions/apply
+// It commits a batch of suggestions to the head branch in one commit.
+func (h *ReviewCommentHandlers) ApplySuggestions(c *gin.Context) {
+	var req services.ApplySuggestionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	h.applySuggestions(c, req)
+}
+
+// ApplySuggestion handles POST /api/v1/repositories/{owner}/{repo}/pulls/{number}/comments/{comment_id}/apply-suggestion
+func (h *ReviewCommentHandlers) ApplySuggestion(c *gin.Context) {
+	commentID, err := uuid.Parse(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+
+	var body struct {
+		Message string `json:"message"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+	}
+	h.applySuggestions(c, services.ApplySuggestionsRequest{CommentIDs: []uuid.UUID{commentID}, Message: body.Message})
+}
+
+func (h *ReviewCommentHandlers) applySuggestions(c *gin.Context, req services.ApplySuggestionsRequest) {
+	pr, ok := h.getPullRequest(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	commit, err := h.reviewCommentService.ApplySuggestions(c.Request.Context(), pr, userID, req)
+	if err != nil {
+		h.handleError(c, err, "Failed to apply suggestions")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"commit": commit})
+}
+
+// ResolveReviewThread handles PUT /api/v1/repositories/{owner}/{repo}/pulls/{number}/comments/{comment_id}/resolution
+func (h *ReviewCommentHandlers) ResolveReviewThread(c *gin.Context) {
+	h.setThreadResolution(c, true)
+}
+
+// UnresolveReviewThread handles DELETE /api/v1/repositories/{owner}/{repo}/pulls/{number}/comments/{comment_id}/resolution
+func (h *ReviewCommentHandlers) UnresolveReviewThread(c *gin.Context) {
+	h.setThreadResolution(c, false)
+}
+
+// setThreadResolution lets collaborators with write access and the pull
+// request's author resolve and reopen review threads
+func (h *ReviewCommentHandlers) setThreadResolution(c *gin.Context, resolved bool) {
+	pr, ok := h.getPullRequest(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	t, _ := tenant.FromContext(c.Request.Context())
+	if !t.HasPermission(models.PermissionWrite) && (pr.UserID == nil || *pr.UserID != userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the pull request author and collaborators with write access can resolve conversations"})
+		return
+	}
+	commentID, err := uuid.Parse(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+
+	var comment *models.ReviewComment
+	if resolved {
+		comment, err = h.reviewCommentService.ResolveThread(c.Request.Context(), pr, commentID, userID)
+	} else {
+		comment, err = h.reviewCommentService.UnresolveThread(c.Request.Context(), pr, commentID, userID)
+	}
+	if err != nil {
+		h.handleError(c, err, "Failed to update review thread")
+		return
+	}
+	c.JSON(http.StatusOK, comment)
+}
+
+// GetReviewThreadHistory handles GET /api/v1/repositories/{owner}/{repo}/pulls/{number}/comments/{comment_id}/resolution/history
+func (h *ReviewCommentHandlers) GetReviewThreadHistory(c *gin.Context) {
+	pr, ok := h.getPullRequest(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	commentID, err := uuid.Parse(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID"})
+		return
+	}
+
+	events, err := h.reviewCommentService.ThreadHistory(c.Request.Context(), pr, commentID)
+	if err != nil {
+		h.handleError(c, err, "Failed to get review thread history")
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// ReviewHandlers serves pull request reviews. Pull request lookups and
+// permission checks are shared with the review comment handlers.
+type ReviewHandlers struct {
+	reviewService services.ReviewService
+	comments      *ReviewCommentHandlers
+	logger        *logrus.Logger
+}
+
+func NewReviewHandlers(reviewService services.ReviewService, comments *ReviewCommentHandlers, logger *logrus.Logger) *ReviewHandlers {
+	return &ReviewHandlers{reviewService: reviewService, comments: comments, logger: logger}
+}
+
+func (h *ReviewHandlers) handleError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrReviewNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidReview):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.comments.handleError(c, err, message)
+	}
+}
+
+func (h *ReviewHandlers) reviewID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("review_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid review ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// ListReviews handles GET /api/v1/repositories/{owner}/{repo}/pulls/{number}/reviews
+func (h *ReviewHandlers) ListReviews(c *gin.Context) {
+	pr, ok := h.comments.getPullRequest(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	reviews, err := h.reviewService.List(c.Request.Context(), pr)
+	if err != nil {
+		h.handleError(c, err, "Failed to list reviews")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reviews": reviews})
+}
+
+// GetReview handles GET /api/v1/repositories/{owner}/{repo}/pulls/{number}/reviews/{review_id}
+func (h *ReviewHandlers) GetReview(c *gin.Context) {
+	pr, ok := h.comments.getPullRequest(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	reviewID, ok := h.reviewID(c)
+	if !ok {
+		return
+	}
+
+	review, err := h.reviewService.Get(c.Request.Context(), pr, reviewID)
+	if err != nil {
+		h.handleError(c, err, "Failed to get review")
+		return
+	}
+	c.JSON(http.StatusOK, review)
+}
+
+// ListReviewComments handles GET /api/v1/repositories/{owner}/{repo}/pulls/{number}/reviews/{review_id}/comments
+func (h *ReviewHandlers) ListReviewComments(c *gin.Context) {
+	pr, ok := h.comments.getPullRequest(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	reviewID, ok := h.reviewID(c)
+	if !ok {
+		return
+	}
+
+	comments, err := h.reviewService.ListComments(c.Request.Context(), pr, reviewID)
+	if err != nil {
+		h.handleError(c, err, "Failed to list review comments")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"comments": comments})
+}
+
+// SubmitReview handles POST /api/v1/repositories/{owner}/{repo}/pulls/{number}/reviews
+// Line comments in the body are anchored by line or by diff position.
+func (h *ReviewHandlers) SubmitReview(c *gin.Context) {
+	pr, ok := h.comments.getPullRequest(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+
+	var req services.SubmitReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	review, err := h.reviewService.Submit(c.Request.Context(), pr, userID, req)
+	if err != nil {
+		h.handleError(c, err, "Failed to submit review")
+		return
+	}
+	c.JSON(http.StatusCreated, review)
+}
+
+// DismissReview handles PUT /api/v1/repositories/{owner}/{repo}/pulls/{number}/reviews/{review_id}/dismissals
+func (h *ReviewHandlers) DismissReview(c *gin.Context) {
+	pr, ok := h.comments.getPullRequest(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	reviewID, ok := h.reviewID(c)
+	if !ok {
+		return
+	}
+
+	var req services.DismissReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	review, err := h.reviewService.Dismiss(c.Request.Context(), pr, reviewID, userID, req)
+	if err != nil {
+		h.handleError(c, err, "Failed to dismiss review")
+		return
+	}
+	c.JSON(http.StatusOK, review)
+}
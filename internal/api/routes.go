@@ -1,13 +1,22 @@
 package api
 
 import (
+	"context"
 	"net/http"
+	"time"
 
+	"github.com/a5c-ai/hub/internal/audit"
 	"github.com/a5c-ai/hub/internal/auth"
 	"github.com/a5c-ai/hub/internal/config"
 	"github.com/a5c-ai/hub/internal/controllers"
 	"github.com/a5c-ai/hub/internal/db"
+	"github.com/a5c-ai/hub/internal/encryption"
+	"github.com/a5c-ai/hub/internal/features"
 	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/i18n"
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/logging"
+	"github.com/a5c-ai/hub/internal/malware"
 	"github.com/a5c-ai/hub/internal/middleware"
 	"github.com/a5c-ai/hub/internal/services"
 	"github.com/gin-gonic/gin"
@@ -15,29 +24,47 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logger) {
+// SetupRoutes wires the services and registers the routes. The background
+// schedulers and workers the services need are returned unstarted; the
+// server starts them with its own context. packs restores offloaded packs
+// before git reads a repository and may be nil when offloading is off.
+func SetupRoutes(router *gin.Engine, database *db.Database, packs *git.PackStore, logger *logrus.Logger) *jobs.Background {
 	cfg, _ := config.Load()
+
+	// Each area of the server logs through its own logger so that levels can
+	// be tuned per module
+	logs := logging.Default()
+	gitLogger := logs.Module(logging.ModuleGit, logger)
+	analyticsLogger := logs.Module(logging.ModuleAnalytics, logger)
+	jobsLogger := logs.Module(logging.ModuleJobs, logger)
+	logger = logs.Module(logging.ModuleAPI, logger)
+
 	jwtManager := auth.NewJWTManager(cfg.JWT)
 
 	// Initialize authentication services
 	authService := auth.NewAuthService(database.DB, jwtManager, cfg)
 	oauthService := auth.NewOAuthService(database.DB, jwtManager, cfg, authService)
-	mfaService := auth.NewMFAService(database.DB)
+	mfaService := auth.NewMFAService(database.DB).
+		WithRelyingParty(auth.NewRelyingParty(cfg.Application.BaseURL, cfg.Application.Name))
 	authHandlers := NewAuthHandlers(authService, oauthService, mfaService)
 
 	// Initialize Git services
-	gitService := git.NewGitService(logger)
+	gitService := git.NewGitServiceWithPackStore(packs, gitLogger)
 	repoBasePath := cfg.Storage.RepositoryPath
 	if repoBasePath == "" {
 		repoBasePath = "/repositories"
 	}
 
-	repositoryService := services.NewRepositoryService(database.DB, gitService, logger, repoBasePath)
+	// Forks share their network's objects through a pool instead of copies
+	objectPools := git.NewObjectPools(cfg.Storage.ForkPools, repoBasePath, gitLogger)
+	repositoryService := services.NewRepositoryServiceWithObjectPools(database.DB, gitService, objectPools, logger, repoBasePath)
 	branchService := services.NewBranchService(database.DB, gitService, repositoryService, logger)
 	pullRequestService := services.NewPullRequestService(database.DB, gitService, repositoryService, logger, repoBasePath)
 
-	// Initialize organization services
-	activityService := services.NewActivityService(database.DB)
+	// Initialize organization services. Logged membership and team changes
+	// are delivered to organization webhooks
+	webhookDeliveryService := services.NewWebhookDeliveryService(database.DB, logger)
+	activityService := services.NewOrganizationWebhookActivity(services.NewActivityService(database.DB), database.DB, webhookDeliveryService, logger)
 	orgService := services.NewOrganizationService(database.DB, activityService)
 	memberService := services.NewMembershipService(database.DB, activityService)
 	invitationService := services.NewInvitationService(database.DB, activityService)
@@ -54,28 +81,320 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 	// Initialize search service
 	searchService := services.NewSearchService(database.DB, elasticsearchService, logger)
 
-	// Initialize analytics service
-	analyticsService := services.NewAnalyticsService(database.DB, logger)
+	// Background schedulers run on one elected replica at a time
+	background := jobs.NewBackground(jobs.NewElector(database.DB, jobsLogger))
+
+	// Redis, when enabled, is shared by rate limiting and the job queue
+	var redisService *services.RedisService
+	if cfg.Redis.Enabled {
+		redisService, err = services.NewRedisService(cfg.Redis, logger)
+		if err != nil {
+			logger.WithError(err).Fatal("failed to connect to Redis")
+		}
+	}
+
+	// Asynchronous work is queued in the database and run by the workers of
+	// every replica that has any; one replica purges finished jobs
+	var jobWaker jobs.Waker
+	if cfg.Jobs.Driver == "redis" {
+		if redisService == nil {
+			logger.Fatal("the redis job driver requires redis to be enabled")
+		}
+		jobWaker = jobs.NewRedisWaker(redisService.GetClient(), jobsLogger)
+	}
+	jobQueue := jobs.NewQueue(database.DB, jobWaker, time.Duration(cfg.Jobs.PollIntervalSeconds)*time.Second, jobsLogger)
+	if cfg.Jobs.Workers > 0 {
+		background.Go(func(ctx context.Context) {
+			jobQueue.Start(ctx, cfg.Jobs.Workers)
+		})
+	}
+	if cfg.Jobs.RetentionDays > 0 {
+		background.Elected("job_cleanup", func(ctx context.Context) {
+			jobQueue.StartPurger(ctx, time.Duration(cfg.Jobs.RetentionDays)*24*time.Hour)
+		})
+	}
+	jobHandlers := NewJobHandlers(jobQueue, logger)
+	webhookDeliveryService.UseQueue(jobQueue)
+
+	// Heavy background jobs wait until their class policy lets them run,
+	// relative to the maintenance windows admins define
+	maintenanceWindowService := services.NewMaintenanceWindowService(database.DB, jobsLogger)
+	jobs.SetDefaultGate(maintenanceWindowService)
+
+	// Security-relevant actions recorded through audit.Record land in the
+	// hash-chained audit trail, which is purged and streamed in the background
+	auditService, err := services.NewAuditService(database.DB, cfg.Audit, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to initialize audit trail")
+	}
+	audit.SetDefaultRecorder(auditService)
+	background.Elected("audit_trail", auditService.StartScheduler)
+	auditHandlers := NewAuditHandlers(auditService, logger)
+
+	// Old daily analytics snapshots are compacted into monthly rollups, with
+	// the raw rows offloaded to object storage
+	analyticsArchiveBackend, err := newAnalyticsArchiveBackend(cfg.AnalyticsArchive, repoBasePath)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to initialize analytics archive storage")
+	}
+	analyticsArchiveService := services.NewAnalyticsArchiveService(database.DB, analyticsArchiveBackend, cfg.AnalyticsArchive, analyticsLogger)
+	background.Elected("analytics_compaction", analyticsArchiveService.StartScheduler)
+
+	// Initialize analytics service; daily aggregation runs as a queued job
+	analyticsService := services.NewAnalyticsService(database.DB, analyticsArchiveService, analyticsLogger)
+	analyticsService.UseQueue(jobQueue)
+
+	// Repository feature previews back features.Enabled for every handler
+	// and service; toggles are tracked in analytics
+	featurePreviewService := services.NewFeaturePreviewService(database.DB, analyticsService, cfg.FeaturePreviews, logger)
+	features.SetDefaultChecker(featurePreviewService)
+
+	// Analytics retention purges run in the background on their own interval
+	retentionService := services.NewRetentionService(database.DB, cfg.Retention, analyticsLogger)
+	background.Elected("analytics_retention", retentionService.StartScheduler)
+
+	// Opt-in traces of repository permission decisions, kept for a few days
+	authorizationTraceService := services.NewAuthorizationTraceService(database.DB, permissionService, repositoryService, cfg.AuthorizationTrace, logger)
+	background.Elected("authorization_trace_retention", authorizationTraceService.StartScheduler)
+
+	// Opt-in anonymized usage reports for fleet management
+	telemetryService := services.NewTelemetryService(database.DB, cfg, logger)
+	background.Elected("telemetry", telemetryService.StartScheduler)
 
 	// Initialize notification service for real-time push
 	notificationService := services.NewNotificationService()
+	webhookDeliveryService.EnableFailureAlerts(notificationService, cfg.IntegrationAlerts)
+
+	// Initialize organization digest emails and their hourly scheduler
+	digestService := services.NewDigestService(database.DB, auth.NewSMTPEmailService(cfg), i18n.Default(), logger, cfg.Application.BaseURL)
+	background.Every("organization_digests", time.Hour, digestService.SendScheduledDigests)
+
+	// Per-user notification threads for mentions, review requests,
+	// assignments, failing CI and watched repositories, with email digests
+	notificationInboxService := services.NewNotificationInboxService(database.DB, permissionService, notificationService, auth.NewSMTPEmailService(cfg), i18n.Default(), logger, cfg.Application.BaseURL)
+	background.Every("notification_digests", time.Hour, notificationInboxService.SendScheduledDigests)
+	notificationHandlers := NewNotificationHandlers(notificationInboxService, logger)
+
+	// Repositories are repacked in the background, tuned to how they are used
+	repositoryMaintenanceService := services.NewRepositoryMaintenanceService(database.DB, repositoryService, packs, cfg.Storage.Maintenance, jobsLogger)
+
+	// Periodic maintenance; every replica runs the scheduler and each run is
+	// claimed by one of them, so no task runs twice at once
+	scheduledTaskService := services.NewScheduledTaskService(database.DB, jobsLogger)
+	tasks := cfg.ScheduledTasks
+	if tasks.AggregateMetricsMinutes > 0 {
+		scheduledTaskService.Register(services.MaintenanceTask{
+			Name:     "aggregate_metrics",
+			Interval: time.Duration(tasks.AggregateMetricsMinutes) * time.Minute,
+			Run: func(ctx context.Context) error {
+				return analyticsService.QueueAggregation(ctx, services.PeriodDaily)
+			},
+		})
+	}
+	if tasks.RepositoryStatsMinutes > 0 {
+		scheduledTaskService.Register(services.MaintenanceTask{
+			Name:     "repository_stats",
+			Interval: time.Duration(tasks.RepositoryStatsMinutes) * time.Minute,
+			Class:    jobs.ClassReindex,
+			Run: func(ctx context.Context) error {
+				_, err := repositoryService.RefreshStaleRepositoryStats(ctx, time.Duration(tasks.RepositoryStatsMaxAgeHours)*time.Hour, tasks.RepositoryStatsBatchSize)
+				return err
+			},
+		})
+	}
+	if cfg.Storage.Maintenance.Enabled {
+		gcInterval := time.Duration(cfg.Storage.Maintenance.IntervalMinutes) * time.Minute
+		if gcInterval <= 0 {
+			gcInterval = time.Hour
+		}
+		scheduledTaskService.Register(services.MaintenanceTask{
+			Name:     "repository_gc",
+			Interval: gcInterval,
+			Class:    jobs.ClassGC,
+			Run: func(ctx context.Context) error {
+				repositoryMaintenanceService.RunScheduled(ctx)
+				return ctx.Err()
+			},
+		})
+	}
+	if tasks.StorageCleanupMinutes > 0 {
+		scheduledTaskService.Register(services.MaintenanceTask{
+			Name:     "orphaned_storage_cleanup",
+			Interval: time.Duration(tasks.StorageCleanupMinutes) * time.Minute,
+			Class:    jobs.ClassGC,
+			Run:      repositoryService.CleanupRepositoryStorage,
+		})
+	}
+	if tasks.TokenExpiryMinutes > 0 {
+		tokenExpiryService := services.NewTokenExpiryService(database.DB, auth.NewSMTPEmailService(cfg), i18n.Default(), logger, time.Duration(tasks.TokenExpiryWarningDays)*24*time.Hour)
+		scheduledTaskService.Register(services.MaintenanceTask{
+			Name:     "token_expiry_notifications",
+			Interval: time.Duration(tasks.TokenExpiryMinutes) * time.Minute,
+			Run: func(ctx context.Context) error {
+				_, err := tokenExpiryService.SendExpiryNotifications(ctx, time.Now())
+				return err
+			},
+		})
+	}
+	// Every push is replicated to object storage for disaster recovery;
+	// replicas are caught up and verified as scheduled tasks
+	dr := cfg.Storage.DisasterRecovery
+	replicator, err := git.NewReplicatorFromConfig(dr, packs, jobsLogger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize disaster recovery replication")
+	}
+	disasterRecoveryService := services.NewDisasterRecoveryService(database.DB, repositoryService, replicator, jobsLogger)
+	if replicator != nil {
+		disasterRecoveryService.UseQueue(jobQueue)
+		if dr.CatchUpIntervalMinutes > 0 {
+			scheduledTaskService.Register(services.MaintenanceTask{
+				Name:     "dr_catch_up",
+				Interval: time.Duration(dr.CatchUpIntervalMinutes) * time.Minute,
+				Run: func(ctx context.Context) error {
+					_, err := disasterRecoveryService.CatchUp(ctx, dr.CatchUpBatchSize)
+					return err
+				},
+			})
+		}
+		if dr.VerifyIntervalMinutes > 0 {
+			scheduledTaskService.Register(services.MaintenanceTask{
+				Name:     "dr_verification",
+				Interval: time.Duration(dr.VerifyIntervalMinutes) * time.Minute,
+				Run: func(ctx context.Context) error {
+					_, err := disasterRecoveryService.Verify(ctx)
+					return err
+				},
+			})
+		}
+	}
+	background.Go(scheduledTaskService.StartScheduler)
+	scheduledTaskHandlers := NewScheduledTaskHandlers(scheduledTaskService, logger)
+	disasterRecoveryHandlers := NewDisasterRecoveryHandlers(disasterRecoveryService, logger)
+
+	// Post-receive listeners; the symbol index and repository stats are
+	// refreshed, and commits are linked to the issues they reference, on
+	// every push. The aggregator records one grouped event per push and
+	// fans it out to webhooks
+	pushDispatcher := services.NewPushDispatcher(logger)
+	pushDispatcher.Subscribe(services.NewPushAggregator(database.DB, webhookDeliveryService, logger).HandlePush)
+	pullRequestService.Subscribe(services.NewPullRequestNotifier(webhookDeliveryService, notificationService, logger).HandlePullRequest)
+	pullRequestService.Subscribe(notificationInboxService.HandlePullRequest)
+	// Failed deliveries are retried with exponential backoff
+	background.Elected("webhook_retries", webhookDeliveryService.StartRetryScheduler)
+	symbolService := services.NewSymbolService(database.DB, gitService, repositoryService, cfg.Symbols, logger)
+	pushDispatcher.Subscribe(symbolService.HandlePush)
+	// Pushes to default branches reindex only the files they changed
+	codeSearchService, err := services.NewCodeSearchService(database.DB, gitService, repositoryService, cfg.CodeSearch, cfg.Elasticsearch, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Failed to initialize code search")
+	}
+	pushDispatcher.Subscribe(codeSearchService.HandlePush)
+	repositoryStatsService := services.NewRepositoryStatsService(database.DB, repositoryService, logger)
+	repositoryStatsService.UseQueue(jobQueue)
+	pushDispatcher.Subscribe(repositoryStatsService.HandlePush)
+	issueLinkService := services.NewIssueLinkService(database.DB, gitService, repositoryService, logger)
+	pushDispatcher.Subscribe(issueLinkService.HandlePush)
+	pushDispatcher.Subscribe(disasterRecoveryService.HandlePush)
+
+	// Repository cron schedules emit schedule events to webhooks
+	repositoryScheduleService := services.NewRepositoryScheduleService(database.DB, webhookDeliveryService, cfg.Schedules, jobsLogger)
+	background.Elected("repository_schedules", repositoryScheduleService.StartScheduler)
+
+	// Merged and stale branches are deleted in the background for
+	// repositories that opted in
+	branchCleanupService := services.NewBranchCleanupService(database.DB, gitService, repositoryService, cfg.BranchCleanup, jobsLogger)
+	background.Elected("branch_cleanup", branchCleanupService.StartScheduler)
 
 	// Initialize handlers
-	repoHandlers := NewRepositoryHandlers(repositoryService, branchService, gitService, logger, database.DB)
-	gitHandlers := NewGitHandlers(repositoryService, logger, jwtManager)
+	// Commit and tag signatures are verified against the keys users upload
+	signingKeyService := services.NewSigningKeyService(database.DB, logger)
+	repositoryImportService := services.NewRepositoryImportService(database.DB, gitService, repositoryService, cfg.Storage.Imports, logger)
+	// Organizations may require approval to create and delete repositories
+	repositoryApprovalService := services.NewRepositoryApprovalService(database.DB, repositoryService, activityService, notificationService, logger)
+	repoHandlers := NewRepositoryHandlers(repositoryService, branchService, symbolService, gitService, signingKeyService, repositoryImportService, repositoryApprovalService, logger, database.DB)
+	gitHandlers := NewGitHandlers(repositoryService, packs, pushDispatcher, logger, jwtManager)
+	symbolHandlers := NewSymbolHandlers(symbolService, repositoryService, logger)
+	codeSearchHandlers := NewCodeSearchHandlers(services.NewCodeIndexService(database.DB, symbolService, logger), codeSearchService, repositoryService, logger)
+	repositoryStatsHandlers := NewRepositoryStatsHandlers(repositoryStatsService, logger)
+	repositoryArchiveHandlers := NewRepositoryArchiveHandlers(repositoryService, gitService, logger)
+	repositoryMaintenanceHandlers := NewRepositoryMaintenanceHandlers(repositoryMaintenanceService, repositoryService, logger)
+	forkNetworkHandlers := NewForkNetworkHandlers(services.NewForkNetworkService(database.DB, repositoryService, objectPools, logger), logger)
+	maintenanceWindowHandlers := NewMaintenanceWindowHandlers(maintenanceWindowService, logger)
+	issueService := services.NewIssueService(database.DB, logger)
+	issueService.Subscribe(services.NewIssueNotifier(webhookDeliveryService, logger).HandleIssue)
+	issueService.Subscribe(notificationInboxService.HandleIssue)
+	issueHandlers := NewIssueHandlers(issueService, issueLinkService, repositoryService, permissionService, database.DB, logger)
+	labelHandlers := NewLabelHandlers(services.NewLabelService(database.DB, logger), logger)
+
+	// Onboarding checklists and sample repositories for new users and organizations
+	onboardingService := services.NewOnboardingService(database.DB, gitService, repositoryService, issueService, pullRequestService, logger)
+	onboardingHandlers := NewOnboardingHandlers(onboardingService, database.DB, logger)
+
+	// Initialize self-hosted runner service
+	runnerService := services.NewRunnerService(database.DB, logger)
+	runnerHandlers := NewRunnerHandlers(runnerService, repositoryService, database.DB, logger)
 	prHandlers := NewPullRequestHandlers(pullRequestService, logger)
+	reviewCommentHandlers := NewReviewCommentHandlers(services.NewReviewCommentService(database.DB, gitService, repositoryService, logger), repositoryService, pullRequestService, logger)
+	reviewHandlers := NewReviewHandlers(services.NewReviewService(database.DB, gitService, repositoryService, logger), reviewCommentHandlers, logger)
 	searchHandlers := NewSearchHandlers(searchService, logger)
 
-	userHandlers := NewUserHandlers(authService, database.DB, cfg, logger, notificationService)
+	userHandlers := NewUserHandlers(authService, database.DB, cfg, logger, notificationService, i18n.Default())
 	adminEmailHandlers := NewAdminEmailHandlers(database.DB, cfg, logger)
-	activityHandlers := NewActivityHandlers(repositoryService, activityService, database.DB, logger)
-	// Initialize webhook and deploy key services for hooks handlers
-	webhookDeliveryService := services.NewWebhookDeliveryService(database.DB, logger)
+	externalCollaboratorHandlers := NewExternalCollaboratorHandlers(services.NewExternalCollaboratorService(database.DB, logger), logger)
+	watchService := services.NewWatchService(database.DB, permissionService, logger)
+	activityHandlers := NewActivityHandlers(repositoryService, activityService, watchService, database.DB, logger)
+	// Commit statuses reported by CI feed the GitHub shim and README badges
+	commitStatusService := services.NewCommitStatusService(database.DB, logger)
+	commitStatusHandlers := NewCommitStatusHandlers(commitStatusService, services.NewCheckRunService(database.DB, logger), repositoryService, gitService, logger)
+	// Workflows in .hub/workflows run on self-hosted runners for pushes and
+	// pull requests and report their jobs as commit statuses
+	workflowService := services.NewWorkflowService(database.DB, gitService, repositoryService, runnerService, commitStatusService, logger)
+	pushDispatcher.Subscribe(workflowService.HandlePush)
+	pullRequestService.Subscribe(workflowService.HandlePullRequest)
+	runnerService.Subscribe(workflowService.HandleRunnerJob)
+	workflowService.Subscribe(notificationInboxService.HandleWorkflowRun)
+	workflowHandlers := NewWorkflowHandlers(workflowService, logger)
+	codeScanningHandlers := NewCodeScanningHandlers(services.NewCodeScanningService(database.DB, gitService, repositoryService, commitStatusService, logger), pullRequestService, logger)
+	// Initialize deploy key service for hooks handlers
 	deployKeyService := services.NewDeployKeyService(database.DB, logger)
 	hooksHandlers := NewHooksHandlers(repositoryService, webhookDeliveryService, deployKeyService, logger)
+	organizationHooksHandlers := NewOrganizationHooksHandlers(database.DB, webhookDeliveryService, logger)
+	activityFeedHandlers := NewActivityFeedHandlers(services.NewActivityFeedService(database.DB, permissionService, logger), logger)
+	activityExportHandlers := NewActivityExportHandlers(services.NewActivityExportService(database.DB, logger), repositoryService, logger)
 	branchProtectionHandlers := NewBranchProtectionHandlers(repositoryService, branchService, logger)
-	analyticsHandlers := NewAnalyticsHandlers(analyticsService, logger, database.DB)
+	timeTrackingHandlers := NewTimeTrackingHandlers(services.NewTimeTrackingService(database.DB, logger), services.NewMilestoneService(database.DB, logger), issueService, logger)
+	repositoryScheduleHandlers := NewRepositoryScheduleHandlers(repositoryScheduleService, logger)
+	pathProtectionHandlers := NewPathProtectionHandlers(services.NewPathProtectionService(database.DB, gitService, repositoryService, logger), pullRequestService, logger)
+	requiredStatusCheckHandlers := NewRequiredStatusCheckHandlers(services.NewRequiredStatusCheckService(database.DB, logger), logger)
+	branchCleanupHandlers := NewBranchCleanupHandlers(branchCleanupService, logger)
+	featurePreviewHandlers := NewFeaturePreviewHandlers(featurePreviewService, logger)
+	mergeChecklistHandlers := NewMergeChecklistHandlers(services.NewMergeChecklistService(database.DB, logger), pullRequestService, logger)
+	changeManagementHandlers := NewChangeManagementHandlers(services.NewChangeManagementService(database.DB, logger), pullRequestService, logger)
+	preferencesService := services.NewUserPreferencesService(database.DB, logger)
+	analyticsHandlers := NewAnalyticsHandlers(analyticsService, preferencesService, logger, database.DB)
+	preferencesHandlers := NewUserPreferencesHandlers(preferencesService, logger)
+	retentionHandlers := NewRetentionHandlers(retentionService, logger)
+	analyticsArchiveHandlers := NewAnalyticsArchiveHandlers(analyticsArchiveService, logger)
+	telemetryHandlers := NewTelemetryHandlers(telemetryService, logger)
+	authorizationTraceHandlers := NewAuthorizationTraceHandlers(authorizationTraceService, logger)
+	// Secrets are encrypted at rest with the process wide keyring set up at startup
+	secretEncryptionService := services.NewSecretEncryptionService(database.DB, encryption.Default(), cfg.Encryption.RotationBatchSize, logger)
+	encryptionHandlers := NewEncryptionHandlers(secretEncryptionService, logger)
+	loggingHandlers := NewLoggingHandlers(logger)
 	sshKeyHandlers := NewSSHKeyHandlers(database.DB, logger)
+	signingKeyHandlers := NewSigningKeyHandlers(signingKeyService, logger)
+	// Fine-grained tokens authenticate API calls limited to selected repositories
+	fineGrainedTokenService := services.NewFineGrainedTokenService(database.DB, logger)
+	fineGrainedTokenHandlers := NewFineGrainedTokenHandlers(fineGrainedTokenService, logger)
+	// The hub as an OAuth2/OpenID Connect provider for registered applications
+	oauthProviderService, err := services.NewOAuthProviderService(database.DB, cfg.OAuth.Provider, cfg.Application.BaseURL, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to initialize OAuth provider")
+	}
+	oauthProviderHandlers := NewOAuthProviderHandlers(oauthProviderService, logger)
+	credentialAuditHandlers := NewCredentialAuditHandlers(services.NewCredentialAuditService(database.DB, oauthProviderService, logger), logger)
+	securityOverviewHandlers := NewSecurityOverviewHandlers(services.NewSecurityOverviewService(database.DB), logger)
+	commitVerificationHandlers := NewCommitVerificationHandlers(services.NewCommitVerificationReportService(database.DB, logger), logger)
 	adminHandlers := NewAdminHandlers(authService, database.DB, logger)
 
 	// Initialize plugin service and handlers
@@ -83,7 +402,18 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 	pluginHandlers := NewPluginHandlers(pluginService)
 
 	// Initialize import/export handlers
-	importHandlers := NewImportHandlers(database)
+	importHandlers := NewImportHandlers(services.NewImportService(database.DB, repositoryService, cfg.Storage.Imports, logger), logger)
+	digestHandlers := NewDigestHandlers(digestService, logger)
+	repositoryPolicyHandlers := NewRepositoryPolicyHandlers(services.NewRepositoryPolicyService(database.DB, logger), logger)
+	repositoryBaselineHandlers := NewRepositoryBaselineHandlers(services.NewRepositoryBaselineService(database.DB, logger), logger)
+	// Organizations may require members to sign in through their identity provider
+	organizationSSOService := services.NewOrganizationSSOService(database.DB, jwtManager, auth.NewSessionService(database.DB), cfg.JWT, cfg.Application.BaseURL, logger)
+	organizationSSOHandlers := NewOrganizationSSOHandlers(organizationSSOService, logger)
+	twoFactorPolicyService := services.NewTwoFactorPolicyService(database.DB)
+	// Identity providers provision members and teams over SCIM
+	scimHandlers := NewSCIMHandlers(services.NewSCIMService(database.DB, auth.NewSessionService(database.DB), cfg.Application.BaseURL, logger), logger)
+	repositoryApprovalHandlers := NewRepositoryApprovalHandlers(repositoryApprovalService, logger)
+	dashboardHandlers := NewDashboardHandlers(services.NewDashboardService(database.DB, analyticsService, logger), logger)
 	exportHandlers := NewExportHandlers(database)
 
 	orgController := controllers.NewOrganizationController(orgService, memberService, invitationService, activityService)
@@ -101,36 +431,208 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 		c.JSON(http.StatusOK, gin.H{
 			"status":    "healthy",
 			"timestamp": "2024-01-01T00:00:00Z",
-			"version":   "1.0.0",
+			"version":   cfg.Application.Version,
 		})
 	})
 
-	// Git HTTP protocol endpoints (no authentication required for public repos)
+	// Release assets, LFS objects and uploaded files are scanned for malware;
+	// admins review quarantined detections
+	scanner, err := malware.New(cfg.MalwareScanning)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to initialize malware scanning")
+	}
+	malwareService := services.NewMalwareScanService(database.DB, scanner, cfg.MalwareScanning, logger)
+
+	// Git LFS objects are stored once per OID and linked to repositories
+	lfsBackend, err := newLFSBackend(cfg.LFS, repoBasePath)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to initialize Git LFS storage")
+	}
+	lfsService := services.NewLFSService(database.DB, lfsBackend, malwareService, cfg.LFS, logger)
+	lfsHandlers := NewLFSHandlers(lfsService, repositoryService, logger)
+
+	// Release assets are kept in their own storage backend
+	releaseBackend, err := newReleaseBackend(cfg.Storage.Releases, repoBasePath)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to initialize release storage")
+	}
+	releaseService := services.NewReleaseService(database.DB, gitService, repositoryService, releaseBackend, malwareService, cfg.Storage.Releases, logger)
+	releaseHandlers := NewReleaseHandlers(releaseService, logger)
+	malwareHandlers := NewMalwareHandlers(malwareService, releaseService, lfsService, logger)
+
+	// Container images pushed to the OCI registry belong to repositories;
+	// blobs no manifest refers to are collected in the background
+	registryBackend, err := newRegistryBackend(cfg.Storage.Registry, repoBasePath)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to initialize container registry storage")
+	}
+	registryService := services.NewRegistryService(database.DB, registryBackend, cfg.Storage.Registry, logger)
+	background.Elected("registry_gc", registryService.StartScheduler)
+	registryHandlers := NewRegistryHandlers(registryService, repositoryService, cfg.Storage.Registry, logger)
+
+	// Organization packages published over the npm, Maven and generic
+	// protocols; retention policies prune old versions in the background
+	packageBackend, err := newPackageBackend(cfg.Storage.Packages, repoBasePath)
+	if err != nil {
+		logger.WithError(err).Fatal("failed to initialize package storage")
+	}
+	packageService := services.NewPackageService(database.DB, packageBackend, analyticsService, cfg.Storage.Packages, logger)
+	background.Elected("package_retention", packageService.StartScheduler)
+	packageHandlers := NewPackageHandlers(packageService, cfg.Storage.Packages, cfg.Application.BaseURL, logger)
+
+	// Requests are limited per caller and category; GET /api/v1/rate_limit
+	// reports the limits. Windows are counted in Redis when it is enabled,
+	// so that replicas share them, which shared-nothing mode requires.
+	rateLimitService := services.NewRateLimitService(cfg.RateLimits)
+	if redisService != nil {
+		rateLimitService = services.NewRedisRateLimitService(cfg.RateLimits, redisService.GetClient(), logger)
+	} else if cfg.Cluster.SharedNothing && cfg.RateLimits.Enabled {
+		logger.Fatal("shared-nothing mode counts rate limits in Redis; enable redis or disable rate_limits")
+	}
+	rateLimitHandlers := NewRateLimitHandlers(rateLimitService)
+
+	// Git HTTP protocol and Git LFS endpoints (no authentication required for
+	// public repos). Clients send tokens as the Basic password; fine-grained
+	// and OAuth tokens are scoped by the handlers.
 	git := router.Group("/")
+	git.Use(middleware.BasicTokenAuth())
+	git.Use(middleware.FineGrainedTokenAuth(fineGrainedTokenService))
+	git.Use(middleware.OAuthTokenAuth(oauthProviderService))
+	git.Use(middleware.TenantMiddleware(cfg.Application.BaseURL, jwtManager, repositoryService, orgService, permissionService, authorizationTraceService, logger))
+	git.Use(middleware.OrganizationSSO(organizationSSOService, logger))
+	git.Use(middleware.RateLimit(rateLimitService, services.RateLimitGit))
+	git.Use(middleware.OrganizationTwoFactor(twoFactorPolicyService, logger))
 	git.Use(gitHandlers.GitMiddleware())
 	{
-		git.GET("/:owner/:repo.git/info/refs", gitHandlers.InfoRefs)
-		git.POST("/:owner/:repo.git/git-upload-pack", gitHandlers.UploadPack)
-		git.POST("/:owner/:repo.git/git-receive-pack", gitHandlers.ReceivePack)
+		// :repo carries the .git suffix, which the handlers trim
+		git.GET("/:owner/:repo/info/refs", gitHandlers.InfoRefs)
+		git.POST("/:owner/:repo/git-upload-pack", gitHandlers.UploadPack)
+		git.POST("/:owner/:repo/git-receive-pack", gitHandlers.ReceivePack)
+
+		git.POST("/:owner/:repo/info/lfs/objects/batch", lfsHandlers.Batch)
+		git.PUT("/:owner/:repo/info/lfs/objects/:oid", lfsHandlers.Upload)
+		git.GET("/:owner/:repo/info/lfs/objects/:oid", lfsHandlers.Download)
+		git.POST("/:owner/:repo/info/lfs/objects/verify", lfsHandlers.Verify)
 	}
 
+	// OCI distribution API for container images, authenticated like git.
+	// Image names contain slashes, so one catch-all route serves every
+	// endpoint and RegistryMiddleware exposes the repository to the tenant.
+	registry := router.Group("/v2")
+	registry.Use(middleware.BasicTokenAuth())
+	registry.Use(middleware.FineGrainedTokenAuth(fineGrainedTokenService))
+	registry.Use(middleware.OAuthTokenAuth(oauthProviderService))
+	registry.Use(registryHandlers.RegistryMiddleware())
+	registry.Use(middleware.TenantMiddleware(cfg.Application.BaseURL, jwtManager, repositoryService, orgService, permissionService, authorizationTraceService, logger))
+	registry.Use(middleware.OrganizationSSO(organizationSSOService, logger))
+	registry.Use(middleware.OrganizationTwoFactor(twoFactorPolicyService, logger))
+	registry.Use(middleware.FineGrainedTokenPackageScope())
+	registry.Use(middleware.RateLimit(rateLimitService, services.RateLimitGit))
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		registry.Handle(method, "/*path", registryHandlers.Serve)
+	}
+
+	// Package registry protocols, authenticated like git: npm and Maven
+	// clients send tokens as bearer or Basic credentials
+	packages := router.Group("/api/v1/orgs/:org/packages")
+	packages.Use(middleware.BasicTokenAuth())
+	packages.Use(middleware.FineGrainedTokenAuth(fineGrainedTokenService))
+	packages.Use(middleware.OAuthTokenAuth(oauthProviderService))
+	packages.Use(middleware.TenantMiddleware(cfg.Application.BaseURL, jwtManager, repositoryService, orgService, permissionService, authorizationTraceService, logger))
+	packages.Use(middleware.OrganizationSSO(organizationSSOService, logger))
+	packages.Use(middleware.OrganizationPackagesTwoFactor(twoFactorPolicyService, logger))
+	packages.Use(middleware.FineGrainedTokenPackageScope())
+	packages.Use(middleware.RateLimit(rateLimitService, services.RateLimitGit))
+	for _, method := range []string{http.MethodGet, http.MethodHead, http.MethodPut} {
+		packages.Handle(method, "/npm/*path", packageHandlers.Npm)
+		packages.Handle(method, "/maven/*path", packageHandlers.Maven)
+		packages.Handle(method, "/generic/*path", packageHandlers.Generic)
+	}
+
+	// README badges of public repositories (no authentication)
+	badgeHandlers := NewBadgeHandlers(services.NewBadgeService(gitService, repositoryService, commitStatusService, logger), repositoryService, logger)
+	router.GET("/badges/:owner/:repo/:badge", badgeHandlers.GetBadge)
+
+	// SCIM 2.0 provisioning, authenticated by organization provisioning tokens
+	scim := router.Group("/scim/v2")
+	scim.Use(scimHandlers.Authenticate())
+	{
+		scim.GET("/ServiceProviderConfig", scimHandlers.ServiceProviderConfig)
+		scim.GET("/Users", scimHandlers.ListUsers)
+		scim.POST("/Users", scimHandlers.CreateUser)
+		scim.GET("/Users/:id", scimHandlers.GetUser)
+		scim.PUT("/Users/:id", scimHandlers.ReplaceUser)
+		scim.PATCH("/Users/:id", scimHandlers.PatchUser)
+		scim.DELETE("/Users/:id", scimHandlers.DeleteUser)
+		scim.GET("/Groups", scimHandlers.ListGroups)
+		scim.POST("/Groups", scimHandlers.CreateGroup)
+		scim.GET("/Groups/:id", scimHandlers.GetGroup)
+		scim.PUT("/Groups/:id", scimHandlers.ReplaceGroup)
+		scim.PATCH("/Groups/:id", scimHandlers.PatchGroup)
+		scim.DELETE("/Groups/:id", scimHandlers.DeleteGroup)
+	}
+
+	// OpenID Connect discovery for applications signing users in with the hub
+	router.GET("/.well-known/openid-configuration", oauthProviderHandlers.Discovery)
+
+	// API versions: v1 is stable; v2 carries endpoints whose DTOs changed and
+	// is served alongside v1 until v1 is sunset
+	supportedVersions := []string{middleware.APIVersion1, middleware.APIVersion2}
+	deprecations := middleware.NewDeprecationRegistry()
+	router.GET("/api/versions", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"current":      middleware.APIVersion1,
+			"supported":    supportedVersions,
+			"deprecations": deprecations.Endpoints(),
+		})
+	})
+
+	v2 := router.Group("/api/v2")
+	v2.Use(middleware.APIVersionMiddleware(middleware.APIVersion2, supportedVersions))
+	v2.Use(middleware.TenantMiddleware(cfg.Application.BaseURL, jwtManager, repositoryService, orgService, permissionService, authorizationTraceService, logger))
+	v2.Use(middleware.OrganizationSSO(organizationSSOService, logger))
+	v2.Use(middleware.OrganizationTwoFactor(twoFactorPolicyService, logger))
+	v2.Use(middleware.RateLimit(rateLimitService, ""))
+	v2.Use(middleware.LocaleMiddleware(i18n.Default(), database.DB))
+	{
+		v2.GET("/ping", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"message": "pong", "version": middleware.APIVersion2})
+		})
+	}
+
+	// GitHub REST v3 compatibility shim for tools written against GitHub
+	githubCompatHandlers := NewGitHubCompatHandlers(repositoryService, orgService, issueService, pullRequestService,
+		commitStatusService, webhookDeliveryService, gitService, cfg.Application.BaseURL, database.DB, logger)
+	githubCompat := router.Group("/api/github/v3")
+	githubCompat.GET("/capabilities", githubCompatHandlers.Capabilities)
+	githubCompat.Use(GitHubTokenAuth())
+	githubCompat.Use(middleware.FineGrainedTokenAuth(fineGrainedTokenService))
+	githubCompat.Use(middleware.OAuthTokenAuth(oauthProviderService))
+	githubCompat.Use(middleware.TenantMiddleware(cfg.Application.BaseURL, jwtManager, repositoryService, orgService, permissionService, authorizationTraceService, logger))
+	githubCompat.Use(middleware.OrganizationSSO(organizationSSOService, logger))
+	githubCompat.Use(middleware.OrganizationTwoFactor(twoFactorPolicyService, logger))
+	githubCompat.Use(middleware.FineGrainedTokenScope())
+	githubCompat.Use(middleware.OAuthTokenScope())
+	githubCompat.Use(middleware.AuthMiddleware(jwtManager))
+	githubCompatHandlers.Register(githubCompat)
+
 	v1 := router.Group("/api/v1")
+	v1.Use(middleware.APIVersionMiddleware(middleware.APIVersion1, supportedVersions))
+	v1.Use(middleware.FineGrainedTokenAuth(fineGrainedTokenService))
+	v1.Use(middleware.OAuthTokenAuth(oauthProviderService))
+	v1.Use(middleware.TenantMiddleware(cfg.Application.BaseURL, jwtManager, repositoryService, orgService, permissionService, authorizationTraceService, logger))
+	v1.Use(middleware.RateLimit(rateLimitService, ""))
+	v1.Use(middleware.FineGrainedTokenScope())
+	v1.Use(middleware.OAuthTokenScope())
+	v1.Use(middleware.OrganizationSSO(organizationSSOService, logger))
+	v1.Use(middleware.OrganizationTwoFactor(twoFactorPolicyService, logger))
+	v1.Use(middleware.LocaleMiddleware(i18n.Default(), database.DB))
 	{
-		// Git LFS endpoints (batch API, upload, download, verify)
-		lfsHandlers, err := NewLFSHandlers(cfg.LFS, repoBasePath)
-		if err != nil {
-			logger.WithError(err).Fatal("failed to initialize Git LFS handlers")
-		}
-		lfs := v1.Group("/git-lfs")
-		{
-			lfs.POST("/objects/batch", lfsHandlers.Batch)
-			lfs.POST("/objects/:oid", lfsHandlers.Upload)
-			lfs.GET("/objects/:oid", lfsHandlers.Download)
-			lfs.HEAD("/objects/:oid", lfsHandlers.Verify)
-		}
+		uploadHandlers := NewUploadHandlers(repositoryService, branchService, gitService, lfsService, malwareService, pushDispatcher, cfg.Storage.Uploads, cfg.LFS.UploadThresholdMB, database.DB, logger)
 		v1.GET("/ping", func(c *gin.Context) {
 			c.JSON(http.StatusOK, gin.H{"message": "pong"})
 		})
+		v1.GET("/rate_limit", rateLimitHandlers.GetRateLimit)
 
 		// Plugin marketplace listing (public)
 		v1.GET("/plugins", pluginHandlers.ListPlugins)
@@ -144,6 +646,8 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 			authGroup.POST("/forgot-password", authHandlers.ForgotPassword)
 			authGroup.POST("/reset-password", authHandlers.ResetPassword)
 			authGroup.GET("/verify-email", authHandlers.VerifyEmail)
+			authGroup.POST("/passkeys/login/begin", authHandlers.BeginPasskeyLogin)
+			authGroup.POST("/passkeys/login/finish", authHandlers.FinishPasskeyLogin)
 
 			// OAuth endpoints
 			oauth := authGroup.Group("/oauth")
@@ -161,10 +665,15 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 				// MFA endpoints
 				mfa := protected.Group("/mfa")
 				{
+					mfa.GET("", authHandlers.GetMFAStatus)
 					mfa.POST("/setup", authHandlers.SetupMFA)
 					mfa.POST("/verify", authHandlers.VerifyMFA)
 					mfa.POST("/disable", authHandlers.DisableMFA)
 					mfa.POST("/regenerate-codes", authHandlers.RegenerateBackupCodes)
+					mfa.POST("/webauthn/register/begin", authHandlers.BeginWebAuthnRegistration)
+					mfa.POST("/webauthn/register/finish", authHandlers.FinishWebAuthnRegistration)
+					mfa.GET("/webauthn/credentials", authHandlers.ListWebAuthnCredentials)
+					mfa.DELETE("/webauthn/credentials/:id", authHandlers.DeleteWebAuthnCredential)
 				}
 			}
 		}
@@ -180,9 +689,28 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 		v1.GET("/repositories/:owner/:repo/commits/:sha", repoHandlers.GetCommit)
 		v1.GET("/repositories/:owner/:repo/contents/*path", repoHandlers.GetTree)
 		v1.GET("/repositories/:owner/:repo/info", repoHandlers.GetRepositoryInfo)
+		v1.GET("/repositories/:owner/:repo/tarball/*ref", repositoryArchiveHandlers.GetTarball)
+		v1.GET("/repositories/:owner/:repo/zipball/*ref", repositoryArchiveHandlers.GetZipball)
+		v1.GET("/repositories/:owner/:repo/symbols", symbolHandlers.SearchSymbols)
+		v1.GET("/repositories/:owner/:repo/symbols/status", symbolHandlers.GetSymbolIndexStatus)
+		v1.GET("/repositories/:owner/:repo/search/code", codeSearchHandlers.SearchRepositoryCode)
+		v1.GET("/repositories/:owner/:repo/search/code/status", codeSearchHandlers.GetRepositoryCodeSearchStatus)
+		v1.GET("/repositories/:owner/:repo/stats/code_frequency", repositoryStatsHandlers.GetCodeFrequency)
+		v1.GET("/repositories/:owner/:repo/stats/code_frequency/authors", repositoryStatsHandlers.GetAuthorCodeFrequency)
+		v1.GET("/repositories/:owner/:repo/stats/participation", repositoryStatsHandlers.GetParticipation)
+		v1.GET("/repositories/:owner/:repo/insights/summary", repositoryStatsHandlers.GetInsightsSummary)
+		v1.GET("/repositories/:owner/:repo/lfs", lfsHandlers.GetUsage)
+		v1.GET("/repositories/:owner/:repo/packages/container", registryHandlers.ListImages)
+		v1.GET("/repositories/:owner/:repo/releases", releaseHandlers.ListReleases)
+		v1.GET("/repositories/:owner/:repo/releases/latest", releaseHandlers.GetLatestRelease)
+		v1.GET("/repositories/:owner/:repo/releases/tags/:tag", releaseHandlers.GetReleaseByTag)
+		v1.GET("/repositories/:owner/:repo/releases/assets/:asset_id", releaseHandlers.GetReleaseAsset)
+		v1.GET("/repositories/:owner/:repo/releases/assets/:asset_id/download", releaseHandlers.DownloadReleaseAsset)
+		v1.GET("/repositories/:owner/:repo/releases/:release_id", releaseHandlers.GetRelease)
 
 		// Public search endpoints (for public content)
 		v1.GET("/search", searchHandlers.GlobalSearch)
+		v1.GET("/search/code", codeSearchHandlers.SearchCode)
 
 		// Public user profile endpoints
 		v1.GET("/users/:username", userHandlers.GetUserProfile)
@@ -190,9 +718,48 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 		v1.GET("/users/:username/organizations", userHandlers.GetUserOrganizations)
 		v1.GET("/users/:username/analytics/public", analyticsHandlers.GetPublicUserAnalytics)
 
+		// Public event timelines, filtered to repositories the caller can read
+		v1.GET("/users/:username/events", activityFeedHandlers.GetUserEvents)
+		v1.GET("/organizations/:org/events", activityFeedHandlers.GetOrganizationEvents)
+
+		// OAuth provider endpoints called by applications, authenticated by
+		// client credentials or the access token itself
+		oauthProvider := v1.Group("/oauth")
+		{
+			oauthProvider.POST("/token", oauthProviderHandlers.Token)
+			oauthProvider.POST("/introspect", oauthProviderHandlers.Introspect)
+			oauthProvider.POST("/revoke", oauthProviderHandlers.Revoke)
+			oauthProvider.GET("/userinfo", oauthProviderHandlers.UserInfo)
+			oauthProvider.GET("/jwks", oauthProviderHandlers.JWKS)
+		}
+
+		// Organization single sign-on, started signed in to link an account
+		orgSSO := v1.Group("/organizations/:org/sso")
+		{
+			orgSSO.POST("/login", organizationSSOHandlers.Login)
+			orgSSO.GET("/oidc/callback", organizationSSOHandlers.OIDCCallback)
+			orgSSO.POST("/saml/acs", organizationSSOHandlers.SAMLACS)
+			orgSSO.GET("/saml/metadata", organizationSSOHandlers.SAMLMetadata)
+		}
+
 		// Public invitation acceptance endpoint
 		v1.POST("/invitations/accept", orgController.AcceptInvitation)
 
+		// Digest unsubscribe links are followed from email without a session
+		v1.GET("/digests/unsubscribe", digestHandlers.Unsubscribe)
+
+		// Self-hosted runner agent endpoints, authenticated by runner token
+		v1.POST("/runners/register", runnerHandlers.RegisterRunner)
+		runnerAgent := v1.Group("/runners")
+		runnerAgent.Use(runnerHandlers.RunnerAuth())
+		{
+			runnerAgent.POST("/heartbeat", runnerHandlers.RunnerHeartbeat)
+			runnerAgent.POST("/jobs/acquire", runnerHandlers.AcquireRunnerJob)
+			runnerAgent.POST("/jobs/:job_id/complete", runnerHandlers.CompleteRunnerJob)
+			runnerAgent.POST("/jobs/:job_id/steps", workflowHandlers.UpdateRunnerJobStep)
+			runnerAgent.POST("/jobs/:job_id/logs", workflowHandlers.AppendRunnerJobLog)
+		}
+
 		// Webhook endpoints (no authentication required for system-level webhooks)
 
 		protected := v1.Group("/")
@@ -201,11 +768,17 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 			// Current user profile endpoints
 			protected.GET("/user", userHandlers.GetCurrentUserProfile)
 			protected.PATCH("/user", userHandlers.UpdateUserProfile)
+			protected.GET("/user/preferences", preferencesHandlers.GetPreferences)
+			protected.PATCH("/user/preferences", preferencesHandlers.UpdatePreferences)
 
 			// User activity and notifications
 			protected.GET("/user/activity", userHandlers.GetUserActivity)
-			protected.GET("/notifications", userHandlers.GetNotifications)
-			protected.PATCH("/notifications", userHandlers.MarkNotificationsAsRead)
+			protected.GET("/user/watching/digest", activityHandlers.GetWatchingDigest)
+			protected.GET("/notifications", notificationHandlers.ListNotifications)
+			protected.PATCH("/notifications", notificationHandlers.MarkNotificationsAsRead)
+			protected.GET("/notifications/unread_count", notificationHandlers.GetUnreadCount)
+			protected.PATCH("/notifications/:id", notificationHandlers.MarkNotificationAsRead)
+			protected.DELETE("/notifications/:id", notificationHandlers.DeleteNotification)
 			// Real-time notifications via WebSocket
 			protected.GET("/notifications/subscribe", userHandlers.SubscribeNotifications)
 
@@ -227,7 +800,11 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 			protected.DELETE("/repos/:owner/:repo/plugins/:name/uninstall", pluginHandlers.UninstallRepoPlugin)
 
 			// Legacy profile endpoint for backward compatibility
-			protected.GET("/profile", func(c *gin.Context) {
+			protected.GET("/profile", deprecations.Deprecate(http.MethodGet, "/api/v1/profile", middleware.DeprecationPolicy{
+				Deprecated: time.Date(2026, 10, 15, 0, 0, 0, 0, time.UTC),
+				Sunset:     time.Date(2027, 4, 15, 0, 0, 0, 0, time.UTC),
+				Successor:  "/api/v1/user",
+			}), func(c *gin.Context) {
 				userID, exists := c.Get("user_id")
 				if !exists {
 					c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
@@ -249,17 +826,56 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 			// User's organizations
 			protected.GET("/user/organizations", orgController.GetUserOrganizations)
 
+			// Onboarding checklist
+			protected.GET("/user/onboarding", onboardingHandlers.GetUserOnboarding)
+			protected.POST("/user/onboarding/sample-repository", onboardingHandlers.GenerateUserSampleRepository)
+			protected.POST("/user/onboarding/dismiss", onboardingHandlers.DismissUserOnboarding)
+
 			// User analytics endpoints
 			protected.GET("/user/analytics/activity", analyticsHandlers.GetUserAnalytics)
 			protected.GET("/user/analytics/contributions", analyticsHandlers.GetUserContributions)
 			protected.GET("/user/analytics/repositories", analyticsHandlers.GetUserRepositories)
 
+			// Ad-hoc analytics event queries (site admins)
+			protected.POST("/analytics/query", analyticsHandlers.QueryEvents)
+
 			// SSH Keys management
 			protected.GET("/user/keys", sshKeyHandlers.ListSSHKeys)
 			protected.POST("/user/keys", sshKeyHandlers.CreateSSHKey)
 			protected.GET("/user/keys/:id", sshKeyHandlers.GetSSHKey)
 			protected.DELETE("/user/keys/:id", sshKeyHandlers.DeleteSSHKey)
 
+			// Commit signing keys
+			protected.GET("/user/gpg_keys", signingKeyHandlers.ListGPGKeys)
+			protected.POST("/user/gpg_keys", signingKeyHandlers.AddGPGKey)
+			protected.GET("/user/gpg_keys/:id", signingKeyHandlers.GetGPGKey)
+			protected.DELETE("/user/gpg_keys/:id", signingKeyHandlers.DeleteGPGKey)
+			protected.GET("/user/ssh_signing_keys", signingKeyHandlers.ListSSHSigningKeys)
+			protected.POST("/user/ssh_signing_keys", signingKeyHandlers.AddSSHSigningKey)
+			protected.GET("/user/ssh_signing_keys/:id", signingKeyHandlers.GetSSHSigningKey)
+			protected.DELETE("/user/ssh_signing_keys/:id", signingKeyHandlers.DeleteSSHSigningKey)
+
+			// Personal access tokens, fine-grained or scoped
+			protected.GET("/user/tokens", fineGrainedTokenHandlers.ListTokens)
+			protected.POST("/user/tokens", fineGrainedTokenHandlers.CreateToken)
+			protected.GET("/user/tokens/scopes", fineGrainedTokenHandlers.ListScopes)
+			protected.GET("/user/tokens/:token_id", fineGrainedTokenHandlers.GetToken)
+			protected.DELETE("/user/tokens/:token_id", fineGrainedTokenHandlers.RevokeToken)
+			protected.POST("/tokens/introspect", fineGrainedTokenHandlers.IntrospectToken)
+
+			// OAuth applications and the consent flow
+			protected.GET("/user/applications", oauthProviderHandlers.ListApplications)
+			protected.POST("/user/applications", oauthProviderHandlers.CreateApplication)
+			protected.GET("/user/applications/:app_id", oauthProviderHandlers.GetApplication)
+			protected.PATCH("/user/applications/:app_id", oauthProviderHandlers.UpdateApplication)
+			protected.DELETE("/user/applications/:app_id", oauthProviderHandlers.DeleteApplication)
+			protected.POST("/user/applications/:app_id/secret", oauthProviderHandlers.ResetClientSecret)
+			protected.GET("/user/authorizations", oauthProviderHandlers.ListAuthorizations)
+			protected.DELETE("/user/authorizations/:app_id", oauthProviderHandlers.RevokeAuthorization)
+			protected.GET("/oauth/authorize", oauthProviderHandlers.GetAuthorization)
+			protected.POST("/oauth/authorize", oauthProviderHandlers.Authorize)
+			protected.POST("/oauth/register", oauthProviderHandlers.RegisterClient)
+
 			admin := protected.Group("/admin")
 			admin.Use(middleware.AdminMiddleware())
 			{
@@ -273,6 +889,8 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 				admin.POST("/users/:id/enable", adminHandlers.EnableUser)
 				admin.POST("/users/:id/disable", adminHandlers.DisableUser)
 				admin.PATCH("/users/:id/role", adminHandlers.SetUserRole)
+				admin.GET("/users/external", externalCollaboratorHandlers.ListExternalCollaborators)
+				admin.PATCH("/users/:id/account_type", externalCollaboratorHandlers.SetAccountType)
 
 				// Admin analytics endpoints
 				admin.GET("/analytics/platform", analyticsHandlers.GetPlatformAnalytics)
@@ -280,6 +898,32 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 				admin.GET("/analytics/performance", analyticsHandlers.GetPerformanceAnalytics)
 				admin.GET("/analytics/costs", analyticsHandlers.GetCostAnalytics)
 				admin.GET("/analytics/export", analyticsHandlers.ExportAnalytics)
+				admin.GET("/analytics/retention", retentionHandlers.GetRetentionPolicy)
+				admin.GET("/analytics/retention/runs", retentionHandlers.ListPurgeRuns)
+				admin.POST("/analytics/retention/purge", retentionHandlers.RunPurge)
+				admin.GET("/analytics/archive", analyticsArchiveHandlers.GetArchivePolicy)
+				admin.POST("/analytics/archive/compact", analyticsArchiveHandlers.Compact)
+
+				// Admin telemetry endpoints
+				admin.GET("/telemetry", telemetryHandlers.GetTelemetry)
+				admin.GET("/telemetry/preview", telemetryHandlers.PreviewTelemetry)
+				admin.GET("/telemetry/submissions", telemetryHandlers.ListTelemetrySubmissions)
+				admin.POST("/telemetry/submit", telemetryHandlers.SubmitTelemetry)
+
+				// Permission decision debugging
+				admin.GET("/debug/authorization/traces", authorizationTraceHandlers.ListAuthorizationTraces)
+				admin.GET("/debug/authorization/traces/:trace_id", authorizationTraceHandlers.GetAuthorizationTrace)
+				admin.POST("/debug/authorization/explain", authorizationTraceHandlers.ExplainAuthorization)
+
+				// Secret encryption at rest
+				admin.GET("/encryption", encryptionHandlers.GetEncryptionStatus)
+				admin.POST("/encryption/rotate", encryptionHandlers.RotateEncryptionKeys)
+				admin.POST("/encryption/verify", encryptionHandlers.VerifyEncryptedSecrets)
+
+				// Log pipeline and runtime configuration reloads
+				admin.GET("/logging", loggingHandlers.GetLogging)
+				admin.POST("/logging/audit/verify", loggingHandlers.VerifyAuditLog)
+				admin.POST("/config/reload", loggingHandlers.ReloadConfig)
 
 				// Admin email management endpoints
 				adminEmail := admin.Group("/email")
@@ -291,19 +935,82 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 					adminEmail.GET("/health", adminEmailHandlers.GetEmailHealth)
 				}
 
+				// Instance-wide self-hosted runners
+				admin.GET("/runners", runnerHandlers.ListInstanceRunners)
+				admin.POST("/runners/registration-token", runnerHandlers.CreateInstanceRegistrationToken)
+				admin.DELETE("/runners/:runner_id", runnerHandlers.DeleteInstanceRunner)
+
+				// OAuth application registry
+				admin.GET("/oauth/applications", oauthProviderHandlers.AdminListApplications)
+				admin.PATCH("/oauth/applications/:app_id", oauthProviderHandlers.AdminUpdateApplication)
+
+				// Audit trail of security-relevant actions
+				admin.GET("/audit-log", auditHandlers.ListEntries)
+				admin.POST("/audit-log/verify", auditHandlers.Verify)
+				admin.GET("/audit-log/stream", auditHandlers.GetStreamStatus)
+				admin.POST("/audit-log/stream/flush", auditHandlers.FlushStream)
+
+				// Credential usage audits
+				admin.GET("/security/credentials", credentialAuditHandlers.GetInstanceCredentialReport)
+				admin.POST("/security/credentials/revoke", credentialAuditHandlers.RevokeInstanceCredentials)
+
+				// Repository pack statistics and maintenance
+				admin.GET("/repositories/:owner/:repo/packs", repositoryMaintenanceHandlers.GetPackStatistics)
+				admin.POST("/repositories/:owner/:repo/maintenance", repositoryMaintenanceHandlers.RunMaintenance)
+				admin.GET("/storage/fork-networks", forkNetworkHandlers.GetReport)
+				admin.POST("/registry/gc", registryHandlers.CollectGarbage)
+				admin.POST("/packages/retention", packageHandlers.ApplyRetention)
+
+				// Maintenance windows and when heavy background jobs may run
+				admin.GET("/maintenance/windows", maintenanceWindowHandlers.ListWindows)
+				admin.POST("/maintenance/windows", maintenanceWindowHandlers.CreateWindow)
+				admin.GET("/maintenance/windows/:id", maintenanceWindowHandlers.GetWindow)
+				admin.PATCH("/maintenance/windows/:id", maintenanceWindowHandlers.UpdateWindow)
+				admin.DELETE("/maintenance/windows/:id", maintenanceWindowHandlers.DeleteWindow)
+				admin.GET("/maintenance/policies", maintenanceWindowHandlers.ListPolicies)
+				admin.PUT("/maintenance/policies/:class", maintenanceWindowHandlers.SetPolicy)
+				admin.GET("/maintenance/calendar", maintenanceWindowHandlers.GetCalendar)
+				admin.GET("/maintenance/status", maintenanceWindowHandlers.GetStatus)
+
+				// Background job queue
+				admin.GET("/jobs", jobHandlers.ListJobs)
+				admin.GET("/jobs/stats", jobHandlers.GetJobStats)
+				admin.GET("/jobs/:id", jobHandlers.GetJob)
+				admin.POST("/jobs/:id/retry", jobHandlers.RetryJob)
+				admin.DELETE("/jobs/:id", jobHandlers.CancelJob)
+
+				// Periodic maintenance tasks
+				admin.GET("/scheduled-tasks", scheduledTaskHandlers.ListScheduledTasks)
+				admin.POST("/scheduled-tasks/:name/run", scheduledTaskHandlers.RunScheduledTask)
+
+				// Disaster recovery replication
+				admin.GET("/disaster-recovery", disasterRecoveryHandlers.GetStatus)
+
+				// Feature preview adoption across repositories
+				admin.GET("/feature-previews", featurePreviewHandlers.GetAdoption)
+
+				// Malware detection review queue
+				admin.GET("/malware/detections", malwareHandlers.ListDetections)
+				admin.GET("/malware/detections/:id", malwareHandlers.GetDetection)
+				admin.POST("/malware/detections/:id/review", malwareHandlers.ReviewDetection)
+				admin.PUT("/repositories/:owner/:repo/lfs/quota", lfsHandlers.SetQuota)
+
 				// Storage admin endpoints
 
 			}
 
 			// Protected repository endpoints
 			// Repository import/export endpoints
+			protected.POST("/import", importHandlers.InitiateImport)
+			protected.GET("/import/:import_id", importHandlers.GetImportStatus)
 			protected.POST("/repositories/import", importHandlers.InitiateImport)
-			protected.GET("/repositories/import/:job_id", importHandlers.GetImportStatus)
+			protected.GET("/repositories/import/:import_id", importHandlers.GetImportStatus)
 			protected.POST("/repositories/:owner/:repo/export", exportHandlers.InitiateExport)
 			protected.GET("/repositories/:owner/:repo/export/:job_id", exportHandlers.GetExportStatus)
 
 			// Repository creation endpoint (without group to avoid trailing slash issues)
 			protected.POST("/repositories", repoHandlers.CreateRepository)
+			protected.GET("/repository-imports/:import_id", repoHandlers.GetRepositoryImport)
 
 			repos := protected.Group("/repositories")
 			{
@@ -313,11 +1020,19 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 				// Branch operations
 				repos.POST("/:owner/:repo/branches", repoHandlers.CreateBranch)
 				repos.DELETE("/:owner/:repo/branches/:branch", repoHandlers.DeleteBranch)
+				repos.POST("/:owner/:repo/branches/:branch/rename", repoHandlers.RenameBranch)
+				repos.GET("/:owner/:repo/branch-cleanup", branchCleanupHandlers.GetBranchCleanup)
+				repos.POST("/:owner/:repo/branch-cleanup", branchCleanupHandlers.DeleteBranches)
+				repos.GET("/:owner/:repo/feature-previews", featurePreviewHandlers.ListFeaturePreviews)
+				repos.PUT("/:owner/:repo/feature-previews/:feature", featurePreviewHandlers.SetFeaturePreview)
+				repos.GET("/:owner/:repo/baseline-drift", repositoryBaselineHandlers.GetDrift)
+				repos.POST("/:owner/:repo/baseline-drift/remediate", repositoryBaselineHandlers.RemediateDrift)
 
 				// File operations
 				repos.POST("/:owner/:repo/contents/*path", repoHandlers.CreateFile)
 				repos.PUT("/:owner/:repo/contents/*path", repoHandlers.UpdateFile)
 				repos.DELETE("/:owner/:repo/contents/*path", repoHandlers.DeleteFile)
+				repos.POST("/:owner/:repo/upload", uploadHandlers.UploadFiles)
 
 				// Repository information and statistics
 				repos.GET("/:owner/:repo/stats", repoHandlers.GetRepositoryStats)
@@ -325,6 +1040,39 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 				repos.GET("/:owner/:repo/tags", repoHandlers.GetRepositoryTags)
 				repos.GET("/:owner/:repo/contributors", activityHandlers.GetRepositoryContributors)
 				repos.GET("/:owner/:repo/activity", activityHandlers.GetRepositoryActivity)
+				repos.POST("/:owner/:repo/symbols/reindex", symbolHandlers.ReindexSymbols)
+				repos.POST("/:owner/:repo/search/code/reindex", codeSearchHandlers.ReindexRepositoryCode)
+
+				// Repository self-hosted runners
+				repos.GET("/:owner/:repo/runners", runnerHandlers.ListRepositoryRunners)
+				repos.POST("/:owner/:repo/runners/registration-token", runnerHandlers.CreateRepositoryRegistrationToken)
+				repos.GET("/:owner/:repo/runners/:runner_id", runnerHandlers.GetRepositoryRunner)
+				repos.DELETE("/:owner/:repo/runners/:runner_id", runnerHandlers.DeleteRepositoryRunner)
+				repos.POST("/:owner/:repo/runner-jobs", runnerHandlers.EnqueueRunnerJob)
+
+				// Commit statuses and check runs reported by external CI
+				repos.POST("/:owner/:repo/statuses/:sha", commitStatusHandlers.CreateStatus)
+				repos.GET("/:owner/:repo/commits/:sha/statuses", commitStatusHandlers.ListStatuses)
+				repos.GET("/:owner/:repo/commits/:sha/status", commitStatusHandlers.GetCombinedStatus)
+				repos.GET("/:owner/:repo/commits/:sha/check-runs", commitStatusHandlers.ListCheckRuns)
+				repos.POST("/:owner/:repo/check-runs", commitStatusHandlers.CreateCheckRun)
+				repos.GET("/:owner/:repo/check-runs/:check_run_id", commitStatusHandlers.GetCheckRun)
+				repos.PATCH("/:owner/:repo/check-runs/:check_run_id", commitStatusHandlers.UpdateCheckRun)
+
+				// Releases and release assets
+				repos.POST("/:owner/:repo/releases", releaseHandlers.CreateRelease)
+				repos.POST("/:owner/:repo/releases/generate-notes", releaseHandlers.GenerateReleaseNotes)
+				repos.PATCH("/:owner/:repo/releases/:release_id", releaseHandlers.UpdateRelease)
+				repos.DELETE("/:owner/:repo/releases/:release_id", releaseHandlers.DeleteRelease)
+				repos.POST("/:owner/:repo/releases/:release_id/assets", releaseHandlers.UploadReleaseAsset)
+				repos.DELETE("/:owner/:repo/releases/assets/:asset_id", releaseHandlers.DeleteReleaseAsset)
+
+				// Workflow runs started from .hub/workflows
+				repos.GET("/:owner/:repo/actions/runs", workflowHandlers.ListWorkflowRuns)
+				repos.GET("/:owner/:repo/actions/runs/:run_id", workflowHandlers.GetWorkflowRun)
+				repos.GET("/:owner/:repo/actions/runs/:run_id/jobs", workflowHandlers.ListWorkflowRunJobs)
+				repos.GET("/:owner/:repo/actions/jobs/:job_id", workflowHandlers.GetWorkflowJob)
+				repos.GET("/:owner/:repo/actions/jobs/:job_id/logs", workflowHandlers.GetWorkflowJobLogs)
 
 				// Branch comparison
 				repos.GET("/:owner/:repo/compare/:base/:head", repoHandlers.CompareBranches)
@@ -334,6 +1082,8 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 				repos.GET("/:owner/:repo/branches/:branch/protection", branchProtectionHandlers.GetBranchProtection)
 				repos.PUT("/:owner/:repo/branches/:branch/protection", branchProtectionHandlers.UpdateBranchProtection)
 				repos.DELETE("/:owner/:repo/branches/:branch/protection", branchProtectionHandlers.DeleteBranchProtection)
+				repos.POST("/:owner/:repo/branches/:branch/protection/simulate", pathProtectionHandlers.SimulateBranchProtection)
+				repos.POST("/:owner/:repo/codeowners/validate", pathProtectionHandlers.ValidateCodeOwners)
 				repos.GET("/:owner/:repo/branches/:branch/protection/required_status_checks", branchProtectionHandlers.GetRequiredStatusChecks)
 				repos.PATCH("/:owner/:repo/branches/:branch/protection/required_status_checks", branchProtectionHandlers.UpdateRequiredStatusChecks)
 				repos.DELETE("/:owner/:repo/branches/:branch/protection/required_status_checks", branchProtectionHandlers.DeleteRequiredStatusChecks)
@@ -348,6 +1098,12 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 				repos.PATCH("/:owner/:repo/hooks/:hook_id", hooksHandlers.UpdateWebhook)
 				repos.DELETE("/:owner/:repo/hooks/:hook_id", hooksHandlers.DeleteWebhook)
 				repos.POST("/:owner/:repo/hooks/:hook_id/pings", hooksHandlers.PingWebhook)
+				repos.POST("/:owner/:repo/hooks/:hook_id/render", hooksHandlers.RenderPayload)
+				repos.POST("/:owner/:repo/hooks/templates/validate", hooksHandlers.ValidatePayloadTemplate)
+				repos.GET("/:owner/:repo/hooks/:hook_id/deliveries", hooksHandlers.ListDeliveries)
+				repos.GET("/:owner/:repo/hooks/:hook_id/deliveries/:delivery_id", hooksHandlers.GetDelivery)
+				repos.POST("/:owner/:repo/hooks/:hook_id/deliveries/:delivery_id/attempts", hooksHandlers.RedeliverDelivery)
+				repos.GET("/:owner/:repo/events/export", activityExportHandlers.ExportActivity)
 
 				// Deploy keys
 				repos.GET("/:owner/:repo/keys", hooksHandlers.ListDeployKeys)
@@ -380,6 +1136,94 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 				repos.GET("/:owner/:repo/pulls/:number", prHandlers.GetPullRequest)
 				repos.PATCH("/:owner/:repo/pulls/:number", prHandlers.UpdatePullRequest)
 				repos.PUT("/:owner/:repo/pulls/:number/merge", prHandlers.MergePullRequest)
+				repos.GET("/:owner/:repo/pulls/:number/files", prHandlers.ListPullRequestFiles)
+				repos.GET("/:owner/:repo/pulls/:number/review-requirements", pathProtectionHandlers.GetReviewRequirements)
+				repos.GET("/:owner/:repo/pulls/:number/checklist", mergeChecklistHandlers.GetPullRequestChecklist)
+				repos.GET("/:owner/:repo/pulls/:number/checklist/history", mergeChecklistHandlers.GetPullRequestChecklistHistory)
+				repos.PUT("/:owner/:repo/pulls/:number/checklist/:item_id", mergeChecklistHandlers.CheckPullRequestChecklistItem)
+				repos.DELETE("/:owner/:repo/pulls/:number/checklist/:item_id", mergeChecklistHandlers.UncheckPullRequestChecklistItem)
+				repos.GET("/:owner/:repo/pulls/:number/change-ticket", changeManagementHandlers.GetPullRequestChangeTicket)
+				repos.POST("/:owner/:repo/pulls/:number/change-ticket/validate", changeManagementHandlers.ValidatePullRequestChangeTicket)
+				repos.GET("/:owner/:repo/pulls/:number/comments", reviewCommentHandlers.ListReviewComments)
+				repos.POST("/:owner/:repo/pulls/:number/comments", reviewCommentHandlers.CreateReviewComment)
+				repos.POST("/:owner/:repo/pulls/:number/comments/:comment_id/apply-suggestion", reviewCommentHandlers.ApplySuggestion)
+				repos.PUT("/:owner/:repo/pulls/:number/comments/:comment_id/resolution", reviewCommentHandlers.ResolveReviewThread)
+				repos.DELETE("/:owner/:repo/pulls/:number/comments/:comment_id/resolution", reviewCommentHandlers.UnresolveReviewThread)
+				repos.GET("/:owner/:repo/pulls/:number/comments/:comment_id/resolution/history", reviewCommentHandlers.GetReviewThreadHistory)
+				repos.POST("/:owner/:repo/pulls/:number/suggestions/apply", reviewCommentHandlers.ApplySuggestions)
+				repos.GET("/:owner/:repo/pulls/:number/reviews", reviewHandlers.ListReviews)
+				repos.POST("/:owner/:repo/pulls/:number/reviews", reviewHandlers.SubmitReview)
+				repos.GET("/:owner/:repo/pulls/:number/reviews/:review_id", reviewHandlers.GetReview)
+				repos.GET("/:owner/:repo/pulls/:number/reviews/:review_id/comments", reviewHandlers.ListReviewComments)
+				repos.PUT("/:owner/:repo/pulls/:number/reviews/:review_id/dismissals", reviewHandlers.DismissReview)
+				repos.GET("/:owner/:repo/pulls/:number/code-scanning", codeScanningHandlers.GetPullRequestAnnotations)
+
+				// Code scanning results uploaded by external scanners
+				repos.POST("/:owner/:repo/code-scanning/sarifs", codeScanningHandlers.UploadSARIF)
+				repos.GET("/:owner/:repo/code-scanning/alerts", codeScanningHandlers.ListCodeScanningAlerts)
+				repos.GET("/:owner/:repo/code-scanning/alerts/:alert_number", codeScanningHandlers.GetCodeScanningAlert)
+				repos.PATCH("/:owner/:repo/code-scanning/alerts/:alert_number", codeScanningHandlers.UpdateCodeScanningAlert)
+
+				// Issue timeline (cross-references from commits and pull requests)
+				// Issues, labels and comments
+				repos.GET("/:owner/:repo/issues", issueHandlers.ListIssues)
+				repos.POST("/:owner/:repo/issues", issueHandlers.CreateIssue)
+				repos.GET("/:owner/:repo/issues/:number", issueHandlers.GetIssue)
+				repos.PATCH("/:owner/:repo/issues/:number", issueHandlers.UpdateIssue)
+				repos.GET("/:owner/:repo/issues/:number/comments", issueHandlers.ListIssueComments)
+				repos.POST("/:owner/:repo/issues/:number/comments", issueHandlers.CreateIssueComment)
+				repos.GET("/:owner/:repo/labels", labelHandlers.ListLabels)
+				repos.POST("/:owner/:repo/labels", labelHandlers.CreateLabel)
+				repos.GET("/:owner/:repo/labels/:name", labelHandlers.GetLabel)
+				repos.PATCH("/:owner/:repo/labels/:name", labelHandlers.UpdateLabel)
+				repos.DELETE("/:owner/:repo/labels/:name", labelHandlers.DeleteLabel)
+				repos.GET("/:owner/:repo/issues/:number/timeline", issueHandlers.GetIssueTimeline)
+				repos.POST("/:owner/:repo/issues/:number/transfer", issueHandlers.TransferIssue)
+
+				// Issue time tracking and milestones
+				repos.GET("/:owner/:repo/issues/:number/time_stats", timeTrackingHandlers.GetTimeStats)
+				repos.POST("/:owner/:repo/issues/:number/time_estimate", timeTrackingHandlers.SetTimeEstimate)
+				repos.POST("/:owner/:repo/issues/:number/reset_time_estimate", timeTrackingHandlers.ResetTimeEstimate)
+				repos.POST("/:owner/:repo/issues/:number/add_spent_time", timeTrackingHandlers.AddSpentTime)
+				repos.POST("/:owner/:repo/issues/:number/reset_spent_time", timeTrackingHandlers.ResetSpentTime)
+				repos.GET("/:owner/:repo/time_report", timeTrackingHandlers.GetTimeReport)
+				repos.GET("/:owner/:repo/milestones", timeTrackingHandlers.ListMilestones)
+				repos.POST("/:owner/:repo/milestones", timeTrackingHandlers.CreateMilestone)
+				repos.GET("/:owner/:repo/milestones/:number", timeTrackingHandlers.GetMilestone)
+				repos.PATCH("/:owner/:repo/milestones/:number", timeTrackingHandlers.UpdateMilestone)
+				repos.DELETE("/:owner/:repo/milestones/:number", timeTrackingHandlers.DeleteMilestone)
+
+				// Sensitive path review rules
+				repos.GET("/:owner/:repo/path-protection", pathProtectionHandlers.ListPathProtectionRules)
+				repos.POST("/:owner/:repo/path-protection", pathProtectionHandlers.CreatePathProtectionRule)
+				repos.PATCH("/:owner/:repo/path-protection/:rule_id", pathProtectionHandlers.UpdatePathProtectionRule)
+				repos.DELETE("/:owner/:repo/path-protection/:rule_id", pathProtectionHandlers.DeletePathProtectionRule)
+
+				// Required status check patterns
+				repos.GET("/:owner/:repo/required-status-checks", requiredStatusCheckHandlers.ListRequiredStatusChecks)
+				repos.POST("/:owner/:repo/required-status-checks", requiredStatusCheckHandlers.CreateRequiredStatusCheck)
+				repos.POST("/:owner/:repo/required-status-checks/test", requiredStatusCheckHandlers.TestRequiredStatusCheckPattern)
+				repos.PATCH("/:owner/:repo/required-status-checks/:check_id", requiredStatusCheckHandlers.UpdateRequiredStatusCheck)
+				repos.DELETE("/:owner/:repo/required-status-checks/:check_id", requiredStatusCheckHandlers.DeleteRequiredStatusCheck)
+
+				// Manual checks required before merge
+				repos.GET("/:owner/:repo/merge-checklist", mergeChecklistHandlers.ListMergeChecklistItems)
+				repos.POST("/:owner/:repo/merge-checklist", mergeChecklistHandlers.CreateMergeChecklistItem)
+				repos.PATCH("/:owner/:repo/merge-checklist/:item_id", mergeChecklistHandlers.UpdateMergeChecklistItem)
+				repos.DELETE("/:owner/:repo/merge-checklist/:item_id", mergeChecklistHandlers.DeleteMergeChecklistItem)
+
+				// Change tickets required on pull requests
+				repos.GET("/:owner/:repo/change-management", changeManagementHandlers.GetChangeManagementPolicy)
+				repos.PUT("/:owner/:repo/change-management", changeManagementHandlers.UpdateChangeManagementPolicy)
+				repos.DELETE("/:owner/:repo/change-management", changeManagementHandlers.DeleteChangeManagementPolicy)
+
+				// Cron schedules and their run history
+				repos.GET("/:owner/:repo/schedules", repositoryScheduleHandlers.ListSchedules)
+				repos.POST("/:owner/:repo/schedules", repositoryScheduleHandlers.CreateSchedule)
+				repos.GET("/:owner/:repo/schedules/:schedule_id", repositoryScheduleHandlers.GetSchedule)
+				repos.PATCH("/:owner/:repo/schedules/:schedule_id", repositoryScheduleHandlers.UpdateSchedule)
+				repos.DELETE("/:owner/:repo/schedules/:schedule_id", repositoryScheduleHandlers.DeleteSchedule)
+				repos.GET("/:owner/:repo/schedules/:schedule_id/runs", repositoryScheduleHandlers.ListScheduleRuns)
 
 				// Repository analytics endpoints (require authentication)
 				repos.GET("/:owner/:repo/analytics", analyticsHandlers.GetRepositoryAnalytics)
@@ -389,6 +1233,7 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 				repos.GET("/:owner/:repo/analytics/performance", analyticsHandlers.GetRepositoryPerformance)
 				repos.GET("/:owner/:repo/analytics/issues", analyticsHandlers.GetRepositoryIssues)
 				repos.GET("/:owner/:repo/analytics/pulls", analyticsHandlers.GetRepositoryPulls)
+				repos.GET("/:owner/:repo/analytics/reviews", analyticsHandlers.GetRepositoryReviews)
 			}
 
 			// Admin-only operations
@@ -451,10 +1296,112 @@ func SetupRoutes(router *gin.Engine, database *db.Database, logger *logrus.Logge
 				// Organization analytics endpoints
 				orgs.GET("/:org/analytics/overview", analyticsHandlers.GetOrganizationAnalytics)
 				orgs.GET("/:org/analytics/members", analyticsHandlers.GetOrganizationMembers)
+				orgs.GET("/:org/analytics/inactive-members", analyticsHandlers.GetInactiveMembers)
 				orgs.GET("/:org/analytics/repositories", analyticsHandlers.GetOrganizationRepositories)
+				orgs.GET("/:org/analytics/languages", analyticsHandlers.GetOrganizationLanguages)
+				orgs.GET("/:org/analytics/reviews", analyticsHandlers.GetOrganizationReviews)
 				orgs.GET("/:org/analytics/teams", analyticsHandlers.GetOrganizationTeams)
-				orgs.GET("/:org/analytics/security", analyticsHandlers.GetOrganizationSecurity)
+				orgs.GET("/:org/analytics/security", securityOverviewHandlers.GetOrganizationOverview)
+				orgs.GET("/:org/analytics/security/repositories", securityOverviewHandlers.ListRepositories)
+				orgs.GET("/:org/analytics/security/repositories/:repo", securityOverviewHandlers.GetRepository)
+				orgs.GET("/:org/stats/code_frequency/authors", repositoryStatsHandlers.GetOrganizationAuthorCodeFrequency)
+
+				// Organization news feed digests
+				orgs.GET("/:org/digest/settings", digestHandlers.GetDigestSettings)
+				orgs.PATCH("/:org/digest/settings", digestHandlers.UpdateDigestSettings)
+				orgs.GET("/:org/digest/subscription", digestHandlers.GetDigestSubscription)
+				orgs.PUT("/:org/digest/subscription", digestHandlers.UpdateDigestSubscription)
+				orgs.GET("/:org/digest/preview", digestHandlers.PreviewDigest)
+
+				// Organization metric dashboards
+				orgs.GET("/:org/dashboards", dashboardHandlers.ListDashboards)
+				orgs.POST("/:org/dashboards", dashboardHandlers.CreateDashboard)
+				orgs.GET("/:org/dashboards/:dashboard_id", dashboardHandlers.GetDashboard)
+				orgs.PATCH("/:org/dashboards/:dashboard_id", dashboardHandlers.UpdateDashboard)
+				orgs.DELETE("/:org/dashboards/:dashboard_id", dashboardHandlers.DeleteDashboard)
+				orgs.GET("/:org/dashboards/:dashboard_id/data", dashboardHandlers.GetDashboardData)
+
+				// Organization forking and visibility policies
+				orgs.GET("/:org/policies/repository", repositoryPolicyHandlers.GetRepositoryPolicy)
+				orgs.PATCH("/:org/policies/repository", repositoryPolicyHandlers.UpdateRepositoryPolicy)
+				orgs.GET("/:org/policies/compliance", repositoryPolicyHandlers.GetPolicyCompliance)
+				orgs.GET("/:org/repository-baseline", repositoryBaselineHandlers.GetBaseline)
+				orgs.PUT("/:org/repository-baseline", repositoryBaselineHandlers.UpdateBaseline)
+
+				// Single sign-on configuration, owners and admins only
+				orgs.GET("/:org/sso/config", organizationSSOHandlers.GetConfig)
+				orgs.PUT("/:org/sso/config", organizationSSOHandlers.UpdateConfig)
+				orgs.DELETE("/:org/sso/config", organizationSSOHandlers.DeleteConfig)
+				orgs.GET("/:org/sso/identities", organizationSSOHandlers.ListIdentities)
+				orgs.GET("/:org/scim/tokens", scimHandlers.ListTokens)
+				orgs.POST("/:org/scim/tokens", scimHandlers.CreateToken)
+				orgs.DELETE("/:org/scim/tokens/:id", scimHandlers.DeleteToken)
+				orgs.GET("/:org/repository-approvals", repositoryApprovalHandlers.ListRepositoryApprovals)
+				orgs.GET("/:org/repository-approvals/:request_id", repositoryApprovalHandlers.GetRepositoryApproval)
+				orgs.POST("/:org/repository-approvals/:request_id/approve", repositoryApprovalHandlers.ApproveRepositoryApproval)
+				orgs.POST("/:org/repository-approvals/:request_id/reject", repositoryApprovalHandlers.RejectRepositoryApproval)
+
+				// Code search indexing controls
+				orgs.GET("/:org/code-search/settings", codeSearchHandlers.GetCodeSearchSettings)
+				orgs.PATCH("/:org/code-search/settings", codeSearchHandlers.UpdateCodeSearchSettings)
+				orgs.GET("/:org/code-search/index", codeSearchHandlers.GetCodeSearchIndex)
+				orgs.POST("/:org/code-search/reindex", codeSearchHandlers.ReindexCodeSearch)
+
+				// Audit trail of the organization
+				orgs.GET("/:org/audit-log", auditHandlers.ListOrganizationEntries)
+
+				// Credential usage audits of members and repositories
+				orgs.GET("/:org/security/credentials", credentialAuditHandlers.GetOrganizationCredentialReport)
+				orgs.POST("/:org/security/credentials/revoke", credentialAuditHandlers.RevokeOrganizationCredentials)
+				orgs.GET("/:org/security/commit-verification", commitVerificationHandlers.GetOrganizationReport)
+
+				// Fine-grained token requests awaiting organization approval
+				orgs.GET("/:org/token-requests", fineGrainedTokenHandlers.ListTokenRequests)
+				orgs.POST("/:org/token-requests/:token_id/approve", fineGrainedTokenHandlers.ApproveTokenRequest)
+				orgs.POST("/:org/token-requests/:token_id/deny", fineGrainedTokenHandlers.DenyTokenRequest)
+
+				// Organization self-hosted runners and runner groups
+				orgs.GET("/:org/runners", runnerHandlers.ListOrganizationRunners)
+				orgs.POST("/:org/runners/registration-token", runnerHandlers.CreateOrganizationRegistrationToken)
+				orgs.GET("/:org/runners/:runner_id", runnerHandlers.GetOrganizationRunner)
+				orgs.DELETE("/:org/runners/:runner_id", runnerHandlers.DeleteOrganizationRunner)
+				orgs.PUT("/:org/runners/:runner_id/group", runnerHandlers.SetOrganizationRunnerGroup)
+				orgs.GET("/:org/runner-groups", runnerHandlers.ListRunnerGroups)
+				orgs.POST("/:org/runner-groups", runnerHandlers.CreateRunnerGroup)
+				orgs.PATCH("/:org/runner-groups/:group_id", runnerHandlers.UpdateRunnerGroup)
+				orgs.DELETE("/:org/runner-groups/:group_id", runnerHandlers.DeleteRunnerGroup)
+				orgs.PUT("/:org/runner-groups/:group_id/repositories", runnerHandlers.SetRunnerGroupRepositories)
+
+				// Onboarding checklist
+				orgs.GET("/:org/onboarding", onboardingHandlers.GetOrganizationOnboarding)
+				orgs.POST("/:org/onboarding/sample-repository", onboardingHandlers.GenerateOrganizationSampleRepository)
+				orgs.POST("/:org/onboarding/dismiss", onboardingHandlers.DismissOrganizationOnboarding)
+
+				// Organization webhooks for membership and team changes
+				orgs.GET("/:org/hooks", organizationHooksHandlers.ListHooks)
+				orgs.POST("/:org/hooks", organizationHooksHandlers.CreateHook)
+				orgs.GET("/:org/hooks/:hook_id", organizationHooksHandlers.GetHook)
+				orgs.PATCH("/:org/hooks/:hook_id", organizationHooksHandlers.UpdateHook)
+				orgs.DELETE("/:org/hooks/:hook_id", organizationHooksHandlers.DeleteHook)
+				orgs.POST("/:org/hooks/:hook_id/pings", organizationHooksHandlers.PingHook)
+				orgs.POST("/:org/hooks/:hook_id/render", organizationHooksHandlers.RenderPayload)
+				orgs.POST("/:org/hooks/templates/validate", organizationHooksHandlers.ValidatePayloadTemplate)
+				orgs.GET("/:org/hooks/:hook_id/deliveries", organizationHooksHandlers.ListDeliveries)
+				orgs.GET("/:org/hooks/:hook_id/deliveries/:delivery_id", organizationHooksHandlers.GetDelivery)
+				orgs.POST("/:org/hooks/:hook_id/deliveries/:delivery_id/attempts", organizationHooksHandlers.RedeliverDelivery)
+				orgs.GET("/:org/integrations/health", organizationHooksHandlers.IntegrationHealth)
+
+				// Organization packages
+				orgs.GET("/:org/packages", packageHandlers.ListPackages)
+				orgs.GET("/:org/packages/retention-policy", packageHandlers.GetRetentionPolicy)
+				orgs.PUT("/:org/packages/retention-policy", packageHandlers.UpdateRetentionPolicy)
+				orgs.GET("/:org/packages/:package_id", packageHandlers.GetPackage)
+				orgs.PATCH("/:org/packages/:package_id", packageHandlers.UpdatePackage)
+				orgs.DELETE("/:org/packages/:package_id", packageHandlers.DeletePackage)
+				orgs.DELETE("/:org/packages/:package_id/versions/:version", packageHandlers.DeletePackageVersion)
 			}
 		}
 	}
+
+	return background
 }
@@ -0,0 +1,517 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// runnerContextKey holds the authenticated runner on runner agent requests
+const runnerContextKey = "runner"
+
+// RunnerHandlers serves self-hosted runner registration, management and job
+// routing endpoints
+type RunnerHandlers struct {
+	runnerService     services.RunnerService
+	repositoryService services.RepositoryService
+	db                *gorm.DB
+	logger            *logrus.Logger
+}
+
+func NewRunnerHandlers(runnerService services.RunnerService, repositoryService services.RepositoryService, db *gorm.DB, logger *logrus.Logger) *RunnerHandlers {
+	return &RunnerHandlers{
+		runnerService:     runnerService,
+		repositoryService: repositoryService,
+		db:                db,
+		logger:            logger,
+	}
+}
+
+// RunnerAuth authenticates runner agents by the token returned at registration
+func (h *RunnerHandlers) RunnerAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
+			return
+		}
+
+		runner, err := h.runnerService.Authenticate(c.Request.Context(), parts[1])
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid runner token"})
+			return
+		}
+		c.Set(runnerContextKey, runner)
+		c.Next()
+	}
+}
+
+func currentRunner(c *gin.Context) *models.Runner {
+	runner, _ := c.MustGet(runnerContextKey).(*models.Runner)
+	return runner
+}
+
+// repositoryTarget resolves the repository in the path and requires admin access to it
+func (h *RunnerHandlers) repositoryTarget(c *gin.Context) (services.RunnerTarget, bool) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return services.RunnerTarget{}, false
+	}
+
+	if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Repository admin access is required"})
+		return services.RunnerTarget{}, false
+	}
+
+	return services.RunnerTarget{Scope: models.RunnerScopeRepository, RepositoryID: &repo.ID}, true
+}
+
+// organizationTarget resolves the organization in the path and requires the
+// caller to be one of its owners or admins
+func (h *RunnerHandlers) organizationTarget(c *gin.Context) (services.RunnerTarget, *models.Organization, bool) {
+	uid, err := parseUserID(c.MustGet("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return services.RunnerTarget{}, nil, false
+	}
+
+	var org models.Organization
+	if err := h.db.WithContext(c.Request.Context()).Where("name = ?", c.Param("org")).First(&org).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+		return services.RunnerTarget{}, nil, false
+	}
+
+	var member models.OrganizationMember
+	err = h.db.WithContext(c.Request.Context()).Where("organization_id = ? AND user_id = ?", org.ID, uid).First(&member).Error
+	if err != nil || (member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only organization owners and admins can manage runners"})
+		return services.RunnerTarget{}, nil, false
+	}
+
+	return services.RunnerTarget{Scope: models.RunnerScopeOrganization, OrganizationID: &org.ID}, &org, true
+}
+
+// instanceTarget is the scope of runners shared by the whole instance; its
+// routes are mounted behind AdminMiddleware
+var instanceTarget = services.RunnerTarget{Scope: models.RunnerScopeInstance}
+
+func (h *RunnerHandlers) createRegistrationToken(c *gin.Context, target services.RunnerTarget) {
+	var actorID *uuid.UUID
+	if uid, err := parseUserID(c.MustGet("user_id")); err == nil {
+		actorID = &uid
+	}
+
+	token, record, err := h.runnerService.CreateRegistrationToken(c.Request.Context(), target, actorID)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to create runner registration token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create registration token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      token,
+		"expires_at": record.ExpiresAt,
+	})
+}
+
+func (h *RunnerHandlers) listRunners(c *gin.Context, target services.RunnerTarget) {
+	runners, err := h.runnerService.ListRunners(c.Request.Context(), target)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list runners")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list runners"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runners":     runners,
+		"total_count": len(runners),
+	})
+}
+
+func (h *RunnerHandlers) getRunner(c *gin.Context, target services.RunnerTarget) {
+	runnerID, err := uuid.Parse(c.Param("runner_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid runner ID"})
+		return
+	}
+
+	runner, err := h.runnerService.GetRunner(c.Request.Context(), target, runnerID)
+	if err != nil {
+		h.runnerError(c, err, "Failed to get runner")
+		return
+	}
+	c.JSON(http.StatusOK, runner)
+}
+
+func (h *RunnerHandlers) removeRunner(c *gin.Context, target services.RunnerTarget) {
+	runnerID, err := uuid.Parse(c.Param("runner_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid runner ID"})
+		return
+	}
+
+	if err := h.runnerService.RemoveRunner(c.Request.Context(), target, runnerID); err != nil {
+		h.runnerError(c, err, "Failed to remove runner")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *RunnerHandlers) runnerError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrRunnerNotFound),
+		errors.Is(err, services.ErrRunnerGroupNotFound),
+		errors.Is(err, services.ErrRunnerJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRunnerToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidRunnerGroup),
+		errors.Is(err, services.ErrInvalidRunnerTarget),
+		errors.Is(err, services.ErrRunnerGroupRepoNotInOrg):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRunnerJobNotAssigned):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListRepositoryRunners handles GET /api/v1/repositories/{owner}/{repo}/runners
+func (h *RunnerHandlers) ListRepositoryRunners(c *gin.Context) {
+	if target, ok := h.repositoryTarget(c); ok {
+		h.listRunners(c, target)
+	}
+}
+
+// GetRepositoryRunner handles GET /api/v1/repositories/{owner}/{repo}/runners/{runner_id}
+func (h *RunnerHandlers) GetRepositoryRunner(c *gin.Context) {
+	if target, ok := h.repositoryTarget(c); ok {
+		h.getRunner(c, target)
+	}
+}
+
+// DeleteRepositoryRunner handles DELETE /api/v1/repositories/{owner}/{repo}/runners/{runner_id}
+func (h *RunnerHandlers) DeleteRepositoryRunner(c *gin.Context) {
+	if target, ok := h.repositoryTarget(c); ok {
+		h.removeRunner(c, target)
+	}
+}
+
+// CreateRepositoryRegistrationToken handles POST /api/v1/repositories/{owner}/{repo}/runners/registration-token
+func (h *RunnerHandlers) CreateRepositoryRegistrationToken(c *gin.Context) {
+	if target, ok := h.repositoryTarget(c); ok {
+		h.createRegistrationToken(c, target)
+	}
+}
+
+// EnqueueRunnerJob handles POST /api/v1/repositories/{owner}/{repo}/runner-jobs
+//
+// The job is routed to the first eligible runner whose labels include every
+// label requested here.
+func (h *RunnerHandlers) EnqueueRunnerJob(c *gin.Context) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		return
+	}
+	if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Write access is required"})
+		return
+	}
+
+	var req struct {
+		Labels  []string `json:"labels" binding:"required,min=1"`
+		Payload string   `json:"payload"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.runnerService.EnqueueJob(c.Request.Context(), repo.ID, req.Labels, req.Payload)
+	if err != nil {
+		h.runnerError(c, err, "Failed to enqueue job")
+		return
+	}
+	c.JSON(http.StatusCreated, job)
+}
+
+// ListOrganizationRunners handles GET /api/v1/organizations/{org}/runners
+func (h *RunnerHandlers) ListOrganizationRunners(c *gin.Context) {
+	if target, _, ok := h.organizationTarget(c); ok {
+		h.listRunners(c, target)
+	}
+}
+
+// GetOrganizationRunner handles GET /api/v1/organizations/{org}/runners/{runner_id}
+func (h *RunnerHandlers) GetOrganizationRunner(c *gin.Context) {
+	if target, _, ok := h.organizationTarget(c); ok {
+		h.getRunner(c, target)
+	}
+}
+
+// DeleteOrganizationRunner handles DELETE /api/v1/organizations/{org}/runners/{runner_id}
+func (h *RunnerHandlers) DeleteOrganizationRunner(c *gin.Context) {
+	if target, _, ok := h.organizationTarget(c); ok {
+		h.removeRunner(c, target)
+	}
+}
+
+// CreateOrganizationRegistrationToken handles POST /api/v1/organizations/{org}/runners/registration-token
+func (h *RunnerHandlers) CreateOrganizationRegistrationToken(c *gin.Context) {
+	if target, _, ok := h.organizationTarget(c); ok {
+		h.createRegistrationToken(c, target)
+	}
+}
+
+// SetOrganizationRunnerGroup handles PUT /api/v1/organizations/{org}/runners/{runner_id}/group
+func (h *RunnerHandlers) SetOrganizationRunnerGroup(c *gin.Context) {
+	_, org, ok := h.organizationTarget(c)
+	if !ok {
+		return
+	}
+	runnerID, err := uuid.Parse(c.Param("runner_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid runner ID"})
+		return
+	}
+
+	var req struct {
+		RunnerGroupID *uuid.UUID `json:"runner_group_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	runner, err := h.runnerService.SetRunnerGroup(c.Request.Context(), org.ID, runnerID, req.RunnerGroupID)
+	if err != nil {
+		h.runnerError(c, err, "Failed to update runner group")
+		return
+	}
+	c.JSON(http.StatusOK, runner)
+}
+
+// ListRunnerGroups handles GET /api/v1/organizations/{org}/runner-groups
+func (h *RunnerHandlers) ListRunnerGroups(c *gin.Context) {
+	_, org, ok := h.organizationTarget(c)
+	if !ok {
+		return
+	}
+
+	groups, err := h.runnerService.ListGroups(c.Request.Context(), org.ID)
+	if err != nil {
+		h.runnerError(c, err, "Failed to list runner groups")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"runner_groups": groups,
+		"total_count":   len(groups),
+	})
+}
+
+// CreateRunnerGroup handles POST /api/v1/organizations/{org}/runner-groups
+func (h *RunnerHandlers) CreateRunnerGroup(c *gin.Context) {
+	_, org, ok := h.organizationTarget(c)
+	if !ok {
+		return
+	}
+
+	var req services.RunnerGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, err := h.runnerService.CreateGroup(c.Request.Context(), org.ID, req)
+	if err != nil {
+		h.runnerError(c, err, "Failed to create runner group")
+		return
+	}
+	c.JSON(http.StatusCreated, group)
+}
+
+// UpdateRunnerGroup handles PATCH /api/v1/organizations/{org}/runner-groups/{group_id}
+func (h *RunnerHandlers) UpdateRunnerGroup(c *gin.Context) {
+	_, org, ok := h.organizationTarget(c)
+	if !ok {
+		return
+	}
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid runner group ID"})
+		return
+	}
+
+	var req services.RunnerGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, err := h.runnerService.UpdateGroup(c.Request.Context(), org.ID, groupID, req)
+	if err != nil {
+		h.runnerError(c, err, "Failed to update runner group")
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// DeleteRunnerGroup handles DELETE /api/v1/organizations/{org}/runner-groups/{group_id}
+func (h *RunnerHandlers) DeleteRunnerGroup(c *gin.Context) {
+	_, org, ok := h.organizationTarget(c)
+	if !ok {
+		return
+	}
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid runner group ID"})
+		return
+	}
+
+	if err := h.runnerService.DeleteGroup(c.Request.Context(), org.ID, groupID); err != nil {
+		h.runnerError(c, err, "Failed to delete runner group")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// SetRunnerGroupRepositories handles PUT /api/v1/organizations/{org}/runner-groups/{group_id}/repositories
+func (h *RunnerHandlers) SetRunnerGroupRepositories(c *gin.Context) {
+	_, org, ok := h.organizationTarget(c)
+	if !ok {
+		return
+	}
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid runner group ID"})
+		return
+	}
+
+	var req struct {
+		Repositories []string `json:"repositories"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	group, err := h.runnerService.SetGroupRepositories(c.Request.Context(), org.ID, groupID, req.Repositories)
+	if err != nil {
+		h.runnerError(c, err, "Failed to update runner group repositories")
+		return
+	}
+	c.JSON(http.StatusOK, group)
+}
+
+// ListInstanceRunners handles GET /api/v1/admin/runners
+func (h *RunnerHandlers) ListInstanceRunners(c *gin.Context) {
+	h.listRunners(c, instanceTarget)
+}
+
+// DeleteInstanceRunner handles DELETE /api/v1/admin/runners/{runner_id}
+func (h *RunnerHandlers) DeleteInstanceRunner(c *gin.Context) {
+	h.removeRunner(c, instanceTarget)
+}
+
+// CreateInstanceRegistrationToken handles POST /api/v1/admin/runners/registration-token
+func (h *RunnerHandlers) CreateInstanceRegistrationToken(c *gin.Context) {
+	h.createRegistrationToken(c, instanceTarget)
+}
+
+// RegisterRunner handles POST /api/v1/runners/register
+//
+// Runners exchange a registration token for a runner token, which they send
+// as a Bearer token on every other runner endpoint.
+func (h *RunnerHandlers) RegisterRunner(c *gin.Context) {
+	var req services.RegisterRunnerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	runner, token, err := h.runnerService.Register(c.Request.Context(), req)
+	if err != nil {
+		h.runnerError(c, err, "Failed to register runner")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"runner": runner,
+		"token":  token,
+	})
+}
+
+// RunnerHeartbeat handles POST /api/v1/runners/heartbeat
+func (h *RunnerHandlers) RunnerHeartbeat(c *gin.Context) {
+	var req struct {
+		Busy bool `json:"busy"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	runner := currentRunner(c)
+	if err := h.runnerService.Heartbeat(c.Request.Context(), runner, req.Busy); err != nil {
+		h.runnerError(c, err, "Failed to record heartbeat")
+		return
+	}
+	c.JSON(http.StatusOK, runner)
+}
+
+// AcquireRunnerJob handles POST /api/v1/runners/jobs/acquire
+//
+// Responds 204 when no queued job matches the runner's labels and access.
+func (h *RunnerHandlers) AcquireRunnerJob(c *gin.Context) {
+	job, err := h.runnerService.AcquireJob(c.Request.Context(), currentRunner(c))
+	if err != nil {
+		h.runnerError(c, err, "Failed to acquire job")
+		return
+	}
+	if job == nil {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// CompleteRunnerJob handles POST /api/v1/runners/jobs/{job_id}/complete
+func (h *RunnerHandlers) CompleteRunnerJob(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	var req struct {
+		Conclusion string `json:"conclusion" binding:"required,oneof=success failure"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.runnerService.CompleteJob(c.Request.Context(), currentRunner(c), jobID, req.Conclusion == "success")
+	if err != nil {
+		h.runnerError(c, err, "Failed to complete job")
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
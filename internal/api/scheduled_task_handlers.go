@@ -0,0 +1,51 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// ScheduledTaskHandlers lets site admins see the periodic maintenance tasks
+// and run one on demand
+type ScheduledTaskHandlers struct {
+	scheduledTaskService services.ScheduledTaskService
+	logger               *logrus.Logger
+}
+
+func NewScheduledTaskHandlers(scheduledTaskService services.ScheduledTaskService, logger *logrus.Logger) *ScheduledTaskHandlers {
+	return &ScheduledTaskHandlers{
+		scheduledTaskService: scheduledTaskService,
+		logger:               logger,
+	}
+}
+
+// ListScheduledTasks handles GET /api/v1/admin/scheduled-tasks
+func (h *ScheduledTaskHandlers) ListScheduledTasks(c *gin.Context) {
+	tasks, err := h.scheduledTaskService.List(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list scheduled tasks")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list scheduled tasks"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tasks": tasks})
+}
+
+// RunScheduledTask handles POST /api/v1/admin/scheduled-tasks/:name/run
+func (h *ScheduledTaskHandlers) RunScheduledTask(c *gin.Context) {
+	err := h.scheduledTaskService.Trigger(c.Request.Context(), c.Param("name"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusAccepted, gin.H{"message": "Scheduled task started"})
+	case errors.Is(err, services.ErrScheduledTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Scheduled task not found"})
+	case errors.Is(err, services.ErrScheduledTaskRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error("Failed to run scheduled task")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to run scheduled task"})
+	}
+}
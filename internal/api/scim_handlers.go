@@ -0,0 +1,349 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	scimContentType     = "application/scim+json"
+	scimOrganizationKey = "scim_organization"
+	// defaultSCIMCount is the page size when a list request sets no count
+	defaultSCIMCount = 100
+)
+
+// SCIMHandlers serves the SCIM 2.0 provisioning API identity providers
+// call with an organization's provisioning token, and the endpoints owners
+// manage those tokens with
+type SCIMHandlers struct {
+	scimService services.SCIMService
+	logger      *logrus.Logger
+}
+
+func NewSCIMHandlers(scimService services.SCIMService, logger *logrus.Logger) *SCIMHandlers {
+	return &SCIMHandlers{scimService: scimService, logger: logger}
+}
+
+// Authenticate resolves the bearer provisioning token to the organization
+// the request provisions into
+func (h *SCIMHandlers) Authenticate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok {
+			h.respondError(c, services.ErrSCIMUnauthorized)
+			c.Abort()
+			return
+		}
+		org, err := h.scimService.Authenticate(c.Request.Context(), strings.TrimSpace(token))
+		if err != nil {
+			h.respondError(c, err)
+			c.Abort()
+			return
+		}
+		c.Set(scimOrganizationKey, org)
+		c.Next()
+	}
+}
+
+func scimOrganization(c *gin.Context) *models.Organization {
+	return c.MustGet(scimOrganizationKey).(*models.Organization)
+}
+
+func (h *SCIMHandlers) respond(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", scimContentType)
+	c.JSON(status, body)
+}
+
+// respondError writes a SCIM error message (RFC 7644 section 3.12)
+func (h *SCIMHandlers) respondError(c *gin.Context, err error) {
+	status, scimType := http.StatusInternalServerError, ""
+	switch {
+	case errors.Is(err, services.ErrSCIMUnauthorized):
+		status = http.StatusUnauthorized
+	case errors.Is(err, services.ErrSCIMNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrSCIMConflict):
+		status, scimType = http.StatusConflict, "uniqueness"
+	case errors.Is(err, services.ErrSCIMInvalid):
+		status, scimType = http.StatusBadRequest, "invalidValue"
+	case errors.Is(err, services.ErrSCIMInvalidPath):
+		status, scimType = http.StatusBadRequest, "invalidPath"
+	case errors.Is(err, services.ErrSCIMFilter):
+		status, scimType = http.StatusBadRequest, "invalidFilter"
+	}
+	detail := err.Error()
+	if status == http.StatusInternalServerError {
+		h.logger.WithError(err).WithField("path", c.FullPath()).Error("SCIM request failed")
+		detail = "Internal server error"
+	}
+	body := gin.H{"schemas": []string{services.SCIMErrorSchema}, "status": strconv.Itoa(status), "detail": detail}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	h.respond(c, status, body)
+}
+
+// bind decodes a SCIM request body, answering invalidSyntax on failure
+func (h *SCIMHandlers) bind(c *gin.Context, dest interface{}) bool {
+	if err := c.ShouldBindJSON(dest); err != nil {
+		h.respond(c, http.StatusBadRequest, gin.H{
+			"schemas": []string{services.SCIMErrorSchema}, "status": "400", "scimType": "invalidSyntax", "detail": err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+func listOptions(c *gin.Context) services.SCIMListOptions {
+	opts := services.SCIMListOptions{Filter: c.Query("filter"), StartIndex: 1, Count: defaultSCIMCount}
+	if n, err := strconv.Atoi(c.Query("startIndex")); err == nil {
+		opts.StartIndex = n
+	}
+	if n, err := strconv.Atoi(c.Query("count")); err == nil {
+		opts.Count = n
+	}
+	return opts
+}
+
+// ServiceProviderConfig handles GET /scim/v2/ServiceProviderConfig
+func (h *SCIMHandlers) ServiceProviderConfig(c *gin.Context) {
+	h.respond(c, http.StatusOK, gin.H{
+		"schemas":        []string{services.SCIMProviderSchema},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": 200},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type": "oauthbearertoken", "name": "OAuth Bearer Token", "primary": true,
+			"description": "Organization SCIM provisioning token",
+		}},
+	})
+}
+
+// ListUsers handles GET /scim/v2/Users
+func (h *SCIMHandlers) ListUsers(c *gin.Context) {
+	list, err := h.scimService.ListUsers(c.Request.Context(), scimOrganization(c), listOptions(c))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, list)
+}
+
+// GetUser handles GET /scim/v2/Users/:id
+func (h *SCIMHandlers) GetUser(c *gin.Context) {
+	user, err := h.scimService.GetUser(c.Request.Context(), scimOrganization(c), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, user)
+}
+
+// CreateUser handles POST /scim/v2/Users
+func (h *SCIMHandlers) CreateUser(c *gin.Context) {
+	var req services.SCIMUserResource
+	if !h.bind(c, &req) {
+		return
+	}
+	user, err := h.scimService.CreateUser(c.Request.Context(), scimOrganization(c), &req)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.respond(c, http.StatusCreated, user)
+}
+
+// ReplaceUser handles PUT /scim/v2/Users/:id
+func (h *SCIMHandlers) ReplaceUser(c *gin.Context) {
+	var req services.SCIMUserResource
+	if !h.bind(c, &req) {
+		return
+	}
+	user, err := h.scimService.ReplaceUser(c.Request.Context(), scimOrganization(c), c.Param("id"), &req)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, user)
+}
+
+type scimPatchRequest struct {
+	Schemas    []string               `json:"schemas"`
+	Operations []services.SCIMPatchOp `json:"Operations"`
+}
+
+// PatchUser handles PATCH /scim/v2/Users/:id
+func (h *SCIMHandlers) PatchUser(c *gin.Context) {
+	var req scimPatchRequest
+	if !h.bind(c, &req) {
+		return
+	}
+	user, err := h.scimService.PatchUser(c.Request.Context(), scimOrganization(c), c.Param("id"), req.Operations)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, user)
+}
+
+// DeleteUser handles DELETE /scim/v2/Users/:id, deactivating the user
+func (h *SCIMHandlers) DeleteUser(c *gin.Context) {
+	if err := h.scimService.DeleteUser(c.Request.Context(), scimOrganization(c), c.Param("id")); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListGroups handles GET /scim/v2/Groups. excludedAttributes=members skips
+// loading members, which identity providers ask for when syncing names.
+func (h *SCIMHandlers) ListGroups(c *gin.Context) {
+	withMembers := !strings.Contains(strings.ToLower(c.Query("excludedAttributes")), "members")
+	list, err := h.scimService.ListGroups(c.Request.Context(), scimOrganization(c), listOptions(c), withMembers)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, list)
+}
+
+// GetGroup handles GET /scim/v2/Groups/:id
+func (h *SCIMHandlers) GetGroup(c *gin.Context) {
+	group, err := h.scimService.GetGroup(c.Request.Context(), scimOrganization(c), c.Param("id"))
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, group)
+}
+
+// CreateGroup handles POST /scim/v2/Groups
+func (h *SCIMHandlers) CreateGroup(c *gin.Context) {
+	var req services.SCIMGroupResource
+	if !h.bind(c, &req) {
+		return
+	}
+	group, err := h.scimService.CreateGroup(c.Request.Context(), scimOrganization(c), &req)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.respond(c, http.StatusCreated, group)
+}
+
+// ReplaceGroup handles PUT /scim/v2/Groups/:id
+func (h *SCIMHandlers) ReplaceGroup(c *gin.Context) {
+	var req services.SCIMGroupResource
+	if !h.bind(c, &req) {
+		return
+	}
+	group, err := h.scimService.ReplaceGroup(c.Request.Context(), scimOrganization(c), c.Param("id"), &req)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, group)
+}
+
+// PatchGroup handles PATCH /scim/v2/Groups/:id
+func (h *SCIMHandlers) PatchGroup(c *gin.Context) {
+	var req scimPatchRequest
+	if !h.bind(c, &req) {
+		return
+	}
+	group, err := h.scimService.PatchGroup(c.Request.Context(), scimOrganization(c), c.Param("id"), req.Operations)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+	h.respond(c, http.StatusOK, group)
+}
+
+// DeleteGroup handles DELETE /scim/v2/Groups/:id, deleting the linked team
+func (h *SCIMHandlers) DeleteGroup(c *gin.Context) {
+	if err := h.scimService.DeleteGroup(c.Request.Context(), scimOrganization(c), c.Param("id")); err != nil {
+		h.respondError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *SCIMHandlers) tokenError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrSCIMNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+	case errors.Is(err, services.ErrSCIMForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListTokens handles GET /api/v1/organizations/:org/scim/tokens
+func (h *SCIMHandlers) ListTokens(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	tokens, err := h.scimService.ListTokens(c.Request.Context(), c.Param("org"), userID)
+	if err != nil {
+		h.tokenError(c, err, "Failed to list SCIM tokens")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// CreateToken handles POST /api/v1/organizations/:org/scim/tokens. The
+// token is only returned in this response.
+func (h *SCIMHandlers) CreateToken(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	token, secret, err := h.scimService.CreateToken(c.Request.Context(), c.Param("org"), userID, req.Description)
+	if err != nil {
+		h.tokenError(c, err, "Failed to create SCIM token")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"token": secret, "scim_token": token})
+}
+
+// DeleteToken handles DELETE /api/v1/organizations/:org/scim/tokens/:id
+func (h *SCIMHandlers) DeleteToken(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	tokenID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Token not found"})
+		return
+	}
+	if err := h.scimService.DeleteToken(c.Request.Context(), c.Param("org"), userID, tokenID); err != nil {
+		h.tokenError(c, err, "Failed to delete SCIM token")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -1,10 +1,15 @@
 package api
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
@@ -22,6 +27,12 @@ func NewSearchHandlers(searchService *services.SearchService, logger *logrus.Log
 }
 
 // GlobalSearch handles GET /api/v1/search
+//
+// Query parameters: q, which takes qualifiers such as repo:owner/name,
+// org:, user:, is:pr, is:issue, state:open, label:, author: and
+// language:; type to search one result type; sort (relevance, created,
+// updated, stars or forks) with order; page and per_page. Results cover
+// what the caller can read.
 func (h *SearchHandlers) GlobalSearch(c *gin.Context) {
 	query := c.Query("q")
 	if query == "" {
@@ -46,14 +57,23 @@ func (h *SearchHandlers) GlobalSearch(c *gin.Context) {
 	}
 
 	filter := services.SearchFilter{
-		Query:   query,
-		Type:    searchType,
-		Page:    page,
-		PerPage: perPage,
+		Query:     query,
+		Type:      searchType,
+		Sort:      c.Query("sort"),
+		Direction: c.DefaultQuery("order", c.Query("direction")),
+		Page:      page,
+		PerPage:   perPage,
+	}
+	if t, ok := tenant.FromContext(c.Request.Context()); ok {
+		filter.UserID = t.UserID
 	}
 
 	// Perform search
 	results, err := h.searchService.GlobalSearch(c.Request.Context(), filter)
+	if errors.Is(err, services.ErrInvalidSearchQuery) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		h.logger.WithError(err).Error("Failed to perform global search")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -62,6 +82,7 @@ func (h *SearchHandlers) GlobalSearch(c *gin.Context) {
 		return
 	}
 
+	setPaginationHeaders(c, page, perPage, results.TotalCount)
 	c.JSON(http.StatusOK, gin.H{
 		"data": results,
 		"meta": gin.H{
@@ -73,3 +94,33 @@ func (h *SearchHandlers) GlobalSearch(c *gin.Context) {
 		},
 	})
 }
+
+// setPaginationHeaders sets X-Total-Count and a Link header with the
+// first, prev, next and last pages of the current request
+func setPaginationHeaders(c *gin.Context, page, perPage int, total int64) {
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	lastPage := int((total + int64(perPage) - 1) / int64(perPage))
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	link := func(p int, rel string) string {
+		query := url.Values{}
+		for key, values := range c.Request.URL.Query() {
+			query[key] = values
+		}
+		query.Set("page", strconv.Itoa(p))
+		query.Set("per_page", strconv.Itoa(perPage))
+		return fmt.Sprintf(`<%s?%s>; rel="%s"`, c.Request.URL.Path, query.Encode(), rel)
+	}
+	var links []string
+	if page > 1 {
+		links = append(links, link(1, "first"), link(min(page-1, lastPage), "prev"))
+	}
+	if page < lastPage {
+		links = append(links, link(page+1, "next"), link(lastPage, "last"))
+	}
+	if len(links) > 0 {
+		c.Header("Link", strings.Join(links, ", "))
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// SecurityOverviewHandlers serves an organization's security score and its
+// per-repository breakdown
+type SecurityOverviewHandlers struct {
+	overviewService services.SecurityOverviewService
+	logger          *logrus.Logger
+}
+
+func NewSecurityOverviewHandlers(overviewService services.SecurityOverviewService, logger *logrus.Logger) *SecurityOverviewHandlers {
+	return &SecurityOverviewHandlers{
+		overviewService: overviewService,
+		logger:          logger,
+	}
+}
+
+func (h *SecurityOverviewHandlers) overviewError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+	case errors.Is(err, services.ErrSecurityRepositoryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+	case errors.Is(err, services.ErrSecurityOverviewForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetOrganizationOverview handles GET /api/v1/organizations/:org/analytics/security
+func (h *SecurityOverviewHandlers) GetOrganizationOverview(c *gin.Context) {
+	actorID, ok := actor(c)
+	if !ok {
+		return
+	}
+	overview, err := h.overviewService.OrganizationOverview(c.Request.Context(), c.Param("org"), actorID)
+	if err != nil {
+		h.overviewError(c, err, "Failed to build security overview")
+		return
+	}
+	c.JSON(http.StatusOK, overview)
+}
+
+// ListRepositories handles GET /api/v1/organizations/:org/analytics/security/repositories
+func (h *SecurityOverviewHandlers) ListRepositories(c *gin.Context) {
+	actorID, ok := actor(c)
+	if !ok {
+		return
+	}
+	repos, err := h.overviewService.ListRepositories(c.Request.Context(), c.Param("org"), actorID)
+	if err != nil {
+		h.overviewError(c, err, "Failed to build security overview")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"repositories": repos, "total_count": len(repos)})
+}
+
+// GetRepository handles GET /api/v1/organizations/:org/analytics/security/repositories/:repo
+func (h *SecurityOverviewHandlers) GetRepository(c *gin.Context) {
+	actorID, ok := actor(c)
+	if !ok {
+		return
+	}
+	repo, err := h.overviewService.GetRepository(c.Request.Context(), c.Param("org"), c.Param("repo"), actorID)
+	if err != nil {
+		h.overviewError(c, err, "Failed to build security overview")
+		return
+	}
+	c.JSON(http.StatusOK, repo)
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// SigningKeyHandlers serves the GPG and SSH keys users sign commits and
+// tags with
+type SigningKeyHandlers struct {
+	signingKeyService services.SigningKeyService
+	logger            *logrus.Logger
+}
+
+func NewSigningKeyHandlers(signingKeyService services.SigningKeyService, logger *logrus.Logger) *SigningKeyHandlers {
+	return &SigningKeyHandlers{
+		signingKeyService: signingKeyService,
+		logger:            logger,
+	}
+}
+
+// AddGPGKeyRequest carries an armored OpenPGP public key
+type AddGPGKeyRequest struct {
+	Name             string `json:"name"`
+	ArmoredPublicKey string `json:"armored_public_key" binding:"required"`
+}
+
+// AddSSHSigningKeyRequest carries an SSH public key in authorized_keys format
+type AddSSHSigningKeyRequest struct {
+	Title string `json:"title" binding:"required"`
+	Key   string `json:"key" binding:"required"`
+}
+
+// keyID parses the :id path parameter
+func (h *SigningKeyHandlers) keyID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid key ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func (h *SigningKeyHandlers) keyError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrSigningKeyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSigningKeyExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidSigningKey):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// ListGPGKeys handles GET /api/v1/user/gpg_keys
+func (h *SigningKeyHandlers) ListGPGKeys(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	keys, err := h.signingKeyService.ListGPGKeys(c.Request.Context(), userID)
+	if err != nil {
+		h.keyError(c, err, "Failed to list GPG keys")
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// AddGPGKey handles POST /api/v1/user/gpg_keys
+func (h *SigningKeyHandlers) AddGPGKey(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req AddGPGKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	key, err := h.signingKeyService.AddGPGKey(c.Request.Context(), userID, req.Name, req.ArmoredPublicKey)
+	if err != nil {
+		h.keyError(c, err, "Failed to add GPG key")
+		return
+	}
+	c.JSON(http.StatusCreated, key)
+}
+
+// GetGPGKey handles GET /api/v1/user/gpg_keys/:id
+func (h *SigningKeyHandlers) GetGPGKey(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	id, ok := h.keyID(c)
+	if !ok {
+		return
+	}
+	key, err := h.signingKeyService.GetGPGKey(c.Request.Context(), userID, id)
+	if err != nil {
+		h.keyError(c, err, "Failed to get GPG key")
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// DeleteGPGKey handles DELETE /api/v1/user/gpg_keys/:id
+func (h *SigningKeyHandlers) DeleteGPGKey(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	id, ok := h.keyID(c)
+	if !ok {
+		return
+	}
+	if err := h.signingKeyService.DeleteGPGKey(c.Request.Context(), userID, id); err != nil {
+		h.keyError(c, err, "Failed to delete GPG key")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListSSHSigningKeys handles GET /api/v1/user/ssh_signing_keys
+func (h *SigningKeyHandlers) ListSSHSigningKeys(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	keys, err := h.signingKeyService.ListSSHSigningKeys(c.Request.Context(), userID)
+	if err != nil {
+		h.keyError(c, err, "Failed to list SSH signing keys")
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// AddSSHSigningKey handles POST /api/v1/user/ssh_signing_keys
+func (h *SigningKeyHandlers) AddSSHSigningKey(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req AddSSHSigningKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+	key, err := h.signingKeyService.AddSSHSigningKey(c.Request.Context(), userID, req.Title, req.Key)
+	if err != nil {
+		h.keyError(c, err, "Failed to add SSH signing key")
+		return
+	}
+	c.JSON(http.StatusCreated, key)
+}
+
+// GetSSHSigningKey handles GET /api/v1/user/ssh_signing_keys/:id
+func (h *SigningKeyHandlers) GetSSHSigningKey(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	id, ok := h.keyID(c)
+	if !ok {
+		return
+	}
+	key, err := h.signingKeyService.GetSSHSigningKey(c.Request.Context(), userID, id)
+	if err != nil {
+		h.keyError(c, err, "Failed to get SSH signing key")
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+// DeleteSSHSigningKey handles DELETE /api/v1/user/ssh_signing_keys/:id
+func (h *SigningKeyHandlers) DeleteSSHSigningKey(c *gin.Context) {
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	id, ok := h.keyID(c)
+	if !ok {
+		return
+	}
+	if err := h.signingKeyService.DeleteSSHSigningKey(c.Request.Context(), userID, id); err != nil {
+		h.keyError(c, err, "Failed to delete SSH signing key")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/a5c-ai/hub/internal/audit"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -144,6 +145,13 @@ func (h *SSHKeyHandlers) CreateSSHKey(c *gin.Context) {
 		"key_id":      sshKey.ID,
 		"fingerprint": fingerprint,
 	}).Info("SSH key created")
+	audit.Record(c.Request.Context(), audit.Event{
+		Action:     audit.ActionSSHKeyAdd,
+		TargetType: "ssh_key",
+		TargetID:   sshKey.ID.String(),
+		TargetName: sshKey.Title,
+		Metadata:   map[string]interface{}{"fingerprint": fingerprint},
+	})
 
 	response := SSHKeyResponse{
 		ID:          sshKey.ID,
@@ -236,6 +244,7 @@ func (h *SSHKeyHandlers) DeleteSSHKey(c *gin.Context) {
 		"user_id": uid,
 		"key_id":  keyID,
 	}).Info("SSH key deleted")
+	audit.Record(c.Request.Context(), audit.Event{Action: audit.ActionSSHKeyRemove, TargetType: "ssh_key", TargetID: keyID.String()})
 
 	c.JSON(http.StatusNoContent, nil)
 }
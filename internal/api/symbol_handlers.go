@@ -0,0 +1,147 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// SymbolHandlers serves code navigation symbol endpoints
+type SymbolHandlers struct {
+	symbolService     services.SymbolService
+	repositoryService services.RepositoryService
+	logger            *logrus.Logger
+}
+
+func NewSymbolHandlers(symbolService services.SymbolService, repositoryService services.RepositoryService, logger *logrus.Logger) *SymbolHandlers {
+	return &SymbolHandlers{
+		symbolService:     symbolService,
+		repositoryService: repositoryService,
+		logger:            logger,
+	}
+}
+
+// SearchSymbols handles GET /api/v1/repositories/{owner}/{repo}/symbols
+//
+// Query parameters: q (name prefix), exact, kind, path, ref, definitions_only,
+// scope ("repository" or "owner" for the owner's public repositories),
+// page and per_page.
+func (h *SymbolHandlers) SearchSymbols(c *gin.Context) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return
+	}
+
+	// Private repositories are only navigable by users with read access
+	if repo.Visibility != models.VisibilityPublic {
+		if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionRead) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+			return
+		}
+	}
+
+	opts := services.SymbolSearchOptions{
+		Query:   c.Query("q"),
+		Kind:    c.Query("kind"),
+		Path:    c.Query("path"),
+		Ref:     c.Query("ref"),
+		Page:    1,
+		PerPage: 30,
+	}
+	opts.Exact, _ = strconv.ParseBool(c.Query("exact"))
+	opts.DefinitionsOnly, _ = strconv.ParseBool(c.Query("definitions_only"))
+	switch scope := c.DefaultQuery("scope", "repository"); scope {
+	case "repository":
+	case "owner":
+		opts.CrossRepository = true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be 'repository' or 'owner'"})
+		return
+	}
+	if opts.Query == "" && opts.Path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'q' or 'path' is required"})
+		return
+	}
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+		opts.Page = page
+	}
+	if perPage, err := strconv.Atoi(c.Query("per_page")); err == nil && perPage > 0 && perPage <= 100 {
+		opts.PerPage = perPage
+	}
+
+	symbols, total, err := h.symbolService.Search(c.Request.Context(), repo, opts)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to search symbols")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search symbols"})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, gin.H{
+		"symbols":   symbols,
+		"total":     total,
+		"languages": h.symbolService.Languages(),
+	})
+}
+
+// ReindexSymbols handles POST /api/v1/repositories/{owner}/{repo}/symbols/reindex
+func (h *SymbolHandlers) ReindexSymbols(c *gin.Context) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		return
+	}
+
+	if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Write access is required"})
+		return
+	}
+
+	ref := c.DefaultQuery("ref", repo.DefaultBranch)
+	count, err := h.symbolService.IndexRef(c.Request.Context(), repo.ID, ref)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to index symbols")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to index symbols"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ref": ref, "symbols": count})
+}
+
+// GetSymbolIndexStatus handles GET /api/v1/repositories/{owner}/{repo}/symbols/status
+func (h *SymbolHandlers) GetSymbolIndexStatus(c *gin.Context) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return
+	}
+
+	if repo.Visibility != models.VisibilityPublic {
+		if t, ok := tenant.FromContext(c.Request.Context()); !ok || !t.HasPermission(models.PermissionRead) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+			return
+		}
+	}
+
+	index, err := h.symbolService.IndexStatus(c.Request.Context(), repo)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get symbol index status")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get symbol index status"})
+		return
+	}
+	c.JSON(http.StatusOK, index)
+}
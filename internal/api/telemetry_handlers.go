@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// TelemetryHandlers serves the admin telemetry endpoints
+type TelemetryHandlers struct {
+	telemetryService services.TelemetryService
+	logger           *logrus.Logger
+}
+
+func NewTelemetryHandlers(telemetryService services.TelemetryService, logger *logrus.Logger) *TelemetryHandlers {
+	return &TelemetryHandlers{
+		telemetryService: telemetryService,
+		logger:           logger,
+	}
+}
+
+// GetTelemetry handles GET /api/v1/admin/telemetry
+func (h *TelemetryHandlers) GetTelemetry(c *gin.Context) {
+	submissions, _, err := h.telemetryService.ListSubmissions(c.Request.Context(), 1, 0)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get last telemetry submission")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get telemetry settings"})
+		return
+	}
+
+	var last *models.TelemetrySubmission
+	if len(submissions) > 0 {
+		last = submissions[0]
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"settings":        h.telemetryService.Settings(),
+		"last_submission": last,
+	})
+}
+
+// PreviewTelemetry handles GET /api/v1/admin/telemetry/preview, returning
+// exactly the report the next submission would send
+func (h *TelemetryHandlers) PreviewTelemetry(c *gin.Context) {
+	report, err := h.telemetryService.Preview(c.Request.Context())
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to build telemetry report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build telemetry report"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ListTelemetrySubmissions handles GET /api/v1/admin/telemetry/submissions
+func (h *TelemetryHandlers) ListTelemetrySubmissions(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	perPage, _ := strconv.Atoi(c.DefaultQuery("per_page", "30"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 30
+	}
+
+	submissions, total, err := h.telemetryService.ListSubmissions(c.Request.Context(), perPage, (page-1)*perPage)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to list telemetry submissions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list telemetry submissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"submissions": submissions,
+		"total_count": total,
+		"page":        page,
+		"per_page":    perPage,
+	})
+}
+
+// SubmitTelemetry handles POST /api/v1/admin/telemetry/submit
+func (h *TelemetryHandlers) SubmitTelemetry(c *gin.Context) {
+	submission, err := h.telemetryService.Submit(c.Request.Context(), models.TelemetryManual)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, submission)
+	case errors.Is(err, services.ErrTelemetryDisabled):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case submission != nil:
+		// The endpoint could not be reached; the failed attempt is recorded
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "submission": submission})
+	default:
+		h.logger.WithError(err).Error("Failed to submit telemetry report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to submit telemetry report"})
+	}
+}
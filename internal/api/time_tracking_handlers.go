@@ -0,0 +1,283 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// TimeTrackingHandlers serves issue time tracking, time reports and the
+// milestones reports are grouped by
+type TimeTrackingHandlers struct {
+	timeTrackingService services.TimeTrackingService
+	milestoneService    services.MilestoneService
+	issueService        services.IssueService
+	logger              *logrus.Logger
+}
+
+func NewTimeTrackingHandlers(timeTrackingService services.TimeTrackingService, milestoneService services.MilestoneService, issueService services.IssueService, logger *logrus.Logger) *TimeTrackingHandlers {
+	return &TimeTrackingHandlers{
+		timeTrackingService: timeTrackingService,
+		milestoneService:    milestoneService,
+		issueService:        issueService,
+		logger:              logger,
+	}
+}
+
+// getIssue resolves the repository and issue in the path
+func (h *TimeTrackingHandlers) getIssue(c *gin.Context, permission models.Permission) (*models.Issue, bool) {
+	repo, ok := tenantRepository(c, permission)
+	if !ok {
+		return nil, false
+	}
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid issue number"})
+		return nil, false
+	}
+	issue, err := h.issueService.Get(c.Request.Context(), repo.ID, number)
+	if err != nil {
+		h.timeError(c, err, "Failed to get issue")
+		return nil, false
+	}
+	return issue, true
+}
+
+func (h *TimeTrackingHandlers) timeError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrIssueNotFound), errors.Is(err, services.ErrMilestoneNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTimeSpent), errors.Is(err, services.ErrInvalidMilestone):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// GetTimeStats handles GET /api/v1/repositories/{owner}/{repo}/issues/{number}/time_stats
+func (h *TimeTrackingHandlers) GetTimeStats(c *gin.Context) {
+	issue, ok := h.getIssue(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	entries, err := h.timeTrackingService.ListEntries(c.Request.Context(), issue)
+	if err != nil {
+		h.timeError(c, err, "Failed to list time entries")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"stats": h.timeTrackingService.Stats(issue), "entries": entries})
+}
+
+// SetTimeEstimate handles POST /api/v1/repositories/{owner}/{repo}/issues/{number}/time_estimate
+func (h *TimeTrackingHandlers) SetTimeEstimate(c *gin.Context) {
+	issue, ok := h.getIssue(c, models.PermissionTriage)
+	if !ok {
+		return
+	}
+	var req struct {
+		Duration string `json:"duration" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	stats, err := h.timeTrackingService.SetEstimate(c.Request.Context(), issue, req.Duration)
+	if err != nil {
+		h.timeError(c, err, "Failed to set time estimate")
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// ResetTimeEstimate handles POST /api/v1/repositories/{owner}/{repo}/issues/{number}/reset_time_estimate
+func (h *TimeTrackingHandlers) ResetTimeEstimate(c *gin.Context) {
+	issue, ok := h.getIssue(c, models.PermissionTriage)
+	if !ok {
+		return
+	}
+	stats, err := h.timeTrackingService.ResetEstimate(c.Request.Context(), issue)
+	if err != nil {
+		h.timeError(c, err, "Failed to reset time estimate")
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// AddSpentTime handles POST /api/v1/repositories/{owner}/{repo}/issues/{number}/add_spent_time
+func (h *TimeTrackingHandlers) AddSpentTime(c *gin.Context) {
+	issue, ok := h.getIssue(c, models.PermissionTriage)
+	if !ok {
+		return
+	}
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	var req services.AddSpentTimeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	stats, err := h.timeTrackingService.AddSpentTime(c.Request.Context(), issue, userID, req)
+	if err != nil {
+		h.timeError(c, err, "Failed to add spent time")
+		return
+	}
+	c.JSON(http.StatusCreated, stats)
+}
+
+// ResetSpentTime handles POST /api/v1/repositories/{owner}/{repo}/issues/{number}/reset_spent_time
+func (h *TimeTrackingHandlers) ResetSpentTime(c *gin.Context) {
+	issue, ok := h.getIssue(c, models.PermissionTriage)
+	if !ok {
+		return
+	}
+	userID, ok := actor(c)
+	if !ok {
+		return
+	}
+	stats, err := h.timeTrackingService.ResetSpentTime(c.Request.Context(), issue, userID)
+	if err != nil {
+		h.timeError(c, err, "Failed to reset spent time")
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetTimeReport handles GET /api/v1/repositories/{owner}/{repo}/time_report.
+// It totals estimates and spent time per user and per milestone, optionally
+// restricted to time spent between the since and until RFC 3339 timestamps.
+func (h *TimeTrackingHandlers) GetTimeReport(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	filter := services.TimeReportFilter{RepositoryID: &repo.ID}
+	for param, target := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if value := c.Query(param); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param + " timestamp"})
+				return
+			}
+			*target = &parsed
+		}
+	}
+
+	report, err := h.timeTrackingService.Report(c.Request.Context(), filter)
+	if err != nil {
+		h.timeError(c, err, "Failed to build time report")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// ListMilestones handles GET /api/v1/repositories/{owner}/{repo}/milestones
+func (h *TimeTrackingHandlers) ListMilestones(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	var state *models.MilestoneState
+	if s := c.Query("state"); s != "" && s != "all" {
+		milestoneState := models.MilestoneState(s)
+		state = &milestoneState
+	}
+
+	milestones, err := h.milestoneService.List(c.Request.Context(), repo.ID, state)
+	if err != nil {
+		h.timeError(c, err, "Failed to list milestones")
+		return
+	}
+	c.JSON(http.StatusOK, milestones)
+}
+
+// CreateMilestone handles POST /api/v1/repositories/{owner}/{repo}/milestones
+func (h *TimeTrackingHandlers) CreateMilestone(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	var req services.CreateMilestoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	milestone, err := h.milestoneService.Create(c.Request.Context(), repo.ID, req)
+	if err != nil {
+		h.timeError(c, err, "Failed to create milestone")
+		return
+	}
+	c.JSON(http.StatusCreated, milestone)
+}
+
+// getMilestone resolves the repository and milestone in the path
+func (h *TimeTrackingHandlers) getMilestone(c *gin.Context, permission models.Permission) (*models.Milestone, bool) {
+	repo, ok := tenantRepository(c, permission)
+	if !ok {
+		return nil, false
+	}
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid milestone number"})
+		return nil, false
+	}
+	milestone, err := h.milestoneService.Get(c.Request.Context(), repo.ID, number)
+	if err != nil {
+		h.timeError(c, err, "Failed to get milestone")
+		return nil, false
+	}
+	return milestone, true
+}
+
+// GetMilestone handles GET /api/v1/repositories/{owner}/{repo}/milestones/{number}
+func (h *TimeTrackingHandlers) GetMilestone(c *gin.Context) {
+	milestone, ok := h.getMilestone(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, milestone)
+}
+
+// UpdateMilestone handles PATCH /api/v1/repositories/{owner}/{repo}/milestones/{number}
+func (h *TimeTrackingHandlers) UpdateMilestone(c *gin.Context) {
+	milestone, ok := h.getMilestone(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	var req services.UpdateMilestoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return
+	}
+
+	updated, err := h.milestoneService.Update(c.Request.Context(), milestone, req)
+	if err != nil {
+		h.timeError(c, err, "Failed to update milestone")
+		return
+	}
+	c.JSON(http.StatusOK, updated)
+}
+
+// DeleteMilestone handles DELETE /api/v1/repositories/{owner}/{repo}/milestones/{number}
+func (h *TimeTrackingHandlers) DeleteMilestone(c *gin.Context) {
+	milestone, ok := h.getMilestone(c, models.PermissionWrite)
+	if !ok {
+		return
+	}
+	if err := h.milestoneService.Delete(c.Request.Context(), milestone); err != nil {
+		h.timeError(c, err, "Failed to delete milestone")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,406 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const lfsPointerVersion = "https://git-lfs.github.com/spec/v1"
+
+// UploadHandlers serves the multipart file upload endpoint
+type UploadHandlers struct {
+	repositoryService services.RepositoryService
+	branchService     services.BranchService
+	gitService        git.GitService
+	lfsService        services.LFSService
+	malwareService    services.MalwareScanService
+	limits            config.UploadLimits
+	lfsThreshold      int64 // bytes; 0 disables LFS routing
+	db                *gorm.DB
+	logger            *logrus.Logger
+	// pushDispatcher, when set, is notified of the branch update of each upload
+	pushDispatcher *services.PushDispatcher
+}
+
+func NewUploadHandlers(repositoryService services.RepositoryService, branchService services.BranchService, gitService git.GitService, lfsService services.LFSService, malwareService services.MalwareScanService, pushDispatcher *services.PushDispatcher, limits config.UploadLimits, lfsThresholdMB int64, db *gorm.DB, logger *logrus.Logger) *UploadHandlers {
+	return &UploadHandlers{
+		repositoryService: repositoryService,
+		branchService:     branchService,
+		gitService:        gitService,
+		lfsService:        lfsService,
+		malwareService:    malwareService,
+		limits:            limits,
+		lfsThreshold:      lfsThresholdMB * 1024 * 1024,
+		db:                db,
+		logger:            logger,
+		pushDispatcher:    pushDispatcher,
+	}
+}
+
+// uploadedFile is a multipart file part spooled to disk
+type uploadedFile struct {
+	name   string
+	tmp    *os.File
+	size   int64
+	sha256 string
+}
+
+// UploadFiles handles POST /api/v1/repositories/{owner}/{repo}/upload
+//
+// The multipart form accepts one or more "files" parts, whose file names are
+// used as paths relative to the optional "directory" field, plus "message",
+// "branch", "author_name" and "author_email". All files land in one commit.
+func (h *UploadHandlers) UploadFiles(c *gin.Context) {
+	repo, err := h.repositoryService.Get(c.Request.Context(), c.Param("owner"), c.Param("repo"))
+	if err != nil {
+		if err.Error() == "repository not found" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository"})
+		}
+		return
+	}
+
+	t, ok := tenant.FromContext(c.Request.Context())
+	if !ok || !t.HasPermission(models.PermissionWrite) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Write access is required"})
+		return
+	}
+
+	if h.limits.MaxRequestSizeMB > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.limits.MaxRequestSizeMB*1024*1024)
+	}
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request must be multipart/form-data"})
+		return
+	}
+
+	fields := map[string]string{}
+	var files []*uploadedFile
+	defer func() {
+		for _, f := range files {
+			f.tmp.Close()
+			os.Remove(f.tmp.Name())
+		}
+	}()
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			h.uploadError(c, err)
+			return
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(io.LimitReader(part, 64*1024))
+			part.Close()
+			if err != nil {
+				h.uploadError(c, err)
+				return
+			}
+			fields[part.FormName()] = string(value)
+			continue
+		}
+
+		if h.limits.MaxFiles > 0 && len(files) >= h.limits.MaxFiles {
+			part.Close()
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("At most %d files may be uploaded at once", h.limits.MaxFiles)})
+			return
+		}
+
+		file, err := h.spool(part)
+		part.Close()
+		if file != nil {
+			files = append(files, file)
+		}
+		if err != nil {
+			h.uploadError(c, err)
+			return
+		}
+	}
+
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one file is required"})
+		return
+	}
+
+	branch := fields["branch"]
+	if branch == "" {
+		branch = repo.DefaultBranch
+	}
+	// Uploads commit straight to the branch, so protected branches only
+	// change through pull requests
+	if _, err := h.branchService.GetProtectionRuleForBranch(c.Request.Context(), repo.ID, branch); err == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Branch '%s' is protected; open a pull request instead", branch)})
+		return
+	} else if err.Error() != "no protection rule found for branch '"+branch+"'" {
+		h.logger.WithError(err).Error("Failed to get branch protection rule")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get branch protection"})
+		return
+	}
+	message := fields["message"]
+	if message == "" {
+		message = fmt.Sprintf("Upload %d file(s)", len(files))
+	}
+
+	author := git.CommitAuthor{Name: fields["author_name"], Email: fields["author_email"]}
+	if author.Name == "" || author.Email == "" {
+		var user models.User
+		if t.UserID != nil && h.db.WithContext(c.Request.Context()).First(&user, "id = ?", *t.UserID).Error == nil {
+			if author.Name == "" {
+				author.Name = user.FullName
+				if author.Name == "" {
+					author.Name = user.Username
+				}
+			}
+			if author.Email == "" {
+				author.Email = user.Email
+			}
+		}
+	}
+
+	req := git.CommitFilesRequest{Message: message, Branch: branch, Author: author}
+	var lfsPaths []string
+	results := make([]gin.H, 0, len(files))
+	for _, f := range files {
+		filePath, err := uploadPath(fields["directory"], f.name)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "file": f.name})
+			return
+		}
+
+		entry := git.CommitFileEntry{Path: filePath}
+		storedInLFS := h.lfsService != nil && h.lfsThreshold > 0 && f.size >= h.lfsThreshold
+		if storedInLFS {
+			if _, err := f.tmp.Seek(0, io.SeekStart); err != nil {
+				h.uploadError(c, err)
+				return
+			}
+			if err := h.lfsService.Upload(c.Request.Context(), repo, f.sha256, f.size, f.tmp); err != nil {
+				if errors.Is(err, services.ErrLFSQuotaExceeded) {
+					c.JSON(http.StatusInsufficientStorage, gin.H{"error": err.Error(), "file": f.name})
+					return
+				}
+				if h.malwareError(c, err, f.name) {
+					return
+				}
+				h.logger.WithError(err).Error("Failed to store upload in LFS")
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store file in LFS"})
+				return
+			}
+			entry.Content = []byte(fmt.Sprintf("version %s\noid sha256:%s\nsize %d\n", lfsPointerVersion, f.sha256, f.size))
+			lfsPaths = append(lfsPaths, filePath)
+		} else {
+			// Committed files cannot be quarantined, so detections are rejected
+			if _, err := f.tmp.Seek(0, io.SeekStart); err != nil {
+				h.uploadError(c, err)
+				return
+			}
+			target := services.MalwareScanTarget{Kind: models.MalwareContentUpload, ContentID: f.sha256, RepositoryID: repo.ID, Name: filePath, Size: f.size, UploaderID: t.UserID}
+			if _, err := h.malwareService.Scan(c.Request.Context(), target, f.tmp); err != nil {
+				if !h.malwareError(c, err, f.name) {
+					h.logger.WithError(err).Error("Failed to scan upload")
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan file"})
+				}
+				return
+			}
+			if _, err := f.tmp.Seek(0, io.SeekStart); err != nil {
+				h.uploadError(c, err)
+				return
+			}
+			entry.Reader = f.tmp
+		}
+
+		req.Files = append(req.Files, entry)
+		results = append(results, gin.H{"path": filePath, "size": f.size, "sha256": f.sha256, "lfs": storedInLFS})
+	}
+
+	repoPath, err := h.repositoryService.GetRepositoryPath(c.Request.Context(), repo.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get repository path"})
+		return
+	}
+
+	if len(lfsPaths) > 0 {
+		if attributes := h.trackLFSPaths(c, repoPath, branch, lfsPaths); attributes != nil {
+			req.Files = append(req.Files, *attributes)
+		}
+	}
+
+	commit, err := h.gitService.CommitFiles(c.Request.Context(), repoPath, req)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to commit uploaded files")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit files", "details": err.Error()})
+		return
+	}
+
+	if h.pushDispatcher != nil {
+		before := map[string]string{}
+		if len(commit.Parents) > 0 {
+			before["refs/heads/"+branch] = commit.Parents[0]
+		}
+		h.pushDispatcher.Dispatch(services.PushEvent{
+			Repository: repo,
+			PusherID:   t.UserID,
+			Updates:    services.DiffRefs(before, map[string]string{"refs/heads/" + branch: commit.SHA}),
+		})
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"files":  results,
+		"commit": commit,
+	})
+}
+
+// spool copies a file part to a temporary file, hashing it on the way and
+// enforcing the per-file size limit
+func (h *UploadHandlers) spool(part *multipart.Part) (*uploadedFile, error) {
+	tmp, err := os.CreateTemp("", "hub-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	file := &uploadedFile{name: partFileName(part), tmp: tmp}
+
+	hasher := sha256.New()
+	var src io.Reader = part
+	maxSize := h.limits.MaxFileSizeMB * 1024 * 1024
+	if maxSize > 0 {
+		src = io.LimitReader(part, maxSize+1)
+	}
+	file.size, err = io.Copy(io.MultiWriter(tmp, hasher), src)
+	if err != nil {
+		return file, err
+	}
+	if maxSize > 0 && file.size > maxSize {
+		return file, &fileTooLargeError{name: file.name, limitMB: h.limits.MaxFileSizeMB}
+	}
+	file.sha256 = hex.EncodeToString(hasher.Sum(nil))
+	return file, nil
+}
+
+// trackLFSPaths returns an updated .gitattributes that marks paths as LFS
+// tracked, or nil when every path is already listed
+func (h *UploadHandlers) trackLFSPaths(c *gin.Context, repoPath, branch string, paths []string) *git.CommitFileEntry {
+	var existing string
+	if file, err := h.gitService.GetFile(c.Request.Context(), repoPath, branch, ".gitattributes"); err == nil {
+		existing = file.Content
+		if file.Encoding == "base64" {
+			if decoded, err := base64.StdEncoding.DecodeString(file.Content); err == nil {
+				existing = string(decoded)
+			}
+		}
+	}
+
+	tracked := map[string]bool{}
+	for _, line := range strings.Split(existing, "\n") {
+		if fields := strings.Fields(line); len(fields) > 1 && strings.Contains(line, "filter=lfs") {
+			tracked[fields[0]] = true
+		}
+	}
+
+	updated := existing
+	for _, p := range paths {
+		pattern := "/" + strings.ReplaceAll(p, " ", "[[:space:]]")
+		if tracked[pattern] {
+			continue
+		}
+		tracked[pattern] = true
+		if updated != "" && !strings.HasSuffix(updated, "\n") {
+			updated += "\n"
+		}
+		updated += pattern + " filter=lfs diff=lfs merge=lfs -text\n"
+	}
+	if updated == existing {
+		return nil
+	}
+	return &git.CommitFileEntry{Path: ".gitattributes", Content: []byte(updated)}
+}
+
+func (h *UploadHandlers) uploadError(c *gin.Context, err error) {
+	var maxBytesErr *http.MaxBytesError
+	var tooLarge *fileTooLargeError
+	switch {
+	case errors.As(err, &tooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": tooLarge.Error()})
+	case errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Upload exceeds the %d MB request limit", h.limits.MaxRequestSizeMB)})
+	default:
+		h.logger.WithError(err).Error("Failed to read upload")
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read upload"})
+	}
+}
+
+// malwareError writes the response for failed malware scans, reporting
+// whether err was one
+func (h *UploadHandlers) malwareError(c *gin.Context, err error, name string) bool {
+	switch {
+	case errors.Is(err, services.ErrMalwareDetected):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error(), "file": name})
+	case errors.Is(err, services.ErrMalwareScanFailed):
+		h.logger.WithError(err).Error("Failed to scan upload for malware")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Malware scanning is unavailable", "file": name})
+	default:
+		return false
+	}
+	return true
+}
+
+type fileTooLargeError struct {
+	name    string
+	limitMB int64
+}
+
+func (e *fileTooLargeError) Error() string {
+	return fmt.Sprintf("File %s exceeds the %d MB limit", e.name, e.limitMB)
+}
+
+// partFileName returns the file name as sent by the client. Part.FileName
+// strips directories, which would flatten folder uploads.
+func partFileName(part *multipart.Part) string {
+	if _, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+	return part.FileName()
+}
+
+// uploadPath joins dir and name into a clean repository-relative path,
+// rejecting traversal and writes into .git
+func uploadPath(dir, name string) (string, error) {
+	joined := path.Clean("/" + path.Join(dir, name))
+	rel := strings.TrimPrefix(joined, "/")
+	if rel == "" || rel == "." {
+		return "", fmt.Errorf("invalid file path %q", name)
+	}
+	for _, segment := range strings.Split(path.Join(dir, name), "/") {
+		if segment == ".." {
+			return "", fmt.Errorf("invalid file path %q", name)
+		}
+	}
+	if rel == ".git" || strings.HasPrefix(rel, ".git/") {
+		return "", fmt.Errorf("invalid file path %q", name)
+	}
+	return rel, nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/a5c-ai/hub/internal/auth"
 	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/i18n"
 	"github.com/a5c-ai/hub/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -25,6 +26,7 @@ type UserHandlers struct {
 	config              *config.Config
 	logger              *logrus.Logger
 	notificationService services.NotificationService
+	bundle              *i18n.Bundle
 }
 
 // NewUserHandlers creates a new user handlers instance
@@ -35,6 +37,7 @@ func NewUserHandlers(
 	cfg *config.Config,
 	logger *logrus.Logger,
 	notificationService services.NotificationService,
+	bundle *i18n.Bundle,
 ) *UserHandlers {
 	return &UserHandlers{
 		authService:         authService,
@@ -42,6 +45,7 @@ func NewUserHandlers(
 		config:              cfg,
 		logger:              logger,
 		notificationService: notificationService,
+		bundle:              bundle,
 	}
 }
 
@@ -62,18 +66,19 @@ func (h *UserHandlers) GetUserProfile(c *gin.Context) {
 
 	// Return public user profile information
 	c.JSON(http.StatusOK, gin.H{
-		"id":         user.ID,
-		"username":   user.Username,
-		"email":      user.Email,
-		"full_name":  user.FullName,
-		"avatar_url": user.AvatarURL,
-		"bio":        user.Bio,
-		"company":    user.Company,
-		"location":   user.Location,
-		"website":    user.Website,
-		"created_at": user.CreatedAt,
-		"updated_at": user.UpdatedAt,
-		"type":       "user",
+		"id":          user.ID,
+		"username":    user.Username,
+		"email":       user.Email,
+		"full_name":   user.FullName,
+		"avatar_url":  user.AvatarURL,
+		"bio":         user.Bio,
+		"company":     user.Company,
+		"location":    user.Location,
+		"website":     user.Website,
+		"created_at":  user.CreatedAt,
+		"updated_at":  user.UpdatedAt,
+		"type":        "user",
+		"is_external": user.IsExternal,
 	})
 }
 
@@ -103,11 +108,13 @@ func (h *UserHandlers) GetCurrentUserProfile(c *gin.Context) {
 		"company":        user.Company,
 		"location":       user.Location,
 		"website":        user.Website,
+		"locale":         user.Locale,
 		"email_verified": user.EmailVerified,
 		"mfa_enabled":    user.TwoFactorEnabled,
 		"created_at":     user.CreatedAt,
 		"updated_at":     user.UpdatedAt,
 		"type":           "user",
+		"is_external":    user.IsExternal,
 	})
 }
 
@@ -126,12 +133,20 @@ func (h *UserHandlers) UpdateUserProfile(c *gin.Context) {
 		Location  *string `json:"location,omitempty"`
 		Website   *string `json:"website,omitempty"`
 		AvatarURL *string `json:"avatar_url,omitempty"`
+		Locale    *string `json:"locale,omitempty"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
 		return
 	}
+	if req.Locale != nil && *req.Locale != "" && !h.bundle.Supports(*req.Locale) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             i18n.For(c.Request.Context()).T("error.unsupported_locale", "locale", *req.Locale),
+			"supported_locales": h.bundle.Locales(),
+		})
+		return
+	}
 
 	// Get current user
 	user, err := h.authService.GetUserByID(userID.(uuid.UUID))
@@ -160,6 +175,9 @@ func (h *UserHandlers) UpdateUserProfile(c *gin.Context) {
 	if req.AvatarURL != nil {
 		user.AvatarURL = *req.AvatarURL
 	}
+	if req.Locale != nil {
+		user.Locale = *req.Locale
+	}
 
 	// Update user in database
 	if err := h.authService.UpdateUser(user); err != nil {
@@ -184,6 +202,7 @@ func (h *UserHandlers) UpdateUserProfile(c *gin.Context) {
 		"created_at":     user.CreatedAt,
 		"updated_at":     user.UpdatedAt,
 		"type":           "user",
+		"is_external":    user.IsExternal,
 	})
 }
 
@@ -250,59 +269,6 @@ func (h *UserHandlers) GetUserActivity(c *gin.Context) {
 	})
 }
 
-// GetNotifications handles GET /api/v1/notifications
-func (h *UserHandlers) GetNotifications(c *gin.Context) {
-	_, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Parse query parameters
-	participating := c.Query("participating") == "true"
-	all := c.Query("all") == "true"
-
-	// For now, return empty notifications list
-	// In a full implementation, this would query notifications from the database
-	c.JSON(http.StatusOK, gin.H{
-		"notifications": []gin.H{},
-		"pagination": gin.H{
-			"page":     1,
-			"per_page": 30,
-			"total":    0,
-			"has_more": false,
-		},
-		"filters": gin.H{
-			"participating": participating,
-			"all":           all,
-		},
-	})
-}
-
-// MarkNotificationsAsRead handles PATCH /api/v1/notifications
-func (h *UserHandlers) MarkNotificationsAsRead(c *gin.Context) {
-	_, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	var req struct {
-		LastReadAt string `json:"last_read_at,omitempty"`
-	}
-
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
-		return
-	}
-
-	// For now, just return success
-	// In a full implementation, this would update notification read status
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Notifications marked as read",
-	})
-}
-
 // SubscribeNotifications upgrades connection to WebSocket and streams real-time notifications
 func (h *UserHandlers) SubscribeNotifications(c *gin.Context) {
 	userIDVal, exists := c.Get("user_id")
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/i18n"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// UserPreferencesHandlers serves the current user's preferences
+type UserPreferencesHandlers struct {
+	preferencesService services.UserPreferencesService
+	logger             *logrus.Logger
+}
+
+func NewUserPreferencesHandlers(preferencesService services.UserPreferencesService, logger *logrus.Logger) *UserPreferencesHandlers {
+	return &UserPreferencesHandlers{
+		preferencesService: preferencesService,
+		logger:             logger,
+	}
+}
+
+// GetPreferences handles GET /api/v1/user/preferences
+func (h *UserPreferencesHandlers) GetPreferences(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.For(c.Request.Context()).T("error.not_authenticated")})
+		return
+	}
+	uid, err := parseUserID(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.For(c.Request.Context()).T("error.invalid_user_id")})
+		return
+	}
+
+	prefs, err := h.preferencesService.Get(c.Request.Context(), uid)
+	if err != nil {
+		h.logger.WithError(err).Error("Failed to get user preferences")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// UpdatePreferences handles PATCH /api/v1/user/preferences
+func (h *UserPreferencesHandlers) UpdatePreferences(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": i18n.For(c.Request.Context()).T("error.not_authenticated")})
+		return
+	}
+	uid, err := parseUserID(userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": i18n.For(c.Request.Context()).T("error.invalid_user_id")})
+		return
+	}
+
+	var req services.UpdateUserPreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	prefs, err := h.preferencesService.Update(c.Request.Context(), uid, req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPreference) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		h.logger.WithError(err).Error("Failed to update user preferences")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// maxWorkflowLogChunk bounds a single log upload from a runner
+const maxWorkflowLogChunk = 1 << 20
+
+// WorkflowHandlers serves workflow runs, their jobs and logs, and the runner
+// endpoints reporting job progress
+type WorkflowHandlers struct {
+	workflowService services.WorkflowService
+	logger          *logrus.Logger
+}
+
+func NewWorkflowHandlers(workflowService services.WorkflowService, logger *logrus.Logger) *WorkflowHandlers {
+	return &WorkflowHandlers{
+		workflowService: workflowService,
+		logger:          logger,
+	}
+}
+
+func (h *WorkflowHandlers) workflowError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrWorkflowRunNotFound), errors.Is(err, services.ErrWorkflowJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidWorkflowStep):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrRunnerJobNotAssigned):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		h.logger.WithError(err).Error(message)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+func (h *WorkflowHandlers) getRun(c *gin.Context) (*models.WorkflowRun, bool) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return nil, false
+	}
+	runID, err := uuid.Parse(c.Param("run_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid run ID"})
+		return nil, false
+	}
+	run, err := h.workflowService.GetRun(c.Request.Context(), repo.ID, runID)
+	if err != nil {
+		h.workflowError(c, err, "Failed to get workflow run")
+		return nil, false
+	}
+	return run, true
+}
+
+func (h *WorkflowHandlers) getJob(c *gin.Context) (*models.WorkflowJob, bool) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return nil, false
+	}
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return nil, false
+	}
+	job, err := h.workflowService.GetJob(c.Request.Context(), repo.ID, jobID)
+	if err != nil {
+		h.workflowError(c, err, "Failed to get workflow job")
+		return nil, false
+	}
+	return job, true
+}
+
+// ListWorkflowRuns handles GET /api/v1/repositories/{owner}/{repo}/actions/runs
+func (h *WorkflowHandlers) ListWorkflowRuns(c *gin.Context) {
+	repo, ok := tenantRepository(c, models.PermissionRead)
+	if !ok {
+		return
+	}
+
+	filter := services.WorkflowRunFilter{Branch: c.Query("branch"), Event: c.Query("event")}
+	if status := c.Query("status"); status != "" {
+		runStatus := models.WorkflowStatus(status)
+		filter.Status = &runStatus
+	}
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+		filter.Page = page
+	}
+	if perPage, err := strconv.Atoi(c.Query("per_page")); err == nil && perPage > 0 && perPage <= 100 {
+		filter.PageSize = perPage
+	}
+
+	runs, err := h.workflowService.ListRuns(c.Request.Context(), repo.ID, filter)
+	if err != nil {
+		h.workflowError(c, err, "Failed to list workflow runs")
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// GetWorkflowRun handles GET /api/v1/repositories/{owner}/{repo}/actions/runs/{run_id}
+func (h *WorkflowHandlers) GetWorkflowRun(c *gin.Context) {
+	run, ok := h.getRun(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// ListWorkflowRunJobs handles GET /api/v1/repositories/{owner}/{repo}/actions/runs/{run_id}/jobs
+func (h *WorkflowHandlers) ListWorkflowRunJobs(c *gin.Context) {
+	run, ok := h.getRun(c)
+	if !ok {
+		return
+	}
+	jobs, err := h.workflowService.ListJobs(c.Request.Context(), run)
+	if err != nil {
+		h.workflowError(c, err, "Failed to list workflow jobs")
+		return
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// GetWorkflowJob handles GET /api/v1/repositories/{owner}/{repo}/actions/jobs/{job_id}
+func (h *WorkflowHandlers) GetWorkflowJob(c *gin.Context) {
+	job, ok := h.getJob(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// GetWorkflowJobLogs handles GET /api/v1/repositories/{owner}/{repo}/actions/jobs/{job_id}/logs
+func (h *WorkflowHandlers) GetWorkflowJobLogs(c *gin.Context) {
+	job, ok := h.getJob(c)
+	if !ok {
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(job.Log))
+}
+
+// runnerJobID parses the runner job in the path of a runner agent request
+func (h *WorkflowHandlers) runnerJobID(c *gin.Context) (uuid.UUID, bool) {
+	jobID, err := uuid.Parse(c.Param("job_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return uuid.Nil, false
+	}
+	return jobID, true
+}
+
+// UpdateRunnerJobStep handles POST /api/v1/runners/jobs/{job_id}/steps
+func (h *WorkflowHandlers) UpdateRunnerJobStep(c *gin.Context) {
+	jobID, ok := h.runnerJobID(c)
+	if !ok {
+		return
+	}
+	var req services.UpdateWorkflowStepRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	step, err := h.workflowService.UpdateStep(c.Request.Context(), currentRunner(c), jobID, req)
+	if err != nil {
+		h.workflowError(c, err, "Failed to update workflow step")
+		return
+	}
+	c.JSON(http.StatusOK, step)
+}
+
+// AppendRunnerJobLog handles POST /api/v1/runners/jobs/{job_id}/logs. The
+// body is plain text appended to the job's log.
+func (h *WorkflowHandlers) AppendRunnerJobLog(c *gin.Context) {
+	jobID, ok := h.runnerJobID(c)
+	if !ok {
+		return
+	}
+	output, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWorkflowLogChunk))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Log chunk is too large"})
+		return
+	}
+
+	if err := h.workflowService.AppendLog(c.Request.Context(), currentRunner(c), jobID, string(output)); err != nil {
+		h.workflowError(c, err, "Failed to append workflow log")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
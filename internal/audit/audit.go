@@ -0,0 +1,110 @@
+// Package audit records security-relevant actions, such as sign-ins,
+// permission changes, repository deletions, key uploads and webhook
+// changes, in the tamper-evident audit trail. Handlers and services call
+// Record; the trail itself is kept by the Recorder set at startup.
+package audit
+
+import (
+	"context"
+	"sync"
+
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/google/uuid"
+)
+
+// Actions recorded in the audit trail
+const (
+	ActionLogin       = "auth.login"
+	ActionLoginFailed = "auth.login_failed"
+
+	ActionRepositoryDelete = "repo.delete"
+	ActionPermissionGrant  = "repo.permission_grant"
+	ActionPermissionRevoke = "repo.permission_revoke"
+	ActionDeployKeyAdd     = "repo.deploy_key_add"
+	ActionDeployKeyRemove  = "repo.deploy_key_remove"
+	ActionChangeTicketLink = "repo.change_ticket_link"
+	ActionPullRequestMerge = "repo.pull_request_merge"
+
+	ActionMemberAdd        = "org.member_add"
+	ActionMemberRemove     = "org.member_remove"
+	ActionMemberRoleChange = "org.member_role_change"
+
+	ActionSSHKeyAdd        = "user.ssh_key_add"
+	ActionSSHKeyRemove     = "user.ssh_key_remove"
+	ActionSigningKeyAdd    = "user.signing_key_add"
+	ActionSigningKeyRemove = "user.signing_key_remove"
+	ActionAccountConvert   = "user.account_convert"
+
+	ActionWebhookCreate = "webhook.create"
+	ActionWebhookUpdate = "webhook.update"
+	ActionWebhookDelete = "webhook.delete"
+
+	// ActionPurge is recorded by the trail itself when retention removes
+	// old entries
+	ActionPurge = "audit.purge"
+)
+
+// Event is one action to record. Actor, client IP and user agent default
+// to the caller in the request's tenant context. Events on a repository
+// owned by an organization are scoped to that organization.
+type Event struct {
+	Action         string
+	ActorID        *uuid.UUID
+	ActorName      string
+	OrganizationID *uuid.UUID
+	RepositoryID   *uuid.UUID
+	TargetType     string
+	TargetID       string
+	TargetName     string
+	IPAddress      string
+	UserAgent      string
+	Metadata       map[string]interface{}
+}
+
+// Recorder keeps the audit trail
+type Recorder interface {
+	Record(ctx context.Context, event Event)
+}
+
+type discardRecorder struct{}
+
+func (discardRecorder) Record(ctx context.Context, event Event) {}
+
+var (
+	recorderMu      sync.RWMutex
+	defaultRecorder Recorder = discardRecorder{}
+)
+
+// SetDefaultRecorder sets the process wide Recorder behind Record; nil
+// discards every event
+func SetDefaultRecorder(r Recorder) {
+	if r == nil {
+		r = discardRecorder{}
+	}
+	recorderMu.Lock()
+	defaultRecorder = r
+	recorderMu.Unlock()
+}
+
+// Record adds event to the audit trail. Failures are logged by the
+// Recorder and never fail the action being recorded.
+func Record(ctx context.Context, event Event) {
+	if t, ok := tenant.FromContext(ctx); ok {
+		if event.ActorID == nil && t.UserID != nil {
+			actorID := *t.UserID
+			event.ActorID = &actorID
+			event.ActorName = t.Username
+		}
+		if event.IPAddress == "" {
+			event.IPAddress = t.ClientIP
+		}
+		if event.UserAgent == "" {
+			event.UserAgent = t.UserAgent
+		}
+	}
+
+	recorderMu.RLock()
+	r := defaultRecorder
+	recorderMu.RUnlock()
+	r.Record(ctx, event)
+}
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/google/uuid"
 	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/pquerna/otp/totp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	sqlite "gorm.io/driver/sqlite"
@@ -46,6 +49,7 @@ func setupTestDB(t *testing.T) *gorm.DB {
 		&Session{},
 		&BackupCode{},
 		&WebAuthnCredential{},
+		&WebAuthnSession{},
 		&SMSVerificationCode{},
 		&EmailVerificationToken{},
 		&PasswordResetToken{},
@@ -216,6 +220,7 @@ func TestMFASetup(t *testing.T) {
 	userID := uuid.New()
 
 	// Test TOTP setup
+	var setup *MFASetupResponse
 	t.Run("TOTP setup", func(t *testing.T) {
 		response, err := mfaService.SetupTOTP(userID, "Test App", "test@example.com")
 		assert.NoError(t, err)
@@ -223,6 +228,7 @@ func TestMFASetup(t *testing.T) {
 		assert.NotEmpty(t, response.Secret)
 		assert.NotEmpty(t, response.QRCodeURL)
 		assert.Len(t, response.BackupCodes, 10)
+		setup = response
 
 		// Verify backup codes were stored
 		var codes []BackupCode
@@ -233,29 +239,90 @@ func TestMFASetup(t *testing.T) {
 
 	// Test backup code usage
 	t.Run("backup code usage", func(t *testing.T) {
+		require.NotNil(t, setup)
 		// Get a backup code
-		var code BackupCode
-		err := db.Where("user_id = ? AND used = false", userID).First(&code).Error
-		require.NoError(t, err)
+		backupCode := setup.BackupCodes[0]
 
 		// Use the backup code
-		valid, err := mfaService.useBackupCode(userID, code.Code)
+		valid, err := mfaService.useBackupCode(userID, backupCode)
 		assert.NoError(t, err)
 		assert.True(t, valid)
 
 		// Verify code is marked as used
-		err = db.First(&code).Error
+		var code BackupCode
+		err = db.Where("user_id = ? AND code = ?", userID, hashBackupCode(backupCode)).First(&code).Error
 		assert.NoError(t, err)
 		assert.True(t, code.Used)
 		assert.NotNil(t, code.UsedAt)
 
 		// Try to use the same code again
-		valid, err = mfaService.useBackupCode(userID, code.Code)
+		valid, err = mfaService.useBackupCode(userID, backupCode)
 		assert.NoError(t, err)
 		assert.False(t, valid)
 	})
 }
 
+func TestTOTPEnrollment(t *testing.T) {
+	authService, db, _ := setupTestServices(t)
+	ctx := context.Background()
+	mfaService := NewMFAService(db)
+	user, err := authService.Register(ctx, RegisterRequest{
+		Username: "totpuser",
+		Email:    "totp@example.com",
+		Password: "SecurePassword123!",
+		FullName: "TOTP User",
+	})
+	require.NoError(t, err)
+
+	setup, err := mfaService.SetupTOTP(user.ID, "Test App", user.Username)
+	require.NoError(t, err)
+	assert.Contains(t, setup.QRCodeURL, "otpauth://totp/")
+	assert.Contains(t, setup.QRCodePNG, "data:image/png;base64,")
+
+	// The secret only takes effect once a code confirms it
+	status, err := mfaService.Status(user.ID)
+	require.NoError(t, err)
+	assert.False(t, status.Enabled)
+	assert.True(t, status.TOTPPending)
+	valid, err := mfaService.ConfirmTOTP(user.ID, "000000")
+	require.NoError(t, err)
+	assert.False(t, valid)
+	code, err := totp.GenerateCode(setup.Secret, time.Now())
+	require.NoError(t, err)
+	valid, err = mfaService.ConfirmTOTP(user.ID, code)
+	require.NoError(t, err)
+	assert.True(t, valid)
+
+	status, err = mfaService.Status(user.ID)
+	require.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.True(t, status.TOTP)
+	assert.False(t, status.TOTPPending)
+	assert.EqualValues(t, 10, status.RecoveryCodesRemaining)
+
+	login := LoginRequest{Email: "totp@example.com", Password: "SecurePassword123!"}
+	_, err = authService.Login(ctx, login)
+	var required *MFARequiredError
+	require.True(t, errors.As(err, &required))
+	assert.Equal(t, []string{"totp", "recovery_code"}, required.Methods)
+
+	login.MFACode = code
+	_, err = authService.Login(ctx, login)
+	require.NoError(t, err)
+
+	// Recovery codes work once, in any case and with or without the dash
+	login.MFACode = strings.ToUpper(strings.ReplaceAll(setup.BackupCodes[0], "-", ""))
+	_, err = authService.Login(ctx, login)
+	require.NoError(t, err)
+	_, err = authService.Login(ctx, login)
+	assert.Error(t, err)
+
+	// Rotating the secret of an enrolled user keeps the recovery codes
+	again, err := mfaService.SetupTOTP(user.ID, "Test App", user.Username)
+	require.NoError(t, err)
+	assert.Empty(t, again.BackupCodes)
+}
+
 func TestSessionManagement(t *testing.T) {
 	_, db, _ := setupTestServices(t)
 	sessionService := NewSessionService(db)
@@ -128,6 +128,11 @@ func (s *SMTPEmailService) SendMFASetupEmail(to string, backupCodes []string) er
 	return s.sendEmail(to, subject, body)
 }
 
+// SendDigestEmail sends a pre-rendered HTML digest email
+func (s *SMTPEmailService) SendDigestEmail(to, subject, htmlBody string) error {
+	return s.sendEmail(to, subject, htmlBody)
+}
+
 func (s *SMTPEmailService) sendEmail(to, subject, body string) error {
 	// If SMTP is not configured, log the email instead of using mock
 	if s.host == "" {
@@ -276,6 +281,10 @@ func (s *TemplatedEmailService) SendMFASetupEmail(to string, backupCodes []strin
 	return s.smtpService.SendMFASetupEmail(to, backupCodes)
 }
 
+func (s *TemplatedEmailService) SendDigestEmail(to, subject, htmlBody string) error {
+	return s.smtpService.SendDigestEmail(to, subject, htmlBody)
+}
+
 // Email templates
 func getPasswordResetHTMLTemplate() string {
 	return `
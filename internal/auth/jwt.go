@@ -33,6 +33,12 @@ func NewJWTManager(cfg config.JWT) *JWTManager {
 }
 
 func (j *JWTManager) GenerateToken(user *models.User) (string, error) {
+	return j.GenerateTokenWithTTL(user, j.expiration)
+}
+
+// GenerateTokenWithTTL issues a token for user that expires after ttl
+// instead of the configured expiration
+func (j *JWTManager) GenerateTokenWithTTL(user *models.User, ttl time.Duration) (string, error) {
 	claims := &Claims{
 		UserID:   user.ID,
 		Username: user.Username,
@@ -40,7 +46,7 @@ func (j *JWTManager) GenerateToken(user *models.User) (string, error) {
 		Roles:    user.Roles,
 		IsAdmin:  user.IsAdmin,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(j.expiration)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
@@ -1,12 +1,18 @@
 package auth
 
 import (
+	"bytes"
 	"crypto/rand"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"image/png"
+	"strings"
 	"time"
 
+	"github.com/a5c-ai/hub/internal/encryption"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/google/uuid"
 	"github.com/pquerna/otp/totp"
@@ -16,16 +22,21 @@ import (
 type MFAService struct {
 	db           *gorm.DB
 	emailService EmailService
+	rp           RelyingParty
 }
 
 type MFASetupRequest struct {
 	UserID uuid.UUID `json:"user_id"`
 }
 
+// MFASetupResponse carries a new TOTP secret as text and as an otpauth URI,
+// also rendered as a QR code PNG data URI. Recovery codes are included when
+// the user is enrolling for the first time.
 type MFASetupResponse struct {
 	Secret      string   `json:"secret"`
 	QRCodeURL   string   `json:"qr_code_url"`
-	BackupCodes []string `json:"backup_codes"`
+	QRCodePNG   string   `json:"qr_code_png,omitempty"`
+	BackupCodes []string `json:"backup_codes,omitempty"`
 }
 
 type MFAVerifyRequest struct {
@@ -33,6 +44,16 @@ type MFAVerifyRequest struct {
 	Code   string    `json:"code"`
 }
 
+// MFAStatus summarizes the second factors of a user
+type MFAStatus struct {
+	Enabled                bool  `json:"enabled"`
+	TOTP                   bool  `json:"totp"`
+	TOTPPending            bool  `json:"totp_pending"`
+	WebAuthnCredentials    int64 `json:"webauthn_credentials"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// BackupCode is a one-time recovery code; only its hash is stored
 type BackupCode struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -40,7 +61,7 @@ type BackupCode struct {
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
 	UserID uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
-	Code   string     `json:"code" gorm:"not null;size:255"`
+	Code   string     `json:"-" gorm:"not null;size:255"`
 	Used   bool       `json:"used" gorm:"default:false"`
 	UsedAt *time.Time `json:"used_at"`
 
@@ -54,15 +75,17 @@ type WebAuthnCredential struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
-	UserID       uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
-	CredentialID string     `json:"credential_id" gorm:"not null;uniqueIndex;size:255"`
-	PublicKey    []byte     `json:"public_key" gorm:"not null"`
-	Name         string     `json:"name" gorm:"not null;size:255"`
-	SignCount    uint32     `json:"sign_count" gorm:"default:0"`
-	LastUsedAt   *time.Time `json:"last_used_at"`
+	UserID uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	// CredentialID is the base64url encoded credential ID
+	CredentialID string `json:"credential_id" gorm:"not null;uniqueIndex;size:255"`
+	// PublicKey is the COSE encoded credential public key
+	PublicKey  []byte     `json:"-" gorm:"not null"`
+	Name       string     `json:"name" gorm:"not null;size:255"`
+	SignCount  uint32     `json:"sign_count" gorm:"default:0"`
+	LastUsedAt *time.Time `json:"last_used_at"`
 
 	// Relationships
-	User models.User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	User models.User `json:"-" gorm:"foreignKey:UserID"`
 }
 
 type SMSVerificationCode struct {
@@ -97,6 +120,7 @@ func NewMFAService(db *gorm.DB) *MFAService {
 	return &MFAService{
 		db:           db,
 		emailService: nil, // Will be set separately when needed
+		rp:           NewRelyingParty("", ""),
 	}
 }
 
@@ -104,9 +128,19 @@ func NewMFAServiceWithEmail(db *gorm.DB, emailService EmailService) *MFAService
 	return &MFAService{
 		db:           db,
 		emailService: emailService,
+		rp:           NewRelyingParty("", ""),
 	}
 }
 
+// WithRelyingParty sets the relying party WebAuthn ceremonies run for
+func (s *MFAService) WithRelyingParty(rp RelyingParty) *MFAService {
+	s.rp = rp
+	return s
+}
+
+// SetupTOTP provisions a TOTP secret that takes effect once ConfirmTOTP
+// checks a code generated from it. Users enrolling in MFA for the first
+// time also get a fresh set of recovery codes.
 func (s *MFAService) SetupTOTP(userID uuid.UUID, issuer, accountName string) (*MFASetupResponse, error) {
 	// Generate TOTP key using proper library
 	key, err := totp.Generate(totp.GenerateOpts{
@@ -117,74 +151,87 @@ func (s *MFAService) SetupTOTP(userID uuid.UUID, issuer, accountName string) (*M
 		return nil, fmt.Errorf("failed to generate TOTP key: %w", err)
 	}
 
-	// Generate QR code URL from the key
-	qrURL := key.URL()
-
-	// Generate backup codes
-	backupCodes := s.generateBackupCodes()
+	// Map updates bypass the model serializer, so the secret is sealed here
+	sealed, err := encryption.Seal((&models.User{}).TableName(), "two_factor_pending_secret", key.Secret())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt MFA secret: %w", err)
+	}
+	if err := s.db.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_pending_secret", sealed).Error; err != nil {
+		return nil, fmt.Errorf("failed to store MFA secret: %w", err)
+	}
 
-	// Store backup codes in database
-	for _, code := range backupCodes {
-		backupCode := BackupCode{
-			UserID: userID,
-			Code:   code,
-			Used:   false,
-		}
-		if err := s.db.Create(&backupCode).Error; err != nil {
-			return nil, fmt.Errorf("failed to store backup code: %w", err)
+	response := &MFASetupResponse{
+		Secret:    key.Secret(),
+		QRCodeURL: key.URL(),
+	}
+	if image, err := key.Image(200, 200); err == nil {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image); err == nil {
+			response.QRCodePNG = "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes())
 		}
 	}
 
-	// Store the secret temporarily (in production, you might want to encrypt this)
-	// For now, we'll assume the client will store it and send it back for verification
-
-	return &MFASetupResponse{
-		Secret:      key.Secret(),
-		QRCodeURL:   qrURL,
-		BackupCodes: backupCodes,
-	}, nil
-}
-
-func (s *MFAService) VerifyTOTP(userID uuid.UUID, secret, code string) (bool, error) {
-	// Verify TOTP code
-	valid := s.verifyTOTPCode(secret, code)
-	if !valid {
-		// Check if it's a backup code
-		return s.useBackupCode(userID, code)
+	var enabled int64
+	if err := s.db.Model(&models.User{}).Where("id = ? AND two_factor_enabled = ?", userID, true).Count(&enabled).Error; err != nil {
+		return nil, fmt.Errorf("failed to check MFA status: %w", err)
 	}
-
-	// If TOTP is valid, enable MFA for user and store the secret
-	if valid {
-		err := s.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
-			"two_factor_enabled": true,
-			"two_factor_secret":  secret,
-		}).Error
+	if enabled == 0 {
+		codes, err := s.replaceBackupCodes(userID)
 		if err != nil {
-			return false, fmt.Errorf("failed to enable MFA: %w", err)
+			return nil, err
 		}
+		response.BackupCodes = codes
+	}
+	return response, nil
+}
 
-		// Send MFA setup notification email
-		if s.emailService != nil {
-			var user models.User
-			if err := s.db.Where("id = ?", userID).First(&user).Error; err == nil {
-				// Get backup codes for this user
-				var backupCodes []BackupCode
-				s.db.Where("user_id = ? AND used = false", userID).Find(&backupCodes)
-
-				codes := make([]string, len(backupCodes))
-				for i, bc := range backupCodes {
-					codes[i] = bc.Code
-				}
-
-				// Send email notification (don't fail if email fails)
-				if err := s.emailService.SendMFASetupEmail(user.Email, codes); err != nil {
-					fmt.Printf("Failed to send MFA setup email: %v\n", err)
-				}
-			}
-		}
+// ConfirmTOTP enables the pending TOTP secret of the user when code was
+// generated from it
+func (s *MFAService) ConfirmTOTP(userID uuid.UUID, code string) (bool, error) {
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return false, fmt.Errorf("user not found: %w", err)
+	}
+	if user.TwoFactorPendingSecret == "" {
+		return false, errors.New("no TOTP setup is pending")
+	}
+	if !s.verifyTOTPCode(user.TwoFactorPendingSecret, code) {
+		return false, nil
 	}
 
-	return valid, nil
+	sealed, err := encryption.Seal(user.TableName(), "two_factor_secret", user.TwoFactorPendingSecret)
+	if err != nil {
+		return false, fmt.Errorf("failed to encrypt MFA secret: %w", err)
+	}
+	err = s.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"two_factor_enabled":        true,
+		"two_factor_secret":         sealed,
+		"two_factor_pending_secret": "",
+	}).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to enable MFA: %w", err)
+	}
+	return true, nil
+}
+
+// Status reports which second factors the user has set up
+func (s *MFAService) Status(userID uuid.UUID) (*MFAStatus, error) {
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	status := &MFAStatus{
+		Enabled:     user.TwoFactorEnabled,
+		TOTP:        user.TwoFactorSecret != "",
+		TOTPPending: user.TwoFactorPendingSecret != "",
+	}
+	if err := s.db.Model(&WebAuthnCredential{}).Where("user_id = ?", userID).Count(&status.WebAuthnCredentials).Error; err != nil {
+		return nil, fmt.Errorf("failed to count WebAuthn credentials: %w", err)
+	}
+	if err := s.db.Model(&BackupCode{}).Where("user_id = ? AND used = ?", userID, false).Count(&status.RecoveryCodesRemaining).Error; err != nil {
+		return nil, fmt.Errorf("failed to count recovery codes: %w", err)
+	}
+	return status, nil
 }
 
 // VerifyMFACode verifies any type of MFA code for login
@@ -216,42 +263,31 @@ func (s *MFAService) VerifyMFACode(userID uuid.UUID, code string) (bool, error)
 	return s.useBackupCode(userID, code)
 }
 
+// DisableMFA turns MFA off and removes every second factor of the user
 func (s *MFAService) DisableMFA(userID uuid.UUID) error {
-	// Disable MFA for user
-	err := s.db.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled", false).Error
-	if err != nil {
-		return fmt.Errorf("failed to disable MFA: %w", err)
-	}
-
-	// Delete all backup codes
-	err = s.db.Where("user_id = ?", userID).Delete(&BackupCode{}).Error
-	if err != nil {
-		return fmt.Errorf("failed to delete backup codes: %w", err)
-	}
-
-	return nil
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"two_factor_enabled":        false,
+			"two_factor_secret":         "",
+			"two_factor_pending_secret": "",
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to disable MFA: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&BackupCode{}).Error; err != nil {
+			return fmt.Errorf("failed to delete backup codes: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&WebAuthnCredential{}).Error; err != nil {
+			return fmt.Errorf("failed to delete WebAuthn credentials: %w", err)
+		}
+		return nil
+	})
 }
 
 func (s *MFAService) RegenerateBackupCodes(userID uuid.UUID) ([]string, error) {
-	// Delete existing backup codes
-	err := s.db.Where("user_id = ?", userID).Delete(&BackupCode{}).Error
+	backupCodes, err := s.replaceBackupCodes(userID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to delete old backup codes: %w", err)
-	}
-
-	// Generate new backup codes
-	backupCodes := s.generateBackupCodes()
-
-	// Store new backup codes
-	for _, code := range backupCodes {
-		backupCode := BackupCode{
-			UserID: userID,
-			Code:   code,
-			Used:   false,
-		}
-		if err := s.db.Create(&backupCode).Error; err != nil {
-			return nil, fmt.Errorf("failed to store backup code: %w", err)
-		}
+		return nil, err
 	}
 
 	// Send email notification about new backup codes
@@ -268,24 +304,44 @@ func (s *MFAService) RegenerateBackupCodes(userID uuid.UUID) ([]string, error) {
 	return backupCodes, nil
 }
 
-func (s *MFAService) useBackupCode(userID uuid.UUID, code string) (bool, error) {
-	var backupCode BackupCode
-	err := s.db.Where("user_id = ? AND code = ? AND used = false", userID, code).First(&backupCode).Error
+// replaceBackupCodes swaps the user's recovery codes for new ones and
+// returns them; only their hashes are kept
+func (s *MFAService) replaceBackupCodes(userID uuid.UUID) ([]string, error) {
+	backupCodes := s.generateBackupCodes()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&BackupCode{}).Error; err != nil {
+			return fmt.Errorf("failed to delete old backup codes: %w", err)
+		}
+		for _, code := range backupCodes {
+			backupCode := BackupCode{
+				ID:     uuid.New(),
+				UserID: userID,
+				Code:   hashBackupCode(code),
+			}
+			if err := tx.Create(&backupCode).Error; err != nil {
+				return fmt.Errorf("failed to store backup code: %w", err)
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return false, nil // Invalid backup code
+		return nil, err
 	}
+	return backupCodes, nil
+}
 
-	// Mark backup code as used
-	now := time.Now()
-	backupCode.Used = true
-	backupCode.UsedAt = &now
-
-	err = s.db.Save(&backupCode).Error
-	if err != nil {
-		return false, fmt.Errorf("failed to mark backup code as used: %w", err)
+// useBackupCode consumes a recovery code, which works only once
+func (s *MFAService) useBackupCode(userID uuid.UUID, code string) (bool, error) {
+	if normalizeBackupCode(code) == "" {
+		return false, nil
 	}
-
-	return true, nil
+	result := s.db.Model(&BackupCode{}).
+		Where("user_id = ? AND code = ? AND used = ?", userID, hashBackupCode(code), false).
+		Updates(map[string]interface{}{"used": true, "used_at": time.Now()})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to mark backup code as used: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
 }
 
 func (s *MFAService) generateBackupCodes() []string {
@@ -296,15 +352,22 @@ func (s *MFAService) generateBackupCodes() []string {
 	return codes
 }
 
+// generateBackupCode returns a random code such as 3f9a1-c07e2
 func generateBackupCode() string {
-	// Generate 8-digit backup code
-	bytes := make([]byte, 4)
+	bytes := make([]byte, 5)
 	rand.Read(bytes)
-	code := ""
-	for _, b := range bytes {
-		code += fmt.Sprintf("%02d", int(b)%100)
-	}
-	return code
+	code := hex.EncodeToString(bytes)
+	return code[:5] + "-" + code[5:]
+}
+
+// normalizeBackupCode ignores case, dashes and spaces in recovery codes
+func normalizeBackupCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
+
+func hashBackupCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeBackupCode(code)))
+	return hex.EncodeToString(sum[:])
 }
 
 // TOTP implementation using proper library
@@ -417,96 +480,38 @@ func (s *MFAService) verifySMSCode(userID uuid.UUID, code string) bool {
 	return true
 }
 
-// WebAuthn methods (basic implementation)
-type WebAuthnRegistrationRequest struct {
-	UserID uuid.UUID `json:"user_id"`
-	Name   string    `json:"name"`
-}
-
-type WebAuthnRegistrationResponse struct {
-	Options string `json:"options"` // JSON string of WebAuthn creation options
-}
-
-type WebAuthnLoginRequest struct {
-	UserID uuid.UUID `json:"user_id"`
-}
-
-type WebAuthnLoginResponse struct {
-	Options string `json:"options"` // JSON string of WebAuthn assertion options
-}
-
-func (s *MFAService) InitiateWebAuthnRegistration(userID uuid.UUID, credentialName string) (*WebAuthnRegistrationResponse, error) {
-	// This is a simplified implementation
-	// In production, you would use a proper WebAuthn library like github.com/go-webauthn/webauthn
-
-	// For now, return mock options
-	options := map[string]interface{}{
-		"challenge": generateMFASecureToken(),
-		"rp": map[string]interface{}{
-			"name": "A5C Hub",
-			"id":   "localhost",
-		},
-		"user": map[string]interface{}{
-			"id":          userID.String(),
-			"name":        credentialName,
-			"displayName": credentialName,
-		},
-		"pubKeyCredParams": []map[string]interface{}{
-			{"alg": -7, "type": "public-key"},
-			{"alg": -257, "type": "public-key"},
-		},
-		"authenticatorSelection": map[string]interface{}{
-			"authenticatorAttachment": "platform",
-			"userVerification":        "required",
-		},
-		"timeout": 60000,
-	}
-
-	optionsJSON, err := json.Marshal(options)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal options: %w", err)
-	}
-
-	return &WebAuthnRegistrationResponse{
-		Options: string(optionsJSON),
-	}, nil
-}
-
-func (s *MFAService) CompleteWebAuthnRegistration(userID uuid.UUID, credentialName string, publicKeyBytes []byte, credentialID string) error {
-	// Store the WebAuthn credential
-	credential := WebAuthnCredential{
-		UserID:       userID,
-		CredentialID: credentialID,
-		PublicKey:    publicKeyBytes,
-		Name:         credentialName,
-		SignCount:    0,
-	}
-
-	if err := s.db.Create(&credential).Error; err != nil {
-		return fmt.Errorf("failed to store WebAuthn credential: %w", err)
-	}
-
-	// Enable MFA for user if not already enabled
-	err := s.db.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled", true).Error
-	if err != nil {
-		return fmt.Errorf("failed to enable MFA: %w", err)
-	}
-
-	return nil
-}
-
 func (s *MFAService) GetWebAuthnCredentials(userID uuid.UUID) ([]WebAuthnCredential, error) {
 	var credentials []WebAuthnCredential
 	err := s.db.Where("user_id = ?", userID).Find(&credentials).Error
 	return credentials, err
 }
 
+// DeleteWebAuthnCredential removes a credential of the user. MFA is turned
+// off when it was the last second factor.
 func (s *MFAService) DeleteWebAuthnCredential(userID uuid.UUID, credentialID string) error {
-	return s.db.Where("user_id = ? AND credential_id = ?", userID, credentialID).Delete(&WebAuthnCredential{}).Error
-}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND credential_id = ?", userID, credentialID).Delete(&WebAuthnCredential{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete WebAuthn credential: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
 
-func generateMFASecureToken() string {
-	bytes := make([]byte, 32)
-	rand.Read(bytes)
-	return fmt.Sprintf("%x", bytes)
+		var user models.User
+		if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+			return fmt.Errorf("user not found: %w", err)
+		}
+		var remaining int64
+		if err := tx.Model(&WebAuthnCredential{}).Where("user_id = ?", userID).Count(&remaining).Error; err != nil {
+			return fmt.Errorf("failed to count WebAuthn credentials: %w", err)
+		}
+		if remaining > 0 || user.TwoFactorSecret != "" {
+			return nil
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled", false).Error; err != nil {
+			return fmt.Errorf("failed to disable MFA: %w", err)
+		}
+		return tx.Where("user_id = ?", userID).Delete(&BackupCode{}).Error
+	})
 }
@@ -299,7 +299,7 @@ func (s *OIDCService) findOrCreateOIDCUser(claims *OIDCClaims) (*models.User, er
 	}
 
 	// Ensure username is unique
-	username = s.ensureUniqueUsername(username)
+	username = ensureUniqueUsername(s.db, username)
 
 	user = models.User{
 		ID:            uuid.New(),
@@ -322,13 +322,15 @@ func (s *OIDCService) findOrCreateOIDCUser(claims *OIDCClaims) (*models.User, er
 	return &user, nil
 }
 
-func (s *OIDCService) ensureUniqueUsername(baseUsername string) string {
+// ensureUniqueUsername returns baseUsername, or a numbered variant of it
+// when the name is taken, for accounts provisioned by single sign-on
+func ensureUniqueUsername(db *gorm.DB, baseUsername string) string {
 	username := baseUsername
 	counter := 1
 
 	for {
 		var existingUser models.User
-		if err := s.db.Where("username = ?", username).First(&existingUser).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+		if err := db.Where("username = ?", username).First(&existingUser).Error; errors.Is(err, gorm.ErrRecordNotFound) {
 			// Username is available
 			return username
 		}
@@ -146,6 +146,7 @@ type EmailService interface {
 	SendPasswordResetEmail(to, token string) error
 	SendEmailVerification(to, token string) error
 	SendMFASetupEmail(to string, backupCodes []string) error
+	SendDigestEmail(to, subject, htmlBody string) error
 }
 
 // Mock email service for development
@@ -167,3 +168,8 @@ func (s *MockEmailService) SendMFASetupEmail(to string, backupCodes []string) er
 	fmt.Printf("MFA Setup Email to %s:\nBackup codes: %v\n", to, backupCodes)
 	return nil
 }
+
+func (s *MockEmailService) SendDigestEmail(to, subject, htmlBody string) error {
+	fmt.Printf("Digest Email to %s:\nSubject: %s\n", to, subject)
+	return nil
+}
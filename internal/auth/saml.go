@@ -314,7 +314,7 @@ func (s *SAMLService) findOrCreateSAMLUser(userInfo *SAMLUserInfo) (*models.User
 	}
 
 	// Ensure username is unique
-	username = s.ensureUniqueUsername(username)
+	username = ensureUniqueUsername(s.db, username)
 
 	user = models.User{
 		ID:            uuid.New(),
@@ -346,28 +346,6 @@ func (s *SAMLService) findOrCreateSAMLUser(userInfo *SAMLUserInfo) (*models.User
 	return &user, nil
 }
 
-func (s *SAMLService) ensureUniqueUsername(baseUsername string) string {
-	username := baseUsername
-	counter := 1
-
-	for {
-		var existingUser models.User
-		if err := s.db.Where("username = ?", username).First(&existingUser).Error; errors.Is(err, gorm.ErrRecordNotFound) {
-			// Username is available
-			return username
-		}
-
-		// Try with counter
-		username = fmt.Sprintf("%s%d", baseUsername, counter)
-		counter++
-
-		if counter > 1000 {
-			// Fallback to UUID
-			return fmt.Sprintf("%s_%s", baseUsername, uuid.New().String()[:8])
-		}
-	}
-}
-
 func generateSAMLID() string {
 	return fmt.Sprintf("_%s", uuid.New().String())
 }
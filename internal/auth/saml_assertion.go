@@ -0,0 +1,302 @@
+package auth
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	samlProtocolNamespace  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlAssertionNamespace = "urn:oasis:names:tc:SAML:2.0:assertion"
+	samlStatusSuccess      = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	samlBearerMethod       = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+	// samlClockSkew is the clock difference tolerated when checking
+	// assertion validity windows
+	samlClockSkew = 3 * time.Minute
+)
+
+// SAMLValidation describes what a SAML response must satisfy to be
+// accepted by a service provider
+type SAMLValidation struct {
+	IdPEntityID  string
+	Certificates []*x509.Certificate
+	Audience     string // service provider entity ID
+	Recipient    string // assertion consumer service URL
+	RequestID    string // ID of the AuthnRequest the response answers
+	Now          time.Time
+}
+
+type samlAssertion struct {
+	Issuer  string `xml:"urn:oasis:names:tc:SAML:2.0:assertion Issuer"`
+	Subject struct {
+		NameID        string `xml:"urn:oasis:names:tc:SAML:2.0:assertion NameID"`
+		Confirmations []struct {
+			Method string `xml:"Method,attr"`
+			Data   struct {
+				NotOnOrAfter string `xml:"NotOnOrAfter,attr"`
+				Recipient    string `xml:"Recipient,attr"`
+				InResponseTo string `xml:"InResponseTo,attr"`
+			} `xml:"urn:oasis:names:tc:SAML:2.0:assertion SubjectConfirmationData"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:assertion SubjectConfirmation"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:assertion Subject"`
+	Conditions struct {
+		NotBefore    string `xml:"NotBefore,attr"`
+		NotOnOrAfter string `xml:"NotOnOrAfter,attr"`
+		Restrictions []struct {
+			Audiences []string `xml:"urn:oasis:names:tc:SAML:2.0:assertion Audience"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:assertion AudienceRestriction"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:assertion Conditions"`
+	Statements []struct {
+		Attributes []struct {
+			Name   string   `xml:"Name,attr"`
+			Values []string `xml:"urn:oasis:names:tc:SAML:2.0:assertion AttributeValue"`
+		} `xml:"urn:oasis:names:tc:SAML:2.0:assertion Attribute"`
+	} `xml:"urn:oasis:names:tc:SAML:2.0:assertion AttributeStatement"`
+}
+
+// ParseSAMLCertificates parses the PEM encoded identity provider signing
+// certificates
+func ParseSAMLCertificates(data string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(data)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return certs, nil
+}
+
+// VerifySAMLResponse verifies a base64 encoded SAML response posted to an
+// assertion consumer service and returns the user it asserts. Either the
+// response or its single assertion must be signed by the identity
+// provider; everything read from the assertion comes from the signed
+// element, so signature wrapping cannot substitute unsigned content.
+func VerifySAMLResponse(encoded string, v SAMLValidation) (*SAMLUserInfo, map[string][]string, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(encoded), ""))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: malformed encoding", ErrInvalidSAMLResponse)
+	}
+	root, err := parseXMLDocument(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidSAMLResponse, err)
+	}
+	if root.space != samlProtocolNamespace || root.local != "Response" {
+		return nil, nil, fmt.Errorf("%w: not a SAML response", ErrInvalidSAMLResponse)
+	}
+	if err := checkUniqueIDs(root, map[string]bool{}); err != nil {
+		return nil, nil, err
+	}
+
+	if status := root.child(samlProtocolNamespace, "Status"); status == nil ||
+		status.child(samlProtocolNamespace, "StatusCode") == nil ||
+		status.child(samlProtocolNamespace, "StatusCode").attr("Value") != samlStatusSuccess {
+		return nil, nil, fmt.Errorf("%w: identity provider did not authenticate the user", ErrInvalidSAMLResponse)
+	}
+	if root.child(samlAssertionNamespace, "EncryptedAssertion") != nil {
+		return nil, nil, fmt.Errorf("%w: encrypted assertions are not supported", ErrInvalidSAMLResponse)
+	}
+	assertions := root.childElements(samlAssertionNamespace, "Assertion")
+	if len(assertions) != 1 {
+		return nil, nil, fmt.Errorf("%w: expected exactly one assertion", ErrInvalidSAMLResponse)
+	}
+	assertionElement := assertions[0]
+
+	signed := assertionElement
+	if root.child(xmldsigNamespace, "Signature") != nil {
+		signed = root
+	}
+	if err := verifyEnvelopedSignature(signed, v.Certificates); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrSAMLSignatureInvalid, err)
+	}
+	if signed == root {
+		if inResponseTo := root.attr("InResponseTo"); inResponseTo != "" && inResponseTo != v.RequestID {
+			return nil, nil, fmt.Errorf("%w: response does not answer this login", ErrInvalidSAMLResponse)
+		}
+		if destination := root.attr("Destination"); destination != "" && destination != v.Recipient {
+			return nil, nil, fmt.Errorf("%w: response was sent to another service", ErrInvalidSAMLResponse)
+		}
+	}
+
+	var assertion samlAssertion
+	canonical := canonicalize(assertionElement, assertionElement.child(xmldsigNamespace, "Signature"), nil)
+	if err := xml.Unmarshal(canonical, &assertion); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidSAMLResponse, err)
+	}
+	if err := validateSAMLAssertion(&assertion, v); err != nil {
+		return nil, nil, err
+	}
+
+	attributes := map[string][]string{}
+	for _, statement := range assertion.Statements {
+		for _, attr := range statement.Attributes {
+			for _, value := range attr.Values {
+				attributes[attr.Name] = append(attributes[attr.Name], strings.TrimSpace(value))
+			}
+		}
+	}
+	info := &SAMLUserInfo{ID: strings.TrimSpace(assertion.Subject.NameID)}
+	info.Email = firstSAMLAttribute(attributes, "email", "mail", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress")
+	info.Username = firstSAMLAttribute(attributes, "username", "uid", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name")
+	info.Name = firstSAMLAttribute(attributes, "name", "displayName", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname")
+	if info.Email == "" && strings.Contains(info.ID, "@") {
+		info.Email = info.ID
+	}
+	return info, attributes, nil
+}
+
+func validateSAMLAssertion(assertion *samlAssertion, v SAMLValidation) error {
+	now := v.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if v.IdPEntityID != "" && strings.TrimSpace(assertion.Issuer) != v.IdPEntityID {
+		return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidSAMLResponse, assertion.Issuer)
+	}
+	if strings.TrimSpace(assertion.Subject.NameID) == "" {
+		return fmt.Errorf("%w: assertion has no subject", ErrInvalidSAMLResponse)
+	}
+
+	if notBefore, err := parseSAMLTime(assertion.Conditions.NotBefore); err != nil {
+		return err
+	} else if !notBefore.IsZero() && now.Add(samlClockSkew).Before(notBefore) {
+		return fmt.Errorf("%w: assertion is not yet valid", ErrInvalidSAMLResponse)
+	}
+	if notOnOrAfter, err := parseSAMLTime(assertion.Conditions.NotOnOrAfter); err != nil {
+		return err
+	} else if !notOnOrAfter.IsZero() && !now.Add(-samlClockSkew).Before(notOnOrAfter) {
+		return fmt.Errorf("%w: assertion has expired", ErrInvalidSAMLResponse)
+	}
+	audienceMatched := false
+	for _, restriction := range assertion.Conditions.Restrictions {
+		for _, audience := range restriction.Audiences {
+			if strings.TrimSpace(audience) == v.Audience {
+				audienceMatched = true
+			}
+		}
+	}
+	if !audienceMatched {
+		return fmt.Errorf("%w: assertion is not intended for this service", ErrInvalidSAMLResponse)
+	}
+
+	for _, confirmation := range assertion.Subject.Confirmations {
+		if confirmation.Method != samlBearerMethod {
+			continue
+		}
+		data := confirmation.Data
+		notOnOrAfter, err := parseSAMLTime(data.NotOnOrAfter)
+		if err != nil {
+			return err
+		}
+		if data.Recipient == v.Recipient && data.InResponseTo == v.RequestID &&
+			!notOnOrAfter.IsZero() && now.Add(-samlClockSkew).Before(notOnOrAfter) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no valid bearer subject confirmation", ErrInvalidSAMLResponse)
+}
+
+func parseSAMLTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: malformed timestamp %q", ErrInvalidSAMLResponse, value)
+	}
+	return t, nil
+}
+
+// checkUniqueIDs rejects documents in which two elements share an ID, which
+// would make signature references ambiguous
+func checkUniqueIDs(e *xmlElement, seen map[string]bool) error {
+	if id := e.attr("ID"); id != "" {
+		if seen[id] {
+			return fmt.Errorf("%w: duplicate ID %q", ErrInvalidSAMLResponse, id)
+		}
+		seen[id] = true
+	}
+	for _, node := range e.children {
+		if node.element != nil {
+			if err := checkUniqueIDs(node.element, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func firstSAMLAttribute(attributes map[string][]string, names ...string) string {
+	for _, name := range names {
+		if values := attributes[name]; len(values) > 0 && values[0] != "" {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// SAMLRedirectURL builds an HTTP-Redirect binding URL that sends an
+// AuthnRequest with the given ID to the identity provider
+func SAMLRedirectURL(ssoURL, requestID, spEntityID, acsURL, relayState string) (string, error) {
+	var request bytes.Buffer
+	request.WriteString(`<samlp:AuthnRequest xmlns:samlp="` + samlProtocolNamespace + `" xmlns:saml="` + samlAssertionNamespace + `"`)
+	for _, attr := range [][2]string{
+		{"ID", requestID},
+		{"Version", "2.0"},
+		{"IssueInstant", time.Now().UTC().Format(time.RFC3339)},
+		{"Destination", ssoURL},
+		{"AssertionConsumerServiceURL", acsURL},
+		{"ProtocolBinding", "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"},
+	} {
+		request.WriteString(" " + attr[0] + `="`)
+		xml.EscapeText(&request, []byte(attr[1]))
+		request.WriteString(`"`)
+	}
+	request.WriteString("><saml:Issuer>")
+	xml.EscapeText(&request, []byte(spEntityID))
+	request.WriteString("</saml:Issuer></samlp:AuthnRequest>")
+
+	var deflated bytes.Buffer
+	writer, err := flate.NewWriter(&deflated, flate.DefaultCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := writer.Write(request.Bytes()); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	target, err := url.Parse(ssoURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid SSO URL: %w", err)
+	}
+	query := target.Query()
+	query.Set("SAMLRequest", base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	if relayState != "" {
+		query.Set("RelayState", relayState)
+	}
+	target.RawQuery = query.Encode()
+	return target.String(), nil
+}
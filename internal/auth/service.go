@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/a5c-ai/hub/internal/audit"
 	"github.com/a5c-ai/hub/internal/config"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/google/uuid"
@@ -27,6 +28,30 @@ type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required,min=6"`
 	MFACode  string `json:"mfa_code,omitempty"`
+	// WebAuthn answers the security key challenge of an earlier attempt
+	// in place of MFACode
+	WebAuthn *WebAuthnAssertion `json:"webauthn,omitempty"`
+}
+
+// WebAuthnAssertion is a signed WebAuthn login challenge
+type WebAuthnAssertion struct {
+	SessionID  uuid.UUID                  `json:"session_id" binding:"required"`
+	Credential WebAuthnCredentialResponse `json:"credential" binding:"required"`
+}
+
+// PasskeyLoginRequest signs in with a passkey alone
+type PasskeyLoginRequest = WebAuthnAssertion
+
+// MFARequiredError is returned by Login when the password was right but a
+// second factor is still needed. WebAuthn holds a challenge for the user's
+// security keys, if they registered any.
+type MFARequiredError struct {
+	Methods  []string
+	WebAuthn *WebAuthnOptions
+}
+
+func (e *MFARequiredError) Error() string {
+	return "MFA code required"
 }
 
 type RegisterRequest struct {
@@ -54,6 +79,9 @@ type PasswordResetConfirmRequest struct {
 
 type AuthService interface {
 	Login(ctx context.Context, req LoginRequest) (*AuthResponse, error)
+	// BeginPasskeyLogin issues a challenge any passkey can sign
+	BeginPasskeyLogin(ctx context.Context) (*WebAuthnOptions, error)
+	LoginWithPasskey(ctx context.Context, req PasskeyLoginRequest) (*AuthResponse, error)
 	Register(ctx context.Context, req RegisterRequest) (*models.User, error)
 	RefreshToken(ctx context.Context, refreshToken string) (*AuthResponse, error)
 	Logout(ctx context.Context, userID uuid.UUID) error
@@ -76,6 +104,7 @@ type authService struct {
 	config           *config.Config
 	sessionService   *SessionService
 	blacklistService *TokenBlacklistService
+	mfaService       *MFAService
 }
 
 func NewAuthService(db *gorm.DB, jwtManager *JWTManager, cfg *config.Config) AuthService {
@@ -88,14 +117,16 @@ func NewAuthService(db *gorm.DB, jwtManager *JWTManager, cfg *config.Config) Aut
 		config:           cfg,
 		sessionService:   sessionService,
 		blacklistService: blacklistService,
+		mfaService: NewMFAServiceWithEmail(db, NewSMTPEmailService(cfg)).
+			WithRelyingParty(NewRelyingParty(cfg.Application.BaseURL, cfg.Application.Name)),
 	}
 }
 
-func (s *authService) Login(ctx context.Context, req LoginRequest) (*AuthResponse, error) {
+func (s *authService) Login(ctx context.Context, req LoginRequest) (resp *AuthResponse, err error) {
 	var user models.User
+	defer func() { recordLogin(ctx, &user, req.Email, "password", err) }()
 
 	// Support login with either email or username
-	var err error
 	if req.Email != "" {
 		err = s.db.Where("email = ?", req.Email).First(&user).Error
 	} else {
@@ -121,24 +152,98 @@ func (s *authService) Login(ctx context.Context, req LoginRequest) (*AuthRespons
 
 	// Check MFA if enabled
 	if user.TwoFactorEnabled {
-		if req.MFACode == "" {
-			return nil, errors.New("MFA code required")
+		if err := s.verifySecondFactor(&user, req); err != nil {
+			return nil, err
 		}
+	}
+
+	return s.issueTokens(&user)
+}
 
-		// Verify MFA code using MFA service
-		emailService := NewSMTPEmailService(s.config)
-		mfaService := NewMFAServiceWithEmail(s.db, emailService)
-		valid, err := mfaService.VerifyMFACode(user.ID, req.MFACode)
+// verifySecondFactor checks the MFA code or security key assertion sent
+// with a login
+func (s *authService) verifySecondFactor(user *models.User, req LoginRequest) error {
+	if req.WebAuthn != nil {
+		keyUser, err := s.mfaService.FinishWebAuthnLogin(req.WebAuthn.SessionID, &req.WebAuthn.Credential)
 		if err != nil {
-			return nil, fmt.Errorf("MFA verification failed: %w", err)
+			return fmt.Errorf("MFA verification failed: %w", err)
 		}
-		if !valid {
-			return nil, errors.New("invalid MFA code")
+		if keyUser.ID != user.ID {
+			return errors.New("invalid MFA code")
 		}
+		return nil
 	}
 
-	// Generate tokens
-	accessToken, err := s.jwtManager.GenerateToken(&user)
+	if req.MFACode == "" {
+		required := &MFARequiredError{Methods: []string{"totp", "recovery_code"}}
+		options, err := s.mfaService.BeginWebAuthnLogin(&user.ID)
+		if err == nil {
+			required.Methods = append(required.Methods, "webauthn")
+			required.WebAuthn = options
+		} else if !errors.Is(err, ErrInvalidWebAuthnResponse) {
+			return fmt.Errorf("failed to start WebAuthn login: %w", err)
+		}
+		return required
+	}
+
+	valid, err := s.mfaService.VerifyMFACode(user.ID, req.MFACode)
+	if err != nil {
+		return fmt.Errorf("MFA verification failed: %w", err)
+	}
+	if !valid {
+		return errors.New("invalid MFA code")
+	}
+	return nil
+}
+
+func (s *authService) BeginPasskeyLogin(ctx context.Context) (*WebAuthnOptions, error) {
+	return s.mfaService.BeginWebAuthnLogin(nil)
+}
+
+// LoginWithPasskey signs in the owner of a passkey; the passkey stands in
+// for both the password and the second factor
+func (s *authService) LoginWithPasskey(ctx context.Context, req PasskeyLoginRequest) (resp *AuthResponse, err error) {
+	user, err := s.mfaService.FinishWebAuthnLogin(req.SessionID, &req.Credential)
+	defer func() { recordLogin(ctx, user, "", "passkey", err) }()
+	if err != nil {
+		return nil, err
+	}
+	if !user.IsActive {
+		return nil, ErrAccountLocked
+	}
+	return s.issueTokens(user)
+}
+
+// recordLogin adds a sign-in attempt to the audit trail. A login waiting
+// for its second factor is recorded once the factor is sent.
+func recordLogin(ctx context.Context, user *models.User, login, method string, err error) {
+	var required *MFARequiredError
+	if errors.As(err, &required) {
+		return
+	}
+	event := audit.Event{
+		Action:     audit.ActionLogin,
+		TargetType: "user",
+		TargetName: login,
+		Metadata:   map[string]interface{}{"method": method},
+	}
+	if user != nil && user.ID != uuid.Nil {
+		userID := user.ID
+		event.ActorID = &userID
+		event.ActorName = user.Username
+		event.TargetID = userID.String()
+		event.TargetName = user.Username
+	}
+	if err != nil {
+		event.Action = audit.ActionLoginFailed
+		event.Metadata["reason"] = err.Error()
+	}
+	audit.Record(ctx, event)
+}
+
+// issueTokens starts a session for an authenticated user
+func (s *authService) issueTokens(user *models.User) (*AuthResponse, error) {
+	accessToken, err := s.jwtManager.GenerateToken(user)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate access token: %w", err)
 	}
@@ -152,13 +257,13 @@ func (s *authService) Login(ctx context.Context, req LoginRequest) (*AuthRespons
 	// Update last login time
 	now := time.Now()
 	user.LastLoginAt = &now
-	s.db.Save(&user)
+	s.db.Save(user)
 
 	// Remove sensitive information before returning
 	user.PasswordHash = ""
 
 	return &AuthResponse{
-		User:         &user,
+		User:         user,
 		AccessToken:  accessToken,
 		RefreshToken: session.RefreshToken,
 		ExpiresIn:    int64(time.Duration(s.config.JWT.ExpirationHour) * time.Hour / time.Second),
@@ -0,0 +1,549 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// WebAuthn ceremonies a session challenge is issued for
+const (
+	WebAuthnPurposeRegistration = "registration"
+	WebAuthnPurposeLogin        = "login"
+)
+
+// webAuthnSessionTTL is how long a ceremony challenge can be answered
+const webAuthnSessionTTL = 5 * time.Minute
+
+// COSE algorithms accepted for WebAuthn credentials
+const (
+	coseAlgES256 = -7
+	coseAlgEdDSA = -8
+	coseAlgRS256 = -257
+)
+
+// Authenticator data flags
+const (
+	authDataUserPresent  = 0x01
+	authDataUserVerified = 0x04
+	authDataAttested     = 0x40
+	authDataExtensions   = 0x80
+)
+
+var (
+	ErrWebAuthnSessionInvalid  = errors.New("webauthn challenge is invalid or expired")
+	ErrInvalidWebAuthnResponse = errors.New("invalid webauthn response")
+	ErrWebAuthnCredentialInUse = errors.New("webauthn credential is already registered")
+)
+
+// RelyingParty identifies this instance to WebAuthn authenticators
+type RelyingParty struct {
+	// ID is the domain credentials are scoped to
+	ID   string
+	Name string
+	// Origin is the only origin ceremonies are accepted from
+	Origin string
+}
+
+// NewRelyingParty derives the relying party from the public base URL of the
+// instance, falling back to localhost when it cannot be parsed
+func NewRelyingParty(baseURL, name string) RelyingParty {
+	if name == "" {
+		name = "A5C Hub"
+	}
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Hostname() == "" {
+		return RelyingParty{ID: "localhost", Name: name, Origin: "http://localhost:3000"}
+	}
+	return RelyingParty{ID: u.Hostname(), Name: name, Origin: u.Scheme + "://" + u.Host}
+}
+
+// WebAuthnSession holds the challenge of a WebAuthn ceremony until it is
+// answered once
+type WebAuthnSession struct {
+	ID        uuid.UUID `gorm:"type:uuid;primaryKey"`
+	CreatedAt time.Time
+
+	// UserID is empty for passkey logins, where the credential names the user
+	UserID    *uuid.UUID `gorm:"type:uuid;index"`
+	Purpose   string     `gorm:"size:20;not null"`
+	Challenge string     `gorm:"size:100;not null"`
+	// Name labels the credential being registered
+	Name      string    `gorm:"size:255"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+}
+
+func (s *WebAuthnSession) TableName() string {
+	return "webauthn_sessions"
+}
+
+// WebAuthnOptions are passed to navigator.credentials.create or get as
+// publicKey; the response is sent back with SessionID
+type WebAuthnOptions struct {
+	SessionID uuid.UUID              `json:"session_id"`
+	PublicKey map[string]interface{} `json:"publicKey"`
+}
+
+// WebAuthnCredentialResponse is a PublicKeyCredential serialized with
+// base64url encoded binary fields, as PublicKeyCredential.toJSON returns it
+type WebAuthnCredentialResponse struct {
+	ID       string `json:"id" binding:"required"`
+	RawID    string `json:"rawId"`
+	Type     string `json:"type"`
+	Response struct {
+		ClientDataJSON string `json:"clientDataJSON"`
+		// AttestationObject is set by registrations
+		AttestationObject string `json:"attestationObject,omitempty"`
+		// AuthenticatorData, Signature and UserHandle are set by logins
+		AuthenticatorData string `json:"authenticatorData,omitempty"`
+		Signature         string `json:"signature,omitempty"`
+		UserHandle        string `json:"userHandle,omitempty"`
+	} `json:"response"`
+}
+
+// BeginWebAuthnRegistration issues the options to create a credential
+// labeled name for the user
+func (s *MFAService) BeginWebAuthnRegistration(userID uuid.UUID, name string) (*WebAuthnOptions, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 255 {
+		return nil, fmt.Errorf("%w: name must be between 1 and 255 characters", ErrInvalidWebAuthnResponse)
+	}
+	var user models.User
+	if err := s.db.Where("id = ?", userID).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	existing, err := s.GetWebAuthnCredentials(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list WebAuthn credentials: %w", err)
+	}
+
+	session, err := s.createWebAuthnSession(&userID, WebAuthnPurposeRegistration, name)
+	if err != nil {
+		return nil, err
+	}
+	displayName := user.FullName
+	if displayName == "" {
+		displayName = user.Username
+	}
+	return &WebAuthnOptions{
+		SessionID: session.ID,
+		PublicKey: map[string]interface{}{
+			"challenge": session.Challenge,
+			"rp":        map[string]interface{}{"id": s.rp.ID, "name": s.rp.Name},
+			"user": map[string]interface{}{
+				"id":          base64.RawURLEncoding.EncodeToString(userID[:]),
+				"name":        user.Username,
+				"displayName": displayName,
+			},
+			"pubKeyCredParams": []map[string]interface{}{
+				{"type": "public-key", "alg": coseAlgES256},
+				{"type": "public-key", "alg": coseAlgEdDSA},
+				{"type": "public-key", "alg": coseAlgRS256},
+			},
+			"excludeCredentials": credentialDescriptors(existing),
+			"authenticatorSelection": map[string]interface{}{
+				"residentKey":      "preferred",
+				"userVerification": "preferred",
+			},
+			"attestation": "none",
+			"timeout":     webAuthnSessionTTL.Milliseconds(),
+		},
+	}, nil
+}
+
+// FinishWebAuthnRegistration verifies the authenticator's answer to a
+// registration challenge and stores the new credential. A registered
+// credential turns two-factor authentication on. Attestation statements
+// are not verified, as the options ask for none.
+func (s *MFAService) FinishWebAuthnRegistration(userID, sessionID uuid.UUID, response *WebAuthnCredentialResponse) (*WebAuthnCredential, error) {
+	session, err := s.takeWebAuthnSession(sessionID, WebAuthnPurposeRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID == nil || *session.UserID != userID {
+		return nil, ErrWebAuthnSessionInvalid
+	}
+	if err := s.verifyClientData(response.Response.ClientDataJSON, "webauthn.create", session.Challenge); err != nil {
+		return nil, err
+	}
+
+	rawAttestation, err := decodeBase64URL(response.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidWebAuthnResponse)
+	}
+	decoded, _, err := cborDecode(rawAttestation)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebAuthnResponse, err)
+	}
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidWebAuthnResponse)
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation object has no authenticator data", ErrInvalidWebAuthnResponse)
+	}
+	authData, err := s.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.credentialID == nil {
+		return nil, fmt.Errorf("%w: no credential was attested", ErrInvalidWebAuthnResponse)
+	}
+	if id, err := decodeBase64URL(response.ID); err != nil || !bytes.Equal(id, authData.credentialID) {
+		return nil, fmt.Errorf("%w: credential ID does not match the authenticator data", ErrInvalidWebAuthnResponse)
+	}
+	if _, err := parseCOSEKey(authData.publicKey); err != nil {
+		return nil, err
+	}
+
+	credential := &WebAuthnCredential{
+		ID:           uuid.New(),
+		UserID:       userID,
+		CredentialID: base64.RawURLEncoding.EncodeToString(authData.credentialID),
+		PublicKey:    authData.publicKey,
+		Name:         session.Name,
+		SignCount:    authData.signCount,
+	}
+	var taken int64
+	if err := s.db.Model(&WebAuthnCredential{}).Where("credential_id = ?", credential.CredentialID).Count(&taken).Error; err != nil {
+		return nil, fmt.Errorf("failed to check WebAuthn credential: %w", err)
+	}
+	if taken > 0 {
+		return nil, ErrWebAuthnCredentialInUse
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(credential).Error; err != nil {
+			return fmt.Errorf("failed to store WebAuthn credential: %w", err)
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("two_factor_enabled", true).Error; err != nil {
+			return fmt.Errorf("failed to enable MFA: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return credential, nil
+}
+
+// BeginWebAuthnLogin issues the options to sign in with a credential of
+// userID, or with any passkey when userID is nil
+func (s *MFAService) BeginWebAuthnLogin(userID *uuid.UUID) (*WebAuthnOptions, error) {
+	publicKey := map[string]interface{}{
+		"rpId":             s.rp.ID,
+		"userVerification": "required",
+		"timeout":          webAuthnSessionTTL.Milliseconds(),
+	}
+	if userID != nil {
+		credentials, err := s.GetWebAuthnCredentials(*userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list WebAuthn credentials: %w", err)
+		}
+		if len(credentials) == 0 {
+			return nil, fmt.Errorf("%w: no WebAuthn credentials are registered", ErrInvalidWebAuthnResponse)
+		}
+		// The password was checked already, so presence is enough
+		publicKey["userVerification"] = "preferred"
+		publicKey["allowCredentials"] = credentialDescriptors(credentials)
+	}
+
+	session, err := s.createWebAuthnSession(userID, WebAuthnPurposeLogin, "")
+	if err != nil {
+		return nil, err
+	}
+	publicKey["challenge"] = session.Challenge
+	return &WebAuthnOptions{SessionID: session.ID, PublicKey: publicKey}, nil
+}
+
+// FinishWebAuthnLogin verifies a signed login challenge and returns the
+// credential's user. Passkey logins, which stand in for both the password
+// and the second factor, must have verified the user.
+func (s *MFAService) FinishWebAuthnLogin(sessionID uuid.UUID, response *WebAuthnCredentialResponse) (*models.User, error) {
+	session, err := s.takeWebAuthnSession(sessionID, WebAuthnPurposeLogin)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifyClientData(response.Response.ClientDataJSON, "webauthn.get", session.Challenge); err != nil {
+		return nil, err
+	}
+
+	rawID, err := decodeBase64URL(response.ID)
+	if err != nil || len(rawID) == 0 {
+		return nil, fmt.Errorf("%w: malformed credential ID", ErrInvalidWebAuthnResponse)
+	}
+	var credential WebAuthnCredential
+	err = s.db.Where("credential_id = ?", base64.RawURLEncoding.EncodeToString(rawID)).First(&credential).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: unknown credential", ErrInvalidWebAuthnResponse)
+		}
+		return nil, fmt.Errorf("failed to get WebAuthn credential: %w", err)
+	}
+	if session.UserID != nil && *session.UserID != credential.UserID {
+		return nil, fmt.Errorf("%w: credential belongs to another user", ErrInvalidWebAuthnResponse)
+	}
+	if session.UserID == nil && response.Response.UserHandle == "" {
+		return nil, fmt.Errorf("%w: passkey logins must return the user handle", ErrInvalidWebAuthnResponse)
+	}
+	if response.Response.UserHandle != "" {
+		handle, err := decodeBase64URL(response.Response.UserHandle)
+		if err != nil || !bytes.Equal(handle, credential.UserID[:]) {
+			return nil, fmt.Errorf("%w: user handle does not match the credential", ErrInvalidWebAuthnResponse)
+		}
+	}
+
+	rawAuthData, err := decodeBase64URL(response.Response.AuthenticatorData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed authenticator data", ErrInvalidWebAuthnResponse)
+	}
+	authData, err := s.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if session.UserID == nil && authData.flags&authDataUserVerified == 0 {
+		return nil, fmt.Errorf("%w: passkey logins must verify the user", ErrInvalidWebAuthnResponse)
+	}
+
+	key, err := parseCOSEKey(credential.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	clientData, _ := decodeBase64URL(response.Response.ClientDataJSON)
+	clientDataHash := sha256.Sum256(clientData)
+	signature, err := decodeBase64URL(response.Response.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidWebAuthnResponse)
+	}
+	if err := key.verify(append(append([]byte(nil), rawAuthData...), clientDataHash[:]...), signature); err != nil {
+		return nil, err
+	}
+
+	// A counter that does not move forward betrays a cloned authenticator;
+	// authenticators without counters always report zero
+	if (authData.signCount != 0 || credential.SignCount != 0) && authData.signCount <= credential.SignCount {
+		return nil, fmt.Errorf("%w: signature counter did not increase", ErrInvalidWebAuthnResponse)
+	}
+	now := time.Now()
+	err = s.db.Model(&credential).UpdateColumns(map[string]interface{}{
+		"sign_count":   authData.signCount,
+		"last_used_at": now,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to update WebAuthn credential: %w", err)
+	}
+
+	var user models.User
+	if err := s.db.Where("id = ?", credential.UserID).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("user not found: %w", err)
+	}
+	return &user, nil
+}
+
+func (s *MFAService) createWebAuthnSession(userID *uuid.UUID, purpose, name string) (*WebAuthnSession, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	now := time.Now()
+	session := &WebAuthnSession{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Purpose:   purpose,
+		Challenge: base64.RawURLEncoding.EncodeToString(challenge),
+		Name:      name,
+		ExpiresAt: now.Add(webAuthnSessionTTL),
+	}
+	// Expired challenges are dropped as new ones are issued
+	s.db.Where("expires_at < ?", now).Delete(&WebAuthnSession{})
+	if err := s.db.Create(session).Error; err != nil {
+		return nil, fmt.Errorf("failed to store WebAuthn challenge: %w", err)
+	}
+	return session, nil
+}
+
+// takeWebAuthnSession consumes a pending challenge so it is answered once
+func (s *MFAService) takeWebAuthnSession(sessionID uuid.UUID, purpose string) (*WebAuthnSession, error) {
+	var session WebAuthnSession
+	err := s.db.Where("id = ? AND purpose = ? AND expires_at > ?", sessionID, purpose, time.Now()).First(&session).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebAuthnSessionInvalid
+		}
+		return nil, fmt.Errorf("failed to get WebAuthn challenge: %w", err)
+	}
+	result := s.db.Where("id = ?", session.ID).Delete(&WebAuthnSession{})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to consume WebAuthn challenge: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrWebAuthnSessionInvalid
+	}
+	return &session, nil
+}
+
+// verifyClientData checks the client data the authenticator signed over
+// belongs to this ceremony and origin
+func (s *MFAService) verifyClientData(encoded, ceremony, challenge string) error {
+	raw, err := decodeBase64URL(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: malformed client data", ErrInvalidWebAuthnResponse)
+	}
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge string `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(raw, &clientData); err != nil {
+		return fmt.Errorf("%w: malformed client data", ErrInvalidWebAuthnResponse)
+	}
+	if clientData.Type != ceremony {
+		return fmt.Errorf("%w: unexpected client data type %q", ErrInvalidWebAuthnResponse, clientData.Type)
+	}
+	if strings.TrimRight(clientData.Challenge, "=") != challenge {
+		return fmt.Errorf("%w: challenge mismatch", ErrInvalidWebAuthnResponse)
+	}
+	if clientData.Origin != s.rp.Origin {
+		return fmt.Errorf("%w: unexpected origin %q", ErrInvalidWebAuthnResponse, clientData.Origin)
+	}
+	return nil
+}
+
+// authenticatorData is the parsed authenticator data of a ceremony
+type authenticatorData struct {
+	flags     byte
+	signCount uint32
+	// credentialID and publicKey (a COSE key) are set for registrations
+	credentialID []byte
+	publicKey    []byte
+}
+
+func (s *MFAService) parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data is too short", ErrInvalidWebAuthnResponse)
+	}
+	rpIDHash := sha256.Sum256([]byte(s.rp.ID))
+	if !bytes.Equal(data[:32], rpIDHash[:]) {
+		return nil, fmt.Errorf("%w: credential is scoped to another relying party", ErrInvalidWebAuthnResponse)
+	}
+	parsed := &authenticatorData{flags: data[32], signCount: binary.BigEndian.Uint32(data[33:37])}
+	if parsed.flags&authDataUserPresent == 0 {
+		return nil, fmt.Errorf("%w: user was not present", ErrInvalidWebAuthnResponse)
+	}
+	if parsed.flags&authDataAttested == 0 {
+		return parsed, nil
+	}
+
+	// AAGUID, then the length-prefixed credential ID and its COSE key
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, fmt.Errorf("%w: attested credential data is too short", ErrInvalidWebAuthnResponse)
+	}
+	idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLength == 0 || len(rest) < idLength {
+		return nil, fmt.Errorf("%w: malformed credential ID", ErrInvalidWebAuthnResponse)
+	}
+	parsed.credentialID = append([]byte(nil), rest[:idLength]...)
+	rest = rest[idLength:]
+	_, keyLength, err := cborDecode(rest)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed credential public key", ErrInvalidWebAuthnResponse)
+	}
+	if parsed.flags&authDataExtensions == 0 && keyLength != len(rest) {
+		return nil, fmt.Errorf("%w: trailing authenticator data", ErrInvalidWebAuthnResponse)
+	}
+	parsed.publicKey = append([]byte(nil), rest[:keyLength]...)
+	return parsed, nil
+}
+
+// coseKey is a credential public key with the algorithm it signs with
+type coseKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parseCOSEKey decodes an ES256, EdDSA (Ed25519) or RS256 COSE key
+func parseCOSEKey(data []byte) (*coseKey, error) {
+	invalid := fmt.Errorf("%w: unsupported credential public key", ErrInvalidWebAuthnResponse)
+	decoded, _, err := cborDecode(data)
+	if err != nil {
+		return nil, invalid
+	}
+	params, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return nil, invalid
+	}
+	kty, _ := params[int64(1)].(int64)
+	alg, _ := params[int64(3)].(int64)
+	crv, _ := params[int64(-1)].(int64)
+	first, _ := params[int64(-1)].([]byte)
+	x, _ := params[int64(-2)].([]byte)
+	y, _ := params[int64(-3)].([]byte)
+
+	switch {
+	case kty == 2 && alg == coseAlgES256 && crv == 1 && len(x) == 32 && len(y) == 32:
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, invalid
+		}
+		return &coseKey{alg: alg, key: key}, nil
+	case kty == 1 && alg == coseAlgEdDSA && crv == 6 && len(x) == ed25519.PublicKeySize:
+		return &coseKey{alg: alg, key: ed25519.PublicKey(x)}, nil
+	case kty == 3 && alg == coseAlgRS256 && len(first) >= 256 && len(x) > 0 && len(x) <= 4:
+		exponent := new(big.Int).SetBytes(x)
+		return &coseKey{alg: alg, key: &rsa.PublicKey{N: new(big.Int).SetBytes(first), E: int(exponent.Int64())}}, nil
+	}
+	return nil, invalid
+}
+
+// verify checks signature over message
+func (k *coseKey) verify(message, signature []byte) error {
+	digest := sha256.Sum256(message)
+	valid := false
+	switch key := k.key.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, digest[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, message, signature)
+	case *rsa.PublicKey:
+		valid = rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature) == nil
+	}
+	if !valid {
+		return fmt.Errorf("%w: signature verification failed", ErrInvalidWebAuthnResponse)
+	}
+	return nil
+}
+
+// credentialDescriptors lists credentials for allowCredentials and
+// excludeCredentials
+func credentialDescriptors(credentials []WebAuthnCredential) []map[string]interface{} {
+	descriptors := make([]map[string]interface{}, 0, len(credentials))
+	for _, credential := range credentials {
+		descriptors = append(descriptors, map[string]interface{}{"type": "public-key", "id": credential.CredentialID})
+	}
+	return descriptors
+}
+
+// decodeBase64URL decodes base64url with or without padding
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
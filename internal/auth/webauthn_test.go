@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cborEncode encodes the integers, strings, byte strings and maps the
+// tests hand to the WebAuthn verifier
+func cborEncode(v interface{}) []byte {
+	header := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 256:
+			return []byte{major<<5 | 24, byte(n)}
+		default:
+			out := []byte{major<<5 | 25, 0, 0}
+			binary.BigEndian.PutUint16(out[1:], uint16(n))
+			return out
+		}
+	}
+	switch value := v.(type) {
+	case int:
+		if value < 0 {
+			return header(1, uint64(-1-value))
+		}
+		return header(0, uint64(value))
+	case []byte:
+		return append(header(2, uint64(len(value))), value...)
+	case string:
+		return append(header(3, uint64(len(value))), value...)
+	case map[interface{}]interface{}:
+		out := header(5, uint64(len(value)))
+		for key, item := range value {
+			out = append(out, cborEncode(key)...)
+			out = append(out, cborEncode(item)...)
+		}
+		return out
+	}
+	panic("cborEncode: unsupported type")
+}
+
+// testAuthenticator is a software ES256 authenticator
+type testAuthenticator struct {
+	key    *ecdsa.PrivateKey
+	id     []byte
+	rpID   string
+	origin string
+	count  uint32
+	// verified sets the user verified flag
+	verified bool
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	id := make([]byte, 16)
+	rand.Read(id)
+	return &testAuthenticator{key: key, id: id, rpID: "localhost", origin: "http://localhost:3000"}
+}
+
+func (a *testAuthenticator) clientData(ceremony string, options *WebAuthnOptions) []byte {
+	data, _ := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": options.PublicKey["challenge"].(string),
+		"origin":    a.origin,
+	})
+	return data
+}
+
+func (a *testAuthenticator) authData(attested bool) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	flags := byte(authDataUserPresent)
+	if a.verified {
+		flags |= authDataUserVerified
+	}
+	if attested {
+		flags |= authDataAttested
+	}
+	data := append(rpIDHash[:], flags, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(data[33:], a.count)
+	if !attested {
+		return data
+	}
+	data = append(data, make([]byte, 16)...)
+	data = append(data, byte(len(a.id)>>8), byte(len(a.id)))
+	data = append(data, a.id...)
+	return append(data, cborEncode(map[interface{}]interface{}{
+		1:  2,
+		3:  -7,
+		-1: 1,
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})...)
+}
+
+func (a *testAuthenticator) create(options *WebAuthnOptions) *WebAuthnCredentialResponse {
+	response := &WebAuthnCredentialResponse{ID: base64.RawURLEncoding.EncodeToString(a.id), Type: "public-key"}
+	response.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(a.clientData("webauthn.create", options))
+	response.Response.AttestationObject = base64.RawURLEncoding.EncodeToString(cborEncode(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": a.authData(true),
+	}))
+	return response
+}
+
+func (a *testAuthenticator) get(t *testing.T, options *WebAuthnOptions, userID uuid.UUID) *WebAuthnCredentialResponse {
+	a.count++
+	clientData := a.clientData("webauthn.get", options)
+	authData := a.authData(false)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+
+	response := &WebAuthnCredentialResponse{ID: base64.RawURLEncoding.EncodeToString(a.id), Type: "public-key"}
+	response.Response.ClientDataJSON = base64.RawURLEncoding.EncodeToString(clientData)
+	response.Response.AuthenticatorData = base64.RawURLEncoding.EncodeToString(authData)
+	response.Response.Signature = base64.RawURLEncoding.EncodeToString(signature)
+	response.Response.UserHandle = base64.RawURLEncoding.EncodeToString(userID[:])
+	return response
+}
+
+func TestWebAuthn(t *testing.T) {
+	authService, db, _ := setupTestServices(t)
+	ctx := context.Background()
+	mfaService := NewMFAService(db)
+	user, err := authService.Register(ctx, RegisterRequest{
+		Username: "keyuser",
+		Email:    "key@example.com",
+		Password: "SecurePassword123!",
+		FullName: "Key User",
+	})
+	require.NoError(t, err)
+	authenticator := newTestAuthenticator(t)
+
+	// Registration enables MFA
+	options, err := mfaService.BeginWebAuthnRegistration(user.ID, "Laptop key")
+	require.NoError(t, err)
+	credential, err := mfaService.FinishWebAuthnRegistration(user.ID, options.SessionID, authenticator.create(options))
+	require.NoError(t, err)
+	assert.Equal(t, "Laptop key", credential.Name)
+	_, err = mfaService.FinishWebAuthnRegistration(user.ID, options.SessionID, authenticator.create(options))
+	assert.ErrorIs(t, err, ErrWebAuthnSessionInvalid, "challenges are answered once")
+	var stored models.User
+	require.NoError(t, db.First(&stored, "id = ?", user.ID).Error)
+	assert.True(t, stored.TwoFactorEnabled)
+
+	// The password alone asks for a second factor
+	password := LoginRequest{Email: "key@example.com", Password: "SecurePassword123!"}
+	_, err = authService.Login(ctx, password)
+	var required *MFARequiredError
+	require.True(t, errors.As(err, &required))
+	assert.Contains(t, required.Methods, "webauthn")
+	require.NotNil(t, required.WebAuthn)
+
+	login := password
+	login.WebAuthn = &WebAuthnAssertion{SessionID: required.WebAuthn.SessionID, Credential: *authenticator.get(t, required.WebAuthn, user.ID)}
+	response, err := authService.Login(ctx, login)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, response.User.ID)
+	_, err = authService.Login(ctx, login)
+	assert.Error(t, err, "assertions cannot be replayed")
+
+	// Passkey logins must verify the user
+	options, err = authService.BeginPasskeyLogin(ctx)
+	require.NoError(t, err)
+	_, err = authService.LoginWithPasskey(ctx, PasskeyLoginRequest{SessionID: options.SessionID, Credential: *authenticator.get(t, options, user.ID)})
+	assert.ErrorIs(t, err, ErrInvalidWebAuthnResponse)
+
+	authenticator.verified = true
+	options, err = authService.BeginPasskeyLogin(ctx)
+	require.NoError(t, err)
+	response, err = authService.LoginWithPasskey(ctx, PasskeyLoginRequest{SessionID: options.SessionID, Credential: *authenticator.get(t, options, user.ID)})
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, response.User.ID)
+	assert.NotEmpty(t, response.AccessToken)
+
+	// A counter that goes backwards betrays a cloned authenticator
+	authenticator.count = 0
+	options, err = authService.BeginPasskeyLogin(ctx)
+	require.NoError(t, err)
+	_, err = authService.LoginWithPasskey(ctx, PasskeyLoginRequest{SessionID: options.SessionID, Credential: *authenticator.get(t, options, user.ID)})
+	assert.ErrorIs(t, err, ErrInvalidWebAuthnResponse)
+
+	// Other origins are refused
+	authenticator.count = 10
+	authenticator.origin = "https://evil.example.com"
+	options, err = authService.BeginPasskeyLogin(ctx)
+	require.NoError(t, err)
+	_, err = authService.LoginWithPasskey(ctx, PasskeyLoginRequest{SessionID: options.SessionID, Credential: *authenticator.get(t, options, user.ID)})
+	assert.ErrorIs(t, err, ErrInvalidWebAuthnResponse)
+
+	// Removing the only second factor turns MFA off
+	status, err := mfaService.Status(user.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, status.WebAuthnCredentials)
+	require.NoError(t, mfaService.DeleteWebAuthnCredential(user.ID, credential.CredentialID))
+	status, err = mfaService.Status(user.ID)
+	require.NoError(t, err)
+	assert.False(t, status.Enabled)
+}
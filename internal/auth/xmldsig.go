@@ -0,0 +1,378 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha1" // registers crypto.SHA1 for legacy signatures
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidXMLSignature is returned when an enveloped XML signature is
+// missing, malformed or does not verify
+var ErrInvalidXMLSignature = errors.New("invalid XML signature")
+
+const (
+	xmldsigNamespace   = "http://www.w3.org/2000/09/xmldsig#"
+	excC14NAlgorithm   = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	envelopedAlgorithm = xmldsigNamespace + "enveloped-signature"
+	xmlNamespace       = "http://www.w3.org/XML/1998/namespace"
+)
+
+var xmldsigDigests = map[string]crypto.Hash{
+	xmldsigNamespace + "sha1":                 crypto.SHA1,
+	"http://www.w3.org/2001/04/xmlenc#sha256": crypto.SHA256,
+	"http://www.w3.org/2001/04/xmlenc#sha512": crypto.SHA512,
+}
+
+var xmldsigSignatures = map[string]crypto.Hash{
+	xmldsigNamespace + "rsa-sha1":                       crypto.SHA1,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha256": crypto.SHA256,
+	"http://www.w3.org/2001/04/xmldsig-more#rsa-sha512": crypto.SHA512,
+}
+
+// xmlElement is a parsed XML element that keeps the namespace prefixes
+// exclusive canonicalization needs. Comments and processing instructions
+// are dropped, as canonicalization without comments does.
+type xmlElement struct {
+	prefix, local string
+	space         string // resolved namespace URI
+	attrs         []xmlAttribute
+	namespaces    map[string]string // declared on this element; "" is the default namespace
+	parent        *xmlElement
+	children      []xmlNode
+}
+
+type xmlAttribute struct {
+	prefix, local, space, value string
+}
+
+// xmlNode is either an element or character data
+type xmlNode struct {
+	element *xmlElement
+	text    string
+}
+
+// parseXMLDocument parses data into a tree, rejecting DTDs
+func parseXMLDocument(data []byte) (*xmlElement, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var root, current *xmlElement
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			el := &xmlElement{prefix: t.Name.Space, local: t.Name.Local, parent: current, namespaces: map[string]string{}}
+			for _, attr := range t.Attr {
+				switch {
+				case attr.Name.Space == "xmlns":
+					el.namespaces[attr.Name.Local] = attr.Value
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					el.namespaces[""] = attr.Value
+				default:
+					el.attrs = append(el.attrs, xmlAttribute{prefix: attr.Name.Space, local: attr.Name.Local, value: attr.Value})
+				}
+			}
+			if el.space, err = el.lookup(el.prefix); err != nil {
+				return nil, err
+			}
+			for i := range el.attrs {
+				if el.attrs[i].prefix != "" {
+					if el.attrs[i].space, err = el.lookup(el.attrs[i].prefix); err != nil {
+						return nil, err
+					}
+				}
+			}
+
+			if current == nil {
+				if root != nil {
+					return nil, errors.New("XML document has more than one root element")
+				}
+				root = el
+			} else {
+				current.children = append(current.children, xmlNode{element: el})
+			}
+			current = el
+		case xml.EndElement:
+			if current == nil || current.prefix != t.Name.Space || current.local != t.Name.Local {
+				return nil, fmt.Errorf("unexpected closing tag %s", t.Name.Local)
+			}
+			current = current.parent
+		case xml.CharData:
+			if current != nil {
+				current.children = append(current.children, xmlNode{text: string(t)})
+			}
+		case xml.Directive:
+			return nil, errors.New("XML document type declarations are not supported")
+		}
+	}
+	if root == nil || current != nil {
+		return nil, errors.New("incomplete XML document")
+	}
+	return root, nil
+}
+
+// lookup resolves prefix in the scope of the element
+func (e *xmlElement) lookup(prefix string) (string, error) {
+	if prefix == "xml" {
+		return xmlNamespace, nil
+	}
+	for el := e; el != nil; el = el.parent {
+		if uri, ok := el.namespaces[prefix]; ok {
+			return uri, nil
+		}
+	}
+	if prefix == "" {
+		return "", nil
+	}
+	return "", fmt.Errorf("undeclared namespace prefix %q", prefix)
+}
+
+func (e *xmlElement) attr(local string) string {
+	for _, a := range e.attrs {
+		if a.prefix == "" && a.local == local {
+			return a.value
+		}
+	}
+	return ""
+}
+
+// child returns the first child element named space:local
+func (e *xmlElement) child(space, local string) *xmlElement {
+	for _, el := range e.childElements(space, local) {
+		return el
+	}
+	return nil
+}
+
+func (e *xmlElement) childElements(space, local string) []*xmlElement {
+	var found []*xmlElement
+	for _, node := range e.children {
+		if node.element != nil && node.element.space == space && node.element.local == local {
+			found = append(found, node.element)
+		}
+	}
+	return found
+}
+
+func (e *xmlElement) text() string {
+	var b strings.Builder
+	for _, node := range e.children {
+		if node.element == nil {
+			b.WriteString(node.text)
+		}
+	}
+	return b.String()
+}
+
+func (e *xmlElement) qualifiedName() string {
+	if e.prefix == "" {
+		return e.local
+	}
+	return e.prefix + ":" + e.local
+}
+
+// canonicalize serializes e with Exclusive XML Canonicalization, leaving
+// out skip. Prefixes in inclusive are treated like inclusive
+// canonicalization treats every prefix.
+func canonicalize(e, skip *xmlElement, inclusive []string) []byte {
+	var buf bytes.Buffer
+	writeCanonical(&buf, e, skip, map[string]string{}, inclusive)
+	return buf.Bytes()
+}
+
+func writeCanonical(buf *bytes.Buffer, e, skip *xmlElement, rendered map[string]string, inclusive []string) {
+	used := map[string]bool{e.prefix: true}
+	for _, a := range e.attrs {
+		if a.prefix != "" {
+			used[a.prefix] = true
+		}
+	}
+	for _, prefix := range inclusive {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		if _, err := e.lookup(prefix); err == nil {
+			used[prefix] = true
+		}
+	}
+
+	next := make(map[string]string, len(rendered))
+	for prefix, uri := range rendered {
+		next[prefix] = uri
+	}
+	var declared []string
+	for prefix := range used {
+		if prefix == "xml" {
+			continue
+		}
+		uri, _ := e.lookup(prefix)
+		if previous, ok := rendered[prefix]; ok && previous == uri || !ok && prefix == "" && uri == "" {
+			continue
+		}
+		declared = append(declared, prefix)
+		next[prefix] = uri
+	}
+	sort.Strings(declared)
+
+	attrs := append([]xmlAttribute(nil), e.attrs...)
+	sort.Slice(attrs, func(i, j int) bool {
+		if attrs[i].space != attrs[j].space {
+			return attrs[i].space < attrs[j].space
+		}
+		return attrs[i].local < attrs[j].local
+	})
+
+	buf.WriteString("<" + e.qualifiedName())
+	for _, prefix := range declared {
+		if prefix == "" {
+			buf.WriteString(` xmlns="` + escapeCanonicalAttr(next[prefix]) + `"`)
+		} else {
+			buf.WriteString(" xmlns:" + prefix + `="` + escapeCanonicalAttr(next[prefix]) + `"`)
+		}
+	}
+	for _, a := range attrs {
+		name := a.local
+		if a.prefix != "" {
+			name = a.prefix + ":" + a.local
+		}
+		buf.WriteString(" " + name + `="` + escapeCanonicalAttr(a.value) + `"`)
+	}
+	buf.WriteString(">")
+	for _, node := range e.children {
+		switch {
+		case node.element == nil:
+			buf.WriteString(escapeCanonicalText(node.text))
+		case node.element != skip:
+			writeCanonical(buf, node.element, skip, next, inclusive)
+		}
+	}
+	buf.WriteString("</" + e.qualifiedName() + ">")
+}
+
+var (
+	canonicalTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	canonicalAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeCanonicalText(s string) string {
+	return canonicalTextEscaper.Replace(s)
+}
+
+func escapeCanonicalAttr(s string) string {
+	return canonicalAttrEscaper.Replace(s)
+}
+
+// inclusivePrefixes reads the InclusiveNamespaces PrefixList of a
+// canonicalization method or transform
+func inclusivePrefixes(method *xmlElement) []string {
+	if list := method.child(excC14NAlgorithm, "InclusiveNamespaces"); list != nil {
+		return strings.Fields(list.attr("PrefixList"))
+	}
+	return nil
+}
+
+// verifyEnvelopedSignature checks the XML signature that is a direct child
+// of e and signs e by its ID attribute. Only Exclusive Canonicalization and
+// RSA signatures are supported; the signature must verify with one of
+// certs, whatever key the document names.
+func verifyEnvelopedSignature(e *xmlElement, certs []*x509.Certificate) error {
+	signature := e.child(xmldsigNamespace, "Signature")
+	if signature == nil {
+		return fmt.Errorf("%w: element is not signed", ErrInvalidXMLSignature)
+	}
+	signedInfo := signature.child(xmldsigNamespace, "SignedInfo")
+	if signedInfo == nil {
+		return fmt.Errorf("%w: missing SignedInfo", ErrInvalidXMLSignature)
+	}
+	c14n := signedInfo.child(xmldsigNamespace, "CanonicalizationMethod")
+	if c14n == nil || c14n.attr("Algorithm") != excC14NAlgorithm {
+		return fmt.Errorf("%w: unsupported canonicalization method", ErrInvalidXMLSignature)
+	}
+	method := signedInfo.child(xmldsigNamespace, "SignatureMethod")
+	if method == nil {
+		return fmt.Errorf("%w: missing SignatureMethod", ErrInvalidXMLSignature)
+	}
+	signatureHash, ok := xmldsigSignatures[method.attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("%w: unsupported signature method %q", ErrInvalidXMLSignature, method.attr("Algorithm"))
+	}
+
+	references := signedInfo.childElements(xmldsigNamespace, "Reference")
+	if len(references) != 1 {
+		return fmt.Errorf("%w: expected one reference", ErrInvalidXMLSignature)
+	}
+	reference := references[0]
+	if id := e.attr("ID"); id == "" || reference.attr("URI") != "#"+id {
+		return fmt.Errorf("%w: signature does not reference the signed element", ErrInvalidXMLSignature)
+	}
+	var referenceInclusive []string
+	canonicalized := false
+	if transforms := reference.child(xmldsigNamespace, "Transforms"); transforms != nil {
+		for _, transform := range transforms.childElements(xmldsigNamespace, "Transform") {
+			switch transform.attr("Algorithm") {
+			case envelopedAlgorithm:
+			case excC14NAlgorithm:
+				canonicalized = true
+				referenceInclusive = inclusivePrefixes(transform)
+			default:
+				return fmt.Errorf("%w: unsupported transform %q", ErrInvalidXMLSignature, transform.attr("Algorithm"))
+			}
+		}
+	}
+	if !canonicalized {
+		return fmt.Errorf("%w: reference is not canonicalized", ErrInvalidXMLSignature)
+	}
+	digestMethod := reference.child(xmldsigNamespace, "DigestMethod")
+	digestValue := reference.child(xmldsigNamespace, "DigestValue")
+	if digestMethod == nil || digestValue == nil {
+		return fmt.Errorf("%w: missing digest", ErrInvalidXMLSignature)
+	}
+	digestHash, ok := xmldsigDigests[digestMethod.attr("Algorithm")]
+	if !ok {
+		return fmt.Errorf("%w: unsupported digest method %q", ErrInvalidXMLSignature, digestMethod.attr("Algorithm"))
+	}
+	expected, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(digestValue.text()), ""))
+	if err != nil {
+		return fmt.Errorf("%w: malformed digest", ErrInvalidXMLSignature)
+	}
+	digest := digestHash.New()
+	digest.Write(canonicalize(e, signature, referenceInclusive))
+	if subtle.ConstantTimeCompare(digest.Sum(nil), expected) != 1 {
+		return fmt.Errorf("%w: digest mismatch", ErrInvalidXMLSignature)
+	}
+
+	signatureValue := signature.child(xmldsigNamespace, "SignatureValue")
+	if signatureValue == nil {
+		return fmt.Errorf("%w: missing SignatureValue", ErrInvalidXMLSignature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(signatureValue.text()), ""))
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature value", ErrInvalidXMLSignature)
+	}
+	signed := signatureHash.New()
+	signed.Write(canonicalize(signedInfo, nil, inclusivePrefixes(c14n)))
+	hashed := signed.Sum(nil)
+	for _, cert := range certs {
+		if key, ok := cert.PublicKey.(*rsa.PublicKey); ok && rsa.VerifyPKCS1v15(key, signatureHash, hashed, sig) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: signature does not match the identity provider certificate", ErrInvalidXMLSignature)
+}
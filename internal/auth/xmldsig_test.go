@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	root, err := parseXMLDocument([]byte(`<?xml version="1.0"?>
+<root xmlns="urn:a" xmlns:b="urn:b" xmlns:unused="urn:c"><!-- comment --><b:child z="1" a="2" b:x='"'>x &amp; y &gt;</b:child><empty/></root>`))
+	require.NoError(t, err)
+
+	// Exclusive canonicalization only declares namespaces an element uses
+	assert.Equal(t, `<root xmlns="urn:a"><b:child xmlns:b="urn:b" a="2" z="1" b:x="&quot;">x &amp; y &gt;</b:child><empty></empty></root>`,
+		string(canonicalize(root, nil, nil)))
+	child := root.childElements("urn:b", "child")[0]
+	assert.Equal(t, `<b:child xmlns:b="urn:b" a="2" z="1" b:x="&quot;">x &amp; y &gt;</b:child>`, string(canonicalize(child, nil, nil)))
+	assert.Equal(t, `<b:child xmlns="urn:a" xmlns:b="urn:b" xmlns:unused="urn:c" a="2" z="1" b:x="&quot;">x &amp; y &gt;</b:child>`,
+		string(canonicalize(child, nil, []string{"#default", "unused"})))
+
+	_, err = parseXMLDocument([]byte(`<!DOCTYPE root [<!ENTITY x "y">]><root>&x;</root>`))
+	assert.Error(t, err)
+	_, err = parseXMLDocument([]byte(`<a:root/>`))
+	assert.Error(t, err)
+}
+
+const testSAMLResponse = `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_response" InResponseTo="_request" Destination="https://hub.example.com/acs">
+  <saml:Issuer xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion">https://idp.example.com</saml:Issuer>
+  <samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>
+  <saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_assertion">
+    <saml:Issuer>https://idp.example.com</saml:Issuer>
+    <ds:Signature xmlns:ds="http://www.w3.org/2000/09/xmldsig#">
+      <ds:SignedInfo>
+        <ds:CanonicalizationMethod Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"/>
+        <ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"/>
+        <ds:Reference URI="#_assertion">
+          <ds:Transforms>
+            <ds:Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"/>
+            <ds:Transform Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"/>
+          </ds:Transforms>
+          <ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"/>
+          <ds:DigestValue>{{DIGEST}}</ds:DigestValue>
+        </ds:Reference>
+      </ds:SignedInfo>
+      <ds:SignatureValue>{{SIGNATURE}}</ds:SignatureValue>
+    </ds:Signature>
+    <saml:Subject>
+      <saml:NameID>jane@example.com</saml:NameID>
+      <saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+        <saml:SubjectConfirmationData NotOnOrAfter="{{EXPIRES}}" Recipient="https://hub.example.com/acs" InResponseTo="_request"/>
+      </saml:SubjectConfirmation>
+    </saml:Subject>
+    <saml:Conditions NotBefore="{{ISSUED}}" NotOnOrAfter="{{EXPIRES}}">
+      <saml:AudienceRestriction><saml:Audience>https://hub.example.com/metadata</saml:Audience></saml:AudienceRestriction>
+    </saml:Conditions>
+    <saml:AttributeStatement>
+      <saml:Attribute Name="email"><saml:AttributeValue>jane@example.com</saml:AttributeValue></saml:Attribute>
+      <saml:Attribute Name="groups"><saml:AttributeValue>engineering</saml:AttributeValue><saml:AttributeValue>admins</saml:AttributeValue></saml:Attribute>
+    </saml:AttributeStatement>
+  </saml:Assertion>
+</samlp:Response>`
+
+// signTestSAMLResponse fills in the validity window and signs the assertion
+// of testSAMLResponse the way an identity provider would
+func signTestSAMLResponse(t *testing.T, key *rsa.PrivateKey, now time.Time) string {
+	document := strings.NewReplacer(
+		"{{ISSUED}}", now.Add(-time.Minute).UTC().Format(time.RFC3339),
+		"{{EXPIRES}}", now.Add(5*time.Minute).UTC().Format(time.RFC3339),
+	).Replace(testSAMLResponse)
+
+	root, err := parseXMLDocument([]byte(document))
+	require.NoError(t, err)
+	assertion := root.child(samlAssertionNamespace, "Assertion")
+	digest := sha256.Sum256(canonicalize(assertion, assertion.child(xmldsigNamespace, "Signature"), nil))
+	document = strings.Replace(document, "{{DIGEST}}", base64.StdEncoding.EncodeToString(digest[:]), 1)
+
+	root, err = parseXMLDocument([]byte(document))
+	require.NoError(t, err)
+	signedInfo := root.child(samlAssertionNamespace, "Assertion").child(xmldsigNamespace, "Signature").child(xmldsigNamespace, "SignedInfo")
+	hashed := sha256.Sum256(canonicalize(signedInfo, nil, nil))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	require.NoError(t, err)
+	return strings.Replace(document, "{{SIGNATURE}}", base64.StdEncoding.EncodeToString(signature), 1)
+}
+
+func testSAMLCertificate(t *testing.T) (*rsa.PrivateKey, *x509.Certificate) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return key, cert
+}
+
+func TestVerifySAMLResponse(t *testing.T) {
+	key, cert := testSAMLCertificate(t)
+	now := time.Now()
+	signed := signTestSAMLResponse(t, key, now)
+	validation := SAMLValidation{
+		IdPEntityID:  "https://idp.example.com",
+		Certificates: []*x509.Certificate{cert},
+		Audience:     "https://hub.example.com/metadata",
+		Recipient:    "https://hub.example.com/acs",
+		RequestID:    "_request",
+		Now:          now,
+	}
+	encode := func(document string) string {
+		return base64.StdEncoding.EncodeToString([]byte(document))
+	}
+
+	info, attributes, err := VerifySAMLResponse(encode(signed), validation)
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", info.ID)
+	assert.Equal(t, "jane@example.com", info.Email)
+	assert.Equal(t, []string{"engineering", "admins"}, attributes["groups"])
+
+	// Edits after signing break the digest
+	tampered := strings.Replace(signed, "<saml:AttributeValue>admins", "<saml:AttributeValue>owners", 1)
+	_, _, err = VerifySAMLResponse(encode(tampered), validation)
+	assert.ErrorIs(t, err, ErrSAMLSignatureInvalid)
+
+	// A second, unsigned assertion cannot be smuggled in next to the signed one
+	start := strings.Index(signed, "<saml:Assertion")
+	wrapped := strings.Replace(signed, "</samlp:Response>", strings.Replace(signed[start:strings.Index(signed, "</samlp:Response>")],
+		`ID="_assertion"`, `ID="_evil"`, 1)+"</samlp:Response>", 1)
+	_, _, err = VerifySAMLResponse(encode(wrapped), validation)
+	assert.ErrorIs(t, err, ErrInvalidSAMLResponse)
+
+	otherKey, otherCert := testSAMLCertificate(t)
+	_, _, err = VerifySAMLResponse(encode(signTestSAMLResponse(t, otherKey, now)), validation)
+	assert.ErrorIs(t, err, ErrSAMLSignatureInvalid)
+	rotated := validation
+	rotated.Certificates = []*x509.Certificate{otherCert, cert}
+	_, _, err = VerifySAMLResponse(encode(signed), rotated)
+	assert.NoError(t, err, "any configured certificate verifies")
+
+	for name, mutate := range map[string]func(*SAMLValidation){
+		"audience": func(v *SAMLValidation) { v.Audience = "https://other.example.com" },
+		"request":  func(v *SAMLValidation) { v.RequestID = "_replayed" },
+		"issuer":   func(v *SAMLValidation) { v.IdPEntityID = "https://evil.example.com" },
+		"expired":  func(v *SAMLValidation) { v.Now = now.Add(time.Hour) },
+	} {
+		invalid := validation
+		mutate(&invalid)
+		_, _, err = VerifySAMLResponse(encode(signed), invalid)
+		assert.ErrorIs(t, err, ErrInvalidSAMLResponse, name)
+	}
+
+	unsigned := strings.Replace(signed, `<ds:Reference URI="#_assertion">`, `<ds:Reference URI="#_response">`, 1)
+	_, _, err = VerifySAMLResponse(encode(unsigned), validation)
+	assert.ErrorIs(t, err, ErrSAMLSignatureInvalid)
+}
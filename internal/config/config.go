@@ -25,6 +25,301 @@ type Config struct {
 	Application   Application       `mapstructure:"application"`
 	// Git LFS configuration
 	LFS LFS `mapstructure:"lfs"`
+	// Code navigation symbol index
+	Symbols Symbols `mapstructure:"symbols"`
+	// Full-text search of repository file contents
+	CodeSearch CodeSearch `mapstructure:"code_search"`
+	// Analytics data retention and anonymization
+	Retention Retention `mapstructure:"retention"`
+	// Compaction of old daily analytics snapshots into monthly rollups
+	AnalyticsArchive AnalyticsArchive `mapstructure:"analytics_archive"`
+	// Panic and server error reporting to a Sentry-compatible tracker
+	ErrorReporting ErrorReporting `mapstructure:"error_reporting"`
+	// Per-repository cron schedules
+	Schedules Schedules `mapstructure:"schedules"`
+	// Opt-in anonymized usage reporting for fleet management
+	Telemetry Telemetry `mapstructure:"telemetry"`
+	// Scheduled deletion of merged branches in repositories that opt in
+	BranchCleanup BranchCleanup `mapstructure:"branch_cleanup"`
+	// Verbose permission decision traces for debugging access problems
+	AuthorizationTrace AuthorizationTrace `mapstructure:"authorization_trace"`
+	// Envelope encryption of secrets and tokens stored in the database
+	Encryption Encryption `mapstructure:"encryption"`
+	// Alerts about webhooks whose deliveries keep failing
+	IntegrationAlerts IntegrationAlerts `mapstructure:"integration_alerts"`
+	// Per-caller request limits of the API and git HTTP endpoints
+	RateLimits RateLimits `mapstructure:"rate_limits"`
+	// Per-module log levels, log formats and the security audit log
+	Logging Logging `mapstructure:"logging"`
+	// Virus and malware scanning of uploaded content
+	MalwareScanning MalwareScanning `mapstructure:"malware_scanning"`
+	// Beta features repository admins can switch on per repository
+	FeaturePreviews FeaturePreviews `mapstructure:"feature_previews"`
+	// Tamper-evident trail of security-relevant actions
+	Audit Audit `mapstructure:"audit"`
+	// Running several replicas behind a load balancer
+	Cluster Cluster `mapstructure:"cluster"`
+	// Queue of asynchronous work and the workers that run it
+	Jobs Jobs `mapstructure:"jobs"`
+	// Periodic maintenance run by the scheduled task scheduler
+	ScheduledTasks ScheduledTasks `mapstructure:"scheduled_tasks"`
+}
+
+// ScheduledTasks sets how often each periodic maintenance task runs; 0
+// turns a task off. Repository garbage collection keeps its interval in
+// Storage.Maintenance. RepositoryStatsMaxAgeHours is how old statistics may
+// get before they are refreshed, at most RepositoryStatsBatchSize
+// repositories per run. Token owners are warned TokenExpiryWarningDays
+// before a token expires.
+type ScheduledTasks struct {
+	AggregateMetricsMinutes    int `mapstructure:"aggregate_metrics_minutes"`
+	RepositoryStatsMinutes     int `mapstructure:"repository_stats_minutes"`
+	RepositoryStatsMaxAgeHours int `mapstructure:"repository_stats_max_age_hours"`
+	RepositoryStatsBatchSize   int `mapstructure:"repository_stats_batch_size"`
+	StorageCleanupMinutes      int `mapstructure:"storage_cleanup_minutes"`
+	TokenExpiryMinutes         int `mapstructure:"token_expiry_minutes"`
+	TokenExpiryWarningDays     int `mapstructure:"token_expiry_warning_days"`
+}
+
+// Jobs configures the background job queue. Jobs are kept in the database;
+// each replica runs Workers of them at a time, and 0 leaves them to other
+// replicas. With Driver "redis",
+// which requires Redis to be enabled, enqueued jobs wake idle workers on
+// every replica instead of waiting for the next poll. Finished jobs are
+// purged after RetentionDays.
+type Jobs struct {
+	Workers             int    `mapstructure:"workers"`
+	Driver              string `mapstructure:"driver"`
+	PollIntervalSeconds int    `mapstructure:"poll_interval_seconds"`
+	RetentionDays       int    `mapstructure:"retention_days"`
+}
+
+// Cluster configures running several replicas behind a load balancer
+// without sticky sessions. With SharedNothing no replica keeps state that
+// others need: rate limit windows are counted in Redis, which must be
+// enabled, and the SSH host key is kept in the database, so every replica
+// presents the same key.
+type Cluster struct {
+	SharedNothing bool `mapstructure:"shared_nothing"`
+}
+
+// Audit keeps the hash-chained trail of security-relevant actions in the
+// database. Entries older than RetentionDays are purged; 0 keeps them
+// forever. Stream forwards the trail to an external sink as well.
+type Audit struct {
+	RetentionDays int         `mapstructure:"retention_days"`
+	Stream        AuditStream `mapstructure:"stream"`
+}
+
+// AuditStream forwards audit entries in order, in batches of
+// newline-delimited JSON: POSTed to URL when Sink is "https", or written as
+// objects under Prefix in an S3 bucket when Sink is "s3". A failed batch is
+// retried on the next flush, and entries are not purged before they are
+// forwarded.
+type AuditStream struct {
+	Enabled bool      `mapstructure:"enabled"`
+	Sink    string    `mapstructure:"sink"`
+	URL     string    `mapstructure:"url"`
+	Token   string    `mapstructure:"token"` // Bearer token sent to URL
+	S3      S3Storage `mapstructure:"s3"`
+	Prefix  string    `mapstructure:"prefix"`
+	// BatchSize caps the entries sent in one request or object
+	BatchSize            int `mapstructure:"batch_size"`
+	FlushIntervalSeconds int `mapstructure:"flush_interval_seconds"`
+}
+
+// FeaturePreviews offers beta features that repository admins can switch on
+// for their repositories. Allowed limits the offer to the listed previews;
+// empty offers every preview. Disabling it switches every preview off.
+type FeaturePreviews struct {
+	Enabled bool     `mapstructure:"enabled"`
+	Allowed []string `mapstructure:"allowed"`
+}
+
+// MalwareScanning scans release assets, Git LFS objects and files uploaded
+// through the API before they are stored. Scanner is "clamav", a clamd
+// daemon at ClamAVAddress, or "http", a cloud scanning API at Endpoint.
+// Infected release assets and LFS objects are quarantined for admin review
+// unless BlockOnDetection rejects them outright; files committed by the
+// upload API are always rejected. When the scanner cannot be reached
+// uploads fail, unless FailOpen accepts them unscanned.
+type MalwareScanning struct {
+	Enabled        bool   `mapstructure:"enabled"`
+	Scanner        string `mapstructure:"scanner"`
+	ClamAVAddress  string `mapstructure:"clamav_address"` // "tcp://host:3310" or a unix socket path
+	Endpoint       string `mapstructure:"endpoint"`
+	Token          string `mapstructure:"token"` // Bearer token for the scanning API
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"`
+	// MaxScanSizeMB skips scanning larger content; 0 scans everything
+	MaxScanSizeMB    int64 `mapstructure:"max_scan_size_mb"`
+	BlockOnDetection bool  `mapstructure:"block_on_detection"`
+	FailOpen         bool  `mapstructure:"fail_open"`
+}
+
+// Logging configures the log pipeline. Level is the level of every module
+// unless Modules overrides it; module names are api, ssh, git, analytics and
+// jobs, and levels are logrus level names. An empty Level falls back to the
+// numeric log_level. Levels, formats and the audit log can be changed at
+// runtime through the admin config reload endpoint.
+type Logging struct {
+	Level   string            `mapstructure:"level"`
+	Modules map[string]string `mapstructure:"modules"`
+	// Format of the main log on stderr: "json" or "text"
+	Format string   `mapstructure:"format"`
+	Audit  AuditLog `mapstructure:"audit"`
+}
+
+// AuditLog writes security-relevant entries, such as admin changes to users,
+// to a separate append-only file regardless of module levels. Every line is
+// prefixed with the SHA-256 of the previous line's hash and its own content,
+// so editing or removing a line breaks the chain from that point on. While
+// disabled, these entries go to the main log.
+type AuditLog struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Path    string `mapstructure:"path"`
+	// Format of the entries: "json" or "text"
+	Format string `mapstructure:"format"`
+}
+
+// RateLimits caps the requests a caller makes in each category. Callers
+// are counted per user when authenticated and per client IP otherwise,
+// within fixed windows kept in the memory of each instance.
+type RateLimits struct {
+	Enabled bool              `mapstructure:"enabled"`
+	Core    RateLimitCategory `mapstructure:"core"`
+	Search  RateLimitCategory `mapstructure:"search"`
+	Git     RateLimitCategory `mapstructure:"git"`
+	Exports RateLimitCategory `mapstructure:"exports"`
+}
+
+// RateLimitCategory allows Limit requests, or AnonymousLimit for
+// unauthenticated callers, every WindowSeconds. A limit of 0 leaves the
+// category unlimited.
+type RateLimitCategory struct {
+	Limit          int `mapstructure:"limit"`
+	AnonymousLimit int `mapstructure:"anonymous_limit"`
+	WindowSeconds  int `mapstructure:"window_seconds"`
+}
+
+// IntegrationAlerts notifies the admins of a webhook's repository or
+// organization once FailureThreshold deliveries in a row have failed. With
+// AutoDisable the webhook is also deactivated until an admin enables it again.
+type IntegrationAlerts struct {
+	Enabled          bool `mapstructure:"enabled"`
+	FailureThreshold int  `mapstructure:"failure_threshold"`
+	AutoDisable      bool `mapstructure:"auto_disable"`
+}
+
+// Encryption configures envelope encryption of secrets stored in the
+// database. Every value is encrypted with its own data key, which is wrapped
+// by the master key named KeyID. The master key holds 32 base64 encoded
+// bytes and is read from MasterKeyFile when set, such as a Key Vault secret
+// mounted by the secrets store CSI driver, otherwise from MasterKey.
+// PreviousKeys maps retired key IDs to their keys so values written before a
+// rotation stay readable until they are re-encrypted. Secrets are stored in
+// plaintext while encryption is disabled.
+type Encryption struct {
+	Enabled       bool              `mapstructure:"enabled"`
+	KeyID         string            `mapstructure:"key_id"`
+	MasterKey     string            `mapstructure:"master_key"`
+	MasterKeyFile string            `mapstructure:"master_key_file"`
+	PreviousKeys  map[string]string `mapstructure:"previous_keys"`
+	// RotationBatchSize is how many rows key rotation re-encrypts per transaction
+	RotationBatchSize int `mapstructure:"rotation_batch_size"`
+}
+
+// AuthorizationTrace records why each request was given its repository
+// permission so admins can answer "why can't I push?" questions. Tracing
+// writes a row per request, so it is off by default; Users limits it to the
+// listed usernames. Traces are purged after RetentionHours.
+type AuthorizationTrace struct {
+	Enabled        bool     `mapstructure:"enabled"`
+	Users          []string `mapstructure:"users"`
+	RetentionHours int      `mapstructure:"retention_hours"`
+}
+
+// BranchCleanup runs the scheduled branch cleanup of repositories with
+// auto_delete_merged_branches set every IntervalHours
+type BranchCleanup struct {
+	Enabled       bool `mapstructure:"enabled"`
+	IntervalHours int  `mapstructure:"interval_hours"`
+}
+
+// Telemetry periodically reports anonymized aggregate usage of the instance,
+// such as its version, object counts and enabled features, to Endpoint.
+// Nothing is sent unless it is enabled with an endpoint.
+type Telemetry struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	Endpoint string `mapstructure:"endpoint"`
+	// Token is sent as a bearer token so the endpoint can tell fleets apart
+	Token          string `mapstructure:"token"`
+	IntervalHours  int    `mapstructure:"interval_hours"`
+	TimeoutSeconds int    `mapstructure:"timeout_seconds"`
+}
+
+// Schedules limits the cron triggers repositories can define
+type Schedules struct {
+	Enabled bool `mapstructure:"enabled"`
+	// MinIntervalMinutes is the shortest allowed gap between two runs
+	MinIntervalMinutes int `mapstructure:"min_interval_minutes"`
+	MaxPerRepository   int `mapstructure:"max_per_repository"`
+}
+
+// ErrorReporting holds the error tracker configuration; reports are only
+// sent when enabled with a DSN, otherwise they are logged
+type ErrorReporting struct {
+	Enabled bool   `mapstructure:"enabled"`
+	DSN     string `mapstructure:"dsn"`
+	// Environment defaults to the application environment
+	Environment string `mapstructure:"environment"`
+	Release     string `mapstructure:"release"`
+	// SampleRate is the fraction of events sent, between 0 and 1
+	SampleRate     float64 `mapstructure:"sample_rate"`
+	TimeoutSeconds int     `mapstructure:"timeout_seconds"`
+}
+
+// Retention holds per data class retention periods in days; zero keeps data forever
+type Retention struct {
+	Enabled bool `mapstructure:"enabled"`
+	// IntervalHours is how often the background purge runs
+	IntervalHours      int `mapstructure:"interval_hours"`
+	EventsDays         int `mapstructure:"events_days"`
+	PerformanceLogDays int `mapstructure:"performance_log_days"`
+	AuditLogDays       int `mapstructure:"audit_log_days"`
+	// AnonymizeAfterDays strips user identifiers from retained events and
+	// performance logs older than this
+	AnonymizeAfterDays int `mapstructure:"anonymize_after_days"`
+}
+
+// AnalyticsArchive configures compaction of daily repository analytics
+// snapshots. Rows older than CompactAfterMonths whole months are rolled into
+// one row per repository and month, and the raw rows are offloaded to the
+// storage backend as gzipped CSV.
+type AnalyticsArchive struct {
+	Enabled bool `mapstructure:"enabled"`
+	// IntervalHours is how often the background compaction runs
+	IntervalHours      int `mapstructure:"interval_hours"`
+	CompactAfterMonths int `mapstructure:"compact_after_months"`
+	// Storage backend for archived rows: "azure_blob", "s3", "filesystem"
+	Backend string       `mapstructure:"backend"`
+	Azure   AzureStorage `mapstructure:"azure"`
+}
+
+// Symbols holds code navigation indexing configuration
+type Symbols struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Languages to index, matching language detector names; empty enables all supported
+	Languages        []string `mapstructure:"languages"`
+	MaxFileSizeKB    int      `mapstructure:"max_file_size_kb"`
+	IndexAllBranches bool     `mapstructure:"index_all_branches"`
+}
+
+// CodeSearch indexes the file contents of repository default branches for
+// full-text code search, in Elasticsearch when it is enabled and in the
+// database otherwise. Files larger than MaxFileSizeKB are left out.
+type CodeSearch struct {
+	Enabled       bool `mapstructure:"enabled"`
+	MaxFileSizeKB int  `mapstructure:"max_file_size_kb"`
 }
 
 // LFS holds Git LFS storage configuration
@@ -32,6 +327,15 @@ type LFS struct {
 	// Storage backend for Git LFS: "azure_blob", "s3", "filesystem"
 	Backend string       `mapstructure:"backend"`
 	Azure   AzureStorage `mapstructure:"azure"`
+	// Files uploaded through the API at or above this size are stored in LFS
+	// and committed as pointer files; 0 disables LFS routing for uploads
+	UploadThresholdMB int64 `mapstructure:"upload_threshold_mb"`
+	// RepositoryQuotaMB caps the LFS storage of each repository unless the
+	// repository overrides it; 0 means unlimited
+	RepositoryQuotaMB int64 `mapstructure:"repository_quota_mb"`
+	// TokenMinutes is the lifetime of the credentials git-lfs-authenticate
+	// hands out to SSH clients
+	TokenMinutes int `mapstructure:"token_minutes"`
 }
 
 type Server struct {
@@ -67,8 +371,125 @@ type CORS struct {
 }
 
 type Storage struct {
-	RepositoryPath string          `mapstructure:"repository_path"`
-	Artifacts      ArtifactStorage `mapstructure:"artifacts"`
+	RepositoryPath   string           `mapstructure:"repository_path"`
+	Artifacts        ArtifactStorage  `mapstructure:"artifacts"`
+	Uploads          UploadLimits     `mapstructure:"uploads"`
+	Imports          ImportLimits     `mapstructure:"imports"`
+	Releases         ReleaseStorage   `mapstructure:"releases"`
+	Registry         RegistryStorage  `mapstructure:"registry"`
+	Packages         PackageStorage   `mapstructure:"packages"`
+	Packs            PackOffload      `mapstructure:"packs"`
+	Maintenance      Maintenance      `mapstructure:"maintenance"`
+	ForkPools        ForkPools        `mapstructure:"fork_pools"`
+	DisasterRecovery DisasterRecovery `mapstructure:"disaster_recovery"`
+}
+
+// DisasterRecovery replicates every push to object storage as an
+// append-only log of packs and ref snapshots. Repositories that were never
+// replicated, or whose last replication failed, are retried every
+// CatchUpIntervalMinutes, at most CatchUpBatchSize per run. Every
+// VerifyIntervalMinutes one replica is restored and checked.
+type DisasterRecovery struct {
+	Enabled                bool         `mapstructure:"enabled"`
+	Backend                string       `mapstructure:"backend"` // "azure", "s3", "filesystem"
+	Azure                  AzureStorage `mapstructure:"azure"`
+	S3                     S3Storage    `mapstructure:"s3"`
+	BasePath               string       `mapstructure:"base_path"` // For filesystem backend
+	CatchUpIntervalMinutes int          `mapstructure:"catch_up_interval_minutes"`
+	CatchUpBatchSize       int          `mapstructure:"catch_up_batch_size"`
+	VerifyIntervalMinutes  int          `mapstructure:"verify_interval_minutes"`
+}
+
+// ForkPools shares the objects of a fork network through one object pool
+// per network instead of copying them into every fork
+type ForkPools struct {
+	Enabled bool `mapstructure:"enabled"`
+}
+
+// Maintenance repacks repositories in the background, tuning how
+// aggressively from their layout and recent fetch and push rates
+type Maintenance struct {
+	Enabled           bool    `mapstructure:"enabled"`
+	IntervalMinutes   int     `mapstructure:"interval_minutes"`
+	MaxLooseObjects   int     `mapstructure:"max_loose_objects"` // Repack once this many loose objects pile up
+	MaxPacks          int     `mapstructure:"max_packs"`         // Repack once this many packs pile up
+	BusyFetchesPerDay float64 `mapstructure:"busy_fetches_per_day"`
+	BusyPushesPerDay  float64 `mapstructure:"busy_pushes_per_day"`
+	AccessWindowDays  int     `mapstructure:"access_window_days"` // Period the access rates are measured over
+}
+
+// PackOffload moves old packfiles of large repositories to object storage,
+// keeping recently used packs in a local cache
+type PackOffload struct {
+	Enabled         bool         `mapstructure:"enabled"`
+	Backend         string       `mapstructure:"backend"` // "azure", "s3", "filesystem"
+	Azure           AzureStorage `mapstructure:"azure"`
+	S3              S3Storage    `mapstructure:"s3"`
+	BasePath        string       `mapstructure:"base_path"`         // For filesystem backend
+	CachePath       string       `mapstructure:"cache_path"`        // Local cache of offloaded packs
+	CacheMaxSizeMB  int64        `mapstructure:"cache_max_size_mb"` // 0 disables eviction
+	MinRepoSizeMB   int64        `mapstructure:"min_repo_size_mb"`  // Smaller repositories are never offloaded
+	MinPackAgeDays  int          `mapstructure:"min_pack_age_days"`
+	IntervalMinutes int          `mapstructure:"interval_minutes"`
+}
+
+// UploadLimits bounds the multipart file upload API
+type UploadLimits struct {
+	MaxFileSizeMB    int64 `mapstructure:"max_file_size_mb"`
+	MaxRequestSizeMB int64 `mapstructure:"max_request_size_mb"`
+	MaxFiles         int   `mapstructure:"max_files"`
+}
+
+// ImportLimits bounds repositories created from uploaded project archives.
+// Archives up to InlineSizeMB are imported before the request returns;
+// larger ones are imported in the background.
+type ImportLimits struct {
+	MaxArchiveSizeMB   int64  `mapstructure:"max_archive_size_mb"`
+	MaxExtractedSizeMB int64  `mapstructure:"max_extracted_size_mb"` // Guards against archive bombs
+	MaxFiles           int    `mapstructure:"max_files"`
+	InlineSizeMB       int64  `mapstructure:"inline_size_mb"`
+	WorkPath           string `mapstructure:"work_path"` // Defaults to the system temp directory
+}
+
+// ReleaseStorage holds the binary assets attached to releases
+type ReleaseStorage struct {
+	Backend        string       `mapstructure:"backend"` // "azure", "s3", "filesystem"
+	Azure          AzureStorage `mapstructure:"azure"`
+	S3             S3Storage    `mapstructure:"s3"`
+	BasePath       string       `mapstructure:"base_path"` // For filesystem backend
+	MaxAssetSizeMB int64        `mapstructure:"max_asset_size_mb"`
+}
+
+// RegistryStorage holds the blobs and manifests of container images pushed
+// to the OCI registry served under /v2
+type RegistryStorage struct {
+	Enabled       bool         `mapstructure:"enabled"`
+	Backend       string       `mapstructure:"backend"` // "azure", "s3", "filesystem"
+	Azure         AzureStorage `mapstructure:"azure"`
+	S3            S3Storage    `mapstructure:"s3"`
+	BasePath      string       `mapstructure:"base_path"`   // For filesystem backend
+	UploadPath    string       `mapstructure:"upload_path"` // Blob uploads in progress; defaults to the system temp directory
+	MaxBlobSizeMB int64        `mapstructure:"max_blob_size_mb"`
+	// GCIntervalHours is how often unreferenced blobs are collected; 0
+	// leaves garbage collection to administrators
+	GCIntervalHours int `mapstructure:"gc_interval_hours"`
+	// GCGracePeriodHours keeps recently pushed blobs, which the manifest
+	// referencing them may not have been pushed yet
+	GCGracePeriodHours int `mapstructure:"gc_grace_period_hours"`
+}
+
+// PackageStorage holds the files of npm, Maven and generic packages
+// published to organizations
+type PackageStorage struct {
+	Enabled       bool         `mapstructure:"enabled"`
+	Backend       string       `mapstructure:"backend"` // "azure", "s3", "filesystem"
+	Azure         AzureStorage `mapstructure:"azure"`
+	S3            S3Storage    `mapstructure:"s3"`
+	BasePath      string       `mapstructure:"base_path"` // For filesystem backend
+	MaxFileSizeMB int64        `mapstructure:"max_file_size_mb"`
+	// RetentionIntervalHours is how often retention policies are applied; 0
+	// leaves it to administrators
+	RetentionIntervalHours int `mapstructure:"retention_interval_hours"`
 }
 
 type ArtifactStorage struct {
@@ -105,6 +526,18 @@ type OAuth struct {
 	Google    GoogleOAuth    `mapstructure:"google"`
 	Microsoft MicrosoftOAuth `mapstructure:"microsoft"`
 	GitLab    GitLabOAuth    `mapstructure:"gitlab"`
+	// The hub acting as an OAuth2/OpenID Connect provider for other applications
+	Provider OAuthProvider `mapstructure:"provider"`
+}
+
+// OAuthProvider configures the OAuth applications users register to sign in
+// with the hub. Without a signing key, ID tokens are signed with a key
+// generated at startup that other replicas and restarts do not share.
+type OAuthProvider struct {
+	AdminOnlyRegistration bool   `mapstructure:"admin_only_registration"`
+	SigningKeyPath        string `mapstructure:"signing_key_path"` // PEM RSA private key for ID tokens
+	AccessTokenMinutes    int    `mapstructure:"access_token_minutes"`
+	RefreshTokenDays      int    `mapstructure:"refresh_token_days"`
 }
 
 type GitHubOAuth struct {
@@ -170,6 +603,7 @@ type SSH struct {
 	Enabled     bool   `mapstructure:"enabled"`
 	Port        int    `mapstructure:"port"`
 	HostKeyPath string `mapstructure:"host_key_path"`
+	SFTPEnabled bool   `mapstructure:"sftp_enabled"`
 }
 
 type SMTP struct {
@@ -194,6 +628,7 @@ type Elasticsearch struct {
 type Application struct {
 	BaseURL string `mapstructure:"base_url"`
 	Name    string `mapstructure:"name"`
+	Version string `mapstructure:"version"`
 }
 
 func Load() (*Config, error) {
@@ -228,10 +663,67 @@ func Load() (*Config, error) {
 	viper.SetDefault("storage.artifacts.retention_days", 90)
 	viper.SetDefault("storage.artifacts.azure.container_name", "artifacts")
 	viper.SetDefault("storage.artifacts.s3.use_ssl", true)
+	viper.SetDefault("storage.packs.enabled", false)
+	viper.SetDefault("storage.packs.backend", "filesystem")
+	viper.SetDefault("storage.packs.base_path", "/var/lib/hub/packs")
+	viper.SetDefault("storage.packs.cache_path", "/var/cache/hub/packs")
+	viper.SetDefault("storage.packs.cache_max_size_mb", 10240)
+	viper.SetDefault("storage.packs.min_repo_size_mb", 1024)
+	viper.SetDefault("storage.packs.min_pack_age_days", 30)
+	viper.SetDefault("storage.packs.interval_minutes", 360)
+	viper.SetDefault("storage.packs.azure.container_name", "packs")
+	viper.SetDefault("storage.packs.s3.use_ssl", true)
+	viper.SetDefault("storage.disaster_recovery.enabled", false)
+	viper.SetDefault("storage.disaster_recovery.backend", "filesystem")
+	viper.SetDefault("storage.disaster_recovery.base_path", "/var/lib/hub/replicas")
+	viper.SetDefault("storage.disaster_recovery.catch_up_interval_minutes", 60)
+	viper.SetDefault("storage.disaster_recovery.catch_up_batch_size", 100)
+	viper.SetDefault("storage.disaster_recovery.verify_interval_minutes", 1440)
+	viper.SetDefault("storage.disaster_recovery.azure.container_name", "replicas")
+	viper.SetDefault("storage.disaster_recovery.s3.use_ssl", true)
+	viper.SetDefault("storage.maintenance.enabled", true)
+	viper.SetDefault("storage.maintenance.interval_minutes", 60)
+	viper.SetDefault("storage.maintenance.max_loose_objects", 6700)
+	viper.SetDefault("storage.maintenance.max_packs", 50)
+	viper.SetDefault("storage.maintenance.busy_fetches_per_day", 100)
+	viper.SetDefault("storage.maintenance.busy_pushes_per_day", 20)
+	viper.SetDefault("storage.maintenance.access_window_days", 7)
+	viper.SetDefault("storage.fork_pools.enabled", true)
+	viper.SetDefault("oauth.provider.admin_only_registration", false)
+	viper.SetDefault("oauth.provider.access_token_minutes", 60)
+	viper.SetDefault("oauth.provider.refresh_token_days", 30)
+	viper.SetDefault("storage.uploads.max_file_size_mb", 100)
+	viper.SetDefault("storage.uploads.max_request_size_mb", 500)
+	viper.SetDefault("storage.uploads.max_files", 100)
+	viper.SetDefault("storage.imports.max_archive_size_mb", 500)
+	viper.SetDefault("storage.imports.max_extracted_size_mb", 2048)
+	viper.SetDefault("storage.imports.max_files", 100000)
+	viper.SetDefault("storage.imports.inline_size_mb", 10)
+	viper.SetDefault("storage.releases.backend", "filesystem")
+	viper.SetDefault("storage.releases.base_path", "/var/lib/hub/releases")
+	viper.SetDefault("storage.releases.max_asset_size_mb", 2048)
+	viper.SetDefault("storage.releases.azure.container_name", "releases")
+	viper.SetDefault("storage.releases.s3.use_ssl", true)
+	viper.SetDefault("storage.registry.enabled", true)
+	viper.SetDefault("storage.registry.backend", "filesystem")
+	viper.SetDefault("storage.registry.base_path", "/var/lib/hub/registry")
+	viper.SetDefault("storage.registry.max_blob_size_mb", 10240)
+	viper.SetDefault("storage.registry.gc_interval_hours", 24)
+	viper.SetDefault("storage.registry.gc_grace_period_hours", 24)
+	viper.SetDefault("storage.registry.azure.container_name", "registry")
+	viper.SetDefault("storage.registry.s3.use_ssl", true)
+	viper.SetDefault("storage.packages.enabled", true)
+	viper.SetDefault("storage.packages.backend", "filesystem")
+	viper.SetDefault("storage.packages.base_path", "/var/lib/hub/packages")
+	viper.SetDefault("storage.packages.max_file_size_mb", 1024)
+	viper.SetDefault("storage.packages.retention_interval_hours", 24)
+	viper.SetDefault("storage.packages.azure.container_name", "packages")
+	viper.SetDefault("storage.packages.s3.use_ssl", true)
 	viper.SetDefault("security.encryption_key", "default-32-byte-key-for-secrets")
 	viper.SetDefault("ssh.enabled", true)
 	viper.SetDefault("ssh.port", 2222)
 	viper.SetDefault("ssh.host_key_path", "./ssh_host_key")
+	viper.SetDefault("ssh.sftp_enabled", false)
 	viper.SetDefault("smtp.host", "")
 	viper.SetDefault("smtp.port", "587")
 	viper.SetDefault("smtp.username", "")
@@ -243,11 +735,127 @@ func Load() (*Config, error) {
 	viper.SetDefault("elasticsearch.index_prefix", "hub")
 	viper.SetDefault("application.base_url", "http://localhost:3000")
 	viper.SetDefault("application.name", "A5C Hub")
+	viper.SetDefault("application.version", "1.0.0")
 	// Git LFS defaults
 	viper.SetDefault("lfs.backend", "filesystem")
 	viper.SetDefault("lfs.azure.account_name", "")
 	viper.SetDefault("lfs.azure.account_key", "")
 	viper.SetDefault("lfs.azure.container_name", "lfs")
+	viper.SetDefault("lfs.upload_threshold_mb", 10)
+	viper.SetDefault("lfs.repository_quota_mb", 0)
+	viper.SetDefault("lfs.token_minutes", 60)
+	// Symbol index defaults
+	viper.SetDefault("symbols.enabled", true)
+	viper.SetDefault("symbols.languages", []string{"Go", "Python", "JavaScript", "TypeScript", "Java"})
+	viper.SetDefault("symbols.max_file_size_kb", 512)
+	viper.SetDefault("symbols.index_all_branches", false)
+	viper.SetDefault("code_search.enabled", true)
+	viper.SetDefault("code_search.max_file_size_kb", 384)
+	// Analytics retention defaults
+	viper.SetDefault("retention.enabled", true)
+	viper.SetDefault("retention.interval_hours", 24)
+	viper.SetDefault("retention.events_days", 395)
+	viper.SetDefault("retention.performance_log_days", 30)
+	viper.SetDefault("retention.audit_log_days", 730)
+	viper.SetDefault("retention.anonymize_after_days", 90)
+
+	// Analytics snapshot compaction defaults
+	viper.SetDefault("analytics_archive.enabled", true)
+	viper.SetDefault("analytics_archive.interval_hours", 24)
+	viper.SetDefault("analytics_archive.compact_after_months", 6)
+	viper.SetDefault("analytics_archive.backend", "filesystem")
+	viper.SetDefault("analytics_archive.azure.account_name", "")
+	viper.SetDefault("analytics_archive.azure.account_key", "")
+	viper.SetDefault("analytics_archive.azure.container_name", "analytics-archive")
+
+	// Error reporting defaults
+	viper.SetDefault("error_reporting.enabled", false)
+	viper.SetDefault("error_reporting.sample_rate", 1.0)
+	viper.SetDefault("error_reporting.timeout_seconds", 5)
+
+	// Repository schedule defaults
+	viper.SetDefault("schedules.enabled", true)
+	viper.SetDefault("schedules.min_interval_minutes", 5)
+	viper.SetDefault("schedules.max_per_repository", 20)
+
+	// Branch cleanup defaults; repositories opt in individually
+	viper.SetDefault("branch_cleanup.enabled", true)
+	viper.SetDefault("branch_cleanup.interval_hours", 24)
+
+	// Authorization tracing defaults; tracing is verbose and opt-in
+	viper.SetDefault("authorization_trace.enabled", false)
+	viper.SetDefault("authorization_trace.retention_hours", 72)
+
+	// Secret encryption defaults; a master key must be provided to enable it
+	viper.SetDefault("encryption.enabled", false)
+	viper.SetDefault("encryption.key_id", "primary")
+	viper.SetDefault("encryption.rotation_batch_size", 500)
+
+	// Integration alert defaults; failing webhooks stay enabled unless configured
+	viper.SetDefault("integration_alerts.enabled", true)
+	viper.SetDefault("integration_alerts.failure_threshold", 10)
+	viper.SetDefault("integration_alerts.auto_disable", false)
+
+	// Rate limit defaults, modeled on GitHub's
+	viper.SetDefault("rate_limits.enabled", true)
+	viper.SetDefault("rate_limits.core.limit", 5000)
+	viper.SetDefault("rate_limits.core.anonymous_limit", 60)
+	viper.SetDefault("rate_limits.core.window_seconds", 3600)
+	viper.SetDefault("rate_limits.search.limit", 30)
+	viper.SetDefault("rate_limits.search.anonymous_limit", 10)
+	viper.SetDefault("rate_limits.search.window_seconds", 60)
+	viper.SetDefault("rate_limits.git.limit", 5000)
+	viper.SetDefault("rate_limits.git.anonymous_limit", 500)
+	viper.SetDefault("rate_limits.git.window_seconds", 3600)
+	viper.SetDefault("rate_limits.exports.limit", 100)
+	viper.SetDefault("rate_limits.exports.anonymous_limit", 20)
+	viper.SetDefault("rate_limits.exports.window_seconds", 3600)
+
+	// Telemetry defaults; reporting is opt-in
+	viper.SetDefault("logging.format", "json")
+	viper.SetDefault("logging.modules", map[string]string{})
+	viper.SetDefault("logging.audit.enabled", false)
+	viper.SetDefault("logging.audit.path", "/var/log/hub/audit.log")
+	viper.SetDefault("logging.audit.format", "json")
+	viper.SetDefault("telemetry.enabled", false)
+	viper.SetDefault("telemetry.interval_hours", 24)
+	viper.SetDefault("telemetry.timeout_seconds", 10)
+
+	// Malware scanning defaults; scanning needs a clamd or scanning API
+	viper.SetDefault("malware_scanning.enabled", false)
+	viper.SetDefault("malware_scanning.scanner", "clamav")
+	viper.SetDefault("malware_scanning.clamav_address", "tcp://localhost:3310")
+	viper.SetDefault("malware_scanning.timeout_seconds", 60)
+	viper.SetDefault("malware_scanning.max_scan_size_mb", 1024)
+	viper.SetDefault("malware_scanning.block_on_detection", false)
+	viper.SetDefault("malware_scanning.fail_open", false)
+
+	// Feature preview defaults; every preview is offered
+	viper.SetDefault("feature_previews.enabled", true)
+
+	// Audit trail defaults; streaming is opt-in
+	viper.SetDefault("audit.retention_days", 730)
+	viper.SetDefault("audit.stream.enabled", false)
+	viper.SetDefault("audit.stream.sink", "https")
+	viper.SetDefault("audit.stream.prefix", "audit")
+	viper.SetDefault("audit.stream.batch_size", 500)
+	viper.SetDefault("audit.stream.flush_interval_seconds", 30)
+	viper.SetDefault("cluster.shared_nothing", false)
+
+	// Job queue defaults; workers run in the server process
+	viper.SetDefault("jobs.workers", 4)
+	viper.SetDefault("jobs.driver", "database")
+	viper.SetDefault("jobs.poll_interval_seconds", 5)
+	viper.SetDefault("jobs.retention_days", 7)
+
+	// Scheduled maintenance task defaults
+	viper.SetDefault("scheduled_tasks.aggregate_metrics_minutes", 60)
+	viper.SetDefault("scheduled_tasks.repository_stats_minutes", 60)
+	viper.SetDefault("scheduled_tasks.repository_stats_max_age_hours", 24)
+	viper.SetDefault("scheduled_tasks.repository_stats_batch_size", 100)
+	viper.SetDefault("scheduled_tasks.storage_cleanup_minutes", 1440)
+	viper.SetDefault("scheduled_tasks.token_expiry_minutes", 360)
+	viper.SetDefault("scheduled_tasks.token_expiry_warning_days", 7)
 
 	viper.AutomaticEnv()
 
@@ -294,10 +902,29 @@ func Load() (*Config, error) {
 	viper.BindEnv("storage.artifacts.s3.secret_access_key", "AWS_SECRET_ACCESS_KEY")
 	viper.BindEnv("storage.artifacts.s3.endpoint_url", "S3_ENDPOINT_URL")
 	viper.BindEnv("storage.artifacts.s3.use_ssl", "S3_USE_SSL")
+	viper.BindEnv("storage.packs.enabled", "PACK_OFFLOAD_ENABLED")
+	viper.BindEnv("storage.packs.backend", "PACK_OFFLOAD_BACKEND")
+	viper.BindEnv("storage.packs.base_path", "PACK_OFFLOAD_PATH")
+	viper.BindEnv("storage.packs.cache_path", "PACK_CACHE_PATH")
+	viper.BindEnv("storage.packs.cache_max_size_mb", "PACK_CACHE_MAX_SIZE_MB")
+	viper.BindEnv("storage.packs.s3.bucket", "PACK_OFFLOAD_S3_BUCKET")
+	viper.BindEnv("storage.packs.azure.container_name", "PACK_OFFLOAD_AZURE_CONTAINER_NAME")
+	viper.BindEnv("storage.disaster_recovery.enabled", "DR_REPLICATION_ENABLED")
+	viper.BindEnv("storage.disaster_recovery.backend", "DR_REPLICATION_BACKEND")
+	viper.BindEnv("storage.disaster_recovery.base_path", "DR_REPLICATION_PATH")
+	viper.BindEnv("storage.disaster_recovery.s3.bucket", "DR_REPLICATION_S3_BUCKET")
+	viper.BindEnv("storage.disaster_recovery.azure.container_name", "DR_REPLICATION_AZURE_CONTAINER_NAME")
+	viper.BindEnv("storage.disaster_recovery.catch_up_interval_minutes", "DR_REPLICATION_CATCH_UP_INTERVAL_MINUTES")
+	viper.BindEnv("storage.disaster_recovery.verify_interval_minutes", "DR_REPLICATION_VERIFY_INTERVAL_MINUTES")
+	viper.BindEnv("oauth.provider.signing_key_path", "OAUTH_PROVIDER_SIGNING_KEY_PATH")
+	viper.BindEnv("storage.maintenance.enabled", "REPOSITORY_MAINTENANCE_ENABLED")
+	viper.BindEnv("storage.fork_pools.enabled", "FORK_POOLS_ENABLED")
+	viper.BindEnv("storage.maintenance.interval_minutes", "REPOSITORY_MAINTENANCE_INTERVAL_MINUTES")
 	viper.BindEnv("security.encryption_key", "ENCRYPTION_KEY")
 	viper.BindEnv("ssh.enabled", "SSH_ENABLED")
 	viper.BindEnv("ssh.port", "SSH_PORT")
 	viper.BindEnv("ssh.host_key_path", "SSH_HOST_KEY_PATH")
+	viper.BindEnv("ssh.sftp_enabled", "SSH_SFTP_ENABLED")
 	viper.BindEnv("smtp.host", "SMTP_HOST")
 	viper.BindEnv("smtp.port", "SMTP_PORT")
 	viper.BindEnv("smtp.username", "SMTP_USERNAME")
@@ -313,11 +940,93 @@ func Load() (*Config, error) {
 	viper.BindEnv("elasticsearch.index_prefix", "ELASTICSEARCH_INDEX_PREFIX")
 	viper.BindEnv("application.base_url", "BASE_URL")
 	viper.BindEnv("application.name", "APPLICATION_NAME")
+	viper.BindEnv("application.version", "APPLICATION_VERSION")
 	// Git LFS env bindings
 	viper.BindEnv("lfs.backend", "LFS_BACKEND")
 	viper.BindEnv("lfs.azure.account_name", "LFS_AZURE_ACCOUNT_NAME")
 	viper.BindEnv("lfs.azure.account_key", "LFS_AZURE_ACCOUNT_KEY")
 	viper.BindEnv("lfs.azure.container_name", "LFS_AZURE_CONTAINER_NAME")
+	viper.BindEnv("lfs.upload_threshold_mb", "LFS_UPLOAD_THRESHOLD_MB")
+	viper.BindEnv("lfs.repository_quota_mb", "LFS_REPOSITORY_QUOTA_MB")
+	viper.BindEnv("lfs.token_minutes", "LFS_TOKEN_MINUTES")
+	viper.BindEnv("symbols.enabled", "SYMBOLS_ENABLED")
+	viper.BindEnv("symbols.max_file_size_kb", "SYMBOLS_MAX_FILE_SIZE_KB")
+	viper.BindEnv("code_search.enabled", "CODE_SEARCH_ENABLED")
+	viper.BindEnv("code_search.max_file_size_kb", "CODE_SEARCH_MAX_FILE_SIZE_KB")
+	viper.BindEnv("retention.enabled", "RETENTION_ENABLED")
+	viper.BindEnv("retention.events_days", "RETENTION_EVENTS_DAYS")
+	viper.BindEnv("retention.performance_log_days", "RETENTION_PERFORMANCE_LOG_DAYS")
+	viper.BindEnv("retention.audit_log_days", "RETENTION_AUDIT_LOG_DAYS")
+	viper.BindEnv("retention.anonymize_after_days", "RETENTION_ANONYMIZE_AFTER_DAYS")
+	viper.BindEnv("analytics_archive.enabled", "ANALYTICS_ARCHIVE_ENABLED")
+	viper.BindEnv("analytics_archive.compact_after_months", "ANALYTICS_ARCHIVE_COMPACT_AFTER_MONTHS")
+	viper.BindEnv("analytics_archive.backend", "ANALYTICS_ARCHIVE_BACKEND")
+	viper.BindEnv("analytics_archive.azure.account_name", "ANALYTICS_ARCHIVE_AZURE_ACCOUNT_NAME")
+	viper.BindEnv("analytics_archive.azure.account_key", "ANALYTICS_ARCHIVE_AZURE_ACCOUNT_KEY")
+	viper.BindEnv("analytics_archive.azure.container_name", "ANALYTICS_ARCHIVE_AZURE_CONTAINER_NAME")
+	viper.BindEnv("error_reporting.enabled", "ERROR_REPORTING_ENABLED")
+	viper.BindEnv("error_reporting.dsn", "ERROR_REPORTING_DSN", "SENTRY_DSN")
+	viper.BindEnv("error_reporting.environment", "ERROR_REPORTING_ENVIRONMENT")
+	viper.BindEnv("error_reporting.release", "ERROR_REPORTING_RELEASE")
+	viper.BindEnv("error_reporting.sample_rate", "ERROR_REPORTING_SAMPLE_RATE")
+	viper.BindEnv("schedules.enabled", "SCHEDULES_ENABLED")
+	viper.BindEnv("schedules.min_interval_minutes", "SCHEDULES_MIN_INTERVAL_MINUTES")
+	viper.BindEnv("schedules.max_per_repository", "SCHEDULES_MAX_PER_REPOSITORY")
+	viper.BindEnv("branch_cleanup.enabled", "BRANCH_CLEANUP_ENABLED")
+	viper.BindEnv("branch_cleanup.interval_hours", "BRANCH_CLEANUP_INTERVAL_HOURS")
+	viper.BindEnv("authorization_trace.enabled", "AUTHORIZATION_TRACE_ENABLED")
+	viper.BindEnv("authorization_trace.users", "AUTHORIZATION_TRACE_USERS")
+	viper.BindEnv("authorization_trace.retention_hours", "AUTHORIZATION_TRACE_RETENTION_HOURS")
+	viper.BindEnv("feature_previews.enabled", "FEATURE_PREVIEWS_ENABLED")
+	viper.BindEnv("feature_previews.allowed", "FEATURE_PREVIEWS_ALLOWED")
+	viper.BindEnv("audit.retention_days", "AUDIT_RETENTION_DAYS")
+	viper.BindEnv("audit.stream.enabled", "AUDIT_STREAM_ENABLED")
+	viper.BindEnv("audit.stream.sink", "AUDIT_STREAM_SINK")
+	viper.BindEnv("audit.stream.url", "AUDIT_STREAM_URL")
+	viper.BindEnv("audit.stream.token", "AUDIT_STREAM_TOKEN")
+	viper.BindEnv("audit.stream.s3.bucket", "AUDIT_STREAM_S3_BUCKET")
+	viper.BindEnv("audit.stream.s3.region", "AUDIT_STREAM_S3_REGION")
+	viper.BindEnv("audit.stream.s3.access_key_id", "AUDIT_STREAM_S3_ACCESS_KEY_ID")
+	viper.BindEnv("audit.stream.s3.secret_access_key", "AUDIT_STREAM_S3_SECRET_ACCESS_KEY")
+	viper.BindEnv("audit.stream.s3.endpoint_url", "AUDIT_STREAM_S3_ENDPOINT_URL")
+	viper.BindEnv("cluster.shared_nothing", "CLUSTER_SHARED_NOTHING")
+	viper.BindEnv("jobs.workers", "JOBS_WORKERS")
+	viper.BindEnv("jobs.driver", "JOBS_DRIVER")
+	viper.BindEnv("jobs.poll_interval_seconds", "JOBS_POLL_INTERVAL_SECONDS")
+	viper.BindEnv("jobs.retention_days", "JOBS_RETENTION_DAYS")
+	viper.BindEnv("scheduled_tasks.aggregate_metrics_minutes", "SCHEDULED_TASKS_AGGREGATE_METRICS_MINUTES")
+	viper.BindEnv("scheduled_tasks.repository_stats_minutes", "SCHEDULED_TASKS_REPOSITORY_STATS_MINUTES")
+	viper.BindEnv("scheduled_tasks.repository_stats_max_age_hours", "SCHEDULED_TASKS_REPOSITORY_STATS_MAX_AGE_HOURS")
+	viper.BindEnv("scheduled_tasks.repository_stats_batch_size", "SCHEDULED_TASKS_REPOSITORY_STATS_BATCH_SIZE")
+	viper.BindEnv("scheduled_tasks.storage_cleanup_minutes", "SCHEDULED_TASKS_STORAGE_CLEANUP_MINUTES")
+	viper.BindEnv("scheduled_tasks.token_expiry_minutes", "SCHEDULED_TASKS_TOKEN_EXPIRY_MINUTES")
+	viper.BindEnv("scheduled_tasks.token_expiry_warning_days", "SCHEDULED_TASKS_TOKEN_EXPIRY_WARNING_DAYS")
+	viper.BindEnv("encryption.enabled", "ENCRYPTION_ENABLED")
+	viper.BindEnv("encryption.key_id", "ENCRYPTION_KEY_ID")
+	viper.BindEnv("encryption.master_key", "ENCRYPTION_MASTER_KEY")
+	viper.BindEnv("encryption.master_key_file", "ENCRYPTION_MASTER_KEY_FILE")
+	viper.BindEnv("encryption.rotation_batch_size", "ENCRYPTION_ROTATION_BATCH_SIZE")
+	viper.BindEnv("integration_alerts.enabled", "INTEGRATION_ALERTS_ENABLED")
+	viper.BindEnv("integration_alerts.failure_threshold", "INTEGRATION_ALERTS_FAILURE_THRESHOLD")
+	viper.BindEnv("integration_alerts.auto_disable", "INTEGRATION_ALERTS_AUTO_DISABLE")
+	viper.BindEnv("rate_limits.enabled", "RATE_LIMITS_ENABLED")
+	viper.BindEnv("rate_limits.core.limit", "RATE_LIMITS_CORE_LIMIT")
+	viper.BindEnv("rate_limits.core.anonymous_limit", "RATE_LIMITS_CORE_ANONYMOUS_LIMIT")
+	viper.BindEnv("rate_limits.search.limit", "RATE_LIMITS_SEARCH_LIMIT")
+	viper.BindEnv("rate_limits.search.anonymous_limit", "RATE_LIMITS_SEARCH_ANONYMOUS_LIMIT")
+	viper.BindEnv("rate_limits.git.limit", "RATE_LIMITS_GIT_LIMIT")
+	viper.BindEnv("rate_limits.git.anonymous_limit", "RATE_LIMITS_GIT_ANONYMOUS_LIMIT")
+	viper.BindEnv("rate_limits.exports.limit", "RATE_LIMITS_EXPORTS_LIMIT")
+	viper.BindEnv("rate_limits.exports.anonymous_limit", "RATE_LIMITS_EXPORTS_ANONYMOUS_LIMIT")
+	viper.BindEnv("logging.level", "LOGGING_LEVEL")
+	viper.BindEnv("logging.format", "LOG_FORMAT")
+	viper.BindEnv("logging.audit.enabled", "AUDIT_LOG_ENABLED")
+	viper.BindEnv("logging.audit.path", "AUDIT_LOG_PATH")
+	viper.BindEnv("logging.audit.format", "AUDIT_LOG_FORMAT")
+	viper.BindEnv("telemetry.enabled", "TELEMETRY_ENABLED")
+	viper.BindEnv("telemetry.endpoint", "TELEMETRY_ENDPOINT")
+	viper.BindEnv("telemetry.token", "TELEMETRY_TOKEN")
+	viper.BindEnv("telemetry.interval_hours", "TELEMETRY_INTERVAL_HOURS")
 
 	// GitHub integration defaults and env bindings
 	viper.SetDefault("github.client_id", "")
@@ -1,9 +1,11 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/a5c-ai/hub/internal/middleware"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/a5c-ai/hub/internal/services"
 	"github.com/gin-gonic/gin"
@@ -69,24 +71,43 @@ func (ctrl *OrganizationController) GetOrganization(c *gin.Context) {
 		return
 	}
 
+	middleware.SetVersionETag(c, org.Version)
 	c.JSON(http.StatusOK, org)
 }
 
 func (ctrl *OrganizationController) UpdateOrganization(c *gin.Context) {
 	orgName := c.Param("org")
 
+	ifVersion, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
 	var req services.UpdateOrganizationRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.IfVersion = ifVersion
 
 	org, err := ctrl.orgService.Update(c.Request.Context(), orgName, req)
+	if errors.Is(err, services.ErrVersionConflict) {
+		// Return the current state so the client can merge and retry
+		current, getErr := ctrl.orgService.Get(c.Request.Context(), orgName)
+		if getErr != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		middleware.SetVersionETag(c, current.Version)
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "current": current})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	middleware.SetVersionETag(c, org.Version)
 	c.JSON(http.StatusOK, org)
 }
 
@@ -211,6 +232,10 @@ func (ctrl *OrganizationController) AddMember(c *gin.Context) {
 	}
 
 	member, err := ctrl.memberService.AddMember(c.Request.Context(), orgName, username, req.Role)
+	if errors.Is(err, services.ErrExternalCollaboratorRestricted) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -364,6 +389,10 @@ func (ctrl *OrganizationController) AcceptInvitation(c *gin.Context) {
 	}
 
 	if err := ctrl.invitationService.AcceptInvitation(c.Request.Context(), req.Token, userID); err != nil {
+		if errors.Is(err, services.ErrExternalCollaboratorRestricted) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
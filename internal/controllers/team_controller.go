@@ -1,9 +1,11 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
+	"github.com/a5c-ai/hub/internal/middleware"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/a5c-ai/hub/internal/services"
 	"github.com/gin-gonic/gin"
@@ -88,6 +90,7 @@ func (ctrl *TeamController) GetTeam(c *gin.Context) {
 		return
 	}
 
+	middleware.SetVersionETag(c, team.Version)
 	c.JSON(http.StatusOK, team)
 }
 
@@ -95,18 +98,36 @@ func (ctrl *TeamController) UpdateTeam(c *gin.Context) {
 	orgName := c.Param("org")
 	teamName := c.Param("team")
 
+	ifVersion, ok := middleware.IfMatchVersion(c)
+	if !ok {
+		return
+	}
+
 	var req services.UpdateTeamRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.IfVersion = ifVersion
 
 	team, err := ctrl.teamService.Update(c.Request.Context(), orgName, teamName, req)
+	if errors.Is(err, services.ErrVersionConflict) {
+		// Return the current state so the client can merge and retry
+		current, getErr := ctrl.teamService.Get(c.Request.Context(), orgName, teamName)
+		if getErr != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		middleware.SetVersionETag(c, current.Version)
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "current": current})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
+	middleware.SetVersionETag(c, team.Version)
 	c.JSON(http.StatusOK, team)
 }
 
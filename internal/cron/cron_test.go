@@ -0,0 +1,78 @@
+package cron
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8",
+		"*/0 * * * *", "5-1 * * * *", "* * * foo *", "@every 5m"} {
+		_, err := Parse(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := time.Date(2024, 1, 31, 10, 17, 30, 0, time.UTC)
+	for _, tc := range []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 1, 31, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 1, 31, 10, 30, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2024, 1, 31, 10, 25, 0, 0, time.UTC)},
+		{"0 9-17 * * mon-fri", time.Date(2024, 1, 31, 11, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 31 * *", time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC)},
+		{"30 6 * * 7", time.Date(2024, 2, 4, 6, 30, 0, 0, time.UTC)},
+		// Either the day of month or the weekday matches when both are set
+		{"0 12 15 * fri", time.Date(2024, 2, 2, 12, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	} {
+		s, err := Parse(tc.spec)
+		require.NoError(t, err, tc.spec)
+		assert.Equal(t, tc.want, s.Next(utc), tc.spec)
+	}
+}
+
+func TestNextAcrossDaylightSaving(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// 02:30 does not exist on the day clocks spring forward
+	s, err := Parse("30 2 * * *")
+	require.NoError(t, err)
+	next := s.Next(time.Date(2024, 3, 10, 0, 0, 0, 0, loc))
+	assert.Equal(t, time.Date(2024, 3, 11, 2, 30, 0, 0, loc), next)
+
+	// 01:30 happens twice when clocks fall back but fires once
+	s, err = Parse("30 1 * * *")
+	require.NoError(t, err)
+	first := s.Next(time.Date(2024, 11, 3, 0, 0, 0, 0, loc))
+	assert.Equal(t, time.Date(2024, 11, 3, 1, 30, 0, 0, loc), first)
+	assert.Equal(t, time.Date(2024, 11, 4, 1, 30, 0, 0, loc), s.Next(first))
+}
+
+func TestMinInterval(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		spec string
+		want time.Duration
+	}{
+		{"* * * * *", time.Minute},
+		{"*/10 * * * *", 10 * time.Minute},
+		{"0,5 * * * *", 5 * time.Minute},
+		{"59 23 * * *", 24 * time.Hour},
+		{"0 0,23 * * *", time.Hour},
+		{"@monthly", 0},
+	} {
+		s, err := Parse(tc.spec)
+		require.NoError(t, err, tc.spec)
+		assert.Equal(t, tc.want, s.MinInterval(from, 8*24*time.Hour), tc.spec)
+	}
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("024_digest_tables", migrate024Up, migrate024Down)
+}
+
+func migrate024Up(db *gorm.DB) error {
+	if err := db.AutoMigrate(
+		&models.OrganizationDigestSettings{},
+		&models.DigestSubscription{},
+	); err != nil {
+		return err
+	}
+
+	// One subscription row per member and organization
+	return db.Exec(`
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_digest_subscriptions_org_user
+		ON digest_subscriptions (organization_id, user_id) WHERE deleted_at IS NULL;
+	`).Error
+}
+
+func migrate024Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(
+		&models.DigestSubscription{},
+		&models.OrganizationDigestSettings{},
+	)
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("025_code_symbols_table", migrate025Up, migrate025Down)
+}
+
+func migrate025Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.CodeSymbol{})
+}
+
+func migrate025Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.CodeSymbol{})
+}
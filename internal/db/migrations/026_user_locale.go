@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("026_user_locale", migrate026Up, migrate026Down)
+}
+
+func migrate026Up(db *gorm.DB) error {
+	if db.Migrator().HasColumn(&models.User{}, "locale") {
+		return nil
+	}
+	return db.Migrator().AddColumn(&models.User{}, "Locale")
+}
+
+func migrate026Down(db *gorm.DB) error {
+	return db.Migrator().DropColumn(&models.User{}, "locale")
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("027_runner_tables", migrate027Up, migrate027Down)
+}
+
+func migrate027Up(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&models.RunnerGroup{},
+		&models.Runner{},
+		&models.RunnerRegistrationToken{},
+		&models.RunnerJob{},
+	)
+}
+
+func migrate027Down(db *gorm.DB) error {
+	if err := db.Migrator().DropTable("runner_group_repositories"); err != nil {
+		return err
+	}
+	return db.Migrator().DropTable(
+		&models.RunnerJob{},
+		&models.RunnerRegistrationToken{},
+		&models.Runner{},
+		&models.RunnerGroup{},
+	)
+}
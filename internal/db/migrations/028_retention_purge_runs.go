@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("028_retention_purge_runs", migrate028Up, migrate028Down)
+}
+
+func migrate028Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.RetentionPurgeRun{})
+}
+
+func migrate028Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.RetentionPurgeRun{})
+}
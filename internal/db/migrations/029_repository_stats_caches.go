@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("029_repository_stats_caches", migrate029Up, migrate029Down)
+}
+
+func migrate029Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.RepositoryStatsCache{})
+}
+
+func migrate029Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.RepositoryStatsCache{})
+}
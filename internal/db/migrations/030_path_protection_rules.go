@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("030_path_protection_rules", migrate030Up, migrate030Down)
+}
+
+func migrate030Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.PathProtectionRule{})
+}
+
+func migrate030Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.PathProtectionRule{})
+}
@@ -0,0 +1,21 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("031_user_service_accounts", migrate031Up, migrate031Down)
+}
+
+func migrate031Up(db *gorm.DB) error {
+	if db.Migrator().HasColumn(&models.User{}, "is_service_account") {
+		return nil
+	}
+	return db.Migrator().AddColumn(&models.User{}, "IsServiceAccount")
+}
+
+func migrate031Down(db *gorm.DB) error {
+	return db.Migrator().DropColumn(&models.User{}, "is_service_account")
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("032_issue_events", migrate032Up, migrate032Down)
+}
+
+func migrate032Up(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Repository{}, "auto_close_issues") {
+		if err := db.Migrator().AddColumn(&models.Repository{}, "AutoCloseIssues"); err != nil {
+			return err
+		}
+	}
+	return db.AutoMigrate(&models.IssueEvent{})
+}
+
+func migrate032Down(db *gorm.DB) error {
+	if err := db.Migrator().DropTable(&models.IssueEvent{}); err != nil {
+		return err
+	}
+	return db.Migrator().DropColumn(&models.Repository{}, "auto_close_issues")
+}
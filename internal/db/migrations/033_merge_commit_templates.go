@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("033_merge_commit_templates", migrate033Up, migrate033Down)
+}
+
+var mergeTemplateColumns = []string{
+	"merge_commit_title_template",
+	"merge_commit_message_template",
+	"squash_commit_title_template",
+	"squash_commit_message_template",
+	"pull_request_title_pattern",
+}
+
+func migrate033Up(db *gorm.DB) error {
+	for _, column := range mergeTemplateColumns {
+		if !db.Migrator().HasColumn(&models.Repository{}, column) {
+			if err := db.Migrator().AddColumn(&models.Repository{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func migrate033Down(db *gorm.DB) error {
+	for _, column := range mergeTemplateColumns {
+		if db.Migrator().HasColumn(&models.Repository{}, column) {
+			if err := db.Migrator().DropColumn(&models.Repository{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
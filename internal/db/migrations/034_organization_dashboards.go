@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("034_organization_dashboards", migrate034Up, migrate034Down)
+}
+
+func migrate034Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.OrganizationDashboard{})
+}
+
+func migrate034Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.OrganizationDashboard{})
+}
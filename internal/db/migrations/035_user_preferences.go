@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("035_user_preferences", migrate035Up, migrate035Down)
+}
+
+func migrate035Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.UserPreferences{})
+}
+
+func migrate035Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.UserPreferences{})
+}
@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("036_review_suggestions", migrate036Up, migrate036Down)
+}
+
+var reviewSuggestionColumns = []string{
+	"original_lines",
+	"suggestion_applied_sha",
+	"suggestion_applied_at",
+}
+
+func migrate036Up(db *gorm.DB) error {
+	for _, column := range reviewSuggestionColumns {
+		if !db.Migrator().HasColumn(&models.ReviewComment{}, column) {
+			if err := db.Migrator().AddColumn(&models.ReviewComment{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func migrate036Down(db *gorm.DB) error {
+	for _, column := range reviewSuggestionColumns {
+		if db.Migrator().HasColumn(&models.ReviewComment{}, column) {
+			if err := db.Migrator().DropColumn(&models.ReviewComment{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
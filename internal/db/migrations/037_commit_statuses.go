@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("037_commit_statuses", migrate037Up, migrate037Down)
+}
+
+func migrate037Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.CommitStatus{})
+}
+
+func migrate037Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.CommitStatus{})
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("038_repository_watches", migrate038Up, migrate038Down)
+}
+
+func migrate038Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.RepositoryWatch{})
+}
+
+func migrate038Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.RepositoryWatch{})
+}
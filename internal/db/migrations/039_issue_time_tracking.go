@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("039_issue_time_tracking", migrate039Up, migrate039Down)
+}
+
+var issueTimeTrackingColumns = []string{
+	"milestone_id",
+	"time_estimate",
+	"total_time_spent",
+}
+
+func migrate039Up(db *gorm.DB) error {
+	if err := db.AutoMigrate(&models.Milestone{}, &models.TimeEntry{}); err != nil {
+		return err
+	}
+	for _, column := range issueTimeTrackingColumns {
+		if !db.Migrator().HasColumn(&models.Issue{}, column) {
+			if err := db.Migrator().AddColumn(&models.Issue{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func migrate039Down(db *gorm.DB) error {
+	for _, column := range issueTimeTrackingColumns {
+		if db.Migrator().HasColumn(&models.Issue{}, column) {
+			if err := db.Migrator().DropColumn(&models.Issue{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return db.Migrator().DropTable(&models.TimeEntry{}, &models.Milestone{})
+}
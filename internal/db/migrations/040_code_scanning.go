@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("040_code_scanning", migrate040Up, migrate040Down)
+}
+
+func migrate040Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.CodeScanningAlert{}, &models.CodeScanningAnalysis{})
+}
+
+func migrate040Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.CodeScanningAnalysis{}, &models.CodeScanningAlert{})
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("041_scheduler_leases", migrate041Up, migrate041Down)
+}
+
+func migrate041Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.SchedulerLease{})
+}
+
+func migrate041Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.SchedulerLease{})
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("042_fine_grained_tokens", migrate042Up, migrate042Down)
+}
+
+func migrate042Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.FineGrainedToken{})
+}
+
+func migrate042Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.FineGrainedToken{})
+}
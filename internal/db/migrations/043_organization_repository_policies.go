@@ -0,0 +1,38 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("043_organization_repository_policies", migrate043Up, migrate043Down)
+}
+
+var organizationRepositoryPolicyColumns = []string{
+	"allow_private_forking",
+	"allow_forks_outside_organization",
+	"visibility_change_policy",
+}
+
+func migrate043Up(db *gorm.DB) error {
+	for _, column := range organizationRepositoryPolicyColumns {
+		if !db.Migrator().HasColumn(&models.OrganizationSettings{}, column) {
+			if err := db.Migrator().AddColumn(&models.OrganizationSettings{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func migrate043Down(db *gorm.DB) error {
+	for _, column := range organizationRepositoryPolicyColumns {
+		if db.Migrator().HasColumn(&models.OrganizationSettings{}, column) {
+			if err := db.Migrator().DropColumn(&models.OrganizationSettings{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
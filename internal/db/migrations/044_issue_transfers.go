@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("044_issue_transfers", migrate044Up, migrate044Down)
+}
+
+func migrate044Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.IssueRedirect{})
+}
+
+func migrate044Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.IssueRedirect{})
+}
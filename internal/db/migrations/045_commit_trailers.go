@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("045_commit_trailers", migrate045Up, migrate045Down)
+}
+
+// Trailers of commits synced before this migration are filled in by the
+// next commit sync of their repository
+func migrate045Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.CommitTrailer{})
+}
+
+func migrate045Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.CommitTrailer{})
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("046_oauth_provider", migrate046Up, migrate046Down)
+}
+
+func migrate046Up(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&models.OAuthApplication{},
+		&models.OAuthAuthorization{},
+		&models.OAuthAuthorizationCode{},
+		&models.OAuthAccessToken{},
+	)
+}
+
+func migrate046Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(
+		&models.OAuthAccessToken{},
+		&models.OAuthAuthorizationCode{},
+		&models.OAuthAuthorization{},
+		&models.OAuthApplication{},
+	)
+}
@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("047_repository_schedules", migrate047Up, migrate047Down)
+}
+
+func migrate047Up(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&models.RepositorySchedule{},
+		&models.RepositoryScheduleRun{},
+	)
+}
+
+func migrate047Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(
+		&models.RepositoryScheduleRun{},
+		&models.RepositorySchedule{},
+	)
+}
@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("048_pull_request_file_cache", migrate048Up, migrate048Down)
+}
+
+var pullRequestFileCacheColumns = []string{
+	"patch_truncated",
+	"head_sha",
+	"base_sha",
+	"position",
+}
+
+func migrate048Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.PullRequestFile{})
+}
+
+func migrate048Down(db *gorm.DB) error {
+	for _, column := range pullRequestFileCacheColumns {
+		if db.Migrator().HasColumn(&models.PullRequestFile{}, column) {
+			if err := db.Migrator().DropColumn(&models.PullRequestFile{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("049_repository_language_snapshots", migrate049Up, migrate049Down)
+}
+
+func migrate049Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.RepositoryLanguageSnapshot{})
+}
+
+func migrate049Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.RepositoryLanguageSnapshot{})
+}
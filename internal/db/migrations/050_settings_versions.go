@@ -0,0 +1,32 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("050_settings_versions", migrate050Up, migrate050Down)
+}
+
+// settingsVersionModels carry a version column for optimistic locking
+var settingsVersionModels = []interface{}{
+	&models.Repository{},
+	&models.Organization{},
+	&models.Team{},
+}
+
+func migrate050Up(db *gorm.DB) error {
+	return db.AutoMigrate(settingsVersionModels...)
+}
+
+func migrate050Down(db *gorm.DB) error {
+	for _, model := range settingsVersionModels {
+		if db.Migrator().HasColumn(model, "version") {
+			if err := db.Migrator().DropColumn(model, "version"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("051_pull_request_metadata", migrate051Up, migrate051Down)
+}
+
+// pullRequestStatColumns are filled in after a pull request is opened
+var pullRequestStatColumns = []string{"mergeable", "mergeable_state", "additions", "deletions", "changed_files"}
+
+// pullRequestJoinTables hold the labels, assignees and review requests
+var pullRequestJoinTables = []string{"pull_request_labels", "pull_request_assignees", "pull_request_review_requests"}
+
+func migrate051Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.PullRequest{})
+}
+
+func migrate051Down(db *gorm.DB) error {
+	for _, table := range pullRequestJoinTables {
+		if err := db.Migrator().DropTable(table); err != nil {
+			return err
+		}
+	}
+	for _, column := range pullRequestStatColumns {
+		if db.Migrator().HasColumn(&models.PullRequest{}, column) {
+			if err := db.Migrator().DropColumn(&models.PullRequest{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
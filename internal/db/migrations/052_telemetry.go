@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("052_telemetry", migrate052Up, migrate052Down)
+}
+
+func migrate052Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.TelemetryInstance{}, &models.TelemetrySubmission{})
+}
+
+func migrate052Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.TelemetrySubmission{}, &models.TelemetryInstance{})
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("053_branch_renames", migrate053Up, migrate053Down)
+}
+
+func migrate053Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.BranchRename{})
+}
+
+func migrate053Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.BranchRename{})
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("054_merge_checklists", migrate054Up, migrate054Down)
+}
+
+func migrate054Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.MergeChecklistItem{}, &models.PullRequestChecklistCheck{}, &models.PullRequestChecklistEvent{})
+}
+
+func migrate054Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.PullRequestChecklistEvent{}, &models.PullRequestChecklistCheck{}, &models.MergeChecklistItem{})
+}
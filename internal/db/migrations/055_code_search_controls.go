@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("055_code_search_controls", migrate055Up, migrate055Down)
+}
+
+var codeSearchSettingsColumns = []string{
+	"code_search_excluded_repositories",
+	"code_search_excluded_paths",
+}
+
+func migrate055Up(db *gorm.DB) error {
+	for _, column := range codeSearchSettingsColumns {
+		if !db.Migrator().HasColumn(&models.OrganizationSettings{}, column) {
+			if err := db.Migrator().AddColumn(&models.OrganizationSettings{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return db.AutoMigrate(&models.CodeIndexStatus{})
+}
+
+func migrate055Down(db *gorm.DB) error {
+	if err := db.Migrator().DropTable(&models.CodeIndexStatus{}); err != nil {
+		return err
+	}
+	for _, column := range codeSearchSettingsColumns {
+		if db.Migrator().HasColumn(&models.OrganizationSettings{}, column) {
+			if err := db.Migrator().DropColumn(&models.OrganizationSettings{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
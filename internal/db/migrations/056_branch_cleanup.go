@@ -0,0 +1,37 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("056_branch_cleanup", migrate056Up, migrate056Down)
+}
+
+var branchCleanupColumns = []string{
+	"auto_delete_merged_branches",
+	"stale_branch_days",
+}
+
+func migrate056Up(db *gorm.DB) error {
+	for _, column := range branchCleanupColumns {
+		if !db.Migrator().HasColumn(&models.Repository{}, column) {
+			if err := db.Migrator().AddColumn(&models.Repository{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func migrate056Down(db *gorm.DB) error {
+	for _, column := range branchCleanupColumns {
+		if db.Migrator().HasColumn(&models.Repository{}, column) {
+			if err := db.Migrator().DropColumn(&models.Repository{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("057_authorization_traces", migrate057Up, migrate057Down)
+}
+
+func migrate057Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.AuthorizationTrace{})
+}
+
+func migrate057Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.AuthorizationTrace{})
+}
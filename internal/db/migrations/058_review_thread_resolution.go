@@ -0,0 +1,50 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("058_review_thread_resolution", migrate058Up, migrate058Down)
+}
+
+var reviewThreadResolutionColumns = []struct {
+	model  interface{}
+	column string
+}{
+	{&models.ReviewComment{}, "resolved"},
+	{&models.ReviewComment{}, "resolved_by_id"},
+	{&models.ReviewComment{}, "resolved_at"},
+	{&models.BranchProtectionRule{}, "require_conversation_resolution"},
+}
+
+func migrate058Up(db *gorm.DB) error {
+	for _, c := range reviewThreadResolutionColumns {
+		if !db.Migrator().HasColumn(c.model, c.column) {
+			if err := db.Migrator().AddColumn(c.model, c.column); err != nil {
+				return err
+			}
+		}
+	}
+	if !db.Migrator().HasIndex(&models.ReviewComment{}, "Resolved") {
+		if err := db.Migrator().CreateIndex(&models.ReviewComment{}, "Resolved"); err != nil {
+			return err
+		}
+	}
+	return db.AutoMigrate(&models.ReviewThreadEvent{})
+}
+
+func migrate058Down(db *gorm.DB) error {
+	if err := db.Migrator().DropTable(&models.ReviewThreadEvent{}); err != nil {
+		return err
+	}
+	for _, c := range reviewThreadResolutionColumns {
+		if db.Migrator().HasColumn(c.model, c.column) {
+			if err := db.Migrator().DropColumn(c.model, c.column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
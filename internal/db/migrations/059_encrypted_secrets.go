@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("059_encrypted_secrets", migrate059Up, migrate059Down)
+}
+
+// Encrypted values outgrow the varchar(255) secret columns
+var encryptedSecretColumns = []struct {
+	model  interface{}
+	column string
+}{
+	{&models.Webhook{}, "secret"},
+	{&models.RepositoryHook{}, "secret"},
+	{&models.User{}, "two_factor_secret"},
+}
+
+func migrate059Up(db *gorm.DB) error {
+	for _, c := range encryptedSecretColumns {
+		if err := db.Migrator().AlterColumn(c.model, c.column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrate059Down(db *gorm.DB) error {
+	// Encrypted values may not fit back into varchar(255), so the columns
+	// are left as text
+	return nil
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("060_lfs_objects", migrate060Up, migrate060Down)
+}
+
+func migrate060Up(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.Repository{}, "lfs_quota_mb") {
+		if err := db.Migrator().AddColumn(&models.Repository{}, "LFSQuotaMB"); err != nil {
+			return err
+		}
+	}
+	return db.AutoMigrate(&models.LFSObject{})
+}
+
+func migrate060Down(db *gorm.DB) error {
+	if err := db.Migrator().DropTable(&models.LFSObject{}); err != nil {
+		return err
+	}
+	if db.Migrator().HasColumn(&models.Repository{}, "lfs_quota_mb") {
+		return db.Migrator().DropColumn(&models.Repository{}, "lfs_quota_mb")
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("061_repository_analytics_monthly", migrate061Up, migrate061Down)
+}
+
+// migrate061Up keys repository analytics snapshots by repository and day,
+// replacing the unique index on the repository alone, and adds the monthly
+// rollup table
+func migrate061Up(db *gorm.DB) error {
+	if db.Migrator().HasIndex(&models.RepositoryAnalytics{}, "idx_repository_analytics_repository_id") {
+		if err := db.Migrator().DropIndex(&models.RepositoryAnalytics{}, "idx_repository_analytics_repository_id"); err != nil {
+			return err
+		}
+	}
+	if !db.Migrator().HasIndex(&models.RepositoryAnalytics{}, "idx_repository_analytics_day") {
+		if err := db.Migrator().CreateIndex(&models.RepositoryAnalytics{}, "idx_repository_analytics_day"); err != nil {
+			return err
+		}
+	}
+	return db.AutoMigrate(&models.RepositoryAnalyticsMonthly{})
+}
+
+func migrate061Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.RepositoryAnalyticsMonthly{})
+}
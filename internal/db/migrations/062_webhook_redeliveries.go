@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("062_webhook_redeliveries", migrate062Up, migrate062Down)
+}
+
+func migrate062Up(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.WebhookDelivery{}, "redelivery") {
+		return db.Migrator().AddColumn(&models.WebhookDelivery{}, "Redelivery")
+	}
+	return nil
+}
+
+func migrate062Down(db *gorm.DB) error {
+	if db.Migrator().HasColumn(&models.WebhookDelivery{}, "redelivery") {
+		return db.Migrator().DropColumn(&models.WebhookDelivery{}, "redelivery")
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("063_organization_webhooks", migrate063Up, migrate063Down)
+}
+
+// migrate063Up lets webhooks belong to an organization instead of a
+// repository
+func migrate063Up(db *gorm.DB) error {
+	if err := db.Migrator().AlterColumn(&models.Webhook{}, "RepositoryID"); err != nil {
+		return err
+	}
+	if !db.Migrator().HasColumn(&models.Webhook{}, "organization_id") {
+		if err := db.Migrator().AddColumn(&models.Webhook{}, "OrganizationID"); err != nil {
+			return err
+		}
+	}
+	if !db.Migrator().HasIndex(&models.Webhook{}, "OrganizationID") {
+		return db.Migrator().CreateIndex(&models.Webhook{}, "OrganizationID")
+	}
+	return nil
+}
+
+func migrate063Down(db *gorm.DB) error {
+	if err := db.Unscoped().Where("organization_id IS NOT NULL").Delete(&models.Webhook{}).Error; err != nil {
+		return err
+	}
+	if db.Migrator().HasColumn(&models.Webhook{}, "organization_id") {
+		if err := db.Migrator().DropColumn(&models.Webhook{}, "organization_id"); err != nil {
+			return err
+		}
+	}
+	return db.Exec("ALTER TABLE webhooks ALTER COLUMN repository_id SET NOT NULL").Error
+}
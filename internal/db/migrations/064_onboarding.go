@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("064_onboarding", migrate064Up, migrate064Down)
+}
+
+func migrate064Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.Onboarding{})
+}
+
+func migrate064Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.Onboarding{})
+}
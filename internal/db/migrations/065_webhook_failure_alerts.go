@@ -0,0 +1,43 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("065_webhook_failure_alerts", migrate065Up, migrate065Down)
+}
+
+var webhookHealthColumns = []struct{ field, column string }{
+	{"ConsecutiveFailures", "consecutive_failures"},
+	{"LastFailureAt", "last_failure_at"},
+	{"DisabledAt", "disabled_at"},
+	{"DisabledReason", "disabled_reason"},
+}
+
+// migrate065Up tracks the failing streak of webhooks and why they were
+// disabled
+func migrate065Up(db *gorm.DB) error {
+	for _, c := range webhookHealthColumns {
+		if db.Migrator().HasColumn(&models.Webhook{}, c.column) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(&models.Webhook{}, c.field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrate065Down(db *gorm.DB) error {
+	for _, c := range webhookHealthColumns {
+		if !db.Migrator().HasColumn(&models.Webhook{}, c.column) {
+			continue
+		}
+		if err := db.Migrator().DropColumn(&models.Webhook{}, c.column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
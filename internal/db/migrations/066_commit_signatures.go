@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("066_commit_signatures", migrate066Up, migrate066Down)
+}
+
+var commitVerificationColumns = []struct{ field, column string }{
+	{"Verified", "verified"},
+	{"VerificationReason", "verification_reason"},
+	{"SignerID", "signer_id"},
+}
+
+// migrate066Up stores the GPG and SSH keys users sign with and the
+// verification of synced commits
+func migrate066Up(db *gorm.DB) error {
+	if err := db.AutoMigrate(&models.GPGKey{}, &models.SSHSigningKey{}); err != nil {
+		return err
+	}
+	for _, c := range commitVerificationColumns {
+		if db.Migrator().HasColumn(&models.Commit{}, c.column) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(&models.Commit{}, c.field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrate066Down(db *gorm.DB) error {
+	for _, c := range commitVerificationColumns {
+		if !db.Migrator().HasColumn(&models.Commit{}, c.column) {
+			continue
+		}
+		if err := db.Migrator().DropColumn(&models.Commit{}, c.column); err != nil {
+			return err
+		}
+	}
+	return db.Migrator().DropTable(&models.SSHSigningKey{}, &models.GPGKey{})
+}
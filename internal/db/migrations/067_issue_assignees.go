@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("067_issue_assignees", migrate067Up, migrate067Down)
+}
+
+// migrate067Up adds the join table of issue assignees
+func migrate067Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.Issue{})
+}
+
+func migrate067Down(db *gorm.DB) error {
+	return db.Migrator().DropTable("issue_assignees")
+}
@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("068_required_status_checks", migrate068Up, migrate068Down)
+}
+
+// migrate068Up stores the repository-level status check patterns
+func migrate068Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.RequiredStatusCheck{})
+}
+
+func migrate068Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.RequiredStatusCheck{})
+}
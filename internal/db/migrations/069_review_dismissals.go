@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("069_review_dismissals", migrate069Up, migrate069Down)
+}
+
+var reviewDismissalColumns = []struct{ field, column string }{
+	{"DismissedByID", "dismissed_by_id"},
+	{"DismissedAt", "dismissed_at"},
+	{"DismissalMessage", "dismissal_message"},
+}
+
+// migrate069Up records who dismissed a review, when and why
+func migrate069Up(db *gorm.DB) error {
+	for _, c := range reviewDismissalColumns {
+		if db.Migrator().HasColumn(&models.Review{}, c.column) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(&models.Review{}, c.field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrate069Down(db *gorm.DB) error {
+	for _, c := range reviewDismissalColumns {
+		if !db.Migrator().HasColumn(&models.Review{}, c.column) {
+			continue
+		}
+		if err := db.Migrator().DropColumn(&models.Review{}, c.column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("070_workflow_runs", migrate070Up, migrate070Down)
+}
+
+// migrate070Up stores workflow runs with their jobs and steps
+func migrate070Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.WorkflowRun{}, &models.WorkflowJob{}, &models.WorkflowStep{})
+}
+
+func migrate070Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.WorkflowStep{}, &models.WorkflowJob{}, &models.WorkflowRun{})
+}
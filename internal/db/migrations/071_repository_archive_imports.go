@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("071_repository_archive_imports", migrate071Up, migrate071Down)
+}
+
+// migrate071Up tracks repositories being created from uploaded archives
+func migrate071Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.RepositoryArchiveImport{})
+}
+
+func migrate071Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.RepositoryArchiveImport{})
+}
@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("072_check_runs", migrate072Up, migrate072Down)
+}
+
+// migrate072Up stores check runs reported by external CI
+func migrate072Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.CheckRun{})
+}
+
+func migrate072Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.CheckRun{})
+}
@@ -0,0 +1,41 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("073_repository_approvals", migrate073Up, migrate073Down)
+}
+
+var repositoryApprovalPolicyColumns = []string{
+	"require_repository_creation_approval",
+	"require_repository_deletion_approval",
+}
+
+// migrate073Up adds the approval policy flags and the requests waiting on them
+func migrate073Up(db *gorm.DB) error {
+	for _, column := range repositoryApprovalPolicyColumns {
+		if !db.Migrator().HasColumn(&models.OrganizationSettings{}, column) {
+			if err := db.Migrator().AddColumn(&models.OrganizationSettings{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return db.AutoMigrate(&models.RepositoryApprovalRequest{})
+}
+
+func migrate073Down(db *gorm.DB) error {
+	if err := db.Migrator().DropTable(&models.RepositoryApprovalRequest{}); err != nil {
+		return err
+	}
+	for _, column := range repositoryApprovalPolicyColumns {
+		if db.Migrator().HasColumn(&models.OrganizationSettings{}, column) {
+			if err := db.Migrator().DropColumn(&models.OrganizationSettings{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("074_releases", migrate074Up, migrate074Down)
+}
+
+// migrate074Up stores releases and the assets attached to them
+func migrate074Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.Release{}, &models.ReleaseAsset{})
+}
+
+func migrate074Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.ReleaseAsset{}, &models.Release{})
+}
@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("075_required_signed_commits", migrate075Up, migrate075Down)
+}
+
+// migrate075Up lets branch protection require verified commit signatures
+func migrate075Up(db *gorm.DB) error {
+	if db.Migrator().HasColumn(&models.BranchProtectionRule{}, "require_signed_commits") {
+		return nil
+	}
+	return db.Migrator().AddColumn(&models.BranchProtectionRule{}, "require_signed_commits")
+}
+
+func migrate075Down(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.BranchProtectionRule{}, "require_signed_commits") {
+		return nil
+	}
+	return db.Migrator().DropColumn(&models.BranchProtectionRule{}, "require_signed_commits")
+}
@@ -0,0 +1,34 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("076_container_registry", migrate076Up, migrate076Down)
+}
+
+// migrate076Up stores the images, blobs, manifests and tags of the OCI
+// registry, and the blob uploads in progress
+func migrate076Up(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&models.ContainerImage{},
+		&models.ContainerBlob{},
+		&models.ContainerManifest{},
+		&models.ContainerManifestReference{},
+		&models.ContainerTag{},
+		&models.ContainerUpload{},
+	)
+}
+
+func migrate076Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(
+		&models.ContainerUpload{},
+		&models.ContainerTag{},
+		&models.ContainerManifestReference{},
+		&models.ContainerManifest{},
+		&models.ContainerBlob{},
+		&models.ContainerImage{},
+	)
+}
@@ -0,0 +1,39 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("077_webhook_payload_formats", migrate077Up, migrate077Down)
+}
+
+var webhookPayloadColumns = []string{
+	"payload_version",
+	"payload_template",
+}
+
+// migrate077Up pins webhooks to a payload version, existing ones to the
+// first, and lets them transform payloads with a template
+func migrate077Up(db *gorm.DB) error {
+	for _, column := range webhookPayloadColumns {
+		if !db.Migrator().HasColumn(&models.Webhook{}, column) {
+			if err := db.Migrator().AddColumn(&models.Webhook{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func migrate077Down(db *gorm.DB) error {
+	for _, column := range webhookPayloadColumns {
+		if db.Migrator().HasColumn(&models.Webhook{}, column) {
+			if err := db.Migrator().DropColumn(&models.Webhook{}, column); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("078_packages", migrate078Up, migrate078Down)
+}
+
+// migrate078Up stores the npm, Maven and generic packages of organizations
+// with their versions, files and retention policies
+func migrate078Up(db *gorm.DB) error {
+	return db.AutoMigrate(
+		&models.Package{},
+		&models.PackageVersion{},
+		&models.PackageFile{},
+		&models.PackageRetentionPolicy{},
+	)
+}
+
+func migrate078Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(
+		&models.PackageRetentionPolicy{},
+		&models.PackageFile{},
+		&models.PackageVersion{},
+		&models.Package{},
+	)
+}
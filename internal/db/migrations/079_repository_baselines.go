@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("079_repository_baselines", migrate079Up, migrate079Down)
+}
+
+// migrate079Up stores the repository settings baseline of organizations
+func migrate079Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.RepositoryBaseline{})
+}
+
+func migrate079Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.RepositoryBaseline{})
+}
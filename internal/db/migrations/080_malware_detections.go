@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("080_malware_detections", migrate080Up, migrate080Down)
+}
+
+// migrate080Up stores uploads flagged by malware scans and their review
+func migrate080Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.MalwareDetection{})
+}
+
+func migrate080Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.MalwareDetection{})
+}
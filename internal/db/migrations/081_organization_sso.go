@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("081_organization_sso", migrate081Up, migrate081Down)
+}
+
+// migrate081Up stores organization identity provider connections, linked
+// identities with their SSO sessions, and pending logins
+func migrate081Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.OrganizationSSOConfig{}, &models.OrganizationSSOIdentity{}, &models.SSOLoginState{})
+}
+
+func migrate081Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.SSOLoginState{}, &models.OrganizationSSOIdentity{}, &models.OrganizationSSOConfig{})
+}
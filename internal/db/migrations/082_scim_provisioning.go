@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("082_scim_provisioning", migrate082Up, migrate082Down)
+}
+
+// migrate082Up stores SCIM provisioning tokens and the users and groups
+// identity providers provision into organizations
+func migrate082Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.SCIMToken{}, &models.SCIMUser{}, &models.SCIMGroup{})
+}
+
+func migrate082Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.SCIMGroup{}, &models.SCIMUser{}, &models.SCIMToken{})
+}
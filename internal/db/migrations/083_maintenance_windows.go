@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("083_maintenance_windows", migrate083Up, migrate083Down)
+}
+
+// migrate083Up stores maintenance windows, the per job class policies that
+// refer to them and the heavy jobs they hold back
+func migrate083Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.MaintenanceWindow{}, &models.JobClassPolicy{}, &models.BackgroundJob{})
+}
+
+func migrate083Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.BackgroundJob{}, &models.JobClassPolicy{}, &models.MaintenanceWindow{})
+}
@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("085_repository_feature_previews", migrate085Up, migrate085Down)
+}
+
+// migrate085Up stores the feature previews repositories switched on
+func migrate085Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.RepositoryFeaturePreview{})
+}
+
+func migrate085Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.RepositoryFeaturePreview{})
+}
@@ -0,0 +1,49 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/auth"
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("086_mfa_enrollment", migrate086Up, migrate086Down)
+}
+
+// migrate086Up adds pending TOTP secrets, WebAuthn credentials and their
+// ceremony sessions, and hashes recovery codes stored in plain text
+func migrate086Up(db *gorm.DB) error {
+	if err := db.AutoMigrate(&models.User{}, &auth.WebAuthnCredential{}, &auth.WebAuthnSession{}); err != nil {
+		return err
+	}
+
+	type backupCode struct {
+		ID   string
+		Code string
+	}
+	var codes []backupCode
+	if err := db.Table("backup_codes").Select("id, code").Where("LENGTH(code) < ?", sha256.Size*2).Scan(&codes).Error; err != nil {
+		return err
+	}
+	for _, code := range codes {
+		sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(code.Code))))
+		if err := db.Table("backup_codes").Where("id = ?", code.ID).Update("code", hex.EncodeToString(sum[:])).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func migrate086Down(db *gorm.DB) error {
+	if err := db.Migrator().DropTable(&auth.WebAuthnSession{}, &auth.WebAuthnCredential{}); err != nil {
+		return err
+	}
+	if db.Migrator().HasColumn(&models.User{}, "two_factor_pending_secret") {
+		return db.Migrator().DropColumn(&models.User{}, "two_factor_pending_secret")
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("087_audit_entries", migrate087Up, migrate087Down)
+}
+
+// migrate087Up stores the hash-chained audit trail and its stream position
+func migrate087Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.AuditEntry{}, &models.AuditStreamState{})
+}
+
+func migrate087Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.AuditStreamState{}, &models.AuditEntry{})
+}
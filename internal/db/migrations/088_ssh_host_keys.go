@@ -0,0 +1,19 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("088_ssh_host_keys", migrate088Up, migrate088Down)
+}
+
+// migrate088Up stores the SSH host keys shared by every SSH server replica
+func migrate088Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.SSHHostKey{})
+}
+
+func migrate088Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.SSHHostKey{})
+}
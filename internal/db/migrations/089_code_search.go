@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("089_code_search", migrate089Up, migrate089Down)
+}
+
+// migrate089Up stores the built-in full-text code search index and the
+// indexed commit of every repository
+func migrate089Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.CodeSearchDocument{}, &models.CodeSearchIndexStatus{})
+}
+
+func migrate089Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.CodeSearchIndexStatus{}, &models.CodeSearchDocument{})
+}
@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("090_change_management", migrate090Up, migrate090Down)
+}
+
+// migrate090Up adds repository change management policies and the change
+// tickets pull requests are linked to
+func migrate090Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.ChangeManagementPolicy{}, &models.PullRequestChangeTicket{})
+}
+
+func migrate090Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.PullRequestChangeTicket{}, &models.ChangeManagementPolicy{})
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("091_notifications", migrate091Up, migrate091Down)
+}
+
+// migrate091Up adds notification threads, custom repository watches and
+// the notification email frequency
+func migrate091Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.Notification{}, &models.RepositoryWatch{}, &models.UserPreferences{})
+}
+
+func migrate091Down(db *gorm.DB) error {
+	if err := db.Migrator().DropColumn(&models.UserPreferences{}, "notification_email_frequency"); err != nil {
+		return err
+	}
+	if err := db.Migrator().DropColumn(&models.RepositoryWatch{}, "events"); err != nil {
+		return err
+	}
+	return db.Migrator().DropTable(&models.Notification{})
+}
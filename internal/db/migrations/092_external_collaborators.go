@@ -0,0 +1,24 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("092_external_collaborators", migrate092Up, migrate092Down)
+}
+
+func migrate092Up(db *gorm.DB) error {
+	if db.Migrator().HasColumn(&models.User{}, "is_external") {
+		return nil
+	}
+	if err := db.Migrator().AddColumn(&models.User{}, "IsExternal"); err != nil {
+		return err
+	}
+	return db.Migrator().CreateIndex(&models.User{}, "IsExternal")
+}
+
+func migrate092Down(db *gorm.DB) error {
+	return db.Migrator().DropColumn(&models.User{}, "is_external")
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("093_queued_jobs", migrate093Up, migrate093Down)
+}
+
+func migrate093Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.QueuedJob{})
+}
+
+func migrate093Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.QueuedJob{})
+}
@@ -0,0 +1,27 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("094_scheduled_tasks", migrate094Up, migrate094Down)
+}
+
+func migrate094Up(db *gorm.DB) error {
+	if err := db.AutoMigrate(&models.ScheduledTask{}); err != nil {
+		return err
+	}
+	if db.Migrator().HasColumn(&models.FineGrainedToken{}, "expiry_notified_at") {
+		return nil
+	}
+	return db.Migrator().AddColumn(&models.FineGrainedToken{}, "ExpiryNotifiedAt")
+}
+
+func migrate094Down(db *gorm.DB) error {
+	if err := db.Migrator().DropColumn(&models.FineGrainedToken{}, "expiry_notified_at"); err != nil {
+		return err
+	}
+	return db.Migrator().DropTable(&models.ScheduledTask{})
+}
@@ -0,0 +1,18 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("095_repository_replicas", migrate095Up, migrate095Down)
+}
+
+func migrate095Up(db *gorm.DB) error {
+	return db.AutoMigrate(&models.RepositoryReplica{})
+}
+
+func migrate095Down(db *gorm.DB) error {
+	return db.Migrator().DropTable(&models.RepositoryReplica{})
+}
@@ -0,0 +1,56 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("096_repository_import_metadata", migrate096Up, migrate096Down)
+}
+
+var repositoryImportColumns = []struct{ field, column string }{
+	{"UserID", "user_id"},
+	{"Name", "name"},
+	{"Stage", "stage"},
+	{"LabelsImported", "labels_imported"},
+	{"MilestonesImported", "milestones_imported"},
+	{"IssuesImported", "issues_imported"},
+	{"PullRequestsImported", "pull_requests_imported"},
+	{"ReleasesImported", "releases_imported"},
+}
+
+// migrate096Up lets repository imports exist before their repository and
+// record what was imported besides the git history
+func migrate096Up(db *gorm.DB) error {
+	if err := db.Migrator().AlterColumn(&models.RepositoryImport{}, "RepositoryID"); err != nil {
+		return err
+	}
+	for _, c := range repositoryImportColumns {
+		if db.Migrator().HasColumn(&models.RepositoryImport{}, c.column) {
+			continue
+		}
+		if err := db.Migrator().AddColumn(&models.RepositoryImport{}, c.field); err != nil {
+			return err
+		}
+	}
+	if !db.Migrator().HasIndex(&models.RepositoryImport{}, "UserID") {
+		return db.Migrator().CreateIndex(&models.RepositoryImport{}, "UserID")
+	}
+	return nil
+}
+
+func migrate096Down(db *gorm.DB) error {
+	if err := db.Unscoped().Where("repository_id IS NULL").Delete(&models.RepositoryImport{}).Error; err != nil {
+		return err
+	}
+	for _, c := range repositoryImportColumns {
+		if !db.Migrator().HasColumn(&models.RepositoryImport{}, c.column) {
+			continue
+		}
+		if err := db.Migrator().DropColumn(&models.RepositoryImport{}, c.column); err != nil {
+			return err
+		}
+	}
+	return db.Exec("ALTER TABLE repository_imports ALTER COLUMN repository_id SET NOT NULL").Error
+}
@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+func init() {
+	registerMigration("097_fine_grained_token_scopes", migrate097Up, migrate097Down)
+}
+
+// migrate097Up lets personal access tokens carry scopes instead of
+// selected repositories, replacing the separate personal_access_tokens
+// table of earlier development builds
+func migrate097Up(db *gorm.DB) error {
+	if err := db.Migrator().DropTable("personal_access_tokens"); err != nil {
+		return err
+	}
+	if db.Migrator().HasColumn(&models.FineGrainedToken{}, "scopes") {
+		return nil
+	}
+	return db.Migrator().AddColumn(&models.FineGrainedToken{}, "Scopes")
+}
+
+func migrate097Down(db *gorm.DB) error {
+	if err := db.Where("scopes IS NOT NULL AND scopes NOT IN ?", []string{"", "null", "[]"}).Delete(&models.FineGrainedToken{}).Error; err != nil {
+		return err
+	}
+	return db.Migrator().DropColumn(&models.FineGrainedToken{}, "scopes")
+}
@@ -0,0 +1,248 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/a5c-ai/hub/internal/config"
+)
+
+// prefix marks values written by a Keyring; values without it are legacy
+// plaintext stored before encryption was enabled
+const prefix = "enc:v1:"
+
+const keySize = 32
+
+var (
+	// ErrNotConfigured is returned when an encrypted value is read without a
+	// master key
+	ErrNotConfigured = errors.New("secret encryption is not configured")
+	// ErrUnknownKey is returned when a value was encrypted with a master key
+	// that is neither current nor listed in the previous keys
+	ErrUnknownKey = errors.New("value was encrypted with an unknown master key")
+	// ErrIntegrity is returned when an encrypted value is malformed, was
+	// tampered with, or was copied from another column
+	ErrIntegrity = errors.New("encrypted value failed integrity verification")
+)
+
+// Keyring encrypts secrets with envelope encryption: every value gets a
+// fresh data key, the value is sealed with the data key and the data key is
+// sealed with the current master key. Both use AES-256-GCM, so any change to
+// a stored value is detected when it is decrypted. Values are bound to the
+// context they were encrypted for, normally "table.column".
+type Keyring struct {
+	currentID string
+	keys      map[string]cipher.AEAD
+}
+
+// NewKeyring creates a Keyring from cfg; it returns nil when encryption is
+// disabled. A nil Keyring stores values in plaintext.
+func NewKeyring(cfg config.Encryption) (*Keyring, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.KeyID == "" || strings.Contains(cfg.KeyID, ":") {
+		return nil, fmt.Errorf("invalid encryption key id %q", cfg.KeyID)
+	}
+
+	encoded := cfg.MasterKey
+	if cfg.MasterKeyFile != "" {
+		data, err := os.ReadFile(cfg.MasterKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master key file: %w", err)
+		}
+		encoded = string(data)
+	}
+	if encoded == "" {
+		return nil, errors.New("encryption is enabled without a master key")
+	}
+
+	k := &Keyring{currentID: cfg.KeyID, keys: make(map[string]cipher.AEAD)}
+	if err := k.addKey(cfg.KeyID, encoded); err != nil {
+		return nil, err
+	}
+	for id, key := range cfg.PreviousKeys {
+		if id == cfg.KeyID {
+			continue
+		}
+		if err := k.addKey(id, key); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+func (k *Keyring) addKey(id, encoded string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return fmt.Errorf("master key %q is not valid base64: %w", id, err)
+	}
+	if len(key) != keySize {
+		return fmt.Errorf("master key %q must be %d bytes, got %d", id, keySize, len(key))
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+	k.keys[id] = aead
+	return nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// CurrentKeyID returns the ID of the master key new values are encrypted with
+func (k *Keyring) CurrentKeyID() string {
+	if k == nil {
+		return ""
+	}
+	return k.currentID
+}
+
+// Encrypt seals plaintext for context. Empty values are kept empty and a nil
+// Keyring returns plaintext unchanged.
+func (k *Keyring) Encrypt(plaintext, context string) (string, error) {
+	if k == nil || plaintext == "" {
+		return plaintext, nil
+	}
+
+	dataKey := make([]byte, keySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	master := k.keys[k.currentID]
+	wrapped, err := seal(master, dataKey, []byte(k.currentID+"|"+context))
+	if err != nil {
+		return "", err
+	}
+	sealed, err := seal(dataAEAD, []byte(plaintext), []byte(context))
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	return prefix + k.currentID + ":" + enc.EncodeToString(wrapped) + ":" + enc.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value sealed for context, verifying its integrity.
+// Plaintext values stored before encryption was enabled are returned
+// unchanged.
+func (k *Keyring) Decrypt(value, context string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if k == nil {
+		return "", ErrNotConfigured
+	}
+
+	parts := strings.Split(strings.TrimPrefix(value, prefix), ":")
+	if len(parts) != 3 {
+		return "", ErrIntegrity
+	}
+	master, ok := k.keys[parts[0]]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownKey, parts[0])
+	}
+
+	enc := base64.RawURLEncoding
+	wrapped, err := enc.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrIntegrity
+	}
+	sealed, err := enc.DecodeString(parts[2])
+	if err != nil {
+		return "", ErrIntegrity
+	}
+
+	dataKey, err := open(master, wrapped, []byte(parts[0]+"|"+context))
+	if err != nil {
+		return "", ErrIntegrity
+	}
+	dataAEAD, err := newAEAD(dataKey)
+	if err != nil {
+		return "", ErrIntegrity
+	}
+	plaintext, err := open(dataAEAD, sealed, []byte(context))
+	if err != nil {
+		return "", ErrIntegrity
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether value is plaintext or was encrypted with a
+// master key other than the current one
+func (k *Keyring) NeedsRotation(value string) bool {
+	if k == nil || value == "" {
+		return false
+	}
+	return KeyID(value) != k.currentID
+}
+
+// IsEncrypted reports whether value was written by a Keyring
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, prefix)
+}
+
+// KeyID returns the ID of the master key value was encrypted with, or ""
+// for plaintext
+func KeyID(value string) string {
+	if !IsEncrypted(value) {
+		return ""
+	}
+	id, _, _ := strings.Cut(strings.TrimPrefix(value, prefix), ":")
+	return id
+}
+
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrIntegrity
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultKeyring *Keyring
+)
+
+// SetDefault sets the process wide Keyring used by the encrypted gorm
+// serializer
+func SetDefault(k *Keyring) {
+	defaultMu.Lock()
+	defaultKeyring = k
+	defaultMu.Unlock()
+}
+
+// Default returns the process wide Keyring, which is nil unless encryption
+// is enabled; a nil Keyring is safe to use
+func Default() *Keyring {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultKeyring
+}
@@ -0,0 +1,108 @@
+package encryption
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), keySize)))
+}
+
+func TestKeyring(t *testing.T) {
+	keyring, err := NewKeyring(config.Encryption{Enabled: true, KeyID: "k1", MasterKey: testKey('a')})
+	require.NoError(t, err)
+
+	sealed, err := keyring.Encrypt("hook-secret", "webhooks.secret")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(sealed))
+	assert.Equal(t, "k1", KeyID(sealed))
+	assert.NotContains(t, sealed, "hook-secret")
+
+	again, err := keyring.Encrypt("hook-secret", "webhooks.secret")
+	require.NoError(t, err)
+	assert.NotEqual(t, sealed, again, "every value gets its own data key and nonce")
+
+	plaintext, err := keyring.Decrypt(sealed, "webhooks.secret")
+	require.NoError(t, err)
+	assert.Equal(t, "hook-secret", plaintext)
+
+	t.Run("values are bound to their column", func(t *testing.T) {
+		_, err := keyring.Decrypt(sealed, "users.two_factor_secret")
+		assert.ErrorIs(t, err, ErrIntegrity)
+	})
+
+	t.Run("tampering is detected", func(t *testing.T) {
+		tampered := sealed[:len(sealed)-2] + "AA"
+		if tampered == sealed {
+			tampered = sealed[:len(sealed)-2] + "BB"
+		}
+		_, err := keyring.Decrypt(tampered, "webhooks.secret")
+		assert.ErrorIs(t, err, ErrIntegrity)
+
+		_, err = keyring.Decrypt(prefix+"k1:garbage", "webhooks.secret")
+		assert.ErrorIs(t, err, ErrIntegrity)
+	})
+
+	t.Run("plaintext passes through", func(t *testing.T) {
+		plaintext, err := keyring.Decrypt("legacy", "webhooks.secret")
+		require.NoError(t, err)
+		assert.Equal(t, "legacy", plaintext)
+		assert.True(t, keyring.NeedsRotation("legacy"))
+		assert.False(t, keyring.NeedsRotation(sealed))
+
+		empty, err := keyring.Encrypt("", "webhooks.secret")
+		require.NoError(t, err)
+		assert.Empty(t, empty)
+	})
+
+	t.Run("rotation keeps old values readable", func(t *testing.T) {
+		rotated, err := NewKeyring(config.Encryption{
+			Enabled:      true,
+			KeyID:        "k2",
+			MasterKey:    testKey('b'),
+			PreviousKeys: map[string]string{"k1": testKey('a')},
+		})
+		require.NoError(t, err)
+		assert.True(t, rotated.NeedsRotation(sealed))
+
+		plaintext, err := rotated.Decrypt(sealed, "webhooks.secret")
+		require.NoError(t, err)
+		assert.Equal(t, "hook-secret", plaintext)
+
+		withoutOld, err := NewKeyring(config.Encryption{Enabled: true, KeyID: "k2", MasterKey: testKey('b')})
+		require.NoError(t, err)
+		_, err = withoutOld.Decrypt(sealed, "webhooks.secret")
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("nil keyring", func(t *testing.T) {
+		var disabled *Keyring
+		value, err := disabled.Encrypt("hook-secret", "webhooks.secret")
+		require.NoError(t, err)
+		assert.Equal(t, "hook-secret", value)
+
+		_, err = disabled.Decrypt(sealed, "webhooks.secret")
+		assert.ErrorIs(t, err, ErrNotConfigured)
+	})
+}
+
+func TestNewKeyring(t *testing.T) {
+	keyring, err := NewKeyring(config.Encryption{})
+	require.NoError(t, err)
+	assert.Nil(t, keyring)
+
+	_, err = NewKeyring(config.Encryption{Enabled: true, KeyID: "k1"})
+	assert.Error(t, err)
+
+	_, err = NewKeyring(config.Encryption{Enabled: true, KeyID: "k1", MasterKey: base64.StdEncoding.EncodeToString([]byte("short"))})
+	assert.Error(t, err)
+
+	_, err = NewKeyring(config.Encryption{Enabled: true, KeyID: "bad:id", MasterKey: testKey('a')})
+	assert.Error(t, err)
+}
@@ -0,0 +1,62 @@
+package encryption
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+)
+
+// SerializerName is the gorm serializer encrypting string columns with the
+// default Keyring, used as `gorm:"serializer:encrypted"`
+const SerializerName = "encrypted"
+
+func init() {
+	schema.RegisterSerializer(SerializerName, Serializer{})
+}
+
+// Serializer encrypts a string field when it is written and decrypts and
+// verifies it when it is read. Values are bound to their table and column.
+// Updates made with a map bypass serializers; use Seal for those values.
+type Serializer struct{}
+
+// Context returns the context values of table.column are encrypted for
+func Context(table, column string) string {
+	return table + "." + column
+}
+
+// Seal encrypts value for table.column with the default Keyring
+func Seal(table, column, value string) (string, error) {
+	return Default().Encrypt(value, Context(table, column))
+}
+
+// Scan implements schema.SerializerInterface
+func (Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+		return nil
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("unsupported value %T for encrypted field %s", dbValue, field.Name)
+	}
+
+	plaintext, err := Default().Decrypt(stored, Context(field.Schema.Table, field.DBName))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s.%s: %w", field.Schema.Table, field.DBName, err)
+	}
+	return field.Set(ctx, dst, plaintext)
+}
+
+// Value implements schema.SerializerInterface
+func (Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	value, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("encrypted field %s must be a string", field.Name)
+	}
+	return Default().Encrypt(value, Context(field.Schema.Table, field.DBName))
+}
@@ -0,0 +1,334 @@
+// Package errorreporting captures panics and server errors from HTTP
+// handlers, SSH sessions and background jobs and forwards them to a
+// Sentry-compatible error tracker.
+package errorreporting
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Level is the severity of an event
+type Level string
+
+const (
+	LevelError Level = "error"
+	LevelFatal Level = "fatal"
+)
+
+// Event is a single error report, shaped after the Sentry event payload
+type Event struct {
+	EventID     string                 `json:"event_id"`
+	Timestamp   time.Time              `json:"timestamp"`
+	Level       Level                  `json:"level"`
+	Platform    string                 `json:"platform"`
+	Logger      string                 `json:"logger,omitempty"`
+	Message     string                 `json:"message,omitempty"`
+	Environment string                 `json:"environment,omitempty"`
+	Release     string                 `json:"release,omitempty"`
+	ServerName  string                 `json:"server_name,omitempty"`
+	Exception   []Exception            `json:"exception,omitempty"`
+	Request     *Request               `json:"request,omitempty"`
+	User        *User                  `json:"user,omitempty"`
+	Tags        map[string]string      `json:"tags,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+}
+
+// Exception describes an error or recovered panic and where it happened
+type Exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *Stacktrace `json:"stacktrace,omitempty"`
+}
+
+// Stacktrace lists frames oldest call first, as Sentry expects
+type Stacktrace struct {
+	Frames []Frame `json:"frames"`
+}
+
+type Frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// Request is the HTTP request an event occurred in
+type Request struct {
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	QueryString string            `json:"query_string,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+}
+
+// User identifies who triggered an event
+type User struct {
+	ID        string `json:"id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	IPAddress string `json:"ip_address,omitempty"`
+}
+
+// Client delivers events to an error tracker
+type Client interface {
+	Send(event *Event)
+	// Flush waits up to timeout for queued events to be delivered and
+	// reports whether the queue drained
+	Flush(timeout time.Duration) bool
+}
+
+// Reporter builds events and hands them to a Client. A nil or disabled
+// Reporter only logs.
+type Reporter struct {
+	client      Client
+	environment string
+	release     string
+	serverName  string
+	sampleRate  float64
+	logger      *logrus.Logger
+}
+
+// NewReporter creates a Reporter from configuration. Reporting is disabled
+// unless it is enabled and a DSN is set.
+func NewReporter(cfg config.ErrorReporting, logger *logrus.Logger) (*Reporter, error) {
+	r := &Reporter{
+		environment: cfg.Environment,
+		release:     cfg.Release,
+		sampleRate:  cfg.SampleRate,
+		logger:      logger,
+	}
+	r.serverName, _ = os.Hostname()
+	if !cfg.Enabled || cfg.DSN == "" {
+		return r, nil
+	}
+
+	client, err := NewSentryClient(cfg.DSN, time.Duration(cfg.TimeoutSeconds)*time.Second, logger)
+	if err != nil {
+		return nil, err
+	}
+	r.client = client
+	return r, nil
+}
+
+// NewReporterWithClient creates a Reporter sending every event to client
+func NewReporterWithClient(client Client, logger *logrus.Logger) *Reporter {
+	return &Reporter{client: client, sampleRate: 1, logger: logger}
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultReporter *Reporter
+)
+
+// SetDefault sets the process wide Reporter used by Default
+func SetDefault(r *Reporter) {
+	defaultMu.Lock()
+	defaultReporter = r
+	defaultMu.Unlock()
+}
+
+// Default returns the process wide Reporter, which is nil until SetDefault
+// is called; a nil Reporter is safe to use
+func Default() *Reporter {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultReporter
+}
+
+// Enabled reports whether events are sent anywhere
+func (r *Reporter) Enabled() bool {
+	return r != nil && r.client != nil
+}
+
+// NewEvent creates an event for err with a stack trace of the caller,
+// skipping skip frames above it
+func (r *Reporter) NewEvent(err error, skip int) *Event {
+	return r.newEvent(fmt.Sprintf("%T", err), err.Error(), skip+1)
+}
+
+// NewPanicEvent creates an event for a value recovered from a panic; it
+// must be called from the deferred function that recovered it
+func (r *Reporter) NewPanicEvent(recovered interface{}) *Event {
+	value := fmt.Sprint(recovered)
+	kind := "panic"
+	if err, ok := recovered.(error); ok {
+		kind = fmt.Sprintf("%T", err)
+		value = err.Error()
+	}
+	// Skip this function and the deferred function; runtime.gopanic is
+	// dropped from the stack below
+	event := r.newEvent(kind, value, 2)
+	event.Level = LevelFatal
+	return event
+}
+
+func (r *Reporter) newEvent(kind, value string, skip int) *Event {
+	event := &Event{
+		EventID:   strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Timestamp: time.Now().UTC(),
+		Level:     LevelError,
+		Platform:  "go",
+		Exception: []Exception{{Type: kind, Value: value, Stacktrace: stacktrace(skip + 2)}},
+		Tags:      map[string]string{},
+	}
+	if r != nil {
+		event.Environment = r.environment
+		event.Release = r.release
+		event.ServerName = r.serverName
+	}
+	return event
+}
+
+// Capture logs an event and sends it to the error tracker, subject to the
+// configured sample rate
+func (r *Reporter) Capture(event *Event) {
+	if r == nil {
+		logrus.WithFields(eventFields(event)).Error(event.summary())
+		return
+	}
+	if r.logger != nil {
+		r.logger.WithFields(eventFields(event)).Error(event.summary())
+	}
+	if r.client == nil {
+		return
+	}
+	if r.sampleRate > 0 && r.sampleRate < 1 && rand.Float64() >= r.sampleRate {
+		return
+	}
+	r.client.Send(event)
+}
+
+// CaptureError reports err with the caller's stack trace and the given tags
+func (r *Reporter) CaptureError(err error, tags map[string]string) {
+	if err == nil {
+		return
+	}
+	event := r.NewEvent(err, 1)
+	for k, v := range tags {
+		event.Tags[k] = v
+	}
+	r.Capture(event)
+}
+
+// Recover reports a panic in a goroutine that is not serving a request and
+// stops it from crashing the process. It must be deferred directly:
+//
+//	defer errorreporting.Default().Recover("digest_scheduler", nil)
+func (r *Reporter) Recover(component string, tags map[string]string) {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	event := r.NewPanicEvent(recovered)
+	event.Logger = component
+	event.Tags["component"] = component
+	for k, v := range tags {
+		event.Tags[k] = v
+	}
+	r.Capture(event)
+}
+
+// Flush waits up to timeout for pending events to be delivered
+func (r *Reporter) Flush(timeout time.Duration) bool {
+	if !r.Enabled() {
+		return true
+	}
+	return r.client.Flush(timeout)
+}
+
+func (e *Event) summary() string {
+	if len(e.Exception) > 0 {
+		return e.Exception[0].Type + ": " + e.Exception[0].Value
+	}
+	return e.Message
+}
+
+func eventFields(e *Event) logrus.Fields {
+	fields := logrus.Fields{"event_id": e.EventID, "level": e.Level}
+	for k, v := range e.Tags {
+		fields[k] = v
+	}
+	if e.User != nil && e.User.ID != "" {
+		fields["user_id"] = e.User.ID
+	}
+	if e.Request != nil {
+		fields["method"] = e.Request.Method
+		fields["url"] = e.Request.URL
+	}
+	if e.Level == LevelFatal && len(e.Exception) > 0 && e.Exception[0].Stacktrace != nil {
+		fields["stack"] = e.Exception[0].Stacktrace.String()
+	}
+	return fields
+}
+
+// stacktrace captures the current goroutine's stack, skipping skip frames,
+// with runtime frames dropped
+func stacktrace(skip int) *Stacktrace {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var out []Frame
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			module, function := splitFunction(frame.Function)
+			out = append(out, Frame{
+				Function: function,
+				Module:   module,
+				Filename: shortFilename(frame.File),
+				AbsPath:  frame.File,
+				Lineno:   frame.Line,
+				InApp:    strings.HasPrefix(module, "github.com/a5c-ai/hub"),
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	// Sentry lists the outermost call first
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return &Stacktrace{Frames: out}
+}
+
+// String renders the stack innermost call first, like a Go panic trace
+func (s *Stacktrace) String() string {
+	var b strings.Builder
+	for i := len(s.Frames) - 1; i >= 0; i-- {
+		f := s.Frames[i]
+		fmt.Fprintf(&b, "%s.%s\n\t%s:%d\n", f.Module, f.Function, f.AbsPath, f.Lineno)
+	}
+	return b.String()
+}
+
+// splitFunction splits "github.com/a/b/pkg.(*T).Method" into its package
+// path and function name
+func splitFunction(name string) (string, string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+2+dot:]
+}
+
+func shortFilename(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		if j := strings.LastIndex(path[:i], "/"); j >= 0 {
+			return path[j+1:]
+		}
+	}
+	return path
+}
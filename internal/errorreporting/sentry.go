@@ -0,0 +1,121 @@
+package errorreporting
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sentryQueueSize bounds the events waiting for delivery; events reported
+// while the queue is full are dropped rather than blocking the caller
+const sentryQueueSize = 100
+
+// sentryClient posts events to the store endpoint of a Sentry-compatible
+// server (Sentry, GlitchTip, ...) from a background goroutine
+type sentryClient struct {
+	endpoint   string
+	auth       string
+	httpClient *http.Client
+	logger     *logrus.Logger
+
+	queue   chan *Event
+	pending sync.WaitGroup
+}
+
+// NewSentryClient creates a Client for a DSN of the form
+// https://<key>@<host>[/<path>]/<project_id>
+func NewSentryClient(dsn string, timeout time.Duration, logger *logrus.Logger) (Client, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid error reporting DSN: %w", err)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return nil, fmt.Errorf("invalid error reporting DSN: missing public key")
+	}
+	path := strings.TrimSuffix(u.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	projectID := path[slash+1:]
+	if projectID == "" {
+		return nil, fmt.Errorf("invalid error reporting DSN: missing project ID")
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	auth := "Sentry sentry_version=7, sentry_client=hub/1.0, sentry_key=" + u.User.Username()
+	if secret, ok := u.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+	c := &sentryClient{
+		endpoint:   fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, path[:slash], projectID),
+		auth:       auth,
+		httpClient: &http.Client{Timeout: timeout},
+		logger:     logger,
+		queue:      make(chan *Event, sentryQueueSize),
+	}
+	go c.run()
+	return c, nil
+}
+
+func (c *sentryClient) Send(event *Event) {
+	c.pending.Add(1)
+	select {
+	case c.queue <- event:
+	default:
+		c.pending.Done()
+		c.logger.WithField("event_id", event.EventID).Warn("Error reporting queue full, dropping event")
+	}
+}
+
+func (c *sentryClient) Flush(timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		c.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (c *sentryClient) run() {
+	for event := range c.queue {
+		if err := c.post(event); err != nil {
+			c.logger.WithError(err).WithField("event_id", event.EventID).Warn("Failed to deliver error report")
+		}
+		c.pending.Done()
+	}
+}
+
+func (c *sentryClient) post(event *Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", c.auth)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error tracker responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package errorreporting
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSentryClientPostsToStoreEndpoint(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	var event Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		received <- r
+	}))
+	defer server.Close()
+
+	dsn := strings.Replace(server.URL, "http://", "http://public@", 1) + "/sentry/7"
+	client, err := NewSentryClient(dsn, time.Second, logrus.New())
+	require.NoError(t, err)
+
+	reporter := NewReporterWithClient(client, logrus.New())
+	reporter.CaptureError(errors.New("disk full"), map[string]string{"component": "test"})
+	require.True(t, reporter.Flush(time.Second))
+
+	req := <-received
+	assert.Equal(t, "/sentry/api/7/store/", req.URL.Path)
+	assert.Contains(t, req.Header.Get("X-Sentry-Auth"), "sentry_key=public")
+	assert.Equal(t, "disk full", event.Exception[0].Value)
+	assert.Equal(t, "test", event.Tags["component"])
+	frames := event.Exception[0].Stacktrace.Frames
+	require.NotEmpty(t, frames)
+	assert.Equal(t, "TestSentryClientPostsToStoreEndpoint", frames[len(frames)-1].Function)
+
+	_, err = NewSentryClient("https://sentry.example.com/7", time.Second, logrus.New())
+	assert.Error(t, err)
+}
+
+func TestRecoverReportsPanics(t *testing.T) {
+	client := &countingClient{}
+	reporter := NewReporterWithClient(client, logrus.New())
+
+	func() {
+		defer reporter.Recover("job", map[string]string{"job_id": "1"})
+		panic(errors.New("nil map"))
+	}()
+
+	require.Len(t, client.events, 1)
+	assert.Equal(t, LevelFatal, client.events[0].Level)
+	assert.Equal(t, "job", client.events[0].Tags["component"])
+	assert.Equal(t, "1", client.events[0].Tags["job_id"])
+
+	// A nil Reporter still recovers
+	var none *Reporter
+	func() {
+		defer none.Recover("job", nil)
+		panic("unreported")
+	}()
+}
+
+type countingClient struct {
+	events []*Event
+}
+
+func (c *countingClient) Send(event *Event)                { c.events = append(c.events, event) }
+func (c *countingClient) Flush(timeout time.Duration) bool { return true }
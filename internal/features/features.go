@@ -0,0 +1,74 @@
+package features
+
+import (
+	"context"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// Feature previews repository admins can switch on for their repositories
+const (
+	MergeQueue   = "merge_queue"
+	DiffEngineV2 = "diff_engine_v2"
+)
+
+// Preview describes a beta feature offered per repository
+type Preview struct {
+	Key         string `json:"key"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Previews lists every feature preview
+var Previews = []Preview{
+	{Key: MergeQueue, Name: "Merge queue", Description: "Queue approved pull requests and merge them in order once their checks pass"},
+	{Key: DiffEngineV2, Name: "New diff engine", Description: "Compute and render diffs with the next diff engine"},
+}
+
+// Lookup returns the preview with key
+func Lookup(key string) (Preview, bool) {
+	for _, preview := range Previews {
+		if preview.Key == key {
+			return preview, true
+		}
+	}
+	return Preview{}, false
+}
+
+// Checker reports whether a repository has a feature preview switched on
+type Checker interface {
+	Enabled(ctx context.Context, repoID uuid.UUID, feature string) bool
+}
+
+type disabledChecker struct{}
+
+func (disabledChecker) Enabled(ctx context.Context, repoID uuid.UUID, feature string) bool {
+	return false
+}
+
+var (
+	checkerMu      sync.RWMutex
+	defaultChecker Checker = disabledChecker{}
+)
+
+// SetDefaultChecker sets the process wide Checker behind Enabled; nil
+// switches every preview off
+func SetDefaultChecker(c Checker) {
+	if c == nil {
+		c = disabledChecker{}
+	}
+	checkerMu.Lock()
+	defaultChecker = c
+	checkerMu.Unlock()
+}
+
+// Enabled reports whether the repository has the feature preview switched
+// on. Handlers and services call it to pick between beta and stable code
+// paths.
+func Enabled(ctx context.Context, repoID uuid.UUID, feature string) bool {
+	checkerMu.RLock()
+	c := defaultChecker
+	checkerMu.RUnlock()
+	return c.Enabled(ctx, repoID, feature)
+}
@@ -0,0 +1,50 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// ArchiveFormat is the format of a source archive
+type ArchiveFormat string
+
+const (
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	ArchiveZip   ArchiveFormat = "zip"
+)
+
+// Archive writes the tree of ref to w as an archive in format, with every
+// path under prefix. The archive is streamed by git archive as it is
+// produced, so it is never held in memory.
+func (s *gitService) Archive(ctx context.Context, repoPath, ref string, format ArchiveFormat, prefix string, w io.Writer) error {
+	if format != ArchiveTarGz && format != ArchiveZip {
+		return fmt.Errorf("unsupported archive format %q", format)
+	}
+	sha, err := s.ResolveSHA(ctx, repoPath, ref)
+	if err != nil {
+		return err
+	}
+	if err := s.packs.Ensure(ctx, repoPath); err != nil {
+		return fmt.Errorf("failed to restore offloaded packs: %w", err)
+	}
+
+	args := []string{"archive", "--format=" + string(format)}
+	if prefix != "" {
+		args = append(args, "--prefix="+strings.TrimSuffix(prefix, "/")+"/")
+	}
+	args = append(args, sha)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = repoPath
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git archive failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
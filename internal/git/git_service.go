@@ -1,6 +1,7 @@
 package git
 
 import (
+	"container/heap"
 	"context"
 	"encoding/base64"
 	"errors"
@@ -30,16 +31,29 @@ var (
 	ErrTagNotFound         = errors.New("tag not found")
 	ErrFileNotFound        = errors.New("file not found")
 	ErrPathNotFound        = errors.New("path not found")
+	// ErrRefChanged is returned when a branch moved while a file change was
+	// being written, so the change was not applied
+	ErrRefChanged = errors.New("branch was updated by another request")
 )
 
 // gitService implements the GitService interface using go-git
 type gitService struct {
+	// packs restores offloaded packs before repositories are read; nil
+	// when pack offloading is disabled
+	packs  *PackStore
 	logger *logrus.Logger
 }
 
 // NewGitService creates a new Git service instance
 func NewGitService(logger *logrus.Logger) GitService {
+	return NewGitServiceWithPackStore(nil, logger)
+}
+
+// NewGitServiceWithPackStore creates a Git service that restores packs
+// offloaded to packs before reading repositories
+func NewGitServiceWithPackStore(packs *PackStore, logger *logrus.Logger) GitService {
 	return &gitService{
+		packs:  packs,
 		logger: logger,
 	}
 }
@@ -194,6 +208,30 @@ func (s *gitService) GetCommit(ctx context.Context, repoPath, sha string) (*Comm
 	return s.convertCommit(commit), nil
 }
 
+// LookupCommits reads several commits by SHA from one open repository
+func (s *gitService) LookupCommits(ctx context.Context, repoPath string, shas []string) (map[string]*Commit, error) {
+	repo, err := s.openRepository(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make(map[string]*Commit, len(shas))
+	for _, sha := range shas {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, ok := commits[sha]; ok {
+			continue
+		}
+		commit, err := repo.CommitObject(plumbing.NewHash(sha))
+		if err != nil {
+			continue
+		}
+		commits[sha] = s.convertCommit(commit)
+	}
+	return commits, nil
+}
+
 // GetBranches retrieves all branches from a repository
 func (s *gitService) GetBranches(ctx context.Context, repoPath string) ([]*Branch, error) {
 	repo, err := s.openRepository(repoPath)
@@ -323,6 +361,52 @@ func (s *gitService) DeleteBranch(ctx context.Context, repoPath, branchName stri
 	return nil
 }
 
+// ValidBranchName reports whether name can be used as a branch name
+func ValidBranchName(name string) bool {
+	return name != "" && plumbing.NewBranchReferenceName(name).Validate() == nil
+}
+
+// ValidTagName reports whether name can be used as a tag name
+func ValidTagName(name string) bool {
+	return name != "" && plumbing.NewTagReferenceName(name).Validate() == nil
+}
+
+// RenameBranch renames a branch. The new name must not be taken.
+func (s *gitService) RenameBranch(ctx context.Context, repoPath, oldName, newName string) error {
+	repo, err := s.openRepository(repoPath)
+	if err != nil {
+		return err
+	}
+
+	oldRef := plumbing.NewBranchReferenceName(oldName)
+	newRef := plumbing.NewBranchReferenceName(newName)
+	if !ValidBranchName(newName) {
+		return fmt.Errorf("invalid branch name %s", newName)
+	}
+
+	current, err := repo.Storer.Reference(oldRef)
+	if err != nil {
+		return fmt.Errorf("failed to get branch %s: %w", oldName, err)
+	}
+	if _, err := repo.Storer.Reference(newRef); err == nil {
+		return fmt.Errorf("branch %s already exists", newName)
+	}
+
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(newRef, current.Hash())); err != nil {
+		return fmt.Errorf("failed to create branch %s: %w", newName, err)
+	}
+	if head, err := repo.Storer.Reference(plumbing.HEAD); err == nil &&
+		head.Type() == plumbing.SymbolicReference && head.Target() == oldRef {
+		if err := repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, newRef)); err != nil {
+			return fmt.Errorf("failed to move HEAD to %s: %w", newName, err)
+		}
+	}
+	if err := repo.Storer.RemoveReference(oldRef); err != nil {
+		return fmt.Errorf("failed to delete branch %s: %w", oldName, err)
+	}
+	return nil
+}
+
 // GetTags retrieves all tags from a repository
 func (s *gitService) GetTags(ctx context.Context, repoPath string) ([]*Tag, error) {
 	repo, err := s.openRepository(repoPath)
@@ -352,6 +436,7 @@ func (s *gitService) GetTags(ctx context.Context, repoPath string) ([]*Tag, erro
 				Email: tagObj.Tagger.Email,
 				Date:  tagObj.Tagger.When,
 			}
+			tag.Signature = s.signatureOf(tagObj, tagObj.PGPSignature)
 		}
 
 		tags = append(tags, tag)
@@ -396,6 +481,7 @@ func (s *gitService) GetTag(ctx context.Context, repoPath, tagName string) (*Tag
 			Email: tagObj.Tagger.Email,
 			Date:  tagObj.Tagger.When,
 		}
+		tag.Signature = s.signatureOf(tagObj, tagObj.PGPSignature)
 	}
 
 	return tag, nil
@@ -490,7 +576,20 @@ func (s *gitService) GetFile(ctx context.Context, repoPath, ref, path string) (*
 // Helper methods
 
 func (s *gitService) openRepository(repoPath string) (*git.Repository, error) {
-	repo, err := git.PlainOpen(repoPath)
+	open := git.PlainOpen
+	if hasAlternates(repoPath) {
+		open = openWithAlternates
+	}
+	if s.packs != nil {
+		if offloaded, _ := s.packs.Offloaded(repoPath); len(offloaded) > 0 {
+			if err := s.packs.Ensure(context.Background(), repoPath); err != nil {
+				return nil, fmt.Errorf("failed to restore offloaded packs: %w", err)
+			}
+			open = openWithAlternates
+		}
+	}
+
+	repo, err := open(repoPath)
 	if err != nil {
 		s.logger.WithError(err).WithField("path", repoPath).Error("Failed to open repository")
 		if os.IsNotExist(err) {
@@ -511,9 +610,10 @@ func (s *gitService) resolveReference(repo *git.Repository, ref string) (plumbin
 		return head.Hash(), nil
 	}
 
-	// Try to parse as hash first
-	if hash := plumbing.NewHash(ref); !hash.IsZero() {
-		return hash, nil
+	// Try to parse as a full hash first; branch names like "feature" are
+	// partially hex and must not be decoded as hashes
+	if plumbing.IsHash(ref) {
+		return plumbing.NewHash(ref), nil
 	}
 
 	// Try to resolve as reference
@@ -554,11 +654,23 @@ func (s *gitService) convertCommit(c *object.Commit) *Commit {
 			Email: c.Committer.Email,
 			Date:  c.Committer.When,
 		},
-		Parents: parents,
-		Tree:    c.TreeHash.String(),
+		Parents:   parents,
+		Tree:      c.TreeHash.String(),
+		Trailers:  ParseTrailers(c.Message),
+		Signature: s.signatureOf(c, c.PGPSignature),
 	}
 }
 
+// signatureOf returns the signature of a commit or tag object, logging
+// rather than failing when the signed payload cannot be rebuilt
+func (s *gitService) signatureOf(obj signedObject, armored string) *Signature {
+	sig, err := extractSignature(obj, armored)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to read object signature")
+	}
+	return sig
+}
+
 // Placeholder implementations for methods that need more complex logic
 
 func (s *gitService) GetCommitDiff(ctx context.Context, repoPath, fromSHA, toSHA string) (*Diff, error) {
@@ -771,7 +883,7 @@ func (s *gitService) CreateFile(ctx context.Context, repoPath string, req Create
 	workTree, err := repo.Worktree()
 	if err != nil {
 		// For bare repositories, we need to work directly with the object database
-		return s.createFileInBareRepo(ctx, repo, req)
+		return s.createFileInBareRepo(ctx, repo, repoPath, req)
 	}
 
 	// Ensure working on requested branch
@@ -843,7 +955,7 @@ func (s *gitService) UpdateFile(ctx context.Context, repoPath string, req Update
 	workTree, err := repo.Worktree()
 	if err != nil {
 		// For bare repositories, we need different handling
-		return s.updateFileInBareRepo(ctx, repo, req)
+		return s.updateFileInBareRepo(ctx, repo, repoPath, req)
 	}
 
 	// Ensure working on requested branch
@@ -928,7 +1040,7 @@ func (s *gitService) DeleteFile(ctx context.Context, repoPath string, req Delete
 	workTree, err := repo.Worktree()
 	if err != nil {
 		// For bare repositories, we need different handling
-		return s.deleteFileInBareRepo(ctx, repo, req)
+		return s.deleteFileInBareRepo(ctx, repo, repoPath, req)
 	}
 
 	// Ensure working on requested branch
@@ -1429,6 +1541,161 @@ func (s *gitService) ResolveSHA(ctx context.Context, repoPath, ref string) (stri
 	return hash.String(), nil
 }
 
+// GetAheadBehind computes ahead/behind counts for each head relative to base.
+// Each head is walked together with base only until their histories meet,
+// so the cost follows how far a branch has diverged rather than the length
+// of the repository's history.
+func (s *gitService) GetAheadBehind(ctx context.Context, repoPath, base string, heads []string) (map[string]*AheadBehind, error) {
+	repo, err := s.openRepository(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	baseHash, err := s.resolveReference(repo, base)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve base reference %s: %w", base, err)
+	}
+
+	results := make(map[string]*AheadBehind, len(heads))
+	for _, head := range heads {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		headHash, err := s.resolveReference(repo, head)
+		if err != nil {
+			// Skip refs that disappeared since they were listed
+			continue
+		}
+		counts, err := divergence(ctx, repo, baseHash, headHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk history of %s: %w", head, err)
+		}
+		results[head] = counts
+	}
+
+	return results, nil
+}
+
+// Sides of a divergence walk a commit is reachable from
+const (
+	fromBase uint8 = 1 << iota
+	fromHead
+	fromBoth = fromBase | fromHead
+)
+
+// divergence counts the commits reachable from only one of base and head.
+// Like git's merge-base, both histories are walked together newest first
+// and the walk stops once every commit left to visit is reachable from both
+// sides and older than anything counted, so shared history is not walked.
+func divergence(ctx context.Context, repo *git.Repository, base, head plumbing.Hash) (*AheadBehind, error) {
+	counts := &AheadBehind{}
+	if base == head {
+		return counts, nil
+	}
+
+	sides := make(map[plumbing.Hash]uint8)
+	counted := make(map[plumbing.Hash]uint8)
+	queued := make(map[plumbing.Hash]bool)
+	queue := &commitQueue{}
+	// unsettled counts the queued commits reachable from one side only
+	unsettled := 0
+	// oldest is the commit time of the oldest commit counted on one side;
+	// a commit reachable from both sides that is newer may still be its
+	// descendant and correct its count
+	var oldest time.Time
+
+	mark := func(hash plumbing.Hash, side uint8) error {
+		was := sides[hash]
+		if was|side == was {
+			return nil
+		}
+		sides[hash] = was | side
+		if queued[hash] {
+			if sides[hash] == fromBoth {
+				unsettled--
+			}
+			return nil
+		}
+		commit, err := repo.CommitObject(hash)
+		if err != nil {
+			return err
+		}
+		heap.Push(queue, commit)
+		queued[hash] = true
+		if sides[hash] != fromBoth {
+			unsettled++
+		}
+		return nil
+	}
+	if err := mark(base, fromBase); err != nil {
+		return nil, err
+	}
+	if err := mark(head, fromHead); err != nil {
+		return nil, err
+	}
+
+	for queue.Len() > 0 {
+		if unsettled == 0 && (oldest.IsZero() || (*queue)[0].Committer.When.Before(oldest)) {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		commit := heap.Pop(queue).(*object.Commit)
+		delete(queued, commit.Hash)
+		side := sides[commit.Hash]
+		if side != fromBoth {
+			unsettled--
+		}
+
+		// A commit is seen again when a side reaches it after it was
+		// counted for the other one
+		switch counted[commit.Hash] {
+		case fromBase:
+			counts.BehindBy--
+		case fromHead:
+			counts.AheadBy--
+		}
+		switch side {
+		case fromBase:
+			counts.BehindBy++
+		case fromHead:
+			counts.AheadBy++
+		}
+		counted[commit.Hash] = side
+		if side != fromBoth && (oldest.IsZero() || commit.Committer.When.Before(oldest)) {
+			oldest = commit.Committer.When
+		}
+
+		for _, parent := range commit.ParentHashes {
+			if err := mark(parent, side); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return counts, nil
+}
+
+// commitQueue orders commits newest first by commit time
+type commitQueue []*object.Commit
+
+func (q commitQueue) Len() int { return len(q) }
+
+func (q commitQueue) Less(i, j int) bool { return q[i].Committer.When.After(q[j].Committer.When) }
+
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *commitQueue) Push(x any) { *q = append(*q, x.(*object.Commit)) }
+
+func (q *commitQueue) Pop() any {
+	old := *q
+	commit := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return commit
+}
+
 // Helper methods for pull request operations
 
 func (s *gitService) getCommitsBetween(repo *git.Repository, base, head *object.Commit) ([]*Commit, error) {
@@ -1570,3 +1837,44 @@ func (s *gitService) isAncestor(repo *git.Repository, ancestor, descendant *obje
 
 	return false, err
 }
+
+// WalkFiles calls fn for every file at ref whose size is at most maxSize
+// bytes (0 means unlimited). Binary files are skipped.
+func (s *gitService) WalkFiles(ctx context.Context, repoPath, ref string, maxSize int64, fn func(path string, content []byte) error) error {
+	repo, err := s.openRepository(repoPath)
+	if err != nil {
+		return err
+	}
+
+	hash, err := s.resolveReference(repo, ref)
+	if err != nil {
+		return err
+	}
+
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return fmt.Errorf("failed to get commit: %w", err)
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return fmt.Errorf("failed to get tree: %w", err)
+	}
+
+	return tree.Files().ForEach(func(file *object.File) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if maxSize > 0 && file.Size > maxSize {
+			return nil
+		}
+		if binary, err := file.IsBinary(); err != nil || binary {
+			return nil
+		}
+		content, err := file.Contents()
+		if err != nil {
+			return nil
+		}
+		return fn(file.Name, []byte(content))
+	})
+}
@@ -0,0 +1,106 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func commitFile(t *testing.T, wt *git.Worktree, dir, name string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+	_, err := wt.Add(name)
+	require.NoError(t, err)
+	_, err = wt.Commit("add "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+}
+
+func TestGetAheadBehind(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+
+	commitFile(t, wt, dir, "a.txt")
+	head, err := repo.Head()
+	require.NoError(t, err)
+	base := head.Name().Short()
+
+	// feature: 2 commits ahead of the fork point
+	require.NoError(t, wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}))
+	commitFile(t, wt, dir, "b.txt")
+	commitFile(t, wt, dir, "c.txt")
+
+	// base: 1 commit after the fork point
+	require.NoError(t, wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(base)}))
+	commitFile(t, wt, dir, "d.txt")
+
+	svc := NewGitService(logrus.New())
+	counts, err := svc.GetAheadBehind(context.Background(), dir, base, []string{"feature", base, "missing"})
+	require.NoError(t, err)
+
+	require.Equal(t, &AheadBehind{AheadBy: 2, BehindBy: 1}, counts["feature"])
+	require.Equal(t, &AheadBehind{}, counts[base])
+	require.NotContains(t, counts, "missing")
+}
+
+func TestGetAheadBehindAcrossMerges(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+
+	// Every commit shares one timestamp so the walk cannot rely on dates
+	when := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	commit := func(name string, parents ...plumbing.Hash) plumbing.Hash {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(name), 0644))
+		_, err := wt.Add(name)
+		require.NoError(t, err)
+		sig := &object.Signature{Name: "test", Email: "test@example.com", When: when}
+		hash, err := wt.Commit("add "+name, &git.CommitOptions{Author: sig, Committer: sig, Parents: parents})
+		require.NoError(t, err)
+		return hash
+	}
+
+	for i := 0; i < 20; i++ {
+		commit(fmt.Sprintf("shared-%d.txt", i))
+	}
+	head, err := repo.Head()
+	require.NoError(t, err)
+	base := head.Name().Short()
+
+	require.NoError(t, wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature"), Create: true}))
+	commit("f1.txt")
+	f2 := commit("f2.txt")
+
+	require.NoError(t, wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(base)}))
+	m1 := commit("m1.txt")
+
+	// feature merges the base branch in, then the base branch moves on
+	require.NoError(t, wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("feature")}))
+	commit("merge.txt", f2, m1)
+	require.NoError(t, wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName(base)}))
+	commit("m2.txt")
+
+	svc := NewGitService(logrus.New())
+	counts, err := svc.GetAheadBehind(context.Background(), dir, base, []string{"feature"})
+	require.NoError(t, err)
+	require.Equal(t, &AheadBehind{AheadBy: 3, BehindBy: 1}, counts["feature"])
+
+	counts, err = svc.GetAheadBehind(context.Background(), dir, "feature", []string{base})
+	require.NoError(t, err)
+	require.Equal(t, &AheadBehind{AheadBy: 1, BehindBy: 3}, counts[base])
+}
@@ -0,0 +1,84 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	_, err := git.PlainInit(dir, true)
+	require.NoError(t, err)
+
+	svc := NewGitService(logrus.New())
+	ctx := context.Background()
+	commit, err := svc.CommitFiles(ctx, dir, CommitFilesRequest{
+		Branch:  "main",
+		Message: "initial",
+		Author:  CommitAuthor{Name: "test", Email: "test@example.com"},
+		Files: []CommitFileEntry{
+			{Path: "README.md", Content: []byte("hello")},
+			{Path: "cmd/main.go", Content: []byte("package main")},
+		},
+	})
+	require.NoError(t, err)
+	want := map[string]string{"app/README.md": "hello", "app/cmd/main.go": "package main"}
+
+	t.Run("tar.gz", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, svc.Archive(ctx, dir, "main", ArchiveTarGz, "app", &buf))
+
+		gz, err := gzip.NewReader(&buf)
+		require.NoError(t, err)
+		files := map[string]string{}
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			require.NoError(t, err)
+			if hdr.Typeflag == tar.TypeReg {
+				content, err := io.ReadAll(tr)
+				require.NoError(t, err)
+				files[hdr.Name] = string(content)
+			}
+		}
+		assert.Equal(t, want, files)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, svc.Archive(ctx, dir, commit.SHA, ArchiveZip, "app/", &buf))
+
+		zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		require.NoError(t, err)
+		files := map[string]string{}
+		for _, f := range zr.File {
+			if f.FileInfo().IsDir() {
+				continue
+			}
+			rc, err := f.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(rc)
+			rc.Close()
+			require.NoError(t, err)
+			files[f.Name] = string(content)
+		}
+		assert.Equal(t, want, files)
+	})
+
+	t.Run("unknown ref", func(t *testing.T) {
+		assert.Error(t, svc.Archive(ctx, dir, "missing", ArchiveTarGz, "app", io.Discard))
+	})
+}
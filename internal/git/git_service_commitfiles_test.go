@@ -0,0 +1,61 @@
+package git
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitFiles(t *testing.T) {
+	dir := t.TempDir()
+	_, err := git.PlainInit(dir, true)
+	require.NoError(t, err)
+
+	svc := NewGitService(logrus.New())
+	ctx := context.Background()
+	author := CommitAuthor{Name: "test", Email: "test@example.com"}
+
+	// First commit creates the branch as a root commit
+	first, err := svc.CommitFiles(ctx, dir, CommitFilesRequest{
+		Branch:  "main",
+		Message: "initial upload",
+		Author:  author,
+		Files: []CommitFileEntry{
+			{Path: "README.md", Content: []byte("hello")},
+			{Path: "assets/img/logo.png", Content: []byte{0x89, 'P', 'N', 'G', 0x00}},
+		},
+	})
+	require.NoError(t, err)
+	require.Empty(t, first.Parents)
+
+	second, err := svc.CommitFiles(ctx, dir, CommitFilesRequest{
+		Branch:  "main",
+		Message: "second upload",
+		Author:  author,
+		Files: []CommitFileEntry{
+			{Path: "assets/img/icon.png", Content: []byte("icon")},
+			{Path: "README.md", Content: []byte("updated")},
+			{Path: "docs/guide.md", Reader: strings.NewReader("streamed")},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{first.SHA}, second.Parents)
+
+	files := map[string]string{}
+	require.NoError(t, svc.WalkFiles(ctx, dir, "main", 0, func(path string, content []byte) error {
+		files[path] = string(content)
+		return nil
+	}))
+	require.Equal(t, map[string]string{
+		"README.md":           "updated",
+		"assets/img/icon.png": "icon",
+		"docs/guide.md":       "streamed",
+	}, files) // logo.png is binary and skipped by WalkFiles
+
+	_, err = svc.CommitFiles(ctx, dir, CommitFilesRequest{Branch: "main", Message: "empty"})
+	require.Error(t, err)
+}
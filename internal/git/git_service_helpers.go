@@ -1,9 +1,12 @@
 package git
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"fmt"
+	"io"
+	"os/exec"
 	"sort"
 	"strings"
 	"time"
@@ -16,20 +19,23 @@ import (
 
 // Helper methods for bare repository operations
 
-func (s *gitService) createFileInBareRepo(ctx context.Context, repo *git.Repository, req CreateFileRequest) (*Commit, error) {
-	return s.modifyFileInBareRepo(ctx, repo, req.Path, req.Content, req.Encoding, req.Message, req.Branch, req.Author, req.Committer, false)
+func (s *gitService) createFileInBareRepo(ctx context.Context, repo *git.Repository, repoPath string, req CreateFileRequest) (*Commit, error) {
+	return s.modifyFileInBareRepo(ctx, repo, repoPath, req.Path, req.Content, req.Encoding, req.Message, req.Branch, "", req.Author, req.Committer, false)
 }
 
-func (s *gitService) updateFileInBareRepo(ctx context.Context, repo *git.Repository, req UpdateFileRequest) (*Commit, error) {
-	return s.modifyFileInBareRepo(ctx, repo, req.Path, req.Content, req.Encoding, req.Message, req.Branch, req.Author, req.Committer, true)
+func (s *gitService) updateFileInBareRepo(ctx context.Context, repo *git.Repository, repoPath string, req UpdateFileRequest) (*Commit, error) {
+	return s.modifyFileInBareRepo(ctx, repo, repoPath, req.Path, req.Content, req.Encoding, req.Message, req.Branch, req.SHA, req.Author, req.Committer, true)
 }
 
-func (s *gitService) deleteFileInBareRepo(ctx context.Context, repo *git.Repository, req DeleteFileRequest) (*Commit, error) {
-	return s.modifyFileInBareRepo(ctx, repo, req.Path, "", "", req.Message, req.Branch, req.Author, req.Committer, true)
+func (s *gitService) deleteFileInBareRepo(ctx context.Context, repo *git.Repository, repoPath string, req DeleteFileRequest) (*Commit, error) {
+	return s.modifyFileInBareRepo(ctx, repo, repoPath, req.Path, "", "", req.Message, req.Branch, "", req.Author, req.Committer, true)
 }
 
-// modifyFileInBareRepo handles create, update, and delete operations for files in bare repositories
-func (s *gitService) modifyFileInBareRepo(ctx context.Context, repo *git.Repository, path, content, encoding, message, branchName string, author, committer CommitAuthor, isUpdate bool) (*Commit, error) {
+// modifyFileInBareRepo handles create, update, and delete operations for files in bare repositories.
+// When fileSHA is set the file must still have that blob. The branch is
+// moved only if nothing else moved it meanwhile; otherwise ErrRefChanged is
+// returned.
+func (s *gitService) modifyFileInBareRepo(ctx context.Context, repo *git.Repository, repoPath, path, content, encoding, message, branchName, fileSHA string, author, committer CommitAuthor, isUpdate bool) (*Commit, error) {
 	// Get the branch reference
 	branchRef := fmt.Sprintf("refs/heads/%s", branchName)
 	ref, err := repo.Reference(plumbing.ReferenceName(branchRef), true)
@@ -49,6 +55,12 @@ func (s *gitService) modifyFileInBareRepo(ctx context.Context, repo *git.Reposit
 		return nil, fmt.Errorf("failed to get current tree: %w", err)
 	}
 
+	if fileSHA != "" {
+		if current, err := currentTree.File(path); err != nil || current.Hash.String() != fileSHA {
+			return nil, ErrRefChanged
+		}
+	}
+
 	// Prepare content
 	var fileContent []byte
 	if content != "" {
@@ -107,10 +119,9 @@ func (s *gitService) modifyFileInBareRepo(ctx context.Context, repo *git.Reposit
 		return nil, fmt.Errorf("failed to store commit: %w", err)
 	}
 
-	// Update the branch reference
-	newRef := plumbing.NewHashReference(plumbing.ReferenceName(branchRef), commitHash)
-	if err := repo.Storer.SetReference(newRef); err != nil {
-		return nil, fmt.Errorf("failed to update branch reference: %w", err)
+	// Update the branch reference, unless it moved since it was read
+	if err := updateRef(ctx, repoPath, branchRef, commitHash, currentCommit.Hash); err != nil {
+		return nil, err
 	}
 
 	// Return the commit information
@@ -127,20 +138,87 @@ func (s *gitService) modifyFileInBareRepo(ctx context.Context, repo *git.Reposit
 			Email: committer.Email,
 			Date:  committer.Date,
 		},
-		Parents: []string{currentCommit.Hash.String()},
+		Parents:  []string{currentCommit.Hash.String()},
+		Trailers: ParseTrailers(message),
 	}, nil
 }
 
 // updateTreeWithFile creates a new tree with the specified file added, updated, or removed
 func (s *gitService) updateTreeWithFile(repo *git.Repository, baseTree *object.Tree, filePath string, content []byte, delete bool) (plumbing.Hash, error) {
+	var blob *plumbing.Hash
+	if !delete && content != nil {
+		hash, err := storeBlob(repo, content)
+		if err != nil {
+			return plumbing.Hash{}, err
+		}
+		blob = &hash
+	}
+	return s.updateTreeWithBlob(repo, baseTree, filePath, blob)
+}
+
+// updateTreeWithBlob points filePath at blob, or removes it when blob is nil
+func (s *gitService) updateTreeWithBlob(repo *git.Repository, baseTree *object.Tree, filePath string, blob *plumbing.Hash) (plumbing.Hash, error) {
 	// Split the path into directory components
 	pathParts := strings.Split(strings.Trim(filePath, "/"), "/")
 
-	return s.updateTreeRecursive(repo, baseTree, pathParts, content, delete)
+	return s.updateTreeRecursive(repo, baseTree, pathParts, blob)
 }
 
-// updateTreeRecursive recursively updates tree objects to modify a file at the given path
-func (s *gitService) updateTreeRecursive(repo *git.Repository, tree *object.Tree, pathParts []string, content []byte, delete bool) (plumbing.Hash, error) {
+// storeBlob writes content to the object store
+func storeBlob(repo *git.Repository, content []byte) (plumbing.Hash, error) {
+	blob := repo.Storer.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	writer, err := blob.Writer()
+	if err != nil {
+		return plumbing.Hash{}, fmt.Errorf("failed to create blob writer: %w", err)
+	}
+
+	if _, err := writer.Write(content); err != nil {
+		writer.Close()
+		return plumbing.Hash{}, fmt.Errorf("failed to write blob content: %w", err)
+	}
+	writer.Close()
+
+	blobHash, err := repo.Storer.SetEncodedObject(blob)
+	if err != nil {
+		return plumbing.Hash{}, fmt.Errorf("failed to store blob: %w", err)
+	}
+	return blobHash, nil
+}
+
+// updateRef points ref at newHash if it still points at oldHash, failing
+// with ErrRefChanged otherwise
+func updateRef(ctx context.Context, repoPath, ref string, newHash, oldHash plumbing.Hash) error {
+	cmd := exec.CommandContext(ctx, "git", "update-ref", ref, newHash.String(), oldHash.String())
+	cmd.Dir = repoPath
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == nil && strings.Contains(stderr.String(), "cannot lock ref") {
+			return ErrRefChanged
+		}
+		return fmt.Errorf("failed to update branch reference: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// streamBlob writes the content read from r to the object store of the
+// repository at repoPath without holding it in memory
+func streamBlob(ctx context.Context, repoPath string, r io.Reader) (plumbing.Hash, error) {
+	cmd := exec.CommandContext(ctx, "git", "hash-object", "-w", "--stdin")
+	cmd.Dir = repoPath
+	cmd.Stdin = r
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return plumbing.Hash{}, fmt.Errorf("git hash-object failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return plumbing.NewHash(strings.TrimSpace(string(out))), nil
+}
+
+// updateTreeRecursive recursively updates tree objects to point a file at blob, removing it when blob is nil
+func (s *gitService) updateTreeRecursive(repo *git.Repository, tree *object.Tree, pathParts []string, blob *plumbing.Hash) (plumbing.Hash, error) {
 	if len(pathParts) == 0 {
 		return tree.Hash, nil
 	}
@@ -160,34 +238,14 @@ func (s *gitService) updateTreeRecursive(repo *git.Repository, tree *object.Tree
 
 	if isLastPart {
 		// This is the file we want to modify
-		if !delete && content != nil {
-			// Create a blob for the file content
-			blob := repo.Storer.NewEncodedObject()
-			blob.SetType(plumbing.BlobObject)
-			writer, err := blob.Writer()
-			if err != nil {
-				return plumbing.Hash{}, fmt.Errorf("failed to create blob writer: %w", err)
-			}
-
-			if _, err := writer.Write(content); err != nil {
-				writer.Close()
-				return plumbing.Hash{}, fmt.Errorf("failed to write blob content: %w", err)
-			}
-			writer.Close()
-
-			blobHash, err := repo.Storer.SetEncodedObject(blob)
-			if err != nil {
-				return plumbing.Hash{}, fmt.Errorf("failed to store blob: %w", err)
-			}
-
-			// Add the new file entry
+		if blob != nil {
 			entryMap[fileName] = object.TreeEntry{
 				Name: fileName,
 				Mode: filemode.Regular,
-				Hash: blobHash,
+				Hash: *blob,
 			}
 		}
-		// If delete == true, we simply don't add the entry (effectively deleting it)
+		// Without a blob we simply don't add the entry (effectively deleting it)
 	} else {
 		// This is a directory, we need to recurse
 		var subTree *object.Tree
@@ -210,7 +268,7 @@ func (s *gitService) updateTreeRecursive(repo *git.Repository, tree *object.Tree
 		}
 
 		// Recursively update the subtree
-		newSubTreeHash, err := s.updateTreeRecursive(repo, subTree, pathParts[1:], content, delete)
+		newSubTreeHash, err := s.updateTreeRecursive(repo, subTree, pathParts[1:], blob)
 		if err != nil {
 			return plumbing.Hash{}, fmt.Errorf("failed to update subtree: %w", err)
 		}
@@ -282,3 +340,90 @@ func gitTreeEntryLess(a, b object.TreeEntry) bool {
 	// Git uses byte-wise comparison
 	return nameA < nameB
 }
+
+// CommitFiles writes every file of req into one commit on req.Branch. It
+// operates on the object database directly, so it works for bare and
+// non-bare repositories alike.
+func (s *gitService) CommitFiles(ctx context.Context, repoPath string, req CommitFilesRequest) (*Commit, error) {
+	if len(req.Files) == 0 {
+		return nil, fmt.Errorf("no files to commit")
+	}
+
+	repo, err := s.openRepository(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	branchRef := plumbing.NewBranchReferenceName(req.Branch)
+	tree := &object.Tree{}
+	var parents []plumbing.Hash
+	if ref, err := repo.Reference(branchRef, true); err == nil {
+		parent, err := repo.CommitObject(ref.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current commit: %w", err)
+		}
+		if tree, err = parent.Tree(); err != nil {
+			return nil, fmt.Errorf("failed to get current tree: %w", err)
+		}
+		parents = append(parents, parent.Hash)
+	} else if err != plumbing.ErrReferenceNotFound {
+		return nil, fmt.Errorf("failed to get branch reference %s: %w", req.Branch, err)
+	}
+
+	for _, file := range req.Files {
+		var blob plumbing.Hash
+		if file.Reader != nil {
+			blob, err = streamBlob(ctx, repoPath, file.Reader)
+		} else {
+			blob, err = storeBlob(repo, file.Content)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", file.Path, err)
+		}
+		treeHash, err := s.updateTreeWithBlob(repo, tree, file.Path, &blob)
+		if err != nil {
+			return nil, fmt.Errorf("failed to add %s: %w", file.Path, err)
+		}
+		if tree, err = repo.TreeObject(treeHash); err != nil {
+			return nil, fmt.Errorf("failed to read updated tree: %w", err)
+		}
+	}
+
+	author, committer := req.Author, req.Committer
+	if committer.Name == "" {
+		committer = author
+	}
+	if author.Date.IsZero() {
+		author.Date = time.Now()
+	}
+	if committer.Date.IsZero() {
+		committer.Date = time.Now()
+	}
+
+	newCommit := &object.Commit{
+		Author:       object.Signature{Name: author.Name, Email: author.Email, When: author.Date},
+		Committer:    object.Signature{Name: committer.Name, Email: committer.Email, When: committer.Date},
+		Message:      req.Message,
+		TreeHash:     tree.Hash,
+		ParentHashes: parents,
+	}
+
+	commitObj := repo.Storer.NewEncodedObject()
+	if err := newCommit.Encode(commitObj); err != nil {
+		return nil, fmt.Errorf("failed to encode commit: %w", err)
+	}
+	commitHash, err := repo.Storer.SetEncodedObject(commitObj)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store commit: %w", err)
+	}
+
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(branchRef, commitHash)); err != nil {
+		return nil, fmt.Errorf("failed to update branch reference: %w", err)
+	}
+
+	stored, err := repo.CommitObject(commitHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit object: %w", err)
+	}
+	return s.convertCommit(stored), nil
+}
@@ -0,0 +1,76 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for path, content := range files {
+		full := filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0o755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0o644))
+	}
+}
+
+func TestSnapshotAndImportHistory(t *testing.T) {
+	svc := NewGitService(logrus.New())
+	ctx := context.Background()
+	author := CommitAuthor{Name: "test", Email: "test@example.com"}
+
+	project := t.TempDir()
+	writeTestFiles(t, project, map[string]string{
+		"README.md":           "hello",
+		"src/main.go":         "package main",
+		".gitignore":          "build/\n",
+		"build/output.bin":    "ignored",
+		"docs/guide/intro.md": "intro",
+	})
+	commit, err := svc.SnapshotDirectory(ctx, project, SnapshotRequest{Branch: "trunk", Message: "Import project", Author: author})
+	require.NoError(t, err)
+	assert.Empty(t, commit.Parents)
+	require.NoError(t, svc.CreateTag(ctx, project, "v1.0.0", "trunk", ""))
+
+	target := filepath.Join(t.TempDir(), "app.git")
+	require.NoError(t, svc.InitRepository(ctx, target, true))
+	require.NoError(t, svc.ImportHistory(ctx, target, project))
+
+	files := map[string]string{}
+	require.NoError(t, svc.WalkFiles(ctx, target, "trunk", 0, func(path string, content []byte) error {
+		files[path] = string(content)
+		return nil
+	}))
+	assert.Equal(t, map[string]string{
+		"README.md":           "hello",
+		"src/main.go":         "package main",
+		".gitignore":          "build/\n",
+		"docs/guide/intro.md": "intro",
+	}, files)
+
+	info, err := svc.GetRepositoryInfo(ctx, target)
+	require.NoError(t, err)
+	assert.Equal(t, "trunk", info.DefaultBranch, "HEAD follows the source")
+	tag, err := svc.GetTag(ctx, target, "v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, commit.SHA, tag.SHA)
+
+	// Sources borrowing objects from elsewhere on disk are refused
+	writeTestFiles(t, project, map[string]string{".git/objects/info/alternates": "/srv/other.git/objects\n"})
+	other := filepath.Join(t.TempDir(), "other.git")
+	require.NoError(t, svc.InitRepository(ctx, other, true))
+	assert.Error(t, svc.ImportHistory(ctx, other, project))
+
+	empty := t.TempDir()
+	_, err = svc.SnapshotDirectory(ctx, empty, SnapshotRequest{Branch: "main", Message: "empty", Author: author})
+	assert.Error(t, err)
+	_, err = git.PlainOpen(empty)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,44 @@
+package git
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateFileRefusesConcurrentChanges(t *testing.T) {
+	dir := t.TempDir()
+	_, err := git.PlainInit(dir, true)
+	require.NoError(t, err)
+
+	svc := NewGitService(logrus.New())
+	ctx := context.Background()
+	author := CommitAuthor{Name: "test", Email: "test@example.com"}
+	first, err := svc.CommitFiles(ctx, dir, CommitFilesRequest{Branch: "settings", Message: "initial", Author: author,
+		Files: []CommitFileEntry{{Path: "repository.yaml", Content: []byte("name: app\n")}}})
+	require.NoError(t, err)
+	file, err := svc.GetFile(ctx, dir, "settings", "repository.yaml")
+	require.NoError(t, err)
+
+	update := UpdateFileRequest{Path: "repository.yaml", Content: "name: web\n", Branch: "settings", Message: "rename", SHA: file.SHA, Author: author}
+	second, err := svc.UpdateFile(ctx, dir, update)
+	require.NoError(t, err)
+	assert.Equal(t, []string{first.SHA}, second.Parents)
+
+	// The file no longer has the version the caller read
+	update.Content = "name: api\n"
+	_, err = svc.UpdateFile(ctx, dir, update)
+	assert.ErrorIs(t, err, ErrRefChanged)
+
+	// The branch is only moved from the commit the change was built on
+	err = updateRef(ctx, dir, "refs/heads/settings", plumbing.NewHash(first.SHA), plumbing.NewHash(first.SHA))
+	assert.ErrorIs(t, err, ErrRefChanged)
+	head, err := svc.GetFile(ctx, dir, "settings", "repository.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "name: web\n", head.Content)
+}
@@ -0,0 +1,242 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/sirupsen/logrus"
+)
+
+// SnapshotRequest describes the single commit SnapshotDirectory creates
+type SnapshotRequest struct {
+	Branch  string
+	Message string
+	Author  CommitAuthor
+}
+
+// SnapshotDirectory turns dir into a repository whose only commit, on
+// req.Branch, holds the files under dir. Files matched by .gitignore are
+// left out. dir must not be a repository already.
+func (s *gitService) SnapshotDirectory(ctx context.Context, dir string, req SnapshotRequest) (*Commit, error) {
+	repo, err := git.PlainInitWithOptions(dir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName(req.Branch)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize repository: %w", err)
+	}
+	wt, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open worktree: %w", err)
+	}
+	if err := wt.AddWithOptions(&git.AddOptions{All: true}); err != nil {
+		return nil, fmt.Errorf("failed to add files: %w", err)
+	}
+
+	when := req.Author.Date
+	if when.IsZero() {
+		when = time.Now()
+	}
+	signature := &object.Signature{Name: req.Author.Name, Email: req.Author.Email, When: when}
+	hash, err := wt.Commit(req.Message, &git.CommitOptions{Author: signature, Committer: signature})
+	if err != nil {
+		if errors.Is(err, git.ErrEmptyCommit) {
+			return nil, fmt.Errorf("no files to commit")
+		}
+		return nil, fmt.Errorf("failed to commit files: %w", err)
+	}
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit object: %w", err)
+	}
+	return s.convertCommit(commit), nil
+}
+
+// ImportHistory copies the objects, branches and tags of the repository at
+// sourcePath, a working tree or a git directory, into the repository at
+// repoPath and points its HEAD at the source's current branch. The source
+// may come from an upload, so repositories borrowing objects through
+// alternates are refused rather than read.
+func (s *gitService) ImportHistory(ctx context.Context, repoPath, sourcePath string) error {
+	for _, gitDir := range []string{sourcePath, filepath.Join(sourcePath, ".git")} {
+		for _, name := range []string{"alternates", "http-alternates"} {
+			if _, err := os.Lstat(filepath.Join(gitDir, "objects", "info", name)); err == nil {
+				return fmt.Errorf("repositories with object alternates cannot be imported")
+			}
+		}
+	}
+
+	source, err := git.PlainOpen(sourcePath)
+	if err != nil {
+		return fmt.Errorf("failed to open source repository: %w", err)
+	}
+	target, err := s.openRepository(repoPath)
+	if err != nil {
+		return err
+	}
+
+	objects, err := source.Storer.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return fmt.Errorf("failed to read source objects: %w", err)
+	}
+	copied := 0
+	err = objects.ForEach(func(obj plumbing.EncodedObject) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if _, err := target.Storer.SetEncodedObject(obj); err != nil {
+			return err
+		}
+		copied++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy objects: %w", err)
+	}
+
+	refs, err := source.References()
+	if err != nil {
+		return fmt.Errorf("failed to read source references: %w", err)
+	}
+	imported := 0
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference || !(ref.Name().IsBranch() || ref.Name().IsTag()) {
+			return nil
+		}
+		// Refuse refs to objects the source does not hold
+		if _, err := target.Storer.EncodedObject(plumbing.AnyObject, ref.Hash()); err != nil {
+			return fmt.Errorf("%s points to a missing object", ref.Name())
+		}
+		imported++
+		return target.Storer.SetReference(ref)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy references: %w", err)
+	}
+
+	if head, err := source.Storer.Reference(plumbing.HEAD); err == nil && head.Type() == plumbing.SymbolicReference {
+		if err := target.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, head.Target())); err != nil {
+			return fmt.Errorf("failed to set HEAD: %w", err)
+		}
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"path":    repoPath,
+		"objects": copied,
+		"refs":    imported,
+	}).Info("Imported repository history")
+	return nil
+}
+
+// RemoteHistory counts what FetchRemoteHistory copied
+type RemoteHistory struct {
+	Branches int
+	Tags     int
+	Commits  int
+}
+
+// FetchRemoteOptions tunes FetchRemoteHistory
+type FetchRemoteOptions struct {
+	DefaultBranch string
+	Username      string
+	Password      string
+	// Address, when set, is the IP address connected to instead of
+	// resolving the URL's host, so a host vetted by the caller cannot be
+	// re-pointed before git connects
+	Address string
+}
+
+// FetchRemoteHistory copies the branches and tags of the repository at
+// sourceURL into a new bare repository at destPath and points its HEAD at
+// the default branch. Credentials, when given, are sent as HTTP basic auth
+// through the environment, so they neither show up in the process list nor
+// end up in the repository's config. Only http and https URLs are fetched
+// and redirects are not followed, so a source cannot point git at local
+// paths or other hosts. Messages of the remote are left out of errors.
+func FetchRemoteHistory(ctx context.Context, sourceURL, destPath string, opts FetchRemoteOptions) (*RemoteHistory, error) {
+	u, err := url.Parse(sourceURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("unsupported source URL %q: only http and https are fetched", sourceURL)
+	}
+	if err := os.MkdirAll(destPath, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create destination directory: %w", err)
+	}
+	if err := runGit(ctx, destPath, "init", "--bare", "-q"); err != nil {
+		return nil, err
+	}
+
+	config := [][2]string{{"http.followRedirects", "false"}}
+	if opts.Username != "" || opts.Password != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(opts.Username + ":" + opts.Password))
+		config = append(config, [2]string{"http.extraHeader", "Authorization: Basic " + credentials})
+	}
+	if opts.Address != "" {
+		port := u.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443"}[u.Scheme]
+		}
+		address := opts.Address
+		if strings.Contains(address, ":") {
+			address = "[" + address + "]"
+		}
+		config = append(config, [2]string{"http.curloptResolve", u.Hostname() + ":" + port + ":" + address})
+	}
+	cmd := exec.CommandContext(ctx, "git", "fetch", "--quiet", "--no-tags", sourceURL,
+		"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+	cmd.Dir = destPath
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ALLOW_PROTOCOL=http:https", "GIT_CONFIG_COUNT="+strconv.Itoa(len(config)))
+	for i, entry := range config {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, entry[0]), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, entry[1]))
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		var local []string
+		for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+			if !strings.HasPrefix(line, "remote:") {
+				local = append(local, line)
+			}
+		}
+		return nil, fmt.Errorf("git fetch failed: %w: %s", err, strings.Join(local, "; "))
+	}
+
+	if opts.DefaultBranch != "" {
+		if err := runGit(ctx, destPath, "symbolic-ref", "HEAD", "refs/heads/"+opts.DefaultBranch); err != nil {
+			return nil, err
+		}
+	}
+
+	var history RemoteHistory
+	refs, err := gitOutput(ctx, destPath, "for-each-ref", "--format=%(refname)")
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range strings.Fields(refs) {
+		switch {
+		case strings.HasPrefix(ref, "refs/heads/"):
+			history.Branches++
+		case strings.HasPrefix(ref, "refs/tags/"):
+			history.Tags++
+		}
+	}
+	if history.Branches+history.Tags > 0 {
+		out, err := gitOutput(ctx, destPath, "rev-list", "--all", "--count")
+		if err != nil {
+			return nil, err
+		}
+		history.Commits, _ = strconv.Atoi(strings.TrimSpace(out))
+	}
+	return &history, nil
+}
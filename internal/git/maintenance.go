@@ -0,0 +1,260 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PackStats describes the object database layout of a repository
+type PackStats struct {
+	PackCount         int   `json:"pack_count"`
+	PackBytes         int64 `json:"pack_bytes"`
+	LooseObjects      int   `json:"loose_objects"`
+	LooseBytes        int64 `json:"loose_bytes"`
+	HasBitmap         bool  `json:"has_bitmap"`
+	HasMultiPackIndex bool  `json:"has_multi_pack_index"`
+	HasCommitGraph    bool  `json:"has_commit_graph"`
+	OffloadedPacks    int   `json:"offloaded_packs"`
+	// Delta statistics are only collected on request since they read every
+	// pack index; DeltaObjects counts objects stored as deltas
+	DeltaObjects      int64   `json:"delta_objects,omitempty"`
+	MaxDeltaDepth     int     `json:"max_delta_depth,omitempty"`
+	AverageDeltaDepth float64 `json:"average_delta_depth,omitempty"`
+	// OldestPackAt is the modification time of the oldest local pack
+	OldestPackAt *time.Time `json:"oldest_pack_at,omitempty"`
+}
+
+// PackStatistics inspects the object database of the repository at
+// repoPath, counting the packs offloaded to packs. Delta chains are
+// measured with git verify-pack when withDeltas is set.
+func PackStatistics(ctx context.Context, packs *PackStore, repoPath string, withDeltas bool) (*PackStats, error) {
+	objects := objectsDir(repoPath)
+	if _, err := os.Stat(objects); err != nil {
+		return nil, fmt.Errorf("failed to open object database: %w", err)
+	}
+	stats := &PackStats{}
+
+	packDir := filepath.Join(objects, "pack")
+	entries, err := os.ReadDir(packDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read pack directory: %w", err)
+	}
+	var indexes []string
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case strings.HasSuffix(name, ".pack"):
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			stats.PackCount++
+			stats.PackBytes += info.Size()
+			if mod := info.ModTime(); stats.OldestPackAt == nil || mod.Before(*stats.OldestPackAt) {
+				stats.OldestPackAt = &mod
+			}
+			indexes = append(indexes, filepath.Join(packDir, strings.TrimSuffix(name, ".pack")+".idx"))
+		case strings.HasSuffix(name, ".bitmap"):
+			stats.HasBitmap = true
+		case name == "multi-pack-index":
+			stats.HasMultiPackIndex = true
+		}
+	}
+
+	// Loose objects live in two hex digit fan-out directories
+	fanout, err := os.ReadDir(objects)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object database: %w", err)
+	}
+	for _, dir := range fanout {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		if _, err := strconv.ParseUint(dir.Name(), 16, 8); err != nil {
+			continue
+		}
+		loose, err := os.ReadDir(filepath.Join(objects, dir.Name()))
+		if err != nil {
+			continue
+		}
+		for _, object := range loose {
+			if info, err := object.Info(); err == nil && info.Mode().IsRegular() {
+				stats.LooseObjects++
+				stats.LooseBytes += info.Size()
+			}
+		}
+	}
+
+	for _, path := range []string{filepath.Join(objects, "info", "commit-graph"), filepath.Join(objects, "info", "commit-graphs")} {
+		if _, err := os.Stat(path); err == nil {
+			stats.HasCommitGraph = true
+		}
+	}
+
+	offloaded, err := packs.Offloaded(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	stats.OffloadedPacks = len(offloaded)
+
+	if withDeltas {
+		var weighted int64
+		for _, idx := range indexes {
+			histogram, err := deltaChains(ctx, idx)
+			if err != nil {
+				return nil, err
+			}
+			for depth, count := range histogram {
+				stats.DeltaObjects += count
+				weighted += int64(depth) * count
+				if depth > stats.MaxDeltaDepth {
+					stats.MaxDeltaDepth = depth
+				}
+			}
+		}
+		if stats.DeltaObjects > 0 {
+			stats.AverageDeltaDepth = float64(weighted) / float64(stats.DeltaObjects)
+		}
+	}
+	return stats, nil
+}
+
+// deltaChains returns the number of objects per delta chain length in a
+// pack, parsed from the "chain length = N: M objects" lines of
+// git verify-pack -s
+func deltaChains(ctx context.Context, idxPath string) (map[int]int64, error) {
+	out, err := exec.CommandContext(ctx, "git", "verify-pack", "-s", idxPath).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify pack %s: %w", filepath.Base(idxPath), err)
+	}
+	histogram := make(map[int]int64)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		var depth int
+		var count int64
+		if n, _ := fmt.Sscanf(scanner.Text(), "chain length = %d: %d object", &depth, &count); n == 2 {
+			histogram[depth] += count
+		}
+	}
+	return histogram, scanner.Err()
+}
+
+// RepackOptions selects how aggressively a repository is repacked
+type RepackOptions struct {
+	// Geometric, when above 1, only rolls up packs that break a geometric
+	// progression of this factor instead of rewriting every object
+	Geometric int `json:"geometric,omitempty"`
+	// WriteBitmaps writes reachability bitmaps, through a multi-pack index
+	// for geometric repacks
+	WriteBitmaps bool `json:"write_bitmaps"`
+	// Window and Depth tune delta search; zero keeps git's defaults
+	Window int `json:"window,omitempty"`
+	Depth  int `json:"depth,omitempty"`
+}
+
+// Args returns the git repack arguments for the options. Objects borrowed
+// from alternates, including offloaded packs, are never copied back.
+func (o RepackOptions) Args() []string {
+	args := []string{"repack", "-d", "-l", "-q"}
+	if o.Geometric > 1 {
+		args = append(args, fmt.Sprintf("--geometric=%d", o.Geometric))
+		if o.WriteBitmaps {
+			args = append(args, "--write-midx", "--write-bitmap-index")
+		}
+	} else {
+		args = append(args, "-a")
+		if o.WriteBitmaps {
+			args = append(args, "--write-bitmap-index")
+		}
+	}
+	if o.Window > 0 {
+		args = append(args, fmt.Sprintf("--window=%d", o.Window))
+	}
+	if o.Depth > 0 {
+		args = append(args, fmt.Sprintf("--depth=%d", o.Depth))
+	}
+	return args
+}
+
+// Repack repacks the repository at repoPath and refreshes its commit graph.
+// It holds the lock packs keeps for the repository so it never races an
+// offload.
+func Repack(ctx context.Context, packs *PackStore, repoPath string, opts RepackOptions) error {
+	if packs != nil {
+		l := packs.lock(repoPath)
+		l.Lock()
+		defer l.Unlock()
+	}
+
+	for _, args := range [][]string{opts.Args(), {"commit-graph", "write", "--reachable", "--split"}} {
+		cmd := exec.CommandContext(ctx, "git", args...)
+		cmd.Dir = repoPath
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+		}
+	}
+	return nil
+}
+
+// AccessPattern counts recent fetches and pushes of a repository
+type AccessPattern struct {
+	Fetches int64         `json:"fetches"`
+	Pushes  int64         `json:"pushes"`
+	Window  time.Duration `json:"-"`
+}
+
+// perDay scales a count over the pattern's window to a daily rate
+func (a AccessPattern) perDay(count int64) float64 {
+	days := a.Window.Hours() / 24
+	if days <= 0 {
+		days = 1
+	}
+	return float64(count) / days
+}
+
+// MaintenanceThresholds decide when a repository needs repacking and which
+// access rates make it busy
+type MaintenanceThresholds struct {
+	MaxLooseObjects   int
+	MaxPacks          int
+	BusyFetchesPerDay float64
+	BusyPushesPerDay  float64
+}
+
+// TuneRepack chooses repack options for a repository from its layout and
+// access pattern. It reports false when the repository needs no work.
+//
+// Frequently pushed repositories get geometric repacks, which only rewrite
+// recently pushed packs. Frequently fetched repositories get bitmaps and a
+// wider delta search, trading repack time for cheaper fetches. Quiet
+// repositories are consolidated into a single pack.
+func TuneRepack(stats *PackStats, access AccessPattern, limits MaintenanceThresholds) (RepackOptions, bool) {
+	busyReads := limits.BusyFetchesPerDay > 0 && access.perDay(access.Fetches) >= limits.BusyFetchesPerDay
+	busyWrites := limits.BusyPushesPerDay > 0 && access.perDay(access.Pushes) >= limits.BusyPushesPerDay
+
+	needed := (limits.MaxLooseObjects > 0 && stats.LooseObjects >= limits.MaxLooseObjects) ||
+		(limits.MaxPacks > 0 && stats.PackCount >= limits.MaxPacks) ||
+		(busyReads && stats.PackCount > 0 && !stats.HasBitmap)
+	if !needed {
+		return RepackOptions{}, false
+	}
+
+	opts := RepackOptions{WriteBitmaps: true}
+	if busyWrites {
+		opts.Geometric = 2
+		opts.WriteBitmaps = busyReads
+	}
+	if busyReads {
+		opts.Window = 250
+		opts.Depth = 50
+	}
+	return opts, true
+}
@@ -0,0 +1,86 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackStatisticsAndRepack(t *testing.T) {
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+
+	// Growing revisions of one file give the repack something to deltify
+	content := strings.Repeat("line of text that stays the same\n", 200)
+	for i := 0; i < 5; i++ {
+		content += "revision " + string(rune('a'+i)) + "\n"
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte(content), 0644))
+		_, err := wt.Add("notes.txt")
+		require.NoError(t, err)
+		_, err = wt.Commit("update notes", &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		require.NoError(t, err)
+	}
+
+	ctx := context.Background()
+	stats, err := PackStatistics(ctx, nil, dir, true)
+	require.NoError(t, err)
+	assert.Equal(t, 0, stats.PackCount)
+	assert.Equal(t, 15, stats.LooseObjects)
+	assert.False(t, stats.HasBitmap)
+
+	require.NoError(t, Repack(ctx, nil, dir, RepackOptions{WriteBitmaps: true, Window: 50, Depth: 10}))
+	stats, err = PackStatistics(ctx, nil, dir, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.PackCount)
+	assert.Zero(t, stats.LooseObjects)
+	assert.True(t, stats.HasBitmap)
+	assert.True(t, stats.HasCommitGraph)
+	assert.Positive(t, stats.DeltaObjects)
+	assert.GreaterOrEqual(t, stats.MaxDeltaDepth, 1)
+	assert.GreaterOrEqual(t, stats.AverageDeltaDepth, 1.0)
+
+	require.NoError(t, Repack(ctx, nil, dir, RepackOptions{Geometric: 2, WriteBitmaps: true}))
+	stats, err = PackStatistics(ctx, nil, dir, false)
+	require.NoError(t, err)
+	assert.True(t, stats.HasMultiPackIndex)
+	assert.Zero(t, stats.MaxDeltaDepth)
+}
+
+func TestTuneRepack(t *testing.T) {
+	limits := MaintenanceThresholds{MaxLooseObjects: 100, MaxPacks: 10, BusyFetchesPerDay: 50, BusyPushesPerDay: 10}
+	week := 7 * 24 * time.Hour
+
+	_, needed := TuneRepack(&PackStats{PackCount: 2, LooseObjects: 10, HasBitmap: true}, AccessPattern{Window: week}, limits)
+	assert.False(t, needed)
+
+	// Quiet repositories are consolidated into one pack with bitmaps
+	opts, needed := TuneRepack(&PackStats{PackCount: 12}, AccessPattern{Window: week}, limits)
+	require.True(t, needed)
+	assert.Equal(t, RepackOptions{WriteBitmaps: true}, opts)
+
+	// Busy pushes switch to geometric repacks
+	opts, needed = TuneRepack(&PackStats{LooseObjects: 500}, AccessPattern{Pushes: 140, Window: week}, limits)
+	require.True(t, needed)
+	assert.Equal(t, RepackOptions{Geometric: 2}, opts)
+
+	// Busy fetches need bitmaps even when the layout is tidy
+	opts, needed = TuneRepack(&PackStats{PackCount: 1}, AccessPattern{Fetches: 700, Pushes: 140, Window: week}, limits)
+	require.True(t, needed)
+	assert.Equal(t, RepackOptions{Geometric: 2, WriteBitmaps: true, Window: 250, Depth: 50}, opts)
+
+	assert.Equal(t, []string{"repack", "-d", "-l", "-q", "--geometric=2", "--write-midx", "--write-bitmap-index", "--window=250", "--depth=50"}, opts.Args())
+	assert.Equal(t, []string{"repack", "-d", "-l", "-q", "-a"}, RepackOptions{}.Args())
+}
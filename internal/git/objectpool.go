@@ -0,0 +1,497 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// objectPoolsDir is the directory under the repository root holding one
+// object pool per fork network
+const objectPoolsDir = ".pools"
+
+// objectPoolMembersFile lists the member repositories of a pool, one per line
+const objectPoolMembersFile = "hub-members"
+
+// ErrObjectPoolUnhealthy is returned when a pool that other repositories
+// borrow objects from is missing or damaged
+var ErrObjectPoolUnhealthy = errors.New("object pool is missing or damaged")
+
+// ObjectPools shares the objects of fork networks. Each network has a bare
+// pool repository; members hardlink their packs and loose objects into it
+// (copying them across filesystems) and borrow them back through an
+// objects/info/alternates entry, so a fork stores only the objects pushed
+// to it. The pool mirrors the refs of every member under
+// refs/members/<member>/ to keep their objects reachable.
+type ObjectPools struct {
+	root   string
+	logger *logrus.Logger
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// ObjectPoolStats describes the pool of one fork network
+type ObjectPoolStats struct {
+	Network  string   `json:"network"`
+	Path     string   `json:"path"`
+	Healthy  bool     `json:"healthy"`
+	Problems []string `json:"problems,omitempty"`
+	Members  []string `json:"members"`
+	// Objects and Bytes count the loose and packed objects of the pool
+	Objects int64 `json:"objects"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// NewObjectPools creates the object pools kept under repositoryRoot. It
+// returns nil when pools are disabled; nil ObjectPools are safe to use.
+func NewObjectPools(cfg config.ForkPools, repositoryRoot string, logger *logrus.Logger) *ObjectPools {
+	if !cfg.Enabled {
+		return nil
+	}
+	root, err := filepath.Abs(filepath.Join(repositoryRoot, objectPoolsDir))
+	if err != nil {
+		root = filepath.Join(repositoryRoot, objectPoolsDir)
+	}
+	return &ObjectPools{root: root, logger: logger, locks: make(map[string]*sync.Mutex)}
+}
+
+// PoolPath returns the pool repository of a network
+func (p *ObjectPools) PoolPath(network string) string {
+	return filepath.Join(p.root, network+".git")
+}
+
+func (p *ObjectPools) lock(network string) *sync.Mutex {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.locks[network]
+	if !ok {
+		l = &sync.Mutex{}
+		p.locks[network] = l
+	}
+	return l
+}
+
+// PoolOf returns the network whose pool the repository borrows objects from
+func (p *ObjectPools) PoolOf(repoPath string) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	for _, dir := range readAlternates(repoPath) {
+		pool := filepath.Dir(dir)
+		if filepath.Base(dir) == "objects" && filepath.Dir(pool) == p.root && strings.HasSuffix(pool, ".git") {
+			return strings.TrimSuffix(filepath.Base(pool), ".git"), true
+		}
+	}
+	return "", false
+}
+
+// Join adds the repository to the pool of network, creating the pool when
+// needed. Joining again links the objects and refs added since.
+func (p *ObjectPools) Join(ctx context.Context, repoPath, network, member string) error {
+	if p == nil {
+		return nil
+	}
+	l := p.lock(network)
+	l.Lock()
+	defer l.Unlock()
+	return p.join(ctx, repoPath, network, member)
+}
+
+func (p *ObjectPools) join(ctx context.Context, repoPath, network, member string) error {
+	pool := p.PoolPath(network)
+	if _, err := os.Stat(filepath.Join(pool, "objects")); os.IsNotExist(err) {
+		if err := os.MkdirAll(p.root, 0755); err != nil {
+			return fmt.Errorf("failed to create object pool directory: %w", err)
+		}
+		if err := runGit(ctx, "", "init", "--quiet", "--bare", pool); err != nil {
+			return err
+		}
+	}
+
+	poolObjects := filepath.Join(pool, "objects")
+	if err := linkObjects(objectsDir(repoPath), poolObjects); err != nil {
+		return fmt.Errorf("failed to link objects into pool: %w", err)
+	}
+	// Every object is in the pool already, so this only copies the refs
+	if err := runGit(ctx, pool, "fetch", "--quiet", "--no-tags", "--prune", repoPath, "+refs/*:refs/members/"+member+"/*"); err != nil {
+		return err
+	}
+	if err := addAlternate(repoPath, poolObjects); err != nil {
+		return err
+	}
+	return updatePoolMembers(pool, func(members []string) []string {
+		for _, m := range members {
+			if m == member {
+				return members
+			}
+		}
+		return append(members, member)
+	})
+}
+
+// Fork creates a bare repository at forkPath with the branches, tags and
+// HEAD of sourcePath, borrowing every object from the source's pool, and
+// adds it to the pool as member
+func (p *ObjectPools) Fork(ctx context.Context, sourcePath, forkPath, member string) error {
+	network, ok := p.PoolOf(sourcePath)
+	if !ok {
+		return fmt.Errorf("repository %s is not in an object pool", sourcePath)
+	}
+	l := p.lock(network)
+	l.Lock()
+	defer l.Unlock()
+
+	if err := runGit(ctx, "", "init", "--quiet", "--bare", forkPath); err != nil {
+		return err
+	}
+	if err := addAlternate(forkPath, filepath.Join(p.PoolPath(network), "objects")); err != nil {
+		return err
+	}
+	if err := runGit(ctx, forkPath, "fetch", "--quiet", "--no-tags", "--update-head-ok", sourcePath,
+		"+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*"); err != nil {
+		return err
+	}
+	if head, err := gitOutput(ctx, sourcePath, "symbolic-ref", "HEAD"); err == nil {
+		if err := runGit(ctx, forkPath, "symbolic-ref", "HEAD", strings.TrimSpace(head)); err != nil {
+			return err
+		}
+	}
+	return p.join(ctx, forkPath, network, member)
+}
+
+// Leave removes a repository about to be deleted from its pool. While other
+// members remain, the pool must be healthy and the repository's objects are
+// linked into it first, so nothing the network borrowed is lost; the pool
+// is deleted with its last member.
+func (p *ObjectPools) Leave(ctx context.Context, repoPath, member string) error {
+	network, ok := p.PoolOf(repoPath)
+	if !ok {
+		return nil
+	}
+	l := p.lock(network)
+	l.Lock()
+	defer l.Unlock()
+
+	pool := p.PoolPath(network)
+	members, err := readPoolMembers(pool)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read pool members: %w", err)
+	}
+	var others []string
+	for _, m := range members {
+		if m != member {
+			others = append(others, m)
+		}
+	}
+	if len(others) == 0 {
+		if err := os.RemoveAll(pool); err != nil {
+			return fmt.Errorf("failed to delete object pool: %w", err)
+		}
+		return nil
+	}
+
+	if err := runGit(ctx, pool, "rev-parse", "--git-dir"); err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrObjectPoolUnhealthy, network, err)
+	}
+	if err := linkObjects(objectsDir(repoPath), filepath.Join(pool, "objects")); err != nil {
+		return fmt.Errorf("failed to link objects into pool: %w", err)
+	}
+	refs, err := gitOutput(ctx, pool, "for-each-ref", "--format=delete %(refname)", "refs/members/"+member+"/")
+	if err != nil {
+		return err
+	}
+	if refs != "" {
+		cmd := exec.CommandContext(ctx, "git", "update-ref", "--stdin")
+		cmd.Dir = pool
+		cmd.Stdin = strings.NewReader(refs)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("git update-ref failed: %w: %s", err, strings.TrimSpace(string(out)))
+		}
+	}
+	return updatePoolMembers(pool, func([]string) []string { return others })
+}
+
+// Networks lists the networks that have a pool
+func (p *ObjectPools) Networks() ([]string, error) {
+	if p == nil {
+		return nil, nil
+	}
+	entries, err := os.ReadDir(p.root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read object pools: %w", err)
+	}
+	var networks []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasSuffix(entry.Name(), ".git") {
+			networks = append(networks, strings.TrimSuffix(entry.Name(), ".git"))
+		}
+	}
+	sort.Strings(networks)
+	return networks, nil
+}
+
+// Inspect reports the size, members and health of a network's pool
+func (p *ObjectPools) Inspect(ctx context.Context, network string) (*ObjectPoolStats, error) {
+	pool := p.PoolPath(network)
+	stats := &ObjectPoolStats{Network: network, Path: pool, Members: []string{}}
+	if _, err := os.Stat(filepath.Join(pool, "objects")); err != nil {
+		stats.Problems = append(stats.Problems, "pool object database is missing")
+		return stats, nil
+	}
+
+	members, err := readPoolMembers(pool)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read pool members: %w", err)
+	}
+	stats.Members = append(stats.Members, members...)
+	if len(members) == 0 {
+		stats.Problems = append(stats.Problems, "pool has no members")
+	}
+
+	out, err := gitOutput(ctx, pool, "count-objects", "-v")
+	if err != nil {
+		stats.Problems = append(stats.Problems, "pool cannot be read: "+err.Error())
+		return stats, nil
+	}
+	counts := make(map[string]int64)
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ": ")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			counts[key] = n
+		}
+	}
+	// count-objects reports sizes in KiB
+	stats.Objects = counts["count"] + counts["in-pack"]
+	stats.Bytes = (counts["size"] + counts["size-pack"]) * 1024
+	if counts["garbage"] > 0 {
+		stats.Problems = append(stats.Problems, fmt.Sprintf("pool holds %d garbage files", counts["garbage"]))
+	}
+	stats.Healthy = len(stats.Problems) == 0
+	return stats, nil
+}
+
+// LocalObjectBytes adds up the packs, pack indexes and loose objects of a
+// repository that are not hardlinked into its pool, which is what the
+// repository stores on its own. Bitmaps and other pack metadata are left out.
+func LocalObjectBytes(repoPath, poolPath string) (int64, error) {
+	objects := objectsDir(repoPath)
+	poolObjects := filepath.Join(poolPath, "objects")
+	var total int64
+	err := filepath.WalkDir(objects, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != objects && d.Name() != "pack" && len(d.Name()) != 2 {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		parent := filepath.Base(filepath.Dir(path))
+		if parent == "pack" && !strings.HasSuffix(d.Name(), ".pack") && !strings.HasSuffix(d.Name(), ".idx") {
+			return nil
+		}
+		if parent != "pack" && len(parent) != 2 {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(objects, path)
+		if pooled, err := os.Stat(filepath.Join(poolObjects, rel)); err == nil && os.SameFile(info, pooled) {
+			return nil
+		}
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure objects: %w", err)
+	}
+	return total, nil
+}
+
+// BorrowsFrom reports whether the repository lists another repository's
+// object database in its alternates
+func BorrowsFrom(repoPath, otherPath string) bool {
+	other, err := filepath.Abs(objectsDir(otherPath))
+	if err != nil {
+		return false
+	}
+	for _, dir := range readAlternates(repoPath) {
+		if filepath.Clean(dir) == other {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAlternates reports whether the repository borrows objects at all
+func hasAlternates(repoPath string) bool {
+	return len(readAlternates(repoPath)) > 0
+}
+
+// readAlternates returns the absolute alternate object directories of a
+// repository
+func readAlternates(repoPath string) []string {
+	objects := objectsDir(repoPath)
+	data, err := os.ReadFile(filepath.Join(objects, "info", "alternates"))
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !filepath.IsAbs(line) {
+			line = filepath.Join(objects, line)
+		}
+		dirs = append(dirs, filepath.Clean(line))
+	}
+	return dirs
+}
+
+// addAlternate adds dir to the alternates of the repository
+func addAlternate(repoPath, dir string) error {
+	path := filepath.Join(objectsDir(repoPath), "info", "alternates")
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read alternates: %w", err)
+	}
+	for _, line := range strings.Split(string(existing), "\n") {
+		if strings.TrimSpace(line) == dir {
+			return nil
+		}
+	}
+
+	content := strings.TrimRight(string(existing), "\n")
+	if content != "" {
+		content += "\n"
+	}
+	return writeFileAtomic(path, []byte(content+dir+"\n"))
+}
+
+// linkObjects links the packs and loose objects of one object database into
+// another. Packs are linked before their indexes so git never sees an index
+// without its pack.
+func linkObjects(src, dst string) error {
+	packs, err := os.ReadDir(filepath.Join(src, "pack"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var indexes []string
+	for _, entry := range packs {
+		name := entry.Name()
+		switch {
+		case strings.HasSuffix(name, ".idx"):
+			indexes = append(indexes, name)
+		case strings.HasSuffix(name, ".pack"), strings.HasSuffix(name, ".rev"):
+			if err := linkObject(filepath.Join(src, "pack", name), filepath.Join(dst, "pack", name)); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range indexes {
+		if err := linkObject(filepath.Join(src, "pack", name), filepath.Join(dst, "pack", name)); err != nil {
+			return err
+		}
+	}
+
+	fanout, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, dir := range fanout {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		loose, err := os.ReadDir(filepath.Join(src, dir.Name()))
+		if err != nil {
+			return err
+		}
+		for _, object := range loose {
+			if err := linkObject(filepath.Join(src, dir.Name(), object.Name()), filepath.Join(dst, dir.Name(), object.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// linkObject links one object file unless the destination has it already;
+// object files are named by their content, so an existing file is the same
+func linkObject(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+	return linkOrCopy(src, dst)
+}
+
+func readPoolMembers(pool string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(pool, objectPoolMembersFile))
+	if err != nil {
+		return nil, err
+	}
+	var members []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			members = append(members, line)
+		}
+	}
+	return members, nil
+}
+
+func updatePoolMembers(pool string, update func([]string) []string) error {
+	members, err := readPoolMembers(pool)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read pool members: %w", err)
+	}
+	members = update(members)
+	var buf bytes.Buffer
+	for _, m := range members {
+		buf.WriteString(m + "\n")
+	}
+	return writeFileAtomic(filepath.Join(pool, objectPoolMembersFile), buf.Bytes())
+}
+
+func runGit(ctx context.Context, dir string, args ...string) error {
+	_, err := gitOutput(ctx, dir, args...)
+	return err
+}
+
+func gitOutput(ctx context.Context, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
@@ -0,0 +1,97 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObjectPools(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	assert.Nil(t, NewObjectPools(config.ForkPools{}, root, logrus.New()))
+	pools := NewObjectPools(config.ForkPools{Enabled: true}, root, logrus.New())
+
+	// A packed bare source repository with a few commits
+	work := t.TempDir()
+	repo, err := git.PlainInit(work, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	for _, content := range []string{"one\n", "two\n", "three\n"} {
+		require.NoError(t, os.WriteFile(filepath.Join(work, "file.txt"), []byte(content), 0644))
+		_, err := wt.Add("file.txt")
+		require.NoError(t, err)
+		_, err = wt.Commit(content, &git.CommitOptions{Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}})
+		require.NoError(t, err)
+	}
+	source := filepath.Join(root, "user", "owner", "app.git")
+	require.NoError(t, runGit(ctx, "", "clone", "--quiet", "--bare", work, source))
+	require.NoError(t, Repack(ctx, nil, source, RepackOptions{}))
+
+	require.NoError(t, pools.Join(ctx, source, "network", "source"))
+	fork := filepath.Join(root, "user", "other", "app.git")
+	require.NoError(t, pools.Fork(ctx, source, fork, "fork"))
+
+	network, ok := pools.PoolOf(fork)
+	require.True(t, ok)
+	assert.Equal(t, "network", network)
+	pool := pools.PoolPath(network)
+	// Neither repository stores objects of its own
+	for _, path := range []string{source, fork} {
+		local, err := LocalObjectBytes(path, pool)
+		require.NoError(t, err)
+		assert.Zero(t, local, path)
+	}
+
+	forkRepo, err := openWithAlternates(fork)
+	require.NoError(t, err)
+	head, err := forkRepo.Head()
+	require.NoError(t, err)
+	commit, err := forkRepo.CommitObject(head.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, "three\n", commit.Message)
+
+	stats, err := pools.Inspect(ctx, network)
+	require.NoError(t, err)
+	assert.True(t, stats.Healthy, stats.Problems)
+	assert.Equal(t, []string{"source", "fork"}, stats.Members)
+	assert.EqualValues(t, 9, stats.Objects)
+	assert.Positive(t, stats.Bytes)
+
+	// Deleting the source leaves the fork intact
+	require.NoError(t, pools.Leave(ctx, source, "source"))
+	require.NoError(t, os.RemoveAll(source))
+	require.NoError(t, runGit(ctx, fork, "fsck", "--connectivity-only", "--no-dangling"))
+	stats, err = pools.Inspect(ctx, network)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fork"}, stats.Members)
+
+	// The pool goes with its last member
+	require.NoError(t, pools.Leave(ctx, fork, "fork"))
+	networks, err := pools.Networks()
+	require.NoError(t, err)
+	assert.Empty(t, networks)
+}
+
+func TestBorrowsFrom(t *testing.T) {
+	dir := t.TempDir()
+	source, borrower := filepath.Join(dir, "source.git"), filepath.Join(dir, "borrower.git")
+	for _, path := range []string{source, borrower} {
+		_, err := git.PlainInit(path, true)
+		require.NoError(t, err)
+	}
+	assert.False(t, BorrowsFrom(borrower, source))
+	require.NoError(t, addAlternate(borrower, filepath.Join(source, "objects")))
+	assert.True(t, BorrowsFrom(borrower, source))
+	assert.False(t, BorrowsFrom(source, borrower))
+}
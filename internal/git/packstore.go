@@ -0,0 +1,596 @@
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/sirupsen/logrus"
+)
+
+// offloadManifestName is the file under objects/info listing the packs of a
+// repository that live in object storage
+const offloadManifestName = "offloaded-packs"
+
+// OffloadedPack records a packfile moved to object storage. The pack and its
+// index are stored at Key+".pack" and Key+".idx".
+type OffloadedPack struct {
+	Name        string    `json:"name"`
+	Key         string    `json:"key"`
+	Size        int64     `json:"size"`
+	OffloadedAt time.Time `json:"offloaded_at"`
+}
+
+// OffloadResult summarizes one Offload call
+type OffloadResult struct {
+	Packs []OffloadedPack `json:"packs"`
+	Bytes int64           `json:"bytes"`
+}
+
+// PackStoreOptions controls which packfiles are offloaded and how much local
+// disk the pack cache may use
+type PackStoreOptions struct {
+	// RepositoryRoot is the directory holding all repositories; object
+	// storage keys mirror repository paths relative to it
+	RepositoryRoot string
+	CachePath      string
+	// CacheMaxBytes bounds the pack cache; 0 disables eviction
+	CacheMaxBytes int64
+	// MinRepoBytes skips repositories whose packs add up to less than this
+	MinRepoBytes int64
+	// MinPackAge skips packs written more recently than this
+	MinPackAge time.Duration
+}
+
+// PackStore offloads old packfiles of large repositories to object storage.
+// Offloaded packs are read through a local cache that each repository
+// references with an objects/info/alternates entry, so git and go-git keep
+// resolving their objects. Packs missing from the cache are downloaded again
+// before the repository is used.
+type PackStore struct {
+	backend storage.Backend
+	opts    PackStoreOptions
+	logger  *logrus.Logger
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewPackStore creates a PackStore from configuration. It returns nil when
+// offloading is disabled; a nil PackStore is safe to use.
+func NewPackStore(cfg config.PackOffload, repositoryRoot string, logger *logrus.Logger) (*PackStore, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var stCfg storage.Config
+	stCfg.Backend = cfg.Backend
+	stCfg.Azure = storage.AzureConfig{
+		AccountName:   cfg.Azure.AccountName,
+		AccountKey:    cfg.Azure.AccountKey,
+		ContainerName: cfg.Azure.ContainerName,
+		EndpointURL:   cfg.Azure.EndpointURL,
+	}
+	stCfg.S3 = storage.S3Config{
+		Region:          cfg.S3.Region,
+		Bucket:          cfg.S3.Bucket,
+		AccessKeyID:     cfg.S3.AccessKeyID,
+		SecretAccessKey: cfg.S3.SecretAccessKey,
+		EndpointURL:     cfg.S3.EndpointURL,
+		UseSSL:          cfg.S3.UseSSL,
+	}
+	stCfg.Filesystem.BasePath = cfg.BasePath
+	backend, err := storage.NewBackend(stCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pack storage backend: %w", err)
+	}
+
+	return NewPackStoreWithBackend(backend, PackStoreOptions{
+		RepositoryRoot: repositoryRoot,
+		CachePath:      cfg.CachePath,
+		CacheMaxBytes:  cfg.CacheMaxSizeMB * 1024 * 1024,
+		MinRepoBytes:   cfg.MinRepoSizeMB * 1024 * 1024,
+		MinPackAge:     time.Duration(cfg.MinPackAgeDays) * 24 * time.Hour,
+	}, logger), nil
+}
+
+// NewPackStoreWithBackend creates a PackStore on an existing storage backend
+func NewPackStoreWithBackend(backend storage.Backend, opts PackStoreOptions, logger *logrus.Logger) *PackStore {
+	return &PackStore{backend: backend, opts: opts, logger: logger, locks: make(map[string]*sync.Mutex)}
+}
+
+// objectsDir returns the object database of a bare or non-bare repository
+func objectsDir(repoPath string) string {
+	if fi, err := os.Stat(filepath.Join(repoPath, ".git")); err == nil && fi.IsDir() {
+		return filepath.Join(repoPath, ".git", "objects")
+	}
+	return filepath.Join(repoPath, "objects")
+}
+
+// repoKey names a repository in object storage and in the cache
+func (p *PackStore) repoKey(repoPath string) string {
+	abs, err := filepath.Abs(repoPath)
+	if err != nil {
+		abs = repoPath
+	}
+	if p.opts.RepositoryRoot != "" {
+		if root, err := filepath.Abs(p.opts.RepositoryRoot); err == nil {
+			if rel, err := filepath.Rel(root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	sum := sha256.Sum256([]byte(abs))
+	return "external/" + hex.EncodeToString(sum[:8])
+}
+
+// cacheObjectsDir is the alternate object database holding a repository's
+// cached packs
+func (p *PackStore) cacheObjectsDir(repoPath string) (string, error) {
+	dir, err := filepath.Abs(filepath.Join(p.opts.CachePath, filepath.FromSlash(p.repoKey(repoPath)), "objects"))
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve pack cache path: %w", err)
+	}
+	return dir, nil
+}
+
+func (p *PackStore) lock(repoPath string) *sync.Mutex {
+	key := p.repoKey(repoPath)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		p.locks[key] = l
+	}
+	return l
+}
+
+// Offloaded returns the packs of a repository that live in object storage
+func (p *PackStore) Offloaded(repoPath string) ([]OffloadedPack, error) {
+	data, err := os.ReadFile(filepath.Join(objectsDir(repoPath), "info", offloadManifestName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read offloaded pack manifest: %w", err)
+	}
+	var packs []OffloadedPack
+	if err := json.Unmarshal(data, &packs); err != nil {
+		return nil, fmt.Errorf("failed to parse offloaded pack manifest: %w", err)
+	}
+	return packs, nil
+}
+
+func writeOffloadManifest(repoPath string, packs []OffloadedPack) error {
+	data, err := json.MarshalIndent(packs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(objectsDir(repoPath), "info", offloadManifestName), data)
+}
+
+// ensureAlternate adds the repository's cache directory to its alternates
+func (p *PackStore) ensureAlternate(repoPath, cacheDir string) error {
+	if err := os.MkdirAll(filepath.Join(cacheDir, "pack"), 0755); err != nil {
+		return fmt.Errorf("failed to create pack cache: %w", err)
+	}
+
+	return addAlternate(repoPath, cacheDir)
+}
+
+// Offload moves the repository's packs older than MinPackAge to object
+// storage, keeping a copy in the cache until it is evicted. Repositories
+// smaller than MinRepoBytes are left alone.
+func (p *PackStore) Offload(ctx context.Context, repoPath string) (*OffloadResult, error) {
+	if p == nil {
+		return &OffloadResult{}, nil
+	}
+	l := p.lock(repoPath)
+	l.Lock()
+	defer l.Unlock()
+
+	packDir := filepath.Join(objectsDir(repoPath), "pack")
+	entries, err := os.ReadDir(packDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &OffloadResult{}, nil
+		}
+		return nil, fmt.Errorf("failed to list packs: %w", err)
+	}
+
+	type localPack struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	var packs []localPack
+	var total int64
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".pack")
+		if !ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat pack %s: %w", name, err)
+		}
+		total += info.Size()
+		if _, err := os.Stat(filepath.Join(packDir, name+".keep")); err == nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(packDir, name+".idx")); err != nil {
+			continue
+		}
+		packs = append(packs, localPack{name: name, size: info.Size(), modTime: info.ModTime()})
+	}
+
+	result := &OffloadResult{Packs: []OffloadedPack{}}
+	if total < p.opts.MinRepoBytes {
+		return result, nil
+	}
+
+	manifest, err := p.Offloaded(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	cacheDir, err := p.cacheObjectsDir(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.ensureAlternate(repoPath, cacheDir); err != nil {
+		return nil, err
+	}
+
+	cutoff := time.Now().Add(-p.opts.MinPackAge)
+	sort.Slice(packs, func(i, j int) bool { return packs[i].modTime.Before(packs[j].modTime) })
+	for _, pack := range packs {
+		if pack.modTime.After(cutoff) {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		offloaded := OffloadedPack{
+			Name:        pack.name,
+			Key:         "packs/" + p.repoKey(repoPath) + "/" + pack.name,
+			Size:        pack.size,
+			OffloadedAt: time.Now(),
+		}
+		// The pack goes before its index everywhere, since git only looks for
+		// packs that have an index
+		for _, ext := range []string{".pack", ".idx"} {
+			if err := p.upload(ctx, filepath.Join(packDir, pack.name+ext), offloaded.Key+ext); err != nil {
+				return result, err
+			}
+		}
+		for _, ext := range []string{".pack", ".idx"} {
+			if err := linkOrCopy(filepath.Join(packDir, pack.name+ext), filepath.Join(cacheDir, "pack", pack.name+ext)); err != nil {
+				return result, fmt.Errorf("failed to cache pack %s: %w", pack.name, err)
+			}
+		}
+
+		manifest = append(manifest, offloaded)
+		if err := writeOffloadManifest(repoPath, manifest); err != nil {
+			return result, fmt.Errorf("failed to record offloaded pack %s: %w", pack.name, err)
+		}
+		for _, ext := range []string{".idx", ".bitmap", ".rev", ".pack"} {
+			if err := os.Remove(filepath.Join(packDir, pack.name+ext)); err != nil && !os.IsNotExist(err) {
+				return result, fmt.Errorf("failed to remove offloaded pack %s: %w", pack.name, err)
+			}
+		}
+
+		result.Packs = append(result.Packs, offloaded)
+		result.Bytes += pack.size
+	}
+
+	if len(result.Packs) > 0 {
+		p.logger.WithFields(logrus.Fields{
+			"repository": repoPath,
+			"packs":      len(result.Packs),
+			"bytes":      result.Bytes,
+		}).Info("Offloaded packfiles to object storage")
+	}
+	return result, nil
+}
+
+func (p *PackStore) upload(ctx context.Context, path, key string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", filepath.Base(path), err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", filepath.Base(path), err)
+	}
+	if err := p.backend.Upload(ctx, key, f, info.Size()); err != nil {
+		return fmt.Errorf("failed to upload %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// Ensure makes every offloaded pack of a repository available locally,
+// downloading packs that were evicted from the cache. It must run before git
+// reads objects from the repository.
+func (p *PackStore) Ensure(ctx context.Context, repoPath string) error {
+	if p == nil {
+		return nil
+	}
+	l := p.lock(repoPath)
+	l.Lock()
+	defer l.Unlock()
+	return p.hydrate(ctx, repoPath)
+}
+
+// Prefetch starts downloading a repository's evicted packs in the background.
+// It is called when an access pattern predicts a full read, such as the
+// info/refs request that precedes a clone, so the read itself finds the
+// packs cached.
+func (p *PackStore) Prefetch(repoPath string) {
+	if p == nil {
+		return
+	}
+	go func() {
+		defer errorreporting.Default().Recover("pack_prefetch", nil)
+
+		l := p.lock(repoPath)
+		// Another request is already hydrating the repository
+		if !l.TryLock() {
+			return
+		}
+		defer l.Unlock()
+		if err := p.hydrate(context.Background(), repoPath); err != nil {
+			p.logger.WithError(err).WithField("repository", repoPath).Warn("Failed to prefetch offloaded packs")
+		}
+	}()
+}
+
+func (p *PackStore) hydrate(ctx context.Context, repoPath string) error {
+	packs, err := p.Offloaded(repoPath)
+	if err != nil || len(packs) == 0 {
+		return err
+	}
+	cacheDir, err := p.cacheObjectsDir(repoPath)
+	if err != nil {
+		return err
+	}
+	if err := p.ensureAlternate(repoPath, cacheDir); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, pack := range packs {
+		packPath := filepath.Join(cacheDir, "pack", pack.Name+".pack")
+		idxPath := filepath.Join(cacheDir, "pack", pack.Name+".idx")
+		if _, err := os.Stat(idxPath); err == nil {
+			// Cache hits refresh the access time eviction is ordered by
+			_ = os.Chtimes(packPath, now, now)
+			continue
+		}
+
+		for _, target := range []struct{ key, path string }{{pack.Key + ".pack", packPath}, {pack.Key + ".idx", idxPath}} {
+			if err := p.download(ctx, target.key, target.path); err != nil {
+				return fmt.Errorf("failed to restore offloaded pack %s: %w", pack.Name, err)
+			}
+		}
+		p.logger.WithFields(logrus.Fields{"repository": repoPath, "pack": pack.Name}).Debug("Restored offloaded pack into cache")
+	}
+	return nil
+}
+
+func (p *PackStore) download(ctx context.Context, key, path string) error {
+	r, err := p.backend.Download(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".download-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// EvictCache removes the least recently used packs from the cache until it
+// fits in CacheMaxBytes, returning the number of bytes freed. Evicted packs
+// stay in object storage and are restored on next use.
+func (p *PackStore) EvictCache(ctx context.Context) (int64, error) {
+	if p == nil || p.opts.CacheMaxBytes <= 0 {
+		return 0, nil
+	}
+
+	type cachedPack struct {
+		path    string // without extension
+		size    int64
+		modTime time.Time
+	}
+	var packs []cachedPack
+	var total int64
+	err := filepath.WalkDir(p.opts.CachePath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".pack") {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size := info.Size()
+		if idx, err := os.Stat(strings.TrimSuffix(path, ".pack") + ".idx"); err == nil {
+			size += idx.Size()
+		}
+		total += size
+		packs = append(packs, cachedPack{path: strings.TrimSuffix(path, ".pack"), size: size, modTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan pack cache: %w", err)
+	}
+
+	sort.Slice(packs, func(i, j int) bool { return packs[i].modTime.Before(packs[j].modTime) })
+	var freed int64
+	for _, pack := range packs {
+		if total-freed <= p.opts.CacheMaxBytes {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return freed, err
+		}
+		// Drop the index first so git never sees an index without its pack
+		if err := os.Remove(pack.path + ".idx"); err != nil && !os.IsNotExist(err) {
+			return freed, fmt.Errorf("failed to evict %s: %w", filepath.Base(pack.path), err)
+		}
+		if err := os.Remove(pack.path + ".pack"); err != nil && !os.IsNotExist(err) {
+			return freed, fmt.Errorf("failed to evict %s: %w", filepath.Base(pack.path), err)
+		}
+		freed += pack.size
+	}
+	return freed, nil
+}
+
+// StartScheduler periodically offloads old packs of every repository under
+// RepositoryRoot and trims the cache
+func (p *PackStore) StartScheduler(ctx context.Context, interval time.Duration) {
+	if p == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job := jobs.Job{Name: "pack_offload", Class: jobs.ClassArchival, Interval: interval}
+			if jobs.DefaultGate().Wait(ctx, job) != nil {
+				return
+			}
+			p.runScheduled(ctx)
+		}
+	}
+}
+
+func (p *PackStore) runScheduled(ctx context.Context) {
+	defer errorreporting.Default().Recover("pack_offload", nil)
+
+	var offloaded int64
+	err := filepath.WalkDir(p.opts.RepositoryRoot, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || !strings.HasSuffix(path, ".git") {
+			return nil
+		}
+		result, err := p.Offload(ctx, path)
+		if err != nil {
+			p.logger.WithError(err).WithField("repository", path).Warn("Failed to offload packfiles")
+		} else {
+			offloaded += result.Bytes
+		}
+		return filepath.SkipDir
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		p.logger.WithError(err).Error("Failed to scan repositories for pack offloading")
+	}
+
+	freed, err := p.EvictCache(ctx)
+	if err != nil {
+		p.logger.WithError(err).Error("Failed to evict pack cache")
+	}
+	p.logger.WithFields(logrus.Fields{"offloaded_bytes": offloaded, "evicted_bytes": freed}).Info("Pack offloading run completed")
+}
+
+// openWithAlternates opens a repository whose alternates point outside it.
+// go-git resolves alternates inside the repository's own filesystem by
+// default, which cannot reach the pack cache.
+func openWithAlternates(repoPath string) (*git.Repository, error) {
+	dotPath := repoPath
+	wt := osfs.New(repoPath)
+	if fi, err := os.Stat(filepath.Join(repoPath, ".git")); err == nil && fi.IsDir() {
+		dotPath = filepath.Join(repoPath, ".git")
+	} else {
+		wt = nil
+	}
+
+	st := filesystem.NewStorageWithOptions(osfs.New(dotPath), cache.NewObjectLRUDefault(), filesystem.Options{AlternatesFS: osfs.New("/")})
+	return git.Open(st, wt)
+}
+
+func linkOrCopy(src, dst string) error {
+	if err := os.Link(src, dst); err == nil || os.IsExist(err) {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".copy-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dst)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,94 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/go-git/go-git/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackStore_OffloadAndRestore(t *testing.T) {
+	root := t.TempDir()
+	repoPath := filepath.Join(root, "user", "alice", "app")
+	repo, err := git.PlainInit(repoPath, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	commitFile(t, wt, repoPath, "a.txt")
+	commitFile(t, wt, repoPath, "b.txt")
+	require.NoError(t, repo.RepackObjects(&git.RepackConfig{}))
+
+	packDir := filepath.Join(repoPath, ".git", "objects", "pack")
+	packs, err := filepath.Glob(filepath.Join(packDir, "*.pack"))
+	require.NoError(t, err)
+	require.Len(t, packs, 1)
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, os.Chtimes(packs[0], old, old))
+
+	backend, err := storage.NewFilesystemBackend(storage.FilesystemConfig{BasePath: t.TempDir()})
+	require.NoError(t, err)
+	cachePath := t.TempDir()
+	store := NewPackStoreWithBackend(backend, PackStoreOptions{
+		RepositoryRoot: root,
+		CachePath:      cachePath,
+		CacheMaxBytes:  1,
+		MinPackAge:     24 * time.Hour,
+	}, logrus.New())
+	ctx := context.Background()
+
+	result, err := store.Offload(ctx, repoPath)
+	require.NoError(t, err)
+	require.Len(t, result.Packs, 1)
+	assert.Equal(t, "packs/user/alice/app/"+result.Packs[0].Name, result.Packs[0].Key)
+	exists, err := backend.Exists(ctx, result.Packs[0].Key+".pack")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	remaining, _ := filepath.Glob(filepath.Join(packDir, "*.pack"))
+	assert.Empty(t, remaining)
+	alternates, err := os.ReadFile(filepath.Join(repoPath, ".git", "objects", "info", "alternates"))
+	require.NoError(t, err)
+	assert.Contains(t, string(alternates), filepath.Join(cachePath, "user", "alice", "app", "objects"))
+
+	svc := NewGitServiceWithPackStore(store, logrus.New())
+	commits, err := svc.GetCommits(ctx, repoPath, CommitOptions{})
+	require.NoError(t, err)
+	assert.Len(t, commits, 2)
+
+	// Evicting the cached copy leaves the pack only in object storage
+	freed, err := store.EvictCache(ctx)
+	require.NoError(t, err)
+	assert.Positive(t, freed)
+	cached, _ := filepath.Glob(filepath.Join(cachePath, "user", "alice", "app", "objects", "pack", "*.pack"))
+	assert.Empty(t, cached)
+
+	file, err := svc.GetFile(ctx, repoPath, "HEAD", "a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt", file.Path)
+	cached, _ = filepath.Glob(filepath.Join(cachePath, "user", "alice", "app", "objects", "pack", "*.pack"))
+	assert.Len(t, cached, 1)
+
+	// Small repositories and recent packs stay local
+	otherPath := filepath.Join(root, "user", "alice", "other")
+	other, err := git.PlainInit(otherPath, false)
+	require.NoError(t, err)
+	wt, err = other.Worktree()
+	require.NoError(t, err)
+	commitFile(t, wt, otherPath, "a.txt")
+	require.NoError(t, other.RepackObjects(&git.RepackConfig{}))
+
+	result, err = store.Offload(ctx, otherPath)
+	require.NoError(t, err)
+	assert.Empty(t, result.Packs)
+	small := NewPackStoreWithBackend(backend, PackStoreOptions{RepositoryRoot: root, CachePath: cachePath, MinRepoBytes: 1 << 30}, logrus.New())
+	result, err = small.Offload(ctx, otherPath)
+	require.NoError(t, err)
+	assert.Empty(t, result.Packs)
+}
@@ -0,0 +1,407 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/sirupsen/logrus"
+)
+
+// ErrReplicaConflict is returned when another writer already appended the
+// snapshot a replication was about to write
+var ErrReplicaConflict = errors.New("replica snapshot already exists")
+
+// ErrReplicaNotFound is returned when a repository has no replica, or none
+// as of the requested point
+var ErrReplicaNotFound = errors.New("replica not found")
+
+// ReplicaSnapshot is one entry of the append-only replication log of a
+// repository: its refs after a push, and the pack holding the objects the
+// push added. Restoring applies the packs of every snapshot up to the chosen
+// one and then writes that snapshot's refs.
+type ReplicaSnapshot struct {
+	Sequence  int64     `json:"sequence"`
+	CreatedAt time.Time `json:"created_at"`
+	// Repository is owner/name at replication time, so replicas can be told
+	// apart without the database
+	Repository string            `json:"repository,omitempty"`
+	Head       string            `json:"head,omitempty"`
+	Refs       map[string]string `json:"refs"`
+	// Pack is empty when the refs only moved to objects earlier packs hold
+	Pack    string `json:"pack,omitempty"`
+	Objects int64  `json:"objects"`
+	Bytes   int64  `json:"bytes"`
+}
+
+// Replicator streams repositories to object storage for disaster recovery.
+// Every replication uploads a pack of the objects new since the previous
+// snapshot and then the snapshot itself; nothing is ever overwritten or
+// deleted, so a replica can be restored to any snapshot.
+type Replicator struct {
+	backend storage.Backend
+	// packs restores offloaded packs before repositories are packed
+	packs  *PackStore
+	logger *logrus.Logger
+}
+
+// NewReplicator creates a Replicator writing to backend
+func NewReplicator(backend storage.Backend, packs *PackStore, logger *logrus.Logger) *Replicator {
+	return &Replicator{backend: backend, packs: packs, logger: logger}
+}
+
+// NewReplicatorFromConfig creates a Replicator from configuration. It
+// returns nil when replication is disabled.
+func NewReplicatorFromConfig(cfg config.DisasterRecovery, packs *PackStore, logger *logrus.Logger) (*Replicator, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	var stCfg storage.Config
+	stCfg.Backend = cfg.Backend
+	stCfg.Azure = storage.AzureConfig{
+		AccountName:   cfg.Azure.AccountName,
+		AccountKey:    cfg.Azure.AccountKey,
+		ContainerName: cfg.Azure.ContainerName,
+		EndpointURL:   cfg.Azure.EndpointURL,
+	}
+	stCfg.S3 = storage.S3Config{
+		Region:          cfg.S3.Region,
+		Bucket:          cfg.S3.Bucket,
+		AccessKeyID:     cfg.S3.AccessKeyID,
+		SecretAccessKey: cfg.S3.SecretAccessKey,
+		EndpointURL:     cfg.S3.EndpointURL,
+		UseSSL:          cfg.S3.UseSSL,
+	}
+	stCfg.Filesystem.BasePath = cfg.BasePath
+	backend, err := storage.NewBackend(stCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create replica storage backend: %w", err)
+	}
+	return NewReplicator(backend, packs, logger), nil
+}
+
+func replicaSnapshotKey(prefix string, sequence int64) string {
+	return fmt.Sprintf("%s/log/%012d.json", prefix, sequence)
+}
+
+func replicaPackKey(prefix string, sequence int64) string {
+	return fmt.Sprintf("%s/packs/%012d.pack", prefix, sequence)
+}
+
+// ReadRefs returns the target of HEAD and every ref of a repository
+func ReadRefs(ctx context.Context, repoPath string) (string, map[string]string, error) {
+	head, err := gitOutput(ctx, repoPath, "symbolic-ref", "-q", "HEAD")
+	if err != nil {
+		// A detached HEAD is not replicated
+		head = ""
+	}
+	out, err := gitOutput(ctx, repoPath, "for-each-ref", "--format=%(objectname) %(refname)")
+	if err != nil {
+		return "", nil, err
+	}
+	refs := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if sha, name, ok := strings.Cut(line, " "); ok {
+			refs[name] = sha
+		}
+	}
+	return strings.TrimSpace(head), refs, nil
+}
+
+// Replicate appends a snapshot of repoPath under prefix when its refs moved
+// since previous, the last snapshot written, and returns it. It returns
+// previous unchanged when there is nothing to replicate.
+func (r *Replicator) Replicate(ctx context.Context, repoPath, prefix, repository string, previous *ReplicaSnapshot) (*ReplicaSnapshot, error) {
+	// Offloaded packs must be local before their objects can be packed
+	if err := r.packs.Ensure(ctx, repoPath); err != nil {
+		return nil, err
+	}
+	head, refs, err := ReadRefs(ctx, repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read refs: %w", err)
+	}
+
+	snapshot := &ReplicaSnapshot{Sequence: 1, CreatedAt: time.Now().UTC(), Repository: repository, Head: head, Refs: refs}
+	var known []string
+	if previous != nil {
+		if previous.Head == head && SameRefs(previous.Refs, refs) {
+			return previous, nil
+		}
+		snapshot.Sequence = previous.Sequence + 1
+		for _, sha := range previous.Refs {
+			known = append(known, sha)
+		}
+	} else if len(refs) == 0 {
+		return nil, nil
+	}
+
+	snapshotKey := replicaSnapshotKey(prefix, snapshot.Sequence)
+	exists, err := r.backend.Exists(ctx, snapshotKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check replica snapshot: %w", err)
+	}
+	if exists {
+		return nil, ErrReplicaConflict
+	}
+
+	pack, objects, err := r.packNewObjects(ctx, repoPath, refs, known)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(pack)
+	if objects > 0 {
+		snapshot.Pack = replicaPackKey(prefix, snapshot.Sequence)
+		snapshot.Objects = objects
+		if snapshot.Bytes, err = r.upload(ctx, pack, snapshot.Pack); err != nil {
+			return nil, err
+		}
+	}
+
+	// The snapshot goes last, so every snapshot in the log has its pack
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode replica snapshot: %w", err)
+	}
+	if err := r.backend.Upload(ctx, snapshotKey, bytes.NewReader(data), int64(len(data))); err != nil {
+		return nil, fmt.Errorf("failed to upload replica snapshot: %w", err)
+	}
+
+	r.logger.WithFields(logrus.Fields{
+		"repository": repository,
+		"sequence":   snapshot.Sequence,
+		"objects":    snapshot.Objects,
+		"bytes":      snapshot.Bytes,
+	}).Debug("Replicated repository")
+	return snapshot, nil
+}
+
+// SameRefs reports whether two ref name to SHA maps are identical
+func SameRefs(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, sha := range a {
+		if b[name] != sha {
+			return false
+		}
+	}
+	return true
+}
+
+// packNewObjects writes a pack of the objects reachable from refs but not
+// from the known tips to a temporary file, and returns its path and object
+// count. Known tips that no longer exist, as after a force push and
+// garbage collection, are left out; their objects are in earlier packs.
+func (r *Replicator) packNewObjects(ctx context.Context, repoPath string, refs map[string]string, known []string) (string, int64, error) {
+	existing, err := existingObjects(ctx, repoPath, known)
+	if err != nil {
+		return "", 0, err
+	}
+
+	var revs bytes.Buffer
+	for _, sha := range refs {
+		revs.WriteString(sha + "\n")
+	}
+	for _, sha := range existing {
+		revs.WriteString("^" + sha + "\n")
+	}
+
+	f, err := os.CreateTemp("", "hub-replica-*.pack")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create replica pack: %w", err)
+	}
+	defer f.Close()
+
+	// Without --thin deltas never refer to objects outside the pack, and
+	// without --local objects borrowed from alternates are included, so
+	// every pack can be indexed on its own
+	cmd := exec.CommandContext(ctx, "git", "pack-objects", "--revs", "--stdout", "--delta-base-offset", "-q")
+	cmd.Dir = repoPath
+	cmd.Stdin = &revs
+	cmd.Stdout = f
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(f.Name())
+		return "", 0, fmt.Errorf("git pack-objects failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// The object count is a big-endian uint32 after the PACK signature and
+	// version
+	header := make([]byte, 12)
+	if _, err := f.ReadAt(header, 0); err != nil {
+		os.Remove(f.Name())
+		return "", 0, fmt.Errorf("failed to read replica pack header: %w", err)
+	}
+	return f.Name(), int64(binary.BigEndian.Uint32(header[8:])), nil
+}
+
+// existingObjects returns the shas of objects present in the repository
+func existingObjects(ctx context.Context, repoPath string, shas []string) ([]string, error) {
+	if len(shas) == 0 {
+		return nil, nil
+	}
+	cmd := exec.CommandContext(ctx, "git", "cat-file", "--batch-check=%(objectname)")
+	cmd.Dir = repoPath
+	cmd.Stdin = strings.NewReader(strings.Join(shas, "\n") + "\n")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file failed: %w", err)
+	}
+	var existing []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		// Missing objects are reported as "<sha> missing"
+		if line != "" && !strings.HasSuffix(line, " missing") {
+			existing = append(existing, line)
+		}
+	}
+	return existing, nil
+}
+
+func (r *Replicator) upload(ctx context.Context, file, key string) (int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open replica pack: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat replica pack: %w", err)
+	}
+	if err := r.backend.Upload(ctx, key, f, info.Size()); err != nil {
+		return 0, fmt.Errorf("failed to upload replica pack: %w", err)
+	}
+	return info.Size(), nil
+}
+
+// Snapshots returns the replication log under prefix, oldest first
+func (r *Replicator) Snapshots(ctx context.Context, prefix string) ([]*ReplicaSnapshot, error) {
+	keys, err := r.backend.List(ctx, prefix+"/log/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replica snapshots: %w", err)
+	}
+	// Sequences are zero-padded, so the keys sort in log order
+	sort.Strings(keys)
+
+	snapshots := make([]*ReplicaSnapshot, 0, len(keys))
+	for _, key := range keys {
+		if path.Ext(key) != ".json" {
+			continue
+		}
+		rc, err := r.backend.Download(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download replica snapshot %s: %w", key, err)
+		}
+		var snapshot ReplicaSnapshot
+		err = json.NewDecoder(rc).Decode(&snapshot)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode replica snapshot %s: %w", key, err)
+		}
+		snapshots = append(snapshots, &snapshot)
+	}
+	return snapshots, nil
+}
+
+// RestoreOptions picks the snapshot to restore: Sequence when set,
+// otherwise the last one written at or before Before, otherwise the last
+type RestoreOptions struct {
+	Sequence int64
+	Before   time.Time
+}
+
+// Restore rebuilds the replica under prefix as a bare repository at target,
+// which must not exist yet, and checks that every restored ref is
+// connected. It returns the snapshot restored.
+func (r *Replicator) Restore(ctx context.Context, prefix, target string, opts RestoreOptions) (*ReplicaSnapshot, error) {
+	snapshots, err := r.Snapshots(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	var chosen *ReplicaSnapshot
+	var packs []string
+	for _, snapshot := range snapshots {
+		if opts.Sequence > 0 && snapshot.Sequence > opts.Sequence {
+			break
+		}
+		if opts.Sequence == 0 && !opts.Before.IsZero() && snapshot.CreatedAt.After(opts.Before) {
+			break
+		}
+		chosen = snapshot
+		if snapshot.Pack != "" {
+			packs = append(packs, snapshot.Pack)
+		}
+	}
+	if chosen == nil || (opts.Sequence > 0 && chosen.Sequence != opts.Sequence) {
+		return nil, ErrReplicaNotFound
+	}
+
+	if _, err := os.Stat(target); err == nil {
+		return nil, fmt.Errorf("restore target %s already exists", target)
+	}
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create restore target: %w", err)
+	}
+	if err := runGit(ctx, target, "init", "--bare", "-q"); err != nil {
+		return nil, err
+	}
+
+	for _, key := range packs {
+		if err := r.indexPack(ctx, target, key); err != nil {
+			return nil, err
+		}
+	}
+
+	var updates bytes.Buffer
+	for name, sha := range chosen.Refs {
+		fmt.Fprintf(&updates, "create %s %s\n", name, sha)
+	}
+	cmd := exec.CommandContext(ctx, "git", "update-ref", "--stdin")
+	cmd.Dir = target
+	cmd.Stdin = &updates
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("git update-ref failed: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	if chosen.Head != "" {
+		if err := runGit(ctx, target, "symbolic-ref", "HEAD", chosen.Head); err != nil {
+			return nil, err
+		}
+	}
+	if err := runGit(ctx, target, "fsck", "--connectivity-only", "--no-progress"); err != nil {
+		return nil, fmt.Errorf("restored repository is incomplete: %w", err)
+	}
+	return chosen, nil
+}
+
+// indexPack downloads a replica pack into the object database of target
+func (r *Replicator) indexPack(ctx context.Context, target, key string) error {
+	rc, err := r.backend.Download(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to download replica pack %s: %w", key, err)
+	}
+	defer rc.Close()
+
+	cmd := exec.CommandContext(ctx, "git", "index-pack", "--stdin")
+	cmd.Dir = target
+	cmd.Stdin = rc
+	cmd.Stdout = io.Discard
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to index replica pack %s: %w: %s", key, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package git
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/go-git/go-git/v5"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplicator_ReplicateAndRestore(t *testing.T) {
+	ctx := context.Background()
+	work := t.TempDir()
+	repo, err := git.PlainInit(work, false)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	commitFile(t, wt, work, "a.txt")
+	commitFile(t, wt, work, "b.txt")
+	source := filepath.Join(t.TempDir(), "app.git")
+	require.NoError(t, runGit(ctx, "", "clone", "--quiet", "--bare", work, source))
+
+	backend, err := storage.NewFilesystemBackend(storage.FilesystemConfig{BasePath: t.TempDir()})
+	require.NoError(t, err)
+	replicator := NewReplicator(backend, nil, logrus.New())
+
+	first, err := replicator.Replicate(ctx, source, "repositories/1", "alice/app", nil)
+	require.NoError(t, err)
+	require.NotNil(t, first)
+	assert.EqualValues(t, 1, first.Sequence)
+	assert.Equal(t, "refs/heads/master", first.Head)
+	assert.EqualValues(t, 6, first.Objects, "two commits, two trees and two blobs")
+	unchanged, err := replicator.Replicate(ctx, source, "repositories/1", "alice/app", first)
+	require.NoError(t, err)
+	assert.Same(t, first, unchanged)
+
+	// Only the objects of the new commit are shipped
+	commitFile(t, wt, work, "c.txt")
+	require.NoError(t, runGit(ctx, work, "push", "-q", source, "HEAD:refs/heads/master", "HEAD:refs/heads/feature"))
+	second, err := replicator.Replicate(ctx, source, "repositories/1", "alice/app", first)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, second.Sequence)
+	assert.EqualValues(t, 3, second.Objects)
+	_, err = replicator.Replicate(ctx, source, "repositories/1", "alice/app", first)
+	assert.ErrorIs(t, err, ErrReplicaConflict, "snapshots are never overwritten")
+
+	// A force push whose old tips were collected can't be bounded by them,
+	// so everything reachable is shipped again
+	require.NoError(t, runGit(ctx, source, "update-ref", "-d", "refs/heads/feature"))
+	require.NoError(t, runGit(ctx, source, "update-ref", "refs/heads/master", first.Refs["refs/heads/master"]))
+	require.NoError(t, runGit(ctx, source, "gc", "-q", "--prune=now"))
+	third, err := replicator.Replicate(ctx, source, "repositories/1", "alice/app", second)
+	require.NoError(t, err)
+	assert.EqualValues(t, 6, third.Objects)
+	assert.Equal(t, first.Refs, third.Refs)
+
+	snapshots, err := replicator.Snapshots(ctx, "repositories/1")
+	require.NoError(t, err)
+	require.Len(t, snapshots, 3)
+	assert.Equal(t, "alice/app", snapshots[2].Repository)
+
+	target := filepath.Join(t.TempDir(), "restored.git")
+	restored, err := replicator.Restore(ctx, "repositories/1", target, RestoreOptions{Sequence: 2})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, restored.Sequence)
+	head, refs, err := ReadRefs(ctx, target)
+	require.NoError(t, err)
+	assert.Equal(t, "refs/heads/master", head)
+	assert.Equal(t, second.Refs, refs)
+
+	latest := filepath.Join(t.TempDir(), "latest.git")
+	restored, err = replicator.Restore(ctx, "repositories/1", latest, RestoreOptions{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, restored.Sequence)
+	_, refs, err = ReadRefs(ctx, latest)
+	require.NoError(t, err)
+	assert.Equal(t, first.Refs, refs)
+
+	_, err = replicator.Restore(ctx, "repositories/1", filepath.Join(t.TempDir(), "old.git"), RestoreOptions{Before: first.CreatedAt.Add(-time.Second)})
+	assert.ErrorIs(t, err, ErrReplicaNotFound)
+	_, err = replicator.Restore(ctx, "repositories/1", target, RestoreOptions{})
+	assert.Error(t, err, "the target must not exist")
+}
@@ -2,6 +2,7 @@ package git
 
 import (
 	"context"
+	"io"
 	"time"
 )
 
@@ -11,10 +12,16 @@ type GitService interface {
 	InitRepository(ctx context.Context, repoPath string, bare bool) error
 	CloneRepository(ctx context.Context, sourceURL, destPath string, options CloneOptions) error
 	DeleteRepository(ctx context.Context, repoPath string) error
+	// ImportHistory copies the branches and tags of a local repository
+	ImportHistory(ctx context.Context, repoPath, sourcePath string) error
+	// SnapshotDirectory makes dir a repository with one commit of its files
+	SnapshotDirectory(ctx context.Context, dir string, req SnapshotRequest) (*Commit, error)
 
 	// Commit operations
 	GetCommits(ctx context.Context, repoPath string, opts CommitOptions) ([]*Commit, error)
 	GetCommit(ctx context.Context, repoPath, sha string) (*Commit, error)
+	// LookupCommits reads several commits by SHA, leaving out those that do not exist
+	LookupCommits(ctx context.Context, repoPath string, shas []string) (map[string]*Commit, error)
 	GetCommitDiff(ctx context.Context, repoPath, fromSHA, toSHA string) (*Diff, error)
 
 	// Branch operations
@@ -22,6 +29,9 @@ type GitService interface {
 	GetBranch(ctx context.Context, repoPath, branchName string) (*Branch, error)
 	CreateBranch(ctx context.Context, repoPath, branchName, fromRef string) error
 	DeleteBranch(ctx context.Context, repoPath, branchName string) error
+	// RenameBranch moves a branch to a new name, keeping HEAD on it when it
+	// was the checked out branch
+	RenameBranch(ctx context.Context, repoPath, oldName, newName string) error
 
 	// Tag operations
 	GetTags(ctx context.Context, repoPath string) ([]*Tag, error)
@@ -36,6 +46,7 @@ type GitService interface {
 	CreateFile(ctx context.Context, repoPath string, req CreateFileRequest) (*Commit, error)
 	UpdateFile(ctx context.Context, repoPath string, req UpdateFileRequest) (*Commit, error)
 	DeleteFile(ctx context.Context, repoPath string, req DeleteFileRequest) (*Commit, error)
+	CommitFiles(ctx context.Context, repoPath string, req CommitFilesRequest) (*Commit, error)
 
 	// Repository info
 	GetRepositoryInfo(ctx context.Context, repoPath string) (*RepositoryInfo, error)
@@ -47,6 +58,14 @@ type GitService interface {
 	MergeBranches(repoPath, base, head string, mergeMethod, title, message string) (string, error)
 	GetBranchCommit(repoPath, branch string) (string, error)
 	ResolveSHA(ctx context.Context, repoPath, ref string) (string, error)
+	// GetAheadBehind computes divergence of each head ref from base, walking only the history where they differ
+	GetAheadBehind(ctx context.Context, repoPath, base string, heads []string) (map[string]*AheadBehind, error)
+
+	// WalkFiles visits every non-binary file at ref no larger than maxSize bytes
+	WalkFiles(ctx context.Context, repoPath, ref string, maxSize int64, fn func(path string, content []byte) error) error
+
+	// Archive streams the tree of ref to w as a tar.gz or zip archive
+	Archive(ctx context.Context, repoPath, ref string, format ArchiveFormat, prefix string, w io.Writer) error
 }
 
 // CloneOptions represents options for cloning a repository
@@ -82,6 +101,12 @@ type Commit struct {
 	Tree      string        `json:"tree"`
 	Stats     *CommitStats  `json:"stats,omitempty"`
 	Files     []*CommitFile `json:"files,omitempty"`
+	// Trailers are parsed from the end of Message
+	Trailers []CommitTrailer `json:"trailers,omitempty"`
+	// Signature is set for signed commits; Verification is filled in by
+	// the signing key service
+	Signature    *Signature    `json:"-"`
+	Verification *Verification `json:"verification,omitempty"`
 }
 
 // CommitAuthor represents the author or committer of a commit
@@ -125,6 +150,9 @@ type Tag struct {
 	Message   string        `json:"message,omitempty"`
 	Tagger    *CommitAuthor `json:"tagger,omitempty"`
 	CreatedAt time.Time     `json:"created_at"`
+	// Signature is set for signed annotated tags
+	Signature    *Signature    `json:"-"`
+	Verification *Verification `json:"verification,omitempty"`
 }
 
 // Tree represents a Git tree (directory)
@@ -201,6 +229,24 @@ type CreateFileRequest struct {
 	Committer CommitAuthor `json:"committer,omitempty"`
 }
 
+// CommitFilesRequest writes several files to a branch in a single commit.
+// The branch is created as a root commit when it does not exist yet.
+type CommitFilesRequest struct {
+	Files     []CommitFileEntry
+	Message   string
+	Branch    string
+	Author    CommitAuthor
+	Committer CommitAuthor
+}
+
+// CommitFileEntry is a single file added or replaced by CommitFiles. When
+// Reader is set the content is streamed from it instead of taken from Content.
+type CommitFileEntry struct {
+	Path    string
+	Content []byte
+	Reader  io.Reader
+}
+
 // UpdateFileRequest represents a request to update a file
 type UpdateFileRequest struct {
 	Path      string       `json:"path"`
@@ -251,6 +297,12 @@ type LanguageStats struct {
 	Percentage float64 `json:"percentage"`
 }
 
+// AheadBehind represents how far a ref has diverged from a base ref
+type AheadBehind struct {
+	AheadBy  int `json:"ahead_by"`
+	BehindBy int `json:"behind_by"`
+}
+
 // BranchComparison represents a comparison between two branches
 type BranchComparison struct {
 	BaseRef    string      `json:"base_ref"`
@@ -0,0 +1,61 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Signature is the armored signature of a commit or an annotated tag
+// together with the object content it signs
+type Signature struct {
+	Armored string
+	Payload []byte
+}
+
+// Verification is the outcome of checking the signature of a commit or a
+// tag against the signing keys users uploaded
+type Verification struct {
+	Verified   bool                `json:"verified"`
+	Reason     string              `json:"reason"`
+	Signature  string              `json:"signature,omitempty"`
+	Payload    string              `json:"payload,omitempty"`
+	Signer     *VerificationSigner `json:"signer,omitempty"`
+	VerifiedAt *time.Time          `json:"verified_at,omitempty"`
+}
+
+// VerificationSigner identifies the key that made a valid signature and
+// the user owning it
+type VerificationSigner struct {
+	Login   string `json:"login"`
+	KeyType string `json:"key_type"`
+	KeyID   string `json:"key_id"`
+}
+
+// signedObject is implemented by the go-git commit and tag objects
+type signedObject interface {
+	EncodeWithoutSignature(o plumbing.EncodedObject) error
+}
+
+// extractSignature returns the signature of obj, or nil when it is unsigned
+func extractSignature(obj signedObject, armored string) (*Signature, error) {
+	if armored == "" {
+		return nil, nil
+	}
+	encoded := &plumbing.MemoryObject{}
+	if err := obj.EncodeWithoutSignature(encoded); err != nil {
+		return nil, fmt.Errorf("failed to encode signed payload: %w", err)
+	}
+	r, err := encoded.Reader()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signed payload: %w", err)
+	}
+	defer r.Close()
+	payload, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signed payload: %w", err)
+	}
+	return &Signature{Armored: armored, Payload: payload}, nil
+}
@@ -0,0 +1,181 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// Symbol kinds reported by the extractors
+const (
+	SymbolKindFunction  = "function"
+	SymbolKindMethod    = "method"
+	SymbolKindType      = "type"
+	SymbolKindClass     = "class"
+	SymbolKindInterface = "interface"
+	SymbolKindVariable  = "variable"
+	SymbolKindConstant  = "constant"
+)
+
+// Symbol is a single definition found in a source file
+type Symbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Line      int    `json:"line"`
+	Signature string `json:"signature"`
+}
+
+// SymbolReference is a single identifier occurrence in a source file
+type SymbolReference struct {
+	Name string `json:"name"`
+	Line int    `json:"line"`
+}
+
+// SymbolExtractor extracts definitions from the source of a single language.
+// Extractors are line-oriented approximations of ctags; they trade precision
+// for having no external tool dependency.
+type SymbolExtractor interface {
+	Language() string
+	ExtractDefinitions(content []byte) []Symbol
+}
+
+type symbolPattern struct {
+	re   *regexp.Regexp
+	kind string
+}
+
+// regexpExtractor matches each line against an ordered list of patterns; the
+// first capture group of the first matching pattern is the symbol name.
+type regexpExtractor struct {
+	language string
+	patterns []symbolPattern
+}
+
+func (e *regexpExtractor) Language() string {
+	return e.language
+}
+
+func (e *regexpExtractor) ExtractDefinitions(content []byte) []Symbol {
+	var symbols []Symbol
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		for _, p := range e.patterns {
+			if m := p.re.FindStringSubmatch(text); m != nil {
+				symbols = append(symbols, Symbol{
+					Name:      m[1],
+					Kind:      p.kind,
+					Line:      line,
+					Signature: strings.TrimSpace(text),
+				})
+				break
+			}
+		}
+	}
+	return symbols
+}
+
+var defaultSymbolExtractors = []SymbolExtractor{
+	&regexpExtractor{language: "Go", patterns: []symbolPattern{
+		{regexp.MustCompile(`^func\s+\([^)]*\)\s+([A-Za-z_]\w*)`), SymbolKindMethod},
+		{regexp.MustCompile(`^func\s+([A-Za-z_]\w*)`), SymbolKindFunction},
+		{regexp.MustCompile(`^type\s+([A-Za-z_]\w*)\s+interface\b`), SymbolKindInterface},
+		{regexp.MustCompile(`^type\s+([A-Za-z_]\w*)`), SymbolKindType},
+		{regexp.MustCompile(`^const\s+([A-Za-z_]\w*)`), SymbolKindConstant},
+		{regexp.MustCompile(`^var\s+([A-Za-z_]\w*)`), SymbolKindVariable},
+	}},
+	&regexpExtractor{language: "Python", patterns: []symbolPattern{
+		{regexp.MustCompile(`^\s+(?:async\s+)?def\s+([A-Za-z_]\w*)`), SymbolKindMethod},
+		{regexp.MustCompile(`^(?:async\s+)?def\s+([A-Za-z_]\w*)`), SymbolKindFunction},
+		{regexp.MustCompile(`^\s*class\s+([A-Za-z_]\w*)`), SymbolKindClass},
+	}},
+	&regexpExtractor{language: "JavaScript", patterns: javaScriptSymbolPatterns()},
+	&regexpExtractor{language: "TypeScript", patterns: append([]symbolPattern{
+		{regexp.MustCompile(`^\s*(?:export\s+)?interface\s+([A-Za-z_$][\w$]*)`), SymbolKindInterface},
+		{regexp.MustCompile(`^\s*(?:export\s+)?type\s+([A-Za-z_$][\w$]*)\s*=`), SymbolKindType},
+	}, javaScriptSymbolPatterns()...)},
+	&regexpExtractor{language: "Java", patterns: []symbolPattern{
+		{regexp.MustCompile(`^\s*(?:(?:public|protected|private|abstract|final|static)\s+)*class\s+([A-Za-z_]\w*)`), SymbolKindClass},
+		{regexp.MustCompile(`^\s*(?:(?:public|protected|private|abstract|static)\s+)*interface\s+([A-Za-z_]\w*)`), SymbolKindInterface},
+		{regexp.MustCompile(`^\s*(?:(?:public|protected|private|static)\s+)*enum\s+([A-Za-z_]\w*)`), SymbolKindType},
+		{regexp.MustCompile(`^\s*(?:(?:public|protected|private|abstract|final|static|synchronized)\s+)+[\w<>\[\],\s]+\s+([A-Za-z_]\w*)\s*\(`), SymbolKindMethod},
+	}},
+}
+
+func javaScriptSymbolPatterns() []symbolPattern {
+	return []symbolPattern{
+		{regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*([A-Za-z_$][\w$]*)`), SymbolKindFunction},
+		{regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+([A-Za-z_$][\w$]*)`), SymbolKindClass},
+		{regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+([A-Za-z_$][\w$]*)\s*=\s*(?:async\s+)?(?:function|\([^)]*\)\s*=>|[A-Za-z_$][\w$]*\s*=>)`), SymbolKindFunction},
+		{regexp.MustCompile(`^\s*(?:export\s+)?const\s+([A-Za-z_$][\w$]*)`), SymbolKindConstant},
+	}
+}
+
+// SymbolExtractorRegistry selects an extractor by detected language
+type SymbolExtractorRegistry struct {
+	extractors map[string]SymbolExtractor
+}
+
+// NewSymbolExtractorRegistry returns the built-in extractors restricted to
+// languages. An empty list enables every built-in language.
+func NewSymbolExtractorRegistry(languages []string) *SymbolExtractorRegistry {
+	enabled := make(map[string]bool, len(languages))
+	for _, l := range languages {
+		enabled[strings.ToLower(l)] = true
+	}
+
+	r := &SymbolExtractorRegistry{extractors: make(map[string]SymbolExtractor)}
+	for _, e := range defaultSymbolExtractors {
+		if len(enabled) == 0 || enabled[strings.ToLower(e.Language())] {
+			r.Register(e)
+		}
+	}
+	return r
+}
+
+// Register adds or replaces the extractor for its language
+func (r *SymbolExtractorRegistry) Register(e SymbolExtractor) {
+	r.extractors[e.Language()] = e
+}
+
+// For returns the extractor for language, if enabled
+func (r *SymbolExtractorRegistry) For(language string) (SymbolExtractor, bool) {
+	e, ok := r.extractors[language]
+	return e, ok
+}
+
+// Languages returns the enabled languages
+func (r *SymbolExtractorRegistry) Languages() []string {
+	languages := make([]string, 0, len(r.extractors))
+	for l := range r.extractors {
+		languages = append(languages, l)
+	}
+	return languages
+}
+
+var identifierPattern = regexp.MustCompile(`[A-Za-z_$][\w$]*`)
+
+// ExtractReferences returns every occurrence of a name in known, keeping one
+// entry per name and line. Restricting to known names keeps the index to
+// identifiers that resolve to a definition somewhere in the repository.
+func ExtractReferences(content []byte, known map[string]bool) []SymbolReference {
+	var refs []SymbolReference
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		seen := make(map[string]bool)
+		for _, name := range identifierPattern.FindAllString(scanner.Text(), -1) {
+			if known[name] && !seen[name] {
+				seen[name] = true
+				refs = append(refs, SymbolReference{Name: name, Line: line})
+			}
+		}
+	}
+	return refs
+}
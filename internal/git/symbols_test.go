@@ -0,0 +1,55 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractGoDefinitions(t *testing.T) {
+	src := []byte(`package demo
+
+type Store interface {
+	Get(id string) error
+}
+
+type memStore struct{}
+
+func (m *memStore) Get(id string) error { return nil }
+
+func NewStore() Store { return &memStore{} }
+
+const Version = "1"
+`)
+	registry := NewSymbolExtractorRegistry(nil)
+	extractor, ok := registry.For("Go")
+	require.True(t, ok)
+
+	got := extractor.ExtractDefinitions(src)
+	assert.Equal(t, []Symbol{
+		{Name: "Store", Kind: SymbolKindInterface, Line: 3, Signature: "type Store interface {"},
+		{Name: "memStore", Kind: SymbolKindType, Line: 7, Signature: "type memStore struct{}"},
+		{Name: "Get", Kind: SymbolKindMethod, Line: 9, Signature: "func (m *memStore) Get(id string) error { return nil }"},
+		{Name: "NewStore", Kind: SymbolKindFunction, Line: 11, Signature: "func NewStore() Store { return &memStore{} }"},
+		{Name: "Version", Kind: SymbolKindConstant, Line: 13, Signature: `const Version = "1"`},
+	}, got)
+}
+
+func TestSymbolExtractorRegistryLanguages(t *testing.T) {
+	registry := NewSymbolExtractorRegistry([]string{"python"})
+	_, ok := registry.For("Python")
+	assert.True(t, ok)
+	_, ok = registry.For("Go")
+	assert.False(t, ok)
+}
+
+func TestExtractReferences(t *testing.T) {
+	src := []byte("s := NewStore()\nif err := s.Get(NewStore); err != nil {}\n")
+	refs := ExtractReferences(src, map[string]bool{"NewStore": true, "Get": true})
+	assert.Equal(t, []SymbolReference{
+		{Name: "NewStore", Line: 1},
+		{Name: "Get", Line: 2},
+		{Name: "NewStore", Line: 2},
+	}, refs)
+}
@@ -0,0 +1,89 @@
+package git
+
+import (
+	"net/mail"
+	"strings"
+)
+
+// Trailer keys the hub attributes contributions from
+const (
+	TrailerCoAuthoredBy = "Co-authored-by"
+	TrailerSignedOffBy  = "Signed-off-by"
+	TrailerReviewedBy   = "Reviewed-by"
+)
+
+// CommitTrailer is a "Key: value" line from the trailer block that ends a
+// commit message
+type CommitTrailer struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Identity parses a trailer value of the form "Name <email>"
+func (t CommitTrailer) Identity() (name, email string, ok bool) {
+	addr, err := mail.ParseAddress(t.Value)
+	if err != nil {
+		return "", "", false
+	}
+	return addr.Name, strings.ToLower(addr.Address), true
+}
+
+// ParseTrailers returns the trailers of a commit message, spelling the
+// well-known keys as the Trailer constants do. Like git
+// interpret-trailers, only the last paragraph is considered, it must not be
+// the subject, and every line in it must be a trailer, a continuation of
+// one, or a cherry-pick note.
+func ParseTrailers(message string) []CommitTrailer {
+	message = strings.TrimRight(strings.ReplaceAll(message, "\r\n", "\n"), "\n ")
+	sep := strings.LastIndex(message, "\n\n")
+	if sep < 0 {
+		return nil
+	}
+
+	var trailers []CommitTrailer
+	for _, line := range strings.Split(message[sep+2:], "\n") {
+		switch {
+		case strings.TrimSpace(line) == "":
+			continue
+		case line[0] == ' ' || line[0] == '\t':
+			if len(trailers) == 0 {
+				return nil
+			}
+			last := &trailers[len(trailers)-1]
+			last.Value += " " + strings.TrimSpace(line)
+		case strings.HasPrefix(line, "(cherry picked from commit "):
+			continue
+		default:
+			key, value, found := strings.Cut(line, ":")
+			if !found || !isTrailerKey(key) {
+				return nil
+			}
+			trailers = append(trailers, CommitTrailer{Key: canonicalTrailerKey(key), Value: strings.TrimSpace(value)})
+		}
+	}
+	return trailers
+}
+
+// canonicalTrailerKey spells the well-known keys consistently
+func canonicalTrailerKey(key string) string {
+	for _, known := range []string{TrailerCoAuthoredBy, TrailerSignedOffBy, TrailerReviewedBy} {
+		if strings.EqualFold(key, known) {
+			return known
+		}
+	}
+	return key
+}
+
+// isTrailerKey reports whether s is a trailer token: letters, digits and
+// hyphens, not starting with a hyphen
+func isTrailerKey(s string) bool {
+	if s == "" || s[0] == '-' {
+		return false
+	}
+	for _, r := range s {
+		if !(r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z') {
+			return false
+		}
+	}
+	return true
+}
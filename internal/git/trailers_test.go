@@ -0,0 +1,34 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTrailers(t *testing.T) {
+	message := "Fix login redirect\n\nThe session cookie was dropped.\n\n" +
+		"co-authored-by: Jane Doe <Jane@Example.com>\n" +
+		"Signed-off-by: John Roe <john@example.com>\n" +
+		"Reviewed-by: Max\n  Mustermann <max@example.com>\n" +
+		"(cherry picked from commit 0123456)\n"
+
+	trailers := ParseTrailers(message)
+	assert.Equal(t, []CommitTrailer{
+		{Key: TrailerCoAuthoredBy, Value: "Jane Doe <Jane@Example.com>"},
+		{Key: TrailerSignedOffBy, Value: "John Roe <john@example.com>"},
+		{Key: TrailerReviewedBy, Value: "Max Mustermann <max@example.com>"},
+	}, trailers)
+
+	name, email, ok := trailers[0].Identity()
+	assert.True(t, ok)
+	assert.Equal(t, "Jane Doe", name)
+	assert.Equal(t, "jane@example.com", email)
+	_, _, ok = CommitTrailer{Key: "Fixes", Value: "#12"}.Identity()
+	assert.False(t, ok)
+
+	// The subject is never a trailer block, and prose disqualifies one
+	assert.Empty(t, ParseTrailers("Signed-off-by: John Roe <john@example.com>"))
+	assert.Empty(t, ParseTrailers("Subject\n\nSigned-off-by: John Roe <john@example.com>\nand some prose"))
+	assert.Empty(t, ParseTrailers("Subject\n\nSee https://example.com: it explains"))
+}
@@ -0,0 +1,259 @@
+// Package i18n localizes server-generated text (notifications, emails and
+// API error strings). Catalogs are embedded JSON files keyed by message ID;
+// a message is either a string or an object of CLDR plural forms.
+package i18n
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is used when no supported locale can be resolved
+const DefaultLocale = "en"
+
+//go:embed locales/*.json
+var catalogFS embed.FS
+
+// message is a catalog entry; Other doubles as the singular-less form
+type message struct {
+	Zero  string `json:"zero,omitempty"`
+	One   string `json:"one,omitempty"`
+	Few   string `json:"few,omitempty"`
+	Many  string `json:"many,omitempty"`
+	Other string `json:"other"`
+}
+
+func (m *message) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		m.Other = s
+		return nil
+	}
+	type plain message
+	return json.Unmarshal(data, (*plain)(m))
+}
+
+func (m *message) form(category string) string {
+	var s string
+	switch category {
+	case "zero":
+		s = m.Zero
+	case "one":
+		s = m.One
+	case "few":
+		s = m.Few
+	case "many":
+		s = m.Many
+	}
+	if s == "" {
+		s = m.Other
+	}
+	return s
+}
+
+// Bundle holds the message catalogs of every supported locale
+type Bundle struct {
+	catalogs map[string]map[string]message
+	matcher  language.Matcher
+	locales  []string
+}
+
+// NewBundle loads the embedded catalogs
+func NewBundle() (*Bundle, error) {
+	entries, err := catalogFS.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bundle{catalogs: make(map[string]map[string]message)}
+	for _, entry := range entries {
+		locale := strings.TrimSuffix(entry.Name(), ".json")
+		data, err := catalogFS.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		catalog := make(map[string]message)
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			return nil, fmt.Errorf("invalid catalog %s: %w", entry.Name(), err)
+		}
+		b.catalogs[locale] = catalog
+	}
+	if _, ok := b.catalogs[DefaultLocale]; !ok {
+		return nil, fmt.Errorf("missing %s catalog", DefaultLocale)
+	}
+
+	// The default locale goes first so the matcher falls back to it
+	b.locales = []string{DefaultLocale}
+	for locale := range b.catalogs {
+		if locale != DefaultLocale {
+			b.locales = append(b.locales, locale)
+		}
+	}
+	sort.Strings(b.locales[1:])
+	tags := make([]language.Tag, len(b.locales))
+	for i, locale := range b.locales {
+		tags[i] = language.Make(locale)
+	}
+	b.matcher = language.NewMatcher(tags)
+
+	return b, nil
+}
+
+// MustNewBundle is like NewBundle but panics on invalid embedded catalogs
+func MustNewBundle() *Bundle {
+	b, err := NewBundle()
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+var (
+	defaultBundle     *Bundle
+	defaultBundleOnce sync.Once
+)
+
+// Default returns the process-wide bundle of embedded catalogs
+func Default() *Bundle {
+	defaultBundleOnce.Do(func() {
+		defaultBundle = MustNewBundle()
+	})
+	return defaultBundle
+}
+
+// Locales returns the supported locales, default first
+func (b *Bundle) Locales() []string {
+	return append([]string(nil), b.locales...)
+}
+
+// Supports reports whether locale has a catalog
+func (b *Bundle) Supports(locale string) bool {
+	_, ok := b.catalogs[locale]
+	return ok
+}
+
+// Resolve picks the best supported locale, preferring the user's stored
+// preference over the Accept-Language header
+func (b *Bundle) Resolve(preference, acceptLanguage string) string {
+	var tags []language.Tag
+	if preference != "" {
+		if tag, err := language.Parse(preference); err == nil {
+			tags = append(tags, tag)
+		}
+	}
+	if acceptLanguage != "" {
+		if parsed, _, err := language.ParseAcceptLanguage(acceptLanguage); err == nil {
+			tags = append(tags, parsed...)
+		}
+	}
+	if len(tags) == 0 {
+		return DefaultLocale
+	}
+
+	_, index, confidence := b.matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLocale
+	}
+	return b.locales[index]
+}
+
+// Localizer returns a localizer for locale, falling back to the default
+func (b *Bundle) Localizer(locale string) *Localizer {
+	if !b.Supports(locale) {
+		locale = DefaultLocale
+	}
+	return &Localizer{bundle: b, locale: locale}
+}
+
+// Localizer translates messages into a single locale
+type Localizer struct {
+	bundle *Bundle
+	locale string
+}
+
+// Locale returns the locale messages are translated into
+func (l *Localizer) Locale() string {
+	return l.locale
+}
+
+// T translates key, substituting {name} placeholders from args, which are
+// given as alternating name/value pairs. Unknown keys are returned as is.
+func (l *Localizer) T(key string, args ...interface{}) string {
+	msg, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+	return format(msg.Other, args)
+}
+
+// N translates the plural form of key selected by count. {count} is always
+// available as a placeholder.
+func (l *Localizer) N(key string, count int, args ...interface{}) string {
+	msg, ok := l.lookup(key)
+	if !ok {
+		return key
+	}
+	args = append([]interface{}{"count", count}, args...)
+	return format(msg.form(pluralCategory(l.locale, count)), args)
+}
+
+func (l *Localizer) lookup(key string) (message, bool) {
+	if msg, ok := l.bundle.catalogs[l.locale][key]; ok {
+		return msg, true
+	}
+	msg, ok := l.bundle.catalogs[DefaultLocale][key]
+	return msg, ok
+}
+
+func format(s string, args []interface{}) string {
+	if len(args) < 2 {
+		return s
+	}
+	pairs := make([]string, 0, len(args))
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, "{"+fmt.Sprint(args[i])+"}", fmt.Sprint(args[i+1]))
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// pluralCategory returns the CLDR cardinal category of n in locale. English
+// and Spanish share the same integer rule; languages with richer plural
+// systems get their own case here when their catalog is added.
+func pluralCategory(locale string, n int) string {
+	if n == 1 || n == -1 {
+		return "one"
+	}
+	return "other"
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l
+func NewContext(ctx context.Context, l *Localizer) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the localizer carried by ctx, if any
+func FromContext(ctx context.Context) (*Localizer, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	l, ok := ctx.Value(contextKey{}).(*Localizer)
+	return l, ok && l != nil
+}
+
+// For returns the localizer carried by ctx, or the default locale's
+func For(ctx context.Context) *Localizer {
+	if l, ok := FromContext(ctx); ok {
+		return l
+	}
+	return Default().Localizer(DefaultLocale)
+}
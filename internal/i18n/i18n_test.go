@@ -0,0 +1,57 @@
+package i18n
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	b := MustNewBundle()
+
+	assert.Equal(t, "en", b.Resolve("", ""))
+	assert.Equal(t, "es", b.Resolve("", "es-MX,es;q=0.9,en;q=0.8"))
+	assert.Equal(t, "en", b.Resolve("", "de-DE,en;q=0.5"))
+	assert.Equal(t, "en", b.Resolve("", "ja"))
+	// Stored preference wins over the header
+	assert.Equal(t, "es", b.Resolve("es", "en-US"))
+	assert.Equal(t, "en", b.Resolve("not a locale", ""))
+}
+
+func TestLocalizer(t *testing.T) {
+	b := MustNewBundle()
+	en := b.Localizer("en")
+	es := b.Localizer("es")
+
+	assert.Equal(t, "merged by alice", en.T("digest.merged_by", "actor", "alice"))
+	assert.Equal(t, "fusionada por alice", es.T("digest.merged_by", "actor", "alice"))
+
+	assert.Equal(t, "1 new comment", en.N("digest.new_comments", 1))
+	assert.Equal(t, "3 new comments", en.N("digest.new_comments", 3))
+	assert.Equal(t, "0 comentarios nuevos", es.N("digest.new_comments", 0))
+
+	// Unknown keys fall through unchanged; unknown locales use the default
+	assert.Equal(t, "missing.key", es.T("missing.key"))
+	assert.Equal(t, "en", b.Localizer("fr").Locale())
+}
+
+func TestCatalogsAreComplete(t *testing.T) {
+	b := MustNewBundle()
+	for _, locale := range b.Locales() {
+		for key := range b.catalogs[DefaultLocale] {
+			_, ok := b.catalogs[locale][key]
+			assert.True(t, ok, "%s is missing %s", locale, key)
+		}
+	}
+}
+
+func TestContext(t *testing.T) {
+	assert.Equal(t, DefaultLocale, For(context.Background()).Locale())
+
+	l := Default().Localizer("es")
+	got, ok := FromContext(NewContext(context.Background(), l))
+	require.True(t, ok)
+	assert.Same(t, l, got)
+}
@@ -0,0 +1,79 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Background collects the long-running work of a server: schedulers that
+// run on the elected replica only and loops every replica runs. Work is
+// registered while the server is wired up and started once with the
+// server's context, so all of it stops on shutdown and nothing runs in
+// processes that only build the routes.
+type Background struct {
+	elector *Elector
+	tasks   []func(ctx context.Context)
+	wg      sync.WaitGroup
+}
+
+// NewBackground creates an empty Background electing through elector
+func NewBackground(elector *Elector) *Background {
+	return &Background{elector: elector}
+}
+
+// Elected registers a scheduler that runs while this replica holds the
+// named lease
+func (b *Background) Elected(name string, scheduler func(ctx context.Context)) {
+	b.Go(func(ctx context.Context) {
+		b.elector.Run(ctx, name, scheduler)
+	})
+}
+
+// Every registers a scheduler that calls tick once per interval while this
+// replica holds the named lease
+func (b *Background) Every(name string, interval time.Duration, tick func(ctx context.Context, now time.Time)) {
+	b.Elected(name, func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				tick(ctx, now)
+			}
+		}
+	})
+}
+
+// Go registers work every replica runs until its context is cancelled
+func (b *Background) Go(run func(ctx context.Context)) {
+	b.tasks = append(b.tasks, run)
+}
+
+// Start runs every registered task in its own goroutine
+func (b *Background) Start(ctx context.Context) {
+	for _, task := range b.tasks {
+		b.wg.Add(1)
+		go func(task func(ctx context.Context)) {
+			defer b.wg.Done()
+			task(ctx)
+		}(task)
+	}
+}
+
+// Wait blocks until every started task has returned, or ctx is done
+func (b *Background) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		b.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
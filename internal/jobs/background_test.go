@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackground(t *testing.T) {
+	background := NewBackground(NewElector(nil, logrus.New()))
+	var started, stopped atomic.Int32
+	run := func(ctx context.Context) {
+		started.Add(1)
+		<-ctx.Done()
+		stopped.Add(1)
+	}
+	background.Elected("digests", run)
+	background.Go(run)
+	assert.Zero(t, started.Load(), "nothing runs before Start")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	background.Start(ctx)
+	cancel()
+	assert.NoError(t, background.Wait(context.Background()))
+	assert.EqualValues(t, 2, started.Load())
+	assert.EqualValues(t, 2, stopped.Load())
+}
+
+func TestBackgroundEvery(t *testing.T) {
+	background := NewBackground(NewElector(nil, logrus.New()))
+	ctx, cancel := context.WithCancel(context.Background())
+	var ticks atomic.Int32
+	background.Every("ticks", time.Millisecond, func(context.Context, time.Time) {
+		if ticks.Add(1) == 3 {
+			cancel()
+		}
+	})
+
+	background.Start(ctx)
+	waitCtx, done := context.WithTimeout(context.Background(), 5*time.Second)
+	defer done()
+	assert.NoError(t, background.Wait(waitCtx))
+	assert.GreaterOrEqual(t, ticks.Load(), int32(3))
+}
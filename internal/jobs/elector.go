@@ -0,0 +1,159 @@
+// Package jobs coordinates background schedulers across horizontally
+// scaled server replicas.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultLeaseTTL is how long a replica leads a scheduler without renewing
+// its lease. A crashed leader is replaced after at most this long.
+const DefaultLeaseTTL = 30 * time.Second
+
+// Elector elects one replica to run each named scheduler. Leadership is a
+// lease row in the database that the leader renews at a third of its TTL;
+// replicas that lose or cannot win the lease stay idle and retry, so every
+// scheduler runs on exactly one replica at a time.
+//
+// Leases compare expiry times across replicas, so server clocks are assumed
+// to agree to well within the TTL.
+type Elector struct {
+	db     *gorm.DB
+	holder string
+	ttl    time.Duration
+	logger *logrus.Logger
+	now    func() time.Time
+}
+
+// NewElector creates an elector identifying this process by host name and
+// pid. A nil db elects every replica, for single-process deployments.
+func NewElector(db *gorm.DB, logger *logrus.Logger) *Elector {
+	return &Elector{
+		db:     db,
+		holder: Holder(),
+		ttl:    DefaultLeaseTTL,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Holder identifies this process to other replicas
+func Holder() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s:%d", hostname, os.Getpid())
+}
+
+// Run campaigns for the named lease and runs the scheduler while this
+// replica holds it. The scheduler's context is cancelled when leadership
+// is lost, after which Run campaigns again. Run returns when ctx is
+// cancelled or the scheduler returns on its own.
+func (e *Elector) Run(ctx context.Context, name string, scheduler func(ctx context.Context)) {
+	if e.db == nil {
+		scheduler(ctx)
+		return
+	}
+
+	retry := time.NewTicker(e.ttl / 3)
+	defer retry.Stop()
+	for {
+		leader, err := e.acquire(ctx, name)
+		if err != nil {
+			e.logger.WithError(err).WithField("scheduler", name).Warn("Failed to acquire scheduler lease")
+		}
+		if leader {
+			if done := e.lead(ctx, name, scheduler); done {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-retry.C:
+		}
+	}
+}
+
+// lead runs the scheduler while renewing the lease. It reports whether Run
+// is finished: ctx was cancelled or the scheduler returned.
+func (e *Elector) lead(ctx context.Context, name string, scheduler func(ctx context.Context)) bool {
+	e.logger.WithFields(logrus.Fields{"scheduler": name, "holder": e.holder}).Info("Acquired scheduler lease")
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		scheduler(runCtx)
+	}()
+
+	renew := time.NewTicker(e.ttl / 3)
+	defer renew.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			cancel()
+			<-stopped
+			e.release(name)
+			return true
+		case <-stopped:
+			e.release(name)
+			return true
+		case <-renew.C:
+			leader, err := e.acquire(ctx, name)
+			if err != nil || !leader {
+				// Stop before the lease can expire and pass to another replica
+				e.logger.WithError(err).WithField("scheduler", name).Warn("Lost scheduler lease")
+				cancel()
+				<-stopped
+				return false
+			}
+		}
+	}
+}
+
+// acquire takes or renews the lease, succeeding when it is free, expired
+// or already held by this replica
+func (e *Elector) acquire(ctx context.Context, name string) (bool, error) {
+	now := e.now()
+	db := e.db.WithContext(ctx)
+	result := db.Model(&models.SchedulerLease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, e.holder, now).
+		Updates(map[string]interface{}{"holder": e.holder, "expires_at": now.Add(e.ttl)})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	result = db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.SchedulerLease{Name: name, Holder: e.holder, ExpiresAt: now.Add(e.ttl)})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// release expires the lease so another replica can take over immediately
+func (e *Elector) release(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := e.db.WithContext(ctx).Model(&models.SchedulerLease{}).
+		Where("name = ? AND holder = ?", name, e.holder).
+		Update("expires_at", e.now().Add(-time.Second)).Error
+	if err != nil {
+		e.logger.WithError(err).WithField("scheduler", name).Warn("Failed to release scheduler lease")
+	}
+}
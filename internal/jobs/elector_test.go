@@ -0,0 +1,107 @@
+package jobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestElectors(t *testing.T, holders ...string) []*Elector {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// Every connection to :memory: is a separate database
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&models.SchedulerLease{}))
+
+	var electors []*Elector
+	for _, holder := range holders {
+		elector := NewElector(db, logrus.New())
+		elector.holder = holder
+		electors = append(electors, elector)
+	}
+	return electors
+}
+
+func TestElector_Acquire(t *testing.T) {
+	electors := newTestElectors(t, "a", "b")
+	a, b := electors[0], electors[1]
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+	b.now = func() time.Time { return now }
+
+	ok, err := a.acquire(ctx, "digests")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = b.acquire(ctx, "digests")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// The holder renews; other schedulers are independent
+	ok, err = a.acquire(ctx, "digests")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = b.acquire(ctx, "retention")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// An expired lease passes to another replica
+	now = now.Add(DefaultLeaseTTL + time.Second)
+	ok, err = b.acquire(ctx, "digests")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = a.acquire(ctx, "digests")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	b.release("digests")
+	ok, err = a.acquire(ctx, "digests")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestElector_RunsSchedulerOnOneReplica(t *testing.T) {
+	electors := newTestElectors(t, "a", "b", "c")
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var running, maxRunning, started int32
+	scheduler := func(ctx context.Context) {
+		atomic.AddInt32(&started, 1)
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		<-ctx.Done()
+		atomic.AddInt32(&running, -1)
+	}
+
+	done := make(chan struct{})
+	for _, elector := range electors {
+		elector.ttl = 60 * time.Millisecond
+		go func(e *Elector) {
+			e.Run(ctx, "digests", scheduler)
+			done <- struct{}{}
+		}(elector)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+	for range electors {
+		<-done
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&started))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&maxRunning))
+}
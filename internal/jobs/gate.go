@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Classes of heavy background jobs whose timing operators can restrict
+const (
+	ClassGC       = "gc"
+	ClassReindex  = "reindex"
+	ClassArchival = "archival"
+	ClassBackup   = "backup"
+)
+
+// Classes lists every job class
+var Classes = []string{ClassGC, ClassReindex, ClassArchival, ClassBackup}
+
+// Job describes a heavy background job to a Gate
+type Job struct {
+	Name  string
+	Class string
+	// Interval between scheduled runs; zero for jobs run on demand
+	Interval time.Duration
+}
+
+// Gate holds heavy jobs back until their class is allowed to run
+type Gate interface {
+	// Wait blocks until job may run, returning an error only when ctx is
+	// done first
+	Wait(ctx context.Context, job Job) error
+}
+
+type openGate struct{}
+
+func (openGate) Wait(ctx context.Context, job Job) error {
+	return ctx.Err()
+}
+
+var (
+	gateMu      sync.RWMutex
+	defaultGate Gate = openGate{}
+)
+
+// SetDefaultGate sets the process wide Gate consulted by schedulers; nil
+// lets every job run immediately
+func SetDefaultGate(g Gate) {
+	if g == nil {
+		g = openGate{}
+	}
+	gateMu.Lock()
+	defaultGate = g
+	gateMu.Unlock()
+}
+
+// DefaultGate returns the process wide Gate
+func DefaultGate() Gate {
+	gateMu.RLock()
+	defer gateMu.RUnlock()
+	return defaultGate
+}
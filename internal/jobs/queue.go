@@ -0,0 +1,516 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownJobKind is returned when enqueuing a kind with no handler
+	ErrUnknownJobKind = errors.New("unknown job kind")
+	// ErrJobNotFound is returned for jobs that do not exist
+	ErrJobNotFound = errors.New("job not found")
+	// ErrJobState is returned when a job cannot be retried or cancelled in
+	// its current state
+	ErrJobState = errors.New("job cannot be changed in its current state")
+)
+
+// Handler runs one job. Returned errors are retried according to the
+// kind's RetryPolicy unless wrapped with Permanent.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// RetryPolicy says how often and when a failed job is tried again, and how
+// long one attempt may run. Retries back off exponentially from Backoff up
+// to MaxBackoff.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+	MaxBackoff  time.Duration
+	Timeout     time.Duration
+}
+
+// DefaultRetryPolicy tries a job five times over roughly half an hour
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 5, Backoff: 30 * time.Second, MaxBackoff: time.Hour, Timeout: 10 * time.Minute}
+
+// delay is the wait before the attempt after the given number of attempts
+func (p RetryPolicy) delay(attempts int) time.Duration {
+	d := time.Duration(float64(p.Backoff) * math.Pow(2, float64(attempts-1)))
+	if d > p.MaxBackoff || d <= 0 {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks a handler error as not worth retrying
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+// Waker wakes idle workers when jobs are enqueued, so they need not wait
+// for the next poll
+type Waker interface {
+	Wake(ctx context.Context)
+	// Wait blocks until woken, ctx is done or timeout passes
+	Wait(ctx context.Context, timeout time.Duration)
+}
+
+// localWaker wakes workers in this process only
+type localWaker struct {
+	ch chan struct{}
+}
+
+func newLocalWaker() *localWaker {
+	return &localWaker{ch: make(chan struct{}, 1)}
+}
+
+func (w *localWaker) Wake(ctx context.Context) {
+	select {
+	case w.ch <- struct{}{}:
+	default:
+	}
+}
+
+func (w *localWaker) Wait(ctx context.Context, timeout time.Duration) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	case <-w.ch:
+	}
+}
+
+// EnqueueOptions delay a job or coalesce it with pending work
+type EnqueueOptions struct {
+	// Delay postpones the first attempt
+	Delay time.Duration
+	// DedupeKey returns the pending job of the same kind and key, if any,
+	// instead of adding another one
+	DedupeKey string
+}
+
+// QueueFilter selects jobs to inspect; the zero value lists the newest jobs
+type QueueFilter struct {
+	State   models.QueuedJobState
+	Kind    string
+	Page    int
+	PerPage int
+}
+
+// QueueStats counts jobs by state, and pending jobs by kind
+type QueueStats struct {
+	States        map[models.QueuedJobState]int64 `json:"states"`
+	PendingByKind map[string]int64                `json:"pending_by_kind"`
+	// OldestPendingAt is the run time of the job waiting longest
+	OldestPendingAt *time.Time `json:"oldest_pending_at"`
+}
+
+type registration struct {
+	handler Handler
+	policy  RetryPolicy
+}
+
+// Queue is a database-backed queue of queued jobs run by a pool of
+// workers. Every replica may run workers; a job is claimed by setting its
+// lock with a conditional update, so each attempt runs on one worker.
+// Jobs survive restarts, and jobs of a worker that died are retried once
+// their lock lapses.
+type Queue struct {
+	db       *gorm.DB
+	waker    Waker
+	logger   *logrus.Logger
+	holder   string
+	interval time.Duration
+	now      func() time.Time
+
+	mu       sync.RWMutex
+	handlers map[string]registration
+}
+
+// NewQueue creates a queue polling for due jobs every interval. A nil
+// waker only wakes workers of this process on enqueue; other replicas
+// pick jobs up at their next poll.
+func NewQueue(db *gorm.DB, waker Waker, interval time.Duration, logger *logrus.Logger) *Queue {
+	if waker == nil {
+		waker = newLocalWaker()
+	}
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	return &Queue{
+		db:       db,
+		waker:    waker,
+		logger:   logger,
+		holder:   Holder(),
+		interval: interval,
+		now:      time.Now,
+		handlers: map[string]registration{},
+	}
+}
+
+// Register sets the handler and retry policy of a job kind. Zero policy
+// fields take the DefaultRetryPolicy values.
+func (q *Queue) Register(kind string, policy RetryPolicy, handler Handler) {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultRetryPolicy.MaxAttempts
+	}
+	if policy.Backoff <= 0 {
+		policy.Backoff = DefaultRetryPolicy.Backoff
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = DefaultRetryPolicy.MaxBackoff
+	}
+	if policy.Timeout <= 0 {
+		policy.Timeout = DefaultRetryPolicy.Timeout
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = registration{handler: handler, policy: policy}
+}
+
+func (q *Queue) registration(kind string) (registration, bool) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	r, ok := q.handlers[kind]
+	return r, ok
+}
+
+func (q *Queue) kinds() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// Enqueue adds a job whose payload is marshalled to JSON
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload interface{}, opts EnqueueOptions) (*models.QueuedJob, error) {
+	reg, ok := q.registration(kind)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownJobKind, kind)
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode job payload: %w", err)
+	}
+
+	db := q.db.WithContext(ctx)
+	if opts.DedupeKey != "" {
+		var pending models.QueuedJob
+		err := db.Where("kind = ? AND dedupe_key = ? AND state = ?", kind, opts.DedupeKey, models.JobPending).First(&pending).Error
+		if err == nil {
+			return &pending, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to check pending jobs: %w", err)
+		}
+	}
+
+	job := &models.QueuedJob{
+		ID:          uuid.New(),
+		Kind:        kind,
+		Payload:     string(data),
+		State:       models.JobPending,
+		RunAt:       q.now().Add(opts.Delay),
+		DedupeKey:   opts.DedupeKey,
+		MaxAttempts: reg.policy.MaxAttempts,
+	}
+	if err := db.Create(job).Error; err != nil {
+		return nil, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	if opts.Delay <= 0 {
+		q.waker.Wake(ctx)
+	}
+	return job, nil
+}
+
+// Start runs workers until ctx is cancelled, then waits for the jobs they
+// are running to finish
+func (q *Queue) Start(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			q.work(ctx, fmt.Sprintf("%s/%d", q.holder, worker))
+		}(i)
+	}
+	q.logger.WithField("workers", workers).Info("Job queue workers started")
+	wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context, worker string) {
+	for ctx.Err() == nil {
+		ran, err := q.RunNext(ctx, worker)
+		if err != nil {
+			q.logger.WithError(err).Warn("Failed to claim job")
+		}
+		if !ran {
+			q.waker.Wait(ctx, q.interval)
+		}
+	}
+}
+
+// RunNext claims and runs one due job, reporting whether there was one
+func (q *Queue) RunNext(ctx context.Context, worker string) (bool, error) {
+	job, err := q.claim(ctx, worker)
+	if err != nil || job == nil {
+		return false, err
+	}
+	q.run(ctx, job)
+	return true, nil
+}
+
+// claim locks the oldest due job of a registered kind for worker. Pending
+// jobs and running jobs whose lock lapsed are due.
+func (q *Queue) claim(ctx context.Context, worker string) (*models.QueuedJob, error) {
+	kinds := q.kinds()
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+	db := q.db.WithContext(ctx)
+	now := q.now()
+	var candidates []models.QueuedJob
+	if err := db.Where("kind IN ? AND ((state = ? AND run_at <= ?) OR (state = ? AND locked_until < ?))",
+		kinds, models.JobPending, now, models.JobRunning, now).
+		Order("run_at").Limit(10).Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		reg, _ := q.registration(candidate.Kind)
+		lockedUntil := now.Add(reg.policy.Timeout + time.Minute)
+		// Only one worker's update matches the state and lock it read
+		query := db.Model(&models.QueuedJob{}).Where("id = ? AND state = ?", candidate.ID, candidate.State)
+		if candidate.LockedUntil == nil {
+			query = query.Where("locked_until IS NULL")
+		} else {
+			query = query.Where("locked_until = ?", *candidate.LockedUntil)
+		}
+		result := query.Updates(map[string]interface{}{
+			"state":        models.JobRunning,
+			"locked_by":    worker,
+			"locked_until": lockedUntil,
+			"started_at":   now,
+			"attempts":     candidate.Attempts + 1,
+		})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			candidate.State = models.JobRunning
+			candidate.LockedBy = worker
+			candidate.LockedUntil = &lockedUntil
+			candidate.StartedAt = &now
+			candidate.Attempts++
+			return &candidate, nil
+		}
+	}
+	return nil, nil
+}
+
+func (q *Queue) run(ctx context.Context, job *models.QueuedJob) {
+	reg, _ := q.registration(job.Kind)
+	logger := q.logger.WithFields(logrus.Fields{"job_id": job.ID, "kind": job.Kind, "attempt": job.Attempts})
+
+	runCtx, cancel := context.WithTimeout(ctx, reg.policy.Timeout)
+	defer cancel()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				event := errorreporting.Default().NewPanicEvent(r)
+				event.Logger = "job_queue"
+				event.Tags["job_kind"] = job.Kind
+				errorreporting.Default().Capture(event)
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		return reg.handler(runCtx, json.RawMessage(job.Payload))
+	}()
+
+	now := q.now()
+	updates := map[string]interface{}{"locked_by": "", "locked_until": nil}
+	var permanent permanentError
+	switch {
+	case err == nil:
+		updates["state"] = models.JobSucceeded
+		updates["completed_at"] = now
+		updates["last_error"] = ""
+		logger.Debug("Job succeeded")
+	case errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts:
+		updates["state"] = models.JobFailed
+		updates["completed_at"] = now
+		updates["last_error"] = err.Error()
+		logger.WithError(err).Error("Job failed")
+	default:
+		updates["state"] = models.JobPending
+		updates["run_at"] = now.Add(reg.policy.delay(job.Attempts))
+		updates["last_error"] = err.Error()
+		logger.WithError(err).Warn("Job failed, will retry")
+	}
+
+	// The job's own context may be cancelled by now
+	saveCtx, saveCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer saveCancel()
+	if err := q.db.WithContext(saveCtx).Model(&models.QueuedJob{}).
+		Where("id = ? AND locked_by = ?", job.ID, job.LockedBy).Updates(updates).Error; err != nil {
+		logger.WithError(err).Error("Failed to record job result")
+	}
+}
+
+// List returns jobs matching filter, newest first, and their total count
+func (q *Queue) List(ctx context.Context, filter QueueFilter) ([]*models.QueuedJob, int64, error) {
+	query := q.db.WithContext(ctx).Model(&models.QueuedJob{})
+	if filter.State != "" {
+		query = query.Where("state = ?", filter.State)
+	}
+	if filter.Kind != "" {
+		query = query.Where("kind = ?", filter.Kind)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count jobs: %w", err)
+	}
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PerPage < 1 || filter.PerPage > 100 {
+		filter.PerPage = 30
+	}
+	var jobs []*models.QueuedJob
+	if err := query.Order("created_at DESC").Offset((filter.Page - 1) * filter.PerPage).Limit(filter.PerPage).Find(&jobs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	return jobs, total, nil
+}
+
+// Get returns one job
+func (q *Queue) Get(ctx context.Context, id uuid.UUID) (*models.QueuedJob, error) {
+	var job models.QueuedJob
+	err := q.db.WithContext(ctx).First(&job, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get job: %w", err)
+	}
+	return &job, nil
+}
+
+// Stats counts jobs by state and pending jobs by kind
+func (q *Queue) Stats(ctx context.Context) (*QueueStats, error) {
+	db := q.db.WithContext(ctx)
+	stats := &QueueStats{States: map[models.QueuedJobState]int64{}, PendingByKind: map[string]int64{}}
+
+	var states []struct {
+		State models.QueuedJobState
+		Count int64
+	}
+	if err := db.Model(&models.QueuedJob{}).Select("state, COUNT(*) AS count").Group("state").Scan(&states).Error; err != nil {
+		return nil, fmt.Errorf("failed to count jobs: %w", err)
+	}
+	for _, s := range states {
+		stats.States[s.State] = s.Count
+	}
+
+	var kinds []struct {
+		Kind  string
+		Count int64
+	}
+	if err := db.Model(&models.QueuedJob{}).Select("kind, COUNT(*) AS count").
+		Where("state = ?", models.JobPending).Group("kind").Scan(&kinds).Error; err != nil {
+		return nil, fmt.Errorf("failed to count pending jobs: %w", err)
+	}
+	for _, k := range kinds {
+		stats.PendingByKind[k.Kind] = k.Count
+	}
+
+	var oldest models.QueuedJob
+	err := db.Where("state = ?", models.JobPending).Order("run_at").First(&oldest).Error
+	if err == nil {
+		stats.OldestPendingAt = &oldest.RunAt
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to find oldest pending job: %w", err)
+	}
+	return stats, nil
+}
+
+// Retry queues a failed or cancelled job again with fresh attempts
+func (q *Queue) Retry(ctx context.Context, id uuid.UUID) (*models.QueuedJob, error) {
+	return q.transition(ctx, id, []models.QueuedJobState{models.JobFailed, models.JobCancelled}, map[string]interface{}{
+		"state": models.JobPending, "run_at": q.now(), "attempts": 0, "completed_at": nil,
+	})
+}
+
+// Cancel stops a pending job from running
+func (q *Queue) Cancel(ctx context.Context, id uuid.UUID) (*models.QueuedJob, error) {
+	return q.transition(ctx, id, []models.QueuedJobState{models.JobPending}, map[string]interface{}{
+		"state": models.JobCancelled, "completed_at": q.now(),
+	})
+}
+
+func (q *Queue) transition(ctx context.Context, id uuid.UUID, from []models.QueuedJobState, updates map[string]interface{}) (*models.QueuedJob, error) {
+	if _, err := q.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	result := q.db.WithContext(ctx).Model(&models.QueuedJob{}).Where("id = ? AND state IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update job: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrJobState
+	}
+	if updates["state"] == models.JobPending {
+		q.waker.Wake(ctx)
+	}
+	return q.Get(ctx, id)
+}
+
+// Purge deletes finished jobs completed before cutoff
+func (q *Queue) Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	result := q.db.WithContext(ctx).Where("state IN ? AND completed_at < ?",
+		[]models.QueuedJobState{models.JobSucceeded, models.JobFailed, models.JobCancelled}, cutoff).
+		Delete(&models.QueuedJob{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge jobs: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// StartPurger deletes finished jobs older than retention every hour until
+// ctx is cancelled
+func (q *Queue) StartPurger(ctx context.Context, retention time.Duration) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			purged, err := q.Purge(ctx, now.Add(-retention))
+			if err != nil {
+				q.logger.WithError(err).Error("Failed to purge finished jobs")
+			} else if purged > 0 {
+				q.logger.WithField("purged", purged).Info("Purged finished jobs")
+			}
+		}
+	}
+}
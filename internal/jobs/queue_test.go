@@ -0,0 +1,167 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestQueue(t *testing.T) (*Queue, *time.Time) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+	require.NoError(t, db.AutoMigrate(&models.QueuedJob{}))
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	queue := NewQueue(db, nil, time.Second, logrus.New())
+	queue.now = func() time.Time { return now }
+	return queue, &now
+}
+
+func TestQueue_RetriesWithBackoff(t *testing.T) {
+	queue, now := newTestQueue(t)
+	ctx := context.Background()
+
+	var seen []string
+	failures := 1
+	queue.Register("greet", RetryPolicy{MaxAttempts: 3, Backoff: time.Minute}, func(ctx context.Context, payload json.RawMessage) error {
+		var name string
+		require.NoError(t, json.Unmarshal(payload, &name))
+		if failures > 0 {
+			failures--
+			return errors.New("flaky")
+		}
+		seen = append(seen, name)
+		return nil
+	})
+
+	_, err := queue.Enqueue(ctx, "unknown", nil, EnqueueOptions{})
+	assert.ErrorIs(t, err, ErrUnknownJobKind)
+
+	job, err := queue.Enqueue(ctx, "greet", "ada", EnqueueOptions{DedupeKey: "ada"})
+	require.NoError(t, err)
+	again, err := queue.Enqueue(ctx, "greet", "ada", EnqueueOptions{DedupeKey: "ada"})
+	require.NoError(t, err)
+	assert.Equal(t, job.ID, again.ID, "pending jobs with the same key are coalesced")
+
+	ran, err := queue.RunNext(ctx, "w1")
+	require.NoError(t, err)
+	assert.True(t, ran)
+	job, err = queue.Get(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobPending, job.State)
+	assert.Equal(t, "flaky", job.LastError)
+	assert.Equal(t, now.Add(time.Minute), job.RunAt.UTC())
+
+	// Not due until the backoff passed
+	ran, err = queue.RunNext(ctx, "w1")
+	require.NoError(t, err)
+	assert.False(t, ran)
+
+	*now = now.Add(time.Minute)
+	ran, err = queue.RunNext(ctx, "w1")
+	require.NoError(t, err)
+	assert.True(t, ran)
+	job, err = queue.Get(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobSucceeded, job.State)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Equal(t, []string{"ada"}, seen)
+}
+
+func TestQueue_FailuresAndAdmin(t *testing.T) {
+	queue, now := newTestQueue(t)
+	ctx := context.Background()
+
+	queue.Register("broken", RetryPolicy{MaxAttempts: 5}, func(ctx context.Context, payload json.RawMessage) error {
+		return Permanent(errors.New("bad payload"))
+	})
+	queue.Register("panics", RetryPolicy{MaxAttempts: 1}, func(ctx context.Context, payload json.RawMessage) error {
+		panic("boom")
+	})
+
+	broken, err := queue.Enqueue(ctx, "broken", nil, EnqueueOptions{})
+	require.NoError(t, err)
+	panics, err := queue.Enqueue(ctx, "panics", nil, EnqueueOptions{})
+	require.NoError(t, err)
+	later, err := queue.Enqueue(ctx, "broken", nil, EnqueueOptions{Delay: time.Hour})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		ran, err := queue.RunNext(ctx, "w1")
+		require.NoError(t, err)
+		require.True(t, ran)
+	}
+	broken, err = queue.Get(ctx, broken.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobFailed, broken.State, "permanent errors are not retried")
+	assert.Equal(t, 1, broken.Attempts)
+	panics, err = queue.Get(ctx, panics.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobFailed, panics.State)
+	assert.Contains(t, panics.LastError, "boom")
+
+	stats, err := queue.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), stats.States[models.JobFailed])
+	assert.Equal(t, map[string]int64{"broken": 1}, stats.PendingByKind)
+
+	_, err = queue.Cancel(ctx, broken.ID)
+	assert.ErrorIs(t, err, ErrJobState)
+	cancelled, err := queue.Cancel(ctx, later.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobCancelled, cancelled.State)
+
+	retried, err := queue.Retry(ctx, panics.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.JobPending, retried.State)
+	assert.Equal(t, 0, retried.Attempts)
+
+	failed, total, err := queue.List(ctx, QueueFilter{State: models.JobFailed})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, broken.ID, failed[0].ID)
+
+	purged, err := queue.Purge(ctx, now.Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged, "the failed and the cancelled job")
+	_, err = queue.Get(ctx, broken.ID)
+	assert.ErrorIs(t, err, ErrJobNotFound)
+}
+
+func TestQueue_ClaimsOnceAndRecoversLostJobs(t *testing.T) {
+	queue, now := newTestQueue(t)
+	ctx := context.Background()
+	queue.Register("slow", RetryPolicy{Timeout: time.Minute}, func(ctx context.Context, payload json.RawMessage) error {
+		return nil
+	})
+	job, err := queue.Enqueue(ctx, "slow", nil, EnqueueOptions{})
+	require.NoError(t, err)
+
+	claimed, err := queue.claim(ctx, "w1")
+	require.NoError(t, err)
+	require.NotNil(t, claimed)
+	assert.Equal(t, job.ID, claimed.ID)
+	other, err := queue.claim(ctx, "w2")
+	require.NoError(t, err)
+	assert.Nil(t, other, "a running job is not claimed twice")
+
+	// w1 died; its lock lapses after the timeout and a minute of grace
+	*now = now.Add(2*time.Minute + time.Second)
+	reclaimed, err := queue.claim(ctx, "w2")
+	require.NoError(t, err)
+	require.NotNil(t, reclaimed)
+	assert.Equal(t, "w2", reclaimed.LockedBy)
+	assert.Equal(t, 2, reclaimed.Attempts)
+}
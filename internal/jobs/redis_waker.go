@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// redisWakeKey is the list every replica's workers block on
+const redisWakeKey = "hub:jobs:wake"
+
+// RedisWaker wakes idle workers on every replica through a Redis list.
+// Jobs stay in the database; a lost wake-up only delays a job until the
+// next poll.
+type RedisWaker struct {
+	client *redis.Client
+	logger *logrus.Logger
+}
+
+func NewRedisWaker(client *redis.Client, logger *logrus.Logger) *RedisWaker {
+	return &RedisWaker{client: client, logger: logger}
+}
+
+func (w *RedisWaker) Wake(ctx context.Context) {
+	pipe := w.client.TxPipeline()
+	pipe.LPush(ctx, redisWakeKey, 1)
+	// A burst of enqueues needs no more wake-ups than there are workers
+	pipe.LTrim(ctx, redisWakeKey, 0, 63)
+	if _, err := pipe.Exec(ctx); err != nil {
+		w.logger.WithError(err).Warn("Failed to wake job workers")
+	}
+}
+
+func (w *RedisWaker) Wait(ctx context.Context, timeout time.Duration) {
+	err := w.client.BRPop(ctx, timeout, redisWakeKey).Err()
+	if err != nil && err != redis.Nil && ctx.Err() == nil {
+		w.logger.WithError(err).Warn("Failed to wait for job wake-up")
+		// Don't spin while Redis is unreachable
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Package logging builds the server's loggers from configuration: one
+// logger per module with its own level, a shared main log and a separate
+// hash-chained audit log for security-relevant entries. Levels, formats and
+// the audit log can be changed while the server runs.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/sirupsen/logrus"
+)
+
+// Modules with their own log level
+const (
+	ModuleAPI       = "api"
+	ModuleSSH       = "ssh"
+	ModuleGit       = "git"
+	ModuleAnalytics = "analytics"
+	ModuleJobs      = "jobs"
+)
+
+// Modules lists every module name accepted in the configuration
+var Modules = []string{ModuleAPI, ModuleSSH, ModuleGit, ModuleAnalytics, ModuleJobs}
+
+// Pipeline owns the loggers handed out to the rest of the server. Loggers
+// are created once, so components keep their logger across Apply calls.
+type Pipeline struct {
+	mu      sync.Mutex
+	out     io.Writer
+	root    *logrus.Logger
+	modules map[string]*logrus.Logger
+	audit   *logrus.Logger
+	sink    *auditSink
+	status  Status
+}
+
+// Status describes the configuration the pipeline currently applies
+type Status struct {
+	Level   string            `json:"level"`
+	Format  string            `json:"format"`
+	Modules map[string]string `json:"modules"`
+	Audit   AuditStatus       `json:"audit"`
+}
+
+// AuditStatus describes the audit log
+type AuditStatus struct {
+	Enabled bool   `json:"enabled"`
+	Path    string `json:"path,omitempty"`
+	Format  string `json:"format"`
+}
+
+// New creates a pipeline logging to stderr. legacyLevel is the numeric
+// log_level, used when cfg has no level of its own.
+func New(cfg config.Logging, legacyLevel int) (*Pipeline, error) {
+	return newPipeline(os.Stderr, cfg, legacyLevel)
+}
+
+func newPipeline(out io.Writer, cfg config.Logging, legacyLevel int) (*Pipeline, error) {
+	p := &Pipeline{
+		out:     out,
+		root:    newLogger(out),
+		modules: make(map[string]*logrus.Logger, len(Modules)),
+		audit:   newLogger(out),
+	}
+	for _, module := range Modules {
+		p.modules[module] = newLogger(out)
+	}
+	// Audit entries are never filtered by level
+	p.audit.SetLevel(logrus.TraceLevel)
+	if err := p.Apply(cfg, legacyLevel); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func newLogger(out io.Writer) *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(out)
+	return logger
+}
+
+// Apply validates cfg and switches every logger over to it. Nothing changes
+// when cfg is invalid.
+func (p *Pipeline) Apply(cfg config.Logging, legacyLevel int) error {
+	level := logrus.Level(legacyLevel)
+	if cfg.Level != "" {
+		parsed, err := logrus.ParseLevel(cfg.Level)
+		if err != nil {
+			return fmt.Errorf("invalid log level %q", cfg.Level)
+		}
+		level = parsed
+	}
+	moduleLevels := make(map[string]logrus.Level, len(Modules))
+	for _, module := range Modules {
+		moduleLevels[module] = level
+	}
+	for module, name := range cfg.Modules {
+		module = strings.ToLower(module)
+		if _, ok := moduleLevels[module]; !ok {
+			return fmt.Errorf("unknown log module %q, expected one of %s", module, strings.Join(Modules, ", "))
+		}
+		parsed, err := logrus.ParseLevel(name)
+		if err != nil {
+			return fmt.Errorf("invalid log level %q for module %s", name, module)
+		}
+		moduleLevels[module] = parsed
+	}
+	formatter, format, err := newFormatter(cfg.Format)
+	if err != nil {
+		return err
+	}
+	auditFormatter, auditFormat, err := newFormatter(cfg.Audit.Format)
+	if err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// The audit file is reopened only when its path changes, so its hash
+	// chain carries on across reloads
+	sink := p.sink
+	if !cfg.Audit.Enabled {
+		sink = nil
+	} else if sink == nil || sink.path != cfg.Audit.Path {
+		if cfg.Audit.Path == "" {
+			return fmt.Errorf("audit log is enabled without a path")
+		}
+		if sink, err = openAuditSink(cfg.Audit.Path); err != nil {
+			return err
+		}
+	}
+	p.root.SetLevel(level)
+	p.root.SetFormatter(formatter)
+	status := Status{Level: level.String(), Format: format, Modules: make(map[string]string, len(Modules)), Audit: AuditStatus{Format: auditFormat}}
+	for module, logger := range p.modules {
+		logger.SetLevel(moduleLevels[module])
+		logger.SetFormatter(formatter)
+		status.Modules[module] = moduleLevels[module].String()
+	}
+	if sink != nil {
+		p.audit.SetOutput(sink)
+		p.audit.SetFormatter(auditFormatter)
+		status.Audit.Enabled = true
+		status.Audit.Path = sink.path
+	} else {
+		p.audit.SetOutput(p.out)
+		p.audit.SetFormatter(formatter)
+	}
+	if p.sink != nil && p.sink != sink {
+		p.sink.Close()
+	}
+	p.sink = sink
+	p.status = status
+	return nil
+}
+
+func newFormatter(format string) (logrus.Formatter, string, error) {
+	switch strings.ToLower(format) {
+	case "", "json":
+		return &logrus.JSONFormatter{}, "json", nil
+	case "text":
+		return &logrus.TextFormatter{DisableColors: true, FullTimestamp: true}, "text", nil
+	default:
+		return nil, "", fmt.Errorf("invalid log format %q, expected json or text", format)
+	}
+}
+
+// Root returns the logger of code outside any module
+func (p *Pipeline) Root() *logrus.Logger {
+	return p.root
+}
+
+// Module returns the logger of a module, or fallback when p is nil or the
+// module is unknown
+func (p *Pipeline) Module(name string, fallback *logrus.Logger) *logrus.Logger {
+	if p == nil {
+		return fallback
+	}
+	if logger, ok := p.modules[name]; ok {
+		return logger
+	}
+	return fallback
+}
+
+// Audit returns the logger of security-relevant entries, or fallback when
+// p is nil
+func (p *Pipeline) Audit(fallback *logrus.Logger) *logrus.Logger {
+	if p == nil {
+		return fallback
+	}
+	return p.audit
+}
+
+// Status returns the configuration currently applied
+func (p *Pipeline) Status() Status {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := p.status
+	status.Modules = make(map[string]string, len(p.status.Modules))
+	for module, level := range p.status.Modules {
+		status.Modules[module] = level
+	}
+	return status
+}
+
+// VerifyAudit checks the hash chain of the audit log file
+func (p *Pipeline) VerifyAudit() (*AuditVerification, error) {
+	p.mu.Lock()
+	sink := p.sink
+	p.mu.Unlock()
+	if sink == nil {
+		return nil, ErrAuditDisabled
+	}
+	return sink.Verify()
+}
+
+// Close closes the audit log file
+func (p *Pipeline) Close() error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.sink == nil {
+		return nil
+	}
+	p.audit.SetOutput(p.out)
+	err := p.sink.Close()
+	p.sink = nil
+	return err
+}
+
+var (
+	defaultMu       sync.RWMutex
+	defaultPipeline *Pipeline
+)
+
+// SetDefault sets the process wide Pipeline
+func SetDefault(p *Pipeline) {
+	defaultMu.Lock()
+	defaultPipeline = p
+	defaultMu.Unlock()
+}
+
+// Default returns the process wide Pipeline, which is nil until SetDefault
+// is called; a nil Pipeline hands out the fallback loggers it is given
+func Default() *Pipeline {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultPipeline
+}
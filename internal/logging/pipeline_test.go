@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeline_ModuleLevels(t *testing.T) {
+	var out bytes.Buffer
+	p, err := newPipeline(&out, config.Logging{Modules: map[string]string{"git": "debug", "API": "warn"}}, int(logrus.InfoLevel))
+	require.NoError(t, err)
+
+	api := p.Module(ModuleAPI, nil)
+	git := p.Module(ModuleGit, nil)
+	assert.Equal(t, logrus.WarnLevel, api.GetLevel())
+	assert.Equal(t, logrus.DebugLevel, git.GetLevel())
+	assert.Equal(t, logrus.InfoLevel, p.Module(ModuleJobs, nil).GetLevel(), "modules default to log_level")
+
+	api.Info("dropped")
+	git.Debug("kept")
+	assert.NotContains(t, out.String(), "dropped")
+	assert.Contains(t, out.String(), `"msg":"kept"`)
+
+	// Reloads change the loggers components already hold
+	require.NoError(t, p.Apply(config.Logging{Level: "error", Format: "text"}, int(logrus.InfoLevel)))
+	assert.Equal(t, logrus.ErrorLevel, api.GetLevel())
+	assert.Equal(t, logrus.ErrorLevel, git.GetLevel())
+	out.Reset()
+	git.Error("failed")
+	assert.Contains(t, out.String(), `msg=failed`)
+
+	// Invalid configuration leaves the pipeline as it was
+	assert.Error(t, p.Apply(config.Logging{Modules: map[string]string{"web": "debug"}}, 0))
+	assert.Error(t, p.Apply(config.Logging{Level: "loud"}, 0))
+	assert.Error(t, p.Apply(config.Logging{Format: "xml"}, 0))
+	assert.Equal(t, logrus.ErrorLevel, git.GetLevel())
+	assert.Equal(t, "text", p.Status().Format)
+
+	fallback := logrus.New()
+	var none *Pipeline
+	assert.Same(t, fallback, none.Module(ModuleAPI, fallback))
+	assert.Same(t, fallback, none.Audit(fallback))
+}
+
+func TestPipeline_AuditChain(t *testing.T) {
+	var out bytes.Buffer
+	path := filepath.Join(t.TempDir(), "audit", "audit.log")
+	cfg := config.Logging{Level: "error", Audit: config.AuditLog{Enabled: true, Path: path}}
+	p, err := newPipeline(&out, cfg, 0)
+	require.NoError(t, err)
+
+	p.Audit(nil).WithField("user_id", "u1").Info("Admin deleted user")
+	p.Audit(nil).Info("Admin reloaded configuration")
+	assert.Empty(t, out.String(), "audit entries only go to the audit log")
+
+	result, err := p.VerifyAudit()
+	require.NoError(t, err)
+	assert.Equal(t, &AuditVerification{Lines: 2, Valid: true}, result)
+
+	// Reopening the file carries the chain on
+	require.NoError(t, p.Close())
+	p, err = newPipeline(&out, cfg, 0)
+	require.NoError(t, err)
+	p.Audit(nil).Info("Credentials revoked")
+	result, err = p.VerifyAudit()
+	require.NoError(t, err)
+	assert.Equal(t, &AuditVerification{Lines: 3, Valid: true}, result)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	tampered := strings.Replace(string(content), "u1", "u2", 1)
+	result, err = VerifyAuditLog(strings.NewReader(tampered))
+	require.NoError(t, err)
+	assert.Equal(t, &AuditVerification{Lines: 1, Valid: false, BrokenLine: 1}, result)
+
+	lines := strings.SplitAfter(string(content), "\n")
+	result, err = VerifyAuditLog(strings.NewReader(lines[0] + lines[2]))
+	require.NoError(t, err)
+	assert.False(t, result.Valid, "removing a line breaks the chain")
+	assert.Equal(t, 2, result.BrokenLine)
+
+	// Disabling the audit log sends entries to the main log
+	require.NoError(t, p.Apply(config.Logging{Level: "error"}, 0))
+	p.Audit(nil).Info("Admin created new user")
+	assert.Contains(t, out.String(), "Admin created new user")
+	_, err = p.VerifyAudit()
+	assert.ErrorIs(t, err, ErrAuditDisabled)
+}
@@ -0,0 +1,55 @@
+package malware
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTPScanner posts content to a cloud scanning API. The API receives the
+// raw content as application/octet-stream, with the token as a bearer
+// token, and answers with a JSON Result.
+type HTTPScanner struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+// NewHTTPScanner creates a scanner for the API at endpoint
+func NewHTTPScanner(endpoint, token string, timeout time.Duration) *HTTPScanner {
+	return &HTTPScanner{endpoint: endpoint, token: token, client: &http.Client{Timeout: timeout}}
+}
+
+func (s *HTTPScanner) Name() string {
+	return ScannerHTTP
+}
+
+func (s *HTTPScanner) Scan(ctx context.Context, content io.Reader) (*Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, content)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Accept", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach scanning API: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("scanning API returned %s", resp.Status)
+	}
+
+	var result Result
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("invalid scanning API response: %w", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,58 @@
+// Package malware scans uploaded content for viruses and malware, either with
+// a ClamAV daemon or with a cloud scanning API.
+package malware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+)
+
+// Scanner names
+const (
+	ScannerClamAV = "clamav"
+	ScannerHTTP   = "http"
+)
+
+// Result is the verdict of a scan
+type Result struct {
+	Infected bool `json:"infected"`
+	// Signature names the malware found
+	Signature string `json:"signature,omitempty"`
+}
+
+// Scanner checks content for malware
+type Scanner interface {
+	// Name identifies the scanner in detection records
+	Name() string
+	Scan(ctx context.Context, content io.Reader) (*Result, error)
+}
+
+// New returns the scanner configured in cfg, or nil when scanning is disabled
+func New(cfg config.MalwareScanning) (Scanner, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	timeout := time.Duration(cfg.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 60 * time.Second
+	}
+
+	switch cfg.Scanner {
+	case ScannerClamAV:
+		if cfg.ClamAVAddress == "" {
+			return nil, fmt.Errorf("malware scanning: clamav_address is required")
+		}
+		return NewClamAVScanner(cfg.ClamAVAddress, timeout), nil
+	case ScannerHTTP:
+		if cfg.Endpoint == "" {
+			return nil, fmt.Errorf("malware scanning: endpoint is required")
+		}
+		return NewHTTPScanner(cfg.Endpoint, cfg.Token, timeout), nil
+	default:
+		return nil, fmt.Errorf("malware scanning: unsupported scanner %q", cfg.Scanner)
+	}
+}
@@ -0,0 +1,116 @@
+package malware
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd answers INSTREAM commands, flagging streams containing EICAR
+func fakeClamd(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				command := make([]byte, len("zINSTREAM\x00"))
+				if _, err := io.ReadFull(conn, command); err != nil || string(command) != "zINSTREAM\x00" {
+					conn.Write([]byte("UNKNOWN COMMAND\x00"))
+					return
+				}
+				var stream bytes.Buffer
+				size := make([]byte, 4)
+				for {
+					if _, err := io.ReadFull(conn, size); err != nil {
+						return
+					}
+					n := binary.BigEndian.Uint32(size)
+					if n == 0 {
+						break
+					}
+					if _, err := io.CopyN(&stream, conn, int64(n)); err != nil {
+						return
+					}
+				}
+				if strings.Contains(stream.String(), "EICAR-STANDARD-ANTIVIRUS-TEST-FILE") {
+					conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+				} else {
+					conn.Write([]byte("stream: OK\x00"))
+				}
+			}(conn)
+		}
+	}()
+	return "tcp://" + listener.Addr().String()
+}
+
+func TestClamAVScanner(t *testing.T) {
+	scanner, err := New(config.MalwareScanning{Enabled: true, Scanner: ScannerClamAV, ClamAVAddress: fakeClamd(t)})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	result, err := scanner.Scan(ctx, strings.NewReader("hello"))
+	require.NoError(t, err)
+	assert.False(t, result.Infected)
+
+	// Content larger than a chunk is streamed in pieces
+	result, err = scanner.Scan(ctx, strings.NewReader(strings.Repeat("a", clamavChunkSize+10)+eicar))
+	require.NoError(t, err)
+	assert.True(t, result.Infected)
+	assert.Equal(t, "Eicar-Test-Signature", result.Signature)
+
+	_, err = parseClamAVReply("INSTREAM size limit exceeded. ERROR\x00")
+	assert.Error(t, err)
+
+	unreachable := NewClamAVScanner("127.0.0.1:1", time.Second)
+	_, err = unreachable.Scan(ctx, strings.NewReader("hello"))
+	assert.Error(t, err)
+}
+
+func TestHTTPScanner(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		json.NewEncoder(w).Encode(Result{Infected: bytes.Contains(body, []byte("EICAR")), Signature: "EICAR"})
+	}))
+	defer server.Close()
+	ctx := context.Background()
+
+	scanner, err := New(config.MalwareScanning{Enabled: true, Scanner: ScannerHTTP, Endpoint: server.URL, Token: "secret"})
+	require.NoError(t, err)
+	result, err := scanner.Scan(ctx, strings.NewReader(eicar))
+	require.NoError(t, err)
+	assert.True(t, result.Infected)
+
+	_, err = NewHTTPScanner(server.URL, "wrong", time.Second).Scan(ctx, strings.NewReader(eicar))
+	assert.Error(t, err)
+
+	disabled, err := New(config.MalwareScanning{Scanner: ScannerHTTP})
+	require.NoError(t, err)
+	assert.Nil(t, disabled)
+	_, err = New(config.MalwareScanning{Enabled: true, Scanner: "antivirus"})
+	assert.Error(t, err)
+}
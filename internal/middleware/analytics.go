@@ -142,12 +142,8 @@ func createAnalyticsEvent(c *gin.Context, requestID string, actorID, repositoryI
 		targetType = "user"
 		targetID = actorID
 
-	// Repository events
-	case strings.Contains(path, "/repositories/") && strings.HasSuffix(path, ".git/git-receive-pack") && method == "POST":
-		eventType = models.EventRepositoryPush
-		targetType = "repository"
-		targetID = repositoryID
-
+	// Repository events; pushes are recorded by the push aggregator once
+	// the refs they updated are known
 	case strings.Contains(path, "/repositories/") && strings.HasSuffix(path, ".git/git-upload-pack") && method == "POST":
 		eventType = models.EventRepositoryClone
 		targetType = "repository"
@@ -0,0 +1,161 @@
+package middleware
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// API versions served by the router. A version is listed here once its
+// route group is mounted; clients select it by path (/api/v2) and may
+// confirm it with a vendor media type in Accept.
+const (
+	APIVersion1 = "v1"
+	APIVersion2 = "v2"
+)
+
+// APIVersionKey is the gin context key holding the negotiated API version
+const APIVersionKey = "api_version"
+
+// APIVersionHeader reports the version that served the response
+const APIVersionHeader = "API-Version"
+
+// apiMediaTypePrefix is the vendor media type used for Accept negotiation,
+// e.g. application/vnd.hub.v2+json or application/vnd.hub+json; version=2
+const apiMediaTypePrefix = "application/vnd.hub"
+
+// APIVersionMiddleware pins the requests of a route group to pathVersion.
+// A vendor media type in Accept that asks for a different version is
+// rejected with 406 so clients never silently receive the wrong DTOs.
+func APIVersionMiddleware(pathVersion string, supported []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if requested := AcceptedAPIVersion(c.GetHeader("Accept")); requested != "" && requested != pathVersion {
+			c.Header(APIVersionHeader, pathVersion)
+			c.AbortWithStatusJSON(http.StatusNotAcceptable, gin.H{
+				"error":              fmt.Sprintf("API version %s was requested in Accept but the path serves %s", requested, pathVersion),
+				"supported_versions": supported,
+			})
+			return
+		}
+
+		c.Set(APIVersionKey, pathVersion)
+		c.Header(APIVersionHeader, pathVersion)
+		c.Next()
+	}
+}
+
+// AcceptedAPIVersion extracts the API version requested by a vendor media
+// type in an Accept header, or "" when none is given
+func AcceptedAPIVersion(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil || (mediaType != apiMediaTypePrefix+"+json" && !strings.HasPrefix(mediaType, apiMediaTypePrefix+".")) {
+			continue
+		}
+		if v := params["version"]; v != "" {
+			return "v" + strings.TrimPrefix(v, "v")
+		}
+		// application/vnd.hub.v2+json
+		rest := strings.TrimPrefix(mediaType, apiMediaTypePrefix)
+		rest = strings.TrimSuffix(rest, "+json")
+		if strings.HasPrefix(rest, ".v") {
+			return rest[1:]
+		}
+	}
+	return ""
+}
+
+// APIVersionFromContext returns the negotiated API version, defaulting to v1
+func APIVersionFromContext(c *gin.Context) string {
+	if v := c.GetString(APIVersionKey); v != "" {
+		return v
+	}
+	return APIVersion1
+}
+
+// DeprecationPolicy describes an endpoint slated for removal
+type DeprecationPolicy struct {
+	// Deprecated is when the endpoint was deprecated
+	Deprecated time.Time `json:"deprecated"`
+	// Sunset is when the endpoint stops being served; zero means not yet scheduled
+	Sunset time.Time `json:"sunset,omitempty"`
+	// Successor is the path clients should migrate to
+	Successor string `json:"successor,omitempty"`
+	// Link points to migration documentation
+	Link string `json:"link,omitempty"`
+}
+
+// DeprecatedEndpoint is a registered deprecation
+type DeprecatedEndpoint struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	DeprecationPolicy
+}
+
+// DeprecationRegistry records deprecated endpoints so they can be listed
+// alongside the headers sent on each response
+type DeprecationRegistry struct {
+	mu        sync.RWMutex
+	endpoints []DeprecatedEndpoint
+	now       func() time.Time
+}
+
+// NewDeprecationRegistry creates an empty registry
+func NewDeprecationRegistry() *DeprecationRegistry {
+	return &DeprecationRegistry{now: time.Now}
+}
+
+// Deprecate registers method and path and returns a middleware that sends
+// the Deprecation (RFC 9745), Sunset (RFC 8594) and Link headers. Once the
+// sunset has passed the endpoint answers 410 Gone.
+func (r *DeprecationRegistry) Deprecate(method, path string, policy DeprecationPolicy) gin.HandlerFunc {
+	r.mu.Lock()
+	r.endpoints = append(r.endpoints, DeprecatedEndpoint{Method: method, Path: path, DeprecationPolicy: policy})
+	r.mu.Unlock()
+
+	return func(c *gin.Context) {
+		c.Header("Deprecation", fmt.Sprintf("@%d", policy.Deprecated.Unix()))
+		if !policy.Sunset.IsZero() {
+			c.Header("Sunset", policy.Sunset.UTC().Format(http.TimeFormat))
+		}
+		var links []string
+		if policy.Link != "" {
+			links = append(links, fmt.Sprintf(`<%s>; rel="deprecation"; type="text/html"`, policy.Link))
+		}
+		if policy.Successor != "" {
+			links = append(links, fmt.Sprintf(`<%s>; rel="successor-version"`, policy.Successor))
+		}
+		if len(links) > 0 {
+			c.Header("Link", strings.Join(links, ", "))
+		}
+
+		if !policy.Sunset.IsZero() && !r.now().Before(policy.Sunset) {
+			c.AbortWithStatusJSON(http.StatusGone, gin.H{
+				"error":     "This endpoint has been removed",
+				"successor": policy.Successor,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Endpoints returns the registered deprecations ordered by path
+func (r *DeprecationRegistry) Endpoints() []DeprecatedEndpoint {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	endpoints := append([]DeprecatedEndpoint(nil), r.endpoints...)
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Path != endpoints[j].Path {
+			return endpoints[i].Path < endpoints[j].Path
+		}
+		return endpoints[i].Method < endpoints[j].Method
+	})
+	return endpoints
+}
@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptedAPIVersion(t *testing.T) {
+	assert.Equal(t, "", AcceptedAPIVersion("application/json"))
+	assert.Equal(t, "", AcceptedAPIVersion("application/vnd.git-lfs+json"))
+	assert.Equal(t, "v2", AcceptedAPIVersion("application/vnd.hub.v2+json"))
+	assert.Equal(t, "v1", AcceptedAPIVersion("text/html, application/vnd.hub+json; version=1"))
+}
+
+func TestAPIVersionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/v1/ping", APIVersionMiddleware(APIVersion1, []string{APIVersion1}), func(c *gin.Context) {
+		c.String(http.StatusOK, APIVersionFromContext(c))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "v1", w.Body.String())
+	assert.Equal(t, "v1", w.Header().Get(APIVersionHeader))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/ping", nil)
+	req.Header.Set("Accept", "application/vnd.hub.v2+json")
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+}
+
+func TestDeprecate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	registry := NewDeprecationRegistry()
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	registry.now = func() time.Time { return now }
+
+	r := gin.New()
+	r.GET("/old", registry.Deprecate(http.MethodGet, "/old", DeprecationPolicy{
+		Deprecated: time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC),
+		Sunset:     time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+		Successor:  "/new",
+	}), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/old", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "@1764547200", w.Header().Get("Deprecation"))
+	assert.Equal(t, "Mon, 01 Jun 2026 00:00:00 GMT", w.Header().Get("Sunset"))
+	assert.Equal(t, `</new>; rel="successor-version"`, w.Header().Get("Link"))
+	assert.Len(t, registry.Endpoints(), 1)
+
+	now = time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/old", nil))
+	assert.Equal(t, http.StatusGone, w.Code)
+}
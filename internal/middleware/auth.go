@@ -10,6 +10,16 @@ import (
 
 func AuthMiddleware(jwtManager *auth.JWTManager) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Already authenticated by FineGrainedTokenAuth or OAuthTokenAuth
+		if _, ok := c.Get(FineGrainedTokenKey); ok {
+			c.Next()
+			return
+		}
+		if _, ok := c.Get(OAuthTokenKey); ok {
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
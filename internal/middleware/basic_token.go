@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// BasicTokenAuth lets git and Git LFS clients, which only send Basic
+// credentials, authenticate with a token: the password of the Basic
+// credentials is forwarded as a Bearer token and the username is ignored.
+// It must run before the token middlewares and TenantMiddleware.
+func BasicTokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, password, ok := c.Request.BasicAuth(); ok && password != "" {
+			c.Request.Header.Set("Authorization", "Bearer "+password)
+		}
+		c.Next()
+	}
+}
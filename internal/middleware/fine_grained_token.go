@@ -0,0 +1,211 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+// FineGrainedTokenKey is the gin context key of the fine-grained token that
+// authenticated the request
+const FineGrainedTokenKey = "fine_grained_token"
+
+// fineGrainedTokenAccountRoutes are the endpoints outside repositories that
+// fine-grained tokens may call
+var fineGrainedTokenAccountRoutes = map[string]bool{
+	"GET /api/v1/user":               true,
+	"POST /api/v1/tokens/introspect": true,
+}
+
+// FineGrainedTokenAuth authenticates Bearer fine-grained tokens and sets the
+// same user keys as AuthMiddleware. Other credentials pass through
+// untouched. It must run before TenantMiddleware.
+func FineGrainedTokenAuth(tokenService services.FineGrainedTokenService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) != 2 || parts[0] != "Bearer" || !strings.HasPrefix(parts[1], models.FineGrainedTokenPrefix) {
+			c.Next()
+			return
+		}
+
+		token, user, err := tokenService.Authenticate(c.Request.Context(), parts[1], c.ClientIP())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		c.Set("user_id", user.ID)
+		c.Set("username", user.Username)
+		c.Set("email", user.Email)
+		// Tokens never carry site admin rights
+		c.Set("is_admin", false)
+		c.Set(FineGrainedTokenKey, token)
+		if token.IsScoped() {
+			c.Header("X-OAuth-Scopes", strings.Join(token.Scopes, ", "))
+		}
+		c.Next()
+	}
+}
+
+// FineGrainedTokenScope limits requests authenticated by a fine-grained
+// token to the token's repositories and permissions, or to its scopes for
+// scoped tokens. Repositories outside the token are reported as not found.
+// It must run after TenantMiddleware.
+func FineGrainedTokenScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get(FineGrainedTokenKey)
+		if !exists {
+			c.Next()
+			return
+		}
+		token := value.(*models.FineGrainedToken)
+		if token.IsScoped() {
+			checkTokenScopes(c, token)
+			return
+		}
+
+		if c.Param("owner") == "" || c.Param("repo") == "" {
+			if !fineGrainedTokenAccountRoutes[c.Request.Method+" "+c.FullPath()] {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Fine-grained tokens can only access repository endpoints"})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		t, ok := tenant.FromContext(c.Request.Context())
+		if !ok || t.Repository == nil || !token.CoversRepository(t.Repository.ID) {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Repository not found"})
+			return
+		}
+
+		scope := tokenScopeFor(c.FullPath(), c.Request.Method)
+		level := models.TokenPermissionWrite
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			level = models.TokenPermissionRead
+		}
+		if !token.Allows(scope, level) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Token requires %s:%s permission", scope, level)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// checkTokenScopes limits a scoped token to its scopes. Like OAuth
+// applications, scoped tokens cannot manage credentials or reach site
+// administration.
+func checkTokenScopes(c *gin.Context, token *models.FineGrainedToken) {
+	route := c.FullPath()
+	for _, prefix := range oauthTokenForbiddenRoutes {
+		if strings.HasPrefix(route, prefix) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Personal access tokens cannot access this endpoint"})
+			return
+		}
+	}
+
+	scope := patScopeFor(c)
+	if !token.HasScope(scope) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Token requires the %s scope", scope)})
+		return
+	}
+	c.Next()
+}
+
+// FineGrainedTokenPackageScope limits package and container registry
+// requests authenticated by a scoped token to packages:read, or
+// packages:write for anything but downloads
+func FineGrainedTokenPackageScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get(FineGrainedTokenKey)
+		if !exists || !value.(*models.FineGrainedToken).IsScoped() {
+			c.Next()
+			return
+		}
+		scope := models.PATScopePackagesWrite
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			scope = models.PATScopePackagesRead
+		}
+		if !value.(*models.FineGrainedToken).HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Token requires the %s scope", scope)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// patScopeFor returns the scope guarding a request. Repository writes that
+// need administration for repository tokens need repo:admin.
+func patScopeFor(c *gin.Context) string {
+	route := c.FullPath()
+	method := c.Request.Method
+	read := method == http.MethodGet || method == http.MethodHead
+	pick := func(readScope, writeScope string) string {
+		if read {
+			return readScope
+		}
+		return writeScope
+	}
+
+	switch {
+	case strings.Contains(route, "/packages"):
+		return pick(models.PATScopePackagesRead, models.PATScopePackagesWrite)
+	case c.Param("owner") != "" && c.Param("repo") != "":
+		if !read && tokenScopeFor(route, method) == models.TokenScopeAdministration {
+			return models.PATScopeRepoAdmin
+		}
+		return pick(models.PATScopeRepoRead, models.PATScopeRepoWrite)
+	case strings.HasSuffix(route, "/repositories") || strings.HasSuffix(route, "/repos"):
+		return pick(models.PATScopeRepoRead, models.PATScopeRepoWrite)
+	case c.Param("org") != "" || strings.Contains(route, "/orgs") || strings.Contains(route, "/organizations"):
+		return pick(models.PATScopeReadOrg, models.PATScopeAdminOrg)
+	}
+	return pick(models.PATScopeUserRead, models.PATScopeUserWrite)
+}
+
+// tokenScopeFor maps a repository route to the permission category guarding
+// it. Unknown routes need administration so new endpoints fail closed.
+func tokenScopeFor(route, method string) string {
+	index := strings.Index(route, "/:repo")
+	if index < 0 {
+		return models.TokenScopeAdministration
+	}
+	rest := strings.Trim(route[index+len("/:repo"):], "/")
+	if rest == "" {
+		if method == http.MethodGet || method == http.MethodHead {
+			return models.TokenScopeMetadata
+		}
+		return models.TokenScopeAdministration
+	}
+
+	segments := strings.Split(rest, "/")
+	for _, segment := range segments {
+		switch segment {
+		case "protection":
+			return models.TokenScopeAdministration
+		case "status", "statuses":
+			return models.TokenScopeStatuses
+		}
+	}
+
+	switch segments[0] {
+	case "issues", "milestones", "time_report", "labels":
+		return models.TokenScopeIssues
+	case "pulls":
+		return models.TokenScopePullRequests
+	case "code-scanning":
+		return models.TokenScopeSecurityEvents
+	case "hooks":
+		return models.TokenScopeWebhooks
+	case "contents", "upload", "branches", "commits", "compare", "tags", "symbols", "codeowners", "languages":
+		return models.TokenScopeContents
+	case "info", "stats", "contributors", "activity", "analytics", "events", "subscription", "star":
+		return models.TokenScopeMetadata
+	}
+	return models.TokenScopeAdministration
+}
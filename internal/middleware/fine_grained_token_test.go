@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenScopeFor(t *testing.T) {
+	assert.Equal(t, models.TokenScopeMetadata, tokenScopeFor("/api/v1/repositories/:owner/:repo", http.MethodGet))
+	assert.Equal(t, models.TokenScopeAdministration, tokenScopeFor("/api/v1/repositories/:owner/:repo", http.MethodDelete))
+	assert.Equal(t, models.TokenScopeContents, tokenScopeFor("/api/v1/repositories/:owner/:repo/contents/*path", http.MethodPut))
+	assert.Equal(t, models.TokenScopeIssues, tokenScopeFor("/api/github/v3/repos/:owner/:repo/issues/:number", http.MethodPatch))
+	assert.Equal(t, models.TokenScopeStatuses, tokenScopeFor("/api/github/v3/repos/:owner/:repo/commits/:ref/status", http.MethodGet))
+	assert.Equal(t, models.TokenScopeAdministration, tokenScopeFor("/api/v1/repositories/:owner/:repo/branches/:branch/protection", http.MethodGet))
+	assert.Equal(t, models.TokenScopeAdministration, tokenScopeFor("/api/v1/repositories/:owner/:repo/keys", http.MethodGet))
+}
+
+func TestFineGrainedTokenScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := &models.Repository{ID: uuid.New()}
+	token := &models.FineGrainedToken{
+		RepositoryIDs: []uuid.UUID{repo.ID},
+		Permissions:   map[string]models.TokenPermission{models.TokenScopeIssues: models.TokenPermissionRead},
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set(FineGrainedTokenKey, token)
+		t := &tenant.Context{}
+		if c.Param("repo") == "app" {
+			t.Repository = repo
+		}
+		c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), t))
+	}, FineGrainedTokenScope())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/api/v1/repositories/:owner/:repo/issues", ok)
+	r.POST("/api/v1/repositories/:owner/:repo/issues", ok)
+	r.GET("/api/v1/repositories/:owner/:repo/pulls", ok)
+	r.GET("/api/v1/user", ok)
+	r.GET("/api/v1/user/keys", ok)
+
+	for _, tc := range []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodGet, "/api/v1/repositories/acme/app/issues", http.StatusOK},
+		{http.MethodPost, "/api/v1/repositories/acme/app/issues", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/repositories/acme/app/pulls", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/repositories/acme/other/issues", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/user", http.StatusOK},
+		{http.MethodGet, "/api/v1/user/keys", http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(t, tc.code, w.Code, "%s %s", tc.method, tc.path)
+	}
+}
+
+func TestFineGrainedTokenScopeScoped(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	token := &models.FineGrainedToken{Scopes: []string{models.PATScopeRepoWrite, models.PATScopeReadOrg}}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(FineGrainedTokenKey, token) }, FineGrainedTokenScope())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/api/v1/repositories/:owner/:repo/issues", ok)
+	r.POST("/api/v1/repositories/:owner/:repo/issues", ok)
+	r.DELETE("/api/v1/repositories/:owner/:repo", ok)
+	r.POST("/api/v1/repositories", ok)
+	r.GET("/api/v1/orgs/:org/members", ok)
+	r.PATCH("/api/v1/orgs/:org", ok)
+	r.PUT("/api/v1/orgs/:org/packages/retention-policy", ok)
+	r.GET("/api/v1/user", ok)
+	r.GET("/api/v1/user/tokens", ok)
+
+	for _, tc := range []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodGet, "/api/v1/repositories/acme/app/issues", http.StatusOK},
+		{http.MethodPost, "/api/v1/repositories/acme/app/issues", http.StatusOK},
+		{http.MethodDelete, "/api/v1/repositories/acme/app", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/repositories", http.StatusOK},
+		{http.MethodGet, "/api/v1/orgs/acme/members", http.StatusOK},
+		{http.MethodPatch, "/api/v1/orgs/acme", http.StatusForbidden},
+		{http.MethodPut, "/api/v1/orgs/acme/packages/retention-policy", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/user", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/user/tokens", http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(t, tc.code, w.Code, "%s %s", tc.method, tc.path)
+	}
+
+	token.Scopes = []string{models.PATScopeAdminOrg, models.PATScopePackagesWrite, models.PATScopeUserRead}
+	for _, tc := range []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodGet, "/api/v1/repositories/acme/app/issues", http.StatusForbidden},
+		{http.MethodPatch, "/api/v1/orgs/acme", http.StatusOK},
+		{http.MethodPut, "/api/v1/orgs/acme/packages/retention-policy", http.StatusOK},
+		{http.MethodGet, "/api/v1/user", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(t, tc.code, w.Code, "%s %s", tc.method, tc.path)
+	}
+}
+
+func TestScopedTokenPackagesAndGit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repoID := uuid.New()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPut, "/v2/acme/app/manifests/latest", nil)
+	c.Set(FineGrainedTokenKey, &models.FineGrainedToken{Scopes: []string{models.PATScopeRepoRead, models.PATScopePackagesRead}})
+
+	assert.True(t, GitTokenAllows(c, repoID, false))
+	assert.False(t, GitTokenAllows(c, repoID, true))
+
+	FineGrainedTokenPackageScope()(c)
+	assert.True(t, c.IsAborted())
+	assert.Equal(t, http.StatusForbidden, c.Writer.Status())
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// GitTokenAllows reports whether the token authenticating a git or Git LFS
+// request permits reading, or with write pushing to, the repository.
+// Fine-grained tokens must cover the repository and grant contents access,
+// or grant repo:read or repo:write when scoped; OAuth tokens need read:repo
+// to read and repo to write. Other credentials are limited by the user's
+// permission only.
+//
+// Git routes check tokens here instead of with FineGrainedTokenScope and
+// OAuthTokenScope because fetches are POST requests.
+func GitTokenAllows(c *gin.Context, repoID uuid.UUID, write bool) bool {
+	if value, ok := c.Get(FineGrainedTokenKey); ok {
+		token := value.(*models.FineGrainedToken)
+		if token.IsScoped() {
+			if write {
+				return token.HasScope(models.PATScopeRepoWrite)
+			}
+			return token.HasScope(models.PATScopeRepoRead)
+		}
+		level := models.TokenPermissionRead
+		if write {
+			level = models.TokenPermissionWrite
+		}
+		return token.CoversRepository(repoID) && token.Allows(models.TokenScopeContents, level)
+	}
+	if value, ok := c.Get(OAuthTokenKey); ok {
+		scope := models.OAuthScopeReadRepo
+		if write {
+			scope = models.OAuthScopeRepo
+		}
+		return value.(*models.OAuthAccessToken).HasScope(scope)
+	}
+	return true
+}
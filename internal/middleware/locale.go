@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"github.com/a5c-ai/hub/internal/i18n"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LocaleMiddleware resolves the locale for server-generated text from the
+// authenticated user's preference, falling back to Accept-Language. It must
+// run after TenantMiddleware.
+func LocaleMiddleware(bundle *i18n.Bundle, db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		var preference string
+		if t, ok := tenant.FromContext(ctx); ok && t.IsAuthenticated() {
+			var user models.User
+			if err := db.WithContext(ctx).Select("locale").First(&user, "id = ?", *t.UserID).Error; err == nil {
+				preference = user.Locale
+			}
+		}
+
+		localizer := bundle.Localizer(bundle.Resolve(preference, c.GetHeader("Accept-Language")))
+		c.Header("Content-Language", localizer.Locale())
+		c.Request = c.Request.WithContext(i18n.NewContext(ctx, localizer))
+		c.Next()
+	}
+}
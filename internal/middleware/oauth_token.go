@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// OAuthTokenKey is the gin context key of the OAuth access token that
+// authenticated the request
+const OAuthTokenKey = "oauth_token"
+
+// oauthTokenForbiddenRoutes prefixes the endpoints that manage credentials
+// and site administration, which OAuth applications never reach
+var oauthTokenForbiddenRoutes = []string{
+	"/api/v1/auth/",
+	"/api/v1/admin/",
+	"/api/v1/tokens/",
+	"/api/v1/user/tokens",
+	"/api/v1/user/keys",
+	"/api/v1/user/applications",
+	"/api/v1/user/authorizations",
+	"/api/v1/oauth/authorize",
+	"/api/v1/oauth/register",
+}
+
+// oauthTokenOpenRoutes need no scope beyond a valid token; the handlers
+// check what they require
+var oauthTokenOpenRoutes = map[string]bool{
+	"/api/v1/oauth/userinfo": true,
+}
+
+// OAuthTokenAuth authenticates Bearer access tokens issued to OAuth
+// applications and sets the same user keys as AuthMiddleware. Other
+// credentials pass through untouched. It must run before TenantMiddleware.
+func OAuthTokenAuth(oauthService services.OAuthProviderService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+		if len(parts) != 2 || parts[0] != "Bearer" || !strings.HasPrefix(parts[1], models.OAuthAccessTokenPrefix) {
+			c.Next()
+			return
+		}
+
+		token, user, err := oauthService.Authenticate(c.Request.Context(), parts[1])
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			return
+		}
+
+		c.Set("user_id", user.ID)
+		c.Set("username", user.Username)
+		c.Set("email", user.Email)
+		// Applications never act with site admin rights
+		c.Set("is_admin", false)
+		c.Set(OAuthTokenKey, token)
+		c.Header("X-OAuth-Scopes", strings.Join(token.Scopes, ", "))
+		c.Next()
+	}
+}
+
+// OAuthTokenScope limits requests authenticated by an OAuth access token to
+// the scopes the user granted. Repository routes need read:repo or repo,
+// other routes read:user or user.
+func OAuthTokenScope() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get(OAuthTokenKey)
+		if !exists {
+			c.Next()
+			return
+		}
+		token := value.(*models.OAuthAccessToken)
+
+		route := c.FullPath()
+		for _, prefix := range oauthTokenForbiddenRoutes {
+			if strings.HasPrefix(route, prefix) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "OAuth applications cannot access this endpoint"})
+				return
+			}
+		}
+
+		if oauthTokenOpenRoutes[route] {
+			c.Next()
+			return
+		}
+
+		scope := oauthScopeFor(c.Param("owner") != "" && c.Param("repo") != "", c.Request.Method)
+		if !token.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Token requires the %s scope", scope)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// oauthScopeFor returns the scope guarding a request
+func oauthScopeFor(repository bool, method string) string {
+	read := method == http.MethodGet || method == http.MethodHead
+	switch {
+	case repository && read:
+		return models.OAuthScopeReadRepo
+	case repository:
+		return models.OAuthScopeRepo
+	case read:
+		return models.OAuthScopeReadUser
+	}
+	return models.OAuthScopeUser
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOAuthTokenScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	token := &models.OAuthAccessToken{Scopes: []string{models.OAuthScopeOpenID, models.OAuthScopeRepo}}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) { c.Set(OAuthTokenKey, token) }, OAuthTokenScope())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/api/v1/repositories/:owner/:repo/issues", ok)
+	r.POST("/api/v1/repositories/:owner/:repo/issues", ok)
+	r.GET("/api/v1/user", ok)
+	r.GET("/api/v1/oauth/userinfo", ok)
+	r.GET("/api/v1/user/tokens", ok)
+	r.GET("/api/v1/admin/users", ok)
+	r.POST("/api/v1/oauth/register", ok)
+
+	for _, tc := range []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodGet, "/api/v1/repositories/acme/app/issues", http.StatusOK},
+		{http.MethodPost, "/api/v1/repositories/acme/app/issues", http.StatusOK},
+		{http.MethodGet, "/api/v1/user", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/oauth/userinfo", http.StatusOK},
+		{http.MethodGet, "/api/v1/user/tokens", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/admin/users", http.StatusForbidden},
+		{http.MethodPost, "/api/v1/oauth/register", http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(t, tc.code, w.Code, "%s %s", tc.method, tc.path)
+	}
+
+	token.Scopes = []string{models.OAuthScopeReadRepo, models.OAuthScopeUser}
+	for _, tc := range []struct {
+		method, path string
+		code         int
+	}{
+		{http.MethodGet, "/api/v1/repositories/acme/app/issues", http.StatusOK},
+		{http.MethodPost, "/api/v1/repositories/acme/app/issues", http.StatusForbidden},
+		{http.MethodGet, "/api/v1/user", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(t, tc.code, w.Code, "%s %s", tc.method, tc.path)
+	}
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// organizationSSORoutes are the SSO endpoints members use to sign in and
+// owners use to repair the configuration, which enforcement must not block
+const organizationSSORoutes = "/api/v1/organizations/:org/sso/"
+
+// OrganizationSSO refuses members of organizations that enforce single
+// sign-on unless they hold an active SSO session. It covers the
+// organization of the request and the organization owning its repository,
+// and must run after TenantMiddleware. Site admins are exempt.
+func OrganizationSSO(ssoService services.OrganizationSSOService, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := tenant.FromContext(c.Request.Context())
+		if !ok || t.UserID == nil || t.IsAdmin || strings.HasPrefix(c.FullPath(), organizationSSORoutes) {
+			c.Next()
+			return
+		}
+
+		var orgID uuid.UUID
+		var orgName string
+		switch {
+		case t.Organization != nil:
+			orgID, orgName = t.Organization.ID, t.Organization.Name
+		case t.Repository != nil && t.Owner != nil && t.Owner.Type == models.OwnerTypeOrganization:
+			orgID, orgName = t.Owner.ID, t.Owner.Username
+		default:
+			c.Next()
+			return
+		}
+
+		err := ssoService.Authorize(c.Request.Context(), orgID, *t.UserID)
+		switch {
+		case errors.Is(err, services.ErrSSORequired):
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error":   "Organization " + orgName + " requires single sign-on",
+				"sso_url": "/api/v1/organizations/" + url.PathEscape(orgName) + "/sso/login",
+			})
+			return
+		case err != nil:
+			logger.WithError(err).Error("Failed to check organization SSO session")
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check single sign-on session"})
+			return
+		}
+		c.Next()
+	}
+}
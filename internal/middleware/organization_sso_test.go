@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// enforcingSSOService requires SSO in one organization for users without a
+// session
+type enforcingSSOService struct {
+	services.OrganizationSSOService
+	orgID    uuid.UUID
+	sessions map[uuid.UUID]bool
+}
+
+func (s *enforcingSSOService) Authorize(ctx context.Context, orgID, userID uuid.UUID) error {
+	if orgID == s.orgID && !s.sessions[userID] {
+		return services.ErrSSORequired
+	}
+	return nil
+}
+
+func TestOrganizationSSO(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	org := &models.Organization{ID: uuid.New(), Name: "acme"}
+	member, signedIn := uuid.New(), uuid.New()
+	ssoService := &enforcingSSOService{orgID: org.ID, sessions: map[uuid.UUID]bool{signedIn: true}}
+
+	router := func(t *tenant.Context) *gin.Engine {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), t))
+		})
+		router.Use(OrganizationSSO(ssoService, logrus.New()))
+		router.GET("/api/v1/organizations/:org", func(c *gin.Context) { c.Status(http.StatusOK) })
+		router.POST("/api/v1/organizations/:org/sso/login", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return router
+	}
+	request := func(t *tenant.Context) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router(t).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/organizations/acme", nil))
+		return w
+	}
+
+	w := request(&tenant.Context{UserID: &member, Organization: org})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "/api/v1/organizations/acme/sso/login", body["sso_url"])
+
+	// Repositories owned by the organization are covered as well
+	repo := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization}
+	owner := &models.OwnerEntity{ID: org.ID, Username: "acme", Type: models.OwnerTypeOrganization}
+	assert.Equal(t, http.StatusForbidden, request(&tenant.Context{UserID: &member, Repository: repo, Owner: owner}).Code)
+
+	assert.Equal(t, http.StatusOK, request(&tenant.Context{UserID: &signedIn, Organization: org}).Code)
+	assert.Equal(t, http.StatusOK, request(&tenant.Context{UserID: &member, IsAdmin: true, Organization: org}).Code)
+	assert.Equal(t, http.StatusOK, request(&tenant.Context{Organization: org}).Code, "anonymous requests see public content")
+	// Members can still reach the endpoints that start SSO
+	w = httptest.NewRecorder()
+	router(&tenant.Context{UserID: &member, Organization: org}).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/v1/organizations/acme/sso/login", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	other := &models.Organization{ID: uuid.New(), Name: "other"}
+	assert.Equal(t, http.StatusOK, request(&tenant.Context{UserID: &member, Organization: other}).Code)
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// OrganizationTwoFactor refuses members of organizations that require
+// two-factor authentication access to the organization's private and
+// internal repositories until they enable MFA. It must run after
+// TenantMiddleware. Site admins are exempt.
+func OrganizationTwoFactor(policy services.TwoFactorPolicyService, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := tenant.FromContext(c.Request.Context())
+		if !ok || t.UserID == nil || t.IsAdmin || t.Repository == nil || t.Repository.Visibility == models.VisibilityPublic ||
+			t.Owner == nil || t.Owner.Type != models.OwnerTypeOrganization {
+			c.Next()
+			return
+		}
+
+		if !authorizeTwoFactor(c, policy, logger, t.Owner.ID, t.Owner.Username) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// OrganizationPackagesTwoFactor applies the two-factor policy to the package
+// registries of the organization in the path. Packages are private unless
+// made public, so authenticated members without MFA are refused whatever
+// the package; anonymous clients still reach public packages. It must run
+// after TenantMiddleware.
+func OrganizationPackagesTwoFactor(policy services.TwoFactorPolicyService, logger *logrus.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		t, ok := tenant.FromContext(c.Request.Context())
+		if !ok || t.UserID == nil || t.IsAdmin || t.Organization == nil {
+			c.Next()
+			return
+		}
+		if !authorizeTwoFactor(c, policy, logger, t.Organization.ID, t.Organization.Name) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorizeTwoFactor checks the policy of an organization for the tenant's
+// user, aborting the request when it refuses
+func authorizeTwoFactor(c *gin.Context, policy services.TwoFactorPolicyService, logger *logrus.Logger, orgID uuid.UUID, orgName string) bool {
+	t, _ := tenant.FromContext(c.Request.Context())
+	err := policy.Authorize(c.Request.Context(), orgID, *t.UserID)
+	switch {
+	case errors.Is(err, services.ErrTwoFactorRequired):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error":     "Organization " + orgName + " requires two-factor authentication",
+			"setup_url": "/api/v1/auth/mfa/setup",
+		})
+		return false
+	case err != nil:
+		logger.WithError(err).Error("Failed to check organization two-factor policy")
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor authentication policy"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// requiringTwoFactorPolicy requires MFA in one organization for users
+// without it
+type requiringTwoFactorPolicy struct {
+	orgID   uuid.UUID
+	enabled map[uuid.UUID]bool
+}
+
+func (p *requiringTwoFactorPolicy) Authorize(ctx context.Context, orgID, userID uuid.UUID) error {
+	if orgID == p.orgID && !p.enabled[userID] {
+		return services.ErrTwoFactorRequired
+	}
+	return nil
+}
+
+func TestOrganizationTwoFactor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	orgID := uuid.New()
+	member, enrolled := uuid.New(), uuid.New()
+	policy := &requiringTwoFactorPolicy{orgID: orgID, enabled: map[uuid.UUID]bool{enrolled: true}}
+	owner := &models.OwnerEntity{ID: orgID, Username: "acme", Type: models.OwnerTypeOrganization}
+	private := &models.Repository{ID: uuid.New(), OwnerID: orgID, OwnerType: models.OwnerTypeOrganization, Visibility: models.VisibilityPrivate}
+	public := &models.Repository{ID: uuid.New(), OwnerID: orgID, OwnerType: models.OwnerTypeOrganization, Visibility: models.VisibilityPublic}
+
+	request := func(t *tenant.Context) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), t))
+		})
+		router.Use(OrganizationTwoFactor(policy, logrus.New()))
+		router.GET("/api/v1/repositories/:owner/:repo", func(c *gin.Context) { c.Status(http.StatusOK) })
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/repositories/acme/app", nil))
+		return w
+	}
+
+	w := request(&tenant.Context{UserID: &member, Repository: private, Owner: owner})
+	require.Equal(t, http.StatusForbidden, w.Code)
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "/api/v1/auth/mfa/setup", body["setup_url"])
+
+	assert.Equal(t, http.StatusOK, request(&tenant.Context{UserID: &enrolled, Repository: private, Owner: owner}).Code)
+	assert.Equal(t, http.StatusOK, request(&tenant.Context{UserID: &member, Repository: public, Owner: owner}).Code, "public repositories stay open")
+	assert.Equal(t, http.StatusOK, request(&tenant.Context{UserID: &member, IsAdmin: true, Repository: private, Owner: owner}).Code)
+	assert.Equal(t, http.StatusOK, request(&tenant.Context{UserID: &member, Owner: owner}).Code, "only repositories are covered")
+
+	// Package registries are covered for the organization in the path
+	org := &models.Organization{ID: orgID, Name: "acme"}
+	packages := func(t *tenant.Context) int {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), t))
+		})
+		router.Use(OrganizationPackagesTwoFactor(policy, logrus.New()))
+		router.GET("/api/v1/orgs/:org/packages/npm/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/orgs/acme/packages/npm/left-pad", nil))
+		return w.Code
+	}
+	assert.Equal(t, http.StatusForbidden, packages(&tenant.Context{UserID: &member, Organization: org}))
+	assert.Equal(t, http.StatusOK, packages(&tenant.Context{UserID: &enrolled, Organization: org}))
+	assert.Equal(t, http.StatusOK, packages(&tenant.Context{Organization: org}), "anonymous clients reach public packages")
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VersionETag formats a settings version as an entity tag
+func VersionETag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// SetVersionETag reports the current settings version in the ETag header
+func SetVersionETag(c *gin.Context, version int64) {
+	c.Header("ETag", VersionETag(version))
+}
+
+// IfMatchVersion reads the settings version a mutating request was based on
+// from If-Match. Requests without the header are answered with 428 so
+// concurrent editors cannot silently overwrite each other; "*" opts out of
+// the check and yields a nil version.
+func IfMatchVersion(c *gin.Context) (*int64, bool) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the current ETag is required"})
+		return nil, false
+	}
+	if header == "*" {
+		return nil, true
+	}
+	version, err := ParseVersionETag(header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return &version, true
+}
+
+// ParseVersionETag parses an entity tag made by VersionETag. Weak tags and
+// bare numbers are accepted as well; of a list only the first tag is used.
+func ParseVersionETag(tag string) (int64, error) {
+	tag, _, _ = strings.Cut(tag, ",")
+	tag = strings.TrimSpace(tag)
+	tag = strings.TrimPrefix(tag, "W/")
+	tag = strings.Trim(tag, `"`)
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid entity tag %q", tag)
+	}
+	return version, nil
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseVersionETag(t *testing.T) {
+	for tag, want := range map[string]int64{`"3"`: 3, `W/"12"`: 12, `7`: 7, `"4", "5"`: 4} {
+		got, err := ParseVersionETag(tag)
+		require.NoError(t, err, tag)
+		assert.Equal(t, want, got, tag)
+	}
+	for _, tag := range []string{`"abc"`, `"0"`, `""`} {
+		_, err := ParseVersionETag(tag)
+		assert.Error(t, err, tag)
+	}
+	assert.Equal(t, `"9"`, VersionETag(9))
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	request := func(ifMatch string) (*int64, bool, int) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPatch, "/", nil)
+		if ifMatch != "" {
+			c.Request.Header.Set("If-Match", ifMatch)
+		}
+		version, ok := IfMatchVersion(c)
+		return version, ok, w.Code
+	}
+
+	_, ok, code := request("")
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusPreconditionRequired, code)
+
+	version, ok, _ := request("*")
+	assert.True(t, ok)
+	assert.Nil(t, version)
+
+	version, ok, _ = request(`"2"`)
+	require.True(t, ok)
+	assert.EqualValues(t, 2, *version)
+
+	_, ok, code = request(`"two"`)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitCaller returns who the request is counted against: the
+// authenticated user of the tenant, or the client IP
+func RateLimitCaller(c *gin.Context) services.RateLimitCaller {
+	if t, ok := tenant.FromContext(c.Request.Context()); ok && t.UserID != nil {
+		return services.RateLimitCaller{Key: "user:" + t.UserID.String(), Authenticated: true}
+	}
+	return services.RateLimitCaller{Key: "ip:" + c.ClientIP()}
+}
+
+// RateLimitCategory returns the category a route is counted in, or an
+// empty string for routes that are not limited
+func RateLimitCategory(route string) string {
+	switch {
+	case route == "" || strings.HasSuffix(route, "/rate_limit"):
+		return ""
+	case strings.Contains(route, "/tarball/"), strings.Contains(route, "/zipball/"), strings.HasSuffix(route, "/export"):
+		return services.RateLimitExports
+	}
+	for _, segment := range strings.Split(route, "/") {
+		if segment == "search" || segment == "symbols" {
+			return services.RateLimitSearch
+		}
+	}
+	return services.RateLimitCore
+}
+
+// RateLimit counts requests against the caller's limit in category, or in
+// the category of the matched route when category is empty, answering 429
+// once it is used up. The X-RateLimit headers report the limit either way.
+// It must run after TenantMiddleware.
+func RateLimit(limiter services.RateLimitService, category string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !limiter.Enabled() {
+			c.Next()
+			return
+		}
+		name := category
+		if name == "" {
+			name = RateLimitCategory(c.FullPath())
+		}
+		if name == "" {
+			c.Next()
+			return
+		}
+
+		status, allowed := limiter.Take(name, RateLimitCaller(c))
+		if status.Limit > 0 {
+			c.Header("X-RateLimit-Limit", strconv.Itoa(status.Limit))
+			c.Header("X-RateLimit-Remaining", strconv.Itoa(status.Remaining))
+			c.Header("X-RateLimit-Used", strconv.Itoa(status.Used))
+			c.Header("X-RateLimit-Reset", strconv.FormatInt(status.Reset, 10))
+			c.Header("X-RateLimit-Resource", status.Resource)
+		}
+		if !allowed {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error":    "API rate limit exceeded",
+				"resource": status.Resource,
+				"reset":    status.Reset,
+			})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimitCategory(t *testing.T) {
+	for route, want := range map[string]string{
+		"/api/v1/repositories/:owner/:repo/commits": services.RateLimitCore,
+		"/api/v1/search": services.RateLimitSearch,
+		"/api/v1/repositories/:owner/:repo/search/code":    services.RateLimitSearch,
+		"/api/v1/repositories/:owner/:repo/symbols":        services.RateLimitSearch,
+		"/api/v1/repositories/:owner/:repo/tarball/*ref":   services.RateLimitExports,
+		"/api/v1/repositories/:owner/:repo/export":         services.RateLimitExports,
+		"/api/v1/repositories/:owner/:repo/export/:job_id": services.RateLimitCore,
+		"/api/v1/rate_limit":                               "",
+		"":                                                 "",
+	} {
+		assert.Equal(t, want, RateLimitCategory(route), route)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := services.NewRateLimitService(config.RateLimits{
+		Enabled: true,
+		Core:    config.RateLimitCategory{Limit: 3, AnonymousLimit: 1, WindowSeconds: 60},
+	})
+	userID := uuid.New()
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if c.GetHeader("X-User") != "" {
+			c.Request = c.Request.WithContext(tenant.NewContext(c.Request.Context(), &tenant.Context{UserID: &userID}))
+		}
+	})
+	router.Use(RateLimit(limiter, ""))
+	router.GET("/api/v1/repos", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/api/v1/search", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(path string, authenticated bool) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if authenticated {
+			req.Header.Set("X-User", "1")
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/api/v1/repos", false)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, services.RateLimitCore, w.Header().Get("X-RateLimit-Resource"))
+	assert.Equal(t, http.StatusTooManyRequests, request("/api/v1/repos", false).Code)
+
+	// Authenticated callers have their own, higher limit
+	for i := 0; i < 3; i++ {
+		w = request("/api/v1/repos", true)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, "3", w.Header().Get("X-RateLimit-Used"))
+	assert.Equal(t, http.StatusTooManyRequests, request("/api/v1/repos", true).Code)
+
+	// Search is not limited here
+	w = request("/api/v1/search", true)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/gin-gonic/gin"
+)
+
+// reportedHeaders are the request headers attached to error reports; others,
+// such as Authorization and Cookie, are left out
+var reportedHeaders = []string{"User-Agent", "Referer", "Content-Type", "Accept", "X-Request-ID", "X-Forwarded-For"}
+
+// Recovery recovers panics in handlers, answering them with a 500, and
+// reports panics and 5xx responses with the request and user to reporter.
+// It replaces gin's own recovery middleware.
+func Recovery(reporter *errorreporting.Reporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				// The client went away; there is nothing to report
+				panic(recovered)
+			}
+
+			event := reporter.NewPanicEvent(recovered)
+			if !c.Writer.Written() {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+			} else {
+				c.Abort()
+			}
+			reporter.Capture(withRequestContext(event, c))
+		}()
+
+		c.Next()
+
+		if status := c.Writer.Status(); status >= http.StatusInternalServerError {
+			err := c.Errors.Last()
+			var event *errorreporting.Event
+			if err != nil {
+				event = reporter.NewEvent(err.Err, 0)
+			} else {
+				event = reporter.NewEvent(fmt.Errorf("%s %s responded %d", c.Request.Method, c.FullPath(), status), 0)
+			}
+			// The stack of the middleware says nothing about the failure
+			event.Exception[0].Stacktrace = nil
+			reporter.Capture(withRequestContext(event, c))
+		}
+	}
+}
+
+// withRequestContext attaches the request, authenticated user and route to an event
+func withRequestContext(event *errorreporting.Event, c *gin.Context) *errorreporting.Event {
+	req := c.Request
+	headers := make(map[string]string)
+	for _, name := range reportedHeaders {
+		if v := req.Header.Get(name); v != "" {
+			headers[name] = v
+		}
+	}
+	scheme := "http"
+	if req.TLS != nil || strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	event.Request = &errorreporting.Request{
+		Method:      req.Method,
+		URL:         scheme + "://" + req.Host + req.URL.Path,
+		QueryString: req.URL.RawQuery,
+		Headers:     headers,
+	}
+
+	user := &errorreporting.User{IPAddress: c.ClientIP()}
+	if v, ok := c.Get("user_id"); ok {
+		user.ID = fmt.Sprint(v)
+	}
+	user.Username = c.GetString("username")
+	event.User = user
+
+	event.Tags["component"] = "http"
+	event.Tags["status"] = fmt.Sprint(c.Writer.Status())
+	if route := c.FullPath(); route != "" {
+		event.Tags["route"] = route
+	}
+	if id := req.Header.Get("X-Request-ID"); id != "" {
+		event.Tags["request_id"] = id
+	} else if id := c.Writer.Header().Get("X-Request-ID"); id != "" {
+		event.Tags["request_id"] = id
+	}
+	return event
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingClient struct {
+	events []*errorreporting.Event
+}
+
+func (r *recordingClient) Send(event *errorreporting.Event) {
+	r.events = append(r.events, event)
+}
+
+func (r *recordingClient) Flush(timeout time.Duration) bool {
+	return true
+}
+
+func TestRecoveryReportsPanicsAndServerErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	client := &recordingClient{}
+	r := gin.New()
+	r.Use(Recovery(errorreporting.NewReporterWithClient(client, logrus.New())))
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", "42")
+		c.Set("username", "octocat")
+	})
+	r.GET("/boom", func(c *gin.Context) {
+		panic("kaboom")
+	})
+	r.GET("/fail", func(c *gin.Context) {
+		c.Error(errors.New("database unavailable"))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service unavailable"})
+	})
+	r.GET("/missing", func(c *gin.Context) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/boom?page=2", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Request-ID", "req-1")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	require.Len(t, client.events, 1)
+	event := client.events[0]
+	assert.Equal(t, errorreporting.LevelFatal, event.Level)
+	assert.Equal(t, "kaboom", event.Exception[0].Value)
+	frames := event.Exception[0].Stacktrace.Frames
+	require.NotEmpty(t, frames)
+	assert.Contains(t, frames[len(frames)-1].Function, "TestRecoveryReportsPanicsAndServerErrors")
+	assert.Equal(t, "page=2", event.Request.QueryString)
+	assert.NotContains(t, event.Request.Headers, "Authorization")
+	assert.Equal(t, "42", event.User.ID)
+	assert.Equal(t, "octocat", event.User.Username)
+	assert.Equal(t, "/boom", event.Tags["route"])
+	assert.Equal(t, "req-1", event.Tags["request_id"])
+	assert.Equal(t, "500", event.Tags["status"])
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fail", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Len(t, client.events, 2)
+	assert.Equal(t, "database unavailable", client.events[1].Exception[0].Value)
+	assert.Equal(t, "503", client.events[1].Tags["status"])
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Len(t, client.events, 2)
+}
@@ -0,0 +1,123 @@
+package middleware
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/auth"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// TenantMiddleware resolves the tenant (user, organization, repository and
+// effective permission) once per request and stores it on both the gin
+// context and the request context. Resolution failures are not fatal: the
+// handler still runs and performs its own not-found handling.
+//
+// When tracer is set and tracing is enabled for the user, the reasons for
+// the repository permission are recorded and the trace ID is returned in
+// the X-Authorization-Trace header.
+func TenantMiddleware(
+	instance string,
+	jwtManager *auth.JWTManager,
+	repositoryService services.RepositoryService,
+	orgService services.OrganizationService,
+	permissionService services.PermissionService,
+	tracer services.AuthorizationTraceService,
+	logger *logrus.Logger,
+) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		t := &tenant.Context{Instance: instance, ClientIP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+
+		// Authentication is optional here; AuthMiddleware still enforces it on protected routes
+		if value, ok := c.Get(FineGrainedTokenKey); ok {
+			userID := value.(*models.FineGrainedToken).UserID
+			t.UserID = &userID
+			t.Username = c.GetString("username")
+		} else if value, ok := c.Get(OAuthTokenKey); ok {
+			userID := value.(*models.OAuthAccessToken).UserID
+			t.UserID = &userID
+			t.Username = c.GetString("username")
+		} else if parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2); len(parts) == 2 && parts[0] == "Bearer" {
+			if claims, err := jwtManager.ValidateToken(parts[1]); err == nil {
+				userID := claims.UserID
+				t.UserID = &userID
+				t.Username = claims.Username
+				t.IsAdmin = claims.IsAdmin
+			}
+		}
+
+		if owner, name := c.Param("owner"), strings.TrimSuffix(c.Param("repo"), ".git"); owner != "" && name != "" {
+			if repo, err := repositoryService.Get(ctx, owner, name); err == nil {
+				t.Repository = repo
+				t.Owner = repo.Owner
+				if t.UserID != nil && tracer != nil && tracer.Enabled(t.Username) {
+					t.Permission = tracePermission(c, tracer, t, logger)
+				} else if t.UserID != nil {
+					perm, err := permissionService.CalculateUserPermission(ctx, *t.UserID, repo.ID)
+					if err != nil {
+						logger.WithError(err).Warn("Failed to resolve tenant permission")
+					}
+					t.Permission = perm
+				}
+			}
+		}
+
+		if orgName := c.Param("org"); orgName != "" {
+			if org, err := orgService.Get(ctx, orgName); err == nil {
+				t.Organization = org
+			}
+		}
+
+		c.Set(tenant.GinKey, t)
+		c.Request = c.Request.WithContext(tenant.NewContext(ctx, t))
+		c.Next()
+	}
+}
+
+// tracePermission resolves the tenant's repository permission and records
+// why it was given
+func tracePermission(c *gin.Context, tracer services.AuthorizationTraceService, t *tenant.Context, logger *logrus.Logger) models.Permission {
+	ctx := c.Request.Context()
+	trace, err := tracer.Trace(ctx, *t.UserID, t.Username, t.IsAdmin, t.Repository, c.Param("branch"))
+	if err != nil {
+		logger.WithError(err).Warn("Failed to resolve tenant permission")
+		return ""
+	}
+
+	if value, ok := c.Get(FineGrainedTokenKey); ok {
+		token := value.(*models.FineGrainedToken)
+		detail := fmt.Sprintf("request uses fine-grained token %q, which limits access to its own repositories and permissions", token.Name)
+		if token.IsScoped() {
+			detail = fmt.Sprintf("request uses personal access token %q, which limits access to its scopes", token.Name)
+		}
+		trace.Steps = append(trace.Steps, models.AuthorizationTraceStep{
+			Source:  models.AuthzSourceToken,
+			Detail:  detail,
+			Matched: true,
+		})
+	} else if _, ok := c.Get(OAuthTokenKey); ok {
+		trace.Steps = append(trace.Steps, models.AuthorizationTraceStep{
+			Source:  models.AuthzSourceToken,
+			Detail:  "request uses an OAuth access token, which limits access to its granted scopes",
+			Matched: true,
+		})
+	}
+
+	trace.RequestID = c.GetHeader("X-Request-ID")
+	if trace.RequestID == "" {
+		trace.RequestID = c.Writer.Header().Get("X-Request-ID")
+	}
+	trace.Method = c.Request.Method
+	trace.Path = c.Request.URL.Path
+	if err := tracer.Record(ctx, trace); err != nil {
+		logger.WithError(err).Warn("Failed to record authorization trace")
+	} else {
+		c.Header("X-Authorization-Trace", trace.ID.String())
+	}
+	return trace.Permission
+}
@@ -21,6 +21,9 @@ const (
 	EventRepositoryWatch       EventType = "repository.watch"
 	EventRepositoryPullRequest EventType = "repository.pull_request"
 
+	EventFeaturePreviewEnabled  EventType = "repository.feature_preview_enabled"
+	EventFeaturePreviewDisabled EventType = "repository.feature_preview_disabled"
+
 	// User Events
 	EventUserLogin         EventType = "user.login"
 	EventUserLogout        EventType = "user.logout"
@@ -41,6 +44,10 @@ const (
 	EventJobCompleted EventType = "job.completed"
 	EventDeployment   EventType = "deployment"
 
+	// Package Events
+	EventPackagePublished  EventType = "package.published"
+	EventPackageDownloaded EventType = "package.downloaded"
+
 	// Security Events
 	EventSecurityScan EventType = "security.scan"
 	EventAccessDenied EventType = "security.access_denied"
@@ -146,15 +153,17 @@ func (am *AnalyticsMetric) TableName() string {
 	return "analytics_metrics"
 }
 
-// RepositoryAnalytics stores repository-specific analytics
+// RepositoryAnalytics stores a daily snapshot of repository analytics.
+// Snapshots older than the compaction age are rolled into
+// RepositoryAnalyticsMonthly.
 type RepositoryAnalytics struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
-	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex"`
-	Date         time.Time `json:"date" gorm:"type:date;not null;index"`
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex:idx_repository_analytics_day,priority:1"`
+	Date         time.Time `json:"date" gorm:"type:date;not null;index;uniqueIndex:idx_repository_analytics_day,priority:2"`
 
 	// Code statistics
 	LinesOfCode      int64 `json:"lines_of_code" gorm:"default:0"`
@@ -191,6 +200,49 @@ func (ra *RepositoryAnalytics) TableName() string {
 	return "repository_analytics"
 }
 
+// RepositoryAnalyticsMonthly rolls up a month of daily RepositoryAnalytics
+// snapshots. Counts are running totals, so the month keeps the values of its
+// last snapshot; rates are averaged over the days that report them. The raw
+// daily rows are kept in object storage under ArchiveKey.
+type RepositoryAnalyticsMonthly struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex:idx_repository_analytics_month,priority:1"`
+	// Month is the first day of the month
+	Month time.Time `json:"month" gorm:"type:date;not null;uniqueIndex:idx_repository_analytics_month,priority:2"`
+	// Days is the number of daily snapshots rolled up
+	Days int `json:"days" gorm:"not null;default:0"`
+
+	LinesOfCode      int64 `json:"lines_of_code" gorm:"default:0"`
+	FileCount        int64 `json:"file_count" gorm:"default:0"`
+	CommitCount      int64 `json:"commit_count" gorm:"default:0"`
+	BranchCount      int64 `json:"branch_count" gorm:"default:0"`
+	ContributorCount int64 `json:"contributor_count" gorm:"default:0"`
+
+	ViewsCount    int64 `json:"views_count" gorm:"default:0"`
+	ClonesCount   int64 `json:"clones_count" gorm:"default:0"`
+	ForksCount    int64 `json:"forks_count" gorm:"default:0"`
+	StarsCount    int64 `json:"stars_count" gorm:"default:0"`
+	WatchersCount int64 `json:"watchers_count" gorm:"default:0"`
+
+	PullRequestsOpened int64 `json:"pull_requests_opened" gorm:"default:0"`
+	PullRequestsClosed int64 `json:"pull_requests_closed" gorm:"default:0"`
+	PullRequestsMerged int64 `json:"pull_requests_merged" gorm:"default:0"`
+
+	AveragePRMergeTime *float64 `json:"average_pr_merge_time,omitempty"`
+	BuildSuccessRate   *float64 `json:"build_success_rate,omitempty"`
+
+	LanguageStats string `json:"language_stats" gorm:"type:jsonb"`
+
+	ArchiveKey string `json:"archive_key" gorm:"size:255;not null"`
+}
+
+func (ra *RepositoryAnalyticsMonthly) TableName() string {
+	return "repository_analytics_monthly"
+}
+
 // UserAnalytics stores user-specific analytics
 type UserAnalytics struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
@@ -338,3 +390,40 @@ type PerformanceLog struct {
 func (pl *PerformanceLog) TableName() string {
 	return "performance_logs"
 }
+
+// RetentionPurgeTrigger records what started a retention purge run
+type RetentionPurgeTrigger string
+
+const (
+	RetentionPurgeScheduled RetentionPurgeTrigger = "scheduled"
+	RetentionPurgeManual    RetentionPurgeTrigger = "manual"
+)
+
+// RetentionPurgeRun records one pass of the analytics retention policy
+type RetentionPurgeRun struct {
+	ID         uuid.UUID  `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"index"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	Trigger       RetentionPurgeTrigger `json:"trigger" gorm:"type:varchar(20);not null"`
+	TriggeredByID *uuid.UUID            `json:"triggered_by_id,omitempty" gorm:"type:uuid"`
+	Status        string                `json:"status" gorm:"type:varchar(20);not null;index"` // running, success, error
+	ErrorMessage  string                `json:"error_message,omitempty" gorm:"type:text"`
+
+	// Policy in effect for the run, in days
+	EventsDays         int `json:"events_days"`
+	PerformanceLogDays int `json:"performance_log_days"`
+	AuditLogDays       int `json:"audit_log_days"`
+	AnonymizeAfterDays int `json:"anonymize_after_days"`
+
+	// Rows affected per data class
+	EventsPurged              int64 `json:"events_purged"`
+	PerformanceLogsPurged     int64 `json:"performance_logs_purged"`
+	AuditLogsPurged           int64 `json:"audit_logs_purged"`
+	EventsAnonymized          int64 `json:"events_anonymized"`
+	PerformanceLogsAnonymized int64 `json:"performance_logs_anonymized"`
+}
+
+func (r *RetentionPurgeRun) TableName() string {
+	return "retention_purge_runs"
+}
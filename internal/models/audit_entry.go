@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEntry is one security-relevant action in the audit trail. Entries
+// form a hash chain in Sequence order: Hash covers the entry's content and
+// the Hash of the entry before it, so editing or removing an entry breaks
+// the chain from that point on.
+type AuditEntry struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	Sequence  int64     `json:"sequence" gorm:"not null;uniqueIndex"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	Action         string     `json:"action" gorm:"size:64;not null;index"`
+	ActorID        *uuid.UUID `json:"actor_id,omitempty" gorm:"type:uuid;index"`
+	ActorName      string     `json:"actor_name,omitempty" gorm:"size:255"`
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty" gorm:"type:uuid;index"`
+	RepositoryID   *uuid.UUID `json:"repository_id,omitempty" gorm:"type:uuid;index"`
+	TargetType     string     `json:"target_type,omitempty" gorm:"size:64"`
+	TargetID       string     `json:"target_id,omitempty" gorm:"size:255"`
+	TargetName     string     `json:"target_name,omitempty" gorm:"size:255"`
+	IPAddress      string     `json:"ip_address,omitempty" gorm:"size:45"`
+	UserAgent      string     `json:"user_agent,omitempty" gorm:"size:255"`
+	// Metadata is a JSON object with action-specific details
+	Metadata string `json:"metadata,omitempty" gorm:"type:text"`
+
+	PrevHash string `json:"prev_hash" gorm:"size:64"`
+	Hash     string `json:"hash" gorm:"size:64;not null"`
+}
+
+func (e *AuditEntry) TableName() string {
+	return "audit_entries"
+}
+
+// AuditStreamState tracks how far the audit trail has been forwarded to
+// the external stream sink. There is a single row.
+type AuditStreamState struct {
+	ID             int        `json:"-" gorm:"primaryKey"`
+	LastSequence   int64      `json:"last_sequence"`
+	LastStreamedAt *time.Time `json:"last_streamed_at,omitempty"`
+	LastError      string     `json:"last_error,omitempty" gorm:"type:text"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (s *AuditStreamState) TableName() string {
+	return "audit_stream_states"
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Sources of an authorization trace step
+const (
+	AuthzSourceSiteAdmin          = "site_admin"
+	AuthzSourceOwner              = "owner"
+	AuthzSourceCollaborator       = "collaborator"
+	AuthzSourceOrganizationRole   = "organization_role"
+	AuthzSourceTeam               = "team"
+	AuthzSourceVisibility         = "visibility"
+	AuthzSourceOrganizationPolicy = "organization_policy"
+	AuthzSourceBranchProtection   = "branch_protection"
+	AuthzSourceToken              = "token"
+)
+
+// AuthorizationTraceStep is one check made while resolving a user's access
+// to a repository. Matched steps granted or restricted access; the others
+// were considered and did not apply.
+type AuthorizationTraceStep struct {
+	Source     string     `json:"source"`
+	Detail     string     `json:"detail"`
+	Permission Permission `json:"permission,omitempty"`
+	Matched    bool       `json:"matched"`
+}
+
+// AuthorizationTrace records why a request was given the permission it had
+// on a repository. Traces are only recorded while authorization tracing is
+// enabled.
+type AuthorizationTrace struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	RequestID string `json:"request_id,omitempty" gorm:"size:64;index"`
+	Method    string `json:"method,omitempty" gorm:"size:10"`
+	Path      string `json:"path,omitempty" gorm:"size:1024"`
+
+	UserID       *uuid.UUID `json:"user_id" gorm:"type:uuid;index"`
+	Username     string     `json:"username" gorm:"size:255"`
+	RepositoryID *uuid.UUID `json:"repository_id" gorm:"type:uuid;index"`
+	Repository   string     `json:"repository" gorm:"size:512"`
+	Branch       string     `json:"branch,omitempty" gorm:"size:255"`
+
+	// Permission is the effective permission the request was given
+	Permission Permission               `json:"permission"`
+	Steps      []AuthorizationTraceStep `json:"steps" gorm:"serializer:json;type:text"`
+}
+
+func (t *AuthorizationTrace) TableName() string {
+	return "authorization_traces"
+}
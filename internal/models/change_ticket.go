@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Change ticket providers a repository can validate tickets against
+const (
+	// ChangeTicketProviderPattern only checks that a ticket is referenced
+	ChangeTicketProviderPattern    = "pattern"
+	ChangeTicketProviderServiceNow = "servicenow"
+	ChangeTicketProviderJira       = "jira"
+)
+
+// ChangeManagementPolicy requires pull requests into matching branches of
+// a repository to reference a change ticket before they can be merged
+type ChangeManagementPolicy struct {
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;primaryKey"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	Enabled bool `json:"enabled" gorm:"not null"`
+	// BranchPattern limits the policy to pull requests into matching base branches
+	BranchPattern string `json:"branch_pattern" gorm:"not null;size:255;default:'*'"`
+	// TicketPattern is the regular expression ticket keys are found with in
+	// the pull request title and body, such as `CHG\d{7}`
+	TicketPattern string `json:"ticket_pattern" gorm:"not null;size:255"`
+	Provider      string `json:"provider" gorm:"type:varchar(20);not null;check:provider IN ('pattern','servicenow','jira')"`
+	// BaseURL, Username and Token are the provider's API endpoint and
+	// credentials; Jira takes an API token, or a personal access token
+	// without a username
+	BaseURL  string `json:"base_url,omitempty" gorm:"size:500"`
+	Username string `json:"username,omitempty" gorm:"size:255"`
+	Token    string `json:"-" gorm:"type:text;serializer:encrypted"`
+	// AllowedStates are the ticket states that allow merging, such as
+	// "Implement" or "Approved"; empty allows any state
+	AllowedStates []string `json:"allowed_states" gorm:"serializer:json;type:text"`
+}
+
+func (p *ChangeManagementPolicy) TableName() string {
+	return "change_management_policies"
+}
+
+// PullRequestChangeTicket links a pull request to the change ticket it
+// references, with the outcome of the ticket's last validation. The row
+// is kept after merge as the record of which ticket authorized the change.
+type PullRequestChangeTicket struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	PullRequestID uuid.UUID `json:"pull_request_id" gorm:"type:uuid;not null;uniqueIndex"`
+	RepositoryID  uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;index"`
+	TicketKey     string    `json:"ticket_key" gorm:"not null;size:100;index"`
+	Provider      string    `json:"provider" gorm:"type:varchar(20);not null"`
+	Valid         bool      `json:"valid"`
+	State         string    `json:"state,omitempty" gorm:"size:100"`
+	Summary       string    `json:"summary,omitempty" gorm:"size:500"`
+	URL           string    `json:"url,omitempty" gorm:"size:500"`
+	// Reason explains why the ticket is not valid
+	Reason      string     `json:"reason,omitempty" gorm:"type:text"`
+	ValidatedAt *time.Time `json:"validated_at,omitempty"`
+	MergedAt    *time.Time `json:"merged_at,omitempty"`
+}
+
+func (t *PullRequestChangeTicket) TableName() string {
+	return "pull_request_change_tickets"
+}
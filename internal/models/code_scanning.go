@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CodeScanningSeverity is the normalized severity of a code scanning alert
+type CodeScanningSeverity string
+
+const (
+	CodeScanningSeverityCritical CodeScanningSeverity = "critical"
+	CodeScanningSeverityHigh     CodeScanningSeverity = "high"
+	CodeScanningSeverityMedium   CodeScanningSeverity = "medium"
+	CodeScanningSeverityLow      CodeScanningSeverity = "low"
+)
+
+// CodeScanningAlertState is the lifecycle state of a code scanning alert
+type CodeScanningAlertState string
+
+const (
+	CodeScanningAlertOpen      CodeScanningAlertState = "open"
+	CodeScanningAlertDismissed CodeScanningAlertState = "dismissed"
+	CodeScanningAlertFixed     CodeScanningAlertState = "fixed"
+)
+
+// CodeScanningAlert is a finding reported by an external scanner on a ref.
+// Findings are deduplicated by fingerprint per repository, ref and tool, so
+// re-uploading an analysis updates alerts instead of duplicating them.
+type CodeScanningAlert struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	RepositoryID    uuid.UUID              `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex:idx_code_scanning_alerts_fingerprint;index"`
+	Number          int                    `json:"number" gorm:"not null"`
+	Ref             string                 `json:"ref" gorm:"not null;size:255;uniqueIndex:idx_code_scanning_alerts_fingerprint"`
+	Tool            string                 `json:"tool" gorm:"not null;size:100;uniqueIndex:idx_code_scanning_alerts_fingerprint"`
+	Fingerprint     string                 `json:"-" gorm:"not null;size:64;uniqueIndex:idx_code_scanning_alerts_fingerprint"`
+	CommitSHA       string                 `json:"commit_sha" gorm:"not null;size:40"`
+	RuleID          string                 `json:"rule_id" gorm:"not null;size:255"`
+	RuleDescription string                 `json:"rule_description" gorm:"type:text"`
+	Severity        CodeScanningSeverity   `json:"severity" gorm:"type:varchar(20);not null"`
+	Message         string                 `json:"message" gorm:"type:text"`
+	Path            string                 `json:"path" gorm:"size:1024"`
+	StartLine       int                    `json:"start_line"`
+	EndLine         int                    `json:"end_line"`
+	State           CodeScanningAlertState `json:"state" gorm:"type:varchar(20);not null;index"`
+	DismissedReason string                 `json:"dismissed_reason,omitempty" gorm:"size:50"`
+	DismissedByID   *uuid.UUID             `json:"dismissed_by_id,omitempty" gorm:"type:uuid"`
+	DismissedAt     *time.Time             `json:"dismissed_at,omitempty"`
+	FixedAt         *time.Time             `json:"fixed_at,omitempty"`
+}
+
+func (a *CodeScanningAlert) TableName() string {
+	return "code_scanning_alerts"
+}
+
+// CodeScanningAnalysis records one SARIF upload for a tool on a commit
+type CodeScanningAnalysis struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	RepositoryID uuid.UUID  `json:"repository_id" gorm:"type:uuid;not null;index"`
+	Ref          string     `json:"ref" gorm:"not null;size:255"`
+	CommitSHA    string     `json:"commit_sha" gorm:"not null;size:40"`
+	Tool         string     `json:"tool" gorm:"not null;size:100"`
+	ResultsCount int        `json:"results_count"`
+	NewAlerts    int        `json:"new_alerts"`
+	FixedAlerts  int        `json:"fixed_alerts"`
+	UploaderID   *uuid.UUID `json:"uploader_id" gorm:"type:uuid"`
+}
+
+func (a *CodeScanningAnalysis) TableName() string {
+	return "code_scanning_analyses"
+}
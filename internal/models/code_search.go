@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CodeSearchDocument is one file of a repository's default branch in the
+// built-in code search index
+type CodeSearchDocument struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex:idx_code_search_documents_repo_path"`
+	Path         string    `json:"path" gorm:"type:text;not null;uniqueIndex:idx_code_search_documents_repo_path"`
+	BlobSHA      string    `json:"blob_sha" gorm:"size:40;not null"`
+	Language     string    `json:"language" gorm:"size:50;index"`
+	SizeBytes    int64     `json:"size_bytes"`
+	Content      string    `json:"-" gorm:"type:text;not null"`
+}
+
+func (d *CodeSearchDocument) TableName() string {
+	return "code_search_documents"
+}
+
+// CodeSearchIndexStatus records the commit a repository's code search
+// index was last brought up to, so pushes only reindex what they changed
+type CodeSearchIndexStatus struct {
+	RepositoryID uuid.UUID      `json:"repository_id" gorm:"type:uuid;primaryKey"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	State        CodeIndexState `json:"state" gorm:"type:varchar(20);not null"`
+	CommitSHA    string         `json:"commit_sha" gorm:"size:40"`
+	Files        int            `json:"files"`
+	SizeBytes    int64          `json:"size_bytes"`
+	// Incremental is set when the last run only reindexed changed files
+	Incremental bool       `json:"incremental"`
+	DurationMs  int64      `json:"duration_ms"`
+	IndexedAt   *time.Time `json:"indexed_at,omitempty"`
+	Error       string     `json:"error,omitempty" gorm:"type:text"`
+}
+
+func (s *CodeSearchIndexStatus) TableName() string {
+	return "code_search_index_statuses"
+}
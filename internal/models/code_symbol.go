@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CodeSymbol is a definition or reference extracted from a repository file at a given ref
+type CodeSymbol struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;index:idx_code_symbols_repo_ref"`
+	Ref          string    `json:"ref" gorm:"size:255;not null;index:idx_code_symbols_repo_ref"`
+	CommitSHA    string    `json:"commit_sha" gorm:"size:40;not null"`
+	Path         string    `json:"path" gorm:"type:text;not null"`
+	Name         string    `json:"name" gorm:"size:255;not null;index"`
+	Kind         string    `json:"kind" gorm:"size:50"`
+	Language     string    `json:"language" gorm:"size:50"`
+	Line         int       `json:"line"`
+	Signature    string    `json:"signature,omitempty" gorm:"type:text"`
+	IsDefinition bool      `json:"is_definition" gorm:"not null;default:false"`
+
+	// Relationships
+	Repository Repository `json:"-" gorm:"foreignKey:RepositoryID"`
+}
+
+func (cs *CodeSymbol) TableName() string {
+	return "code_symbols"
+}
+
+type CodeIndexState string
+
+const (
+	CodeIndexStateIndexed  CodeIndexState = "indexed"
+	CodeIndexStateExcluded CodeIndexState = "excluded"
+	CodeIndexStateFailed   CodeIndexState = "failed"
+)
+
+// CodeIndexStatus records the last indexing run of a repository ref, so
+// search consumers can tell how fresh and how large the index is
+type CodeIndexStatus struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	RepositoryID uuid.UUID      `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex:idx_code_index_statuses_repo_ref"`
+	Ref          string         `json:"ref" gorm:"size:255;not null;uniqueIndex:idx_code_index_statuses_repo_ref"`
+	State        CodeIndexState `json:"state" gorm:"type:varchar(20);not null"`
+	// CommitSHA is the commit the index was built from
+	CommitSHA     string     `json:"commit_sha" gorm:"size:40"`
+	Files         int        `json:"files"`
+	ExcludedFiles int        `json:"excluded_files"`
+	Symbols       int        `json:"symbols"`
+	SizeBytes     int64      `json:"size_bytes"`
+	DurationMs    int64      `json:"duration_ms"`
+	IndexedAt     *time.Time `json:"indexed_at,omitempty"`
+	Error         string     `json:"error,omitempty" gorm:"type:text"`
+}
+
+func (s *CodeIndexStatus) TableName() string {
+	return "code_index_statuses"
+}
@@ -31,14 +31,39 @@ type Commit struct {
 	Deletions int `json:"deletions" gorm:"default:0"`
 	Changes   int `json:"changes" gorm:"default:0"`
 
+	// Signature verification, as of when the commit was synced
+	Verified           bool       `json:"verified" gorm:"default:false"`
+	VerificationReason string     `json:"verification_reason,omitempty" gorm:"size:50"`
+	SignerID           *uuid.UUID `json:"signer_id,omitempty" gorm:"type:uuid;index"`
+
 	// Relationships
-	Repository Repository `json:"repository,omitempty" gorm:"foreignKey:RepositoryID"`
+	Repository Repository      `json:"repository,omitempty" gorm:"foreignKey:RepositoryID"`
+	Trailers   []CommitTrailer `json:"trailers,omitempty" gorm:"foreignKey:CommitID"`
 }
 
 func (c *Commit) TableName() string {
 	return "commits"
 }
 
+// CommitTrailer is a trailer line of a commit message, such as
+// Co-authored-by. Name and Email are set for trailers naming a person, and
+// Email is lowercased so trailers can be matched against user emails.
+type CommitTrailer struct {
+	ID        uuid.UUID `json:"-" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"-"`
+
+	CommitID     uuid.UUID `json:"-" gorm:"type:uuid;not null;index"`
+	RepositoryID uuid.UUID `json:"-" gorm:"type:uuid;not null;index:idx_commit_trailer_identity"`
+	Key          string    `json:"key" gorm:"not null;size:100;index:idx_commit_trailer_identity"`
+	Value        string    `json:"value" gorm:"type:text"`
+	Name         string    `json:"name,omitempty" gorm:"size:255"`
+	Email        string    `json:"email,omitempty" gorm:"size:255;index:idx_commit_trailer_identity;index"`
+}
+
+func (t *CommitTrailer) TableName() string {
+	return "commit_trailers"
+}
+
 // CommitFile represents a file changed in a commit
 type CommitFile struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
@@ -115,8 +140,8 @@ type RepositoryHook struct {
 	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;index"`
 	Name         string    `json:"name" gorm:"not null;size:255"`
 	URL          string    `json:"url" gorm:"not null;size:500"`
-	Secret       string    `json:"secret" gorm:"size:255"`  // Webhook secret for verification
-	Events       string    `json:"events" gorm:"type:json"` // JSON array of events
+	Secret       string    `json:"secret" gorm:"type:text;serializer:encrypted"` // Webhook secret for verification
+	Events       string    `json:"events" gorm:"type:json"`                      // JSON array of events
 	Active       bool      `json:"active" gorm:"default:true"`
 	InsecureSSL  bool      `json:"insecure_ssl" gorm:"default:false"`
 	ContentType  string    `json:"content_type" gorm:"default:'application/json';size:50"`
@@ -0,0 +1,113 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CommitStatusState is the outcome an external system reports for a commit
+type CommitStatusState string
+
+const (
+	CommitStatusPending CommitStatusState = "pending"
+	CommitStatusSuccess CommitStatusState = "success"
+	CommitStatusFailure CommitStatusState = "failure"
+	CommitStatusError   CommitStatusState = "error"
+)
+
+// CommitStatus is a status reported for a commit by CI or another
+// integration; the latest status per context is the current one
+type CommitStatus struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	RepositoryID uuid.UUID         `json:"repository_id" gorm:"type:uuid;not null;index:idx_commit_statuses_repo_sha"`
+	SHA          string            `json:"sha" gorm:"not null;size:40;index:idx_commit_statuses_repo_sha"`
+	State        CommitStatusState `json:"state" gorm:"type:varchar(20);not null"`
+	Context      string            `json:"context" gorm:"not null;size:255;default:'default'"`
+	Description  string            `json:"description" gorm:"size:1024"`
+	TargetURL    string            `json:"target_url" gorm:"size:2048"`
+	CreatorID    *uuid.UUID        `json:"creator_id" gorm:"type:uuid;index"`
+
+	// Relationships
+	Creator *User `json:"creator,omitempty" gorm:"foreignKey:CreatorID"`
+}
+
+func (s *CommitStatus) TableName() string {
+	return "commit_statuses"
+}
+
+// RequiredStatusCheck requires the statuses whose context matches Pattern
+// to succeed before pull requests into branches matching BranchPattern can
+// be merged. Pattern is a glob such as ci/build-*.
+type RequiredStatusCheck struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	RepositoryID  uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;index"`
+	Pattern       string    `json:"pattern" gorm:"not null;size:255"`
+	BranchPattern string    `json:"branch_pattern" gorm:"not null;size:255;default:'*'"`
+	Description   string    `json:"description" gorm:"type:text"`
+}
+
+func (r *RequiredStatusCheck) TableName() string {
+	return "required_status_checks"
+}
+
+// CheckRunStatus is where a check run is in its lifecycle
+type CheckRunStatus string
+
+const (
+	CheckRunQueued     CheckRunStatus = "queued"
+	CheckRunInProgress CheckRunStatus = "in_progress"
+	CheckRunCompleted  CheckRunStatus = "completed"
+)
+
+// CheckRunConclusion is the outcome of a completed check run
+type CheckRunConclusion string
+
+const (
+	CheckRunSuccess        CheckRunConclusion = "success"
+	CheckRunFailure        CheckRunConclusion = "failure"
+	CheckRunNeutral        CheckRunConclusion = "neutral"
+	CheckRunCancelled      CheckRunConclusion = "cancelled"
+	CheckRunSkipped        CheckRunConclusion = "skipped"
+	CheckRunTimedOut       CheckRunConclusion = "timed_out"
+	CheckRunActionRequired CheckRunConclusion = "action_required"
+)
+
+// CheckRun is a check an external CI system runs against a commit. Unlike
+// a commit status it is updated in place as it progresses, and it counts
+// towards the combined status of the commit under its name.
+type CheckRun struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	RepositoryID uuid.UUID          `json:"repository_id" gorm:"type:uuid;not null;index:idx_check_runs_repo_sha"`
+	HeadSHA      string             `json:"head_sha" gorm:"not null;size:40;index:idx_check_runs_repo_sha"`
+	Name         string             `json:"name" gorm:"not null;size:255"`
+	Status       CheckRunStatus     `json:"status" gorm:"type:varchar(20);not null;default:'queued'"`
+	Conclusion   CheckRunConclusion `json:"conclusion,omitempty" gorm:"type:varchar(20)"`
+	ExternalID   string             `json:"external_id,omitempty" gorm:"size:255"`
+	DetailsURL   string             `json:"details_url,omitempty" gorm:"size:2048"`
+	Title        string             `json:"title,omitempty" gorm:"size:1024"`
+	Summary      string             `json:"summary,omitempty" gorm:"type:text"`
+	StartedAt    *time.Time         `json:"started_at,omitempty"`
+	CompletedAt  *time.Time         `json:"completed_at,omitempty"`
+	CreatorID    *uuid.UUID         `json:"creator_id" gorm:"type:uuid;index"`
+
+	// Relationships
+	Creator *User `json:"creator,omitempty" gorm:"foreignKey:CreatorID"`
+}
+
+func (r *CheckRun) TableName() string {
+	return "check_runs"
+}
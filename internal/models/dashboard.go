@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DashboardChartType controls how a dashboard widget is drawn
+type DashboardChartType string
+
+const (
+	DashboardChartLine   DashboardChartType = "line"
+	DashboardChartBar    DashboardChartType = "bar"
+	DashboardChartArea   DashboardChartType = "area"
+	DashboardChartNumber DashboardChartType = "number"
+	DashboardChartTable  DashboardChartType = "table"
+)
+
+// ThresholdSeverity ranks dashboard thresholds; critical outranks warning
+type ThresholdSeverity string
+
+const (
+	ThresholdSeverityWarning  ThresholdSeverity = "warning"
+	ThresholdSeverityCritical ThresholdSeverity = "critical"
+)
+
+// OrganizationDashboard is an org-defined set of metric widgets
+type OrganizationDashboard struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	OrganizationID uuid.UUID         `json:"organization_id" gorm:"type:uuid;not null;index"`
+	Name           string            `json:"name" gorm:"type:varchar(255);not null"`
+	Description    string            `json:"description" gorm:"type:text"`
+	CreatedByID    *uuid.UUID        `json:"created_by_id,omitempty" gorm:"type:uuid"`
+	Widgets        []DashboardWidget `json:"widgets" gorm:"serializer:json;type:text"`
+
+	// Relationships
+	Organization Organization `json:"-" gorm:"foreignKey:OrganizationID"`
+}
+
+func (od *OrganizationDashboard) TableName() string {
+	return "organization_dashboards"
+}
+
+// DashboardWidget is one chart on a dashboard: a metric query against the
+// analytics service plus the thresholds it is judged by
+type DashboardWidget struct {
+	Title        string               `json:"title"`
+	ChartType    DashboardChartType   `json:"chart_type"`
+	Metric       string               `json:"metric"`
+	MetricType   MetricType           `json:"metric_type,omitempty"`
+	Period       string               `json:"period,omitempty"`      // hourly, daily, weekly, monthly
+	Aggregation  string               `json:"aggregation,omitempty"` // sum, avg, min, max, last
+	RangeDays    int                  `json:"range_days,omitempty"`
+	RepositoryID *uuid.UUID           `json:"repository_id,omitempty"`
+	Thresholds   []DashboardThreshold `json:"thresholds,omitempty"`
+}
+
+// DashboardThreshold flags a widget once its value reaches Value, or drops
+// to it when Below is set
+type DashboardThreshold struct {
+	Value    float64           `json:"value"`
+	Severity ThresholdSeverity `json:"severity"`
+	Below    bool              `json:"below,omitempty"`
+	Label    string            `json:"label,omitempty"`
+}
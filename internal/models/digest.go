@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DigestFrequency controls how often an organization news feed digest is sent
+type DigestFrequency string
+
+const (
+	DigestFrequencyOff    DigestFrequency = "off"
+	DigestFrequencyDaily  DigestFrequency = "daily"
+	DigestFrequencyWeekly DigestFrequency = "weekly"
+	// DigestFrequencyDefault defers to the organization default frequency
+	DigestFrequencyDefault DigestFrequency = "default"
+)
+
+// OrganizationDigestSettings holds the org-admin controlled digest defaults
+type OrganizationDigestSettings struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	OrganizationID   uuid.UUID       `json:"organization_id" gorm:"type:uuid;not null;uniqueIndex"`
+	DefaultFrequency DigestFrequency `json:"default_frequency" gorm:"type:varchar(20);not null;default:'weekly'"`
+	IncludeMergedPRs bool            `json:"include_merged_prs" gorm:"default:true"`
+	IncludeReleases  bool            `json:"include_releases" gorm:"default:true"`
+	IncludeTopIssues bool            `json:"include_top_issues" gorm:"default:true"`
+	TopIssuesLimit   int             `json:"top_issues_limit" gorm:"default:5"`
+
+	// Relationships
+	Organization Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+}
+
+func (ods *OrganizationDigestSettings) TableName() string {
+	return "organization_digest_settings"
+}
+
+// DigestSubscription records a member's digest preference for an organization
+type DigestSubscription struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	OrganizationID   uuid.UUID       `json:"organization_id" gorm:"type:uuid;not null;index"`
+	UserID           uuid.UUID       `json:"user_id" gorm:"type:uuid;not null;index"`
+	Frequency        DigestFrequency `json:"frequency" gorm:"type:varchar(20);not null;default:'default'"`
+	UnsubscribeToken string          `json:"-" gorm:"uniqueIndex;not null;size:64"`
+	LastSentAt       *time.Time      `json:"last_sent_at"`
+
+	// Relationships
+	Organization Organization `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
+	User         User         `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+func (ds *DigestSubscription) TableName() string {
+	return "digest_subscriptions"
+}
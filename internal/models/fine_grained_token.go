@@ -0,0 +1,160 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FineGrainedTokenPrefix starts every fine-grained token so that the
+// authentication middleware can tell them apart from session JWTs
+const FineGrainedTokenPrefix = "hub_pat_"
+
+// FineGrainedTokenStatus is the lifecycle state of a fine-grained token
+type FineGrainedTokenStatus string
+
+const (
+	FineGrainedTokenActive          FineGrainedTokenStatus = "active"
+	FineGrainedTokenPendingApproval FineGrainedTokenStatus = "pending_approval"
+	FineGrainedTokenDenied          FineGrainedTokenStatus = "denied"
+	FineGrainedTokenRevoked         FineGrainedTokenStatus = "revoked"
+)
+
+// TokenPermission is the access a fine-grained token grants to one
+// permission category
+type TokenPermission string
+
+const (
+	TokenPermissionRead  TokenPermission = "read"
+	TokenPermissionWrite TokenPermission = "write"
+)
+
+// Fine-grained token permission categories
+const (
+	TokenScopeMetadata       = "metadata"
+	TokenScopeContents       = "contents"
+	TokenScopeIssues         = "issues"
+	TokenScopePullRequests   = "pull_requests"
+	TokenScopeStatuses       = "statuses"
+	TokenScopeSecurityEvents = "security_events"
+	TokenScopeWebhooks       = "webhooks"
+	TokenScopeAdministration = "administration"
+)
+
+// Token scopes of scoped tokens. Write scopes include the matching read
+// scope, repo:admin includes repo:write and admin:org includes read:org.
+const (
+	PATScopeRepoRead      = "repo:read"
+	PATScopeRepoWrite     = "repo:write"
+	PATScopeRepoAdmin     = "repo:admin"
+	PATScopePackagesRead  = "packages:read"
+	PATScopePackagesWrite = "packages:write"
+	PATScopeReadOrg       = "read:org"
+	PATScopeAdminOrg      = "admin:org"
+	PATScopeUserRead      = "user:read"
+	PATScopeUserWrite     = "user:write"
+)
+
+// PATScopes lists every token scope
+var PATScopes = []string{
+	PATScopeRepoRead, PATScopeRepoWrite, PATScopeRepoAdmin,
+	PATScopePackagesRead, PATScopePackagesWrite,
+	PATScopeReadOrg, PATScopeAdminOrg,
+	PATScopeUserRead, PATScopeUserWrite,
+}
+
+// patScopeImplies maps each scope to the broader scopes that include it
+var patScopeImplies = map[string][]string{
+	PATScopeRepoRead:     {PATScopeRepoWrite, PATScopeRepoAdmin},
+	PATScopeRepoWrite:    {PATScopeRepoAdmin},
+	PATScopePackagesRead: {PATScopePackagesWrite},
+	PATScopeReadOrg:      {PATScopeAdminOrg},
+	PATScopeUserRead:     {PATScopeUserWrite},
+}
+
+// FineGrainedToken is a personal access token. Repository tokens are
+// restricted to the selected repositories of one resource owner and to a
+// set of permission categories; tokens targeting an organization wait for
+// approval by an organization owner or admin before they authenticate.
+// Scoped tokens instead act for their user on every repository and
+// organization the user can reach, limited to their Scopes.
+type FineGrainedToken struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID      uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Name        string    `json:"name" gorm:"size:255;not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	TokenHash   string    `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	// TokenLastEight identifies the token in listings without revealing it
+	TokenLastEight string `json:"token_last_eight" gorm:"size:8"`
+
+	ResourceOwnerID   uuid.UUID                  `json:"resource_owner_id" gorm:"type:uuid;not null;index"`
+	ResourceOwnerType OwnerType                  `json:"resource_owner_type" gorm:"type:varchar(50);not null"`
+	RepositoryIDs     []uuid.UUID                `json:"repository_ids" gorm:"serializer:json;type:text"`
+	Permissions       map[string]TokenPermission `json:"permissions" gorm:"serializer:json;type:text"`
+	// Scopes is set on scoped tokens only
+	Scopes []string `json:"scopes,omitempty" gorm:"serializer:json;type:text"`
+
+	Status       FineGrainedTokenStatus `json:"status" gorm:"type:varchar(30);not null;index"`
+	ReviewedByID *uuid.UUID             `json:"reviewed_by_id,omitempty" gorm:"type:uuid"`
+	ReviewedAt   *time.Time             `json:"reviewed_at,omitempty"`
+	ReviewReason string                 `json:"review_reason,omitempty" gorm:"type:text"`
+
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty" gorm:"size:45"`
+	UsageCount int64      `json:"usage_count" gorm:"default:0"`
+	// ExpiryNotifiedAt is when the owner was warned that the token expires
+	ExpiryNotifiedAt *time.Time `json:"-"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+func (t *FineGrainedToken) TableName() string {
+	return "fine_grained_tokens"
+}
+
+// IsScoped reports whether the token is a scoped token rather than one
+// restricted to selected repositories
+func (t *FineGrainedToken) IsScoped() bool {
+	return len(t.Scopes) > 0
+}
+
+// HasScope reports whether a scoped token was granted scope or a scope
+// that includes it
+func (t *FineGrainedToken) HasScope(scope string) bool {
+	for _, granted := range t.Scopes {
+		if granted == scope {
+			return true
+		}
+		for _, broader := range patScopeImplies[scope] {
+			if granted == broader {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CoversRepository reports whether the token was granted repoID
+func (t *FineGrainedToken) CoversRepository(repoID uuid.UUID) bool {
+	for _, id := range t.RepositoryIDs {
+		if id == repoID {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether the token grants at least level on scope. Every
+// token may read repository metadata.
+func (t *FineGrainedToken) Allows(scope string, level TokenPermission) bool {
+	granted, ok := t.Permissions[scope]
+	if !ok {
+		return scope == TokenScopeMetadata && level == TokenPermissionRead
+	}
+	return granted == TokenPermissionWrite || level == TokenPermissionRead
+}
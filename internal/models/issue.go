@@ -28,15 +28,74 @@ type Issue struct {
 	State        IssueState `json:"state" gorm:"type:varchar(50);not null;check:state IN ('open','closed')"`
 	ClosedAt     *time.Time `json:"closed_at"`
 	ClosedByID   *uuid.UUID `json:"closed_by_id" gorm:"type:uuid;index"`
+	MilestoneID  *uuid.UUID `json:"milestone_id" gorm:"type:uuid;index"`
+
+	// Time tracking, in seconds. TotalTimeSpent is the sum of the issue's
+	// time entries and is kept in step with them.
+	TimeEstimate   int64 `json:"time_estimate" gorm:"not null;default:0"`
+	TotalTimeSpent int64 `json:"total_time_spent" gorm:"not null;default:0"`
 
 	// Relationships
 	Repository Repository `json:"repository,omitempty" gorm:"foreignKey:RepositoryID"`
 	User       *User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	ClosedBy   *User      `json:"closed_by,omitempty" gorm:"foreignKey:ClosedByID"`
+	Milestone  *Milestone `json:"milestone,omitempty" gorm:"foreignKey:MilestoneID"`
 	Comments   []Comment  `json:"comments,omitempty" gorm:"foreignKey:IssueID"`
 	Labels     []Label    `json:"labels,omitempty" gorm:"many2many:issue_labels"`
+	Assignees  []User     `json:"assignees,omitempty" gorm:"many2many:issue_assignees"`
 }
 
 func (i *Issue) TableName() string {
 	return "issues"
 }
+
+// IssueEventType is the kind of entry on an issue's timeline
+type IssueEventType string
+
+const (
+	IssueEventCrossReferenced IssueEventType = "cross_referenced"
+	IssueEventClosed          IssueEventType = "closed"
+	// IssueEventTransferred is recorded when an issue moves to another
+	// repository; SourceRepositoryID is the repository it came from
+	IssueEventTransferred IssueEventType = "transferred"
+)
+
+// IssueEvent is a timeline entry recorded when a commit or pull request
+// references an issue, or closes it through a closing keyword
+type IssueEvent struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	IssueID uuid.UUID      `json:"issue_id" gorm:"type:uuid;not null;index"`
+	Event   IssueEventType `json:"event" gorm:"type:varchar(50);not null"`
+	ActorID *uuid.UUID     `json:"actor_id" gorm:"type:uuid;index"`
+
+	// Source of the reference: a commit, a pull request, or both when a
+	// merged pull request closes the issue
+	SourceRepositoryID uuid.UUID  `json:"source_repository_id" gorm:"type:uuid;not null"`
+	CommitSHA          string     `json:"commit_sha,omitempty" gorm:"size:40"`
+	PullRequestID      *uuid.UUID `json:"pull_request_id,omitempty" gorm:"type:uuid;index"`
+	// WillClose is set on cross-references made with a closing keyword
+	WillClose bool `json:"will_close" gorm:"default:false"`
+
+	Actor *User `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+}
+
+func (e *IssueEvent) TableName() string {
+	return "issue_events"
+}
+
+// IssueRedirect points an issue number of a repository at the issue that was
+// transferred away from it
+type IssueRedirect struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex:idx_issue_redirect_number"`
+	Number       int       `json:"number" gorm:"not null;uniqueIndex:idx_issue_redirect_number"`
+	IssueID      uuid.UUID `json:"issue_id" gorm:"type:uuid;not null;index"`
+}
+
+func (r *IssueRedirect) TableName() string {
+	return "issue_redirects"
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LFSObject links a Git LFS object to a repository that uploaded it. Object
+// content is stored once per OID in the LFS storage backend; these rows
+// control which repositories may download it and count toward their quota.
+type LFSObject struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex:idx_lfs_objects_repository_oid"`
+	// OID is the hex SHA-256 of the object content
+	OID  string `json:"oid" gorm:"column:oid;not null;size:64;uniqueIndex:idx_lfs_objects_repository_oid;index"`
+	Size int64  `json:"size" gorm:"not null"`
+}
+
+func (o *LFSObject) TableName() string {
+	return "lfs_objects"
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobPolicyMode decides when a class of heavy background jobs may run
+// relative to maintenance windows
+type JobPolicyMode string
+
+const (
+	JobPolicyAnytime        JobPolicyMode = "anytime"
+	JobPolicyWindowsOnly    JobPolicyMode = "windows_only"
+	JobPolicyOutsideWindows JobPolicyMode = "outside_windows"
+)
+
+// MaintenanceWindow is a recurring period set aside for heavy background
+// work. It opens on each activation of Schedule, a cron expression evaluated
+// in Timezone, and stays open for DurationMinutes.
+type MaintenanceWindow struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Name            string `json:"name" gorm:"not null;size:255"`
+	Description     string `json:"description" gorm:"type:text"`
+	Schedule        string `json:"schedule" gorm:"not null;size:255"`
+	DurationMinutes int    `json:"duration_minutes" gorm:"not null"`
+	Timezone        string `json:"timezone" gorm:"not null;size:64;default:'UTC'"`
+	// JobClasses the window applies to; empty applies it to every class
+	JobClasses  []string   `json:"job_classes" gorm:"serializer:json;type:text"`
+	Enabled     bool       `json:"enabled" gorm:"default:true"`
+	CreatedByID *uuid.UUID `json:"created_by_id,omitempty" gorm:"type:uuid"`
+}
+
+func (w *MaintenanceWindow) TableName() string {
+	return "maintenance_windows"
+}
+
+// JobClassPolicy sets when jobs of a class may run. Classes without a
+// policy run at any time.
+type JobClassPolicy struct {
+	JobClass    string        `json:"job_class" gorm:"primaryKey;size:32"`
+	Mode        JobPolicyMode `json:"mode" gorm:"not null;size:32"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	UpdatedByID *uuid.UUID    `json:"updated_by_id,omitempty" gorm:"type:uuid"`
+}
+
+func (p *JobClassPolicy) TableName() string {
+	return "job_class_policies"
+}
+
+// BackgroundJob records a heavy scheduler as last seen by the maintenance
+// gate, so that its upcoming runs can be projected onto the calendar
+type BackgroundJob struct {
+	Name            string     `json:"name" gorm:"primaryKey;size:64"`
+	Class           string     `json:"class" gorm:"not null;size:32"`
+	IntervalSeconds int64      `json:"interval_seconds"`
+	LastStartedAt   *time.Time `json:"last_started_at,omitempty"`
+	// WaitingSince is set while the job is held back by its class policy
+	WaitingSince *time.Time `json:"waiting_since,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (j *BackgroundJob) TableName() string {
+	return "background_jobs"
+}
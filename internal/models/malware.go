@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Malware detection statuses. Quarantined content stays in storage but
+// cannot be downloaded until an admin releases or deletes it; blocked
+// content was rejected when it was uploaded.
+const (
+	MalwareStatusQuarantined = "quarantined"
+	MalwareStatusBlocked     = "blocked"
+	MalwareStatusReleased    = "released"
+	MalwareStatusDeleted     = "deleted"
+)
+
+// Kinds of scanned content
+const (
+	MalwareContentReleaseAsset = "release_asset"
+	MalwareContentLFSObject    = "lfs_object"
+	MalwareContentUpload       = "upload"
+)
+
+// MalwareDetection records uploaded content a malware scan flagged.
+// ContentID is the release asset ID or LFS OID the content is stored as;
+// quarantined detections form the admin review queue.
+type MalwareDetection struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ContentKind  string     `json:"content_kind" gorm:"not null;size:32;index:idx_malware_detections_content"`
+	ContentID    string     `json:"content_id" gorm:"not null;size:255;index:idx_malware_detections_content"`
+	RepositoryID uuid.UUID  `json:"repository_id" gorm:"type:uuid;not null;index"`
+	Name         string     `json:"name" gorm:"size:255"`
+	Size         int64      `json:"size"`
+	UploaderID   *uuid.UUID `json:"uploader_id" gorm:"type:uuid;index"`
+	Scanner      string     `json:"scanner" gorm:"size:32"`
+	Signature    string     `json:"signature" gorm:"size:255"`
+	Status       string     `json:"status" gorm:"not null;size:20;index"`
+
+	ReviewedByID *uuid.UUID `json:"reviewed_by_id" gorm:"type:uuid"`
+	ReviewedAt   *time.Time `json:"reviewed_at"`
+	ReviewNote   string     `json:"review_note" gorm:"type:text"`
+}
+
+func (d *MalwareDetection) TableName() string {
+	return "malware_detections"
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MergeChecklistItem is a manual check, such as "security review done", that
+// must be checked off on a pull request before it can be merged
+type MergeChecklistItem struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;index"`
+	Title        string    `json:"title" gorm:"not null;size:255"`
+	Description  string    `json:"description" gorm:"type:text"`
+	// BranchPattern limits the item to pull requests into matching base branches
+	BranchPattern string `json:"branch_pattern" gorm:"not null;size:255;default:'*'"`
+	// RequiredTeams restricts who may check the item off to members of these
+	// teams; empty lets anyone with write access do it
+	RequiredTeams []string `json:"required_teams" gorm:"serializer:json;type:text"`
+	Position      int      `json:"position" gorm:"not null;default:0"`
+}
+
+func (i *MergeChecklistItem) TableName() string {
+	return "merge_checklist_items"
+}
+
+// PullRequestChecklistCheck marks a checklist item as done on a pull request
+type PullRequestChecklistCheck struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	PullRequestID uuid.UUID `json:"pull_request_id" gorm:"type:uuid;not null;uniqueIndex:idx_pull_request_checklist_checks_item"`
+	ItemID        uuid.UUID `json:"item_id" gorm:"type:uuid;not null;uniqueIndex:idx_pull_request_checklist_checks_item"`
+	CheckedByID   uuid.UUID `json:"checked_by_id" gorm:"type:uuid;not null"`
+	Note          string    `json:"note,omitempty" gorm:"type:text"`
+
+	CheckedBy *User `json:"checked_by,omitempty" gorm:"foreignKey:CheckedByID"`
+}
+
+func (c *PullRequestChecklistCheck) TableName() string {
+	return "pull_request_checklist_checks"
+}
+
+type ChecklistAction string
+
+const (
+	ChecklistActionChecked   ChecklistAction = "checked"
+	ChecklistActionUnchecked ChecklistAction = "unchecked"
+)
+
+// PullRequestChecklistEvent is the audit trail of checklist changes on a
+// pull request. The item title is copied so the trail survives edits.
+type PullRequestChecklistEvent struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	PullRequestID uuid.UUID       `json:"pull_request_id" gorm:"type:uuid;not null;index"`
+	ItemID        uuid.UUID       `json:"item_id" gorm:"type:uuid;not null"`
+	ItemTitle     string          `json:"item_title" gorm:"not null;size:255"`
+	ActorID       uuid.UUID       `json:"actor_id" gorm:"type:uuid;not null"`
+	Action        ChecklistAction `json:"action" gorm:"type:varchar(20);not null"`
+	Note          string          `json:"note,omitempty" gorm:"type:text"`
+
+	Actor *User `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+}
+
+func (e *PullRequestChecklistEvent) TableName() string {
+	return "pull_request_checklist_events"
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type MilestoneState string
+
+const (
+	MilestoneStateOpen   MilestoneState = "open"
+	MilestoneStateClosed MilestoneState = "closed"
+)
+
+// Milestone groups the issues of a repository towards a target date
+type Milestone struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	RepositoryID uuid.UUID      `json:"repository_id" gorm:"type:uuid;not null;index"`
+	Number       int            `json:"number" gorm:"not null"`
+	Title        string         `json:"title" gorm:"not null;size:255"`
+	Description  string         `json:"description" gorm:"type:text"`
+	State        MilestoneState `json:"state" gorm:"type:varchar(50);not null;default:'open'"`
+	DueOn        *time.Time     `json:"due_on"`
+}
+
+func (m *Milestone) TableName() string {
+	return "milestones"
+}
+
+// TimeEntry is time logged against an issue. Negative entries subtract
+// time, so resetting spent time keeps the history.
+type TimeEntry struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	IssueID uuid.UUID `json:"issue_id" gorm:"type:uuid;not null;index"`
+	UserID  uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Seconds int64     `json:"seconds" gorm:"not null"`
+	SpentAt time.Time `json:"spent_at" gorm:"not null;index"`
+	Summary string    `json:"summary" gorm:"size:255"`
+
+	User *User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+func (e *TimeEntry) TableName() string {
+	return "issue_time_entries"
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// NotificationReason is why a user was notified about a subject
+type NotificationReason string
+
+const (
+	NotificationReasonMention         NotificationReason = "mention"
+	NotificationReasonReviewRequested NotificationReason = "review_requested"
+	NotificationReasonAssign          NotificationReason = "assign"
+	// NotificationReasonCIActivity is a failed workflow run the user started
+	// or that ran on their pull request
+	NotificationReasonCIActivity NotificationReason = "ci_activity"
+	// NotificationReasonSubscribed is activity in a watched repository
+	NotificationReasonSubscribed NotificationReason = "subscribed"
+)
+
+// NotificationSubjectType is the kind of object a notification is about
+type NotificationSubjectType string
+
+const (
+	NotificationSubjectIssue       NotificationSubjectType = "issue"
+	NotificationSubjectPullRequest NotificationSubjectType = "pull_request"
+	NotificationSubjectWorkflowRun NotificationSubjectType = "workflow_run"
+)
+
+// Notification is a user's notification thread about one subject. New
+// activity on the subject updates the thread and marks it unread again
+// instead of adding another notification.
+type Notification struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"index"`
+
+	UserID       uuid.UUID               `json:"-" gorm:"type:uuid;not null;uniqueIndex:idx_notifications_user_subject;index:idx_notifications_user_read"`
+	RepositoryID uuid.UUID               `json:"repository_id" gorm:"type:uuid;not null;index"`
+	Repository   string                  `json:"repository" gorm:"not null;size:255"`
+	SubjectType  NotificationSubjectType `json:"subject_type" gorm:"type:varchar(20);not null;uniqueIndex:idx_notifications_user_subject"`
+	SubjectID    uuid.UUID               `json:"subject_id" gorm:"type:uuid;not null;uniqueIndex:idx_notifications_user_subject"`
+	Number       int                     `json:"number,omitempty"`
+	Title        string                  `json:"title" gorm:"not null;size:255"`
+	URL          string                  `json:"url" gorm:"size:2048"`
+	Reason       NotificationReason      `json:"reason" gorm:"type:varchar(30);not null"`
+	ActorID      *uuid.UUID              `json:"actor_id,omitempty" gorm:"type:uuid"`
+	ReadAt       *time.Time              `json:"read_at" gorm:"index:idx_notifications_user_read"`
+	// EmailedAt is when the thread was last included in an email digest
+	EmailedAt *time.Time `json:"-"`
+
+	// Relationships
+	Actor *User `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+}
+
+func (n *Notification) TableName() string {
+	return "notifications"
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Prefixes of the secrets the hub issues as an OAuth provider, so that the
+// authentication middleware can tell them apart from other credentials
+const (
+	OAuthClientSecretPrefix = "hub_ocs_"
+	OAuthAccessTokenPrefix  = "hub_oat_"
+	OAuthRefreshTokenPrefix = "hub_ort_"
+)
+
+// OAuth scopes applications can request
+const (
+	// OAuthScopeOpenID asks for an OpenID Connect ID token
+	OAuthScopeOpenID = "openid"
+	// OAuthScopeProfile and OAuthScopeEmail release claims from userinfo
+	OAuthScopeProfile = "profile"
+	OAuthScopeEmail   = "email"
+	// OAuthScopeReadUser reads the account endpoints; OAuthScopeUser also
+	// changes them
+	OAuthScopeReadUser = "read:user"
+	OAuthScopeUser     = "user"
+	// OAuthScopeReadRepo reads the repositories the user can read;
+	// OAuthScopeRepo also writes to them
+	OAuthScopeReadRepo = "read:repo"
+	OAuthScopeRepo     = "repo"
+)
+
+// OAuthApplication is a third-party application registered to sign users in
+// with the hub and call its API on their behalf. Public clients, such as
+// native and single-page apps, have no secret and must use PKCE.
+type OAuthApplication struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OwnerID     uuid.UUID `json:"owner_id" gorm:"type:uuid;not null;index"`
+	Name        string    `json:"name" gorm:"size:255;not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	HomepageURL string    `json:"homepage_url" gorm:"size:500"`
+
+	ClientID         string `json:"client_id" gorm:"size:64;not null;uniqueIndex"`
+	ClientSecretHash string `json:"-" gorm:"size:64"`
+	// ClientSecretLastEight identifies the secret without revealing it
+	ClientSecretLastEight string   `json:"client_secret_last_eight,omitempty" gorm:"size:8"`
+	Confidential          bool     `json:"confidential"`
+	RedirectURIs          []string `json:"redirect_uris" gorm:"serializer:json;type:text"`
+	// Scopes bounds what the application may request
+	Scopes []string `json:"scopes" gorm:"serializer:json;type:text"`
+
+	// Trusted applications are vetted by a site admin and skip the consent
+	// screen
+	Trusted         bool       `json:"trusted"`
+	SuspendedAt     *time.Time `json:"suspended_at,omitempty"`
+	SuspendedReason string     `json:"suspended_reason,omitempty" gorm:"type:text"`
+
+	// Relationships
+	Owner User `json:"-" gorm:"foreignKey:OwnerID"`
+}
+
+func (a *OAuthApplication) TableName() string {
+	return "oauth_applications"
+}
+
+// AllowsRedirect reports whether uri exactly matches a registered redirect URI
+func (a *OAuthApplication) AllowsRedirect(uri string) bool {
+	for _, registered := range a.RedirectURIs {
+		if registered == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthAuthorization records the scopes a user consented to grant an
+// application. Revoking it revokes every token of the application for the
+// user.
+type OAuthAuthorization struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID        uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_oauth_authorization_user_app"`
+	ApplicationID uuid.UUID `json:"application_id" gorm:"type:uuid;not null;uniqueIndex:idx_oauth_authorization_user_app"`
+	Scopes        []string  `json:"scopes" gorm:"serializer:json;type:text"`
+
+	// Relationships
+	Application OAuthApplication `json:"application" gorm:"foreignKey:ApplicationID"`
+}
+
+func (a *OAuthAuthorization) TableName() string {
+	return "oauth_authorizations"
+}
+
+// OAuthAuthorizationCode is a single-use code handed to the application's
+// redirect URI after consent and exchanged for tokens
+type OAuthAuthorizationCode struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	CodeHash            string    `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	ApplicationID       uuid.UUID `json:"application_id" gorm:"type:uuid;not null;index"`
+	UserID              uuid.UUID `json:"user_id" gorm:"type:uuid;not null"`
+	RedirectURI         string    `json:"redirect_uri" gorm:"size:500;not null"`
+	Scopes              []string  `json:"scopes" gorm:"serializer:json;type:text"`
+	CodeChallenge       string    `json:"-" gorm:"size:128"`
+	CodeChallengeMethod string    `json:"-" gorm:"size:10"`
+	Nonce               string    `json:"-" gorm:"size:255"`
+
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+func (c *OAuthAuthorizationCode) TableName() string {
+	return "oauth_authorization_codes"
+}
+
+// OAuthAccessToken is an access token issued to an application together
+// with the refresh token that renews it. Refreshing rotates both.
+type OAuthAccessToken struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ApplicationID    uuid.UUID `json:"application_id" gorm:"type:uuid;not null;index"`
+	UserID           uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	TokenHash        string    `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	RefreshTokenHash string    `json:"-" gorm:"size:64;uniqueIndex"`
+	Scopes           []string  `json:"scopes" gorm:"serializer:json;type:text"`
+
+	ExpiresAt        time.Time  `json:"expires_at"`
+	RefreshExpiresAt *time.Time `json:"refresh_expires_at,omitempty"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty"`
+
+	// Relationships
+	Application OAuthApplication `json:"-" gorm:"foreignKey:ApplicationID"`
+	User        User             `json:"-" gorm:"foreignKey:UserID"`
+}
+
+func (t *OAuthAccessToken) TableName() string {
+	return "oauth_access_tokens"
+}
+
+// HasScope reports whether the token was granted scope. The write scopes
+// imply their read counterparts.
+func (t *OAuthAccessToken) HasScope(scope string) bool {
+	for _, granted := range t.Scopes {
+		if granted == scope ||
+			scope == OAuthScopeReadRepo && granted == OAuthScopeRepo ||
+			scope == OAuthScopeReadUser && granted == OAuthScopeUser {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeString joins the token's scopes as OAuth responses spell them
+func (t *OAuthAccessToken) ScopeString() string {
+	return strings.Join(t.Scopes, " ")
+}
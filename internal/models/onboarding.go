@@ -0,0 +1,52 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OnboardingStep is a step of the onboarding checklist
+type OnboardingStep string
+
+const (
+	OnboardingStepAddSSHKey        OnboardingStep = "add_ssh_key"
+	OnboardingStepCreateRepository OnboardingStep = "create_repository"
+	OnboardingStepInviteMember     OnboardingStep = "invite_member"
+	OnboardingStepCreateTeam       OnboardingStep = "create_team"
+)
+
+// Onboarding tracks the onboarding checklist of a user or organization. Steps
+// stay completed once done, even if what completed them is later removed.
+type Onboarding struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OwnerID   uuid.UUID `json:"owner_id" gorm:"type:uuid;not null;uniqueIndex:idx_onboarding_owner"`
+	OwnerType OwnerType `json:"owner_type" gorm:"type:varchar(50);not null;uniqueIndex:idx_onboarding_owner"`
+	// CompletedSteps is a JSON object of step completion times
+	CompletedSteps     string     `json:"-" gorm:"type:text"`
+	SampleRepositoryID *uuid.UUID `json:"sample_repository_id,omitempty" gorm:"type:uuid"`
+	DismissedAt        *time.Time `json:"dismissed_at,omitempty"`
+}
+
+func (o *Onboarding) TableName() string {
+	return "onboardings"
+}
+
+// Completed returns when each completed step was completed
+func (o *Onboarding) Completed() map[OnboardingStep]time.Time {
+	steps := map[OnboardingStep]time.Time{}
+	if o.CompletedSteps != "" {
+		json.Unmarshal([]byte(o.CompletedSteps), &steps)
+	}
+	return steps
+}
+
+// SetCompleted stores the step completion times
+func (o *Onboarding) SetCompleted(steps map[OnboardingStep]time.Time) {
+	data, _ := json.Marshal(steps)
+	o.CompletedSteps = string(data)
+}
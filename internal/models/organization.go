@@ -22,6 +22,9 @@ type Organization struct {
 	Email        string `json:"email" gorm:"size:255"`
 	BillingEmail string `json:"billing_email" gorm:"size:255"`
 
+	// Version is bumped by every settings update and served as the ETag
+	Version int64 `json:"version" gorm:"not null;default:1"`
+
 	// Relationships
 	Members      []OrganizationMember `json:"members,omitempty" gorm:"foreignKey:OrganizationID"`
 	Teams        []Team               `json:"teams,omitempty" gorm:"foreignKey:OrganizationID"`
@@ -105,6 +108,9 @@ type Team struct {
 	Privacy        TeamPrivacy `json:"privacy" gorm:"type:varchar(50);not null;check:privacy IN ('closed','secret')"`
 	ParentTeamID   *uuid.UUID  `json:"parent_team_id,omitempty" gorm:"type:uuid;index"`
 
+	// Version is bumped by every settings update and served as the ETag
+	Version int64 `json:"version" gorm:"not null;default:1"`
+
 	// Relationships
 	Organization Organization           `json:"organization,omitempty" gorm:"foreignKey:OrganizationID"`
 	Members      []TeamMember           `json:"members,omitempty" gorm:"foreignKey:TeamID"`
@@ -206,12 +212,20 @@ const (
 	ActivityTeamCreated             ActivityAction = "team.created"
 	ActivityTeamDeleted             ActivityAction = "team.deleted"
 	ActivityTeamUpdated             ActivityAction = "team.updated"
+	ActivityTeamMemberAdded         ActivityAction = "team.member_added"
+	ActivityTeamMemberRemoved       ActivityAction = "team.member_removed"
+	ActivityTeamMemberRoleChanged   ActivityAction = "team.member_role_changed"
 	ActivityRepositoryCreated       ActivityAction = "repository.created"
 	ActivityRepositoryDeleted       ActivityAction = "repository.deleted"
 	ActivityInvitationSent          ActivityAction = "invitation.sent"
 	ActivityInvitationAccepted      ActivityAction = "invitation.accepted"
 	ActivityPermissionGranted       ActivityAction = "permission.granted"
 	ActivityPermissionRevoked       ActivityAction = "permission.revoked"
+	ActivityCredentialRevoked       ActivityAction = "credential.revoked"
+
+	ActivityRepositoryApprovalRequested ActivityAction = "repository.approval_requested"
+	ActivityRepositoryApprovalApproved  ActivityAction = "repository.approval_approved"
+	ActivityRepositoryApprovalRejected  ActivityAction = "repository.approval_rejected"
 )
 
 type OrganizationActivity struct {
@@ -305,6 +319,16 @@ func (ot *OrganizationTemplate) TableName() string {
 	return "organization_templates"
 }
 
+// VisibilityChangePolicy names who may change the visibility of
+// organization repositories
+type VisibilityChangePolicy string
+
+const (
+	VisibilityChangeOwners           VisibilityChangePolicy = "owners"
+	VisibilityChangeAdmins           VisibilityChangePolicy = "admins"
+	VisibilityChangeRepositoryAdmins VisibilityChangePolicy = "repository_admins"
+)
+
 // Organization Settings Enhancement
 type OrganizationSettings struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
@@ -334,6 +358,19 @@ type OrganizationSettings struct {
 	AllowForking              bool   `json:"allow_forking" gorm:"default:true"`
 	AllowOutsideCollaborators bool   `json:"allow_outside_collaborators" gorm:"default:true"`
 
+	// Forking and visibility policy for organization repositories
+	AllowPrivateForking           bool                   `json:"allow_private_forking" gorm:"default:true"`
+	AllowForksOutsideOrganization bool                   `json:"allow_forks_outside_organization" gorm:"default:true"`
+	VisibilityChangePolicy        VisibilityChangePolicy `json:"visibility_change_policy" gorm:"size:30;default:'repository_admins'"`
+
+	// Creating and deleting repositories waits for an owner or admin to approve
+	RequireRepositoryCreationApproval bool `json:"require_repository_creation_approval" gorm:"default:false"`
+	RequireRepositoryDeletionApproval bool `json:"require_repository_deletion_approval" gorm:"default:false"`
+
+	// Code search indexing: repositories by name and path patterns left out of the index
+	CodeSearchExcludedRepositories []string `json:"code_search_excluded_repositories" gorm:"serializer:json;type:text"`
+	CodeSearchExcludedPaths        []string `json:"code_search_excluded_paths" gorm:"serializer:json;type:text"`
+
 	// Billing and Usage
 	BillingPlan     string     `json:"billing_plan" gorm:"size:50;default:'free'"`
 	SeatCount       int        `json:"seat_count" gorm:"default:0"`
@@ -353,3 +390,52 @@ type OrganizationSettings struct {
 func (os *OrganizationSettings) TableName() string {
 	return "organization_settings"
 }
+
+// RepositoryApprovalAction is what a repository approval request asks for
+type RepositoryApprovalAction string
+
+const (
+	RepositoryApprovalCreate RepositoryApprovalAction = "create"
+	RepositoryApprovalDelete RepositoryApprovalAction = "delete"
+)
+
+// RepositoryApprovalStatus is where a repository approval request stands
+type RepositoryApprovalStatus string
+
+const (
+	RepositoryApprovalPending  RepositoryApprovalStatus = "pending"
+	RepositoryApprovalApproved RepositoryApprovalStatus = "approved"
+	RepositoryApprovalRejected RepositoryApprovalStatus = "rejected"
+)
+
+// RepositoryApprovalRequest is a repository creation or deletion in an
+// organization waiting for an owner or admin, as its repository policy
+// requires. Approving it carries the action out.
+type RepositoryApprovalRequest struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	OrganizationID uuid.UUID                `json:"organization_id" gorm:"type:uuid;not null;index"`
+	Action         RepositoryApprovalAction `json:"action" gorm:"type:varchar(10);not null"`
+	Status         RepositoryApprovalStatus `json:"status" gorm:"type:varchar(10);not null;default:'pending';index"`
+	RepositoryName string                   `json:"repository_name" gorm:"not null;size:255"`
+	// RepositoryID is the repository to delete, or the one created on approval
+	RepositoryID *uuid.UUID `json:"repository_id,omitempty" gorm:"type:uuid;index"`
+	// Settings holds the repository to create, as JSON
+	Settings      string     `json:"settings,omitempty" gorm:"type:text"`
+	Reason        string     `json:"reason" gorm:"type:text"`
+	RequesterID   uuid.UUID  `json:"requester_id" gorm:"type:uuid;not null;index"`
+	ReviewerID    *uuid.UUID `json:"reviewer_id,omitempty" gorm:"type:uuid"`
+	ReviewComment string     `json:"review_comment,omitempty" gorm:"type:text"`
+	ReviewedAt    *time.Time `json:"reviewed_at,omitempty"`
+
+	// Relationships
+	Requester *User `json:"requester,omitempty" gorm:"foreignKey:RequesterID"`
+	Reviewer  *User `json:"reviewer,omitempty" gorm:"foreignKey:ReviewerID"`
+}
+
+func (r *RepositoryApprovalRequest) TableName() string {
+	return "repository_approval_requests"
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SCIMTokenPrefix starts every SCIM provisioning token
+const SCIMTokenPrefix = "hub_scim_"
+
+// SCIMToken authenticates an organization's identity provider to the SCIM
+// provisioning API. Only the hash of the token is stored.
+type SCIMToken struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	OrganizationID uuid.UUID `json:"organization_id" gorm:"type:uuid;not null;index"`
+	Description    string    `json:"description" gorm:"size:255"`
+	TokenHash      string    `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	// TokenLastEight identifies the token in listings without revealing it
+	TokenLastEight string     `json:"token_last_eight" gorm:"size:8"`
+	CreatedByID    uuid.UUID  `json:"created_by_id" gorm:"type:uuid;not null"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty"`
+}
+
+func (t *SCIMToken) TableName() string {
+	return "scim_tokens"
+}
+
+// SCIMUser is a user provisioned into an organization by its identity
+// provider; its ID is the SCIM resource id. Users removed at the identity
+// provider are deactivated and soft-deleted rather than erased, so that
+// provisioning them again restores the same account.
+type SCIMUser struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	OrganizationID uuid.UUID `json:"organization_id" gorm:"type:uuid;not null;uniqueIndex:idx_scim_users_org_user"`
+	UserID         uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_scim_users_org_user"`
+	ExternalID     string    `json:"external_id" gorm:"size:255;index"`
+	UserName       string    `json:"user_name" gorm:"not null;size:255"`
+	GivenName      string    `json:"given_name" gorm:"size:255"`
+	FamilyName     string    `json:"family_name" gorm:"size:255"`
+	Active         bool      `json:"active"`
+	// Provisioned is set when the user account was created by SCIM, which
+	// makes the identity provider responsible for the whole account
+	Provisioned   bool       `json:"provisioned"`
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+func (u *SCIMUser) TableName() string {
+	return "scim_users"
+}
+
+// SCIMGroup links an identity provider group to the organization team whose
+// membership it manages; its ID is the SCIM resource id
+type SCIMGroup struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrganizationID uuid.UUID `json:"organization_id" gorm:"type:uuid;not null;index"`
+	TeamID         uuid.UUID `json:"team_id" gorm:"type:uuid;not null;uniqueIndex"`
+	ExternalID     string    `json:"external_id" gorm:"size:255;index"`
+
+	Team Team `json:"team,omitempty" gorm:"foreignKey:TeamID"`
+}
+
+func (g *SCIMGroup) TableName() string {
+	return "scim_groups"
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Organization SSO protocols
+const (
+	SSOProtocolSAML = "saml"
+	SSOProtocolOIDC = "oidc"
+)
+
+// SSOTeamMapping grants membership of a team to users whose identity
+// provider groups include Group
+type SSOTeamMapping struct {
+	Group  string    `json:"group"`
+	TeamID uuid.UUID `json:"team_id"`
+	Role   TeamRole  `json:"role"`
+}
+
+// OrganizationSSOConfig connects an organization to an external identity
+// provider. While Enforced, members must hold an active SSO session to use
+// the organization's resources.
+type OrganizationSSOConfig struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrganizationID uuid.UUID `json:"organization_id" gorm:"type:uuid;not null;uniqueIndex"`
+	Protocol       string    `json:"protocol" gorm:"not null;size:10"`
+	Enabled        bool      `json:"enabled"`
+	Enforced       bool      `json:"enforced"`
+
+	// OIDC
+	IssuerURL    string   `json:"issuer_url,omitempty" gorm:"size:500"`
+	ClientID     string   `json:"client_id,omitempty" gorm:"size:255"`
+	ClientSecret string   `json:"-" gorm:"type:text;serializer:encrypted"`
+	Scopes       []string `json:"scopes,omitempty" gorm:"serializer:json;type:text"`
+
+	// SAML
+	IdPEntityID    string `json:"idp_entity_id,omitempty" gorm:"size:500"`
+	IdPSSOURL      string `json:"idp_sso_url,omitempty" gorm:"size:500"`
+	IdPCertificate string `json:"idp_certificate,omitempty" gorm:"type:text"`
+
+	// GroupsAttribute is the claim or attribute listing the user's groups
+	GroupsAttribute string           `json:"groups_attribute" gorm:"size:255"`
+	JITProvisioning bool             `json:"jit_provisioning"`
+	DefaultRole     OrganizationRole `json:"default_role" gorm:"type:varchar(50)"`
+	SessionHours    int              `json:"session_hours"`
+	TeamMappings    []SSOTeamMapping `json:"team_mappings" gorm:"serializer:json;type:text"`
+
+	UpdatedByID *uuid.UUID `json:"updated_by_id,omitempty" gorm:"type:uuid"`
+}
+
+func (c *OrganizationSSOConfig) TableName() string {
+	return "organization_sso_configs"
+}
+
+// OrganizationSSOIdentity links a user to their identity at an
+// organization's identity provider and tracks their SSO session
+type OrganizationSSOIdentity struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrganizationID uuid.UUID `json:"organization_id" gorm:"type:uuid;not null;uniqueIndex:idx_org_sso_identity_user;uniqueIndex:idx_org_sso_identity_external"`
+	UserID         uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_org_sso_identity_user"`
+	ExternalID     string    `json:"external_id" gorm:"not null;size:255;uniqueIndex:idx_org_sso_identity_external"`
+	Email          string    `json:"email" gorm:"size:255"`
+	Groups         []string  `json:"groups" gorm:"serializer:json;type:text"`
+	// Provisioned is set when the user account was created by SSO
+	Provisioned bool `json:"provisioned"`
+
+	LastAuthenticatedAt *time.Time `json:"last_authenticated_at"`
+	SessionExpiresAt    *time.Time `json:"session_expires_at"`
+
+	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+func (i *OrganizationSSOIdentity) TableName() string {
+	return "organization_sso_identities"
+}
+
+// SSOLoginState is a pending organization SSO login, looked up by the
+// state or relay state the identity provider echoes back
+type SSOLoginState struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	State          string     `json:"-" gorm:"not null;size:64;uniqueIndex"`
+	OrganizationID uuid.UUID  `json:"organization_id" gorm:"type:uuid;not null"`
+	UserID         *uuid.UUID `json:"user_id" gorm:"type:uuid"`
+	Nonce          string     `json:"-" gorm:"size:64"`
+	CodeVerifier   string     `json:"-" gorm:"size:128"`
+	RequestID      string     `json:"-" gorm:"size:64"`
+	ExpiresAt      time.Time  `json:"expires_at" gorm:"not null;index"`
+	Used           bool       `json:"used"`
+}
+
+func (s *SSOLoginState) TableName() string {
+	return "sso_login_states"
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Package types served by the package registry
+const (
+	PackageTypeNpm     = "npm"
+	PackageTypeMaven   = "maven"
+	PackageTypeGeneric = "generic"
+)
+
+// Package is a package published to the registry of an organization.
+// Maven packages are named groupId:artifactId; npm names keep their scope.
+type Package struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrganizationID uuid.UUID  `json:"organization_id" gorm:"type:uuid;not null;uniqueIndex:idx_packages_org_type_name"`
+	Type           string     `json:"type" gorm:"not null;size:20;uniqueIndex:idx_packages_org_type_name"`
+	Name           string     `json:"name" gorm:"not null;size:255;uniqueIndex:idx_packages_org_type_name"`
+	Visibility     Visibility `json:"visibility" gorm:"type:varchar(50);not null;default:'private'"`
+	// DistTags maps npm dist-tags such as latest to versions, as JSON
+	DistTags      string `json:"-" gorm:"type:text"`
+	DownloadCount int64  `json:"download_count" gorm:"not null;default:0"`
+
+	// Relationships
+	Versions []PackageVersion `json:"versions,omitempty" gorm:"foreignKey:PackageID"`
+}
+
+func (p *Package) TableName() string {
+	return "packages"
+}
+
+// PackageVersion is a published version of a package. Versions are
+// immutable once published, apart from files added to them.
+type PackageVersion struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	PackageID   uuid.UUID  `json:"package_id" gorm:"type:uuid;not null;uniqueIndex:idx_package_versions_package_version"`
+	Version     string     `json:"version" gorm:"not null;size:128;uniqueIndex:idx_package_versions_package_version"`
+	PublisherID *uuid.UUID `json:"publisher_id,omitempty" gorm:"type:uuid"`
+	// Metadata is the npm manifest of the version as published, as JSON
+	Metadata         string     `json:"-" gorm:"type:text"`
+	DownloadCount    int64      `json:"download_count" gorm:"not null;default:0"`
+	LastDownloadedAt *time.Time `json:"last_downloaded_at,omitempty"`
+
+	// Relationships
+	Files []PackageFile `json:"files,omitempty" gorm:"foreignKey:VersionID"`
+}
+
+func (v *PackageVersion) TableName() string {
+	return "package_versions"
+}
+
+// PackageFile is a file of a package version. Content is stored once per
+// SHA-256 in the package storage backend.
+type PackageFile struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	VersionID uuid.UUID `json:"version_id" gorm:"type:uuid;not null;uniqueIndex:idx_package_files_version_name"`
+	Name      string    `json:"name" gorm:"not null;size:255;uniqueIndex:idx_package_files_version_name"`
+	Size      int64     `json:"size" gorm:"not null"`
+	SHA256    string    `json:"sha256" gorm:"not null;size:64;index"`
+	// SHA1 and MD5 are served to Maven clients, which check them
+	SHA1 string `json:"sha1" gorm:"size:40"`
+	MD5  string `json:"md5" gorm:"size:32"`
+}
+
+func (f *PackageFile) TableName() string {
+	return "package_files"
+}
+
+// PackageRetentionPolicy decides which package versions of an organization
+// are deleted automatically. The newest KeepVersions versions of each
+// package are always kept; older ones are deleted once published more than
+// MaxAgeDays ago, right away when it is 0, unless downloaded within the
+// last KeepDownloadedDays.
+type PackageRetentionPolicy struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrganizationID     uuid.UUID `json:"organization_id" gorm:"type:uuid;not null;uniqueIndex"`
+	Enabled            bool      `json:"enabled" gorm:"not null;default:false"`
+	KeepVersions       int       `json:"keep_versions" gorm:"not null;default:10"`
+	MaxAgeDays         int       `json:"max_age_days" gorm:"not null"`
+	KeepDownloadedDays int       `json:"keep_downloaded_days" gorm:"not null;default:0"`
+}
+
+func (p *PackageRetentionPolicy) TableName() string {
+	return "package_retention_policies"
+}
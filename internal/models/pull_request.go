@@ -15,6 +15,13 @@ const (
 	PullRequestStateMerged PullRequestState = "merged"
 )
 
+// Mergeable states computed in the background after a pull request changes
+const (
+	MergeableStateUnknown     = "unknown"
+	MergeableStateClean       = "clean"
+	MergeableStateConflicting = "conflicting"
+)
+
 type PullRequest struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -38,6 +45,18 @@ type PullRequest struct {
 	MergedByID       *uuid.UUID       `json:"merged_by_id" gorm:"type:uuid;index"`
 	ClosedAt         *time.Time       `json:"closed_at"`
 
+	// Mergeability and diff stats are filled in asynchronously; Mergeable is
+	// nil while MergeableState is still unknown
+	Mergeable      *bool  `json:"mergeable"`
+	MergeableState string `json:"mergeable_state" gorm:"size:20;not null;default:'unknown'"`
+	Additions      int    `json:"additions" gorm:"not null;default:0"`
+	Deletions      int    `json:"deletions" gorm:"not null;default:0"`
+	ChangedFiles   int    `json:"changed_files" gorm:"not null;default:0"`
+
+	// UnresolvedReviewThreads counts review comment threads nobody has
+	// resolved yet; it is filled in when pull requests are fetched
+	UnresolvedReviewThreads int64 `json:"unresolved_review_threads" gorm:"-"`
+
 	// Relationships
 	Repository     Repository  `json:"repository,omitempty" gorm:"foreignKey:RepositoryID"`
 	Issue          *Issue      `json:"issue,omitempty" gorm:"foreignKey:IssueID"`
@@ -46,6 +65,12 @@ type PullRequest struct {
 	BaseRepository Repository  `json:"base_repository,omitempty" gorm:"foreignKey:BaseRepositoryID"`
 	MergedBy       *User       `json:"merged_by,omitempty" gorm:"foreignKey:MergedByID"`
 	Comments       []Comment   `json:"comments,omitempty" gorm:"foreignKey:PullRequestID"`
+	// ChangeTicket is set when the repository requires change tickets
+	ChangeTicket *PullRequestChangeTicket `json:"change_ticket,omitempty" gorm:"foreignKey:PullRequestID"`
+
+	Labels             []Label `json:"labels,omitempty" gorm:"many2many:pull_request_labels"`
+	Assignees          []User  `json:"assignees,omitempty" gorm:"many2many:pull_request_assignees"`
+	RequestedReviewers []User  `json:"requested_reviewers,omitempty" gorm:"many2many:pull_request_review_requests"`
 }
 
 func (pr *PullRequest) TableName() string {
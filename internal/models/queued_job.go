@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QueuedJobState is where a queued job is in its lifecycle
+type QueuedJobState string
+
+const (
+	JobPending   QueuedJobState = "pending"
+	JobRunning   QueuedJobState = "running"
+	JobSucceeded QueuedJobState = "succeeded"
+	// JobFailed jobs used up their attempts or failed permanently
+	JobFailed    QueuedJobState = "failed"
+	JobCancelled QueuedJobState = "cancelled"
+)
+
+// QueuedJob is a unit of asynchronous work in the job queue. Pending
+// jobs run once RunAt has passed; a running job whose lock expired is
+// assumed lost with its worker and is picked up again.
+type QueuedJob struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Kind    string         `json:"kind" gorm:"not null;size:100;index"`
+	Payload string         `json:"payload" gorm:"type:text"`
+	State   QueuedJobState `json:"state" gorm:"type:varchar(20);not null;index:idx_queued_jobs_state_run_at"`
+	RunAt   time.Time      `json:"run_at" gorm:"not null;index:idx_queued_jobs_state_run_at"`
+	// DedupeKey coalesces enqueues of the same work while a job for it is pending
+	DedupeKey   string `json:"dedupe_key,omitempty" gorm:"size:255;index"`
+	Attempts    int    `json:"attempts" gorm:"not null;default:0"`
+	MaxAttempts int    `json:"max_attempts" gorm:"not null"`
+	LastError   string `json:"last_error,omitempty" gorm:"type:text"`
+	// LockedBy is the worker running the job, LockedUntil when its claim lapses
+	LockedBy    string     `json:"locked_by,omitempty" gorm:"size:255"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" gorm:"index"`
+}
+
+func (j *QueuedJob) TableName() string {
+	return "queued_jobs"
+}
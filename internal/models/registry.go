@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ContainerImage is an image pushed to the OCI registry. Its name starts
+// with the owner and name of the repository it belongs to, which decides
+// who may pull and push it.
+type ContainerImage struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;index"`
+	// Name is the full image name, such as acme/api or acme/api/worker
+	Name string `json:"name" gorm:"not null;size:255;uniqueIndex"`
+
+	// Relationships
+	Tags []ContainerTag `json:"tags,omitempty" gorm:"foreignKey:ImageID"`
+}
+
+func (i *ContainerImage) TableName() string {
+	return "container_images"
+}
+
+// ContainerBlob links a layer or config blob to an image it was pushed to.
+// Blob content is stored once per digest in the registry storage backend;
+// these rows control which images may pull it.
+type ContainerBlob struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ImageID uuid.UUID `json:"image_id" gorm:"type:uuid;not null;uniqueIndex:idx_container_blobs_image_digest"`
+	// Digest is the algorithm and hex hash of the content, such as sha256:ab12...
+	Digest string `json:"digest" gorm:"not null;size:80;uniqueIndex:idx_container_blobs_image_digest;index"`
+	Size   int64  `json:"size" gorm:"not null"`
+}
+
+func (b *ContainerBlob) TableName() string {
+	return "container_blobs"
+}
+
+// ContainerManifest is an image manifest or index pushed to an image. Its
+// content is stored by digest alongside the blobs.
+type ContainerManifest struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	ImageID   uuid.UUID `json:"image_id" gorm:"type:uuid;not null;uniqueIndex:idx_container_manifests_image_digest"`
+	Digest    string    `json:"digest" gorm:"not null;size:80;uniqueIndex:idx_container_manifests_image_digest;index"`
+	MediaType string    `json:"media_type" gorm:"not null;size:255"`
+	Size      int64     `json:"size" gorm:"not null"`
+
+	// Relationships
+	References []ContainerManifestReference `json:"-" gorm:"foreignKey:ManifestID"`
+}
+
+func (m *ContainerManifest) TableName() string {
+	return "container_manifests"
+}
+
+// ContainerManifestReference records a blob or child manifest a manifest
+// refers to, keeping it from being garbage collected
+type ContainerManifestReference struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	ManifestID uuid.UUID `json:"manifest_id" gorm:"type:uuid;not null;index"`
+	Digest     string    `json:"digest" gorm:"not null;size:80;index"`
+}
+
+func (r *ContainerManifestReference) TableName() string {
+	return "container_manifest_references"
+}
+
+// ContainerTag points a tag of an image at a manifest
+type ContainerTag struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ImageID    uuid.UUID `json:"image_id" gorm:"type:uuid;not null;uniqueIndex:idx_container_tags_image_name"`
+	Name       string    `json:"name" gorm:"not null;size:128;uniqueIndex:idx_container_tags_image_name"`
+	ManifestID uuid.UUID `json:"manifest_id" gorm:"type:uuid;not null;index"`
+
+	// Relationships
+	Manifest *ContainerManifest `json:"manifest,omitempty" gorm:"foreignKey:ManifestID"`
+}
+
+func (t *ContainerTag) TableName() string {
+	return "container_tags"
+}
+
+// ContainerUpload is a blob upload in progress. Chunks are spooled to a
+// local file named after the upload until the client completes it.
+type ContainerUpload struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	ImageID uuid.UUID `json:"image_id" gorm:"type:uuid;not null;index"`
+	// Size is the number of bytes received so far
+	Size int64 `json:"size" gorm:"not null;default:0"`
+}
+
+func (u *ContainerUpload) TableName() string {
+	return "container_uploads"
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Release publishes a tag of a repository with notes and downloadable
+// assets. Drafts are only visible to users who can push to the repository.
+type Release struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;index:idx_releases_repo_tag"`
+	TagName      string    `json:"tag_name" gorm:"not null;size:255;index:idx_releases_repo_tag"`
+	// TargetCommitish is the branch or commit the tag was created from
+	TargetCommitish string     `json:"target_commitish" gorm:"size:255"`
+	Name            string     `json:"name" gorm:"size:255"`
+	Body            string     `json:"body" gorm:"type:text"`
+	Draft           bool       `json:"draft" gorm:"default:false"`
+	Prerelease      bool       `json:"prerelease" gorm:"default:false"`
+	AuthorID        *uuid.UUID `json:"author_id" gorm:"type:uuid;index"`
+	PublishedAt     *time.Time `json:"published_at"`
+
+	// Relationships
+	Author *User          `json:"author,omitempty" gorm:"foreignKey:AuthorID"`
+	Assets []ReleaseAsset `json:"assets" gorm:"foreignKey:ReleaseID"`
+}
+
+func (r *Release) TableName() string {
+	return "releases"
+}
+
+// ReleaseAsset is a binary file attached to a release. The content lives in
+// the release storage backend under StoragePath.
+type ReleaseAsset struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	ReleaseID     uuid.UUID  `json:"release_id" gorm:"type:uuid;not null;index"`
+	Name          string     `json:"name" gorm:"not null;size:255"`
+	Label         string     `json:"label" gorm:"size:255"`
+	ContentType   string     `json:"content_type" gorm:"size:255"`
+	Size          int64      `json:"size" gorm:"not null"`
+	DownloadCount int64      `json:"download_count" gorm:"not null;default:0"`
+	StoragePath   string     `json:"-" gorm:"not null;size:512"`
+	UploaderID    *uuid.UUID `json:"uploader_id" gorm:"type:uuid;index"`
+}
+
+func (a *ReleaseAsset) TableName() string {
+	return "release_assets"
+}
@@ -56,12 +56,34 @@ type Repository struct {
 	AllowSquashMerge    bool       `json:"allow_squash_merge" gorm:"default:true"`
 	AllowRebaseMerge    bool       `json:"allow_rebase_merge" gorm:"default:true"`
 	DeleteBranchOnMerge bool       `json:"delete_branch_on_merge" gorm:"default:false"`
+	AutoCloseIssues     bool       `json:"auto_close_issues" gorm:"default:true"`
 	SizeKB              int64      `json:"size_kb" gorm:"default:0"`
 	StarsCount          int        `json:"stars_count" gorm:"default:0"`
 	ForksCount          int        `json:"forks_count" gorm:"default:0"`
 	WatchersCount       int        `json:"watchers_count" gorm:"default:0"`
 	PushedAt            *time.Time `json:"pushed_at"`
 
+	// Merge commit templates and the pull request title convention; empty
+	// templates fall back to the defaults and an empty pattern allows any title
+	MergeCommitTitleTemplate    string `json:"merge_commit_title_template" gorm:"size:255"`
+	MergeCommitMessageTemplate  string `json:"merge_commit_message_template" gorm:"type:text"`
+	SquashCommitTitleTemplate   string `json:"squash_commit_title_template" gorm:"size:255"`
+	SquashCommitMessageTemplate string `json:"squash_commit_message_template" gorm:"type:text"`
+	PullRequestTitlePattern     string `json:"pull_request_title_pattern" gorm:"size:500"`
+
+	// Branch cleanup: AutoDeleteMergedBranches lets the scheduled cleanup
+	// delete merged branches, and branches without commits for
+	// StaleBranchDays are suggested for deletion
+	AutoDeleteMergedBranches bool `json:"auto_delete_merged_branches" gorm:"default:false"`
+	StaleBranchDays          int  `json:"stale_branch_days" gorm:"default:90"`
+
+	// LFSQuotaMB overrides the instance wide Git LFS storage quota; nil uses
+	// the default and 0 removes the limit
+	LFSQuotaMB *int64 `json:"lfs_quota_mb"`
+
+	// Version is bumped by every settings update and served as the ETag
+	Version int64 `json:"version" gorm:"not null;default:1"`
+
 	// Owner relationship (polymorphic)
 	Owner *OwnerEntity `json:"owner,omitempty" gorm:"-"`
 
@@ -146,6 +168,25 @@ func (b *Branch) TableName() string {
 	return "branches"
 }
 
+// BranchRename records a branch rename so requests for the old name can be
+// pointed at the new one
+type BranchRename struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	RepositoryID uuid.UUID  `json:"repository_id" gorm:"type:uuid;not null;index:idx_branch_renames_old_name"`
+	OldName      string     `json:"old_name" gorm:"not null;size:255;index:idx_branch_renames_old_name"`
+	NewName      string     `json:"new_name" gorm:"not null;size:255"`
+	RenamedByID  *uuid.UUID `json:"renamed_by_id,omitempty" gorm:"type:uuid"`
+	// Open pull requests and protection rules moved over to the new name
+	RetargetedPullRequests int `json:"retargeted_pull_requests"`
+	UpdatedProtectionRules int `json:"updated_protection_rules"`
+}
+
+func (r *BranchRename) TableName() string {
+	return "branch_renames"
+}
+
 type BranchProtectionRule struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -158,6 +199,11 @@ type BranchProtectionRule struct {
 	EnforceAdmins              bool      `json:"enforce_admins" gorm:"default:false"`
 	RequiredPullRequestReviews string    `json:"required_pull_request_reviews" gorm:"type:json"`
 	Restrictions               string    `json:"restrictions" gorm:"type:json"`
+	// RequireConversationResolution blocks merging while review threads are unresolved
+	RequireConversationResolution bool `json:"require_conversation_resolution" gorm:"default:false"`
+	// RequireSignedCommits blocks merging pull requests with commits whose
+	// signatures are not verified
+	RequireSignedCommits bool `json:"require_signed_commits" gorm:"default:false"`
 
 	// Relationships
 	Repository Repository `json:"repository,omitempty" gorm:"foreignKey:RepositoryID"`
@@ -166,3 +212,70 @@ type BranchProtectionRule struct {
 func (bpr *BranchProtectionRule) TableName() string {
 	return "branch_protection_rules"
 }
+
+// PathProtectionRule requires additional review before a pull request that
+// touches matching paths can be merged
+type PathProtectionRule struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;index"`
+	// Pattern is a path glob; "**" matches any number of directories, e.g. /terraform/**
+	Pattern string `json:"pattern" gorm:"not null;size:500"`
+	// BranchPattern limits the rule to pull requests into matching base branches
+	BranchPattern     string   `json:"branch_pattern" gorm:"not null;size:255;default:'*'"`
+	RequiredApprovals int      `json:"required_approvals" gorm:"not null;default:2"`
+	RequiredTeams     []string `json:"required_teams" gorm:"serializer:json;type:text"`
+	Description       string   `json:"description" gorm:"type:text"`
+
+	// Relationships
+	Repository Repository `json:"-" gorm:"foreignKey:RepositoryID"`
+}
+
+func (ppr *PathProtectionRule) TableName() string {
+	return "path_protection_rules"
+}
+
+// RepositoryArchiveImportStatus is how far the import of an uploaded archive has got
+type RepositoryArchiveImportStatus string
+
+const (
+	ArchiveImportQueued     RepositoryArchiveImportStatus = "queued"
+	ArchiveImportExtracting RepositoryArchiveImportStatus = "extracting"
+	ArchiveImportCommitting RepositoryArchiveImportStatus = "committing"
+	ArchiveImportCompleted  RepositoryArchiveImportStatus = "completed"
+	ArchiveImportFailed     RepositoryArchiveImportStatus = "failed"
+)
+
+// RepositoryArchiveImport tracks a repository being created from an uploaded
+// tar or zip archive of an existing project. The repository exists once the
+// import has completed.
+type RepositoryArchiveImport struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID          uuid.UUID                     `json:"user_id" gorm:"type:uuid;not null;index"`
+	Name            string                        `json:"name" gorm:"not null;size:255"`
+	Format          string                        `json:"format" gorm:"not null;size:10"`
+	ArchiveSize     int64                         `json:"archive_size"`
+	PreserveHistory bool                          `json:"preserve_history"`
+	Status          RepositoryArchiveImportStatus `json:"status" gorm:"type:varchar(20);not null;default:'queued';index"`
+	// FilesTotal is 0 until the number of files in the archive is known
+	FilesTotal     int        `json:"files_total"`
+	FilesProcessed int        `json:"files_processed"`
+	RepositoryID   *uuid.UUID `json:"repository_id,omitempty" gorm:"type:uuid"`
+	Error          string     `json:"error,omitempty" gorm:"type:text"`
+	CompletedAt    *time.Time `json:"completed_at,omitempty"`
+	// Request is the repository settings to create, as JSON
+	Request string `json:"-" gorm:"type:text"`
+
+	// Relationships
+	Repository *Repository `json:"repository,omitempty" gorm:"foreignKey:RepositoryID"`
+}
+
+func (r *RepositoryArchiveImport) TableName() string {
+	return "repository_archive_imports"
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RepositoryBaseline is the settings every repository of an organization is
+// expected to have. Repositories are compared against it to report drift;
+// settings left unset in the baseline are not checked.
+type RepositoryBaseline struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	OrganizationID uuid.UUID `json:"organization_id" gorm:"type:uuid;not null;uniqueIndex"`
+
+	Settings    BaselineSettings     `json:"settings" gorm:"serializer:json;type:text"`
+	Policies    BaselinePolicies     `json:"policies" gorm:"serializer:json;type:text"`
+	Protections []BaselineProtection `json:"protections" gorm:"serializer:json;type:text"`
+	Webhooks    []BaselineWebhook    `json:"webhooks" gorm:"serializer:json;type:text"`
+	Labels      []BaselineLabel      `json:"labels" gorm:"serializer:json;type:text"`
+
+	UpdatedByID *uuid.UUID `json:"updated_by_id,omitempty" gorm:"type:uuid"`
+}
+
+func (b *RepositoryBaseline) TableName() string {
+	return "repository_baselines"
+}
+
+// BaselineSettings are the expected features and merge methods of
+// repositories; nil fields are not checked
+type BaselineSettings struct {
+	DefaultBranch       *string `json:"default_branch,omitempty"`
+	HasWiki             *bool   `json:"has_wiki,omitempty"`
+	HasDownloads        *bool   `json:"has_downloads,omitempty"`
+	AutoCloseIssues     *bool   `json:"auto_close_issues,omitempty"`
+	AllowMergeCommit    *bool   `json:"allow_merge_commit,omitempty"`
+	AllowSquashMerge    *bool   `json:"allow_squash_merge,omitempty"`
+	AllowRebaseMerge    *bool   `json:"allow_rebase_merge,omitempty"`
+	DeleteBranchOnMerge *bool   `json:"delete_branch_on_merge,omitempty"`
+}
+
+// BaselinePolicies are the expected pull request and branch policies of
+// repositories; nil fields are not checked
+type BaselinePolicies struct {
+	PullRequestTitlePattern  *string `json:"pull_request_title_pattern,omitempty"`
+	AutoDeleteMergedBranches *bool   `json:"auto_delete_merged_branches,omitempty"`
+	StaleBranchDays          *int    `json:"stale_branch_days,omitempty"`
+}
+
+// BaselineProtection is a branch protection rule repositories must have.
+// Repositories may protect branches more strictly than the baseline.
+type BaselineProtection struct {
+	Pattern                       string   `json:"pattern"`
+	RequiredApprovingReviewCount  int      `json:"required_approving_review_count"`
+	RequiredStatusChecks          []string `json:"required_status_checks,omitempty"`
+	EnforceAdmins                 bool     `json:"enforce_admins"`
+	RequireConversationResolution bool     `json:"require_conversation_resolution"`
+	RequireSignedCommits          bool     `json:"require_signed_commits"`
+}
+
+// BaselineWebhook is a webhook repositories must have, matched by URL
+type BaselineWebhook struct {
+	URL         string   `json:"url"`
+	Events      []string `json:"events"`
+	ContentType string   `json:"content_type,omitempty"`
+}
+
+// BaselineLabel is an issue label repositories must have, matched by name
+type BaselineLabel struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description,omitempty"`
+}
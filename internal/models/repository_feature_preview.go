@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RepositoryFeaturePreview records a feature preview switched on for a
+// repository; switching it off deletes the row
+type RepositoryFeaturePreview struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	RepositoryID uuid.UUID  `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex:idx_repository_feature_preview"`
+	Feature      string     `json:"feature" gorm:"size:100;not null;uniqueIndex:idx_repository_feature_preview;index"`
+	EnabledByID  *uuid.UUID `json:"enabled_by_id,omitempty" gorm:"type:uuid"`
+}
+
+func (p *RepositoryFeaturePreview) TableName() string {
+	return "repository_feature_previews"
+}
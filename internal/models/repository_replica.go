@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RepositoryReplica tracks the disaster recovery replica of a repository.
+// Snapshot is the last snapshot appended to its replication log, which the
+// next replication builds on. The replica running a replication holds it
+// until LockedUntil, so a repository is never replicated twice at once.
+type RepositoryReplica struct {
+	RepositoryID     uuid.UUID  `json:"repository_id" gorm:"type:uuid;primaryKey"`
+	Sequence         int64      `json:"sequence"`
+	Snapshot         string     `json:"-" gorm:"type:text"`
+	LastReplicatedAt *time.Time `json:"last_replicated_at"`
+	LastError        string     `json:"last_error,omitempty" gorm:"type:text"`
+	LockedBy         string     `json:"-" gorm:"size:255"`
+	LockedUntil      *time.Time `json:"-"`
+
+	// The last verification restored VerifiedSequence
+	LastVerifiedAt   *time.Time `json:"last_verified_at"`
+	VerifiedSequence int64      `json:"verified_sequence"`
+	VerifyError      string     `json:"verify_error,omitempty" gorm:"type:text"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (r *RepositoryReplica) TableName() string {
+	return "repository_replicas"
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Repository schedule run statuses
+const (
+	ScheduleRunTriggered = "triggered"
+	ScheduleRunFailed    = "failed"
+)
+
+// RepositorySchedule is a cron expression that emits a schedule event for
+// the repository each time it fires
+type RepositorySchedule struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;index"`
+	Name         string    `json:"name" gorm:"not null;size:255"`
+	Cron         string    `json:"cron" gorm:"not null;size:255"`
+	// Timezone is an IANA location name the cron expression is evaluated in
+	Timezone string `json:"timezone" gorm:"not null;size:100;default:'UTC'"`
+	// Ref is passed along with the event; empty means the default branch
+	Ref         string     `json:"ref" gorm:"size:255"`
+	Active      bool       `json:"active" gorm:"default:true"`
+	CreatedByID *uuid.UUID `json:"created_by_id,omitempty" gorm:"type:uuid"`
+
+	// NextRunAt is cleared while the schedule is inactive
+	NextRunAt  *time.Time `json:"next_run_at,omitempty" gorm:"index"`
+	LastRunAt  *time.Time `json:"last_run_at,omitempty"`
+	LastStatus string     `json:"last_status,omitempty" gorm:"size:20"`
+}
+
+func (s *RepositorySchedule) TableName() string {
+	return "repository_schedules"
+}
+
+// RepositoryScheduleRun records one firing of a schedule
+type RepositoryScheduleRun struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	ScheduleID   uuid.UUID `json:"schedule_id" gorm:"type:uuid;not null;index"`
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;index"`
+	ScheduledFor time.Time `json:"scheduled_for"`
+	Status       string    `json:"status" gorm:"not null;size:20"`
+	Error        string    `json:"error,omitempty" gorm:"type:text"`
+	// Missed counts activations skipped because the scheduler was not running
+	Missed int `json:"missed" gorm:"default:0"`
+}
+
+func (r *RepositoryScheduleRun) TableName() string {
+	return "repository_schedule_runs"
+}
@@ -27,6 +27,23 @@ func (rl *RepositoryLanguage) TableName() string {
 	return "repository_languages"
 }
 
+// RepositoryLanguageSnapshot records a repository's language breakdown as of
+// a day, so language usage can be charted over time. A later analysis on the
+// same day replaces that day's rows.
+type RepositoryLanguageSnapshot struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex:idx_repository_language_snapshot,priority:1"`
+	Date         time.Time `json:"date" gorm:"type:date;not null;index;uniqueIndex:idx_repository_language_snapshot,priority:2"`
+	Language     string    `json:"language" gorm:"not null;size:100;uniqueIndex:idx_repository_language_snapshot,priority:3"`
+	Bytes        int64     `json:"bytes" gorm:"not null;default:0"`
+}
+
+func (rs *RepositoryLanguageSnapshot) TableName() string {
+	return "repository_language_snapshots"
+}
+
 // RepositoryStatistics represents comprehensive statistics for a repository
 type RepositoryStatistics struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
@@ -53,6 +70,33 @@ func (rs *RepositoryStatistics) TableName() string {
 	return "repository_statistics"
 }
 
+// Repository statistics computed in the background and served from cache
+const (
+	StatsKindCodeFrequency = "code_frequency"
+	StatsKindParticipation = "participation"
+	// StatsKindInsightsSummary is the dashboard card summary, refreshed on a
+	// much shorter schedule than the commit statistics
+	StatsKindInsightsSummary = "insights_summary"
+	// StatsKindAuthorFrequency is the weekly activity of each commit author
+	StatsKindAuthorFrequency = "author_frequency"
+)
+
+// RepositoryStatsCache holds a precomputed statistics payload for a repository
+type RepositoryStatsCache struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex:idx_repo_stats_kind"`
+	Kind         string    `json:"kind" gorm:"not null;size:50;uniqueIndex:idx_repo_stats_kind"`
+	Data         string    `json:"data" gorm:"type:text"` // JSON encoded payload
+	ComputedAt   time.Time `json:"computed_at" gorm:"not null"`
+}
+
+func (rsc *RepositoryStatsCache) TableName() string {
+	return "repository_stats_caches"
+}
+
 // RepositoryTemplate represents a repository template
 type RepositoryTemplate struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
@@ -99,18 +143,32 @@ func (gh *GitHook) TableName() string {
 	return "git_hooks"
 }
 
-// RepositoryImport represents an import operation for a repository
+// Statuses of a RepositoryImport
+const (
+	RepositoryImportPending    = "pending"
+	RepositoryImportInProgress = "in_progress"
+	RepositoryImportCompleted  = "completed"
+	RepositoryImportFailed     = "failed"
+)
+
+// RepositoryImport tracks a repository being imported from GitHub or GitLab
+// along with its labels, milestones, issues, pull requests and releases.
+// RepositoryID is set once the git history has been copied; Stage is the
+// step in progress.
 type RepositoryImport struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
-	RepositoryID     uuid.UUID  `json:"repository_id" gorm:"type:uuid;not null;index"`
-	SourceType       string     `json:"source_type" gorm:"not null;size:50"` // github, gitlab, bitbucket
+	UserID           uuid.UUID  `json:"user_id" gorm:"type:uuid;index"`
+	Name             string     `json:"name" gorm:"size:255"`
+	RepositoryID     *uuid.UUID `json:"repository_id,omitempty" gorm:"type:uuid;index"`
+	SourceType       string     `json:"source_type" gorm:"not null;size:50"` // github, gitlab
 	SourceURL        string     `json:"source_url" gorm:"not null;size:500"`
 	Status           string     `json:"status" gorm:"not null;size:50;default:'pending'"` // pending, in_progress, completed, failed
-	Progress         int        `json:"progress" gorm:"default:0"`                        // 0-100
+	Stage            string     `json:"stage,omitempty" gorm:"size:50"`
+	Progress         int        `json:"progress" gorm:"default:0"` // 0-100
 	ErrorMessage     string     `json:"error_message" gorm:"type:text"`
 	ImportedAt       *time.Time `json:"imported_at"`
 	TotalCommits     int        `json:"total_commits" gorm:"default:0"`
@@ -118,8 +176,14 @@ type RepositoryImport struct {
 	TotalBranches    int        `json:"total_branches" gorm:"default:0"`
 	ImportedBranches int        `json:"imported_branches" gorm:"default:0"`
 
+	LabelsImported       int `json:"labels_imported" gorm:"default:0"`
+	MilestonesImported   int `json:"milestones_imported" gorm:"default:0"`
+	IssuesImported       int `json:"issues_imported" gorm:"default:0"`
+	PullRequestsImported int `json:"pull_requests_imported" gorm:"default:0"`
+	ReleasesImported     int `json:"releases_imported" gorm:"default:0"`
+
 	// Relationships
-	Repository Repository `json:"repository,omitempty" gorm:"foreignKey:RepositoryID"`
+	Repository *Repository `json:"repository,omitempty" gorm:"foreignKey:RepositoryID"`
 }
 
 func (ri *RepositoryImport) TableName() string {
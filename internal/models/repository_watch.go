@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of repository activity a custom watch can select
+const (
+	WatchEventIssues       = "issues"
+	WatchEventPullRequests = "pull_requests"
+	WatchEventCI           = "ci"
+)
+
+// RepositoryWatch records that a user watches a repository, or explicitly
+// ignores it
+type RepositoryWatch struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID       uuid.UUID `json:"user_id" gorm:"type:uuid;not null;uniqueIndex:idx_repository_watches_user_repo"`
+	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex:idx_repository_watches_user_repo;index"`
+	Ignored      bool      `json:"ignored" gorm:"default:false"`
+	Reason       string    `json:"reason" gorm:"size:50"`
+	// Events limits a custom watch to a comma separated list of watch
+	// events; empty watches issues and pull requests
+	Events string `json:"events" gorm:"size:100"`
+
+	// Relationships
+	Repository Repository `json:"repository,omitempty" gorm:"foreignKey:RepositoryID"`
+}
+
+func (w *RepositoryWatch) TableName() string {
+	return "repository_watches"
+}
+
+// Watches reports whether the watch covers an event
+func (w *RepositoryWatch) Watches(event string) bool {
+	if w.Ignored {
+		return false
+	}
+	if w.Events == "" {
+		return event == WatchEventIssues || event == WatchEventPullRequests
+	}
+	for _, e := range strings.Split(w.Events, ",") {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
@@ -30,6 +30,12 @@ type Review struct {
 	Body          string      `json:"body" gorm:"type:text"`
 	SubmittedAt   *time.Time  `json:"submitted_at"`
 
+	// Dismissal of an approval or change request, which then no longer
+	// counts towards branch protection
+	DismissedByID    *uuid.UUID `json:"dismissed_by_id,omitempty" gorm:"type:uuid"`
+	DismissedAt      *time.Time `json:"dismissed_at,omitempty"`
+	DismissalMessage string     `json:"dismissal_message,omitempty" gorm:"type:text"`
+
 	// Relationships
 	PullRequest    PullRequest     `json:"pull_request,omitempty" gorm:"foreignKey:PullRequestID"`
 	User           *User           `json:"user,omitempty" gorm:"foreignKey:UserID"`
@@ -61,10 +67,25 @@ type ReviewComment struct {
 	Body             string     `json:"body" gorm:"not null;type:text"`
 	InReplyToID      *uuid.UUID `json:"in_reply_to_id" gorm:"type:uuid;index"`
 
+	// Suggestion state. OriginalLines holds the lines a suggestion replaces as
+	// they were at CommitSHA, so applying it can detect that they changed
+	OriginalLines        string     `json:"-" gorm:"type:text"`
+	SuggestionAppliedSHA string     `json:"suggestion_applied_sha,omitempty" gorm:"size:40"`
+	SuggestionAppliedAt  *time.Time `json:"suggestion_applied_at,omitempty"`
+	// Suggestion is the replacement text of a suggestion block in Body
+	Suggestion *string `json:"suggestion,omitempty" gorm:"-"`
+
+	// Resolution of the conversation; only set on the first comment of a
+	// thread, replies have InReplyToID set instead
+	Resolved     bool       `json:"resolved" gorm:"default:false;index"`
+	ResolvedByID *uuid.UUID `json:"resolved_by_id,omitempty" gorm:"type:uuid"`
+	ResolvedAt   *time.Time `json:"resolved_at,omitempty"`
+
 	// Relationships
 	Review      *Review         `json:"review,omitempty" gorm:"foreignKey:ReviewID"`
 	PullRequest PullRequest     `json:"pull_request,omitempty" gorm:"foreignKey:PullRequestID"`
 	User        *User           `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	ResolvedBy  *User           `json:"resolved_by,omitempty" gorm:"foreignKey:ResolvedByID"`
 	InReplyTo   *ReviewComment  `json:"in_reply_to,omitempty" gorm:"foreignKey:InReplyToID"`
 	Replies     []ReviewComment `json:"replies,omitempty" gorm:"foreignKey:InReplyToID"`
 }
@@ -73,6 +94,32 @@ func (rc *ReviewComment) TableName() string {
 	return "review_comments"
 }
 
+// ReviewThreadAction is a change to the resolution of a review thread
+type ReviewThreadAction string
+
+const (
+	ReviewThreadResolved   ReviewThreadAction = "resolved"
+	ReviewThreadUnresolved ReviewThreadAction = "unresolved"
+)
+
+// ReviewThreadEvent records who resolved or reopened a review thread
+type ReviewThreadEvent struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// CommentID is the first comment of the thread
+	CommentID     uuid.UUID          `json:"comment_id" gorm:"type:uuid;not null;index"`
+	PullRequestID uuid.UUID          `json:"pull_request_id" gorm:"type:uuid;not null;index"`
+	ActorID       uuid.UUID          `json:"actor_id" gorm:"type:uuid;not null"`
+	Action        ReviewThreadAction `json:"action" gorm:"type:varchar(20);not null"`
+
+	Actor *User `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+}
+
+func (e *ReviewThreadEvent) TableName() string {
+	return "review_thread_events"
+}
+
 type PullRequestFile struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -87,6 +134,15 @@ type PullRequestFile struct {
 	Changes          int       `json:"changes" gorm:"default:0"`
 	Patch            string    `json:"patch" gorm:"type:text"`
 	PreviousFilename *string   `json:"previous_filename" gorm:"size:4096"`
+	// PatchTruncated is set when the patch was cut short or left out
+	// because the file or pull request diff is too large
+	PatchTruncated bool `json:"patch_truncated" gorm:"default:false"`
+
+	// The diff is cached for the head and base commits it was computed
+	// between; Position keeps the order files were listed in
+	HeadSHA  string `json:"-" gorm:"size:40;index"`
+	BaseSHA  string `json:"-" gorm:"size:40"`
+	Position int    `json:"-" gorm:"default:0"`
 
 	// Relationships
 	PullRequest PullRequest `json:"pull_request,omitempty" gorm:"foreignKey:PullRequestID"`
@@ -0,0 +1,164 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RunnerScope is the level a self-hosted runner is registered at
+type RunnerScope string
+
+const (
+	RunnerScopeRepository   RunnerScope = "repository"
+	RunnerScopeOrganization RunnerScope = "organization"
+	RunnerScopeInstance     RunnerScope = "instance"
+)
+
+// RunnerStatus is the health of a runner derived from its heartbeats
+type RunnerStatus string
+
+const (
+	RunnerStatusOnline  RunnerStatus = "online"
+	RunnerStatusOffline RunnerStatus = "offline"
+	RunnerStatusBusy    RunnerStatus = "busy"
+)
+
+// RunnerGroupVisibility controls which organization repositories may use a group
+type RunnerGroupVisibility string
+
+const (
+	RunnerGroupVisibilityAll      RunnerGroupVisibility = "all"
+	RunnerGroupVisibilitySelected RunnerGroupVisibility = "selected"
+)
+
+// RunnerGroup organizes organization runners and restricts the repositories they serve
+type RunnerGroup struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	OrganizationID          uuid.UUID             `json:"organization_id" gorm:"type:uuid;not null;index"`
+	Name                    string                `json:"name" gorm:"not null;size:255"`
+	Visibility              RunnerGroupVisibility `json:"visibility" gorm:"type:varchar(20);not null;default:'all'"`
+	AllowPublicRepositories bool                  `json:"allow_public_repositories" gorm:"default:false"`
+
+	// Relationships
+	Organization Organization `json:"-" gorm:"foreignKey:OrganizationID"`
+	Repositories []Repository `json:"repositories,omitempty" gorm:"many2many:runner_group_repositories"`
+}
+
+func (rg *RunnerGroup) TableName() string {
+	return "runner_groups"
+}
+
+// Runner is a registered self-hosted runner
+type Runner struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	Name           string      `json:"name" gorm:"not null;size:255"`
+	Scope          RunnerScope `json:"scope" gorm:"type:varchar(20);not null;index"`
+	OrganizationID *uuid.UUID  `json:"organization_id,omitempty" gorm:"type:uuid;index"`
+	RepositoryID   *uuid.UUID  `json:"repository_id,omitempty" gorm:"type:uuid;index"`
+	RunnerGroupID  *uuid.UUID  `json:"runner_group_id,omitempty" gorm:"type:uuid;index"`
+	Labels         []string    `json:"labels" gorm:"serializer:json;type:text"`
+	OS             string      `json:"os" gorm:"size:50"`
+	Architecture   string      `json:"architecture" gorm:"size:50"`
+	Version        string      `json:"version" gorm:"size:50"`
+	Busy           bool        `json:"busy" gorm:"default:false"`
+	LastSeenAt     *time.Time  `json:"last_seen_at"`
+	TokenHash      string      `json:"-" gorm:"size:64;uniqueIndex"`
+
+	// Status is derived from LastSeenAt and Busy when the runner is loaded
+	Status RunnerStatus `json:"status" gorm:"-"`
+
+	// Relationships
+	RunnerGroup *RunnerGroup `json:"runner_group,omitempty" gorm:"foreignKey:RunnerGroupID"`
+}
+
+func (r *Runner) TableName() string {
+	return "runners"
+}
+
+// RunnerOfflineAfter is how long a runner may go without a heartbeat before it is offline
+const RunnerOfflineAfter = 2 * time.Minute
+
+// ComputeStatus derives the runner status at now
+func (r *Runner) ComputeStatus(now time.Time) RunnerStatus {
+	if r.LastSeenAt == nil || now.Sub(*r.LastSeenAt) > RunnerOfflineAfter {
+		return RunnerStatusOffline
+	}
+	if r.Busy {
+		return RunnerStatusBusy
+	}
+	return RunnerStatusOnline
+}
+
+// HasLabels reports whether the runner carries every label in required (case-insensitive)
+func (r *Runner) HasLabels(required []string) bool {
+	have := make(map[string]bool, len(r.Labels))
+	for _, l := range r.Labels {
+		have[strings.ToLower(l)] = true
+	}
+	for _, l := range required {
+		if !have[strings.ToLower(l)] {
+			return false
+		}
+	}
+	return true
+}
+
+// RunnerRegistrationToken is a short-lived token used by runners to register
+type RunnerRegistrationToken struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+
+	Scope          RunnerScope `json:"scope" gorm:"type:varchar(20);not null"`
+	OrganizationID *uuid.UUID  `json:"organization_id,omitempty" gorm:"type:uuid"`
+	RepositoryID   *uuid.UUID  `json:"repository_id,omitempty" gorm:"type:uuid"`
+	TokenHash      string      `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt      time.Time   `json:"expires_at" gorm:"not null"`
+	CreatedByID    *uuid.UUID  `json:"created_by_id,omitempty" gorm:"type:uuid"`
+}
+
+func (t *RunnerRegistrationToken) TableName() string {
+	return "runner_registration_tokens"
+}
+
+// RunnerJobStatus is the lifecycle state of a queued job
+type RunnerJobStatus string
+
+const (
+	RunnerJobStatusQueued    RunnerJobStatus = "queued"
+	RunnerJobStatusAssigned  RunnerJobStatus = "assigned"
+	RunnerJobStatusCompleted RunnerJobStatus = "completed"
+	RunnerJobStatusFailed    RunnerJobStatus = "failed"
+)
+
+// RunnerJob is a job waiting for, or running on, a self-hosted runner
+type RunnerJob struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	RepositoryID uuid.UUID       `json:"repository_id" gorm:"type:uuid;not null;index"`
+	Labels       []string        `json:"labels" gorm:"serializer:json;type:text"`
+	Payload      string          `json:"payload" gorm:"type:text"`
+	Status       RunnerJobStatus `json:"status" gorm:"type:varchar(20);not null;default:'queued';index"`
+	RunnerID     *uuid.UUID      `json:"runner_id,omitempty" gorm:"type:uuid;index"`
+	AssignedAt   *time.Time      `json:"assigned_at,omitempty"`
+	CompletedAt  *time.Time      `json:"completed_at,omitempty"`
+
+	// Relationships
+	Repository Repository `json:"-" gorm:"foreignKey:RepositoryID"`
+}
+
+func (j *RunnerJob) TableName() string {
+	return "runner_jobs"
+}
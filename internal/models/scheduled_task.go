@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// ScheduledTask records the runs of a periodic maintenance task. The
+// replica running it holds it until LockedUntil and renews the lock while
+// the task runs, so a task never runs twice at once.
+type ScheduledTask struct {
+	Name           string     `json:"name" gorm:"primaryKey;size:100"`
+	LastStartedAt  *time.Time `json:"last_started_at"`
+	LastFinishedAt *time.Time `json:"last_finished_at"`
+	LastDurationMS int64      `json:"last_duration_ms"`
+	LastError      string     `json:"last_error,omitempty" gorm:"type:text"`
+	LockedBy       string     `json:"locked_by,omitempty" gorm:"size:255"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+func (t *ScheduledTask) TableName() string {
+	return "scheduled_tasks"
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SchedulerLease records which server replica currently runs a named
+// background scheduler, and until when its claim holds without renewal
+type SchedulerLease struct {
+	Name      string    `json:"name" gorm:"primaryKey;size:100"`
+	Holder    string    `json:"holder" gorm:"not null;size:255"`
+	ExpiresAt time.Time `json:"expires_at" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (l *SchedulerLease) TableName() string {
+	return "scheduler_leases"
+}
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Signing key types, as reported in commit verifications
+const (
+	SigningKeyTypeGPG = "gpg"
+	SigningKeyTypeSSH = "ssh"
+)
+
+// GPGKey is an OpenPGP public key a user signs commits and tags with.
+// Subkeys are stored as rows of their own pointing at their primary key, so
+// signatures made by either are matched by key ID.
+type GPGKey struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	UserID       uuid.UUID  `json:"user_id" gorm:"type:uuid;not null;index"`
+	PrimaryKeyID *uuid.UUID `json:"primary_key_id,omitempty" gorm:"type:uuid;index"`
+	Name         string     `json:"name,omitempty" gorm:"size:255"`
+	// KeyID is the 16 hex digit long key ID, upper case
+	KeyID       string     `json:"key_id" gorm:"not null;size:16;index"`
+	Fingerprint string     `json:"fingerprint" gorm:"not null;size:64"`
+	PublicKey   string     `json:"public_key,omitempty" gorm:"type:text"`
+	Emails      []string   `json:"emails" gorm:"serializer:json;type:text"`
+	CanSign     bool       `json:"can_sign" gorm:"default:false"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+
+	// Relationships
+	User    User     `json:"-" gorm:"foreignKey:UserID"`
+	Subkeys []GPGKey `json:"subkeys,omitempty" gorm:"foreignKey:PrimaryKeyID"`
+}
+
+func (k *GPGKey) TableName() string {
+	return "gpg_keys"
+}
+
+// SSHSigningKey is an SSH public key a user signs commits and tags with.
+// Signing keys are kept apart from the authentication keys in ssh_keys, as
+// git does with gpg.ssh.allowedSignersFile.
+type SSHSigningKey struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	UserID      uuid.UUID `json:"user_id" gorm:"type:uuid;not null;index"`
+	Title       string    `json:"title" gorm:"not null;size:255"`
+	Key         string    `json:"key" gorm:"not null;type:text"`
+	Fingerprint string    `json:"fingerprint" gorm:"not null;size:255;index"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+func (k *SSHSigningKey) TableName() string {
+	return "ssh_signing_keys"
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// SSHHostKey is an SSH host key kept in the database so that every SSH
+// server replica presents the same key, whichever replica a client reaches
+type SSHHostKey struct {
+	Algorithm  string    `json:"algorithm" gorm:"primaryKey;size:50"`
+	PrivateKey string    `json:"-" gorm:"type:text;not null;serializer:encrypted"` // PEM encoded
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (k *SSHHostKey) TableName() string {
+	return "ssh_host_keys"
+}
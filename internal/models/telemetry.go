@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TelemetryInstance holds the random identifier telemetry reports are sent
+// under. The table has a single row created on first use, so the identifier
+// survives restarts without revealing anything about the instance.
+type TelemetryInstance struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (i *TelemetryInstance) TableName() string {
+	return "telemetry_instances"
+}
+
+type TelemetryTrigger string
+
+const (
+	TelemetryScheduled TelemetryTrigger = "scheduled"
+	TelemetryManual    TelemetryTrigger = "manual"
+)
+
+// TelemetrySubmission records one telemetry report and exactly what was sent
+type TelemetrySubmission struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	Trigger      TelemetryTrigger `json:"trigger" gorm:"type:varchar(20);not null"`
+	Endpoint     string           `json:"endpoint" gorm:"size:2048;not null"`
+	Status       string           `json:"status" gorm:"type:varchar(20);not null;index"` // success, error
+	StatusCode   int              `json:"status_code,omitempty"`
+	ErrorMessage string           `json:"error_message,omitempty" gorm:"type:text"`
+	Payload      string           `json:"payload" gorm:"type:text;not null"`
+}
+
+func (s *TelemetrySubmission) TableName() string {
+	return "telemetry_submissions"
+}
@@ -13,22 +13,30 @@ type User struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
-	Username         string     `json:"username" gorm:"uniqueIndex;not null;size:255"`
-	Email            string     `json:"email" gorm:"uniqueIndex;not null;size:255"`
-	PasswordHash     string     `json:"-" gorm:"not null;size:255"`
-	FullName         string     `json:"full_name" gorm:"size:255"`
-	AvatarURL        string     `json:"avatar_url" gorm:"type:text"`
-	Bio              string     `json:"bio" gorm:"type:text"`
-	Location         string     `json:"location" gorm:"size:255"`
-	Website          string     `json:"website" gorm:"size:255"`
-	Company          string     `json:"company" gorm:"size:255"`
-	EmailVerified    bool       `json:"email_verified" gorm:"default:false"`
-	TwoFactorEnabled bool       `json:"two_factor_enabled" gorm:"default:false"`
-	TwoFactorSecret  string     `json:"-" gorm:"size:255"`
-	PhoneNumber      string     `json:"phone_number" gorm:"size:20"`
-	IsActive         bool       `json:"is_active" gorm:"default:true"`
-	IsAdmin          bool       `json:"is_admin" gorm:"default:false"`
-	LastLoginAt      *time.Time `json:"last_login_at"`
+	Username         string `json:"username" gorm:"uniqueIndex;not null;size:255"`
+	Email            string `json:"email" gorm:"uniqueIndex;not null;size:255"`
+	PasswordHash     string `json:"-" gorm:"not null;size:255"`
+	FullName         string `json:"full_name" gorm:"size:255"`
+	AvatarURL        string `json:"avatar_url" gorm:"type:text"`
+	Bio              string `json:"bio" gorm:"type:text"`
+	Location         string `json:"location" gorm:"size:255"`
+	Website          string `json:"website" gorm:"size:255"`
+	Company          string `json:"company" gorm:"size:255"`
+	EmailVerified    bool   `json:"email_verified" gorm:"default:false"`
+	TwoFactorEnabled bool   `json:"two_factor_enabled" gorm:"default:false"`
+	TwoFactorSecret  string `json:"-" gorm:"type:text;serializer:encrypted"`
+	// TwoFactorPendingSecret holds a TOTP secret until a code confirms it
+	TwoFactorPendingSecret string     `json:"-" gorm:"type:text;serializer:encrypted"`
+	PhoneNumber            string     `json:"phone_number" gorm:"size:20"`
+	IsActive               bool       `json:"is_active" gorm:"default:true"`
+	IsAdmin                bool       `json:"is_admin" gorm:"default:false"`
+	IsServiceAccount       bool       `json:"is_service_account" gorm:"default:false"`
+	LastLoginAt            *time.Time `json:"last_login_at"`
+	// IsExternal marks an external collaborator, who only sees repositories
+	// shared with them directly and cannot own repositories or join organizations
+	IsExternal bool `json:"is_external" gorm:"default:false;index"`
+	// Locale is the preferred language for server-generated text; empty follows Accept-Language
+	Locale string `json:"locale" gorm:"size:20"`
 	// Roles extracted from external identity providers (e.g. OIDC), not persisted in DB
 	Roles []string `json:"roles" gorm:"-"`
 
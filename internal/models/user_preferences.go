@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DateFormat selects how dates are written in server-rendered text
+type DateFormat string
+
+const (
+	DateFormatISO  DateFormat = "iso"  // 2006-01-02
+	DateFormatUS   DateFormat = "us"   // 01/02/2006
+	DateFormatEU   DateFormat = "eu"   // 02/01/2006
+	DateFormatLong DateFormat = "long" // Jan 2, 2006
+)
+
+// Layout returns the Go time layout of a date format
+func (f DateFormat) Layout() string {
+	switch f {
+	case DateFormatUS:
+		return "01/02/2006"
+	case DateFormatEU:
+		return "02/01/2006"
+	case DateFormatLong:
+		return "Jan 2, 2006"
+	}
+	return "2006-01-02"
+}
+
+// DiffView is the default layout of commit and pull request diffs
+type DiffView string
+
+const (
+	DiffViewUnified DiffView = "unified"
+	DiffViewSplit   DiffView = "split"
+)
+
+// UserPreferences holds a user's display and notification preferences
+type UserPreferences struct {
+	ID        uuid.UUID      `json:"-" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time      `json:"-"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	UserID uuid.UUID `json:"-" gorm:"type:uuid;not null;uniqueIndex"`
+	// Timezone is an IANA zone name used for dates and daily analytics buckets
+	Timezone      string     `json:"timezone" gorm:"size:64;not null;default:'UTC'"`
+	DateFormat    DateFormat `json:"date_format" gorm:"size:20;not null;default:'iso'"`
+	DiffView      DiffView   `json:"diff_view" gorm:"size:20;not null;default:'unified'"`
+	EditorTabSize int        `json:"editor_tab_size" gorm:"not null;default:4"`
+	// EmailFrequency is the digest frequency for organizations the user has
+	// not configured individually; "default" follows each organization
+	EmailFrequency DigestFrequency `json:"email_frequency" gorm:"size:20;not null;default:'default'"`
+	// NotificationEmailFrequency is how often unread notifications are
+	// emailed: daily, weekly or off
+	NotificationEmailFrequency DigestFrequency `json:"notification_email_frequency" gorm:"size:20;not null;default:'daily'"`
+}
+
+func (up *UserPreferences) TableName() string {
+	return "user_preferences"
+}
+
+// Location returns the preferred timezone, falling back to UTC
+func (up *UserPreferences) Location() *time.Location {
+	if up == nil || up.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(up.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
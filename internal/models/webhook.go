@@ -1,30 +1,50 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 
+	// Registers the encrypted serializer used by secret columns
+	_ "github.com/a5c-ai/hub/internal/encryption"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
-// Webhook represents a repository webhook configuration
+// Webhook represents a repository or organization webhook configuration.
+// Exactly one of RepositoryID and OrganizationID is set.
 type Webhook struct {
 	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primaryKey"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
-	RepositoryID uuid.UUID `json:"repository_id" gorm:"type:uuid;not null;index"`
-	Name         string    `json:"name" gorm:"not null;size:255"`
-	URL          string    `json:"url" gorm:"not null;size:2048"`
-	Secret       string    `json:"-" gorm:"size:255"`
-	ContentType  string    `json:"content_type" gorm:"default:'application/json';size:100"`
-	InsecureSSL  bool      `json:"insecure_ssl" gorm:"default:false"`
-	Active       bool      `json:"active" gorm:"default:true"`
-	Events       string    `json:"events" gorm:"type:text"`
+	RepositoryID   *uuid.UUID `json:"repository_id,omitempty" gorm:"type:uuid;index"`
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty" gorm:"type:uuid;index"`
+	Name           string     `json:"name" gorm:"not null;size:255"`
+	URL            string     `json:"url" gorm:"not null;size:2048"`
+	Secret         string     `json:"-" gorm:"type:text;serializer:encrypted"`
+	ContentType    string     `json:"content_type" gorm:"default:'application/json';size:100"`
+	InsecureSSL    bool       `json:"insecure_ssl" gorm:"default:false"`
+	Active         bool       `json:"active" gorm:"default:true"`
+	Events         string     `json:"events" gorm:"type:text"`
+
+	// PayloadVersion pins the payload schema sent to the receiver.
+	// PayloadTemplate, when set, is a Go template rendering the request body
+	// from the payload of that version.
+	PayloadVersion  string `json:"payload_version" gorm:"not null;default:'v1';size:10"`
+	PayloadTemplate string `json:"payload_template,omitempty" gorm:"type:text"`
+
+	// Health of the endpoint. ConsecutiveFailures counts deliveries that
+	// failed for good since the last successful one; DisabledAt is set when
+	// the webhook was deactivated because of them.
+	ConsecutiveFailures int        `json:"consecutive_failures" gorm:"not null;default:0"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason      string     `json:"disabled_reason,omitempty" gorm:"size:255"`
 
 	// Relationships
-	Repository Repository        `json:"repository,omitempty" gorm:"foreignKey:RepositoryID"`
+	Repository *Repository       `json:"repository,omitempty" gorm:"foreignKey:RepositoryID"`
 	Deliveries []WebhookDelivery `json:"deliveries,omitempty" gorm:"foreignKey:WebhookID"`
 }
 
@@ -32,34 +52,58 @@ func (w *Webhook) TableName() string {
 	return "webhooks"
 }
 
-// GetEventsSlice returns the events as a slice of strings
+// GetEventsSlice returns the events the webhook subscribes to. Events are
+// stored as a JSON array; older rows hold a comma separated list.
 func (w *Webhook) GetEventsSlice() []string {
+	events := []string{}
 	if w.Events == "" {
-		return []string{}
+		return events
 	}
-
-	// Parse JSON events string
-	// For simplicity, assume comma-separated for now
-	// In production, this would use proper JSON unmarshaling
-	events := []string{}
-	// This is a simplified implementation - in production use proper JSON
-	if w.Events != "" {
-		// Simple comma-separated parsing for now
-		return []string{"push", "pull_request"} // Default events
+	if err := json.Unmarshal([]byte(w.Events), &events); err == nil {
+		return events
+	}
+	events = events[:0]
+	for _, event := range strings.Split(w.Events, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			events = append(events, event)
+		}
 	}
 	return events
 }
 
-// SetEventsSlice sets the events from a slice of strings
+// SetEventsSlice sets the events the webhook subscribes to
 func (w *Webhook) SetEventsSlice(events []string) {
-	// In production, this would use proper JSON marshaling
-	// For now, store as comma-separated
 	if len(events) == 0 {
 		w.Events = ""
 		return
 	}
-	// Simple implementation - in production use proper JSON
-	w.Events = "push,pull_request" // Default for demo
+	data, _ := json.Marshal(events)
+	w.Events = string(data)
+}
+
+// BelongsToRepository reports whether the webhook is configured on repoID
+func (w *Webhook) BelongsToRepository(repoID uuid.UUID) bool {
+	return w.RepositoryID != nil && *w.RepositoryID == repoID
+}
+
+// BelongsToOrganization reports whether the webhook is configured on orgID
+func (w *Webhook) BelongsToOrganization(orgID uuid.UUID) bool {
+	return w.OrganizationID != nil && *w.OrganizationID == orgID
+}
+
+// SubscribesTo reports whether the webhook receives events of eventType with
+// action. "*" subscribes to every event, an event name to all of its actions
+// and "event.action" to that action only.
+func (w *Webhook) SubscribesTo(eventType, action string) bool {
+	for _, event := range w.GetEventsSlice() {
+		if event == eventType || event == "*" {
+			return true
+		}
+		if action != "" && event == eventType+"."+action {
+			return true
+		}
+	}
+	return false
 }
 
 // WebhookDelivery represents a webhook delivery attempt
@@ -80,6 +124,8 @@ type WebhookDelivery struct {
 	ErrorMessage string     `json:"error_message" gorm:"type:text"`
 	Attempts     int        `json:"attempts" gorm:"default:1"`
 	NextRetryAt  *time.Time `json:"next_retry_at"`
+	// Redelivery marks deliveries resent on request
+	Redelivery bool `json:"redelivery" gorm:"not null;default:false"`
 
 	// Response details
 	ResponseHeaders string `json:"response_headers" gorm:"type:text"`
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkflowStatus is how far a workflow run, job or step has progressed
+type WorkflowStatus string
+
+const (
+	WorkflowStatusQueued     WorkflowStatus = "queued"
+	WorkflowStatusInProgress WorkflowStatus = "in_progress"
+	WorkflowStatusCompleted  WorkflowStatus = "completed"
+)
+
+// WorkflowConclusion is the outcome of a completed run, job or step
+type WorkflowConclusion string
+
+const (
+	WorkflowConclusionSuccess   WorkflowConclusion = "success"
+	WorkflowConclusionFailure   WorkflowConclusion = "failure"
+	WorkflowConclusionCancelled WorkflowConclusion = "cancelled"
+	WorkflowConclusionSkipped   WorkflowConclusion = "skipped"
+)
+
+// WorkflowRun is one execution of a workflow file from .hub/workflows,
+// triggered by a push or pull request
+type WorkflowRun struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	RepositoryID  uuid.UUID          `json:"repository_id" gorm:"type:uuid;not null;uniqueIndex:idx_workflow_runs_repo_number"`
+	RunNumber     int                `json:"run_number" gorm:"not null;uniqueIndex:idx_workflow_runs_repo_number"`
+	Name          string             `json:"name" gorm:"not null;size:255"`
+	WorkflowPath  string             `json:"path" gorm:"not null;size:1024"`
+	Event         string             `json:"event" gorm:"not null;size:50;index"`
+	HeadBranch    string             `json:"head_branch" gorm:"size:255;index"`
+	HeadSHA       string             `json:"head_sha" gorm:"not null;size:40"`
+	PullRequestID *uuid.UUID         `json:"pull_request_id,omitempty" gorm:"type:uuid;index"`
+	ActorID       *uuid.UUID         `json:"actor_id,omitempty" gorm:"type:uuid"`
+	Status        WorkflowStatus     `json:"status" gorm:"type:varchar(20);not null;default:'queued';index"`
+	Conclusion    WorkflowConclusion `json:"conclusion,omitempty" gorm:"type:varchar(20)"`
+	StartedAt     *time.Time         `json:"started_at,omitempty"`
+	CompletedAt   *time.Time         `json:"completed_at,omitempty"`
+
+	// Relationships
+	Actor *User          `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+	Jobs  []*WorkflowJob `json:"jobs,omitempty" gorm:"foreignKey:RunID"`
+}
+
+func (r *WorkflowRun) TableName() string {
+	return "workflow_runs"
+}
+
+// WorkflowJob is a job of a workflow run. It is dispatched to a runner as a
+// RunnerJob once every job it needs has succeeded.
+type WorkflowJob struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	RunID uuid.UUID `json:"run_id" gorm:"type:uuid;not null;index"`
+	// Key is the job's id in the workflow file
+	Key         string             `json:"key" gorm:"not null;size:255"`
+	Name        string             `json:"name" gorm:"not null;size:255"`
+	RunsOn      []string           `json:"runs_on" gorm:"serializer:json;type:text"`
+	Needs       []string           `json:"needs" gorm:"serializer:json;type:text"`
+	Status      WorkflowStatus     `json:"status" gorm:"type:varchar(20);not null;default:'queued'"`
+	Conclusion  WorkflowConclusion `json:"conclusion,omitempty" gorm:"type:varchar(20)"`
+	RunnerJobID *uuid.UUID         `json:"runner_job_id,omitempty" gorm:"type:uuid;uniqueIndex"`
+	RunnerID    *uuid.UUID         `json:"runner_id,omitempty" gorm:"type:uuid"`
+	StartedAt   *time.Time         `json:"started_at,omitempty"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+	// Payload is what the runner receives: the job's steps and environment
+	Payload string `json:"-" gorm:"type:text"`
+	// Log is the output the runner streamed for the job
+	Log string `json:"-" gorm:"type:text"`
+
+	// Relationships
+	Steps []*WorkflowStep `json:"steps" gorm:"foreignKey:JobID"`
+}
+
+func (j *WorkflowJob) TableName() string {
+	return "workflow_jobs"
+}
+
+// WorkflowStep is a step of a workflow job, numbered from 1 in file order
+type WorkflowStep struct {
+	ID        uuid.UUID `json:"id" gorm:"type:uuid;primaryKey;default:(gen_random_uuid())"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	JobID       uuid.UUID          `json:"job_id" gorm:"type:uuid;not null;index"`
+	Number      int                `json:"number" gorm:"not null"`
+	Name        string             `json:"name" gorm:"not null;size:255"`
+	Status      WorkflowStatus     `json:"status" gorm:"type:varchar(20);not null;default:'queued'"`
+	Conclusion  WorkflowConclusion `json:"conclusion,omitempty" gorm:"type:varchar(20)"`
+	StartedAt   *time.Time         `json:"started_at,omitempty"`
+	CompletedAt *time.Time         `json:"completed_at,omitempty"`
+}
+
+func (s *WorkflowStep) TableName() string {
+	return "workflow_steps"
+}
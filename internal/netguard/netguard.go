@@ -0,0 +1,83 @@
+// Package netguard keeps connections the server makes on behalf of users,
+// such as repository imports, away from addresses inside the deployment:
+// loopback, private and shared networks, link-local addresses (including
+// cloud metadata endpoints) and anything unspecified or multicast.
+package netguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for hosts that resolve to an address the
+// server must not connect to
+var ErrForbiddenAddress = errors.New("address is not reachable from this server")
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, which
+// net.IP does not count as private
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// Check returns ErrForbiddenAddress when ip is not a public unicast address
+func Check(ip net.IP) error {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip == nil || ip.IsUnspecified() || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip) ||
+		ip.Equal(net.IPv4bcast) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+	}
+	return nil
+}
+
+// Resolve looks host up and returns its first address, failing when any of
+// its addresses is forbidden so a name cannot mix public and internal ones
+func Resolve(ctx context.Context, host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, Check(ip)
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses found for %s", host)
+	}
+	for _, addr := range addrs {
+		if err := Check(addr.IP); err != nil {
+			return nil, fmt.Errorf("%s: %w", host, err)
+		}
+	}
+	return addrs[0].IP, nil
+}
+
+// Dialer returns a dialer that refuses forbidden addresses. The check runs
+// on the address actually dialed, after resolution, so names re-pointed
+// between lookups are caught too.
+func Dialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return Check(net.ParseIP(host))
+		},
+	}
+}
+
+// Transport returns an HTTP transport dialing through Dialer. Proxies are
+// not used, as the proxy would make the connection instead.
+func Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = Dialer().DialContext
+	return transport
+}
@@ -0,0 +1,40 @@
+package netguard
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	for _, addr := range []string{"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"100.64.0.1", "0.0.0.0", "::", "fd00:ec2::254", "fe80::1", "224.0.0.1", "255.255.255.255", "::ffff:127.0.0.1"} {
+		assert.ErrorIs(t, Check(net.ParseIP(addr)), ErrForbiddenAddress, addr)
+	}
+	for _, addr := range []string{"140.82.112.3", "8.8.8.8", "2606:4700:4700::1111"} {
+		assert.NoError(t, Check(net.ParseIP(addr)), addr)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	_, err := Resolve(context.Background(), "127.0.0.1")
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+	_, err = Resolve(context.Background(), "localhost")
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+	ip, err := Resolve(context.Background(), "8.8.8.8")
+	require.NoError(t, err)
+	assert.Equal(t, "8.8.8.8", ip.String())
+}
+
+func TestTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	_, err := (&http.Client{Transport: Transport()}).Get(server.URL)
+	assert.ErrorIs(t, err, ErrForbiddenAddress)
+}
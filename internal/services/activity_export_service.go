@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Webhook event names produced by the activity export
+const (
+	WebhookEventPush                     = "push"
+	WebhookEventIssues                   = "issues"
+	WebhookEventIssueComment             = "issue_comment"
+	WebhookEventPullRequest              = "pull_request"
+	WebhookEventPullRequestReview        = "pull_request_review"
+	WebhookEventPullRequestReviewComment = "pull_request_review_comment"
+)
+
+// ActivityExportEvents lists the events an export can contain
+var ActivityExportEvents = []string{
+	WebhookEventPush,
+	WebhookEventIssues,
+	WebhookEventIssueComment,
+	WebhookEventPullRequest,
+	WebhookEventPullRequestReview,
+	WebhookEventPullRequestReviewComment,
+}
+
+// ActivityExportOptions selects the events of an export; zero values select everything
+type ActivityExportOptions struct {
+	Events []string
+	Since  *time.Time
+	Until  *time.Time
+}
+
+// ActivityExportService replays the history of a repository as webhook
+// payloads so integrations enabled late can backfill
+type ActivityExportService interface {
+	// Export writes the repository's events oldest first as newline-delimited
+	// JSON WebhookPayloads and returns how many were written
+	Export(ctx context.Context, repo *models.Repository, fullName string, opts ActivityExportOptions, w io.Writer) (int, error)
+}
+
+type activityExportService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+// NewActivityExportService creates a new ActivityExportService
+func NewActivityExportService(db *gorm.DB, logger *logrus.Logger) ActivityExportService {
+	return &activityExportService{db: db, logger: logger}
+}
+
+// activityRecord is one payload of an export and when it happened
+type activityRecord struct {
+	at      time.Time
+	payload WebhookPayload
+}
+
+type activityExport struct {
+	db         *gorm.DB
+	repo       *models.Repository
+	repository map[string]interface{}
+	opts       ActivityExportOptions
+	records    []activityRecord
+}
+
+func (s *activityExportService) Export(ctx context.Context, repo *models.Repository, fullName string, opts ActivityExportOptions, w io.Writer) (int, error) {
+	e := &activityExport{
+		db:   s.db.WithContext(ctx),
+		repo: repo,
+		repository: map[string]interface{}{
+			"id":             repo.ID.String(),
+			"name":           repo.Name,
+			"full_name":      fullName,
+			"private":        repo.Visibility != models.VisibilityPublic,
+			"default_branch": repo.DefaultBranch,
+		},
+		opts: opts,
+	}
+
+	collectors := map[string]func() error{
+		WebhookEventPush:                     e.pushes,
+		WebhookEventIssues:                   e.issues,
+		WebhookEventIssueComment:             e.issueComments,
+		WebhookEventPullRequest:              e.pullRequests,
+		WebhookEventPullRequestReview:        e.reviews,
+		WebhookEventPullRequestReviewComment: e.reviewComments,
+	}
+	for _, event := range ActivityExportEvents {
+		if !e.wants(event) {
+			continue
+		}
+		if err := collectors[event](); err != nil {
+			return 0, err
+		}
+	}
+
+	sort.SliceStable(e.records, func(i, j int) bool { return e.records[i].at.Before(e.records[j].at) })
+
+	enc := json.NewEncoder(w)
+	for i, record := range e.records {
+		if err := enc.Encode(record.payload); err != nil {
+			return i, fmt.Errorf("failed to write activity export: %w", err)
+		}
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"repository_id": repo.ID,
+		"events":        len(e.records),
+	}).Info("Exported repository activity")
+	return len(e.records), nil
+}
+
+func (e *activityExport) wants(event string) bool {
+	if len(e.opts.Events) == 0 {
+		return true
+	}
+	for _, wanted := range e.opts.Events {
+		if wanted == event {
+			return true
+		}
+	}
+	return false
+}
+
+// add records a payload if it happened within the export window
+func (e *activityExport) add(at time.Time, event, action string, sender *models.User, data map[string]interface{}) {
+	if (e.opts.Since != nil && at.Before(*e.opts.Since)) || (e.opts.Until != nil && at.After(*e.opts.Until)) {
+		return
+	}
+	e.records = append(e.records, activityRecord{at: at, payload: WebhookPayload{
+		Event:      event,
+		Action:     action,
+		Repository: e.repository,
+		Sender:     exportUser(sender),
+		Data:       data,
+		Timestamp:  at,
+	}})
+}
+
+// pushes replays synced commits as one push each to the default branch, since
+// individual pushes are not retained
+func (e *activityExport) pushes() error {
+	var commits []models.Commit
+	if err := e.db.Where("repository_id = ?", e.repo.ID).Order("committer_date ASC").Find(&commits).Error; err != nil {
+		return fmt.Errorf("failed to load commits: %w", err)
+	}
+	for _, commit := range commits {
+		before := commit.ParentSHA
+		if before == "" {
+			before = zeroSHA
+		}
+		e.add(commit.CommitterDate, WebhookEventPush, "", nil, map[string]interface{}{
+			"ref":    "refs/heads/" + e.repo.DefaultBranch,
+			"before": before,
+			"after":  commit.SHA,
+			"pusher": map[string]interface{}{"name": commit.CommitterName, "email": commit.CommitterEmail},
+			"commits": []map[string]interface{}{{
+				"id":        commit.SHA,
+				"message":   commit.Message,
+				"timestamp": commit.AuthorDate,
+				"author":    map[string]interface{}{"name": commit.AuthorName, "email": commit.AuthorEmail},
+				"committer": map[string]interface{}{"name": commit.CommitterName, "email": commit.CommitterEmail},
+			}},
+		})
+	}
+	return nil
+}
+
+func (e *activityExport) issues() error {
+	var issues []models.Issue
+	err := e.db.Preload("User").Preload("ClosedBy").
+		Where("repository_id = ?", e.repo.ID).Order("number ASC").Find(&issues).Error
+	if err != nil {
+		return fmt.Errorf("failed to load issues: %w", err)
+	}
+	for i := range issues {
+		issue := &issues[i]
+		e.add(issue.CreatedAt, WebhookEventIssues, "opened", issue.User, map[string]interface{}{"issue": exportIssue(issue)})
+		if issue.ClosedAt != nil {
+			e.add(*issue.ClosedAt, WebhookEventIssues, "closed", issue.ClosedBy, map[string]interface{}{"issue": exportIssue(issue)})
+		}
+	}
+	return nil
+}
+
+func (e *activityExport) issueComments() error {
+	var comments []models.Comment
+	err := e.db.Preload("User").Preload("Issue").Preload("PullRequest").
+		Where("issue_id IN (?) OR pull_request_id IN (?)",
+			e.db.Model(&models.Issue{}).Select("id").Where("repository_id = ?", e.repo.ID),
+			e.db.Model(&models.PullRequest{}).Select("id").Where("repository_id = ?", e.repo.ID)).
+		Order("created_at ASC").Find(&comments).Error
+	if err != nil {
+		return fmt.Errorf("failed to load comments: %w", err)
+	}
+	for i := range comments {
+		comment := &comments[i]
+		data := map[string]interface{}{"comment": exportComment(comment.ID, comment.Body, comment.User, comment.CreatedAt)}
+		if comment.Issue != nil {
+			data["issue"] = exportIssue(comment.Issue)
+		}
+		if comment.PullRequest != nil {
+			data["pull_request"] = exportPullRequest(comment.PullRequest)
+		}
+		e.add(comment.CreatedAt, WebhookEventIssueComment, "created", comment.User, data)
+	}
+	return nil
+}
+
+func (e *activityExport) pullRequests() error {
+	var prs []models.PullRequest
+	err := e.db.Preload("User").Preload("MergedBy").
+		Where("repository_id = ?", e.repo.ID).Order("number ASC").Find(&prs).Error
+	if err != nil {
+		return fmt.Errorf("failed to load pull requests: %w", err)
+	}
+	for i := range prs {
+		pr := &prs[i]
+		e.add(pr.CreatedAt, WebhookEventPullRequest, "opened", pr.User, map[string]interface{}{"pull_request": exportPullRequest(pr)})
+		switch {
+		case pr.MergedAt != nil:
+			e.add(*pr.MergedAt, WebhookEventPullRequest, "closed", pr.MergedBy, map[string]interface{}{"pull_request": exportPullRequest(pr)})
+		case pr.ClosedAt != nil:
+			e.add(*pr.ClosedAt, WebhookEventPullRequest, "closed", nil, map[string]interface{}{"pull_request": exportPullRequest(pr)})
+		}
+	}
+	return nil
+}
+
+func (e *activityExport) reviews() error {
+	var reviews []models.Review
+	err := e.db.Preload("User").Preload("PullRequest").
+		Where("pull_request_id IN (?) AND submitted_at IS NOT NULL",
+			e.db.Model(&models.PullRequest{}).Select("id").Where("repository_id = ?", e.repo.ID)).
+		Order("submitted_at ASC").Find(&reviews).Error
+	if err != nil {
+		return fmt.Errorf("failed to load reviews: %w", err)
+	}
+	for i := range reviews {
+		review := &reviews[i]
+		e.add(*review.SubmittedAt, WebhookEventPullRequestReview, "submitted", review.User, map[string]interface{}{
+			"review": map[string]interface{}{
+				"id":           review.ID.String(),
+				"state":        review.State,
+				"body":         review.Body,
+				"commit_id":    review.CommitSHA,
+				"user":         exportUser(review.User),
+				"submitted_at": review.SubmittedAt,
+			},
+			"pull_request": exportPullRequest(&review.PullRequest),
+		})
+	}
+	return nil
+}
+
+func (e *activityExport) reviewComments() error {
+	var comments []models.ReviewComment
+	err := e.db.Preload("User").Preload("PullRequest").
+		Where("pull_request_id IN (?)",
+			e.db.Model(&models.PullRequest{}).Select("id").Where("repository_id = ?", e.repo.ID)).
+		Order("created_at ASC").Find(&comments).Error
+	if err != nil {
+		return fmt.Errorf("failed to load review comments: %w", err)
+	}
+	for i := range comments {
+		comment := &comments[i]
+		payload := exportComment(comment.ID, comment.Body, comment.User, comment.CreatedAt)
+		payload["path"] = comment.Path
+		payload["commit_id"] = comment.CommitSHA
+		payload["line"] = comment.Line
+		payload["side"] = comment.Side
+		e.add(comment.CreatedAt, WebhookEventPullRequestReviewComment, "created", comment.User, map[string]interface{}{
+			"comment":      payload,
+			"pull_request": exportPullRequest(&comment.PullRequest),
+		})
+	}
+	return nil
+}
+
+func exportUser(user *models.User) map[string]interface{} {
+	if user == nil {
+		return nil
+	}
+	return map[string]interface{}{"id": user.ID.String(), "login": user.Username}
+}
+
+func exportIssue(issue *models.Issue) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         issue.ID.String(),
+		"number":     issue.Number,
+		"title":      issue.Title,
+		"body":       issue.Body,
+		"state":      issue.State,
+		"user":       exportUser(issue.User),
+		"created_at": issue.CreatedAt,
+		"closed_at":  issue.ClosedAt,
+	}
+}
+
+func exportPullRequest(pr *models.PullRequest) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         pr.ID.String(),
+		"number":     pr.Number,
+		"title":      pr.Title,
+		"body":       pr.Body,
+		"state":      pr.State,
+		"draft":      pr.Draft,
+		"merged":     pr.Merged,
+		"base":       map[string]interface{}{"ref": pr.BaseBranch},
+		"head":       map[string]interface{}{"ref": pr.HeadBranch},
+		"user":       exportUser(pr.User),
+		"created_at": pr.CreatedAt,
+		"merged_at":  pr.MergedAt,
+		"closed_at":  pr.ClosedAt,
+	}
+}
+
+func exportComment(id uuid.UUID, body string, user *models.User, createdAt time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         id.String(),
+		"body":       body,
+		"user":       exportUser(user),
+		"created_at": createdAt,
+	}
+}
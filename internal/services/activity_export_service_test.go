@@ -0,0 +1,74 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivityExportService_Export(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.Commit{}, &models.Issue{},
+		&models.PullRequest{}, &models.Comment{}, &models.Review{}, &models.ReviewComment{})
+
+	user := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(user).Error)
+	repo := &models.Repository{ID: uuid.New(), OwnerID: user.ID, OwnerType: models.OwnerTypeUser, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	other := &models.Repository{ID: uuid.New(), OwnerID: user.ID, OwnerType: models.OwnerTypeUser, Name: "other", DefaultBranch: "main", Visibility: models.VisibilityPublic}
+	require.NoError(t, db.Create(other).Error)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, db.Create(&models.Commit{ID: uuid.New(), RepositoryID: repo.ID, SHA: "abc", Message: "init",
+		AuthorName: "Alice", AuthorEmail: "alice@example.com", AuthorDate: base, CommitterName: "Alice",
+		CommitterEmail: "alice@example.com", CommitterDate: base, TreeSHA: "t"}).Error)
+
+	closedAt := base.Add(3 * time.Hour)
+	issue := &models.Issue{ID: uuid.New(), RepositoryID: repo.ID, Number: 1, Title: "bug", UserID: &user.ID,
+		State: models.IssueStateClosed, ClosedAt: &closedAt, ClosedByID: &user.ID, CreatedAt: base.Add(time.Hour)}
+	require.NoError(t, db.Create(issue).Error)
+	require.NoError(t, db.Create(&models.Issue{ID: uuid.New(), RepositoryID: other.ID, Number: 1, Title: "elsewhere",
+		State: models.IssueStateOpen, CreatedAt: base}).Error)
+	require.NoError(t, db.Create(&models.Comment{ID: uuid.New(), IssueID: &issue.ID, UserID: &user.ID, Body: "on it",
+		CreatedAt: base.Add(2 * time.Hour)}).Error)
+
+	var out bytes.Buffer
+	svc := NewActivityExportService(db, logrus.New())
+	n, err := svc.Export(context.Background(), repo, "alice/app", ActivityExportOptions{}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	var payloads []WebhookPayload
+	for _, line := range lines {
+		var p WebhookPayload
+		require.NoError(t, json.Unmarshal([]byte(line), &p))
+		payloads = append(payloads, p)
+	}
+	assert.Equal(t, []string{"push", "issues", "issue_comment", "issues"},
+		[]string{payloads[0].Event, payloads[1].Event, payloads[2].Event, payloads[3].Event})
+	assert.Equal(t, "opened", payloads[1].Action)
+	assert.Equal(t, "closed", payloads[3].Action)
+	assert.Equal(t, "alice/app", payloads[0].Repository["full_name"])
+	assert.Equal(t, true, payloads[0].Repository["private"])
+	assert.Equal(t, "abc", payloads[0].Data["after"])
+	assert.Equal(t, "alice", payloads[2].Sender["login"])
+	assert.Equal(t, "on it", payloads[2].Data["comment"].(map[string]interface{})["body"])
+
+	out.Reset()
+	since := base.Add(90 * time.Minute)
+	n, err = svc.Export(context.Background(), repo, "alice/app", ActivityExportOptions{Events: []string{WebhookEventIssues}, Since: &since}, &out)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Contains(t, out.String(), `"action":"closed"`)
+}
@@ -0,0 +1,467 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Event types of user and organization timelines
+const (
+	FeedEventPush    = "PushEvent"
+	FeedEventWatch   = "WatchEvent"
+	FeedEventRelease = "ReleaseEvent"
+	FeedEventCreate  = "CreateEvent"
+)
+
+// FeedEventTypes lists the event types a timeline can contain
+var FeedEventTypes = []string{FeedEventPush, FeedEventWatch, FeedEventRelease, FeedEventCreate}
+
+// maxFeedEvents is how far back timelines can be paged
+const maxFeedEvents = 300
+
+var (
+	// ErrFeedNotFound is returned for timelines of unknown users and organizations
+	ErrFeedNotFound = errors.New("user or organization not found")
+	// ErrInvalidFeedEventType is returned when filtering by an unknown event type
+	ErrInvalidFeedEventType = errors.New("invalid event type")
+)
+
+// FeedOptions pages and filters a timeline; no types selects every type
+type FeedOptions struct {
+	Types   []string
+	Page    int
+	PerPage int
+}
+
+// FeedActor is the user or organization of an event
+type FeedActor struct {
+	ID        uuid.UUID `json:"id"`
+	Login     string    `json:"login"`
+	AvatarURL string    `json:"avatar_url"`
+}
+
+// FeedRepository is the repository an event happened in
+type FeedRepository struct {
+	ID   uuid.UUID `json:"id"`
+	Name string    `json:"name"`
+}
+
+// FeedEvent is one entry of a user or organization timeline
+type FeedEvent struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Actor     *FeedActor             `json:"actor,omitempty"`
+	Repo      *FeedRepository        `json:"repo"`
+	Org       *FeedActor             `json:"org,omitempty"`
+	Payload   map[string]interface{} `json:"payload"`
+	Public    bool                   `json:"public"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// ActivityFeedService builds event timelines of users and organizations
+// from pushes, stars, releases and new repositories, newest first. Events
+// in repositories the tenant of ctx cannot read are left out.
+type ActivityFeedService interface {
+	// UserEvents returns the events performed by a user; hasMore reports
+	// whether a next page exists
+	UserEvents(ctx context.Context, username string, opts FeedOptions) (events []*FeedEvent, hasMore bool, err error)
+	// OrganizationEvents returns the events in the organization's repositories
+	OrganizationEvents(ctx context.Context, orgName string, opts FeedOptions) (events []*FeedEvent, hasMore bool, err error)
+}
+
+type activityFeedService struct {
+	db          *gorm.DB
+	permissions PermissionService
+	logger      *logrus.Logger
+}
+
+// NewActivityFeedService creates a new ActivityFeedService. Without a
+// permission service only events in public repositories are shown.
+func NewActivityFeedService(db *gorm.DB, permissions PermissionService, logger *logrus.Logger) ActivityFeedService {
+	return &activityFeedService{db: db, permissions: permissions, logger: logger}
+}
+
+// feedScope selects the events of one timeline
+type feedScope struct {
+	// actor limits events to those performed by a user
+	actor *models.User
+	// repos are the repositories the viewer can read, by ID
+	repos map[uuid.UUID]*models.Repository
+	// owners are the login and avatar of repository owners, by ID
+	owners map[uuid.UUID]*FeedActor
+	limit  int
+}
+
+func (s *activityFeedService) UserEvents(ctx context.Context, username string, opts FeedOptions) ([]*FeedEvent, bool, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, ErrFeedNotFound
+		}
+		return nil, false, err
+	}
+
+	db := s.db.WithContext(ctx)
+	candidates := db.Model(&models.Repository{}).
+		Where("id IN (?)", db.Model(&models.AnalyticsEvent{}).Select("repository_id").
+			Where("event_type = ? AND actor_id = ?", models.EventRepositoryPush, user.ID)).
+		Or("id IN (?)", db.Model(&models.Star{}).Select("repository_id").Where("user_id = ?", user.ID)).
+		Or("owner_id = ? AND owner_type = ?", user.ID, models.OwnerTypeUser)
+	if user.Email != "" {
+		candidates = candidates.Or("id IN (?)", db.Model(&models.Tag{}).Select("repository_id").Where("LOWER(tagger_email) = ?", strings.ToLower(user.Email)))
+	}
+	return s.timeline(ctx, candidates, &user, opts)
+}
+
+func (s *activityFeedService) OrganizationEvents(ctx context.Context, orgName string, opts FeedOptions) ([]*FeedEvent, bool, error) {
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, ErrFeedNotFound
+		}
+		return nil, false, err
+	}
+
+	candidates := s.db.WithContext(ctx).Model(&models.Repository{}).
+		Where("owner_id = ? AND owner_type = ?", org.ID, models.OwnerTypeOrganization)
+	return s.timeline(ctx, candidates, nil, opts)
+}
+
+// timeline collects the events of the readable repositories among
+// candidates, merges them newest first and returns the requested page
+func (s *activityFeedService) timeline(ctx context.Context, candidates *gorm.DB, actor *models.User, opts FeedOptions) ([]*FeedEvent, bool, error) {
+	types, err := feedTypes(opts.Types)
+	if err != nil {
+		return nil, false, err
+	}
+	page, perPage := opts.Page, opts.PerPage
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 30
+	}
+	offset := (page - 1) * perPage
+	if offset >= maxFeedEvents {
+		return []*FeedEvent{}, false, nil
+	}
+	// One extra event tells whether another page exists
+	limit := offset + perPage + 1
+	if limit > maxFeedEvents {
+		limit = maxFeedEvents
+	}
+
+	var repos []*models.Repository
+	if err := candidates.Find(&repos).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to load repositories: %w", err)
+	}
+	scope := &feedScope{actor: actor, repos: s.readable(ctx, repos), limit: limit}
+	if len(scope.repos) == 0 {
+		return []*FeedEvent{}, false, nil
+	}
+	if scope.owners, err = s.owners(ctx, scope.repos); err != nil {
+		return nil, false, err
+	}
+
+	sources := map[string]func(context.Context, *feedScope) ([]*FeedEvent, error){
+		FeedEventPush:    s.pushEvents,
+		FeedEventWatch:   s.watchEvents,
+		FeedEventRelease: s.releaseEvents,
+		FeedEventCreate:  s.createEvents,
+	}
+	var events []*FeedEvent
+	for _, t := range types {
+		found, err := sources[t](ctx, scope)
+		if err != nil {
+			return nil, false, err
+		}
+		events = append(events, found...)
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].ID > events[j].ID
+		}
+		return events[i].CreatedAt.After(events[j].CreatedAt)
+	})
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	if offset >= len(events) {
+		return []*FeedEvent{}, false, nil
+	}
+	events = events[offset:]
+	hasMore := len(events) > perPage
+	if hasMore {
+		events = events[:perPage]
+	}
+	return events, hasMore, nil
+}
+
+func feedTypes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return FeedEventTypes, nil
+	}
+	types := make([]string, 0, len(requested))
+	seen := make(map[string]bool, len(requested))
+	for _, t := range requested {
+		valid := false
+		for _, known := range FeedEventTypes {
+			valid = valid || t == known
+		}
+		if !valid {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidFeedEventType, t)
+		}
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	return types, nil
+}
+
+// readable keeps the repositories the tenant of ctx can read
+func (s *activityFeedService) readable(ctx context.Context, repos []*models.Repository) map[uuid.UUID]*models.Repository {
+	t, _ := tenant.FromContext(ctx)
+	readable := make(map[uuid.UUID]*models.Repository, len(repos))
+	for _, repo := range repos {
+		if repo.Visibility != models.VisibilityPublic && (t == nil || !t.IsAdmin) {
+			if t == nil || t.UserID == nil || s.permissions == nil {
+				continue
+			}
+			ok, err := s.permissions.CheckRepositoryPermission(ctx, *t.UserID, repo.ID, models.PermissionRead)
+			if err != nil {
+				s.logger.WithError(err).WithField("repository_id", repo.ID).Warn("Failed to check repository permission for timeline")
+			}
+			if err != nil || !ok {
+				continue
+			}
+		}
+		readable[repo.ID] = repo
+	}
+	return readable
+}
+
+// owners resolves the users and organizations owning repos
+func (s *activityFeedService) owners(ctx context.Context, repos map[uuid.UUID]*models.Repository) (map[uuid.UUID]*FeedActor, error) {
+	var userIDs, orgIDs []uuid.UUID
+	for _, repo := range repos {
+		if repo.OwnerType == models.OwnerTypeOrganization {
+			orgIDs = append(orgIDs, repo.OwnerID)
+		} else {
+			userIDs = append(userIDs, repo.OwnerID)
+		}
+	}
+
+	owners := make(map[uuid.UUID]*FeedActor)
+	actors, err := s.users(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	for id, actor := range actors {
+		owners[id] = actor
+	}
+	if len(orgIDs) > 0 {
+		var orgs []models.Organization
+		if err := s.db.WithContext(ctx).Where("id IN ?", orgIDs).Find(&orgs).Error; err != nil {
+			return nil, fmt.Errorf("failed to load organizations: %w", err)
+		}
+		for _, org := range orgs {
+			owners[org.ID] = &FeedActor{ID: org.ID, Login: org.Name, AvatarURL: org.AvatarURL}
+		}
+	}
+	return owners, nil
+}
+
+func (s *activityFeedService) users(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*FeedActor, error) {
+	actors := make(map[uuid.UUID]*FeedActor, len(ids))
+	if len(ids) == 0 {
+		return actors, nil
+	}
+	var users []models.User
+	if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to load users: %w", err)
+	}
+	for _, user := range users {
+		actors[user.ID] = &FeedActor{ID: user.ID, Login: user.Username, AvatarURL: user.AvatarURL}
+	}
+	return actors, nil
+}
+
+// event creates an event of type in repoID with the repository and
+// organization filled in from scope
+func (scope *feedScope) event(id uuid.UUID, eventType string, repoID uuid.UUID, at time.Time, payload map[string]interface{}) *FeedEvent {
+	repo := scope.repos[repoID]
+	owner := scope.owners[repo.OwnerID]
+	name := repo.Name
+	if owner != nil {
+		name = owner.Login + "/" + repo.Name
+	}
+	event := &FeedEvent{
+		ID:        id.String(),
+		Type:      eventType,
+		Repo:      &FeedRepository{ID: repo.ID, Name: name},
+		Payload:   payload,
+		Public:    repo.Visibility == models.VisibilityPublic,
+		CreatedAt: at,
+	}
+	if repo.OwnerType == models.OwnerTypeOrganization {
+		event.Org = owner
+	}
+	return event
+}
+
+func (scope *feedScope) repoIDs() []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(scope.repos))
+	for id := range scope.repos {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (s *activityFeedService) pushEvents(ctx context.Context, scope *feedScope) ([]*FeedEvent, error) {
+	query := s.db.WithContext(ctx).
+		Where("event_type = ? AND repository_id IN ?", models.EventRepositoryPush, scope.repoIDs())
+	if scope.actor != nil {
+		query = query.Where("actor_id = ?", scope.actor.ID)
+	}
+	var rows []models.AnalyticsEvent
+	if err := query.Order("created_at DESC").Limit(scope.limit).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load pushes: %w", err)
+	}
+
+	var actorIDs []uuid.UUID
+	for _, row := range rows {
+		if row.ActorID != nil {
+			actorIDs = append(actorIDs, *row.ActorID)
+		}
+	}
+	actors, err := s.users(ctx, actorIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]*FeedEvent, 0, len(rows))
+	for _, row := range rows {
+		var group PushGroup
+		if err := json.Unmarshal([]byte(row.Metadata), &group); err != nil {
+			s.logger.WithError(err).WithField("event_id", row.ID).Warn("Skipping push with unreadable metadata")
+			continue
+		}
+		event := scope.event(row.ID, FeedEventPush, *row.RepositoryID, row.CreatedAt, map[string]interface{}{
+			"push_id": group.PushID,
+			"size":    group.RefCount,
+			"refs":    group.Refs(),
+		})
+		if row.ActorID != nil {
+			event.Actor = actors[*row.ActorID]
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+func (s *activityFeedService) watchEvents(ctx context.Context, scope *feedScope) ([]*FeedEvent, error) {
+	query := s.db.WithContext(ctx).Preload("User").Where("repository_id IN ?", scope.repoIDs())
+	if scope.actor != nil {
+		query = query.Where("user_id = ?", scope.actor.ID)
+	}
+	var stars []models.Star
+	if err := query.Order("created_at DESC").Limit(scope.limit).Find(&stars).Error; err != nil {
+		return nil, fmt.Errorf("failed to load stars: %w", err)
+	}
+
+	events := make([]*FeedEvent, 0, len(stars))
+	for _, star := range stars {
+		event := scope.event(star.ID, FeedEventWatch, star.RepositoryID, star.CreatedAt, map[string]interface{}{"action": "started"})
+		event.Actor = &FeedActor{ID: star.User.ID, Login: star.User.Username, AvatarURL: star.User.AvatarURL}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// releaseEvents are tags; their actor is the user whose email tagged them
+func (s *activityFeedService) releaseEvents(ctx context.Context, scope *feedScope) ([]*FeedEvent, error) {
+	query := s.db.WithContext(ctx).Where("repository_id IN ?", scope.repoIDs())
+	if scope.actor != nil {
+		if scope.actor.Email == "" {
+			return nil, nil
+		}
+		query = query.Where("LOWER(tagger_email) = ?", strings.ToLower(scope.actor.Email))
+	}
+	var tags []models.Tag
+	if err := query.Order("created_at DESC").Limit(scope.limit).Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to load releases: %w", err)
+	}
+
+	var emails []string
+	for _, tag := range tags {
+		if tag.TaggerEmail != "" {
+			emails = append(emails, strings.ToLower(tag.TaggerEmail))
+		}
+	}
+	taggers := make(map[string]*FeedActor)
+	if len(emails) > 0 {
+		var users []models.User
+		if err := s.db.WithContext(ctx).Where("LOWER(email) IN ?", emails).Find(&users).Error; err != nil {
+			return nil, fmt.Errorf("failed to load taggers: %w", err)
+		}
+		for _, user := range users {
+			taggers[strings.ToLower(user.Email)] = &FeedActor{ID: user.ID, Login: user.Username, AvatarURL: user.AvatarURL}
+		}
+	}
+
+	events := make([]*FeedEvent, 0, len(tags))
+	for _, tag := range tags {
+		event := scope.event(tag.ID, FeedEventRelease, tag.RepositoryID, tag.CreatedAt, map[string]interface{}{
+			"action": "published",
+			"release": map[string]interface{}{
+				"tag_name": tag.Name,
+				"sha":      tag.SHA,
+				"body":     tag.Message,
+			},
+		})
+		event.Actor = taggers[strings.ToLower(tag.TaggerEmail)]
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// createEvents are new repositories, performed by their owner
+func (s *activityFeedService) createEvents(ctx context.Context, scope *feedScope) ([]*FeedEvent, error) {
+	var repos []*models.Repository
+	for _, repo := range scope.repos {
+		if scope.actor != nil && (repo.OwnerType != models.OwnerTypeUser || repo.OwnerID != scope.actor.ID) {
+			continue
+		}
+		repos = append(repos, repo)
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].CreatedAt.After(repos[j].CreatedAt) })
+	if len(repos) > scope.limit {
+		repos = repos[:scope.limit]
+	}
+
+	events := make([]*FeedEvent, 0, len(repos))
+	for _, repo := range repos {
+		event := scope.event(repo.ID, FeedEventCreate, repo.ID, repo.CreatedAt, map[string]interface{}{
+			"ref_type":    "repository",
+			"description": repo.Description,
+		})
+		if repo.OwnerType == models.OwnerTypeUser {
+			event.Actor = scope.owners[repo.OwnerID]
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivityFeedService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.Repository{},
+		&models.Star{}, &models.Tag{}, &models.AnalyticsEvent{})
+	ctx := context.Background()
+	svc := NewActivityFeedService(db, nil, logrus.New())
+	base := time.Now().Add(-time.Hour)
+
+	alice := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}
+	bob := &models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com"}
+	require.NoError(t, db.Create(alice).Error)
+	require.NoError(t, db.Create(bob).Error)
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+
+	newRepo := func(name string, ownerID uuid.UUID, ownerType models.OwnerType, visibility models.Visibility, minute int) *models.Repository {
+		repo := &models.Repository{ID: uuid.New(), OwnerID: ownerID, OwnerType: ownerType, Name: name,
+			DefaultBranch: "main", Visibility: visibility, CreatedAt: base.Add(time.Duration(minute) * time.Minute)}
+		require.NoError(t, db.Create(repo).Error)
+		return repo
+	}
+	tools := newRepo("tools", alice.ID, models.OwnerTypeUser, models.VisibilityPublic, 0)
+	secret := newRepo("secret", org.ID, models.OwnerTypeOrganization, models.VisibilityPrivate, 1)
+	site := newRepo("site", org.ID, models.OwnerTypeOrganization, models.VisibilityPublic, 2)
+
+	push := func(repo *models.Repository, actor *models.User, minute int) {
+		group := PushGroup{PushID: uuid.New(), RepositoryID: repo.ID, PusherID: &actor.ID, RefCount: 1,
+			Branches: []RefUpdate{{Ref: "refs/heads/main", NewSHA: "abc"}}}
+		metadata, err := json.Marshal(group)
+		require.NoError(t, err)
+		orgID := repo.OwnerID
+		event := &models.AnalyticsEvent{ID: uuid.New(), EventType: models.EventRepositoryPush, ActorID: &actor.ID, ActorType: "user",
+			RepositoryID: &repo.ID, Metadata: string(metadata), CreatedAt: base.Add(time.Duration(minute) * time.Minute)}
+		if repo.OwnerType == models.OwnerTypeOrganization {
+			event.OrganizationID = &orgID
+		}
+		require.NoError(t, db.Create(event).Error)
+	}
+	push(tools, alice, 10)
+	push(site, alice, 11)
+	push(secret, alice, 12)
+	push(site, bob, 13)
+	require.NoError(t, db.Create(&models.Star{ID: uuid.New(), UserID: alice.ID, RepositoryID: site.ID, CreatedAt: base.Add(14 * time.Minute)}).Error)
+	require.NoError(t, db.Create(&models.Tag{ID: uuid.New(), RepositoryID: site.ID, Name: "v1.0.0", SHA: "abc",
+		TaggerName: "Alice", TaggerEmail: "Alice@example.com", CreatedAt: base.Add(15 * time.Minute)}).Error)
+
+	types := func(events []*FeedEvent) []string {
+		var out []string
+		for _, event := range events {
+			out = append(out, event.Type+" "+event.Repo.Name)
+		}
+		return out
+	}
+
+	t.Run("user timeline hides private repositories", func(t *testing.T) {
+		events, hasMore, err := svc.UserEvents(ctx, "alice", FeedOptions{})
+		require.NoError(t, err)
+		assert.False(t, hasMore)
+		assert.Equal(t, []string{
+			"ReleaseEvent acme/site",
+			"WatchEvent acme/site",
+			"PushEvent acme/site",
+			"PushEvent alice/tools",
+			"CreateEvent alice/tools",
+		}, types(events))
+		assert.Equal(t, "alice", events[0].Actor.Login)
+		assert.Equal(t, "acme", events[0].Org.Login)
+		assert.True(t, events[0].Public)
+	})
+
+	t.Run("admins see private repositories", func(t *testing.T) {
+		adminCtx := tenant.NewContext(ctx, &tenant.Context{UserID: &bob.ID, IsAdmin: true})
+		events, _, err := svc.UserEvents(adminCtx, "alice", FeedOptions{Types: []string{FeedEventPush}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"PushEvent acme/secret", "PushEvent acme/site", "PushEvent alice/tools"}, types(events))
+		assert.False(t, events[0].Public)
+	})
+
+	t.Run("organization timeline pages events", func(t *testing.T) {
+		events, hasMore, err := svc.OrganizationEvents(ctx, "acme", FeedOptions{Page: 1, PerPage: 2})
+		require.NoError(t, err)
+		assert.True(t, hasMore)
+		assert.Equal(t, []string{"ReleaseEvent acme/site", "WatchEvent acme/site"}, types(events))
+
+		events, hasMore, err = svc.OrganizationEvents(ctx, "acme", FeedOptions{Page: 2, PerPage: 2})
+		require.NoError(t, err)
+		assert.True(t, hasMore)
+		assert.Equal(t, []string{"PushEvent acme/site", "PushEvent acme/site"}, types(events))
+		assert.Equal(t, "bob", events[0].Actor.Login)
+
+		events, hasMore, err = svc.OrganizationEvents(ctx, "acme", FeedOptions{Page: 3, PerPage: 2})
+		require.NoError(t, err)
+		assert.False(t, hasMore)
+		assert.Equal(t, []string{"CreateEvent acme/site"}, types(events))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, _, err := svc.UserEvents(ctx, "nobody", FeedOptions{})
+		assert.ErrorIs(t, err, ErrFeedNotFound)
+		_, _, err = svc.OrganizationEvents(ctx, "acme", FeedOptions{Types: []string{"ForkEvent"}})
+		assert.ErrorIs(t, err, ErrInvalidFeedEventType)
+	})
+}
@@ -0,0 +1,360 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// JobAggregateMetrics is the queued job kind QueueAggregation enqueues
+const JobAggregateMetrics = "analytics.aggregate_metrics"
+
+// aggregationCatchUpDays bounds how many missed days one AggregateMetrics
+// run recomputes, so an instance that was down for long catches up in
+// several runs rather than one very long one
+const aggregationCatchUpDays = 7
+
+// aggregationBatchSize is the number of repositories snapshotted per query
+const aggregationBatchSize = 100
+
+// rolledUpMetrics are the hourly request metrics rolled into daily ones
+var rolledUpMetrics = []string{"api_response_time", "api_request_count", "api_error_count"}
+
+func (s *analyticsService) UseQueue(queue *jobs.Queue) {
+	s.queue = queue
+	queue.Register(JobAggregateMetrics, jobs.RetryPolicy{}, func(ctx context.Context, payload json.RawMessage) error {
+		var args struct {
+			Period Period `json:"period"`
+		}
+		if err := json.Unmarshal(payload, &args); err != nil {
+			return jobs.Permanent(err)
+		}
+		return s.AggregateMetrics(ctx, args.Period)
+	})
+}
+
+func (s *analyticsService) QueueAggregation(ctx context.Context, period Period) error {
+	if period != PeriodDaily {
+		return fmt.Errorf("unsupported aggregation period: %s", period)
+	}
+	if s.queue == nil {
+		return s.AggregateMetrics(ctx, period)
+	}
+	// A run still waiting covers this request too
+	payload := map[string]Period{"period": period}
+	if _, err := s.queue.Enqueue(ctx, JobAggregateMetrics, payload, jobs.EnqueueOptions{DedupeKey: string(period)}); err != nil {
+		return fmt.Errorf("failed to enqueue metrics aggregation: %w", err)
+	}
+	return nil
+}
+
+// AggregateMetrics computes the daily system and repository snapshots and
+// rolls up the hourly request metrics. The last aggregated day is redone,
+// as it may have been aggregated before it was over, followed by every
+// day since up to and including today.
+func (s *analyticsService) AggregateMetrics(ctx context.Context, period Period) error {
+	if period != PeriodDaily {
+		return fmt.Errorf("unsupported aggregation period: %s", period)
+	}
+
+	today := analyticsDay(s.now())
+	start := today.AddDate(0, 0, -1)
+	var last models.SystemAnalytics
+	err := s.db.WithContext(ctx).Order("date DESC").First(&last).Error
+	switch {
+	case err == nil:
+		start = analyticsDay(last.Date)
+	case err != gorm.ErrRecordNotFound:
+		return fmt.Errorf("failed to find last aggregated day: %w", err)
+	}
+	if oldest := today.AddDate(0, 0, -aggregationCatchUpDays); start.Before(oldest) {
+		start = oldest
+	}
+
+	for day := start; !day.After(today); day = day.AddDate(0, 0, 1) {
+		if err := s.aggregateDay(ctx, day); err != nil {
+			return fmt.Errorf("failed to aggregate %s: %w", day.Format("2006-01-02"), err)
+		}
+	}
+	return nil
+}
+
+func (s *analyticsService) aggregateDay(ctx context.Context, day time.Time) error {
+	if err := s.rollUpMetrics(ctx, day); err != nil {
+		return err
+	}
+	if err := s.UpdateSystemAnalytics(ctx, day); err != nil {
+		return err
+	}
+
+	var repos []models.Repository
+	return s.db.WithContext(ctx).Select("id").FindInBatches(&repos, aggregationBatchSize, func(tx *gorm.DB, batch int) error {
+		for _, repo := range repos {
+			if err := s.UpdateRepositoryAnalytics(ctx, repo.ID, day); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// UpdateSystemAnalytics replaces the platform snapshot of date
+func (s *analyticsService) UpdateSystemAnalytics(ctx context.Context, date time.Time) error {
+	day := analyticsDay(date)
+	end := day.AddDate(0, 0, 1)
+	db := s.db.WithContext(ctx)
+
+	snapshot := &models.SystemAnalytics{ID: uuid.New(), Date: day}
+	counts := []struct {
+		query *gorm.DB
+		dest  *int64
+	}{
+		{db.Model(&models.User{}).Where("created_at < ?", end), &snapshot.TotalUsers},
+		{db.Model(&models.User{}).Where("created_at >= ? AND created_at < ?", day, end), &snapshot.NewRegistrations},
+		{db.Model(&models.Organization{}).Where("created_at < ?", end), &snapshot.TotalOrganizations},
+		{db.Model(&models.Repository{}).Where("created_at < ?", end), &snapshot.TotalRepositories},
+		{db.Model(&models.AnalyticsEvent{}).Where("actor_id IS NOT NULL AND created_at >= ? AND created_at < ?", day, end).
+			Distinct("actor_id"), &snapshot.ActiveUsers},
+	}
+	for _, c := range counts {
+		if err := c.query.Count(c.dest).Error; err != nil {
+			return fmt.Errorf("failed to count system analytics: %w", err)
+		}
+	}
+
+	if previous := snapshot.TotalUsers - snapshot.NewRegistrations; previous > 0 {
+		growth := float64(snapshot.NewRegistrations) / float64(previous) * 100
+		snapshot.GrowthRate = &growth
+	}
+
+	var requests struct {
+		Count int64
+		Sum   float64
+	}
+	if err := db.Model(&models.AnalyticsMetric{}).Select("COUNT(*) AS count, COALESCE(SUM(value), 0) AS sum").
+		Where("name = ? AND period = ? AND timestamp >= ? AND timestamp < ?", "api_response_time", string(PeriodHourly), day, end).
+		Scan(&requests).Error; err != nil {
+		return fmt.Errorf("failed to aggregate response times: %w", err)
+	}
+	if requests.Count > 0 {
+		average := requests.Sum / float64(requests.Count)
+		snapshot.AverageResponseTime = &average
+		p95, err := s.metricPercentile(ctx, "api_response_time", day, end, requests.Count, 95)
+		if err != nil {
+			return err
+		}
+		snapshot.P95ResponseTime = &p95
+
+		var failures int64
+		if err := db.Model(&models.AnalyticsMetric{}).
+			Where("name = ? AND period = ? AND timestamp >= ? AND timestamp < ?", "api_error_count", string(PeriodHourly), day, end).
+			Count(&failures).Error; err != nil {
+			return fmt.Errorf("failed to count errors: %w", err)
+		}
+		rate := float64(failures) / float64(requests.Count) * 100
+		snapshot.ErrorRate = &rate
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("date = ?", day).Delete(&models.SystemAnalytics{}).Error; err != nil {
+			return fmt.Errorf("failed to replace system analytics: %w", err)
+		}
+		if err := tx.Create(snapshot).Error; err != nil {
+			return fmt.Errorf("failed to save system analytics: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateRepositoryAnalytics replaces the snapshot of repoID for date.
+// Counts are running totals as of the end of the day; the code statistics
+// come from the cached repository statistics.
+func (s *analyticsService) UpdateRepositoryAnalytics(ctx context.Context, repoID uuid.UUID, date time.Time) error {
+	day := analyticsDay(date)
+	end := day.AddDate(0, 0, 1)
+	db := s.db.WithContext(ctx)
+
+	var repo models.Repository
+	if err := db.Where("id = ?", repoID).First(&repo).Error; err != nil {
+		return fmt.Errorf("repository not found: %w", err)
+	}
+	snapshot := &models.RepositoryAnalytics{
+		ID:            uuid.New(),
+		RepositoryID:  repoID,
+		Date:          day,
+		StarsCount:    int64(repo.StarsCount),
+		ForksCount:    int64(repo.ForksCount),
+		WatchersCount: int64(repo.WatchersCount),
+		LanguageStats: "{}",
+	}
+
+	var stats models.RepositoryStatistics
+	err := db.Where("repository_id = ?", repoID).First(&stats).Error
+	switch {
+	case err == nil:
+		snapshot.CommitCount = int64(stats.CommitCount)
+		snapshot.BranchCount = int64(stats.BranchCount)
+		snapshot.ContributorCount = int64(stats.Contributors)
+	case err != gorm.ErrRecordNotFound:
+		return fmt.Errorf("failed to get repository statistics: %w", err)
+	}
+
+	var languages []models.RepositoryLanguage
+	if err := db.Where("repository_id = ?", repoID).Find(&languages).Error; err != nil {
+		return fmt.Errorf("failed to get repository languages: %w", err)
+	}
+	if len(languages) > 0 {
+		breakdown := make(map[string]int64, len(languages))
+		for _, l := range languages {
+			breakdown[l.Language] = l.Bytes
+		}
+		raw, err := json.Marshal(breakdown)
+		if err != nil {
+			return fmt.Errorf("failed to encode language stats: %w", err)
+		}
+		snapshot.LanguageStats = string(raw)
+	}
+
+	counts := []struct {
+		query *gorm.DB
+		dest  *int64
+	}{
+		{db.Model(&models.AnalyticsEvent{}).Where("repository_id = ? AND event_type = ? AND created_at < ?", repoID, models.EventPageView, end), &snapshot.ViewsCount},
+		{db.Model(&models.AnalyticsEvent{}).Where("repository_id = ? AND event_type = ? AND created_at < ?", repoID, models.EventRepositoryClone, end), &snapshot.ClonesCount},
+		{db.Model(&models.PullRequest{}).Where("repository_id = ? AND created_at < ?", repoID, end), &snapshot.PullRequestsOpened},
+		{db.Model(&models.PullRequest{}).Where("repository_id = ? AND merged = ? AND closed_at < ?", repoID, false, end), &snapshot.PullRequestsClosed},
+		{db.Model(&models.PullRequest{}).Where("repository_id = ? AND merged_at < ?", repoID, end), &snapshot.PullRequestsMerged},
+	}
+	for _, c := range counts {
+		if err := c.query.Count(c.dest).Error; err != nil {
+			return fmt.Errorf("failed to count repository analytics: %w", err)
+		}
+	}
+
+	// The merge time averages the pull requests merged on the day
+	var merged []models.PullRequest
+	if err := db.Select("created_at, merged_at").
+		Where("repository_id = ? AND merged_at >= ? AND merged_at < ?", repoID, day, end).Find(&merged).Error; err != nil {
+		return fmt.Errorf("failed to get merged pull requests: %w", err)
+	}
+	if len(merged) > 0 {
+		var hours float64
+		for _, pr := range merged {
+			hours += pr.MergedAt.Sub(pr.CreatedAt).Hours()
+		}
+		average := hours / float64(len(merged))
+		snapshot.AveragePRMergeTime = &average
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("repository_id = ? AND date = ?", repoID, day).Delete(&models.RepositoryAnalytics{}).Error; err != nil {
+			return fmt.Errorf("failed to replace repository analytics: %w", err)
+		}
+		if err := tx.Create(snapshot).Error; err != nil {
+			return fmt.Errorf("failed to save repository analytics: %w", err)
+		}
+		return nil
+	})
+}
+
+// rollUpMetrics replaces the platform-wide daily rollups of the hourly
+// request metrics recorded on day
+func (s *analyticsService) rollUpMetrics(ctx context.Context, day time.Time) error {
+	end := day.AddDate(0, 0, 1)
+	db := s.db.WithContext(ctx)
+
+	var groups []struct {
+		Name       string
+		MetricType models.MetricType
+		Count      int64
+		Sum        float64
+		Min        float64
+		Max        float64
+	}
+	if err := db.Model(&models.AnalyticsMetric{}).
+		Select("name, metric_type, COUNT(*) AS count, SUM(value) AS sum, MIN(value) AS min, MAX(value) AS max").
+		Where("name IN ? AND period = ? AND timestamp >= ? AND timestamp < ?", rolledUpMetrics, string(PeriodHourly), day, end).
+		Group("name, metric_type").Scan(&groups).Error; err != nil {
+		return fmt.Errorf("failed to roll up metrics: %w", err)
+	}
+
+	rollups := make([]*models.AnalyticsMetric, 0, len(groups))
+	for _, g := range groups {
+		count, sum, minimum, maximum := g.Count, g.Sum, g.Min, g.Max
+		average := sum / float64(count)
+		rollup := &models.AnalyticsMetric{
+			ID:         uuid.New(),
+			Name:       g.Name,
+			MetricType: g.MetricType,
+			Value:      sum,
+			Timestamp:  day,
+			Period:     string(PeriodDaily),
+			Tags:       "{}",
+			Count:      &count,
+			Sum:        &sum,
+			Min:        &minimum,
+			Max:        &maximum,
+			Average:    &average,
+		}
+		if g.MetricType == models.MetricTypeHistogram {
+			rollup.Value = average
+			for _, p := range []struct {
+				percent float64
+				dest    **float64
+			}{{50, &rollup.Percentile50}, {95, &rollup.Percentile95}, {99, &rollup.Percentile99}} {
+				value, err := s.metricPercentile(ctx, g.Name, day, end, count, p.percent)
+				if err != nil {
+					return err
+				}
+				*p.dest = &value
+			}
+		}
+		rollups = append(rollups, rollup)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("name IN ? AND period = ? AND timestamp = ? AND repository_id IS NULL AND organization_id IS NULL AND user_id IS NULL",
+			rolledUpMetrics, string(PeriodDaily), day).Delete(&models.AnalyticsMetric{}).Error; err != nil {
+			return fmt.Errorf("failed to replace metric rollups: %w", err)
+		}
+		if len(rollups) == 0 {
+			return nil
+		}
+		if err := tx.Create(&rollups).Error; err != nil {
+			return fmt.Errorf("failed to save metric rollups: %w", err)
+		}
+		return nil
+	})
+}
+
+// metricPercentile returns the nearest-rank percentile of the count hourly
+// values of name recorded in [start, end)
+func (s *analyticsService) metricPercentile(ctx context.Context, name string, start, end time.Time, count int64, percent float64) (float64, error) {
+	rank := int(math.Ceil(float64(count)*percent/100)) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	var values []float64
+	if err := s.db.WithContext(ctx).Model(&models.AnalyticsMetric{}).
+		Where("name = ? AND period = ? AND timestamp >= ? AND timestamp < ?", name, string(PeriodHourly), start, end).
+		Order("value").Offset(rank).Limit(1).Pluck("value", &values).Error; err != nil {
+		return 0, fmt.Errorf("failed to compute %s percentile: %w", name, err)
+	}
+	if len(values) == 0 {
+		return 0, nil
+	}
+	return values[0], nil
+}
+
+// analyticsDay truncates t to the start of its UTC day
+func analyticsDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyticsAggregateMetrics(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.Repository{}, &models.PullRequest{},
+		&models.RepositoryStatistics{}, &models.RepositoryLanguage{}, &models.AnalyticsEvent{}, &models.AnalyticsMetric{},
+		&models.SystemAnalytics{}, &models.RepositoryAnalytics{})
+	ctx := context.Background()
+	now := time.Date(2024, 6, 3, 15, 0, 0, 0, time.UTC)
+	svc := NewAnalyticsService(db, nil, logrus.New()).(*analyticsService)
+	svc.now = func() time.Time { return now }
+
+	yesterday := time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC)
+	alice := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", CreatedAt: yesterday.AddDate(0, 0, -30)}
+	bob := &models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com", CreatedAt: yesterday.Add(9 * time.Hour)}
+	require.NoError(t, db.Create([]*models.User{alice, bob}).Error)
+	repo := &models.Repository{ID: uuid.New(), OwnerID: alice.ID, OwnerType: models.OwnerTypeUser, Name: "app",
+		DefaultBranch: "main", Visibility: models.VisibilityPublic, StarsCount: 4, CreatedAt: yesterday.AddDate(0, 0, -10)}
+	require.NoError(t, db.Create(repo).Error)
+	require.NoError(t, db.Create(&models.RepositoryStatistics{ID: uuid.New(), RepositoryID: repo.ID, CommitCount: 12, Contributors: 2}).Error)
+	require.NoError(t, db.Create(&models.RepositoryLanguage{ID: uuid.New(), RepositoryID: repo.ID, Language: "Go", Bytes: 2048}).Error)
+
+	mergedAt := yesterday.Add(14 * time.Hour)
+	require.NoError(t, db.Create(&models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 1,
+		Title: "Fix", State: models.PullRequestStateMerged, Merged: true, MergedAt: &mergedAt, ClosedAt: &mergedAt,
+		CreatedAt: yesterday.Add(8 * time.Hour)}).Error)
+	require.NoError(t, db.Create([]*models.AnalyticsEvent{
+		{ID: uuid.New(), EventType: models.EventPageView, ActorID: &alice.ID, RepositoryID: &repo.ID, CreatedAt: yesterday.Add(time.Hour)},
+		{ID: uuid.New(), EventType: models.EventRepositoryClone, ActorID: &bob.ID, RepositoryID: &repo.ID, CreatedAt: yesterday.Add(2 * time.Hour)},
+		{ID: uuid.New(), EventType: models.EventPageView, ActorID: &bob.ID, RepositoryID: &repo.ID, CreatedAt: now.Add(-time.Hour)},
+	}).Error)
+
+	hourly := func(name string, metricType models.MetricType, value float64, at time.Time) *models.AnalyticsMetric {
+		return &models.AnalyticsMetric{ID: uuid.New(), Name: name, MetricType: metricType, Value: value, Timestamp: at, Period: "hourly", Tags: "{}"}
+	}
+	var metrics []*models.AnalyticsMetric
+	for i := 1; i <= 20; i++ {
+		at := yesterday.Add(time.Duration(i) * time.Minute)
+		metrics = append(metrics, hourly("api_response_time", models.MetricTypeHistogram, float64(i*10), at),
+			hourly("api_request_count", models.MetricTypeCounter, 1, at))
+	}
+	metrics = append(metrics, hourly("api_error_count", models.MetricTypeCounter, 1, yesterday.Add(time.Minute)))
+	require.NoError(t, db.Create(metrics).Error)
+
+	assert.Error(t, svc.AggregateMetrics(ctx, PeriodWeekly))
+	require.NoError(t, svc.AggregateMetrics(ctx, PeriodDaily))
+	// Rerunning replaces today's snapshots instead of duplicating them
+	require.NoError(t, svc.AggregateMetrics(ctx, PeriodDaily))
+
+	var days []models.SystemAnalytics
+	require.NoError(t, db.Order("date").Find(&days).Error)
+	require.Len(t, days, 2, "yesterday and today")
+	assert.Equal(t, int64(2), days[0].TotalUsers)
+	assert.Equal(t, int64(1), days[0].NewRegistrations)
+	assert.Equal(t, int64(2), days[0].ActiveUsers)
+	assert.InDelta(t, 100, *days[0].GrowthRate, 0.001)
+	assert.InDelta(t, 105, *days[0].AverageResponseTime, 0.001)
+	assert.InDelta(t, 190, *days[0].P95ResponseTime, 0.001)
+	assert.InDelta(t, 5, *days[0].ErrorRate, 0.001)
+	assert.Equal(t, int64(1), days[1].ActiveUsers)
+	assert.Nil(t, days[1].AverageResponseTime)
+
+	var snapshots []models.RepositoryAnalytics
+	require.NoError(t, db.Order("date").Find(&snapshots).Error)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, int64(1), snapshots[0].ViewsCount)
+	assert.Equal(t, int64(1), snapshots[0].ClonesCount)
+	assert.Equal(t, int64(2), snapshots[1].ViewsCount, "counts are running totals")
+	assert.Equal(t, int64(4), snapshots[0].StarsCount)
+	assert.Equal(t, int64(12), snapshots[0].CommitCount)
+	assert.Equal(t, int64(1), snapshots[0].PullRequestsMerged)
+	assert.Equal(t, int64(0), snapshots[0].PullRequestsClosed)
+	assert.InDelta(t, 6, *snapshots[0].AveragePRMergeTime, 0.001)
+	assert.JSONEq(t, `{"Go":2048}`, snapshots[0].LanguageStats)
+
+	var rollups []models.AnalyticsMetric
+	require.NoError(t, db.Where("period = ?", "daily").Order("name").Find(&rollups).Error)
+	require.Len(t, rollups, 3)
+	assert.Equal(t, "api_error_count", rollups[0].Name)
+	assert.Equal(t, float64(20), rollups[1].Value, "counters sum their hourly values")
+	latency := rollups[2]
+	assert.Equal(t, "api_response_time", latency.Name)
+	assert.Equal(t, int64(20), *latency.Count)
+	assert.Equal(t, float64(105), latency.Value)
+	assert.Equal(t, float64(100), *latency.Percentile50)
+	assert.Equal(t, float64(200), *latency.Percentile99)
+}
+
+func TestAnalyticsQueueAggregation(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.QueuedJob{})
+	svc := NewAnalyticsService(db, nil, logrus.New())
+	svc.UseQueue(jobs.NewQueue(db, nil, time.Second, logrus.New()))
+	ctx := context.Background()
+
+	assert.Error(t, svc.QueueAggregation(ctx, PeriodWeekly))
+	// Requests made while a run is pending share it
+	require.NoError(t, svc.QueueAggregation(ctx, PeriodDaily))
+	require.NoError(t, svc.QueueAggregation(ctx, PeriodDaily))
+	var queued []models.QueuedJob
+	require.NoError(t, db.Find(&queued).Error)
+	require.Len(t, queued, 1)
+	assert.Equal(t, JobAggregateMetrics, queued[0].Kind)
+	assert.JSONEq(t, `{"period":"daily"}`, queued[0].Payload)
+}
@@ -0,0 +1,579 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maxArchivedDayMonths bounds how many archived months a daily series reads
+// back from object storage; longer ranges use the monthly rollups
+const maxArchivedDayMonths = 3
+
+// analyticsArchiveColumns are the columns of an archived month, in order
+var analyticsArchiveColumns = []string{
+	"id", "date", "created_at",
+	"lines_of_code", "file_count", "commit_count", "branch_count", "contributor_count",
+	"views_count", "clones_count", "forks_count", "stars_count", "watchers_count",
+	"pull_requests_opened", "pull_requests_closed", "pull_requests_merged",
+	"average_pr_merge_time", "build_success_rate", "language_stats",
+}
+
+// AnalyticsArchiveService compacts daily repository analytics snapshots
+// past the compaction age into monthly rollups, offloading the raw rows to
+// object storage, and reads snapshots across the hot and archived ranges
+type AnalyticsArchiveService interface {
+	Policy() config.AnalyticsArchive
+	Compact(ctx context.Context) (*AnalyticsCompaction, error)
+	// RepositoryAnalytics returns the snapshots of a repository in the
+	// filter range, oldest first. Archived months appear as one snapshot
+	// dated the first of the month, or as their daily rows for daily series
+	// spanning at most three archived months.
+	RepositoryAnalytics(ctx context.Context, repoID uuid.UUID, filters InsightFilters) ([]*models.RepositoryAnalytics, error)
+	// LatestRepositoryAnalytics returns the most recent snapshot of a
+	// repository, or nil when it has none
+	LatestRepositoryAnalytics(ctx context.Context, repoID uuid.UUID) (*models.RepositoryAnalytics, error)
+	ArchivedDays(ctx context.Context, repoID uuid.UUID, month time.Time) ([]*models.RepositoryAnalytics, error)
+	StartScheduler(ctx context.Context)
+}
+
+// AnalyticsCompaction summarizes a compaction run
+type AnalyticsCompaction struct {
+	Cutoff       time.Time `json:"cutoff"`
+	Repositories int       `json:"repositories"`
+	Months       int       `json:"months"`
+	RowsArchived int64     `json:"rows_archived"`
+}
+
+type analyticsArchiveService struct {
+	db      *gorm.DB
+	backend storage.Backend
+	cfg     config.AnalyticsArchive
+	logger  *logrus.Logger
+	now     func() time.Time
+}
+
+// NewAnalyticsArchiveService creates a new AnalyticsArchiveService storing
+// archived rows in backend
+func NewAnalyticsArchiveService(db *gorm.DB, backend storage.Backend, cfg config.AnalyticsArchive, logger *logrus.Logger) AnalyticsArchiveService {
+	return &analyticsArchiveService{db: db, backend: backend, cfg: cfg, logger: logger, now: time.Now}
+}
+
+func (s *analyticsArchiveService) Policy() config.AnalyticsArchive {
+	return s.cfg
+}
+
+// monthStart returns the first day of t's month in UTC
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// analyticsArchiveKey is the storage key of a repository's archived month
+func analyticsArchiveKey(repoID uuid.UUID, month time.Time) string {
+	return fmt.Sprintf("repository-analytics/%s/%s.csv.gz", repoID, month.Format("2006-01"))
+}
+
+// Compact rolls up every whole month older than CompactAfterMonths. Months
+// compacted before are merged with snapshots written for them since, so
+// runs can be repeated. A compact age of zero keeps all snapshots daily.
+func (s *analyticsArchiveService) Compact(ctx context.Context) (*AnalyticsCompaction, error) {
+	result := &AnalyticsCompaction{}
+	if s.cfg.CompactAfterMonths <= 0 {
+		return result, nil
+	}
+	result.Cutoff = monthStart(s.now()).AddDate(0, -s.cfg.CompactAfterMonths, 0)
+
+	var repoIDs []uuid.UUID
+	if err := s.db.WithContext(ctx).Model(&models.RepositoryAnalytics{}).
+		Where("date < ?", result.Cutoff).Distinct("repository_id").
+		Pluck("repository_id", &repoIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list repositories to compact: %w", err)
+	}
+
+	for _, repoID := range repoIDs {
+		months, rows, err := s.compactRepository(ctx, repoID, result.Cutoff)
+		result.Months += months
+		result.RowsArchived += rows
+		if err != nil {
+			return result, err
+		}
+		result.Repositories++
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"cutoff":        result.Cutoff,
+		"repositories":  result.Repositories,
+		"months":        result.Months,
+		"rows_archived": result.RowsArchived,
+	}).Info("Compacted repository analytics snapshots")
+	return result, nil
+}
+
+func (s *analyticsArchiveService) compactRepository(ctx context.Context, repoID uuid.UUID, cutoff time.Time) (int, int64, error) {
+	var rows []*models.RepositoryAnalytics
+	if err := s.db.WithContext(ctx).Where("repository_id = ? AND date < ?", repoID, cutoff).
+		Order("date ASC").Find(&rows).Error; err != nil {
+		return 0, 0, fmt.Errorf("failed to load analytics snapshots: %w", err)
+	}
+
+	byMonth := make(map[time.Time][]*models.RepositoryAnalytics)
+	var months []time.Time
+	for _, row := range rows {
+		month := monthStart(row.Date)
+		if _, ok := byMonth[month]; !ok {
+			months = append(months, month)
+		}
+		byMonth[month] = append(byMonth[month], row)
+	}
+
+	var archived int64
+	for i, month := range months {
+		if err := s.compactMonth(ctx, repoID, month, byMonth[month]); err != nil {
+			return i, archived, err
+		}
+		archived += int64(len(byMonth[month]))
+	}
+	return len(months), archived, nil
+}
+
+// compactMonth archives rows, the month's daily snapshots still in the
+// database, and replaces them with the month's rollup. The archive is
+// written before the rows are deleted so a failure never loses data.
+func (s *analyticsArchiveService) compactMonth(ctx context.Context, repoID uuid.UUID, month time.Time, rows []*models.RepositoryAnalytics) error {
+	db := s.db.WithContext(ctx)
+
+	var existing models.RepositoryAnalyticsMonthly
+	err := db.Where("repository_id = ? AND month = ?", repoID, month).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to load monthly analytics: %w", err)
+	}
+	found := err == nil
+
+	all := rows
+	if found {
+		archived, err := s.readArchive(ctx, existing.ArchiveKey)
+		if err != nil {
+			return err
+		}
+		all = mergeSnapshots(archived, rows)
+	}
+
+	key := analyticsArchiveKey(repoID, month)
+	if err := s.writeArchive(ctx, key, all); err != nil {
+		return err
+	}
+
+	rollup := rollupSnapshots(repoID, month, all)
+	rollup.ArchiveKey = key
+	if found {
+		rollup.ID = existing.ID
+		rollup.CreatedAt = existing.CreatedAt
+	}
+
+	ids := make([]uuid.UUID, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(rollup).Error; err != nil {
+			return fmt.Errorf("failed to save monthly analytics: %w", err)
+		}
+		if err := tx.Unscoped().Where("id IN ?", ids).Delete(&models.RepositoryAnalytics{}).Error; err != nil {
+			return fmt.Errorf("failed to delete compacted snapshots: %w", err)
+		}
+		return nil
+	})
+}
+
+// mergeSnapshots combines archived and fresh snapshots of a month by day,
+// preferring fresh ones, oldest first
+func mergeSnapshots(archived, fresh []*models.RepositoryAnalytics) []*models.RepositoryAnalytics {
+	byDay := make(map[string]*models.RepositoryAnalytics)
+	for _, row := range archived {
+		byDay[row.Date.UTC().Format("2006-01-02")] = row
+	}
+	for _, row := range fresh {
+		byDay[row.Date.UTC().Format("2006-01-02")] = row
+	}
+	merged := make([]*models.RepositoryAnalytics, 0, len(byDay))
+	for _, row := range byDay {
+		merged = append(merged, row)
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].Date.Before(merged[j].Date) })
+	return merged
+}
+
+// rollupSnapshots builds the rollup of a month's snapshots, oldest first
+func rollupSnapshots(repoID uuid.UUID, month time.Time, rows []*models.RepositoryAnalytics) *models.RepositoryAnalyticsMonthly {
+	last := rows[len(rows)-1]
+	rollup := &models.RepositoryAnalyticsMonthly{
+		ID:                 uuid.New(),
+		RepositoryID:       repoID,
+		Month:              month,
+		Days:               len(rows),
+		LinesOfCode:        last.LinesOfCode,
+		FileCount:          last.FileCount,
+		CommitCount:        last.CommitCount,
+		BranchCount:        last.BranchCount,
+		ContributorCount:   last.ContributorCount,
+		ViewsCount:         last.ViewsCount,
+		ClonesCount:        last.ClonesCount,
+		ForksCount:         last.ForksCount,
+		StarsCount:         last.StarsCount,
+		WatchersCount:      last.WatchersCount,
+		PullRequestsOpened: last.PullRequestsOpened,
+		PullRequestsClosed: last.PullRequestsClosed,
+		PullRequestsMerged: last.PullRequestsMerged,
+		LanguageStats:      last.LanguageStats,
+	}
+	rollup.AveragePRMergeTime = averageOf(rows, func(row *models.RepositoryAnalytics) *float64 { return row.AveragePRMergeTime })
+	rollup.BuildSuccessRate = averageOf(rows, func(row *models.RepositoryAnalytics) *float64 { return row.BuildSuccessRate })
+	return rollup
+}
+
+// averageOf averages the values of rows that report one
+func averageOf(rows []*models.RepositoryAnalytics, value func(*models.RepositoryAnalytics) *float64) *float64 {
+	var sum float64
+	var n int
+	for _, row := range rows {
+		if v := value(row); v != nil {
+			sum += *v
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	avg := sum / float64(n)
+	return &avg
+}
+
+// rollupSnapshot presents a monthly rollup as a snapshot dated the first
+// of its month
+func rollupSnapshot(m *models.RepositoryAnalyticsMonthly) *models.RepositoryAnalytics {
+	return &models.RepositoryAnalytics{
+		ID:                 m.ID,
+		CreatedAt:          m.CreatedAt,
+		UpdatedAt:          m.UpdatedAt,
+		RepositoryID:       m.RepositoryID,
+		Date:               m.Month,
+		LinesOfCode:        m.LinesOfCode,
+		FileCount:          m.FileCount,
+		CommitCount:        m.CommitCount,
+		BranchCount:        m.BranchCount,
+		ContributorCount:   m.ContributorCount,
+		ViewsCount:         m.ViewsCount,
+		ClonesCount:        m.ClonesCount,
+		ForksCount:         m.ForksCount,
+		StarsCount:         m.StarsCount,
+		WatchersCount:      m.WatchersCount,
+		PullRequestsOpened: m.PullRequestsOpened,
+		PullRequestsClosed: m.PullRequestsClosed,
+		PullRequestsMerged: m.PullRequestsMerged,
+		AveragePRMergeTime: m.AveragePRMergeTime,
+		BuildSuccessRate:   m.BuildSuccessRate,
+		LanguageStats:      m.LanguageStats,
+	}
+}
+
+func formatOptionalFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func (s *analyticsArchiveService) writeArchive(ctx context.Context, key string, rows []*models.RepositoryAnalytics) error {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := csv.NewWriter(gz)
+	w.Write(analyticsArchiveColumns)
+	for _, row := range rows {
+		w.Write([]string{
+			row.ID.String(),
+			row.Date.UTC().Format("2006-01-02"),
+			row.CreatedAt.UTC().Format(time.RFC3339Nano),
+			strconv.FormatInt(row.LinesOfCode, 10),
+			strconv.FormatInt(row.FileCount, 10),
+			strconv.FormatInt(row.CommitCount, 10),
+			strconv.FormatInt(row.BranchCount, 10),
+			strconv.FormatInt(row.ContributorCount, 10),
+			strconv.FormatInt(row.ViewsCount, 10),
+			strconv.FormatInt(row.ClonesCount, 10),
+			strconv.FormatInt(row.ForksCount, 10),
+			strconv.FormatInt(row.StarsCount, 10),
+			strconv.FormatInt(row.WatchersCount, 10),
+			strconv.FormatInt(row.PullRequestsOpened, 10),
+			strconv.FormatInt(row.PullRequestsClosed, 10),
+			strconv.FormatInt(row.PullRequestsMerged, 10),
+			formatOptionalFloat(row.AveragePRMergeTime),
+			formatOptionalFloat(row.BuildSuccessRate),
+			row.LanguageStats,
+		})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("failed to encode analytics archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to compress analytics archive: %w", err)
+	}
+	if err := s.backend.Upload(ctx, key, bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+		return fmt.Errorf("failed to upload analytics archive: %w", err)
+	}
+	return nil
+}
+
+func (s *analyticsArchiveService) readArchive(ctx context.Context, key string) ([]*models.RepositoryAnalytics, error) {
+	reader, err := s.backend.Download(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download analytics archive: %w", err)
+	}
+	defer reader.Close()
+	gz, err := gzip.NewReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress analytics archive: %w", err)
+	}
+	defer gz.Close()
+
+	r := csv.NewReader(gz)
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read analytics archive: %w", err)
+	}
+	// Columns are looked up by name so archives written with fewer columns
+	// stay readable
+	index := make(map[string]int, len(header))
+	for i, name := range header {
+		index[name] = i
+	}
+
+	var rows []*models.RepositoryAnalytics
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read analytics archive: %w", err)
+		}
+		row, err := parseArchivedSnapshot(record, index)
+		if err != nil {
+			return nil, fmt.Errorf("invalid analytics archive %s: %w", key, err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseArchivedSnapshot(record []string, index map[string]int) (*models.RepositoryAnalytics, error) {
+	field := func(name string) string {
+		if i, ok := index[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	var parseErr error
+	integer := func(name string) int64 {
+		v := field(name)
+		if v == "" {
+			return 0
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil && parseErr == nil {
+			parseErr = fmt.Errorf("column %s: %w", name, err)
+		}
+		return n
+	}
+	optionalFloat := func(name string) *float64 {
+		v := field(name)
+		if v == "" {
+			return nil
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			if parseErr == nil {
+				parseErr = fmt.Errorf("column %s: %w", name, err)
+			}
+			return nil
+		}
+		return &f
+	}
+
+	id, err := uuid.Parse(field("id"))
+	if err != nil {
+		return nil, fmt.Errorf("column id: %w", err)
+	}
+	date, err := time.Parse("2006-01-02", field("date"))
+	if err != nil {
+		return nil, fmt.Errorf("column date: %w", err)
+	}
+	row := &models.RepositoryAnalytics{
+		ID:                 id,
+		Date:               date,
+		LinesOfCode:        integer("lines_of_code"),
+		FileCount:          integer("file_count"),
+		CommitCount:        integer("commit_count"),
+		BranchCount:        integer("branch_count"),
+		ContributorCount:   integer("contributor_count"),
+		ViewsCount:         integer("views_count"),
+		ClonesCount:        integer("clones_count"),
+		ForksCount:         integer("forks_count"),
+		StarsCount:         integer("stars_count"),
+		WatchersCount:      integer("watchers_count"),
+		PullRequestsOpened: integer("pull_requests_opened"),
+		PullRequestsClosed: integer("pull_requests_closed"),
+		PullRequestsMerged: integer("pull_requests_merged"),
+		AveragePRMergeTime: optionalFloat("average_pr_merge_time"),
+		BuildSuccessRate:   optionalFloat("build_success_rate"),
+		LanguageStats:      field("language_stats"),
+	}
+	if createdAt, err := time.Parse(time.RFC3339Nano, field("created_at")); err == nil {
+		row.CreatedAt = createdAt
+	}
+	return row, parseErr
+}
+
+// ArchivedDays returns the daily snapshots of an archived month, oldest first
+func (s *analyticsArchiveService) ArchivedDays(ctx context.Context, repoID uuid.UUID, month time.Time) ([]*models.RepositoryAnalytics, error) {
+	var rollup models.RepositoryAnalyticsMonthly
+	if err := s.db.WithContext(ctx).Where("repository_id = ? AND month = ?", repoID, monthStart(month)).
+		First(&rollup).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []*models.RepositoryAnalytics{}, nil
+		}
+		return nil, fmt.Errorf("failed to load monthly analytics: %w", err)
+	}
+
+	rows, err := s.readArchive(ctx, rollup.ArchiveKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		row.RepositoryID = repoID
+	}
+	return rows, nil
+}
+
+func (s *analyticsArchiveService) RepositoryAnalytics(ctx context.Context, repoID uuid.UUID, filters InsightFilters) ([]*models.RepositoryAnalytics, error) {
+	db := s.db.WithContext(ctx)
+
+	hot := db.Where("repository_id = ?", repoID)
+	cold := db.Where("repository_id = ?", repoID)
+	if filters.StartDate != nil {
+		hot = hot.Where("date >= ?", *filters.StartDate)
+		cold = cold.Where("month >= ?", monthStart(*filters.StartDate))
+	}
+	if filters.EndDate != nil {
+		hot = hot.Where("date <= ?", *filters.EndDate)
+		cold = cold.Where("month <= ?", *filters.EndDate)
+	}
+
+	var rows []*models.RepositoryAnalytics
+	if err := hot.Order("date ASC").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get repository analytics: %w", err)
+	}
+	var rollups []*models.RepositoryAnalyticsMonthly
+	if err := cold.Order("month ASC").Find(&rollups).Error; err != nil {
+		return nil, fmt.Errorf("failed to get monthly repository analytics: %w", err)
+	}
+	if len(rollups) == 0 {
+		return rows, nil
+	}
+
+	daily := filters.Period == PeriodDaily && len(rollups) <= maxArchivedDayMonths
+	var archived []*models.RepositoryAnalytics
+	for _, rollup := range rollups {
+		if daily {
+			days, err := s.readArchive(ctx, rollup.ArchiveKey)
+			if err == nil {
+				for _, day := range days {
+					if (filters.StartDate == nil || !day.Date.Before(*filters.StartDate)) &&
+						(filters.EndDate == nil || !day.Date.After(*filters.EndDate)) {
+						day.RepositoryID = repoID
+						archived = append(archived, day)
+					}
+				}
+				continue
+			}
+			s.logger.WithError(err).WithField("key", rollup.ArchiveKey).Warn("Failed to read archived analytics, using monthly rollup")
+		}
+		archived = append(archived, rollupSnapshot(rollup))
+	}
+
+	stitched := append(archived, rows...)
+	sort.SliceStable(stitched, func(i, j int) bool { return stitched[i].Date.Before(stitched[j].Date) })
+	return stitched, nil
+}
+
+func (s *analyticsArchiveService) LatestRepositoryAnalytics(ctx context.Context, repoID uuid.UUID) (*models.RepositoryAnalytics, error) {
+	db := s.db.WithContext(ctx)
+
+	var latest models.RepositoryAnalytics
+	err := db.Where("repository_id = ?", repoID).Order("date DESC").First(&latest).Error
+	if err == nil {
+		return &latest, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get repository analytics: %w", err)
+	}
+
+	var rollup models.RepositoryAnalyticsMonthly
+	err = db.Where("repository_id = ?", repoID).Order("month DESC").First(&rollup).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get monthly repository analytics: %w", err)
+	}
+	return rollupSnapshot(&rollup), nil
+}
+
+// StartScheduler compacts snapshots every IntervalHours until ctx is
+// cancelled. It returns immediately when compaction is disabled.
+func (s *analyticsArchiveService) StartScheduler(ctx context.Context) {
+	if !s.cfg.Enabled {
+		return
+	}
+	interval := time.Duration(s.cfg.IntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job := jobs.Job{Name: "analytics_compaction", Class: jobs.ClassArchival, Interval: interval}
+			if jobs.DefaultGate().Wait(ctx, job) != nil {
+				return
+			}
+			func() {
+				defer errorreporting.Default().Recover("analytics_archive_scheduler", nil)
+				if _, err := s.Compact(ctx); err != nil {
+					s.logger.WithError(err).Error("Failed to compact repository analytics")
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyticsArchiveService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Repository{}, &models.RepositoryAnalytics{}, &models.RepositoryAnalyticsMonthly{})
+	ctx := context.Background()
+
+	backend, err := storage.NewFilesystemBackend(storage.FilesystemConfig{BasePath: t.TempDir()})
+	require.NoError(t, err)
+	svc := NewAnalyticsArchiveService(db, backend, config.AnalyticsArchive{CompactAfterMonths: 2}, logrus.New()).(*analyticsArchiveService)
+	svc.now = func() time.Time { return time.Date(2026, 6, 15, 12, 0, 0, 0, time.UTC) }
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "app",
+		DefaultBranch: "main", Visibility: models.VisibilityPublic}
+	require.NoError(t, db.Create(repo).Error)
+
+	rate := func(v float64) *float64 { return &v }
+	snapshot := func(date string, stars int64, buildRate *float64) {
+		day, err := time.Parse("2006-01-02", date)
+		require.NoError(t, err)
+		require.NoError(t, db.Create(&models.RepositoryAnalytics{ID: uuid.New(), RepositoryID: repo.ID, Date: day,
+			StarsCount: stars, BuildSuccessRate: buildRate, LanguageStats: `{"Go":100}`}).Error)
+	}
+	snapshot("2026-02-27", 10, rate(80))
+	snapshot("2026-02-28", 12, nil)
+	snapshot("2026-03-01", 13, rate(90))
+	snapshot("2026-03-31", 15, rate(100))
+	snapshot("2026-04-01", 16, nil)
+	snapshot("2026-06-01", 20, nil)
+
+	stars := func(rows []*models.RepositoryAnalytics) []int64 {
+		var out []int64
+		for _, row := range rows {
+			out = append(out, row.StarsCount)
+		}
+		return out
+	}
+
+	t.Run("compacts months before the cutoff", func(t *testing.T) {
+		result, err := svc.Compact(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), result.Cutoff)
+		assert.Equal(t, 1, result.Repositories)
+		assert.Equal(t, 2, result.Months)
+		assert.Equal(t, int64(4), result.RowsArchived)
+
+		var hot int64
+		require.NoError(t, db.Unscoped().Model(&models.RepositoryAnalytics{}).Count(&hot).Error)
+		assert.Equal(t, int64(2), hot)
+
+		var march models.RepositoryAnalyticsMonthly
+		require.NoError(t, db.Where("repository_id = ? AND month = ?", repo.ID, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)).First(&march).Error)
+		assert.Equal(t, 2, march.Days)
+		assert.Equal(t, int64(15), march.StarsCount)
+		require.NotNil(t, march.BuildSuccessRate)
+		assert.Equal(t, 95.0, *march.BuildSuccessRate)
+		assert.Equal(t, `{"Go":100}`, march.LanguageStats)
+	})
+
+	t.Run("archived days round trip", func(t *testing.T) {
+		days, err := svc.ArchivedDays(ctx, repo.ID, time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Equal(t, []int64{10, 12}, stars(days))
+		require.NotNil(t, days[0].BuildSuccessRate)
+		assert.Equal(t, 80.0, *days[0].BuildSuccessRate)
+		assert.Nil(t, days[1].BuildSuccessRate)
+	})
+
+	t.Run("queries stitch rollups and daily snapshots", func(t *testing.T) {
+		rows, err := svc.RepositoryAnalytics(ctx, repo.ID, InsightFilters{})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{12, 15, 16, 20}, stars(rows))
+		assert.Equal(t, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC), rows[0].Date.UTC())
+
+		start := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+		rows, err = svc.RepositoryAnalytics(ctx, repo.ID, InsightFilters{StartDate: &start, Period: PeriodDaily})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{15, 16, 20}, stars(rows))
+
+		latest, err := svc.LatestRepositoryAnalytics(ctx, repo.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int64(20), latest.StarsCount)
+	})
+
+	t.Run("late snapshots merge into compacted months", func(t *testing.T) {
+		snapshot("2026-03-15", 14, nil)
+		result, err := svc.Compact(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, result.Months)
+
+		days, err := svc.ArchivedDays(ctx, repo.ID, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		assert.Equal(t, []int64{13, 14, 15}, stars(days))
+
+		var count int64
+		require.NoError(t, db.Model(&models.RepositoryAnalyticsMonthly{}).Count(&count).Error)
+		assert.Equal(t, int64(2), count)
+	})
+}
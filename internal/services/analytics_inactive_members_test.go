@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyticsService_GetInactiveMembers(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.OrganizationMember{}, &models.Repository{}, &models.Commit{},
+		&models.Issue{}, &models.PullRequest{}, &models.Review{}, &models.Comment{}, &models.ReviewComment{})
+
+	now := time.Now()
+	daysAgo := func(d int) time.Time { return now.AddDate(0, 0, -d) }
+	orgID := uuid.New()
+	repo := &models.Repository{ID: uuid.New(), OwnerID: orgID, OwnerType: models.OwnerTypeOrganization, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+
+	member := func(username string, joined time.Time, lastLogin *time.Time, service bool) *models.User {
+		user := &models.User{ID: uuid.New(), Username: username, Email: username + "@example.com", PasswordHash: "x", LastLoginAt: lastLogin, IsServiceAccount: service}
+		require.NoError(t, db.Create(user).Error)
+		require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), CreatedAt: joined, OrganizationID: orgID, UserID: user.ID, Role: models.OrgRoleMember}).Error)
+		return user
+	}
+	recentLogin := daysAgo(3)
+	oldLogin := daysAgo(200)
+
+	member("active-login", daysAgo(365), &recentLogin, false)
+	committer := member("committer", daysAgo(365), &oldLogin, false)
+	reviewer := member("reviewer", daysAgo(365), nil, false)
+	stale := member("stale", daysAgo(365), &oldLogin, false)
+	member("never", daysAgo(365), nil, false)
+	member("newcomer", daysAgo(5), nil, false)
+	member("ci-bot", daysAgo(365), nil, true)
+
+	require.NoError(t, db.Create(&models.Commit{ID: uuid.New(), RepositoryID: repo.ID, SHA: "a1", AuthorName: "c", AuthorEmail: strings.ToUpper(committer.Email),
+		AuthorDate: daysAgo(10), CommitterName: "c", CommitterEmail: committer.Email, CommitterDate: daysAgo(10), TreeSHA: "t"}).Error)
+	pr := &models.PullRequest{ID: uuid.New(), CreatedAt: daysAgo(150), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 1, Title: "pr",
+		UserID: &stale.ID, BaseBranch: "main", HeadBranch: "f", State: models.PullRequestStateOpen}
+	require.NoError(t, db.Create(pr).Error)
+	require.NoError(t, db.Create(&models.Review{ID: uuid.New(), CreatedAt: daysAgo(20), PullRequestID: pr.ID, UserID: &reviewer.ID, State: models.ReviewStateApproved}).Error)
+
+	svc := &analyticsService{db: db, logger: logrus.New()}
+	report, err := svc.GetInactiveMembers(context.Background(), orgID, InactiveMemberFilters{Days: 90})
+	require.NoError(t, err)
+
+	var usernames []string
+	for _, m := range report.Members {
+		usernames = append(usernames, m.Username)
+	}
+	assert.Equal(t, []string{"never", "stale"}, usernames, "never-active members lead, service accounts and newcomers are excluded")
+	assert.Equal(t, 7, report.TotalMembers)
+	assert.Equal(t, 2, report.InactiveCount)
+	require.NotNil(t, report.Members[1].LastPullRequestAt)
+	assert.WithinDuration(t, daysAgo(150), *report.Members[1].LastPullRequestAt, time.Second)
+	assert.WithinDuration(t, daysAgo(150), *report.Members[1].LastActiveAt, time.Second)
+
+	report, err = svc.GetInactiveMembers(context.Background(), orgID, InactiveMemberFilters{Days: 90, IncludeServiceAccounts: true})
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.InactiveCount)
+
+	data, err := report.CSV()
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "Username,Email"))
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+)
+
+// Language trend intervals
+const (
+	LanguageIntervalDay   = "day"
+	LanguageIntervalWeek  = "week"
+	LanguageIntervalMonth = "month"
+)
+
+const (
+	// defaultLanguageTrendDays is the trend window used without a start date
+	defaultLanguageTrendDays = 90
+	// maxLanguageTrendPoints bounds the number of points in a trend
+	maxLanguageTrendPoints = 400
+)
+
+// LanguageReportFilters selects the trend window of an organization language
+// report. Dates are truncated to UTC days.
+type LanguageReportFilters struct {
+	StartDate *time.Time `json:"start_date,omitempty"`
+	EndDate   *time.Time `json:"end_date,omitempty"`
+	Interval  string     `json:"interval,omitempty"`
+}
+
+// OrganizationLanguageReport aggregates language byte counts across the
+// organization repositories a viewer can see
+type OrganizationLanguageReport struct {
+	RepositoryCount int                      `json:"repository_count"`
+	TotalBytes      int64                    `json:"total_bytes"`
+	Languages       []*LanguageBytes         `json:"languages"`
+	Interval        string                   `json:"interval"`
+	Trend           []*LanguageTrendPoint    `json:"trend"`
+	Teams           []*TeamLanguageBreakdown `json:"teams"`
+}
+
+// LanguageBytes is one language's share of a set of repositories
+type LanguageBytes struct {
+	Language     string  `json:"language"`
+	Bytes        int64   `json:"bytes"`
+	Percentage   float64 `json:"percentage"`
+	Repositories int     `json:"repositories"`
+}
+
+// LanguageTrendPoint is the language breakdown as of Date, using the most
+// recent snapshot of each repository taken on or before it
+type LanguageTrendPoint struct {
+	Date       time.Time        `json:"date"`
+	TotalBytes int64            `json:"total_bytes"`
+	Languages  map[string]int64 `json:"languages"`
+}
+
+// TeamLanguageBreakdown is the language breakdown of the visible repositories
+// a team has been granted access to
+type TeamLanguageBreakdown struct {
+	TeamID          uuid.UUID        `json:"team_id"`
+	TeamName        string           `json:"team_name"`
+	RepositoryCount int              `json:"repository_count"`
+	TotalBytes      int64            `json:"total_bytes"`
+	Languages       []*LanguageBytes `json:"languages"`
+}
+
+// GetOrganizationLanguages reports language usage across the organization's
+// repositories that viewerID can read, with a trend built from daily
+// snapshots and a breakdown per team. Secret teams are only included for
+// their members and organization owners and admins.
+func (s *analyticsService) GetOrganizationLanguages(ctx context.Context, orgID, viewerID uuid.UUID, filters LanguageReportFilters) (*OrganizationLanguageReport, error) {
+	interval := filters.Interval
+	if interval == "" {
+		interval = LanguageIntervalWeek
+	}
+	if interval != LanguageIntervalDay && interval != LanguageIntervalWeek && interval != LanguageIntervalMonth {
+		return nil, fmt.Errorf("unsupported interval %q", interval)
+	}
+	end := truncateToDay(time.Now())
+	if filters.EndDate != nil {
+		end = truncateToDay(*filters.EndDate)
+	}
+	start := end.AddDate(0, 0, -defaultLanguageTrendDays)
+	if filters.StartDate != nil {
+		start = truncateToDay(*filters.StartDate)
+	}
+	if start.After(end) {
+		return nil, fmt.Errorf("start date is after end date")
+	}
+
+	db := s.db.WithContext(ctx)
+	orgAdmin, err := s.isOrganizationAdminViewer(ctx, orgID, viewerID)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := db.Model(&models.Repository{}).Select("id").
+		Where("owner_id = ? AND owner_type = ?", orgID, models.OwnerTypeOrganization)
+	if !orgAdmin {
+		teamIDs := db.Model(&models.TeamMember{}).Select("team_members.team_id").
+			Joins("JOIN teams ON teams.id = team_members.team_id AND teams.deleted_at IS NULL").
+			Where("teams.organization_id = ? AND team_members.user_id = ?", orgID, viewerID)
+		granted := db.Model(&models.RepositoryPermission{}).Select("repository_id").
+			Where("(subject_type = ? AND subject_id = ?) OR (subject_type = ? AND subject_id IN (?))",
+				models.SubjectTypeUser, viewerID, models.SubjectTypeTeam, teamIDs)
+		member := db.Model(&models.OrganizationMember{}).Select("user_id").
+			Where("organization_id = ? AND user_id = ?", orgID, viewerID)
+		repos = repos.Where("visibility = ? OR (visibility = ? AND EXISTS (?)) OR id IN (?)",
+			models.VisibilityPublic, models.VisibilityInternal, member, granted)
+	}
+	var repoIDs []uuid.UUID
+	if err := repos.Pluck("id", &repoIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to load visible repositories: %w", err)
+	}
+
+	report := &OrganizationLanguageReport{
+		RepositoryCount: len(repoIDs),
+		Interval:        interval,
+		Languages:       []*LanguageBytes{},
+		Trend:           []*LanguageTrendPoint{},
+		Teams:           []*TeamLanguageBreakdown{},
+	}
+	if len(repoIDs) == 0 {
+		return report, nil
+	}
+
+	var current []*models.RepositoryLanguage
+	if err := db.Where("repository_id IN ?", repoIDs).Find(&current).Error; err != nil {
+		return nil, fmt.Errorf("failed to load repository languages: %w", err)
+	}
+	byRepo := make(map[uuid.UUID][]*models.RepositoryLanguage)
+	for _, lang := range current {
+		byRepo[lang.RepositoryID] = append(byRepo[lang.RepositoryID], lang)
+	}
+	report.Languages, report.TotalBytes = summarizeLanguages(repoIDs, byRepo)
+
+	report.Trend, err = s.languageTrend(ctx, repoIDs, start, end, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	report.Teams, err = s.teamLanguages(ctx, orgID, viewerID, orgAdmin, repoIDs, byRepo)
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// isOrganizationAdminViewer reports whether viewerID sees every repository of
+// the organization, as a site admin or an organization owner or admin
+func (s *analyticsService) isOrganizationAdminViewer(ctx context.Context, orgID, viewerID uuid.UUID) (bool, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Select("id, is_admin").Where("id = ?", viewerID).Limit(1).Find(&user).Error; err != nil {
+		return false, fmt.Errorf("failed to load viewer: %w", err)
+	}
+	if user.IsAdmin {
+		return true, nil
+	}
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ? AND role IN ?", orgID, viewerID, []models.OrganizationRole{models.OrgRoleOwner, models.OrgRoleAdmin}).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check organization role: %w", err)
+	}
+	return count > 0, nil
+}
+
+// languageTrend replays the snapshots of repoIDs up to end and samples the
+// running breakdown at every interval boundary from start
+func (s *analyticsService) languageTrend(ctx context.Context, repoIDs []uuid.UUID, start, end time.Time, interval string) ([]*LanguageTrendPoint, error) {
+	var points []time.Time
+	for at := start; !at.After(end) && len(points) < maxLanguageTrendPoints; at = stepLanguageInterval(at, interval) {
+		points = append(points, at)
+	}
+	if last := points[len(points)-1]; last.Before(end) && len(points) < maxLanguageTrendPoints {
+		points = append(points, end)
+	}
+
+	var snapshots []*models.RepositoryLanguageSnapshot
+	if err := s.db.WithContext(ctx).Where("repository_id IN ? AND date <= ?", repoIDs, end).
+		Order("date ASC").Find(&snapshots).Error; err != nil {
+		return nil, fmt.Errorf("failed to load language snapshots: %w", err)
+	}
+
+	type repoState struct {
+		date      time.Time
+		languages map[string]int64
+	}
+	state := make(map[uuid.UUID]*repoState)
+	trend := make([]*LanguageTrendPoint, 0, len(points))
+	next := 0
+	for _, at := range points {
+		for ; next < len(snapshots) && !truncateToDay(snapshots[next].Date).After(at); next++ {
+			snap := snapshots[next]
+			date := truncateToDay(snap.Date)
+			st := state[snap.RepositoryID]
+			if st == nil || !st.date.Equal(date) {
+				// Each day's snapshot is a full breakdown of the repository
+				st = &repoState{date: date, languages: make(map[string]int64)}
+				state[snap.RepositoryID] = st
+			}
+			st.languages[snap.Language] += snap.Bytes
+		}
+
+		point := &LanguageTrendPoint{Date: at, Languages: make(map[string]int64)}
+		for _, st := range state {
+			for language, bytes := range st.languages {
+				point.Languages[language] += bytes
+				point.TotalBytes += bytes
+			}
+		}
+		trend = append(trend, point)
+	}
+	return trend, nil
+}
+
+// teamLanguages breaks the current languages down by the teams granted access
+// to visible repositories
+func (s *analyticsService) teamLanguages(ctx context.Context, orgID, viewerID uuid.UUID, orgAdmin bool, repoIDs []uuid.UUID, byRepo map[uuid.UUID][]*models.RepositoryLanguage) ([]*TeamLanguageBreakdown, error) {
+	db := s.db.WithContext(ctx)
+	teamsQuery := db.Where("organization_id = ?", orgID)
+	if !orgAdmin {
+		memberOf := db.Model(&models.TeamMember{}).Select("team_id").Where("user_id = ?", viewerID)
+		teamsQuery = teamsQuery.Where("privacy <> ? OR id IN (?)", models.TeamPrivacySecret, memberOf)
+	}
+	var teams []*models.Team
+	if err := teamsQuery.Order("name ASC").Find(&teams).Error; err != nil {
+		return nil, fmt.Errorf("failed to load teams: %w", err)
+	}
+	if len(teams) == 0 {
+		return []*TeamLanguageBreakdown{}, nil
+	}
+
+	teamIDs := make([]uuid.UUID, len(teams))
+	for i, team := range teams {
+		teamIDs[i] = team.ID
+	}
+	var grants []*models.RepositoryPermission
+	if err := db.Where("subject_type = ? AND subject_id IN ? AND repository_id IN ?", models.SubjectTypeTeam, teamIDs, repoIDs).
+		Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("failed to load team repositories: %w", err)
+	}
+	reposByTeam := make(map[uuid.UUID][]uuid.UUID)
+	for _, grant := range grants {
+		reposByTeam[grant.SubjectID] = append(reposByTeam[grant.SubjectID], grant.RepositoryID)
+	}
+
+	breakdowns := make([]*TeamLanguageBreakdown, 0, len(teams))
+	for _, team := range teams {
+		teamRepos := reposByTeam[team.ID]
+		breakdown := &TeamLanguageBreakdown{TeamID: team.ID, TeamName: team.Name, RepositoryCount: len(teamRepos)}
+		breakdown.Languages, breakdown.TotalBytes = summarizeLanguages(teamRepos, byRepo)
+		breakdowns = append(breakdowns, breakdown)
+	}
+	return breakdowns, nil
+}
+
+// summarizeLanguages totals the languages of repoIDs, largest first
+func summarizeLanguages(repoIDs []uuid.UUID, byRepo map[uuid.UUID][]*models.RepositoryLanguage) ([]*LanguageBytes, int64) {
+	totals := make(map[string]*LanguageBytes)
+	var total int64
+	for _, repoID := range repoIDs {
+		for _, lang := range byRepo[repoID] {
+			entry := totals[lang.Language]
+			if entry == nil {
+				entry = &LanguageBytes{Language: lang.Language}
+				totals[lang.Language] = entry
+			}
+			entry.Bytes += lang.Bytes
+			entry.Repositories++
+			total += lang.Bytes
+		}
+	}
+
+	languages := make([]*LanguageBytes, 0, len(totals))
+	for _, entry := range totals {
+		if total > 0 {
+			entry.Percentage = float64(entry.Bytes) * 100 / float64(total)
+		}
+		languages = append(languages, entry)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		if languages[i].Bytes != languages[j].Bytes {
+			return languages[i].Bytes > languages[j].Bytes
+		}
+		return languages[i].Language < languages[j].Language
+	})
+	return languages, total
+}
+
+func stepLanguageInterval(t time.Time, interval string) time.Time {
+	switch interval {
+	case LanguageIntervalDay:
+		return t.AddDate(0, 0, 1)
+	case LanguageIntervalMonth:
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 7)
+	}
+}
+
+func truncateToDay(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyticsService_GetOrganizationLanguages(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{}, &models.Team{},
+		&models.TeamMember{}, &models.Repository{}, &models.RepositoryPermission{}, &models.RepositoryLanguage{},
+		&models.RepositoryLanguageSnapshot{})
+	ctx := context.Background()
+	svc := NewAnalyticsService(db, nil, logrus.New())
+
+	owner := &models.User{ID: uuid.New(), Username: "olivia", Email: "olivia@example.com"}
+	member := &models.User{ID: uuid.New(), Username: "max", Email: "max@example.com"}
+	outsider := &models.User{ID: uuid.New(), Username: "eve", Email: "eve@example.com"}
+	require.NoError(t, db.Create([]*models.User{owner, member, outsider}).Error)
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	require.NoError(t, db.Create([]*models.OrganizationMember{
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: owner.ID, Role: models.OrgRoleOwner},
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: member.ID, Role: models.OrgRoleMember},
+	}).Error)
+
+	newRepo := func(name string, visibility models.Visibility, language string, bytes int64) *models.Repository {
+		repo := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: name, Visibility: visibility}
+		require.NoError(t, db.Create(repo).Error)
+		require.NoError(t, db.Create(&models.RepositoryLanguage{ID: uuid.New(), RepositoryID: repo.ID, Language: language, Bytes: bytes}).Error)
+		return repo
+	}
+	web := newRepo("web", models.VisibilityPublic, "Go", 1000)
+	newRepo("tools", models.VisibilityInternal, "Python", 500)
+	infra := newRepo("infra", models.VisibilityPrivate, "Rust", 300)
+	vault := newRepo("vault", models.VisibilityPrivate, "Java", 10000)
+
+	platform := &models.Team{ID: uuid.New(), OrganizationID: org.ID, Name: "platform", Privacy: models.TeamPrivacyClosed}
+	security := &models.Team{ID: uuid.New(), OrganizationID: org.ID, Name: "security", Privacy: models.TeamPrivacySecret}
+	require.NoError(t, db.Create([]*models.Team{platform, security}).Error)
+	require.NoError(t, db.Create(&models.TeamMember{ID: uuid.New(), TeamID: platform.ID, UserID: member.ID, Role: models.TeamRoleMember}).Error)
+	require.NoError(t, db.Create([]*models.RepositoryPermission{
+		{ID: uuid.New(), RepositoryID: infra.ID, SubjectID: platform.ID, SubjectType: models.SubjectTypeTeam, Permission: models.PermissionWrite},
+		{ID: uuid.New(), RepositoryID: web.ID, SubjectID: platform.ID, SubjectType: models.SubjectTypeTeam, Permission: models.PermissionRead},
+		{ID: uuid.New(), RepositoryID: vault.ID, SubjectID: security.ID, SubjectType: models.SubjectTypeTeam, Permission: models.PermissionAdmin},
+	}).Error)
+
+	end := time.Date(2024, 6, 20, 0, 0, 0, 0, time.UTC)
+	day := func(daysAgo int) time.Time { return end.AddDate(0, 0, -daysAgo) }
+	require.NoError(t, db.Create([]*models.RepositoryLanguageSnapshot{
+		{ID: uuid.New(), RepositoryID: web.ID, Date: day(10), Language: "Go", Bytes: 600},
+		{ID: uuid.New(), RepositoryID: web.ID, Date: day(10), Language: "Shell", Bytes: 50},
+		{ID: uuid.New(), RepositoryID: web.ID, Date: day(3), Language: "Go", Bytes: 1000},
+		{ID: uuid.New(), RepositoryID: infra.ID, Date: day(3), Language: "Rust", Bytes: 300},
+		{ID: uuid.New(), RepositoryID: vault.ID, Date: day(3), Language: "Java", Bytes: 10000},
+	}).Error)
+	start := day(10)
+	filters := LanguageReportFilters{StartDate: &start, EndDate: &end, Interval: LanguageIntervalDay}
+
+	// Members see public, internal and team-granted repositories
+	report, err := svc.GetOrganizationLanguages(ctx, org.ID, member.ID, filters)
+	require.NoError(t, err)
+	assert.Equal(t, 3, report.RepositoryCount)
+	assert.EqualValues(t, 1800, report.TotalBytes)
+	require.Len(t, report.Languages, 3)
+	assert.Equal(t, "Go", report.Languages[0].Language)
+	assert.InDelta(t, 55.55, report.Languages[0].Percentage, 0.01)
+
+	require.Len(t, report.Trend, 11)
+	assert.EqualValues(t, 650, report.Trend[0].TotalBytes)
+	assert.EqualValues(t, 650, report.Trend[6].TotalBytes)
+	// The later snapshot replaces the whole breakdown of the repository
+	assert.Equal(t, map[string]int64{"Go": 1000, "Rust": 300}, report.Trend[7].Languages)
+
+	require.Len(t, report.Teams, 1)
+	assert.Equal(t, "platform", report.Teams[0].TeamName)
+	assert.Equal(t, 2, report.Teams[0].RepositoryCount)
+	assert.EqualValues(t, 1300, report.Teams[0].TotalBytes)
+
+	// Outsiders only see public repositories
+	report, err = svc.GetOrganizationLanguages(ctx, org.ID, outsider.ID, filters)
+	require.NoError(t, err)
+	assert.Equal(t, 1, report.RepositoryCount)
+	assert.EqualValues(t, 1000, report.TotalBytes)
+	assert.EqualValues(t, 1000, report.Trend[10].TotalBytes)
+	require.Len(t, report.Teams, 1)
+	assert.Equal(t, 1, report.Teams[0].RepositoryCount)
+
+	// Owners see every repository and secret teams
+	report, err = svc.GetOrganizationLanguages(ctx, org.ID, owner.ID, LanguageReportFilters{StartDate: &start, EndDate: &end})
+	require.NoError(t, err)
+	assert.Equal(t, 4, report.RepositoryCount)
+	assert.EqualValues(t, 11800, report.TotalBytes)
+	require.Len(t, report.Trend, 3)
+	assert.Equal(t, end, report.Trend[2].Date)
+	assert.EqualValues(t, 11300, report.Trend[2].TotalBytes)
+	require.Len(t, report.Teams, 2)
+	assert.Equal(t, "security", report.Teams[1].TeamName)
+	assert.EqualValues(t, 10000, report.Teams[1].TotalBytes)
+
+	_, err = svc.GetOrganizationLanguages(ctx, org.ID, owner.ID, LanguageReportFilters{Interval: "year"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,458 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Guards on analytics event queries
+const (
+	maxEventQueryGroups       = 3
+	maxEventQueryAggregations = 5
+	maxEventQueryFilterNodes  = 50
+	maxEventQueryFilterDepth  = 5
+	maxEventQueryInValues     = 100
+	defaultEventQueryRows     = 100
+	maxEventQueryRows         = 1000
+	defaultEventQueryDays     = 30
+	maxEventQuerySpan         = 366 * 24 * time.Hour
+	eventQueryTimeout         = 10 * time.Second
+)
+
+var (
+	ErrInvalidEventQuery = errors.New("invalid analytics query")
+	ErrEventQueryTimeout = errors.New("analytics query timed out")
+)
+
+// EventQuery aggregates analytics events. The embedded filters narrow the
+// events as for GetEvents, with Limit and Offset paging the result rows;
+// the range defaults to the last 30 days and spans at most a year. Without
+// aggregations the query counts events.
+type EventQuery struct {
+	EventFilters
+	Filter       *EventQueryFilter  `json:"filter,omitempty"`
+	GroupBy      []string           `json:"group_by,omitempty"`
+	Aggregations []EventAggregation `json:"aggregations,omitempty"`
+	// Location buckets days; nil buckets in UTC
+	Location *time.Location `json:"-"`
+}
+
+// EventQueryFilter is a filter expression: either a comparison of Field
+// with Value, or a composition of other expressions with And, Or or Not.
+//
+// Comparison operators are eq, ne, gt, gte, lt, lte, in (Value is a list)
+// and exists (Value is a boolean).
+type EventQueryFilter struct {
+	Field string      `json:"field,omitempty"`
+	Op    string      `json:"op,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+
+	And []*EventQueryFilter `json:"and,omitempty"`
+	Or  []*EventQueryFilter `json:"or,omitempty"`
+	Not *EventQueryFilter   `json:"not,omitempty"`
+}
+
+// EventAggregation computes a value per group: count counts events, unique
+// counts the distinct values of Field. As names the result column.
+type EventAggregation struct {
+	Op    string `json:"op"`
+	Field string `json:"field,omitempty"`
+	As    string `json:"as,omitempty"`
+}
+
+// EventQueryResult holds one row per group, keyed by the group-by
+// dimensions and aggregation names
+type EventQueryResult struct {
+	StartDate time.Time                `json:"start_date"`
+	EndDate   time.Time                `json:"end_date"`
+	Columns   []string                 `json:"columns"`
+	Rows      []map[string]interface{} `json:"rows"`
+	// Truncated is set when more rows follow the returned page
+	Truncated bool  `json:"truncated"`
+	ElapsedMs int64 `json:"elapsed_ms"`
+}
+
+type eventFieldKind int
+
+const (
+	eventFieldString eventFieldKind = iota
+	eventFieldUUID
+	eventFieldNumber
+	eventFieldTime
+)
+
+type eventQueryField struct {
+	column string
+	kind   eventFieldKind
+	// unique marks fields that can be counted distinctly
+	unique bool
+}
+
+// eventQueryFields are the event fields a query can filter and count by
+var eventQueryFields = map[string]eventQueryField{
+	"event_type":      {column: "event_type"},
+	"actor_type":      {column: "actor_type"},
+	"target_type":     {column: "target_type"},
+	"status":          {column: "status"},
+	"actor_id":        {column: "actor_id", kind: eventFieldUUID, unique: true},
+	"target_id":       {column: "target_id", kind: eventFieldUUID, unique: true},
+	"repo":            {column: "repository_id", kind: eventFieldUUID, unique: true},
+	"repository_id":   {column: "repository_id", kind: eventFieldUUID, unique: true},
+	"org":             {column: "organization_id", kind: eventFieldUUID, unique: true},
+	"organization_id": {column: "organization_id", kind: eventFieldUUID, unique: true},
+	"session_id":      {column: "session_id", unique: true},
+	"ip_address":      {column: "ip_address", unique: true},
+	"duration":        {column: "duration", kind: eventFieldNumber},
+	"size":            {column: "size", kind: eventFieldNumber},
+	"created_at":      {column: "created_at", kind: eventFieldTime},
+}
+
+// eventQueryDimensions are the dimensions a query can group by, besides day
+var eventQueryDimensions = map[string]string{
+	"event_type": "event_type",
+	"repo":       "repository_id",
+	"org":        "organization_id",
+	"actor":      "actor_id",
+	"actor_type": "actor_type",
+	"status":     "status",
+}
+
+var eventQueryComparisons = map[string]string{
+	"eq": "=", "ne": "<>", "gt": ">", "gte": ">=", "lt": "<", "lte": "<=",
+}
+
+// applyEventFilters narrows an analytics event query by filters, leaving
+// out paging
+func applyEventFilters(query *gorm.DB, filters EventFilters) *gorm.DB {
+	if len(filters.EventTypes) > 0 {
+		query = query.Where("event_type IN ?", filters.EventTypes)
+	}
+	if filters.ActorID != nil {
+		query = query.Where("actor_id = ?", *filters.ActorID)
+	}
+	if filters.RepositoryID != nil {
+		query = query.Where("repository_id = ?", *filters.RepositoryID)
+	}
+	if filters.OrganizationID != nil {
+		query = query.Where("organization_id = ?", *filters.OrganizationID)
+	}
+	if filters.StartDate != nil {
+		query = query.Where("created_at >= ?", *filters.StartDate)
+	}
+	if filters.EndDate != nil {
+		query = query.Where("created_at <= ?", *filters.EndDate)
+	}
+	if filters.Status != "" {
+		query = query.Where("status = ?", filters.Status)
+	}
+	return query
+}
+
+// QueryEvents runs an aggregation query over analytics events
+func (s *analyticsService) QueryEvents(ctx context.Context, q EventQuery) (*EventQueryResult, error) {
+	started := time.Now()
+
+	end := started.UTC()
+	if q.EndDate != nil {
+		end = *q.EndDate
+	}
+	start := end.AddDate(0, 0, -defaultEventQueryDays)
+	if q.StartDate != nil {
+		start = *q.StartDate
+	}
+	if end.Before(start) || end.Sub(start) > maxEventQuerySpan {
+		return nil, fmt.Errorf("%w: end_date must follow start_date by at most %d days",
+			ErrInvalidEventQuery, int(maxEventQuerySpan.Hours()/24))
+	}
+	q.StartDate, q.EndDate = &start, &end
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = defaultEventQueryRows
+	}
+	if limit < 0 || limit > maxEventQueryRows {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidEventQuery, maxEventQueryRows)
+	}
+	if q.Offset < 0 {
+		return nil, fmt.Errorf("%w: offset must not be negative", ErrInvalidEventQuery)
+	}
+
+	var selects, groups, orders, columns []string
+	if len(q.GroupBy) > maxEventQueryGroups {
+		return nil, fmt.Errorf("%w: at most %d group_by dimensions", ErrInvalidEventQuery, maxEventQueryGroups)
+	}
+	for _, dimension := range q.GroupBy {
+		if slices.Contains(columns, dimension) {
+			return nil, fmt.Errorf("%w: %s is grouped by twice", ErrInvalidEventQuery, dimension)
+		}
+		expr, ok := eventQueryDimensions[dimension]
+		if dimension == "day" {
+			expr, ok = s.dayBucket("created_at", q.Location), true
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: cannot group by %q", ErrInvalidEventQuery, dimension)
+		}
+		selects = append(selects, fmt.Sprintf("%s AS g%d", expr, len(groups)))
+		groups = append(groups, expr)
+		columns = append(columns, dimension)
+	}
+
+	aggregations := q.Aggregations
+	if len(aggregations) == 0 {
+		aggregations = []EventAggregation{{Op: "count"}}
+	}
+	if len(aggregations) > maxEventQueryAggregations {
+		return nil, fmt.Errorf("%w: at most %d aggregations", ErrInvalidEventQuery, maxEventQueryAggregations)
+	}
+	for i, agg := range aggregations {
+		var expr, name string
+		switch agg.Op {
+		case "count":
+			expr, name = "COUNT(*)", "count"
+		case "unique":
+			field, ok := eventQueryFields[agg.Field]
+			if !ok || !field.unique {
+				return nil, fmt.Errorf("%w: cannot count unique %q", ErrInvalidEventQuery, agg.Field)
+			}
+			expr, name = "COUNT(DISTINCT "+field.column+")", "unique_"+agg.Field
+		default:
+			return nil, fmt.Errorf("%w: unknown aggregation %q", ErrInvalidEventQuery, agg.Op)
+		}
+		if agg.As != "" {
+			name = agg.As
+		}
+		if slices.Contains(columns, name) {
+			return nil, fmt.Errorf("%w: column %q is used twice", ErrInvalidEventQuery, name)
+		}
+		selects = append(selects, fmt.Sprintf("%s AS a%d", expr, i))
+		columns = append(columns, name)
+	}
+
+	// Days read in order; other groups largest first
+	for i, dimension := range q.GroupBy {
+		if dimension == "day" {
+			orders = append(orders, fmt.Sprintf("g%d ASC", i))
+		}
+	}
+	orders = append(orders, "a0 DESC")
+	for i, dimension := range q.GroupBy {
+		if dimension != "day" {
+			orders = append(orders, fmt.Sprintf("g%d ASC", i))
+		}
+	}
+
+	where, args, err := buildEventQueryFilter(q.Filter, 0, new(int))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, eventQueryTimeout)
+	defer cancel()
+	query := applyEventFilters(s.db.WithContext(ctx).Model(&models.AnalyticsEvent{}), q.EventFilters)
+	if where != "" {
+		query = query.Where(where, args...)
+	}
+	query = query.Select(strings.Join(selects, ", "))
+	if len(groups) > 0 {
+		query = query.Group(strings.Join(groups, ", "))
+	}
+	rows, err := query.Order(strings.Join(orders, ", ")).Limit(limit + 1).Offset(q.Offset).Rows()
+	if err != nil {
+		return nil, eventQueryError(ctx, err)
+	}
+	defer rows.Close()
+
+	result := &EventQueryResult{StartDate: start, EndDate: end, Columns: columns, Rows: []map[string]interface{}{}}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, eventQueryError(ctx, err)
+		}
+		if len(result.Rows) == limit {
+			result.Truncated = true
+			break
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			row[column] = eventQueryValue(values[i], i < len(q.GroupBy) && q.GroupBy[i] == "day")
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, eventQueryError(ctx, err)
+	}
+	result.ElapsedMs = time.Since(started).Milliseconds()
+	return result, nil
+}
+
+// eventQueryError reports queries cut off by the time guard as timeouts
+func eventQueryError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrEventQueryTimeout
+	}
+	return fmt.Errorf("failed to query events: %w", err)
+}
+
+// eventQueryValue normalizes a scanned value across databases; days are
+// reported as dates
+func eventQueryValue(value interface{}, day bool) interface{} {
+	if b, ok := value.([]byte); ok {
+		value = string(b)
+	}
+	if !day {
+		return value
+	}
+	switch v := value.(type) {
+	case time.Time:
+		return v.Format("2006-01-02")
+	case string:
+		if len(v) > 10 {
+			return v[:10]
+		}
+	}
+	return value
+}
+
+// buildEventQueryFilter compiles a filter expression into a SQL condition
+// over whitelisted columns, counting nodes against the size guard
+func buildEventQueryFilter(f *EventQueryFilter, depth int, nodes *int) (string, []interface{}, error) {
+	if f == nil {
+		return "", nil, nil
+	}
+	*nodes++
+	if depth > maxEventQueryFilterDepth || *nodes > maxEventQueryFilterNodes {
+		return "", nil, fmt.Errorf("%w: filters nest at most %d deep with at most %d expressions",
+			ErrInvalidEventQuery, maxEventQueryFilterDepth, maxEventQueryFilterNodes)
+	}
+
+	compose := func(parts []*EventQueryFilter, joiner string) (string, []interface{}, error) {
+		var conditions []string
+		var args []interface{}
+		for _, part := range parts {
+			condition, partArgs, err := buildEventQueryFilter(part, depth+1, nodes)
+			if err != nil {
+				return "", nil, err
+			}
+			if condition != "" {
+				conditions = append(conditions, "("+condition+")")
+				args = append(args, partArgs...)
+			}
+		}
+		return strings.Join(conditions, joiner), args, nil
+	}
+
+	set := 0
+	for _, present := range []bool{f.Field != "", len(f.And) > 0, len(f.Or) > 0, f.Not != nil} {
+		if present {
+			set++
+		}
+	}
+	if set != 1 {
+		return "", nil, fmt.Errorf("%w: each filter needs exactly one of field, and, or and not", ErrInvalidEventQuery)
+	}
+
+	switch {
+	case len(f.And) > 0:
+		return compose(f.And, " AND ")
+	case len(f.Or) > 0:
+		return compose(f.Or, " OR ")
+	case f.Not != nil:
+		condition, args, err := buildEventQueryFilter(f.Not, depth+1, nodes)
+		if err != nil || condition == "" {
+			return "", nil, err
+		}
+		return "NOT (" + condition + ")", args, nil
+	}
+
+	field, ok := eventQueryFields[f.Field]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unknown field %q", ErrInvalidEventQuery, f.Field)
+	}
+	switch f.Op {
+	case "exists":
+		exists, ok := f.Value.(bool)
+		if !ok {
+			return "", nil, fmt.Errorf("%w: exists takes true or false", ErrInvalidEventQuery)
+		}
+		if exists {
+			return field.column + " IS NOT NULL", nil, nil
+		}
+		return field.column + " IS NULL", nil, nil
+	case "in":
+		list, ok := f.Value.([]interface{})
+		if !ok || len(list) == 0 || len(list) > maxEventQueryInValues {
+			return "", nil, fmt.Errorf("%w: in takes a list of 1 to %d values", ErrInvalidEventQuery, maxEventQueryInValues)
+		}
+		values := make([]interface{}, 0, len(list))
+		for _, item := range list {
+			value, err := eventQueryArg(f.Field, field.kind, item)
+			if err != nil {
+				return "", nil, err
+			}
+			values = append(values, value)
+		}
+		return field.column + " IN ?", []interface{}{values}, nil
+	}
+	operator, ok := eventQueryComparisons[f.Op]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidEventQuery, f.Op)
+	}
+	if operator != "=" && operator != "<>" && field.kind != eventFieldNumber && field.kind != eventFieldTime {
+		return "", nil, fmt.Errorf("%w: %s only supports eq, ne, in and exists", ErrInvalidEventQuery, f.Field)
+	}
+	value, err := eventQueryArg(f.Field, field.kind, f.Value)
+	if err != nil {
+		return "", nil, err
+	}
+	return field.column + " " + operator + " ?", []interface{}{value}, nil
+}
+
+// eventQueryArg converts a decoded JSON value to the type of the field
+func eventQueryArg(name string, kind eventFieldKind, value interface{}) (interface{}, error) {
+	invalid := fmt.Errorf("%w: invalid value for %s", ErrInvalidEventQuery, name)
+	switch kind {
+	case eventFieldNumber:
+		number, ok := value.(float64)
+		if !ok {
+			return nil, invalid
+		}
+		return int64(number), nil
+	case eventFieldUUID:
+		text, ok := value.(string)
+		if !ok {
+			return nil, invalid
+		}
+		id, err := uuid.Parse(text)
+		if err != nil {
+			return nil, invalid
+		}
+		return id, nil
+	case eventFieldTime:
+		text, ok := value.(string)
+		if !ok {
+			return nil, invalid
+		}
+		t, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			return nil, invalid
+		}
+		return t, nil
+	}
+	text, ok := value.(string)
+	if !ok {
+		return nil, invalid
+	}
+	return text, nil
+}
@@ -0,0 +1,144 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/a5c-ai/hub/internal/testutil"
+)
+
+func TestAnalyticsService_QueryEvents(t *testing.T) {
+	db := testutil.NewTestDB(t)
+	require.NoError(t, db.Exec(`
+		CREATE TABLE analytics_events (
+			id TEXT PRIMARY KEY,
+			created_at DATETIME,
+			updated_at DATETIME,
+			deleted_at DATETIME,
+			event_type VARCHAR(100) NOT NULL,
+			actor_id TEXT,
+			actor_type VARCHAR(50),
+			target_type VARCHAR(50),
+			target_id TEXT,
+			repository_id TEXT,
+			organization_id TEXT,
+			user_agent TEXT,
+			ip_address VARCHAR(45),
+			session_id VARCHAR(255),
+			request_id VARCHAR(255),
+			metadata JSON,
+			duration INTEGER,
+			size INTEGER,
+			status VARCHAR(50),
+			error_message TEXT
+		);
+	`).Error)
+
+	svc := services.NewAnalyticsService(db, nil, logrus.New())
+	ctx := context.Background()
+
+	day := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -2).Add(12 * time.Hour)
+	alice, bob := uuid.New(), uuid.New()
+	repoA, repoB := uuid.New(), uuid.New()
+	record := func(eventType string, actor, repo uuid.UUID, at time.Time, duration int64) {
+		require.NoError(t, svc.RecordEvent(ctx, &models.AnalyticsEvent{
+			EventType:    models.EventType(eventType),
+			ActorID:      &actor,
+			RepositoryID: &repo,
+			Duration:     &duration,
+			Status:       "success",
+			CreatedAt:    at,
+		}))
+	}
+	record("repo.push", alice, repoA, day, 100)
+	record("repo.push", alice, repoA, day, 300)
+	record("repo.push", bob, repoB, day.AddDate(0, 0, 1), 50)
+	record("repo.clone", bob, repoA, day.AddDate(0, 0, 1), 900)
+
+	t.Run("group by event type with unique actors", func(t *testing.T) {
+		result, err := svc.QueryEvents(ctx, services.EventQuery{
+			GroupBy: []string{"event_type"},
+			Aggregations: []services.EventAggregation{
+				{Op: "count"},
+				{Op: "unique", Field: "actor_id", As: "actors"},
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"event_type", "count", "actors"}, result.Columns)
+		require.Len(t, result.Rows, 2)
+		require.Equal(t, "repo.push", result.Rows[0]["event_type"])
+		require.EqualValues(t, 3, result.Rows[0]["count"])
+		require.EqualValues(t, 2, result.Rows[0]["actors"])
+		require.False(t, result.Truncated)
+	})
+
+	t.Run("group by day orders days", func(t *testing.T) {
+		result, err := svc.QueryEvents(ctx, services.EventQuery{GroupBy: []string{"day"}})
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 2)
+		require.Equal(t, day.Format("2006-01-02"), result.Rows[0]["day"])
+		require.EqualValues(t, 2, result.Rows[0]["count"])
+		require.EqualValues(t, 2, result.Rows[1]["count"])
+	})
+
+	t.Run("composed filters", func(t *testing.T) {
+		var filter services.EventQueryFilter
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"or": [
+				{"and": [
+					{"field": "event_type", "op": "eq", "value": "repo.push"},
+					{"field": "duration", "op": "gte", "value": 100}
+				]},
+				{"not": {"field": "event_type", "op": "in", "value": ["repo.push"]}}
+			]
+		}`), &filter))
+		result, err := svc.QueryEvents(ctx, services.EventQuery{Filter: &filter, GroupBy: []string{"repo"}})
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 1)
+		require.Equal(t, repoA.String(), result.Rows[0]["repo"])
+		require.EqualValues(t, 3, result.Rows[0]["count"])
+	})
+
+	t.Run("limit truncates rows", func(t *testing.T) {
+		result, err := svc.QueryEvents(ctx, services.EventQuery{
+			EventFilters: services.EventFilters{Limit: 1},
+			GroupBy:      []string{"actor"},
+		})
+		require.NoError(t, err)
+		require.Len(t, result.Rows, 1)
+		require.True(t, result.Truncated)
+	})
+
+	t.Run("guards", func(t *testing.T) {
+		deep := &services.EventQueryFilter{Field: "status", Op: "eq", Value: "success"}
+		for i := 0; i < 6; i++ {
+			deep = &services.EventQueryFilter{Not: deep}
+		}
+		start := time.Now().AddDate(-2, 0, 0)
+		invalid := []services.EventQuery{
+			{GroupBy: []string{"metadata"}},
+			{GroupBy: []string{"day", "repo", "org", "actor"}},
+			{Aggregations: []services.EventAggregation{{Op: "unique", Field: "duration"}}},
+			{Aggregations: []services.EventAggregation{{Op: "sum", Field: "size"}}},
+			{Filter: &services.EventQueryFilter{Field: "error_message", Op: "eq", Value: "x"}},
+			{Filter: &services.EventQueryFilter{Field: "event_type", Op: "gt", Value: "x"}},
+			{Filter: &services.EventQueryFilter{Field: "repo", Op: "eq", Value: "not-a-uuid"}},
+			{Filter: deep},
+			{EventFilters: services.EventFilters{StartDate: &start}},
+			{EventFilters: services.EventFilters{Limit: 5000}},
+		}
+		for i, query := range invalid {
+			_, err := svc.QueryEvents(ctx, query)
+			require.Truef(t, errors.Is(err, services.ErrInvalidEventQuery), "query %d: %v", i, err)
+		}
+	})
+}
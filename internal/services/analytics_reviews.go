@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+)
+
+const (
+	// defaultReviewAnalyticsDays is the window used without a start date
+	defaultReviewAnalyticsDays = 90
+	// quickApprovalWindow is how soon after a pull request was opened an
+	// approval counts as quick
+	quickApprovalWindow = 5 * time.Minute
+	// largePullRequestLines is the number of changed lines from which a pull
+	// request counts as large
+	largePullRequestLines = 500
+)
+
+// ReviewAnalyticsFilters selects the pull requests, by creation date, that
+// review analytics are computed from
+type ReviewAnalyticsFilters struct {
+	StartDate *time.Time `json:"start_date,omitempty"`
+	EndDate   *time.Time `json:"end_date,omitempty"`
+}
+
+// ReviewAnalytics summarizes how the pull requests of a repository or
+// organization were reviewed. Reviews by pull request authors and pending
+// reviews are left out.
+type ReviewAnalytics struct {
+	StartDate            time.Time `json:"start_date"`
+	EndDate              time.Time `json:"end_date"`
+	PullRequests         int       `json:"pull_requests"`
+	ReviewedPullRequests int       `json:"reviewed_pull_requests"`
+	Reviews              int       `json:"reviews"`
+	// TimeToFirstReview runs from a pull request opening to its first review
+	TimeToFirstReview DurationPercentiles `json:"time_to_first_review"`
+	// ReviewTurnaround runs from a pull request opening to the first review
+	// of each of its reviewers
+	ReviewTurnaround DurationPercentiles `json:"review_turnaround"`
+	Reviewers        []*ReviewerLoad     `json:"reviewers"`
+	RubberStamps     RubberStampSignals  `json:"rubber_stamps"`
+}
+
+// DurationPercentiles describes a set of durations in hours
+type DurationPercentiles struct {
+	Count        int     `json:"count"`
+	AverageHours float64 `json:"average_hours"`
+	P50Hours     float64 `json:"p50_hours"`
+	P75Hours     float64 `json:"p75_hours"`
+	P90Hours     float64 `json:"p90_hours"`
+}
+
+// ReviewerLoad is the review work of one reviewer. PendingRequests counts
+// open pull requests currently waiting on the reviewer, whatever their age.
+type ReviewerLoad struct {
+	UserID                uuid.UUID `json:"user_id"`
+	Username              string    `json:"username"`
+	Reviews               int       `json:"reviews"`
+	PullRequests          int       `json:"pull_requests"`
+	Approvals             int       `json:"approvals"`
+	ChangesRequested      int       `json:"changes_requested"`
+	Comments              int       `json:"comments"`
+	PendingRequests       int       `json:"pending_requests"`
+	Share                 float64   `json:"share"`
+	MedianTurnaroundHours float64   `json:"median_turnaround_hours"`
+	RubberStamps          int       `json:"rubber_stamps"`
+}
+
+// RubberStampSignals flags approvals given without visible scrutiny. Quick
+// approvals came within five minutes of the pull request opening, silent
+// ones have neither a body nor review comments; rubber stamps are both, and
+// Rate is their share of all approvals.
+type RubberStampSignals struct {
+	Approvals         int     `json:"approvals"`
+	QuickApprovals    int     `json:"quick_approvals"`
+	SilentApprovals   int     `json:"silent_approvals"`
+	RubberStamps      int     `json:"rubber_stamps"`
+	LargeRubberStamps int     `json:"large_rubber_stamps"`
+	Rate              float64 `json:"rate"`
+}
+
+// GetRepositoryReviewAnalytics computes review analytics for a repository
+func (s *analyticsService) GetRepositoryReviewAnalytics(ctx context.Context, repoID uuid.UUID, filters ReviewAnalyticsFilters) (*ReviewAnalytics, error) {
+	return s.reviewAnalytics(ctx, []uuid.UUID{repoID}, filters)
+}
+
+// GetOrganizationReviewAnalytics computes review analytics across the
+// repositories of an organization
+func (s *analyticsService) GetOrganizationReviewAnalytics(ctx context.Context, orgID uuid.UUID, filters ReviewAnalyticsFilters) (*ReviewAnalytics, error) {
+	var repoIDs []uuid.UUID
+	if err := s.db.WithContext(ctx).Model(&models.Repository{}).
+		Where("owner_id = ? AND owner_type = ?", orgID, models.OwnerTypeOrganization).
+		Pluck("id", &repoIDs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list organization repositories: %w", err)
+	}
+	return s.reviewAnalytics(ctx, repoIDs, filters)
+}
+
+func (s *analyticsService) reviewAnalytics(ctx context.Context, repoIDs []uuid.UUID, filters ReviewAnalyticsFilters) (*ReviewAnalytics, error) {
+	end := time.Now()
+	if filters.EndDate != nil {
+		end = *filters.EndDate
+	}
+	start := end.AddDate(0, 0, -defaultReviewAnalyticsDays)
+	if filters.StartDate != nil {
+		start = *filters.StartDate
+	}
+	report := &ReviewAnalytics{StartDate: start, EndDate: end, Reviewers: []*ReviewerLoad{}}
+	if len(repoIDs) == 0 {
+		return report, nil
+	}
+	db := s.db.WithContext(ctx)
+
+	var prs []models.PullRequest
+	if err := db.Where("repository_id IN ? AND created_at >= ? AND created_at <= ?", repoIDs, start, end).
+		Find(&prs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list pull requests: %w", err)
+	}
+	report.PullRequests = len(prs)
+	byID := make(map[uuid.UUID]*models.PullRequest, len(prs))
+	prIDs := make([]uuid.UUID, 0, len(prs))
+	for i := range prs {
+		byID[prs[i].ID] = &prs[i]
+		prIDs = append(prIDs, prs[i].ID)
+	}
+
+	var reviews []models.Review
+	if len(prIDs) > 0 {
+		if err := db.Where("pull_request_id IN ? AND user_id IS NOT NULL AND state <> ?", prIDs, models.ReviewStatePending).
+			Order("created_at").Find(&reviews).Error; err != nil {
+			return nil, fmt.Errorf("failed to list reviews: %w", err)
+		}
+	}
+
+	// Review comments tell silent approvals from approvals with feedback
+	commented := make(map[uuid.UUID]bool)
+	if len(reviews) > 0 {
+		reviewIDs := make([]uuid.UUID, 0, len(reviews))
+		for _, review := range reviews {
+			reviewIDs = append(reviewIDs, review.ID)
+		}
+		var withComments []uuid.UUID
+		if err := db.Model(&models.ReviewComment{}).Distinct("review_id").
+			Where("review_id IN ?", reviewIDs).Pluck("review_id", &withComments).Error; err != nil {
+			return nil, fmt.Errorf("failed to count review comments: %w", err)
+		}
+		for _, id := range withComments {
+			commented[id] = true
+		}
+	}
+
+	reviewers := make(map[uuid.UUID]*ReviewerLoad)
+	reviewer := func(id uuid.UUID) *ReviewerLoad {
+		if r, ok := reviewers[id]; ok {
+			return r
+		}
+		r := &ReviewerLoad{UserID: id}
+		reviewers[id] = r
+		return r
+	}
+	type pair struct{ pr, user uuid.UUID }
+	firstByReviewer := make(map[pair]bool)
+	firstByPR := make(map[uuid.UUID]time.Duration)
+	var turnarounds []time.Duration
+	turnaroundsByReviewer := make(map[uuid.UUID][]time.Duration)
+
+	for _, review := range reviews {
+		pr := byID[review.PullRequestID]
+		userID := *review.UserID
+		if pr.UserID != nil && *pr.UserID == userID {
+			continue
+		}
+		submitted := review.CreatedAt
+		if review.SubmittedAt != nil {
+			submitted = *review.SubmittedAt
+		}
+		elapsed := submitted.Sub(pr.CreatedAt)
+		if elapsed < 0 {
+			elapsed = 0
+		}
+
+		report.Reviews++
+		r := reviewer(userID)
+		r.Reviews++
+		switch review.State {
+		case models.ReviewStateApproved:
+			r.Approvals++
+		case models.ReviewStateRequestChanges:
+			r.ChangesRequested++
+		case models.ReviewStateCommented:
+			r.Comments++
+		}
+
+		if first, ok := firstByPR[pr.ID]; !ok || elapsed < first {
+			firstByPR[pr.ID] = elapsed
+		}
+		if key := (pair{pr.ID, userID}); !firstByReviewer[key] {
+			firstByReviewer[key] = true
+			r.PullRequests++
+			turnarounds = append(turnarounds, elapsed)
+			turnaroundsByReviewer[userID] = append(turnaroundsByReviewer[userID], elapsed)
+		}
+
+		if review.State != models.ReviewStateApproved {
+			continue
+		}
+		stamps := &report.RubberStamps
+		stamps.Approvals++
+		quick := elapsed <= quickApprovalWindow
+		silent := review.Body == "" && !commented[review.ID]
+		if quick {
+			stamps.QuickApprovals++
+		}
+		if silent {
+			stamps.SilentApprovals++
+		}
+		if quick && silent {
+			stamps.RubberStamps++
+			r.RubberStamps++
+			if pr.Additions+pr.Deletions >= largePullRequestLines {
+				stamps.LargeRubberStamps++
+			}
+		}
+	}
+	if report.RubberStamps.Approvals > 0 {
+		report.RubberStamps.Rate = roundTo(float64(report.RubberStamps.RubberStamps)/float64(report.RubberStamps.Approvals), 4)
+	}
+
+	report.ReviewedPullRequests = len(firstByPR)
+	firsts := make([]time.Duration, 0, len(firstByPR))
+	for _, d := range firstByPR {
+		firsts = append(firsts, d)
+	}
+	report.TimeToFirstReview = durationPercentiles(firsts)
+	report.ReviewTurnaround = durationPercentiles(turnarounds)
+
+	var pending []struct {
+		UserID uuid.UUID
+		Count  int
+	}
+	if err := db.Table("pull_request_review_requests").
+		Select("pull_request_review_requests.user_id AS user_id, COUNT(*) AS count").
+		Joins("JOIN pull_requests ON pull_requests.id = pull_request_review_requests.pull_request_id").
+		Where("pull_requests.repository_id IN ? AND pull_requests.state = ? AND pull_requests.deleted_at IS NULL", repoIDs, models.PullRequestStateOpen).
+		Group("pull_request_review_requests.user_id").Scan(&pending).Error; err != nil {
+		return nil, fmt.Errorf("failed to count pending review requests: %w", err)
+	}
+	for _, p := range pending {
+		reviewer(p.UserID).PendingRequests = p.Count
+	}
+
+	if len(reviewers) == 0 {
+		return report, nil
+	}
+	ids := make([]uuid.UUID, 0, len(reviewers))
+	for id := range reviewers {
+		ids = append(ids, id)
+	}
+	var users []models.User
+	if err := db.Select("id, username").Where("id IN ?", ids).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to get reviewers: %w", err)
+	}
+	for _, user := range users {
+		reviewers[user.ID].Username = user.Username
+	}
+	for id, r := range reviewers {
+		if report.Reviews > 0 {
+			r.Share = roundTo(float64(r.Reviews)/float64(report.Reviews), 4)
+		}
+		r.MedianTurnaroundHours = durationPercentiles(turnaroundsByReviewer[id]).P50Hours
+		report.Reviewers = append(report.Reviewers, r)
+	}
+	sort.Slice(report.Reviewers, func(i, j int) bool {
+		a, b := report.Reviewers[i], report.Reviewers[j]
+		if a.Reviews != b.Reviews {
+			return a.Reviews > b.Reviews
+		}
+		if a.PendingRequests != b.PendingRequests {
+			return a.PendingRequests > b.PendingRequests
+		}
+		return a.Username < b.Username
+	})
+	return report, nil
+}
+
+// durationPercentiles summarizes durations using nearest-rank percentiles
+func durationPercentiles(durations []time.Duration) DurationPercentiles {
+	result := DurationPercentiles{Count: len(durations)}
+	if len(durations) == 0 {
+		return result
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	rank := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return roundTo(sorted[i].Hours(), 2)
+	}
+	result.AverageHours = roundTo(total.Hours()/float64(len(sorted)), 2)
+	result.P50Hours = rank(0.5)
+	result.P75Hours = rank(0.75)
+	result.P90Hours = rank(0.9)
+	return result
+}
+
+func roundTo(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyticsService_ReviewAnalytics(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.Repository{}, &models.PullRequest{},
+		&models.Review{}, &models.ReviewComment{})
+	ctx := context.Background()
+	svc := NewAnalyticsService(db, nil, logrus.New())
+
+	author := &models.User{ID: uuid.New(), Username: "ann", Email: "ann@example.com"}
+	bob := &models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com"}
+	cat := &models.User{ID: uuid.New(), Username: "cat", Email: "cat@example.com"}
+	require.NoError(t, db.Create([]*models.User{author, bob, cat}).Error)
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	api := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "api", Visibility: models.VisibilityPrivate}
+	web := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "web", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create([]*models.Repository{api, web}).Error)
+
+	opened := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+	number := 0
+	newPR := func(repo *models.Repository, state models.PullRequestState, lines int) *models.PullRequest {
+		number++
+		pr := &models.PullRequest{ID: uuid.New(), CreatedAt: opened, RepositoryID: repo.ID, BaseRepositoryID: repo.ID,
+			Number: number, Title: "change", UserID: &author.ID, BaseBranch: "main", HeadBranch: "feature",
+			State: state, Additions: lines}
+		require.NoError(t, db.Create(pr).Error)
+		return pr
+	}
+	review := func(pr *models.PullRequest, user *models.User, state models.ReviewState, after time.Duration, body string) *models.Review {
+		submitted := opened.Add(after)
+		r := &models.Review{ID: uuid.New(), PullRequestID: pr.ID, UserID: &user.ID, CommitSHA: "abc", State: state,
+			Body: body, SubmittedAt: &submitted}
+		require.NoError(t, db.Create(r).Error)
+		return r
+	}
+
+	// Reviewed after 1h by bob, who approves it later, and after 3h by cat
+	first := newPR(api, models.PullRequestStateMerged, 20)
+	review(first, bob, models.ReviewStateCommented, time.Hour, "a question")
+	review(first, cat, models.ReviewStateRequestChanges, 3*time.Hour, "please fix")
+	review(first, bob, models.ReviewStateApproved, 5*time.Hour, "")
+	// A large change approved within two minutes without a word
+	second := newPR(api, models.PullRequestStateMerged, 900)
+	review(second, bob, models.ReviewStateApproved, 2*time.Minute, "")
+	// A quick approval with review comments is not a rubber stamp
+	third := newPR(web, models.PullRequestStateOpen, 10)
+	commented := review(third, cat, models.ReviewStateApproved, time.Minute, "")
+	require.NoError(t, db.Create(&models.ReviewComment{ID: uuid.New(), ReviewID: &commented.ID, PullRequestID: third.ID,
+		UserID: &cat.ID, CommitSHA: "abc", Path: "main.go", Side: "RIGHT", StartSide: "RIGHT", Body: "nit"}).Error)
+	// Self reviews and pending reviews do not count
+	review(third, author, models.ReviewStateApproved, time.Minute, "")
+	review(third, bob, models.ReviewStatePending, time.Minute, "")
+	// Waiting on bob
+	waiting := newPR(web, models.PullRequestStateOpen, 10)
+	require.NoError(t, db.Model(waiting).Association("RequestedReviewers").Append(bob))
+
+	windowStart, windowEnd := opened.Add(-time.Hour), opened.Add(time.Hour)
+	filters := ReviewAnalyticsFilters{StartDate: &windowStart, EndDate: &windowEnd}
+
+	t.Run("repository", func(t *testing.T) {
+		report, err := svc.GetRepositoryReviewAnalytics(ctx, api.ID, filters)
+		require.NoError(t, err)
+		assert.Equal(t, 2, report.PullRequests)
+		assert.Equal(t, 2, report.ReviewedPullRequests)
+		assert.Equal(t, 4, report.Reviews)
+
+		// First reviews came after 2 minutes and 1 hour
+		assert.Equal(t, 2, report.TimeToFirstReview.Count)
+		assert.Equal(t, 0.03, report.TimeToFirstReview.P50Hours)
+		assert.Equal(t, 1.0, report.TimeToFirstReview.P90Hours)
+		// Each reviewer's first review: bob 1h and 2m, cat 3h
+		assert.Equal(t, 3, report.ReviewTurnaround.Count)
+		assert.Equal(t, 1.0, report.ReviewTurnaround.P50Hours)
+
+		require.Len(t, report.Reviewers, 2)
+		b := report.Reviewers[0]
+		assert.Equal(t, "bob", b.Username)
+		assert.Equal(t, 3, b.Reviews)
+		assert.Equal(t, 2, b.PullRequests)
+		assert.Equal(t, 2, b.Approvals)
+		assert.Equal(t, 1, b.Comments)
+		assert.Equal(t, 0.75, b.Share)
+		assert.Equal(t, 1, b.RubberStamps)
+		assert.Equal(t, "cat", report.Reviewers[1].Username)
+		assert.Equal(t, 1, report.Reviewers[1].ChangesRequested)
+
+		assert.Equal(t, RubberStampSignals{Approvals: 2, QuickApprovals: 1, SilentApprovals: 2, RubberStamps: 1,
+			LargeRubberStamps: 1, Rate: 0.5}, report.RubberStamps)
+	})
+
+	t.Run("organization", func(t *testing.T) {
+		report, err := svc.GetOrganizationReviewAnalytics(ctx, org.ID, filters)
+		require.NoError(t, err)
+		assert.Equal(t, 4, report.PullRequests)
+		assert.Equal(t, 3, report.ReviewedPullRequests)
+		assert.Equal(t, 5, report.Reviews)
+		assert.Equal(t, 2, report.RubberStamps.QuickApprovals)
+		assert.Equal(t, 1, report.RubberStamps.RubberStamps)
+
+		loads := map[string]*ReviewerLoad{}
+		for _, r := range report.Reviewers {
+			loads[r.Username] = r
+		}
+		assert.Equal(t, 1, loads["bob"].PendingRequests)
+		assert.Equal(t, 2, loads["cat"].Reviews)
+		assert.NotContains(t, loads, "ann")
+	})
+
+	t.Run("outside the window", func(t *testing.T) {
+		start := opened.Add(time.Hour)
+		report, err := svc.GetOrganizationReviewAnalytics(ctx, org.ID, ReviewAnalyticsFilters{StartDate: &start})
+		require.NoError(t, err)
+		assert.Equal(t, 0, report.PullRequests)
+		assert.Equal(t, 0, report.TimeToFirstReview.Count)
+		// Pending requests are current, whatever the window
+		require.Len(t, report.Reviewers, 1)
+		assert.Equal(t, 1, report.Reviewers[0].PendingRequests)
+	})
+}
@@ -2,10 +2,17 @@ package services
 
 import (
 	"context"
+	"database/sql/driver"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/jobs"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -17,6 +24,8 @@ type AnalyticsService interface {
 	// Event tracking
 	RecordEvent(ctx context.Context, event *models.AnalyticsEvent) error
 	GetEvents(ctx context.Context, filters EventFilters) ([]*models.AnalyticsEvent, int64, error)
+	// QueryEvents aggregates events with the query DSL
+	QueryEvents(ctx context.Context, query EventQuery) (*EventQueryResult, error)
 
 	// Metrics recording and querying
 	RecordMetric(ctx context.Context, metric *models.AnalyticsMetric) error
@@ -32,6 +41,7 @@ type AnalyticsService interface {
 
 	GetRepositoryPRStats(ctx context.Context, repoID uuid.UUID, filters InsightFilters) (*PullRequestStatistics, error)
 	GetRepositoryPerformanceStats(ctx context.Context, repoID uuid.UUID, filters InsightFilters) (*PerformanceStatistics, error)
+	GetRepositoryReviewAnalytics(ctx context.Context, repoID uuid.UUID, filters ReviewAnalyticsFilters) (*ReviewAnalytics, error)
 
 	// User analytics
 	GetUserAnalytics(ctx context.Context, userID uuid.UUID, period Period) (*models.UserAnalytics, error)
@@ -42,6 +52,9 @@ type AnalyticsService interface {
 	GetOrganizationAnalytics(ctx context.Context, orgID uuid.UUID, period Period) (*models.OrganizationAnalytics, error)
 	UpdateOrganizationAnalytics(ctx context.Context, orgID uuid.UUID, date time.Time) error
 	GetOrganizationInsights(ctx context.Context, orgID uuid.UUID, filters InsightFilters) (*OrganizationInsights, error)
+	GetInactiveMembers(ctx context.Context, orgID uuid.UUID, filters InactiveMemberFilters) (*InactiveMembersReport, error)
+	GetOrganizationLanguages(ctx context.Context, orgID, viewerID uuid.UUID, filters LanguageReportFilters) (*OrganizationLanguageReport, error)
+	GetOrganizationReviewAnalytics(ctx context.Context, orgID uuid.UUID, filters ReviewAnalyticsFilters) (*ReviewAnalytics, error)
 
 	// System analytics
 	GetSystemAnalytics(ctx context.Context, period Period) (*models.SystemAnalytics, error)
@@ -55,6 +68,12 @@ type AnalyticsService interface {
 
 	// Data aggregation and reporting
 	AggregateMetrics(ctx context.Context, period Period) error
+	// UseQueue runs the aggregations QueueAggregation requests as queued
+	// jobs, which are retried when they fail and survive restarts
+	UseQueue(queue *jobs.Queue)
+	// QueueAggregation requests an AggregateMetrics run, which runs right
+	// away when no queue is used
+	QueueAggregation(ctx context.Context, period Period) error
 	GenerateReport(ctx context.Context, reportType ReportType, filters ReportFilters) (*Report, error)
 	ExportData(ctx context.Context, exportType ExportType, filters ExportFilters) ([]byte, error)
 }
@@ -100,6 +119,8 @@ type InsightFilters struct {
 	StartDate *time.Time `json:"start_date,omitempty"`
 	EndDate   *time.Time `json:"end_date,omitempty"`
 	Period    Period     `json:"period,omitempty"`
+	// Location buckets daily series by the viewer's calendar day; nil is UTC
+	Location *time.Location `json:"-"`
 }
 
 // PerformanceFilters for filtering performance logs
@@ -156,11 +177,14 @@ type ContributorStatistics struct {
 }
 
 type ContributorStat struct {
-	UserID       uuid.UUID `json:"user_id"`
-	Username     string    `json:"username"`
-	CommitCount  int64     `json:"commit_count"`
-	LinesAdded   int64     `json:"lines_added"`
-	LinesDeleted int64     `json:"lines_deleted"`
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	// CommitCount includes the CoAuthoredCommits; lines only count
+	// authored commits
+	CommitCount       int64 `json:"commit_count"`
+	CoAuthoredCommits int64 `json:"co_authored_commits"`
+	LinesAdded        int64 `json:"lines_added"`
+	LinesDeleted      int64 `json:"lines_deleted"`
 }
 
 type PullRequestStatistics struct {
@@ -218,6 +242,7 @@ type OrganizationInsights struct {
 	RepositoryStats *OrganizationRepositoryStats    `json:"repository_stats"`
 	ActivityStats   *OrganizationActivityStats      `json:"activity_stats"`
 	ResourceStats   *OrganizationResourceStats      `json:"resource_stats"`
+	TimeTracking    *TimeReport                     `json:"time_tracking"`
 }
 
 type OrganizationMemberStats struct {
@@ -248,6 +273,39 @@ type OrganizationResourceStats struct {
 	ResourceTrend      []TimeSeriesPoint `json:"resource_trend"`
 }
 
+// InactiveMemberFilters selects the window used by the inactive-seat report
+type InactiveMemberFilters struct {
+	Days                   int  `json:"days"`
+	IncludeServiceAccounts bool `json:"include_service_accounts"`
+}
+
+// InactiveMembersReport lists organization members with no activity since a
+// cutoff, to support seat reclamation
+type InactiveMembersReport struct {
+	Since         time.Time         `json:"since"`
+	Days          int               `json:"days"`
+	TotalMembers  int               `json:"total_members"`
+	InactiveCount int               `json:"inactive_count"`
+	Members       []*InactiveMember `json:"members"`
+}
+
+// InactiveMember is a member of an InactiveMembersReport. Last* fields are
+// nil when the member has never had that kind of activity.
+type InactiveMember struct {
+	UserID            uuid.UUID  `json:"user_id"`
+	Username          string     `json:"username"`
+	Email             string     `json:"email"`
+	FullName          string     `json:"full_name"`
+	Role              string     `json:"role"`
+	IsServiceAccount  bool       `json:"is_service_account"`
+	JoinedAt          time.Time  `json:"joined_at"`
+	LastLoginAt       *time.Time `json:"last_login_at"`
+	LastCommitAt      *time.Time `json:"last_commit_at"`
+	LastPullRequestAt *time.Time `json:"last_pull_request_at"`
+	LastCommentAt     *time.Time `json:"last_comment_at"`
+	LastActiveAt      *time.Time `json:"last_active_at"`
+}
+
 // System Insights
 type SystemInsights struct {
 	Analytics        []*models.SystemAnalytics `json:"analytics"`
@@ -360,15 +418,23 @@ type ExportFilters struct {
 
 // analyticsService implements AnalyticsService
 type analyticsService struct {
-	db     *gorm.DB
-	logger *logrus.Logger
+	db *gorm.DB
+	// archive stitches compacted months into repository analytics; nil
+	// reads daily snapshots only
+	archive AnalyticsArchiveService
+	logger  *logrus.Logger
+	now     func() time.Time
+	// queue runs aggregations; nil runs them inline
+	queue *jobs.Queue
 }
 
 // NewAnalyticsService creates a new analytics service
-func NewAnalyticsService(db *gorm.DB, logger *logrus.Logger) AnalyticsService {
+func NewAnalyticsService(db *gorm.DB, archive AnalyticsArchiveService, logger *logrus.Logger) AnalyticsService {
 	return &analyticsService{
-		db:     db,
-		logger: logger,
+		db:      db,
+		archive: archive,
+		logger:  logger,
+		now:     time.Now,
 	}
 }
 
@@ -392,28 +458,7 @@ func (s *analyticsService) RecordEvent(ctx context.Context, event *models.Analyt
 func (s *analyticsService) GetEvents(ctx context.Context, filters EventFilters) ([]*models.AnalyticsEvent, int64, error) {
 	query := s.db.WithContext(ctx).Model(&models.AnalyticsEvent{})
 
-	// Apply filters
-	if len(filters.EventTypes) > 0 {
-		query = query.Where("event_type IN ?", filters.EventTypes)
-	}
-	if filters.ActorID != nil {
-		query = query.Where("actor_id = ?", *filters.ActorID)
-	}
-	if filters.RepositoryID != nil {
-		query = query.Where("repository_id = ?", *filters.RepositoryID)
-	}
-	if filters.OrganizationID != nil {
-		query = query.Where("organization_id = ?", *filters.OrganizationID)
-	}
-	if filters.StartDate != nil {
-		query = query.Where("created_at >= ?", *filters.StartDate)
-	}
-	if filters.EndDate != nil {
-		query = query.Where("created_at <= ?", *filters.EndDate)
-	}
-	if filters.Status != "" {
-		query = query.Where("status = ?", filters.Status)
-	}
+	query = applyEventFilters(query, filters)
 
 	// Get total count
 	var total int64
@@ -489,6 +534,17 @@ func (s *analyticsService) GetMetrics(ctx context.Context, filters MetricFilters
 
 // Placeholder implementations for other methods (to be implemented)
 func (s *analyticsService) GetRepositoryAnalytics(ctx context.Context, repoID uuid.UUID, period Period) (*models.RepositoryAnalytics, error) {
+	if s.archive != nil {
+		latest, err := s.archive.LatestRepositoryAnalytics(ctx, repoID)
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil {
+			return nil, fmt.Errorf("repository analytics not found")
+		}
+		return latest, nil
+	}
+
 	var analytics models.RepositoryAnalytics
 	err := s.db.WithContext(ctx).Where("repository_id = ?", repoID).
 		Order("date DESC").First(&analytics).Error
@@ -521,11 +577,6 @@ func (s *analyticsService) GetRepositoryPerformanceStats(ctx context.Context, re
 	return s.getRepositoryPerformanceStats(ctx, repoID, filters)
 }
 
-func (s *analyticsService) UpdateRepositoryAnalytics(ctx context.Context, repoID uuid.UUID, date time.Time) error {
-	// Implementation will be added
-	return fmt.Errorf("not implemented yet")
-}
-
 func (s *analyticsService) GetRepositoryInsights(ctx context.Context, repoID uuid.UUID, filters InsightFilters) (*RepositoryInsights, error) {
 	// Get repository details
 	var repository models.Repository
@@ -687,6 +738,16 @@ func (s *analyticsService) GetOrganizationInsights(ctx context.Context, orgID uu
 		return nil, fmt.Errorf("failed to get resource stats: %w", err)
 	}
 
+	// Roll up time tracked on issues across the organization's repositories
+	timeTracking, err := buildTimeReport(ctx, s.db, TimeReportFilter{
+		OrganizationID: &orgID,
+		Since:          filters.StartDate,
+		Until:          filters.EndDate,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get time tracking stats: %w", err)
+	}
+
 	return &OrganizationInsights{
 		Organization:    &organization,
 		Analytics:       analytics,
@@ -694,6 +755,7 @@ func (s *analyticsService) GetOrganizationInsights(ctx context.Context, orgID uu
 		RepositoryStats: repositoryStats,
 		ActivityStats:   activityStats,
 		ResourceStats:   resourceStats,
+		TimeTracking:    timeTracking,
 	}, nil
 }
 
@@ -702,11 +764,6 @@ func (s *analyticsService) GetSystemAnalytics(ctx context.Context, period Period
 	return nil, fmt.Errorf("not implemented yet")
 }
 
-func (s *analyticsService) UpdateSystemAnalytics(ctx context.Context, date time.Time) error {
-	// Implementation will be added
-	return fmt.Errorf("not implemented yet")
-}
-
 func (s *analyticsService) GetSystemInsights(ctx context.Context, filters InsightFilters) (*SystemInsights, error) {
 	// Get system analytics data
 	analytics, err := s.getSystemAnalyticsData(ctx, filters)
@@ -917,11 +974,6 @@ func (s *analyticsService) GetPerformanceMetrics(ctx context.Context, filters Pe
 	}, nil
 }
 
-func (s *analyticsService) AggregateMetrics(ctx context.Context, period Period) error {
-	// Implementation will be added
-	return fmt.Errorf("not implemented yet")
-}
-
 func (s *analyticsService) GenerateReport(ctx context.Context, reportType ReportType, filters ReportFilters) (*Report, error) {
 	// Implementation will be added
 	return nil, fmt.Errorf("not implemented yet")
@@ -995,6 +1047,10 @@ func (s *analyticsService) ExportData(ctx context.Context, exportType ExportType
 // Helper methods for repository analytics
 
 func (s *analyticsService) getRepositoryAnalyticsData(ctx context.Context, repoID uuid.UUID, filters InsightFilters) ([]*models.RepositoryAnalytics, error) {
+	if s.archive != nil {
+		return s.archive.RepositoryAnalytics(ctx, repoID, filters)
+	}
+
 	query := s.db.WithContext(ctx).Model(&models.RepositoryAnalytics{}).Where("repository_id = ?", repoID)
 
 	if filters.StartDate != nil {
@@ -1012,6 +1068,26 @@ func (s *analyticsService) getRepositoryAnalyticsData(ctx context.Context, repoI
 	return analytics, nil
 }
 
+// latestRepositoryAnalytics returns the most recent snapshot of a
+// repository, including compacted months, or a zero snapshot when there is none
+func (s *analyticsService) latestRepositoryAnalytics(ctx context.Context, repoID uuid.UUID) (*models.RepositoryAnalytics, error) {
+	if s.archive != nil {
+		latest, err := s.archive.LatestRepositoryAnalytics(ctx, repoID)
+		if err != nil || latest != nil {
+			return latest, err
+		}
+		return &models.RepositoryAnalytics{}, nil
+	}
+
+	var latest models.RepositoryAnalytics
+	err := s.db.WithContext(ctx).Where("repository_id = ?", repoID).
+		Order("date DESC").First(&latest).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, fmt.Errorf("failed to get repository analytics: %w", err)
+	}
+	return &latest, nil
+}
+
 func parseLanguageStats(raw string) (map[string]int64, error) {
 	var result map[string]int64
 	if err := json.Unmarshal([]byte(raw), &result); err != nil {
@@ -1022,11 +1098,9 @@ func parseLanguageStats(raw string) (map[string]int64, error) {
 
 func (s *analyticsService) getRepositoryCodeStats(ctx context.Context, repoID uuid.UUID) (*CodeStatistics, error) {
 	// Get latest repository analytics for code stats
-	var latest models.RepositoryAnalytics
-	err := s.db.WithContext(ctx).Where("repository_id = ?", repoID).
-		Order("date DESC").First(&latest).Error
-	if err != nil && err != gorm.ErrRecordNotFound {
-		return nil, fmt.Errorf("failed to get repository analytics: %w", err)
+	latest, err := s.latestRepositoryAnalytics(ctx, repoID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get commits count from database
@@ -1072,11 +1146,9 @@ func (s *analyticsService) getRepositoryCodeStats(ctx context.Context, repoID uu
 
 func (s *analyticsService) getRepositoryActivityStats(ctx context.Context, repoID uuid.UUID, filters InsightFilters) (*ActivityStatistics, error) {
 	// Get latest repository analytics for activity stats
-	var latest models.RepositoryAnalytics
-	err := s.db.WithContext(ctx).Where("repository_id = ?", repoID).
-		Order("date DESC").First(&latest).Error
-	if err != nil && err != gorm.ErrRecordNotFound {
-		return nil, fmt.Errorf("failed to get repository analytics: %w", err)
+	latest, err := s.latestRepositoryAnalytics(ctx, repoID)
+	if err != nil {
+		return nil, err
 	}
 
 	// Get activity trend data
@@ -1097,75 +1169,53 @@ func (s *analyticsService) getRepositoryActivityStats(ctx context.Context, repoI
 }
 
 func (s *analyticsService) getRepositoryContributorStats(ctx context.Context, repoID uuid.UUID, filters InsightFilters) (*ContributorStatistics, error) {
-	// Get contributor data from commits
-	type contributorData struct {
-		UserID       uuid.UUID `json:"user_id"`
-		Username     string    `json:"username"`
-		CommitCount  int64     `json:"commit_count"`
-		LinesAdded   int64     `json:"lines_added"`
-		LinesDeleted int64     `json:"lines_deleted"`
-	}
-
-	query := `
-		SELECT 
-			u.id as user_id,
-			u.username,
-			COUNT(c.id) as commit_count,
-			COALESCE(SUM(c.additions), 0) as lines_added,
-			COALESCE(SUM(c.deletions), 0) as lines_deleted
-		FROM commits c
-		JOIN users u ON c.author_id = u.id
-		WHERE c.repository_id = ?
-	`
-
-	if filters.StartDate != nil {
-		query += " AND c.created_at >= ?"
-	}
-	if filters.EndDate != nil {
-		query += " AND c.created_at <= ?"
+	// Commits are attributed to their author and to the co-authors named in
+	// Co-authored-by trailers, by email
+	contributions, err := s.repositoryContributions(ctx, repoID, filters.StartDate, filters.EndDate)
+	if err != nil {
+		return nil, err
 	}
 
-	query += " GROUP BY u.id, u.username ORDER BY commit_count DESC LIMIT 10"
-
-	var args []interface{}
-	args = append(args, repoID)
-	if filters.StartDate != nil {
-		args = append(args, *filters.StartDate)
-	}
-	if filters.EndDate != nil {
-		args = append(args, *filters.EndDate)
+	emails := make([]string, 0, len(contributions))
+	for email := range contributions {
+		emails = append(emails, email)
 	}
-
-	var contributors []contributorData
-	if err := s.db.WithContext(ctx).Raw(query, args...).Scan(&contributors).Error; err != nil {
-		return nil, fmt.Errorf("failed to get contributors: %w", err)
+	var users []models.User
+	if len(emails) > 0 {
+		if err := s.db.WithContext(ctx).Select("id", "username", "email").
+			Where("LOWER(email) IN ?", emails).Find(&users).Error; err != nil {
+			return nil, fmt.Errorf("failed to get contributors: %w", err)
+		}
 	}
 
 	// Convert to ContributorStat format
 	var topContributors []ContributorStat
-	for _, c := range contributors {
+	for _, user := range users {
+		c := contributions[strings.ToLower(user.Email)]
 		topContributors = append(topContributors, ContributorStat{
-			UserID:       c.UserID,
-			Username:     c.Username,
-			CommitCount:  c.CommitCount,
-			LinesAdded:   c.LinesAdded,
-			LinesDeleted: c.LinesDeleted,
+			UserID:            user.ID,
+			Username:          user.Username,
+			CommitCount:       c.authored + c.coAuthored,
+			CoAuthoredCommits: c.coAuthored,
+			LinesAdded:        c.linesAdded,
+			LinesDeleted:      c.linesDeleted,
 		})
 	}
+	sort.SliceStable(topContributors, func(i, j int) bool {
+		return topContributors[i].CommitCount > topContributors[j].CommitCount
+	})
+	if len(topContributors) > 10 {
+		topContributors = topContributors[:10]
+	}
 
 	// Get total and active contributor counts
-	var totalContributors int64
-	var activeContributors int64
-
-	s.db.WithContext(ctx).Model(&models.Commit{}).
-		Where("repository_id = ?", repoID).
-		Distinct("author_id").Count(&totalContributors)
-
-	// Active contributors in the last 30 days
+	totalContributors := int64(len(contributions))
 	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
-	s.db.WithContext(ctx).Model(&models.Commit{}).
-		Where("repository_id = ? AND created_at >= ?", repoID, thirtyDaysAgo).
-		Distinct("author_id").Count(&activeContributors)
+	active, err := s.repositoryContributions(ctx, repoID, &thirtyDaysAgo, nil)
+	if err != nil {
+		return nil, err
+	}
+	activeContributors := int64(len(active))
 
 	contributorActivity, err := s.getContributorActivity(ctx, repoID, filters)
 	if err != nil {
@@ -1181,6 +1231,73 @@ func (s *analyticsService) getRepositoryContributorStats(ctx context.Context, re
 	}, nil
 }
 
+// contribution is what one email contributed to a repository
+type contribution struct {
+	authored, coAuthored     int64
+	linesAdded, linesDeleted int64
+}
+
+// repositoryContributions maps lowercased emails to their commits to a
+// repository, crediting co-authors named in Co-authored-by trailers
+func (s *analyticsService) repositoryContributions(ctx context.Context, repoID uuid.UUID, since, until *time.Time) (map[string]*contribution, error) {
+	window := func(query *gorm.DB) *gorm.DB {
+		if since != nil {
+			query = query.Where("commits.created_at >= ?", *since)
+		}
+		if until != nil {
+			query = query.Where("commits.created_at <= ?", *until)
+		}
+		return query
+	}
+
+	var authored []struct {
+		Email        string
+		CommitCount  int64
+		LinesAdded   int64
+		LinesDeleted int64
+	}
+	if err := window(s.db.WithContext(ctx).Model(&models.Commit{})).
+		Select("LOWER(author_email) as email, COUNT(*) as commit_count, COALESCE(SUM(additions), 0) as lines_added, COALESCE(SUM(deletions), 0) as lines_deleted").
+		Where("commits.repository_id = ?", repoID).
+		Group("LOWER(author_email)").
+		Scan(&authored).Error; err != nil {
+		return nil, fmt.Errorf("failed to get contributors: %w", err)
+	}
+
+	var coAuthored []struct {
+		Email       string
+		CommitCount int64
+	}
+	if err := window(s.db.WithContext(ctx).Model(&models.CommitTrailer{})).
+		Select("commit_trailers.email as email, COUNT(DISTINCT commit_trailers.commit_id) as commit_count").
+		Joins("JOIN commits ON commits.id = commit_trailers.commit_id").
+		Where("commit_trailers.repository_id = ? AND commit_trailers.key = ? AND commit_trailers.email <> ''", repoID, git.TrailerCoAuthoredBy).
+		Group("commit_trailers.email").
+		Scan(&coAuthored).Error; err != nil {
+		return nil, fmt.Errorf("failed to get co-authors: %w", err)
+	}
+
+	contributions := make(map[string]*contribution)
+	get := func(email string) *contribution {
+		c, ok := contributions[email]
+		if !ok {
+			c = &contribution{}
+			contributions[email] = c
+		}
+		return c
+	}
+	for _, a := range authored {
+		c := get(a.Email)
+		c.authored += a.CommitCount
+		c.linesAdded += a.LinesAdded
+		c.linesDeleted += a.LinesDeleted
+	}
+	for _, a := range coAuthored {
+		get(a.Email).coAuthored += a.CommitCount
+	}
+	return contributions, nil
+}
+
 func (s *analyticsService) getRepositoryPRStats(ctx context.Context, repoID uuid.UUID, filters InsightFilters) (*PullRequestStatistics, error) {
 	var totalPRs, openPRs, mergedPRs, closedPRs int64
 
@@ -1277,6 +1394,23 @@ func (s *analyticsService) getRepositoryPerformanceStats(ctx context.Context, re
 
 // Helper methods for time series data
 
+// dayBucket returns the SQL expression truncating column to a calendar day
+// in loc. Only PostgreSQL converts timezones; other databases bucket in UTC.
+func (s *analyticsService) dayBucket(column string, loc *time.Location) string {
+	if loc == nil || loc == time.UTC || s.db.Dialector.Name() != "postgres" {
+		return "DATE(" + column + ")"
+	}
+	return fmt.Sprintf("DATE(%s AT TIME ZONE '%s')", column, strings.ReplaceAll(loc.String(), "'", "''"))
+}
+
+// localDay returns the start of a bucketed day in loc
+func localDay(date time.Time, loc *time.Location) time.Time {
+	if loc == nil {
+		return date
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+}
+
 func (s *analyticsService) getCommitActivity(ctx context.Context, repoID uuid.UUID, days int) ([]TimeSeriesPoint, error) {
 	since := time.Now().AddDate(0, 0, -days)
 
@@ -1320,10 +1454,10 @@ func (s *analyticsService) getActivityTrend(ctx context.Context, repoID uuid.UUI
 	}
 
 	err := s.db.WithContext(ctx).Model(&models.AnalyticsEvent{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
+		Select(s.dayBucket("created_at", filters.Location)+" as date, COUNT(*) as count").
 		Where("repository_id = ? AND created_at >= ? AND event_type IN (?)",
 			repoID, since, []string{"repository.clone", "repository.view"}).
-		Group("DATE(created_at)").
+		Group("date").
 		Order("date ASC").
 		Scan(&results).Error
 
@@ -1334,7 +1468,7 @@ func (s *analyticsService) getActivityTrend(ctx context.Context, repoID uuid.UUI
 	var trend []TimeSeriesPoint
 	for _, r := range results {
 		trend = append(trend, TimeSeriesPoint{
-			Timestamp: r.Date,
+			Timestamp: localDay(r.Date, filters.Location),
 			Value:     float64(r.Count),
 		})
 	}
@@ -1354,9 +1488,9 @@ func (s *analyticsService) getContributorActivity(ctx context.Context, repoID uu
 	}
 
 	err := s.db.WithContext(ctx).Model(&models.Commit{}).
-		Select("DATE(created_at) as date, COUNT(DISTINCT author_id) as count").
+		Select(s.dayBucket("created_at", filters.Location)+" as date, COUNT(DISTINCT author_id) as count").
 		Where("repository_id = ? AND created_at >= ?", repoID, since).
-		Group("DATE(created_at)").
+		Group("date").
 		Order("date ASC").
 		Scan(&results).Error
 
@@ -1367,7 +1501,7 @@ func (s *analyticsService) getContributorActivity(ctx context.Context, repoID uu
 	var activity []TimeSeriesPoint
 	for _, r := range results {
 		activity = append(activity, TimeSeriesPoint{
-			Timestamp: r.Date,
+			Timestamp: localDay(r.Date, filters.Location),
 			Value:     float64(r.Count),
 		})
 	}
@@ -1387,9 +1521,9 @@ func (s *analyticsService) getPRActivity(ctx context.Context, repoID uuid.UUID,
 	}
 
 	err := s.db.WithContext(ctx).Model(&models.PullRequest{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
+		Select(s.dayBucket("created_at", filters.Location)+" as date, COUNT(*) as count").
 		Where("repository_id = ? AND created_at >= ?", repoID, since).
-		Group("DATE(created_at)").
+		Group("date").
 		Order("date ASC").
 		Scan(&results).Error
 
@@ -1400,7 +1534,7 @@ func (s *analyticsService) getPRActivity(ctx context.Context, repoID uuid.UUID,
 	var activity []TimeSeriesPoint
 	for _, r := range results {
 		activity = append(activity, TimeSeriesPoint{
-			Timestamp: r.Date,
+			Timestamp: localDay(r.Date, filters.Location),
 			Value:     float64(r.Count),
 		})
 	}
@@ -1420,9 +1554,9 @@ func (s *analyticsService) getThroughputTrend(ctx context.Context, repoID uuid.U
 	}
 
 	err := s.db.WithContext(ctx).Model(&models.PerformanceLog{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
+		Select(s.dayBucket("created_at", filters.Location)+" as date, COUNT(*) as count").
 		Where("repository_id = ? AND created_at >= ?", repoID, since).
-		Group("DATE(created_at)").
+		Group("date").
 		Order("date ASC").
 		Scan(&results).Error
 
@@ -1433,7 +1567,7 @@ func (s *analyticsService) getThroughputTrend(ctx context.Context, repoID uuid.U
 	var trend []TimeSeriesPoint
 	for _, r := range results {
 		trend = append(trend, TimeSeriesPoint{
-			Timestamp: r.Date,
+			Timestamp: localDay(r.Date, filters.Location),
 			Value:     float64(r.Count),
 		})
 	}
@@ -1490,8 +1624,12 @@ func (s *analyticsService) getUserContributionStats(ctx context.Context, userID
 	// Get contribution data from various sources
 	var totalCommits, totalPullRequests, totalComments int64
 
-	// Count commits by user
-	commitQuery := s.db.WithContext(ctx).Model(&models.Commit{}).Where("author_id = ?", userID)
+	// Count commits the user authored or co-authored
+	var user models.User
+	if err := s.db.WithContext(ctx).Select("email").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	commitQuery := AttributedCommits(s.db.WithContext(ctx).Model(&models.Commit{}), user.Email)
 	if filters.StartDate != nil {
 		commitQuery = commitQuery.Where("created_at >= ?", *filters.StartDate)
 	}
@@ -1585,10 +1723,10 @@ func (s *analyticsService) getUserActivityTrend(ctx context.Context, userID uuid
 	}
 
 	err := s.db.WithContext(ctx).Model(&models.AnalyticsEvent{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
+		Select(s.dayBucket("created_at", filters.Location)+" as date, COUNT(*) as count").
 		Where("actor_id = ? AND created_at >= ? AND event_type IN (?)",
 			userID, since, []string{"user.login", "page.view"}).
-		Group("DATE(created_at)").
+		Group("date").
 		Order("date ASC").
 		Scan(&results).Error
 
@@ -1599,7 +1737,7 @@ func (s *analyticsService) getUserActivityTrend(ctx context.Context, userID uuid
 	var trend []TimeSeriesPoint
 	for _, r := range results {
 		trend = append(trend, TimeSeriesPoint{
-			Timestamp: r.Date,
+			Timestamp: localDay(r.Date, filters.Location),
 			Value:     float64(r.Count),
 		})
 	}
@@ -1618,10 +1756,15 @@ func (s *analyticsService) getUserContributionTrend(ctx context.Context, userID
 		Count int64     `json:"count"`
 	}
 
-	err := s.db.WithContext(ctx).Model(&models.Commit{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
-		Where("author_id = ? AND created_at >= ?", userID, since).
-		Group("DATE(created_at)").
+	var user models.User
+	if err := s.db.WithContext(ctx).Select("email").First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+
+	err := AttributedCommits(s.db.WithContext(ctx).Model(&models.Commit{}), user.Email).
+		Select(s.dayBucket("created_at", filters.Location)+" as date, COUNT(*) as count").
+		Where("created_at >= ?", since).
+		Group("date").
 		Order("date ASC").
 		Scan(&results).Error
 
@@ -1632,7 +1775,7 @@ func (s *analyticsService) getUserContributionTrend(ctx context.Context, userID
 	var trend []TimeSeriesPoint
 	for _, r := range results {
 		trend = append(trend, TimeSeriesPoint{
-			Timestamp: r.Date,
+			Timestamp: localDay(r.Date, filters.Location),
 			Value:     float64(r.Count),
 		})
 	}
@@ -1652,9 +1795,9 @@ func (s *analyticsService) getUserRepositoryTrend(ctx context.Context, userID uu
 	}
 
 	err := s.db.WithContext(ctx).Model(&models.Repository{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
+		Select(s.dayBucket("created_at", filters.Location)+" as date, COUNT(*) as count").
 		Where("owner_id = ? AND owner_type = ? AND created_at >= ?", userID, "user", since).
-		Group("DATE(created_at)").
+		Group("date").
 		Order("date ASC").
 		Scan(&results).Error
 
@@ -1665,7 +1808,7 @@ func (s *analyticsService) getUserRepositoryTrend(ctx context.Context, userID uu
 	var trend []TimeSeriesPoint
 	for _, r := range results {
 		trend = append(trend, TimeSeriesPoint{
-			Timestamp: r.Date,
+			Timestamp: localDay(r.Date, filters.Location),
 			Value:     float64(r.Count),
 		})
 	}
@@ -1859,9 +2002,9 @@ func (s *analyticsService) getSystemUserTrend(ctx context.Context, filters Insig
 	}
 
 	err := s.db.WithContext(ctx).Model(&models.User{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
+		Select(s.dayBucket("created_at", filters.Location)+" as date, COUNT(*) as count").
 		Where("created_at >= ?", since).
-		Group("DATE(created_at)").
+		Group("date").
 		Order("date ASC").
 		Scan(&results).Error
 
@@ -1872,7 +2015,7 @@ func (s *analyticsService) getSystemUserTrend(ctx context.Context, filters Insig
 	var trend []TimeSeriesPoint
 	for _, r := range results {
 		trend = append(trend, TimeSeriesPoint{
-			Timestamp: r.Date,
+			Timestamp: localDay(r.Date, filters.Location),
 			Value:     float64(r.Count),
 		})
 	}
@@ -1892,9 +2035,9 @@ func (s *analyticsService) getSystemRepositoryTrend(ctx context.Context, filters
 	}
 
 	err := s.db.WithContext(ctx).Model(&models.Repository{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
+		Select(s.dayBucket("created_at", filters.Location)+" as date, COUNT(*) as count").
 		Where("created_at >= ?", since).
-		Group("DATE(created_at)").
+		Group("date").
 		Order("date ASC").
 		Scan(&results).Error
 
@@ -1905,7 +2048,7 @@ func (s *analyticsService) getSystemRepositoryTrend(ctx context.Context, filters
 	var trend []TimeSeriesPoint
 	for _, r := range results {
 		trend = append(trend, TimeSeriesPoint{
-			Timestamp: r.Date,
+			Timestamp: localDay(r.Date, filters.Location),
 			Value:     float64(r.Count),
 		})
 	}
@@ -1925,9 +2068,9 @@ func (s *analyticsService) getSystemPerformanceTrend(ctx context.Context, filter
 	}
 
 	err := s.db.WithContext(ctx).Model(&models.PerformanceLog{}).
-		Select("DATE(created_at) as date, AVG(duration) as avg").
+		Select(s.dayBucket("created_at", filters.Location)+" as date, AVG(duration) as avg").
 		Where("created_at >= ?", since).
-		Group("DATE(created_at)").
+		Group("date").
 		Order("date ASC").
 		Scan(&results).Error
 
@@ -1938,7 +2081,7 @@ func (s *analyticsService) getSystemPerformanceTrend(ctx context.Context, filter
 	var trend []TimeSeriesPoint
 	for _, r := range results {
 		trend = append(trend, TimeSeriesPoint{
-			Timestamp: r.Date,
+			Timestamp: localDay(r.Date, filters.Location),
 			Value:     r.Avg,
 		})
 	}
@@ -2126,9 +2269,9 @@ func (s *analyticsService) getOrganizationMemberTrend(ctx context.Context, orgID
 	}
 
 	err := s.db.WithContext(ctx).Model(&models.OrganizationMember{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
+		Select(s.dayBucket("created_at", filters.Location)+" as date, COUNT(*) as count").
 		Where("organization_id = ? AND created_at >= ?", orgID, since).
-		Group("DATE(created_at)").
+		Group("date").
 		Order("date ASC").
 		Scan(&results).Error
 
@@ -2139,7 +2282,7 @@ func (s *analyticsService) getOrganizationMemberTrend(ctx context.Context, orgID
 	var trend []TimeSeriesPoint
 	for _, r := range results {
 		trend = append(trend, TimeSeriesPoint{
-			Timestamp: r.Date,
+			Timestamp: localDay(r.Date, filters.Location),
 			Value:     float64(r.Count),
 		})
 	}
@@ -2159,9 +2302,9 @@ func (s *analyticsService) getOrganizationRepositoryTrend(ctx context.Context, o
 	}
 
 	err := s.db.WithContext(ctx).Model(&models.Repository{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
+		Select(s.dayBucket("created_at", filters.Location)+" as date, COUNT(*) as count").
 		Where("owner_id = ? AND owner_type = ? AND created_at >= ?", orgID, "organization", since).
-		Group("DATE(created_at)").
+		Group("date").
 		Order("date ASC").
 		Scan(&results).Error
 
@@ -2172,7 +2315,7 @@ func (s *analyticsService) getOrganizationRepositoryTrend(ctx context.Context, o
 	var trend []TimeSeriesPoint
 	for _, r := range results {
 		trend = append(trend, TimeSeriesPoint{
-			Timestamp: r.Date,
+			Timestamp: localDay(r.Date, filters.Location),
 			Value:     float64(r.Count),
 		})
 	}
@@ -2192,9 +2335,9 @@ func (s *analyticsService) getOrganizationActivityTrend(ctx context.Context, org
 	}
 
 	err := s.db.WithContext(ctx).Model(&models.AnalyticsEvent{}).
-		Select("DATE(created_at) as date, COUNT(*) as count").
+		Select(s.dayBucket("created_at", filters.Location)+" as date, COUNT(*) as count").
 		Where("organization_id = ? AND created_at >= ?", orgID, since).
-		Group("DATE(created_at)").
+		Group("date").
 		Order("date ASC").
 		Scan(&results).Error
 
@@ -2205,7 +2348,7 @@ func (s *analyticsService) getOrganizationActivityTrend(ctx context.Context, org
 	var trend []TimeSeriesPoint
 	for _, r := range results {
 		trend = append(trend, TimeSeriesPoint{
-			Timestamp: r.Date,
+			Timestamp: localDay(r.Date, filters.Location),
 			Value:     float64(r.Count),
 		})
 	}
@@ -2345,3 +2488,225 @@ func (s *analyticsService) getErrorRateTrend(ctx context.Context, filters Perfor
 
 	return trend, nil
 }
+
+// defaultInactiveDays is the inactivity window used when none is given
+const defaultInactiveDays = 90
+
+// activityTime scans a MAX() over a timestamp column. SQLite returns
+// aggregates of timestamps as text, so both forms are accepted.
+type activityTime struct {
+	Time  time.Time
+	Valid bool
+}
+
+func (t *activityTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		t.Valid = false
+		return nil
+	case time.Time:
+		t.Time, t.Valid = v, true
+		return nil
+	case []byte:
+		return t.parse(string(v))
+	case string:
+		return t.parse(v)
+	}
+	return fmt.Errorf("cannot scan %T into activity time", value)
+}
+
+func (t activityTime) Value() (driver.Value, error) {
+	if !t.Valid {
+		return nil, nil
+	}
+	return t.Time, nil
+}
+
+func (t *activityTime) parse(v string) error {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00", "2006-01-02 15:04:05.999999999"} {
+		if parsed, err := time.Parse(layout, v); err == nil {
+			t.Time, t.Valid = parsed, true
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse activity time %q", v)
+}
+
+// GetInactiveMembers reports members of an organization with no commits,
+// pull requests, reviews, comments or logins within the window. Commits,
+// pull requests and comments only count in the organization's repositories;
+// members who joined within the window are not reported.
+func (s *analyticsService) GetInactiveMembers(ctx context.Context, orgID uuid.UUID, filters InactiveMemberFilters) (*InactiveMembersReport, error) {
+	days := filters.Days
+	if days <= 0 {
+		days = defaultInactiveDays
+	}
+	since := time.Now().AddDate(0, 0, -days)
+	db := s.db.WithContext(ctx)
+
+	var members []struct {
+		UserID           uuid.UUID
+		Role             string
+		JoinedAt         time.Time
+		Username         string
+		Email            string
+		FullName         string
+		IsServiceAccount bool
+		LastLoginAt      *time.Time
+	}
+	err := db.Table("organization_members").
+		Select("organization_members.user_id, organization_members.role, organization_members.created_at AS joined_at, users.username, users.email, users.full_name, users.is_service_account, users.last_login_at").
+		Joins("JOIN users ON users.id = organization_members.user_id AND users.deleted_at IS NULL").
+		Where("organization_members.organization_id = ? AND organization_members.deleted_at IS NULL", orgID).
+		Order("users.username ASC").
+		Scan(&members).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load organization members: %w", err)
+	}
+
+	repoIDs := db.Model(&models.Repository{}).Select("id").
+		Where("owner_id = ? AND owner_type = ?", orgID, models.OwnerTypeOrganization)
+	prIDs := db.Model(&models.PullRequest{}).Select("id").Where("repository_id IN (?)", repoIDs)
+	issueIDs := db.Model(&models.Issue{}).Select("id").Where("repository_id IN (?)", repoIDs)
+
+	type userActivity struct {
+		UserID uuid.UUID
+		Last   activityTime
+	}
+	latest := func(dest map[uuid.UUID]time.Time, query *gorm.DB) error {
+		var rows []userActivity
+		if err := query.Where("user_id IS NOT NULL").Group("user_id").Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if row.Last.Valid && row.Last.Time.After(dest[row.UserID]) {
+				dest[row.UserID] = row.Last.Time
+			}
+		}
+		return nil
+	}
+
+	lastPullRequest := make(map[uuid.UUID]time.Time)
+	if err := latest(lastPullRequest, db.Model(&models.PullRequest{}).Select("user_id, MAX(created_at) AS last").Where("repository_id IN (?)", repoIDs)); err != nil {
+		return nil, fmt.Errorf("failed to load pull request activity: %w", err)
+	}
+	if err := latest(lastPullRequest, db.Model(&models.Review{}).Select("user_id, MAX(created_at) AS last").Where("pull_request_id IN (?)", prIDs)); err != nil {
+		return nil, fmt.Errorf("failed to load review activity: %w", err)
+	}
+
+	lastComment := make(map[uuid.UUID]time.Time)
+	if err := latest(lastComment, db.Model(&models.Comment{}).Select("user_id, MAX(created_at) AS last").Where("issue_id IN (?) OR pull_request_id IN (?)", issueIDs, prIDs)); err != nil {
+		return nil, fmt.Errorf("failed to load comment activity: %w", err)
+	}
+	if err := latest(lastComment, db.Model(&models.ReviewComment{}).Select("user_id, MAX(created_at) AS last").Where("pull_request_id IN (?)", prIDs)); err != nil {
+		return nil, fmt.Errorf("failed to load review comment activity: %w", err)
+	}
+
+	// Commits are attributed by author email
+	var commitRows []struct {
+		Email string
+		Last  activityTime
+	}
+	err = db.Model(&models.Commit{}).
+		Select("LOWER(author_email) AS email, MAX(author_date) AS last").
+		Where("repository_id IN (?)", repoIDs).
+		Group("LOWER(author_email)").
+		Scan(&commitRows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load commit activity: %w", err)
+	}
+	lastCommit := make(map[string]time.Time, len(commitRows))
+	for _, row := range commitRows {
+		if row.Last.Valid {
+			lastCommit[row.Email] = row.Last.Time
+		}
+	}
+
+	report := &InactiveMembersReport{
+		Since:        since,
+		Days:         days,
+		TotalMembers: len(members),
+		Members:      []*InactiveMember{},
+	}
+	for _, m := range members {
+		if m.IsServiceAccount && !filters.IncludeServiceAccounts {
+			continue
+		}
+		if m.JoinedAt.After(since) {
+			continue
+		}
+
+		member := &InactiveMember{
+			UserID:           m.UserID,
+			Username:         m.Username,
+			Email:            m.Email,
+			FullName:         m.FullName,
+			Role:             m.Role,
+			IsServiceAccount: m.IsServiceAccount,
+			JoinedAt:         m.JoinedAt,
+			LastLoginAt:      m.LastLoginAt,
+		}
+		if t, ok := lastCommit[strings.ToLower(m.Email)]; ok {
+			member.LastCommitAt = &t
+		}
+		if t, ok := lastPullRequest[m.UserID]; ok {
+			member.LastPullRequestAt = &t
+		}
+		if t, ok := lastComment[m.UserID]; ok {
+			member.LastCommentAt = &t
+		}
+		for _, t := range []*time.Time{member.LastLoginAt, member.LastCommitAt, member.LastPullRequestAt, member.LastCommentAt} {
+			if t != nil && (member.LastActiveAt == nil || t.After(*member.LastActiveAt)) {
+				member.LastActiveAt = t
+			}
+		}
+
+		if member.LastActiveAt == nil || member.LastActiveAt.Before(since) {
+			report.Members = append(report.Members, member)
+		}
+	}
+
+	// Longest inactive first; members never active lead the list
+	sort.SliceStable(report.Members, func(i, j int) bool {
+		a, b := report.Members[i].LastActiveAt, report.Members[j].LastActiveAt
+		if a == nil || b == nil {
+			return a == nil && b != nil
+		}
+		return a.Before(*b)
+	})
+	report.InactiveCount = len(report.Members)
+	return report, nil
+}
+
+// CSV renders the report as CSV with a header row
+func (r *InactiveMembersReport) CSV() ([]byte, error) {
+	var output strings.Builder
+	writer := csv.NewWriter(&output)
+
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	writer.Write([]string{"Username", "Email", "Full Name", "Role", "Service Account", "Joined At", "Last Active At", "Last Login At", "Last Commit At", "Last Pull Request At", "Last Comment At"})
+	for _, m := range r.Members {
+		writer.Write([]string{
+			m.Username,
+			m.Email,
+			m.FullName,
+			m.Role,
+			strconv.FormatBool(m.IsServiceAccount),
+			formatTime(&m.JoinedAt),
+			formatTime(m.LastActiveAt),
+			formatTime(m.LastLoginAt),
+			formatTime(m.LastCommitAt),
+			formatTime(m.LastPullRequestAt),
+			formatTime(m.LastCommentAt),
+		})
+	}
+
+	writer.Flush()
+	return []byte(output.String()), writer.Error()
+}
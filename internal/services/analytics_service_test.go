@@ -43,7 +43,7 @@ func TestAnalyticsService_RecordAndGetEvents(t *testing.T) {
 	`).Error)
 
 	logger := logrus.New()
-	svc := services.NewAnalyticsService(db, logger)
+	svc := services.NewAnalyticsService(db, nil, logger)
 
 	now := time.Now().UTC()
 	actorID := uuid.New()
@@ -0,0 +1,597 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/audit"
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAuditForbidden      = errors.New("only organization owners and admins can read its audit log")
+	ErrAuditStreamDisabled = errors.New("audit streaming is not enabled")
+)
+
+// AuditService keeps the hash-chained audit trail of security-relevant
+// actions, separate from analytics events. It is the audit.Recorder behind
+// audit.Record.
+type AuditService interface {
+	audit.Recorder
+	Query(ctx context.Context, query AuditQuery) ([]*models.AuditEntry, int64, error)
+	QueryOrganization(ctx context.Context, orgName string, actorID uuid.UUID, query AuditQuery) ([]*models.AuditEntry, int64, error)
+	Verify(ctx context.Context) (*AuditVerification, error)
+	Purge(ctx context.Context) (int64, error)
+	Flush(ctx context.Context) (int, error)
+	StreamStatus(ctx context.Context) (*AuditStreamStatus, error)
+	StartScheduler(ctx context.Context)
+}
+
+// AuditQuery filters the audit trail. Action is a full action such as
+// "repo.delete" or a category such as "repo".
+type AuditQuery struct {
+	Action         string
+	ActorID        *uuid.UUID
+	OrganizationID *uuid.UUID
+	RepositoryID   *uuid.UUID
+	Since          *time.Time
+	Until          *time.Time
+	Limit          int
+	Offset         int
+}
+
+// AuditVerification is the outcome of checking the audit trail's hash chain
+type AuditVerification struct {
+	Entries       int64 `json:"entries"`
+	Valid         bool  `json:"valid"`
+	FirstSequence int64 `json:"first_sequence,omitempty"`
+	LastSequence  int64 `json:"last_sequence,omitempty"`
+	// BrokenSequence is the first entry that does not match the chain
+	BrokenSequence int64  `json:"broken_sequence,omitempty"`
+	Reason         string `json:"reason,omitempty"`
+}
+
+// AuditStreamStatus describes how far the trail has been forwarded to the
+// stream sink
+type AuditStreamStatus struct {
+	Enabled bool   `json:"enabled"`
+	Sink    string `json:"sink,omitempty"`
+	models.AuditStreamState
+	Pending int64 `json:"pending"`
+}
+
+type auditService struct {
+	db     *gorm.DB
+	cfg    config.Audit
+	sink   auditSink
+	logger *logrus.Logger
+	now    func() time.Time
+
+	// mu serializes appends within this process; the unique sequence
+	// catches appends racing from other replicas
+	mu      sync.Mutex
+	flushMu sync.Mutex
+}
+
+// NewAuditService creates a new AuditService, failing when the stream sink
+// is misconfigured
+func NewAuditService(db *gorm.DB, cfg config.Audit, logger *logrus.Logger) (AuditService, error) {
+	sink, err := newAuditSink(cfg.Stream)
+	if err != nil {
+		return nil, err
+	}
+	return &auditService{db: db, cfg: cfg, sink: sink, logger: logger, now: time.Now}, nil
+}
+
+// Record appends event to the trail, logging failures
+func (s *auditService) Record(ctx context.Context, event audit.Event) {
+	entry := &models.AuditEntry{
+		Action:         event.Action,
+		ActorID:        event.ActorID,
+		ActorName:      event.ActorName,
+		OrganizationID: event.OrganizationID,
+		RepositoryID:   event.RepositoryID,
+		TargetType:     event.TargetType,
+		TargetID:       event.TargetID,
+		TargetName:     event.TargetName,
+		IPAddress:      event.IPAddress,
+		UserAgent:      truncate(event.UserAgent, 255),
+	}
+	if len(event.Metadata) > 0 {
+		metadata, err := json.Marshal(event.Metadata)
+		if err != nil {
+			s.logger.WithError(err).WithField("action", event.Action).Error("Failed to encode audit metadata")
+		}
+		entry.Metadata = string(metadata)
+	}
+	// Repository events belong to the organization owning the repository
+	if entry.OrganizationID == nil && entry.RepositoryID != nil {
+		var repo models.Repository
+		err := s.db.WithContext(ctx).Unscoped().Select("owner_id", "owner_type").Where("id = ?", *entry.RepositoryID).Take(&repo).Error
+		if err == nil && repo.OwnerType == models.OwnerTypeOrganization {
+			entry.OrganizationID = &repo.OwnerID
+		}
+	}
+
+	if err := s.append(ctx, entry); err != nil {
+		s.logger.WithError(err).WithField("action", event.Action).Error("Failed to record audit entry")
+	}
+}
+
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	return value[:max]
+}
+
+// append links entry to the newest entry of the chain and stores it
+func (s *auditService) append(ctx context.Context, entry *models.AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			entry.Sequence, entry.PrevHash = 1, ""
+			var last models.AuditEntry
+			if err := tx.Order("sequence DESC").Take(&last).Error; err == nil {
+				entry.Sequence, entry.PrevHash = last.Sequence+1, last.Hash
+			} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			entry.ID = uuid.New()
+			// Databases keep microseconds at most; hash what is stored
+			entry.CreatedAt = s.now().UTC().Truncate(time.Microsecond)
+			entry.Hash = auditHash(entry)
+			return tx.Create(entry).Error
+		})
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("failed to append audit entry: %w", err)
+}
+
+// auditHash returns sha256(previous hash + content) of an entry in hex
+func auditHash(e *models.AuditEntry) string {
+	content, _ := json.Marshal(struct {
+		ID             uuid.UUID  `json:"id"`
+		Sequence       int64      `json:"sequence"`
+		CreatedAt      string     `json:"created_at"`
+		Action         string     `json:"action"`
+		ActorID        *uuid.UUID `json:"actor_id"`
+		ActorName      string     `json:"actor_name"`
+		OrganizationID *uuid.UUID `json:"organization_id"`
+		RepositoryID   *uuid.UUID `json:"repository_id"`
+		TargetType     string     `json:"target_type"`
+		TargetID       string     `json:"target_id"`
+		TargetName     string     `json:"target_name"`
+		IPAddress      string     `json:"ip_address"`
+		UserAgent      string     `json:"user_agent"`
+		Metadata       string     `json:"metadata"`
+	}{
+		e.ID, e.Sequence, e.CreatedAt.UTC().Format(time.RFC3339Nano), e.Action, e.ActorID, e.ActorName,
+		e.OrganizationID, e.RepositoryID, e.TargetType, e.TargetID, e.TargetName, e.IPAddress, e.UserAgent, e.Metadata,
+	})
+	h := sha256.New()
+	h.Write([]byte(e.PrevHash))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (s *auditService) Query(ctx context.Context, query AuditQuery) ([]*models.AuditEntry, int64, error) {
+	db := s.db.WithContext(ctx).Model(&models.AuditEntry{})
+	if query.Action != "" {
+		if strings.Contains(query.Action, ".") {
+			db = db.Where("action = ?", query.Action)
+		} else {
+			db = db.Where("action LIKE ?", query.Action+".%")
+		}
+	}
+	if query.ActorID != nil {
+		db = db.Where("actor_id = ?", *query.ActorID)
+	}
+	if query.OrganizationID != nil {
+		db = db.Where("organization_id = ?", *query.OrganizationID)
+	}
+	if query.RepositoryID != nil {
+		db = db.Where("repository_id = ?", *query.RepositoryID)
+	}
+	if query.Since != nil {
+		db = db.Where("created_at >= ?", *query.Since)
+	}
+	if query.Until != nil {
+		db = db.Where("created_at < ?", *query.Until)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+	limit := query.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	var entries []*models.AuditEntry
+	if err := db.Order("sequence DESC").Limit(limit).Offset(query.Offset).Find(&entries).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	return entries, total, nil
+}
+
+// QueryOrganization lists the entries of an organization; actorID must be
+// one of its owners or admins
+func (s *auditService) QueryOrganization(ctx context.Context, orgName string, actorID uuid.UUID, query AuditQuery) ([]*models.AuditEntry, int64, error) {
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, 0, fmt.Errorf("organization not found: %w", err)
+	}
+	var member models.OrganizationMember
+	err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", org.ID, actorID).First(&member).Error
+	if err != nil || (member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin) {
+		return nil, 0, ErrAuditForbidden
+	}
+	query.OrganizationID = &org.ID
+	return s.Query(ctx, query)
+}
+
+// Verify walks the whole trail in sequence order. The oldest entry kept
+// must start the chain or follow the last entry removed by a retention
+// purge, as recorded by that purge.
+func (s *auditService) Verify(ctx context.Context) (*AuditVerification, error) {
+	result := &AuditVerification{Valid: true}
+	var prev *models.AuditEntry
+	for {
+		var entries []*models.AuditEntry
+		db := s.db.WithContext(ctx).Order("sequence ASC").Limit(1000)
+		if prev != nil {
+			db = db.Where("sequence > ?", prev.Sequence)
+		}
+		if err := db.Find(&entries).Error; err != nil {
+			return nil, fmt.Errorf("failed to read audit entries: %w", err)
+		}
+
+		for _, entry := range entries {
+			reason := ""
+			switch {
+			case prev == nil && entry.Sequence > 1:
+				anchored, err := s.purgedThrough(ctx, entry.Sequence-1, entry.PrevHash)
+				if err != nil {
+					return nil, err
+				}
+				if !anchored {
+					reason = "earlier entries were removed outside retention"
+				}
+			case prev == nil && entry.PrevHash != "":
+				reason = "the first entry does not start the chain"
+			case prev != nil && entry.Sequence != prev.Sequence+1:
+				reason = "entries before it are missing"
+			case prev != nil && entry.PrevHash != prev.Hash:
+				reason = "it does not link to the entry before it"
+			}
+			if reason == "" && auditHash(entry) != entry.Hash {
+				reason = "its content does not match its hash"
+			}
+			if reason != "" {
+				result.Valid = false
+				result.BrokenSequence = entry.Sequence
+				result.Reason = reason
+				return result, nil
+			}
+
+			if prev == nil {
+				result.FirstSequence = entry.Sequence
+			}
+			result.Entries++
+			result.LastSequence = entry.Sequence
+			prev = entry
+		}
+		if len(entries) < 1000 {
+			return result, nil
+		}
+	}
+}
+
+// purgedThrough reports whether a retention purge removed the entries up
+// to sequence, the last of them hashing to hash
+func (s *auditService) purgedThrough(ctx context.Context, sequence int64, hash string) (bool, error) {
+	var purges []*models.AuditEntry
+	if err := s.db.WithContext(ctx).Where("action = ?", audit.ActionPurge).Find(&purges).Error; err != nil {
+		return false, fmt.Errorf("failed to read audit purges: %w", err)
+	}
+	for _, purge := range purges {
+		var metadata struct {
+			ThroughSequence int64  `json:"through_sequence"`
+			ThroughHash     string `json:"through_hash"`
+		}
+		if json.Unmarshal([]byte(purge.Metadata), &metadata) == nil &&
+			metadata.ThroughSequence == sequence && metadata.ThroughHash == hash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Purge removes the entries older than the retention period, except the
+// newest entry and entries not streamed yet, and records the purge in the
+// trail
+func (s *auditService) Purge(ctx context.Context) (int64, error) {
+	if s.cfg.RetentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := s.now().AddDate(0, 0, -s.cfg.RetentionDays)
+
+	var newest models.AuditEntry
+	if err := s.db.WithContext(ctx).Order("sequence DESC").Take(&newest).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read audit entries: %w", err)
+	}
+	// Keeping the newest entry lets the chain carry on
+	expired := s.db.WithContext(ctx).Where("created_at < ? AND sequence < ?", cutoff, newest.Sequence)
+	if s.sink != nil {
+		state, err := s.streamState(ctx)
+		if err != nil {
+			return 0, err
+		}
+		expired = expired.Where("sequence <= ?", state.LastSequence)
+	}
+	var last models.AuditEntry
+	if err := expired.Order("sequence DESC").Take(&last).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to find expired audit entries: %w", err)
+	}
+
+	// Entries are removed from the start of the chain only
+	result := s.db.WithContext(ctx).Where("sequence <= ?", last.Sequence).Delete(&models.AuditEntry{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge audit entries: %w", result.Error)
+	}
+	s.Record(ctx, audit.Event{
+		Action:     audit.ActionPurge,
+		TargetType: "audit_trail",
+		Metadata: map[string]interface{}{
+			"purged":           result.RowsAffected,
+			"retention_days":   s.cfg.RetentionDays,
+			"through_sequence": last.Sequence,
+			"through_hash":     last.Hash,
+		},
+	})
+	s.logger.WithFields(logrus.Fields{
+		"purged":           result.RowsAffected,
+		"through_sequence": last.Sequence,
+	}).Info("Purged expired audit entries")
+	return result.RowsAffected, nil
+}
+
+func (s *auditService) streamState(ctx context.Context) (*models.AuditStreamState, error) {
+	state := &models.AuditStreamState{ID: 1}
+	if err := s.db.WithContext(ctx).FirstOrCreate(state, models.AuditStreamState{ID: 1}).Error; err != nil {
+		return nil, fmt.Errorf("failed to read audit stream state: %w", err)
+	}
+	return state, nil
+}
+
+// Flush forwards the entries not streamed yet to the sink, in batches, and
+// returns how many were sent
+func (s *auditService) Flush(ctx context.Context) (int, error) {
+	if s.sink == nil {
+		return 0, ErrAuditStreamDisabled
+	}
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	state, err := s.streamState(ctx)
+	if err != nil {
+		return 0, err
+	}
+	batchSize := s.cfg.Stream.BatchSize
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+
+	sent := 0
+	for {
+		var entries []*models.AuditEntry
+		if err := s.db.WithContext(ctx).Where("sequence > ?", state.LastSequence).
+			Order("sequence ASC").Limit(batchSize).Find(&entries).Error; err != nil {
+			return sent, fmt.Errorf("failed to read audit entries: %w", err)
+		}
+		if len(entries) == 0 {
+			return sent, nil
+		}
+
+		var body bytes.Buffer
+		encoder := json.NewEncoder(&body)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return sent, fmt.Errorf("failed to encode audit entry: %w", err)
+			}
+		}
+		first, last := entries[0].Sequence, entries[len(entries)-1].Sequence
+		if err := s.sink.Send(ctx, first, last, body.Bytes()); err != nil {
+			state.LastError = err.Error()
+			if saveErr := s.db.WithContext(ctx).Save(state).Error; saveErr != nil {
+				s.logger.WithError(saveErr).Warn("Failed to record audit stream error")
+			}
+			return sent, fmt.Errorf("failed to stream audit entries: %w", err)
+		}
+
+		now := s.now()
+		state.LastSequence = last
+		state.LastStreamedAt = &now
+		state.LastError = ""
+		if err := s.db.WithContext(ctx).Save(state).Error; err != nil {
+			return sent, fmt.Errorf("failed to record audit stream state: %w", err)
+		}
+		sent += len(entries)
+		if len(entries) < batchSize {
+			return sent, nil
+		}
+	}
+}
+
+func (s *auditService) StreamStatus(ctx context.Context) (*AuditStreamStatus, error) {
+	status := &AuditStreamStatus{Enabled: s.sink != nil}
+	if s.sink == nil {
+		return status, nil
+	}
+	status.Sink = s.cfg.Stream.Sink
+	state, err := s.streamState(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status.AuditStreamState = *state
+	if err := s.db.WithContext(ctx).Model(&models.AuditEntry{}).
+		Where("sequence > ?", state.LastSequence).Count(&status.Pending).Error; err != nil {
+		return nil, fmt.Errorf("failed to count pending audit entries: %w", err)
+	}
+	return status, nil
+}
+
+// StartScheduler streams new entries every FlushIntervalSeconds and purges
+// expired ones daily until ctx is cancelled. It returns immediately when
+// there is neither a sink nor a retention period.
+func (s *auditService) StartScheduler(ctx context.Context) {
+	if s.sink == nil && s.cfg.RetentionDays <= 0 {
+		return
+	}
+
+	var flushTicks <-chan time.Time
+	if s.sink != nil {
+		interval := time.Duration(s.cfg.Stream.FlushIntervalSeconds) * time.Second
+		if interval <= 0 {
+			interval = 30 * time.Second
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		flushTicks = ticker.C
+	}
+	purgeTicker := time.NewTicker(24 * time.Hour)
+	defer purgeTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-flushTicks:
+			func() {
+				defer errorreporting.Default().Recover("audit_stream", nil)
+				if _, err := s.Flush(ctx); err != nil {
+					s.logger.WithError(err).Warn("Failed to stream audit entries")
+				}
+			}()
+		case <-purgeTicker.C:
+			func() {
+				defer errorreporting.Default().Recover("audit_retention", nil)
+				if _, err := s.Purge(ctx); err != nil {
+					s.logger.WithError(err).Error("Failed to purge audit entries")
+				}
+			}()
+		}
+	}
+}
+
+// auditSink receives batches of audit entries, first to last sequence, as
+// newline-delimited JSON
+type auditSink interface {
+	Send(ctx context.Context, first, last int64, body []byte) error
+}
+
+func newAuditSink(cfg config.AuditStream) (auditSink, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	switch strings.ToLower(cfg.Sink) {
+	case "https":
+		endpoint, err := url.Parse(cfg.URL)
+		if err != nil || endpoint.Scheme != "https" || endpoint.Host == "" {
+			return nil, fmt.Errorf("audit stream url must be an https URL")
+		}
+		return &httpsAuditSink{url: cfg.URL, token: cfg.Token, client: &http.Client{Timeout: 30 * time.Second}}, nil
+	case "s3":
+		if cfg.S3.Bucket == "" {
+			return nil, fmt.Errorf("audit stream s3 bucket is required")
+		}
+		backend, err := storage.NewBackend(storage.Config{
+			Backend: "s3",
+			S3: storage.S3Config{
+				Region:          cfg.S3.Region,
+				Bucket:          cfg.S3.Bucket,
+				AccessKeyID:     cfg.S3.AccessKeyID,
+				SecretAccessKey: cfg.S3.SecretAccessKey,
+				EndpointURL:     cfg.S3.EndpointURL,
+				UseSSL:          cfg.S3.UseSSL,
+			},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create audit stream storage: %w", err)
+		}
+		return &storageAuditSink{backend: backend, prefix: cfg.Prefix}, nil
+	default:
+		return nil, fmt.Errorf("unsupported audit stream sink %q, expected https or s3", cfg.Sink)
+	}
+}
+
+// httpsAuditSink POSTs each batch to an HTTPS endpoint
+type httpsAuditSink struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (s *httpsAuditSink) Send(ctx context.Context, first, last int64, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("X-Hub-Audit-Sequence", fmt.Sprintf("%d-%d", first, last))
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("audit sink answered %s", resp.Status)
+	}
+	return nil
+}
+
+// storageAuditSink writes each batch as an object named after its first and
+// last sequence, so objects sort in chain order
+type storageAuditSink struct {
+	backend storage.Backend
+	prefix  string
+}
+
+func (s *storageAuditSink) Send(ctx context.Context, first, last int64, body []byte) error {
+	name := path.Join(s.prefix, fmt.Sprintf("%020d-%020d.ndjson", first, last))
+	return s.backend.Upload(ctx, name, bytes.NewReader(body), int64(len(body)))
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/audit"
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.AuditEntry{}, &models.AuditStreamState{}, &models.Organization{},
+		&models.OrganizationMember{}, &models.Repository{})
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc, err := NewAuditService(db, config.Audit{RetentionDays: 30}, logrus.New())
+	require.NoError(t, err)
+	impl := svc.(*auditService)
+	impl.now = func() time.Time { return now }
+	audit.SetDefaultRecorder(svc)
+	t.Cleanup(func() { audit.SetDefaultRecorder(nil) })
+
+	owner, member := uuid.New(), uuid.New()
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	require.NoError(t, db.Create([]*models.OrganizationMember{
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: owner, Role: models.OrgRoleOwner},
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: member, Role: models.OrgRoleMember},
+	}).Error)
+	repo := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "app",
+		DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+
+	// The actor and client come from the request's tenant
+	requestCtx := tenant.NewContext(ctx, &tenant.Context{UserID: &owner, Username: "olivia", ClientIP: "203.0.113.7", UserAgent: "curl/8"})
+	audit.Record(requestCtx, audit.Event{Action: audit.ActionDeployKeyAdd, RepositoryID: &repo.ID, TargetType: "deploy_key", TargetName: "ci"})
+	audit.Record(ctx, audit.Event{Action: audit.ActionLoginFailed, TargetType: "user", TargetName: "mallory", Metadata: map[string]interface{}{"reason": "invalid credentials"}})
+	now = now.Add(time.Hour)
+	audit.Record(requestCtx, audit.Event{Action: audit.ActionMemberRoleChange, OrganizationID: &org.ID, TargetType: "user", TargetID: member.String()})
+
+	entries, total, err := svc.Query(ctx, AuditQuery{})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+	assert.Equal(t, []int64{3, 2, 1}, []int64{entries[0].Sequence, entries[1].Sequence, entries[2].Sequence})
+	key := entries[2]
+	assert.Equal(t, &owner, key.ActorID)
+	assert.Equal(t, "olivia", key.ActorName)
+	assert.Equal(t, "203.0.113.7", key.IPAddress)
+	assert.Equal(t, &org.ID, key.OrganizationID, "repository events belong to the owning organization")
+	assert.Empty(t, key.PrevHash)
+	assert.Equal(t, key.Hash, entries[1].PrevHash)
+
+	entries, total, err = svc.Query(ctx, AuditQuery{Action: "auth"})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	assert.Equal(t, audit.ActionLoginFailed, entries[0].Action)
+	_, total, err = svc.Query(ctx, AuditQuery{ActorID: &owner, Since: &now})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+
+	// Only owners and admins read the organization's trail
+	_, _, err = svc.QueryOrganization(ctx, "acme", member, AuditQuery{})
+	assert.ErrorIs(t, err, ErrAuditForbidden)
+	entries, total, err = svc.QueryOrganization(ctx, "acme", owner, AuditQuery{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+	for _, entry := range entries {
+		assert.NotEqual(t, audit.ActionLoginFailed, entry.Action)
+	}
+
+	verification, err := svc.Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, verification.Valid)
+	assert.EqualValues(t, 3, verification.Entries)
+
+	// Editing an entry breaks the chain
+	require.NoError(t, db.Model(&models.AuditEntry{}).Where("sequence = ?", 2).Update("target_name", "alice").Error)
+	verification, err = svc.Verify(ctx)
+	require.NoError(t, err)
+	assert.False(t, verification.Valid)
+	assert.EqualValues(t, 2, verification.BrokenSequence)
+	require.NoError(t, db.Model(&models.AuditEntry{}).Where("sequence = ?", 2).Update("target_name", "mallory").Error)
+
+	// Retention removes expired entries from the start of the chain and
+	// records where it stopped
+	now = now.AddDate(0, 0, 30).Add(-30 * time.Minute)
+	purged, err := svc.Purge(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, purged)
+	entries, _, err = svc.Query(ctx, AuditQuery{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, audit.ActionPurge, entries[0].Action)
+	verification, err = svc.Verify(ctx)
+	require.NoError(t, err)
+	assert.True(t, verification.Valid)
+	assert.EqualValues(t, 3, verification.FirstSequence)
+
+	// Removing entries outside retention is detected
+	require.NoError(t, db.Where("sequence = ?", 3).Delete(&models.AuditEntry{}).Error)
+	verification, err = svc.Verify(ctx)
+	require.NoError(t, err)
+	assert.False(t, verification.Valid)
+	assert.EqualValues(t, 4, verification.BrokenSequence)
+}
+
+func TestAuditServiceStream(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.AuditEntry{}, &models.AuditStreamState{}, &models.Repository{})
+	ctx := context.Background()
+
+	_, err := NewAuditService(db, config.Audit{Stream: config.AuditStream{Enabled: true, Sink: "https", URL: "http://siem.example.com"}}, logrus.New())
+	assert.Error(t, err, "the sink must use https")
+
+	var batches [][]string
+	failing := true
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		assert.Equal(t, "application/x-ndjson", r.Header.Get("Content-Type"))
+		body, _ := io.ReadAll(r.Body)
+		batches = append(batches, strings.Split(strings.TrimSpace(string(body)), "\n"))
+	}))
+	defer server.Close()
+
+	cfg := config.Audit{RetentionDays: 1, Stream: config.AuditStream{Enabled: true, Sink: "https", URL: server.URL, Token: "secret", BatchSize: 2}}
+	svc, err := NewAuditService(db, cfg, logrus.New())
+	require.NoError(t, err)
+	impl := svc.(*auditService)
+	impl.sink.(*httpsAuditSink).client = server.Client()
+	now := time.Now()
+	impl.now = func() time.Time { return now }
+	for i := 0; i < 3; i++ {
+		svc.Record(ctx, audit.Event{Action: audit.ActionSSHKeyAdd, TargetType: "ssh_key"})
+	}
+
+	// A failed batch is kept for the next flush
+	_, err = svc.Flush(ctx)
+	assert.Error(t, err)
+	status, err := svc.StreamStatus(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, status.Pending)
+	assert.Contains(t, status.LastError, "503")
+
+	// Unstreamed entries outlive their retention
+	now = now.AddDate(0, 0, 2)
+	purged, err := svc.Purge(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+
+	failing = false
+	sent, err := svc.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, sent)
+	require.Len(t, batches, 2)
+	assert.Len(t, batches[0], 2)
+	var first models.AuditEntry
+	require.NoError(t, json.Unmarshal([]byte(batches[0][0]), &first))
+	assert.EqualValues(t, 1, first.Sequence)
+	status, err = svc.StreamStatus(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, status.Pending)
+	assert.EqualValues(t, 3, status.LastSequence)
+	assert.Empty(t, status.LastError)
+
+	purged, err = svc.Purge(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, purged, "the newest entry is kept")
+
+	// Object storage sinks get one object per batch, named in chain order
+	backend, err := storage.NewFilesystemBackend(storage.FilesystemConfig{BasePath: t.TempDir()})
+	require.NoError(t, err)
+	impl.sink = &storageAuditSink{backend: backend, prefix: "audit"}
+	sent, err = svc.Flush(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent, "the purge entry")
+	objects, err := backend.List(ctx, "audit")
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+	assert.Contains(t, objects[0], "00000000000000000004-00000000000000000004.ndjson")
+	reader, err := backend.Download(ctx, objects[0])
+	require.NoError(t, err)
+	defer reader.Close()
+	scanner := bufio.NewScanner(reader)
+	require.True(t, scanner.Scan())
+	assert.Contains(t, scanner.Text(), audit.ActionPurge)
+}
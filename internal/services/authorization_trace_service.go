@@ -0,0 +1,367 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAuthorizationTraceNotFound  = errors.New("authorization trace not found")
+	ErrInvalidAuthorizationExplain = errors.New("invalid authorization explain request")
+)
+
+// ExplainAuthorizationRequest asks why a user has the access they have on a
+// repository, and on one of its branches when Branch is set
+type ExplainAuthorizationRequest struct {
+	Username string `json:"username" binding:"required"`
+	// Repository is owner/name
+	Repository string `json:"repository" binding:"required"`
+	Branch     string `json:"branch,omitempty"`
+}
+
+// AuthorizationTraceListOptions filters recorded traces
+type AuthorizationTraceListOptions struct {
+	Username   string
+	Repository string
+	RequestID  string
+	Limit      int
+	Offset     int
+}
+
+// AuthorizationTraceSettings is the tracing configuration shown to admins
+type AuthorizationTraceSettings struct {
+	Enabled        bool     `json:"enabled"`
+	Users          []string `json:"users"`
+	RetentionHours int      `json:"retention_hours"`
+}
+
+// AuthorizationTraceService explains repository permission decisions. While
+// tracing is enabled, the tenant middleware records a trace for every
+// request that resolves a repository permission.
+type AuthorizationTraceService interface {
+	Settings() AuthorizationTraceSettings
+	// Enabled reports whether requests by username are traced
+	Enabled(username string) bool
+	// Trace resolves the user's permission on repo, and the protection of
+	// branch when it is set, without storing the result
+	Trace(ctx context.Context, userID uuid.UUID, username string, isAdmin bool, repo *models.Repository, branch string) (*models.AuthorizationTrace, error)
+	Record(ctx context.Context, trace *models.AuthorizationTrace) error
+	List(ctx context.Context, opts AuthorizationTraceListOptions) ([]*models.AuthorizationTrace, int64, error)
+	Get(ctx context.Context, id uuid.UUID) (*models.AuthorizationTrace, error)
+	// Explain traces a user's access on demand, whether or not tracing is enabled
+	Explain(ctx context.Context, req ExplainAuthorizationRequest) (*models.AuthorizationTrace, error)
+	Purge(ctx context.Context) (int64, error)
+	StartScheduler(ctx context.Context)
+}
+
+type authorizationTraceService struct {
+	db                *gorm.DB
+	permissionService PermissionService
+	repositoryService RepositoryService
+	cfg               config.AuthorizationTrace
+	logger            *logrus.Logger
+	now               func() time.Time
+}
+
+// NewAuthorizationTraceService creates a new AuthorizationTraceService
+func NewAuthorizationTraceService(db *gorm.DB, permissionService PermissionService, repositoryService RepositoryService, cfg config.AuthorizationTrace, logger *logrus.Logger) AuthorizationTraceService {
+	return &authorizationTraceService{
+		db:                db,
+		permissionService: permissionService,
+		repositoryService: repositoryService,
+		cfg:               cfg,
+		logger:            logger,
+		now:               time.Now,
+	}
+}
+
+func (s *authorizationTraceService) Settings() AuthorizationTraceSettings {
+	users := s.cfg.Users
+	if users == nil {
+		users = []string{}
+	}
+	return AuthorizationTraceSettings{Enabled: s.cfg.Enabled, Users: users, RetentionHours: s.cfg.RetentionHours}
+}
+
+func (s *authorizationTraceService) Enabled(username string) bool {
+	if !s.cfg.Enabled {
+		return false
+	}
+	if len(s.cfg.Users) == 0 {
+		return true
+	}
+	for _, user := range s.cfg.Users {
+		if strings.EqualFold(user, username) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *authorizationTraceService) Trace(ctx context.Context, userID uuid.UUID, username string, isAdmin bool, repo *models.Repository, branch string) (*models.AuthorizationTrace, error) {
+	permission, steps, err := s.permissionService.ExplainUserPermission(ctx, userID, repo.ID)
+	if err != nil {
+		return nil, err
+	}
+	trace := &models.AuthorizationTrace{
+		UserID:       &userID,
+		Username:     username,
+		RepositoryID: &repo.ID,
+		Repository:   repositoryFullName(repo),
+		Branch:       branch,
+		Permission:   permission,
+	}
+	if isAdmin {
+		trace.Steps = append(trace.Steps, models.AuthorizationTraceStep{
+			Source:     models.AuthzSourceSiteAdmin,
+			Detail:     "site administrators bypass repository permission checks",
+			Permission: models.PermissionAdmin,
+			Matched:    true,
+		})
+	}
+	trace.Steps = append(trace.Steps, steps...)
+
+	if repo.OwnerType == models.OwnerTypeOrganization {
+		policySteps, err := s.organizationPolicySteps(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		trace.Steps = append(trace.Steps, policySteps...)
+	}
+	if branch != "" {
+		protectionSteps, err := s.branchProtectionSteps(ctx, repo, userID, isAdmin || permission == models.PermissionAdmin, branch)
+		if err != nil {
+			return nil, err
+		}
+		trace.Steps = append(trace.Steps, protectionSteps...)
+	}
+	return trace, nil
+}
+
+func repositoryFullName(repo *models.Repository) string {
+	if repo.Owner != nil {
+		return repo.Owner.Username + "/" + repo.Name
+	}
+	return repo.Name
+}
+
+// organizationPolicySteps reports the organization policies that restrict
+// what repository members may do beyond their permission
+func (s *authorizationTraceService) organizationPolicySteps(ctx context.Context, repo *models.Repository) ([]models.AuthorizationTraceStep, error) {
+	var settings models.OrganizationSettings
+	err := s.db.WithContext(ctx).Where("organization_id = ?", repo.OwnerID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load organization settings: %w", err)
+	}
+
+	var steps []models.AuthorizationTraceStep
+	switch {
+	case !settings.AllowForking:
+		steps = append(steps, models.AuthorizationTraceStep{Source: models.AuthzSourceOrganizationPolicy, Detail: "organization policy disables forking", Matched: true})
+	case !settings.AllowPrivateForking && repo.Visibility != models.VisibilityPublic:
+		steps = append(steps, models.AuthorizationTraceStep{Source: models.AuthzSourceOrganizationPolicy, Detail: "organization policy disables forking private repositories", Matched: true})
+	}
+	if settings.VisibilityChangePolicy != "" && settings.VisibilityChangePolicy != models.VisibilityChangeRepositoryAdmins {
+		steps = append(steps, models.AuthorizationTraceStep{
+			Source:  models.AuthzSourceOrganizationPolicy,
+			Detail:  fmt.Sprintf("organization policy limits visibility changes to organization %s", settings.VisibilityChangePolicy),
+			Matched: true,
+		})
+	}
+	return steps, nil
+}
+
+// branchProtectionSteps describes every protection rule that applies to branch
+func (s *authorizationTraceService) branchProtectionSteps(ctx context.Context, repo *models.Repository, userID uuid.UUID, admin bool, branch string) ([]models.AuthorizationTraceStep, error) {
+	var rules []*models.BranchProtectionRule
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repo.ID).Order("pattern").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to load branch protection: %w", err)
+	}
+
+	var steps []models.AuthorizationTraceStep
+	for _, rule := range rules {
+		if !matchPattern(rule.Pattern, branch) {
+			continue
+		}
+		var requirements []string
+		var reviews RequiredPullRequestReviews
+		if rule.RequiredPullRequestReviews != "" && json.Unmarshal([]byte(rule.RequiredPullRequestReviews), &reviews) == nil && reviews.RequiredApprovingReviewCount > 0 {
+			requirements = append(requirements, fmt.Sprintf("%d approving reviews required", reviews.RequiredApprovingReviewCount))
+		}
+		var checks RequiredStatusChecks
+		if rule.RequiredStatusChecks != "" && json.Unmarshal([]byte(rule.RequiredStatusChecks), &checks) == nil && len(checks.Contexts) > 0 {
+			requirements = append(requirements, fmt.Sprintf("status checks %s must pass", strings.Join(checks.Contexts, ", ")))
+		}
+		var restrictions BranchRestrictions
+		if rule.Restrictions != "" && json.Unmarshal([]byte(rule.Restrictions), &restrictions) == nil && len(restrictions.Users)+len(restrictions.Teams) > 0 {
+			allowed, err := s.pushAllowed(ctx, repo, userID, restrictions)
+			if err != nil {
+				return nil, err
+			}
+			if allowed {
+				requirements = append(requirements, "pushes are restricted and the user is allowed to push")
+			} else {
+				requirements = append(requirements, "pushes are restricted and the user is not allowed to push")
+			}
+		}
+		switch {
+		case rule.EnforceAdmins:
+			requirements = append(requirements, "the rule applies to admins")
+		case admin:
+			requirements = append(requirements, "the user is an admin and exempt from the rule")
+		}
+		if len(requirements) == 0 {
+			requirements = append(requirements, "no requirements configured")
+		}
+		steps = append(steps, models.AuthorizationTraceStep{
+			Source:  models.AuthzSourceBranchProtection,
+			Detail:  fmt.Sprintf("branch %s is protected by rule %q: %s", branch, rule.Pattern, strings.Join(requirements, "; ")),
+			Matched: true,
+		})
+	}
+	if len(steps) == 0 {
+		steps = append(steps, models.AuthorizationTraceStep{
+			Source: models.AuthzSourceBranchProtection,
+			Detail: fmt.Sprintf("no protection rule matches branch %s", branch),
+		})
+	}
+	return steps, nil
+}
+
+// pushAllowed reports whether the user is listed in restrictions directly or
+// through one of the organization's teams
+func (s *authorizationTraceService) pushAllowed(ctx context.Context, repo *models.Repository, userID uuid.UUID, restrictions BranchRestrictions) (bool, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).Select("id", "username").First(&user, "id = ?", userID).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, fmt.Errorf("failed to load user: %w", err)
+	}
+	for _, name := range restrictions.Users {
+		if user.Username != "" && strings.EqualFold(name, user.Username) {
+			return true, nil
+		}
+	}
+	if len(restrictions.Teams) == 0 || repo.OwnerType != models.OwnerTypeOrganization {
+		return false, nil
+	}
+	var count int64
+	err := s.db.WithContext(ctx).Table("team_members").
+		Joins("JOIN teams ON team_members.team_id = teams.id").
+		Where("teams.organization_id = ? AND teams.name IN ? AND team_members.user_id = ?", repo.OwnerID, restrictions.Teams, userID).
+		Where("team_members.deleted_at IS NULL AND teams.deleted_at IS NULL").
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check team membership: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (s *authorizationTraceService) Record(ctx context.Context, trace *models.AuthorizationTrace) error {
+	trace.ID = uuid.New()
+	if err := s.db.WithContext(ctx).Create(trace).Error; err != nil {
+		return fmt.Errorf("failed to record authorization trace: %w", err)
+	}
+	return nil
+}
+
+func (s *authorizationTraceService) List(ctx context.Context, opts AuthorizationTraceListOptions) ([]*models.AuthorizationTrace, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.AuthorizationTrace{})
+	if opts.Username != "" {
+		query = query.Where("LOWER(username) = LOWER(?)", opts.Username)
+	}
+	if opts.Repository != "" {
+		query = query.Where("repository = ?", opts.Repository)
+	}
+	if opts.RequestID != "" {
+		query = query.Where("request_id = ?", opts.RequestID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count authorization traces: %w", err)
+	}
+	var traces []*models.AuthorizationTrace
+	if err := query.Order("created_at DESC").Limit(opts.Limit).Offset(opts.Offset).Find(&traces).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list authorization traces: %w", err)
+	}
+	return traces, total, nil
+}
+
+func (s *authorizationTraceService) Get(ctx context.Context, id uuid.UUID) (*models.AuthorizationTrace, error) {
+	var trace models.AuthorizationTrace
+	if err := s.db.WithContext(ctx).First(&trace, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAuthorizationTraceNotFound
+		}
+		return nil, fmt.Errorf("failed to get authorization trace: %w", err)
+	}
+	return &trace, nil
+}
+
+func (s *authorizationTraceService) Explain(ctx context.Context, req ExplainAuthorizationRequest) (*models.AuthorizationTrace, error) {
+	owner, name, ok := strings.Cut(req.Repository, "/")
+	if !ok || owner == "" || name == "" {
+		return nil, fmt.Errorf("%w: repository must be owner/name", ErrInvalidAuthorizationExplain)
+	}
+	var user models.User
+	if err := s.db.WithContext(ctx).Where("username = ?", req.Username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: user %s not found", ErrInvalidAuthorizationExplain, req.Username)
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	repo, err := s.repositoryService.Get(ctx, owner, name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: repository %s not found", ErrInvalidAuthorizationExplain, req.Repository)
+	}
+	return s.Trace(ctx, user.ID, user.Username, user.IsAdmin, repo, req.Branch)
+}
+
+func (s *authorizationTraceService) Purge(ctx context.Context) (int64, error) {
+	retention := s.cfg.RetentionHours
+	if retention <= 0 {
+		retention = 72
+	}
+	cutoff := s.now().Add(-time.Duration(retention) * time.Hour)
+	result := s.db.WithContext(ctx).Where("created_at < ?", cutoff).Delete(&models.AuthorizationTrace{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge authorization traces: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// StartScheduler purges expired traces every hour until ctx is cancelled.
+// It keeps running while tracing is disabled so traces recorded earlier
+// still expire.
+func (s *authorizationTraceService) StartScheduler(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			func() {
+				defer errorreporting.Default().Recover("authorization_trace_purge", nil)
+				if purged, err := s.Purge(ctx); err != nil {
+					s.logger.WithError(err).Error("Failed to purge authorization traces")
+				} else if purged > 0 {
+					s.logger.WithField("purged", purged).Info("Purged expired authorization traces")
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorizationTraceService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{}, &models.OrganizationSettings{},
+		&models.Team{}, &models.TeamMember{}, &models.Repository{}, &models.RepositoryPermission{},
+		&models.BranchProtectionRule{}, &models.AuthorizationTrace{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	repoService := NewRepositoryService(db, nil, logger, t.TempDir())
+	svc := NewAuthorizationTraceService(db, NewPermissionService(db, nil), repoService,
+		config.AuthorizationTrace{Enabled: true, Users: []string{"Developer"}, RetentionHours: 24}, logger)
+
+	developer := &models.User{ID: uuid.New(), Username: "developer", Email: "developer@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(developer).Error)
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: developer.ID, Role: models.OrgRoleMember}).Error)
+	require.NoError(t, db.Create(&models.OrganizationSettings{ID: uuid.New(), OrganizationID: org.ID}).Error)
+	require.NoError(t, db.Model(&models.OrganizationSettings{}).Where("organization_id = ?", org.ID).Update("allow_forking", false).Error)
+
+	devs := &models.Team{ID: uuid.New(), OrganizationID: org.ID, Name: "devs", Privacy: models.TeamPrivacyClosed}
+	ops := &models.Team{ID: uuid.New(), OrganizationID: org.ID, Name: "ops", Privacy: models.TeamPrivacyClosed}
+	require.NoError(t, db.Create([]*models.Team{devs, ops}).Error)
+	require.NoError(t, db.Create([]*models.TeamMember{
+		{ID: uuid.New(), TeamID: devs.ID, UserID: developer.ID, Role: models.TeamRoleMember},
+		{ID: uuid.New(), TeamID: ops.ID, UserID: developer.ID, Role: models.TeamRoleMember},
+	}).Error)
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	require.NoError(t, db.Create(&models.RepositoryPermission{ID: uuid.New(), RepositoryID: repo.ID, SubjectID: devs.ID, SubjectType: models.SubjectTypeTeam, Permission: models.PermissionWrite}).Error)
+	require.NoError(t, db.Create(&models.BranchProtectionRule{ID: uuid.New(), RepositoryID: repo.ID, Pattern: "main",
+		RequiredPullRequestReviews: `{"required_approving_review_count":2}`, Restrictions: `{"users":[],"teams":["release"]}`}).Error)
+
+	t.Run("tracing is limited to the configured users", func(t *testing.T) {
+		assert.True(t, svc.Enabled("developer"))
+		assert.False(t, svc.Enabled("someone"))
+	})
+
+	t.Run("explain validates the request", func(t *testing.T) {
+		_, err := svc.Explain(ctx, ExplainAuthorizationRequest{Username: "developer", Repository: "app"})
+		assert.ErrorIs(t, err, ErrInvalidAuthorizationExplain)
+		_, err = svc.Explain(ctx, ExplainAuthorizationRequest{Username: "nobody", Repository: "acme/app"})
+		assert.ErrorIs(t, err, ErrInvalidAuthorizationExplain)
+	})
+
+	trace, err := svc.Explain(ctx, ExplainAuthorizationRequest{Username: "developer", Repository: "acme/app", Branch: "main"})
+	require.NoError(t, err)
+	assert.Equal(t, models.PermissionWrite, trace.Permission)
+	assert.Equal(t, "acme/app", trace.Repository)
+
+	matched := make(map[string][]string)
+	for _, step := range trace.Steps {
+		if step.Matched {
+			matched[step.Source] = append(matched[step.Source], step.Detail)
+		}
+	}
+	assert.Equal(t, []string{"team devs has access to the repository"}, matched[models.AuthzSourceTeam])
+	assert.Equal(t, []string{"organization policy disables forking"}, matched[models.AuthzSourceOrganizationPolicy])
+	require.Len(t, matched[models.AuthzSourceBranchProtection], 1)
+	assert.Contains(t, matched[models.AuthzSourceBranchProtection][0], "2 approving reviews required")
+	assert.Contains(t, matched[models.AuthzSourceBranchProtection][0], "the user is not allowed to push")
+
+	trace.RequestID = "req-1"
+	require.NoError(t, svc.Record(ctx, trace))
+	traces, total, err := svc.List(ctx, AuthorizationTraceListOptions{Username: "Developer", Limit: 10})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	require.Len(t, traces, 1)
+	assert.Len(t, traces[0].Steps, len(trace.Steps))
+
+	stored, err := svc.Get(ctx, trace.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "req-1", stored.RequestID)
+	_, err = svc.Get(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrAuthorizationTraceNotFound)
+
+	svc.(*authorizationTraceService).now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	purged, err := svc.Purge(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, purged)
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/sirupsen/logrus"
+)
+
+// CoverageStatusContext is the commit status context coverage tools report
+// under; the first percentage in its description is the coverage
+const CoverageStatusContext = "coverage"
+
+var ErrUnknownBadge = errors.New("unknown badge")
+
+var coveragePattern = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*%`)
+
+// Badge colors, matching the common shields palette
+const (
+	badgeGreen  = "#4c1"
+	badgeYellow = "#dfb317"
+	badgeRed    = "#e05d44"
+	badgeGrey   = "#9f9f9f"
+	badgeBlue   = "#007ec6"
+)
+
+// Badge is the label, value and color of a README status badge
+type Badge struct {
+	Label   string `json:"label"`
+	Message string `json:"message"`
+	Color   string `json:"color"`
+}
+
+// BadgeService computes README badges from repository stats and commit statuses
+type BadgeService interface {
+	// Badge returns the named badge (stars, build or coverage); build and
+	// coverage read the statuses of branch, or the default branch when empty
+	Badge(ctx context.Context, repo *models.Repository, name, branch string) (*Badge, error)
+}
+
+type badgeService struct {
+	gitService    git.GitService
+	repoService   RepositoryService
+	statusService CommitStatusService
+	logger        *logrus.Logger
+}
+
+// NewBadgeService creates a new BadgeService
+func NewBadgeService(gitService git.GitService, repoService RepositoryService, statusService CommitStatusService, logger *logrus.Logger) BadgeService {
+	return &badgeService{gitService: gitService, repoService: repoService, statusService: statusService, logger: logger}
+}
+
+func (s *badgeService) Badge(ctx context.Context, repo *models.Repository, name, branch string) (*Badge, error) {
+	switch name {
+	case "stars":
+		return &Badge{Label: "stars", Message: strconv.Itoa(repo.StarsCount), Color: badgeBlue}, nil
+	case "build":
+		combined, err := s.combinedStatus(ctx, repo, branch)
+		if err != nil || combined.TotalCount == 0 {
+			return &Badge{Label: "build", Message: "unknown", Color: badgeGrey}, err
+		}
+		return buildBadge(combined), nil
+	case "coverage":
+		combined, err := s.combinedStatus(ctx, repo, branch)
+		if err != nil {
+			return &Badge{Label: "coverage", Message: "unknown", Color: badgeGrey}, err
+		}
+		return coverageBadge(combined), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownBadge, name)
+}
+
+// combinedStatus resolves branch and returns its statuses; a missing branch
+// or empty repository is reported as no statuses rather than an error
+func (s *badgeService) combinedStatus(ctx context.Context, repo *models.Repository, branch string) (*CombinedCommitStatus, error) {
+	if branch == "" {
+		branch = repo.DefaultBranch
+	}
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, repo.ID)
+	if err != nil {
+		return nil, err
+	}
+	sha, err := s.gitService.ResolveSHA(ctx, repoPath, branch)
+	if err != nil {
+		s.logger.WithError(err).WithField("repository_id", repo.ID).Debug("Badge branch does not resolve")
+		return &CombinedCommitStatus{State: models.CommitStatusPending}, nil
+	}
+	return s.statusService.Combined(ctx, repo.ID, sha)
+}
+
+func buildBadge(combined *CombinedCommitStatus) *Badge {
+	switch combined.State {
+	case models.CommitStatusSuccess:
+		return &Badge{Label: "build", Message: "passing", Color: badgeGreen}
+	case models.CommitStatusFailure:
+		return &Badge{Label: "build", Message: "failing", Color: badgeRed}
+	}
+	return &Badge{Label: "build", Message: "pending", Color: badgeYellow}
+}
+
+func coverageBadge(combined *CombinedCommitStatus) *Badge {
+	for _, status := range combined.Statuses {
+		if status.Context != CoverageStatusContext {
+			continue
+		}
+		match := coveragePattern.FindStringSubmatch(status.Description)
+		if match == nil {
+			break
+		}
+		percent, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			break
+		}
+		color := badgeRed
+		switch {
+		case percent >= 80:
+			color = badgeGreen
+		case percent >= 60:
+			color = badgeYellow
+		}
+		return &Badge{Label: "coverage", Message: strconv.FormatFloat(percent, 'f', -1, 64) + "%", Color: color}
+	}
+	return &Badge{Label: "coverage", Message: "unknown", Color: badgeGrey}
+}
+
+// RenderBadgeSVG draws a flat two-part badge. Text widths are estimated
+// from an average glyph width, which is close enough for short labels.
+func RenderBadgeSVG(badge *Badge) []byte {
+	labelWidth := textWidth(badge.Label)
+	messageWidth := textWidth(badge.Message)
+	width := labelWidth + messageWidth
+	label := html.EscapeString(badge.Label)
+	message := html.EscapeString(badge.Message)
+
+	return []byte(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">`+
+		`<title>%[4]s: %[5]s</title>`+
+		`<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>`+
+		`<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>`+
+		`<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>`+
+		`<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11">`+
+		`<text x="%[7]d" y="14">%[4]s</text><text x="%[8]d" y="14">%[5]s</text></g></svg>`,
+		width, labelWidth, messageWidth, label, message, badge.Color, labelWidth/2, labelWidth+messageWidth/2))
+}
+
+func textWidth(text string) int {
+	return len([]rune(text))*7 + 10
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgeService_Badges(t *testing.T) {
+	svc := NewBadgeService(nil, nil, nil, logrus.New())
+	stars, err := svc.Badge(context.Background(), &models.Repository{StarsCount: 42}, "stars", "")
+	require.NoError(t, err)
+	assert.Equal(t, &Badge{Label: "stars", Message: "42", Color: badgeBlue}, stars)
+
+	_, err = svc.Badge(context.Background(), &models.Repository{}, "downloads", "")
+	assert.ErrorIs(t, err, ErrUnknownBadge)
+
+	assert.Equal(t, "passing", buildBadge(&CombinedCommitStatus{State: models.CommitStatusSuccess}).Message)
+	assert.Equal(t, "failing", buildBadge(&CombinedCommitStatus{State: models.CommitStatusFailure}).Message)
+
+	coverage := coverageBadge(&CombinedCommitStatus{Statuses: []models.CommitStatus{
+		{Context: "ci/build", Description: "100% of jobs passed"},
+		{Context: CoverageStatusContext, Description: "Coverage 72.5% (+0.3%)"},
+	}})
+	assert.Equal(t, &Badge{Label: "coverage", Message: "72.5%", Color: badgeYellow}, coverage)
+	assert.Equal(t, "unknown", coverageBadge(&CombinedCommitStatus{}).Message)
+}
+
+func TestRenderBadgeSVG(t *testing.T) {
+	svg := string(RenderBadgeSVG(&Badge{Label: "build", Message: "<passing>", Color: badgeGreen}))
+	assert.Contains(t, svg, `<svg xmlns="http://www.w3.org/2000/svg"`)
+	assert.Contains(t, svg, `fill="#4c1"`)
+	assert.Contains(t, svg, "&lt;passing&gt;")
+	assert.NotContains(t, svg, "%!")
+}
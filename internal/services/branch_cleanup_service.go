@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidBranchCleanup is returned for out of range branch cleanup settings
+var ErrInvalidBranchCleanup = errors.New("invalid branch cleanup settings")
+
+// Reasons a branch is suggested for cleanup
+const (
+	BranchCleanupMerged            = "merged"
+	BranchCleanupPullRequestMerged = "pull_request_merged"
+	BranchCleanupStale             = "stale"
+)
+
+// BranchCleanupCandidate is a branch that can probably be deleted
+type BranchCleanupCandidate struct {
+	Name         string    `json:"name"`
+	SHA          string    `json:"sha"`
+	LastCommitAt time.Time `json:"last_commit_at"`
+	AheadBy      int       `json:"ahead_by"`
+	BehindBy     int       `json:"behind_by"`
+	Reasons      []string  `json:"reasons"`
+	// PullRequest is the number of the merged pull request the branch was the head of
+	PullRequest *int `json:"pull_request,omitempty"`
+	// Automatic is set when the scheduled cleanup would delete the branch
+	Automatic bool `json:"automatic"`
+}
+
+// BranchCleanupReport lists the cleanup candidates of a repository.
+// Default, protected and open pull request branches are never candidates.
+type BranchCleanupReport struct {
+	DefaultBranch            string                    `json:"default_branch"`
+	StaleDays                int                       `json:"stale_days"`
+	AutoDeleteMergedBranches bool                      `json:"auto_delete_merged_branches"`
+	DeleteBranchOnMerge      bool                      `json:"delete_branch_on_merge"`
+	Candidates               []*BranchCleanupCandidate `json:"candidates"`
+}
+
+// BranchCleanupResult is the outcome of a bulk branch deletion
+type BranchCleanupResult struct {
+	Deleted []string               `json:"deleted"`
+	Skipped []BranchCleanupSkipped `json:"skipped"`
+}
+
+// BranchCleanupSkipped is a branch a bulk deletion left in place
+type BranchCleanupSkipped struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// BranchCleanupService suggests merged and stale branches for deletion,
+// deletes them in bulk, and runs the scheduled cleanup of repositories
+// that opted in
+type BranchCleanupService interface {
+	// Suggestions lists cleanup candidates; staleDays overrides the
+	// repository's stale_branch_days when positive
+	Suggestions(ctx context.Context, repo *models.Repository, staleDays int) (*BranchCleanupReport, error)
+	// DeleteBranches deletes the named branches that are still cleanup
+	// candidates and reports why the others were skipped
+	DeleteBranches(ctx context.Context, repo *models.Repository, names []string, actorID uuid.UUID) (*BranchCleanupResult, error)
+	RunScheduled(ctx context.Context)
+	StartScheduler(ctx context.Context)
+}
+
+type branchCleanupService struct {
+	db                *gorm.DB
+	gitService        git.GitService
+	repositoryService RepositoryService
+	cfg               config.BranchCleanup
+	logger            *logrus.Logger
+	now               func() time.Time
+}
+
+// NewBranchCleanupService creates a new BranchCleanupService
+func NewBranchCleanupService(db *gorm.DB, gitService git.GitService, repositoryService RepositoryService, cfg config.BranchCleanup, logger *logrus.Logger) BranchCleanupService {
+	return &branchCleanupService{
+		db:                db,
+		gitService:        gitService,
+		repositoryService: repositoryService,
+		cfg:               cfg,
+		logger:            logger,
+		now:               time.Now,
+	}
+}
+
+func (s *branchCleanupService) Suggestions(ctx context.Context, repo *models.Repository, staleDays int) (*BranchCleanupReport, error) {
+	if staleDays <= 0 {
+		staleDays = repo.StaleBranchDays
+	}
+	if staleDays <= 0 {
+		staleDays = 90
+	}
+	report := &BranchCleanupReport{
+		DefaultBranch:            repo.DefaultBranch,
+		StaleDays:                staleDays,
+		AutoDeleteMergedBranches: repo.AutoDeleteMergedBranches,
+		DeleteBranchOnMerge:      repo.DeleteBranchOnMerge,
+		Candidates:               []*BranchCleanupCandidate{},
+	}
+
+	repoPath, err := s.repositoryService.GetRepositoryPath(ctx, repo.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository path: %w", err)
+	}
+	branches, err := s.gitService.GetBranches(ctx, repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	keep, err := s.keptBranches(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	var protection []*models.BranchProtectionRule
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repo.ID).Find(&protection).Error; err != nil {
+		return nil, fmt.Errorf("failed to load branch protection: %w", err)
+	}
+	mergedPRs, err := s.mergedPullRequests(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, branch := range branches {
+		if branch.Name == repo.DefaultBranch || keep[branch.Name] || branchProtected(protection, branch.Name) {
+			continue
+		}
+		names = append(names, branch.Name)
+	}
+	if len(names) == 0 {
+		return report, nil
+	}
+	divergence, err := s.gitService.GetAheadBehind(ctx, repoPath, repo.DefaultBranch, names)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare branches: %w", err)
+	}
+
+	staleBefore := s.now().AddDate(0, 0, -staleDays)
+	for _, branch := range branches {
+		counts, ok := divergence[branch.Name]
+		if !ok {
+			continue
+		}
+		commit, err := s.gitService.GetCommit(ctx, repoPath, branch.SHA)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get head commit of %s: %w", branch.Name, err)
+		}
+
+		candidate := &BranchCleanupCandidate{
+			Name:         branch.Name,
+			SHA:          branch.SHA,
+			LastCommitAt: commit.Committer.Date,
+			AheadBy:      counts.AheadBy,
+			BehindBy:     counts.BehindBy,
+		}
+		if counts.AheadBy == 0 {
+			candidate.Reasons = append(candidate.Reasons, BranchCleanupMerged)
+			candidate.Automatic = repo.AutoDeleteMergedBranches
+		}
+		// Squash and rebase merges leave the branch ahead, so a merged pull
+		// request counts as long as nothing was committed after the merge
+		if pr, ok := mergedPRs[branch.Name]; ok && !commit.Committer.Date.After(*pr.MergedAt) {
+			number := pr.Number
+			candidate.PullRequest = &number
+			candidate.Reasons = append(candidate.Reasons, BranchCleanupPullRequestMerged)
+			candidate.Automatic = candidate.Automatic || repo.DeleteBranchOnMerge || repo.AutoDeleteMergedBranches
+		}
+		if commit.Committer.Date.Before(staleBefore) {
+			candidate.Reasons = append(candidate.Reasons, BranchCleanupStale)
+		}
+		if len(candidate.Reasons) > 0 {
+			report.Candidates = append(report.Candidates, candidate)
+		}
+	}
+
+	sort.Slice(report.Candidates, func(i, j int) bool {
+		return report.Candidates[i].LastCommitAt.Before(report.Candidates[j].LastCommitAt)
+	})
+	return report, nil
+}
+
+// keptBranches returns the branches open pull requests merge from or into
+func (s *branchCleanupService) keptBranches(ctx context.Context, repo *models.Repository) (map[string]bool, error) {
+	var prs []*models.PullRequest
+	err := s.db.WithContext(ctx).Select("base_branch", "head_branch", "head_repository_id").
+		Where("repository_id = ? AND state = ?", repo.ID, models.PullRequestStateOpen).Find(&prs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load open pull requests: %w", err)
+	}
+	keep := make(map[string]bool)
+	for _, pr := range prs {
+		keep[pr.BaseBranch] = true
+		if pr.HeadRepositoryID == nil || *pr.HeadRepositoryID == repo.ID {
+			keep[pr.HeadBranch] = true
+		}
+	}
+	// Pull requests opened from this repository against another one keep
+	// their head branch too
+	var outgoing []*models.PullRequest
+	err = s.db.WithContext(ctx).Select("head_branch").
+		Where("head_repository_id = ? AND repository_id <> ? AND state = ?", repo.ID, repo.ID, models.PullRequestStateOpen).
+		Find(&outgoing).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load open pull requests: %w", err)
+	}
+	for _, pr := range outgoing {
+		keep[pr.HeadBranch] = true
+	}
+	return keep, nil
+}
+
+// mergedPullRequests returns the most recently merged pull request of each
+// head branch of the repository
+func (s *branchCleanupService) mergedPullRequests(ctx context.Context, repo *models.Repository) (map[string]*models.PullRequest, error) {
+	var prs []*models.PullRequest
+	err := s.db.WithContext(ctx).
+		Where("repository_id = ? AND state = ? AND merged_at IS NOT NULL", repo.ID, models.PullRequestStateMerged).
+		Where("head_repository_id IS NULL OR head_repository_id = ?", repo.ID).
+		Order("merged_at ASC").Find(&prs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load merged pull requests: %w", err)
+	}
+	merged := make(map[string]*models.PullRequest, len(prs))
+	for _, pr := range prs {
+		merged[pr.HeadBranch] = pr
+	}
+	return merged, nil
+}
+
+func branchProtected(rules []*models.BranchProtectionRule, branch string) bool {
+	for _, rule := range rules {
+		if matchPattern(rule.Pattern, branch) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *branchCleanupService) DeleteBranches(ctx context.Context, repo *models.Repository, names []string, actorID uuid.UUID) (*BranchCleanupResult, error) {
+	report, err := s.Suggestions(ctx, repo, 0)
+	if err != nil {
+		return nil, err
+	}
+	candidates := make(map[string]bool, len(report.Candidates))
+	for _, candidate := range report.Candidates {
+		candidates[candidate.Name] = true
+	}
+
+	result := &BranchCleanupResult{Deleted: []string{}, Skipped: []BranchCleanupSkipped{}}
+	var deletable []string
+	for _, name := range names {
+		if !candidates[name] {
+			result.Skipped = append(result.Skipped, BranchCleanupSkipped{Name: name, Reason: "not a cleanup candidate"})
+			continue
+		}
+		deletable = append(deletable, name)
+	}
+	s.deleteBranches(ctx, repo, deletable, result)
+
+	s.logger.WithFields(logrus.Fields{
+		"repository_id": repo.ID,
+		"actor_id":      actorID,
+		"deleted":       result.Deleted,
+	}).Info("Deleted branches in bulk")
+	return result, nil
+}
+
+func (s *branchCleanupService) deleteBranches(ctx context.Context, repo *models.Repository, names []string, result *BranchCleanupResult) {
+	if len(names) == 0 {
+		return
+	}
+	repoPath, err := s.repositoryService.GetRepositoryPath(ctx, repo.ID)
+	if err != nil {
+		for _, name := range names {
+			result.Skipped = append(result.Skipped, BranchCleanupSkipped{Name: name, Reason: err.Error()})
+		}
+		return
+	}
+	for _, name := range names {
+		if err := s.gitService.DeleteBranch(ctx, repoPath, name); err != nil {
+			result.Skipped = append(result.Skipped, BranchCleanupSkipped{Name: name, Reason: err.Error()})
+			continue
+		}
+		if err := s.db.WithContext(ctx).Where("repository_id = ? AND name = ?", repo.ID, name).Delete(&models.Branch{}).Error; err != nil {
+			s.logger.WithError(err).WithField("repository_id", repo.ID).Warn("Failed to delete branch record")
+		}
+		result.Deleted = append(result.Deleted, name)
+	}
+}
+
+// RunScheduled deletes the automatic cleanup candidates of every repository
+// that opted into scheduled cleanup or deletes branches on merge
+func (s *branchCleanupService) RunScheduled(ctx context.Context) {
+	var deleted, failed int
+	var repos []*models.Repository
+	err := s.db.WithContext(ctx).
+		Where("auto_delete_merged_branches = ? OR delete_branch_on_merge = ?", true, true).
+		Where("is_archived = ?", false).
+		Order("id").
+		FindInBatches(&repos, maintenanceBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, repo := range repos {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				report, err := s.Suggestions(ctx, repo, 0)
+				if err != nil {
+					failed++
+					s.logger.WithError(err).WithField("repository_id", repo.ID).Warn("Failed to find branches to clean up")
+					continue
+				}
+				var names []string
+				for _, candidate := range report.Candidates {
+					if candidate.Automatic {
+						names = append(names, candidate.Name)
+					}
+				}
+				result := &BranchCleanupResult{}
+				s.deleteBranches(ctx, repo, names, result)
+				deleted += len(result.Deleted)
+				failed += len(result.Skipped)
+			}
+			return nil
+		}).Error
+	if err != nil && !errors.Is(err, context.Canceled) {
+		s.logger.WithError(err).Error("Failed to scan repositories for branch cleanup")
+	}
+	s.logger.WithFields(logrus.Fields{"deleted": deleted, "failed": failed}).Info("Branch cleanup run completed")
+}
+
+// StartScheduler runs the branch cleanup every IntervalHours until ctx is
+// cancelled. It returns immediately when branch cleanup is disabled.
+func (s *branchCleanupService) StartScheduler(ctx context.Context) {
+	if !s.cfg.Enabled {
+		return
+	}
+	interval := time.Duration(s.cfg.IntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			func() {
+				defer errorreporting.Default().Recover("branch_cleanup", nil)
+				s.RunScheduled(ctx)
+			}()
+		}
+	}
+}
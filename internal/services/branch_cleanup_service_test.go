@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranchCleanupService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Repository{}, &models.Branch{}, &models.PullRequest{}, &models.BranchProtectionRule{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	base := t.TempDir()
+	gitService := git.NewGitService(logger)
+	repoService := NewRepositoryService(db, gitService, logger, base)
+	svc := NewBranchCleanupService(db, gitService, repoService, config.BranchCleanup{Enabled: true, IntervalHours: 24}, logger)
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "app",
+		DefaultBranch: "main", Visibility: models.VisibilityPrivate, DeleteBranchOnMerge: true, StaleBranchDays: 90}
+	require.NoError(t, db.Create(repo).Error)
+
+	fixture := testutil.NewGitRepo(t, testutil.RepositoryPath(base, repo))
+	fixture.Commit("main", "initial", map[string]string{"README.md": "app\n"})
+	fixture.Branch("merged", "main")
+	fixture.Branch("release/1.0", "main")
+	fixture.Branch("open", "main")
+	fixture.Branch("feature", "main")
+	fixture.Commit("feature", "work in progress", map[string]string{"feature.go": "package app\n"})
+	fixture.Branch("squashed", "main")
+	fixture.Commit("squashed", "squashed work", map[string]string{"squashed.go": "package app\n"})
+	fixture.Branch("old", "main")
+	fixture.Author.Date = time.Now().AddDate(0, 0, -200)
+	fixture.Commit("old", "abandoned", map[string]string{"old.go": "package app\n"})
+
+	mergedAt := time.Now().Add(time.Minute)
+	require.NoError(t, db.Create([]*models.PullRequest{
+		{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 1, Title: "Open",
+			BaseBranch: "main", HeadBranch: "open", State: models.PullRequestStateOpen},
+		{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 2, Title: "Squashed",
+			BaseBranch: "main", HeadBranch: "squashed", State: models.PullRequestStateMerged, MergedAt: &mergedAt},
+	}).Error)
+	require.NoError(t, db.Create(&models.BranchProtectionRule{ID: uuid.New(), RepositoryID: repo.ID, Pattern: "release/*"}).Error)
+
+	candidates := func(report *BranchCleanupReport) map[string]*BranchCleanupCandidate {
+		byName := make(map[string]*BranchCleanupCandidate)
+		for _, candidate := range report.Candidates {
+			byName[candidate.Name] = candidate
+		}
+		return byName
+	}
+
+	report, err := svc.Suggestions(ctx, repo, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 90, report.StaleDays)
+	found := candidates(report)
+	require.Len(t, found, 3, "default, protected, open pull request and active branches are kept")
+	assert.Equal(t, "old", report.Candidates[0].Name, "oldest first")
+	assert.Equal(t, []string{BranchCleanupStale}, found["old"].Reasons)
+	assert.Equal(t, []string{BranchCleanupMerged}, found["merged"].Reasons)
+	assert.False(t, found["merged"].Automatic)
+	assert.Equal(t, []string{BranchCleanupPullRequestMerged}, found["squashed"].Reasons)
+	require.NotNil(t, found["squashed"].PullRequest)
+	assert.Equal(t, 2, *found["squashed"].PullRequest)
+	assert.True(t, found["squashed"].Automatic, "delete on merge applies to branches merged before it was enabled")
+
+	report, err = svc.Suggestions(ctx, repo, 365)
+	require.NoError(t, err)
+	assert.NotContains(t, candidates(report), "old")
+
+	svc.RunScheduled(ctx)
+	branches, err := gitService.GetBranches(ctx, fixture.Path)
+	require.NoError(t, err)
+	names := make([]string, 0, len(branches))
+	for _, branch := range branches {
+		names = append(names, branch.Name)
+	}
+	assert.NotContains(t, names, "squashed")
+	assert.Contains(t, names, "merged", "merged branches are only deleted automatically when opted in")
+
+	result, err := svc.DeleteBranches(ctx, repo, []string{"merged", "old", "feature", "release/1.0"}, uuid.New())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"merged", "old"}, result.Deleted)
+	require.Len(t, result.Skipped, 2)
+	assert.Equal(t, "not a cleanup candidate", result.Skipped[0].Reason)
+
+	report, err = svc.Suggestions(ctx, repo, 0)
+	require.NoError(t, err)
+	assert.Empty(t, report.Candidates)
+}
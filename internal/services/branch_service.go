@@ -3,7 +3,9 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/a5c-ai/hub/internal/git"
 	"github.com/a5c-ai/hub/internal/models"
@@ -15,10 +17,17 @@ import (
 // BranchService provides branch management operations
 type BranchService interface {
 	List(ctx context.Context, repoID uuid.UUID) ([]*models.Branch, error)
+	ListDetailed(ctx context.Context, repoID uuid.UUID, opts BranchListOptions) ([]*BranchDetails, int64, error)
 	Get(ctx context.Context, repoID uuid.UUID, branchName string) (*models.Branch, error)
 	Create(ctx context.Context, repoID uuid.UUID, req CreateBranchRequest) (*models.Branch, error)
 	Delete(ctx context.Context, repoID uuid.UUID, branchName string) error
 	SetDefault(ctx context.Context, repoID uuid.UUID, branchName string) error
+	// Rename moves a branch to a new name along with the open pull requests
+	// and protection rules that refer to it by name
+	Rename(ctx context.Context, repoID uuid.UUID, branchName string, req RenameBranchRequest) (*models.BranchRename, error)
+	// ResolveRename returns the current name of a branch that was renamed,
+	// following successive renames, or ErrBranchNotFound
+	ResolveRename(ctx context.Context, repoID uuid.UUID, branchName string) (*models.BranchRename, error)
 
 	// Branch protection
 	GetProtectionRule(ctx context.Context, repoID uuid.UUID, pattern string) (*models.BranchProtectionRule, error)
@@ -32,28 +41,63 @@ type BranchService interface {
 	SyncBranchesFromGit(ctx context.Context, repoID uuid.UUID) error
 }
 
+var (
+	ErrBranchNotFound    = errors.New("branch not found")
+	ErrBranchExists      = errors.New("branch already exists")
+	ErrInvalidBranchName = errors.New("invalid branch name")
+)
+
+// maxRenameHops bounds how many successive renames ResolveRename follows
+const maxRenameHops = 10
+
+// RenameBranchRequest represents a request to rename a branch
+type RenameBranchRequest struct {
+	NewName     string     `json:"new_name" binding:"required"`
+	RenamedByID *uuid.UUID `json:"-"`
+}
+
 // CreateBranchRequest represents a request to create a branch
 type CreateBranchRequest struct {
 	Name    string `json:"name"`
 	FromRef string `json:"from_ref,omitempty"` // Branch or commit to create from
 }
 
+// BranchListOptions controls paginated branch listing
+type BranchListOptions struct {
+	Page              int // 1-based
+	PerPage           int
+	IncludeDivergence bool // compute ahead/behind relative to the default branch
+}
+
+// BranchDetails is a branch enriched with protection, last commit and divergence info
+type BranchDetails struct {
+	models.Branch
+	Protected  bool        `json:"protected"`
+	LastCommit *git.Commit `json:"commit,omitempty"`
+	AheadBy    *int        `json:"ahead_by,omitempty"`
+	BehindBy   *int        `json:"behind_by,omitempty"`
+}
+
 // CreateBranchProtectionRequest represents a request to create a branch protection rule
 type CreateBranchProtectionRequest struct {
-	Pattern                    string                      `json:"pattern"`
-	RequiredStatusChecks       *RequiredStatusChecks       `json:"required_status_checks,omitempty"`
-	EnforceAdmins              bool                        `json:"enforce_admins"`
-	RequiredPullRequestReviews *RequiredPullRequestReviews `json:"required_pull_request_reviews,omitempty"`
-	Restrictions               *BranchRestrictions         `json:"restrictions,omitempty"`
+	Pattern                       string                      `json:"pattern"`
+	RequiredStatusChecks          *RequiredStatusChecks       `json:"required_status_checks,omitempty"`
+	EnforceAdmins                 bool                        `json:"enforce_admins"`
+	RequiredPullRequestReviews    *RequiredPullRequestReviews `json:"required_pull_request_reviews,omitempty"`
+	Restrictions                  *BranchRestrictions         `json:"restrictions,omitempty"`
+	RequireConversationResolution bool                        `json:"require_conversation_resolution"`
+	RequireSignedCommits          bool                        `json:"require_signed_commits"`
 }
 
 // UpdateBranchProtectionRequest represents a request to update a branch protection rule
 type UpdateBranchProtectionRequest struct {
-	Pattern                    *string                     `json:"pattern,omitempty"`
-	RequiredStatusChecks       *RequiredStatusChecks       `json:"required_status_checks,omitempty"`
-	EnforceAdmins              *bool                       `json:"enforce_admins,omitempty"`
-	RequiredPullRequestReviews *RequiredPullRequestReviews `json:"required_pull_request_reviews,omitempty"`
-	Restrictions               *BranchRestrictions         `json:"restrictions,omitempty"`
+	Pattern                       *string                     `json:"pattern,omitempty"`
+	RequiredStatusChecks          *RequiredStatusChecks       `json:"required_status_checks,omitempty"`
+	EnforceAdmins                 *bool                       `json:"enforce_admins,omitempty"`
+	RequiredPullRequestReviews    *RequiredPullRequestReviews `json:"required_pull_request_reviews,omitempty"`
+	Restrictions                  *BranchRestrictions         `json:"restrictions,omitempty"`
+	RequireConversationResolution *bool                       `json:"require_conversation_resolution,omitempty"`
+	RequireSignedCommits          *bool                       `json:"require_signed_commits,omitempty"`
 }
 
 // RequiredStatusChecks represents required status checks for branch protection
@@ -112,6 +156,97 @@ func (s *branchService) List(ctx context.Context, repoID uuid.UUID) ([]*models.B
 	return branches, nil
 }
 
+// ListDetailed retrieves a page of branches with protection flags, last commit
+// metadata and, optionally, ahead/behind counts against the default branch
+func (s *branchService) ListDetailed(ctx context.Context, repoID uuid.UUID, opts BranchListOptions) ([]*BranchDetails, int64, error) {
+	if err := s.SyncBranchesFromGit(ctx, repoID); err != nil {
+		s.logger.WithError(err).Warn("Failed to sync branches from Git")
+	}
+
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+	if opts.PerPage <= 0 || opts.PerPage > 100 {
+		opts.PerPage = 30
+	}
+
+	query := s.db.Model(&models.Branch{}).Where("repository_id = ?", repoID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count branches: %w", err)
+	}
+
+	var branches []*models.Branch
+	if err := query.Order("is_default DESC, name ASC").
+		Offset((opts.Page - 1) * opts.PerPage).Limit(opts.PerPage).
+		Find(&branches).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list branches: %w", err)
+	}
+
+	rules, err := s.ListProtectionRules(ctx, repoID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	repoPath, err := s.repositoryService.GetRepositoryPath(ctx, repoID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get repository path: %w", err)
+	}
+
+	shas := make([]string, 0, len(branches))
+	for _, branch := range branches {
+		shas = append(shas, branch.SHA)
+	}
+	// The page's tip commits are read from one open repository
+	commits, err := s.gitService.LookupCommits(ctx, repoPath, shas)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to read branch commits")
+	}
+
+	details := make([]*BranchDetails, 0, len(branches))
+	names := make([]string, 0, len(branches))
+	defaultBranch := ""
+	for _, branch := range branches {
+		d := &BranchDetails{Branch: *branch, Protected: branch.IsProtected}
+		for _, rule := range rules {
+			if matchPattern(rule.Pattern, branch.Name) {
+				d.Protected = true
+				break
+			}
+		}
+		d.LastCommit = commits[branch.SHA]
+		if branch.IsDefault {
+			defaultBranch = branch.Name
+		}
+		details = append(details, d)
+		names = append(names, branch.Name)
+	}
+
+	if opts.IncludeDivergence && len(details) > 0 {
+		if defaultBranch == "" {
+			repo, err := s.repositoryService.GetByID(ctx, repoID)
+			if err != nil {
+				return nil, 0, err
+			}
+			defaultBranch = repo.DefaultBranch
+		}
+
+		counts, err := s.gitService.GetAheadBehind(ctx, repoPath, defaultBranch, names)
+		if err != nil {
+			s.logger.WithError(err).Warn("Failed to compute branch divergence")
+		} else {
+			for _, d := range details {
+				if c, ok := counts[d.Name]; ok {
+					ahead, behind := c.AheadBy, c.BehindBy
+					d.AheadBy, d.BehindBy = &ahead, &behind
+				}
+			}
+		}
+	}
+
+	return details, total, nil
+}
+
 // Get retrieves a single branch by repository ID and name
 func (s *branchService) Get(ctx context.Context, repoID uuid.UUID, branchName string) (*models.Branch, error) {
 	var branch models.Branch
@@ -126,7 +261,7 @@ func (s *branchService) Get(ctx context.Context, repoID uuid.UUID, branchName st
 
 		if err != nil {
 			if err == gorm.ErrRecordNotFound {
-				return nil, fmt.Errorf("branch not found")
+				return nil, ErrBranchNotFound
 			}
 			return nil, fmt.Errorf("failed to get branch: %w", err)
 		}
@@ -167,6 +302,7 @@ func (s *branchService) Create(ctx context.Context, repoID uuid.UUID, req Create
 
 	// Create branch in database
 	branch := &models.Branch{
+		ID:           uuid.New(),
 		RepositoryID: repoID,
 		Name:         req.Name,
 		SHA:          gitBranch.SHA,
@@ -262,6 +398,122 @@ func (s *branchService) SetDefault(ctx context.Context, repoID uuid.UUID, branch
 	return nil
 }
 
+// Rename renames a branch in Git and moves everything that refers to it by
+// name: the default branch setting, the base and head of open pull requests
+// and protection rules for exactly that branch. Wildcard rules are left
+// alone. The rename is recorded so requests for the old name can be
+// redirected.
+func (s *branchService) Rename(ctx context.Context, repoID uuid.UUID, branchName string, req RenameBranchRequest) (*models.BranchRename, error) {
+	newName := strings.TrimSpace(req.NewName)
+	s.logger.WithFields(logrus.Fields{
+		"repo_id":  repoID,
+		"name":     branchName,
+		"new_name": newName,
+	}).Info("Renaming branch")
+
+	if !git.ValidBranchName(newName) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidBranchName, newName)
+	}
+	if newName == branchName {
+		return nil, fmt.Errorf("%w: the new name is the current name", ErrInvalidBranchName)
+	}
+
+	branch, err := s.Get(ctx, repoID, branchName)
+	if err != nil {
+		return nil, err
+	}
+
+	repoPath, err := s.repositoryService.GetRepositoryPath(ctx, repoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository path: %w", err)
+	}
+	if _, err := s.gitService.GetBranch(ctx, repoPath, newName); err == nil {
+		return nil, fmt.Errorf("%w: %s", ErrBranchExists, newName)
+	}
+
+	if err := s.gitService.RenameBranch(ctx, repoPath, branchName, newName); err != nil {
+		return nil, fmt.Errorf("failed to rename branch in Git: %w", err)
+	}
+
+	rename := &models.BranchRename{
+		ID:           uuid.New(),
+		RepositoryID: repoID,
+		OldName:      branchName,
+		NewName:      newName,
+		RenamedByID:  req.RenamedByID,
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Branch{}).Where("id = ?", branch.ID).Update("name", newName).Error; err != nil {
+			return fmt.Errorf("failed to rename branch: %w", err)
+		}
+		if branch.IsDefault {
+			if err := tx.Model(&models.Repository{}).Where("id = ?", repoID).
+				Updates(map[string]interface{}{"default_branch": newName, "version": gorm.Expr("version + 1")}).Error; err != nil {
+				return fmt.Errorf("failed to update default branch: %w", err)
+			}
+		}
+
+		open := tx.Model(&models.PullRequest{}).Where("repository_id = ? AND state = ?", repoID, models.PullRequestStateOpen)
+		result := open.Session(&gorm.Session{}).Where("base_branch = ?", branchName).Update("base_branch", newName)
+		if result.Error != nil {
+			return fmt.Errorf("failed to retarget pull requests: %w", result.Error)
+		}
+		rename.RetargetedPullRequests = int(result.RowsAffected)
+		if err := open.Session(&gorm.Session{}).
+			Where("head_branch = ? AND (head_repository_id = ? OR head_repository_id IS NULL)", branchName, repoID).
+			Update("head_branch", newName).Error; err != nil {
+			return fmt.Errorf("failed to update pull request heads: %w", err)
+		}
+
+		result = tx.Model(&models.BranchProtectionRule{}).Where("repository_id = ? AND pattern = ?", repoID, branchName).Update("pattern", newName)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update branch protection rules: %w", result.Error)
+		}
+		rename.UpdatedProtectionRules = int(result.RowsAffected)
+		result = tx.Model(&models.PathProtectionRule{}).Where("repository_id = ? AND branch_pattern = ?", repoID, branchName).Update("branch_pattern", newName)
+		if result.Error != nil {
+			return fmt.Errorf("failed to update path protection rules: %w", result.Error)
+		}
+		rename.UpdatedProtectionRules += int(result.RowsAffected)
+
+		return tx.Create(rename).Error
+	})
+	if err != nil {
+		// Put the Git branch back so Git and the database agree
+		if rollbackErr := s.gitService.RenameBranch(ctx, repoPath, newName, branchName); rollbackErr != nil {
+			s.logger.WithError(rollbackErr).Error("Failed to restore branch after failed rename")
+		}
+		return nil, err
+	}
+
+	return rename, nil
+}
+
+func (s *branchService) ResolveRename(ctx context.Context, repoID uuid.UUID, branchName string) (*models.BranchRename, error) {
+	var resolved *models.BranchRename
+	name := branchName
+	for i := 0; i < maxRenameHops; i++ {
+		var rename models.BranchRename
+		err := s.db.WithContext(ctx).Where("repository_id = ? AND old_name = ?", repoID, name).
+			Order("created_at DESC").First(&rename).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up branch renames: %w", err)
+		}
+		resolved = &rename
+		name = rename.NewName
+	}
+	if resolved == nil {
+		return nil, ErrBranchNotFound
+	}
+
+	// Report the chain as a single rename to the latest name
+	resolved.OldName = branchName
+	return resolved, nil
+}
+
 // SyncBranchesFromGit synchronizes branches from Git repository to database
 func (s *branchService) SyncBranchesFromGit(ctx context.Context, repoID uuid.UUID) error {
 	// Get repository path
@@ -306,6 +558,7 @@ func (s *branchService) SyncBranchesFromGit(ctx context.Context, repoID uuid.UUI
 		} else {
 			// Create new branch
 			newBranch := &models.Branch{
+				ID:           uuid.New(),
 				RepositoryID: repoID,
 				Name:         gitBranch.Name,
 				SHA:          gitBranch.SHA,
@@ -393,12 +646,14 @@ func (s *branchService) CreateProtectionRule(ctx context.Context, repoID uuid.UU
 
 	// Create protection rule
 	rule := &models.BranchProtectionRule{
-		RepositoryID:               repoID,
-		Pattern:                    req.Pattern,
-		RequiredStatusChecks:       requiredStatusChecksJSON,
-		EnforceAdmins:              req.EnforceAdmins,
-		RequiredPullRequestReviews: requiredPRReviewsJSON,
-		Restrictions:               restrictionsJSON,
+		RepositoryID:                  repoID,
+		Pattern:                       req.Pattern,
+		RequiredStatusChecks:          requiredStatusChecksJSON,
+		EnforceAdmins:                 req.EnforceAdmins,
+		RequiredPullRequestReviews:    requiredPRReviewsJSON,
+		Restrictions:                  restrictionsJSON,
+		RequireConversationResolution: req.RequireConversationResolution,
+		RequireSignedCommits:          req.RequireSignedCommits,
 	}
 
 	if err := s.db.Create(rule).Error; err != nil {
@@ -439,6 +694,14 @@ func (s *branchService) UpdateProtectionRule(ctx context.Context, ruleID uuid.UU
 		rule.EnforceAdmins = *req.EnforceAdmins
 	}
 
+	if req.RequireConversationResolution != nil {
+		rule.RequireConversationResolution = *req.RequireConversationResolution
+	}
+
+	if req.RequireSignedCommits != nil {
+		rule.RequireSignedCommits = *req.RequireSignedCommits
+	}
+
 	if req.RequiredStatusChecks != nil {
 		statusChecksBytes, err := json.Marshal(req.RequiredStatusChecks)
 		if err != nil {
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBranchService_Rename(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Repository{}, &models.Branch{}, &models.PullRequest{},
+		&models.BranchProtectionRule{}, &models.PathProtectionRule{}, &models.BranchRename{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	base := t.TempDir()
+	gitService := git.NewGitService(logger)
+	repoService := NewRepositoryService(db, gitService, logger, base)
+	svc := NewBranchService(db, gitService, repoService, logger)
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "app", DefaultBranch: "master", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	fixture := testutil.NewGitRepo(t, testutil.RepositoryPath(base, repo))
+	fixture.Commit("master", "initial", map[string]string{"README.md": "hello\n"})
+	fixture.Branch("feature", "master")
+	require.NoError(t, svc.SyncBranchesFromGit(ctx, repo.ID))
+	require.NoError(t, db.Model(&models.Branch{}).Where("repository_id = ? AND name = ?", repo.ID, "master").Update("is_default", true).Error)
+
+	prs := []*models.PullRequest{
+		{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, HeadRepositoryID: &repo.ID, Number: 1, Title: "Open", BaseBranch: "master", HeadBranch: "feature", State: models.PullRequestStateOpen},
+		{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, HeadRepositoryID: &repo.ID, Number: 2, Title: "Closed", BaseBranch: "master", HeadBranch: "feature", State: models.PullRequestStateClosed},
+	}
+	require.NoError(t, db.Create(prs).Error)
+	require.NoError(t, db.Create([]*models.BranchProtectionRule{
+		{ID: uuid.New(), RepositoryID: repo.ID, Pattern: "master"},
+		{ID: uuid.New(), RepositoryID: repo.ID, Pattern: "release/*"},
+	}).Error)
+
+	t.Run("rejects bad names", func(t *testing.T) {
+		_, err := svc.Rename(ctx, repo.ID, "master", RenameBranchRequest{NewName: "bad name"})
+		assert.ErrorIs(t, err, ErrInvalidBranchName)
+		_, err = svc.Rename(ctx, repo.ID, "master", RenameBranchRequest{NewName: "feature"})
+		assert.ErrorIs(t, err, ErrBranchExists)
+		_, err = svc.Rename(ctx, repo.ID, "missing", RenameBranchRequest{NewName: "other"})
+		assert.ErrorIs(t, err, ErrBranchNotFound)
+	})
+
+	actor := uuid.New()
+	rename, err := svc.Rename(ctx, repo.ID, "master", RenameBranchRequest{NewName: "main", RenamedByID: &actor})
+	require.NoError(t, err)
+	assert.Equal(t, 1, rename.RetargetedPullRequests)
+	assert.Equal(t, 1, rename.UpdatedProtectionRules)
+
+	repoPath := testutil.RepositoryPath(base, repo)
+	_, err = gitService.GetBranch(ctx, repoPath, "master")
+	assert.Error(t, err)
+	_, err = gitService.GetBranch(ctx, repoPath, "main")
+	assert.NoError(t, err)
+	head, err := os.ReadFile(filepath.Join(repoPath, "HEAD"))
+	require.NoError(t, err)
+	assert.Equal(t, "ref: refs/heads/main", strings.TrimSpace(string(head)))
+
+	var updated models.Repository
+	require.NoError(t, db.First(&updated, "id = ?", repo.ID).Error)
+	assert.Equal(t, "main", updated.DefaultBranch)
+
+	branch, err := svc.Get(ctx, repo.ID, "main")
+	require.NoError(t, err)
+	assert.True(t, branch.IsDefault)
+
+	var open, closed models.PullRequest
+	require.NoError(t, db.First(&open, "id = ?", prs[0].ID).Error)
+	require.NoError(t, db.First(&closed, "id = ?", prs[1].ID).Error)
+	assert.Equal(t, "main", open.BaseBranch)
+	assert.Equal(t, "master", closed.BaseBranch)
+
+	_, err = svc.GetProtectionRule(ctx, repo.ID, "main")
+	assert.NoError(t, err)
+	_, err = svc.GetProtectionRule(ctx, repo.ID, "release/*")
+	assert.NoError(t, err)
+
+	// The original name resolves through successive renames
+	_, err = svc.Rename(ctx, repo.ID, "main", RenameBranchRequest{NewName: "trunk"})
+	require.NoError(t, err)
+	resolved, err := svc.ResolveRename(ctx, repo.ID, "master")
+	require.NoError(t, err)
+	assert.Equal(t, "trunk", resolved.NewName)
+	assert.Equal(t, "master", resolved.OldName)
+
+	_, err = svc.ResolveRename(ctx, repo.ID, "never-existed")
+	assert.ErrorIs(t, err, ErrBranchNotFound)
+}
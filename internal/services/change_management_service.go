@@ -0,0 +1,443 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/audit"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChangeManagementService requires pull requests to reference change
+// tickets, validating them by pattern or against ServiceNow or Jira
+type ChangeManagementService interface {
+	GetPolicy(ctx context.Context, repoID uuid.UUID) (*models.ChangeManagementPolicy, error)
+	UpdatePolicy(ctx context.Context, repoID uuid.UUID, req ChangeManagementPolicyRequest) (*models.ChangeManagementPolicy, error)
+	DeletePolicy(ctx context.Context, repoID uuid.UUID) error
+
+	// LinkPullRequest links the pull request to the first ticket its title
+	// or body references and validates it. It returns nil when no policy
+	// applies to the pull request's base branch.
+	LinkPullRequest(ctx context.Context, pr *models.PullRequest) (*models.PullRequestChangeTicket, error)
+	// Evaluate reports whether the pull request references a valid ticket,
+	// validating it again once the last validation is stale. It returns
+	// nil when no policy applies.
+	Evaluate(ctx context.Context, pr *models.PullRequest) (*ChangeTicketEvaluation, error)
+	// Revalidate validates the pull request's ticket with the provider now
+	Revalidate(ctx context.Context, pr *models.PullRequest) (*ChangeTicketEvaluation, error)
+	// MarkMerged records that the pull request was merged under its ticket
+	MarkMerged(ctx context.Context, pr *models.PullRequest, mergedAt time.Time) error
+}
+
+// ChangeManagementPolicyRequest creates or updates a repository's policy.
+// A Token left out keeps the current one.
+type ChangeManagementPolicyRequest struct {
+	Enabled       *bool     `json:"enabled"`
+	BranchPattern *string   `json:"branch_pattern"`
+	TicketPattern *string   `json:"ticket_pattern"`
+	Provider      *string   `json:"provider"`
+	BaseURL       *string   `json:"base_url"`
+	Username      *string   `json:"username"`
+	Token         *string   `json:"token"`
+	AllowedStates *[]string `json:"allowed_states"`
+}
+
+// ChangeTicketEvaluation reports how a pull request fares against its
+// repository's change management policy
+type ChangeTicketEvaluation struct {
+	TicketPattern string                          `json:"ticket_pattern"`
+	Provider      string                          `json:"provider"`
+	Ticket        *models.PullRequestChangeTicket `json:"ticket,omitempty"`
+	Satisfied     bool                            `json:"satisfied"`
+	Reason        string                          `json:"reason,omitempty"`
+}
+
+var (
+	ErrChangeManagementPolicyNotFound = errors.New("change management policy not found")
+	ErrInvalidChangeManagementPolicy  = errors.New("invalid change management policy")
+	// errChangeTicketNotFound is returned by providers for unknown tickets
+	errChangeTicketNotFound = errors.New("change ticket not found")
+)
+
+// changeTicketRevalidateAfter is how long a provider's answer is trusted
+// before a ticket is looked up again, so approvals and cancellations in
+// the provider reach pull requests
+const changeTicketRevalidateAfter = 5 * time.Minute
+
+// changeTicketInfo is what a provider knows about a ticket
+type changeTicketInfo struct {
+	State   string
+	Summary string
+	URL     string
+}
+
+type changeManagementService struct {
+	db     *gorm.DB
+	client *http.Client
+	logger *logrus.Logger
+	now    func() time.Time
+}
+
+// NewChangeManagementService creates a new ChangeManagementService
+func NewChangeManagementService(db *gorm.DB, logger *logrus.Logger) ChangeManagementService {
+	return &changeManagementService{
+		db:     db,
+		client: &http.Client{Timeout: 10 * time.Second},
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+func (s *changeManagementService) GetPolicy(ctx context.Context, repoID uuid.UUID) (*models.ChangeManagementPolicy, error) {
+	var policy models.ChangeManagementPolicy
+	err := s.db.WithContext(ctx).First(&policy, "repository_id = ?", repoID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrChangeManagementPolicyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get change management policy: %w", err)
+	}
+	return &policy, nil
+}
+
+func (s *changeManagementService) UpdatePolicy(ctx context.Context, repoID uuid.UUID, req ChangeManagementPolicyRequest) (*models.ChangeManagementPolicy, error) {
+	policy, err := s.GetPolicy(ctx, repoID)
+	if errors.Is(err, ErrChangeManagementPolicyNotFound) {
+		policy = &models.ChangeManagementPolicy{RepositoryID: repoID, Enabled: true, BranchPattern: "*", Provider: models.ChangeTicketProviderPattern}
+	} else if err != nil {
+		return nil, err
+	}
+
+	if req.Enabled != nil {
+		policy.Enabled = *req.Enabled
+	}
+	if req.BranchPattern != nil {
+		policy.BranchPattern = strings.TrimSpace(*req.BranchPattern)
+	}
+	if req.TicketPattern != nil {
+		policy.TicketPattern = strings.TrimSpace(*req.TicketPattern)
+	}
+	if req.Provider != nil {
+		policy.Provider = strings.ToLower(strings.TrimSpace(*req.Provider))
+	}
+	if req.BaseURL != nil {
+		policy.BaseURL = strings.TrimSuffix(strings.TrimSpace(*req.BaseURL), "/")
+	}
+	if req.Username != nil {
+		policy.Username = strings.TrimSpace(*req.Username)
+	}
+	if req.Token != nil {
+		policy.Token = *req.Token
+	}
+	if req.AllowedStates != nil {
+		policy.AllowedStates = *req.AllowedStates
+	}
+	if err := validateChangeManagementPolicy(policy); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Save(policy).Error; err != nil {
+		return nil, fmt.Errorf("failed to save change management policy: %w", err)
+	}
+	return policy, nil
+}
+
+func validateChangeManagementPolicy(policy *models.ChangeManagementPolicy) error {
+	if policy.BranchPattern == "" {
+		return fmt.Errorf("%w: branch_pattern is required", ErrInvalidChangeManagementPolicy)
+	}
+	if policy.TicketPattern == "" {
+		return fmt.Errorf("%w: ticket_pattern is required", ErrInvalidChangeManagementPolicy)
+	}
+	if _, err := regexp.Compile(policy.TicketPattern); err != nil {
+		return fmt.Errorf("%w: ticket_pattern: %v", ErrInvalidChangeManagementPolicy, err)
+	}
+	switch policy.Provider {
+	case models.ChangeTicketProviderPattern:
+		return nil
+	case models.ChangeTicketProviderServiceNow, models.ChangeTicketProviderJira:
+	default:
+		return fmt.Errorf("%w: provider must be pattern, servicenow or jira", ErrInvalidChangeManagementPolicy)
+	}
+	endpoint, err := url.Parse(policy.BaseURL)
+	if err != nil || (endpoint.Scheme != "https" && endpoint.Scheme != "http") || endpoint.Host == "" {
+		return fmt.Errorf("%w: base_url must be an http or https URL", ErrInvalidChangeManagementPolicy)
+	}
+	if policy.Token == "" {
+		return fmt.Errorf("%w: token is required to validate tickets with %s", ErrInvalidChangeManagementPolicy, policy.Provider)
+	}
+	if policy.Provider == models.ChangeTicketProviderServiceNow && policy.Username == "" {
+		return fmt.Errorf("%w: username is required for servicenow", ErrInvalidChangeManagementPolicy)
+	}
+	return nil
+}
+
+func (s *changeManagementService) DeletePolicy(ctx context.Context, repoID uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("repository_id = ?", repoID).Delete(&models.ChangeManagementPolicy{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete change management policy: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrChangeManagementPolicyNotFound
+	}
+	return nil
+}
+
+// applicablePolicy returns the enabled policy covering the pull request's
+// base branch, or nil
+func (s *changeManagementService) applicablePolicy(ctx context.Context, pr *models.PullRequest) (*models.ChangeManagementPolicy, error) {
+	var policy models.ChangeManagementPolicy
+	err := s.db.WithContext(ctx).Where("repository_id = ? AND enabled = ?", pr.RepositoryID, true).Limit(1).Find(&policy).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load change management policy: %w", err)
+	}
+	if policy.RepositoryID == uuid.Nil || !matchPattern(policy.BranchPattern, pr.BaseBranch) {
+		return nil, nil
+	}
+	return &policy, nil
+}
+
+func (s *changeManagementService) LinkPullRequest(ctx context.Context, pr *models.PullRequest) (*models.PullRequestChangeTicket, error) {
+	policy, err := s.applicablePolicy(ctx, pr)
+	if err != nil || policy == nil {
+		return nil, err
+	}
+	return s.link(ctx, policy, pr, false)
+}
+
+func (s *changeManagementService) Evaluate(ctx context.Context, pr *models.PullRequest) (*ChangeTicketEvaluation, error) {
+	return s.evaluate(ctx, pr, false)
+}
+
+func (s *changeManagementService) Revalidate(ctx context.Context, pr *models.PullRequest) (*ChangeTicketEvaluation, error) {
+	return s.evaluate(ctx, pr, true)
+}
+
+func (s *changeManagementService) evaluate(ctx context.Context, pr *models.PullRequest, force bool) (*ChangeTicketEvaluation, error) {
+	policy, err := s.applicablePolicy(ctx, pr)
+	if err != nil || policy == nil {
+		return nil, err
+	}
+	ticket, err := s.link(ctx, policy, pr, force)
+	if err != nil {
+		return nil, err
+	}
+
+	eval := &ChangeTicketEvaluation{TicketPattern: policy.TicketPattern, Provider: policy.Provider, Ticket: ticket}
+	switch {
+	case ticket == nil:
+		eval.Reason = fmt.Sprintf("branch %s requires a change ticket matching %s in the title or description", pr.BaseBranch, policy.TicketPattern)
+	case !ticket.Valid:
+		eval.Reason = fmt.Sprintf("change ticket %s is not valid: %s", ticket.TicketKey, ticket.Reason)
+	default:
+		eval.Satisfied = true
+	}
+	return eval, nil
+}
+
+// link finds the ticket the pull request references and brings its
+// linkage up to date. A ticket is validated again when it changed, when
+// force is set or when the provider's last answer is stale. Merged pull
+// requests keep the ticket they were merged under.
+func (s *changeManagementService) link(ctx context.Context, policy *models.ChangeManagementPolicy, pr *models.PullRequest, force bool) (*models.PullRequestChangeTicket, error) {
+	var existing models.PullRequestChangeTicket
+	err := s.db.WithContext(ctx).Where("pull_request_id = ?", pr.ID).Limit(1).Find(&existing).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load change ticket: %w", err)
+	}
+	if existing.ID != uuid.Nil && existing.MergedAt != nil {
+		return &existing, nil
+	}
+
+	key := findChangeTicket(policy.TicketPattern, pr.Title, pr.Body)
+	if key == "" {
+		if existing.ID != uuid.Nil {
+			if err := s.db.WithContext(ctx).Delete(&existing).Error; err != nil {
+				return nil, fmt.Errorf("failed to unlink change ticket: %w", err)
+			}
+		}
+		return nil, nil
+	}
+
+	now := s.now()
+	changed := existing.ID == uuid.Nil || existing.TicketKey != key || existing.Provider != policy.Provider
+	stale := policy.Provider != models.ChangeTicketProviderPattern &&
+		(existing.ValidatedAt == nil || now.Sub(*existing.ValidatedAt) >= changeTicketRevalidateAfter)
+	if !changed && !stale && !force {
+		return &existing, nil
+	}
+
+	ticket := existing
+	if ticket.ID == uuid.Nil {
+		ticket = models.PullRequestChangeTicket{ID: uuid.New(), PullRequestID: pr.ID, RepositoryID: pr.RepositoryID}
+	}
+	ticket.TicketKey, ticket.Provider, ticket.ValidatedAt = key, policy.Provider, &now
+	ticket.Valid, ticket.State, ticket.Summary, ticket.URL, ticket.Reason = false, "", "", "", ""
+
+	info, err := s.lookup(ctx, policy, key)
+	switch {
+	case errors.Is(err, errChangeTicketNotFound):
+		ticket.Reason = fmt.Sprintf("%s was not found in %s", key, policy.Provider)
+	case err != nil:
+		// Tickets that cannot be checked block merging until they can be
+		s.logger.WithError(err).WithFields(logrus.Fields{"pull_request_id": pr.ID, "ticket": key}).Warn("Failed to validate change ticket")
+		ticket.Reason = fmt.Sprintf("%s could not be validated with %s", key, policy.Provider)
+	default:
+		ticket.State, ticket.Summary, ticket.URL = info.State, truncate(info.Summary, 500), info.URL
+		ticket.Valid = changeTicketStateAllowed(policy.AllowedStates, info.State)
+		if !ticket.Valid {
+			ticket.Reason = fmt.Sprintf("state %q does not allow merging, expected one of %s", info.State, strings.Join(policy.AllowedStates, ", "))
+		}
+	}
+
+	err = s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "pull_request_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"ticket_key", "provider", "valid", "state", "summary", "url", "reason", "validated_at", "updated_at"}),
+	}).Create(&ticket).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to save change ticket: %w", err)
+	}
+	if changed {
+		audit.Record(ctx, audit.Event{
+			Action:       audit.ActionChangeTicketLink,
+			RepositoryID: &pr.RepositoryID,
+			TargetType:   "pull_request",
+			TargetID:     pr.ID.String(),
+			TargetName:   fmt.Sprintf("#%d", pr.Number),
+			Metadata:     map[string]interface{}{"ticket": key, "provider": policy.Provider, "valid": ticket.Valid, "state": ticket.State},
+		})
+	}
+	return &ticket, nil
+}
+
+func (s *changeManagementService) MarkMerged(ctx context.Context, pr *models.PullRequest, mergedAt time.Time) error {
+	return s.db.WithContext(ctx).Model(&models.PullRequestChangeTicket{}).
+		Where("pull_request_id = ? AND merged_at IS NULL", pr.ID).
+		Update("merged_at", mergedAt).Error
+}
+
+// findChangeTicket returns the first ticket key pattern finds in the title,
+// then the body
+func findChangeTicket(pattern, title, body string) string {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return ""
+	}
+	if key := re.FindString(title); key != "" {
+		return key
+	}
+	return re.FindString(body)
+}
+
+func changeTicketStateAllowed(allowed []string, state string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, candidate := range allowed {
+		if strings.EqualFold(strings.TrimSpace(candidate), state) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookup asks the policy's provider about a ticket
+func (s *changeManagementService) lookup(ctx context.Context, policy *models.ChangeManagementPolicy, key string) (*changeTicketInfo, error) {
+	switch policy.Provider {
+	case models.ChangeTicketProviderServiceNow:
+		return s.lookupServiceNow(ctx, policy, key)
+	case models.ChangeTicketProviderJira:
+		return s.lookupJira(ctx, policy, key)
+	default:
+		return &changeTicketInfo{}, nil
+	}
+}
+
+// lookupServiceNow finds a change request by number through the Table API
+func (s *changeManagementService) lookupServiceNow(ctx context.Context, policy *models.ChangeManagementPolicy, key string) (*changeTicketInfo, error) {
+	query := url.Values{
+		"sysparm_query":         {"number=" + key},
+		"sysparm_fields":        {"sys_id,number,short_description,state"},
+		"sysparm_display_value": {"true"},
+		"sysparm_limit":         {"1"},
+	}
+	var result struct {
+		Result []struct {
+			SysID            string `json:"sys_id"`
+			ShortDescription string `json:"short_description"`
+			State            string `json:"state"`
+		} `json:"result"`
+	}
+	if err := s.getJSON(ctx, policy, policy.BaseURL+"/api/now/table/change_request?"+query.Encode(), &result); err != nil {
+		return nil, err
+	}
+	if len(result.Result) == 0 {
+		return nil, errChangeTicketNotFound
+	}
+	change := result.Result[0]
+	return &changeTicketInfo{
+		State:   change.State,
+		Summary: change.ShortDescription,
+		URL:     policy.BaseURL + "/nav_to.do?uri=" + url.QueryEscape("change_request.do?sys_id="+change.SysID),
+	}, nil
+}
+
+// lookupJira fetches an issue's summary and status
+func (s *changeManagementService) lookupJira(ctx context.Context, policy *models.ChangeManagementPolicy, key string) (*changeTicketInfo, error) {
+	var issue struct {
+		Key    string `json:"key"`
+		Fields struct {
+			Summary string `json:"summary"`
+			Status  struct {
+				Name string `json:"name"`
+			} `json:"status"`
+		} `json:"fields"`
+	}
+	if err := s.getJSON(ctx, policy, policy.BaseURL+"/rest/api/2/issue/"+url.PathEscape(key)+"?fields=summary,status", &issue); err != nil {
+		return nil, err
+	}
+	return &changeTicketInfo{
+		State:   issue.Fields.Status.Name,
+		Summary: issue.Fields.Summary,
+		URL:     policy.BaseURL + "/browse/" + url.PathEscape(issue.Key),
+	}, nil
+}
+
+func (s *changeManagementService) getJSON(ctx context.Context, policy *models.ChangeManagementPolicy, endpoint string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if policy.Username != "" {
+		req.SetBasicAuth(policy.Username, policy.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+policy.Token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errChangeTicketNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %d: %s", policy.Provider, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeManagementService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.Issue{}, &models.PullRequest{},
+		&models.PullRequestMerge{}, &models.IssueEvent{}, &models.Review{}, &models.BranchProtectionRule{}, &models.PathProtectionRule{},
+		&models.MergeChecklistItem{}, &models.PullRequestChecklistCheck{}, &models.RequiredStatusCheck{},
+		&models.ChangeManagementPolicy{}, &models.PullRequestChangeTicket{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	svc := NewChangeManagementService(db, logger)
+	prService := NewPullRequestService(db, nil, nil, logger, "")
+
+	newRepo := func(name string) *models.Repository {
+		repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: name, DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+		require.NoError(t, db.Create(repo).Error)
+		return repo
+	}
+	number := 0
+	newPullRequest := func(repo *models.Repository, base, title string) *models.PullRequest {
+		number++
+		pr := &models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: number, Title: title,
+			BaseBranch: base, HeadBranch: "feature", State: models.PullRequestStateOpen}
+		require.NoError(t, db.Create(pr).Error)
+		return pr
+	}
+	str := func(s string) *string { return &s }
+
+	t.Run("validates policies", func(t *testing.T) {
+		repo := newRepo("invalid")
+		for _, req := range []ChangeManagementPolicyRequest{
+			{},
+			{TicketPattern: str("CHG[")},
+			{TicketPattern: str(`CHG\d+`), Provider: str("remedy")},
+			{TicketPattern: str(`OPS-\d+`), Provider: str("jira"), BaseURL: str("https://jira.example.com")},
+			{TicketPattern: str(`CHG\d+`), Provider: str("servicenow"), BaseURL: str("ftp://example.com"), Token: str("x")},
+		} {
+			_, err := svc.UpdatePolicy(ctx, repo.ID, req)
+			assert.ErrorIs(t, err, ErrInvalidChangeManagementPolicy)
+		}
+		_, err := svc.GetPolicy(ctx, repo.ID)
+		assert.ErrorIs(t, err, ErrChangeManagementPolicyNotFound)
+	})
+
+	t.Run("pattern policies block merging until a ticket is referenced", func(t *testing.T) {
+		repo := newRepo("app")
+		policy, err := svc.UpdatePolicy(ctx, repo.ID, ChangeManagementPolicyRequest{TicketPattern: str(`CHG\d{7}`), BranchPattern: str("main")})
+		require.NoError(t, err)
+		assert.True(t, policy.Enabled)
+		assert.Equal(t, models.ChangeTicketProviderPattern, policy.Provider)
+
+		other := newPullRequest(repo, "develop", "No ticket needed")
+		eval, err := svc.Evaluate(ctx, other)
+		require.NoError(t, err)
+		assert.Nil(t, eval, "the policy only covers main")
+
+		pr := newPullRequest(repo, "main", "Rotate certificates")
+		var blocked *MergeBlockedError
+		require.True(t, errors.As(prService.Merge(ctx, pr.ID, MergePullRequestRequest{}), &blocked))
+		require.NotNil(t, blocked.Requirements.ChangeTicket)
+		assert.Contains(t, blocked.Requirements.Reasons[0], `requires a change ticket matching CHG\d{7}`)
+
+		_, err = prService.Update(ctx, pr.ID, UpdatePullRequestRequest{Body: str("Approved in CHG0012345 and CHG0099999")})
+		require.NoError(t, err)
+		linked, err := prService.GetByNumber(ctx, repo.ID, pr.Number)
+		require.NoError(t, err)
+		require.NotNil(t, linked.ChangeTicket)
+		assert.Equal(t, "CHG0012345", linked.ChangeTicket.TicketKey)
+		assert.True(t, linked.ChangeTicket.Valid)
+
+		require.NoError(t, prService.Merge(ctx, pr.ID, MergePullRequestRequest{}))
+		var ticket models.PullRequestChangeTicket
+		require.NoError(t, db.First(&ticket, "pull_request_id = ?", pr.ID).Error)
+		assert.NotNil(t, ticket.MergedAt, "the ticket is kept as the record of the merge")
+
+		// Disabled policies no longer apply
+		_, err = svc.UpdatePolicy(ctx, repo.ID, ChangeManagementPolicyRequest{Enabled: new(bool)})
+		require.NoError(t, err)
+		eval, err = svc.Evaluate(ctx, newPullRequest(repo, "main", "Untracked"))
+		require.NoError(t, err)
+		assert.Nil(t, eval)
+		require.NoError(t, svc.DeletePolicy(ctx, repo.ID))
+		assert.ErrorIs(t, svc.DeletePolicy(ctx, repo.ID), ErrChangeManagementPolicyNotFound)
+	})
+
+	t.Run("jira tickets must be in an allowed state", func(t *testing.T) {
+		status := "In Review"
+		lookups := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lookups++
+			user, token, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, "bot@example.com", user)
+			assert.Equal(t, "secret", token)
+			if r.URL.Path != "/rest/api/2/issue/OPS-7" {
+				http.NotFound(w, r)
+				return
+			}
+			issue := map[string]interface{}{"key": "OPS-7", "fields": map[string]interface{}{"summary": "Rotate certificates", "status": map[string]string{"name": status}}}
+			_ = json.NewEncoder(w).Encode(issue)
+		}))
+		defer server.Close()
+
+		repo := newRepo("jira")
+		policy, err := svc.UpdatePolicy(ctx, repo.ID, ChangeManagementPolicyRequest{TicketPattern: str(`OPS-\d+`), Provider: str("jira"),
+			BaseURL: str(server.URL + "/"), Username: str("bot@example.com"), Token: str("secret"), AllowedStates: &[]string{"approved"}})
+		require.NoError(t, err)
+		assert.Equal(t, server.URL, policy.BaseURL)
+
+		pr := newPullRequest(repo, "main", "OPS-7: rotate certificates")
+		eval, err := svc.Evaluate(ctx, pr)
+		require.NoError(t, err)
+		assert.False(t, eval.Satisfied)
+		assert.Equal(t, "Rotate certificates", eval.Ticket.Summary)
+		assert.Equal(t, server.URL+"/browse/OPS-7", eval.Ticket.URL)
+		assert.Contains(t, eval.Reason, `state "In Review" does not allow merging`)
+
+		// Answers are reused until they are stale or revalidated on demand
+		status = "Approved"
+		eval, err = svc.Evaluate(ctx, pr)
+		require.NoError(t, err)
+		assert.False(t, eval.Satisfied)
+		assert.Equal(t, 1, lookups)
+		eval, err = svc.Revalidate(ctx, pr)
+		require.NoError(t, err)
+		assert.True(t, eval.Satisfied)
+		assert.Equal(t, "Approved", eval.Ticket.State)
+
+		missing := newPullRequest(repo, "main", "OPS-8: unknown")
+		eval, err = svc.Evaluate(ctx, missing)
+		require.NoError(t, err)
+		assert.False(t, eval.Satisfied)
+		assert.Contains(t, eval.Reason, "OPS-8 was not found in jira")
+	})
+
+	t.Run("servicenow change requests are looked up by number", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/api/now/table/change_request", r.URL.Path)
+			assert.Equal(t, "number=CHG0000042", r.URL.Query().Get("sysparm_query"))
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"result": []map[string]string{
+				{"sys_id": "abc123", "short_description": "Database upgrade", "state": "Implement"},
+			}})
+		}))
+		defer server.Close()
+
+		repo := newRepo("servicenow")
+		_, err := svc.UpdatePolicy(ctx, repo.ID, ChangeManagementPolicyRequest{TicketPattern: str(`CHG\d{7}`), Provider: str("servicenow"),
+			BaseURL: str(server.URL), Username: str("hub"), Token: str("secret"), AllowedStates: &[]string{"Scheduled", "Implement"}})
+		require.NoError(t, err)
+
+		ticket, err := svc.LinkPullRequest(ctx, newPullRequest(repo, "main", "Upgrade database (CHG0000042)"))
+		require.NoError(t, err)
+		assert.True(t, ticket.Valid)
+		assert.Equal(t, "Database upgrade", ticket.Summary)
+		assert.Contains(t, ticket.URL, "sys_id%3Dabc123")
+	})
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCheckRunNotFound = errors.New("check run not found")
+	ErrInvalidCheckRun  = errors.New("invalid check run")
+)
+
+// CreateCheckRunRequest starts a check run on a commit. A conclusion marks
+// the run completed right away.
+type CreateCheckRunRequest struct {
+	HeadSHA    string                    `json:"head_sha" binding:"required"`
+	Name       string                    `json:"name" binding:"required"`
+	Status     models.CheckRunStatus     `json:"status"`
+	Conclusion models.CheckRunConclusion `json:"conclusion"`
+	ExternalID string                    `json:"external_id"`
+	DetailsURL string                    `json:"details_url"`
+	Title      string                    `json:"title"`
+	Summary    string                    `json:"summary"`
+}
+
+// UpdateCheckRunRequest reports progress on a check run
+type UpdateCheckRunRequest struct {
+	Status     *models.CheckRunStatus     `json:"status"`
+	Conclusion *models.CheckRunConclusion `json:"conclusion"`
+	DetailsURL *string                    `json:"details_url"`
+	Title      *string                    `json:"title"`
+	Summary    *string                    `json:"summary"`
+}
+
+// CheckRunService stores check runs reported by external CI. The latest
+// run of each name counts towards the combined status of its commit.
+type CheckRunService interface {
+	Create(ctx context.Context, repoID uuid.UUID, creatorID *uuid.UUID, req CreateCheckRunRequest) (*models.CheckRun, error)
+	Update(ctx context.Context, repoID, checkRunID uuid.UUID, req UpdateCheckRunRequest) (*models.CheckRun, error)
+	Get(ctx context.Context, repoID, checkRunID uuid.UUID) (*models.CheckRun, error)
+	// List returns every check run of a commit, newest first
+	List(ctx context.Context, repoID uuid.UUID, sha string) ([]models.CheckRun, error)
+}
+
+type checkRunService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+// NewCheckRunService creates a new CheckRunService
+func NewCheckRunService(db *gorm.DB, logger *logrus.Logger) CheckRunService {
+	return &checkRunService{db: db, logger: logger}
+}
+
+func (s *checkRunService) Create(ctx context.Context, repoID uuid.UUID, creatorID *uuid.UUID, req CreateCheckRunRequest) (*models.CheckRun, error) {
+	if !commitSHAPattern.MatchString(req.HeadSHA) {
+		return nil, fmt.Errorf("%w: head_sha must be a full 40 character commit id", ErrInvalidCheckRun)
+	}
+	if req.Name == "" || len(req.Name) > 255 {
+		return nil, fmt.Errorf("%w: name must be between 1 and 255 characters", ErrInvalidCheckRun)
+	}
+
+	run := &models.CheckRun{
+		ID:           uuid.New(),
+		RepositoryID: repoID,
+		HeadSHA:      req.HeadSHA,
+		Name:         req.Name,
+		Status:       models.CheckRunQueued,
+		ExternalID:   req.ExternalID,
+		DetailsURL:   req.DetailsURL,
+		Title:        req.Title,
+		Summary:      req.Summary,
+		CreatorID:    creatorID,
+	}
+	if err := applyCheckRunProgress(run, req.Status, req.Conclusion, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(run).Error; err != nil {
+		return nil, fmt.Errorf("failed to create check run: %w", err)
+	}
+	return run, nil
+}
+
+func (s *checkRunService) Update(ctx context.Context, repoID, checkRunID uuid.UUID, req UpdateCheckRunRequest) (*models.CheckRun, error) {
+	run, err := s.Get(ctx, repoID, checkRunID)
+	if err != nil {
+		return nil, err
+	}
+
+	var status models.CheckRunStatus
+	var conclusion models.CheckRunConclusion
+	if req.Status != nil {
+		status = *req.Status
+	}
+	if req.Conclusion != nil {
+		conclusion = *req.Conclusion
+	}
+	if err := applyCheckRunProgress(run, status, conclusion, time.Now()); err != nil {
+		return nil, err
+	}
+	if req.DetailsURL != nil {
+		run.DetailsURL = *req.DetailsURL
+	}
+	if req.Title != nil {
+		run.Title = *req.Title
+	}
+	if req.Summary != nil {
+		run.Summary = *req.Summary
+	}
+
+	if err := s.db.WithContext(ctx).Save(run).Error; err != nil {
+		return nil, fmt.Errorf("failed to update check run: %w", err)
+	}
+	return run, nil
+}
+
+// applyCheckRunProgress moves run to status, or to completed when a
+// conclusion is given, stamping when it started and completed
+func applyCheckRunProgress(run *models.CheckRun, status models.CheckRunStatus, conclusion models.CheckRunConclusion, now time.Time) error {
+	switch conclusion {
+	case "":
+	case models.CheckRunSuccess, models.CheckRunFailure, models.CheckRunNeutral, models.CheckRunCancelled,
+		models.CheckRunSkipped, models.CheckRunTimedOut, models.CheckRunActionRequired:
+		if status == "" {
+			status = models.CheckRunCompleted
+		}
+	default:
+		return fmt.Errorf("%w: unknown conclusion %q", ErrInvalidCheckRun, conclusion)
+	}
+
+	switch status {
+	case "":
+		return nil
+	case models.CheckRunQueued, models.CheckRunInProgress:
+		if conclusion != "" {
+			return fmt.Errorf("%w: only completed check runs have a conclusion", ErrInvalidCheckRun)
+		}
+		run.Conclusion, run.CompletedAt = "", nil
+	case models.CheckRunCompleted:
+		if conclusion == "" {
+			return fmt.Errorf("%w: completed check runs need a conclusion", ErrInvalidCheckRun)
+		}
+		run.Conclusion, run.CompletedAt = conclusion, &now
+	default:
+		return fmt.Errorf("%w: status must be queued, in_progress or completed", ErrInvalidCheckRun)
+	}
+	if status != models.CheckRunQueued && run.StartedAt == nil {
+		run.StartedAt = &now
+	}
+	run.Status = status
+	return nil
+}
+
+func (s *checkRunService) Get(ctx context.Context, repoID, checkRunID uuid.UUID) (*models.CheckRun, error) {
+	var run models.CheckRun
+	err := s.db.WithContext(ctx).Preload("Creator").
+		Where("id = ? AND repository_id = ?", checkRunID, repoID).First(&run).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCheckRunNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get check run: %w", err)
+	}
+	return &run, nil
+}
+
+func (s *checkRunService) List(ctx context.Context, repoID uuid.UUID, sha string) ([]models.CheckRun, error) {
+	var runs []models.CheckRun
+	err := s.db.WithContext(ctx).Preload("Creator").
+		Where("repository_id = ? AND head_sha = ?", repoID, sha).
+		Order("created_at DESC").
+		Find(&runs).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list check runs: %w", err)
+	}
+	return runs, nil
+}
+
+// checkRunState is the commit status state a check run counts as
+func checkRunState(run *models.CheckRun) models.CommitStatusState {
+	if run.Status != models.CheckRunCompleted {
+		return models.CommitStatusPending
+	}
+	switch run.Conclusion {
+	case models.CheckRunSuccess, models.CheckRunNeutral, models.CheckRunSkipped:
+		return models.CommitStatusSuccess
+	}
+	return models.CommitStatusFailure
+}
+
+// checkRunAsStatus presents a check run as a status under its name so it
+// rolls up with commit statuses and satisfies required checks alike
+func checkRunAsStatus(run *models.CheckRun) models.CommitStatus {
+	return models.CommitStatus{
+		ID:           run.ID,
+		CreatedAt:    run.UpdatedAt,
+		UpdatedAt:    run.UpdatedAt,
+		RepositoryID: run.RepositoryID,
+		SHA:          run.HeadSHA,
+		State:        checkRunState(run),
+		Context:      run.Name,
+		Description:  run.Title,
+		TargetURL:    run.DetailsURL,
+		CreatorID:    run.CreatorID,
+		Creator:      run.Creator,
+	}
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRunService_GatesMerges(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.BranchProtectionRule{}, &models.RequiredStatusCheck{}, &models.CommitStatus{}, &models.CheckRun{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	svc := NewCheckRunService(db, logger)
+	statuses := NewCommitStatusService(db, logger)
+	required := NewRequiredStatusCheckService(db, logger)
+	repoID := uuid.New()
+	sha := strings.Repeat("c", 40)
+
+	_, err := svc.Create(ctx, repoID, nil, CreateCheckRunRequest{HeadSHA: "abc", Name: "build"})
+	assert.ErrorIs(t, err, ErrInvalidCheckRun)
+	_, err = svc.Create(ctx, repoID, nil, CreateCheckRunRequest{HeadSHA: sha, Name: "build", Status: models.CheckRunCompleted})
+	assert.ErrorIs(t, err, ErrInvalidCheckRun, "completed runs need a conclusion")
+	_, err = svc.Create(ctx, repoID, nil, CreateCheckRunRequest{HeadSHA: sha, Name: "build", Conclusion: "maybe"})
+	assert.ErrorIs(t, err, ErrInvalidCheckRun)
+
+	run, err := svc.Create(ctx, repoID, nil, CreateCheckRunRequest{HeadSHA: sha, Name: "ci/build"})
+	require.NoError(t, err)
+	assert.Equal(t, models.CheckRunQueued, run.Status)
+	assert.Nil(t, run.StartedAt)
+
+	pattern := "ci/*"
+	_, err = required.Create(ctx, repoID, RequiredStatusCheckRequest{Pattern: &pattern})
+	require.NoError(t, err)
+	patterns, err := required.Required(ctx, repoID, "main")
+	require.NoError(t, err)
+
+	eval, err := required.Evaluate(ctx, repoID, sha, patterns)
+	require.NoError(t, err)
+	assert.False(t, eval.Satisfied, "a queued check run is pending")
+	assert.Equal(t, string(models.CommitStatusPending), eval.Checks[0].State)
+
+	inProgress := models.CheckRunInProgress
+	run, err = svc.Update(ctx, repoID, run.ID, UpdateCheckRunRequest{Status: &inProgress})
+	require.NoError(t, err)
+	assert.NotNil(t, run.StartedAt)
+
+	failure := models.CheckRunFailure
+	run, err = svc.Update(ctx, repoID, run.ID, UpdateCheckRunRequest{Conclusion: &failure})
+	require.NoError(t, err)
+	assert.Equal(t, models.CheckRunCompleted, run.Status)
+	assert.NotNil(t, run.CompletedAt)
+
+	combined, err := statuses.Combined(ctx, repoID, sha)
+	require.NoError(t, err)
+	assert.Equal(t, models.CommitStatusFailure, combined.State)
+	require.Len(t, combined.Statuses, 1)
+	assert.Equal(t, "ci/build", combined.Statuses[0].Context)
+
+	// A rerun under the same name replaces the failed one
+	_, err = svc.Create(ctx, repoID, nil, CreateCheckRunRequest{HeadSHA: sha, Name: "ci/build", Conclusion: models.CheckRunSuccess})
+	require.NoError(t, err)
+	_, err = statuses.Create(ctx, repoID, sha, nil, CreateCommitStatusRequest{State: models.CommitStatusSuccess, Context: "ci/lint"})
+	require.NoError(t, err)
+
+	eval, err = required.Evaluate(ctx, repoID, sha, patterns)
+	require.NoError(t, err)
+	assert.True(t, eval.Satisfied)
+	assert.Equal(t, []string{"ci/build", "ci/lint"}, eval.Checks[0].Contexts)
+
+	runs, err := svc.List(ctx, repoID, sha)
+	require.NoError(t, err)
+	assert.Len(t, runs, 2)
+	_, err = svc.Get(ctx, uuid.New(), run.ID)
+	assert.ErrorIs(t, err, ErrCheckRunNotFound)
+}
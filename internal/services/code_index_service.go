@@ -0,0 +1,364 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCodeSearchSettings = errors.New("invalid code search settings")
+	ErrCodeSearchForbidden       = errors.New("only organization owners and admins can manage code search")
+)
+
+// RepositoryCodeIndex summarizes the code search index of a repository.
+// Stale is set when an indexed ref has moved on since it was indexed or the
+// default branch has not been indexed, so results may be out of date.
+type RepositoryCodeIndex struct {
+	Enabled       bool                  `json:"enabled"`
+	Excluded      bool                  `json:"excluded"`
+	Stale         bool                  `json:"stale"`
+	Symbols       int                   `json:"symbols"`
+	SizeBytes     int64                 `json:"size_bytes"`
+	LastIndexedAt *time.Time            `json:"last_indexed_at,omitempty"`
+	Refs          []*CodeIndexRefStatus `json:"refs"`
+}
+
+// CodeIndexRefStatus is the index status of one ref along with the commit
+// the ref points at now
+type CodeIndexRefStatus struct {
+	*models.CodeIndexStatus
+	HeadSHA string `json:"head_sha,omitempty"`
+	Stale   bool   `json:"stale"`
+}
+
+// CodeSearchSettings are an organization's code search indexing controls
+type CodeSearchSettings struct {
+	ExcludedRepositories []string `json:"excluded_repositories"`
+	ExcludedPaths        []string `json:"excluded_paths"`
+}
+
+// UpdateCodeSearchSettingsRequest changes the fields that are set
+type UpdateCodeSearchSettingsRequest struct {
+	ExcludedRepositories *[]string `json:"excluded_repositories,omitempty"`
+	ExcludedPaths        *[]string `json:"excluded_paths,omitempty"`
+}
+
+// OrganizationCodeIndex reports the code search index of every repository
+// of an organization
+type OrganizationCodeIndex struct {
+	Organization      string                         `json:"organization"`
+	Settings          CodeSearchSettings             `json:"settings"`
+	Symbols           int                            `json:"symbols"`
+	SizeBytes         int64                          `json:"size_bytes"`
+	StaleRepositories int                            `json:"stale_repositories"`
+	Repositories      []*OrganizationRepositoryIndex `json:"repositories"`
+}
+
+// OrganizationRepositoryIndex is one repository's entry in OrganizationCodeIndex
+type OrganizationRepositoryIndex struct {
+	Repository string `json:"repository"`
+	*RepositoryCodeIndex
+}
+
+// CodeIndexService gives organization admins control over which of their
+// repositories and paths are indexed for code search, and lets them watch
+// and refresh the index
+type CodeIndexService interface {
+	GetSettings(ctx context.Context, orgName string, actorID uuid.UUID) (*CodeSearchSettings, error)
+	UpdateSettings(ctx context.Context, orgName string, actorID uuid.UUID, req UpdateCodeSearchSettingsRequest) (*CodeSearchSettings, error)
+	Status(ctx context.Context, orgName string, actorID uuid.UUID) (*OrganizationCodeIndex, error)
+	// Reindex rebuilds the default branch index of the named repositories,
+	// or of every repository of the organization, in the background. It
+	// returns the repositories that were queued.
+	Reindex(ctx context.Context, orgName string, actorID uuid.UUID, repositories []string) ([]string, error)
+}
+
+type codeIndexService struct {
+	db            *gorm.DB
+	symbolService SymbolService
+	logger        *logrus.Logger
+}
+
+// NewCodeIndexService creates a new CodeIndexService
+func NewCodeIndexService(db *gorm.DB, symbolService SymbolService, logger *logrus.Logger) CodeIndexService {
+	return &codeIndexService{db: db, symbolService: symbolService, logger: logger}
+}
+
+// codeSearchExclusions are the exclusions that apply to one repository
+type codeSearchExclusions struct {
+	Repository bool
+	Paths      []string
+}
+
+func (e codeSearchExclusions) excludes(file string) bool {
+	for _, pattern := range e.Paths {
+		if MatchPathPattern(pattern, file) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadCodeSearchExclusions reads the exclusions the organization owning repo
+// has configured. Repositories owned by users have none.
+func loadCodeSearchExclusions(ctx context.Context, db *gorm.DB, repo *models.Repository) (codeSearchExclusions, error) {
+	if repo.OwnerType != models.OwnerTypeOrganization {
+		return codeSearchExclusions{}, nil
+	}
+	var settings models.OrganizationSettings
+	err := db.WithContext(ctx).Where("organization_id = ?", repo.OwnerID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return codeSearchExclusions{}, nil
+	}
+	if err != nil {
+		return codeSearchExclusions{}, fmt.Errorf("failed to get organization settings: %w", err)
+	}
+
+	exclusions := codeSearchExclusions{Paths: settings.CodeSearchExcludedPaths}
+	for _, name := range settings.CodeSearchExcludedRepositories {
+		if name == repo.Name {
+			exclusions.Repository = true
+		}
+	}
+	return exclusions, nil
+}
+
+// authorize resolves the organization and requires actorID to be one of
+// its owners or admins
+func (s *codeIndexService) authorize(ctx context.Context, orgName string, actorID uuid.UUID) (*models.Organization, error) {
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("organization not found: %w", err)
+	}
+	var member models.OrganizationMember
+	err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", org.ID, actorID).First(&member).Error
+	if err != nil || (member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin) {
+		return nil, ErrCodeSearchForbidden
+	}
+	return &org, nil
+}
+
+func (s *codeIndexService) loadSettings(ctx context.Context, orgID uuid.UUID) (*CodeSearchSettings, error) {
+	settings := &CodeSearchSettings{ExcludedRepositories: []string{}, ExcludedPaths: []string{}}
+	var row models.OrganizationSettings
+	err := s.db.WithContext(ctx).Where("organization_id = ?", orgID).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization settings: %w", err)
+	}
+	if row.CodeSearchExcludedRepositories != nil {
+		settings.ExcludedRepositories = row.CodeSearchExcludedRepositories
+	}
+	if row.CodeSearchExcludedPaths != nil {
+		settings.ExcludedPaths = row.CodeSearchExcludedPaths
+	}
+	return settings, nil
+}
+
+func (s *codeIndexService) GetSettings(ctx context.Context, orgName string, actorID uuid.UUID) (*CodeSearchSettings, error) {
+	org, err := s.authorize(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadSettings(ctx, org.ID)
+}
+
+// UpdateSettings stores new exclusions. Newly excluded repositories are
+// dropped from the index immediately; path exclusions take effect the next
+// time a repository is indexed.
+func (s *codeIndexService) UpdateSettings(ctx context.Context, orgName string, actorID uuid.UUID, req UpdateCodeSearchSettingsRequest) (*CodeSearchSettings, error) {
+	org, err := s.authorize(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings models.OrganizationSettings
+	columns := []string{}
+	var excludedIDs []uuid.UUID
+	if req.ExcludedRepositories != nil {
+		names := *req.ExcludedRepositories
+		if len(names) > 0 {
+			repos, err := s.repositories(ctx, org.ID, names)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkRepositoryNames(repos, names); err != nil {
+				return nil, err
+			}
+			for _, repo := range repos {
+				excludedIDs = append(excludedIDs, repo.ID)
+			}
+		}
+		settings.CodeSearchExcludedRepositories = names
+		columns = append(columns, "code_search_excluded_repositories")
+	}
+	if req.ExcludedPaths != nil {
+		patterns := make([]string, 0, len(*req.ExcludedPaths))
+		for _, pattern := range *req.ExcludedPaths {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "" || pattern == "/" {
+				return nil, fmt.Errorf("%w: empty path pattern", ErrInvalidCodeSearchSettings)
+			}
+			if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+				return nil, fmt.Errorf("%w: malformed path pattern %q", ErrInvalidCodeSearchSettings, pattern)
+			}
+			patterns = append(patterns, pattern)
+		}
+		settings.CodeSearchExcludedPaths = patterns
+		columns = append(columns, "code_search_excluded_paths")
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.OrganizationSettings
+		err := tx.Where(models.OrganizationSettings{OrganizationID: org.ID}).
+			Attrs(models.OrganizationSettings{ID: uuid.New()}).FirstOrCreate(&existing).Error
+		if err != nil {
+			return err
+		}
+		if len(columns) == 0 {
+			return nil
+		}
+		if err := tx.Model(&existing).Select(columns).Updates(&settings).Error; err != nil {
+			return err
+		}
+
+		if len(excludedIDs) == 0 {
+			return nil
+		}
+		if err := tx.Where("repository_id IN ?", excludedIDs).Delete(&models.CodeSymbol{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.CodeIndexStatus{}).Where("repository_id IN ?", excludedIDs).
+			Updates(map[string]interface{}{"state": models.CodeIndexStateExcluded, "symbols": 0, "files": 0, "size_bytes": 0}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("repository_id IN ?", excludedIDs).Delete(&models.CodeSearchDocument{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.CodeSearchIndexStatus{}).Where("repository_id IN ?", excludedIDs).
+			Updates(map[string]interface{}{"state": models.CodeIndexStateExcluded, "commit_sha": "", "files": 0, "size_bytes": 0}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update code search settings: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"organization": orgName,
+		"actor_id":     actorID,
+		"changes":      columns,
+	}).Info("Updated organization code search settings")
+
+	return s.loadSettings(ctx, org.ID)
+}
+
+func (s *codeIndexService) Status(ctx context.Context, orgName string, actorID uuid.UUID) (*OrganizationCodeIndex, error) {
+	org, err := s.authorize(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.loadSettings(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	repos, err := s.repositories(ctx, org.ID, nil)
+	if err != nil {
+		return nil, err
+	}
+	status := &OrganizationCodeIndex{
+		Organization: org.Name,
+		Settings:     *settings,
+		Repositories: make([]*OrganizationRepositoryIndex, 0, len(repos)),
+	}
+	for _, repo := range repos {
+		index, err := s.symbolService.IndexStatus(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		status.Symbols += index.Symbols
+		status.SizeBytes += index.SizeBytes
+		if index.Stale {
+			status.StaleRepositories++
+		}
+		status.Repositories = append(status.Repositories, &OrganizationRepositoryIndex{Repository: repo.Name, RepositoryCodeIndex: index})
+	}
+	return status, nil
+}
+
+func (s *codeIndexService) Reindex(ctx context.Context, orgName string, actorID uuid.UUID, repositories []string) ([]string, error) {
+	org, err := s.authorize(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+	repos, err := s.repositories(ctx, org.ID, repositories)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRepositoryNames(repos, repositories); err != nil {
+		return nil, err
+	}
+
+	queued := make([]string, 0, len(repos))
+	for _, repo := range repos {
+		queued = append(queued, repo.Name)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"organization": orgName,
+		"actor_id":     actorID,
+		"repositories": queued,
+	}).Info("Reindexing organization code search")
+
+	go func() {
+		defer errorreporting.Default().Recover("code_search_reindex", map[string]string{"organization": orgName})
+		job := jobs.Job{Name: "code_search_reindex:" + orgName, Class: jobs.ClassReindex}
+		if jobs.DefaultGate().Wait(context.Background(), job) != nil {
+			return
+		}
+		for _, repo := range repos {
+			if _, err := s.symbolService.IndexRef(context.Background(), repo.ID, repo.DefaultBranch); err != nil {
+				s.logger.WithError(err).WithField("repository_id", repo.ID).Warn("Failed to reindex repository")
+			}
+		}
+	}()
+	return queued, nil
+}
+
+// repositories lists the organization's repositories, limited to names when given
+func (s *codeIndexService) repositories(ctx context.Context, orgID uuid.UUID, names []string) ([]*models.Repository, error) {
+	query := s.db.WithContext(ctx).Where("owner_id = ? AND owner_type = ?", orgID, models.OwnerTypeOrganization)
+	if len(names) > 0 {
+		query = query.Where("name IN ?", names)
+	}
+	var repos []*models.Repository
+	if err := query.Order("name ASC").Find(&repos).Error; err != nil {
+		return nil, fmt.Errorf("failed to list repositories: %w", err)
+	}
+	return repos, nil
+}
+
+// checkRepositoryNames reports the first of names missing from repos
+func checkRepositoryNames(repos []*models.Repository, names []string) error {
+	known := make(map[string]bool, len(repos))
+	for _, repo := range repos {
+		known[repo.Name] = true
+	}
+	for _, name := range names {
+		if !known[name] {
+			return fmt.Errorf("%w: repository %q not found", ErrInvalidCodeSearchSettings, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeIndexService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{},
+		&models.OrganizationSettings{}, &models.Repository{}, &models.CodeSymbol{}, &models.CodeIndexStatus{},
+		&models.CodeSearchDocument{}, &models.CodeSearchIndexStatus{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	base := t.TempDir()
+	gitService := git.NewGitService(logger)
+	repoService := NewRepositoryService(db, gitService, logger, base)
+	symbols := NewSymbolService(db, gitService, repoService, config.Symbols{Enabled: true, MaxFileSizeKB: 512}, logger)
+	svc := NewCodeIndexService(db, symbols, logger)
+
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	admin, member := uuid.New(), uuid.New()
+	require.NoError(t, db.Create([]*models.OrganizationMember{
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: admin, Role: models.OrgRoleAdmin},
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: member, Role: models.OrgRoleMember},
+	}).Error)
+
+	newRepo := func(name string) (*models.Repository, *testutil.GitRepo) {
+		repo := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: name, DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+		require.NoError(t, db.Create(repo).Error)
+		fixture := testutil.NewGitRepo(t, testutil.RepositoryPath(base, repo))
+		fixture.Commit("main", "initial", map[string]string{
+			"main.go":               "package main\n\nfunc Hello() {}\n",
+			"vendor/lib/lib.go":     "package lib\n\nfunc Vendored() {}\n",
+			"docs/notes/readme.txt": "notes\n",
+		})
+		return repo, fixture
+	}
+	app, appFixture := newRepo("app")
+	legacy, _ := newRepo("legacy")
+
+	_, err := symbols.IndexRef(ctx, legacy.ID, "main")
+	require.NoError(t, err)
+
+	t.Run("settings require an org admin and valid values", func(t *testing.T) {
+		_, err := svc.GetSettings(ctx, "acme", member)
+		assert.ErrorIs(t, err, ErrCodeSearchForbidden)
+		_, err = svc.UpdateSettings(ctx, "acme", admin, UpdateCodeSearchSettingsRequest{ExcludedRepositories: &[]string{"missing"}})
+		assert.ErrorIs(t, err, ErrInvalidCodeSearchSettings)
+		_, err = svc.UpdateSettings(ctx, "acme", admin, UpdateCodeSearchSettingsRequest{ExcludedPaths: &[]string{"vendor/["}})
+		assert.ErrorIs(t, err, ErrInvalidCodeSearchSettings)
+	})
+
+	settings, err := svc.UpdateSettings(ctx, "acme", admin, UpdateCodeSearchSettingsRequest{
+		ExcludedRepositories: &[]string{"legacy"},
+		ExcludedPaths:        &[]string{"vendor/**"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"legacy"}, settings.ExcludedRepositories)
+	assert.Equal(t, []string{"vendor/**"}, settings.ExcludedPaths)
+
+	var legacySymbols int64
+	require.NoError(t, db.Model(&models.CodeSymbol{}).Where("repository_id = ?", legacy.ID).Count(&legacySymbols).Error)
+	assert.Zero(t, legacySymbols, "excluding a repository drops its index")
+
+	count, err := symbols.IndexRef(ctx, app.ID, "main")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count, "vendored definitions are not indexed")
+
+	index, err := symbols.IndexStatus(ctx, app)
+	require.NoError(t, err)
+	require.Len(t, index.Refs, 1)
+	ref := index.Refs[0]
+	assert.Equal(t, models.CodeIndexStateIndexed, ref.State)
+	assert.Equal(t, 1, ref.Files)
+	assert.Equal(t, 1, ref.ExcludedFiles)
+	assert.Positive(t, ref.SizeBytes)
+	assert.False(t, index.Stale)
+
+	appFixture.Commit("main", "more", map[string]string{"other.go": "package main\n\nfunc Other() {}\n"})
+	index, err = symbols.IndexStatus(ctx, app)
+	require.NoError(t, err)
+	assert.True(t, index.Stale)
+	assert.NotEqual(t, index.Refs[0].CommitSHA, index.Refs[0].HeadSHA)
+
+	status, err := svc.Status(ctx, "acme", admin)
+	require.NoError(t, err)
+	require.Len(t, status.Repositories, 2)
+	assert.Equal(t, 1, status.StaleRepositories)
+	assert.Equal(t, "legacy", status.Repositories[1].Repository)
+	assert.True(t, status.Repositories[1].Excluded)
+	assert.Equal(t, models.CodeIndexStateExcluded, status.Repositories[1].Refs[0].State)
+
+	queued, err := svc.Reindex(ctx, "acme", admin, []string{"app"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"app"}, queued)
+	require.Eventually(t, func() bool {
+		index, err := symbols.IndexStatus(ctx, app)
+		return err == nil && !index.Stale
+	}, 5*time.Second, 20*time.Millisecond)
+
+	_, err = svc.Reindex(ctx, "acme", member, nil)
+	assert.ErrorIs(t, err, ErrCodeSearchForbidden)
+}
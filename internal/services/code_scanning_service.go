@@ -0,0 +1,624 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxSARIFSize bounds a decoded SARIF upload
+	maxSARIFSize = 20 << 20
+	// maxSARIFResults bounds the results of a single run
+	maxSARIFResults = 5000
+	// CodeScanningStatusContextPrefix prefixes the commit status each
+	// analysis reports, followed by the tool name
+	CodeScanningStatusContextPrefix = "code-scanning/"
+)
+
+var (
+	ErrCodeScanningAlertNotFound = errors.New("code scanning alert not found")
+	ErrInvalidSARIF              = errors.New("invalid SARIF upload")
+	ErrInvalidCodeScanningAlert  = errors.New("invalid code scanning alert update")
+)
+
+// CodeScanningDismissReasons are the reasons an alert may be dismissed with
+var CodeScanningDismissReasons = []string{"false positive", "won't fix", "used in tests"}
+
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// UploadSARIFRequest is a SARIF upload for a commit on a ref. SARIF is the
+// base64 encoding of the SARIF JSON document, optionally gzip compressed.
+type UploadSARIFRequest struct {
+	CommitSHA string `json:"commit_sha" binding:"required"`
+	Ref       string `json:"ref" binding:"required"`
+	SARIF     string `json:"sarif" binding:"required"`
+}
+
+type CodeScanningAlertFilter struct {
+	State    *models.CodeScanningAlertState
+	Severity *models.CodeScanningSeverity
+	Ref      string
+	Tool     string
+	Page     int
+	PageSize int
+}
+
+type UpdateCodeScanningAlertRequest struct {
+	State           models.CodeScanningAlertState `json:"state" binding:"required"`
+	DismissedReason string                        `json:"dismissed_reason,omitempty"`
+}
+
+// CodeScanningAnnotation is an open alert on a line a pull request changes
+type CodeScanningAnnotation struct {
+	AlertNumber int                         `json:"alert_number"`
+	Tool        string                      `json:"tool"`
+	RuleID      string                      `json:"rule_id"`
+	Severity    models.CodeScanningSeverity `json:"severity"`
+	Message     string                      `json:"message"`
+	Path        string                      `json:"path"`
+	StartLine   int                         `json:"start_line"`
+	EndLine     int                         `json:"end_line"`
+}
+
+// CodeScanningService ingests SARIF results from external scanners and
+// manages the resulting alerts. Each analysis is also reported as a commit
+// status, which is how it shows up on pull requests.
+type CodeScanningService interface {
+	// UploadSARIF records one analysis per SARIF run, opening new alerts and
+	// marking alerts the tool no longer reports on the ref as fixed
+	UploadSARIF(ctx context.Context, repo *models.Repository, uploaderID *uuid.UUID, req UploadSARIFRequest) ([]*models.CodeScanningAnalysis, error)
+	ListAlerts(ctx context.Context, repoID uuid.UUID, filter CodeScanningAlertFilter) ([]*models.CodeScanningAlert, error)
+	GetAlert(ctx context.Context, repoID uuid.UUID, number int) (*models.CodeScanningAlert, error)
+	// UpdateAlert dismisses an open alert or reopens a dismissed one
+	UpdateAlert(ctx context.Context, alert *models.CodeScanningAlert, userID uuid.UUID, req UpdateCodeScanningAlertRequest) (*models.CodeScanningAlert, error)
+	// PullRequestAnnotations returns the open alerts of the pull request's
+	// head on lines the pull request adds or changes
+	PullRequestAnnotations(ctx context.Context, pr *models.PullRequest) ([]CodeScanningAnnotation, error)
+}
+
+type codeScanningService struct {
+	db            *gorm.DB
+	gitService    git.GitService
+	repoService   RepositoryService
+	statusService CommitStatusService
+	logger        *logrus.Logger
+}
+
+// NewCodeScanningService creates a new CodeScanningService
+func NewCodeScanningService(db *gorm.DB, gitService git.GitService, repoService RepositoryService, statusService CommitStatusService, logger *logrus.Logger) CodeScanningService {
+	return &codeScanningService{db: db, gitService: gitService, repoService: repoService, statusService: statusService, logger: logger}
+}
+
+// sarifLog is the subset of SARIF 2.1.0 read on upload
+type sarifLog struct {
+	Version string `json:"version"`
+	Runs    []struct {
+		Tool struct {
+			Driver struct {
+				Name  string      `json:"name"`
+				Rules []sarifRule `json:"rules"`
+			} `json:"driver"`
+		} `json:"tool"`
+		Results []sarifResult `json:"results"`
+	} `json:"runs"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+	FullDescription  sarifMessage `json:"fullDescription"`
+	Configuration    struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string       `json:"ruleId"`
+	RuleIndex *int         `json:"ruleIndex"`
+	Level     string       `json:"level"`
+	Message   sarifMessage `json:"message"`
+	Locations []struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region struct {
+				StartLine int `json:"startLine"`
+				EndLine   int `json:"endLine"`
+			} `json:"region"`
+		} `json:"physicalLocation"`
+	} `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+// DecodeSARIF decodes a base64 SARIF upload, decompressing it if gzipped
+func DecodeSARIF(encoded string) ([]byte, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("%w: sarif is not base64 encoded", ErrInvalidSARIF)
+	}
+	if len(raw) > 2 && raw[0] == 0x1f && raw[1] == 0x8b {
+		reader, err := gzip.NewReader(bytes.NewReader(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSARIF, err)
+		}
+		defer reader.Close()
+		raw, err = io.ReadAll(io.LimitReader(reader, maxSARIFSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidSARIF, err)
+		}
+	}
+	if len(raw) > maxSARIFSize {
+		return nil, fmt.Errorf("%w: sarif exceeds %d bytes", ErrInvalidSARIF, maxSARIFSize)
+	}
+	return raw, nil
+}
+
+// codeScanningSeverity maps a SARIF result onto a severity. The rule's
+// security-severity score wins when present, as scanners use level for
+// how sure they are rather than how bad the finding is.
+func codeScanningSeverity(level string, rule *sarifRule) models.CodeScanningSeverity {
+	if rule != nil {
+		if raw, ok := rule.Properties["security-severity"]; ok {
+			var score float64
+			var err error
+			switch v := raw.(type) {
+			case string:
+				score, err = strconv.ParseFloat(v, 64)
+			case float64:
+				score = v
+			}
+			if err == nil && score > 0 {
+				switch {
+				case score >= 9:
+					return models.CodeScanningSeverityCritical
+				case score >= 7:
+					return models.CodeScanningSeverityHigh
+				case score >= 4:
+					return models.CodeScanningSeverityMedium
+				}
+				return models.CodeScanningSeverityLow
+			}
+		}
+		if level == "" {
+			level = rule.Configuration.Level
+		}
+	}
+	switch level {
+	case "error":
+		return models.CodeScanningSeverityHigh
+	case "warning", "":
+		return models.CodeScanningSeverityMedium
+	}
+	return models.CodeScanningSeverityLow
+}
+
+func (s *codeScanningService) UploadSARIF(ctx context.Context, repo *models.Repository, uploaderID *uuid.UUID, req UploadSARIFRequest) ([]*models.CodeScanningAnalysis, error) {
+	if !commitSHAPattern.MatchString(req.CommitSHA) {
+		return nil, fmt.Errorf("%w: commit_sha must be a full 40 character SHA", ErrInvalidSARIF)
+	}
+	if !strings.HasPrefix(req.Ref, "refs/") {
+		return nil, fmt.Errorf("%w: ref must be a full reference such as refs/heads/main", ErrInvalidSARIF)
+	}
+	raw, err := DecodeSARIF(req.SARIF)
+	if err != nil {
+		return nil, err
+	}
+	var log sarifLog
+	if err := json.Unmarshal(raw, &log); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSARIF, err)
+	}
+	if len(log.Runs) == 0 {
+		return nil, fmt.Errorf("%w: no runs", ErrInvalidSARIF)
+	}
+
+	var analyses []*models.CodeScanningAnalysis
+	for _, run := range log.Runs {
+		tool := strings.TrimSpace(run.Tool.Driver.Name)
+		if tool == "" {
+			return nil, fmt.Errorf("%w: run has no tool name", ErrInvalidSARIF)
+		}
+		if len(run.Results) > maxSARIFResults {
+			return nil, fmt.Errorf("%w: run has more than %d results", ErrInvalidSARIF, maxSARIFResults)
+		}
+		rules := make(map[string]*sarifRule, len(run.Tool.Driver.Rules))
+		for i := range run.Tool.Driver.Rules {
+			rules[run.Tool.Driver.Rules[i].ID] = &run.Tool.Driver.Rules[i]
+		}
+
+		alerts := make([]*models.CodeScanningAlert, 0, len(run.Results))
+		seen := map[string]bool{}
+		occurrences := map[string]int{}
+		for _, result := range run.Results {
+			rule := rules[result.RuleID]
+			if rule == nil && result.RuleIndex != nil && *result.RuleIndex >= 0 && *result.RuleIndex < len(run.Tool.Driver.Rules) {
+				rule = &run.Tool.Driver.Rules[*result.RuleIndex]
+			}
+			alert := sarifAlert(result, rule)
+			alert.Fingerprint = sarifFingerprint(alert, result.PartialFingerprints, occurrences)
+			if seen[alert.Fingerprint] {
+				continue
+			}
+			seen[alert.Fingerprint] = true
+			alerts = append(alerts, alert)
+		}
+
+		analysis, err := s.recordAnalysis(ctx, repo.ID, uploaderID, req, tool, alerts)
+		if err != nil {
+			return nil, err
+		}
+		analyses = append(analyses, analysis)
+		s.reportStatus(ctx, repo.ID, uploaderID, req, tool)
+	}
+	return analyses, nil
+}
+
+func sarifAlert(result sarifResult, rule *sarifRule) *models.CodeScanningAlert {
+	alert := &models.CodeScanningAlert{
+		RuleID:   result.RuleID,
+		Severity: codeScanningSeverity(result.Level, rule),
+		Message:  result.Message.Text,
+	}
+	if rule != nil {
+		if alert.RuleID == "" {
+			alert.RuleID = rule.ID
+		}
+		alert.RuleDescription = rule.ShortDescription.Text
+		if alert.RuleDescription == "" {
+			alert.RuleDescription = rule.FullDescription.Text
+		}
+	}
+	if len(result.Locations) > 0 {
+		location := result.Locations[0].PhysicalLocation
+		uri := strings.TrimPrefix(location.ArtifactLocation.URI, "file://")
+		alert.Path = strings.TrimPrefix(uri, "./")
+		alert.StartLine = location.Region.StartLine
+		alert.EndLine = location.Region.EndLine
+		if alert.EndLine < alert.StartLine {
+			alert.EndLine = alert.StartLine
+		}
+	}
+	return alert
+}
+
+// sarifFingerprint identifies a finding across analyses. Scanner supplied
+// partial fingerprints are used when present; otherwise the rule, path and
+// message identify it, numbered by occurrence so identical findings in one
+// file stay distinct without depending on line numbers.
+func sarifFingerprint(alert *models.CodeScanningAlert, partial map[string]string, occurrences map[string]int) string {
+	var key string
+	if len(partial) > 0 {
+		keys := make([]string, 0, len(partial))
+		for k := range partial {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var b strings.Builder
+		b.WriteString(alert.RuleID)
+		for _, k := range keys {
+			b.WriteString("\x00" + k + "=" + partial[k])
+		}
+		key = b.String()
+	} else {
+		base := alert.RuleID + "\x00" + alert.Path + "\x00" + alert.Message
+		key = base + "\x00" + strconv.Itoa(occurrences[base])
+		occurrences[base]++
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *codeScanningService) recordAnalysis(ctx context.Context, repoID uuid.UUID, uploaderID *uuid.UUID, req UploadSARIFRequest, tool string, alerts []*models.CodeScanningAlert) (*models.CodeScanningAnalysis, error) {
+	analysis := &models.CodeScanningAnalysis{
+		ID:           uuid.New(),
+		RepositoryID: repoID,
+		Ref:          req.Ref,
+		CommitSHA:    req.CommitSHA,
+		Tool:         tool,
+		ResultsCount: len(alerts),
+		UploaderID:   uploaderID,
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing []*models.CodeScanningAlert
+		if err := tx.Where("repository_id = ? AND ref = ? AND tool = ?", repoID, req.Ref, tool).Find(&existing).Error; err != nil {
+			return err
+		}
+		byFingerprint := make(map[string]*models.CodeScanningAlert, len(existing))
+		for _, alert := range existing {
+			byFingerprint[alert.Fingerprint] = alert
+		}
+
+		var last int
+		if err := tx.Model(&models.CodeScanningAlert{}).Where("repository_id = ?", repoID).
+			Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		reported := map[string]bool{}
+		for _, alert := range alerts {
+			reported[alert.Fingerprint] = true
+			if current, ok := byFingerprint[alert.Fingerprint]; ok {
+				updates := map[string]interface{}{
+					"commit_sha":       req.CommitSHA,
+					"severity":         alert.Severity,
+					"message":          alert.Message,
+					"rule_description": alert.RuleDescription,
+					"start_line":       alert.StartLine,
+					"end_line":         alert.EndLine,
+				}
+				if current.State == models.CodeScanningAlertFixed {
+					updates["state"] = models.CodeScanningAlertOpen
+					updates["fixed_at"] = nil
+				}
+				if err := tx.Model(current).Updates(updates).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			last++
+			alert.ID = uuid.New()
+			alert.RepositoryID = repoID
+			alert.Number = last
+			alert.Ref = req.Ref
+			alert.Tool = tool
+			alert.CommitSHA = req.CommitSHA
+			alert.State = models.CodeScanningAlertOpen
+			if err := tx.Create(alert).Error; err != nil {
+				return err
+			}
+			analysis.NewAlerts++
+		}
+
+		for _, alert := range existing {
+			if reported[alert.Fingerprint] || alert.State != models.CodeScanningAlertOpen {
+				continue
+			}
+			if err := tx.Model(alert).Updates(map[string]interface{}{
+				"state":      models.CodeScanningAlertFixed,
+				"fixed_at":   now,
+				"commit_sha": req.CommitSHA,
+			}).Error; err != nil {
+				return err
+			}
+			analysis.FixedAlerts++
+		}
+		return tx.Create(analysis).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record code scanning analysis: %w", err)
+	}
+	return analysis, nil
+}
+
+// reportStatus sets the tool's commit status: failing while the ref has
+// open critical or high alerts from the tool
+func (s *codeScanningService) reportStatus(ctx context.Context, repoID uuid.UUID, uploaderID *uuid.UUID, req UploadSARIFRequest, tool string) {
+	if s.statusService == nil {
+		return
+	}
+	var counts []struct {
+		Severity models.CodeScanningSeverity
+		Count    int64
+	}
+	if err := s.db.WithContext(ctx).Model(&models.CodeScanningAlert{}).Select("severity, COUNT(*) AS count").
+		Where("repository_id = ? AND ref = ? AND tool = ? AND state = ?", repoID, req.Ref, tool, models.CodeScanningAlertOpen).
+		Group("severity").Scan(&counts).Error; err != nil {
+		s.logger.WithError(err).Warn("Failed to count code scanning alerts")
+		return
+	}
+
+	var open, blocking int64
+	for _, c := range counts {
+		open += c.Count
+		if c.Severity == models.CodeScanningSeverityCritical || c.Severity == models.CodeScanningSeverityHigh {
+			blocking += c.Count
+		}
+	}
+	state := models.CommitStatusSuccess
+	description := "No new or open alerts"
+	if open > 0 {
+		description = fmt.Sprintf("%d open alerts", open)
+	}
+	if blocking > 0 {
+		state = models.CommitStatusFailure
+		description = fmt.Sprintf("%d open alerts, %d critical or high", open, blocking)
+	}
+
+	_, err := s.statusService.Create(ctx, repoID, req.CommitSHA, uploaderID, CreateCommitStatusRequest{
+		State:       state,
+		Context:     CodeScanningStatusContextPrefix + tool,
+		Description: description,
+	})
+	if err != nil {
+		s.logger.WithError(err).WithField("repository_id", repoID).Warn("Failed to report code scanning status")
+	}
+}
+
+func (s *codeScanningService) ListAlerts(ctx context.Context, repoID uuid.UUID, filter CodeScanningAlertFilter) ([]*models.CodeScanningAlert, error) {
+	query := s.db.WithContext(ctx).Where("repository_id = ?", repoID)
+	if filter.State != nil {
+		query = query.Where("state = ?", *filter.State)
+	}
+	if filter.Severity != nil {
+		query = query.Where("severity = ?", *filter.Severity)
+	}
+	if filter.Ref != "" {
+		query = query.Where("ref = ?", filter.Ref)
+	}
+	if filter.Tool != "" {
+		query = query.Where("tool = ?", filter.Tool)
+	}
+
+	pageSize := 30
+	if filter.PageSize > 0 {
+		pageSize = filter.PageSize
+	}
+	offset := 0
+	if filter.Page > 1 {
+		offset = (filter.Page - 1) * pageSize
+	}
+
+	var alerts []*models.CodeScanningAlert
+	if err := query.Order("number DESC").Limit(pageSize).Offset(offset).Find(&alerts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list code scanning alerts: %w", err)
+	}
+	return alerts, nil
+}
+
+func (s *codeScanningService) GetAlert(ctx context.Context, repoID uuid.UUID, number int) (*models.CodeScanningAlert, error) {
+	var alert models.CodeScanningAlert
+	err := s.db.WithContext(ctx).Where("repository_id = ? AND number = ?", repoID, number).First(&alert).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCodeScanningAlertNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get code scanning alert: %w", err)
+	}
+	return &alert, nil
+}
+
+func (s *codeScanningService) UpdateAlert(ctx context.Context, alert *models.CodeScanningAlert, userID uuid.UUID, req UpdateCodeScanningAlertRequest) (*models.CodeScanningAlert, error) {
+	if alert.State == models.CodeScanningAlertFixed {
+		return nil, fmt.Errorf("%w: fixed alerts cannot be changed", ErrInvalidCodeScanningAlert)
+	}
+
+	var updates map[string]interface{}
+	switch req.State {
+	case models.CodeScanningAlertDismissed:
+		valid := false
+		for _, reason := range CodeScanningDismissReasons {
+			valid = valid || req.DismissedReason == reason
+		}
+		if !valid {
+			return nil, fmt.Errorf("%w: dismissed_reason must be one of %s", ErrInvalidCodeScanningAlert, strings.Join(CodeScanningDismissReasons, ", "))
+		}
+		updates = map[string]interface{}{
+			"state":            models.CodeScanningAlertDismissed,
+			"dismissed_reason": req.DismissedReason,
+			"dismissed_by_id":  userID,
+			"dismissed_at":     time.Now(),
+		}
+	case models.CodeScanningAlertOpen:
+		updates = map[string]interface{}{
+			"state":            models.CodeScanningAlertOpen,
+			"dismissed_reason": "",
+			"dismissed_by_id":  nil,
+			"dismissed_at":     nil,
+		}
+	default:
+		return nil, fmt.Errorf("%w: state must be open or dismissed", ErrInvalidCodeScanningAlert)
+	}
+
+	if err := s.db.WithContext(ctx).Model(alert).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update code scanning alert: %w", err)
+	}
+	return s.GetAlert(ctx, alert.RepositoryID, alert.Number)
+}
+
+func (s *codeScanningService) PullRequestAnnotations(ctx context.Context, pr *models.PullRequest) ([]CodeScanningAnnotation, error) {
+	repoID := pr.RepositoryID
+	if pr.HeadRepositoryID != nil {
+		repoID = *pr.HeadRepositoryID
+	}
+
+	var alerts []*models.CodeScanningAlert
+	refs := []string{"refs/heads/" + pr.HeadBranch, fmt.Sprintf("refs/pull/%d/head", pr.Number), fmt.Sprintf("refs/pull/%d/merge", pr.Number)}
+	if err := s.db.WithContext(ctx).Where("repository_id = ? AND ref IN ? AND state = ?", repoID, refs, models.CodeScanningAlertOpen).
+		Order("path ASC, start_line ASC").Find(&alerts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list code scanning alerts: %w", err)
+	}
+	annotations := []CodeScanningAnnotation{}
+	if len(alerts) == 0 {
+		return annotations, nil
+	}
+
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, repoID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository path: %w", err)
+	}
+	comparison, err := s.gitService.CompareRefs(repoPath, pr.BaseBranch, pr.HeadBranch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare pull request branches: %w", err)
+	}
+	changed := make(map[string]map[int]bool, len(comparison.Files))
+	for _, file := range comparison.Files {
+		if file.Status != "deleted" {
+			changed[file.Path] = ChangedLines(file.Patch)
+		}
+	}
+
+	for _, alert := range alerts {
+		lines := changed[alert.Path]
+		for line := alert.StartLine; line <= alert.EndLine; line++ {
+			if lines[line] {
+				annotations = append(annotations, CodeScanningAnnotation{
+					AlertNumber: alert.Number,
+					Tool:        alert.Tool,
+					RuleID:      alert.RuleID,
+					Severity:    alert.Severity,
+					Message:     alert.Message,
+					Path:        alert.Path,
+					StartLine:   alert.StartLine,
+					EndLine:     alert.EndLine,
+				})
+				break
+			}
+		}
+	}
+	return annotations, nil
+}
+
+// ChangedLines returns the new-file line numbers a unified diff adds
+func ChangedLines(patch string) map[int]bool {
+	lines := map[int]bool{}
+	line := 0
+	inHunk := false
+	for _, text := range strings.Split(patch, "\n") {
+		if match := hunkHeaderPattern.FindStringSubmatch(text); match != nil {
+			line, _ = strconv.Atoi(match[1])
+			inHunk = true
+			continue
+		}
+		if !inHunk {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(text, "+"):
+			lines[line] = true
+			line++
+		case strings.HasPrefix(text, " "):
+			line++
+		case strings.HasPrefix(text, "-"), strings.HasPrefix(text, `\`):
+		default:
+			inHunk = false
+		}
+	}
+	return lines
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeSARIF(t *testing.T, results ...map[string]interface{}) string {
+	doc := map[string]interface{}{
+		"version": "2.1.0",
+		"runs": []interface{}{map[string]interface{}{
+			"tool": map[string]interface{}{"driver": map[string]interface{}{
+				"name": "gosec",
+				"rules": []interface{}{
+					map[string]interface{}{"id": "G101", "shortDescription": map[string]string{"text": "Hardcoded credentials"},
+						"properties": map[string]string{"security-severity": "9.1"}},
+					map[string]interface{}{"id": "G104", "shortDescription": map[string]string{"text": "Unchecked error"}},
+				},
+			}},
+			"results": results,
+		}},
+	}
+	raw, err := json.Marshal(doc)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err = zw.Write(raw)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func sarifFinding(rule, level, path string, line int) map[string]interface{} {
+	return map[string]interface{}{
+		"ruleId":  rule,
+		"level":   level,
+		"message": map[string]string{"text": rule + " in " + path},
+		"locations": []interface{}{map[string]interface{}{"physicalLocation": map[string]interface{}{
+			"artifactLocation": map[string]string{"uri": path},
+			"region":           map[string]int{"startLine": line},
+		}}},
+	}
+}
+
+func TestCodeScanningService_UploadSARIF(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.CommitStatus{}, &models.CheckRun{}, &models.CodeScanningAlert{}, &models.CodeScanningAnalysis{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	statuses := NewCommitStatusService(db, logger)
+	svc := NewCodeScanningService(db, nil, nil, statuses, logger)
+	repo := &models.Repository{ID: uuid.New()}
+	sha := strings.Repeat("a", 40)
+
+	_, err := svc.UploadSARIF(ctx, repo, nil, UploadSARIFRequest{CommitSHA: sha, Ref: "refs/heads/main", SARIF: "%%%"})
+	assert.ErrorIs(t, err, ErrInvalidSARIF)
+	_, err = svc.UploadSARIF(ctx, repo, nil, UploadSARIFRequest{CommitSHA: "abc", Ref: "refs/heads/main", SARIF: encodeSARIF(t)})
+	assert.ErrorIs(t, err, ErrInvalidSARIF)
+
+	upload := UploadSARIFRequest{CommitSHA: sha, Ref: "refs/heads/main", SARIF: encodeSARIF(t,
+		sarifFinding("G101", "warning", "config.go", 10),
+		sarifFinding("G104", "warning", "./main.go", 20),
+		sarifFinding("G104", "warning", "./main.go", 20),
+	)}
+	// Identical findings stay distinct by occurrence
+	analyses, err := svc.UploadSARIF(ctx, repo, nil, upload)
+	require.NoError(t, err)
+	require.Len(t, analyses, 1)
+	assert.Equal(t, "gosec", analyses[0].Tool)
+	assert.Equal(t, 3, analyses[0].NewAlerts)
+
+	alerts, err := svc.ListAlerts(ctx, repo.ID, CodeScanningAlertFilter{})
+	require.NoError(t, err)
+	require.Len(t, alerts, 3)
+	critical := models.CodeScanningSeverityCritical
+	alerts, err = svc.ListAlerts(ctx, repo.ID, CodeScanningAlertFilter{Severity: &critical})
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, "config.go", alerts[0].Path)
+	assert.Equal(t, "Hardcoded credentials", alerts[0].RuleDescription)
+
+	combined, err := statuses.Combined(ctx, repo.ID, sha)
+	require.NoError(t, err)
+	assert.Equal(t, models.CommitStatusFailure, combined.State)
+	assert.Equal(t, "code-scanning/gosec", combined.Statuses[0].Context)
+
+	// Re-uploading deduplicates; findings no longer reported are fixed
+	next := strings.Repeat("b", 40)
+	analyses, err = svc.UploadSARIF(ctx, repo, nil, UploadSARIFRequest{CommitSHA: next, Ref: "refs/heads/main", SARIF: encodeSARIF(t,
+		sarifFinding("G104", "warning", "./main.go", 25),
+	)})
+	require.NoError(t, err)
+	assert.Equal(t, 0, analyses[0].NewAlerts)
+	assert.Equal(t, 2, analyses[0].FixedAlerts)
+
+	open := models.CodeScanningAlertOpen
+	alerts, err = svc.ListAlerts(ctx, repo.ID, CodeScanningAlertFilter{State: &open})
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, 25, alerts[0].StartLine)
+	assert.Equal(t, next, alerts[0].CommitSHA)
+	combined, err = statuses.Combined(ctx, repo.ID, next)
+	require.NoError(t, err)
+	assert.Equal(t, models.CommitStatusSuccess, combined.State)
+
+	_, err = svc.UpdateAlert(ctx, alerts[0], uuid.New(), UpdateCodeScanningAlertRequest{State: models.CodeScanningAlertDismissed})
+	assert.ErrorIs(t, err, ErrInvalidCodeScanningAlert)
+	dismissed, err := svc.UpdateAlert(ctx, alerts[0], uuid.New(), UpdateCodeScanningAlertRequest{State: models.CodeScanningAlertDismissed, DismissedReason: "false positive"})
+	require.NoError(t, err)
+	assert.Equal(t, models.CodeScanningAlertDismissed, dismissed.State)
+
+	// A dismissed alert stays dismissed when reported again
+	_, err = svc.UploadSARIF(ctx, repo, nil, UploadSARIFRequest{CommitSHA: next, Ref: "refs/heads/main", SARIF: encodeSARIF(t,
+		sarifFinding("G104", "warning", "./main.go", 25),
+	)})
+	require.NoError(t, err)
+	alert, err := svc.GetAlert(ctx, repo.ID, dismissed.Number)
+	require.NoError(t, err)
+	assert.Equal(t, models.CodeScanningAlertDismissed, alert.State)
+
+	_, err = svc.GetAlert(ctx, repo.ID, 99)
+	assert.ErrorIs(t, err, ErrCodeScanningAlertNotFound)
+}
+
+func TestCodeScanningSeverity(t *testing.T) {
+	assert.Equal(t, models.CodeScanningSeverityHigh, codeScanningSeverity("error", nil))
+	assert.Equal(t, models.CodeScanningSeverityMedium, codeScanningSeverity("", nil))
+	assert.Equal(t, models.CodeScanningSeverityLow, codeScanningSeverity("note", nil))
+
+	rule := &sarifRule{Properties: map[string]interface{}{"security-severity": "7.5"}}
+	assert.Equal(t, models.CodeScanningSeverityHigh, codeScanningSeverity("note", rule))
+	rule = &sarifRule{}
+	rule.Configuration.Level = "error"
+	assert.Equal(t, models.CodeScanningSeverityHigh, codeScanningSeverity("", rule))
+}
+
+func TestChangedLines(t *testing.T) {
+	patch := "diff --git a/main.go b/main.go\n--- a/main.go\n+++ b/main.go\n" +
+		"@@ -1,3 +1,4 @@\n package main\n-import \"fmt\"\n+import \"os\"\n+import \"fmt\"\n func main() {}\n" +
+		"@@ -10,2 +11,2 @@\n x := 1\n+y := 2\n"
+	assert.Equal(t, map[int]bool{2: true, 3: true, 12: true}, ChangedLines(patch))
+}
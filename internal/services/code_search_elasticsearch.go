@@ -0,0 +1,342 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+)
+
+// codeSearchIndexSettings analyzes file contents into lower case trigrams
+// so phrase queries find substrings anywhere in a line
+const codeSearchIndexSettings = `{
+	"settings": {
+		"analysis": {
+			"tokenizer": {"code_trigram": {"type": "ngram", "min_gram": 3, "max_gram": 3}},
+			"analyzer": {"code": {"type": "custom", "tokenizer": "code_trigram", "filter": ["lowercase"]}},
+			"normalizer": {"lowercase": {"type": "custom", "filter": ["lowercase"]}}
+		}
+	},
+	"mappings": {
+		"properties": {
+			"repository_id": {"type": "keyword"},
+			"path": {"type": "keyword"},
+			"blob_sha": {"type": "keyword", "index": false},
+			"language": {"type": "keyword", "normalizer": "lowercase"},
+			"size_bytes": {"type": "long"},
+			"content": {"type": "text", "analyzer": "code"}
+		}
+	}
+}`
+
+// elasticsearchCodeSearchIndex keeps the code search index in an
+// Elasticsearch index named <index_prefix>_code
+type elasticsearchCodeSearchIndex struct {
+	baseURL string
+	index   string
+	cfg     config.Elasticsearch
+	client  *http.Client
+
+	mu    sync.Mutex
+	ready bool
+}
+
+type elasticsearchCodeDocument struct {
+	RepositoryID string `json:"repository_id"`
+	Path         string `json:"path"`
+	BlobSHA      string `json:"blob_sha"`
+	Language     string `json:"language"`
+	SizeBytes    int64  `json:"size_bytes"`
+	Content      string `json:"content"`
+}
+
+func newElasticsearchCodeSearchIndex(cfg config.Elasticsearch) (*elasticsearchCodeSearchIndex, error) {
+	baseURL := ""
+	switch {
+	case cfg.CloudID != "":
+		url, err := elasticCloudURL(cfg.CloudID)
+		if err != nil {
+			return nil, err
+		}
+		baseURL = url
+	case len(cfg.Addresses) > 0:
+		baseURL = cfg.Addresses[0]
+	default:
+		return nil, fmt.Errorf("elasticsearch is enabled without addresses or a cloud ID")
+	}
+	prefix := cfg.IndexPrefix
+	if prefix == "" {
+		prefix = "hub"
+	}
+	return &elasticsearchCodeSearchIndex{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		index:   prefix + "_" + IndexCode,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// elasticCloudURL decodes the Elasticsearch endpoint of an Elastic Cloud ID,
+// "<name>:base64(<host>$<es id>$<kibana id>)"
+func elasticCloudURL(cloudID string) (string, error) {
+	encoded := cloudID
+	if i := strings.LastIndex(cloudID, ":"); i >= 0 {
+		encoded = cloudID[i+1:]
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("invalid elasticsearch cloud ID: %w", err)
+	}
+	parts := strings.Split(string(decoded), "$")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid elasticsearch cloud ID")
+	}
+	return "https://" + parts[1] + "." + parts[0], nil
+}
+
+func (i *elasticsearchCodeSearchIndex) do(ctx context.Context, method, path, contentType string, body []byte, out interface{}) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, method, i.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	switch {
+	case i.cfg.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+i.cfg.APIKey)
+	case i.cfg.Username != "":
+		req.SetBasicAuth(i.cfg.Username, i.cfg.Password)
+	}
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("elasticsearch request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 && !(method == http.MethodHead && resp.StatusCode == http.StatusNotFound) {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, fmt.Errorf("elasticsearch %s %s returned %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("invalid elasticsearch response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func (i *elasticsearchCodeSearchIndex) doJSON(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	_, err = i.do(ctx, method, path, "application/json", payload, out)
+	return err
+}
+
+// ensureIndex creates the index with its mappings the first time it is used
+func (i *elasticsearchCodeSearchIndex) ensureIndex(ctx context.Context) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.ready {
+		return nil
+	}
+	status, err := i.do(ctx, http.MethodHead, "/"+i.index, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound {
+		if _, err := i.do(ctx, http.MethodPut, "/"+i.index, "application/json", []byte(codeSearchIndexSettings), nil); err != nil {
+			return err
+		}
+	}
+	i.ready = true
+	return nil
+}
+
+func codeDocumentID(repoID uuid.UUID, path string) string {
+	sum := sha256.Sum256([]byte(repoID.String() + "/" + path))
+	return hex.EncodeToString(sum[:])
+}
+
+// bulk runs index and delete actions, refreshing so searches see them
+func (i *elasticsearchCodeSearchIndex) bulk(ctx context.Context, body *bytes.Buffer) error {
+	if err := i.ensureIndex(ctx); err != nil {
+		return err
+	}
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int             `json:"status"`
+			Error  json.RawMessage `json:"error"`
+		} `json:"items"`
+	}
+	if _, err := i.do(ctx, http.MethodPost, "/"+i.index+"/_bulk?refresh=wait_for", "application/x-ndjson", body.Bytes(), &result); err != nil {
+		return err
+	}
+	if result.Errors {
+		for _, item := range result.Items {
+			for _, action := range item {
+				// Deleting a file that was never indexed is not an error
+				if action.Error != nil && action.Status != http.StatusNotFound {
+					return fmt.Errorf("elasticsearch bulk request failed: %s", action.Error)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (i *elasticsearchCodeSearchIndex) put(ctx context.Context, docs []*models.CodeSearchDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, doc := range docs {
+		action := map[string]interface{}{"index": map[string]string{"_id": codeDocumentID(doc.RepositoryID, doc.Path)}}
+		if err := encoder.Encode(action); err != nil {
+			return err
+		}
+		if err := encoder.Encode(elasticsearchCodeDocument{
+			RepositoryID: doc.RepositoryID.String(),
+			Path:         doc.Path,
+			BlobSHA:      doc.BlobSHA,
+			Language:     doc.Language,
+			SizeBytes:    doc.SizeBytes,
+			Content:      doc.Content,
+		}); err != nil {
+			return err
+		}
+	}
+	return i.bulk(ctx, &body)
+}
+
+func (i *elasticsearchCodeSearchIndex) remove(ctx context.Context, repoID uuid.UUID, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, path := range paths {
+		action := map[string]interface{}{"delete": map[string]string{"_id": codeDocumentID(repoID, path)}}
+		if err := encoder.Encode(action); err != nil {
+			return err
+		}
+	}
+	return i.bulk(ctx, &body)
+}
+
+func (i *elasticsearchCodeSearchIndex) drop(ctx context.Context, repoID uuid.UUID) error {
+	if err := i.ensureIndex(ctx); err != nil {
+		return err
+	}
+	query := map[string]interface{}{
+		"query": map[string]interface{}{"term": map[string]string{"repository_id": repoID.String()}},
+	}
+	return i.doJSON(ctx, http.MethodPost, "/"+i.index+"/_delete_by_query?refresh=true&conflicts=proceed", query, nil)
+}
+
+func (i *elasticsearchCodeSearchIndex) stats(ctx context.Context, repoID uuid.UUID) (int, int64, error) {
+	if err := i.ensureIndex(ctx); err != nil {
+		return 0, 0, err
+	}
+	query := map[string]interface{}{
+		"size":             0,
+		"track_total_hits": true,
+		"query":            map[string]interface{}{"term": map[string]string{"repository_id": repoID.String()}},
+		"aggs":             map[string]interface{}{"size": map[string]interface{}{"sum": map[string]string{"field": "size_bytes"}}},
+	}
+	var result struct {
+		Hits struct {
+			Total struct {
+				Value int `json:"value"`
+			} `json:"total"`
+		} `json:"hits"`
+		Aggregations struct {
+			Size struct {
+				Value float64 `json:"value"`
+			} `json:"size"`
+		} `json:"aggregations"`
+	}
+	if err := i.doJSON(ctx, http.MethodPost, "/"+i.index+"/_search", query, &result); err != nil {
+		return 0, 0, err
+	}
+	return result.Hits.Total.Value, int64(result.Aggregations.Size.Value), nil
+}
+
+func (i *elasticsearchCodeSearchIndex) candidates(ctx context.Context, filter codeSearchFilter, fn func(doc *models.CodeSearchDocument) bool) error {
+	if err := i.ensureIndex(ctx); err != nil {
+		return err
+	}
+	repoIDs := make([]string, 0, len(filter.RepositoryIDs))
+	for _, id := range filter.RepositoryIDs {
+		repoIDs = append(repoIDs, id.String())
+	}
+	filters := []interface{}{map[string]interface{}{"terms": map[string]interface{}{"repository_id": repoIDs}}}
+	if filter.Language != "" {
+		filters = append(filters, map[string]interface{}{"term": map[string]string{"language": filter.Language}})
+	}
+	if filter.PathPrefix != "" {
+		filters = append(filters, map[string]interface{}{"prefix": map[string]string{"path": filter.PathPrefix}})
+	}
+	var must []interface{}
+	for _, literal := range filter.Literals {
+		// Shorter literals produce no trigrams and are left to verification
+		if len([]rune(literal)) >= 3 {
+			must = append(must, map[string]interface{}{"match_phrase": map[string]string{"content": literal}})
+		}
+	}
+
+	for from := 0; ; from += codeSearchBatchSize {
+		query := map[string]interface{}{
+			"from":  from,
+			"size":  codeSearchBatchSize,
+			"sort":  []interface{}{map[string]string{"repository_id": "asc"}, map[string]string{"path": "asc"}},
+			"query": map[string]interface{}{"bool": map[string]interface{}{"filter": filters, "must": must}},
+		}
+		var result struct {
+			Hits struct {
+				Hits []struct {
+					Source elasticsearchCodeDocument `json:"_source"`
+				} `json:"hits"`
+			} `json:"hits"`
+		}
+		if err := i.doJSON(ctx, http.MethodPost, "/"+i.index+"/_search", query, &result); err != nil {
+			return err
+		}
+		for _, hit := range result.Hits.Hits {
+			repoID, err := uuid.Parse(hit.Source.RepositoryID)
+			if err != nil {
+				continue
+			}
+			doc := &models.CodeSearchDocument{
+				RepositoryID: repoID,
+				Path:         hit.Source.Path,
+				BlobSHA:      hit.Source.BlobSHA,
+				Language:     hit.Source.Language,
+				SizeBytes:    hit.Source.SizeBytes,
+				Content:      hit.Source.Content,
+			}
+			if !fn(doc) {
+				return nil
+			}
+		}
+		if len(result.Hits.Hits) < codeSearchBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// databaseCodeSearchIndex is the built-in code search index. Candidates
+// are narrowed with case-insensitive substring matches on file contents.
+type databaseCodeSearchIndex struct {
+	db *gorm.DB
+}
+
+func (i *databaseCodeSearchIndex) put(ctx context.Context, docs []*models.CodeSearchDocument) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	return i.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "repository_id"}, {Name: "path"}},
+		DoUpdates: clause.AssignmentColumns([]string{"blob_sha", "language", "size_bytes", "content", "updated_at"}),
+	}).Create(docs).Error
+}
+
+func (i *databaseCodeSearchIndex) remove(ctx context.Context, repoID uuid.UUID, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	return i.db.WithContext(ctx).Where("repository_id = ? AND path IN ?", repoID, paths).
+		Delete(&models.CodeSearchDocument{}).Error
+}
+
+func (i *databaseCodeSearchIndex) drop(ctx context.Context, repoID uuid.UUID) error {
+	return i.db.WithContext(ctx).Where("repository_id = ?", repoID).Delete(&models.CodeSearchDocument{}).Error
+}
+
+func (i *databaseCodeSearchIndex) stats(ctx context.Context, repoID uuid.UUID) (int, int64, error) {
+	var totals struct {
+		Files int
+		Size  int64
+	}
+	err := i.db.WithContext(ctx).Model(&models.CodeSearchDocument{}).
+		Select("COUNT(*) AS files, COALESCE(SUM(size_bytes), 0) AS size").
+		Where("repository_id = ?", repoID).Scan(&totals).Error
+	return totals.Files, totals.Size, err
+}
+
+func (i *databaseCodeSearchIndex) candidates(ctx context.Context, filter codeSearchFilter, fn func(doc *models.CodeSearchDocument) bool) error {
+	query := i.db.WithContext(ctx).Model(&models.CodeSearchDocument{}).
+		Where("repository_id IN ?", filter.RepositoryIDs)
+	if filter.Language != "" {
+		query = query.Where("LOWER(language) = ?", strings.ToLower(filter.Language))
+	}
+	if filter.PathPrefix != "" {
+		query = query.Where("path LIKE ? ESCAPE '\\'", escapeLikePattern(filter.PathPrefix)+"%")
+	}
+	for _, literal := range filter.Literals {
+		query = query.Where("LOWER(content) LIKE ? ESCAPE '\\'", "%"+escapeLikePattern(strings.ToLower(literal))+"%")
+	}
+
+	// Page by (repository, path) so the scan stops as soon as fn is done
+	var lastRepo uuid.UUID
+	lastPath := ""
+	for {
+		page := query.Session(&gorm.Session{}).Order("repository_id, path").Limit(codeSearchBatchSize)
+		if lastPath != "" {
+			page = page.Where("repository_id > ? OR (repository_id = ? AND path > ?)", lastRepo, lastRepo, lastPath)
+		}
+		var docs []*models.CodeSearchDocument
+		if err := page.Find(&docs).Error; err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			if !fn(doc) {
+				return nil
+			}
+		}
+		if len(docs) < codeSearchBatchSize {
+			return nil
+		}
+		lastRepo, lastPath = docs[len(docs)-1].RepositoryID, docs[len(docs)-1].Path
+	}
+}
+
+// escapeLikePattern escapes the LIKE wildcards in s
+func escapeLikePattern(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,618 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidCodeSearchQuery = errors.New("invalid code search query")
+	ErrCodeSearchDisabled     = errors.New("code search is disabled")
+)
+
+// Code search query modes
+const (
+	// CodeSearchModeTerms finds files containing every whitespace separated
+	// term, in any case
+	CodeSearchModeTerms = "terms"
+	// CodeSearchModeExact finds the query as typed
+	CodeSearchModeExact = "exact"
+	// CodeSearchModeRegex finds matches of an RE2 regular expression
+	CodeSearchModeRegex = "regex"
+)
+
+const (
+	// codeSearchMaxCandidates caps the indexed files a search verifies;
+	// results past it are reported as incomplete
+	codeSearchMaxCandidates = 1000
+	// codeSearchMaxLines caps the matching lines returned per file
+	codeSearchMaxLines = 10
+	// codeSearchBatchSize is how many files are written to the index at once
+	codeSearchBatchSize = 100
+)
+
+// CodeSearchOptions is a code search query and its filters
+type CodeSearchOptions struct {
+	Query    string
+	Mode     string // terms by default
+	Language string
+	// Path is a path pattern as used by path protection rules, such as
+	// "internal/", "*.go" or "/cmd/**/main.go"
+	Path string
+	// Repository narrows the search to one repository; otherwise every
+	// repository UserID can read is searched
+	Repository *models.Repository
+	UserID     *uuid.UUID
+	Page       int
+	PerPage    int
+}
+
+// CodeSearchResults is one page of files matching a code search.
+// IncompleteResults is set when more files matched the index than the
+// search verifies, so TotalCount is a lower bound.
+type CodeSearchResults struct {
+	TotalCount        int              `json:"total_count"`
+	IncompleteResults bool             `json:"incomplete_results"`
+	Items             []*CodeSearchHit `json:"items"`
+}
+
+// CodeSearchHit is a file matching a code search, with its matching lines
+type CodeSearchHit struct {
+	RepositoryID uuid.UUID         `json:"repository_id"`
+	Repository   string            `json:"repository"`
+	Path         string            `json:"path"`
+	Language     string            `json:"language,omitempty"`
+	BlobSHA      string            `json:"sha"`
+	Matches      []*CodeSearchLine `json:"matches"`
+}
+
+// CodeSearchLine is a matching line; Line is 1-based
+type CodeSearchLine struct {
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// CodeSearchService indexes the file contents of repository default
+// branches and searches them
+type CodeSearchService interface {
+	Enabled() bool
+	// IndexRepository brings the index of a repository's default branch up
+	// to date, reindexing only the files changed since the last run when
+	// it can
+	IndexRepository(ctx context.Context, repoID uuid.UUID) (*models.CodeSearchIndexStatus, error)
+	// ReindexRepository rebuilds the index of a repository from scratch
+	ReindexRepository(ctx context.Context, repoID uuid.UUID) (*models.CodeSearchIndexStatus, error)
+	IndexStatus(ctx context.Context, repoID uuid.UUID) (*models.CodeSearchIndexStatus, error)
+	Search(ctx context.Context, opts CodeSearchOptions) (*CodeSearchResults, error)
+
+	// HandlePush is a PushListener that reindexes pushed default branches
+	HandlePush(ctx context.Context, event PushEvent)
+}
+
+// codeSearchFilter selects the indexed files a search verifies.
+// Literals must all appear in a file, in any case.
+type codeSearchFilter struct {
+	RepositoryIDs []uuid.UUID
+	Language      string
+	PathPrefix    string
+	Literals      []string
+}
+
+// codeSearchIndex stores the searchable files of repositories
+type codeSearchIndex interface {
+	// put adds files, replacing earlier versions of the same paths
+	put(ctx context.Context, docs []*models.CodeSearchDocument) error
+	// remove drops the files at paths of a repository
+	remove(ctx context.Context, repoID uuid.UUID, paths []string) error
+	// drop removes every file of a repository
+	drop(ctx context.Context, repoID uuid.UUID) error
+	// stats counts the files of a repository and their size
+	stats(ctx context.Context, repoID uuid.UUID) (int, int64, error)
+	// candidates visits the files passing filter in repository and path
+	// order, with their contents, until fn returns false
+	candidates(ctx context.Context, filter codeSearchFilter, fn func(doc *models.CodeSearchDocument) bool) error
+}
+
+type codeSearchService struct {
+	db                *gorm.DB
+	gitService        git.GitService
+	repositoryService RepositoryService
+	index             codeSearchIndex
+	cfg               config.CodeSearch
+	logger            *logrus.Logger
+	now               func() time.Time
+}
+
+// NewCodeSearchService creates a code search service that keeps its index
+// in Elasticsearch when it is enabled and in the database otherwise
+func NewCodeSearchService(db *gorm.DB, gitService git.GitService, repositoryService RepositoryService, cfg config.CodeSearch, es config.Elasticsearch, logger *logrus.Logger) (CodeSearchService, error) {
+	var index codeSearchIndex = &databaseCodeSearchIndex{db: db}
+	if es.Enabled {
+		esIndex, err := newElasticsearchCodeSearchIndex(es)
+		if err != nil {
+			return nil, err
+		}
+		index = esIndex
+	}
+	return &codeSearchService{
+		db:                db,
+		gitService:        gitService,
+		repositoryService: repositoryService,
+		index:             index,
+		cfg:               cfg,
+		logger:            logger,
+		now:               time.Now,
+	}, nil
+}
+
+func (s *codeSearchService) Enabled() bool {
+	return s.cfg.Enabled
+}
+
+func (s *codeSearchService) IndexRepository(ctx context.Context, repoID uuid.UUID) (*models.CodeSearchIndexStatus, error) {
+	return s.run(ctx, repoID, false)
+}
+
+func (s *codeSearchService) ReindexRepository(ctx context.Context, repoID uuid.UUID) (*models.CodeSearchIndexStatus, error) {
+	return s.run(ctx, repoID, true)
+}
+
+func (s *codeSearchService) IndexStatus(ctx context.Context, repoID uuid.UUID) (*models.CodeSearchIndexStatus, error) {
+	var status models.CodeSearchIndexStatus
+	if err := s.db.WithContext(ctx).First(&status, "repository_id = ?", repoID).Error; err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// run indexes a repository and records the outcome. A failed run keeps
+// the last indexed commit, so the next run starts from it again.
+func (s *codeSearchService) run(ctx context.Context, repoID uuid.UUID, full bool) (*models.CodeSearchIndexStatus, error) {
+	if !s.cfg.Enabled {
+		return nil, ErrCodeSearchDisabled
+	}
+	var previous models.CodeSearchIndexStatus
+	err := s.db.WithContext(ctx).First(&previous, "repository_id = ?", repoID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load code search index status: %w", err)
+	}
+
+	started := s.now()
+	status := &models.CodeSearchIndexStatus{RepositoryID: repoID, CommitSHA: previous.CommitSHA}
+	err = s.indexRepository(ctx, repoID, &previous, status, full)
+	status.DurationMs = s.now().Sub(started).Milliseconds()
+	if err != nil {
+		status.State = models.CodeIndexStateFailed
+		status.Error = err.Error()
+	}
+	if status.State != models.CodeIndexStateFailed {
+		if status.Files, status.SizeBytes, err = s.index.stats(ctx, repoID); err != nil {
+			return nil, err
+		}
+	}
+	if saveErr := s.db.WithContext(ctx).Save(status).Error; saveErr != nil {
+		s.logger.WithError(saveErr).WithField("repository_id", repoID).Warn("Failed to record code search index status")
+	}
+	if status.State == models.CodeIndexStateFailed {
+		return status, fmt.Errorf("failed to index repository: %s", status.Error)
+	}
+	return status, nil
+}
+
+func (s *codeSearchService) indexRepository(ctx context.Context, repoID uuid.UUID, previous, status *models.CodeSearchIndexStatus, full bool) error {
+	var repo models.Repository
+	if err := s.db.WithContext(ctx).First(&repo, "id = ?", repoID).Error; err != nil {
+		return fmt.Errorf("failed to load repository: %w", err)
+	}
+	exclusions, err := loadCodeSearchExclusions(ctx, s.db, &repo)
+	if err != nil {
+		return err
+	}
+	if exclusions.Repository {
+		status.State = models.CodeIndexStateExcluded
+		status.CommitSHA = ""
+		return s.index.drop(ctx, repoID)
+	}
+
+	repoPath, err := s.repositoryService.GetRepositoryPath(ctx, repoID)
+	if err != nil {
+		return fmt.Errorf("failed to get repository path: %w", err)
+	}
+	sha, err := s.gitService.ResolveSHA(ctx, repoPath, repo.DefaultBranch)
+	if err != nil {
+		return fmt.Errorf("failed to resolve default branch: %w", err)
+	}
+
+	now := s.now()
+	status.State, status.IndexedAt = models.CodeIndexStateIndexed, &now
+	if !full && previous.CommitSHA != "" {
+		if previous.CommitSHA == sha {
+			status.CommitSHA, status.Incremental = sha, true
+			return nil
+		}
+		diff, err := s.gitService.GetCommitDiff(ctx, repoPath, previous.CommitSHA, sha)
+		if err == nil {
+			if err := s.indexChanges(ctx, &repo, repoPath, sha, exclusions, diff); err != nil {
+				return err
+			}
+			status.CommitSHA, status.Incremental = sha, true
+			return nil
+		}
+		// The last indexed commit is gone after a force push
+		s.logger.WithError(err).WithField("repository_id", repoID).Info("Rebuilding code search index")
+	}
+
+	// Until the rebuild completes nothing is indexed
+	status.CommitSHA = ""
+	if err := s.index.drop(ctx, repoID); err != nil {
+		return err
+	}
+	detector := git.NewLanguageDetector()
+	var batch []*models.CodeSearchDocument
+	err = s.gitService.WalkFiles(ctx, repoPath, sha, s.maxFileSize(), func(path string, content []byte) error {
+		if exclusions.excludes(path) {
+			return nil
+		}
+		batch = append(batch, codeSearchDocument(repoID, path, plumbing.ComputeHash(plumbing.BlobObject, content).String(), detector.DetectLanguage(path, content), content))
+		if len(batch) < codeSearchBatchSize {
+			return nil
+		}
+		err := s.index.put(ctx, batch)
+		batch = nil
+		return err
+	})
+	if err == nil && len(batch) > 0 {
+		err = s.index.put(ctx, batch)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to index files: %w", err)
+	}
+	status.CommitSHA = sha
+	return nil
+}
+
+// indexChanges reindexes the files diff added, changed, renamed or removed
+func (s *codeSearchService) indexChanges(ctx context.Context, repo *models.Repository, repoPath, sha string, exclusions codeSearchExclusions, diff *git.Diff) error {
+	detector := git.NewLanguageDetector()
+	var removed []string
+	var batch []*models.CodeSearchDocument
+	for _, file := range diff.Files {
+		switch file.Status {
+		case "deleted":
+			removed = append(removed, file.Path)
+			continue
+		case "renamed":
+			removed = append(removed, file.PrevPath)
+		}
+		if exclusions.excludes(file.Path) {
+			removed = append(removed, file.Path)
+			continue
+		}
+		blob, err := s.gitService.GetFile(ctx, repoPath, sha, file.Path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", file.Path, err)
+		}
+		if blob.Encoding != "" || strings.ContainsRune(blob.Content, 0) || (s.maxFileSize() > 0 && blob.Size > s.maxFileSize()) {
+			removed = append(removed, file.Path)
+			continue
+		}
+		content := []byte(blob.Content)
+		batch = append(batch, codeSearchDocument(repo.ID, file.Path, blob.SHA, detector.DetectLanguage(file.Path, content), content))
+	}
+
+	if len(removed) > 0 {
+		if err := s.index.remove(ctx, repo.ID, removed); err != nil {
+			return err
+		}
+	}
+	for start := 0; start < len(batch); start += codeSearchBatchSize {
+		end := start + codeSearchBatchSize
+		if end > len(batch) {
+			end = len(batch)
+		}
+		if err := s.index.put(ctx, batch[start:end]); err != nil {
+			return fmt.Errorf("failed to index files: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *codeSearchService) maxFileSize() int64 {
+	return int64(s.cfg.MaxFileSizeKB) * 1024
+}
+
+func codeSearchDocument(repoID uuid.UUID, path, blobSHA, language string, content []byte) *models.CodeSearchDocument {
+	return &models.CodeSearchDocument{
+		ID:           uuid.New(),
+		RepositoryID: repoID,
+		Path:         path,
+		BlobSHA:      blobSHA,
+		Language:     language,
+		SizeBytes:    int64(len(content)),
+		Content:      string(content),
+	}
+}
+
+func (s *codeSearchService) Search(ctx context.Context, opts CodeSearchOptions) (*CodeSearchResults, error) {
+	if !s.cfg.Enabled {
+		return nil, ErrCodeSearchDisabled
+	}
+	matcher, literals, err := parseCodeSearchQuery(opts.Query, opts.Mode)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+	if opts.PerPage < 1 || opts.PerPage > 100 {
+		opts.PerPage = 30
+	}
+
+	repos, err := s.searchableRepositories(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	results := &CodeSearchResults{Items: []*CodeSearchHit{}}
+	if len(repos) == 0 {
+		return results, nil
+	}
+	filter := codeSearchFilter{Language: opts.Language, PathPrefix: codeSearchPathPrefix(opts.Path), Literals: literals}
+	reposByID := make(map[uuid.UUID]*models.Repository, len(repos))
+	for _, repo := range repos {
+		filter.RepositoryIDs = append(filter.RepositoryIDs, repo.ID)
+		reposByID[repo.ID] = repo
+	}
+
+	// Exclusions apply to files indexed before they were added
+	exclusions := map[uuid.UUID]codeSearchExclusions{}
+	var exclusionErr error
+	var hits []*CodeSearchHit
+	verified := 0
+	err = s.index.candidates(ctx, filter, func(doc *models.CodeSearchDocument) bool {
+		if verified == codeSearchMaxCandidates {
+			results.IncompleteResults = true
+			return false
+		}
+		verified++
+		if opts.Path != "" && !MatchPathPattern(opts.Path, doc.Path) {
+			return true
+		}
+		excluded, ok := exclusions[doc.RepositoryID]
+		if !ok {
+			if excluded, exclusionErr = loadCodeSearchExclusions(ctx, s.db, reposByID[doc.RepositoryID]); exclusionErr != nil {
+				return false
+			}
+			exclusions[doc.RepositoryID] = excluded
+		}
+		if excluded.Repository || excluded.excludes(doc.Path) {
+			return true
+		}
+		if matches := matcher.match(doc.Content); matches != nil {
+			hits = append(hits, &CodeSearchHit{
+				RepositoryID: doc.RepositoryID,
+				Path:         doc.Path,
+				Language:     doc.Language,
+				BlobSHA:      doc.BlobSHA,
+				Matches:      matches,
+			})
+		}
+		return true
+	})
+	if err == nil {
+		err = exclusionErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to search code: %w", err)
+	}
+
+	results.TotalCount = len(hits)
+	start := (opts.Page - 1) * opts.PerPage
+	if start >= len(hits) {
+		return results, nil
+	}
+	end := start + opts.PerPage
+	if end > len(hits) {
+		end = len(hits)
+	}
+	results.Items = hits[start:end]
+	owners := ownerNames(ctx, s.db, s.logger, repos)
+	for _, hit := range results.Items {
+		repo := reposByID[hit.RepositoryID]
+		hit.Repository = owners[repo.OwnerID] + "/" + repo.Name
+	}
+	return results, nil
+}
+
+// searchableRepositories returns the repositories a search covers: the
+// requested one, or every repository the user can read. Repositories
+// excluded from code search are left out.
+func (s *codeSearchService) searchableRepositories(ctx context.Context, opts CodeSearchOptions) ([]*models.Repository, error) {
+	db := s.db.WithContext(ctx)
+	excluded := db.Model(&models.CodeSearchIndexStatus{}).Select("repository_id").
+		Where("state = ?", models.CodeIndexStateExcluded)
+	repos := db.Model(&models.Repository{}).Where("id NOT IN (?)", excluded)
+
+	switch {
+	case opts.Repository != nil:
+		repos = repos.Where("id = ?", opts.Repository.ID)
+	default:
+		repos = readableRepositories(db, repos, opts.UserID)
+	}
+
+	var found []*models.Repository
+	if err := repos.Select("id", "name", "owner_id", "owner_type").Find(&found).Error; err != nil {
+		return nil, fmt.Errorf("failed to load searchable repositories: %w", err)
+	}
+	return found, nil
+}
+
+// codeSearchPathPrefix returns the directory every path matching pattern
+// starts with, so the index can skip other files, or "" when matches can
+// be anywhere
+func codeSearchPathPrefix(pattern string) string {
+	anchored := strings.HasPrefix(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if !anchored && !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+		return ""
+	}
+	if i := strings.IndexAny(pattern, "*?["); i >= 0 {
+		pattern = pattern[:i]
+	}
+	if i := strings.LastIndex(pattern, "/"); i >= 0 {
+		return pattern[:i+1]
+	}
+	return ""
+}
+
+func (s *codeSearchService) HandlePush(ctx context.Context, event PushEvent) {
+	if !s.cfg.Enabled || event.Repository == nil {
+		return
+	}
+	for _, update := range event.Updates {
+		if update.BranchName() != event.Repository.DefaultBranch || update.IsDelete() {
+			continue
+		}
+		status, err := s.IndexRepository(ctx, event.Repository.ID)
+		logger := s.logger.WithField("repository_id", event.Repository.ID)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to update code search index")
+			return
+		}
+		logger.WithFields(logrus.Fields{
+			"commit":      status.CommitSHA,
+			"files":       status.Files,
+			"incremental": status.Incremental,
+		}).Info("Updated code search index")
+		return
+	}
+}
+
+// codeSearchMatcher finds the matching lines of a file
+type codeSearchMatcher struct {
+	mode  string
+	terms []string // lower case in terms mode
+	exact string
+	re    *regexp.Regexp
+}
+
+// parseCodeSearchQuery compiles a query and returns the literals every
+// matching file contains
+func parseCodeSearchQuery(query, mode string) (*codeSearchMatcher, []string, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, nil, fmt.Errorf("%w: empty query", ErrInvalidCodeSearchQuery)
+	}
+	switch mode {
+	case "", CodeSearchModeTerms:
+		terms := strings.Fields(strings.ToLower(query))
+		return &codeSearchMatcher{mode: CodeSearchModeTerms, terms: terms}, terms, nil
+	case CodeSearchModeExact:
+		return &codeSearchMatcher{mode: mode, exact: query}, []string{query}, nil
+	case CodeSearchModeRegex:
+		re, err := regexp.Compile(query)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidCodeSearchQuery, err)
+		}
+		parsed, err := syntax.Parse(query, syntax.Perl)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: %v", ErrInvalidCodeSearchQuery, err)
+		}
+		return &codeSearchMatcher{mode: mode, re: re}, requiredLiterals(parsed.Simplify()), nil
+	default:
+		return nil, nil, fmt.Errorf("%w: unknown mode %q", ErrInvalidCodeSearchQuery, mode)
+	}
+}
+
+// requiredLiterals returns strings every match of re contains, so the
+// index can narrow the files to verify
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return []string{string(re.Rune)}
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min > 0 {
+			return requiredLiterals(re.Sub[0])
+		}
+	case syntax.OpConcat:
+		var literals []string
+		for _, sub := range re.Sub {
+			literals = append(literals, requiredLiterals(sub)...)
+		}
+		return literals
+	}
+	return nil
+}
+
+// match returns the matching lines of content, or nil when the file does
+// not match
+func (m *codeSearchMatcher) match(content string) []*CodeSearchLine {
+	searched := content
+	var offsets []int
+	switch m.mode {
+	case CodeSearchModeTerms:
+		// Offsets into the lower cased copy fall on the same lines
+		searched = strings.ToLower(content)
+		for _, term := range m.terms {
+			found := indexAll(searched, term)
+			if len(found) == 0 {
+				return nil
+			}
+			offsets = append(offsets, found...)
+		}
+	case CodeSearchModeExact:
+		offsets = indexAll(content, m.exact)
+	case CodeSearchModeRegex:
+		for _, loc := range m.re.FindAllStringIndex(content, -1) {
+			offsets = append(offsets, loc[0])
+		}
+	}
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	sort.Ints(offsets)
+	lines := strings.Split(content, "\n")
+	var matches []*CodeSearchLine
+	for _, offset := range offsets {
+		line := strings.Count(searched[:offset], "\n")
+		if len(matches) > 0 && matches[len(matches)-1].Line == line+1 {
+			continue
+		}
+		matches = append(matches, &CodeSearchLine{Line: line + 1, Text: lines[line]})
+		if len(matches) == codeSearchMaxLines {
+			break
+		}
+	}
+	return matches
+}
+
+func indexAll(s, substr string) []int {
+	var offsets []int
+	for start := 0; start <= len(s); {
+		i := strings.Index(s[start:], substr)
+		if i < 0 {
+			break
+		}
+		offsets = append(offsets, start+i)
+		start += i + len(substr)
+		if substr == "" {
+			start++
+		}
+	}
+	return offsets
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodeSearchService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{},
+		&models.OrganizationSettings{}, &models.Team{}, &models.TeamMember{}, &models.RepositoryPermission{},
+		&models.Repository{}, &models.CodeSearchDocument{}, &models.CodeSearchIndexStatus{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	base := t.TempDir()
+	gitService := git.NewGitService(logger)
+	repoService := NewRepositoryService(db, gitService, logger, base)
+	svc, err := NewCodeSearchService(db, gitService, repoService, config.CodeSearch{Enabled: true, MaxFileSizeKB: 1}, config.Elasticsearch{}, logger)
+	require.NoError(t, err)
+
+	owner := &models.User{ID: uuid.New(), Username: "olivia", Email: "olivia@example.com"}
+	outsider := uuid.New()
+	require.NoError(t, db.Create(owner).Error)
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: owner.ID, Role: models.OrgRoleOwner}).Error)
+
+	newRepo := func(name string, visibility models.Visibility, files map[string]string) (*models.Repository, *testutil.GitRepo) {
+		repo := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: name, DefaultBranch: "main", Visibility: visibility}
+		require.NoError(t, db.Create(repo).Error)
+		fixture := testutil.NewGitRepo(t, testutil.RepositoryPath(base, repo))
+		fixture.Commit("main", "initial", files)
+		return repo, fixture
+	}
+	app, appFixture := newRepo("app", models.VisibilityPublic, map[string]string{
+		"main.go":         "package main\n\nfunc ParseConfig() error {\n\treturn nil\n}\n",
+		"cmd/tool/run.go": "package tool\n\n// parseconfig is called by Run\nfunc Run() { ParseConfig() }\n",
+		"README.md":       "# App\n\nCall ParseConfig to load settings.\n",
+		"big.txt":         string(make([]byte, 2048)),
+	})
+	secret, _ := newRepo("secret", models.VisibilityPrivate, map[string]string{
+		"keys.go": "package keys\n\nfunc ParseConfig() {}\n",
+	})
+
+	status, err := svc.IndexRepository(ctx, app.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.CodeIndexStateIndexed, status.State)
+	assert.Equal(t, appFixture.SHA("main"), status.CommitSHA)
+	assert.Equal(t, 3, status.Files, "files over the size limit are skipped")
+	assert.False(t, status.Incremental)
+	_, err = svc.IndexRepository(ctx, secret.ID)
+	require.NoError(t, err)
+
+	search := func(opts CodeSearchOptions) []string {
+		t.Helper()
+		results, err := svc.Search(ctx, opts)
+		require.NoError(t, err)
+		paths := []string{}
+		for _, hit := range results.Items {
+			paths = append(paths, hit.Repository+"/"+hit.Path)
+		}
+		return paths
+	}
+
+	t.Run("modes", func(t *testing.T) {
+		assert.Equal(t, []string{"acme/app/README.md", "acme/app/cmd/tool/run.go", "acme/app/main.go"},
+			search(CodeSearchOptions{Query: "parseconfig"}))
+		assert.Equal(t, []string{"acme/app/main.go"}, search(CodeSearchOptions{Query: "parseconfig error"}))
+		assert.Equal(t, []string{"acme/app/cmd/tool/run.go"}, search(CodeSearchOptions{Query: "parseconfig is", Mode: CodeSearchModeExact}))
+		assert.Equal(t, []string{"acme/app/cmd/tool/run.go", "acme/app/main.go"},
+			search(CodeSearchOptions{Query: `func \w+\(\)`, Mode: CodeSearchModeRegex}))
+
+		results, err := svc.Search(ctx, CodeSearchOptions{Query: "ParseConfig", Mode: CodeSearchModeExact, Path: "main.go"})
+		require.NoError(t, err)
+		require.Len(t, results.Items, 1)
+		assert.Equal(t, []*CodeSearchLine{{Line: 3, Text: "func ParseConfig() error {"}}, results.Items[0].Matches)
+		assert.Equal(t, "Go", results.Items[0].Language)
+
+		_, err = svc.Search(ctx, CodeSearchOptions{Query: "(", Mode: CodeSearchModeRegex})
+		assert.ErrorIs(t, err, ErrInvalidCodeSearchQuery)
+		_, err = svc.Search(ctx, CodeSearchOptions{Query: " "})
+		assert.ErrorIs(t, err, ErrInvalidCodeSearchQuery)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		assert.Equal(t, []string{"acme/app/README.md"}, search(CodeSearchOptions{Query: "parseconfig", Language: "markdown"}))
+		assert.Equal(t, []string{"acme/app/cmd/tool/run.go"}, search(CodeSearchOptions{Query: "parseconfig", Path: "/cmd/**"}))
+		assert.Equal(t, []string{"acme/app/cmd/tool/run.go", "acme/app/main.go"}, search(CodeSearchOptions{Query: "parseconfig", Path: "*.go"}))
+	})
+
+	t.Run("visibility", func(t *testing.T) {
+		assert.Len(t, search(CodeSearchOptions{Query: "parseconfig", UserID: &outsider}), 3)
+		assert.Len(t, search(CodeSearchOptions{Query: "parseconfig", UserID: &owner.ID}), 4)
+		assert.Equal(t, []string{"acme/secret/keys.go"}, search(CodeSearchOptions{Query: "parseconfig", Repository: secret}))
+	})
+
+	t.Run("push reindexes changed files", func(t *testing.T) {
+		before := appFixture.SHA("main")
+		file, err := gitService.GetFile(ctx, appFixture.Path, "main", "README.md")
+		require.NoError(t, err)
+		_, err = gitService.DeleteFile(ctx, appFixture.Path, git.DeleteFileRequest{Path: "README.md", Message: "drop readme", Branch: "main", SHA: file.SHA, Author: testutil.DefaultAuthor})
+		require.NoError(t, err)
+		appFixture.Commit("main", "load config", map[string]string{"main.go": "package main\n\nfunc LoadConfig() error {\n\treturn nil\n}\n"})
+
+		svc.HandlePush(ctx, PushEvent{Repository: app, Updates: []RefUpdate{{Ref: "refs/heads/main", OldSHA: before, NewSHA: appFixture.SHA("main")}}})
+		status, err := svc.IndexStatus(ctx, app.ID)
+		require.NoError(t, err)
+		assert.True(t, status.Incremental)
+		assert.Equal(t, appFixture.SHA("main"), status.CommitSHA)
+		assert.Equal(t, 2, status.Files)
+		assert.Equal(t, []string{"acme/app/cmd/tool/run.go"}, search(CodeSearchOptions{Query: "parseconfig", Repository: app}))
+		assert.Equal(t, []string{"acme/app/main.go"}, search(CodeSearchOptions{Query: "LoadConfig", Repository: app}))
+
+		status, err = svc.ReindexRepository(ctx, app.ID)
+		require.NoError(t, err)
+		assert.False(t, status.Incremental)
+		assert.Equal(t, 2, status.Files)
+	})
+
+	t.Run("exclusions", func(t *testing.T) {
+		require.NoError(t, db.Create(&models.OrganizationSettings{ID: uuid.New(), OrganizationID: org.ID,
+			CodeSearchExcludedRepositories: []string{"secret"}, CodeSearchExcludedPaths: []string{"cmd/**"}}).Error)
+		assert.Empty(t, search(CodeSearchOptions{Query: "parseconfig", UserID: &owner.ID}), "exclusions apply before reindexing")
+
+		status, err := svc.IndexRepository(ctx, secret.ID)
+		require.NoError(t, err)
+		assert.Equal(t, models.CodeIndexStateExcluded, status.State)
+		assert.Zero(t, status.Files)
+		assert.Empty(t, search(CodeSearchOptions{Query: "parseconfig", Repository: secret}))
+	})
+
+	disabled, err := NewCodeSearchService(db, gitService, repoService, config.CodeSearch{}, config.Elasticsearch{}, logger)
+	require.NoError(t, err)
+	_, err = disabled.Search(ctx, CodeSearchOptions{Query: "x"})
+	assert.ErrorIs(t, err, ErrCodeSearchDisabled)
+}
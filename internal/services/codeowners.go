@@ -0,0 +1,105 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+)
+
+// CodeOwnersPaths are the locations searched for a CODEOWNERS file, in order
+var CodeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"}
+
+var (
+	codeOwnerHandle = regexp.MustCompile(`^@[A-Za-z0-9][A-Za-z0-9._-]*(/[A-Za-z0-9][A-Za-z0-9._-]*)?$`)
+	codeOwnerEmail  = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+)
+
+// CodeOwnersRule is one pattern line of a CODEOWNERS file. A rule without
+// owners leaves matching files unowned.
+type CodeOwnersRule struct {
+	Line    int      `json:"line"`
+	Pattern string   `json:"pattern"`
+	Owners  []string `json:"owners"`
+}
+
+// CodeOwnersProblem is a syntax error or unresolvable owner in a CODEOWNERS file
+type CodeOwnersProblem struct {
+	Line    int    `json:"line"`
+	Kind    string `json:"kind"` // syntax, unknown_owner
+	Owner   string `json:"owner,omitempty"`
+	Source  string `json:"source"`
+	Message string `json:"message"`
+}
+
+// CodeOwners is a parsed CODEOWNERS file
+type CodeOwners struct {
+	Rules []CodeOwnersRule
+}
+
+// ParseCodeOwners parses CODEOWNERS content, skipping the lines it cannot
+// parse and reporting them as syntax problems
+func ParseCodeOwners(content string) (*CodeOwners, []CodeOwnersProblem) {
+	owners := &CodeOwners{}
+	var problems []CodeOwnersProblem
+
+	for i, raw := range strings.Split(content, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if idx := strings.Index(line, " #"); idx >= 0 {
+			line = strings.TrimSpace(line[:idx])
+		}
+		syntaxError := func(message string) {
+			problems = append(problems, CodeOwnersProblem{Line: i + 1, Kind: "syntax", Source: strings.TrimSpace(raw), Message: message})
+		}
+
+		fields := strings.Fields(line)
+		pattern := fields[0]
+		switch {
+		case strings.HasPrefix(pattern, "!"):
+			syntaxError("negated patterns are not supported")
+			continue
+		case strings.ContainsAny(pattern, "[]"):
+			syntaxError("character ranges are not supported")
+			continue
+		case strings.HasPrefix(pattern, "@") || codeOwnerEmail.MatchString(pattern):
+			syntaxError("line must start with a file pattern")
+			continue
+		}
+
+		rule := CodeOwnersRule{Line: i + 1, Pattern: pattern, Owners: []string{}}
+		valid := true
+		for _, owner := range fields[1:] {
+			if !codeOwnerHandle.MatchString(owner) && !codeOwnerEmail.MatchString(owner) {
+				syntaxError("invalid owner " + owner + ", expected @user, @org/team or an email address")
+				valid = false
+				break
+			}
+			rule.Owners = append(rule.Owners, owner)
+		}
+		if valid {
+			owners.Rules = append(owners.Rules, rule)
+		}
+	}
+	return owners, problems
+}
+
+// OwnersOf returns the owners of a file; the last matching rule wins
+func (c *CodeOwners) OwnersOf(file string) []string {
+	for i := len(c.Rules) - 1; i >= 0; i-- {
+		if MatchCodeOwnersPattern(c.Rules[i].Pattern, file) {
+			return c.Rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// MatchCodeOwnersPattern reports whether a CODEOWNERS pattern matches a file.
+// Patterns follow path protection pattern rules, and a pattern naming a
+// directory also matches everything below it.
+func MatchCodeOwnersPattern(pattern, file string) bool {
+	if MatchPathPattern(pattern, file) {
+		return true
+	}
+	return !strings.HasSuffix(pattern, "/") && MatchPathPattern(pattern+"/", file)
+}
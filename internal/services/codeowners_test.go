@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+const testCodeOwners = `# Default owners
+*               @alice
+/docs/          docs@example.com
+/internal/auth  @acme/security @bob   # security review
+*.generated.go
+!vendor/        @alice
+src/[ab]/       @alice
+/api/           alice
+`
+
+func TestParseCodeOwners(t *testing.T) {
+	owners, problems := ParseCodeOwners(testCodeOwners)
+
+	require.Len(t, owners.Rules, 4)
+	assert.Equal(t, CodeOwnersRule{Line: 4, Pattern: "/internal/auth", Owners: []string{"@acme/security", "@bob"}}, owners.Rules[2])
+
+	require.Len(t, problems, 3)
+	assert.Equal(t, 6, problems[0].Line)
+	assert.Equal(t, 7, problems[1].Line)
+	assert.Equal(t, 8, problems[2].Line)
+	assert.Contains(t, problems[2].Message, "invalid owner alice")
+
+	assert.Equal(t, []string{"@alice"}, owners.OwnersOf("README.md"))
+	assert.Equal(t, []string{"docs@example.com"}, owners.OwnersOf("docs/guide/intro.md"))
+	assert.Equal(t, []string{"@acme/security", "@bob"}, owners.OwnersOf("internal/auth/jwt.go"))
+	assert.Empty(t, owners.OwnersOf("pkg/models.generated.go"))
+}
+
+type fakeRepositoryPaths struct {
+	RepositoryService
+}
+
+func (fakeRepositoryPaths) GetRepositoryPath(ctx context.Context, repoID uuid.UUID) (string, error) {
+	return "/repos/" + repoID.String(), nil
+}
+
+type fakeCodeOwnersGit struct {
+	git.GitService
+	files map[string]string
+}
+
+func (f fakeCodeOwnersGit) GetFile(ctx context.Context, repoPath, ref, path string) (*git.File, error) {
+	content, ok := f.files[path]
+	if !ok {
+		return nil, errors.New("file not found")
+	}
+	return &git.File{Path: path, Content: content}, nil
+}
+
+func newCodeOwnersTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.Team{}, &models.Repository{},
+		&models.BranchProtectionRule{}, &models.PathProtectionRule{}, &models.RequiredStatusCheck{})
+	return db
+}
+
+func TestPathProtectionService_ValidateCodeOwners(t *testing.T) {
+	db := newCodeOwnersTestDB(t)
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	require.NoError(t, db.Create(&models.Team{ID: uuid.New(), OrganizationID: org.ID, Name: "security", Privacy: models.TeamPrivacyClosed}).Error)
+	require.NoError(t, db.Create(&models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", PasswordHash: "x"}).Error)
+	repo := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+
+	svc := NewPathProtectionService(db, fakeCodeOwnersGit{files: map[string]string{".github/CODEOWNERS": "* @alice @acme/security\n"}}, fakeRepositoryPaths{}, logrus.New())
+	ctx := context.Background()
+
+	validation, err := svc.ValidateCodeOwners(ctx, repo, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, ".github/CODEOWNERS", validation.Path)
+	assert.True(t, validation.Valid)
+
+	content := "* @alice @acme/security @acme\n/docs/ nobody@example.com @acme/writers @ghost\n/api/ @alice\n"
+	validation, err = svc.ValidateCodeOwners(ctx, repo, "", &content)
+	require.NoError(t, err)
+	assert.False(t, validation.Valid)
+	assert.Len(t, validation.Rules, 3)
+	var owners []string
+	for _, problem := range validation.Problems {
+		assert.Equal(t, "unknown_owner", problem.Kind)
+		owners = append(owners, problem.Owner)
+	}
+	assert.Equal(t, []string{"@acme", "nobody@example.com", "@acme/writers", "@ghost"}, owners)
+
+	_, err = NewPathProtectionService(db, fakeCodeOwnersGit{}, fakeRepositoryPaths{}, logrus.New()).ValidateCodeOwners(ctx, repo, "main", nil)
+	assert.True(t, errors.Is(err, ErrCodeOwnersNotFound))
+}
+
+func TestPathProtectionService_SimulateProtection(t *testing.T) {
+	db := newCodeOwnersTestDB(t)
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPublic}
+	require.NoError(t, db.Create(repo).Error)
+
+	checks, _ := json.Marshal(RequiredStatusChecks{Strict: true, Contexts: []string{"test", "lint"}})
+	reviews, _ := json.Marshal(RequiredPullRequestReviews{RequiredApprovingReviewCount: 1, RequireCodeOwnerReviews: true})
+	require.NoError(t, db.Create(&models.BranchProtectionRule{ID: uuid.New(), RepositoryID: repo.ID, Pattern: "main",
+		RequiredStatusChecks: string(checks), RequiredPullRequestReviews: string(reviews)}).Error)
+	require.NoError(t, db.Create(&models.PathProtectionRule{ID: uuid.New(), RepositoryID: repo.ID, Pattern: "/terraform/**",
+		BranchPattern: "*", RequiredApprovals: 2}).Error)
+
+	gitService := fakeCodeOwnersGit{files: map[string]string{"CODEOWNERS": "* @alice\n/terraform/ @ops\n"}}
+	svc := NewPathProtectionService(db, gitService, fakeRepositoryPaths{}, logrus.New())
+
+	sim, err := svc.SimulateProtection(context.Background(), repo, "main", ProtectionSimulationRequest{Files: []string{"terraform/main.tf", "README.md"}})
+	require.NoError(t, err)
+	assert.True(t, sim.Protected)
+	assert.Equal(t, []string{"lint", "test"}, sim.RequiredStatusChecks)
+	assert.True(t, sim.StrictStatusChecks)
+	assert.Equal(t, 1, sim.BranchRequiredApprovals)
+	assert.Equal(t, 2, sim.RequiredApprovals)
+	require.Len(t, sim.PathRules, 1)
+	assert.Equal(t, []string{"terraform/main.tf"}, sim.PathRules[0].MatchedPaths)
+	assert.Equal(t, "CODEOWNERS", sim.CodeOwnersPath)
+	require.Len(t, sim.CodeOwners, 2)
+	assert.Equal(t, []string{"@ops"}, sim.CodeOwners[0].Owners)
+	assert.Contains(t, sim.Requirements, "code owner review from @alice for README.md")
+
+	sim, err = svc.SimulateProtection(context.Background(), repo, "develop", ProtectionSimulationRequest{Files: []string{"terraform/main.tf"}})
+	require.NoError(t, err)
+	assert.False(t, sim.Protected)
+	assert.Equal(t, 2, sim.RequiredApprovals)
+}
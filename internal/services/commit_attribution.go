@@ -0,0 +1,37 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"gorm.io/gorm"
+)
+
+// commitTrailerRows converts the trailers of a synced commit's message into
+// rows, resolving the people named by them
+func commitTrailerRows(commit *models.Commit) []models.CommitTrailer {
+	var rows []models.CommitTrailer
+	for _, trailer := range git.ParseTrailers(commit.Message) {
+		row := models.CommitTrailer{
+			CommitID:     commit.ID,
+			RepositoryID: commit.RepositoryID,
+			Key:          trailer.Key,
+			Value:        trailer.Value,
+		}
+		if name, email, ok := trailer.Identity(); ok {
+			row.Name, row.Email = name, email
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// AttributedCommits scopes a commits query to the commits authored by email
+// or crediting it in a Co-authored-by trailer
+func AttributedCommits(query *gorm.DB, email string) *gorm.DB {
+	email = strings.ToLower(email)
+	coAuthored := query.Session(&gorm.Session{NewDB: true}).Model(&models.CommitTrailer{}).
+		Select("commit_id").Where("key = ? AND email = ?", git.TrailerCoAuthoredBy, email)
+	return query.Where("(LOWER(commits.author_email) = ? OR commits.id IN (?))", email, coAuthored)
+}
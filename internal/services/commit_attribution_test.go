@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitTrailerAttribution(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Commit{}, &models.CommitTrailer{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	svc := NewRepositoryService(db, git.NewGitService(logger), logger, t.TempDir()).(*repositoryService)
+	repoID := uuid.New()
+	jane := &models.User{ID: uuid.New(), Username: "jane", Email: "Jane@example.com"}
+	john := &models.User{ID: uuid.New(), Username: "john", Email: "john@example.com"}
+	require.NoError(t, db.Create([]*models.User{jane, john}).Error)
+
+	// A commit synced before trailers were recorded is backfilled
+	legacy := models.Commit{ID: uuid.New(), RepositoryID: repoID, SHA: "a1", AuthorName: "John", AuthorEmail: "john@example.com",
+		Message: "Add docs\n\nCo-authored-by: Jane <jane@example.com>", AuthorDate: time.Now(), CommitterDate: time.Now()}
+	require.NoError(t, db.Create(&legacy).Error)
+
+	commit := func(sha, email, message string) *git.Commit {
+		author := git.CommitAuthor{Name: "Someone", Email: email, Date: time.Now()}
+		return &git.Commit{SHA: sha, Message: message, Author: author, Committer: author, Stats: &git.CommitStats{Additions: 10, Deletions: 2}}
+	}
+	require.NoError(t, svc.syncCommitBatch(ctx, repoID, []*git.Commit{
+		commit("a1", "john@example.com", legacy.Message),
+		commit("b2", "john@example.com", "Fix build\n\nCo-authored-by: Jane <JANE@example.com>\nSigned-off-by: John <john@example.com>"),
+		commit("c3", "jane@example.com", "Refactor\n\nCo-authored-by: Pat <pat@example.com>"),
+		commit("d4", "john@example.com", "Tidy up"),
+	}))
+
+	var trailers []models.CommitTrailer
+	require.NoError(t, db.Order("email").Find(&trailers).Error)
+	require.Len(t, trailers, 4)
+	assert.Equal(t, git.TrailerCoAuthoredBy, trailers[0].Key)
+	assert.Equal(t, "jane@example.com", trailers[0].Email)
+
+	// Syncing again records nothing twice
+	require.NoError(t, svc.syncCommitBatch(ctx, repoID, []*git.Commit{commit("a1", "john@example.com", legacy.Message)}))
+	var count int64
+	require.NoError(t, db.Model(&models.CommitTrailer{}).Count(&count).Error)
+	assert.Equal(t, int64(4), count)
+
+	require.NoError(t, AttributedCommits(db.Model(&models.Commit{}), jane.Email).Count(&count).Error)
+	assert.Equal(t, int64(3), count)
+
+	analytics := &analyticsService{db: db, logger: logger}
+	stats, err := analytics.getRepositoryContributorStats(ctx, repoID, InsightFilters{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.TotalContributors)
+	require.Len(t, stats.TopContributors, 2)
+	assert.Equal(t, "jane", stats.TopContributors[0].Username)
+	assert.Equal(t, int64(3), stats.TopContributors[0].CommitCount)
+	assert.Equal(t, int64(2), stats.TopContributors[0].CoAuthoredCommits)
+	assert.Equal(t, int64(10), stats.TopContributors[0].LinesAdded)
+	assert.Equal(t, "john", stats.TopContributors[1].Username)
+	assert.Equal(t, int64(3), stats.TopContributors[1].CommitCount)
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidCommitStatus = errors.New("invalid commit status")
+
+var commitSHAPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// CreateCommitStatusRequest reports the state of a commit for one context
+type CreateCommitStatusRequest struct {
+	State       models.CommitStatusState `json:"state" binding:"required"`
+	Context     string                   `json:"context"`
+	Description string                   `json:"description"`
+	TargetURL   string                   `json:"target_url"`
+}
+
+// CombinedCommitStatus is the latest status of every context of a commit
+// rolled up into one state. Check runs count as statuses under their name.
+type CombinedCommitStatus struct {
+	SHA        string                   `json:"sha"`
+	State      models.CommitStatusState `json:"state"`
+	TotalCount int                      `json:"total_count"`
+	Statuses   []models.CommitStatus    `json:"statuses"`
+}
+
+// CommitStatusService stores statuses reported by CI and other integrations
+type CommitStatusService interface {
+	Create(ctx context.Context, repoID uuid.UUID, sha string, creatorID *uuid.UUID, req CreateCommitStatusRequest) (*models.CommitStatus, error)
+	// List returns every status of a commit, newest first
+	List(ctx context.Context, repoID uuid.UUID, sha string) ([]models.CommitStatus, error)
+	Combined(ctx context.Context, repoID uuid.UUID, sha string) (*CombinedCommitStatus, error)
+}
+
+type commitStatusService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+// NewCommitStatusService creates a new CommitStatusService
+func NewCommitStatusService(db *gorm.DB, logger *logrus.Logger) CommitStatusService {
+	return &commitStatusService{db: db, logger: logger}
+}
+
+func (s *commitStatusService) Create(ctx context.Context, repoID uuid.UUID, sha string, creatorID *uuid.UUID, req CreateCommitStatusRequest) (*models.CommitStatus, error) {
+	if !commitSHAPattern.MatchString(sha) {
+		return nil, fmt.Errorf("%w: sha must be a full 40 character commit id", ErrInvalidCommitStatus)
+	}
+	switch req.State {
+	case models.CommitStatusPending, models.CommitStatusSuccess, models.CommitStatusFailure, models.CommitStatusError:
+	default:
+		return nil, fmt.Errorf("%w: state must be pending, success, failure or error", ErrInvalidCommitStatus)
+	}
+	if req.Context == "" {
+		req.Context = "default"
+	}
+
+	status := &models.CommitStatus{
+		ID:           uuid.New(),
+		RepositoryID: repoID,
+		SHA:          sha,
+		State:        req.State,
+		Context:      req.Context,
+		Description:  req.Description,
+		TargetURL:    req.TargetURL,
+		CreatorID:    creatorID,
+	}
+	if err := s.db.WithContext(ctx).Create(status).Error; err != nil {
+		return nil, fmt.Errorf("failed to create commit status: %w", err)
+	}
+	return status, nil
+}
+
+func (s *commitStatusService) List(ctx context.Context, repoID uuid.UUID, sha string) ([]models.CommitStatus, error) {
+	var statuses []models.CommitStatus
+	err := s.db.WithContext(ctx).Preload("Creator").
+		Where("repository_id = ? AND sha = ?", repoID, sha).
+		Order("created_at DESC").
+		Find(&statuses).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commit statuses: %w", err)
+	}
+	return statuses, nil
+}
+
+// Combined is failure if any context failed or errored, pending if any is
+// still pending or none were reported, and success otherwise
+func (s *commitStatusService) Combined(ctx context.Context, repoID uuid.UUID, sha string) (*CombinedCommitStatus, error) {
+	statuses, err := s.List(ctx, repoID, sha)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := NewCheckRunService(s.db, s.logger).List(ctx, repoID, sha)
+	if err != nil {
+		return nil, err
+	}
+	for i := range runs {
+		statuses = append(statuses, checkRunAsStatus(&runs[i]))
+	}
+	// A status and a check run of the same name: the later report wins
+	sort.SliceStable(statuses, func(i, j int) bool { return statuses[i].CreatedAt.After(statuses[j].CreatedAt) })
+
+	combined := &CombinedCommitStatus{SHA: sha, State: models.CommitStatusSuccess, Statuses: []models.CommitStatus{}}
+	seen := map[string]bool{}
+	for _, status := range statuses {
+		if seen[status.Context] {
+			continue
+		}
+		seen[status.Context] = true
+		combined.Statuses = append(combined.Statuses, status)
+
+		switch status.State {
+		case models.CommitStatusFailure, models.CommitStatusError:
+			combined.State = models.CommitStatusFailure
+		case models.CommitStatusPending:
+			if combined.State != models.CommitStatusFailure {
+				combined.State = models.CommitStatusPending
+			}
+		}
+	}
+	if len(combined.Statuses) == 0 {
+		combined.State = models.CommitStatusPending
+	}
+	combined.TotalCount = len(combined.Statuses)
+	return combined, nil
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitStatusService_Combined(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.CommitStatus{}, &models.CheckRun{})
+
+	ctx := context.Background()
+	svc := NewCommitStatusService(db, logrus.New())
+	repoID := uuid.New()
+	sha := strings.Repeat("a", 40)
+
+	combined, err := svc.Combined(ctx, repoID, sha)
+	require.NoError(t, err)
+	assert.Equal(t, models.CommitStatusPending, combined.State)
+
+	_, err = svc.Create(ctx, repoID, "abc", nil, CreateCommitStatusRequest{State: models.CommitStatusSuccess})
+	assert.ErrorIs(t, err, ErrInvalidCommitStatus)
+	_, err = svc.Create(ctx, repoID, sha, nil, CreateCommitStatusRequest{State: "done"})
+	assert.ErrorIs(t, err, ErrInvalidCommitStatus)
+
+	_, err = svc.Create(ctx, repoID, sha, nil, CreateCommitStatusRequest{State: models.CommitStatusPending, Context: "ci/build"})
+	require.NoError(t, err)
+	_, err = svc.Create(ctx, repoID, sha, nil, CreateCommitStatusRequest{State: models.CommitStatusSuccess})
+	require.NoError(t, err)
+
+	combined, err = svc.Combined(ctx, repoID, sha)
+	require.NoError(t, err)
+	assert.Equal(t, models.CommitStatusPending, combined.State)
+	assert.Equal(t, 2, combined.TotalCount)
+
+	// Only the latest status of a context counts
+	_, err = svc.Create(ctx, repoID, sha, nil, CreateCommitStatusRequest{State: models.CommitStatusSuccess, Context: "ci/build"})
+	require.NoError(t, err)
+	combined, err = svc.Combined(ctx, repoID, sha)
+	require.NoError(t, err)
+	assert.Equal(t, models.CommitStatusSuccess, combined.State)
+	assert.Equal(t, 2, combined.TotalCount)
+
+	_, err = svc.Create(ctx, repoID, sha, nil, CreateCommitStatusRequest{State: models.CommitStatusError, Context: "lint"})
+	require.NoError(t, err)
+	combined, err = svc.Combined(ctx, repoID, sha)
+	require.NoError(t, err)
+	assert.Equal(t, models.CommitStatusFailure, combined.State)
+
+	statuses, err := svc.List(ctx, repoID, sha)
+	require.NoError(t, err)
+	assert.Len(t, statuses, 4)
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Defaults used when a commit verification report does not set them
+const (
+	defaultCommitVerificationDays  = 90
+	defaultTopUnverifiedCommitters = 10
+)
+
+// VerificationUnknown counts commits synced before their signatures were checked
+const VerificationUnknown = "unknown"
+
+var (
+	ErrCommitVerificationReportForbidden = errors.New("only organization owners and admins can view commit verification reports")
+	ErrInvalidCommitVerificationReport   = errors.New("invalid commit verification report request")
+)
+
+// CommitVerificationReportService summarizes how many commits pushed to the
+// repositories of an organization carry verified signatures, for compliance
+// reviews. It reads the verification recorded when commits are synced.
+type CommitVerificationReportService interface {
+	OrganizationReport(ctx context.Context, orgName string, actorID uuid.UUID, opts CommitVerificationReportOptions) (*CommitVerificationReport, error)
+}
+
+// CommitVerificationReportOptions bounds a commit verification report
+type CommitVerificationReportOptions struct {
+	// Days covers commits committed in this many days before now
+	Days int `json:"days"`
+	// TopCommitters caps how many committers of unverified commits are listed
+	TopCommitters int `json:"top_committers"`
+}
+
+// CommitVerificationReport is the signature coverage of an organization
+type CommitVerificationReport struct {
+	Organization    string    `json:"organization"`
+	GeneratedAt     time.Time `json:"generated_at"`
+	Since           time.Time `json:"since"`
+	TotalCommits    int64     `json:"total_commits"`
+	VerifiedCommits int64     `json:"verified_commits"`
+	PercentVerified float64   `json:"percent_verified"`
+	// UnverifiedReasons counts unverified commits by why they failed to verify
+	UnverifiedReasons       map[string]int64                  `json:"unverified_reasons"`
+	Repositories            []*RepositoryVerificationCoverage `json:"repositories"`
+	TopUnverifiedCommitters []*UnverifiedCommitter            `json:"top_unverified_committers"`
+	// RepositoriesWithoutEnforcement names the repositories whose default
+	// branch is not protected by a rule requiring signed commits
+	RepositoriesWithoutEnforcement []string `json:"repositories_without_enforcement"`
+}
+
+// RepositoryVerificationCoverage is the signature coverage of one repository
+type RepositoryVerificationCoverage struct {
+	RepositoryID          uuid.UUID `json:"repository_id"`
+	Name                  string    `json:"name"`
+	TotalCommits          int64     `json:"total_commits"`
+	VerifiedCommits       int64     `json:"verified_commits"`
+	PercentVerified       float64   `json:"percent_verified"`
+	SignedCommitsRequired bool      `json:"signed_commits_required"`
+}
+
+// UnverifiedCommitter is someone who committed unverified commits. Username
+// is set when the committer email belongs to a user.
+type UnverifiedCommitter struct {
+	Name              string `json:"name"`
+	Email             string `json:"email"`
+	Username          string `json:"username,omitempty"`
+	UnverifiedCommits int64  `json:"unverified_commits"`
+}
+
+type commitVerificationReportService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+	now    func() time.Time
+}
+
+// NewCommitVerificationReportService creates a new CommitVerificationReportService
+func NewCommitVerificationReportService(db *gorm.DB, logger *logrus.Logger) CommitVerificationReportService {
+	return &commitVerificationReportService{db: db, logger: logger, now: time.Now}
+}
+
+func (s *commitVerificationReportService) OrganizationReport(ctx context.Context, orgName string, actorID uuid.UUID, opts CommitVerificationReportOptions) (*CommitVerificationReport, error) {
+	if opts.Days < 0 || opts.TopCommitters < 0 {
+		return nil, fmt.Errorf("%w: days and top_committers must be positive", ErrInvalidCommitVerificationReport)
+	}
+	if opts.Days == 0 {
+		opts.Days = defaultCommitVerificationDays
+	}
+	if opts.TopCommitters == 0 {
+		opts.TopCommitters = defaultTopUnverifiedCommitters
+	}
+
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("organization not found: %w", err)
+	}
+	var member models.OrganizationMember
+	err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", org.ID, actorID).First(&member).Error
+	if err != nil || (member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin) {
+		return nil, ErrCommitVerificationReportForbidden
+	}
+
+	now := s.now()
+	report := &CommitVerificationReport{
+		Organization:                   org.Name,
+		GeneratedAt:                    now,
+		Since:                          now.AddDate(0, 0, -opts.Days),
+		UnverifiedReasons:              map[string]int64{},
+		Repositories:                   []*RepositoryVerificationCoverage{},
+		TopUnverifiedCommitters:        []*UnverifiedCommitter{},
+		RepositoriesWithoutEnforcement: []string{},
+	}
+
+	var repos []models.Repository
+	if err := s.db.WithContext(ctx).Where("owner_id = ? AND owner_type = ?", org.ID, models.OwnerTypeOrganization).
+		Order("name").Find(&repos).Error; err != nil {
+		return nil, fmt.Errorf("failed to list organization repositories: %w", err)
+	}
+	if len(repos) == 0 {
+		return report, nil
+	}
+	repoIDs := make([]uuid.UUID, len(repos))
+	for i, repo := range repos {
+		repoIDs[i] = repo.ID
+	}
+
+	required, err := s.signedCommitsRequired(ctx, repos)
+	if err != nil {
+		return nil, err
+	}
+	coverage, err := s.coverage(ctx, repoIDs, report.Since)
+	if err != nil {
+		return nil, err
+	}
+	for _, repo := range repos {
+		entry := &RepositoryVerificationCoverage{
+			RepositoryID:          repo.ID,
+			Name:                  repo.Name,
+			SignedCommitsRequired: required[repo.ID],
+		}
+		if counts, ok := coverage[repo.ID]; ok {
+			entry.TotalCommits, entry.VerifiedCommits = counts.Total, counts.Verified
+		}
+		entry.PercentVerified = percentOf(entry.VerifiedCommits, entry.TotalCommits)
+		report.TotalCommits += entry.TotalCommits
+		report.VerifiedCommits += entry.VerifiedCommits
+		report.Repositories = append(report.Repositories, entry)
+		if !entry.SignedCommitsRequired {
+			report.RepositoriesWithoutEnforcement = append(report.RepositoriesWithoutEnforcement, repo.Name)
+		}
+	}
+	report.PercentVerified = percentOf(report.VerifiedCommits, report.TotalCommits)
+
+	if report.UnverifiedReasons, err = s.unverifiedReasons(ctx, repoIDs, report.Since); err != nil {
+		return nil, err
+	}
+	if report.TopUnverifiedCommitters, err = s.topUnverifiedCommitters(ctx, repoIDs, report.Since, opts.TopCommitters); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+type verificationCounts struct {
+	RepositoryID uuid.UUID
+	Total        int64
+	Verified     int64
+}
+
+// coverage counts the commits and the verified commits of each repository
+func (s *commitVerificationReportService) coverage(ctx context.Context, repoIDs []uuid.UUID, since time.Time) (map[uuid.UUID]verificationCounts, error) {
+	var rows []verificationCounts
+	err := s.db.WithContext(ctx).Model(&models.Commit{}).
+		Select("repository_id, COUNT(*) AS total, SUM(CASE WHEN verified = ? THEN 1 ELSE 0 END) AS verified", true).
+		Where("repository_id IN ? AND committer_date >= ?", repoIDs, since).
+		Group("repository_id").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count verified commits: %w", err)
+	}
+	counts := make(map[uuid.UUID]verificationCounts, len(rows))
+	for _, row := range rows {
+		counts[row.RepositoryID] = row
+	}
+	return counts, nil
+}
+
+func (s *commitVerificationReportService) unverifiedReasons(ctx context.Context, repoIDs []uuid.UUID, since time.Time) (map[string]int64, error) {
+	var rows []struct {
+		VerificationReason string
+		Count              int64
+	}
+	err := s.db.WithContext(ctx).Model(&models.Commit{}).
+		Select("verification_reason, COUNT(*) AS count").
+		Where("repository_id IN ? AND committer_date >= ? AND verified = ?", repoIDs, since, false).
+		Group("verification_reason").Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unverified commits: %w", err)
+	}
+	reasons := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		reason := row.VerificationReason
+		if reason == "" {
+			reason = VerificationUnknown
+		}
+		reasons[reason] += row.Count
+	}
+	return reasons, nil
+}
+
+// topUnverifiedCommitters ranks committer emails by unverified commits,
+// matching them to users case-insensitively
+func (s *commitVerificationReportService) topUnverifiedCommitters(ctx context.Context, repoIDs []uuid.UUID, since time.Time, limit int) ([]*UnverifiedCommitter, error) {
+	var rows []struct {
+		Email string
+		Name  string
+		Count int64
+	}
+	err := s.db.WithContext(ctx).Model(&models.Commit{}).
+		Select("LOWER(committer_email) AS email, MAX(committer_name) AS name, COUNT(*) AS count").
+		Where("repository_id IN ? AND committer_date >= ? AND verified = ?", repoIDs, since, false).
+		Group("LOWER(committer_email)").Order("count DESC, email").Limit(limit).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to rank unverified committers: %w", err)
+	}
+
+	committers := make([]*UnverifiedCommitter, 0, len(rows))
+	emails := make([]string, 0, len(rows))
+	for _, row := range rows {
+		committers = append(committers, &UnverifiedCommitter{Name: row.Name, Email: row.Email, UnverifiedCommits: row.Count})
+		emails = append(emails, row.Email)
+	}
+	if len(emails) == 0 {
+		return committers, nil
+	}
+
+	var users []models.User
+	if err := s.db.WithContext(ctx).Where("LOWER(email) IN ?", emails).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to match committers to users: %w", err)
+	}
+	usernames := make(map[string]string, len(users))
+	for _, user := range users {
+		usernames[strings.ToLower(user.Email)] = user.Username
+	}
+	for _, committer := range committers {
+		committer.Username = usernames[committer.Email]
+	}
+	return committers, nil
+}
+
+// signedCommitsRequired reports which repositories protect their default
+// branch with a rule requiring signed commits
+func (s *commitVerificationReportService) signedCommitsRequired(ctx context.Context, repos []models.Repository) (map[uuid.UUID]bool, error) {
+	repoIDs := make([]uuid.UUID, len(repos))
+	for i, repo := range repos {
+		repoIDs[i] = repo.ID
+	}
+	var rules []models.BranchProtectionRule
+	if err := s.db.WithContext(ctx).Where("repository_id IN ? AND require_signed_commits = ?", repoIDs, true).
+		Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to load branch protection: %w", err)
+	}
+	patterns := make(map[uuid.UUID][]string)
+	for _, rule := range rules {
+		patterns[rule.RepositoryID] = append(patterns[rule.RepositoryID], rule.Pattern)
+	}
+
+	required := make(map[uuid.UUID]bool, len(repos))
+	for _, repo := range repos {
+		branch := repo.DefaultBranch
+		if branch == "" {
+			branch = "main"
+		}
+		for _, pattern := range patterns[repo.ID] {
+			if matchPattern(pattern, branch) {
+				required[repo.ID] = true
+				break
+			}
+		}
+	}
+	return required, nil
+}
+
+// percentOf returns part as a percentage of total, to one decimal
+func percentOf(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)*1000/float64(total)) / 10
+}
@@ -0,0 +1,93 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitVerificationReport(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{},
+		&models.Repository{}, &models.Commit{}, &models.BranchProtectionRule{})
+
+	ctx := context.Background()
+	svc := NewCommitVerificationReportService(db, logrus.New())
+
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	admin, member := uuid.New(), uuid.New()
+	for id, role := range map[uuid.UUID]models.OrganizationRole{admin: models.OrgRoleAdmin, member: models.OrgRoleMember} {
+		require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: id, Role: role}).Error)
+	}
+	require.NoError(t, db.Create(&models.User{ID: uuid.New(), Username: "dana", Email: "Dana@example.com", PasswordHash: "x"}).Error)
+
+	repo := func(name string) *models.Repository {
+		r := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: name,
+			DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+		require.NoError(t, db.Create(r).Error)
+		return r
+	}
+	api, web, docs := repo("api"), repo("web"), repo("docs")
+	require.NoError(t, db.Create([]*models.BranchProtectionRule{
+		{ID: uuid.New(), RepositoryID: api.ID, Pattern: "*", RequireSignedCommits: true},
+		{ID: uuid.New(), RepositoryID: web.ID, Pattern: "release/*", RequireSignedCommits: true},
+		{ID: uuid.New(), RepositoryID: docs.ID, Pattern: "main"},
+	}).Error)
+
+	n := 0
+	commit := func(r *models.Repository, email string, verified bool, reason string, age time.Duration) *models.Commit {
+		n++
+		at := time.Now().Add(-age)
+		return &models.Commit{ID: uuid.New(), RepositoryID: r.ID, SHA: fmt.Sprintf("%040d", n), AuthorName: "x", AuthorEmail: email,
+			AuthorDate: at, CommitterName: "Dana", CommitterEmail: email, CommitterDate: at, TreeSHA: "t",
+			Verified: verified, VerificationReason: reason}
+	}
+	day := 24 * time.Hour
+	require.NoError(t, db.Create([]*models.Commit{
+		commit(api, "dana@example.com", true, VerificationValid, day),
+		commit(api, "dana@example.com", true, VerificationValid, day),
+		commit(api, "DANA@example.com", false, VerificationUnsigned, day),
+		commit(web, "dana@example.com", false, VerificationUnsigned, 2*day),
+		commit(web, "bot@example.com", false, "", 3*day),
+		commit(web, "bot@example.com", false, VerificationUnsigned, 200*day),
+	}).Error)
+
+	_, err := svc.OrganizationReport(ctx, "acme", member, CommitVerificationReportOptions{})
+	assert.ErrorIs(t, err, ErrCommitVerificationReportForbidden)
+	_, err = svc.OrganizationReport(ctx, "acme", admin, CommitVerificationReportOptions{Days: -1})
+	assert.ErrorIs(t, err, ErrInvalidCommitVerificationReport)
+
+	report, err := svc.OrganizationReport(ctx, "acme", admin, CommitVerificationReportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), report.TotalCommits, "commits older than the window are left out")
+	assert.Equal(t, int64(2), report.VerifiedCommits)
+	assert.Equal(t, 40.0, report.PercentVerified)
+	assert.Equal(t, map[string]int64{VerificationUnsigned: 2, VerificationUnknown: 1}, report.UnverifiedReasons)
+	assert.Equal(t, []string{"docs", "web"}, report.RepositoriesWithoutEnforcement)
+
+	require.Len(t, report.Repositories, 3)
+	assert.Equal(t, "api", report.Repositories[0].Name)
+	assert.True(t, report.Repositories[0].SignedCommitsRequired)
+	assert.Equal(t, 66.7, report.Repositories[0].PercentVerified)
+	assert.Equal(t, int64(0), report.Repositories[1].TotalCommits)
+
+	require.Len(t, report.TopUnverifiedCommitters, 2)
+	assert.Equal(t, "dana@example.com", report.TopUnverifiedCommitters[0].Email)
+	assert.Equal(t, "dana", report.TopUnverifiedCommitters[0].Username)
+	assert.Equal(t, int64(2), report.TopUnverifiedCommitters[0].UnverifiedCommits)
+	assert.Empty(t, report.TopUnverifiedCommitters[1].Username)
+
+	report, err = svc.OrganizationReport(ctx, "acme", admin, CommitVerificationReportOptions{Days: 365, TopCommitters: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), report.TotalCommits)
+	require.Len(t, report.TopUnverifiedCommitters, 1)
+	assert.Equal(t, "bot@example.com", report.TopUnverifiedCommitters[0].Email)
+}
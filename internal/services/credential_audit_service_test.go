@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentialAuditService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{}, &models.OrganizationActivity{},
+		&models.Repository{}, &models.FineGrainedToken{}, &models.SSHKey{}, &models.DeployKey{},
+		&models.OAuthApplication{}, &models.OAuthAuthorization{}, &models.OAuthAccessToken{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	daysAgo := func(days int) time.Time { return now.AddDate(0, 0, -days) }
+	at := func(t time.Time) *time.Time { return &t }
+
+	oauth, err := NewOAuthProviderService(db, config.OAuthProvider{AccessTokenMinutes: 60, RefreshTokenDays: 30}, "https://hub.example.com", logger)
+	require.NoError(t, err)
+	svc := NewCredentialAuditService(db, oauth, logger).(*credentialAuditService)
+	svc.now = func() time.Time { return now }
+
+	owner := &models.User{ID: uuid.New(), Username: "olivia", Email: "olivia@example.com"}
+	member := &models.User{ID: uuid.New(), Username: "max", Email: "max@example.com"}
+	outsider := &models.User{ID: uuid.New(), Username: "eve", Email: "eve@example.com"}
+	require.NoError(t, db.Create([]*models.User{owner, member, outsider}).Error)
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	require.NoError(t, db.Create([]*models.OrganizationMember{
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: owner.ID, Role: models.OrgRoleOwner},
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: member.ID, Role: models.OrgRoleMember},
+	}).Error)
+	repo := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "app", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+
+	staleKey := &models.SSHKey{ID: uuid.New(), CreatedAt: daysAgo(400), UserID: member.ID, Title: "old laptop", KeyData: "ssh-ed25519 AAAA1", Fingerprint: "SHA256:old", LastUsedAt: at(daysAgo(200))}
+	freshKey := &models.SSHKey{ID: uuid.New(), CreatedAt: daysAgo(400), UserID: member.ID, Title: "laptop", KeyData: "ssh-ed25519 AAAA2", Fingerprint: "SHA256:new", LastUsedAt: at(daysAgo(1))}
+	outsiderKey := &models.SSHKey{ID: uuid.New(), CreatedAt: daysAgo(400), UserID: outsider.ID, Title: "eve", KeyData: "ssh-ed25519 AAAA3", Fingerprint: "SHA256:eve"}
+	require.NoError(t, db.Create([]*models.SSHKey{staleKey, freshKey, outsiderKey}).Error)
+
+	deployKey := &models.DeployKey{ID: uuid.New(), CreatedAt: daysAgo(120), RepositoryID: repo.ID, Title: "ci", Key: "ssh-ed25519 AAAA4", Fingerprint: "SHA256:ci"}
+	require.NoError(t, db.Create(deployKey).Error)
+
+	token := &models.FineGrainedToken{ID: uuid.New(), CreatedAt: daysAgo(30), UserID: member.ID, Name: "deploy", TokenHash: "hash",
+		TokenLastEight: "abcd1234", ResourceOwnerID: org.ID, ResourceOwnerType: models.OwnerTypeOrganization, Status: models.FineGrainedTokenActive}
+	require.NoError(t, db.Create(token).Error)
+	// Scoped tokens reach the organization through their user's membership
+	require.NoError(t, db.Create([]*models.FineGrainedToken{
+		{ID: uuid.New(), CreatedAt: daysAgo(30), UserID: member.ID, Name: "cli", TokenHash: "scoped1", ResourceOwnerID: member.ID,
+			ResourceOwnerType: models.OwnerTypeUser, Scopes: []string{models.PATScopeRepoRead}, Status: models.FineGrainedTokenActive},
+		{ID: uuid.New(), CreatedAt: daysAgo(30), UserID: outsider.ID, Name: "cli", TokenHash: "scoped2", ResourceOwnerID: outsider.ID,
+			ResourceOwnerType: models.OwnerTypeUser, Scopes: []string{models.PATScopeRepoRead}, Status: models.FineGrainedTokenActive},
+	}).Error)
+
+	app := &models.OAuthApplication{ID: uuid.New(), OwnerID: outsider.ID, Name: "ci-bot", ClientID: "client"}
+	require.NoError(t, db.Create(app).Error)
+	grant := &models.OAuthAuthorization{ID: uuid.New(), CreatedAt: daysAgo(300), UserID: member.ID, ApplicationID: app.ID}
+	require.NoError(t, db.Create(grant).Error)
+	require.NoError(t, db.Create([]*models.OAuthAccessToken{
+		{ID: uuid.New(), ApplicationID: app.ID, UserID: member.ID, TokenHash: "t1", RefreshTokenHash: "r1", LastUsedAt: at(daysAgo(100))},
+		{ID: uuid.New(), ApplicationID: app.ID, UserID: member.ID, TokenHash: "t2", RefreshTokenHash: "r2", LastUsedAt: at(daysAgo(95))},
+	}).Error)
+
+	report, err := svc.InstanceReport(ctx, CredentialReportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 90, report.InactiveDays)
+	assert.Len(t, report.Credentials, 8)
+	assert.Equal(t, CredentialTypeSummary{Total: 3, Inactive: 2, NeverUsed: 1}, report.Summary[CredentialSSHKey])
+	// The never used outsider key has gone unused the longest
+	assert.Equal(t, outsiderKey.ID, report.Credentials[0].ID)
+	assert.Equal(t, 400, report.Credentials[0].DaysUnused)
+
+	_, err = svc.OrganizationReport(ctx, "acme", member.ID, CredentialReportOptions{})
+	assert.ErrorIs(t, err, ErrCredentialAuditForbidden)
+	_, err = svc.InstanceReport(ctx, CredentialReportOptions{Types: []string{"password"}})
+	assert.ErrorIs(t, err, ErrInvalidCredentialAudit)
+
+	report, err = svc.OrganizationReport(ctx, "acme", owner.ID, CredentialReportOptions{InactiveOnly: true})
+	require.NoError(t, err)
+	byID := map[uuid.UUID]*CredentialUsage{}
+	for _, c := range report.Credentials {
+		byID[c.ID] = c
+	}
+	assert.Len(t, byID, 3)
+	assert.Contains(t, byID, staleKey.ID)
+	assert.Contains(t, byID, deployKey.ID)
+	require.Contains(t, byID, grant.ID)
+	assert.Equal(t, 95, byID[grant.ID].DaysUnused)
+	assert.Equal(t, "max", byID[grant.ID].Username)
+	assert.Equal(t, 2, report.Summary[CredentialPersonalAccessToken].Total)
+
+	// Credentials outside the organization cannot be revoked through it
+	result, err := svc.OrganizationRevoke(ctx, "acme", owner.ID, CredentialRevocationRequest{Credentials: []CredentialRef{
+		{Type: CredentialSSHKey, ID: staleKey.ID},
+		{Type: CredentialSSHKey, ID: outsiderKey.ID},
+	}})
+	require.NoError(t, err)
+	require.Len(t, result.Revoked, 1)
+	require.Len(t, result.Failed, 1)
+	assert.Equal(t, outsiderKey.ID, result.Failed[0].ID)
+	var remaining int64
+	require.NoError(t, db.Model(&models.SSHKey{}).Where("id = ?", staleKey.ID).Count(&remaining).Error)
+	assert.Zero(t, remaining)
+	var activity models.OrganizationActivity
+	require.NoError(t, db.Where("organization_id = ? AND action = ?", org.ID, models.ActivityCredentialRevoked).First(&activity).Error)
+	assert.Equal(t, owner.ID, activity.ActorID)
+
+	_, err = svc.OrganizationRevoke(ctx, "acme", owner.ID, CredentialRevocationRequest{})
+	assert.ErrorIs(t, err, ErrInvalidCredentialAudit)
+
+	// A dry run lists the inactive credentials without touching them
+	result, err = svc.InstanceRevoke(ctx, owner.ID, CredentialRevocationRequest{InactiveDays: 90, Types: []string{CredentialDeployKey, CredentialOAuthAuthorization}, DryRun: true})
+	require.NoError(t, err)
+	assert.Len(t, result.Revoked, 2)
+	require.NoError(t, db.Model(&models.OAuthAuthorization{}).Count(&remaining).Error)
+	assert.EqualValues(t, 1, remaining)
+
+	result, err = svc.InstanceRevoke(ctx, owner.ID, CredentialRevocationRequest{InactiveDays: 90, Types: []string{CredentialDeployKey, CredentialOAuthAuthorization}})
+	require.NoError(t, err)
+	assert.Len(t, result.Revoked, 2)
+	assert.Empty(t, result.Failed)
+	require.NoError(t, db.Model(&models.OAuthAuthorization{}).Count(&remaining).Error)
+	assert.Zero(t, remaining)
+	require.NoError(t, db.Model(&models.OAuthAccessToken{}).Where("revoked_at IS NULL").Count(&remaining).Error)
+	assert.Zero(t, remaining)
+
+	result, err = svc.InstanceRevoke(ctx, owner.ID, CredentialRevocationRequest{Credentials: []CredentialRef{{Type: CredentialPersonalAccessToken, ID: token.ID}}})
+	require.NoError(t, err)
+	require.Len(t, result.Revoked, 1)
+	var revoked models.FineGrainedToken
+	require.NoError(t, db.First(&revoked, "id = ?", token.ID).Error)
+	assert.Equal(t, models.FineGrainedTokenRevoked, revoked.Status)
+}
@@ -0,0 +1,400 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	maxDashboardWidgets     = 50
+	defaultDashboardRange   = 30
+	maxDashboardRangeInDays = 365
+)
+
+// CreateDashboardRequest defines a new organization dashboard
+type CreateDashboardRequest struct {
+	Name        string                   `json:"name" binding:"required"`
+	Description string                   `json:"description"`
+	Widgets     []models.DashboardWidget `json:"widgets"`
+}
+
+// UpdateDashboardRequest changes an organization dashboard; Widgets replaces
+// the whole widget list when set
+type UpdateDashboardRequest struct {
+	Name        *string                   `json:"name,omitempty"`
+	Description *string                   `json:"description,omitempty"`
+	Widgets     *[]models.DashboardWidget `json:"widgets,omitempty"`
+}
+
+// DashboardPoint is one metric sample in a widget series
+type DashboardPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// DashboardWidgetData is the evaluated result of a widget's query
+type DashboardWidgetData struct {
+	Widget    models.DashboardWidget     `json:"widget"`
+	Points    []DashboardPoint           `json:"points"`
+	Value     *float64                   `json:"value"`
+	Status    string                     `json:"status"` // ok, warning, critical, no_data
+	Threshold *models.DashboardThreshold `json:"threshold,omitempty"`
+}
+
+// DashboardData is a dashboard with every widget evaluated
+type DashboardData struct {
+	Dashboard   *models.OrganizationDashboard `json:"dashboard"`
+	Widgets     []DashboardWidgetData         `json:"widgets"`
+	GeneratedAt time.Time                     `json:"generated_at"`
+}
+
+// DashboardService manages organization metric dashboards. Members can view
+// dashboards; only owners and admins can change them.
+type DashboardService interface {
+	List(ctx context.Context, orgName string, actorID uuid.UUID) ([]*models.OrganizationDashboard, error)
+	Get(ctx context.Context, orgName string, dashboardID, actorID uuid.UUID) (*models.OrganizationDashboard, error)
+	Create(ctx context.Context, orgName string, actorID uuid.UUID, req CreateDashboardRequest) (*models.OrganizationDashboard, error)
+	Update(ctx context.Context, orgName string, dashboardID, actorID uuid.UUID, req UpdateDashboardRequest) (*models.OrganizationDashboard, error)
+	Delete(ctx context.Context, orgName string, dashboardID, actorID uuid.UUID) error
+	Evaluate(ctx context.Context, orgName string, dashboardID, actorID uuid.UUID, now time.Time) (*DashboardData, error)
+}
+
+var (
+	ErrDashboardNotFound    = errors.New("dashboard not found")
+	ErrInvalidDashboard     = errors.New("invalid dashboard")
+	ErrDashboardForbidden   = errors.New("only organization owners and admins can manage dashboards")
+	ErrDashboardMembersOnly = errors.New("dashboards are only visible to organization members")
+)
+
+type dashboardService struct {
+	db        *gorm.DB
+	analytics AnalyticsService
+	logger    *logrus.Logger
+}
+
+// NewDashboardService creates a new DashboardService. Widget queries are
+// answered by analytics.
+func NewDashboardService(db *gorm.DB, analytics AnalyticsService, logger *logrus.Logger) DashboardService {
+	return &dashboardService{db: db, analytics: analytics, logger: logger}
+}
+
+func (s *dashboardService) getOrg(ctx context.Context, orgName string) (*models.Organization, error) {
+	if t, ok := tenant.FromContext(ctx); ok && t.MatchesOrganization(orgName) {
+		return t.Organization, nil
+	}
+
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("organization not found: %w", err)
+	}
+	return &org, nil
+}
+
+// authorize resolves the organization and checks the actor's membership,
+// requiring an owner or admin role when manage is set
+func (s *dashboardService) authorize(ctx context.Context, orgName string, actorID uuid.UUID, manage bool) (*models.Organization, error) {
+	org, err := s.getOrg(ctx, orgName)
+	if err != nil {
+		return nil, err
+	}
+
+	var member models.OrganizationMember
+	if err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", org.ID, actorID).First(&member).Error; err != nil {
+		if manage {
+			return nil, ErrDashboardForbidden
+		}
+		return nil, ErrDashboardMembersOnly
+	}
+	if manage && member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin {
+		return nil, ErrDashboardForbidden
+	}
+	return org, nil
+}
+
+func (s *dashboardService) find(ctx context.Context, orgID, dashboardID uuid.UUID) (*models.OrganizationDashboard, error) {
+	var dashboard models.OrganizationDashboard
+	err := s.db.WithContext(ctx).Where("id = ? AND organization_id = ?", dashboardID, orgID).First(&dashboard).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDashboardNotFound
+		}
+		return nil, fmt.Errorf("failed to get dashboard: %w", err)
+	}
+	return &dashboard, nil
+}
+
+func (s *dashboardService) List(ctx context.Context, orgName string, actorID uuid.UUID) ([]*models.OrganizationDashboard, error) {
+	org, err := s.authorize(ctx, orgName, actorID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var dashboards []*models.OrganizationDashboard
+	if err := s.db.WithContext(ctx).Where("organization_id = ?", org.ID).Order("name ASC").Find(&dashboards).Error; err != nil {
+		return nil, fmt.Errorf("failed to list dashboards: %w", err)
+	}
+	return dashboards, nil
+}
+
+func (s *dashboardService) Get(ctx context.Context, orgName string, dashboardID, actorID uuid.UUID) (*models.OrganizationDashboard, error) {
+	org, err := s.authorize(ctx, orgName, actorID, false)
+	if err != nil {
+		return nil, err
+	}
+	return s.find(ctx, org.ID, dashboardID)
+}
+
+func (s *dashboardService) Create(ctx context.Context, orgName string, actorID uuid.UUID, req CreateDashboardRequest) (*models.OrganizationDashboard, error) {
+	org, err := s.authorize(ctx, orgName, actorID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	dashboard := &models.OrganizationDashboard{
+		ID:             uuid.New(),
+		OrganizationID: org.ID,
+		Name:           strings.TrimSpace(req.Name),
+		Description:    req.Description,
+		CreatedByID:    &actorID,
+		Widgets:        req.Widgets,
+	}
+	if err := s.validate(ctx, org, dashboard); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Create(dashboard).Error; err != nil {
+		return nil, fmt.Errorf("failed to create dashboard: %w", err)
+	}
+	return dashboard, nil
+}
+
+func (s *dashboardService) Update(ctx context.Context, orgName string, dashboardID, actorID uuid.UUID, req UpdateDashboardRequest) (*models.OrganizationDashboard, error) {
+	org, err := s.authorize(ctx, orgName, actorID, true)
+	if err != nil {
+		return nil, err
+	}
+	dashboard, err := s.find(ctx, org.ID, dashboardID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		dashboard.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		dashboard.Description = *req.Description
+	}
+	if req.Widgets != nil {
+		dashboard.Widgets = *req.Widgets
+	}
+	if err := s.validate(ctx, org, dashboard); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.WithContext(ctx).Save(dashboard).Error; err != nil {
+		return nil, fmt.Errorf("failed to update dashboard: %w", err)
+	}
+	return dashboard, nil
+}
+
+func (s *dashboardService) Delete(ctx context.Context, orgName string, dashboardID, actorID uuid.UUID) error {
+	org, err := s.authorize(ctx, orgName, actorID, true)
+	if err != nil {
+		return err
+	}
+	dashboard, err := s.find(ctx, org.ID, dashboardID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.db.WithContext(ctx).Delete(dashboard).Error; err != nil {
+		return fmt.Errorf("failed to delete dashboard: %w", err)
+	}
+	return nil
+}
+
+// validate checks a dashboard definition and fills in widget defaults
+func (s *dashboardService) validate(ctx context.Context, org *models.Organization, dashboard *models.OrganizationDashboard) error {
+	if dashboard.Name == "" || len(dashboard.Name) > 255 {
+		return fmt.Errorf("%w: name must be between 1 and 255 characters", ErrInvalidDashboard)
+	}
+	if len(dashboard.Widgets) > maxDashboardWidgets {
+		return fmt.Errorf("%w: at most %d widgets are allowed", ErrInvalidDashboard, maxDashboardWidgets)
+	}
+	if dashboard.Widgets == nil {
+		dashboard.Widgets = []models.DashboardWidget{}
+	}
+
+	for i := range dashboard.Widgets {
+		widget := &dashboard.Widgets[i]
+		invalid := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%w: widget %d: %s", ErrInvalidDashboard, i+1, fmt.Sprintf(format, args...))
+		}
+
+		widget.Metric = strings.TrimSpace(widget.Metric)
+		if widget.Metric == "" {
+			return invalid("metric is required")
+		}
+		if widget.Title == "" {
+			widget.Title = widget.Metric
+		}
+
+		switch widget.ChartType {
+		case "":
+			widget.ChartType = models.DashboardChartLine
+		case models.DashboardChartLine, models.DashboardChartBar, models.DashboardChartArea,
+			models.DashboardChartNumber, models.DashboardChartTable:
+		default:
+			return invalid("unsupported chart type %q", widget.ChartType)
+		}
+
+		switch widget.MetricType {
+		case "", models.MetricTypeCounter, models.MetricTypeGauge, models.MetricTypeHistogram, models.MetricTypeSummary:
+		default:
+			return invalid("unsupported metric type %q", widget.MetricType)
+		}
+
+		switch Period(widget.Period) {
+		case "":
+			widget.Period = string(PeriodDaily)
+		case PeriodHourly, PeriodDaily, PeriodWeekly, PeriodMonthly:
+		default:
+			return invalid("unsupported period %q", widget.Period)
+		}
+
+		switch widget.Aggregation {
+		case "":
+			widget.Aggregation = "last"
+			if widget.MetricType == models.MetricTypeCounter {
+				widget.Aggregation = "sum"
+			}
+		case "sum", "avg", "min", "max", "last":
+		default:
+			return invalid("unsupported aggregation %q", widget.Aggregation)
+		}
+
+		if widget.RangeDays == 0 {
+			widget.RangeDays = defaultDashboardRange
+		}
+		if widget.RangeDays < 0 || widget.RangeDays > maxDashboardRangeInDays {
+			return invalid("range_days must be between 1 and %d", maxDashboardRangeInDays)
+		}
+
+		if widget.RepositoryID != nil {
+			var count int64
+			if err := s.db.WithContext(ctx).Model(&models.Repository{}).
+				Where("id = ? AND owner_id = ? AND owner_type = ?", *widget.RepositoryID, org.ID, models.OwnerTypeOrganization).
+				Count(&count).Error; err != nil {
+				return fmt.Errorf("failed to check dashboard repository: %w", err)
+			}
+			if count == 0 {
+				return invalid("repository does not belong to %s", org.Name)
+			}
+		}
+
+		for _, threshold := range widget.Thresholds {
+			if threshold.Severity != models.ThresholdSeverityWarning && threshold.Severity != models.ThresholdSeverityCritical {
+				return invalid("threshold severity must be warning or critical")
+			}
+		}
+	}
+	return nil
+}
+
+func (s *dashboardService) Evaluate(ctx context.Context, orgName string, dashboardID, actorID uuid.UUID, now time.Time) (*DashboardData, error) {
+	org, err := s.authorize(ctx, orgName, actorID, false)
+	if err != nil {
+		return nil, err
+	}
+	dashboard, err := s.find(ctx, org.ID, dashboardID)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &DashboardData{Dashboard: dashboard, Widgets: make([]DashboardWidgetData, 0, len(dashboard.Widgets)), GeneratedAt: now}
+	for _, widget := range dashboard.Widgets {
+		start := now.AddDate(0, 0, -widget.RangeDays)
+		filters := MetricFilters{
+			Names:      []string{widget.Metric},
+			MetricType: string(widget.MetricType),
+			Period:     Period(widget.Period),
+			StartDate:  &start,
+			EndDate:    &now,
+		}
+		// Repository metrics are not always tagged with their organization,
+		// and validate already checked the repository belongs to it
+		if widget.RepositoryID != nil {
+			filters.RepositoryID = widget.RepositoryID
+		} else {
+			filters.OrganizationID = &org.ID
+		}
+
+		metrics, err := s.analytics.GetMetrics(ctx, filters)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate widget %q: %w", widget.Title, err)
+		}
+		data.Widgets = append(data.Widgets, evaluateWidget(widget, metrics))
+	}
+	return data, nil
+}
+
+// evaluateWidget aggregates a widget's samples and picks the most severe
+// threshold they cross
+func evaluateWidget(widget models.DashboardWidget, metrics []*models.AnalyticsMetric) DashboardWidgetData {
+	result := DashboardWidgetData{Widget: widget, Points: make([]DashboardPoint, 0, len(metrics)), Status: "no_data"}
+	// Metrics come back newest first
+	for i := len(metrics) - 1; i >= 0; i-- {
+		result.Points = append(result.Points, DashboardPoint{Timestamp: metrics[i].Timestamp, Value: metrics[i].Value})
+	}
+	if len(result.Points) == 0 {
+		return result
+	}
+
+	value := result.Points[len(result.Points)-1].Value
+	if widget.Aggregation != "last" {
+		value = result.Points[0].Value
+		sum := 0.0
+		for _, point := range result.Points {
+			sum += point.Value
+			switch {
+			case widget.Aggregation == "min" && point.Value < value:
+				value = point.Value
+			case widget.Aggregation == "max" && point.Value > value:
+				value = point.Value
+			}
+		}
+		switch widget.Aggregation {
+		case "sum":
+			value = sum
+		case "avg":
+			value = sum / float64(len(result.Points))
+		}
+	}
+	result.Value = &value
+	result.Status = "ok"
+
+	for i := range widget.Thresholds {
+		threshold := widget.Thresholds[i]
+		crossed := value >= threshold.Value
+		if threshold.Below {
+			crossed = value <= threshold.Value
+		}
+		if !crossed {
+			continue
+		}
+		if result.Threshold == nil || (threshold.Severity == models.ThresholdSeverityCritical && result.Threshold.Severity != models.ThresholdSeverityCritical) {
+			result.Threshold = &threshold
+			result.Status = string(threshold.Severity)
+		}
+	}
+	return result
+}
@@ -0,0 +1,81 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboardService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Organization{}, &models.OrganizationMember{}, &models.Repository{},
+		&models.OrganizationDashboard{}, &models.AnalyticsMetric{})
+
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	admin, member, outsider := uuid.New(), uuid.New(), uuid.New()
+	require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: admin, Role: models.OrgRoleAdmin}).Error)
+	require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: member, Role: models.OrgRoleMember}).Error)
+
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	for i, value := range []float64{4, 7, 12} {
+		require.NoError(t, db.Create(&models.AnalyticsMetric{ID: uuid.New(), Name: "ci.failures", MetricType: models.MetricTypeCounter,
+			Value: value, Timestamp: now.AddDate(0, 0, i-3), OrganizationID: &org.ID, Period: "daily", Tags: "{}"}).Error)
+	}
+	require.NoError(t, db.Create(&models.AnalyticsMetric{ID: uuid.New(), Name: "ci.failures", MetricType: models.MetricTypeCounter,
+		Value: 100, Timestamp: now.AddDate(0, 0, -1), OrganizationID: &outsider, Period: "daily", Tags: "{}"}).Error)
+
+	svc := NewDashboardService(db, NewAnalyticsService(db, nil, logrus.New()), logrus.New())
+	ctx := context.Background()
+
+	req := CreateDashboardRequest{Name: "CI health", Widgets: []models.DashboardWidget{{
+		Metric:     "ci.failures",
+		MetricType: models.MetricTypeCounter,
+		ChartType:  models.DashboardChartNumber,
+		Thresholds: []models.DashboardThreshold{
+			{Value: 10, Severity: models.ThresholdSeverityWarning},
+			{Value: 20, Severity: models.ThresholdSeverityCritical},
+		},
+	}}}
+	_, err := svc.Create(ctx, "acme", member, req)
+	assert.True(t, errors.Is(err, ErrDashboardForbidden))
+
+	dashboard, err := svc.Create(ctx, "acme", admin, req)
+	require.NoError(t, err)
+	assert.Equal(t, "sum", dashboard.Widgets[0].Aggregation)
+	assert.Equal(t, 30, dashboard.Widgets[0].RangeDays)
+
+	dashboards, err := svc.List(ctx, "acme", member)
+	require.NoError(t, err)
+	require.Len(t, dashboards, 1)
+	_, err = svc.List(ctx, "acme", outsider)
+	assert.True(t, errors.Is(err, ErrDashboardMembersOnly))
+
+	data, err := svc.Evaluate(ctx, "acme", dashboard.ID, member, now)
+	require.NoError(t, err)
+	require.Len(t, data.Widgets, 1)
+	assert.Len(t, data.Widgets[0].Points, 3)
+	assert.Equal(t, 4.0, data.Widgets[0].Points[0].Value)
+	assert.Equal(t, 23.0, *data.Widgets[0].Value)
+	assert.Equal(t, "critical", data.Widgets[0].Status)
+
+	bad := []models.DashboardWidget{{Metric: "ci.failures", ChartType: "pie"}}
+	_, err = svc.Update(ctx, "acme", dashboard.ID, admin, UpdateDashboardRequest{Widgets: &bad})
+	assert.True(t, errors.Is(err, ErrInvalidDashboard))
+
+	foreign := uuid.New()
+	bad = []models.DashboardWidget{{Metric: "ci.failures", RepositoryID: &foreign}}
+	_, err = svc.Update(ctx, "acme", dashboard.ID, admin, UpdateDashboardRequest{Widgets: &bad})
+	assert.True(t, errors.Is(err, ErrInvalidDashboard))
+
+	require.NoError(t, svc.Delete(ctx, "acme", dashboard.ID, admin))
+	_, err = svc.Get(ctx, "acme", dashboard.ID, member)
+	assert.True(t, errors.Is(err, ErrDashboardNotFound))
+}
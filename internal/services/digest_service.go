@@ -0,0 +1,543 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/i18n"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// DigestMailer delivers rendered digest emails.
+type DigestMailer interface {
+	SendDigestEmail(to, subject, htmlBody string) error
+}
+
+// UpdateDigestSettingsRequest updates the organization digest defaults.
+type UpdateDigestSettingsRequest struct {
+	DefaultFrequency *models.DigestFrequency `json:"default_frequency,omitempty"`
+	IncludeMergedPRs *bool                   `json:"include_merged_prs,omitempty"`
+	IncludeReleases  *bool                   `json:"include_releases,omitempty"`
+	IncludeTopIssues *bool                   `json:"include_top_issues,omitempty"`
+	TopIssuesLimit   *int                    `json:"top_issues_limit,omitempty"`
+}
+
+// DigestItem is a single line in a digest section.
+type DigestItem struct {
+	Repository string    `json:"repository"`
+	Title      string    `json:"title"`
+	Number     int       `json:"number,omitempty"`
+	Actor      string    `json:"actor,omitempty"`
+	Count      int       `json:"count,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Digest is the summarized organization activity for one recipient and period.
+type Digest struct {
+	Organization   string       `json:"organization"`
+	Frequency      string       `json:"frequency"`
+	PeriodStart    time.Time    `json:"period_start"`
+	PeriodEnd      time.Time    `json:"period_end"`
+	MergedPRs      []DigestItem `json:"merged_pull_requests"`
+	Releases       []DigestItem `json:"releases"`
+	TopIssues      []DigestItem `json:"top_issues"`
+	Activity       []DigestItem `json:"activity"`
+	UnsubscribeURL string       `json:"unsubscribe_url,omitempty"`
+}
+
+// IsEmpty reports whether the digest has nothing worth sending.
+func (d *Digest) IsEmpty() bool {
+	return len(d.MergedPRs) == 0 && len(d.Releases) == 0 && len(d.TopIssues) == 0 && len(d.Activity) == 0
+}
+
+// DigestService manages organization news feed digests.
+type DigestService interface {
+	GetSettings(ctx context.Context, orgName string) (*models.OrganizationDigestSettings, error)
+	UpdateSettings(ctx context.Context, orgName string, actorID uuid.UUID, req UpdateDigestSettingsRequest) (*models.OrganizationDigestSettings, error)
+	GetSubscription(ctx context.Context, orgName string, userID uuid.UUID) (*models.DigestSubscription, error)
+	UpdateSubscription(ctx context.Context, orgName string, userID uuid.UUID, frequency models.DigestFrequency) (*models.DigestSubscription, error)
+	Unsubscribe(ctx context.Context, token string) error
+	// BuildDigest builds the digest a member would receive, covering only
+	// the repositories the member can read
+	BuildDigest(ctx context.Context, orgName string, recipientID uuid.UUID, frequency models.DigestFrequency, now time.Time) (*Digest, error)
+	SendDueDigests(ctx context.Context, now time.Time) (int, error)
+	// SendScheduledDigests is the scheduler tick of SendDueDigests; failures
+	// and panics are logged rather than returned
+	SendScheduledDigests(ctx context.Context, now time.Time)
+}
+
+var (
+	ErrInvalidDigestFrequency = errors.New("invalid digest frequency")
+	ErrDigestForbidden        = errors.New("only organization owners and admins can change digest settings")
+	ErrDigestNotMember        = errors.New("only organization members can read its digest")
+	ErrDigestOrgNotFound      = errors.New("organization not found")
+)
+
+type digestService struct {
+	db      *gorm.DB
+	mailer  DigestMailer
+	bundle  *i18n.Bundle
+	logger  *logrus.Logger
+	baseURL string
+}
+
+// NewDigestService creates a new DigestService. Emails are localized into
+// each member's preferred locale using bundle.
+func NewDigestService(db *gorm.DB, mailer DigestMailer, bundle *i18n.Bundle, logger *logrus.Logger, baseURL string) DigestService {
+	return &digestService{db: db, mailer: mailer, bundle: bundle, logger: logger, baseURL: baseURL}
+}
+
+func validDigestFrequency(f models.DigestFrequency, allowDefault bool) bool {
+	switch f {
+	case models.DigestFrequencyOff, models.DigestFrequencyDaily, models.DigestFrequencyWeekly:
+		return true
+	case models.DigestFrequencyDefault:
+		return allowDefault
+	}
+	return false
+}
+
+// digestPeriod returns the lookback window for a frequency.
+func digestPeriod(f models.DigestFrequency) time.Duration {
+	if f == models.DigestFrequencyDaily {
+		return 24 * time.Hour
+	}
+	return 7 * 24 * time.Hour
+}
+
+func (s *digestService) getOrg(ctx context.Context, orgName string) (*models.Organization, error) {
+	if t, ok := tenant.FromContext(ctx); ok && t.MatchesOrganization(orgName) {
+		return t.Organization, nil
+	}
+
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDigestOrgNotFound
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return &org, nil
+}
+
+func (s *digestService) settingsFor(ctx context.Context, orgID uuid.UUID) (*models.OrganizationDigestSettings, error) {
+	settings := &models.OrganizationDigestSettings{
+		OrganizationID:   orgID,
+		DefaultFrequency: models.DigestFrequencyWeekly,
+		IncludeMergedPRs: true,
+		IncludeReleases:  true,
+		IncludeTopIssues: true,
+		TopIssuesLimit:   5,
+	}
+	err := s.db.WithContext(ctx).Where("organization_id = ?", orgID).First(settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get digest settings: %w", err)
+	}
+	return settings, nil
+}
+
+func (s *digestService) GetSettings(ctx context.Context, orgName string) (*models.OrganizationDigestSettings, error) {
+	org, err := s.getOrg(ctx, orgName)
+	if err != nil {
+		return nil, err
+	}
+	return s.settingsFor(ctx, org.ID)
+}
+
+func (s *digestService) UpdateSettings(ctx context.Context, orgName string, actorID uuid.UUID, req UpdateDigestSettingsRequest) (*models.OrganizationDigestSettings, error) {
+	org, err := s.getOrg(ctx, orgName)
+	if err != nil {
+		return nil, err
+	}
+
+	var member models.OrganizationMember
+	if err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", org.ID, actorID).First(&member).Error; err != nil {
+		return nil, ErrDigestForbidden
+	}
+	if member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin {
+		return nil, ErrDigestForbidden
+	}
+
+	settings, err := s.settingsFor(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DefaultFrequency != nil {
+		if !validDigestFrequency(*req.DefaultFrequency, false) {
+			return nil, ErrInvalidDigestFrequency
+		}
+		settings.DefaultFrequency = *req.DefaultFrequency
+	}
+	if req.IncludeMergedPRs != nil {
+		settings.IncludeMergedPRs = *req.IncludeMergedPRs
+	}
+	if req.IncludeReleases != nil {
+		settings.IncludeReleases = *req.IncludeReleases
+	}
+	if req.IncludeTopIssues != nil {
+		settings.IncludeTopIssues = *req.IncludeTopIssues
+	}
+	if req.TopIssuesLimit != nil && *req.TopIssuesLimit > 0 && *req.TopIssuesLimit <= 50 {
+		settings.TopIssuesLimit = *req.TopIssuesLimit
+	}
+
+	if err := s.db.WithContext(ctx).Save(settings).Error; err != nil {
+		return nil, fmt.Errorf("failed to save digest settings: %w", err)
+	}
+	return settings, nil
+}
+
+// subscriptionFor returns the member's subscription, creating a default one on first access.
+func (s *digestService) subscriptionFor(ctx context.Context, orgID, userID uuid.UUID) (*models.DigestSubscription, error) {
+	var sub models.DigestSubscription
+	err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", orgID, userID).First(&sub).Error
+	if err == nil {
+		return &sub, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get digest subscription: %w", err)
+	}
+
+	token, err := generateSecureToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate unsubscribe token: %w", err)
+	}
+	sub = models.DigestSubscription{
+		OrganizationID:   orgID,
+		UserID:           userID,
+		Frequency:        models.DigestFrequencyDefault,
+		UnsubscribeToken: token,
+	}
+	if err := s.db.WithContext(ctx).Create(&sub).Error; err != nil {
+		return nil, fmt.Errorf("failed to create digest subscription: %w", err)
+	}
+	return &sub, nil
+}
+
+func (s *digestService) GetSubscription(ctx context.Context, orgName string, userID uuid.UUID) (*models.DigestSubscription, error) {
+	org, err := s.getOrg(ctx, orgName)
+	if err != nil {
+		return nil, err
+	}
+	return s.subscriptionFor(ctx, org.ID, userID)
+}
+
+func (s *digestService) UpdateSubscription(ctx context.Context, orgName string, userID uuid.UUID, frequency models.DigestFrequency) (*models.DigestSubscription, error) {
+	if !validDigestFrequency(frequency, true) {
+		return nil, ErrInvalidDigestFrequency
+	}
+	org, err := s.getOrg(ctx, orgName)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := s.subscriptionFor(ctx, org.ID, userID)
+	if err != nil {
+		return nil, err
+	}
+	sub.Frequency = frequency
+	if err := s.db.WithContext(ctx).Save(sub).Error; err != nil {
+		return nil, fmt.Errorf("failed to update digest subscription: %w", err)
+	}
+	return sub, nil
+}
+
+func (s *digestService) Unsubscribe(ctx context.Context, token string) error {
+	result := s.db.WithContext(ctx).Model(&models.DigestSubscription{}).
+		Where("unsubscribe_token = ?", token).
+		Update("frequency", models.DigestFrequencyOff)
+	if result.Error != nil {
+		return fmt.Errorf("failed to unsubscribe: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("unsubscribe token not found")
+	}
+	return nil
+}
+
+func (s *digestService) BuildDigest(ctx context.Context, orgName string, recipientID uuid.UUID, frequency models.DigestFrequency, now time.Time) (*Digest, error) {
+	org, err := s.getOrg(ctx, orgName)
+	if err != nil {
+		return nil, err
+	}
+	var members int64
+	if err := s.db.WithContext(ctx).Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", org.ID, recipientID).Count(&members).Error; err != nil {
+		return nil, fmt.Errorf("failed to check organization membership: %w", err)
+	}
+	if members == 0 {
+		return nil, ErrDigestNotMember
+	}
+	settings, err := s.settingsFor(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+	if frequency == models.DigestFrequencyDefault || frequency == "" {
+		frequency = settings.DefaultFrequency
+	}
+	return s.buildDigest(ctx, org, recipientID, settings, frequency, now)
+}
+
+// buildDigest summarizes the organization's activity for one recipient;
+// repositories the recipient cannot read are left out
+func (s *digestService) buildDigest(ctx context.Context, org *models.Organization, recipientID uuid.UUID, settings *models.OrganizationDigestSettings, frequency models.DigestFrequency, now time.Time) (*Digest, error) {
+	since := now.Add(-digestPeriod(frequency))
+	digest := &Digest{
+		Organization: org.Name,
+		Frequency:    string(frequency),
+		PeriodStart:  since,
+		PeriodEnd:    now,
+	}
+	db := s.db.WithContext(ctx)
+
+	repoIDs := readableRepositories(db, db.Model(&models.Repository{}).Select("id").
+		Where("owner_id = ? AND owner_type = ?", org.ID, models.OwnerTypeOrganization), &recipientID)
+
+	if settings.IncludeMergedPRs {
+		var prs []models.PullRequest
+		if err := db.Preload("Repository").Preload("MergedBy").
+			Where("repository_id IN (?) AND merged = ? AND merged_at >= ? AND merged_at < ?", repoIDs, true, since, now).
+			Order("merged_at DESC").Limit(50).Find(&prs).Error; err != nil {
+			return nil, fmt.Errorf("failed to load merged pull requests: %w", err)
+		}
+		for _, pr := range prs {
+			item := DigestItem{Repository: pr.Repository.Name, Title: pr.Title, Number: pr.Number, Timestamp: *pr.MergedAt}
+			if pr.MergedBy != nil {
+				item.Actor = pr.MergedBy.Username
+			}
+			digest.MergedPRs = append(digest.MergedPRs, item)
+		}
+	}
+
+	if settings.IncludeReleases {
+		var tags []models.Tag
+		if err := db.Preload("Repository").
+			Where("repository_id IN (?) AND created_at >= ? AND created_at < ?", repoIDs, since, now).
+			Order("created_at DESC").Limit(50).Find(&tags).Error; err != nil {
+			return nil, fmt.Errorf("failed to load releases: %w", err)
+		}
+		for _, tag := range tags {
+			digest.Releases = append(digest.Releases, DigestItem{
+				Repository: tag.Repository.Name,
+				Title:      tag.Name,
+				Actor:      tag.TaggerName,
+				Timestamp:  tag.CreatedAt,
+			})
+		}
+	}
+
+	if settings.IncludeTopIssues {
+		var rows []struct {
+			Repository string
+			Title      string
+			Number     int
+			Comments   int
+			CreatedAt  time.Time
+		}
+		if err := db.Table("issues").
+			Select("repositories.name AS repository, issues.title, issues.number, COUNT(comments.id) AS comments, issues.created_at").
+			Joins("JOIN repositories ON repositories.id = issues.repository_id").
+			Joins("LEFT JOIN comments ON comments.issue_id = issues.id AND comments.created_at >= ? AND comments.deleted_at IS NULL", since).
+			Where("issues.repository_id IN (?) AND issues.state = ? AND issues.deleted_at IS NULL", repoIDs, models.IssueStateOpen).
+			Group("repositories.name, issues.id, issues.title, issues.number, issues.created_at").
+			Having("COUNT(comments.id) > 0 OR issues.created_at >= ?", since).
+			Order("comments DESC, issues.created_at DESC").
+			Limit(settings.TopIssuesLimit).
+			Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to load top issues: %w", err)
+		}
+		for _, row := range rows {
+			digest.TopIssues = append(digest.TopIssues, DigestItem{
+				Repository: row.Repository,
+				Title:      row.Title,
+				Number:     row.Number,
+				Count:      row.Comments,
+				Timestamp:  row.CreatedAt,
+			})
+		}
+	}
+
+	var activities []models.OrganizationActivity
+	if err := db.Preload("Actor").
+		Where("organization_id = ? AND created_at >= ? AND created_at < ?", org.ID, since, now).
+		Order("created_at DESC").Limit(20).Find(&activities).Error; err != nil {
+		return nil, fmt.Errorf("failed to load activity: %w", err)
+	}
+	for _, activity := range activities {
+		digest.Activity = append(digest.Activity, DigestItem{
+			Title:     string(activity.Action),
+			Actor:     activity.Actor.Username,
+			Timestamp: activity.CreatedAt,
+		})
+	}
+
+	return digest, nil
+}
+
+// isDigestDue reports whether a subscription with the effective frequency should receive a digest now.
+func isDigestDue(frequency models.DigestFrequency, lastSentAt *time.Time, now time.Time) bool {
+	if frequency != models.DigestFrequencyDaily && frequency != models.DigestFrequencyWeekly {
+		return false
+	}
+	if lastSentAt == nil {
+		return true
+	}
+	// Allow a small grace window so hourly scheduling does not drift by a full period
+	return now.Sub(*lastSentAt) >= digestPeriod(frequency)-time.Hour
+}
+
+func (s *digestService) SendDueDigests(ctx context.Context, now time.Time) (int, error) {
+	var members []models.OrganizationMember
+	if err := s.db.WithContext(ctx).Preload("Organization").Preload("User").Find(&members).Error; err != nil {
+		return 0, fmt.Errorf("failed to list organization members: %w", err)
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(members))
+	for _, member := range members {
+		userIDs = append(userIDs, member.UserID)
+	}
+	var prefRows []*models.UserPreferences
+	if err := s.db.WithContext(ctx).Where("user_id IN ?", userIDs).Find(&prefRows).Error; err != nil {
+		return 0, fmt.Errorf("failed to load user preferences: %w", err)
+	}
+	preferences := make(map[uuid.UUID]*models.UserPreferences, len(prefRows))
+	for _, prefs := range prefRows {
+		preferences[prefs.UserID] = prefs
+	}
+
+	settingsCache := make(map[uuid.UUID]*models.OrganizationDigestSettings)
+	sent := 0
+	for _, member := range members {
+		if !member.User.IsActive || member.User.Email == "" {
+			continue
+		}
+
+		settings, ok := settingsCache[member.OrganizationID]
+		if !ok {
+			var err error
+			settings, err = s.settingsFor(ctx, member.OrganizationID)
+			if err != nil {
+				return sent, err
+			}
+			settingsCache[member.OrganizationID] = settings
+		}
+
+		sub, err := s.subscriptionFor(ctx, member.OrganizationID, member.UserID)
+		if err != nil {
+			return sent, err
+		}
+		prefs, ok := preferences[member.UserID]
+		if !ok {
+			prefs = DefaultUserPreferences(member.UserID)
+		}
+		// A per-organization subscription wins over the user's preference,
+		// which wins over the organization default
+		frequency := sub.Frequency
+		if frequency == models.DigestFrequencyDefault {
+			frequency = prefs.EmailFrequency
+		}
+		if frequency == models.DigestFrequencyDefault {
+			frequency = settings.DefaultFrequency
+		}
+		if !isDigestDue(frequency, sub.LastSentAt, now) {
+			continue
+		}
+
+		digest, err := s.buildDigest(ctx, &member.Organization, member.UserID, settings, frequency, now)
+		if err != nil {
+			return sent, err
+		}
+		if !digest.IsEmpty() {
+			digest.UnsubscribeURL = fmt.Sprintf("%s/api/v1/digests/unsubscribe?token=%s", s.baseURL, sub.UnsubscribeToken)
+			localizer := s.bundle.Localizer(member.User.Locale)
+			body, err := renderDigestHTML(digest, localizer, prefs)
+			if err != nil {
+				return sent, err
+			}
+			subject := localizer.T("digest.subject",
+				"frequency", localizer.T("digest.frequency."+string(frequency)),
+				"organization", member.Organization.DisplayName)
+			if err := s.mailer.SendDigestEmail(member.User.Email, subject, body); err != nil {
+				s.logger.WithError(err).WithField("user_id", member.UserID).Warn("Failed to send organization digest")
+				continue
+			}
+			sent++
+		}
+
+		// Record the run even for empty digests so the window advances
+		if err := s.db.WithContext(ctx).Model(sub).Update("last_sent_at", now).Error; err != nil {
+			return sent, fmt.Errorf("failed to record digest delivery: %w", err)
+		}
+	}
+
+	return sent, nil
+}
+
+// SendScheduledDigests runs one scheduler tick; a panic is reported rather
+// than stopping the scheduler
+func (s *digestService) SendScheduledDigests(ctx context.Context, now time.Time) {
+	defer errorreporting.Default().Recover("digest_scheduler", nil)
+	sent, err := s.SendDueDigests(ctx, now)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to send organization digests")
+		return
+	}
+	if sent > 0 {
+		s.logger.WithField("sent", sent).Info("Organization digests sent")
+	}
+}
+
+// digestTemplate is parsed with placeholder funcs; renderDigestHTML binds
+// them to the recipient's localizer
+var digestTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{
+	"t":    func(string, ...interface{}) string { return "" },
+	"n":    func(string, int, ...interface{}) string { return "" },
+	"date": func(time.Time) string { return "" },
+}).Parse(`<html>
+<body>
+	<h2>{{t "digest.heading" "organization" .Organization "frequency" (t (printf "digest.frequency.%s" .Frequency))}}</h2>
+	<p>{{date .PeriodStart}} &ndash; {{date .PeriodEnd}}</p>
+	{{if .MergedPRs}}<h3>{{t "digest.merged_prs"}}</h3>
+	<ul>{{range .MergedPRs}}<li>{{.Repository}} #{{.Number}}: {{.Title}}{{if .Actor}} ({{t "digest.merged_by" "actor" .Actor}}){{end}}</li>{{end}}</ul>{{end}}
+	{{if .Releases}}<h3>{{t "digest.releases"}}</h3>
+	<ul>{{range .Releases}}<li>{{.Repository}} {{.Title}}</li>{{end}}</ul>{{end}}
+	{{if .TopIssues}}<h3>{{t "digest.top_issues"}}</h3>
+	<ul>{{range .TopIssues}}<li>{{.Repository}} #{{.Number}}: {{.Title}} ({{n "digest.new_comments" .Count}})</li>{{end}}</ul>{{end}}
+	{{if .Activity}}<h3>{{t "digest.activity"}}</h3>
+	<ul>{{range .Activity}}<li>{{.Actor}} {{.Title}}</li>{{end}}</ul>{{end}}
+	<p style="font-size: 12px; color: #666;"><a href="{{.UnsubscribeURL}}">{{t "digest.unsubscribe"}}</a> {{t "digest.unsubscribe_suffix" "organization" .Organization}}</p>
+</body>
+</html>`))
+
+// renderDigestHTML renders a digest for one recipient; dates follow prefs,
+// which may be nil for the defaults
+func renderDigestHTML(digest *Digest, localizer *i18n.Localizer, prefs *models.UserPreferences) (string, error) {
+	tmpl, err := digestTemplate.Clone()
+	if err != nil {
+		return "", fmt.Errorf("failed to render digest: %w", err)
+	}
+	loc, layout := prefs.Location(), models.DateFormatISO.Layout()
+	if prefs != nil {
+		layout = prefs.DateFormat.Layout()
+	}
+	tmpl.Funcs(template.FuncMap{
+		"t":    localizer.T,
+		"n":    localizer.N,
+		"date": func(t time.Time) string { return t.In(loc).Format(layout) },
+	})
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, digest); err != nil {
+		return "", fmt.Errorf("failed to render digest: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/i18n"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsDigestDue(t *testing.T) {
+	now := time.Date(2024, 1, 8, 9, 0, 0, 0, time.UTC)
+	sixDaysAgo := now.Add(-6 * 24 * time.Hour)
+	almostWeekAgo := now.Add(-7*24*time.Hour + 30*time.Minute)
+	yesterday := now.Add(-24 * time.Hour)
+
+	assert.True(t, isDigestDue(models.DigestFrequencyDaily, nil, now))
+	assert.True(t, isDigestDue(models.DigestFrequencyDaily, &yesterday, now))
+	assert.False(t, isDigestDue(models.DigestFrequencyWeekly, &sixDaysAgo, now))
+	assert.True(t, isDigestDue(models.DigestFrequencyWeekly, &almostWeekAgo, now))
+	assert.False(t, isDigestDue(models.DigestFrequencyOff, nil, now))
+	assert.False(t, isDigestDue(models.DigestFrequencyDefault, nil, now))
+}
+
+func TestRenderDigestHTML(t *testing.T) {
+	digest := &Digest{
+		Organization:   "acme",
+		Frequency:      "weekly",
+		PeriodStart:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		PeriodEnd:      time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC),
+		MergedPRs:      []DigestItem{{Repository: "api", Title: "Add <script> tag", Number: 7, Actor: "alice"}},
+		UnsubscribeURL: "http://localhost/api/v1/digests/unsubscribe?token=abc",
+	}
+
+	bundle := i18n.MustNewBundle()
+	body, err := renderDigestHTML(digest, bundle.Localizer("en"), nil)
+	require.NoError(t, err)
+	assert.Contains(t, body, "2024-01-01")
+	assert.Contains(t, body, "api #7")
+	assert.Contains(t, body, "token=abc")
+	assert.Contains(t, body, "merged by alice")
+	assert.False(t, strings.Contains(body, "<script>"), "titles must be escaped")
+	assert.NotContains(t, body, "New releases")
+
+	prefs := &models.UserPreferences{Timezone: "America/New_York", DateFormat: models.DateFormatEU}
+	body, err = renderDigestHTML(digest, bundle.Localizer("es"), prefs)
+	require.NoError(t, err)
+	assert.Contains(t, body, "31/12/2023")
+	assert.Contains(t, body, "Resumen semanal de acme")
+	assert.Contains(t, body, "fusionada por alice")
+}
+
+func TestBuildDigestRecipient(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{}, &models.TeamMember{},
+		&models.Repository{}, &models.RepositoryPermission{}, &models.PullRequest{}, &models.Tag{}, &models.Issue{},
+		&models.Comment{}, &models.OrganizationActivity{}, &models.OrganizationDigestSettings{})
+	ctx := context.Background()
+	now := time.Date(2024, 3, 8, 9, 0, 0, 0, time.UTC)
+	svc := NewDigestService(db, nil, i18n.Default(), logrus.New(), "https://hub.example.com")
+
+	owner := &models.User{ID: uuid.New(), Username: "owner", Email: "owner@example.com"}
+	member := &models.User{ID: uuid.New(), Username: "member", Email: "member@example.com"}
+	outsider := &models.User{ID: uuid.New(), Username: "outsider", Email: "outsider@example.com"}
+	require.NoError(t, db.Create([]*models.User{owner, member, outsider}).Error)
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	require.NoError(t, db.Create([]*models.OrganizationMember{
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: owner.ID, Role: models.OrgRoleOwner},
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: member.ID, Role: models.OrgRoleMember},
+	}).Error)
+	public := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "site", Visibility: models.VisibilityPublic}
+	secret := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "secret", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create([]*models.Repository{public, secret}).Error)
+	merged := now.Add(-time.Hour)
+	for i, repo := range []*models.Repository{public, secret} {
+		require.NoError(t, db.Create(&models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: i + 1,
+			Title: "Change " + repo.Name, State: models.PullRequestStateMerged, Merged: true, MergedAt: &merged}).Error)
+	}
+
+	titles := func(digest *Digest) []string {
+		var titles []string
+		for _, item := range digest.MergedPRs {
+			titles = append(titles, item.Title)
+		}
+		return titles
+	}
+	digest, err := svc.BuildDigest(ctx, "acme", owner.ID, models.DigestFrequencyWeekly, now)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Change site", "Change secret"}, titles(digest))
+
+	digest, err = svc.BuildDigest(ctx, "acme", member.ID, models.DigestFrequencyWeekly, now)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Change site"}, titles(digest), "private repositories the member cannot read are left out")
+
+	_, err = svc.BuildDigest(ctx, "acme", outsider.ID, models.DigestFrequencyWeekly, now)
+	assert.ErrorIs(t, err, ErrDigestNotMember)
+	_, err = svc.BuildDigest(ctx, "missing", owner.ID, models.DigestFrequencyWeekly, now)
+	assert.ErrorIs(t, err, ErrDigestOrgNotFound)
+}
@@ -0,0 +1,381 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// JobReplicateRepository is the queued job kind HandlePush enqueues
+const JobReplicateRepository = "repository.dr_replicate"
+
+// replicaLockTTL is how long a replication holds its repository; a replica
+// that dies mid-run frees the repository after at most this long
+const replicaLockTTL = 30 * time.Minute
+
+// ErrReplicationBusy is returned when another replica is replicating the
+// repository; the queued job is retried later
+var ErrReplicationBusy = errors.New("repository is being replicated")
+
+// DisasterRecoveryStatus summarizes how far replication has come
+type DisasterRecoveryStatus struct {
+	Enabled      bool  `json:"enabled"`
+	Repositories int64 `json:"repositories"`
+	Replicated   int64 `json:"replicated"`
+	Failing      int64 `json:"failing"`
+	// LastVerification is the replica restored most recently
+	LastVerification *models.RepositoryReplica  `json:"last_verification"`
+	Failures         []models.RepositoryReplica `json:"failures"`
+}
+
+// DisasterRecoveryService continuously replicates repositories to object
+// storage. Every push appends the new objects and refs to the repository's
+// replication log, so a lost repository can be restored up to its last
+// push; a verification job periodically restores a replica and compares
+// its refs to catch broken replicas before they are needed.
+type DisasterRecoveryService interface {
+	// HandlePush is a PushListener that replicates the pushed repository
+	HandlePush(ctx context.Context, event PushEvent)
+	// UseQueue runs the replications HandlePush triggers as queued jobs,
+	// which are retried when they fail and survive restarts
+	UseQueue(queue *jobs.Queue)
+	Replicate(ctx context.Context, repoID uuid.UUID) (*models.RepositoryReplica, error)
+	// CatchUp replicates up to limit repositories that were never
+	// replicated or whose last replication failed, and returns how many
+	CatchUp(ctx context.Context, limit int) (int, error)
+	// Verify restores the replica verified longest ago into a temporary
+	// directory and checks its refs against the replicated snapshot. It
+	// returns nil when there is nothing to verify.
+	Verify(ctx context.Context) (*models.RepositoryReplica, error)
+	Status(ctx context.Context) (*DisasterRecoveryStatus, error)
+}
+
+type disasterRecoveryService struct {
+	db                *gorm.DB
+	repositoryService RepositoryService
+	replicator        *git.Replicator
+	logger            *logrus.Logger
+	holder            string
+	now               func() time.Time
+	queue             *jobs.Queue
+}
+
+// NewDisasterRecoveryService creates a new DisasterRecoveryService; with a
+// nil replicator replication is disabled and pushes are ignored
+func NewDisasterRecoveryService(db *gorm.DB, repositoryService RepositoryService, replicator *git.Replicator, logger *logrus.Logger) DisasterRecoveryService {
+	return &disasterRecoveryService{
+		db:                db,
+		repositoryService: repositoryService,
+		replicator:        replicator,
+		logger:            logger,
+		holder:            jobs.Holder(),
+		now:               time.Now,
+	}
+}
+
+// replicaPrefix is where a repository's replication log is kept. It uses
+// the ID rather than the name, which renames and transfers change.
+func replicaPrefix(repoID uuid.UUID) string {
+	return "repositories/" + repoID.String()
+}
+
+func (s *disasterRecoveryService) HandlePush(ctx context.Context, event PushEvent) {
+	if s.replicator == nil || event.Repository == nil {
+		return
+	}
+	if s.queue == nil {
+		if _, err := s.Replicate(ctx, event.Repository.ID); err != nil {
+			s.logger.WithError(err).WithField("repository_id", event.Repository.ID).Warn("Failed to replicate repository")
+		}
+		return
+	}
+	// Pushes in quick succession share one pending replication
+	payload := map[string]uuid.UUID{"repository_id": event.Repository.ID}
+	if _, err := s.queue.Enqueue(ctx, JobReplicateRepository, payload, jobs.EnqueueOptions{DedupeKey: event.Repository.ID.String()}); err != nil {
+		s.logger.WithError(err).WithField("repository_id", event.Repository.ID).Warn("Failed to enqueue repository replication")
+	}
+}
+
+func (s *disasterRecoveryService) UseQueue(queue *jobs.Queue) {
+	s.queue = queue
+	queue.Register(JobReplicateRepository, jobs.RetryPolicy{MaxAttempts: 10}, func(ctx context.Context, payload json.RawMessage) error {
+		var args struct {
+			RepositoryID uuid.UUID `json:"repository_id"`
+		}
+		if err := json.Unmarshal(payload, &args); err != nil {
+			return jobs.Permanent(err)
+		}
+		_, err := s.Replicate(ctx, args.RepositoryID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// The repository was deleted; its replica is kept
+			return jobs.Permanent(err)
+		}
+		return err
+	})
+}
+
+func (s *disasterRecoveryService) Replicate(ctx context.Context, repoID uuid.UUID) (*models.RepositoryReplica, error) {
+	if s.replicator == nil {
+		return nil, errors.New("disaster recovery replication is not enabled")
+	}
+	db := s.db.WithContext(ctx)
+	var repo models.Repository
+	if err := db.First(&repo, "id = ?", repoID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+	repoPath, err := s.repositoryService.GetRepositoryPath(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+
+	replica, err := s.claim(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+	snapshot, err := s.replicate(ctx, replica, repoPath, s.repositoryFullName(ctx, &repo))
+
+	updates := map[string]interface{}{"locked_by": "", "locked_until": nil, "last_error": ""}
+	if err == nil && snapshot != nil {
+		var data []byte
+		if data, err = json.Marshal(snapshot); err == nil {
+			updates["sequence"] = snapshot.Sequence
+			updates["snapshot"] = string(data)
+			updates["last_replicated_at"] = s.now()
+		}
+	}
+	if err != nil {
+		updates["last_error"] = err.Error()
+	}
+	if saveErr := db.Model(&models.RepositoryReplica{}).Where("repository_id = ?", repoID).Updates(updates).Error; saveErr != nil && err == nil {
+		err = fmt.Errorf("failed to save repository replica: %w", saveErr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var saved models.RepositoryReplica
+	if err := db.First(&saved, "repository_id = ?", repoID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load repository replica: %w", err)
+	}
+	return &saved, nil
+}
+
+// replicate appends to the replication log of a claimed repository. When
+// the log is ahead of the database, as after a replica died between
+// uploading a snapshot and saving it, it continues from the log.
+func (s *disasterRecoveryService) replicate(ctx context.Context, replica *models.RepositoryReplica, repoPath, repository string) (*git.ReplicaSnapshot, error) {
+	prefix := replicaPrefix(replica.RepositoryID)
+	var previous *git.ReplicaSnapshot
+	if replica.Snapshot != "" {
+		previous = &git.ReplicaSnapshot{}
+		if err := json.Unmarshal([]byte(replica.Snapshot), previous); err != nil {
+			return nil, fmt.Errorf("failed to decode replica snapshot: %w", err)
+		}
+	}
+
+	snapshot, err := s.replicator.Replicate(ctx, repoPath, prefix, repository, previous)
+	if !errors.Is(err, git.ErrReplicaConflict) {
+		return snapshot, err
+	}
+	snapshots, err := s.replicator.Snapshots(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 0 {
+		return nil, git.ErrReplicaConflict
+	}
+	s.logger.WithFields(logrus.Fields{
+		"repository_id": replica.RepositoryID,
+		"sequence":      snapshots[len(snapshots)-1].Sequence,
+	}).Info("Resuming replication from the replication log")
+	return s.replicator.Replicate(ctx, repoPath, prefix, repository, snapshots[len(snapshots)-1])
+}
+
+// claim locks the replica of a repository for this process
+func (s *disasterRecoveryService) claim(ctx context.Context, repoID uuid.UUID) (*models.RepositoryReplica, error) {
+	db := s.db.WithContext(ctx)
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RepositoryReplica{RepositoryID: repoID}).Error; err != nil {
+		return nil, fmt.Errorf("failed to create repository replica: %w", err)
+	}
+
+	now := s.now()
+	result := db.Model(&models.RepositoryReplica{}).
+		Where("repository_id = ? AND (locked_until IS NULL OR locked_until < ?)", repoID, now).
+		Updates(map[string]interface{}{"locked_by": s.holder, "locked_until": now.Add(replicaLockTTL)})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to claim repository replica: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrReplicationBusy
+	}
+
+	var replica models.RepositoryReplica
+	if err := db.First(&replica, "repository_id = ?", repoID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load repository replica: %w", err)
+	}
+	return &replica, nil
+}
+
+func (s *disasterRecoveryService) repositoryFullName(ctx context.Context, repo *models.Repository) string {
+	var owner string
+	if repo.OwnerType == models.OwnerTypeOrganization {
+		s.db.WithContext(ctx).Model(&models.Organization{}).Where("id = ?", repo.OwnerID).Pluck("name", &owner)
+	} else {
+		s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", repo.OwnerID).Pluck("username", &owner)
+	}
+	return owner + "/" + repo.Name
+}
+
+func (s *disasterRecoveryService) CatchUp(ctx context.Context, limit int) (int, error) {
+	if s.replicator == nil {
+		return 0, nil
+	}
+	var ids []uuid.UUID
+	if err := s.db.WithContext(ctx).Model(&models.Repository{}).
+		Joins("LEFT JOIN repository_replicas ON repository_replicas.repository_id = repositories.id").
+		Where("repository_replicas.repository_id IS NULL OR repository_replicas.last_error <> ''").
+		Order("repositories.created_at").Limit(limit).
+		Pluck("repositories.id", &ids).Error; err != nil {
+		return 0, fmt.Errorf("failed to list unreplicated repositories: %w", err)
+	}
+
+	caught := 0
+	for _, id := range ids {
+		if err := ctx.Err(); err != nil {
+			return caught, err
+		}
+		if s.queue != nil {
+			payload := map[string]uuid.UUID{"repository_id": id}
+			if _, err := s.queue.Enqueue(ctx, JobReplicateRepository, payload, jobs.EnqueueOptions{DedupeKey: id.String()}); err != nil {
+				return caught, fmt.Errorf("failed to enqueue repository replication: %w", err)
+			}
+		} else if _, err := s.Replicate(ctx, id); err != nil {
+			s.logger.WithError(err).WithField("repository_id", id).Warn("Failed to replicate repository")
+			continue
+		}
+		caught++
+	}
+	return caught, nil
+}
+
+func (s *disasterRecoveryService) Verify(ctx context.Context) (*models.RepositoryReplica, error) {
+	if s.replicator == nil {
+		return nil, nil
+	}
+	db := s.db.WithContext(ctx)
+	var replica models.RepositoryReplica
+	err := db.Where("sequence > 0").Order("last_verified_at IS NOT NULL, last_verified_at").First(&replica).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to pick a replica to verify: %w", err)
+	}
+
+	verifyErr := s.verify(ctx, &replica)
+	now := s.now()
+	updates := map[string]interface{}{"last_verified_at": now, "verified_sequence": replica.Sequence, "verify_error": ""}
+	if verifyErr != nil {
+		updates["verify_error"] = verifyErr.Error()
+	}
+	if err := db.Model(&models.RepositoryReplica{}).Where("repository_id = ?", replica.RepositoryID).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to save replica verification: %w", err)
+	}
+	replica.LastVerifiedAt = &now
+	replica.VerifiedSequence = replica.Sequence
+	replica.VerifyError = updates["verify_error"].(string)
+
+	entry := s.logger.WithFields(logrus.Fields{"repository_id": replica.RepositoryID, "sequence": replica.Sequence})
+	if verifyErr != nil {
+		entry.WithError(verifyErr).Error("Replica verification failed")
+		return &replica, fmt.Errorf("replica of repository %s failed verification: %w", replica.RepositoryID, verifyErr)
+	}
+	entry.Info("Verified replica")
+	return &replica, nil
+}
+
+// verify restores the recorded snapshot of a replica and compares the
+// restored refs with the snapshot's
+func (s *disasterRecoveryService) verify(ctx context.Context, replica *models.RepositoryReplica) error {
+	var snapshot git.ReplicaSnapshot
+	if err := json.Unmarshal([]byte(replica.Snapshot), &snapshot); err != nil {
+		return fmt.Errorf("failed to decode replica snapshot: %w", err)
+	}
+
+	dir, err := os.MkdirTemp("", "hub-dr-verify-*")
+	if err != nil {
+		return fmt.Errorf("failed to create verification directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+	target := filepath.Join(dir, "repository.git")
+	if _, err := s.replicator.Restore(ctx, replicaPrefix(replica.RepositoryID), target, git.RestoreOptions{Sequence: replica.Sequence}); err != nil {
+		return err
+	}
+	head, refs, err := git.ReadRefs(ctx, target)
+	if err != nil {
+		return fmt.Errorf("failed to read restored refs: %w", err)
+	}
+	if head != snapshot.Head {
+		return fmt.Errorf("restored HEAD is %q, expected %q", head, snapshot.Head)
+	}
+	for name, sha := range snapshot.Refs {
+		if refs[name] != sha {
+			return fmt.Errorf("restored %s is %q, expected %s", name, refs[name], sha)
+		}
+	}
+	if len(refs) != len(snapshot.Refs) {
+		return fmt.Errorf("restored %d refs, expected %d", len(refs), len(snapshot.Refs))
+	}
+
+	// Refs that moved since are normally a replication still in flight
+	if repoPath, err := s.repositoryService.GetRepositoryPath(ctx, replica.RepositoryID); err == nil {
+		if _, live, err := git.ReadRefs(ctx, repoPath); err == nil && !git.SameRefs(live, refs) {
+			s.logger.WithField("repository_id", replica.RepositoryID).Warn("Replica is behind the repository")
+		}
+	}
+	return nil
+}
+
+func (s *disasterRecoveryService) Status(ctx context.Context) (*DisasterRecoveryStatus, error) {
+	status := &DisasterRecoveryStatus{Enabled: s.replicator != nil, Failures: []models.RepositoryReplica{}}
+	db := s.db.WithContext(ctx)
+	if err := db.Model(&models.Repository{}).Count(&status.Repositories).Error; err != nil {
+		return nil, fmt.Errorf("failed to count repositories: %w", err)
+	}
+	replicas := func() *gorm.DB {
+		return db.Model(&models.RepositoryReplica{}).
+			Joins("JOIN repositories ON repositories.id = repository_replicas.repository_id AND repositories.deleted_at IS NULL")
+	}
+	if err := replicas().Where("repository_replicas.sequence > 0").Count(&status.Replicated).Error; err != nil {
+		return nil, fmt.Errorf("failed to count replicas: %w", err)
+	}
+	if err := replicas().Where("repository_replicas.last_error <> '' OR repository_replicas.verify_error <> ''").
+		Count(&status.Failing).Error; err != nil {
+		return nil, fmt.Errorf("failed to count failing replicas: %w", err)
+	}
+	if err := replicas().Where("repository_replicas.last_error <> '' OR repository_replicas.verify_error <> ''").
+		Order("repository_replicas.updated_at DESC").Limit(50).Find(&status.Failures).Error; err != nil {
+		return nil, fmt.Errorf("failed to list failing replicas: %w", err)
+	}
+
+	var verified models.RepositoryReplica
+	err := db.Where("last_verified_at IS NOT NULL").Order("last_verified_at DESC").First(&verified).Error
+	if err == nil {
+		status.LastVerification = &verified
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to load last verification: %w", err)
+	}
+	return status, nil
+}
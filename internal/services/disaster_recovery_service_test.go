@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDisasterRecoveryService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.RepositoryReplica{})
+	ctx := context.Background()
+	logger := logrus.New()
+	base := t.TempDir()
+	replicas := t.TempDir()
+	backend, err := storage.NewFilesystemBackend(storage.FilesystemConfig{BasePath: replicas})
+	require.NoError(t, err)
+	repoService := NewRepositoryService(db, git.NewGitService(logger), logger, base)
+	svc := NewDisasterRecoveryService(db, repoService, git.NewReplicator(backend, nil, logger), logger).(*disasterRecoveryService)
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time {
+		now = now.Add(time.Minute)
+		return now
+	}
+
+	alice := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}
+	require.NoError(t, db.Create(alice).Error)
+	app := &models.Repository{ID: uuid.New(), OwnerID: alice.ID, OwnerType: models.OwnerTypeUser, Name: "app",
+		DefaultBranch: "main", Visibility: models.VisibilityPrivate, CreatedAt: now}
+	lib := &models.Repository{ID: uuid.New(), OwnerID: alice.ID, OwnerType: models.OwnerTypeUser, Name: "lib",
+		DefaultBranch: "main", Visibility: models.VisibilityPrivate, CreatedAt: now.Add(time.Hour)}
+	require.NoError(t, db.Create([]*models.Repository{app, lib}).Error)
+	appGit := testutil.NewGitRepo(t, testutil.RepositoryPath(base, app))
+	appGit.Commit("main", "initial", map[string]string{"README.md": "app\n"})
+	libGit := testutil.NewGitRepo(t, testutil.RepositoryPath(base, lib))
+	libGit.Commit("main", "initial", map[string]string{"README.md": "lib\n"})
+
+	// Without a queue pushes are replicated inline
+	svc.HandlePush(ctx, PushEvent{Repository: app})
+	var replica models.RepositoryReplica
+	require.NoError(t, db.First(&replica, "repository_id = ?", app.ID).Error)
+	assert.EqualValues(t, 1, replica.Sequence)
+	assert.Empty(t, replica.LastError)
+	assert.Empty(t, replica.LockedBy, "the lock is released")
+
+	appGit.Commit("main", "second", map[string]string{"main.go": "package main\n"})
+	saved, err := svc.Replicate(ctx, app.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, saved.Sequence)
+
+	// A replica that died before saving its snapshot is resumed from the log
+	require.NoError(t, db.Model(&models.RepositoryReplica{}).Where("repository_id = ?", app.ID).
+		Updates(map[string]interface{}{"sequence": replica.Sequence, "snapshot": replica.Snapshot}).Error)
+	appGit.Commit("main", "third", map[string]string{"util.go": "package main\n"})
+	saved, err = svc.Replicate(ctx, app.ID)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, saved.Sequence)
+
+	locked := now.Add(time.Hour)
+	require.NoError(t, db.Model(&models.RepositoryReplica{}).Where("repository_id = ?", app.ID).Update("locked_until", locked).Error)
+	_, err = svc.Replicate(ctx, app.ID)
+	assert.ErrorIs(t, err, ErrReplicationBusy)
+	require.NoError(t, db.Model(&models.RepositoryReplica{}).Where("repository_id = ?", app.ID).Update("locked_until", nil).Error)
+
+	caught, err := svc.CatchUp(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, caught, "only lib was never replicated")
+
+	verified, err := svc.Verify(ctx)
+	require.NoError(t, err)
+	verifiedFirst := verified.RepositoryID
+	verified, err = svc.Verify(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, verifiedFirst, verified.RepositoryID, "the replica verified longest ago goes next")
+
+	status, err := svc.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.EqualValues(t, 2, status.Repositories)
+	assert.EqualValues(t, 2, status.Replicated)
+	assert.Zero(t, status.Failing)
+	require.NotNil(t, status.LastVerification)
+	assert.Equal(t, verified.RepositoryID, status.LastVerification.RepositoryID)
+
+	// A replica missing a pack fails verification
+	require.NoError(t, os.Remove(filepath.Join(replicas, replicaPrefix(verifiedFirst), "packs", "000000000001.pack")))
+	verified, err = svc.Verify(ctx)
+	require.Error(t, err)
+	assert.Equal(t, verifiedFirst, verified.RepositoryID)
+	assert.NotEmpty(t, verified.VerifyError)
+	status, err = svc.Status(ctx)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, status.Failing)
+	require.Len(t, status.Failures, 1)
+	assert.Equal(t, verifiedFirst, status.Failures[0].RepositoryID)
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/a5c-ai/hub/internal/audit"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrExternalCollaboratorRestricted is returned when an external
+	// collaborator would own a repository or join an organization
+	ErrExternalCollaboratorRestricted = errors.New("external collaborators can only work in repositories shared with them")
+	// ErrExternalConversionConflict is returned when an account still has
+	// memberships or repositories an external collaborator cannot have
+	ErrExternalConversionConflict = errors.New("account cannot become an external collaborator")
+)
+
+// SharedRepository is a repository shared directly with an external collaborator
+type SharedRepository struct {
+	ID         uuid.UUID         `json:"id"`
+	FullName   string            `json:"full_name"`
+	Permission models.Permission `json:"permission"`
+}
+
+// ExternalCollaborator is an external account and the repositories shared with it
+type ExternalCollaborator struct {
+	*models.User
+	Repositories []SharedRepository `json:"repositories"`
+}
+
+// ExternalCollaboratorService lists external collaborator accounts and
+// converts accounts between external and full. External collaborators
+// are restricted where access is decided: permission checks, search,
+// repository ownership and organization membership.
+type ExternalCollaboratorService interface {
+	List(ctx context.Context) ([]*ExternalCollaborator, error)
+	// ConvertToFull lifts the restrictions on an external collaborator; the
+	// repositories shared with them stay shared
+	ConvertToFull(ctx context.Context, userID, actorID uuid.UUID) (*models.User, error)
+	// ConvertToExternal restricts an account that is not a site admin,
+	// belongs to no organization and owns no repositories
+	ConvertToExternal(ctx context.Context, userID, actorID uuid.UUID) (*models.User, error)
+}
+
+type externalCollaboratorService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+func NewExternalCollaboratorService(db *gorm.DB, logger *logrus.Logger) ExternalCollaboratorService {
+	return &externalCollaboratorService{db: db, logger: logger}
+}
+
+// isExternalUser reports whether userID is an external collaborator
+func isExternalUser(db *gorm.DB, userID uuid.UUID) (bool, error) {
+	if userID == uuid.Nil {
+		return false, nil
+	}
+	var count int64
+	if err := db.Model(&models.User{}).Where("id = ? AND is_external = ?", userID, true).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check account type: %w", err)
+	}
+	return count > 0, nil
+}
+
+// checkExternalOwner rejects repositories that would be owned by an
+// external collaborator
+func checkExternalOwner(ctx context.Context, db *gorm.DB, ownerID uuid.UUID, ownerType models.OwnerType) error {
+	if ownerType != models.OwnerTypeUser {
+		return nil
+	}
+	external, err := isExternalUser(db.WithContext(ctx), ownerID)
+	if err != nil {
+		return err
+	}
+	if external {
+		return ErrExternalCollaboratorRestricted
+	}
+	return nil
+}
+
+func (s *externalCollaboratorService) List(ctx context.Context) ([]*ExternalCollaborator, error) {
+	db := s.db.WithContext(ctx)
+	var users []*models.User
+	if err := db.Where("is_external = ?", true).Order("username").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to list external collaborators: %w", err)
+	}
+	if len(users) == 0 {
+		return []*ExternalCollaborator{}, nil
+	}
+
+	ids := make([]uuid.UUID, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	var grants []models.RepositoryPermission
+	if err := db.Where("subject_type = ? AND subject_id IN ?", models.SubjectTypeUser, ids).Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("failed to list shared repositories: %w", err)
+	}
+	shared := map[uuid.UUID][]SharedRepository{}
+	for _, grant := range grants {
+		var repo models.Repository
+		if err := db.First(&repo, "id = ?", grant.RepositoryID).Error; err != nil {
+			continue
+		}
+		shared[grant.SubjectID] = append(shared[grant.SubjectID], SharedRepository{
+			ID: repo.ID, FullName: s.repositoryFullName(db, &repo), Permission: grant.Permission,
+		})
+	}
+
+	collaborators := make([]*ExternalCollaborator, len(users))
+	for i, user := range users {
+		repos := shared[user.ID]
+		if repos == nil {
+			repos = []SharedRepository{}
+		}
+		collaborators[i] = &ExternalCollaborator{User: user, Repositories: repos}
+	}
+	return collaborators, nil
+}
+
+func (s *externalCollaboratorService) repositoryFullName(db *gorm.DB, repo *models.Repository) string {
+	var owner string
+	if repo.OwnerType == models.OwnerTypeOrganization {
+		db.Model(&models.Organization{}).Where("id = ?", repo.OwnerID).Pluck("name", &owner)
+	} else {
+		db.Model(&models.User{}).Where("id = ?", repo.OwnerID).Pluck("username", &owner)
+	}
+	return owner + "/" + repo.Name
+}
+
+func (s *externalCollaboratorService) ConvertToFull(ctx context.Context, userID, actorID uuid.UUID) (*models.User, error) {
+	return s.convert(ctx, userID, actorID, false)
+}
+
+func (s *externalCollaboratorService) ConvertToExternal(ctx context.Context, userID, actorID uuid.UUID) (*models.User, error) {
+	return s.convert(ctx, userID, actorID, true)
+}
+
+func (s *externalCollaboratorService) convert(ctx context.Context, userID, actorID uuid.UUID, external bool) (*models.User, error) {
+	db := s.db.WithContext(ctx)
+	var user models.User
+	if err := db.First(&user, "id = ?", userID).Error; err != nil {
+		return nil, err
+	}
+	if user.IsExternal == external {
+		return &user, nil
+	}
+
+	if external {
+		if user.IsAdmin {
+			return nil, fmt.Errorf("%w: site admins cannot be restricted", ErrExternalConversionConflict)
+		}
+		var memberships, repositories int64
+		if err := db.Model(&models.OrganizationMember{}).Where("user_id = ?", userID).Count(&memberships).Error; err != nil {
+			return nil, fmt.Errorf("failed to count organization memberships: %w", err)
+		}
+		if memberships > 0 {
+			return nil, fmt.Errorf("%w: remove the account from its %d organizations first", ErrExternalConversionConflict, memberships)
+		}
+		if err := db.Model(&models.Repository{}).Where("owner_type = ? AND owner_id = ?", models.OwnerTypeUser, userID).Count(&repositories).Error; err != nil {
+			return nil, fmt.Errorf("failed to count owned repositories: %w", err)
+		}
+		if repositories > 0 {
+			return nil, fmt.Errorf("%w: transfer or delete the account's %d repositories first", ErrExternalConversionConflict, repositories)
+		}
+	}
+
+	if err := db.Model(&user).Update("is_external", external).Error; err != nil {
+		return nil, fmt.Errorf("failed to change account type: %w", err)
+	}
+	user.IsExternal = external
+
+	accountType := "full"
+	if external {
+		accountType = "external"
+	}
+	audit.Record(ctx, audit.Event{
+		Action:     audit.ActionAccountConvert,
+		ActorID:    &actorID,
+		TargetType: "user",
+		TargetID:   user.ID.String(),
+		TargetName: user.Username,
+		Metadata:   map[string]interface{}{"account_type": accountType},
+	})
+	s.logger.WithFields(logrus.Fields{"user_id": user.ID, "account_type": accountType}).Info("Account type changed")
+	return &user, nil
+}
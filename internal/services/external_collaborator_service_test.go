@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalCollaborators(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{}, &models.Team{}, &models.TeamMember{},
+		&models.Repository{}, &models.RepositoryPermission{})
+
+	ctx := context.Background()
+	svc := NewExternalCollaboratorService(db, logrus.New())
+	permissions := NewPermissionService(db, nil)
+
+	admin := &models.User{ID: uuid.New(), Username: "root", Email: "root@example.com", IsAdmin: true}
+	owner := &models.User{ID: uuid.New(), Username: "olivia", Email: "olivia@example.com"}
+	contractor := &models.User{ID: uuid.New(), Username: "casey", Email: "casey@example.com", IsExternal: true}
+	require.NoError(t, db.Create([]*models.User{admin, owner, contractor}).Error)
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: owner.ID, Role: models.OrgRoleOwner}).Error)
+
+	public := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "site", Visibility: models.VisibilityPublic}
+	shared := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "api", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create([]*models.Repository{public, shared}).Error)
+	require.NoError(t, db.Create(&models.RepositoryPermission{ID: uuid.New(), RepositoryID: shared.ID, SubjectID: contractor.ID,
+		SubjectType: models.SubjectTypeUser, Permission: models.PermissionWrite}).Error)
+
+	// Only shared repositories are visible, even public ones are not
+	perm, err := permissions.CalculateUserPermission(ctx, contractor.ID, shared.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PermissionWrite, perm)
+	perm, err = permissions.CalculateUserPermission(ctx, contractor.ID, public.ID)
+	require.NoError(t, err)
+	assert.Empty(t, perm)
+
+	var readable []models.Repository
+	require.NoError(t, readableRepositories(db, db.Model(&models.Repository{}), &contractor.ID).Find(&readable).Error)
+	require.Len(t, readable, 1)
+	assert.Equal(t, "api", readable[0].Name)
+
+	search := NewSearchService(db, nil, logrus.New())
+	users, err := search.searchUsers(SearchFilter{Query: "casey", PerPage: 10}, 0)
+	require.NoError(t, err)
+	assert.Empty(t, users, "external collaborators are not searchable")
+
+	assert.ErrorIs(t, checkExternalOwner(ctx, db, contractor.ID, models.OwnerTypeUser), ErrExternalCollaboratorRestricted)
+	assert.NoError(t, checkExternalOwner(ctx, db, owner.ID, models.OwnerTypeUser))
+	_, err = NewMembershipService(db, nil).AddMember(ctx, "acme", "casey", models.OrgRoleMember)
+	assert.ErrorIs(t, err, ErrExternalCollaboratorRestricted)
+
+	collaborators, err := svc.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, collaborators, 1)
+	assert.Equal(t, []SharedRepository{{ID: shared.ID, FullName: "acme/api", Permission: models.PermissionWrite}}, collaborators[0].Repositories)
+
+	_, err = svc.ConvertToExternal(ctx, owner.ID, admin.ID)
+	assert.ErrorIs(t, err, ErrExternalConversionConflict, "organization members cannot be restricted")
+	_, err = svc.ConvertToExternal(ctx, admin.ID, admin.ID)
+	assert.ErrorIs(t, err, ErrExternalConversionConflict)
+
+	converted, err := svc.ConvertToFull(ctx, contractor.ID, admin.ID)
+	require.NoError(t, err)
+	assert.False(t, converted.IsExternal)
+	perm, err = permissions.CalculateUserPermission(ctx, contractor.ID, public.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PermissionRead, perm)
+
+	converted, err = svc.ConvertToExternal(ctx, contractor.ID, admin.ID)
+	require.NoError(t, err)
+	assert.True(t, converted.IsExternal)
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/features"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrFeaturePreviewNotFound is returned for previews unknown or not offered
+// on this instance
+var ErrFeaturePreviewNotFound = errors.New("feature preview not found")
+
+// FeaturePreviewStatus is a preview offered on the instance and whether a
+// repository switched it on
+type FeaturePreviewStatus struct {
+	features.Preview
+	Enabled     bool       `json:"enabled"`
+	EnabledAt   *time.Time `json:"enabled_at,omitempty"`
+	EnabledByID *uuid.UUID `json:"enabled_by_id,omitempty"`
+}
+
+// FeaturePreviewAdoption counts the repositories using a preview
+type FeaturePreviewAdoption struct {
+	features.Preview
+	Offered      bool  `json:"offered"`
+	Repositories int64 `json:"repositories"`
+}
+
+// FeaturePreviewService manages the beta features repository admins switch
+// on for their repositories, within the previews the instance offers. It is
+// the features.Checker behind features.Enabled.
+type FeaturePreviewService interface {
+	List(ctx context.Context, repoID uuid.UUID) ([]*FeaturePreviewStatus, error)
+	Set(ctx context.Context, repoID uuid.UUID, feature string, enabled bool, actorID *uuid.UUID) (*FeaturePreviewStatus, error)
+	Enabled(ctx context.Context, repoID uuid.UUID, feature string) bool
+	// Adoption counts the repositories that switched each preview on
+	Adoption(ctx context.Context) ([]*FeaturePreviewAdoption, error)
+}
+
+type featurePreviewService struct {
+	db        *gorm.DB
+	analytics AnalyticsService
+	cfg       config.FeaturePreviews
+	logger    *logrus.Logger
+}
+
+// NewFeaturePreviewService creates a new FeaturePreviewService. Toggles are
+// recorded as analytics events when analytics is set.
+func NewFeaturePreviewService(db *gorm.DB, analytics AnalyticsService, cfg config.FeaturePreviews, logger *logrus.Logger) FeaturePreviewService {
+	return &featurePreviewService{db: db, analytics: analytics, cfg: cfg, logger: logger}
+}
+
+// offered reports whether the instance allowlist offers feature
+func (s *featurePreviewService) offered(feature string) bool {
+	if !s.cfg.Enabled {
+		return false
+	}
+	if _, ok := features.Lookup(feature); !ok {
+		return false
+	}
+	return len(s.cfg.Allowed) == 0 || slices.Contains(s.cfg.Allowed, feature)
+}
+
+func (s *featurePreviewService) List(ctx context.Context, repoID uuid.UUID) ([]*FeaturePreviewStatus, error) {
+	var rows []models.RepositoryFeaturePreview
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repoID).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list feature previews: %w", err)
+	}
+
+	statuses := []*FeaturePreviewStatus{}
+	for _, preview := range features.Previews {
+		if !s.offered(preview.Key) {
+			continue
+		}
+		status := &FeaturePreviewStatus{Preview: preview}
+		for _, row := range rows {
+			if row.Feature == preview.Key {
+				createdAt := row.CreatedAt
+				status.Enabled = true
+				status.EnabledAt = &createdAt
+				status.EnabledByID = row.EnabledByID
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (s *featurePreviewService) Set(ctx context.Context, repoID uuid.UUID, feature string, enabled bool, actorID *uuid.UUID) (*FeaturePreviewStatus, error) {
+	if !s.offered(feature) {
+		return nil, ErrFeaturePreviewNotFound
+	}
+
+	var changed int64
+	if enabled {
+		result := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RepositoryFeaturePreview{
+			ID:           uuid.New(),
+			RepositoryID: repoID,
+			Feature:      feature,
+			EnabledByID:  actorID,
+		})
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to enable feature preview: %w", result.Error)
+		}
+		changed = result.RowsAffected
+	} else {
+		result := s.db.WithContext(ctx).Where("repository_id = ? AND feature = ?", repoID, feature).
+			Delete(&models.RepositoryFeaturePreview{})
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to disable feature preview: %w", result.Error)
+		}
+		changed = result.RowsAffected
+	}
+	if changed > 0 {
+		s.recordToggle(ctx, repoID, feature, enabled, actorID)
+	}
+
+	statuses, err := s.List(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		if status.Key == feature {
+			return status, nil
+		}
+	}
+	return nil, ErrFeaturePreviewNotFound
+}
+
+// recordToggle tracks preview adoption in analytics
+func (s *featurePreviewService) recordToggle(ctx context.Context, repoID uuid.UUID, feature string, enabled bool, actorID *uuid.UUID) {
+	s.logger.WithFields(logrus.Fields{
+		"repository_id": repoID,
+		"feature":       feature,
+		"enabled":       enabled,
+	}).Info("Toggled feature preview")
+	if s.analytics == nil {
+		return
+	}
+
+	eventType := models.EventFeaturePreviewDisabled
+	if enabled {
+		eventType = models.EventFeaturePreviewEnabled
+	}
+	actorType := "system"
+	if actorID != nil {
+		actorType = "user"
+	}
+	metadata, _ := json.Marshal(map[string]string{"feature": feature})
+	event := &models.AnalyticsEvent{
+		ID:           uuid.New(),
+		EventType:    eventType,
+		ActorID:      actorID,
+		ActorType:    actorType,
+		TargetType:   "repository",
+		TargetID:     &repoID,
+		RepositoryID: &repoID,
+		Metadata:     string(metadata),
+		Status:       "success",
+	}
+	if err := s.analytics.RecordEvent(ctx, event); err != nil {
+		s.logger.WithError(err).WithField("repository_id", repoID).Warn("Failed to record feature preview toggle")
+	}
+}
+
+func (s *featurePreviewService) Enabled(ctx context.Context, repoID uuid.UUID, feature string) bool {
+	if !s.offered(feature) {
+		return false
+	}
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.RepositoryFeaturePreview{}).
+		Where("repository_id = ? AND feature = ?", repoID, feature).Count(&count).Error
+	if err != nil {
+		// Fall back to the stable code path
+		s.logger.WithError(err).WithField("feature", feature).Warn("Failed to check feature preview")
+		return false
+	}
+	return count > 0
+}
+
+func (s *featurePreviewService) Adoption(ctx context.Context) ([]*FeaturePreviewAdoption, error) {
+	var counts []struct {
+		Feature string
+		Count   int64
+	}
+	err := s.db.WithContext(ctx).Model(&models.RepositoryFeaturePreview{}).
+		Select("feature, COUNT(*) AS count").Group("feature").Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count feature preview adoption: %w", err)
+	}
+
+	adoption := make([]*FeaturePreviewAdoption, 0, len(features.Previews))
+	for _, preview := range features.Previews {
+		entry := &FeaturePreviewAdoption{Preview: preview, Offered: s.offered(preview.Key)}
+		for _, count := range counts {
+			if count.Feature == preview.Key {
+				entry.Repositories = count.Count
+			}
+		}
+		adoption = append(adoption, entry)
+	}
+	return adoption, nil
+}
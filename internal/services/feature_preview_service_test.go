@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/features"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingAnalytics keeps the events recorded through it
+type recordingAnalytics struct {
+	AnalyticsService
+	events []*models.AnalyticsEvent
+}
+
+func (a *recordingAnalytics) RecordEvent(ctx context.Context, event *models.AnalyticsEvent) error {
+	a.events = append(a.events, event)
+	return nil
+}
+
+func TestFeaturePreviewService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.RepositoryFeaturePreview{})
+	ctx := context.Background()
+	analytics := &recordingAnalytics{}
+	cfg := config.FeaturePreviews{Enabled: true, Allowed: []string{features.MergeQueue}}
+	svc := NewFeaturePreviewService(db, analytics, cfg, logrus.New())
+	repoID, otherRepoID, actorID := uuid.New(), uuid.New(), uuid.New()
+
+	// Only allowlisted previews are offered
+	previews, err := svc.List(ctx, repoID)
+	require.NoError(t, err)
+	require.Len(t, previews, 1)
+	assert.Equal(t, features.MergeQueue, previews[0].Key)
+	assert.False(t, previews[0].Enabled)
+	_, err = svc.Set(ctx, repoID, features.DiffEngineV2, true, &actorID)
+	assert.ErrorIs(t, err, ErrFeaturePreviewNotFound)
+	_, err = svc.Set(ctx, repoID, "unknown", true, &actorID)
+	assert.ErrorIs(t, err, ErrFeaturePreviewNotFound)
+
+	status, err := svc.Set(ctx, repoID, features.MergeQueue, true, &actorID)
+	require.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.Equal(t, &actorID, status.EnabledByID)
+	// Enabling twice records one toggle
+	_, err = svc.Set(ctx, repoID, features.MergeQueue, true, &actorID)
+	require.NoError(t, err)
+	require.Len(t, analytics.events, 1)
+	assert.Equal(t, models.EventFeaturePreviewEnabled, analytics.events[0].EventType)
+	assert.Equal(t, repoID, *analytics.events[0].RepositoryID)
+
+	features.SetDefaultChecker(svc)
+	t.Cleanup(func() { features.SetDefaultChecker(nil) })
+	assert.True(t, features.Enabled(ctx, repoID, features.MergeQueue))
+	assert.False(t, features.Enabled(ctx, otherRepoID, features.MergeQueue))
+	assert.False(t, features.Enabled(ctx, repoID, features.DiffEngineV2))
+
+	adoption, err := svc.Adoption(ctx)
+	require.NoError(t, err)
+	require.Len(t, adoption, len(features.Previews))
+	for _, entry := range adoption {
+		switch entry.Key {
+		case features.MergeQueue:
+			assert.True(t, entry.Offered)
+			assert.EqualValues(t, 1, entry.Repositories)
+		case features.DiffEngineV2:
+			assert.False(t, entry.Offered)
+			assert.Zero(t, entry.Repositories)
+		}
+	}
+
+	// Dropping a preview from the allowlist switches it off everywhere
+	restricted := NewFeaturePreviewService(db, analytics, config.FeaturePreviews{Enabled: true, Allowed: []string{features.DiffEngineV2}}, logrus.New())
+	assert.False(t, restricted.Enabled(ctx, repoID, features.MergeQueue))
+
+	status, err = svc.Set(ctx, repoID, features.MergeQueue, false, &actorID)
+	require.NoError(t, err)
+	assert.False(t, status.Enabled)
+	assert.False(t, svc.Enabled(ctx, repoID, features.MergeQueue))
+	require.Len(t, analytics.events, 2)
+	assert.Equal(t, models.EventFeaturePreviewDisabled, analytics.events[1].EventType)
+}
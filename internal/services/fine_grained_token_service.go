@@ -0,0 +1,492 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Fine-grained token lifetimes, in days
+const (
+	defaultFineGrainedTokenLifetime = 30
+	maxFineGrainedTokenLifetime     = 366
+)
+
+var (
+	ErrFineGrainedTokenNotFound     = errors.New("token not found")
+	ErrInvalidFineGrainedToken      = errors.New("invalid token request")
+	ErrFineGrainedTokenUnauthorized = errors.New("token is invalid, expired or not approved")
+	ErrTokenReviewForbidden         = errors.New("only organization owners and admins can review token requests")
+)
+
+// fineGrainedTokenScopes lists the permission categories a token can be granted
+var fineGrainedTokenScopes = map[string]bool{
+	models.TokenScopeMetadata:       true,
+	models.TokenScopeContents:       true,
+	models.TokenScopeIssues:         true,
+	models.TokenScopePullRequests:   true,
+	models.TokenScopeStatuses:       true,
+	models.TokenScopeSecurityEvents: true,
+	models.TokenScopeWebhooks:       true,
+	models.TokenScopeAdministration: true,
+}
+
+// CreateFineGrainedTokenRequest describes a new token. Repository tokens
+// set Repositories and Permissions; ResourceOwner is the user or
+// organization whose repositories the token reaches and defaults to the
+// requesting user. Scoped tokens set Scopes instead.
+type CreateFineGrainedTokenRequest struct {
+	Name          string                            `json:"name" binding:"required"`
+	Description   string                            `json:"description"`
+	ResourceOwner string                            `json:"resource_owner"`
+	Repositories  []string                          `json:"repositories"`
+	Permissions   map[string]models.TokenPermission `json:"permissions"`
+	Scopes        []string                          `json:"scopes"`
+	ExpiresInDays int                               `json:"expires_in_days"`
+}
+
+// FineGrainedTokenIntrospection describes a token presented for inspection.
+// Only Active is set for tokens the caller may not inspect.
+type FineGrainedTokenIntrospection struct {
+	Active        bool                     `json:"active"`
+	Token         *models.FineGrainedToken `json:"token,omitempty"`
+	Username      string                   `json:"username,omitempty"`
+	ResourceOwner string                   `json:"resource_owner,omitempty"`
+	Repositories  []string                 `json:"repositories,omitempty"`
+}
+
+// FineGrainedTokenService issues and authenticates personal access tokens,
+// either limited to selected repositories and permission categories or
+// scoped to every repository and organization their user can reach. Tokens
+// for organization repositories need approval by an organization owner or
+// admin unless one of them created the token.
+type FineGrainedTokenService interface {
+	// Create issues a token and returns its secret, which is not stored
+	Create(ctx context.Context, userID uuid.UUID, req CreateFineGrainedTokenRequest) (*models.FineGrainedToken, string, error)
+	List(ctx context.Context, userID uuid.UUID) ([]*models.FineGrainedToken, error)
+	Get(ctx context.Context, userID, tokenID uuid.UUID) (*models.FineGrainedToken, error)
+	Revoke(ctx context.Context, userID, tokenID uuid.UUID) error
+
+	// Authenticate resolves a presented token and records its use
+	Authenticate(ctx context.Context, token, ip string) (*models.FineGrainedToken, *models.User, error)
+	// Introspect describes a token to its owner or a site admin
+	Introspect(ctx context.Context, token string, actorID uuid.UUID, isAdmin bool) (*FineGrainedTokenIntrospection, error)
+
+	// ListRequests returns the tokens awaiting approval by the organization
+	ListRequests(ctx context.Context, orgName string, actorID uuid.UUID) ([]*models.FineGrainedToken, error)
+	Review(ctx context.Context, orgName string, actorID, tokenID uuid.UUID, approve bool, reason string) (*models.FineGrainedToken, error)
+}
+
+type fineGrainedTokenService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+	now    func() time.Time
+}
+
+// NewFineGrainedTokenService creates a new FineGrainedTokenService
+func NewFineGrainedTokenService(db *gorm.DB, logger *logrus.Logger) FineGrainedTokenService {
+	return &fineGrainedTokenService{db: db, logger: logger, now: time.Now}
+}
+
+func hashFineGrainedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// resourceOwner resolves a user or organization name
+func (s *fineGrainedTokenService) resourceOwner(ctx context.Context, name string) (uuid.UUID, models.OwnerType, error) {
+	var user models.User
+	err := s.db.WithContext(ctx).Where("username = ?", name).First(&user).Error
+	if err == nil {
+		return user.ID, models.OwnerTypeUser, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, "", err
+	}
+
+	var org models.Organization
+	err = s.db.WithContext(ctx).Where("name = ?", name).First(&org).Error
+	if err == nil {
+		return org.ID, models.OwnerTypeOrganization, nil
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, "", fmt.Errorf("%w: resource owner %q not found", ErrInvalidFineGrainedToken, name)
+	}
+	return uuid.Nil, "", err
+}
+
+func (s *fineGrainedTokenService) orgRole(ctx context.Context, orgID, userID uuid.UUID) (models.OrganizationRole, error) {
+	var member models.OrganizationMember
+	err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return member.Role, err
+}
+
+func validateTokenPermissions(permissions map[string]models.TokenPermission) error {
+	for scope, level := range permissions {
+		if !fineGrainedTokenScopes[scope] {
+			return fmt.Errorf("%w: unknown permission %q", ErrInvalidFineGrainedToken, scope)
+		}
+		if level != models.TokenPermissionRead && level != models.TokenPermissionWrite {
+			return fmt.Errorf("%w: %s must be read or write", ErrInvalidFineGrainedToken, scope)
+		}
+		if scope == models.TokenScopeMetadata && level != models.TokenPermissionRead {
+			return fmt.Errorf("%w: metadata is read-only", ErrInvalidFineGrainedToken)
+		}
+	}
+	return nil
+}
+
+// validateTokenScopes checks the scopes of a scoped token and drops
+// duplicates
+func validateTokenScopes(requested []string) ([]string, error) {
+	var scopes []string
+	for _, scope := range requested {
+		if !slices.Contains(models.PATScopes, scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidFineGrainedToken, scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+func (s *fineGrainedTokenService) Create(ctx context.Context, userID uuid.UUID, req CreateFineGrainedTokenRequest) (*models.FineGrainedToken, string, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).First(&user, "id = ?", userID).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to get user: %w", err)
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidFineGrainedToken)
+	}
+	if len(req.Scopes) > 0 {
+		if req.ResourceOwner != "" || len(req.Repositories) > 0 || len(req.Permissions) > 0 {
+			return nil, "", fmt.Errorf("%w: scoped tokens cannot select repositories or permissions", ErrInvalidFineGrainedToken)
+		}
+	} else if len(req.Repositories) == 0 {
+		return nil, "", fmt.Errorf("%w: select at least one repository or scope", ErrInvalidFineGrainedToken)
+	}
+	scopes, err := validateTokenScopes(req.Scopes)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := validateTokenPermissions(req.Permissions); err != nil {
+		return nil, "", err
+	}
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultFineGrainedTokenLifetime
+	}
+	if days < 1 || days > maxFineGrainedTokenLifetime {
+		return nil, "", fmt.Errorf("%w: expiration must be between 1 and %d days", ErrInvalidFineGrainedToken, maxFineGrainedTokenLifetime)
+	}
+
+	ownerName := req.ResourceOwner
+	if ownerName == "" {
+		ownerName = user.Username
+	}
+	ownerID, ownerType, err := s.resourceOwner(ctx, ownerName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	status := models.FineGrainedTokenActive
+	switch ownerType {
+	case models.OwnerTypeUser:
+		if ownerID != userID {
+			return nil, "", fmt.Errorf("%w: tokens can only target your own repositories or an organization", ErrInvalidFineGrainedToken)
+		}
+	case models.OwnerTypeOrganization:
+		role, err := s.orgRole(ctx, ownerID, userID)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get organization membership: %w", err)
+		}
+		if role == "" {
+			return nil, "", fmt.Errorf("%w: you are not a member of %s", ErrInvalidFineGrainedToken, ownerName)
+		}
+		if role != models.OrgRoleOwner && role != models.OrgRoleAdmin {
+			status = models.FineGrainedTokenPendingApproval
+		}
+	}
+
+	repoIDs := make([]uuid.UUID, 0, len(req.Repositories))
+	for _, repoName := range req.Repositories {
+		var repo models.Repository
+		err := s.db.WithContext(ctx).Where("owner_id = ? AND owner_type = ? AND name = ?", ownerID, ownerType, repoName).First(&repo).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, "", fmt.Errorf("%w: repository %s/%s not found", ErrInvalidFineGrainedToken, ownerName, repoName)
+			}
+			return nil, "", fmt.Errorf("failed to get repository: %w", err)
+		}
+		repoIDs = append(repoIDs, repo.ID)
+	}
+
+	secret, err := generateSecureToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	secret = models.FineGrainedTokenPrefix + secret
+
+	expiresAt := s.now().AddDate(0, 0, days)
+	token := &models.FineGrainedToken{
+		ID:                uuid.New(),
+		UserID:            userID,
+		Name:              name,
+		Description:       req.Description,
+		TokenHash:         hashFineGrainedToken(secret),
+		TokenLastEight:    secret[len(secret)-8:],
+		ResourceOwnerID:   ownerID,
+		ResourceOwnerType: ownerType,
+		RepositoryIDs:     repoIDs,
+		Permissions:       req.Permissions,
+		Scopes:            scopes,
+		Status:            status,
+		ExpiresAt:         &expiresAt,
+	}
+	if err := s.db.WithContext(ctx).Create(token).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create token: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"token_id":       token.ID,
+		"user_id":        userID,
+		"resource_owner": ownerName,
+		"scopes":         scopes,
+		"status":         status,
+	}).Info("Created fine-grained token")
+	return token, secret, nil
+}
+
+func (s *fineGrainedTokenService) List(ctx context.Context, userID uuid.UUID) ([]*models.FineGrainedToken, error) {
+	var tokens []*models.FineGrainedToken
+	err := s.db.WithContext(ctx).Where("user_id = ? AND status <> ?", userID, models.FineGrainedTokenRevoked).
+		Order("created_at DESC").Find(&tokens).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *fineGrainedTokenService) Get(ctx context.Context, userID, tokenID uuid.UUID) (*models.FineGrainedToken, error) {
+	var token models.FineGrainedToken
+	err := s.db.WithContext(ctx).Where("id = ? AND user_id = ? AND status <> ?", tokenID, userID, models.FineGrainedTokenRevoked).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFineGrainedTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	return &token, nil
+}
+
+func (s *fineGrainedTokenService) Revoke(ctx context.Context, userID, tokenID uuid.UUID) error {
+	token, err := s.Get(ctx, userID, tokenID)
+	if err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Model(token).Update("status", models.FineGrainedTokenRevoked).Error; err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// lookup finds a token by its secret regardless of status
+func (s *fineGrainedTokenService) lookup(ctx context.Context, secret string) (*models.FineGrainedToken, error) {
+	if !strings.HasPrefix(secret, models.FineGrainedTokenPrefix) {
+		return nil, ErrFineGrainedTokenNotFound
+	}
+	var token models.FineGrainedToken
+	err := s.db.WithContext(ctx).Where("token_hash = ?", hashFineGrainedToken(secret)).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFineGrainedTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get token: %w", err)
+	}
+	return &token, nil
+}
+
+// usable reports whether token may authenticate now. Organization tokens
+// stop working when their creator leaves the organization.
+func (s *fineGrainedTokenService) usable(ctx context.Context, token *models.FineGrainedToken) (bool, error) {
+	if token.Status != models.FineGrainedTokenActive {
+		return false, nil
+	}
+	if token.ExpiresAt != nil && !s.now().Before(*token.ExpiresAt) {
+		return false, nil
+	}
+	if token.ResourceOwnerType == models.OwnerTypeOrganization {
+		role, err := s.orgRole(ctx, token.ResourceOwnerID, token.UserID)
+		if err != nil {
+			return false, err
+		}
+		return role != "", nil
+	}
+	return true, nil
+}
+
+func (s *fineGrainedTokenService) Authenticate(ctx context.Context, secret, ip string) (*models.FineGrainedToken, *models.User, error) {
+	token, err := s.lookup(ctx, secret)
+	if err != nil {
+		if errors.Is(err, ErrFineGrainedTokenNotFound) {
+			return nil, nil, ErrFineGrainedTokenUnauthorized
+		}
+		return nil, nil, err
+	}
+	ok, err := s.usable(ctx, token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to check token: %w", err)
+	}
+	if !ok {
+		return nil, nil, ErrFineGrainedTokenUnauthorized
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).First(&user, "id = ?", token.UserID).Error; err != nil || !user.IsActive {
+		return nil, nil, ErrFineGrainedTokenUnauthorized
+	}
+
+	now := s.now()
+	err = s.db.WithContext(ctx).Model(token).UpdateColumns(map[string]interface{}{
+		"last_used_at": now,
+		"last_used_ip": ip,
+		"usage_count":  gorm.Expr("usage_count + 1"),
+	}).Error
+	if err != nil {
+		// Usage tracking must not fail the request
+		s.logger.WithError(err).WithField("token_id", token.ID).Warn("Failed to record token usage")
+	}
+	token.LastUsedAt = &now
+	token.LastUsedIP = ip
+	token.UsageCount++
+	return token, &user, nil
+}
+
+func (s *fineGrainedTokenService) Introspect(ctx context.Context, secret string, actorID uuid.UUID, isAdmin bool) (*FineGrainedTokenIntrospection, error) {
+	token, err := s.lookup(ctx, secret)
+	if err != nil {
+		if errors.Is(err, ErrFineGrainedTokenNotFound) {
+			return &FineGrainedTokenIntrospection{}, nil
+		}
+		return nil, err
+	}
+	active, err := s.usable(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check token: %w", err)
+	}
+	// Tokens of other users are not disclosed, only whether they work
+	if token.UserID != actorID && !isAdmin {
+		return &FineGrainedTokenIntrospection{Active: active}, nil
+	}
+
+	result := &FineGrainedTokenIntrospection{Active: active, Token: token}
+	var user models.User
+	if err := s.db.WithContext(ctx).Select("username").First(&user, "id = ?", token.UserID).Error; err == nil {
+		result.Username = user.Username
+	}
+	if token.ResourceOwnerType == models.OwnerTypeOrganization {
+		var org models.Organization
+		if err := s.db.WithContext(ctx).Select("name").First(&org, "id = ?", token.ResourceOwnerID).Error; err == nil {
+			result.ResourceOwner = org.Name
+		}
+	} else {
+		result.ResourceOwner = result.Username
+	}
+
+	if len(token.RepositoryIDs) > 0 {
+		var names []string
+		err := s.db.WithContext(ctx).Model(&models.Repository{}).Where("id IN ?", token.RepositoryIDs).Pluck("name", &names).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to get token repositories: %w", err)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			result.Repositories = append(result.Repositories, result.ResourceOwner+"/"+name)
+		}
+	}
+	return result, nil
+}
+
+// reviewer resolves the organization and requires actorID to manage it
+func (s *fineGrainedTokenService) reviewer(ctx context.Context, orgName string, actorID uuid.UUID) (*models.Organization, error) {
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("organization not found: %w", err)
+	}
+	role, err := s.orgRole(ctx, org.ID, actorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get organization membership: %w", err)
+	}
+	if role != models.OrgRoleOwner && role != models.OrgRoleAdmin {
+		return nil, ErrTokenReviewForbidden
+	}
+	return &org, nil
+}
+
+func (s *fineGrainedTokenService) ListRequests(ctx context.Context, orgName string, actorID uuid.UUID) ([]*models.FineGrainedToken, error) {
+	org, err := s.reviewer(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []*models.FineGrainedToken
+	err = s.db.WithContext(ctx).Where("resource_owner_id = ? AND resource_owner_type = ? AND status = ?",
+		org.ID, models.OwnerTypeOrganization, models.FineGrainedTokenPendingApproval).
+		Order("created_at ASC").Find(&tokens).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list token requests: %w", err)
+	}
+	return tokens, nil
+}
+
+func (s *fineGrainedTokenService) Review(ctx context.Context, orgName string, actorID, tokenID uuid.UUID, approve bool, reason string) (*models.FineGrainedToken, error) {
+	org, err := s.reviewer(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	var token models.FineGrainedToken
+	err = s.db.WithContext(ctx).Where("id = ? AND resource_owner_id = ? AND resource_owner_type = ? AND status = ?",
+		tokenID, org.ID, models.OwnerTypeOrganization, models.FineGrainedTokenPendingApproval).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFineGrainedTokenNotFound
+		}
+		return nil, fmt.Errorf("failed to get token request: %w", err)
+	}
+
+	now := s.now()
+	token.Status = models.FineGrainedTokenDenied
+	if approve {
+		token.Status = models.FineGrainedTokenActive
+	}
+	token.ReviewedByID = &actorID
+	token.ReviewedAt = &now
+	token.ReviewReason = reason
+	if err := s.db.WithContext(ctx).Save(&token).Error; err != nil {
+		return nil, fmt.Errorf("failed to review token request: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"token_id":     token.ID,
+		"organization": orgName,
+		"status":       token.Status,
+	}).Info("Reviewed fine-grained token request")
+	return &token, nil
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFineGrainedTokenService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{},
+		&models.Repository{}, &models.FineGrainedToken{})
+
+	alice := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", IsActive: true}
+	bob := &models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com", IsActive: true}
+	require.NoError(t, db.Create(alice).Error)
+	require.NoError(t, db.Create(bob).Error)
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: alice.ID, Role: models.OrgRoleMember}).Error)
+	require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: bob.ID, Role: models.OrgRoleOwner}).Error)
+
+	personal := &models.Repository{ID: uuid.New(), OwnerID: alice.ID, OwnerType: models.OwnerTypeUser, Name: "dotfiles", Visibility: models.VisibilityPrivate}
+	api := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "api", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(personal).Error)
+	require.NoError(t, db.Create(api).Error)
+
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewFineGrainedTokenService(db, logrus.New()).(*fineGrainedTokenService)
+	svc.now = func() time.Time { return now }
+
+	_, _, err := svc.Create(ctx, alice.ID, CreateFineGrainedTokenRequest{Name: "ci", Repositories: []string{"dotfiles"},
+		Permissions: map[string]models.TokenPermission{"packages": models.TokenPermissionRead}})
+	assert.ErrorIs(t, err, ErrInvalidFineGrainedToken)
+	_, _, err = svc.Create(ctx, alice.ID, CreateFineGrainedTokenRequest{Name: "ci", ResourceOwner: "bob", Repositories: []string{"dotfiles"}})
+	assert.ErrorIs(t, err, ErrInvalidFineGrainedToken)
+
+	// Tokens for the user's own repositories are active immediately
+	token, secret, err := svc.Create(ctx, alice.ID, CreateFineGrainedTokenRequest{Name: "ci", Repositories: []string{"dotfiles"},
+		Permissions: map[string]models.TokenPermission{models.TokenScopeContents: models.TokenPermissionRead}})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, models.FineGrainedTokenPrefix))
+	assert.Equal(t, models.FineGrainedTokenActive, token.Status)
+	assert.Equal(t, secret[len(secret)-8:], token.TokenLastEight)
+
+	authenticated, user, err := svc.Authenticate(ctx, secret, "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, user.ID)
+	assert.True(t, authenticated.CoversRepository(personal.ID))
+	assert.False(t, authenticated.CoversRepository(api.ID))
+	assert.True(t, authenticated.Allows(models.TokenScopeContents, models.TokenPermissionRead))
+	assert.False(t, authenticated.Allows(models.TokenScopeContents, models.TokenPermissionWrite))
+	assert.True(t, authenticated.Allows(models.TokenScopeMetadata, models.TokenPermissionRead))
+	assert.False(t, authenticated.Allows(models.TokenScopeIssues, models.TokenPermissionRead))
+
+	introspection, err := svc.Introspect(ctx, secret, alice.ID, false)
+	require.NoError(t, err)
+	assert.True(t, introspection.Active)
+	assert.Equal(t, int64(1), introspection.Token.UsageCount)
+	assert.Equal(t, "10.0.0.1", introspection.Token.LastUsedIP)
+	assert.Equal(t, []string{"alice/dotfiles"}, introspection.Repositories)
+	introspection, err = svc.Introspect(ctx, secret, bob.ID, false)
+	require.NoError(t, err)
+	assert.True(t, introspection.Active)
+	assert.Nil(t, introspection.Token)
+
+	// Organization tokens requested by members wait for an owner or admin
+	orgToken, orgSecret, err := svc.Create(ctx, alice.ID, CreateFineGrainedTokenRequest{Name: "deploy", ResourceOwner: "acme",
+		Repositories: []string{"api"}, Permissions: map[string]models.TokenPermission{models.TokenScopeIssues: models.TokenPermissionWrite}})
+	require.NoError(t, err)
+	assert.Equal(t, models.FineGrainedTokenPendingApproval, orgToken.Status)
+	_, _, err = svc.Authenticate(ctx, orgSecret, "10.0.0.1")
+	assert.ErrorIs(t, err, ErrFineGrainedTokenUnauthorized)
+
+	_, err = svc.ListRequests(ctx, "acme", alice.ID)
+	assert.ErrorIs(t, err, ErrTokenReviewForbidden)
+	requests, err := svc.ListRequests(ctx, "acme", bob.ID)
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	approved, err := svc.Review(ctx, "acme", bob.ID, orgToken.ID, true, "needed for deploys")
+	require.NoError(t, err)
+	assert.Equal(t, models.FineGrainedTokenActive, approved.Status)
+	_, err = svc.Review(ctx, "acme", bob.ID, orgToken.ID, false, "")
+	assert.ErrorIs(t, err, ErrFineGrainedTokenNotFound)
+	_, _, err = svc.Authenticate(ctx, orgSecret, "10.0.0.1")
+	require.NoError(t, err)
+
+	// Tokens stop working once revoked or expired
+	require.NoError(t, svc.Revoke(ctx, alice.ID, token.ID))
+	_, _, err = svc.Authenticate(ctx, secret, "10.0.0.1")
+	assert.ErrorIs(t, err, ErrFineGrainedTokenUnauthorized)
+	tokens, err := svc.List(ctx, alice.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+
+	now = now.AddDate(0, 0, defaultFineGrainedTokenLifetime+1)
+	_, _, err = svc.Authenticate(ctx, orgSecret, "10.0.0.1")
+	assert.ErrorIs(t, err, ErrFineGrainedTokenUnauthorized)
+	_, _, err = svc.Authenticate(ctx, "hub_pat_unknown", "10.0.0.1")
+	assert.ErrorIs(t, err, ErrFineGrainedTokenUnauthorized)
+}
+
+func TestFineGrainedTokenServiceScoped(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.FineGrainedToken{})
+	alice := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", IsActive: true}
+	require.NoError(t, db.Create(alice).Error)
+	require.NoError(t, db.Create(&models.Repository{ID: uuid.New(), OwnerID: alice.ID, OwnerType: models.OwnerTypeUser, Name: "dotfiles", Visibility: models.VisibilityPrivate}).Error)
+
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewFineGrainedTokenService(db, logrus.New()).(*fineGrainedTokenService)
+	svc.now = func() time.Time { return now }
+
+	_, _, err := svc.Create(ctx, alice.ID, CreateFineGrainedTokenRequest{Name: "ci", Scopes: []string{"repo"}})
+	assert.ErrorIs(t, err, ErrInvalidFineGrainedToken)
+	_, _, err = svc.Create(ctx, alice.ID, CreateFineGrainedTokenRequest{Name: "ci", Scopes: []string{models.PATScopeRepoRead}, Repositories: []string{"dotfiles"}})
+	assert.ErrorIs(t, err, ErrInvalidFineGrainedToken)
+	_, _, err = svc.Create(ctx, alice.ID, CreateFineGrainedTokenRequest{Name: "ci"})
+	assert.ErrorIs(t, err, ErrInvalidFineGrainedToken)
+
+	token, secret, err := svc.Create(ctx, alice.ID, CreateFineGrainedTokenRequest{Name: "ci",
+		Scopes: []string{models.PATScopeRepoWrite, models.PATScopePackagesWrite, models.PATScopeRepoWrite}, ExpiresInDays: 7})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, models.FineGrainedTokenPrefix))
+	assert.True(t, token.IsScoped())
+	assert.Equal(t, models.FineGrainedTokenActive, token.Status)
+	assert.Equal(t, alice.ID, token.ResourceOwnerID)
+	assert.Equal(t, []string{models.PATScopeRepoWrite, models.PATScopePackagesWrite}, token.Scopes)
+	assert.True(t, token.HasScope(models.PATScopeRepoRead))
+	assert.False(t, token.HasScope(models.PATScopeRepoAdmin))
+
+	authenticated, user, err := svc.Authenticate(ctx, secret, "10.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, user.ID)
+	assert.Equal(t, []string{models.PATScopeRepoWrite, models.PATScopePackagesWrite}, authenticated.Scopes)
+
+	now = now.AddDate(0, 0, 8)
+	_, _, err = svc.Authenticate(ctx, secret, "10.0.0.1")
+	assert.ErrorIs(t, err, ErrFineGrainedTokenUnauthorized)
+}
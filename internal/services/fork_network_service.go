@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Health of a fork network
+const (
+	ForkNetworkHealthy  = "healthy"
+	ForkNetworkDegraded = "degraded"
+)
+
+// ForkNetworkMember is a repository borrowing objects from a network pool
+type ForkNetworkMember struct {
+	RepositoryID uuid.UUID `json:"repository_id"`
+	FullName     string    `json:"full_name,omitempty"`
+	// LocalBytes is what the repository stores outside the pool
+	LocalBytes int64  `json:"local_bytes"`
+	Healthy    bool   `json:"healthy"`
+	Problem    string `json:"problem,omitempty"`
+}
+
+// ForkNetworkReport describes the object pool of one fork network
+type ForkNetworkReport struct {
+	Network string               `json:"network"`
+	Status  string               `json:"status"`
+	Pool    *git.ObjectPoolStats `json:"pool"`
+	Members []*ForkNetworkMember `json:"members"`
+	// SavedBytes estimates the storage members would need for their own
+	// copies of the pool's objects
+	SavedBytes int64 `json:"saved_bytes"`
+}
+
+// ForkStorageReport sums up object sharing across fork networks
+type ForkStorageReport struct {
+	Enabled    bool                 `json:"enabled"`
+	Networks   []*ForkNetworkReport `json:"networks"`
+	PoolBytes  int64                `json:"pool_bytes"`
+	LocalBytes int64                `json:"local_bytes"`
+	SavedBytes int64                `json:"saved_bytes"`
+}
+
+// ForkNetworkService reports how fork networks share storage through their
+// object pools
+type ForkNetworkService interface {
+	Report(ctx context.Context) (*ForkStorageReport, error)
+}
+
+type forkNetworkService struct {
+	db                *gorm.DB
+	repositoryService RepositoryService
+	pools             *git.ObjectPools
+	logger            *logrus.Logger
+}
+
+// NewForkNetworkService creates a new ForkNetworkService reporting on
+// pools, which is nil when fork pools are disabled
+func NewForkNetworkService(db *gorm.DB, repositoryService RepositoryService, pools *git.ObjectPools, logger *logrus.Logger) ForkNetworkService {
+	return &forkNetworkService{db: db, repositoryService: repositoryService, pools: pools, logger: logger}
+}
+
+func (s *forkNetworkService) Report(ctx context.Context) (*ForkStorageReport, error) {
+	pools := s.pools
+	report := &ForkStorageReport{Enabled: pools != nil, Networks: []*ForkNetworkReport{}}
+	networks, err := pools.Networks()
+	if err != nil {
+		return nil, err
+	}
+	for _, network := range networks {
+		stats, err := pools.Inspect(ctx, network)
+		if err != nil {
+			return nil, err
+		}
+		networkReport, err := s.networkReport(ctx, pools, stats)
+		if err != nil {
+			return nil, err
+		}
+		report.Networks = append(report.Networks, networkReport)
+		report.PoolBytes += stats.Bytes
+		report.SavedBytes += networkReport.SavedBytes
+		for _, member := range networkReport.Members {
+			report.LocalBytes += member.LocalBytes
+		}
+	}
+	return report, nil
+}
+
+func (s *forkNetworkService) networkReport(ctx context.Context, pools *git.ObjectPools, stats *git.ObjectPoolStats) (*ForkNetworkReport, error) {
+	report := &ForkNetworkReport{Network: stats.Network, Pool: stats, Members: []*ForkNetworkMember{}}
+
+	var ids []uuid.UUID
+	for _, member := range stats.Members {
+		if id, err := uuid.Parse(member); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	var repos []*models.Repository
+	if len(ids) > 0 {
+		if err := s.db.WithContext(ctx).Where("id IN ?", ids).Find(&repos).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[uuid.UUID]*models.Repository, len(repos))
+	for _, repo := range repos {
+		byID[repo.ID] = repo
+	}
+	names := ownerNames(ctx, s.db, s.logger, repos)
+
+	healthy := stats.Healthy
+	for _, key := range stats.Members {
+		member := &ForkNetworkMember{}
+		id, err := uuid.Parse(key)
+		repo := byID[id]
+		switch {
+		case err != nil:
+			member.Problem = "member " + key + " is not a repository ID"
+		case repo == nil:
+			member.RepositoryID = id
+			member.Problem = "repository no longer exists"
+		default:
+			member.RepositoryID = repo.ID
+			member.FullName = names[repo.OwnerID] + "/" + repo.Name
+			member.Problem = s.checkMember(ctx, pools, stats, repo, member)
+		}
+		member.Healthy = member.Problem == ""
+		healthy = healthy && member.Healthy
+		report.Members = append(report.Members, member)
+	}
+
+	report.Status = ForkNetworkDegraded
+	if healthy {
+		report.Status = ForkNetworkHealthy
+	}
+	if len(stats.Members) > 1 {
+		report.SavedBytes = int64(len(stats.Members)-1) * stats.Bytes
+	}
+	return report, nil
+}
+
+// checkMember measures a member and returns what is wrong with it, if
+// anything
+func (s *forkNetworkService) checkMember(ctx context.Context, pools *git.ObjectPools, stats *git.ObjectPoolStats, repo *models.Repository, member *ForkNetworkMember) string {
+	path, err := s.repositoryService.GetRepositoryPath(ctx, repo.ID)
+	if err != nil {
+		return "repository path cannot be resolved"
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return "repository is missing on disk"
+	}
+	if network, ok := pools.PoolOf(path); !ok || network != stats.Network {
+		return "repository does not borrow objects from the pool"
+	}
+	local, err := git.LocalObjectBytes(path, stats.Path)
+	if err != nil {
+		return err.Error()
+	}
+	member.LocalBytes = local
+	return ""
+}
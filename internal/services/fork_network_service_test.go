@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForkNetworkService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.Repository{})
+	ctx := context.Background()
+	logger := logrus.New()
+	base := t.TempDir()
+	gitService := git.NewGitService(logger)
+	report, err := NewForkNetworkService(db, NewRepositoryService(db, gitService, logger, base), nil, logger).Report(ctx)
+	require.NoError(t, err)
+	assert.False(t, report.Enabled)
+
+	pools := git.NewObjectPools(config.ForkPools{Enabled: true}, base, logger)
+	repoService := NewRepositoryServiceWithObjectPools(db, gitService, pools, logger, base)
+	svc := NewForkNetworkService(db, repoService, pools, logger)
+
+	alice := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", IsActive: true}
+	bob := &models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com", IsActive: true}
+	require.NoError(t, db.Create([]*models.User{alice, bob}).Error)
+	source := &models.Repository{ID: uuid.New(), OwnerID: alice.ID, OwnerType: models.OwnerTypeUser, Name: "app",
+		DefaultBranch: "main", Visibility: models.VisibilityPublic}
+	require.NoError(t, db.Create(source).Error)
+	fixture := testutil.NewGitRepo(t, testutil.RepositoryPath(base, source))
+	head := fixture.Commit("main", "initial", map[string]string{"README.md": "app\n"})
+
+	fork, err := repoService.Fork(ctx, source.ID, ForkRequest{OwnerID: bob.ID, OwnerType: models.OwnerTypeUser})
+	require.NoError(t, err)
+	forkPath, err := repoService.GetRepositoryPath(ctx, fork.ID)
+	require.NoError(t, err)
+	commit, err := gitService.GetCommit(ctx, forkPath, head)
+	require.NoError(t, err)
+	assert.Equal(t, "initial", commit.Message)
+
+	report, err = svc.Report(ctx)
+	require.NoError(t, err)
+	require.Len(t, report.Networks, 1)
+	network := report.Networks[0]
+	assert.Equal(t, source.ID.String(), network.Network)
+	assert.Equal(t, ForkNetworkHealthy, network.Status)
+	require.Len(t, network.Members, 2)
+	assert.Equal(t, "alice/app", network.Members[0].FullName)
+	assert.Equal(t, "bob/app", network.Members[1].FullName)
+	assert.Zero(t, network.Members[1].LocalBytes, "the fork stores no objects of its own")
+	assert.Positive(t, network.Pool.Objects)
+	assert.Equal(t, network.Pool.Bytes, network.SavedBytes)
+	assert.Equal(t, network.SavedBytes, report.SavedBytes)
+
+	// The fork keeps working once its source is deleted
+	require.NoError(t, repoService.Delete(ctx, source.ID))
+	commit, err = gitService.GetCommit(ctx, forkPath, head)
+	require.NoError(t, err)
+	assert.Equal(t, "initial", commit.Message)
+	report, err = svc.Report(ctx)
+	require.NoError(t, err)
+	require.Len(t, report.Networks, 1)
+	assert.Equal(t, ForkNetworkHealthy, report.Networks[0].Status)
+	require.Len(t, report.Networks[0].Members, 1)
+	assert.Equal(t, fork.ID, report.Networks[0].Members[0].RepositoryID)
+
+	require.NoError(t, repoService.Delete(ctx, fork.ID))
+	report, err = svc.Report(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, report.Networks)
+}
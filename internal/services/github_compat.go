@@ -0,0 +1,262 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+)
+
+// GitHubCompatRoute is a GitHub REST v3 route served by the compatibility
+// shim and the hub endpoint it maps onto
+type GitHubCompatRoute struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	MapsTo string `json:"maps_to"`
+}
+
+// GitHubUser is the GitHub "simple user" shape
+type GitHubUser struct {
+	Login string `json:"login"`
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	URL   string `json:"url"`
+}
+
+// GitHubRepository is the subset of the GitHub repository shape the shim returns
+type GitHubRepository struct {
+	ID              string     `json:"id"`
+	Name            string     `json:"name"`
+	FullName        string     `json:"full_name"`
+	Owner           GitHubUser `json:"owner"`
+	Private         bool       `json:"private"`
+	Visibility      string     `json:"visibility"`
+	Description     string     `json:"description"`
+	Fork            bool       `json:"fork"`
+	Archived        bool       `json:"archived"`
+	DefaultBranch   string     `json:"default_branch"`
+	URL             string     `json:"url"`
+	HTMLURL         string     `json:"html_url"`
+	CloneURL        string     `json:"clone_url"`
+	StargazersCount int        `json:"stargazers_count"`
+	ForksCount      int        `json:"forks_count"`
+	WatchersCount   int        `json:"watchers_count"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	PushedAt        *time.Time `json:"pushed_at"`
+}
+
+// GitHubIssue is the subset of the GitHub issue shape the shim returns
+type GitHubIssue struct {
+	ID        string      `json:"id"`
+	Number    int         `json:"number"`
+	Title     string      `json:"title"`
+	Body      string      `json:"body"`
+	State     string      `json:"state"`
+	User      *GitHubUser `json:"user"`
+	URL       string      `json:"url"`
+	HTMLURL   string      `json:"html_url"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	ClosedAt  *time.Time  `json:"closed_at"`
+}
+
+// GitHubBranchRef is the head or base of a GitHub pull request
+type GitHubBranchRef struct {
+	Label string `json:"label"`
+	Ref   string `json:"ref"`
+}
+
+// GitHubPullRequest is the subset of the GitHub pull request shape the shim returns
+type GitHubPullRequest struct {
+	ID        string          `json:"id"`
+	Number    int             `json:"number"`
+	Title     string          `json:"title"`
+	Body      string          `json:"body"`
+	State     string          `json:"state"`
+	Draft     bool            `json:"draft"`
+	Merged    bool            `json:"merged"`
+	MergedAt  *time.Time      `json:"merged_at"`
+	ClosedAt  *time.Time      `json:"closed_at"`
+	User      *GitHubUser     `json:"user"`
+	Head      GitHubBranchRef `json:"head"`
+	Base      GitHubBranchRef `json:"base"`
+	URL       string          `json:"url"`
+	HTMLURL   string          `json:"html_url"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// GitHubStatus is the GitHub commit status shape
+type GitHubStatus struct {
+	ID          string      `json:"id"`
+	State       string      `json:"state"`
+	Context     string      `json:"context"`
+	Description string      `json:"description"`
+	TargetURL   string      `json:"target_url"`
+	Creator     *GitHubUser `json:"creator"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// GitHubHookConfig is the delivery configuration of a GitHub webhook
+type GitHubHookConfig struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"`
+	InsecureSSL string `json:"insecure_ssl"`
+	Secret      string `json:"secret,omitempty"`
+}
+
+// GitHubHook is the GitHub repository webhook shape
+type GitHubHook struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name"`
+	Active    bool             `json:"active"`
+	Events    []string         `json:"events"`
+	Config    GitHubHookConfig `json:"config"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// GitHubCompat converts hub models into GitHub REST v3 shapes. IDs are
+// hub UUIDs rendered as strings; clients that require numeric IDs are not
+// supported.
+type GitHubCompat struct {
+	baseURL string
+}
+
+// NewGitHubCompat creates a converter whose URLs point at baseURL
+func NewGitHubCompat(baseURL string) *GitHubCompat {
+	return &GitHubCompat{baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+func (g *GitHubCompat) apiURL(format string, args ...interface{}) string {
+	return g.baseURL + "/api/github/v3" + fmt.Sprintf(format, args...)
+}
+
+// User converts a hub user; nil stays nil
+func (g *GitHubCompat) User(user *models.User) *GitHubUser {
+	if user == nil {
+		return nil
+	}
+	return &GitHubUser{Login: user.Username, ID: user.ID.String(), Type: "User", URL: g.apiURL("/users/%s", user.Username)}
+}
+
+// Repository converts a repository owned by ownerLogin
+func (g *GitHubCompat) Repository(repo *models.Repository, ownerLogin string) GitHubRepository {
+	ownerType := "User"
+	if repo.OwnerType == models.OwnerTypeOrganization {
+		ownerType = "Organization"
+	}
+	fullName := ownerLogin + "/" + repo.Name
+	return GitHubRepository{
+		ID:              repo.ID.String(),
+		Name:            repo.Name,
+		FullName:        fullName,
+		Owner:           GitHubUser{Login: ownerLogin, ID: repo.OwnerID.String(), Type: ownerType, URL: g.apiURL("/users/%s", ownerLogin)},
+		Private:         repo.Visibility != models.VisibilityPublic,
+		Visibility:      string(repo.Visibility),
+		Description:     repo.Description,
+		Fork:            repo.IsFork,
+		Archived:        repo.IsArchived,
+		DefaultBranch:   repo.DefaultBranch,
+		URL:             g.apiURL("/repos/%s", fullName),
+		HTMLURL:         g.baseURL + "/" + fullName,
+		CloneURL:        g.baseURL + "/" + fullName + ".git",
+		StargazersCount: repo.StarsCount,
+		ForksCount:      repo.ForksCount,
+		WatchersCount:   repo.WatchersCount,
+		CreatedAt:       repo.CreatedAt,
+		UpdatedAt:       repo.UpdatedAt,
+		PushedAt:        repo.PushedAt,
+	}
+}
+
+// Issue converts an issue of the repository fullName
+func (g *GitHubCompat) Issue(issue *models.Issue, fullName string) GitHubIssue {
+	return GitHubIssue{
+		ID:        issue.ID.String(),
+		Number:    issue.Number,
+		Title:     issue.Title,
+		Body:      issue.Body,
+		State:     string(issue.State),
+		User:      g.User(issue.User),
+		URL:       g.apiURL("/repos/%s/issues/%d", fullName, issue.Number),
+		HTMLURL:   fmt.Sprintf("%s/%s/issues/%d", g.baseURL, fullName, issue.Number),
+		CreatedAt: issue.CreatedAt,
+		UpdatedAt: issue.UpdatedAt,
+		ClosedAt:  issue.ClosedAt,
+	}
+}
+
+// PullRequest converts a pull request of the repository fullName. GitHub has
+// no merged state, so merged pull requests are closed with merged set.
+func (g *GitHubCompat) PullRequest(pr *models.PullRequest, fullName string) GitHubPullRequest {
+	state := "open"
+	if pr.State != models.PullRequestStateOpen {
+		state = "closed"
+	}
+	owner := strings.SplitN(fullName, "/", 2)[0]
+	return GitHubPullRequest{
+		ID:        pr.ID.String(),
+		Number:    pr.Number,
+		Title:     pr.Title,
+		Body:      pr.Body,
+		State:     state,
+		Draft:     pr.Draft,
+		Merged:    pr.Merged || pr.State == models.PullRequestStateMerged,
+		MergedAt:  pr.MergedAt,
+		ClosedAt:  pr.ClosedAt,
+		User:      g.User(pr.User),
+		Head:      GitHubBranchRef{Label: owner + ":" + pr.HeadBranch, Ref: pr.HeadBranch},
+		Base:      GitHubBranchRef{Label: owner + ":" + pr.BaseBranch, Ref: pr.BaseBranch},
+		URL:       g.apiURL("/repos/%s/pulls/%d", fullName, pr.Number),
+		HTMLURL:   fmt.Sprintf("%s/%s/pull/%d", g.baseURL, fullName, pr.Number),
+		CreatedAt: pr.CreatedAt,
+		UpdatedAt: pr.UpdatedAt,
+	}
+}
+
+// Status converts a commit status
+func (g *GitHubCompat) Status(status *models.CommitStatus) GitHubStatus {
+	return GitHubStatus{
+		ID:          status.ID.String(),
+		State:       string(status.State),
+		Context:     status.Context,
+		Description: status.Description,
+		TargetURL:   status.TargetURL,
+		Creator:     g.User(status.Creator),
+		CreatedAt:   status.CreatedAt,
+		UpdatedAt:   status.UpdatedAt,
+	}
+}
+
+// Hook converts a webhook; the secret is never returned
+func (g *GitHubCompat) Hook(hook *models.Webhook) GitHubHook {
+	insecureSSL := "0"
+	if hook.InsecureSSL {
+		insecureSSL = "1"
+	}
+	contentType := "json"
+	if hook.ContentType == "application/x-www-form-urlencoded" {
+		contentType = "form"
+	}
+	return GitHubHook{
+		ID:        hook.ID.String(),
+		Name:      "web",
+		Active:    hook.Active,
+		Events:    hook.GetEventsSlice(),
+		Config:    GitHubHookConfig{URL: hook.URL, ContentType: contentType, InsecureSSL: insecureSSL},
+		CreatedAt: hook.CreatedAt,
+		UpdatedAt: hook.UpdatedAt,
+	}
+}
+
+// GitHubHookContentType maps a GitHub hook content type onto hub's
+func GitHubHookContentType(contentType string) string {
+	if contentType == "form" {
+		return "application/x-www-form-urlencoded"
+	}
+	return "application/json"
+}
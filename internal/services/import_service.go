@@ -0,0 +1,479 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/netguard"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidImportSource is returned for repository URLs that cannot be
+// imported, and when the source API refuses access to the repository
+var ErrInvalidImportSource = errors.New("invalid import source")
+
+// Stages of an import, in order, and the progress each one starts at
+const (
+	ImportStageCloning      = "cloning"
+	ImportStageLabels       = "labels"
+	ImportStageMilestones   = "milestones"
+	ImportStageIssues       = "issues"
+	ImportStagePullRequests = "pull_requests"
+	ImportStageReleases     = "releases"
+)
+
+var importStageProgress = map[string]int{
+	ImportStageCloning:      5,
+	ImportStageLabels:       40,
+	ImportStageMilestones:   45,
+	ImportStageIssues:       50,
+	ImportStagePullRequests: 75,
+	ImportStageReleases:     90,
+}
+
+// importRecordProgressEvery is how many issues or pull requests pass
+// between updates of the import's counts
+const importRecordProgressEvery = 50
+
+var importLabelColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// ImportService imports repositories from GitHub and GitLab: the git
+// history, then labels, milestones, issues, pull requests and releases
+// read from the provider's API with the caller's token. Imports run in the
+// background; the token is only held in memory while one runs.
+type ImportService interface {
+	Start(ctx context.Context, userID uuid.UUID, req StartImportRequest) (*models.RepositoryImport, error)
+	// Get returns an import of the user with its progress
+	Get(ctx context.Context, userID, importID uuid.UUID) (*models.RepositoryImport, error)
+}
+
+// StartImportRequest is the repository to import and the repository to
+// create from it. Provider is derived from the URL's host when empty, and
+// APIURL from the provider and host. Name defaults to the source's name
+// and Visibility to private.
+type StartImportRequest struct {
+	URL         string
+	Provider    string
+	APIURL      string
+	Token       string
+	Name        string
+	Description string
+	Visibility  models.Visibility
+}
+
+type importService struct {
+	db          *gorm.DB
+	repoService RepositoryService
+	cfg         config.ImportLimits
+	client      *http.Client
+	resolve     func(ctx context.Context, host string) (net.IP, error) // vets the host git clones from
+	logger      *logrus.Logger
+	now         func() time.Time
+}
+
+func NewImportService(db *gorm.DB, repoService RepositoryService, cfg config.ImportLimits, logger *logrus.Logger) ImportService {
+	return &importService{
+		db:          db,
+		repoService: repoService,
+		cfg:         cfg,
+		client:      newImportClient(),
+		resolve:     netguard.Resolve,
+		logger:      logger,
+		now:         time.Now,
+	}
+}
+
+func (s *importService) Start(ctx context.Context, userID uuid.UUID, req StartImportRequest) (*models.RepositoryImport, error) {
+	source, name, err := newImportSource(s.client, req.URL, req.Provider, req.APIURL, req.Token)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != "" {
+		name = req.Name
+	}
+	if req.Visibility == "" {
+		req.Visibility = models.VisibilityPrivate
+	}
+
+	var taken int64
+	if err := s.db.WithContext(ctx).Model(&models.Repository{}).
+		Where("owner_id = ? AND owner_type = ? AND name = ?", userID, models.OwnerTypeUser, name).
+		Count(&taken).Error; err != nil {
+		return nil, fmt.Errorf("failed to check existing repository: %w", err)
+	}
+	if taken > 0 {
+		return nil, ErrImportRepositoryExists
+	}
+
+	// Credentials pasted into the URL are never stored
+	sourceURL, _ := url.Parse(strings.TrimSpace(req.URL))
+	sourceURL.User = nil
+	imp := &models.RepositoryImport{
+		ID:         uuid.New(),
+		UserID:     userID,
+		Name:       name,
+		SourceType: source.Provider(),
+		SourceURL:  sourceURL.String(),
+		Status:     models.RepositoryImportPending,
+	}
+	if err := s.db.WithContext(ctx).Create(imp).Error; err != nil {
+		return nil, fmt.Errorf("failed to create repository import: %w", err)
+	}
+
+	req.Name = name
+	go func() {
+		defer errorreporting.Default().Recover("repository_import", map[string]string{"import_id": imp.ID.String()})
+		s.run(context.Background(), imp, source, req)
+	}()
+	return imp, nil
+}
+
+// run imports the repository and records the outcome on the import. A
+// failure after the repository was created leaves it with what was
+// imported so far.
+func (s *importService) run(ctx context.Context, imp *models.RepositoryImport, source importSource, req StartImportRequest) {
+	log := s.logger.WithFields(logrus.Fields{"import_id": imp.ID, "source": imp.SourceURL})
+	s.db.WithContext(ctx).Model(imp).Update("status", models.RepositoryImportInProgress)
+
+	err := s.importRepository(ctx, imp, source, req)
+	now := s.now()
+	updates := map[string]interface{}{"imported_at": now, "stage": ""}
+	if err != nil {
+		log.WithError(err).Warn("Repository import failed")
+		updates["status"], updates["error_message"] = models.RepositoryImportFailed, err.Error()
+	} else {
+		log.WithField("repository_id", imp.RepositoryID).Info("Imported repository")
+		updates["status"], updates["progress"] = models.RepositoryImportCompleted, 100
+	}
+	if err := s.db.WithContext(ctx).Model(imp).Updates(updates).Error; err != nil {
+		log.WithError(err).Error("Failed to record repository import outcome")
+	}
+}
+
+func (s *importService) importRepository(ctx context.Context, imp *models.RepositoryImport, source importSource, req StartImportRequest) error {
+	s.setStage(ctx, imp, ImportStageCloning)
+	meta, err := source.Repository(ctx)
+	if err != nil {
+		return err
+	}
+
+	cloneURL := meta.CloneURL
+	if cloneURL == "" {
+		cloneURL = imp.SourceURL
+	}
+
+	root := s.cfg.WorkPath
+	if root == "" {
+		root = filepath.Join(os.TempDir(), "hub-imports")
+	}
+	dir := filepath.Join(root, imp.ID.String())
+	defer os.RemoveAll(dir)
+	// The source names the clone URL, so its host is vetted like the API's
+	// and git is pinned to the vetted address
+	parsed, err := url.Parse(cloneURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+		return fmt.Errorf("%w: the source repository must be cloned over http or https", ErrInvalidImportSource)
+	}
+	address, err := s.resolve(ctx, parsed.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: cannot clone from %s: %v", ErrInvalidImportSource, parsed.Hostname(), err)
+	}
+	opts := git.FetchRemoteOptions{DefaultBranch: meta.DefaultBranch, Address: address.String()}
+	if req.Token != "" {
+		opts.Username, opts.Password = source.CloneCredentials()
+	}
+	history, err := git.FetchRemoteHistory(ctx, cloneURL, filepath.Join(dir, "source.git"), opts)
+	if err != nil {
+		return fmt.Errorf("failed to fetch the source repository: %w", err)
+	}
+	if history.Branches == 0 {
+		return fmt.Errorf("%w: the source repository has no branches", ErrInvalidImportSource)
+	}
+
+	description := req.Description
+	if description == "" {
+		description = meta.Description
+	}
+	repo, err := s.repoService.Create(ctx, CreateRepositoryRequest{
+		OwnerID:       imp.UserID,
+		OwnerType:     models.OwnerTypeUser,
+		Name:          req.Name,
+		Description:   description,
+		DefaultBranch: meta.DefaultBranch,
+		Visibility:    req.Visibility,
+		HasIssues:     true,
+		ImportFrom:    filepath.Join(dir, "source.git"),
+	})
+	if err != nil {
+		return err
+	}
+	imp.RepositoryID = &repo.ID
+	if err := s.db.WithContext(ctx).Model(imp).Updates(map[string]interface{}{
+		"repository_id":     repo.ID,
+		"total_commits":     history.Commits,
+		"imported_commits":  history.Commits,
+		"total_branches":    history.Branches,
+		"imported_branches": history.Branches,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update repository import: %w", err)
+	}
+
+	importer := &metadataImporter{s: s, imp: imp, repo: repo, source: source}
+	for _, step := range []struct {
+		stage string
+		run   func(context.Context) error
+	}{
+		{ImportStageLabels, importer.labels},
+		{ImportStageMilestones, importer.milestones},
+		{ImportStageIssues, importer.issues},
+		{ImportStagePullRequests, importer.pullRequests},
+		{ImportStageReleases, importer.releases},
+	} {
+		s.setStage(ctx, imp, step.stage)
+		if err := step.run(ctx); err != nil {
+			return fmt.Errorf("failed to import %s: %w", strings.ReplaceAll(step.stage, "_", " "), err)
+		}
+	}
+	return nil
+}
+
+func (s *importService) setStage(ctx context.Context, imp *models.RepositoryImport, stage string) {
+	imp.Stage, imp.Progress = stage, importStageProgress[stage]
+	if err := s.db.WithContext(ctx).Model(imp).Updates(map[string]interface{}{"stage": stage, "progress": imp.Progress}).Error; err != nil {
+		s.logger.WithError(err).WithField("import_id", imp.ID).Warn("Failed to update repository import stage")
+	}
+}
+
+// metadataImporter copies the records of the source repository, keeping
+// their numbers, into the imported repository. Authors are not mapped to
+// local accounts: the importing user becomes the author and the original
+// one is credited in the body.
+type metadataImporter struct {
+	s            *importService
+	imp          *models.RepositoryImport
+	repo         *models.Repository
+	source       importSource
+	labelsByName map[string]models.Label
+	milestoneIDs map[int]uuid.UUID
+}
+
+func (m *metadataImporter) count(ctx context.Context, column string, n int) {
+	m.s.db.WithContext(ctx).Model(m.imp).Update(column, n)
+}
+
+func (m *metadataImporter) labels(ctx context.Context) error {
+	labels, err := m.source.Labels(ctx)
+	if err != nil {
+		return err
+	}
+	m.labelsByName = map[string]models.Label{}
+	for _, l := range labels {
+		if _, ok := m.labelsByName[l.Name]; ok || l.Name == "" {
+			continue
+		}
+		label := models.Label{ID: uuid.New(), RepositoryID: m.repo.ID, Name: l.Name, Description: l.Description, Color: l.Color}
+		if !importLabelColor.MatchString(label.Color) {
+			label.Color = "#6b7280"
+		}
+		if err := m.s.db.WithContext(ctx).Create(&label).Error; err != nil {
+			return err
+		}
+		m.labelsByName[l.Name] = label
+	}
+	m.imp.LabelsImported = len(m.labelsByName)
+	m.count(ctx, "labels_imported", m.imp.LabelsImported)
+	return nil
+}
+
+func (m *metadataImporter) milestones(ctx context.Context) error {
+	milestones, err := m.source.Milestones(ctx)
+	if err != nil {
+		return err
+	}
+	m.milestoneIDs = map[int]uuid.UUID{}
+	for _, ms := range milestones {
+		milestone := models.Milestone{ID: uuid.New(), RepositoryID: m.repo.ID, Number: ms.Number, Title: truncate(ms.Title, 255),
+			Description: ms.Description, State: models.MilestoneStateOpen, DueOn: ms.DueOn}
+		if ms.Closed {
+			milestone.State = models.MilestoneStateClosed
+		}
+		if err := m.s.db.WithContext(ctx).Create(&milestone).Error; err != nil {
+			return err
+		}
+		m.milestoneIDs[ms.Number] = milestone.ID
+	}
+	m.imp.MilestonesImported = len(m.milestoneIDs)
+	m.count(ctx, "milestones_imported", m.imp.MilestonesImported)
+	return nil
+}
+
+// body credits the original author of an imported issue or pull request
+func (m *metadataImporter) body(issue importedIssue, note string) string {
+	provider := "GitHub"
+	if m.source.Provider() == ImportProviderGitLab {
+		provider = "GitLab"
+	}
+	credit := fmt.Sprintf("*Originally opened by @%s on %s on %s%s*", issue.Author, provider, issue.CreatedAt.UTC().Format("2006-01-02"), note)
+	if issue.Body == "" {
+		return credit
+	}
+	return credit + "\n\n" + issue.Body
+}
+
+func (m *metadataImporter) labelsOf(names []string) []models.Label {
+	var labels []models.Label
+	for _, name := range names {
+		if label, ok := m.labelsByName[name]; ok {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+func (m *metadataImporter) issues(ctx context.Context) error {
+	issues, err := m.source.Issues(ctx)
+	if err != nil {
+		return err
+	}
+	for i, src := range issues {
+		issue := models.Issue{
+			ID:           uuid.New(),
+			CreatedAt:    src.CreatedAt,
+			RepositoryID: m.repo.ID,
+			Number:       src.Number,
+			Title:        truncate(src.Title, 255),
+			Body:         m.body(src, ""),
+			UserID:       &m.imp.UserID,
+			State:        models.IssueStateOpen,
+			Labels:       m.labelsOf(src.Labels),
+		}
+		if src.Closed {
+			issue.State, issue.ClosedAt = models.IssueStateClosed, src.ClosedAt
+		}
+		if id, ok := m.milestoneIDs[src.Milestone]; ok {
+			issue.MilestoneID = &id
+		}
+		if err := m.s.db.WithContext(ctx).Create(&issue).Error; err != nil {
+			return err
+		}
+		m.imp.IssuesImported = i + 1
+		if m.imp.IssuesImported%importRecordProgressEvery == 0 {
+			m.count(ctx, "issues_imported", m.imp.IssuesImported)
+		}
+	}
+	m.count(ctx, "issues_imported", m.imp.IssuesImported)
+	return nil
+}
+
+// pullRequests imports pull requests as closed records: their branches may
+// be gone and reviews are not imported, so only merged ones keep their state
+func (m *metadataImporter) pullRequests(ctx context.Context) error {
+	pulls, err := m.source.PullRequests(ctx)
+	if err != nil {
+		return err
+	}
+	now := m.s.now()
+	for i, src := range pulls {
+		note := ""
+		if !src.Closed {
+			note = "; it was still open when imported"
+		}
+		pr := models.PullRequest{
+			ID:               uuid.New(),
+			CreatedAt:        src.CreatedAt,
+			RepositoryID:     m.repo.ID,
+			Number:           src.Number,
+			Title:            truncate(src.Title, 255),
+			Body:             m.body(src.importedIssue, note),
+			UserID:           &m.imp.UserID,
+			BaseRepositoryID: m.repo.ID,
+			BaseBranch:       src.BaseBranch,
+			HeadBranch:       src.HeadBranch,
+			State:            models.PullRequestStateClosed,
+			ClosedAt:         src.ClosedAt,
+			Labels:           m.labelsOf(src.Labels),
+		}
+		if src.MergedAt != nil {
+			pr.State, pr.Merged, pr.MergedAt = models.PullRequestStateMerged, true, src.MergedAt
+		}
+		if pr.ClosedAt == nil {
+			pr.ClosedAt = &now
+		}
+		if err := m.s.db.WithContext(ctx).Create(&pr).Error; err != nil {
+			return err
+		}
+		m.imp.PullRequestsImported = i + 1
+		if m.imp.PullRequestsImported%importRecordProgressEvery == 0 {
+			m.count(ctx, "pull_requests_imported", m.imp.PullRequestsImported)
+		}
+	}
+	m.count(ctx, "pull_requests_imported", m.imp.PullRequestsImported)
+	return nil
+}
+
+func (m *metadataImporter) releases(ctx context.Context) error {
+	releases, err := m.source.Releases(ctx)
+	if err != nil {
+		return err
+	}
+	for _, src := range releases {
+		release := models.Release{
+			ID:              uuid.New(),
+			CreatedAt:       src.CreatedAt,
+			RepositoryID:    m.repo.ID,
+			TagName:         src.TagName,
+			TargetCommitish: src.Target,
+			Name:            truncate(src.Name, 255),
+			Body:            src.Body,
+			Draft:           src.Draft,
+			Prerelease:      src.Prerelease,
+			AuthorID:        &m.imp.UserID,
+			PublishedAt:     src.PublishedAt,
+		}
+		if err := m.s.db.WithContext(ctx).Create(&release).Error; err != nil {
+			return err
+		}
+	}
+	m.imp.ReleasesImported = len(releases)
+	m.count(ctx, "releases_imported", m.imp.ReleasesImported)
+	return nil
+}
+
+func (s *importService) Get(ctx context.Context, userID, importID uuid.UUID) (*models.RepositoryImport, error) {
+	var imp models.RepositoryImport
+	if err := s.db.WithContext(ctx).Preload("Repository").
+		First(&imp, "id = ? AND user_id = ?", importID, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRepositoryImportNotFound
+		}
+		return nil, err
+	}
+
+	// An import that stopped making progress was cut short by a restart
+	finished := imp.Status == models.RepositoryImportCompleted || imp.Status == models.RepositoryImportFailed
+	if !finished && s.now().Sub(imp.UpdatedAt) > importStaleAfter {
+		now := s.now()
+		imp.Status, imp.ErrorMessage, imp.ImportedAt = models.RepositoryImportFailed, "import was interrupted", &now
+		if err := s.db.WithContext(ctx).Model(&imp).Updates(map[string]interface{}{
+			"status": imp.Status, "error_message": imp.ErrorMessage, "imported_at": now,
+		}).Error; err != nil {
+			return nil, fmt.Errorf("failed to update repository import: %w", err)
+		}
+	}
+	return &imp, nil
+}
@@ -0,0 +1,551 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/netguard"
+)
+
+// Providers repositories can be imported from
+const (
+	ImportProviderGitHub = "github"
+	ImportProviderGitLab = "gitlab"
+)
+
+// importMaxPages bounds how many pages of each kind of record are read, so
+// a misbehaving API cannot keep an import running forever
+const importMaxPages = 1000
+
+// importMaxResponseSize bounds a page of the source API
+const importMaxResponseSize = 32 << 20
+
+// importedRepository is the source repository of an import
+type importedRepository struct {
+	Name          string
+	Description   string
+	DefaultBranch string
+	CloneURL      string
+}
+
+type importedLabel struct {
+	Name        string
+	Color       string
+	Description string
+}
+
+type importedMilestone struct {
+	Number      int
+	Title       string
+	Description string
+	Closed      bool
+	DueOn       *time.Time
+}
+
+type importedIssue struct {
+	Number    int
+	Title     string
+	Body      string
+	Author    string
+	Closed    bool
+	CreatedAt time.Time
+	ClosedAt  *time.Time
+	Labels    []string
+	Milestone int
+}
+
+type importedPullRequest struct {
+	importedIssue
+	BaseBranch string
+	HeadBranch string
+	MergedAt   *time.Time
+}
+
+type importedRelease struct {
+	TagName     string
+	Target      string
+	Name        string
+	Body        string
+	Draft       bool
+	Prerelease  bool
+	CreatedAt   time.Time
+	PublishedAt *time.Time
+}
+
+// importSource reads a repository and its metadata from a provider's API
+type importSource interface {
+	Provider() string
+	Repository(ctx context.Context) (*importedRepository, error)
+	Labels(ctx context.Context) ([]importedLabel, error)
+	Milestones(ctx context.Context) ([]importedMilestone, error)
+	Issues(ctx context.Context) ([]importedIssue, error)
+	PullRequests(ctx context.Context) ([]importedPullRequest, error)
+	Releases(ctx context.Context) ([]importedRelease, error)
+	// CloneCredentials is the HTTP basic auth git uses with the token
+	CloneCredentials() (string, string)
+}
+
+// newImportSource picks the provider of a repository URL, from the host
+// unless given, and the API to read it from, derived from the host unless
+// given. GitHub Enterprise serves its API under /api/v3 and GitLab under
+// /api/v4.
+func newImportSource(client *http.Client, rawURL, provider, apiURL, token string) (importSource, string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, "", fmt.Errorf("%w: the URL must be an http or https repository URL", ErrInvalidImportSource)
+	}
+	project := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if project == "" || !strings.Contains(project, "/") {
+		return nil, "", fmt.Errorf("%w: the URL must name a repository", ErrInvalidImportSource)
+	}
+	name := project[strings.LastIndex(project, "/")+1:]
+
+	if provider == "" {
+		switch host := strings.ToLower(u.Hostname()); {
+		case strings.Contains(host, "github"):
+			provider = ImportProviderGitHub
+		case strings.Contains(host, "gitlab"):
+			provider = ImportProviderGitLab
+		default:
+			return nil, "", fmt.Errorf("%w: cannot tell the provider of %s; set it to github or gitlab", ErrInvalidImportSource, u.Host)
+		}
+	}
+	api := &importAPI{client: client, token: token}
+	switch provider {
+	case ImportProviderGitHub:
+		if strings.Count(project, "/") != 1 {
+			return nil, "", fmt.Errorf("%w: GitHub repository URLs look like https://github.com/owner/name", ErrInvalidImportSource)
+		}
+		api.base = apiURL
+		if api.base == "" {
+			api.base = u.Scheme + "://" + u.Host + "/api/v3"
+			if strings.EqualFold(u.Hostname(), "github.com") {
+				api.base = "https://api.github.com"
+			}
+		}
+		api.base = strings.TrimSuffix(api.base, "/") + "/repos/" + project
+		api.header = func(req *http.Request, token string) { req.Header.Set("Authorization", "Bearer "+token) }
+		return &githubImportSource{api: api}, name, nil
+	case ImportProviderGitLab:
+		api.base = apiURL
+		if api.base == "" {
+			api.base = u.Scheme + "://" + u.Host + "/api/v4"
+		}
+		api.base = strings.TrimSuffix(api.base, "/") + "/projects/" + url.PathEscape(project)
+		api.header = func(req *http.Request, token string) { req.Header.Set("PRIVATE-TOKEN", token) }
+		return &gitlabImportSource{api: api}, name, nil
+	}
+	return nil, "", fmt.Errorf("%w: unknown provider %q", ErrInvalidImportSource, provider)
+}
+
+// newImportClient returns the client source APIs are read with. It refuses
+// addresses inside the deployment and redirects to other hosts, which
+// GitLab's PRIVATE-TOKEN header would otherwise follow.
+func newImportClient() *http.Client {
+	return &http.Client{
+		Timeout:   60 * time.Second,
+		Transport: netguard.Transport(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 || req.URL.Host != via[0].URL.Host {
+				return fmt.Errorf("source API redirected to %s", req.URL.Host)
+			}
+			return nil
+		},
+	}
+}
+
+// importAPI reads paginated JSON from a provider API. Both GitHub and
+// GitLab link to the next page in the Link header.
+type importAPI struct {
+	client *http.Client
+	base   string
+	token  string
+	// header sets the token on a request; GitHub takes a bearer token and
+	// GitLab a PRIVATE-TOKEN header
+	header func(req *http.Request, token string)
+}
+
+var importNextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+func (a *importAPI) get(ctx context.Context, path string, dest interface{}) (string, error) {
+	target := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		target = a.base + path
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if a.token != "" && a.header != nil {
+		a.header(req, a.token)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach the source API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Response bodies are not echoed: the import's error is shown to users
+	// and must not reveal what an arbitrary host answered
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return "", fmt.Errorf("%w: the source API answered %d; check the URL and that the token can read the repository", ErrInvalidImportSource, resp.StatusCode)
+	default:
+		return "", fmt.Errorf("source API answered %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, importMaxResponseSize)).Decode(dest); err != nil {
+		return "", fmt.Errorf("failed to decode source API response: %w", err)
+	}
+	m := importNextLink.FindStringSubmatch(resp.Header.Get("Link"))
+	if m == nil {
+		return "", nil
+	}
+	// The token goes with every page, so pages are only read from the API
+	next, err := url.Parse(m[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid next page link from source API: %w", err)
+	}
+	base, _ := url.Parse(a.base)
+	if !strings.EqualFold(next.Scheme, base.Scheme) || !strings.EqualFold(next.Host, base.Host) {
+		return "", fmt.Errorf("source API linked its next page to another host (%s)", next.Host)
+	}
+	return next.String(), nil
+}
+
+// importPages reads every page of a list endpoint
+func importPages[T any](ctx context.Context, a *importAPI, path string) ([]T, error) {
+	var all []T
+	for page := 0; path != "" && page < importMaxPages; page++ {
+		var items []T
+		next, err := a.get(ctx, path, &items)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		path = next
+	}
+	return all, nil
+}
+
+type githubImportSource struct {
+	api *importAPI
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+}
+
+type githubLabel struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+type githubMilestone struct {
+	Number      int        `json:"number"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	State       string     `json:"state"`
+	DueOn       *time.Time `json:"due_on"`
+}
+
+type githubIssue struct {
+	Number      int              `json:"number"`
+	Title       string           `json:"title"`
+	Body        string           `json:"body"`
+	User        githubUser       `json:"user"`
+	State       string           `json:"state"`
+	CreatedAt   time.Time        `json:"created_at"`
+	ClosedAt    *time.Time       `json:"closed_at"`
+	Labels      []githubLabel    `json:"labels"`
+	Milestone   *githubMilestone `json:"milestone"`
+	PullRequest *struct{}        `json:"pull_request"`
+}
+
+func (g githubIssue) imported() importedIssue {
+	issue := importedIssue{
+		Number:    g.Number,
+		Title:     g.Title,
+		Body:      g.Body,
+		Author:    g.User.Login,
+		Closed:    g.State == "closed",
+		CreatedAt: g.CreatedAt,
+		ClosedAt:  g.ClosedAt,
+	}
+	for _, label := range g.Labels {
+		issue.Labels = append(issue.Labels, label.Name)
+	}
+	if g.Milestone != nil {
+		issue.Milestone = g.Milestone.Number
+	}
+	return issue
+}
+
+func (s *githubImportSource) Provider() string { return ImportProviderGitHub }
+
+func (s *githubImportSource) CloneCredentials() (string, string) {
+	return "x-access-token", s.api.token
+}
+
+func (s *githubImportSource) Repository(ctx context.Context) (*importedRepository, error) {
+	var repo struct {
+		Name          string `json:"name"`
+		Description   string `json:"description"`
+		DefaultBranch string `json:"default_branch"`
+		CloneURL      string `json:"clone_url"`
+	}
+	if _, err := s.api.get(ctx, "", &repo); err != nil {
+		return nil, err
+	}
+	return &importedRepository{Name: repo.Name, Description: repo.Description, DefaultBranch: repo.DefaultBranch, CloneURL: repo.CloneURL}, nil
+}
+
+func (s *githubImportSource) Labels(ctx context.Context) ([]importedLabel, error) {
+	labels, err := importPages[githubLabel](ctx, s.api, "/labels?per_page=100")
+	if err != nil {
+		return nil, err
+	}
+	imported := make([]importedLabel, 0, len(labels))
+	for _, label := range labels {
+		imported = append(imported, importedLabel{Name: label.Name, Color: "#" + label.Color, Description: label.Description})
+	}
+	return imported, nil
+}
+
+func (s *githubImportSource) Milestones(ctx context.Context) ([]importedMilestone, error) {
+	milestones, err := importPages[githubMilestone](ctx, s.api, "/milestones?state=all&per_page=100")
+	if err != nil {
+		return nil, err
+	}
+	imported := make([]importedMilestone, 0, len(milestones))
+	for _, m := range milestones {
+		imported = append(imported, importedMilestone{Number: m.Number, Title: m.Title, Description: m.Description, Closed: m.State == "closed", DueOn: m.DueOn})
+	}
+	return imported, nil
+}
+
+func (s *githubImportSource) Issues(ctx context.Context) ([]importedIssue, error) {
+	issues, err := importPages[githubIssue](ctx, s.api, "/issues?state=all&per_page=100&sort=created&direction=asc")
+	if err != nil {
+		return nil, err
+	}
+	imported := make([]importedIssue, 0, len(issues))
+	for _, issue := range issues {
+		// The issues API lists pull requests too
+		if issue.PullRequest == nil {
+			imported = append(imported, issue.imported())
+		}
+	}
+	return imported, nil
+}
+
+func (s *githubImportSource) PullRequests(ctx context.Context) ([]importedPullRequest, error) {
+	type ref struct {
+		Ref string `json:"ref"`
+	}
+	type pull struct {
+		githubIssue
+		MergedAt *time.Time `json:"merged_at"`
+		Base     ref        `json:"base"`
+		Head     ref        `json:"head"`
+	}
+	pulls, err := importPages[pull](ctx, s.api, "/pulls?state=all&per_page=100&sort=created&direction=asc")
+	if err != nil {
+		return nil, err
+	}
+	imported := make([]importedPullRequest, 0, len(pulls))
+	for _, pr := range pulls {
+		imported = append(imported, importedPullRequest{
+			importedIssue: pr.imported(),
+			BaseBranch:    pr.Base.Ref,
+			HeadBranch:    pr.Head.Ref,
+			MergedAt:      pr.MergedAt,
+		})
+	}
+	return imported, nil
+}
+
+func (s *githubImportSource) Releases(ctx context.Context) ([]importedRelease, error) {
+	type release struct {
+		TagName         string     `json:"tag_name"`
+		TargetCommitish string     `json:"target_commitish"`
+		Name            string     `json:"name"`
+		Body            string     `json:"body"`
+		Draft           bool       `json:"draft"`
+		Prerelease      bool       `json:"prerelease"`
+		CreatedAt       time.Time  `json:"created_at"`
+		PublishedAt     *time.Time `json:"published_at"`
+	}
+	releases, err := importPages[release](ctx, s.api, "/releases?per_page=100")
+	if err != nil {
+		return nil, err
+	}
+	imported := make([]importedRelease, 0, len(releases))
+	for _, r := range releases {
+		imported = append(imported, importedRelease{TagName: r.TagName, Target: r.TargetCommitish, Name: r.Name, Body: r.Body,
+			Draft: r.Draft, Prerelease: r.Prerelease, CreatedAt: r.CreatedAt, PublishedAt: r.PublishedAt})
+	}
+	return imported, nil
+}
+
+type gitlabImportSource struct {
+	api *importAPI
+}
+
+type gitlabMilestoneRef struct {
+	IID int `json:"iid"`
+}
+
+type gitlabIssue struct {
+	IID         int    `json:"iid"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Author      struct {
+		Username string `json:"username"`
+	} `json:"author"`
+	State     string              `json:"state"`
+	CreatedAt time.Time           `json:"created_at"`
+	ClosedAt  *time.Time          `json:"closed_at"`
+	Labels    []string            `json:"labels"`
+	Milestone *gitlabMilestoneRef `json:"milestone"`
+}
+
+func (g gitlabIssue) imported() importedIssue {
+	issue := importedIssue{
+		Number:    g.IID,
+		Title:     g.Title,
+		Body:      g.Description,
+		Author:    g.Author.Username,
+		Closed:    g.State != "opened",
+		CreatedAt: g.CreatedAt,
+		ClosedAt:  g.ClosedAt,
+		Labels:    g.Labels,
+	}
+	if g.Milestone != nil {
+		issue.Milestone = g.Milestone.IID
+	}
+	return issue
+}
+
+func (s *gitlabImportSource) Provider() string { return ImportProviderGitLab }
+
+func (s *gitlabImportSource) CloneCredentials() (string, string) {
+	return "oauth2", s.api.token
+}
+
+func (s *gitlabImportSource) Repository(ctx context.Context) (*importedRepository, error) {
+	var project struct {
+		Name          string `json:"path"`
+		Description   string `json:"description"`
+		DefaultBranch string `json:"default_branch"`
+		CloneURL      string `json:"http_url_to_repo"`
+	}
+	if _, err := s.api.get(ctx, "", &project); err != nil {
+		return nil, err
+	}
+	return &importedRepository{Name: project.Name, Description: project.Description, DefaultBranch: project.DefaultBranch, CloneURL: project.CloneURL}, nil
+}
+
+func (s *gitlabImportSource) Labels(ctx context.Context) ([]importedLabel, error) {
+	// GitLab label colors already start with #
+	labels, err := importPages[githubLabel](ctx, s.api, "/labels?per_page=100")
+	if err != nil {
+		return nil, err
+	}
+	imported := make([]importedLabel, 0, len(labels))
+	for _, label := range labels {
+		imported = append(imported, importedLabel{Name: label.Name, Color: label.Color, Description: label.Description})
+	}
+	return imported, nil
+}
+
+func (s *gitlabImportSource) Milestones(ctx context.Context) ([]importedMilestone, error) {
+	type milestone struct {
+		IID         int    `json:"iid"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		State       string `json:"state"`
+		DueDate     string `json:"due_date"`
+	}
+	milestones, err := importPages[milestone](ctx, s.api, "/milestones?per_page=100")
+	if err != nil {
+		return nil, err
+	}
+	imported := make([]importedMilestone, 0, len(milestones))
+	for _, m := range milestones {
+		im := importedMilestone{Number: m.IID, Title: m.Title, Description: m.Description, Closed: m.State == "closed"}
+		if due, err := time.Parse("2006-01-02", m.DueDate); err == nil {
+			im.DueOn = &due
+		}
+		imported = append(imported, im)
+	}
+	return imported, nil
+}
+
+func (s *gitlabImportSource) Issues(ctx context.Context) ([]importedIssue, error) {
+	issues, err := importPages[gitlabIssue](ctx, s.api, "/issues?scope=all&per_page=100&order_by=created_at&sort=asc")
+	if err != nil {
+		return nil, err
+	}
+	imported := make([]importedIssue, 0, len(issues))
+	for _, issue := range issues {
+		imported = append(imported, issue.imported())
+	}
+	return imported, nil
+}
+
+func (s *gitlabImportSource) PullRequests(ctx context.Context) ([]importedPullRequest, error) {
+	type mergeRequest struct {
+		gitlabIssue
+		MergedAt     *time.Time `json:"merged_at"`
+		TargetBranch string     `json:"target_branch"`
+		SourceBranch string     `json:"source_branch"`
+	}
+	mrs, err := importPages[mergeRequest](ctx, s.api, "/merge_requests?scope=all&state=all&per_page=100&order_by=created_at&sort=asc")
+	if err != nil {
+		return nil, err
+	}
+	imported := make([]importedPullRequest, 0, len(mrs))
+	for _, mr := range mrs {
+		imported = append(imported, importedPullRequest{
+			importedIssue: mr.imported(),
+			BaseBranch:    mr.TargetBranch,
+			HeadBranch:    mr.SourceBranch,
+			MergedAt:      mr.MergedAt,
+		})
+	}
+	return imported, nil
+}
+
+func (s *gitlabImportSource) Releases(ctx context.Context) ([]importedRelease, error) {
+	type release struct {
+		TagName     string     `json:"tag_name"`
+		Name        string     `json:"name"`
+		Description string     `json:"description"`
+		CreatedAt   time.Time  `json:"created_at"`
+		ReleasedAt  *time.Time `json:"released_at"`
+		Upcoming    bool       `json:"upcoming_release"`
+		Commit      struct {
+			ID string `json:"id"`
+		} `json:"commit"`
+	}
+	releases, err := importPages[release](ctx, s.api, "/releases?per_page=100")
+	if err != nil {
+		return nil, err
+	}
+	imported := make([]importedRelease, 0, len(releases))
+	for _, r := range releases {
+		imported = append(imported, importedRelease{TagName: r.TagName, Target: r.Commit.ID, Name: r.Name, Body: r.Description,
+			Prerelease: r.Upcoming, CreatedAt: r.CreatedAt, PublishedAt: r.ReleasedAt})
+	}
+	return imported, nil
+}
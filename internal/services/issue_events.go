@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Issue event actions
+const (
+	IssueActionOpened   = "opened"
+	IssueActionEdited   = "edited"
+	IssueActionClosed   = "closed"
+	IssueActionReopened = "reopened"
+	// IssueActionCommented carries the new comment in IssueEvent.Comment
+	IssueActionCommented = "commented"
+)
+
+// IssueEvent describes a change to an issue
+type IssueEvent struct {
+	Action  string
+	Issue   *models.Issue
+	Comment *models.Comment
+	ActorID uuid.UUID
+}
+
+// IssueListener is notified in the background of issue events
+type IssueListener func(ctx context.Context, event IssueEvent)
+
+func (s *issueService) Subscribe(listener IssueListener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// emit starts every listener without delaying the request that caused the event
+func (s *issueService) emit(event IssueEvent) {
+	s.mu.RLock()
+	listeners := make([]IssueListener, len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.RUnlock()
+
+	tags := map[string]string{"issue_id": event.Issue.ID.String()}
+	for _, listener := range listeners {
+		go func(l IssueListener) {
+			defer errorreporting.Default().Recover("issue_listener", tags)
+			l(context.Background(), event)
+		}(listener)
+	}
+}
+
+// IssueNotifier delivers issue events to repository webhooks
+type IssueNotifier struct {
+	webhooks *WebhookDeliveryService
+	logger   *logrus.Logger
+}
+
+// NewIssueNotifier creates a new IssueNotifier
+func NewIssueNotifier(webhooks *WebhookDeliveryService, logger *logrus.Logger) *IssueNotifier {
+	return &IssueNotifier{webhooks: webhooks, logger: logger}
+}
+
+// HandleIssue is an IssueListener
+func (n *IssueNotifier) HandleIssue(ctx context.Context, event IssueEvent) {
+	issue := event.Issue
+	var err error
+	if event.Action == IssueActionCommented {
+		comment := event.Comment
+		err = n.webhooks.TriggerWebhooks(ctx, issue.RepositoryID, WebhookEventIssueComment, map[string]interface{}{
+			"action":  "created",
+			"issue":   exportIssue(issue),
+			"comment": exportComment(comment.ID, comment.Body, comment.User, comment.CreatedAt),
+		})
+	} else {
+		err = n.webhooks.TriggerWebhooks(ctx, issue.RepositoryID, WebhookEventIssues, map[string]interface{}{
+			"action": event.Action,
+			"number": issue.Number,
+			"issue":  exportIssue(issue),
+		})
+	}
+	if err != nil {
+		n.logger.WithError(err).WithField("issue_id", issue.ID).Error("Failed to trigger issue webhooks")
+	}
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// IssueLinkService links commits and pull requests to the issues they
+// reference, and closes issues referenced with a closing keyword once the
+// change lands on the default branch
+type IssueLinkService interface {
+	// HandlePush is a PushListener that links commits pushed to the default branch
+	HandlePush(ctx context.Context, event PushEvent)
+	// LinkPullRequest records references made in a pull request's title and body
+	LinkPullRequest(ctx context.Context, pr *models.PullRequest) error
+	// CloseLinkedIssues closes the issues a merged pull request closes
+	CloseLinkedIssues(ctx context.Context, pr *models.PullRequest) error
+
+	ListEvents(ctx context.Context, issueID uuid.UUID) ([]*models.IssueEvent, error)
+}
+
+// IssueReference is an issue mentioned in a commit message or pull request.
+// Owner and Repo are empty for references within the same repository.
+type IssueReference struct {
+	Owner  string
+	Repo   string
+	Number int
+	Closes bool
+}
+
+// issueReferencePattern matches "#123" and "owner/repo#123", optionally
+// preceded by a closing keyword such as "fixes" or "Closes:"
+var issueReferencePattern = regexp.MustCompile(`(?i)(?:^|[^\w/#])(?:(close[sd]?|fix(?:e[sd])?|resolve[sd]?):?\s+)?(?:([\w.-]+)/([\w.-]+))?#(\d+)\b`)
+
+// ParseIssueReferences extracts the issue references from text, once per
+// issue; a reference closes the issue if any mention uses a closing keyword
+func ParseIssueReferences(text string) []IssueReference {
+	var refs []IssueReference
+	index := make(map[string]int)
+	for _, m := range issueReferencePattern.FindAllStringSubmatch(text, -1) {
+		number, err := strconv.Atoi(m[4])
+		if err != nil || number <= 0 {
+			continue
+		}
+		ref := IssueReference{Owner: m[2], Repo: m[3], Number: number, Closes: m[1] != ""}
+
+		key := strings.ToLower(ref.Owner+"/"+ref.Repo) + "#" + m[4]
+		if i, ok := index[key]; ok {
+			refs[i].Closes = refs[i].Closes || ref.Closes
+			continue
+		}
+		index[key] = len(refs)
+		refs = append(refs, ref)
+	}
+	return refs
+}
+
+type issueLinkService struct {
+	db          *gorm.DB
+	gitService  git.GitService
+	repoService RepositoryService
+	logger      *logrus.Logger
+}
+
+// NewIssueLinkService creates a new IssueLinkService
+func NewIssueLinkService(db *gorm.DB, gitService git.GitService, repoService RepositoryService, logger *logrus.Logger) IssueLinkService {
+	return &issueLinkService{
+		db:          db,
+		gitService:  gitService,
+		repoService: repoService,
+		logger:      logger,
+	}
+}
+
+// issueLinkSource is the commit or pull request an issue reference was made from
+type issueLinkSource struct {
+	repository    *models.Repository
+	actorID       *uuid.UUID
+	commitSHA     string
+	pullRequestID *uuid.UUID
+	// landed is true once the change is on the default branch, which is
+	// when closing keywords take effect
+	landed bool
+}
+
+func (s *issueLinkService) HandlePush(ctx context.Context, event PushEvent) {
+	if event.Repository == nil {
+		return
+	}
+	logger := s.logger.WithField("repository_id", event.Repository.ID)
+
+	for _, update := range event.Updates {
+		// A newly created default branch carries the whole history; only
+		// commits added to an existing default branch are linked
+		if update.BranchName() != event.Repository.DefaultBranch || update.IsDelete() || update.OldSHA == zeroSHA {
+			continue
+		}
+
+		repoPath, err := s.repoService.GetRepositoryPath(ctx, event.Repository.ID)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to resolve repository path for issue links")
+			return
+		}
+		comparison, err := s.gitService.CompareRefs(repoPath, update.OldSHA, update.NewSHA)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to list pushed commits for issue links")
+			continue
+		}
+
+		for _, commit := range comparison.Commits {
+			source := issueLinkSource{
+				repository: event.Repository,
+				actorID:    s.userByEmail(ctx, commit.Author.Email),
+				commitSHA:  commit.SHA,
+				landed:     true,
+			}
+			if err := s.link(ctx, source, ParseIssueReferences(commit.Message)); err != nil {
+				logger.WithError(err).WithField("sha", commit.SHA).Warn("Failed to link commit to issues")
+			}
+		}
+	}
+}
+
+func (s *issueLinkService) LinkPullRequest(ctx context.Context, pr *models.PullRequest) error {
+	repo, err := s.pullRequestRepository(ctx, pr)
+	if err != nil {
+		return err
+	}
+	refs := ParseIssueReferences(pr.Title + "\n" + pr.Body)
+	if pr.BaseBranch != repo.DefaultBranch {
+		// Closing keywords only take effect on the default branch
+		for i := range refs {
+			refs[i].Closes = false
+		}
+	}
+	source := issueLinkSource{repository: repo, actorID: pr.UserID, pullRequestID: &pr.ID}
+	return s.link(ctx, source, refs)
+}
+
+func (s *issueLinkService) CloseLinkedIssues(ctx context.Context, pr *models.PullRequest) error {
+	repo, err := s.pullRequestRepository(ctx, pr)
+	if err != nil {
+		return err
+	}
+	if pr.BaseBranch != repo.DefaultBranch {
+		return nil
+	}
+	source := issueLinkSource{repository: repo, pullRequestID: &pr.ID, landed: true}
+	return s.link(ctx, source, ParseIssueReferences(pr.Title+"\n"+pr.Body))
+}
+
+func (s *issueLinkService) ListEvents(ctx context.Context, issueID uuid.UUID) ([]*models.IssueEvent, error) {
+	var events []*models.IssueEvent
+	err := s.db.WithContext(ctx).Preload("Actor").
+		Where("issue_id = ?", issueID).
+		Order("created_at ASC").
+		Find(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list issue events: %w", err)
+	}
+	return events, nil
+}
+
+func (s *issueLinkService) pullRequestRepository(ctx context.Context, pr *models.PullRequest) (*models.Repository, error) {
+	var repo models.Repository
+	if err := s.db.WithContext(ctx).First(&repo, "id = ?", pr.RepositoryID).Error; err != nil {
+		return nil, fmt.Errorf("repository not found: %w", err)
+	}
+	return &repo, nil
+}
+
+// link records a cross-reference on every referenced issue and, once the
+// source has landed, closes the issues referenced with a closing keyword
+func (s *issueLinkService) link(ctx context.Context, source issueLinkSource, refs []IssueReference) error {
+	for _, ref := range refs {
+		issue, target, err := s.resolve(ctx, source.repository, ref)
+		if err != nil {
+			return err
+		}
+		if issue == nil {
+			continue
+		}
+
+		// Closing across repositories is limited to repositories of the same owner
+		closes := ref.Closes && target.AutoCloseIssues && target.OwnerID == source.repository.OwnerID
+		if err := s.recordEvent(ctx, issue, models.IssueEventCrossReferenced, source, closes); err != nil {
+			return err
+		}
+		if closes && source.landed && issue.State == models.IssueStateOpen {
+			if err := s.closeIssue(ctx, issue, source); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolve finds the issue a reference points at, or nil if it does not
+// exist or the reference would disclose a private repository's changes
+func (s *issueLinkService) resolve(ctx context.Context, source *models.Repository, ref IssueReference) (*models.Issue, *models.Repository, error) {
+	target := source
+	if ref.Owner != "" {
+		repo, err := s.repoService.Get(ctx, ref.Owner, ref.Repo)
+		if err != nil {
+			return nil, nil, nil
+		}
+		// A private repository's commits are only linked to its owner's repositories
+		if repo.ID != source.ID && source.Visibility != models.VisibilityPublic && repo.OwnerID != source.OwnerID {
+			return nil, nil, nil
+		}
+		target = repo
+	}
+
+	var issue models.Issue
+	err := s.db.WithContext(ctx).Where("repository_id = ? AND number = ?", target.ID, ref.Number).First(&issue).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load issue: %w", err)
+	}
+	return &issue, target, nil
+}
+
+// recordEvent adds a timeline event unless the same source already recorded it
+func (s *issueLinkService) recordEvent(ctx context.Context, issue *models.Issue, kind models.IssueEventType, source issueLinkSource, willClose bool) error {
+	query := s.db.WithContext(ctx).Model(&models.IssueEvent{}).
+		Where("issue_id = ? AND event = ? AND commit_sha = ?", issue.ID, kind, source.commitSHA)
+	if source.pullRequestID != nil {
+		query = query.Where("pull_request_id = ?", *source.pullRequestID)
+	} else {
+		query = query.Where("pull_request_id IS NULL")
+	}
+
+	var existing models.IssueEvent
+	err := query.First(&existing).Error
+	if err == nil {
+		if existing.WillClose != willClose && kind == models.IssueEventCrossReferenced {
+			return s.db.WithContext(ctx).Model(&existing).Update("will_close", willClose).Error
+		}
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to check issue events: %w", err)
+	}
+
+	event := &models.IssueEvent{
+		ID:                 uuid.New(),
+		IssueID:            issue.ID,
+		Event:              kind,
+		ActorID:            source.actorID,
+		SourceRepositoryID: source.repository.ID,
+		CommitSHA:          source.commitSHA,
+		PullRequestID:      source.pullRequestID,
+		WillClose:          willClose,
+	}
+	if err := s.db.WithContext(ctx).Create(event).Error; err != nil {
+		return fmt.Errorf("failed to record issue event: %w", err)
+	}
+	return nil
+}
+
+func (s *issueLinkService) closeIssue(ctx context.Context, issue *models.Issue, source issueLinkSource) error {
+	now := time.Now()
+	err := s.db.WithContext(ctx).Model(&models.Issue{}).
+		Where("id = ? AND state = ?", issue.ID, models.IssueStateOpen).
+		Updates(map[string]interface{}{
+			"state":        models.IssueStateClosed,
+			"closed_at":    now,
+			"closed_by_id": source.actorID,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to close issue: %w", err)
+	}
+	issue.State = models.IssueStateClosed
+	issue.ClosedAt = &now
+	issue.ClosedByID = source.actorID
+
+	return s.recordEvent(ctx, issue, models.IssueEventClosed, source, false)
+}
+
+// userByEmail returns the ID of the user with the given email, if any
+func (s *issueLinkService) userByEmail(ctx context.Context, email string) *uuid.UUID {
+	var user models.User
+	if err := s.db.WithContext(ctx).Select("id").Where("LOWER(email) = ?", strings.ToLower(email)).First(&user).Error; err != nil {
+		return nil
+	}
+	return &user.ID
+}
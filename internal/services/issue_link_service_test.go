@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIssueReferences(t *testing.T) {
+	refs := ParseIssueReferences("Fixes #12, closes acme/api#45 and see #7.\nResolved: #12\nfoo#99 http://x/y#3 #0")
+	assert.Equal(t, []IssueReference{
+		{Number: 12, Closes: true},
+		{Owner: "acme", Repo: "api", Number: 45, Closes: true},
+		{Number: 7},
+	}, refs)
+
+	assert.Empty(t, ParseIssueReferences("no references here"))
+	assert.Equal(t, []IssueReference{{Number: 3}, {Number: 3, Owner: "a", Repo: "b"}}, ParseIssueReferences("prefixes #3 and a/b#3"))
+}
+
+func TestIssueLinkService_PullRequestClosesIssuesOnMerge(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.Issue{}, &models.PullRequest{}, &models.IssueEvent{})
+
+	ownerID := uuid.New()
+	repo := &models.Repository{ID: uuid.New(), OwnerID: ownerID, OwnerType: models.OwnerTypeUser, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+
+	fixed := &models.Issue{ID: uuid.New(), RepositoryID: repo.ID, Number: 1, Title: "bug", State: models.IssueStateOpen}
+	mentioned := &models.Issue{ID: uuid.New(), RepositoryID: repo.ID, Number: 2, Title: "related", State: models.IssueStateOpen}
+	require.NoError(t, db.Create([]*models.Issue{fixed, mentioned}).Error)
+
+	author := uuid.New()
+	pr := &models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 1, Title: "Fix bug",
+		Body: "Fixes #1, see #2 and #404", UserID: &author, BaseBranch: "main", HeadBranch: "fix", State: models.PullRequestStateOpen}
+	require.NoError(t, db.Create(pr).Error)
+
+	svc := NewIssueLinkService(db, nil, nil, logrus.New())
+	ctx := context.Background()
+
+	require.NoError(t, svc.LinkPullRequest(ctx, pr))
+	require.NoError(t, svc.LinkPullRequest(ctx, pr), "linking again does not duplicate events")
+
+	events, err := svc.ListEvents(ctx, fixed.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.IssueEventCrossReferenced, events[0].Event)
+	assert.True(t, events[0].WillClose)
+	assert.Equal(t, &author, events[0].ActorID)
+
+	require.NoError(t, svc.CloseLinkedIssues(ctx, pr))
+
+	var closed, open models.Issue
+	require.NoError(t, db.First(&closed, "id = ?", fixed.ID).Error)
+	assert.Equal(t, models.IssueStateClosed, closed.State)
+	assert.NotNil(t, closed.ClosedAt)
+	require.NoError(t, db.First(&open, "id = ?", mentioned.ID).Error)
+	assert.Equal(t, models.IssueStateOpen, open.State)
+
+	events, err = svc.ListEvents(ctx, fixed.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, models.IssueEventClosed, events[1].Event)
+	assert.Equal(t, &pr.ID, events[1].PullRequestID)
+}
+
+func TestIssueLinkService_AutoCloseDisabled(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.Issue{}, &models.PullRequest{}, &models.IssueEvent{})
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPublic}
+	require.NoError(t, db.Create(repo).Error)
+	require.NoError(t, db.Model(repo).Update("auto_close_issues", false).Error)
+
+	issue := &models.Issue{ID: uuid.New(), RepositoryID: repo.ID, Number: 1, Title: "bug", State: models.IssueStateOpen}
+	require.NoError(t, db.Create(issue).Error)
+	pr := &models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 1, Title: "closes #1",
+		BaseBranch: "main", HeadBranch: "fix", State: models.PullRequestStateMerged}
+	require.NoError(t, db.Create(pr).Error)
+
+	svc := NewIssueLinkService(db, nil, nil, logrus.New())
+	require.NoError(t, svc.CloseLinkedIssues(context.Background(), pr))
+
+	var reloaded models.Issue
+	require.NoError(t, db.First(&reloaded, "id = ?", issue.ID).Error)
+	assert.Equal(t, models.IssueStateOpen, reloaded.State)
+
+	events, err := svc.ListEvents(context.Background(), issue.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.False(t, events[0].WillClose)
+}
@@ -0,0 +1,550 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrIssueNotFound = errors.New("issue not found")
+	ErrInvalidIssue  = errors.New("invalid issue")
+)
+
+type CreateIssueRequest struct {
+	Title string `json:"title" binding:"required"`
+	Body  string `json:"body"`
+	// Labels are label names and Assignees usernames; Milestone is a
+	// milestone number of the repository
+	Labels    []string `json:"labels,omitempty"`
+	Assignees []string `json:"assignees,omitempty"`
+	Milestone *int     `json:"milestone,omitempty"`
+}
+
+type UpdateIssueRequest struct {
+	Title *string            `json:"title,omitempty"`
+	Body  *string            `json:"body,omitempty"`
+	State *models.IssueState `json:"state,omitempty"`
+	// Milestone is a milestone number of the issue's repository; 0 clears it
+	Milestone *int `json:"milestone,omitempty"`
+	// Labels and Assignees replace the issue's labels and assignees when set
+	Labels    *[]string `json:"labels,omitempty"`
+	Assignees *[]string `json:"assignees,omitempty"`
+}
+
+type CreateIssueCommentRequest struct {
+	Body string `json:"body" binding:"required"`
+}
+
+// TransferIssueRequest moves an issue to another repository, named
+// "owner/name"
+type TransferIssueRequest struct {
+	Repository string `json:"repository" binding:"required"`
+}
+
+// IssueTransferResult is a transferred issue and the labels that had no
+// counterpart in the new repository
+type IssueTransferResult struct {
+	Issue         *models.Issue `json:"issue"`
+	DroppedLabels []string      `json:"dropped_labels"`
+}
+
+// Filter values of IssueFilter.Assignee and IssueFilter.Milestone
+const (
+	IssueFilterNone = "none"
+	IssueFilterAny  = "*"
+)
+
+type IssueFilter struct {
+	State *models.IssueState `json:"state,omitempty"`
+	// Labels are label names the issues must all have
+	Labels []string `json:"labels,omitempty"`
+	// Assignee is a username, or IssueFilterNone or IssueFilterAny
+	Assignee string `json:"assignee,omitempty"`
+	// Milestone is a milestone number, or IssueFilterNone or IssueFilterAny
+	Milestone string `json:"milestone,omitempty"`
+	Page      int    `json:"page,omitempty"`
+	PageSize  int    `json:"page_size,omitempty"`
+}
+
+// IssueService manages repository issues
+type IssueService interface {
+	List(ctx context.Context, repoID uuid.UUID, filter IssueFilter) ([]*models.Issue, error)
+	Get(ctx context.Context, repoID uuid.UUID, number int) (*models.Issue, error)
+	Create(ctx context.Context, repoID, userID uuid.UUID, req CreateIssueRequest) (*models.Issue, error)
+	// Update edits an issue; closing it records who closed it and when
+	Update(ctx context.Context, issue *models.Issue, userID uuid.UUID, req UpdateIssueRequest) (*models.Issue, error)
+	ListComments(ctx context.Context, issue *models.Issue, page, pageSize int) ([]*models.Comment, error)
+	CreateComment(ctx context.Context, issue *models.Issue, userID uuid.UUID, req CreateIssueCommentRequest) (*models.Comment, error)
+	// Resolve is Get that follows the redirects left by transfers; the
+	// returned issue may belong to another repository
+	Resolve(ctx context.Context, repoID uuid.UUID, number int) (*models.Issue, error)
+	// Transfer moves an issue with its comments and history to target. Labels
+	// and the milestone are matched by name in the target repository, and the
+	// old number redirects to the issue.
+	Transfer(ctx context.Context, issue *models.Issue, target *models.Repository, userID uuid.UUID) (*IssueTransferResult, error)
+	// Subscribe registers a listener for issues being opened, edited,
+	// closed and reopened
+	Subscribe(listener IssueListener)
+}
+
+type issueService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+
+	mu        sync.RWMutex
+	listeners []IssueListener
+}
+
+// NewIssueService creates a new IssueService
+func NewIssueService(db *gorm.DB, logger *logrus.Logger) IssueService {
+	return &issueService{db: db, logger: logger}
+}
+
+func (s *issueService) List(ctx context.Context, repoID uuid.UUID, filter IssueFilter) ([]*models.Issue, error) {
+	query := s.db.WithContext(ctx).Where("repository_id = ?", repoID)
+	if filter.State != nil {
+		query = query.Where("state = ?", *filter.State)
+	}
+	for _, name := range filter.Labels {
+		query = query.Where("id IN (?)", s.db.Table("issue_labels").Select("issue_labels.issue_id").
+			Joins("JOIN labels ON labels.id = issue_labels.label_id").
+			Where("labels.repository_id = ? AND labels.name = ?", repoID, name))
+	}
+	switch filter.Assignee {
+	case "":
+	case IssueFilterNone:
+		query = query.Where("id NOT IN (?)", s.db.Table("issue_assignees").Select("issue_id"))
+	case IssueFilterAny:
+		query = query.Where("id IN (?)", s.db.Table("issue_assignees").Select("issue_id"))
+	default:
+		query = query.Where("id IN (?)", s.db.Table("issue_assignees").Select("issue_assignees.issue_id").
+			Joins("JOIN users ON users.id = issue_assignees.user_id").
+			Where("users.username = ?", filter.Assignee))
+	}
+	switch filter.Milestone {
+	case "":
+	case IssueFilterNone:
+		query = query.Where("milestone_id IS NULL")
+	case IssueFilterAny:
+		query = query.Where("milestone_id IS NOT NULL")
+	default:
+		number, err := strconv.Atoi(filter.Milestone)
+		if err != nil {
+			return nil, fmt.Errorf("%w: milestone must be a number, %q or %q", ErrInvalidIssue, IssueFilterNone, IssueFilterAny)
+		}
+		query = query.Where("milestone_id IN (?)", s.db.Model(&models.Milestone{}).Select("id").
+			Where("repository_id = ? AND number = ?", repoID, number))
+	}
+
+	pageSize := 30
+	if filter.PageSize > 0 {
+		pageSize = filter.PageSize
+	}
+	offset := 0
+	if filter.Page > 1 {
+		offset = (filter.Page - 1) * pageSize
+	}
+
+	var issues []*models.Issue
+	if err := preloadIssueMetadata(query).Order("number DESC").Limit(pageSize).Offset(offset).Find(&issues).Error; err != nil {
+		return nil, fmt.Errorf("failed to list issues: %w", err)
+	}
+	return issues, nil
+}
+
+func (s *issueService) Get(ctx context.Context, repoID uuid.UUID, number int) (*models.Issue, error) {
+	var issue models.Issue
+	err := preloadIssueMetadata(s.db.WithContext(ctx)).
+		Where("repository_id = ? AND number = ?", repoID, number).
+		First(&issue).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrIssueNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get issue: %w", err)
+	}
+	return &issue, nil
+}
+
+// preloadIssueMetadata loads the author, labels, assignees and milestone
+// of issues
+func preloadIssueMetadata(query *gorm.DB) *gorm.DB {
+	return query.Preload("User").Preload("Labels").Preload("Assignees").Preload("Milestone")
+}
+
+func (s *issueService) Create(ctx context.Context, repoID, userID uuid.UUID, req CreateIssueRequest) (*models.Issue, error) {
+	title := strings.TrimSpace(req.Title)
+	if title == "" || len(title) > 255 {
+		return nil, fmt.Errorf("%w: title must be between 1 and 255 characters", ErrInvalidIssue)
+	}
+	labels, err := s.findLabels(ctx, repoID, req.Labels)
+	if err != nil {
+		return nil, err
+	}
+	assignees, err := s.findUsers(ctx, req.Assignees)
+	if err != nil {
+		return nil, err
+	}
+	var milestoneID *uuid.UUID
+	if req.Milestone != nil && *req.Milestone != 0 {
+		milestone, err := s.findMilestone(ctx, repoID, *req.Milestone)
+		if err != nil {
+			return nil, err
+		}
+		milestoneID = &milestone.ID
+	}
+
+	issue := &models.Issue{
+		ID:           uuid.New(),
+		RepositoryID: repoID,
+		Title:        title,
+		Body:         req.Body,
+		UserID:       &userID,
+		State:        models.IssueStateOpen,
+		MilestoneID:  milestoneID,
+		Labels:       labels,
+		Assignees:    assignees,
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		number, err := nextIssueNumber(tx, repoID)
+		if err != nil {
+			return err
+		}
+		issue.Number = number
+		return tx.Omit("Labels.*", "Assignees.*").Create(issue).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create issue: %w", err)
+	}
+	s.emit(IssueEvent{Action: IssueActionOpened, Issue: issue, ActorID: userID})
+	return issue, nil
+}
+
+// findUsers loads users by username, failing on any that does not exist
+func (s *issueService) findUsers(ctx context.Context, usernames []string) ([]models.User, error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	var users []models.User
+	if err := s.db.WithContext(ctx).Where("username IN ?", usernames).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to find users: %w", err)
+	}
+	found := make(map[string]bool, len(users))
+	for _, user := range users {
+		found[user.Username] = true
+	}
+	for _, username := range usernames {
+		if !found[username] {
+			return nil, fmt.Errorf("%w: user %q not found", ErrInvalidIssue, username)
+		}
+	}
+	return users, nil
+}
+
+// findLabels loads labels of the repository by name
+func (s *issueService) findLabels(ctx context.Context, repoID uuid.UUID, names []string) ([]models.Label, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var labels []models.Label
+	if err := s.db.WithContext(ctx).Where("repository_id = ? AND name IN ?", repoID, names).Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("failed to find labels: %w", err)
+	}
+	found := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		found[label.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("%w: label %q not found", ErrInvalidIssue, name)
+		}
+	}
+	return labels, nil
+}
+
+// findMilestone loads a milestone of the repository by number
+func (s *issueService) findMilestone(ctx context.Context, repoID uuid.UUID, number int) (*models.Milestone, error) {
+	var milestone models.Milestone
+	err := s.db.WithContext(ctx).Select("id").
+		Where("repository_id = ? AND number = ?", repoID, number).First(&milestone).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: milestone %d does not exist", ErrInvalidIssue, number)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get milestone: %w", err)
+	}
+	return &milestone, nil
+}
+
+func (s *issueService) Update(ctx context.Context, issue *models.Issue, userID uuid.UUID, req UpdateIssueRequest) (*models.Issue, error) {
+	updates := map[string]interface{}{}
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" || len(title) > 255 {
+			return nil, fmt.Errorf("%w: title must be between 1 and 255 characters", ErrInvalidIssue)
+		}
+		updates["title"] = title
+	}
+	if req.Body != nil {
+		updates["body"] = *req.Body
+	}
+	if req.State != nil && *req.State != issue.State {
+		switch *req.State {
+		case models.IssueStateClosed:
+			updates["state"] = models.IssueStateClosed
+			updates["closed_at"] = time.Now()
+			updates["closed_by_id"] = userID
+		case models.IssueStateOpen:
+			updates["state"] = models.IssueStateOpen
+			updates["closed_at"] = nil
+			updates["closed_by_id"] = nil
+		default:
+			return nil, fmt.Errorf("%w: state must be open or closed", ErrInvalidIssue)
+		}
+	}
+	if req.Milestone != nil {
+		if *req.Milestone == 0 {
+			updates["milestone_id"] = nil
+		} else {
+			milestone, err := s.findMilestone(ctx, issue.RepositoryID, *req.Milestone)
+			if err != nil {
+				return nil, err
+			}
+			updates["milestone_id"] = milestone.ID
+		}
+	}
+	var labels []models.Label
+	if req.Labels != nil {
+		var err error
+		if labels, err = s.findLabels(ctx, issue.RepositoryID, *req.Labels); err != nil {
+			return nil, err
+		}
+	}
+	var assignees []models.User
+	if req.Assignees != nil {
+		var err error
+		if assignees, err = s.findUsers(ctx, *req.Assignees); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(updates) == 0 && req.Labels == nil && req.Assignees == nil {
+		return s.Get(ctx, issue.RepositoryID, issue.Number)
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(issue).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.Labels != nil {
+			if err := tx.Model(issue).Omit("Labels.*").Association("Labels").Replace(labels); err != nil {
+				return err
+			}
+		}
+		if req.Assignees != nil {
+			if err := tx.Model(issue).Omit("Assignees.*").Association("Assignees").Replace(assignees); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update issue: %w", err)
+	}
+	updated, err := s.Get(ctx, issue.RepositoryID, issue.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	action := IssueActionEdited
+	if state, ok := updates["state"]; ok {
+		action = IssueActionReopened
+		if state == models.IssueStateClosed {
+			action = IssueActionClosed
+		}
+	}
+	s.emit(IssueEvent{Action: action, Issue: updated, ActorID: userID})
+	return updated, nil
+}
+
+func (s *issueService) ListComments(ctx context.Context, issue *models.Issue, page, pageSize int) ([]*models.Comment, error) {
+	if pageSize <= 0 {
+		pageSize = 30
+	}
+	offset := 0
+	if page > 1 {
+		offset = (page - 1) * pageSize
+	}
+
+	var comments []*models.Comment
+	if err := s.db.WithContext(ctx).Preload("User").Where("issue_id = ?", issue.ID).
+		Order("created_at ASC").Limit(pageSize).Offset(offset).Find(&comments).Error; err != nil {
+		return nil, fmt.Errorf("failed to list comments: %w", err)
+	}
+	return comments, nil
+}
+
+func (s *issueService) CreateComment(ctx context.Context, issue *models.Issue, userID uuid.UUID, req CreateIssueCommentRequest) (*models.Comment, error) {
+	if strings.TrimSpace(req.Body) == "" {
+		return nil, fmt.Errorf("%w: comment body must not be empty", ErrInvalidIssue)
+	}
+
+	comment := &models.Comment{
+		ID:      uuid.New(),
+		IssueID: &issue.ID,
+		UserID:  &userID,
+		Body:    req.Body,
+	}
+	if err := s.db.WithContext(ctx).Create(comment).Error; err != nil {
+		return nil, fmt.Errorf("failed to create comment: %w", err)
+	}
+	if err := s.db.WithContext(ctx).Preload("User").First(comment, "id = ?", comment.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get comment: %w", err)
+	}
+	s.emit(IssueEvent{Action: IssueActionCommented, Issue: issue, Comment: comment, ActorID: userID})
+	return comment, nil
+}
+
+// nextIssueNumber returns the next free issue number of a repository.
+// Numbers of deleted and transferred issues are never reused.
+func nextIssueNumber(tx *gorm.DB, repoID uuid.UUID) (int, error) {
+	var lastIssue, lastRedirect int
+	if err := tx.Model(&models.Issue{}).Unscoped().Where("repository_id = ?", repoID).
+		Select("COALESCE(MAX(number), 0)").Scan(&lastIssue).Error; err != nil {
+		return 0, err
+	}
+	if err := tx.Model(&models.IssueRedirect{}).Where("repository_id = ?", repoID).
+		Select("COALESCE(MAX(number), 0)").Scan(&lastRedirect).Error; err != nil {
+		return 0, err
+	}
+	return max(lastIssue, lastRedirect) + 1, nil
+}
+
+func (s *issueService) Resolve(ctx context.Context, repoID uuid.UUID, number int) (*models.Issue, error) {
+	issue, err := s.Get(ctx, repoID, number)
+	if !errors.Is(err, ErrIssueNotFound) {
+		return issue, err
+	}
+
+	var redirect models.IssueRedirect
+	err = s.db.WithContext(ctx).Where("repository_id = ? AND number = ?", repoID, number).First(&redirect).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrIssueNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get issue redirect: %w", err)
+	}
+
+	var moved models.Issue
+	err = s.db.WithContext(ctx).Preload("User").First(&moved, "id = ?", redirect.IssueID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrIssueNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get issue: %w", err)
+	}
+	return &moved, nil
+}
+
+func (s *issueService) Transfer(ctx context.Context, issue *models.Issue, target *models.Repository, userID uuid.UUID) (*IssueTransferResult, error) {
+	if target.ID == issue.RepositoryID {
+		return nil, fmt.Errorf("%w: issue is already in %s", ErrInvalidIssue, target.Name)
+	}
+
+	result := &IssueTransferResult{DroppedLabels: []string{}}
+	var number int
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if number, err = nextIssueNumber(tx, target.ID); err != nil {
+			return err
+		}
+
+		// Keep the labels the target repository also has, matched by name
+		var labels []models.Label
+		if err := tx.Joins("JOIN issue_labels ON issue_labels.label_id = labels.id").
+			Where("issue_labels.issue_id = ?", issue.ID).Find(&labels).Error; err != nil {
+			return err
+		}
+		var mapped []models.IssueLabel
+		for _, label := range labels {
+			var counterpart models.Label
+			err := tx.Where("repository_id = ? AND LOWER(name) = LOWER(?)", target.ID, label.Name).First(&counterpart).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				result.DroppedLabels = append(result.DroppedLabels, label.Name)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			mapped = append(mapped, models.IssueLabel{IssueID: issue.ID, LabelID: counterpart.ID})
+		}
+		if err := tx.Where("issue_id = ?", issue.ID).Delete(&models.IssueLabel{}).Error; err != nil {
+			return err
+		}
+		if len(mapped) > 0 {
+			if err := tx.Create(&mapped).Error; err != nil {
+				return err
+			}
+		}
+
+		// Likewise the milestone, by title
+		var milestoneID *uuid.UUID
+		if issue.MilestoneID != nil {
+			var milestone, counterpart models.Milestone
+			if err := tx.Select("title").First(&milestone, "id = ?", *issue.MilestoneID).Error; err == nil {
+				if err := tx.Select("id").Where("repository_id = ? AND title = ?", target.ID, milestone.Title).
+					First(&counterpart).Error; err == nil {
+					milestoneID = &counterpart.ID
+				}
+			}
+		}
+
+		if err := tx.Model(&models.Issue{}).Where("id = ?", issue.ID).Updates(map[string]interface{}{
+			"repository_id": target.ID,
+			"number":        number,
+			"milestone_id":  milestoneID,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.IssueRedirect{
+			ID:           uuid.New(),
+			RepositoryID: issue.RepositoryID,
+			Number:       issue.Number,
+			IssueID:      issue.ID,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.IssueEvent{
+			ID:                 uuid.New(),
+			IssueID:            issue.ID,
+			Event:              models.IssueEventTransferred,
+			ActorID:            &userID,
+			SourceRepositoryID: issue.RepositoryID,
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to transfer issue: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"issue_id":       issue.ID,
+		"from":           issue.RepositoryID,
+		"to":             target.ID,
+		"dropped_labels": len(result.DroppedLabels),
+	}).Info("Issue transferred")
+	if result.Issue, err = s.Get(ctx, target.ID, number); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssueService_CreateAndClose(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Issue{}, &models.IssueRedirect{}, &models.Label{}, &models.Milestone{})
+
+	ctx := context.Background()
+	svc := NewIssueService(db, logrus.New())
+	repoID, userID := uuid.New(), uuid.New()
+
+	first, err := svc.Create(ctx, repoID, userID, CreateIssueRequest{Title: "  Crash on start "})
+	require.NoError(t, err)
+	second, err := svc.Create(ctx, repoID, userID, CreateIssueRequest{Title: "Docs typo"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, first.Number)
+	assert.Equal(t, "Crash on start", first.Title)
+	assert.Equal(t, 2, second.Number)
+
+	_, err = svc.Create(ctx, repoID, userID, CreateIssueRequest{Title: " "})
+	assert.ErrorIs(t, err, ErrInvalidIssue)
+
+	closed := models.IssueStateClosed
+	updated, err := svc.Update(ctx, first, userID, UpdateIssueRequest{State: &closed})
+	require.NoError(t, err)
+	assert.Equal(t, models.IssueStateClosed, updated.State)
+	require.NotNil(t, updated.ClosedAt)
+	assert.Equal(t, userID, *updated.ClosedByID)
+
+	open := models.IssueStateOpen
+	issues, err := svc.List(ctx, repoID, IssueFilter{State: &open})
+	require.NoError(t, err)
+	require.Len(t, issues, 1)
+	assert.Equal(t, 2, issues[0].Number)
+
+	_, err = svc.Get(ctx, repoID, 3)
+	assert.ErrorIs(t, err, ErrIssueNotFound)
+}
+
+func TestIssueService_Transfer(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.Issue{}, &models.IssueRedirect{},
+		&models.IssueEvent{}, &models.Label{}, &models.IssueLabel{}, &models.Milestone{}, &models.Comment{})
+
+	ctx := context.Background()
+	svc := NewIssueService(db, logrus.New())
+	userID := uuid.New()
+	source := &models.Repository{ID: uuid.New(), OwnerID: userID, OwnerType: models.OwnerTypeUser, Name: "api", Visibility: models.VisibilityPrivate}
+	target := &models.Repository{ID: uuid.New(), OwnerID: userID, OwnerType: models.OwnerTypeUser, Name: "web", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create([]*models.Repository{source, target}).Error)
+
+	_, err := svc.Create(ctx, target.ID, userID, CreateIssueRequest{Title: "Existing"})
+	require.NoError(t, err)
+	_, err = svc.Create(ctx, source.ID, userID, CreateIssueRequest{Title: "First"})
+	require.NoError(t, err)
+	issue, err := svc.Create(ctx, source.ID, userID, CreateIssueRequest{Title: "Login fails"})
+	require.NoError(t, err)
+
+	bug := &models.Label{ID: uuid.New(), RepositoryID: source.ID, Name: "bug"}
+	wontfix := &models.Label{ID: uuid.New(), RepositoryID: source.ID, Name: "wontfix"}
+	targetBug := &models.Label{ID: uuid.New(), RepositoryID: target.ID, Name: "Bug"}
+	require.NoError(t, db.Create([]*models.Label{bug, wontfix, targetBug}).Error)
+	require.NoError(t, db.Create([]models.IssueLabel{{IssueID: issue.ID, LabelID: bug.ID}, {IssueID: issue.ID, LabelID: wontfix.ID}}).Error)
+	milestone := &models.Milestone{ID: uuid.New(), RepositoryID: source.ID, Number: 1, Title: "v1"}
+	targetMilestone := &models.Milestone{ID: uuid.New(), RepositoryID: target.ID, Number: 1, Title: "v1"}
+	require.NoError(t, db.Create([]*models.Milestone{milestone, targetMilestone}).Error)
+	require.NoError(t, db.Model(issue).Update("milestone_id", milestone.ID).Error)
+	require.NoError(t, db.Create(&models.Comment{ID: uuid.New(), IssueID: &issue.ID, UserID: &userID, Body: "Seen on staging"}).Error)
+
+	_, err = svc.Transfer(ctx, issue, source, userID)
+	assert.ErrorIs(t, err, ErrInvalidIssue)
+
+	result, err := svc.Transfer(ctx, issue, target, userID)
+	require.NoError(t, err)
+	assert.Equal(t, issue.ID, result.Issue.ID)
+	assert.Equal(t, target.ID, result.Issue.RepositoryID)
+	assert.Equal(t, 2, result.Issue.Number)
+	assert.Equal(t, &targetMilestone.ID, result.Issue.MilestoneID)
+	assert.Equal(t, []string{"wontfix"}, result.DroppedLabels)
+
+	var labelIDs []uuid.UUID
+	require.NoError(t, db.Model(&models.IssueLabel{}).Where("issue_id = ?", issue.ID).Pluck("label_id", &labelIDs).Error)
+	assert.Equal(t, []uuid.UUID{targetBug.ID}, labelIDs)
+	var comments int64
+	require.NoError(t, db.Model(&models.Comment{}).Where("issue_id = ?", issue.ID).Count(&comments).Error)
+	assert.Equal(t, int64(1), comments)
+	var event models.IssueEvent
+	require.NoError(t, db.Where("issue_id = ?", issue.ID).First(&event).Error)
+	assert.Equal(t, models.IssueEventTransferred, event.Event)
+	assert.Equal(t, source.ID, event.SourceRepositoryID)
+
+	// The old number redirects and is never handed out again
+	_, err = svc.Get(ctx, source.ID, 2)
+	assert.ErrorIs(t, err, ErrIssueNotFound)
+	resolved, err := svc.Resolve(ctx, source.ID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, issue.ID, resolved.ID)
+	next, err := svc.Create(ctx, source.ID, userID, CreateIssueRequest{Title: "Another"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, next.Number)
+	_, err = svc.Resolve(ctx, source.ID, 9)
+	assert.ErrorIs(t, err, ErrIssueNotFound)
+}
+
+func TestIssueService_LabelsAssigneesAndMilestones(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Issue{}, &models.IssueRedirect{},
+		&models.Label{}, &models.IssueLabel{}, &models.Milestone{}, &models.Comment{})
+
+	ctx := context.Background()
+	svc := NewIssueService(db, logrus.New())
+	labels := NewLabelService(db, logrus.New())
+	milestones := NewMilestoneService(db, logrus.New())
+	repoID := uuid.New()
+	jane := &models.User{ID: uuid.New(), Username: "jane", Email: "jane@example.com", PasswordHash: "x"}
+	john := &models.User{ID: uuid.New(), Username: "john", Email: "john@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create([]*models.User{jane, john}).Error)
+
+	bug, err := labels.Create(ctx, repoID, CreateLabelRequest{Name: "bug", Color: "D73A4A"})
+	require.NoError(t, err)
+	assert.Equal(t, "#d73a4a", bug.Color)
+	_, err = labels.Create(ctx, repoID, CreateLabelRequest{Name: "Bug"})
+	assert.ErrorIs(t, err, ErrLabelExists)
+	_, err = labels.Create(ctx, repoID, CreateLabelRequest{Name: "ui", Color: "blue"})
+	assert.ErrorIs(t, err, ErrInvalidLabel)
+	ui, err := labels.Create(ctx, repoID, CreateLabelRequest{Name: "ui"})
+	require.NoError(t, err)
+	assert.Equal(t, defaultLabelColor, ui.Color)
+	v1, err := milestones.Create(ctx, repoID, CreateMilestoneRequest{Title: "v1"})
+	require.NoError(t, err)
+
+	crash, err := svc.Create(ctx, repoID, jane.ID, CreateIssueRequest{
+		Title: "Crash", Labels: []string{"bug", "ui"}, Assignees: []string{"john"}, Milestone: &v1.Number,
+	})
+	require.NoError(t, err)
+	_, err = svc.Create(ctx, repoID, jane.ID, CreateIssueRequest{Title: "Typo", Labels: []string{"ui"}})
+	require.NoError(t, err)
+	_, err = svc.Create(ctx, repoID, jane.ID, CreateIssueRequest{Title: "Bad", Labels: []string{"missing"}})
+	assert.ErrorIs(t, err, ErrInvalidIssue)
+	_, err = svc.Create(ctx, repoID, jane.ID, CreateIssueRequest{Title: "Bad", Assignees: []string{"nobody"}})
+	assert.ErrorIs(t, err, ErrInvalidIssue)
+
+	got, err := svc.Get(ctx, repoID, crash.Number)
+	require.NoError(t, err)
+	assert.Len(t, got.Labels, 2)
+	require.Len(t, got.Assignees, 1)
+	assert.Equal(t, "john", got.Assignees[0].Username)
+	require.NotNil(t, got.Milestone)
+	assert.Equal(t, "v1", got.Milestone.Title)
+
+	numbers := func(filter IssueFilter) []int {
+		issues, err := svc.List(ctx, repoID, filter)
+		require.NoError(t, err)
+		var numbers []int
+		for _, issue := range issues {
+			numbers = append(numbers, issue.Number)
+		}
+		return numbers
+	}
+	assert.Equal(t, []int{2, 1}, numbers(IssueFilter{Labels: []string{"ui"}}))
+	assert.Equal(t, []int{1}, numbers(IssueFilter{Labels: []string{"ui", "bug"}}))
+	assert.Equal(t, []int{1}, numbers(IssueFilter{Assignee: "john"}))
+	assert.Empty(t, numbers(IssueFilter{Assignee: "jane"}))
+	assert.Equal(t, []int{2}, numbers(IssueFilter{Assignee: IssueFilterNone}))
+	assert.Equal(t, []int{1}, numbers(IssueFilter{Milestone: "1"}))
+	assert.Equal(t, []int{2}, numbers(IssueFilter{Milestone: IssueFilterNone}))
+	assert.Equal(t, []int{1}, numbers(IssueFilter{Milestone: IssueFilterAny}))
+	_, err = svc.List(ctx, repoID, IssueFilter{Milestone: "v1"})
+	assert.ErrorIs(t, err, ErrInvalidIssue)
+
+	none := []string{}
+	assignees := []string{"jane"}
+	updated, err := svc.Update(ctx, got, jane.ID, UpdateIssueRequest{Labels: &none, Assignees: &assignees})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Labels)
+	require.Len(t, updated.Assignees, 1)
+	assert.Equal(t, "jane", updated.Assignees[0].Username)
+
+	require.NoError(t, labels.Delete(ctx, ui))
+	assert.Empty(t, numbers(IssueFilter{Labels: []string{"ui"}}))
+	require.NoError(t, milestones.Delete(ctx, v1))
+	assert.Equal(t, []int{2, 1}, numbers(IssueFilter{Milestone: IssueFilterNone}))
+}
+
+func TestIssueService_Comments(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Issue{}, &models.IssueRedirect{},
+		&models.Label{}, &models.Milestone{}, &models.Comment{})
+
+	ctx := context.Background()
+	svc := NewIssueService(db, logrus.New())
+	jane := &models.User{ID: uuid.New(), Username: "jane", Email: "jane@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(jane).Error)
+	issue, err := svc.Create(ctx, uuid.New(), jane.ID, CreateIssueRequest{Title: "Crash"})
+	require.NoError(t, err)
+
+	_, err = svc.CreateComment(ctx, issue, jane.ID, CreateIssueCommentRequest{Body: "  "})
+	assert.ErrorIs(t, err, ErrInvalidIssue)
+	comment, err := svc.CreateComment(ctx, issue, jane.ID, CreateIssueCommentRequest{Body: "Seen it too"})
+	require.NoError(t, err)
+	require.NotNil(t, comment.User)
+	assert.Equal(t, "jane", comment.User.Username)
+
+	comments, err := svc.ListComments(ctx, issue, 1, 0)
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	assert.Equal(t, "Seen it too", comments[0].Body)
+}
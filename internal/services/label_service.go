@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrLabelNotFound = errors.New("label not found")
+	ErrLabelExists   = errors.New("label already exists")
+	ErrInvalidLabel  = errors.New("invalid label")
+)
+
+// defaultLabelColor is used for labels created without a color
+const defaultLabelColor = "#6b7280"
+
+var labelColorPattern = regexp.MustCompile(`^#?[0-9a-fA-F]{6}$`)
+
+// CreateLabelRequest creates a label. Color is a hex color with or without
+// the leading '#'.
+type CreateLabelRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Color       string `json:"color"`
+	Description string `json:"description"`
+}
+
+type UpdateLabelRequest struct {
+	Name        *string `json:"new_name,omitempty"`
+	Color       *string `json:"color,omitempty"`
+	Description *string `json:"description,omitempty"`
+}
+
+// LabelService manages repository labels. Names are unique within a
+// repository regardless of case.
+type LabelService interface {
+	List(ctx context.Context, repoID uuid.UUID) ([]*models.Label, error)
+	Get(ctx context.Context, repoID uuid.UUID, name string) (*models.Label, error)
+	Create(ctx context.Context, repoID uuid.UUID, req CreateLabelRequest) (*models.Label, error)
+	Update(ctx context.Context, label *models.Label, req UpdateLabelRequest) (*models.Label, error)
+	// Delete removes a label and takes it off every issue
+	Delete(ctx context.Context, label *models.Label) error
+}
+
+type labelService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+// NewLabelService creates a new LabelService
+func NewLabelService(db *gorm.DB, logger *logrus.Logger) LabelService {
+	return &labelService{db: db, logger: logger}
+}
+
+func (s *labelService) List(ctx context.Context, repoID uuid.UUID) ([]*models.Label, error) {
+	var labels []*models.Label
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repoID).Order("name ASC").Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("failed to list labels: %w", err)
+	}
+	return labels, nil
+}
+
+func (s *labelService) Get(ctx context.Context, repoID uuid.UUID, name string) (*models.Label, error) {
+	var label models.Label
+	err := s.db.WithContext(ctx).Where("repository_id = ? AND LOWER(name) = LOWER(?)", repoID, name).First(&label).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrLabelNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get label: %w", err)
+	}
+	return &label, nil
+}
+
+func (s *labelService) Create(ctx context.Context, repoID uuid.UUID, req CreateLabelRequest) (*models.Label, error) {
+	name, err := labelName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	color := defaultLabelColor
+	if req.Color != "" {
+		if color, err = labelColor(req.Color); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.checkNameFree(ctx, repoID, name, uuid.Nil); err != nil {
+		return nil, err
+	}
+
+	label := &models.Label{
+		ID:           uuid.New(),
+		RepositoryID: repoID,
+		Name:         name,
+		Color:        color,
+		Description:  req.Description,
+	}
+	if err := s.db.WithContext(ctx).Create(label).Error; err != nil {
+		return nil, fmt.Errorf("failed to create label: %w", err)
+	}
+	return label, nil
+}
+
+func (s *labelService) Update(ctx context.Context, label *models.Label, req UpdateLabelRequest) (*models.Label, error) {
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name, err := labelName(*req.Name)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkNameFree(ctx, label.RepositoryID, name, label.ID); err != nil {
+			return nil, err
+		}
+		updates["name"] = name
+	}
+	if req.Color != nil {
+		color, err := labelColor(*req.Color)
+		if err != nil {
+			return nil, err
+		}
+		updates["color"] = color
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.WithContext(ctx).Model(label).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update label: %w", err)
+		}
+	}
+	return label, nil
+}
+
+func (s *labelService) Delete(ctx context.Context, label *models.Label) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("label_id = ?", label.ID).Delete(&models.IssueLabel{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(label).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete label: %w", err)
+	}
+	return nil
+}
+
+// checkNameFree fails when another label of the repository than except
+// has the name
+func (s *labelService) checkNameFree(ctx context.Context, repoID uuid.UUID, name string, except uuid.UUID) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Label{}).
+		Where("repository_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", repoID, name, except).
+		Count(&count).Error; err != nil {
+		return fmt.Errorf("failed to check label name: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: %s", ErrLabelExists, name)
+	}
+	return nil
+}
+
+func labelName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 255 {
+		return "", fmt.Errorf("%w: name must be between 1 and 255 characters", ErrInvalidLabel)
+	}
+	return name, nil
+}
+
+// labelColor normalizes a hex color to its '#rrggbb' form
+func labelColor(color string) (string, error) {
+	if !labelColorPattern.MatchString(color) {
+		return "", fmt.Errorf("%w: color must be a hex color such as #6b7280", ErrInvalidLabel)
+	}
+	return "#" + strings.ToLower(strings.TrimPrefix(color, "#")), nil
+}
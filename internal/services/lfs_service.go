@@ -0,0 +1,386 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrLFSObjectNotFound is returned for objects the repository has not uploaded
+	ErrLFSObjectNotFound = errors.New("LFS object not found")
+	// ErrInvalidLFSObject is returned for malformed OIDs and for content
+	// that does not match its OID or size
+	ErrInvalidLFSObject = errors.New("invalid LFS object")
+	// ErrLFSQuotaExceeded is returned when an upload would take a repository
+	// over its LFS storage quota
+	ErrLFSQuotaExceeded = errors.New("repository LFS storage quota exceeded")
+	// ErrInvalidLFSQuota is returned for negative quotas
+	ErrInvalidLFSQuota = errors.New("invalid LFS quota")
+)
+
+// Git LFS batch operations
+const (
+	LFSOperationUpload   = "upload"
+	LFSOperationDownload = "download"
+)
+
+var lfsOIDPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// LFSBatchRequest is a Git LFS batch API request
+type LFSBatchRequest struct {
+	Operation string          `json:"operation"`
+	Transfers []string        `json:"transfers,omitempty"`
+	Objects   []LFSObjectSpec `json:"objects"`
+	Ref       *LFSRef         `json:"ref,omitempty"`
+	HashAlgo  string          `json:"hash_algo,omitempty"`
+}
+
+// LFSRef is the ref a batch request is made for
+type LFSRef struct {
+	Name string `json:"name"`
+}
+
+// LFSObjectSpec identifies an object by its OID and size
+type LFSObjectSpec struct {
+	OID  string `json:"oid"`
+	Size int64  `json:"size"`
+}
+
+// LFSBatchResponse is a Git LFS batch API response
+type LFSBatchResponse struct {
+	Transfer string            `json:"transfer"`
+	Objects  []*LFSBatchObject `json:"objects"`
+	HashAlgo string            `json:"hash_algo"`
+}
+
+// LFSBatchObject tells the client what to do with one object; objects
+// without actions need no transfer
+type LFSBatchObject struct {
+	OID           string                `json:"oid"`
+	Size          int64                 `json:"size"`
+	Authenticated bool                  `json:"authenticated,omitempty"`
+	Actions       map[string]*LFSAction `json:"actions,omitempty"`
+	Error         *LFSObjectError       `json:"error,omitempty"`
+}
+
+// LFSAction is a transfer request the client makes with the basic adapter
+type LFSAction struct {
+	Href      string            `json:"href"`
+	Header    map[string]string `json:"header,omitempty"`
+	ExpiresIn int               `json:"expires_in,omitempty"`
+}
+
+// LFSObjectError is a per object batch error
+type LFSObjectError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// LFSUsage reports the LFS storage of a repository
+type LFSUsage struct {
+	Objects   int64 `json:"objects"`
+	SizeBytes int64 `json:"size_bytes"`
+	// QuotaBytes is zero when the repository has no quota
+	QuotaBytes int64 `json:"quota_bytes"`
+}
+
+// LFSService implements the Git LFS batch API and basic transfer adapter.
+// Object content is stored once per OID in the LFS storage backend and
+// linked to every repository that uploaded it; repositories can only
+// download objects linked to them and are limited by their LFS quota.
+type LFSService interface {
+	// Batch answers a batch request; actions point at objectsURL/{oid} and
+	// carry header so transfers authenticate like the batch request did
+	Batch(ctx context.Context, repo *models.Repository, req *LFSBatchRequest, objectsURL string, header map[string]string) (*LFSBatchResponse, error)
+	// Upload stores content after checking it matches oid and size
+	Upload(ctx context.Context, repo *models.Repository, oid string, size int64, content io.Reader) error
+	Download(ctx context.Context, repo *models.Repository, oid string) (io.ReadCloser, int64, error)
+	// Verify confirms an upload completed with the expected size
+	Verify(ctx context.Context, repo *models.Repository, oid string, size int64) error
+	Usage(ctx context.Context, repo *models.Repository) (*LFSUsage, error)
+	// SetQuota overrides the repository quota; nil restores the default
+	SetQuota(ctx context.Context, repo *models.Repository, quotaMB *int64) error
+	// Purge removes an object from every repository and from storage
+	Purge(ctx context.Context, oid string) error
+}
+
+type lfsService struct {
+	db      *gorm.DB
+	backend storage.Backend
+	malware MalwareScanService
+	cfg     config.LFS
+	logger  *logrus.Logger
+}
+
+// NewLFSService creates a new LFSService storing objects in backend.
+// Uploads are scanned for malware and quarantined objects cannot be
+// downloaded.
+func NewLFSService(db *gorm.DB, backend storage.Backend, malware MalwareScanService, cfg config.LFS, logger *logrus.Logger) LFSService {
+	return &lfsService{
+		db:      db,
+		backend: backend,
+		malware: malware,
+		cfg:     cfg,
+		logger:  logger,
+	}
+}
+
+func (s *lfsService) Batch(ctx context.Context, repo *models.Repository, req *LFSBatchRequest, objectsURL string, header map[string]string) (*LFSBatchResponse, error) {
+	if req.Operation != LFSOperationUpload && req.Operation != LFSOperationDownload {
+		return nil, fmt.Errorf("%w: unsupported operation %q", ErrInvalidLFSObject, req.Operation)
+	}
+	if req.HashAlgo != "" && req.HashAlgo != "sha256" {
+		return nil, fmt.Errorf("%w: unsupported hash algorithm %q", ErrInvalidLFSObject, req.HashAlgo)
+	}
+
+	oids := make([]string, 0, len(req.Objects))
+	for _, obj := range req.Objects {
+		oids = append(oids, obj.OID)
+	}
+	var linked []models.LFSObject
+	if err := s.db.WithContext(ctx).Where("repository_id = ? AND oid IN ?", repo.ID, oids).Find(&linked).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up LFS objects: %w", err)
+	}
+	existing := make(map[string]int64, len(linked))
+	for _, obj := range linked {
+		existing[obj.OID] = obj.Size
+	}
+	quarantined := map[string]bool{}
+	if req.Operation == LFSOperationDownload {
+		var err error
+		if quarantined, err = s.malware.Quarantined(ctx, models.MalwareContentLFSObject, oids...); err != nil {
+			return nil, err
+		}
+	}
+
+	var remaining int64 = -1
+	if req.Operation == LFSOperationUpload {
+		usage, err := s.Usage(ctx, repo)
+		if err != nil {
+			return nil, err
+		}
+		if usage.QuotaBytes > 0 {
+			remaining = usage.QuotaBytes - usage.SizeBytes
+		}
+	}
+
+	resp := &LFSBatchResponse{Transfer: "basic", HashAlgo: "sha256", Objects: make([]*LFSBatchObject, 0, len(req.Objects))}
+	for _, obj := range req.Objects {
+		entry := &LFSBatchObject{OID: obj.OID, Size: obj.Size, Authenticated: true}
+		resp.Objects = append(resp.Objects, entry)
+
+		if !lfsOIDPattern.MatchString(obj.OID) || obj.Size < 0 {
+			entry.Error = &LFSObjectError{Code: 422, Message: "invalid object"}
+			continue
+		}
+		transfer := &LFSAction{Href: objectsURL + "/" + obj.OID, Header: header}
+		size, ok := existing[obj.OID]
+		switch req.Operation {
+		case LFSOperationDownload:
+			if !ok {
+				entry.Error = &LFSObjectError{Code: 404, Message: "object does not exist"}
+				continue
+			}
+			if quarantined[obj.OID] {
+				entry.Error = &LFSObjectError{Code: 403, Message: ErrMalwareQuarantined.Error()}
+				continue
+			}
+			entry.Size = size
+			entry.Actions = map[string]*LFSAction{"download": transfer}
+		case LFSOperationUpload:
+			if ok {
+				// Already uploaded; the client skips objects without actions
+				continue
+			}
+			if remaining >= 0 {
+				if obj.Size > remaining {
+					entry.Error = &LFSObjectError{Code: 507, Message: ErrLFSQuotaExceeded.Error()}
+					continue
+				}
+				remaining -= obj.Size
+			}
+			entry.Actions = map[string]*LFSAction{
+				"upload": transfer,
+				"verify": {Href: objectsURL + "/verify", Header: header},
+			}
+		}
+	}
+	return resp, nil
+}
+
+func (s *lfsService) Upload(ctx context.Context, repo *models.Repository, oid string, size int64, content io.Reader) error {
+	if !lfsOIDPattern.MatchString(oid) {
+		return fmt.Errorf("%w: malformed oid", ErrInvalidLFSObject)
+	}
+	if err := s.checkQuota(ctx, repo, oid, size); err != nil {
+		return err
+	}
+
+	// Spool to disk so content is only stored once its hash is known to match
+	tmp, err := os.CreateTemp("", "hub-lfs-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	written, err := io.Copy(io.MultiWriter(tmp, hash), content)
+	if err != nil {
+		return fmt.Errorf("failed to receive LFS object: %w", err)
+	}
+	if size >= 0 && written != size {
+		return fmt.Errorf("%w: expected %d bytes, got %d", ErrInvalidLFSObject, size, written)
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != oid {
+		return fmt.Errorf("%w: content does not match oid", ErrInvalidLFSObject)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	target := MalwareScanTarget{Kind: models.MalwareContentLFSObject, ContentID: oid, RepositoryID: repo.ID, Name: oid, Size: written}
+	if _, err := s.malware.Scan(ctx, target, tmp); err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if err := s.backend.Upload(ctx, oid, tmp, written); err != nil {
+		return fmt.Errorf("failed to store LFS object: %w", err)
+	}
+
+	object := &models.LFSObject{ID: uuid.New(), RepositoryID: repo.ID, OID: oid, Size: written}
+	err = s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(object).Error
+	if err != nil {
+		return fmt.Errorf("failed to record LFS object: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"repository_id": repo.ID,
+		"oid":           oid,
+		"size":          written,
+	}).Info("Stored LFS object")
+	return nil
+}
+
+// checkQuota fails when storing a new object of size would exceed the quota
+func (s *lfsService) checkQuota(ctx context.Context, repo *models.Repository, oid string, size int64) error {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.LFSObject{}).
+		Where("repository_id = ? AND oid = ?", repo.ID, oid).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	usage, err := s.Usage(ctx, repo)
+	if err != nil {
+		return err
+	}
+	if usage.QuotaBytes > 0 && usage.SizeBytes+size > usage.QuotaBytes {
+		return ErrLFSQuotaExceeded
+	}
+	return nil
+}
+
+func (s *lfsService) Download(ctx context.Context, repo *models.Repository, oid string) (io.ReadCloser, int64, error) {
+	object, err := s.linkedObject(ctx, repo, oid)
+	if err != nil {
+		return nil, 0, err
+	}
+	quarantined, err := s.malware.Quarantined(ctx, models.MalwareContentLFSObject, oid)
+	if err != nil {
+		return nil, 0, err
+	}
+	if quarantined[oid] {
+		return nil, 0, ErrMalwareQuarantined
+	}
+	reader, err := s.backend.Download(ctx, oid)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read LFS object: %w", err)
+	}
+	return reader, object.Size, nil
+}
+
+func (s *lfsService) Verify(ctx context.Context, repo *models.Repository, oid string, size int64) error {
+	object, err := s.linkedObject(ctx, repo, oid)
+	if err != nil {
+		return err
+	}
+	if object.Size != size {
+		return fmt.Errorf("%w: expected %d bytes, stored %d", ErrInvalidLFSObject, size, object.Size)
+	}
+	return nil
+}
+
+func (s *lfsService) linkedObject(ctx context.Context, repo *models.Repository, oid string) (*models.LFSObject, error) {
+	var object models.LFSObject
+	err := s.db.WithContext(ctx).Where("repository_id = ? AND oid = ?", repo.ID, oid).First(&object).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrLFSObjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+func (s *lfsService) Usage(ctx context.Context, repo *models.Repository) (*LFSUsage, error) {
+	var usage LFSUsage
+	err := s.db.WithContext(ctx).Model(&models.LFSObject{}).
+		Select("COUNT(*) AS objects, COALESCE(SUM(size), 0) AS size_bytes").
+		Where("repository_id = ?", repo.ID).
+		Scan(&usage).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get LFS usage: %w", err)
+	}
+
+	quotaMB := s.cfg.RepositoryQuotaMB
+	if repo.LFSQuotaMB != nil {
+		quotaMB = *repo.LFSQuotaMB
+	}
+	if quotaMB > 0 {
+		usage.QuotaBytes = quotaMB * 1024 * 1024
+	}
+	return &usage, nil
+}
+
+func (s *lfsService) SetQuota(ctx context.Context, repo *models.Repository, quotaMB *int64) error {
+	if quotaMB != nil && *quotaMB < 0 {
+		return ErrInvalidLFSQuota
+	}
+	if err := s.db.WithContext(ctx).Model(repo).Update("lfs_quota_mb", quotaMB).Error; err != nil {
+		return fmt.Errorf("failed to update LFS quota: %w", err)
+	}
+	repo.LFSQuotaMB = quotaMB
+	return nil
+}
+
+func (s *lfsService) Purge(ctx context.Context, oid string) error {
+	if !lfsOIDPattern.MatchString(oid) {
+		return fmt.Errorf("%w: malformed oid", ErrInvalidLFSObject)
+	}
+	if err := s.db.WithContext(ctx).Where("oid = ?", oid).Delete(&models.LFSObject{}).Error; err != nil {
+		return fmt.Errorf("failed to unlink LFS object: %w", err)
+	}
+	if err := s.backend.Delete(ctx, oid); err != nil {
+		s.logger.WithError(err).WithField("oid", oid).Warn("Failed to remove LFS object content")
+	}
+	s.logger.WithField("oid", oid).Info("Purged LFS object")
+	return nil
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLFSService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Repository{}, &models.LFSObject{}, &models.MalwareDetection{})
+	ctx := context.Background()
+
+	backend, err := storage.NewFilesystemBackend(storage.FilesystemConfig{BasePath: t.TempDir()})
+	require.NoError(t, err)
+	svc := NewLFSService(db, backend, NewMalwareScanService(db, nil, config.MalwareScanning{}, logrus.New()), config.LFS{RepositoryQuotaMB: 1}, logrus.New())
+
+	newRepo := func(name string) *models.Repository {
+		repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: name,
+			DefaultBranch: "main", Visibility: models.VisibilityPublic}
+		require.NoError(t, db.Create(repo).Error)
+		return repo
+	}
+	object := func(content []byte) (string, int64) {
+		sum := sha256.Sum256(content)
+		return hex.EncodeToString(sum[:]), int64(len(content))
+	}
+
+	repo := newRepo("assets")
+	other := newRepo("other")
+	content := []byte("large binary file")
+	oid, size := object(content)
+	const objectsURL = "https://hub.example.com/alice/assets.git/info/lfs/objects"
+	header := map[string]string{"Authorization": "Bearer token"}
+
+	t.Run("upload batch returns transfer actions", func(t *testing.T) {
+		resp, err := svc.Batch(ctx, repo, &LFSBatchRequest{Operation: LFSOperationUpload, Objects: []LFSObjectSpec{{OID: oid, Size: size}}}, objectsURL, header)
+		require.NoError(t, err)
+		require.Len(t, resp.Objects, 1)
+		assert.Equal(t, "basic", resp.Transfer)
+		assert.Equal(t, objectsURL+"/"+oid, resp.Objects[0].Actions["upload"].Href)
+		assert.Equal(t, objectsURL+"/verify", resp.Objects[0].Actions["verify"].Href)
+		assert.Equal(t, "Bearer token", resp.Objects[0].Actions["upload"].Header["Authorization"])
+	})
+
+	t.Run("upload rejects content not matching the oid", func(t *testing.T) {
+		err := svc.Upload(ctx, repo, oid, size, bytes.NewReader([]byte("something else!!!")))
+		assert.ErrorIs(t, err, ErrInvalidLFSObject)
+		_, _, err = svc.Download(ctx, repo, oid)
+		assert.ErrorIs(t, err, ErrLFSObjectNotFound)
+	})
+
+	t.Run("upload, verify and download", func(t *testing.T) {
+		require.NoError(t, svc.Upload(ctx, repo, oid, size, bytes.NewReader(content)))
+		require.NoError(t, svc.Verify(ctx, repo, oid, size))
+		assert.ErrorIs(t, svc.Verify(ctx, repo, oid, size+1), ErrInvalidLFSObject)
+
+		reader, gotSize, err := svc.Download(ctx, repo, oid)
+		require.NoError(t, err)
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.Equal(t, content, data)
+		assert.Equal(t, size, gotSize)
+
+		// Uploaded objects need no further transfer
+		resp, err := svc.Batch(ctx, repo, &LFSBatchRequest{Operation: LFSOperationUpload, Objects: []LFSObjectSpec{{OID: oid, Size: size}}}, objectsURL, nil)
+		require.NoError(t, err)
+		assert.Empty(t, resp.Objects[0].Actions)
+	})
+
+	t.Run("objects are scoped to their repository", func(t *testing.T) {
+		_, _, err := svc.Download(ctx, other, oid)
+		assert.ErrorIs(t, err, ErrLFSObjectNotFound)
+
+		resp, err := svc.Batch(ctx, other, &LFSBatchRequest{Operation: LFSOperationDownload, Objects: []LFSObjectSpec{{OID: oid, Size: size}}}, objectsURL, nil)
+		require.NoError(t, err)
+		require.NotNil(t, resp.Objects[0].Error)
+		assert.Equal(t, 404, resp.Objects[0].Error.Code)
+	})
+
+	t.Run("quota limits uploads", func(t *testing.T) {
+		big := bytes.Repeat([]byte("x"), 1024*1024)
+		bigOID, bigSize := object(big)
+
+		resp, err := svc.Batch(ctx, repo, &LFSBatchRequest{Operation: LFSOperationUpload, Objects: []LFSObjectSpec{{OID: bigOID, Size: bigSize}}}, objectsURL, nil)
+		require.NoError(t, err)
+		require.NotNil(t, resp.Objects[0].Error)
+		assert.Equal(t, 507, resp.Objects[0].Error.Code)
+		assert.ErrorIs(t, svc.Upload(ctx, repo, bigOID, bigSize, bytes.NewReader(big)), ErrLFSQuotaExceeded)
+
+		// A quota of 0 removes the limit
+		unlimited := int64(0)
+		require.NoError(t, svc.SetQuota(ctx, repo, &unlimited))
+		require.NoError(t, svc.Upload(ctx, repo, bigOID, bigSize, bytes.NewReader(big)))
+
+		usage, err := svc.Usage(ctx, repo)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), usage.Objects)
+		assert.Equal(t, size+bigSize, usage.SizeBytes)
+		assert.Zero(t, usage.QuotaBytes)
+
+		negative := int64(-1)
+		assert.ErrorIs(t, svc.SetQuota(ctx, repo, &negative), ErrInvalidLFSQuota)
+	})
+}
@@ -0,0 +1,579 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/cron"
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaintenanceWindowService manages maintenance windows and the per job class
+// policies that restrict heavy background jobs to, or keep them out of,
+// those windows. It is the jobs.Gate that heavy schedulers wait on.
+type MaintenanceWindowService interface {
+	ListWindows(ctx context.Context) ([]*models.MaintenanceWindow, error)
+	GetWindow(ctx context.Context, id uuid.UUID) (*models.MaintenanceWindow, error)
+	CreateWindow(ctx context.Context, createdBy *uuid.UUID, req MaintenanceWindowRequest) (*models.MaintenanceWindow, error)
+	UpdateWindow(ctx context.Context, id uuid.UUID, req MaintenanceWindowRequest) (*models.MaintenanceWindow, error)
+	DeleteWindow(ctx context.Context, id uuid.UUID) error
+
+	// ListPolicies returns the policy of every job class, including the
+	// default of classes nobody configured
+	ListPolicies(ctx context.Context) ([]*models.JobClassPolicy, error)
+	SetPolicy(ctx context.Context, class string, mode models.JobPolicyMode, updatedBy *uuid.UUID) (*models.JobClassPolicy, error)
+
+	// Allowed reports whether jobs of class may run at t and, when they may
+	// not, the next time they may; that time is zero if they never will
+	Allowed(ctx context.Context, class string, t time.Time) (bool, time.Time, error)
+	// Calendar lists the window occurrences and projected job runs in
+	// [from, to)
+	Calendar(ctx context.Context, from, to time.Time) (*MaintenanceCalendar, error)
+	// Status reports whether each job class may run now and the jobs the
+	// gate has seen
+	Status(ctx context.Context) (*MaintenanceStatus, error)
+
+	jobs.Gate
+}
+
+// MaintenanceWindowRequest creates or updates a maintenance window
+type MaintenanceWindowRequest struct {
+	Name            *string   `json:"name"`
+	Description     *string   `json:"description"`
+	Schedule        *string   `json:"schedule"`
+	DurationMinutes *int      `json:"duration_minutes"`
+	Timezone        *string   `json:"timezone"`
+	JobClasses      *[]string `json:"job_classes"`
+	Enabled         *bool     `json:"enabled"`
+}
+
+// MaintenanceWindowOccurrence is one opening of a maintenance window
+type MaintenanceWindowOccurrence struct {
+	WindowID   uuid.UUID `json:"window_id"`
+	Name       string    `json:"name"`
+	JobClasses []string  `json:"job_classes"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+}
+
+// PlannedJobRun is a projected run of a periodic job. RunsAt differs from
+// ScheduledAt when the job's class policy defers it, and is nil when the
+// policy never lets it run.
+type PlannedJobRun struct {
+	Job         string     `json:"job"`
+	Class       string     `json:"class"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	RunsAt      *time.Time `json:"runs_at"`
+	Deferred    bool       `json:"deferred"`
+}
+
+// MaintenanceCalendar shows what is planned to run when
+type MaintenanceCalendar struct {
+	From     time.Time                     `json:"from"`
+	To       time.Time                     `json:"to"`
+	Policies []*models.JobClassPolicy      `json:"policies"`
+	Windows  []MaintenanceWindowOccurrence `json:"windows"`
+	Runs     []PlannedJobRun               `json:"runs"`
+}
+
+// JobClassStatus reports whether a job class may run now
+type JobClassStatus struct {
+	Class         string               `json:"class"`
+	Mode          models.JobPolicyMode `json:"mode"`
+	AllowedNow    bool                 `json:"allowed_now"`
+	NextAllowedAt *time.Time           `json:"next_allowed_at,omitempty"`
+}
+
+// MaintenanceStatus is the current state of the maintenance gate
+type MaintenanceStatus struct {
+	Time    time.Time               `json:"time"`
+	Classes []JobClassStatus        `json:"classes"`
+	Jobs    []*models.BackgroundJob `json:"jobs"`
+}
+
+var (
+	ErrMaintenanceWindowNotFound = errors.New("maintenance window not found")
+	ErrInvalidMaintenanceWindow  = errors.New("invalid maintenance window")
+	ErrInvalidJobPolicy          = errors.New("invalid job class policy")
+	ErrInvalidCalendarRange      = errors.New("invalid calendar range")
+)
+
+const (
+	// maxWindowDuration bounds how long a window stays open
+	maxWindowDuration = 7 * 24 * time.Hour
+	// maxCalendarSpan bounds the range of one calendar request
+	maxCalendarSpan = 31 * 24 * time.Hour
+	// maxCalendarEntries caps the occurrences or runs listed per window or job
+	maxCalendarEntries = 1000
+	// maintenanceRecheckInterval is how often a held back job re-reads the
+	// windows and policies, so that changes to them apply to waiting jobs
+	maintenanceRecheckInterval = time.Minute
+)
+
+type maintenanceWindowService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+	now    func() time.Time
+}
+
+// NewMaintenanceWindowService creates a new MaintenanceWindowService
+func NewMaintenanceWindowService(db *gorm.DB, logger *logrus.Logger) MaintenanceWindowService {
+	return &maintenanceWindowService{db: db, logger: logger, now: time.Now}
+}
+
+func (s *maintenanceWindowService) ListWindows(ctx context.Context) ([]*models.MaintenanceWindow, error) {
+	var windows []*models.MaintenanceWindow
+	if err := s.db.WithContext(ctx).Order("name ASC").Find(&windows).Error; err != nil {
+		return nil, fmt.Errorf("failed to list maintenance windows: %w", err)
+	}
+	return windows, nil
+}
+
+func (s *maintenanceWindowService) GetWindow(ctx context.Context, id uuid.UUID) (*models.MaintenanceWindow, error) {
+	var window models.MaintenanceWindow
+	if err := s.db.WithContext(ctx).First(&window, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMaintenanceWindowNotFound
+		}
+		return nil, err
+	}
+	return &window, nil
+}
+
+func (s *maintenanceWindowService) CreateWindow(ctx context.Context, createdBy *uuid.UUID, req MaintenanceWindowRequest) (*models.MaintenanceWindow, error) {
+	if req.Name == nil || req.Schedule == nil || req.DurationMinutes == nil {
+		return nil, fmt.Errorf("%w: name, schedule and duration_minutes are required", ErrInvalidMaintenanceWindow)
+	}
+	window := &models.MaintenanceWindow{
+		ID:          uuid.New(),
+		Timezone:    "UTC",
+		Enabled:     true,
+		CreatedByID: createdBy,
+	}
+	if err := s.applyWindowRequest(window, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(window).Error; err != nil {
+		return nil, fmt.Errorf("failed to create maintenance window: %w", err)
+	}
+	return window, nil
+}
+
+func (s *maintenanceWindowService) UpdateWindow(ctx context.Context, id uuid.UUID, req MaintenanceWindowRequest) (*models.MaintenanceWindow, error) {
+	window, err := s.GetWindow(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyWindowRequest(window, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Save(window).Error; err != nil {
+		return nil, fmt.Errorf("failed to update maintenance window: %w", err)
+	}
+	return window, nil
+}
+
+func (s *maintenanceWindowService) DeleteWindow(ctx context.Context, id uuid.UUID) error {
+	result := s.db.WithContext(ctx).Delete(&models.MaintenanceWindow{}, "id = ?", id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete maintenance window: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrMaintenanceWindowNotFound
+	}
+	return nil
+}
+
+// applyWindowRequest validates the request onto window
+func (s *maintenanceWindowService) applyWindowRequest(window *models.MaintenanceWindow, req MaintenanceWindowRequest) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 255 {
+			return fmt.Errorf("%w: name must be between 1 and 255 characters", ErrInvalidMaintenanceWindow)
+		}
+		window.Name = name
+	}
+	if req.Description != nil {
+		window.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Schedule != nil {
+		window.Schedule = strings.Join(strings.Fields(*req.Schedule), " ")
+	}
+	if req.DurationMinutes != nil {
+		window.DurationMinutes = *req.DurationMinutes
+	}
+	if req.Timezone != nil {
+		window.Timezone = strings.TrimSpace(*req.Timezone)
+		if window.Timezone == "" {
+			window.Timezone = "UTC"
+		}
+	}
+	if req.JobClasses != nil {
+		classes := make([]string, 0, len(*req.JobClasses))
+		for _, class := range *req.JobClasses {
+			class = strings.ToLower(strings.TrimSpace(class))
+			if !slices.Contains(jobs.Classes, class) {
+				return fmt.Errorf("%w: unknown job class %q", ErrInvalidMaintenanceWindow, class)
+			}
+			if !slices.Contains(classes, class) {
+				classes = append(classes, class)
+			}
+		}
+		window.JobClasses = classes
+	}
+	if req.Enabled != nil {
+		window.Enabled = *req.Enabled
+	}
+
+	parsed, err := parseMaintenanceWindow(window)
+	if err != nil {
+		return err
+	}
+	if parsed.expr.Next(s.now().In(parsed.loc)).IsZero() {
+		return fmt.Errorf("%w: %q never opens", ErrInvalidMaintenanceWindow, window.Schedule)
+	}
+	return nil
+}
+
+func (s *maintenanceWindowService) ListPolicies(ctx context.Context) ([]*models.JobClassPolicy, error) {
+	var stored []*models.JobClassPolicy
+	if err := s.db.WithContext(ctx).Find(&stored).Error; err != nil {
+		return nil, fmt.Errorf("failed to list job class policies: %w", err)
+	}
+	policies := make([]*models.JobClassPolicy, 0, len(jobs.Classes))
+	for _, class := range jobs.Classes {
+		policy := &models.JobClassPolicy{JobClass: class, Mode: models.JobPolicyAnytime}
+		for _, p := range stored {
+			if p.JobClass == class {
+				policy = p
+			}
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+func (s *maintenanceWindowService) SetPolicy(ctx context.Context, class string, mode models.JobPolicyMode, updatedBy *uuid.UUID) (*models.JobClassPolicy, error) {
+	if !slices.Contains(jobs.Classes, class) {
+		return nil, fmt.Errorf("%w: unknown job class %q", ErrInvalidJobPolicy, class)
+	}
+	switch mode {
+	case models.JobPolicyAnytime, models.JobPolicyWindowsOnly, models.JobPolicyOutsideWindows:
+	default:
+		return nil, fmt.Errorf("%w: unknown mode %q", ErrInvalidJobPolicy, mode)
+	}
+	policy := &models.JobClassPolicy{JobClass: class, Mode: mode, UpdatedByID: updatedBy}
+	if err := s.db.WithContext(ctx).Save(policy).Error; err != nil {
+		return nil, fmt.Errorf("failed to set job class policy: %w", err)
+	}
+	return policy, nil
+}
+
+func (s *maintenanceWindowService) Allowed(ctx context.Context, class string, t time.Time) (bool, time.Time, error) {
+	rules, err := s.loadRules(ctx)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	allowed, next := rules.allowed(class, t)
+	return allowed, next, nil
+}
+
+func (s *maintenanceWindowService) Calendar(ctx context.Context, from, to time.Time) (*MaintenanceCalendar, error) {
+	if !to.After(from) || to.Sub(from) > maxCalendarSpan {
+		return nil, fmt.Errorf("%w: to must be after from and at most %d days later",
+			ErrInvalidCalendarRange, int(maxCalendarSpan.Hours()/24))
+	}
+	rules, err := s.loadRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	policies, err := s.ListPolicies(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var backgroundJobs []*models.BackgroundJob
+	if err := s.db.WithContext(ctx).Order("name ASC").Find(&backgroundJobs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list background jobs: %w", err)
+	}
+
+	calendar := &MaintenanceCalendar{
+		From:     from.UTC(),
+		To:       to.UTC(),
+		Policies: policies,
+		Windows:  []MaintenanceWindowOccurrence{},
+		Runs:     []PlannedJobRun{},
+	}
+	for _, w := range rules.windows {
+		start := w.expr.Next(from.Add(-w.duration).In(w.loc))
+		for n := 0; !start.IsZero() && start.Before(to) && n < maxCalendarEntries; n++ {
+			calendar.Windows = append(calendar.Windows, MaintenanceWindowOccurrence{
+				WindowID:   w.window.ID,
+				Name:       w.window.Name,
+				JobClasses: w.window.JobClasses,
+				Start:      start.UTC(),
+				End:        start.Add(w.duration).UTC(),
+			})
+			start = w.expr.Next(start)
+		}
+	}
+	slices.SortStableFunc(calendar.Windows, func(a, b MaintenanceWindowOccurrence) int {
+		return a.Start.Compare(b.Start)
+	})
+
+	for _, job := range backgroundJobs {
+		if job.IntervalSeconds <= 0 {
+			continue
+		}
+		interval := time.Duration(job.IntervalSeconds) * time.Second
+		tick := job.UpdatedAt
+		if job.LastStartedAt != nil {
+			tick = *job.LastStartedAt
+		}
+		tick = tick.Add(interval)
+		if tick.Before(from) {
+			tick = tick.Add((from.Sub(tick) + interval - 1) / interval * interval)
+		}
+		for n := 0; tick.Before(to) && n < maxCalendarEntries; n++ {
+			allowed, next := rules.allowed(job.Class, tick)
+			run := PlannedJobRun{Job: job.Name, Class: job.Class, ScheduledAt: tick.UTC(), Deferred: !allowed}
+			if !next.IsZero() {
+				runsAt := next.UTC()
+				run.RunsAt = &runsAt
+			}
+			calendar.Runs = append(calendar.Runs, run)
+			tick = tick.Add(interval)
+		}
+	}
+	slices.SortStableFunc(calendar.Runs, func(a, b PlannedJobRun) int {
+		return a.ScheduledAt.Compare(b.ScheduledAt)
+	})
+	return calendar, nil
+}
+
+func (s *maintenanceWindowService) Status(ctx context.Context) (*MaintenanceStatus, error) {
+	rules, err := s.loadRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now().UTC()
+	status := &MaintenanceStatus{Time: now}
+	for _, class := range jobs.Classes {
+		allowed, next := rules.allowed(class, now)
+		classStatus := JobClassStatus{Class: class, Mode: rules.mode(class), AllowedNow: allowed}
+		if !allowed && !next.IsZero() {
+			next = next.UTC()
+			classStatus.NextAllowedAt = &next
+		}
+		status.Classes = append(status.Classes, classStatus)
+	}
+	if err := s.db.WithContext(ctx).Order("name ASC").Find(&status.Jobs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list background jobs: %w", err)
+	}
+	return status, nil
+}
+
+// Wait holds job back until its class policy lets it run. Failing to read
+// the policy lets the job run rather than stalling it indefinitely.
+func (s *maintenanceWindowService) Wait(ctx context.Context, job jobs.Job) error {
+	deferred := false
+	for {
+		if err := ctx.Err(); err != nil {
+			if deferred {
+				s.recordJob(context.Background(), job, nil, nil)
+			}
+			return err
+		}
+		now := s.now()
+		allowed, next, err := s.Allowed(ctx, job.Class, now)
+		if err != nil {
+			s.logger.WithError(err).WithField("job", job.Name).Warn("Failed to check maintenance windows, running job")
+			allowed = true
+		}
+		if allowed {
+			s.recordJob(ctx, job, &now, nil)
+			return nil
+		}
+		if !deferred {
+			deferred = true
+			s.recordJob(ctx, job, nil, &now)
+			entry := s.logger.WithFields(logrus.Fields{"job": job.Name, "class": job.Class})
+			if next.IsZero() {
+				entry.Warn("Job class policy never lets job run")
+			} else {
+				entry.WithField("next_allowed_at", next).Info("Deferring job until its class may run")
+			}
+		}
+
+		wait := maintenanceRecheckInterval
+		if !next.IsZero() && next.Sub(now) < wait {
+			wait = next.Sub(now)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// recordJob upserts the gate's view of job; started is set when it is let
+// through and waiting while it is held back
+func (s *maintenanceWindowService) recordJob(ctx context.Context, job jobs.Job, started, waiting *time.Time) {
+	row := &models.BackgroundJob{
+		Name:            job.Name,
+		Class:           job.Class,
+		IntervalSeconds: int64(job.Interval / time.Second),
+		LastStartedAt:   started,
+		WaitingSince:    waiting,
+		UpdatedAt:       s.now(),
+	}
+	columns := []string{"class", "interval_seconds", "waiting_since", "updated_at"}
+	if started != nil {
+		columns = append(columns, "last_started_at")
+	}
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(row).Error
+	if err != nil {
+		s.logger.WithError(err).WithField("job", job.Name).Warn("Failed to record background job")
+	}
+}
+
+// maintenanceRules is a snapshot of the enabled windows and class policies
+type maintenanceRules struct {
+	policies map[string]models.JobPolicyMode
+	windows  []*parsedMaintenanceWindow
+}
+
+type parsedMaintenanceWindow struct {
+	window   *models.MaintenanceWindow
+	expr     *cron.Schedule
+	loc      *time.Location
+	duration time.Duration
+}
+
+func (s *maintenanceWindowService) loadRules(ctx context.Context) (*maintenanceRules, error) {
+	var policies []*models.JobClassPolicy
+	if err := s.db.WithContext(ctx).Find(&policies).Error; err != nil {
+		return nil, fmt.Errorf("failed to load job class policies: %w", err)
+	}
+	var windows []*models.MaintenanceWindow
+	if err := s.db.WithContext(ctx).Where("enabled = ?", true).Find(&windows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load maintenance windows: %w", err)
+	}
+
+	rules := &maintenanceRules{policies: make(map[string]models.JobPolicyMode, len(policies))}
+	for _, p := range policies {
+		rules.policies[p.JobClass] = p.Mode
+	}
+	for _, window := range windows {
+		parsed, err := parseMaintenanceWindow(window)
+		if err != nil {
+			s.logger.WithError(err).WithField("window_id", window.ID).Warn("Skipping invalid maintenance window")
+			continue
+		}
+		rules.windows = append(rules.windows, parsed)
+	}
+	return rules, nil
+}
+
+func parseMaintenanceWindow(window *models.MaintenanceWindow) (*parsedMaintenanceWindow, error) {
+	expr, err := cron.Parse(window.Schedule)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMaintenanceWindow, err)
+	}
+	loc, err := time.LoadLocation(window.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidMaintenanceWindow, window.Timezone)
+	}
+	duration := time.Duration(window.DurationMinutes) * time.Minute
+	if duration <= 0 || duration > maxWindowDuration {
+		return nil, fmt.Errorf("%w: duration_minutes must be between 1 and %d",
+			ErrInvalidMaintenanceWindow, int(maxWindowDuration.Minutes()))
+	}
+	return &parsedMaintenanceWindow{window: window, expr: expr, loc: loc, duration: duration}, nil
+}
+
+func (w *parsedMaintenanceWindow) covers(class string) bool {
+	return len(w.window.JobClasses) == 0 || slices.Contains(w.window.JobClasses, class)
+}
+
+// openUntil returns when the occurrence of w open at t closes, or the zero
+// time when w is closed at t
+func (w *parsedMaintenanceWindow) openUntil(t time.Time) time.Time {
+	var end time.Time
+	start := w.expr.Next(t.Add(-w.duration).In(w.loc))
+	// Occurrences can overlap; the latest one to open closes last
+	for n := 0; !start.IsZero() && !start.After(t) && n < maxCalendarEntries; n++ {
+		end = start.Add(w.duration)
+		start = w.expr.Next(start)
+	}
+	return end
+}
+
+func (r *maintenanceRules) mode(class string) models.JobPolicyMode {
+	if mode, ok := r.policies[class]; ok {
+		return mode
+	}
+	return models.JobPolicyAnytime
+}
+
+// openUntil returns when the last window covering class that is open at t
+// closes, or the zero time when none is open
+func (r *maintenanceRules) openUntil(class string, t time.Time) time.Time {
+	var end time.Time
+	for _, w := range r.windows {
+		if !w.covers(class) {
+			continue
+		}
+		if e := w.openUntil(t); e.After(end) {
+			end = e
+		}
+	}
+	return end
+}
+
+// allowed reports whether class may run at t and otherwise the next time
+// it may, which is zero if it never will
+func (r *maintenanceRules) allowed(class string, t time.Time) (bool, time.Time) {
+	switch r.mode(class) {
+	case models.JobPolicyWindowsOnly:
+		if !r.openUntil(class, t).IsZero() {
+			return true, t
+		}
+		var next time.Time
+		for _, w := range r.windows {
+			if !w.covers(class) {
+				continue
+			}
+			if start := w.expr.Next(t.In(w.loc)); !start.IsZero() && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+		return false, next
+	case models.JobPolicyOutsideWindows:
+		next := t
+		// Back to back windows keep the class waiting until the last closes
+		for n := 0; n < maxCalendarEntries; n++ {
+			end := r.openUntil(class, next)
+			if end.IsZero() {
+				return n == 0, next
+			}
+			next = end
+		}
+		return false, time.Time{}
+	default:
+		return true, t
+	}
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceWindowService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.MaintenanceWindow{}, &models.JobClassPolicy{}, &models.BackgroundJob{})
+	ctx := context.Background()
+	svc := NewMaintenanceWindowService(db, logrus.New())
+	day := time.Date(2026, 6, 15, 0, 0, 0, 0, time.UTC)
+	at := func(hour, minute int) time.Time {
+		return day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	now := at(3, 0)
+	svc.(*maintenanceWindowService).now = func() time.Time { return now }
+
+	str := func(s string) *string { return &s }
+	minutes := func(n int) *int { return &n }
+	classes := func(c ...string) *[]string { return &c }
+
+	_, err := svc.CreateWindow(ctx, nil, MaintenanceWindowRequest{Name: str("bad"), Schedule: str("0 25 * * *"), DurationMinutes: minutes(60)})
+	assert.ErrorIs(t, err, ErrInvalidMaintenanceWindow)
+	_, err = svc.CreateWindow(ctx, nil, MaintenanceWindowRequest{Name: str("bad"), Schedule: str("0 2 * * *"), DurationMinutes: minutes(0)})
+	assert.ErrorIs(t, err, ErrInvalidMaintenanceWindow)
+	_, err = svc.CreateWindow(ctx, nil, MaintenanceWindowRequest{Name: str("bad"), Schedule: str("0 2 * * *"),
+		DurationMinutes: minutes(60), JobClasses: classes("vacuum")})
+	assert.ErrorIs(t, err, ErrInvalidMaintenanceWindow)
+
+	// 02:00-04:00 for gc and backups, 03:30-04:30 for everything
+	_, err = svc.CreateWindow(ctx, nil, MaintenanceWindowRequest{Name: str("nightly"), Schedule: str("0 2 * * *"),
+		DurationMinutes: minutes(120), JobClasses: classes(jobs.ClassGC, jobs.ClassBackup)})
+	require.NoError(t, err)
+	_, err = svc.CreateWindow(ctx, nil, MaintenanceWindowRequest{Name: str("freeze"), Schedule: str("30 3 * * *"),
+		DurationMinutes: minutes(60)})
+	require.NoError(t, err)
+
+	_, err = svc.SetPolicy(ctx, jobs.ClassGC, "sometimes", nil)
+	assert.ErrorIs(t, err, ErrInvalidJobPolicy)
+	_, err = svc.SetPolicy(ctx, jobs.ClassGC, models.JobPolicyWindowsOnly, nil)
+	require.NoError(t, err)
+	_, err = svc.SetPolicy(ctx, jobs.ClassArchival, models.JobPolicyOutsideWindows, nil)
+	require.NoError(t, err)
+	_, err = svc.SetPolicy(ctx, jobs.ClassBackup, models.JobPolicyOutsideWindows, nil)
+	require.NoError(t, err)
+	policies, err := svc.ListPolicies(ctx)
+	require.NoError(t, err)
+	require.Len(t, policies, len(jobs.Classes))
+
+	allowed := func(class string, when time.Time) (bool, time.Time) {
+		ok, next, err := svc.Allowed(ctx, class, when)
+		require.NoError(t, err)
+		return ok, next
+	}
+	ok, next := allowed(jobs.ClassGC, at(1, 0))
+	assert.False(t, ok)
+	assert.Equal(t, at(2, 0), next.UTC())
+	ok, _ = allowed(jobs.ClassGC, at(3, 59))
+	assert.True(t, ok)
+	ok, next = allowed(jobs.ClassArchival, at(3, 45))
+	assert.False(t, ok)
+	assert.Equal(t, at(4, 30), next.UTC())
+	ok, next = allowed(jobs.ClassBackup, at(3, 0))
+	assert.False(t, ok)
+	assert.Equal(t, at(4, 30), next.UTC(), "overlapping windows defer until the last closes")
+	ok, _ = allowed(jobs.ClassReindex, at(3, 45))
+	assert.True(t, ok, "classes without a policy run any time")
+
+	// Allowed jobs are recorded as started; held back ones wait
+	require.NoError(t, svc.Wait(ctx, jobs.Job{Name: "registry_gc", Class: jobs.ClassGC, Interval: 6 * time.Hour}))
+	waitCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	now = at(3, 45)
+	assert.ErrorIs(t, svc.Wait(waitCtx, jobs.Job{Name: "analytics_compaction", Class: jobs.ClassArchival}), context.DeadlineExceeded)
+
+	status, err := svc.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, status.Jobs, 2)
+	assert.Equal(t, "analytics_compaction", status.Jobs[0].Name)
+	assert.Nil(t, status.Jobs[0].WaitingSince)
+	for _, class := range status.Classes {
+		if class.Class == jobs.ClassArchival {
+			assert.False(t, class.AllowedNow)
+			require.NotNil(t, class.NextAllowedAt)
+			assert.Equal(t, at(4, 30), *class.NextAllowedAt)
+		}
+	}
+
+	_, err = svc.Calendar(ctx, day, day.Add(60*24*time.Hour))
+	assert.ErrorIs(t, err, ErrInvalidCalendarRange)
+	calendar, err := svc.Calendar(ctx, day, day.Add(24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, calendar.Windows, 2)
+	assert.Equal(t, "nightly", calendar.Windows[0].Name)
+	assert.Equal(t, at(4, 30), calendar.Windows[1].End)
+	require.Len(t, calendar.Runs, 3, "runs every six hours after 03:00")
+	assert.Equal(t, at(9, 0), calendar.Runs[0].ScheduledAt)
+	assert.True(t, calendar.Runs[0].Deferred)
+	require.NotNil(t, calendar.Runs[0].RunsAt)
+	assert.Equal(t, at(26, 0), *calendar.Runs[0].RunsAt)
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/malware"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrMalwareDetected is returned for uploads rejected by a malware scan
+	ErrMalwareDetected = errors.New("malware detected")
+	// ErrMalwareScanFailed is returned when content could not be scanned
+	ErrMalwareScanFailed = errors.New("malware scan failed")
+	// ErrMalwareQuarantined is returned for downloads of quarantined content
+	ErrMalwareQuarantined = errors.New("content is quarantined pending malware review")
+	// ErrMalwareDetectionNotFound is returned for unknown detections
+	ErrMalwareDetectionNotFound = errors.New("malware detection not found")
+	// ErrInvalidMalwareReview is returned for reviews of detections that are
+	// not quarantined and for unknown decisions
+	ErrInvalidMalwareReview = errors.New("invalid malware review")
+)
+
+// MalwareScanTarget describes content being scanned before it is stored
+type MalwareScanTarget struct {
+	Kind         string
+	ContentID    string
+	RepositoryID uuid.UUID
+	Name         string
+	Size         int64
+	UploaderID   *uuid.UUID
+}
+
+// MalwareScanService scans uploaded content with the configured scanner and
+// keeps the detections admins review. Files the upload API commits cannot
+// be held back, so they are always blocked on detection; other content is
+// quarantined unless block_on_detection is set.
+type MalwareScanService interface {
+	// Scan checks content before it is stored. It returns ErrMalwareDetected
+	// for blocked content and reports whether the content is quarantined.
+	Scan(ctx context.Context, target MalwareScanTarget, content io.Reader) (bool, error)
+	// Quarantined returns which of contentIDs of kind are quarantined
+	Quarantined(ctx context.Context, kind string, contentIDs ...string) (map[string]bool, error)
+	// List returns detections newest first, optionally with status
+	List(ctx context.Context, status string, limit, offset int) ([]*models.MalwareDetection, int64, error)
+	Get(ctx context.Context, id uuid.UUID) (*models.MalwareDetection, error)
+	// Review releases or deletes quarantined content. Deleting only records
+	// the decision; the caller removes the content.
+	Review(ctx context.Context, id, reviewerID uuid.UUID, decision, note string) (*models.MalwareDetection, error)
+}
+
+type malwareScanService struct {
+	db      *gorm.DB
+	scanner malware.Scanner
+	cfg     config.MalwareScanning
+	logger  *logrus.Logger
+}
+
+// NewMalwareScanService creates a new MalwareScanService; a nil scanner
+// accepts all content
+func NewMalwareScanService(db *gorm.DB, scanner malware.Scanner, cfg config.MalwareScanning, logger *logrus.Logger) MalwareScanService {
+	return &malwareScanService{
+		db:      db,
+		scanner: scanner,
+		cfg:     cfg,
+		logger:  logger,
+	}
+}
+
+func (s *malwareScanService) Scan(ctx context.Context, target MalwareScanTarget, content io.Reader) (bool, error) {
+	if s.scanner == nil {
+		return false, nil
+	}
+	fields := logrus.Fields{
+		"kind":          target.Kind,
+		"content_id":    target.ContentID,
+		"repository_id": target.RepositoryID,
+	}
+	if s.cfg.MaxScanSizeMB > 0 && target.Size > s.cfg.MaxScanSizeMB*1024*1024 {
+		s.logger.WithFields(fields).Warn("Content exceeds the malware scan size limit and was not scanned")
+		return false, nil
+	}
+
+	result, err := s.scanner.Scan(ctx, content)
+	if err != nil {
+		if s.cfg.FailOpen {
+			s.logger.WithError(err).WithFields(fields).Warn("Malware scan failed; accepting content unscanned")
+			return false, nil
+		}
+		return false, fmt.Errorf("%w: %v", ErrMalwareScanFailed, err)
+	}
+	if !result.Infected {
+		return false, nil
+	}
+
+	block := s.cfg.BlockOnDetection || target.Kind == models.MalwareContentUpload
+	detection := &models.MalwareDetection{
+		ID:           uuid.New(),
+		ContentKind:  target.Kind,
+		ContentID:    target.ContentID,
+		RepositoryID: target.RepositoryID,
+		Name:         target.Name,
+		Size:         target.Size,
+		UploaderID:   target.UploaderID,
+		Scanner:      s.scanner.Name(),
+		Signature:    result.Signature,
+		Status:       models.MalwareStatusQuarantined,
+	}
+	if block {
+		detection.Status = models.MalwareStatusBlocked
+	}
+	if err := s.db.WithContext(ctx).Create(detection).Error; err != nil {
+		return false, fmt.Errorf("failed to record malware detection: %w", err)
+	}
+
+	s.logger.WithFields(fields).WithFields(logrus.Fields{
+		"signature": result.Signature,
+		"status":    detection.Status,
+	}).Warn("Malware detected in upload")
+	if block {
+		return false, fmt.Errorf("%w: %s", ErrMalwareDetected, result.Signature)
+	}
+	return true, nil
+}
+
+func (s *malwareScanService) Quarantined(ctx context.Context, kind string, contentIDs ...string) (map[string]bool, error) {
+	quarantined := map[string]bool{}
+	if len(contentIDs) == 0 {
+		return quarantined, nil
+	}
+	var ids []string
+	err := s.db.WithContext(ctx).Model(&models.MalwareDetection{}).
+		Where("content_kind = ? AND content_id IN ? AND status = ?", kind, contentIDs, models.MalwareStatusQuarantined).
+		Pluck("content_id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up quarantined content: %w", err)
+	}
+	for _, id := range ids {
+		quarantined[id] = true
+	}
+	return quarantined, nil
+}
+
+func (s *malwareScanService) List(ctx context.Context, status string, limit, offset int) ([]*models.MalwareDetection, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.MalwareDetection{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count malware detections: %w", err)
+	}
+	var detections []*models.MalwareDetection
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&detections).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list malware detections: %w", err)
+	}
+	return detections, total, nil
+}
+
+func (s *malwareScanService) Get(ctx context.Context, id uuid.UUID) (*models.MalwareDetection, error) {
+	var detection models.MalwareDetection
+	err := s.db.WithContext(ctx).First(&detection, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMalwareDetectionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get malware detection: %w", err)
+	}
+	return &detection, nil
+}
+
+func (s *malwareScanService) Review(ctx context.Context, id, reviewerID uuid.UUID, decision, note string) (*models.MalwareDetection, error) {
+	if decision != models.MalwareStatusReleased && decision != models.MalwareStatusDeleted {
+		return nil, fmt.Errorf("%w: decision must be %s or %s", ErrInvalidMalwareReview, models.MalwareStatusReleased, models.MalwareStatusDeleted)
+	}
+	detection, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if detection.Status != models.MalwareStatusQuarantined {
+		return nil, fmt.Errorf("%w: detection is %s", ErrInvalidMalwareReview, detection.Status)
+	}
+
+	now := time.Now()
+	detection.Status = decision
+	detection.ReviewedByID = &reviewerID
+	detection.ReviewedAt = &now
+	detection.ReviewNote = note
+	err = s.db.WithContext(ctx).Model(detection).Updates(map[string]interface{}{
+		"status":         detection.Status,
+		"reviewed_by_id": detection.ReviewedByID,
+		"reviewed_at":    detection.ReviewedAt,
+		"review_note":    detection.ReviewNote,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to review malware detection: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"detection_id": detection.ID,
+		"decision":     decision,
+		"reviewer_id":  reviewerID,
+	}).Info("Reviewed quarantined upload")
+	return detection, nil
+}
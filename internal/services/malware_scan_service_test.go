@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/malware"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeMalwareScanner flags content containing "EICAR" and fails while down
+type fakeMalwareScanner struct {
+	down bool
+}
+
+func (s *fakeMalwareScanner) Name() string {
+	return "fake"
+}
+
+func (s *fakeMalwareScanner) Scan(ctx context.Context, content io.Reader) (*malware.Result, error) {
+	if s.down {
+		return nil, errors.New("connection refused")
+	}
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(string(data), "EICAR") {
+		return &malware.Result{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	}
+	return &malware.Result{}, nil
+}
+
+func TestMalwareScanService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Repository{}, &models.LFSObject{}, &models.MalwareDetection{})
+	ctx := context.Background()
+	scanner := &fakeMalwareScanner{}
+	svc := NewMalwareScanService(db, scanner, config.MalwareScanning{Enabled: true}, logrus.New())
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "assets",
+		DefaultBranch: "main", Visibility: models.VisibilityPublic}
+	require.NoError(t, db.Create(repo).Error)
+	target := func(kind, id string) MalwareScanTarget {
+		return MalwareScanTarget{Kind: kind, ContentID: id, RepositoryID: repo.ID, Name: id, Size: 5}
+	}
+
+	quarantined, err := svc.Scan(ctx, target(models.MalwareContentReleaseAsset, "clean"), strings.NewReader("hello"))
+	require.NoError(t, err)
+	assert.False(t, quarantined)
+
+	// Infected assets are quarantined unless detections block
+	quarantined, err = svc.Scan(ctx, target(models.MalwareContentReleaseAsset, "asset"), strings.NewReader("EICAR"))
+	require.NoError(t, err)
+	assert.True(t, quarantined)
+	_, err = svc.Scan(ctx, target(models.MalwareContentUpload, "upload"), strings.NewReader("EICAR"))
+	assert.ErrorIs(t, err, ErrMalwareDetected, "committed uploads cannot be quarantined")
+	blocking := NewMalwareScanService(db, scanner, config.MalwareScanning{Enabled: true, BlockOnDetection: true}, logrus.New())
+	_, err = blocking.Scan(ctx, target(models.MalwareContentReleaseAsset, "blocked"), strings.NewReader("EICAR"))
+	assert.ErrorIs(t, err, ErrMalwareDetected)
+
+	held, err := svc.Quarantined(ctx, models.MalwareContentReleaseAsset, "clean", "asset", "blocked")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"asset": true}, held)
+	queue, total, err := svc.List(ctx, models.MalwareStatusQuarantined, 30, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, queue, 1)
+	assert.Equal(t, "Eicar-Test-Signature", queue[0].Signature)
+	_, total, err = svc.List(ctx, "", 30, 0)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), total)
+
+	// Unreachable scanners fail uploads unless scanning fails open
+	scanner.down = true
+	_, err = svc.Scan(ctx, target(models.MalwareContentReleaseAsset, "unscanned"), strings.NewReader("hello"))
+	assert.ErrorIs(t, err, ErrMalwareScanFailed)
+	failOpen := NewMalwareScanService(db, scanner, config.MalwareScanning{Enabled: true, FailOpen: true}, logrus.New())
+	_, err = failOpen.Scan(ctx, target(models.MalwareContentReleaseAsset, "unscanned"), strings.NewReader("hello"))
+	assert.NoError(t, err)
+	scanner.down = false
+
+	// Quarantined LFS objects are stored but cannot be downloaded
+	backend, err := storage.NewFilesystemBackend(storage.FilesystemConfig{BasePath: t.TempDir()})
+	require.NoError(t, err)
+	lfs := NewLFSService(db, backend, svc, config.LFS{}, logrus.New())
+	content := "EICAR in LFS"
+	sum := sha256.Sum256([]byte(content))
+	oid := hex.EncodeToString(sum[:])
+	require.NoError(t, lfs.Upload(ctx, repo, oid, int64(len(content)), strings.NewReader(content)))
+	_, _, err = lfs.Download(ctx, repo, oid)
+	assert.ErrorIs(t, err, ErrMalwareQuarantined)
+	batch, err := lfs.Batch(ctx, repo, &LFSBatchRequest{Operation: LFSOperationDownload, Objects: []LFSObjectSpec{{OID: oid}}}, "https://hub/objects", nil)
+	require.NoError(t, err)
+	require.NotNil(t, batch.Objects[0].Error)
+	assert.Equal(t, 403, batch.Objects[0].Error.Code)
+
+	queue, _, err = svc.List(ctx, models.MalwareStatusQuarantined, 30, 0)
+	require.NoError(t, err)
+	require.Len(t, queue, 2)
+	reviewer := uuid.New()
+	_, err = svc.Review(ctx, queue[0].ID, reviewer, "ignore", "")
+	assert.ErrorIs(t, err, ErrInvalidMalwareReview)
+	for _, detection := range queue {
+		if detection.ContentKind == models.MalwareContentLFSObject {
+			reviewed, err := svc.Review(ctx, detection.ID, reviewer, models.MalwareStatusReleased, "false positive")
+			require.NoError(t, err)
+			assert.Equal(t, models.MalwareStatusReleased, reviewed.Status)
+			_, err = svc.Review(ctx, detection.ID, reviewer, models.MalwareStatusDeleted, "")
+			assert.ErrorIs(t, err, ErrInvalidMalwareReview, "only quarantined content is reviewed")
+		}
+	}
+	reader, _, err := lfs.Download(ctx, repo, oid)
+	require.NoError(t, err)
+	reader.Close()
+
+	require.NoError(t, lfs.Purge(ctx, oid))
+	_, _, err = lfs.Download(ctx, repo, oid)
+	assert.ErrorIs(t, err, ErrLFSObjectNotFound)
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// MergeChecklistService manages the manual checks a repository requires
+// before merge and tracks who checked them off on each pull request
+type MergeChecklistService interface {
+	ListItems(ctx context.Context, repoID uuid.UUID) ([]*models.MergeChecklistItem, error)
+	CreateItem(ctx context.Context, repoID uuid.UUID, req MergeChecklistItemRequest) (*models.MergeChecklistItem, error)
+	UpdateItem(ctx context.Context, repoID, itemID uuid.UUID, req MergeChecklistItemRequest) (*models.MergeChecklistItem, error)
+	DeleteItem(ctx context.Context, repoID, itemID uuid.UUID) error
+
+	// Evaluate reports every item that applies to the pull request's base
+	// branch and whether it has been checked off
+	Evaluate(ctx context.Context, pr *models.PullRequest) ([]*ChecklistItemStatus, error)
+	Check(ctx context.Context, pr *models.PullRequest, itemID, actorID uuid.UUID, note string) (*models.PullRequestChecklistCheck, error)
+	Uncheck(ctx context.Context, pr *models.PullRequest, itemID, actorID uuid.UUID, note string) error
+	History(ctx context.Context, pr *models.PullRequest) ([]*models.PullRequestChecklistEvent, error)
+}
+
+// MergeChecklistItemRequest creates or updates a checklist item
+type MergeChecklistItemRequest struct {
+	Title         *string   `json:"title"`
+	Description   *string   `json:"description"`
+	BranchPattern *string   `json:"branch_pattern"`
+	RequiredTeams *[]string `json:"required_teams"`
+	Position      *int      `json:"position"`
+}
+
+// ChecklistItemStatus reports whether one checklist item is checked on a pull request
+type ChecklistItemStatus struct {
+	Item      *models.MergeChecklistItem `json:"item"`
+	Checked   bool                       `json:"checked"`
+	CheckedBy string                     `json:"checked_by,omitempty"`
+	CheckedAt *time.Time                 `json:"checked_at,omitempty"`
+	Note      string                     `json:"note,omitempty"`
+}
+
+var (
+	ErrChecklistItemNotFound      = errors.New("merge checklist item not found")
+	ErrInvalidChecklistItem       = errors.New("invalid merge checklist item")
+	ErrChecklistNotAuthorized     = errors.New("not authorized to change this checklist item")
+	ErrChecklistPullRequestClosed = errors.New("pull request is not open")
+)
+
+type mergeChecklistService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+// NewMergeChecklistService creates a new MergeChecklistService
+func NewMergeChecklistService(db *gorm.DB, logger *logrus.Logger) MergeChecklistService {
+	return &mergeChecklistService{
+		db:     db,
+		logger: logger,
+	}
+}
+
+func (s *mergeChecklistService) ListItems(ctx context.Context, repoID uuid.UUID) ([]*models.MergeChecklistItem, error) {
+	var items []*models.MergeChecklistItem
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repoID).
+		Order("position ASC, created_at ASC").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to list merge checklist items: %w", err)
+	}
+	return items, nil
+}
+
+func (s *mergeChecklistService) CreateItem(ctx context.Context, repoID uuid.UUID, req MergeChecklistItemRequest) (*models.MergeChecklistItem, error) {
+	if req.Title == nil {
+		return nil, fmt.Errorf("%w: title is required", ErrInvalidChecklistItem)
+	}
+	item := &models.MergeChecklistItem{
+		ID:            uuid.New(),
+		RepositoryID:  repoID,
+		BranchPattern: "*",
+	}
+	if err := s.applyItemRequest(ctx, item, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(item).Error; err != nil {
+		return nil, fmt.Errorf("failed to create merge checklist item: %w", err)
+	}
+	return item, nil
+}
+
+func (s *mergeChecklistService) UpdateItem(ctx context.Context, repoID, itemID uuid.UUID, req MergeChecklistItemRequest) (*models.MergeChecklistItem, error) {
+	item, err := s.getItem(ctx, repoID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyItemRequest(ctx, item, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Save(item).Error; err != nil {
+		return nil, fmt.Errorf("failed to update merge checklist item: %w", err)
+	}
+	return item, nil
+}
+
+func (s *mergeChecklistService) DeleteItem(ctx context.Context, repoID, itemID uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("repository_id = ?", repoID).Delete(&models.MergeChecklistItem{}, "id = ?", itemID)
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete merge checklist item: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrChecklistItemNotFound
+	}
+	return nil
+}
+
+func (s *mergeChecklistService) getItem(ctx context.Context, repoID, itemID uuid.UUID) (*models.MergeChecklistItem, error) {
+	var item models.MergeChecklistItem
+	err := s.db.WithContext(ctx).Where("repository_id = ? AND id = ?", repoID, itemID).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrChecklistItemNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get merge checklist item: %w", err)
+	}
+	return &item, nil
+}
+
+func (s *mergeChecklistService) applyItemRequest(ctx context.Context, item *models.MergeChecklistItem, req MergeChecklistItemRequest) error {
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" || len(title) > 255 {
+			return fmt.Errorf("%w: title must be between 1 and 255 characters", ErrInvalidChecklistItem)
+		}
+		item.Title = title
+	}
+	if req.Description != nil {
+		item.Description = *req.Description
+	}
+	if req.BranchPattern != nil {
+		item.BranchPattern = strings.TrimSpace(*req.BranchPattern)
+		if item.BranchPattern == "" {
+			item.BranchPattern = "*"
+		}
+	}
+	if req.RequiredTeams != nil {
+		if err := s.validateTeams(ctx, item.RepositoryID, *req.RequiredTeams); err != nil {
+			return err
+		}
+		item.RequiredTeams = *req.RequiredTeams
+	}
+	if req.Position != nil {
+		item.Position = *req.Position
+	}
+	return nil
+}
+
+// validateTeams checks that every team exists in the organization owning the repository
+func (s *mergeChecklistService) validateTeams(ctx context.Context, repoID uuid.UUID, teams []string) error {
+	if len(teams) == 0 {
+		return nil
+	}
+
+	var repo models.Repository
+	if err := s.db.WithContext(ctx).First(&repo, "id = ?", repoID).Error; err != nil {
+		return fmt.Errorf("failed to load repository: %w", err)
+	}
+	if repo.OwnerType != models.OwnerTypeOrganization {
+		return fmt.Errorf("%w: required teams need an organization repository", ErrInvalidChecklistItem)
+	}
+
+	var found []string
+	if err := s.db.WithContext(ctx).Model(&models.Team{}).
+		Where("organization_id = ? AND name IN ?", repo.OwnerID, teams).
+		Pluck("name", &found).Error; err != nil {
+		return fmt.Errorf("failed to look up teams: %w", err)
+	}
+	known := make(map[string]bool, len(found))
+	for _, name := range found {
+		known[name] = true
+	}
+	for _, name := range teams {
+		if !known[name] {
+			return fmt.Errorf("%w: team %q not found", ErrInvalidChecklistItem, name)
+		}
+	}
+	return nil
+}
+
+func (s *mergeChecklistService) Evaluate(ctx context.Context, pr *models.PullRequest) ([]*ChecklistItemStatus, error) {
+	items, err := s.ListItems(ctx, pr.RepositoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	var checks []*models.PullRequestChecklistCheck
+	if err := s.db.WithContext(ctx).Preload("CheckedBy").
+		Where("pull_request_id = ?", pr.ID).Find(&checks).Error; err != nil {
+		return nil, fmt.Errorf("failed to load checklist checks: %w", err)
+	}
+	byItem := make(map[uuid.UUID]*models.PullRequestChecklistCheck, len(checks))
+	for _, check := range checks {
+		byItem[check.ItemID] = check
+	}
+
+	statuses := []*ChecklistItemStatus{}
+	for _, item := range items {
+		if !matchPattern(item.BranchPattern, pr.BaseBranch) {
+			continue
+		}
+		status := &ChecklistItemStatus{Item: item}
+		if check, ok := byItem[item.ID]; ok {
+			status.Checked = true
+			status.CheckedAt = &check.CreatedAt
+			status.Note = check.Note
+			status.CheckedBy = check.CheckedByID.String()
+			if check.CheckedBy != nil {
+				status.CheckedBy = check.CheckedBy.Username
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Check marks an item as done. Checking an item that is already checked
+// returns the existing check unchanged.
+func (s *mergeChecklistService) Check(ctx context.Context, pr *models.PullRequest, itemID, actorID uuid.UUID, note string) (*models.PullRequestChecklistCheck, error) {
+	item, err := s.authorize(ctx, pr, itemID, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	var check *models.PullRequestChecklistCheck
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.PullRequestChecklistCheck
+		err := tx.Where("pull_request_id = ? AND item_id = ?", pr.ID, item.ID).First(&existing).Error
+		if err == nil {
+			check = &existing
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		check = &models.PullRequestChecklistCheck{
+			ID:            uuid.New(),
+			PullRequestID: pr.ID,
+			ItemID:        item.ID,
+			CheckedByID:   actorID,
+			Note:          note,
+		}
+		if err := tx.Create(check).Error; err != nil {
+			return err
+		}
+		return tx.Create(s.event(pr, item, actorID, models.ChecklistActionChecked, note)).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check merge checklist item: %w", err)
+	}
+	return check, nil
+}
+
+func (s *mergeChecklistService) Uncheck(ctx context.Context, pr *models.PullRequest, itemID, actorID uuid.UUID, note string) error {
+	item, err := s.authorize(ctx, pr, itemID, actorID)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("pull_request_id = ? AND item_id = ?", pr.ID, item.ID).Delete(&models.PullRequestChecklistCheck{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Create(s.event(pr, item, actorID, models.ChecklistActionUnchecked, note)).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to uncheck merge checklist item: %w", err)
+	}
+	return nil
+}
+
+func (s *mergeChecklistService) event(pr *models.PullRequest, item *models.MergeChecklistItem, actorID uuid.UUID, action models.ChecklistAction, note string) *models.PullRequestChecklistEvent {
+	return &models.PullRequestChecklistEvent{
+		ID:            uuid.New(),
+		PullRequestID: pr.ID,
+		ItemID:        item.ID,
+		ItemTitle:     item.Title,
+		ActorID:       actorID,
+		Action:        action,
+		Note:          note,
+	}
+}
+
+// authorize loads an item that applies to the pull request and checks that
+// the actor belongs to one of its required teams, if it has any
+func (s *mergeChecklistService) authorize(ctx context.Context, pr *models.PullRequest, itemID, actorID uuid.UUID) (*models.MergeChecklistItem, error) {
+	if pr.State != models.PullRequestStateOpen {
+		return nil, ErrChecklistPullRequestClosed
+	}
+	item, err := s.getItem(ctx, pr.RepositoryID, itemID)
+	if err != nil {
+		return nil, err
+	}
+	if !matchPattern(item.BranchPattern, pr.BaseBranch) {
+		return nil, fmt.Errorf("%w: item does not apply to branch %s", ErrChecklistItemNotFound, pr.BaseBranch)
+	}
+	if len(item.RequiredTeams) == 0 {
+		return item, nil
+	}
+
+	var count int64
+	err = s.db.WithContext(ctx).Model(&models.Team{}).
+		Joins("JOIN repositories ON repositories.owner_id = teams.organization_id AND repositories.id = ?", pr.RepositoryID).
+		Joins("JOIN team_members ON team_members.team_id = teams.id AND team_members.deleted_at IS NULL").
+		Where("teams.name IN ? AND team_members.user_id = ?", item.RequiredTeams, actorID).
+		Count(&count).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to check team membership: %w", err)
+	}
+	if count == 0 {
+		return nil, fmt.Errorf("%w: requires membership in %s", ErrChecklistNotAuthorized, strings.Join(item.RequiredTeams, ", "))
+	}
+	return item, nil
+}
+
+// History lists checklist changes on the pull request, oldest first
+func (s *mergeChecklistService) History(ctx context.Context, pr *models.PullRequest) ([]*models.PullRequestChecklistEvent, error) {
+	var events []*models.PullRequestChecklistEvent
+	if err := s.db.WithContext(ctx).Preload("Actor").
+		Where("pull_request_id = ?", pr.ID).
+		Order("created_at ASC").Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to list checklist history: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeChecklistService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.Team{}, &models.TeamMember{},
+		&models.Repository{}, &models.Issue{}, &models.PullRequest{}, &models.PullRequestMerge{}, &models.IssueEvent{},
+		&models.Review{}, &models.BranchProtectionRule{}, &models.PathProtectionRule{},
+		&models.MergeChecklistItem{}, &models.PullRequestChecklistCheck{}, &models.PullRequestChecklistEvent{}, &models.RequiredStatusCheck{},
+		&models.ChangeManagementPolicy{}, &models.PullRequestChangeTicket{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	svc := NewMergeChecklistService(db, logger)
+
+	newUser := func(name string) *models.User {
+		user := &models.User{ID: uuid.New(), Username: name, Email: name + "@example.com", PasswordHash: "x"}
+		require.NoError(t, db.Create(user).Error)
+		return user
+	}
+	developer, auditor := newUser("developer"), newUser("auditor")
+
+	orgID := uuid.New()
+	team := &models.Team{ID: uuid.New(), OrganizationID: orgID, Name: "security", Privacy: models.TeamPrivacyClosed}
+	require.NoError(t, db.Create(team).Error)
+	require.NoError(t, db.Create(&models.TeamMember{ID: uuid.New(), TeamID: team.ID, UserID: auditor.ID, Role: models.TeamRoleMember}).Error)
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: orgID, OwnerType: models.OwnerTypeOrganization, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	pr := &models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 1, Title: "Add widgets",
+		BaseBranch: "main", HeadBranch: "widgets", State: models.PullRequestStateOpen}
+	require.NoError(t, db.Create(pr).Error)
+
+	str := func(s string) *string { return &s }
+	position := 5
+	t.Run("validates items", func(t *testing.T) {
+		_, err := svc.CreateItem(ctx, repo.ID, MergeChecklistItemRequest{})
+		assert.ErrorIs(t, err, ErrInvalidChecklistItem)
+		_, err = svc.CreateItem(ctx, repo.ID, MergeChecklistItemRequest{Title: str("Review"), RequiredTeams: &[]string{"missing"}})
+		assert.ErrorIs(t, err, ErrInvalidChecklistItem)
+	})
+
+	security, err := svc.CreateItem(ctx, repo.ID, MergeChecklistItemRequest{Title: str("Security review done"), RequiredTeams: &[]string{"security"}, Position: &position})
+	require.NoError(t, err)
+	docs, err := svc.CreateItem(ctx, repo.ID, MergeChecklistItemRequest{Title: str("Docs updated")})
+	require.NoError(t, err)
+	_, err = svc.CreateItem(ctx, repo.ID, MergeChecklistItemRequest{Title: str("Release notes"), BranchPattern: str("release/*")})
+	require.NoError(t, err)
+
+	statuses, err := svc.Evaluate(ctx, pr)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2, "items for other branches do not apply")
+
+	prService := NewPullRequestService(db, nil, nil, logger, "")
+	merge := MergePullRequestRequest{MergeMethod: "merge"}
+	var blocked *MergeBlockedError
+	require.True(t, errors.As(prService.Merge(ctx, pr.ID, merge), &blocked))
+	assert.Len(t, blocked.Requirements.Reasons, 2)
+
+	t.Run("required teams restrict who can check", func(t *testing.T) {
+		_, err := svc.Check(ctx, pr, security.ID, developer.ID, "")
+		assert.ErrorIs(t, err, ErrChecklistNotAuthorized)
+	})
+
+	_, err = svc.Check(ctx, pr, security.ID, auditor.ID, "looked at the auth changes")
+	require.NoError(t, err)
+	_, err = svc.Check(ctx, pr, docs.ID, developer.ID, "")
+	require.NoError(t, err)
+	_, err = svc.Check(ctx, pr, docs.ID, developer.ID, "again")
+	require.NoError(t, err, "checking twice is a no-op")
+	require.NoError(t, svc.Uncheck(ctx, pr, docs.ID, developer.ID, "docs were wrong"))
+	_, err = svc.Check(ctx, pr, docs.ID, developer.ID, "")
+	require.NoError(t, err)
+
+	statuses, err = svc.Evaluate(ctx, pr)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "Docs updated", statuses[0].Item.Title)
+	assert.True(t, statuses[0].Checked)
+	assert.Equal(t, "auditor", statuses[1].CheckedBy)
+	assert.Equal(t, "looked at the auth changes", statuses[1].Note)
+
+	history, err := svc.History(ctx, pr)
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+	assert.Equal(t, models.ChecklistActionUnchecked, history[2].Action)
+	assert.Equal(t, "docs were wrong", history[2].Note)
+	require.NotNil(t, history[2].Actor)
+	assert.Equal(t, "developer", history[2].Actor.Username)
+
+	require.NoError(t, prService.Merge(ctx, pr.ID, merge))
+
+	pr.State = models.PullRequestStateMerged
+	_, err = svc.Check(ctx, pr, docs.ID, developer.ID, "")
+	assert.ErrorIs(t, err, ErrChecklistPullRequestClosed)
+}
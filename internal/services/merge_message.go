@@ -0,0 +1,143 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+)
+
+var (
+	ErrInvalidMergeTemplate    = errors.New("invalid merge commit template")
+	ErrInvalidTitlePattern     = errors.New("invalid pull request title pattern")
+	ErrPullRequestTitleInvalid = errors.New("pull request title does not match the repository's title convention")
+	ErrInvalidMergeMethod      = errors.New("merge method must be one of merge, squash or rebase")
+)
+
+// Default merge commit templates, used when a repository leaves its own empty
+const (
+	DefaultMergeCommitTitleTemplate    = "Merge pull request #{{number}} from {{head_branch}}"
+	DefaultMergeCommitMessageTemplate  = "{{title}}"
+	DefaultSquashCommitTitleTemplate   = "{{title}} (#{{number}})"
+	DefaultSquashCommitMessageTemplate = "{{commits}}\n\n{{co_authors}}"
+)
+
+// MergeTemplatePlaceholders lists the placeholders merge commit templates may use
+var MergeTemplatePlaceholders = []string{"number", "title", "body", "author", "head_branch", "base_branch", "co_authors", "commits"}
+
+var (
+	mergeTemplatePlaceholder = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+	blankLineRuns            = regexp.MustCompile(`\n{3,}`)
+)
+
+// MergeMessageData is what merge commit template placeholders expand to
+type MergeMessageData struct {
+	Number     int
+	Title      string
+	Body       string
+	Author     string
+	HeadBranch string
+	BaseBranch string
+	// CoAuthors are "Name <email>" of the pull request's other commit authors
+	CoAuthors []string
+	// Commits are the subject lines of the pull request's commits
+	Commits []string
+}
+
+// ValidateMergeTemplate checks that a template only uses known placeholders
+func ValidateMergeTemplate(template string) error {
+	for _, m := range mergeTemplatePlaceholder.FindAllStringSubmatch(template, -1) {
+		known := false
+		for _, name := range MergeTemplatePlaceholders {
+			if m[1] == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("%w: unknown placeholder %q", ErrInvalidMergeTemplate, m[0])
+		}
+	}
+	return nil
+}
+
+// RenderMergeTemplate expands the placeholders of a merge commit template.
+// Runs of blank lines left by empty placeholders are collapsed.
+func RenderMergeTemplate(template string, data MergeMessageData) string {
+	coAuthors := make([]string, len(data.CoAuthors))
+	for i, coAuthor := range data.CoAuthors {
+		coAuthors[i] = "Co-authored-by: " + coAuthor
+	}
+	commits := make([]string, len(data.Commits))
+	for i, subject := range data.Commits {
+		commits[i] = "* " + subject
+	}
+	values := map[string]string{
+		"number":      strconv.Itoa(data.Number),
+		"title":       data.Title,
+		"body":        data.Body,
+		"author":      data.Author,
+		"head_branch": data.HeadBranch,
+		"base_branch": data.BaseBranch,
+		"co_authors":  strings.Join(coAuthors, "\n"),
+		"commits":     strings.Join(commits, "\n"),
+	}
+
+	rendered := mergeTemplatePlaceholder.ReplaceAllStringFunc(template, func(m string) string {
+		return values[mergeTemplatePlaceholder.FindStringSubmatch(m)[1]]
+	})
+	rendered = blankLineRuns.ReplaceAllString(rendered, "\n\n")
+	return strings.TrimSpace(rendered)
+}
+
+// MergeCommitMessage renders a repository's commit title and message for
+// merging with the given method; rebase merges keep the original commits
+func MergeCommitMessage(repo *models.Repository, method models.MergeMethod, data MergeMessageData) (string, string) {
+	switch method {
+	case models.MergeMethodSquash:
+		return RenderMergeTemplate(orDefault(repo.SquashCommitTitleTemplate, DefaultSquashCommitTitleTemplate), data),
+			RenderMergeTemplate(orDefault(repo.SquashCommitMessageTemplate, DefaultSquashCommitMessageTemplate), data)
+	case models.MergeMethodRebase:
+		return "", ""
+	default:
+		return RenderMergeTemplate(orDefault(repo.MergeCommitTitleTemplate, DefaultMergeCommitTitleTemplate), data),
+			RenderMergeTemplate(orDefault(repo.MergeCommitMessageTemplate, DefaultMergeCommitMessageTemplate), data)
+	}
+}
+
+// ValidateTitlePattern checks that a pull request title pattern compiles
+func ValidateTitlePattern(pattern string) error {
+	if pattern == "" {
+		return nil
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTitlePattern, err)
+	}
+	return nil
+}
+
+// CheckPullRequestTitle enforces a repository's title convention, such as
+// a Conventional Commits pattern; repositories without one accept any title
+func CheckPullRequestTitle(repo *models.Repository, title string) error {
+	if repo.PullRequestTitlePattern == "" {
+		return nil
+	}
+	re, err := regexp.Compile(repo.PullRequestTitlePattern)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTitlePattern, err)
+	}
+	if !re.MatchString(title) {
+		return fmt.Errorf("%w (%s)", ErrPullRequestTitleInvalid, repo.PullRequestTitlePattern)
+	}
+	return nil
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderMergeTemplate(t *testing.T) {
+	data := MergeMessageData{
+		Number:     42,
+		Title:      "feat: add widgets",
+		HeadBranch: "widgets",
+		CoAuthors:  []string{"Bob <bob@example.com>"},
+		Commits:    []string{"add widget model", "add widget API"},
+	}
+
+	repo := &models.Repository{}
+	title, message := MergeCommitMessage(repo, models.MergeMethodSquash, data)
+	assert.Equal(t, "feat: add widgets (#42)", title)
+	assert.Equal(t, "* add widget model\n* add widget API\n\nCo-authored-by: Bob <bob@example.com>", message)
+
+	title, message = MergeCommitMessage(repo, models.MergeMethodMerge, data)
+	assert.Equal(t, "Merge pull request #42 from widgets", title)
+	assert.Equal(t, "feat: add widgets", message)
+
+	repo.MergeCommitMessageTemplate = "{{ title }}\n\n{{body}}\n\n\n{{co_authors}}"
+	_, message = MergeCommitMessage(repo, models.MergeMethodMerge, data)
+	assert.Equal(t, "feat: add widgets\n\nCo-authored-by: Bob <bob@example.com>", message)
+
+	assert.NoError(t, ValidateMergeTemplate(DefaultSquashCommitMessageTemplate))
+	assert.True(t, errors.Is(ValidateMergeTemplate("{{title}} {{ticket}}"), ErrInvalidMergeTemplate))
+}
+
+func TestCheckPullRequestTitle(t *testing.T) {
+	repo := &models.Repository{}
+	assert.NoError(t, CheckPullRequestTitle(repo, "anything goes"))
+
+	repo.PullRequestTitlePattern = `^(feat|fix|chore)(\([a-z-]+\))?!?: .+`
+	assert.NoError(t, CheckPullRequestTitle(repo, "fix(api): handle empty body"))
+	assert.True(t, errors.Is(CheckPullRequestTitle(repo, "Handle empty body"), ErrPullRequestTitleInvalid))
+
+	assert.True(t, errors.Is(ValidateTitlePattern("(unclosed"), ErrInvalidTitlePattern))
+}
+
+func TestPullRequestService_MergeAppliesTemplates(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.Issue{}, &models.PullRequest{},
+		&models.PullRequestMerge{}, &models.IssueEvent{}, &models.PathProtectionRule{}, &models.Review{}, &models.BranchProtectionRule{},
+		&models.MergeChecklistItem{}, &models.PullRequestChecklistCheck{}, &models.RequiredStatusCheck{},
+		&models.ChangeManagementPolicy{}, &models.PullRequestChangeTicket{})
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "app",
+		DefaultBranch: "main", Visibility: models.VisibilityPublic, PullRequestTitlePattern: `^(feat|fix): `,
+		SquashCommitTitleTemplate: "{{title}} [#{{number}}]"}
+	require.NoError(t, db.Create(repo).Error)
+
+	svc := NewPullRequestService(db, nil, nil, logrus.New(), "")
+	ctx := context.Background()
+
+	_, err := svc.Create(ctx, repo.ID, uuid.New(), CreatePullRequestRequest{Title: "Add widgets", Head: "widgets", Base: "main"})
+	assert.True(t, errors.Is(err, ErrPullRequestTitleInvalid))
+
+	pr := &models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 1, Title: "feat: add widgets",
+		BaseBranch: "main", HeadBranch: "widgets", State: models.PullRequestStateOpen}
+	require.NoError(t, db.Create(pr).Error)
+
+	badTitle := "widgets"
+	_, err = svc.Update(ctx, pr.ID, UpdatePullRequestRequest{Title: &badTitle})
+	assert.True(t, errors.Is(err, ErrPullRequestTitleInvalid))
+
+	assert.True(t, errors.Is(svc.Merge(ctx, pr.ID, MergePullRequestRequest{MergeMethod: "octopus"}), ErrInvalidMergeMethod))
+	require.NoError(t, svc.Merge(ctx, pr.ID, MergePullRequestRequest{MergeMethod: "squash"}))
+
+	var merge models.PullRequestMerge
+	require.NoError(t, db.First(&merge, "pull_request_id = ?", pr.ID).Error)
+	assert.Equal(t, models.MergeMethodSquash, merge.MergeMethod)
+	assert.Equal(t, "feat: add widgets [#1]", merge.CommitTitle)
+}
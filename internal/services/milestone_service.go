@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrMilestoneNotFound = errors.New("milestone not found")
+	ErrInvalidMilestone  = errors.New("invalid milestone")
+)
+
+type CreateMilestoneRequest struct {
+	Title       string     `json:"title" binding:"required"`
+	Description string     `json:"description"`
+	DueOn       *time.Time `json:"due_on,omitempty"`
+}
+
+type UpdateMilestoneRequest struct {
+	Title       *string                `json:"title,omitempty"`
+	Description *string                `json:"description,omitempty"`
+	State       *models.MilestoneState `json:"state,omitempty"`
+	DueOn       *time.Time             `json:"due_on,omitempty"`
+}
+
+// MilestoneService manages repository milestones
+type MilestoneService interface {
+	List(ctx context.Context, repoID uuid.UUID, state *models.MilestoneState) ([]*models.Milestone, error)
+	Get(ctx context.Context, repoID uuid.UUID, number int) (*models.Milestone, error)
+	Create(ctx context.Context, repoID uuid.UUID, req CreateMilestoneRequest) (*models.Milestone, error)
+	Update(ctx context.Context, milestone *models.Milestone, req UpdateMilestoneRequest) (*models.Milestone, error)
+	// Delete removes a milestone, leaving its issues without one
+	Delete(ctx context.Context, milestone *models.Milestone) error
+}
+
+type milestoneService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+// NewMilestoneService creates a new MilestoneService
+func NewMilestoneService(db *gorm.DB, logger *logrus.Logger) MilestoneService {
+	return &milestoneService{db: db, logger: logger}
+}
+
+func (s *milestoneService) List(ctx context.Context, repoID uuid.UUID, state *models.MilestoneState) ([]*models.Milestone, error) {
+	query := s.db.WithContext(ctx).Where("repository_id = ?", repoID)
+	if state != nil {
+		query = query.Where("state = ?", *state)
+	}
+	var milestones []*models.Milestone
+	if err := query.Order("number ASC").Find(&milestones).Error; err != nil {
+		return nil, fmt.Errorf("failed to list milestones: %w", err)
+	}
+	return milestones, nil
+}
+
+func (s *milestoneService) Get(ctx context.Context, repoID uuid.UUID, number int) (*models.Milestone, error) {
+	var milestone models.Milestone
+	err := s.db.WithContext(ctx).Where("repository_id = ? AND number = ?", repoID, number).First(&milestone).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrMilestoneNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get milestone: %w", err)
+	}
+	return &milestone, nil
+}
+
+func (s *milestoneService) Create(ctx context.Context, repoID uuid.UUID, req CreateMilestoneRequest) (*models.Milestone, error) {
+	title := strings.TrimSpace(req.Title)
+	if title == "" || len(title) > 255 {
+		return nil, fmt.Errorf("%w: title must be between 1 and 255 characters", ErrInvalidMilestone)
+	}
+
+	milestone := &models.Milestone{
+		ID:           uuid.New(),
+		RepositoryID: repoID,
+		Title:        title,
+		Description:  req.Description,
+		State:        models.MilestoneStateOpen,
+		DueOn:        req.DueOn,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var last int
+		if err := tx.Model(&models.Milestone{}).Unscoped().Where("repository_id = ?", repoID).
+			Select("COALESCE(MAX(number), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+		milestone.Number = last + 1
+		return tx.Create(milestone).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create milestone: %w", err)
+	}
+	return milestone, nil
+}
+
+func (s *milestoneService) Update(ctx context.Context, milestone *models.Milestone, req UpdateMilestoneRequest) (*models.Milestone, error) {
+	updates := map[string]interface{}{}
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" || len(title) > 255 {
+			return nil, fmt.Errorf("%w: title must be between 1 and 255 characters", ErrInvalidMilestone)
+		}
+		updates["title"] = title
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.State != nil {
+		if *req.State != models.MilestoneStateOpen && *req.State != models.MilestoneStateClosed {
+			return nil, fmt.Errorf("%w: state must be open or closed", ErrInvalidMilestone)
+		}
+		updates["state"] = *req.State
+	}
+	if req.DueOn != nil {
+		updates["due_on"] = *req.DueOn
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.WithContext(ctx).Model(milestone).Updates(updates).Error; err != nil {
+			return nil, fmt.Errorf("failed to update milestone: %w", err)
+		}
+	}
+	return s.Get(ctx, milestone.RepositoryID, milestone.Number)
+}
+
+func (s *milestoneService) Delete(ctx context.Context, milestone *models.Milestone) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Issue{}).Where("milestone_id = ?", milestone.ID).
+			Update("milestone_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(milestone).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete milestone: %w", err)
+	}
+	s.logger.WithFields(logrus.Fields{
+		"milestone_id":  milestone.ID,
+		"repository_id": milestone.RepositoryID,
+	}).Info("Milestone deleted")
+	return nil
+}
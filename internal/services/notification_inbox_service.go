@@ -0,0 +1,527 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/i18n"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// notificationMaxMentions caps the users one text can notify
+	notificationMaxMentions = 50
+	// notificationDigestLimit caps the threads listed in one email
+	notificationDigestLimit = 50
+)
+
+// ErrNotificationNotFound is returned for threads of other users too
+var ErrNotificationNotFound = errors.New("notification not found")
+
+// mentionPattern matches @username not preceded by a word character, so
+// email addresses are not mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@/])@([A-Za-z0-9][A-Za-z0-9_.-]*)`)
+
+// NotificationFilter narrows a user's notifications. Only unread threads
+// are listed unless All is set.
+type NotificationFilter struct {
+	All bool
+	// Participating leaves out threads from watched repositories the user
+	// is not involved in
+	Participating bool
+	RepositoryID  *uuid.UUID
+	Since         *time.Time
+	Page          int
+	PerPage       int
+}
+
+// NotificationInboxService keeps each user's notification threads about
+// mentions, review requests, assignments, failed workflow runs and
+// activity in watched repositories, and emails digests of unread threads.
+// New threads are also pushed to the user's live NotificationService
+// subscribers.
+type NotificationInboxService interface {
+	List(ctx context.Context, userID uuid.UUID, filter NotificationFilter) ([]*models.Notification, int64, error)
+	Get(ctx context.Context, userID, id uuid.UUID) (*models.Notification, error)
+	UnreadCount(ctx context.Context, userID uuid.UUID) (int64, error)
+	// MarkRead marks the listed threads read or, when none are listed,
+	// every thread last updated before lastReadAt
+	MarkRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID, lastReadAt time.Time) (int64, error)
+	// Delete marks a thread done; new activity on its subject starts a new one
+	Delete(ctx context.Context, userID, id uuid.UUID) error
+
+	// HandleIssue is an IssueListener
+	HandleIssue(ctx context.Context, event IssueEvent)
+	// HandlePullRequest is a PullRequestListener
+	HandlePullRequest(ctx context.Context, event PullRequestEvent)
+	// HandleWorkflowRun is a WorkflowRunListener notifying about failed runs
+	HandleWorkflowRun(ctx context.Context, run *models.WorkflowRun)
+
+	// SendDueDigests emails every user whose notification email is due the
+	// unread threads not emailed yet
+	SendDueDigests(ctx context.Context, now time.Time) (int, error)
+	// SendScheduledDigests is the scheduler tick of SendDueDigests; failures
+	// and panics are logged rather than returned
+	SendScheduledDigests(ctx context.Context, now time.Time)
+}
+
+type notificationInboxService struct {
+	db          *gorm.DB
+	permissions PermissionService
+	push        NotificationService
+	mailer      DigestMailer
+	bundle      *i18n.Bundle
+	logger      *logrus.Logger
+	baseURL     string
+	now         func() time.Time
+}
+
+// NewNotificationInboxService creates a new NotificationInboxService. push
+// and mailer may be nil to turn off live delivery or emails.
+func NewNotificationInboxService(db *gorm.DB, permissions PermissionService, push NotificationService, mailer DigestMailer, bundle *i18n.Bundle, logger *logrus.Logger, baseURL string) NotificationInboxService {
+	return &notificationInboxService{
+		db:          db,
+		permissions: permissions,
+		push:        push,
+		mailer:      mailer,
+		bundle:      bundle,
+		logger:      logger,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		now:         time.Now,
+	}
+}
+
+func (s *notificationInboxService) List(ctx context.Context, userID uuid.UUID, filter NotificationFilter) ([]*models.Notification, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Notification{}).Where("user_id = ?", userID)
+	if !filter.All {
+		query = query.Where("read_at IS NULL")
+	}
+	if filter.Participating {
+		query = query.Where("reason <> ?", models.NotificationReasonSubscribed)
+	}
+	if filter.RepositoryID != nil {
+		query = query.Where("repository_id = ?", *filter.RepositoryID)
+	}
+	if filter.Since != nil {
+		query = query.Where("updated_at >= ?", *filter.Since)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+	page, perPage := filter.Page, filter.PerPage
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 50
+	}
+	var notifications []*models.Notification
+	if err := query.Preload("Actor").Order("updated_at DESC").
+		Offset((page - 1) * perPage).Limit(perPage).Find(&notifications).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list notifications: %w", err)
+	}
+	return notifications, total, nil
+}
+
+func (s *notificationInboxService) Get(ctx context.Context, userID, id uuid.UUID) (*models.Notification, error) {
+	var notification models.Notification
+	err := s.db.WithContext(ctx).Preload("Actor").Where("id = ? AND user_id = ?", id, userID).First(&notification).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotificationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notification: %w", err)
+	}
+	return &notification, nil
+}
+
+func (s *notificationInboxService) UnreadCount(ctx context.Context, userID uuid.UUID) (int64, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count notifications: %w", err)
+	}
+	return count, nil
+}
+
+func (s *notificationInboxService) MarkRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID, lastReadAt time.Time) (int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	} else {
+		query = query.Where("updated_at <= ?", lastReadAt)
+	}
+	// Reading a thread is not activity on it, so updated_at is kept
+	result := query.UpdateColumn("read_at", s.now())
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to mark notifications read: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func (s *notificationInboxService) Delete(ctx context.Context, userID, id uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.Notification{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete notification: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
+// notificationSubject is what a batch of notifications is about
+type notificationSubject struct {
+	repo    *models.Repository
+	kind    models.NotificationSubjectType
+	id      uuid.UUID
+	number  int
+	title   string
+	path    string
+	actorID uuid.UUID
+	// watchEvent is the watch event that notifies watchers, if any
+	watchEvent string
+}
+
+// notificationRecipients collects users in priority order; the first
+// reason given for a user is kept. An empty reason only updates a thread
+// the user already has.
+type notificationRecipients struct {
+	order   []uuid.UUID
+	reasons map[uuid.UUID]models.NotificationReason
+}
+
+func (r *notificationRecipients) add(userID uuid.UUID, reason models.NotificationReason) {
+	if r.reasons == nil {
+		r.reasons = map[uuid.UUID]models.NotificationReason{}
+	}
+	if _, ok := r.reasons[userID]; ok {
+		return
+	}
+	r.order = append(r.order, userID)
+	r.reasons[userID] = reason
+}
+
+func (s *notificationInboxService) HandleIssue(ctx context.Context, event IssueEvent) {
+	issue := event.Issue
+	subject, err := s.subject(ctx, issue.RepositoryID, models.NotificationSubjectIssue, issue.ID, issue.Number, issue.Title, "issues", event.ActorID)
+	if err != nil {
+		s.logger.WithError(err).WithField("issue_id", issue.ID).Warn("Failed to notify about issue")
+		return
+	}
+
+	var recipients notificationRecipients
+	onlyNew := false
+	switch event.Action {
+	case IssueActionOpened, IssueActionEdited:
+		for _, assignee := range issue.Assignees {
+			recipients.add(assignee.ID, models.NotificationReasonAssign)
+		}
+		s.addMentions(ctx, &recipients, issue.Title+"\n"+issue.Body)
+		if event.Action == IssueActionOpened {
+			subject.watchEvent = models.WatchEventIssues
+		} else {
+			// Edits only notify the users they newly involve
+			onlyNew = true
+		}
+	case IssueActionCommented:
+		s.addMentions(ctx, &recipients, event.Comment.Body)
+		s.addThreadHolders(ctx, &recipients, subject)
+	default:
+		s.addThreadHolders(ctx, &recipients, subject)
+	}
+	s.deliver(ctx, subject, &recipients, onlyNew)
+}
+
+func (s *notificationInboxService) HandlePullRequest(ctx context.Context, event PullRequestEvent) {
+	if event.Action != PullRequestActionOpened {
+		return
+	}
+	pr := event.PullRequest
+	subject, err := s.subject(ctx, pr.RepositoryID, models.NotificationSubjectPullRequest, pr.ID, pr.Number, pr.Title, "pull", event.ActorID)
+	if err != nil {
+		s.logger.WithError(err).WithField("pull_request_id", pr.ID).Warn("Failed to notify about pull request")
+		return
+	}
+	subject.watchEvent = models.WatchEventPullRequests
+
+	var recipients notificationRecipients
+	for _, reviewer := range pr.RequestedReviewers {
+		recipients.add(reviewer.ID, models.NotificationReasonReviewRequested)
+	}
+	for _, assignee := range pr.Assignees {
+		recipients.add(assignee.ID, models.NotificationReasonAssign)
+	}
+	s.addMentions(ctx, &recipients, pr.Title+"\n"+pr.Body)
+	s.deliver(ctx, subject, &recipients, false)
+}
+
+func (s *notificationInboxService) HandleWorkflowRun(ctx context.Context, run *models.WorkflowRun) {
+	if run.Status != models.WorkflowStatusCompleted || run.Conclusion != models.WorkflowConclusionFailure {
+		return
+	}
+	title := fmt.Sprintf("%s failed on %s", run.Name, run.HeadBranch)
+	// Nobody is the actor of a failure, so whoever started the run hears about it
+	subject, err := s.subject(ctx, run.RepositoryID, models.NotificationSubjectWorkflowRun, run.ID, 0, title, "actions/runs/"+run.ID.String(), uuid.Nil)
+	if err != nil {
+		s.logger.WithError(err).WithField("run_id", run.ID).Warn("Failed to notify about workflow run")
+		return
+	}
+	subject.watchEvent = models.WatchEventCI
+
+	var recipients notificationRecipients
+	if run.ActorID != nil {
+		recipients.add(*run.ActorID, models.NotificationReasonCIActivity)
+	}
+	if run.PullRequestID != nil {
+		var pr models.PullRequest
+		if err := s.db.WithContext(ctx).Select("id, user_id").First(&pr, "id = ?", *run.PullRequestID).Error; err == nil && pr.UserID != nil {
+			recipients.add(*pr.UserID, models.NotificationReasonCIActivity)
+		}
+	}
+	s.deliver(ctx, subject, &recipients, false)
+}
+
+// subject loads the repository of a subject; pathPrefix is the web path
+// below the repository, followed by the number when there is one
+func (s *notificationInboxService) subject(ctx context.Context, repoID uuid.UUID, kind models.NotificationSubjectType, id uuid.UUID, number int, title, pathPrefix string, actorID uuid.UUID) (*notificationSubject, error) {
+	var repo models.Repository
+	if err := s.db.WithContext(ctx).First(&repo, "id = ?", repoID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load repository: %w", err)
+	}
+	path := pathPrefix
+	if number > 0 {
+		path = fmt.Sprintf("%s/%d", pathPrefix, number)
+	}
+	return &notificationSubject{repo: &repo, kind: kind, id: id, number: number, title: truncate(title, 255), path: path, actorID: actorID}, nil
+}
+
+// addMentions adds the existing users @mentioned in text
+func (s *notificationInboxService) addMentions(ctx context.Context, recipients *notificationRecipients, text string) {
+	var usernames []string
+	for _, match := range mentionPattern.FindAllStringSubmatch(text, -1) {
+		username := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if username != "" && len(usernames) < notificationMaxMentions {
+			usernames = append(usernames, username)
+		}
+	}
+	if len(usernames) == 0 {
+		return
+	}
+	var users []models.User
+	if err := s.db.WithContext(ctx).Select("id").Where("LOWER(username) IN ?", usernames).Find(&users).Error; err != nil {
+		s.logger.WithError(err).Warn("Failed to resolve mentioned users")
+		return
+	}
+	for _, user := range users {
+		recipients.add(user.ID, models.NotificationReasonMention)
+	}
+}
+
+// addThreadHolders adds the users who already have a thread on the subject,
+// keeping their reason
+func (s *notificationInboxService) addThreadHolders(ctx context.Context, recipients *notificationRecipients, subject *notificationSubject) {
+	var userIDs []uuid.UUID
+	if err := s.db.WithContext(ctx).Model(&models.Notification{}).
+		Where("subject_type = ? AND subject_id = ?", subject.kind, subject.id).Pluck("user_id", &userIDs).Error; err != nil {
+		s.logger.WithError(err).Warn("Failed to load notification threads")
+		return
+	}
+	for _, userID := range userIDs {
+		recipients.add(userID, "")
+	}
+}
+
+// deliver creates or refreshes the recipients' threads and those of the
+// repository's watchers. Users ignoring the repository, the actor and users
+// who cannot read the repository are skipped.
+func (s *notificationInboxService) deliver(ctx context.Context, subject *notificationSubject, recipients *notificationRecipients, onlyNew bool) {
+	db := s.db.WithContext(ctx)
+	var watches []models.RepositoryWatch
+	if err := db.Where("repository_id = ?", subject.repo.ID).Find(&watches).Error; err != nil {
+		s.logger.WithError(err).Warn("Failed to load repository watches")
+		return
+	}
+	ignored := map[uuid.UUID]bool{}
+	for _, watch := range watches {
+		if watch.Ignored {
+			ignored[watch.UserID] = true
+		} else if subject.watchEvent != "" && watch.Watches(subject.watchEvent) {
+			recipients.add(watch.UserID, models.NotificationReasonSubscribed)
+		}
+	}
+
+	fullName := s.repositoryFullName(ctx, subject.repo)
+	now := s.now()
+	for _, userID := range recipients.order {
+		if userID == subject.actorID || ignored[userID] || !s.canRead(ctx, userID, subject.repo) {
+			continue
+		}
+		reason := recipients.reasons[userID]
+
+		var thread models.Notification
+		err := db.Where("user_id = ? AND subject_type = ? AND subject_id = ?", userID, subject.kind, subject.id).First(&thread).Error
+		switch {
+		case err == nil:
+			if onlyNew {
+				continue
+			}
+			if reason != "" {
+				thread.Reason = reason
+			}
+			thread.Title, thread.ReadAt, thread.EmailedAt, thread.UpdatedAt = subject.title, nil, nil, now
+			thread.ActorID = nil
+			if subject.actorID != uuid.Nil {
+				thread.ActorID = &subject.actorID
+			}
+			err = db.Save(&thread).Error
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if reason == "" {
+				continue
+			}
+			thread = models.Notification{
+				ID: uuid.New(), CreatedAt: now, UpdatedAt: now, UserID: userID, RepositoryID: subject.repo.ID, Repository: fullName,
+				SubjectType: subject.kind, SubjectID: subject.id, Number: subject.number, Title: subject.title,
+				URL: fmt.Sprintf("%s/%s/%s", s.baseURL, fullName, subject.path), Reason: reason,
+			}
+			if subject.actorID != uuid.Nil {
+				thread.ActorID = &subject.actorID
+			}
+			err = db.Create(&thread).Error
+		}
+		if err != nil {
+			s.logger.WithError(err).WithField("user_id", userID).Warn("Failed to store notification")
+			continue
+		}
+		if s.push != nil {
+			s.push.Publish(userID, Notification{ID: thread.ID, Type: "notification", Payload: &thread, Timestamp: now})
+		}
+	}
+}
+
+func (s *notificationInboxService) canRead(ctx context.Context, userID uuid.UUID, repo *models.Repository) bool {
+	if repo.Visibility == models.VisibilityPublic || s.permissions == nil {
+		return true
+	}
+	ok, err := s.permissions.CheckRepositoryPermission(ctx, userID, repo.ID, models.PermissionRead)
+	return err == nil && ok
+}
+
+func (s *notificationInboxService) repositoryFullName(ctx context.Context, repo *models.Repository) string {
+	var owner string
+	if repo.OwnerType == models.OwnerTypeOrganization {
+		s.db.WithContext(ctx).Model(&models.Organization{}).Where("id = ?", repo.OwnerID).Pluck("name", &owner)
+	} else {
+		s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", repo.OwnerID).Pluck("username", &owner)
+	}
+	if owner == "" {
+		return repo.Name
+	}
+	return owner + "/" + repo.Name
+}
+
+func (s *notificationInboxService) SendDueDigests(ctx context.Context, now time.Time) (int, error) {
+	if s.mailer == nil {
+		return 0, nil
+	}
+	db := s.db.WithContext(ctx)
+	var userIDs []uuid.UUID
+	if err := db.Model(&models.Notification{}).Where("read_at IS NULL AND emailed_at IS NULL").
+		Distinct("user_id").Pluck("user_id", &userIDs).Error; err != nil {
+		return 0, fmt.Errorf("failed to list notified users: %w", err)
+	}
+
+	sent := 0
+	for _, userID := range userIDs {
+		var user models.User
+		if err := db.First(&user, "id = ?", userID).Error; err != nil || !user.IsActive || user.Email == "" {
+			continue
+		}
+		prefs := DefaultUserPreferences(userID)
+		if err := db.Where("user_id = ?", userID).First(prefs).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return sent, fmt.Errorf("failed to load user preferences: %w", err)
+		}
+		var lastEmailed struct{ At *time.Time }
+		if err := db.Model(&models.Notification{}).Select("MAX(emailed_at) AS at").Where("user_id = ?", userID).Scan(&lastEmailed).Error; err != nil {
+			return sent, fmt.Errorf("failed to load last notification email: %w", err)
+		}
+		if !isDigestDue(prefs.NotificationEmailFrequency, lastEmailed.At, now) {
+			continue
+		}
+
+		var threads []*models.Notification
+		if err := db.Where("user_id = ? AND read_at IS NULL AND emailed_at IS NULL", userID).
+			Order("updated_at DESC").Limit(notificationDigestLimit).Find(&threads).Error; err != nil {
+			return sent, fmt.Errorf("failed to load notifications: %w", err)
+		}
+		localizer := s.bundle.Localizer(user.Locale)
+		body, err := renderNotificationDigestHTML(threads, localizer)
+		if err != nil {
+			return sent, err
+		}
+		if err := s.mailer.SendDigestEmail(user.Email, localizer.N("notification.digest.subject", len(threads)), body); err != nil {
+			s.logger.WithError(err).WithField("user_id", userID).Warn("Failed to send notification digest")
+			continue
+		}
+		sent++
+
+		ids := make([]uuid.UUID, len(threads))
+		for i, thread := range threads {
+			ids[i] = thread.ID
+		}
+		// Updating emailed_at must not count as activity on the thread
+		if err := db.Model(&models.Notification{}).Where("id IN ?", ids).UpdateColumn("emailed_at", now).Error; err != nil {
+			return sent, fmt.Errorf("failed to record notification digest: %w", err)
+		}
+	}
+	return sent, nil
+}
+
+func (s *notificationInboxService) SendScheduledDigests(ctx context.Context, now time.Time) {
+	defer errorreporting.Default().Recover("notification_digest_scheduler", nil)
+	sent, err := s.SendDueDigests(ctx, now)
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to send notification digests")
+		return
+	}
+	if sent > 0 {
+		s.logger.WithField("sent", sent).Info("Notification digests sent")
+	}
+}
+
+var notificationDigestTemplate = template.Must(template.New("notifications").Funcs(template.FuncMap{
+	"t": func(string, ...interface{}) string { return "" },
+}).Parse(`<html>
+<body>
+	<h2>{{t "notification.digest.heading"}}</h2>
+	<ul>{{range .}}<li><a href="{{.URL}}">{{.Repository}}{{if .Number}} #{{.Number}}{{end}}: {{.Title}}</a> ({{t (printf "notification.reason.%s" .Reason)}})</li>{{end}}</ul>
+	<p style="font-size: 12px; color: #666;">{{t "notification.digest.footer"}}</p>
+</body>
+</html>`))
+
+// renderNotificationDigestHTML renders the threads of one email
+func renderNotificationDigestHTML(threads []*models.Notification, localizer *i18n.Localizer) (string, error) {
+	tmpl, err := notificationDigestTemplate.Clone()
+	if err != nil {
+		return "", fmt.Errorf("failed to render notification digest: %w", err)
+	}
+	tmpl.Funcs(template.FuncMap{"t": localizer.T})
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, threads); err != nil {
+		return "", fmt.Errorf("failed to render notification digest: %w", err)
+	}
+	return buf.String(), nil
+}
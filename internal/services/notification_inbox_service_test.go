@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/i18n"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingDigestMailer struct {
+	sent map[string]string
+}
+
+func (m *recordingDigestMailer) SendDigestEmail(to, subject, htmlBody string) error {
+	m.sent[to] = subject
+	return nil
+}
+
+func TestNotificationInboxService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.Repository{}, &models.RepositoryWatch{},
+		&models.Notification{}, &models.UserPreferences{}, &models.PullRequest{})
+
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	mailer := &recordingDigestMailer{sent: map[string]string{}}
+	svc := NewNotificationInboxService(db, nil, nil, mailer, i18n.MustNewBundle(), logrus.New(), "http://hub.local/").(*notificationInboxService)
+	svc.now = func() time.Time { return now }
+
+	user := func(name string) *models.User {
+		u := &models.User{ID: uuid.New(), Username: name, Email: name + "@example.com", IsActive: true}
+		require.NoError(t, db.Create(u).Error)
+		return u
+	}
+	alice, bob, carol, dave, erin, frank := user("alice"), user("bob"), user("carol"), user("dave"), user("erin"), user("frank")
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	repo := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPublic}
+	require.NoError(t, db.Create(repo).Error)
+	require.NoError(t, db.Create([]*models.RepositoryWatch{
+		{ID: uuid.New(), UserID: dave.ID, RepositoryID: repo.ID},
+		{ID: uuid.New(), UserID: erin.ID, RepositoryID: repo.ID, Reason: "custom", Events: models.WatchEventCI},
+		{ID: uuid.New(), UserID: frank.ID, RepositoryID: repo.ID, Ignored: true},
+	}).Error)
+
+	reasons := func(subjectID uuid.UUID) map[string]models.NotificationReason {
+		var threads []models.Notification
+		require.NoError(t, db.Where("subject_id = ?", subjectID).Find(&threads).Error)
+		result := map[string]models.NotificationReason{}
+		for _, thread := range threads {
+			for _, u := range []*models.User{alice, bob, carol, dave, erin, frank} {
+				if u.ID == thread.UserID {
+					result[u.Username] = thread.Reason
+				}
+			}
+		}
+		return result
+	}
+
+	issue := &models.Issue{ID: uuid.New(), RepositoryID: repo.ID, Number: 3, Title: "Crash on login",
+		Body: "cc @carol @frank, reported by alice@example.com", Assignees: []models.User{*bob}}
+	svc.HandleIssue(ctx, IssueEvent{Action: IssueActionOpened, Issue: issue, ActorID: alice.ID})
+	assert.Equal(t, map[string]models.NotificationReason{
+		"bob":   models.NotificationReasonAssign,
+		"carol": models.NotificationReasonMention,
+		"dave":  models.NotificationReasonSubscribed,
+	}, reasons(issue.ID), "the actor, ignoring users and email addresses are skipped")
+
+	threads, total, err := svc.List(ctx, carol.ID, NotificationFilter{})
+	require.NoError(t, err)
+	require.Len(t, threads, 1)
+	assert.Equal(t, int64(1), total)
+	assert.Equal(t, "acme/app", threads[0].Repository)
+	assert.Equal(t, "http://hub.local/acme/app/issues/3", threads[0].URL)
+	marked, err := svc.MarkRead(ctx, carol.ID, nil, now)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), marked)
+
+	// A comment reaches the users it mentions and everyone with a thread again
+	comment := &models.Comment{ID: uuid.New(), Body: "@erin can you take a look?"}
+	svc.HandleIssue(ctx, IssueEvent{Action: IssueActionCommented, Issue: issue, Comment: comment, ActorID: bob.ID})
+	assert.Equal(t, models.NotificationReasonMention, reasons(issue.ID)["erin"])
+	count, err := svc.UnreadCount(ctx, carol.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	// Failed runs notify whoever started them and watchers of CI
+	run := &models.WorkflowRun{ID: uuid.New(), RepositoryID: repo.ID, Name: "CI", HeadBranch: "main", ActorID: &alice.ID,
+		Status: models.WorkflowStatusCompleted, Conclusion: models.WorkflowConclusionFailure}
+	svc.HandleWorkflowRun(ctx, run)
+	assert.Equal(t, map[string]models.NotificationReason{
+		"alice": models.NotificationReasonCIActivity,
+		"erin":  models.NotificationReasonSubscribed,
+	}, reasons(run.ID))
+
+	threads, _, err = svc.List(ctx, erin.ID, NotificationFilter{Participating: true})
+	require.NoError(t, err)
+	require.Len(t, threads, 1)
+	assert.Equal(t, issue.ID, threads[0].SubjectID)
+
+	require.NoError(t, db.Create(&models.UserPreferences{ID: uuid.New(), UserID: dave.ID, NotificationEmailFrequency: models.DigestFrequencyOff}).Error)
+	sent, err := svc.SendDueDigests(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 4, sent)
+	assert.Equal(t, "You have 2 unread notifications", mailer.sent["erin@example.com"])
+	assert.NotContains(t, mailer.sent, "dave@example.com")
+
+	sent, err = svc.SendDueDigests(ctx, now.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	assert.Zero(t, sent, "threads are only emailed once")
+
+	var thread models.Notification
+	require.NoError(t, db.Where("user_id = ? AND subject_id = ?", alice.ID, run.ID).First(&thread).Error)
+	require.NoError(t, svc.Delete(ctx, alice.ID, thread.ID))
+	assert.ErrorIs(t, svc.Delete(ctx, bob.ID, thread.ID), ErrNotificationNotFound)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Errors of the dynamic client registration endpoint, spelled as the RFC
+// 7591 error codes they are reported as
+var (
+	ErrOAuthInvalidRedirectURI    = errors.New("invalid_redirect_uri")
+	ErrOAuthInvalidClientMetadata = errors.New("invalid_client_metadata")
+)
+
+// OAuthClientMetadata is an RFC 7591 client registration request. Clients
+// using token_endpoint_auth_method none are registered as public clients.
+type OAuthClientMetadata struct {
+	RedirectURIs            []string `json:"redirect_uris"`
+	TokenEndpointAuthMethod string   `json:"token_endpoint_auth_method,omitempty"`
+	GrantTypes              []string `json:"grant_types,omitempty"`
+	ResponseTypes           []string `json:"response_types,omitempty"`
+	ClientName              string   `json:"client_name,omitempty"`
+	ClientURI               string   `json:"client_uri,omitempty"`
+	Scope                   string   `json:"scope,omitempty"`
+}
+
+// OAuthClientRegistration is an RFC 7591 client information response. The
+// client secret is only ever returned here.
+type OAuthClientRegistration struct {
+	OAuthClientMetadata
+	ClientID              string `json:"client_id"`
+	ClientSecret          string `json:"client_secret,omitempty"`
+	ClientIDIssuedAt      int64  `json:"client_id_issued_at"`
+	ClientSecretExpiresAt *int64 `json:"client_secret_expires_at,omitempty"`
+}
+
+// unsupportedOAuthValue returns the first of values not in supported
+func unsupportedOAuthValue(values []string, supported ...string) string {
+	for _, value := range values {
+		found := false
+		for _, s := range supported {
+			found = found || value == s
+		}
+		if !found {
+			return value
+		}
+	}
+	return ""
+}
+
+// RegisterClient registers an application for ownerID from RFC 7591
+// client metadata, so tools can register themselves with the token of the
+// user they act for
+func (s *oauthProviderService) RegisterClient(ctx context.Context, ownerID uuid.UUID, isAdmin bool, metadata OAuthClientMetadata) (*OAuthClientRegistration, error) {
+	if len(metadata.RedirectURIs) == 0 {
+		return nil, fmt.Errorf("%w: at least one redirect URI is required", ErrOAuthInvalidRedirectURI)
+	}
+	for _, uri := range metadata.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrOAuthInvalidRedirectURI, strings.TrimPrefix(err.Error(), ErrInvalidOAuthApplication.Error()+": "))
+		}
+	}
+
+	if metadata.TokenEndpointAuthMethod == "" {
+		metadata.TokenEndpointAuthMethod = "client_secret_basic"
+	}
+	if len(metadata.GrantTypes) == 0 {
+		metadata.GrantTypes = []string{"authorization_code"}
+	}
+	if len(metadata.ResponseTypes) == 0 {
+		metadata.ResponseTypes = []string{"code"}
+	}
+	if method := unsupportedOAuthValue([]string{metadata.TokenEndpointAuthMethod}, "client_secret_basic", "client_secret_post", "none"); method != "" {
+		return nil, fmt.Errorf("%w: unsupported token_endpoint_auth_method %q", ErrOAuthInvalidClientMetadata, method)
+	}
+	if grant := unsupportedOAuthValue(metadata.GrantTypes, "authorization_code", "refresh_token"); grant != "" {
+		return nil, fmt.Errorf("%w: unsupported grant type %q", ErrOAuthInvalidClientMetadata, grant)
+	}
+	if responseType := unsupportedOAuthValue(metadata.ResponseTypes, "code"); responseType != "" {
+		return nil, fmt.Errorf("%w: unsupported response type %q", ErrOAuthInvalidClientMetadata, responseType)
+	}
+	name := metadata.ClientName
+	if strings.TrimSpace(name) == "" {
+		name = metadata.RedirectURIs[0]
+	}
+
+	app, secret, err := s.CreateApplication(ctx, ownerID, isAdmin, OAuthApplicationRequest{
+		Name:         name,
+		HomepageURL:  metadata.ClientURI,
+		RedirectURIs: metadata.RedirectURIs,
+		Scopes:       strings.Fields(metadata.Scope),
+		Public:       metadata.TokenEndpointAuthMethod == "none",
+	})
+	if errors.Is(err, ErrInvalidOAuthApplication) {
+		return nil, fmt.Errorf("%w: %s", ErrOAuthInvalidClientMetadata, strings.TrimPrefix(err.Error(), ErrInvalidOAuthApplication.Error()+": "))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metadata.ClientName = app.Name
+	metadata.Scope = strings.Join(app.Scopes, " ")
+	registration := &OAuthClientRegistration{
+		OAuthClientMetadata: metadata,
+		ClientID:            app.ClientID,
+		ClientSecret:        secret,
+		ClientIDIssuedAt:    app.CreatedAt.Unix(),
+	}
+	if app.Confidential {
+		// Secrets do not expire; they are reset from the application settings
+		never := int64(0)
+		registration.ClientSecretExpiresAt = &never
+	}
+	return registration, nil
+}
@@ -0,0 +1,1110 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// oauthCodeLifetime bounds how long an authorization code can be exchanged
+const oauthCodeLifetime = 10 * time.Minute
+
+var (
+	ErrOAuthApplicationNotFound   = errors.New("oauth application not found")
+	ErrInvalidOAuthApplication    = errors.New("invalid oauth application")
+	ErrOAuthRegistrationForbidden = errors.New("only site admins can register oauth applications")
+	ErrOAuthTokenUnauthorized     = errors.New("oauth token is invalid, expired or revoked")
+
+	// Errors of the authorization, token, introspection and revocation
+	// endpoints, spelled as the RFC 6749 error codes they are reported as
+	ErrOAuthInvalidRequest       = errors.New("invalid_request")
+	ErrOAuthInvalidClient        = errors.New("invalid_client")
+	ErrOAuthInvalidGrant         = errors.New("invalid_grant")
+	ErrOAuthInvalidScope         = errors.New("invalid_scope")
+	ErrOAuthUnsupportedGrantType = errors.New("unsupported_grant_type")
+)
+
+// oauthScopes lists the scopes an application can be registered for
+var oauthScopes = map[string]bool{
+	models.OAuthScopeOpenID:   true,
+	models.OAuthScopeProfile:  true,
+	models.OAuthScopeEmail:    true,
+	models.OAuthScopeReadUser: true,
+	models.OAuthScopeUser:     true,
+	models.OAuthScopeReadRepo: true,
+	models.OAuthScopeRepo:     true,
+}
+
+// defaultOAuthApplicationScopes are granted to applications registered
+// without a scope list: sign-in only
+var defaultOAuthApplicationScopes = []string{
+	models.OAuthScopeOpenID, models.OAuthScopeProfile, models.OAuthScopeEmail, models.OAuthScopeReadUser,
+}
+
+// OAuthApplicationRequest registers or updates an OAuth application. Public
+// is only read on registration.
+type OAuthApplicationRequest struct {
+	Name         string   `json:"name" binding:"required"`
+	Description  string   `json:"description"`
+	HomepageURL  string   `json:"homepage_url"`
+	RedirectURIs []string `json:"redirect_uris" binding:"required"`
+	Scopes       []string `json:"scopes"`
+	Public       bool     `json:"public"`
+}
+
+// AdminOAuthApplicationRequest changes how the site treats an application
+type AdminOAuthApplicationRequest struct {
+	Trusted   *bool  `json:"trusted"`
+	Suspended *bool  `json:"suspended"`
+	Reason    string `json:"reason"`
+}
+
+// OAuthAuthorizeRequest carries the parameters of an authorization request
+type OAuthAuthorizeRequest struct {
+	ResponseType        string `form:"response_type" json:"response_type"`
+	ClientID            string `form:"client_id" json:"client_id"`
+	RedirectURI         string `form:"redirect_uri" json:"redirect_uri"`
+	Scope               string `form:"scope" json:"scope"`
+	State               string `form:"state" json:"state"`
+	CodeChallenge       string `form:"code_challenge" json:"code_challenge"`
+	CodeChallengeMethod string `form:"code_challenge_method" json:"code_challenge_method"`
+	Nonce               string `form:"nonce" json:"nonce"`
+}
+
+// OAuthConsent describes an authorization request to the user deciding on it
+type OAuthConsent struct {
+	Application *models.OAuthApplication `json:"application"`
+	Scopes      []string                 `json:"scopes"`
+	RedirectURI string                   `json:"redirect_uri"`
+	// ConsentRequired is false when the application is trusted or the user
+	// already granted every requested scope
+	ConsentRequired bool `json:"consent_required"`
+}
+
+// OAuthTokenRequest carries the parameters of a token endpoint request
+type OAuthTokenRequest struct {
+	GrantType    string `form:"grant_type"`
+	Code         string `form:"code"`
+	RedirectURI  string `form:"redirect_uri"`
+	CodeVerifier string `form:"code_verifier"`
+	RefreshToken string `form:"refresh_token"`
+	Scope        string `form:"scope"`
+	ClientID     string `form:"client_id"`
+	ClientSecret string `form:"client_secret"`
+}
+
+// OAuthTokenResponse is a successful token endpoint response
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+// OAuthIntrospection is an RFC 7662 introspection response. Only Active is
+// set for tokens the calling client did not receive.
+type OAuthIntrospection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+}
+
+// OAuthUserInfo holds the OpenID Connect claims released for a token's scopes
+type OAuthUserInfo struct {
+	Subject           string `json:"sub"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+	Profile           string `json:"profile,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+}
+
+// oauthIDTokenClaims are the claims of an ID token
+type oauthIDTokenClaims struct {
+	Nonce             string `json:"nonce,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	Name              string `json:"name,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+	Profile           string `json:"profile,omitempty"`
+	Email             string `json:"email,omitempty"`
+	EmailVerified     *bool  `json:"email_verified,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// OAuthProviderService lets the hub act as an OAuth 2.0 and OpenID Connect
+// provider: users register applications, consent to them acting on their
+// behalf and the applications exchange authorization codes for access
+// tokens accepted by the hub API
+type OAuthProviderService interface {
+	// CreateApplication registers an application and returns its client
+	// secret, which is not stored. Public applications have no secret.
+	CreateApplication(ctx context.Context, ownerID uuid.UUID, isAdmin bool, req OAuthApplicationRequest) (*models.OAuthApplication, string, error)
+	// RegisterClient registers an application from RFC 7591 dynamic client
+	// registration metadata
+	RegisterClient(ctx context.Context, ownerID uuid.UUID, isAdmin bool, metadata OAuthClientMetadata) (*OAuthClientRegistration, error)
+	ListApplications(ctx context.Context, ownerID uuid.UUID) ([]*models.OAuthApplication, error)
+	GetApplication(ctx context.Context, ownerID, appID uuid.UUID) (*models.OAuthApplication, error)
+	UpdateApplication(ctx context.Context, ownerID, appID uuid.UUID, req OAuthApplicationRequest) (*models.OAuthApplication, error)
+	// ResetClientSecret replaces the secret of a confidential application
+	ResetClientSecret(ctx context.Context, ownerID, appID uuid.UUID) (*models.OAuthApplication, string, error)
+	// DeleteApplication removes an application and every grant and token
+	// issued to it
+	DeleteApplication(ctx context.Context, ownerID, appID uuid.UUID) error
+
+	// AdminListApplications returns every registered application
+	AdminListApplications(ctx context.Context) ([]*models.OAuthApplication, error)
+	// AdminUpdateApplication trusts or suspends an application. Suspending
+	// revokes its tokens.
+	AdminUpdateApplication(ctx context.Context, appID uuid.UUID, req AdminOAuthApplicationRequest) (*models.OAuthApplication, error)
+
+	// PrepareAuthorization validates an authorization request and describes
+	// it for the consent screen
+	PrepareAuthorization(ctx context.Context, userID uuid.UUID, req OAuthAuthorizeRequest) (*OAuthConsent, error)
+	// Authorize records the user's decision and returns the URL to send the
+	// user back to, carrying either an authorization code or an error
+	Authorize(ctx context.Context, userID uuid.UUID, req OAuthAuthorizeRequest, approve bool) (string, error)
+	// Token serves the authorization_code and refresh_token grants
+	Token(ctx context.Context, req OAuthTokenRequest) (*OAuthTokenResponse, error)
+
+	// Authenticate resolves a presented access token and records its use
+	Authenticate(ctx context.Context, token string) (*models.OAuthAccessToken, *models.User, error)
+	// Introspect describes an access or refresh token to the client it was
+	// issued to
+	Introspect(ctx context.Context, clientID, clientSecret, token string) (*OAuthIntrospection, error)
+	// Revoke revokes an access or refresh token of the calling client.
+	// Unknown tokens are not an error.
+	Revoke(ctx context.Context, clientID, clientSecret, token string) error
+	UserInfo(token *models.OAuthAccessToken, user *models.User) *OAuthUserInfo
+
+	// ListAuthorizations returns the applications the user granted access
+	ListAuthorizations(ctx context.Context, userID uuid.UUID) ([]*models.OAuthAuthorization, error)
+	// RevokeAuthorization withdraws the user's grant and revokes the
+	// application's tokens for the user
+	RevokeAuthorization(ctx context.Context, userID, appID uuid.UUID) error
+
+	// Discovery returns the OpenID Connect provider metadata
+	Discovery() map[string]interface{}
+	// JWKS returns the key set ID tokens are verified with
+	JWKS() map[string]interface{}
+}
+
+type oauthProviderService struct {
+	db         *gorm.DB
+	cfg        config.OAuthProvider
+	issuer     string
+	signingKey *rsa.PrivateKey
+	keyID      string
+	logger     *logrus.Logger
+	now        func() time.Time
+}
+
+// NewOAuthProviderService creates a new OAuthProviderService issuing tokens
+// as issuer, the public base URL of the hub
+func NewOAuthProviderService(db *gorm.DB, cfg config.OAuthProvider, issuer string, logger *logrus.Logger) (OAuthProviderService, error) {
+	key, err := loadOAuthSigningKey(cfg.SigningKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SigningKeyPath == "" {
+		logger.Warn("No OAuth provider signing key configured; ID tokens are signed with a temporary key")
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode oauth signing key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+
+	return &oauthProviderService{
+		db:         db,
+		cfg:        cfg,
+		issuer:     strings.TrimSuffix(issuer, "/"),
+		signingKey: key,
+		keyID:      base64.RawURLEncoding.EncodeToString(sum[:12]),
+		logger:     logger,
+		now:        time.Now,
+	}, nil
+}
+
+// loadOAuthSigningKey reads a PEM RSA private key, or generates one when no
+// path is configured
+func loadOAuthSigningKey(path string) (*rsa.PrivateKey, error) {
+	if path == "" {
+		return rsa.GenerateKey(rand.Reader, 2048)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read oauth signing key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("oauth signing key %s is not PEM encoded", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse oauth signing key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("oauth signing key %s is not an RSA key", path)
+	}
+	return key, nil
+}
+
+func hashOAuthSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *oauthProviderService) accessTokenLifetime() time.Duration {
+	if s.cfg.AccessTokenMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(s.cfg.AccessTokenMinutes) * time.Minute
+}
+
+func (s *oauthProviderService) refreshTokenDays() int {
+	if s.cfg.RefreshTokenDays <= 0 {
+		return 30
+	}
+	return s.cfg.RefreshTokenDays
+}
+
+// validateRedirectURI accepts https URLs, http URLs of loopback hosts for
+// local development and private-use schemes of native applications
+func validateRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Fragment != "" {
+		return fmt.Errorf("%w: redirect URI %q must be an absolute URI without a fragment", ErrInvalidOAuthApplication, raw)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "https":
+		if u.Host == "" {
+			return fmt.Errorf("%w: redirect URI %q has no host", ErrInvalidOAuthApplication, raw)
+		}
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return fmt.Errorf("%w: redirect URI %q must use https", ErrInvalidOAuthApplication, raw)
+		}
+	case "javascript", "data", "file", "vbscript":
+		return fmt.Errorf("%w: redirect URI %q uses a forbidden scheme", ErrInvalidOAuthApplication, raw)
+	}
+	return nil
+}
+
+// applyApplicationRequest validates req onto app
+func applyApplicationRequest(app *models.OAuthApplication, req OAuthApplicationRequest) error {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidOAuthApplication)
+	}
+	if len(req.RedirectURIs) == 0 {
+		return fmt.Errorf("%w: at least one redirect URI is required", ErrInvalidOAuthApplication)
+	}
+	for _, uri := range req.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return err
+		}
+	}
+	if req.HomepageURL != "" {
+		if u, err := url.Parse(req.HomepageURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+			return fmt.Errorf("%w: homepage URL must be an http or https URL", ErrInvalidOAuthApplication)
+		}
+	}
+	scopes := req.Scopes
+	if len(scopes) == 0 {
+		scopes = defaultOAuthApplicationScopes
+	}
+	for _, scope := range scopes {
+		if !oauthScopes[scope] {
+			return fmt.Errorf("%w: unknown scope %q", ErrInvalidOAuthApplication, scope)
+		}
+	}
+
+	app.Name = name
+	app.Description = req.Description
+	app.HomepageURL = req.HomepageURL
+	app.RedirectURIs = req.RedirectURIs
+	app.Scopes = normalizeScopes(scopes)
+	return nil
+}
+
+// normalizeScopes sorts scopes and removes duplicates
+func normalizeScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if scope != "" && !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// coversScopes reports whether granted includes every scope of requested
+func coversScopes(granted, requested []string) bool {
+	set := make(map[string]bool, len(granted))
+	for _, scope := range granted {
+		set[scope] = true
+	}
+	for _, scope := range requested {
+		if !set[scope] {
+			return false
+		}
+	}
+	return true
+}
+
+// newClientSecret generates a client secret and stores its hash on app
+func newClientSecret(app *models.OAuthApplication) (string, error) {
+	secret, err := generateSecureToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate client secret: %w", err)
+	}
+	secret = models.OAuthClientSecretPrefix + secret
+	app.ClientSecretHash = hashOAuthSecret(secret)
+	app.ClientSecretLastEight = secret[len(secret)-8:]
+	return secret, nil
+}
+
+func (s *oauthProviderService) CreateApplication(ctx context.Context, ownerID uuid.UUID, isAdmin bool, req OAuthApplicationRequest) (*models.OAuthApplication, string, error) {
+	if s.cfg.AdminOnlyRegistration && !isAdmin {
+		return nil, "", ErrOAuthRegistrationForbidden
+	}
+
+	clientID, err := generateSecureToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate client ID: %w", err)
+	}
+	app := &models.OAuthApplication{
+		ID:           uuid.New(),
+		OwnerID:      ownerID,
+		ClientID:     clientID[:32],
+		Confidential: !req.Public,
+	}
+	if err := applyApplicationRequest(app, req); err != nil {
+		return nil, "", err
+	}
+
+	var secret string
+	if app.Confidential {
+		if secret, err = newClientSecret(app); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := s.db.WithContext(ctx).Create(app).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create oauth application: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"application_id": app.ID,
+		"owner_id":       ownerID,
+		"confidential":   app.Confidential,
+	}).Info("Registered OAuth application")
+	return app, secret, nil
+}
+
+func (s *oauthProviderService) ListApplications(ctx context.Context, ownerID uuid.UUID) ([]*models.OAuthApplication, error) {
+	var apps []*models.OAuthApplication
+	if err := s.db.WithContext(ctx).Where("owner_id = ?", ownerID).Order("created_at DESC").Find(&apps).Error; err != nil {
+		return nil, fmt.Errorf("failed to list oauth applications: %w", err)
+	}
+	return apps, nil
+}
+
+func (s *oauthProviderService) findApplication(ctx context.Context, query string, args ...interface{}) (*models.OAuthApplication, error) {
+	var app models.OAuthApplication
+	if err := s.db.WithContext(ctx).Where(query, args...).First(&app).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOAuthApplicationNotFound
+		}
+		return nil, fmt.Errorf("failed to get oauth application: %w", err)
+	}
+	return &app, nil
+}
+
+func (s *oauthProviderService) GetApplication(ctx context.Context, ownerID, appID uuid.UUID) (*models.OAuthApplication, error) {
+	return s.findApplication(ctx, "id = ? AND owner_id = ?", appID, ownerID)
+}
+
+func (s *oauthProviderService) UpdateApplication(ctx context.Context, ownerID, appID uuid.UUID, req OAuthApplicationRequest) (*models.OAuthApplication, error) {
+	app, err := s.GetApplication(ctx, ownerID, appID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyApplicationRequest(app, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Save(app).Error; err != nil {
+		return nil, fmt.Errorf("failed to update oauth application: %w", err)
+	}
+	return app, nil
+}
+
+func (s *oauthProviderService) ResetClientSecret(ctx context.Context, ownerID, appID uuid.UUID) (*models.OAuthApplication, string, error) {
+	app, err := s.GetApplication(ctx, ownerID, appID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !app.Confidential {
+		return nil, "", fmt.Errorf("%w: public applications have no client secret", ErrInvalidOAuthApplication)
+	}
+	secret, err := newClientSecret(app)
+	if err != nil {
+		return nil, "", err
+	}
+	err = s.db.WithContext(ctx).Model(app).Updates(map[string]interface{}{
+		"client_secret_hash":       app.ClientSecretHash,
+		"client_secret_last_eight": app.ClientSecretLastEight,
+	}).Error
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to reset client secret: %w", err)
+	}
+	return app, secret, nil
+}
+
+func (s *oauthProviderService) DeleteApplication(ctx context.Context, ownerID, appID uuid.UUID) error {
+	app, err := s.GetApplication(ctx, ownerID, appID)
+	if err != nil {
+		return err
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{&models.OAuthAccessToken{}, &models.OAuthAuthorizationCode{}, &models.OAuthAuthorization{}} {
+			if err := tx.Where("application_id = ?", app.ID).Delete(model).Error; err != nil {
+				return fmt.Errorf("failed to delete oauth grants: %w", err)
+			}
+		}
+		if err := tx.Delete(app).Error; err != nil {
+			return fmt.Errorf("failed to delete oauth application: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *oauthProviderService) AdminListApplications(ctx context.Context) ([]*models.OAuthApplication, error) {
+	var apps []*models.OAuthApplication
+	if err := s.db.WithContext(ctx).Order("created_at DESC").Find(&apps).Error; err != nil {
+		return nil, fmt.Errorf("failed to list oauth applications: %w", err)
+	}
+	return apps, nil
+}
+
+func (s *oauthProviderService) AdminUpdateApplication(ctx context.Context, appID uuid.UUID, req AdminOAuthApplicationRequest) (*models.OAuthApplication, error) {
+	app, err := s.findApplication(ctx, "id = ?", appID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Trusted != nil {
+		app.Trusted = *req.Trusted
+	}
+	if req.Suspended != nil {
+		if *req.Suspended {
+			now := s.now()
+			app.SuspendedAt = &now
+			app.SuspendedReason = req.Reason
+		} else {
+			app.SuspendedAt = nil
+			app.SuspendedReason = ""
+		}
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(app).Error; err != nil {
+			return fmt.Errorf("failed to update oauth application: %w", err)
+		}
+		if app.SuspendedAt == nil {
+			return nil
+		}
+		return s.revokeTokens(tx, "application_id = ?", app.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// revokeTokens revokes the unrevoked tokens matching query
+func (s *oauthProviderService) revokeTokens(tx *gorm.DB, query string, args ...interface{}) error {
+	err := tx.Model(&models.OAuthAccessToken{}).Where("revoked_at IS NULL").Where(query, args...).
+		Update("revoked_at", s.now()).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke oauth tokens: %w", err)
+	}
+	return nil
+}
+
+// authorizationTarget resolves the application and redirect URI of an
+// authorization request. Until both are known, errors cannot be reported
+// to the application.
+func (s *oauthProviderService) authorizationTarget(ctx context.Context, req OAuthAuthorizeRequest) (*models.OAuthApplication, string, error) {
+	if req.ClientID == "" {
+		return nil, "", fmt.Errorf("%w: client_id is required", ErrOAuthInvalidRequest)
+	}
+	app, err := s.findApplication(ctx, "client_id = ?", req.ClientID)
+	if err != nil {
+		if errors.Is(err, ErrOAuthApplicationNotFound) {
+			return nil, "", fmt.Errorf("%w: unknown client", ErrOAuthInvalidClient)
+		}
+		return nil, "", err
+	}
+	if app.SuspendedAt != nil {
+		return nil, "", fmt.Errorf("%w: application is suspended", ErrOAuthInvalidClient)
+	}
+
+	redirectURI := req.RedirectURI
+	if redirectURI == "" {
+		if len(app.RedirectURIs) != 1 {
+			return nil, "", fmt.Errorf("%w: redirect_uri is required", ErrOAuthInvalidRequest)
+		}
+		redirectURI = app.RedirectURIs[0]
+	}
+	if !app.AllowsRedirect(redirectURI) {
+		return nil, "", fmt.Errorf("%w: redirect_uri is not registered for the application", ErrOAuthInvalidRequest)
+	}
+	return app, redirectURI, nil
+}
+
+// authorizationScopes validates the rest of an authorization request and
+// returns the requested scopes, defaulting to the application's
+func authorizationScopes(app *models.OAuthApplication, req OAuthAuthorizeRequest) ([]string, error) {
+	if req.ResponseType != "code" {
+		return nil, fmt.Errorf("%w: response_type must be code", ErrOAuthInvalidRequest)
+	}
+	if req.CodeChallenge == "" {
+		if !app.Confidential {
+			return nil, fmt.Errorf("%w: public clients must use PKCE", ErrOAuthInvalidRequest)
+		}
+	} else if req.CodeChallengeMethod != "S256" {
+		return nil, fmt.Errorf("%w: code_challenge_method must be S256", ErrOAuthInvalidRequest)
+	}
+
+	scopes := app.Scopes
+	if req.Scope != "" {
+		scopes = normalizeScopes(strings.Fields(req.Scope))
+	}
+	if !coversScopes(app.Scopes, scopes) {
+		return nil, fmt.Errorf("%w: the application is not registered for every requested scope", ErrOAuthInvalidScope)
+	}
+	return scopes, nil
+}
+
+func (s *oauthProviderService) PrepareAuthorization(ctx context.Context, userID uuid.UUID, req OAuthAuthorizeRequest) (*OAuthConsent, error) {
+	app, redirectURI, err := s.authorizationTarget(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	scopes, err := authorizationScopes(app, req)
+	if err != nil {
+		return nil, err
+	}
+
+	consent := &OAuthConsent{Application: app, Scopes: scopes, RedirectURI: redirectURI, ConsentRequired: !app.Trusted}
+	if consent.ConsentRequired {
+		var grant models.OAuthAuthorization
+		err := s.db.WithContext(ctx).Where("user_id = ? AND application_id = ?", userID, app.ID).First(&grant).Error
+		switch {
+		case err == nil:
+			consent.ConsentRequired = !coversScopes(grant.Scopes, scopes)
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, fmt.Errorf("failed to get oauth authorization: %w", err)
+		}
+	}
+	return consent, nil
+}
+
+// redirectWith appends query parameters to a registered redirect URI
+func redirectWith(redirectURI string, params url.Values) string {
+	u, _ := url.Parse(redirectURI)
+	query := u.Query()
+	for key, values := range params {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func (s *oauthProviderService) Authorize(ctx context.Context, userID uuid.UUID, req OAuthAuthorizeRequest, approve bool) (string, error) {
+	app, redirectURI, err := s.authorizationTarget(ctx, req)
+	if err != nil {
+		return "", err
+	}
+	params := url.Values{}
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	scopes, err := authorizationScopes(app, req)
+	if err != nil {
+		code := ErrOAuthInvalidRequest
+		if errors.Is(err, ErrOAuthInvalidScope) {
+			code = ErrOAuthInvalidScope
+		}
+		params.Set("error", code.Error())
+		params.Set("error_description", strings.TrimPrefix(err.Error(), code.Error()+": "))
+		return redirectWith(redirectURI, params), nil
+	}
+	if !approve {
+		params.Set("error", "access_denied")
+		params.Set("error_description", "the user denied the request")
+		return redirectWith(redirectURI, params), nil
+	}
+
+	secret, err := generateSecureToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate authorization code: %w", err)
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var grant models.OAuthAuthorization
+		err := tx.Where("user_id = ? AND application_id = ?", userID, app.ID).First(&grant).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			grant = models.OAuthAuthorization{ID: uuid.New(), UserID: userID, ApplicationID: app.ID, Scopes: scopes}
+			if err := tx.Create(&grant).Error; err != nil {
+				return fmt.Errorf("failed to record oauth authorization: %w", err)
+			}
+		case err != nil:
+			return fmt.Errorf("failed to get oauth authorization: %w", err)
+		case !coversScopes(grant.Scopes, scopes):
+			grant.Scopes = normalizeScopes(append(grant.Scopes, scopes...))
+			if err := tx.Model(&grant).Update("scopes", grant.Scopes).Error; err != nil {
+				return fmt.Errorf("failed to record oauth authorization: %w", err)
+			}
+		}
+
+		code := &models.OAuthAuthorizationCode{
+			ID:                  uuid.New(),
+			CodeHash:            hashOAuthSecret(secret),
+			ApplicationID:       app.ID,
+			UserID:              userID,
+			RedirectURI:         redirectURI,
+			Scopes:              scopes,
+			CodeChallenge:       req.CodeChallenge,
+			CodeChallengeMethod: req.CodeChallengeMethod,
+			Nonce:               req.Nonce,
+			ExpiresAt:           s.now().Add(oauthCodeLifetime),
+		}
+		if err := tx.Create(code).Error; err != nil {
+			return fmt.Errorf("failed to create authorization code: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	params.Set("code", secret)
+	return redirectWith(redirectURI, params), nil
+}
+
+// authenticateClient checks the credentials of a client. Public clients
+// identify themselves by client ID alone.
+func (s *oauthProviderService) authenticateClient(ctx context.Context, clientID, clientSecret string) (*models.OAuthApplication, error) {
+	if clientID == "" {
+		return nil, fmt.Errorf("%w: client authentication is required", ErrOAuthInvalidClient)
+	}
+	app, err := s.findApplication(ctx, "client_id = ?", clientID)
+	if err != nil {
+		if errors.Is(err, ErrOAuthApplicationNotFound) {
+			return nil, fmt.Errorf("%w: unknown client", ErrOAuthInvalidClient)
+		}
+		return nil, err
+	}
+	if app.SuspendedAt != nil {
+		return nil, fmt.Errorf("%w: application is suspended", ErrOAuthInvalidClient)
+	}
+	if app.Confidential {
+		if subtle.ConstantTimeCompare([]byte(hashOAuthSecret(clientSecret)), []byte(app.ClientSecretHash)) != 1 {
+			return nil, fmt.Errorf("%w: client authentication failed", ErrOAuthInvalidClient)
+		}
+	} else if clientSecret != "" {
+		return nil, fmt.Errorf("%w: public clients have no secret", ErrOAuthInvalidClient)
+	}
+	return app, nil
+}
+
+func (s *oauthProviderService) Token(ctx context.Context, req OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	switch req.GrantType {
+	case "authorization_code", "refresh_token":
+	case "":
+		return nil, fmt.Errorf("%w: grant_type is required", ErrOAuthInvalidRequest)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrOAuthUnsupportedGrantType, req.GrantType)
+	}
+	app, err := s.authenticateClient(ctx, req.ClientID, req.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if req.GrantType == "refresh_token" {
+		return s.refresh(ctx, app, req)
+	}
+
+	if req.Code == "" {
+		return nil, fmt.Errorf("%w: code is required", ErrOAuthInvalidRequest)
+	}
+	var code models.OAuthAuthorizationCode
+	err = s.db.WithContext(ctx).Where("code_hash = ? AND application_id = ?", hashOAuthSecret(req.Code), app.ID).First(&code).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: unknown authorization code", ErrOAuthInvalidGrant)
+		}
+		return nil, fmt.Errorf("failed to get authorization code: %w", err)
+	}
+	if !s.now().Before(code.ExpiresAt) {
+		return nil, fmt.Errorf("%w: authorization code expired", ErrOAuthInvalidGrant)
+	}
+	if req.RedirectURI != code.RedirectURI {
+		return nil, fmt.Errorf("%w: redirect_uri does not match the authorization request", ErrOAuthInvalidGrant)
+	}
+	if code.CodeChallenge != "" {
+		sum := sha256.Sum256([]byte(req.CodeVerifier))
+		if req.CodeVerifier == "" || base64.RawURLEncoding.EncodeToString(sum[:]) != code.CodeChallenge {
+			return nil, fmt.Errorf("%w: code_verifier does not match the code challenge", ErrOAuthInvalidGrant)
+		}
+	}
+
+	var response *OAuthTokenResponse
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Claiming the code conditionally makes a replayed code fail even
+		// when both exchanges race
+		result := tx.Model(&models.OAuthAuthorizationCode{}).Where("id = ? AND used_at IS NULL", code.ID).Update("used_at", s.now())
+		if result.Error != nil {
+			return fmt.Errorf("failed to redeem authorization code: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: authorization code was already used", ErrOAuthInvalidGrant)
+		}
+		var err error
+		response, err = s.issueTokens(tx, app, code.UserID, code.Scopes, code.Nonce)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// refresh rotates a refresh token, optionally narrowing its scopes
+func (s *oauthProviderService) refresh(ctx context.Context, app *models.OAuthApplication, req OAuthTokenRequest) (*OAuthTokenResponse, error) {
+	if req.RefreshToken == "" {
+		return nil, fmt.Errorf("%w: refresh_token is required", ErrOAuthInvalidRequest)
+	}
+	var token models.OAuthAccessToken
+	err := s.db.WithContext(ctx).Where("refresh_token_hash = ? AND application_id = ?", hashOAuthSecret(req.RefreshToken), app.ID).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: unknown refresh token", ErrOAuthInvalidGrant)
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if token.RevokedAt != nil || token.RefreshExpiresAt == nil || !s.now().Before(*token.RefreshExpiresAt) {
+		return nil, fmt.Errorf("%w: refresh token is expired or revoked", ErrOAuthInvalidGrant)
+	}
+
+	scopes := token.Scopes
+	if req.Scope != "" {
+		scopes = normalizeScopes(strings.Fields(req.Scope))
+		if !coversScopes(token.Scopes, scopes) {
+			return nil, fmt.Errorf("%w: refreshed scopes must be granted by the original token", ErrOAuthInvalidScope)
+		}
+	}
+
+	var response *OAuthTokenResponse
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.OAuthAccessToken{}).Where("id = ? AND revoked_at IS NULL", token.ID).Update("revoked_at", s.now())
+		if result.Error != nil {
+			return fmt.Errorf("failed to rotate refresh token: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: refresh token is expired or revoked", ErrOAuthInvalidGrant)
+		}
+		var err error
+		response, err = s.issueTokens(tx, app, token.UserID, scopes, "")
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// issueTokens creates an access and refresh token pair, and an ID token
+// when openid was granted
+func (s *oauthProviderService) issueTokens(tx *gorm.DB, app *models.OAuthApplication, userID uuid.UUID, scopes []string, nonce string) (*OAuthTokenResponse, error) {
+	var user models.User
+	if err := tx.First(&user, "id = ?", userID).Error; err != nil || !user.IsActive {
+		return nil, fmt.Errorf("%w: the user can no longer sign in", ErrOAuthInvalidGrant)
+	}
+
+	access, err := generateSecureToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+	refresh, err := generateSecureToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	access = models.OAuthAccessTokenPrefix + access
+	refresh = models.OAuthRefreshTokenPrefix + refresh
+
+	now := s.now()
+	refreshExpiresAt := now.AddDate(0, 0, s.refreshTokenDays())
+	token := &models.OAuthAccessToken{
+		ID:               uuid.New(),
+		ApplicationID:    app.ID,
+		UserID:           userID,
+		TokenHash:        hashOAuthSecret(access),
+		RefreshTokenHash: hashOAuthSecret(refresh),
+		Scopes:           scopes,
+		ExpiresAt:        now.Add(s.accessTokenLifetime()),
+		RefreshExpiresAt: &refreshExpiresAt,
+	}
+	if err := tx.Create(token).Error; err != nil {
+		return nil, fmt.Errorf("failed to create oauth token: %w", err)
+	}
+
+	response := &OAuthTokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTokenLifetime().Seconds()),
+		RefreshToken: refresh,
+		Scope:        token.ScopeString(),
+	}
+	if token.HasScope(models.OAuthScopeOpenID) {
+		info := s.UserInfo(token, &user)
+		claims := oauthIDTokenClaims{
+			Nonce:             nonce,
+			PreferredUsername: info.PreferredUsername,
+			Name:              info.Name,
+			Picture:           info.Picture,
+			Profile:           info.Profile,
+			Email:             info.Email,
+			EmailVerified:     info.EmailVerified,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    s.issuer,
+				Subject:   user.ID.String(),
+				Audience:  jwt.ClaimStrings{app.ClientID},
+				ExpiresAt: jwt.NewNumericDate(token.ExpiresAt),
+				IssuedAt:  jwt.NewNumericDate(now),
+			},
+		}
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		idToken.Header["kid"] = s.keyID
+		if response.IDToken, err = idToken.SignedString(s.signingKey); err != nil {
+			return nil, fmt.Errorf("failed to sign id token: %w", err)
+		}
+	}
+	return response, nil
+}
+
+func (s *oauthProviderService) Authenticate(ctx context.Context, secret string) (*models.OAuthAccessToken, *models.User, error) {
+	if !strings.HasPrefix(secret, models.OAuthAccessTokenPrefix) {
+		return nil, nil, ErrOAuthTokenUnauthorized
+	}
+	var token models.OAuthAccessToken
+	err := s.db.WithContext(ctx).Preload("Application").Where("token_hash = ?", hashOAuthSecret(secret)).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrOAuthTokenUnauthorized
+		}
+		return nil, nil, fmt.Errorf("failed to get oauth token: %w", err)
+	}
+	now := s.now()
+	if token.RevokedAt != nil || !now.Before(token.ExpiresAt) || token.Application.SuspendedAt != nil {
+		return nil, nil, ErrOAuthTokenUnauthorized
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).First(&user, "id = ?", token.UserID).Error; err != nil || !user.IsActive {
+		return nil, nil, ErrOAuthTokenUnauthorized
+	}
+
+	if err := s.db.WithContext(ctx).Model(&token).UpdateColumn("last_used_at", now).Error; err != nil {
+		// Usage tracking must not fail the request
+		s.logger.WithError(err).WithField("token_id", token.ID).Warn("Failed to record oauth token usage")
+	}
+	token.LastUsedAt = &now
+	token.User = user
+	return &token, &user, nil
+}
+
+// lookupToken finds an access or refresh token of app by its secret
+func (s *oauthProviderService) lookupToken(ctx context.Context, app *models.OAuthApplication, secret string) (*models.OAuthAccessToken, string, error) {
+	column, tokenType := "token_hash", "access_token"
+	switch {
+	case strings.HasPrefix(secret, models.OAuthRefreshTokenPrefix):
+		column, tokenType = "refresh_token_hash", "refresh_token"
+	case !strings.HasPrefix(secret, models.OAuthAccessTokenPrefix):
+		return nil, "", nil
+	}
+	var token models.OAuthAccessToken
+	err := s.db.WithContext(ctx).Where(column+" = ? AND application_id = ?", hashOAuthSecret(secret), app.ID).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, "", nil
+		}
+		return nil, "", fmt.Errorf("failed to get oauth token: %w", err)
+	}
+	return &token, tokenType, nil
+}
+
+func (s *oauthProviderService) Introspect(ctx context.Context, clientID, clientSecret, secret string) (*OAuthIntrospection, error) {
+	app, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	token, tokenType, err := s.lookupToken(ctx, app, secret)
+	if err != nil || token == nil {
+		return &OAuthIntrospection{}, err
+	}
+
+	expiresAt := token.ExpiresAt
+	if tokenType == "refresh_token" && token.RefreshExpiresAt != nil {
+		expiresAt = *token.RefreshExpiresAt
+	}
+	if token.RevokedAt != nil || !s.now().Before(expiresAt) {
+		return &OAuthIntrospection{}, nil
+	}
+
+	var user models.User
+	if err := s.db.WithContext(ctx).First(&user, "id = ?", token.UserID).Error; err != nil || !user.IsActive {
+		return &OAuthIntrospection{}, nil
+	}
+	return &OAuthIntrospection{
+		Active:    true,
+		Scope:     token.ScopeString(),
+		ClientID:  app.ClientID,
+		Username:  user.Username,
+		Subject:   user.ID.String(),
+		TokenType: tokenType,
+		ExpiresAt: expiresAt.Unix(),
+		IssuedAt:  token.CreatedAt.Unix(),
+	}, nil
+}
+
+func (s *oauthProviderService) Revoke(ctx context.Context, clientID, clientSecret, secret string) error {
+	app, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return err
+	}
+	token, _, err := s.lookupToken(ctx, app, secret)
+	if err != nil || token == nil {
+		return err
+	}
+	return s.revokeTokens(s.db.WithContext(ctx), "id = ?", token.ID)
+}
+
+func (s *oauthProviderService) UserInfo(token *models.OAuthAccessToken, user *models.User) *OAuthUserInfo {
+	info := &OAuthUserInfo{Subject: user.ID.String()}
+	if token.HasScope(models.OAuthScopeProfile) {
+		info.PreferredUsername = user.Username
+		info.Name = user.FullName
+		info.Picture = user.AvatarURL
+		info.Profile = s.issuer + "/" + user.Username
+	}
+	if token.HasScope(models.OAuthScopeEmail) {
+		verified := user.EmailVerified
+		info.Email = user.Email
+		info.EmailVerified = &verified
+	}
+	return info
+}
+
+func (s *oauthProviderService) ListAuthorizations(ctx context.Context, userID uuid.UUID) ([]*models.OAuthAuthorization, error) {
+	var grants []*models.OAuthAuthorization
+	err := s.db.WithContext(ctx).Preload("Application").Where("user_id = ?", userID).Order("updated_at DESC").Find(&grants).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list oauth authorizations: %w", err)
+	}
+	return grants, nil
+}
+
+func (s *oauthProviderService) RevokeAuthorization(ctx context.Context, userID, appID uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("user_id = ? AND application_id = ?", userID, appID).Delete(&models.OAuthAuthorization{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to revoke oauth authorization: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrOAuthApplicationNotFound
+		}
+		return s.revokeTokens(tx, "user_id = ? AND application_id = ?", userID, appID)
+	})
+}
+
+func (s *oauthProviderService) Discovery() map[string]interface{} {
+	scopes := make([]string, 0, len(oauthScopes))
+	for scope := range oauthScopes {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	api := s.issuer + "/api/v1/oauth"
+	return map[string]interface{}{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                api + "/authorize",
+		"token_endpoint":                        api + "/token",
+		"userinfo_endpoint":                     api + "/userinfo",
+		"jwks_uri":                              api + "/jwks",
+		"introspection_endpoint":                api + "/introspect",
+		"revocation_endpoint":                   api + "/revoke",
+		"registration_endpoint":                 api + "/register",
+		"scopes_supported":                      scopes,
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{"authorization_code", "refresh_token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+	}
+}
+
+func (s *oauthProviderService) JWKS() map[string]interface{} {
+	public := s.signingKey.PublicKey
+	return map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": s.keyID,
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	}
+}
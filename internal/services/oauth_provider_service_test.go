@@ -0,0 +1,263 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuthProviderService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.OAuthApplication{}, &models.OAuthAuthorization{},
+		&models.OAuthAuthorizationCode{}, &models.OAuthAccessToken{})
+
+	alice := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", FullName: "Alice", EmailVerified: true, IsActive: true}
+	bob := &models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com", IsActive: true}
+	require.NoError(t, db.Create([]*models.User{alice, bob}).Error)
+
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	service, err := NewOAuthProviderService(db, config.OAuthProvider{AccessTokenMinutes: 60, RefreshTokenDays: 30}, "https://hub.example.com/", logrus.New())
+	require.NoError(t, err)
+	svc := service.(*oauthProviderService)
+	svc.now = func() time.Time { return now }
+
+	_, _, err = svc.CreateApplication(ctx, bob.ID, false, OAuthApplicationRequest{Name: "ci", RedirectURIs: []string{"http://ci.example.com/callback"}})
+	assert.ErrorIs(t, err, ErrInvalidOAuthApplication)
+	_, _, err = svc.CreateApplication(ctx, bob.ID, false, OAuthApplicationRequest{Name: "ci", RedirectURIs: []string{"https://ci.example.com/cb"}, Scopes: []string{"admin"}})
+	assert.ErrorIs(t, err, ErrInvalidOAuthApplication)
+
+	app, secret, err := svc.CreateApplication(ctx, bob.ID, false, OAuthApplicationRequest{
+		Name:         "ci",
+		RedirectURIs: []string{"https://ci.example.com/cb", "http://127.0.0.1:8000/cb"},
+		Scopes:       []string{models.OAuthScopeOpenID, models.OAuthScopeEmail, models.OAuthScopeProfile, models.OAuthScopeReadRepo},
+	})
+	require.NoError(t, err)
+	assert.True(t, app.Confidential)
+	assert.Equal(t, secret[len(secret)-8:], app.ClientSecretLastEight)
+
+	svc.cfg.AdminOnlyRegistration = true
+	_, _, err = svc.CreateApplication(ctx, bob.ID, false, OAuthApplicationRequest{Name: "other", RedirectURIs: []string{"https://other.example.com/cb"}})
+	assert.ErrorIs(t, err, ErrOAuthRegistrationForbidden)
+	svc.cfg.AdminOnlyRegistration = false
+
+	request := OAuthAuthorizeRequest{ResponseType: "code", ClientID: app.ClientID, RedirectURI: "https://ci.example.com/cb",
+		Scope: "openid email profile", State: "xyz", Nonce: "n-0S6"}
+
+	// Unregistered redirect URIs are never redirected to
+	_, err = svc.Authorize(ctx, alice.ID, OAuthAuthorizeRequest{ResponseType: "code", ClientID: app.ClientID, RedirectURI: "https://evil.example.com/cb"}, true)
+	assert.ErrorIs(t, err, ErrOAuthInvalidRequest)
+
+	// Other problems are reported back to the application
+	redirect, err := svc.Authorize(ctx, alice.ID, OAuthAuthorizeRequest{ResponseType: "code", ClientID: app.ClientID, RedirectURI: "https://ci.example.com/cb", Scope: "repo", State: "xyz"}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "invalid_scope", queryOf(t, redirect).Get("error"))
+	redirect, err = svc.Authorize(ctx, alice.ID, request, false)
+	require.NoError(t, err)
+	assert.Equal(t, "access_denied", queryOf(t, redirect).Get("error"))
+	assert.Equal(t, "xyz", queryOf(t, redirect).Get("state"))
+
+	consent, err := svc.PrepareAuthorization(ctx, alice.ID, request)
+	require.NoError(t, err)
+	assert.True(t, consent.ConsentRequired)
+	assert.Equal(t, []string{"email", "openid", "profile"}, consent.Scopes)
+
+	redirect, err = svc.Authorize(ctx, alice.ID, request, true)
+	require.NoError(t, err)
+	code := queryOf(t, redirect).Get("code")
+	require.NotEmpty(t, code)
+	consent, err = svc.PrepareAuthorization(ctx, alice.ID, request)
+	require.NoError(t, err)
+	assert.False(t, consent.ConsentRequired)
+
+	exchange := OAuthTokenRequest{GrantType: "authorization_code", Code: code, RedirectURI: "https://ci.example.com/cb", ClientID: app.ClientID, ClientSecret: secret}
+	wrongSecret := exchange
+	wrongSecret.ClientSecret = "hub_ocs_nope"
+	_, err = svc.Token(ctx, wrongSecret)
+	assert.ErrorIs(t, err, ErrOAuthInvalidClient)
+	wrongRedirect := exchange
+	wrongRedirect.RedirectURI = "http://127.0.0.1:8000/cb"
+	_, err = svc.Token(ctx, wrongRedirect)
+	assert.ErrorIs(t, err, ErrOAuthInvalidGrant)
+
+	tokens, err := svc.Token(ctx, exchange)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	assert.Equal(t, 3600, tokens.ExpiresIn)
+	assert.Equal(t, "email openid profile", tokens.Scope)
+
+	// Codes are single use
+	_, err = svc.Token(ctx, exchange)
+	assert.ErrorIs(t, err, ErrOAuthInvalidGrant)
+
+	// The ID token verifies against the published key set
+	claims := &oauthIDTokenClaims{}
+	_, err = jwt.ParseWithClaims(tokens.IDToken, claims, func(token *jwt.Token) (interface{}, error) {
+		assert.Equal(t, svc.JWKS()["keys"].([]map[string]string)[0]["kid"], token.Header["kid"])
+		return &svc.signingKey.PublicKey, nil
+	}, jwt.WithTimeFunc(func() time.Time { return now }), jwt.WithAudience(app.ClientID), jwt.WithIssuer("https://hub.example.com"))
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID.String(), claims.Subject)
+	assert.Equal(t, "n-0S6", claims.Nonce)
+	assert.Equal(t, "alice@example.com", claims.Email)
+	assert.Equal(t, "alice", claims.PreferredUsername)
+
+	token, user, err := svc.Authenticate(ctx, tokens.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, alice.ID, user.ID)
+	assert.False(t, token.HasScope(models.OAuthScopeReadRepo))
+	info := svc.UserInfo(token, user)
+	assert.Equal(t, "https://hub.example.com/alice", info.Profile)
+
+	// Only the client the token was issued to may introspect it
+	other, otherSecret, err := svc.CreateApplication(ctx, bob.ID, false, OAuthApplicationRequest{Name: "other", RedirectURIs: []string{"https://other.example.com/cb"}})
+	require.NoError(t, err)
+	introspection, err := svc.Introspect(ctx, other.ClientID, otherSecret, tokens.AccessToken)
+	require.NoError(t, err)
+	assert.False(t, introspection.Active)
+	introspection, err = svc.Introspect(ctx, app.ClientID, secret, tokens.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, introspection.Active)
+	assert.Equal(t, "alice", introspection.Username)
+	assert.Equal(t, "access_token", introspection.TokenType)
+
+	// Refreshing rotates both tokens and may narrow the scopes
+	refreshed, err := svc.Token(ctx, OAuthTokenRequest{GrantType: "refresh_token", RefreshToken: tokens.RefreshToken, Scope: "openid", ClientID: app.ClientID, ClientSecret: secret})
+	require.NoError(t, err)
+	assert.Equal(t, "openid", refreshed.Scope)
+	_, _, err = svc.Authenticate(ctx, tokens.AccessToken)
+	assert.ErrorIs(t, err, ErrOAuthTokenUnauthorized)
+	_, err = svc.Token(ctx, OAuthTokenRequest{GrantType: "refresh_token", RefreshToken: tokens.RefreshToken, ClientID: app.ClientID, ClientSecret: secret})
+	assert.ErrorIs(t, err, ErrOAuthInvalidGrant)
+
+	require.NoError(t, svc.Revoke(ctx, app.ClientID, secret, refreshed.RefreshToken))
+	_, _, err = svc.Authenticate(ctx, refreshed.AccessToken)
+	assert.ErrorIs(t, err, ErrOAuthTokenUnauthorized)
+	require.NoError(t, svc.Revoke(ctx, app.ClientID, secret, "hub_oat_unknown"))
+
+	// Tokens expire
+	redirect, err = svc.Authorize(ctx, alice.ID, request, true)
+	require.NoError(t, err)
+	exchange.Code = queryOf(t, redirect).Get("code")
+	tokens, err = svc.Token(ctx, exchange)
+	require.NoError(t, err)
+	svc.now = func() time.Time { return now.Add(2 * time.Hour) }
+	_, _, err = svc.Authenticate(ctx, tokens.AccessToken)
+	assert.ErrorIs(t, err, ErrOAuthTokenUnauthorized)
+	svc.now = func() time.Time { return now }
+
+	// Withdrawing the grant revokes the application's tokens
+	grants, err := svc.ListAuthorizations(ctx, alice.ID)
+	require.NoError(t, err)
+	require.Len(t, grants, 1)
+	assert.Equal(t, "ci", grants[0].Application.Name)
+	require.NoError(t, svc.RevokeAuthorization(ctx, alice.ID, app.ID))
+	introspection, err = svc.Introspect(ctx, app.ClientID, secret, tokens.RefreshToken)
+	require.NoError(t, err)
+	assert.False(t, introspection.Active)
+	assert.ErrorIs(t, svc.RevokeAuthorization(ctx, alice.ID, app.ID), ErrOAuthApplicationNotFound)
+
+	// Public clients have no secret and must prove possession with PKCE
+	spa, spaSecret, err := svc.CreateApplication(ctx, bob.ID, false, OAuthApplicationRequest{Name: "spa", Public: true,
+		RedirectURIs: []string{"http://localhost:3000/cb"}, Scopes: []string{models.OAuthScopeRepo}})
+	require.NoError(t, err)
+	assert.Empty(t, spaSecret)
+	_, _, err = svc.ResetClientSecret(ctx, bob.ID, spa.ID)
+	assert.ErrorIs(t, err, ErrInvalidOAuthApplication)
+
+	pkce := OAuthAuthorizeRequest{ResponseType: "code", ClientID: spa.ClientID}
+	_, err = svc.PrepareAuthorization(ctx, alice.ID, pkce)
+	assert.ErrorIs(t, err, ErrOAuthInvalidRequest)
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	pkce.CodeChallenge = base64.RawURLEncoding.EncodeToString(sum[:])
+	pkce.CodeChallengeMethod = "S256"
+	redirect, err = svc.Authorize(ctx, alice.ID, pkce, true)
+	require.NoError(t, err)
+	spaExchange := OAuthTokenRequest{GrantType: "authorization_code", Code: queryOf(t, redirect).Get("code"),
+		RedirectURI: "http://localhost:3000/cb", ClientID: spa.ClientID, CodeVerifier: "wrong"}
+	_, err = svc.Token(ctx, spaExchange)
+	assert.ErrorIs(t, err, ErrOAuthInvalidGrant)
+	spaExchange.CodeVerifier = verifier
+	spaTokens, err := svc.Token(ctx, spaExchange)
+	require.NoError(t, err)
+	assert.Empty(t, spaTokens.IDToken)
+	token, _, err = svc.Authenticate(ctx, spaTokens.AccessToken)
+	require.NoError(t, err)
+	assert.True(t, token.HasScope(models.OAuthScopeReadRepo))
+
+	// Suspending an application revokes its tokens and blocks new ones
+	suspended := true
+	_, err = svc.AdminUpdateApplication(ctx, spa.ID, AdminOAuthApplicationRequest{Suspended: &suspended, Reason: "abuse"})
+	require.NoError(t, err)
+	_, _, err = svc.Authenticate(ctx, spaTokens.AccessToken)
+	assert.ErrorIs(t, err, ErrOAuthTokenUnauthorized)
+	_, err = svc.PrepareAuthorization(ctx, alice.ID, pkce)
+	assert.ErrorIs(t, err, ErrOAuthInvalidClient)
+
+	require.NoError(t, svc.DeleteApplication(ctx, bob.ID, app.ID))
+	_, err = svc.GetApplication(ctx, bob.ID, app.ID)
+	assert.ErrorIs(t, err, ErrOAuthApplicationNotFound)
+}
+
+func queryOf(t *testing.T, redirect string) url.Values {
+	u, err := url.Parse(redirect)
+	require.NoError(t, err)
+	return u.Query()
+}
+
+func TestOAuthProviderService_RegisterClient(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.OAuthApplication{})
+	ctx := context.Background()
+	svc, err := NewOAuthProviderService(db, config.OAuthProvider{}, "https://hub.example.com", logrus.New())
+	require.NoError(t, err)
+	owner := uuid.New()
+
+	_, err = svc.RegisterClient(ctx, owner, false, OAuthClientMetadata{RedirectURIs: []string{"http://cli.example.com/cb"}})
+	assert.ErrorIs(t, err, ErrOAuthInvalidRedirectURI)
+	_, err = svc.RegisterClient(ctx, owner, false, OAuthClientMetadata{RedirectURIs: []string{"https://cli.example.com/cb"}, GrantTypes: []string{"password"}})
+	assert.ErrorIs(t, err, ErrOAuthInvalidClientMetadata)
+	_, err = svc.RegisterClient(ctx, owner, false, OAuthClientMetadata{RedirectURIs: []string{"https://cli.example.com/cb"}, Scope: "openid admin"})
+	assert.ErrorIs(t, err, ErrOAuthInvalidClientMetadata)
+
+	// Confidential clients get a secret that does not expire
+	registration, err := svc.RegisterClient(ctx, owner, false, OAuthClientMetadata{
+		RedirectURIs: []string{"https://ci.example.com/cb"},
+		ClientName:   "ci",
+		GrantTypes:   []string{"authorization_code", "refresh_token"},
+		Scope:        "read:repo openid",
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, registration.ClientSecret)
+	require.NotNil(t, registration.ClientSecretExpiresAt)
+	assert.Zero(t, *registration.ClientSecretExpiresAt)
+	assert.Equal(t, "client_secret_basic", registration.TokenEndpointAuthMethod)
+	assert.Equal(t, "openid read:repo", registration.Scope)
+	apps, err := svc.ListApplications(ctx, owner)
+	require.NoError(t, err)
+	require.Len(t, apps, 1)
+	assert.Equal(t, registration.ClientID, apps[0].ClientID)
+	assert.True(t, apps[0].Confidential)
+
+	// Native tools register as public clients, which must use PKCE
+	registration, err = svc.RegisterClient(ctx, owner, false, OAuthClientMetadata{
+		RedirectURIs:            []string{"http://127.0.0.1:8400/cb"},
+		TokenEndpointAuthMethod: "none",
+	})
+	require.NoError(t, err)
+	assert.Empty(t, registration.ClientSecret)
+	assert.Nil(t, registration.ClientSecretExpiresAt)
+	assert.Equal(t, "http://127.0.0.1:8400/cb", registration.ClientName)
+	assert.Equal(t, "https://hub.example.com/api/v1/oauth/register", svc.Discovery()["registration_endpoint"])
+}
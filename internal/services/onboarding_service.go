@@ -0,0 +1,451 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSampleRepositoryExists is returned when a sample repository was
+	// already generated and still exists
+	ErrSampleRepositoryExists = errors.New("sample repository already exists")
+)
+
+// onboardingSteps are the checklist steps of users and organizations, in the
+// order they are shown
+var onboardingSteps = map[models.OwnerType][]OnboardingStepStatus{
+	models.OwnerTypeUser: {
+		{Step: models.OnboardingStepAddSSHKey, Title: "Add an SSH key", Description: "Push and pull over SSH without entering a password."},
+		{Step: models.OnboardingStepCreateRepository, Title: "Create a repository", Description: "Start a project of your own, or explore the sample repository first."},
+		{Step: models.OnboardingStepInviteMember, Title: "Invite a teammate", Description: "Invite someone to one of your organizations."},
+	},
+	models.OwnerTypeOrganization: {
+		{Step: models.OnboardingStepCreateRepository, Title: "Create a repository", Description: "Give the organization its first project."},
+		{Step: models.OnboardingStepInviteMember, Title: "Invite a member", Description: "Bring your team into the organization."},
+		{Step: models.OnboardingStepCreateTeam, Title: "Create a team", Description: "Group members to manage repository access together."},
+	},
+}
+
+// sampleRepositoryName is the name given to generated sample repositories,
+// suffixed with a number when taken
+const sampleRepositoryName = "hub-sample"
+
+// OnboardingStepStatus is a checklist step and whether it is completed
+type OnboardingStepStatus struct {
+	Step        models.OnboardingStep `json:"step"`
+	Title       string                `json:"title"`
+	Description string                `json:"description"`
+	Completed   bool                  `json:"completed"`
+	CompletedAt *time.Time            `json:"completed_at,omitempty"`
+}
+
+// OnboardingProgress is the onboarding checklist of a user or organization
+type OnboardingProgress struct {
+	OwnerType        models.OwnerType       `json:"owner_type"`
+	Steps            []OnboardingStepStatus `json:"steps"`
+	Completed        int                    `json:"completed"`
+	Total            int                    `json:"total"`
+	SampleRepository *models.Repository     `json:"sample_repository,omitempty"`
+	DismissedAt      *time.Time             `json:"dismissed_at,omitempty"`
+}
+
+// OnboardingService tracks onboarding checklists and generates sample
+// repositories for new users and organizations
+type OnboardingService interface {
+	// Progress returns the checklist of the owner, recording steps that
+	// have been completed since it was last checked
+	Progress(ctx context.Context, ownerType models.OwnerType, ownerID uuid.UUID) (*OnboardingProgress, error)
+	// GenerateSampleRepository creates a private repository for the owner
+	// with example code, CI configuration, issues and a pull request opened
+	// by actorID
+	GenerateSampleRepository(ctx context.Context, ownerType models.OwnerType, ownerID, actorID uuid.UUID) (*models.Repository, error)
+	// Dismiss hides the checklist of the owner
+	Dismiss(ctx context.Context, ownerType models.OwnerType, ownerID uuid.UUID) error
+}
+
+type onboardingService struct {
+	db                 *gorm.DB
+	gitService         git.GitService
+	repositoryService  RepositoryService
+	issueService       IssueService
+	pullRequestService PullRequestService
+	logger             *logrus.Logger
+	now                func() time.Time
+}
+
+// NewOnboardingService creates a new onboarding service
+func NewOnboardingService(db *gorm.DB, gitService git.GitService, repositoryService RepositoryService, issueService IssueService,
+	pullRequestService PullRequestService, logger *logrus.Logger) OnboardingService {
+	return &onboardingService{
+		db:                 db,
+		gitService:         gitService,
+		repositoryService:  repositoryService,
+		issueService:       issueService,
+		pullRequestService: pullRequestService,
+		logger:             logger,
+		now:                time.Now,
+	}
+}
+
+// onboarding returns the onboarding record of the owner, creating it on
+// first use
+func (s *onboardingService) onboarding(ctx context.Context, ownerType models.OwnerType, ownerID uuid.UUID) (*models.Onboarding, error) {
+	if _, ok := onboardingSteps[ownerType]; !ok {
+		return nil, fmt.Errorf("unknown owner type %q", ownerType)
+	}
+	var onboarding models.Onboarding
+	err := s.db.WithContext(ctx).Where(models.Onboarding{OwnerID: ownerID, OwnerType: ownerType}).
+		Attrs(models.Onboarding{ID: uuid.New()}).FirstOrCreate(&onboarding).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get onboarding: %w", err)
+	}
+	return &onboarding, nil
+}
+
+func (s *onboardingService) Progress(ctx context.Context, ownerType models.OwnerType, ownerID uuid.UUID) (*OnboardingProgress, error) {
+	onboarding, err := s.onboarding(ctx, ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	completed := onboarding.Completed()
+	changed := false
+	progress := &OnboardingProgress{OwnerType: ownerType, DismissedAt: onboarding.DismissedAt}
+	for _, step := range onboardingSteps[ownerType] {
+		if _, ok := completed[step.Step]; !ok {
+			done, err := s.stepDone(ctx, onboarding, step.Step)
+			if err != nil {
+				return nil, err
+			}
+			if done {
+				completed[step.Step] = s.now()
+				changed = true
+			}
+		}
+		if at, ok := completed[step.Step]; ok {
+			step.Completed = true
+			step.CompletedAt = &at
+			progress.Completed++
+		}
+		progress.Steps = append(progress.Steps, step)
+	}
+	progress.Total = len(progress.Steps)
+
+	if changed {
+		onboarding.SetCompleted(completed)
+		if err := s.db.WithContext(ctx).Model(onboarding).Update("completed_steps", onboarding.CompletedSteps).Error; err != nil {
+			return nil, fmt.Errorf("failed to record onboarding progress: %w", err)
+		}
+	}
+
+	if onboarding.SampleRepositoryID != nil {
+		var repo models.Repository
+		err := s.db.WithContext(ctx).First(&repo, "id = ?", *onboarding.SampleRepositoryID).Error
+		if err == nil {
+			progress.SampleRepository = &repo
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("failed to get sample repository: %w", err)
+		}
+	}
+	return progress, nil
+}
+
+// stepDone reports whether the owner has done what completes step. Removed
+// keys and settled invitations still count; the sample repository does not
+// count as a created repository, and members added directly count as
+// invited.
+func (s *onboardingService) stepDone(ctx context.Context, onboarding *models.Onboarding, step models.OnboardingStep) (bool, error) {
+	db := s.db.WithContext(ctx)
+	var queries []*gorm.DB
+	switch step {
+	case models.OnboardingStepAddSSHKey:
+		queries = append(queries, db.Unscoped().Model(&models.SSHKey{}).Where("user_id = ?", onboarding.OwnerID))
+	case models.OnboardingStepCreateRepository:
+		query := db.Model(&models.Repository{}).Where("owner_id = ? AND owner_type = ?", onboarding.OwnerID, onboarding.OwnerType)
+		if onboarding.SampleRepositoryID != nil {
+			query = query.Where("id <> ?", *onboarding.SampleRepositoryID)
+		}
+		queries = append(queries, query)
+	case models.OnboardingStepInviteMember:
+		if onboarding.OwnerType == models.OwnerTypeUser {
+			queries = append(queries, db.Unscoped().Model(&models.OrganizationInvitation{}).Where("inviter_id = ?", onboarding.OwnerID))
+		} else {
+			queries = append(queries,
+				db.Unscoped().Model(&models.OrganizationInvitation{}).Where("organization_id = ?", onboarding.OwnerID),
+				db.Model(&models.OrganizationMember{}).Where("organization_id = ? AND role <> ?", onboarding.OwnerID, models.OrgRoleOwner))
+		}
+	case models.OnboardingStepCreateTeam:
+		queries = append(queries, db.Model(&models.Team{}).Where("organization_id = ?", onboarding.OwnerID))
+	}
+
+	for _, query := range queries {
+		var count int64
+		if err := query.Limit(1).Count(&count).Error; err != nil {
+			return false, fmt.Errorf("failed to check onboarding step %s: %w", step, err)
+		}
+		if count > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *onboardingService) Dismiss(ctx context.Context, ownerType models.OwnerType, ownerID uuid.UUID) error {
+	onboarding, err := s.onboarding(ctx, ownerType, ownerID)
+	if err != nil {
+		return err
+	}
+	if err := s.db.WithContext(ctx).Model(onboarding).Update("dismissed_at", s.now()).Error; err != nil {
+		return fmt.Errorf("failed to dismiss onboarding: %w", err)
+	}
+	return nil
+}
+
+func (s *onboardingService) GenerateSampleRepository(ctx context.Context, ownerType models.OwnerType, ownerID, actorID uuid.UUID) (*models.Repository, error) {
+	onboarding, err := s.onboarding(ctx, ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if onboarding.SampleRepositoryID != nil {
+		var count int64
+		if err := s.db.WithContext(ctx).Model(&models.Repository{}).Where("id = ?", *onboarding.SampleRepositoryID).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to get sample repository: %w", err)
+		}
+		if count > 0 {
+			return nil, ErrSampleRepositoryExists
+		}
+	}
+
+	var actor models.User
+	if err := s.db.WithContext(ctx).First(&actor, "id = ?", actorID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	name, err := s.sampleName(ctx, ownerType, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	repo, err := s.repositoryService.Create(ctx, CreateRepositoryRequest{
+		OwnerID:          ownerID,
+		OwnerType:        ownerType,
+		Name:             name,
+		Description:      "A sample project to explore issues, pull requests and CI",
+		Visibility:       models.VisibilityPrivate,
+		HasIssues:        true,
+		AllowMergeCommit: true,
+		AllowSquashMerge: true,
+		AutoInit:         true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create sample repository: %w", err)
+	}
+	// The repository is recorded first so a partly populated sample is
+	// not generated twice
+	if err := s.db.WithContext(ctx).Model(onboarding).Update("sample_repository_id", repo.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to record sample repository: %w", err)
+	}
+
+	if err := s.populateSample(ctx, repo, &actor); err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"repository_id": repo.ID,
+		"owner_id":      ownerID,
+		"owner_type":    ownerType,
+	}).Info("Generated sample repository")
+	return repo, nil
+}
+
+// sampleName returns the first sample repository name the owner does not use
+func (s *onboardingService) sampleName(ctx context.Context, ownerType models.OwnerType, ownerID uuid.UUID) (string, error) {
+	for i := 1; i <= 20; i++ {
+		name := sampleRepositoryName
+		if i > 1 {
+			name = fmt.Sprintf("%s-%d", sampleRepositoryName, i)
+		}
+		var count int64
+		err := s.db.WithContext(ctx).Model(&models.Repository{}).
+			Where("owner_id = ? AND owner_type = ? AND name = ?", ownerID, ownerType, name).Count(&count).Error
+		if err != nil {
+			return "", fmt.Errorf("failed to check repository name: %w", err)
+		}
+		if count == 0 {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("no sample repository name available")
+}
+
+// populateSample commits the sample project to the default branch, a change
+// to a feature branch, and opens issues and a pull request for it
+func (s *onboardingService) populateSample(ctx context.Context, repo *models.Repository, actor *models.User) error {
+	repoPath, err := s.repositoryService.GetRepositoryPath(ctx, repo.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get repository path: %w", err)
+	}
+	author := git.CommitAuthor{Name: actor.FullName, Email: actor.Email}
+	if author.Name == "" {
+		author.Name = actor.Username
+	}
+
+	if _, err := s.gitService.CommitFiles(ctx, repoPath, git.CommitFilesRequest{
+		Files:   sampleFiles(repo.Name),
+		Message: "Add sample project",
+		Branch:  repo.DefaultBranch,
+		Author:  author,
+	}); err != nil {
+		return fmt.Errorf("failed to commit sample project: %w", err)
+	}
+	if err := s.gitService.CreateBranch(ctx, repoPath, sampleFeatureBranch, repo.DefaultBranch); err != nil {
+		return fmt.Errorf("failed to create sample branch: %w", err)
+	}
+	if _, err := s.gitService.CommitFiles(ctx, repoPath, git.CommitFilesRequest{
+		Files:   sampleFeatureFiles,
+		Message: "Greet by name",
+		Branch:  sampleFeatureBranch,
+		Author:  author,
+	}); err != nil {
+		return fmt.Errorf("failed to commit sample change: %w", err)
+	}
+
+	for _, issue := range sampleIssues {
+		if _, err := s.issueService.Create(ctx, repo.ID, actor.ID, issue); err != nil {
+			return fmt.Errorf("failed to create sample issue: %w", err)
+		}
+	}
+	if _, err := s.pullRequestService.Create(ctx, repo.ID, actor.ID, CreatePullRequestRequest{
+		Title: "Greet by name",
+		Body:  "Lets `hello` greet whoever is named on the command line.\n\nReview the change, check the CI results and merge it when you are ready.\n\nCloses #2",
+		Head:  sampleFeatureBranch,
+		Base:  repo.DefaultBranch,
+	}); err != nil {
+		return fmt.Errorf("failed to create sample pull request: %w", err)
+	}
+	return nil
+}
+
+const sampleFeatureBranch = "greet-by-name"
+
+func sampleFiles(name string) []git.CommitFileEntry {
+	readme := "# " + name + "\n\n" +
+		"This sample repository was generated to show you around. It contains a small Go\n" +
+		"program, a CI workflow that tests it on every push, a few issues and a pull request.\n\n" +
+		"## Things to try\n\n" +
+		"- Clone the repository and run `go run .` to see the greeting.\n" +
+		"- Work through the open issues.\n" +
+		"- Review and merge the pull request that closes one of them.\n" +
+		"- Delete this repository when you are done; it is yours to change.\n"
+	return []git.CommitFileEntry{
+		{Path: "README.md", Content: []byte(readme)},
+		{Path: "go.mod", Content: []byte("module example.com/hello\n\ngo 1.22\n")},
+		{Path: "main.go", Content: []byte(sampleMain)},
+		{Path: "main_test.go", Content: []byte(sampleTest)},
+		{Path: ".github/workflows/ci.yml", Content: []byte(sampleWorkflow)},
+	}
+}
+
+const sampleMain = `package main
+
+import "fmt"
+
+// Greeting returns the greeting printed by hello
+func Greeting() string {
+	return "Hello, world!"
+}
+
+func main() {
+	fmt.Println(Greeting())
+}
+`
+
+const sampleTest = `package main
+
+import "testing"
+
+func TestGreeting(t *testing.T) {
+	if got := Greeting(); got != "Hello, world!" {
+		t.Errorf("Greeting() = %q", got)
+	}
+}
+`
+
+const sampleWorkflow = `name: CI
+
+on:
+  push:
+  pull_request:
+
+jobs:
+  test:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v4
+      - uses: actions/setup-go@v5
+        with:
+          go-version: "1.22"
+      - run: go vet ./...
+      - run: go test ./...
+`
+
+var sampleFeatureFiles = []git.CommitFileEntry{
+	{Path: "main.go", Content: []byte(`package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// Greeting returns the greeting printed by hello for name, or for the
+// world when name is empty
+func Greeting(name string) string {
+	if name == "" {
+		name = "world"
+	}
+	return "Hello, " + name + "!"
+}
+
+func main() {
+	name := ""
+	if len(os.Args) > 1 {
+		name = os.Args[1]
+	}
+	fmt.Println(Greeting(name))
+}
+`)},
+	{Path: "main_test.go", Content: []byte(`package main
+
+import "testing"
+
+func TestGreeting(t *testing.T) {
+	for name, want := range map[string]string{"": "Hello, world!", "Ada": "Hello, Ada!"} {
+		if got := Greeting(name); got != want {
+			t.Errorf("Greeting(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+`)},
+}
+
+var sampleIssues = []CreateIssueRequest{
+	{
+		Title: "Welcome to your sample repository",
+		Body:  "Issues track bugs, ideas and tasks. Comment here, add a label or assign yourself, then close the issue when you are done.",
+	},
+	{
+		Title: "Greet people by name",
+		Body:  "`hello` always greets the world. It should greet whoever is named on the command line.\n\nThe open pull request fixes this and closes the issue when merged.",
+	},
+	{
+		Title: "Protect the default branch",
+		Body:  "Open the repository settings and add a branch protection rule requiring the CI check to pass before merging.",
+	},
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnboardingService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{}, &models.OrganizationInvitation{},
+		&models.Team{}, &models.SSHKey{}, &models.Repository{}, &models.Label{}, &models.Issue{}, &models.IssueRedirect{}, &models.PullRequest{},
+		&models.PullRequestFile{}, &models.Onboarding{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	base := t.TempDir()
+	gitService := git.NewGitService(logger)
+	repoService := NewRepositoryService(db, gitService, logger, base)
+	svc := NewOnboardingService(db, gitService, repoService, NewIssueService(db, logger),
+		NewPullRequestService(db, gitService, repoService, logger, base), logger).(*onboardingService)
+	now := time.Date(2026, 5, 1, 9, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	alice := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", FullName: "Alice", PasswordHash: "x"}
+	require.NoError(t, db.Create(alice).Error)
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+
+	steps := func(progress *OnboardingProgress) map[models.OnboardingStep]bool {
+		out := map[models.OnboardingStep]bool{}
+		for _, step := range progress.Steps {
+			out[step.Step] = step.Completed
+		}
+		return out
+	}
+
+	t.Run("new users start with nothing completed", func(t *testing.T) {
+		progress, err := svc.Progress(ctx, models.OwnerTypeUser, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, progress.Total)
+		assert.Equal(t, 0, progress.Completed)
+		assert.Nil(t, progress.SampleRepository)
+	})
+
+	t.Run("sample repository", func(t *testing.T) {
+		repo, err := svc.GenerateSampleRepository(ctx, models.OwnerTypeUser, alice.ID, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "hub-sample", repo.Name)
+		assert.Equal(t, models.VisibilityPrivate, repo.Visibility)
+
+		repoPath, err := repoService.GetRepositoryPath(ctx, repo.ID)
+		require.NoError(t, err)
+		file, err := gitService.GetFile(ctx, repoPath, "main", ".github/workflows/ci.yml")
+		require.NoError(t, err)
+		assert.NotEmpty(t, file.Content)
+		_, err = gitService.GetBranch(ctx, repoPath, sampleFeatureBranch)
+		require.NoError(t, err)
+
+		var issues, pulls int64
+		require.NoError(t, db.Model(&models.Issue{}).Where("repository_id = ?", repo.ID).Count(&issues).Error)
+		require.NoError(t, db.Model(&models.PullRequest{}).Where("repository_id = ?", repo.ID).Count(&pulls).Error)
+		assert.Equal(t, int64(len(sampleIssues)), issues)
+		assert.Equal(t, int64(1), pulls)
+
+		_, err = svc.GenerateSampleRepository(ctx, models.OwnerTypeUser, alice.ID, alice.ID)
+		assert.ErrorIs(t, err, ErrSampleRepositoryExists)
+
+		// The sample does not complete the create repository step
+		progress, err := svc.Progress(ctx, models.OwnerTypeUser, alice.ID)
+		require.NoError(t, err)
+		require.NotNil(t, progress.SampleRepository)
+		assert.Equal(t, repo.ID, progress.SampleRepository.ID)
+		assert.False(t, steps(progress)[models.OnboardingStepCreateRepository])
+	})
+
+	t.Run("steps complete and stay completed", func(t *testing.T) {
+		key := &models.SSHKey{ID: uuid.New(), UserID: alice.ID, Title: "laptop", KeyData: "ssh-ed25519 AAAA", Fingerprint: "SHA256:abc"}
+		require.NoError(t, db.Create(key).Error)
+		require.NoError(t, db.Create(&models.Repository{ID: uuid.New(), OwnerID: alice.ID, OwnerType: models.OwnerTypeUser,
+			Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPublic}).Error)
+
+		progress, err := svc.Progress(ctx, models.OwnerTypeUser, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, progress.Completed)
+		assert.Equal(t, map[models.OnboardingStep]bool{
+			models.OnboardingStepAddSSHKey:        true,
+			models.OnboardingStepCreateRepository: true,
+			models.OnboardingStepInviteMember:     false,
+		}, steps(progress))
+		assert.True(t, now.Equal(*progress.Steps[0].CompletedAt))
+
+		require.NoError(t, db.Unscoped().Delete(key).Error)
+		progress, err = svc.Progress(ctx, models.OwnerTypeUser, alice.ID)
+		require.NoError(t, err)
+		assert.True(t, steps(progress)[models.OnboardingStepAddSSHKey])
+	})
+
+	t.Run("organization checklist", func(t *testing.T) {
+		require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: alice.ID, Role: models.OrgRoleOwner}).Error)
+		require.NoError(t, db.Create(&models.OrganizationInvitation{ID: uuid.New(), OrganizationID: org.ID, InviterID: alice.ID,
+			Email: "bob@example.com", Role: models.OrgRoleMember, Token: "t", ExpiresAt: now.Add(time.Hour)}).Error)
+
+		progress, err := svc.Progress(ctx, models.OwnerTypeOrganization, org.ID)
+		require.NoError(t, err)
+		assert.Equal(t, map[models.OnboardingStep]bool{
+			models.OnboardingStepCreateRepository: false,
+			models.OnboardingStepInviteMember:     true,
+			models.OnboardingStepCreateTeam:       false,
+		}, steps(progress))
+
+		// Inviting completes the step of the inviter too
+		progress, err = svc.Progress(ctx, models.OwnerTypeUser, alice.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, progress.Completed)
+	})
+
+	t.Run("dismiss", func(t *testing.T) {
+		require.NoError(t, svc.Dismiss(ctx, models.OwnerTypeUser, alice.ID))
+		progress, err := svc.Progress(ctx, models.OwnerTypeUser, alice.ID)
+		require.NoError(t, err)
+		require.NotNil(t, progress.DismissedAt)
+	})
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrVersionConflict is returned when an update names a version of a
+// repository, organization or team that is no longer current
+var ErrVersionConflict = errors.New("resource was modified by another request")
+
+// checkVersion fails with ErrVersionConflict when ifVersion is set and does
+// not match the current version
+func checkVersion(current int64, ifVersion *int64) error {
+	if ifVersion != nil && *ifVersion != current {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// updateVersioned applies updates to the rows selected by query and bumps
+// their version. With ifVersion set the write only happens while the row is
+// still at that version, so a concurrent update in between is reported as
+// ErrVersionConflict instead of being overwritten.
+func updateVersioned(query *gorm.DB, updates map[string]interface{}, ifVersion *int64) error {
+	if ifVersion != nil {
+		query = query.Where("version = ?", *ifVersion)
+	}
+	values := make(map[string]interface{}, len(updates)+1)
+	for column, value := range updates {
+		values[column] = value
+	}
+	values["version"] = gorm.Expr("version + 1")
+	result := query.Updates(values)
+	if result.Error != nil {
+		return result.Error
+	}
+	if ifVersion != nil && result.RowsAffected == 0 {
+		return ErrVersionConflict
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryService_UpdateVersionConflict(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.Repository{})
+	ctx := context.Background()
+	svc := NewRepositoryService(db, nil, logrus.New(), t.TempDir())
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "app", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	stored, err := svc.GetByID(ctx, repo.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, stored.Version)
+
+	// An automation and an admin both start editing version 1
+	version := stored.Version
+	first, second := "from automation", "from the UI"
+	updated, err := svc.Update(ctx, repo.ID, UpdateRepositoryRequest{Description: &first, IfVersion: &version})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, updated.Version)
+	assert.Equal(t, first, updated.Description)
+
+	_, err = svc.Update(ctx, repo.ID, UpdateRepositoryRequest{Description: &second, IfVersion: &version})
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	current, err := svc.GetByID(ctx, repo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first, current.Description)
+
+	// Without a version the update is unconditional
+	updated, err = svc.Update(ctx, repo.ID, UpdateRepositoryRequest{Description: &second})
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, updated.Version)
+}
+
+func TestTeamService_UpdateVersionConflict(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Organization{}, &models.Team{})
+	ctx := context.Background()
+	svc := NewTeamService(db, nil)
+
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	team := &models.Team{ID: uuid.New(), OrganizationID: org.ID, Name: "platform", Privacy: models.TeamPrivacyClosed}
+	require.NoError(t, db.Create(team).Error)
+
+	stale := int64(1)
+	description := "Platform engineering"
+	updated, err := svc.Update(ctx, "acme", "platform", UpdateTeamRequest{Description: &description, IfVersion: &stale})
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, updated.Version)
+
+	secret := models.TeamPrivacySecret
+	_, err = svc.Update(ctx, "acme", "platform", UpdateTeamRequest{Privacy: &secret, IfVersion: &stale})
+	assert.ErrorIs(t, err, ErrVersionConflict)
+}
+
+func TestOrganizationService_UpdateVersionConflictRefreshesTenant(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Organization{})
+	svc := NewOrganizationService(db, nil)
+
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	resolved := *org
+	resolved.Version = 1
+	ctx := tenant.NewContext(context.Background(), &tenant.Context{Organization: &resolved})
+
+	// Another writer changes the organization after the request resolved it
+	require.NoError(t, db.Model(&models.Organization{}).Where("id = ?", org.ID).Updates(map[string]interface{}{"display_name": "Acme Corp", "version": 2}).Error)
+
+	stale := int64(1)
+	name := "Acme Inc"
+	_, err := svc.Update(ctx, "acme", UpdateOrganizationRequest{DisplayName: &name, IfVersion: &stale})
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	current, err := svc.Get(ctx, "acme")
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, current.Version)
+	assert.Equal(t, "Acme Corp", current.DisplayName)
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/a5c-ai/hub/internal/models"
@@ -217,11 +218,12 @@ type UsageTrendData struct {
 
 // Service Implementation
 type organizationAnalyticsService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	security *securityOverviewService
 }
 
 func NewOrganizationAnalyticsService(db *gorm.DB) OrganizationAnalyticsService {
-	return &organizationAnalyticsService{db: db}
+	return &organizationAnalyticsService{db: db, security: newSecurityOverviewService(db)}
 }
 
 func (s *organizationAnalyticsService) GetDashboardMetrics(ctx context.Context, orgName string) (*DashboardMetrics, error) {
@@ -368,8 +370,21 @@ func (s *organizationAnalyticsService) GetSecurityMetrics(ctx context.Context, o
 		return nil, fmt.Errorf("organization not found: %w", err)
 	}
 
-	// Calculate security score based on various factors
-	securityScore := s.calculateSecurityScore(org.ID)
+	overview, err := s.security.overview(ctx, &org)
+	if err != nil {
+		return nil, err
+	}
+	vulnerabilitiesFound := 0
+	for _, counts := range overview.Alerts {
+		vulnerabilitiesFound += counts.Open
+	}
+	var vulnerabilitiesFixed int64
+	if err := s.db.Model(&models.CodeScanningAlert{}).
+		Where("state = ? AND repository_id IN (?)", models.CodeScanningAlertFixed, s.db.Model(&models.Repository{}).Select("id").
+			Where("owner_id = ? AND owner_type = ?", org.ID, models.OwnerTypeOrganization)).
+		Count(&vulnerabilitiesFixed).Error; err != nil {
+		return nil, err
+	}
 
 	securityAlerts, _ := s.getSecurityAlerts(org.ID, 50)
 	complianceStatus := s.getComplianceStatus(org.ID)
@@ -377,9 +392,9 @@ func (s *organizationAnalyticsService) GetSecurityMetrics(ctx context.Context, o
 
 	return &SecurityMetrics{
 		Period:               period,
-		SecurityScore:        securityScore,
-		VulnerabilitiesFound: 0, // Would integrate with security scanners
-		VulnerabilitiesFixed: 0, // Would integrate with security scanners
+		SecurityScore:        overview.Score,
+		VulnerabilitiesFound: vulnerabilitiesFound,
+		VulnerabilitiesFixed: int(vulnerabilitiesFixed),
 		SecurityAlerts:       convertSecurityAlertSlice(securityAlerts),
 		ComplianceStatus:     complianceStatus,
 		PolicyViolations:     policyViolations,
@@ -612,11 +627,42 @@ func (s *organizationAnalyticsService) getActiveMembers(orgID uuid.UUID, limit i
 }
 
 func (s *organizationAnalyticsService) getSecurityAlerts(orgID uuid.UUID, limit int) ([]*SecurityAlert, error) {
-	// Get security-related events and convert to alerts
+	// Open scanning alerts on default branches come first, newest first,
+	// followed by security events
+	var org models.Organization
+	if err := s.db.First(&org, "id = ?", orgID).Error; err != nil {
+		return nil, err
+	}
+	repos, err := s.security.repositories(context.Background(), &org, "", true)
+	if err != nil {
+		return nil, err
+	}
+	var alerts []*SecurityAlert
+	for _, repo := range repos {
+		for _, alert := range repo.OpenAlerts {
+			title := alert.RuleDescription
+			if title == "" {
+				title = alert.RuleID
+			}
+			alerts = append(alerts, &SecurityAlert{
+				Type:        securityAlertCategory(alert),
+				Severity:    string(alert.Severity),
+				Title:       title,
+				Description: alert.Message,
+				Repository:  repo.Name,
+				CreatedAt:   alert.CreatedAt,
+			})
+		}
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].CreatedAt.After(alerts[j].CreatedAt) })
+	if len(alerts) >= limit {
+		return alerts[:limit], nil
+	}
+
 	var events []models.AnalyticsEvent
-	err := s.db.Where("organization_id = ? AND event_type LIKE ?", orgID, "security.%").
+	err = s.db.Where("organization_id = ? AND event_type LIKE ?", orgID, "security.%").
 		Order("created_at DESC").
-		Limit(limit).
+		Limit(limit - len(alerts)).
 		Preload("Repository").
 		Find(&events).Error
 
@@ -624,7 +670,6 @@ func (s *organizationAnalyticsService) getSecurityAlerts(orgID uuid.UUID, limit
 		return nil, err
 	}
 
-	var alerts []*SecurityAlert
 	for _, event := range events {
 		severity := "medium"
 		if event.EventType == "security.access_denied" {
@@ -646,18 +691,6 @@ func (s *organizationAnalyticsService) getSecurityAlerts(orgID uuid.UUID, limit
 		})
 	}
 
-	// Add some placeholder alerts if no real ones exist
-	if len(alerts) == 0 {
-		alerts = append(alerts, &SecurityAlert{
-			Type:        "vulnerability",
-			Severity:    "low",
-			Title:       "No recent security alerts",
-			Description: "Your organization has no recent security alerts",
-			Repository:  "",
-			CreatedAt:   time.Now(),
-		})
-	}
-
 	return alerts, nil
 }
 
@@ -756,9 +789,18 @@ func (s *organizationAnalyticsService) generateTeamSizeData(orgID uuid.UUID) []T
 	return []TeamSizeData{}
 }
 
+// calculateSecurityScore returns the score of the security overview, or 0
+// when it cannot be computed
 func (s *organizationAnalyticsService) calculateSecurityScore(orgID uuid.UUID) float64 {
-	// Calculate based on various security factors
-	return 85.5
+	var org models.Organization
+	if err := s.db.First(&org, "id = ?", orgID).Error; err != nil {
+		return 0
+	}
+	overview, err := s.security.overview(context.Background(), &org)
+	if err != nil {
+		return 0
+	}
+	return overview.Score
 }
 
 func (s *organizationAnalyticsService) getComplianceStatus(orgID uuid.UUID) map[string]bool {
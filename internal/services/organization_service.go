@@ -4,10 +4,13 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/a5c-ai/hub/internal/audit"
 	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/tenant"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
@@ -32,6 +35,10 @@ type UpdateOrganizationRequest struct {
 	Location     *string `json:"location,omitempty"`
 	Email        *string `json:"email,omitempty"`
 	BillingEmail *string `json:"billing_email,omitempty"`
+
+	// IfVersion, taken from the If-Match header, rejects the update with
+	// ErrVersionConflict unless it matches the current version
+	IfVersion *int64 `json:"-"`
 }
 
 type OrganizationFilters struct {
@@ -137,6 +144,11 @@ func (s *organizationService) Create(ctx context.Context, req CreateOrganization
 }
 
 func (s *organizationService) Get(ctx context.Context, name string) (*models.Organization, error) {
+	if t, ok := tenant.FromContext(ctx); ok && t.MatchesOrganization(name) {
+		org := *t.Organization
+		return &org, nil
+	}
+
 	var org models.Organization
 	if err := s.db.Where("name = ?", name).First(&org).Error; err != nil {
 		return nil, fmt.Errorf("organization not found: %w", err)
@@ -149,6 +161,10 @@ func (s *organizationService) Update(ctx context.Context, name string, req Updat
 	if err := s.db.Where("name = ?", name).First(&org).Error; err != nil {
 		return nil, fmt.Errorf("organization not found: %w", err)
 	}
+	if err := checkVersion(org.Version, req.IfVersion); err != nil {
+		s.refreshTenantOrganization(ctx, name)
+		return nil, err
+	}
 
 	updates := make(map[string]interface{})
 	if req.DisplayName != nil {
@@ -173,21 +189,40 @@ func (s *organizationService) Update(ctx context.Context, name string, req Updat
 		updates["billing_email"] = *req.BillingEmail
 	}
 
-	if err := s.db.Model(&models.Organization{}).Where("name = ?", name).Updates(updates).Error; err != nil {
+	if len(updates) == 0 {
+		return &org, nil
+	}
+	if err := updateVersioned(s.db.Model(&models.Organization{}).Where("name = ?", name), updates, req.IfVersion); err != nil {
+		if errors.Is(err, ErrVersionConflict) {
+			s.refreshTenantOrganization(ctx, name)
+			return nil, err
+		}
 		return nil, fmt.Errorf("failed to update organization: %w", err)
 	}
-
-	// Reflect updates in returned struct
-	if req.DisplayName != nil {
-		org.DisplayName = *req.DisplayName
+	if err := s.db.Where("name = ?", name).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("failed to reload organization: %w", err)
 	}
-	if req.Description != nil {
-		org.Description = *req.Description
+	if t, ok := tenant.FromContext(ctx); ok {
+		t.RefreshOrganization(&org)
 	}
 
 	return &org, nil
 }
 
+// refreshTenantOrganization reloads the organization into the request's
+// tenant context after a conflicting update, so a following Get reports the
+// stored state rather than the copy resolved at the start of the request
+func (s *organizationService) refreshTenantOrganization(ctx context.Context, name string) {
+	t, ok := tenant.FromContext(ctx)
+	if !ok {
+		return
+	}
+	var org models.Organization
+	if err := s.db.Where("name = ?", name).First(&org).Error; err == nil {
+		t.RefreshOrganization(&org)
+	}
+}
+
 func (s *organizationService) Delete(ctx context.Context, name string) error {
 	if err := s.db.Where("name = ?", name).Delete(&models.Organization{}).Error; err != nil {
 		return fmt.Errorf("failed to delete organization: %w", err)
@@ -244,6 +279,9 @@ func (s *membershipService) AddMember(ctx context.Context, orgName, username str
 	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
 		return nil, fmt.Errorf("user not found: %w", err)
 	}
+	if user.IsExternal {
+		return nil, ErrExternalCollaboratorRestricted
+	}
 
 	member := &models.OrganizationMember{
 		OrganizationID: org.ID,
@@ -259,6 +297,15 @@ func (s *membershipService) AddMember(ctx context.Context, orgName, username str
 	// Load relationships
 	s.db.Preload("Organization").Preload("User").First(member, member.ID)
 
+	audit.Record(ctx, audit.Event{
+		Action:         audit.ActionMemberAdd,
+		OrganizationID: &org.ID,
+		TargetType:     "user",
+		TargetID:       user.ID.String(),
+		TargetName:     user.Username,
+		Metadata:       map[string]interface{}{"role": role},
+	})
+
 	// Log activity
 	if s.as != nil {
 		go func() {
@@ -286,6 +333,14 @@ func (s *membershipService) RemoveMember(ctx context.Context, orgName, username
 		return fmt.Errorf("failed to remove member: %w", err)
 	}
 
+	audit.Record(ctx, audit.Event{
+		Action:         audit.ActionMemberRemove,
+		OrganizationID: &org.ID,
+		TargetType:     "user",
+		TargetID:       user.ID.String(),
+		TargetName:     user.Username,
+	})
+
 	// Log activity
 	if s.as != nil {
 		go func() {
@@ -322,6 +377,15 @@ func (s *membershipService) UpdateMemberRole(ctx context.Context, orgName, usern
 	// Load relationships
 	s.db.Preload("Organization").Preload("User").First(&member, member.ID)
 
+	audit.Record(ctx, audit.Event{
+		Action:         audit.ActionMemberRoleChange,
+		OrganizationID: &org.ID,
+		TargetType:     "user",
+		TargetID:       user.ID.String(),
+		TargetName:     user.Username,
+		Metadata:       map[string]interface{}{"old_role": oldRole, "new_role": role},
+	})
+
 	// Log activity
 	if s.as != nil {
 		go func() {
@@ -341,7 +405,9 @@ func (s *membershipService) GetMembers(ctx context.Context, orgName string, filt
 		return nil, fmt.Errorf("organization not found: %w", err)
 	}
 
-	query := s.db.Where("organization_id = ?", org.ID).Preload("User")
+	// External collaborators are never listed as members
+	query := s.db.Where("organization_id = ?", org.ID).Preload("User").
+		Where("user_id IN (?)", s.db.Model(&models.User{}).Select("id").Where("is_external = ?", false))
 
 	if filters.Role != "" {
 		query = query.Where("role = ?", filters.Role)
@@ -470,6 +536,13 @@ func (s *invitationService) AcceptInvitation(ctx context.Context, token string,
 		Preload("Organization").First(&invitation).Error; err != nil {
 		return fmt.Errorf("invitation not found or expired: %w", err)
 	}
+	external, err := isExternalUser(s.db.WithContext(ctx), userID)
+	if err != nil {
+		return err
+	}
+	if external {
+		return ErrExternalCollaboratorRestricted
+	}
 
 	return s.db.Transaction(func(tx *gorm.DB) error {
 		// Mark invitation as accepted
@@ -68,7 +68,8 @@ func setupOrgTestDB(t *testing.T) *gorm.DB {
 			website TEXT,
 			location TEXT,
 			email TEXT,
-			billing_email TEXT
+			billing_email TEXT,
+			version INTEGER NOT NULL DEFAULT 1
 		);
 		
 		CREATE TABLE organization_members (
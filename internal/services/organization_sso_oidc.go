@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// oidcProviderCacheTTL bounds how long discovery documents and signing keys
+// are reused before they are fetched again
+const oidcProviderCacheTTL = time.Hour
+
+// oidcMetadata is the subset of OpenID provider discovery SSO logins use
+type oidcMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcProvider struct {
+	metadata  oidcMetadata
+	keys      map[string]crypto.PublicKey // by key ID
+	fetchedAt time.Time
+}
+
+// oidcClient talks to the OpenID providers organizations sign in with
+type oidcClient struct {
+	http      *http.Client
+	mu        sync.Mutex
+	providers map[string]*oidcProvider // by issuer URL
+}
+
+func newOIDCClient() *oidcClient {
+	return &oidcClient{http: &http.Client{Timeout: 15 * time.Second}, providers: map[string]*oidcProvider{}}
+}
+
+func (c *oidcClient) getJSON(ctx context.Context, target string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", target, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+// provider returns the discovery document and signing keys of issuer,
+// fetching them when they are not cached, stale, or refresh is set
+func (c *oidcClient) provider(ctx context.Context, issuer string, refresh bool) (*oidcProvider, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	c.mu.Lock()
+	cached := c.providers[issuer]
+	c.mu.Unlock()
+	if cached != nil && !refresh && time.Since(cached.fetchedAt) < oidcProviderCacheTTL {
+		return cached, nil
+	}
+
+	var metadata oidcMetadata
+	if err := c.getJSON(ctx, issuer+"/.well-known/openid-configuration", &metadata); err != nil {
+		return nil, fmt.Errorf("OpenID discovery failed: %w", err)
+	}
+	if strings.TrimSuffix(metadata.Issuer, "/") != issuer {
+		return nil, fmt.Errorf("OpenID provider reports issuer %q", metadata.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("OpenID discovery document is incomplete")
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := c.getJSON(ctx, metadata.JWKSURI, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to fetch OpenID signing keys: %w", err)
+	}
+	provider := &oidcProvider{metadata: metadata, keys: map[string]crypto.PublicKey{}, fetchedAt: time.Now()}
+	for _, key := range jwks.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		switch key.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(key.N)
+			e, errE := base64.RawURLEncoding.DecodeString(key.E)
+			if errN != nil || errE != nil {
+				continue
+			}
+			provider.keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			var curve elliptic.Curve
+			switch key.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				continue
+			}
+			x, errX := base64.RawURLEncoding.DecodeString(key.X)
+			y, errY := base64.RawURLEncoding.DecodeString(key.Y)
+			if errX != nil || errY != nil {
+				continue
+			}
+			provider.keys[key.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+
+	c.mu.Lock()
+	c.providers[issuer] = provider
+	c.mu.Unlock()
+	return provider, nil
+}
+
+// pkceChallenge derives the S256 code challenge of verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// authURL builds the authorization request that starts a login
+func (c *oidcClient) authURL(ctx context.Context, cfg *models.OrganizationSSOConfig, redirectURI, state, nonce, verifier string) (string, error) {
+	provider, err := c.provider(ctx, cfg.IssuerURL, false)
+	if err != nil {
+		return "", err
+	}
+	target, err := url.Parse(provider.metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid authorization endpoint: %w", err)
+	}
+	query := target.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", cfg.ClientID)
+	query.Set("redirect_uri", redirectURI)
+	query.Set("scope", strings.Join(cfg.Scopes, " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", pkceChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+	target.RawQuery = query.Encode()
+	return target.String(), nil
+}
+
+// exchange redeems an authorization code and returns the verified ID token
+// claims
+func (c *oidcClient) exchange(ctx context.Context, cfg *models.OrganizationSSOConfig, redirectURI, code, verifier, nonce string) (jwt.MapClaims, error) {
+	provider, err := c.provider(ctx, cfg.IssuerURL, false)
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {cfg.ClientID},
+		"client_secret": {cfg.ClientSecret},
+		"code_verifier": {verifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, provider.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&tokens); err != nil {
+		return nil, fmt.Errorf("invalid token response: %w", err)
+	}
+	if tokens.IDToken == "" {
+		return nil, errors.New("token response has no ID token")
+	}
+	return c.verifyIDToken(ctx, cfg, tokens.IDToken, nonce)
+}
+
+// verifyIDToken checks the signature, issuer, audience, expiry and nonce of
+// an ID token. Unknown key IDs refresh the provider's keys once, so key
+// rotation at the provider does not break logins.
+func (c *oidcClient) verifyIDToken(ctx context.Context, cfg *models.OrganizationSSOConfig, raw, nonce string) (jwt.MapClaims, error) {
+	issuer := strings.TrimSuffix(cfg.IssuerURL, "/")
+	provider, err := c.provider(ctx, issuer, false)
+	if err != nil {
+		return nil, err
+	}
+	refreshed := false
+	keyFunc := func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := provider.keys[kid]
+		if !ok && !refreshed {
+			refreshed = true
+			if provider, err = c.provider(ctx, issuer, true); err != nil {
+				return nil, err
+			}
+			key, ok = provider.keys[kid]
+		}
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key, nil
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, keyFunc,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}),
+		jwt.WithIssuer(provider.metadata.Issuer),
+		jwt.WithAudience(cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID token: %w", err)
+	}
+	if claimed, _ := claims["nonce"].(string); claimed == "" || claimed != nonce {
+		return nil, errors.New("ID token nonce does not match the login")
+	}
+	return claims, nil
+}
+
+// claimStrings reads a claim that holds a string or a list of strings
+func claimStrings(claims map[string]interface{}, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,737 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/auth"
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSSONotConfigured = errors.New("single sign-on is not configured for this organization")
+	ErrSSOForbidden     = errors.New("only organization owners and admins can manage single sign-on")
+	ErrInvalidSSOConfig = errors.New("invalid single sign-on configuration")
+	ErrSSOLoginFailed   = errors.New("single sign-on failed")
+	ErrSSORequired      = errors.New("organization requires single sign-on")
+)
+
+const (
+	ssoLoginTTL            = 10 * time.Minute
+	defaultSSOSessionHours = 24
+	maxSSOSessionHours     = 720
+)
+
+var ssoUsernameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// SSOLoginResult is a completed organization SSO login. Tokens are only
+// issued to users SSO provisioned; existing accounts keep signing in as
+// before and gain an SSO session for the organization.
+type SSOLoginResult struct {
+	User             *models.User `json:"user"`
+	AccessToken      string       `json:"access_token,omitempty"`
+	RefreshToken     string       `json:"refresh_token,omitempty"`
+	ExpiresIn        int64        `json:"expires_in,omitempty"`
+	Provisioned      bool         `json:"provisioned"`
+	SessionExpiresAt time.Time    `json:"sso_session_expires_at"`
+}
+
+// ssoExternalUser is a user as the identity provider asserts them
+type ssoExternalUser struct {
+	ID       string
+	Email    string
+	Username string
+	Name     string
+	Groups   []string
+}
+
+// OrganizationSSOService connects organizations to SAML 2.0 and OpenID
+// Connect identity providers. Members who sign in are linked to their
+// identity, teams follow the mapped identity provider groups, and
+// enforcing organizations refuse members without an active SSO session.
+type OrganizationSSOService interface {
+	GetConfig(ctx context.Context, orgName string, actorID uuid.UUID) (*models.OrganizationSSOConfig, error)
+	// SetConfig creates or replaces the SSO configuration; an empty client
+	// secret keeps the stored one
+	SetConfig(ctx context.Context, orgName string, actorID uuid.UUID, cfg *models.OrganizationSSOConfig) (*models.OrganizationSSOConfig, error)
+	DeleteConfig(ctx context.Context, orgName string, actorID uuid.UUID) error
+	ListIdentities(ctx context.Context, orgName string, actorID uuid.UUID) ([]models.OrganizationSSOIdentity, error)
+
+	// BeginLogin returns the identity provider URL that starts a login.
+	// The identity is linked to userID when the login is started signed in.
+	BeginLogin(ctx context.Context, orgName string, userID *uuid.UUID) (string, error)
+	CompleteOIDC(ctx context.Context, orgName, state, code string) (*SSOLoginResult, error)
+	CompleteSAML(ctx context.Context, orgName, samlResponse, relayState string) (*SSOLoginResult, error)
+	// Metadata returns the SAML service provider metadata of an organization
+	Metadata(ctx context.Context, orgName string) (string, error)
+
+	// Authorize returns ErrSSORequired when the organization enforces SSO
+	// and userID is a member without an active SSO session
+	Authorize(ctx context.Context, orgID, userID uuid.UUID) error
+}
+
+type organizationSSOService struct {
+	db             *gorm.DB
+	jwtManager     *auth.JWTManager
+	sessionService *auth.SessionService
+	jwtConfig      config.JWT
+	baseURL        string
+	oidc           *oidcClient
+	logger         *logrus.Logger
+}
+
+// NewOrganizationSSOService creates a new OrganizationSSOService. baseURL is
+// the external URL of the hub that identity providers redirect back to.
+func NewOrganizationSSOService(db *gorm.DB, jwtManager *auth.JWTManager, sessionService *auth.SessionService, jwtConfig config.JWT, baseURL string, logger *logrus.Logger) OrganizationSSOService {
+	return &organizationSSOService{
+		db:             db,
+		jwtManager:     jwtManager,
+		sessionService: sessionService,
+		jwtConfig:      jwtConfig,
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		oidc:           newOIDCClient(),
+		logger:         logger,
+	}
+}
+
+func (s *organizationSSOService) getOrganization(ctx context.Context, orgName string) (*models.Organization, error) {
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("organization not found: %w", err)
+	}
+	return &org, nil
+}
+
+// manageableOrganization returns the organization when actorID is one of
+// its owners or admins
+func (s *organizationSSOService) manageableOrganization(ctx context.Context, orgName string, actorID uuid.UUID) (*models.Organization, error) {
+	org, err := s.getOrganization(ctx, orgName)
+	if err != nil {
+		return nil, err
+	}
+	var member models.OrganizationMember
+	err = s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", org.ID, actorID).First(&member).Error
+	if err != nil || (member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin) {
+		return nil, ErrSSOForbidden
+	}
+	return org, nil
+}
+
+func (s *organizationSSOService) loadConfig(ctx context.Context, orgID uuid.UUID) (*models.OrganizationSSOConfig, error) {
+	var cfg models.OrganizationSSOConfig
+	err := s.db.WithContext(ctx).Where("organization_id = ?", orgID).First(&cfg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSSONotConfigured
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SSO configuration: %w", err)
+	}
+	return &cfg, nil
+}
+
+// ssoURL returns the URL of an organization's SSO endpoint
+func (s *organizationSSOService) ssoURL(org *models.Organization, path string) string {
+	return s.baseURL + "/api/v1/organizations/" + url.PathEscape(org.Name) + "/sso/" + path
+}
+
+func (s *organizationSSOService) GetConfig(ctx context.Context, orgName string, actorID uuid.UUID) (*models.OrganizationSSOConfig, error) {
+	org, err := s.manageableOrganization(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadConfig(ctx, org.ID)
+}
+
+// validateSSOConfig checks cfg and fills in defaults
+func (s *organizationSSOService) validateSSOConfig(ctx context.Context, orgID uuid.UUID, cfg *models.OrganizationSSOConfig) error {
+	absolute := func(field, value string) error {
+		u, err := url.Parse(value)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return fmt.Errorf("%w: %s must be an absolute URL", ErrInvalidSSOConfig, field)
+		}
+		return nil
+	}
+
+	switch cfg.Protocol {
+	case models.SSOProtocolOIDC:
+		if err := absolute("issuer_url", cfg.IssuerURL); err != nil {
+			return err
+		}
+		cfg.IssuerURL = strings.TrimSuffix(cfg.IssuerURL, "/")
+		if cfg.ClientID == "" || cfg.ClientSecret == "" {
+			return fmt.Errorf("%w: client_id and client_secret are required", ErrInvalidSSOConfig)
+		}
+		if len(cfg.Scopes) == 0 {
+			cfg.Scopes = []string{"openid", "email", "profile"}
+		}
+		hasOpenID := false
+		for _, scope := range cfg.Scopes {
+			hasOpenID = hasOpenID || scope == "openid"
+		}
+		if !hasOpenID {
+			cfg.Scopes = append([]string{"openid"}, cfg.Scopes...)
+		}
+	case models.SSOProtocolSAML:
+		if cfg.IdPEntityID == "" {
+			return fmt.Errorf("%w: idp_entity_id is required", ErrInvalidSSOConfig)
+		}
+		if err := absolute("idp_sso_url", cfg.IdPSSOURL); err != nil {
+			return err
+		}
+		if _, err := auth.ParseSAMLCertificates(cfg.IdPCertificate); err != nil {
+			return fmt.Errorf("%w: idp_certificate: %v", ErrInvalidSSOConfig, err)
+		}
+	default:
+		return fmt.Errorf("%w: protocol must be %q or %q", ErrInvalidSSOConfig, models.SSOProtocolSAML, models.SSOProtocolOIDC)
+	}
+
+	if cfg.GroupsAttribute == "" {
+		cfg.GroupsAttribute = "groups"
+	}
+	switch cfg.DefaultRole {
+	case "":
+		cfg.DefaultRole = models.OrgRoleMember
+	case models.OrgRoleMember, models.OrgRoleAdmin:
+	default:
+		return fmt.Errorf("%w: default_role must be member or admin", ErrInvalidSSOConfig)
+	}
+	if cfg.SessionHours == 0 {
+		cfg.SessionHours = defaultSSOSessionHours
+	}
+	if cfg.SessionHours < 1 || cfg.SessionHours > maxSSOSessionHours {
+		return fmt.Errorf("%w: session_hours must be between 1 and %d", ErrInvalidSSOConfig, maxSSOSessionHours)
+	}
+	if cfg.Enforced && !cfg.Enabled {
+		return fmt.Errorf("%w: SSO must be enabled to be enforced", ErrInvalidSSOConfig)
+	}
+
+	for i := range cfg.TeamMappings {
+		mapping := &cfg.TeamMappings[i]
+		if strings.TrimSpace(mapping.Group) == "" {
+			return fmt.Errorf("%w: team mappings need a group", ErrInvalidSSOConfig)
+		}
+		switch mapping.Role {
+		case "":
+			mapping.Role = models.TeamRoleMember
+		case models.TeamRoleMember, models.TeamRoleMaintainer:
+		default:
+			return fmt.Errorf("%w: team mapping role must be member or maintainer", ErrInvalidSSOConfig)
+		}
+		var count int64
+		if err := s.db.WithContext(ctx).Model(&models.Team{}).Where("id = ? AND organization_id = ?", mapping.TeamID, orgID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: team %s is not a team of this organization", ErrInvalidSSOConfig, mapping.TeamID)
+		}
+	}
+	return nil
+}
+
+func (s *organizationSSOService) SetConfig(ctx context.Context, orgName string, actorID uuid.UUID, cfg *models.OrganizationSSOConfig) (*models.OrganizationSSOConfig, error) {
+	org, err := s.manageableOrganization(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.loadConfig(ctx, org.ID)
+	if err != nil && !errors.Is(err, ErrSSONotConfigured) {
+		return nil, err
+	}
+	if existing != nil {
+		cfg.ID = existing.ID
+		cfg.CreatedAt = existing.CreatedAt
+		if cfg.ClientSecret == "" {
+			cfg.ClientSecret = existing.ClientSecret
+		}
+	} else {
+		cfg.ID = uuid.New()
+	}
+	cfg.OrganizationID = org.ID
+	cfg.UpdatedByID = &actorID
+	if err := s.validateSSOConfig(ctx, org.ID, cfg); err != nil {
+		return nil, err
+	}
+
+	// Enforcing SSO without a session of one's own would lock the actor out
+	if cfg.Enforced && (existing == nil || !existing.Enforced) {
+		active, err := s.hasActiveSession(ctx, org.ID, actorID)
+		if err != nil {
+			return nil, err
+		}
+		if !active {
+			return nil, fmt.Errorf("%w: sign in with SSO before enforcing it", ErrInvalidSSOConfig)
+		}
+	}
+
+	save := s.db.WithContext(ctx).Save
+	if existing == nil {
+		save = s.db.WithContext(ctx).Create
+	}
+	if err := save(cfg).Error; err != nil {
+		return nil, fmt.Errorf("failed to save SSO configuration: %w", err)
+	}
+	s.logger.WithFields(logrus.Fields{"organization": org.Name, "protocol": cfg.Protocol, "enforced": cfg.Enforced}).Info("Organization SSO configured")
+	return cfg, nil
+}
+
+func (s *organizationSSOService) DeleteConfig(ctx context.Context, orgName string, actorID uuid.UUID) error {
+	org, err := s.manageableOrganization(ctx, orgName, actorID)
+	if err != nil {
+		return err
+	}
+	result := s.db.WithContext(ctx).Where("organization_id = ?", org.ID).Delete(&models.OrganizationSSOConfig{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete SSO configuration: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSSONotConfigured
+	}
+	return nil
+}
+
+func (s *organizationSSOService) ListIdentities(ctx context.Context, orgName string, actorID uuid.UUID) ([]models.OrganizationSSOIdentity, error) {
+	org, err := s.manageableOrganization(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+	var identities []models.OrganizationSSOIdentity
+	err = s.db.WithContext(ctx).Preload("User").Where("organization_id = ?", org.ID).Order("created_at").Find(&identities).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SSO identities: %w", err)
+	}
+	return identities, nil
+}
+
+// enabledConfig returns the organization and its SSO configuration when SSO
+// is enabled
+func (s *organizationSSOService) enabledConfig(ctx context.Context, orgName string) (*models.Organization, *models.OrganizationSSOConfig, error) {
+	org, err := s.getOrganization(ctx, orgName)
+	if err != nil {
+		return nil, nil, err
+	}
+	cfg, err := s.loadConfig(ctx, org.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cfg.Enabled {
+		return nil, nil, ErrSSONotConfigured
+	}
+	return org, cfg, nil
+}
+
+func randomSSOValue() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *organizationSSOService) BeginLogin(ctx context.Context, orgName string, userID *uuid.UUID) (string, error) {
+	org, cfg, err := s.enabledConfig(ctx, orgName)
+	if err != nil {
+		return "", err
+	}
+	login := &models.SSOLoginState{ID: uuid.New(), OrganizationID: org.ID, UserID: userID, ExpiresAt: time.Now().Add(ssoLoginTTL)}
+	if login.State, err = randomSSOValue(); err != nil {
+		return "", err
+	}
+
+	var redirect string
+	switch cfg.Protocol {
+	case models.SSOProtocolOIDC:
+		if login.Nonce, err = randomSSOValue(); err != nil {
+			return "", err
+		}
+		if login.CodeVerifier, err = randomSSOValue(); err != nil {
+			return "", err
+		}
+		redirect, err = s.oidc.authURL(ctx, cfg, s.ssoURL(org, "oidc/callback"), login.State, login.Nonce, login.CodeVerifier)
+	case models.SSOProtocolSAML:
+		var id string
+		if id, err = randomSSOValue(); err != nil {
+			return "", err
+		}
+		login.RequestID = "_" + id[:40]
+		redirect, err = auth.SAMLRedirectURL(cfg.IdPSSOURL, login.RequestID, s.ssoURL(org, "saml/metadata"), s.ssoURL(org, "saml/acs"), login.State)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrSSOLoginFailed, err)
+	}
+
+	// Expired logins are dropped as new ones start
+	s.db.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&models.SSOLoginState{})
+	if err := s.db.WithContext(ctx).Create(login).Error; err != nil {
+		return "", fmt.Errorf("failed to start SSO login: %w", err)
+	}
+	return redirect, nil
+}
+
+// consumeLogin claims a pending login so its state cannot be replayed
+func (s *organizationSSOService) consumeLogin(ctx context.Context, orgID uuid.UUID, state string) (*models.SSOLoginState, error) {
+	if state == "" {
+		return nil, fmt.Errorf("%w: missing state", ErrSSOLoginFailed)
+	}
+	result := s.db.WithContext(ctx).Model(&models.SSOLoginState{}).
+		Where("state = ? AND organization_id = ? AND used = ? AND expires_at > ?", state, orgID, false, time.Now()).
+		Update("used", true)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: login expired or was already used", ErrSSOLoginFailed)
+	}
+	var login models.SSOLoginState
+	if err := s.db.WithContext(ctx).Where("state = ?", state).First(&login).Error; err != nil {
+		return nil, err
+	}
+	return &login, nil
+}
+
+func (s *organizationSSOService) CompleteOIDC(ctx context.Context, orgName, state, code string) (*SSOLoginResult, error) {
+	org, cfg, err := s.enabledConfig(ctx, orgName)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Protocol != models.SSOProtocolOIDC {
+		return nil, fmt.Errorf("%w: organization does not use OpenID Connect", ErrSSOLoginFailed)
+	}
+	login, err := s.consumeLogin(ctx, org.ID, state)
+	if err != nil {
+		return nil, err
+	}
+	if code == "" {
+		return nil, fmt.Errorf("%w: missing authorization code", ErrSSOLoginFailed)
+	}
+	claims, err := s.oidc.exchange(ctx, cfg, s.ssoURL(org, "oidc/callback"), code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSSOLoginFailed, err)
+	}
+
+	external := ssoExternalUser{Groups: claimStrings(claims, cfg.GroupsAttribute)}
+	external.ID, _ = claims["sub"].(string)
+	external.Name, _ = claims["name"].(string)
+	external.Username, _ = claims["preferred_username"].(string)
+	if verified, ok := claims["email_verified"].(bool); !ok || verified {
+		external.Email, _ = claims["email"].(string)
+	}
+	return s.completeLogin(ctx, org, cfg, login, external)
+}
+
+func (s *organizationSSOService) CompleteSAML(ctx context.Context, orgName, samlResponse, relayState string) (*SSOLoginResult, error) {
+	org, cfg, err := s.enabledConfig(ctx, orgName)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Protocol != models.SSOProtocolSAML {
+		return nil, fmt.Errorf("%w: organization does not use SAML", ErrSSOLoginFailed)
+	}
+	login, err := s.consumeLogin(ctx, org.ID, relayState)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := auth.ParseSAMLCertificates(cfg.IdPCertificate)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSSOLoginFailed, err)
+	}
+	info, attributes, err := auth.VerifySAMLResponse(samlResponse, auth.SAMLValidation{
+		IdPEntityID:  cfg.IdPEntityID,
+		Certificates: certs,
+		Audience:     s.ssoURL(org, "saml/metadata"),
+		Recipient:    s.ssoURL(org, "saml/acs"),
+		RequestID:    login.RequestID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSSOLoginFailed, err)
+	}
+	return s.completeLogin(ctx, org, cfg, login, ssoExternalUser{
+		ID:       info.ID,
+		Email:    info.Email,
+		Username: info.Username,
+		Name:     info.Name,
+		Groups:   attributes[cfg.GroupsAttribute],
+	})
+}
+
+// completeLogin links the external identity to a user, provisioning one
+// when allowed, starts the SSO session and syncs membership
+func (s *organizationSSOService) completeLogin(ctx context.Context, org *models.Organization, cfg *models.OrganizationSSOConfig, login *models.SSOLoginState, external ssoExternalUser) (*SSOLoginResult, error) {
+	if external.ID == "" {
+		return nil, fmt.Errorf("%w: identity provider did not identify the user", ErrSSOLoginFailed)
+	}
+	now := time.Now()
+	expires := now.Add(time.Duration(cfg.SessionHours) * time.Hour)
+
+	var user models.User
+	var identity models.OrganizationSSOIdentity
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("organization_id = ? AND external_id = ?", org.ID, external.ID).First(&identity).Error
+		switch {
+		case err == nil:
+			if login.UserID != nil && *login.UserID != identity.UserID {
+				return fmt.Errorf("%w: this identity is linked to another account", ErrSSOLoginFailed)
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		case login.UserID != nil:
+			var count int64
+			if err := tx.Model(&models.OrganizationSSOIdentity{}).Where("organization_id = ? AND user_id = ?", org.ID, *login.UserID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("%w: your account is linked to another identity", ErrSSOLoginFailed)
+			}
+			identity = models.OrganizationSSOIdentity{ID: uuid.New(), OrganizationID: org.ID, UserID: *login.UserID, ExternalID: external.ID}
+		default:
+			// Accounts provisioned over SCIM are linked by their email
+			var scimUser models.SCIMUser
+			err := tx.Joins("JOIN users ON users.id = scim_users.user_id").
+				Where("scim_users.organization_id = ? AND LOWER(users.email) = ?", org.ID, strings.ToLower(external.Email)).
+				First(&scimUser).Error
+			switch {
+			case err == nil && external.Email != "":
+				identity = models.OrganizationSSOIdentity{ID: uuid.New(), OrganizationID: org.ID, UserID: scimUser.UserID, ExternalID: external.ID, Provisioned: scimUser.Provisioned}
+			case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+				return err
+			case cfg.JITProvisioning:
+				provisioned, err := s.provisionUser(tx, external)
+				if err != nil {
+					return err
+				}
+				identity = models.OrganizationSSOIdentity{ID: uuid.New(), OrganizationID: org.ID, UserID: provisioned.ID, ExternalID: external.ID, Provisioned: true}
+			default:
+				return fmt.Errorf("%w: no account is linked to this identity; sign in and start SSO to link it", ErrSSOLoginFailed)
+			}
+		}
+
+		if err := tx.First(&user, "id = ?", identity.UserID).Error; err != nil {
+			return err
+		}
+		if !user.IsActive {
+			return fmt.Errorf("%w: account is disabled", ErrSSOLoginFailed)
+		}
+		var deprovisioned int64
+		err = tx.Unscoped().Model(&models.SCIMUser{}).
+			Where("organization_id = ? AND user_id = ? AND (active = ? OR deleted_at IS NOT NULL)", org.ID, user.ID, false).
+			Count(&deprovisioned).Error
+		if err != nil {
+			return err
+		}
+		if deprovisioned > 0 {
+			return fmt.Errorf("%w: account was deprovisioned by the identity provider", ErrSSOLoginFailed)
+		}
+		identity.Email = external.Email
+		identity.Groups = external.Groups
+		identity.LastAuthenticatedAt = &now
+		identity.SessionExpiresAt = &expires
+		if err := tx.Save(&identity).Error; err != nil {
+			return err
+		}
+		return s.syncMembership(tx, org, cfg, user.ID, external.Groups)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SSOLoginResult{User: &user, Provisioned: identity.Provisioned, SessionExpiresAt: expires}
+	if identity.Provisioned {
+		if result.AccessToken, err = s.jwtManager.GenerateToken(&user); err != nil {
+			return nil, fmt.Errorf("failed to generate access token: %w", err)
+		}
+		session, err := s.sessionService.CreateSession(user.ID, "", "", false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create session: %w", err)
+		}
+		result.RefreshToken = session.RefreshToken
+		result.ExpiresIn = int64(time.Duration(s.jwtConfig.ExpirationHour) * time.Hour / time.Second)
+		s.db.WithContext(ctx).Model(&user).Update("last_login_at", now)
+	}
+	user.PasswordHash = ""
+	s.logger.WithFields(logrus.Fields{"organization": org.Name, "user": user.Username, "provisioned": identity.Provisioned}).Info("Organization SSO login")
+	return result, nil
+}
+
+// provisionUser creates the account of an identity signing in for the first
+// time. Emails of existing accounts are refused, so an identity provider
+// cannot take over an account by asserting its email.
+func (s *organizationSSOService) provisionUser(tx *gorm.DB, external ssoExternalUser) (*models.User, error) {
+	if external.Email == "" {
+		return nil, fmt.Errorf("%w: identity provider did not assert a verified email", ErrSSOLoginFailed)
+	}
+	var count int64
+	if err := tx.Model(&models.User{}).Where("LOWER(email) = ?", strings.ToLower(external.Email)).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, fmt.Errorf("%w: an account with this email exists; sign in and start SSO to link it", ErrSSOLoginFailed)
+	}
+
+	username, err := provisionedUsername(tx, external.Username, external.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		ID:            uuid.New(),
+		Username:      username,
+		Email:         external.Email,
+		FullName:      external.Name,
+		EmailVerified: true,
+		IsActive:      true,
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return nil, fmt.Errorf("failed to provision user: %w", err)
+	}
+	return user, nil
+}
+
+// provisionedUsername derives a free username for an account created by an
+// identity provider from its preferred name or the email's local part
+func provisionedUsername(tx *gorm.DB, preferred, email string) (string, error) {
+	base := preferred
+	if base == "" || strings.Contains(base, "@") {
+		base = strings.SplitN(email, "@", 2)[0]
+	}
+	base = strings.Trim(ssoUsernameInvalid.ReplaceAllString(base, "-"), "-")
+	if base == "" {
+		base = "user"
+	}
+	username := base
+	for i := 1; ; i++ {
+		var count int64
+		if err := tx.Model(&models.User{}).Where("username = ?", username).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return username, nil
+		}
+		username = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// syncMembership adds the user to the organization when provisioning is on
+// and makes the mapped teams follow the user's identity provider groups.
+// Teams without a mapping are left alone.
+func (s *organizationSSOService) syncMembership(tx *gorm.DB, org *models.Organization, cfg *models.OrganizationSSOConfig, userID uuid.UUID, groups []string) error {
+	var member models.OrganizationMember
+	err := tx.Where("organization_id = ? AND user_id = ?", org.ID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if !cfg.JITProvisioning {
+			return nil
+		}
+		member = models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: userID, Role: cfg.DefaultRole}
+		if err := tx.Create(&member).Error; err != nil {
+			return fmt.Errorf("failed to add organization member: %w", err)
+		}
+	} else if err != nil {
+		return err
+	}
+
+	inGroup := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		inGroup[group] = true
+	}
+	// A team mapped from several groups keeps the highest role granted
+	wanted := map[uuid.UUID]models.TeamRole{}
+	mapped := map[uuid.UUID]bool{}
+	for _, mapping := range cfg.TeamMappings {
+		mapped[mapping.TeamID] = true
+		if inGroup[mapping.Group] && wanted[mapping.TeamID] != models.TeamRoleMaintainer {
+			wanted[mapping.TeamID] = mapping.Role
+		}
+	}
+	for teamID := range mapped {
+		role, ok := wanted[teamID]
+		if !ok {
+			if err := tx.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&models.TeamMember{}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		var teamMember models.TeamMember
+		err := tx.Where("team_id = ? AND user_id = ?", teamID, userID).First(&teamMember).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			teamMember = models.TeamMember{ID: uuid.New(), TeamID: teamID, UserID: userID, Role: role}
+			if err := tx.Create(&teamMember).Error; err != nil {
+				return fmt.Errorf("failed to add team member: %w", err)
+			}
+		case err != nil:
+			return err
+		case teamMember.Role != role:
+			if err := tx.Model(&teamMember).Update("role", role).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *organizationSSOService) Metadata(ctx context.Context, orgName string) (string, error) {
+	org, cfg, err := s.enabledConfig(ctx, orgName)
+	if err != nil {
+		return "", err
+	}
+	if cfg.Protocol != models.SSOProtocolSAML {
+		return "", ErrSSONotConfigured
+	}
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="` + xmlAttrEscape(s.ssoURL(org, "saml/metadata")) + `">` + "\n")
+	b.WriteString(`  <md:SPSSODescriptor AuthnRequestsSigned="false" WantAssertionsSigned="true" protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">` + "\n")
+	b.WriteString(`    <md:AssertionConsumerService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="` + xmlAttrEscape(s.ssoURL(org, "saml/acs")) + `" index="0" isDefault="true"/>` + "\n")
+	b.WriteString("  </md:SPSSODescriptor>\n</md:EntityDescriptor>\n")
+	return b.String(), nil
+}
+
+func xmlAttrEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;").Replace(s)
+}
+
+func (s *organizationSSOService) hasActiveSession(ctx context.Context, orgID, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.OrganizationSSOIdentity{}).
+		Where("organization_id = ? AND user_id = ? AND session_expires_at > ?", orgID, userID, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (s *organizationSSOService) Authorize(ctx context.Context, orgID, userID uuid.UUID) error {
+	var cfg models.OrganizationSSOConfig
+	err := s.db.WithContext(ctx).Select("enabled", "enforced").Where("organization_id = ?", orgID).First(&cfg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !cfg.Enabled || !cfg.Enforced {
+		return nil
+	}
+	var members int64
+	if err := s.db.WithContext(ctx).Model(&models.OrganizationMember{}).Where("organization_id = ? AND user_id = ?", orgID, userID).Count(&members).Error; err != nil {
+		return err
+	}
+	if members == 0 {
+		return nil
+	}
+	active, err := s.hasActiveSession(ctx, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if !active {
+		return ErrSSORequired
+	}
+	return nil
+}
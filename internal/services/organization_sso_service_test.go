@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/auth"
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// fakeOIDCProvider is an OpenID provider that signs in whoever the test
+// sets as the next user, checking the PKCE verifier of each code
+type fakeOIDCProvider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	next   jwt.MapClaims
+	logins map[string]url.Values // authorization requests by code
+}
+
+func newFakeOIDCProvider(t *testing.T) *fakeOIDCProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p := &fakeOIDCProvider{key: key, logins: map[string]url.Values{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		login, ok := p.logins[r.FormValue("code")]
+		if !ok || r.FormValue("client_secret") != "secret" || pkceChallenge(r.FormValue("code_verifier")) != login.Get("code_challenge") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		claims := jwt.MapClaims{"iss": p.URL, "aud": "hub", "exp": time.Now().Add(time.Hour).Unix(), "nonce": login.Get("nonce")}
+		for k, v := range p.next {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "k1"
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": signed})
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+// authorize plays the user approving the login at the provider and returns
+// the state and code the provider redirects back with
+func (p *fakeOIDCProvider) authorize(t *testing.T, redirect string) (string, string) {
+	u, err := url.Parse(redirect)
+	require.NoError(t, err)
+	require.Equal(t, "S256", u.Query().Get("code_challenge_method"))
+	code := uuid.NewString()
+	p.logins[code] = u.Query()
+	return u.Query().Get("state"), code
+}
+
+func TestOrganizationSSOService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{}, &models.Team{},
+		&models.TeamMember{}, &models.OrganizationSSOConfig{}, &models.OrganizationSSOIdentity{}, &models.SSOLoginState{}, &models.SCIMUser{}, &auth.Session{})
+	// Sessions rely on the database generating their IDs, which SQLite cannot
+	require.NoError(t, db.Callback().Create().Before("gorm:create").Register("test:session_id", func(tx *gorm.DB) {
+		if session, ok := tx.Statement.Dest.(*auth.Session); ok && session.ID == uuid.Nil {
+			session.ID = uuid.New()
+		}
+	}))
+	ctx := context.Background()
+	jwtConfig := config.JWT{Secret: "test-secret", ExpirationHour: 1}
+	svc := NewOrganizationSSOService(db, auth.NewJWTManager(jwtConfig), auth.NewSessionService(db), jwtConfig, "https://hub.example.com", logrus.New())
+	idp := newFakeOIDCProvider(t)
+
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	owner := &models.User{ID: uuid.New(), Username: "owner", Email: "owner@acme.com", IsActive: true}
+	require.NoError(t, db.Create(owner).Error)
+	require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: owner.ID, Role: models.OrgRoleOwner}).Error)
+	platform := &models.Team{ID: uuid.New(), OrganizationID: org.ID, Name: "platform", Privacy: models.TeamPrivacyClosed}
+	require.NoError(t, db.Create(platform).Error)
+
+	_, err := svc.BeginLogin(ctx, "acme", nil)
+	assert.ErrorIs(t, err, ErrSSONotConfigured)
+	ssoConfig := &models.OrganizationSSOConfig{Protocol: models.SSOProtocolOIDC, Enabled: true, IssuerURL: idp.URL + "/",
+		ClientID: "hub", ClientSecret: "secret", JITProvisioning: true,
+		TeamMappings: []models.SSOTeamMapping{{Group: "platform-eng", TeamID: platform.ID}}}
+	_, err = svc.SetConfig(ctx, "acme", uuid.New(), ssoConfig)
+	assert.ErrorIs(t, err, ErrSSOForbidden)
+	ssoConfig.Enforced = true
+	_, err = svc.SetConfig(ctx, "acme", owner.ID, ssoConfig)
+	assert.ErrorIs(t, err, ErrInvalidSSOConfig, "enforcing without an SSO session of one's own")
+	ssoConfig.Enforced = false
+	saved, err := svc.SetConfig(ctx, "acme", owner.ID, ssoConfig)
+	require.NoError(t, err)
+	assert.Equal(t, idp.URL, saved.IssuerURL)
+	assert.Equal(t, models.OrgRoleMember, saved.DefaultRole)
+
+	// The owner links their existing account by starting SSO signed in
+	redirect, err := svc.BeginLogin(ctx, "acme", &owner.ID)
+	require.NoError(t, err)
+	state, code := idp.authorize(t, redirect)
+	idp.next = jwt.MapClaims{"sub": "idp-owner", "email": "owner@acme.com"}
+	result, err := svc.CompleteOIDC(ctx, "acme", state, code)
+	require.NoError(t, err)
+	assert.Equal(t, owner.ID, result.User.ID)
+	assert.False(t, result.Provisioned)
+	assert.Empty(t, result.AccessToken, "existing accounts are not signed in by SSO")
+	_, err = svc.CompleteOIDC(ctx, "acme", state, code)
+	assert.ErrorIs(t, err, ErrSSOLoginFailed, "states cannot be replayed")
+
+	ssoConfig.Enforced = true
+	_, err = svc.SetConfig(ctx, "acme", owner.ID, ssoConfig)
+	require.NoError(t, err)
+	assert.NoError(t, svc.Authorize(ctx, org.ID, owner.ID))
+
+	// New identities are provisioned with membership and mapped teams
+	redirect, err = svc.BeginLogin(ctx, "acme", nil)
+	require.NoError(t, err)
+	state, code = idp.authorize(t, redirect)
+	idp.next = jwt.MapClaims{"sub": "idp-jane", "email": "jane@acme.com", "preferred_username": "jane", "groups": []string{"platform-eng"}}
+	result, err = svc.CompleteOIDC(ctx, "acme", state, code)
+	require.NoError(t, err)
+	assert.True(t, result.Provisioned)
+	assert.NotEmpty(t, result.AccessToken)
+	assert.NotEmpty(t, result.RefreshToken)
+	jane := result.User
+	assert.Equal(t, "jane", jane.Username)
+	var member models.OrganizationMember
+	require.NoError(t, db.Where("organization_id = ? AND user_id = ?", org.ID, jane.ID).First(&member).Error)
+	assert.Equal(t, models.OrgRoleMember, member.Role)
+	var teamMembers int64
+	db.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", platform.ID, jane.ID).Count(&teamMembers)
+	assert.EqualValues(t, 1, teamMembers)
+
+	// Leaving the group at the provider removes the mapped team membership
+	redirect, err = svc.BeginLogin(ctx, "acme", nil)
+	require.NoError(t, err)
+	state, code = idp.authorize(t, redirect)
+	idp.next = jwt.MapClaims{"sub": "idp-jane", "email": "jane@acme.com"}
+	result, err = svc.CompleteOIDC(ctx, "acme", state, code)
+	require.NoError(t, err)
+	assert.Equal(t, jane.ID, result.User.ID)
+	db.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", platform.ID, jane.ID).Count(&teamMembers)
+	assert.Zero(t, teamMembers)
+
+	// Provisioning never takes over an existing account by email
+	redirect, err = svc.BeginLogin(ctx, "acme", nil)
+	require.NoError(t, err)
+	state, code = idp.authorize(t, redirect)
+	idp.next = jwt.MapClaims{"sub": "idp-impostor", "email": "owner@acme.com"}
+	_, err = svc.CompleteOIDC(ctx, "acme", state, code)
+	assert.ErrorIs(t, err, ErrSSOLoginFailed)
+
+	// Nonces bind ID tokens to their login
+	redirect, err = svc.BeginLogin(ctx, "acme", nil)
+	require.NoError(t, err)
+	state, code = idp.authorize(t, redirect)
+	idp.logins[code].Set("nonce", "other")
+	idp.next = jwt.MapClaims{"sub": "idp-jane", "email": "jane@acme.com"}
+	_, err = svc.CompleteOIDC(ctx, "acme", state, code)
+	assert.ErrorIs(t, err, ErrSSOLoginFailed)
+
+	// Enforcement requires members to hold an unexpired session
+	require.NoError(t, db.Model(&models.OrganizationSSOIdentity{}).Where("user_id = ?", jane.ID).
+		Update("session_expires_at", time.Now().Add(-time.Minute)).Error)
+	assert.ErrorIs(t, svc.Authorize(ctx, org.ID, jane.ID), ErrSSORequired)
+	assert.NoError(t, svc.Authorize(ctx, org.ID, uuid.New()), "non-members are not affected")
+
+	identities, err := svc.ListIdentities(ctx, "acme", owner.ID)
+	require.NoError(t, err)
+	assert.Len(t, identities, 2)
+	require.NoError(t, svc.DeleteConfig(ctx, "acme", owner.ID))
+	assert.NoError(t, svc.Authorize(ctx, org.ID, jane.ID))
+}
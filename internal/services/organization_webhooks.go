@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Organization webhook events
+const (
+	WebhookEventOrganization = "organization"
+	WebhookEventTeam         = "team"
+	WebhookEventMembership   = "membership"
+)
+
+// OrganizationWebhookActions lists the actions of each organization webhook
+// event. Hooks subscribe to an event, or to one action as "event.action".
+var OrganizationWebhookActions = map[string][]string{
+	WebhookEventOrganization: {"member_added", "member_removed", "member_role_changed"},
+	WebhookEventTeam:         {"created", "deleted"},
+	WebhookEventMembership:   {"added", "removed", "role_changed"},
+}
+
+// organizationWebhookEvents maps the logged activities delivered to
+// organization webhooks to their event and action
+var organizationWebhookEvents = map[models.ActivityAction][2]string{
+	models.ActivityMemberAdded:           {WebhookEventOrganization, "member_added"},
+	models.ActivityMemberRemoved:         {WebhookEventOrganization, "member_removed"},
+	models.ActivityMemberRoleChanged:     {WebhookEventOrganization, "member_role_changed"},
+	models.ActivityTeamCreated:           {WebhookEventTeam, "created"},
+	models.ActivityTeamDeleted:           {WebhookEventTeam, "deleted"},
+	models.ActivityTeamMemberAdded:       {WebhookEventMembership, "added"},
+	models.ActivityTeamMemberRemoved:     {WebhookEventMembership, "removed"},
+	models.ActivityTeamMemberRoleChanged: {WebhookEventMembership, "role_changed"},
+}
+
+// organizationWebhookActivity is an ActivityService that also delivers
+// membership and team changes to organization webhooks once they are logged
+type organizationWebhookActivity struct {
+	ActivityService
+	db       *gorm.DB
+	webhooks *WebhookDeliveryService
+	logger   *logrus.Logger
+}
+
+// NewOrganizationWebhookActivity wraps as so that the membership and team
+// activities it logs trigger organization webhooks
+func NewOrganizationWebhookActivity(as ActivityService, db *gorm.DB, webhooks *WebhookDeliveryService, logger *logrus.Logger) ActivityService {
+	return &organizationWebhookActivity{ActivityService: as, db: db, webhooks: webhooks, logger: logger}
+}
+
+func (s *organizationWebhookActivity) LogActivity(ctx context.Context, orgID, actorID uuid.UUID, action models.ActivityAction, targetType string, targetID *uuid.UUID, metadata map[string]interface{}) error {
+	err := s.ActivityService.LogActivity(ctx, orgID, actorID, action, targetType, targetID, metadata)
+
+	event, ok := organizationWebhookEvents[action]
+	if !ok || orgID == uuid.Nil {
+		return err
+	}
+	payload, payloadErr := s.payload(ctx, orgID, actorID, event[1], targetType, targetID, metadata)
+	if payloadErr == nil {
+		payloadErr = s.webhooks.TriggerOrganizationWebhooks(ctx, orgID, event[0], payload)
+	}
+	if payloadErr != nil {
+		s.logger.WithError(payloadErr).WithFields(logrus.Fields{
+			"organization_id": orgID,
+			"action":          action,
+		}).Error("Failed to trigger organization webhooks")
+	}
+	return err
+}
+
+// payload builds the webhook payload of an activity. Member activities
+// target the user, or the organization when its owner is added on creation;
+// team membership activities target the team and name the user in metadata.
+func (s *organizationWebhookActivity) payload(ctx context.Context, orgID, actorID uuid.UUID, action, targetType string, targetID *uuid.UUID, metadata map[string]interface{}) (map[string]interface{}, error) {
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Unscoped().First(&org, "id = ?", orgID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+
+	data := map[string]interface{}{}
+	if targetType == "team" && targetID != nil {
+		var team models.Team
+		if err := s.db.WithContext(ctx).Unscoped().First(&team, "id = ?", *targetID).Error; err != nil {
+			return nil, fmt.Errorf("failed to get team: %w", err)
+		}
+		data["team"] = webhookTeam(&team)
+	}
+
+	userID := actorID
+	if targetType == "user" && targetID != nil {
+		userID = *targetID
+	}
+	if id, ok := metadata["user_id"].(uuid.UUID); ok {
+		userID = id
+	}
+	if targetType != "team" || metadata["user_id"] != nil {
+		var user models.User
+		if err := s.db.WithContext(ctx).Unscoped().First(&user, "id = ?", userID).Error; err != nil {
+			return nil, fmt.Errorf("failed to get user: %w", err)
+		}
+		data["user"] = map[string]interface{}{
+			"id":    user.ID.String(),
+			"login": user.Username,
+			"name":  user.FullName,
+		}
+	}
+
+	if role, ok := metadata["role"]; ok {
+		data["role"] = fmt.Sprint(role)
+	}
+	if role, ok := metadata["new_role"]; ok {
+		data["role"] = fmt.Sprint(role)
+		data["previous_role"] = fmt.Sprint(metadata["old_role"])
+	}
+
+	return map[string]interface{}{
+		"action": action,
+		"organization": map[string]interface{}{
+			"id":    org.ID.String(),
+			"login": org.Name,
+			"name":  org.DisplayName,
+		},
+		"data": data,
+	}, nil
+}
+
+func webhookTeam(team *models.Team) map[string]interface{} {
+	out := map[string]interface{}{
+		"id":          team.ID.String(),
+		"name":        team.Name,
+		"description": team.Description,
+		"privacy":     string(team.Privacy),
+	}
+	if team.ParentTeamID != nil {
+		out["parent_team_id"] = team.ParentTeamID.String()
+	}
+	return out
+}
+
+// ValidOrganizationWebhookEvent reports whether an organization webhook can
+// subscribe to event: "*", an event name or "event.action"
+func ValidOrganizationWebhookEvent(event string) bool {
+	if event == "*" {
+		return true
+	}
+	name, action, hasAction := strings.Cut(event, ".")
+	actions, ok := OrganizationWebhookActions[name]
+	if !ok || !hasAction {
+		return ok
+	}
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrganizationWebhookActivity(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.Team{}, &models.Webhook{}, &models.WebhookDelivery{})
+	ctx := context.Background()
+
+	type delivery struct {
+		Event string
+		Body  WebhookPayload
+	}
+	received := make(chan delivery, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload WebhookPayload
+		json.Unmarshal(body, &payload)
+		received <- delivery{Event: r.Header.Get("X-Hub-Event"), Body: payload}
+	}))
+	defer server.Close()
+	next := func() delivery {
+		select {
+		case d := <-received:
+			return d
+		case <-time.After(5 * time.Second):
+			t.Fatal("no webhook delivered")
+			return delivery{}
+		}
+	}
+
+	webhooks := NewWebhookDeliveryService(db, logrus.New())
+	inner := new(mockActivityService)
+	inner.On("LogActivity", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	activity := NewOrganizationWebhookActivity(inner, db, webhooks, logrus.New())
+
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	alice := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", FullName: "Alice"}
+	require.NoError(t, db.Create(alice).Error)
+	team := &models.Team{ID: uuid.New(), OrganizationID: org.ID, Name: "ops", Privacy: models.TeamPrivacyClosed}
+	require.NoError(t, db.Create(team).Error)
+
+	// Subscribes to member additions and every membership change only
+	_, err := webhooks.CreateOrganizationWebhook(ctx, org.ID, "directory", server.URL, "", []string{"organization.member_added", "membership"},
+		"application/json", false, true)
+	require.NoError(t, err)
+
+	t.Run("member added", func(t *testing.T) {
+		require.NoError(t, activity.LogActivity(ctx, org.ID, alice.ID, models.ActivityMemberAdded, "user", &alice.ID, map[string]interface{}{
+			"role": models.OrgRoleMember,
+		}))
+		d := next()
+		assert.Equal(t, "organization", d.Event)
+		assert.Equal(t, "member_added", d.Body.Action)
+		assert.Equal(t, "acme", d.Body.Organization["login"])
+		assert.Nil(t, d.Body.Repository)
+		assert.Equal(t, "alice", d.Body.Data["user"].(map[string]interface{})["login"])
+		assert.Equal(t, "member", d.Body.Data["role"])
+	})
+
+	t.Run("unsubscribed actions are filtered", func(t *testing.T) {
+		require.NoError(t, activity.LogActivity(ctx, org.ID, alice.ID, models.ActivityMemberRemoved, "user", &alice.ID, nil))
+		require.NoError(t, activity.LogActivity(ctx, org.ID, uuid.Nil, models.ActivityTeamCreated, "team", &team.ID, nil))
+		require.NoError(t, activity.LogActivity(ctx, org.ID, alice.ID, models.ActivityTeamMemberRoleChanged, "team", &team.ID, map[string]interface{}{
+			"user_id":  alice.ID,
+			"old_role": models.TeamRoleMember,
+			"new_role": models.TeamRoleMaintainer,
+		}))
+		d := next()
+		assert.Equal(t, "membership", d.Event)
+		assert.Equal(t, "role_changed", d.Body.Action)
+		assert.Equal(t, "ops", d.Body.Data["team"].(map[string]interface{})["name"])
+		assert.Equal(t, "alice", d.Body.Data["user"].(map[string]interface{})["login"])
+		assert.Equal(t, "maintainer", d.Body.Data["role"])
+		assert.Equal(t, "member", d.Body.Data["previous_role"])
+
+		select {
+		case d := <-received:
+			t.Fatalf("unexpected %s.%s delivery", d.Event, d.Body.Action)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("activity is still logged", func(t *testing.T) {
+		inner.AssertNumberOfCalls(t, "LogActivity", 4)
+	})
+}
+
+func TestValidOrganizationWebhookEvent(t *testing.T) {
+	for _, event := range []string{"*", "organization", "team.deleted", "membership.role_changed"} {
+		assert.True(t, ValidOrganizationWebhookEvent(event), event)
+	}
+	for _, event := range []string{"push", "team.updated", "organization.", ""} {
+		assert.False(t, ValidOrganizationWebhookEvent(event), event)
+	}
+}
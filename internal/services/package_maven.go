@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+)
+
+// MavenMetadataFile is the file listing the versions of a Maven artifact
+const MavenMetadataFile = "maven-metadata.xml"
+
+// MavenPath is a path in a Maven repository: a file of a version of an
+// artifact, or the metadata of an artifact when Version is empty
+type MavenPath struct {
+	// Name is groupId:artifactId
+	Name    string
+	Version string
+	File    string
+}
+
+// ParseMavenPath parses a path such as com/example/lib/1.0/lib-1.0.jar or
+// com/example/lib/maven-metadata.xml. Metadata under a version directory
+// is kept by snapshot versions, whose directories end with -SNAPSHOT.
+func ParseMavenPath(p string) (*MavenPath, error) {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("%w: invalid Maven path %q", ErrPackageInvalid, p)
+	}
+	file := parts[len(parts)-1]
+	if strings.HasPrefix(file, MavenMetadataFile) && !strings.HasSuffix(parts[len(parts)-2], "-SNAPSHOT") {
+		group, artifact := parts[:len(parts)-2], parts[len(parts)-2]
+		return &MavenPath{Name: strings.Join(group, ".") + ":" + artifact, File: file}, nil
+	}
+	if len(parts) < 4 {
+		return nil, fmt.Errorf("%w: invalid Maven path %q", ErrPackageInvalid, p)
+	}
+	group, artifact, version := parts[:len(parts)-3], parts[len(parts)-3], parts[len(parts)-2]
+	return &MavenPath{Name: strings.Join(group, ".") + ":" + artifact, Version: version, File: file}, nil
+}
+
+// mavenMetadata is the maven-metadata.xml of an artifact
+type mavenMetadata struct {
+	XMLName    xml.Name `xml:"metadata"`
+	GroupID    string   `xml:"groupId"`
+	ArtifactID string   `xml:"artifactId"`
+	Versioning struct {
+		Latest      string   `xml:"latest,omitempty"`
+		Release     string   `xml:"release,omitempty"`
+		Versions    []string `xml:"versions>version"`
+		LastUpdated string   `xml:"lastUpdated"`
+	} `xml:"versioning"`
+}
+
+func (s *packageService) MavenMetadata(ctx context.Context, org *models.Organization, actorID *uuid.UUID, name string) ([]byte, error) {
+	pkg, err := s.readable(ctx, org, actorID, models.PackageTypeMaven, name)
+	if err != nil {
+		return nil, err
+	}
+	var versions []models.PackageVersion
+	if err := s.db.WithContext(ctx).Where("package_id = ?", pkg.ID).Order("created_at").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get package versions: %w", err)
+	}
+
+	var metadata mavenMetadata
+	metadata.GroupID, metadata.ArtifactID, _ = strings.Cut(pkg.Name, ":")
+	updated := pkg.UpdatedAt
+	for _, ver := range versions {
+		metadata.Versioning.Versions = append(metadata.Versioning.Versions, ver.Version)
+		metadata.Versioning.Latest = ver.Version
+		if !strings.HasSuffix(ver.Version, "-SNAPSHOT") {
+			metadata.Versioning.Release = ver.Version
+		}
+		if ver.UpdatedAt.After(updated) {
+			updated = ver.UpdatedAt
+		}
+	}
+	metadata.Versioning.LastUpdated = updated.UTC().Format("20060102150405")
+
+	content, err := xml.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to render Maven metadata: %w", err)
+	}
+	return append([]byte(xml.Header), content...), nil
+}
+
+// mavenChecksumSuffixes are the checksum files Maven clients publish and
+// fetch next to each file
+var mavenChecksumSuffixes = []string{".sha1", ".md5", ".sha256", ".sha512"}
+
+// SplitMavenChecksum returns the file a Maven checksum file is for and
+// the checksum algorithm, or ok false when file is not a checksum file
+func SplitMavenChecksum(file string) (target, algorithm string, ok bool) {
+	for _, suffix := range mavenChecksumSuffixes {
+		if strings.HasSuffix(file, suffix) {
+			return strings.TrimSuffix(file, suffix), suffix[1:], true
+		}
+	}
+	return file, "", false
+}
+
+// MavenChecksum returns the stored checksum of file for a Maven checksum
+// algorithm, or "" when it is not kept
+func MavenChecksum(file *models.PackageFile, algorithm string) string {
+	switch algorithm {
+	case "sha1":
+		return file.SHA1
+	case "md5":
+		return file.MD5
+	case "sha256":
+		return file.SHA256
+	}
+	return ""
+}
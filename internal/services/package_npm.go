@@ -0,0 +1,183 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// npmPublishDocument holds the fields of the document npm publish sends
+type npmPublishDocument struct {
+	Name        string                     `json:"name"`
+	Access      string                     `json:"access"`
+	DistTags    map[string]string          `json:"dist-tags"`
+	Versions    map[string]json.RawMessage `json:"versions"`
+	Attachments map[string]struct {
+		Data   string `json:"data"`
+		Length int64  `json:"length"`
+	} `json:"_attachments"`
+}
+
+func (s *packageService) PublishNpm(ctx context.Context, org *models.Organization, actorID uuid.UUID, name string, document []byte) (*models.PackageVersion, error) {
+	var doc npmPublishDocument
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPackageInvalid, err)
+	}
+	if doc.Name != name {
+		return nil, fmt.Errorf("%w: document is for package %q", ErrPackageInvalid, doc.Name)
+	}
+	if len(doc.Versions) != 1 || len(doc.Attachments) != 1 {
+		return nil, fmt.Errorf("%w: exactly one version and tarball must be published", ErrPackageInvalid)
+	}
+	// The document carries a single version and tarball
+	var version string
+	var manifest json.RawMessage
+	for version, manifest = range doc.Versions {
+	}
+	if err := validatePackage(models.PackageTypeNpm, name, version); err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, org, actorID, false); err != nil {
+		return nil, err
+	}
+
+	var fileName, data string
+	for key, attachment := range doc.Attachments {
+		fileName, data = path.Base(key), attachment.Data
+	}
+	if !packageFileNamePattern.MatchString(fileName) {
+		return nil, fmt.Errorf("%w: invalid tarball name %q", ErrPackageInvalid, fileName)
+	}
+	tarball, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: tarball is not base64 encoded", ErrPackageInvalid)
+	}
+	spooled, err := s.spool(bytes.NewReader(tarball))
+	if err != nil {
+		return nil, err
+	}
+	defer spooled.Close()
+
+	var pkg models.Package
+	err = s.db.WithContext(ctx).Where("organization_id = ? AND type = ? AND name = ?", org.ID, models.PackageTypeNpm, name).First(&pkg).Error
+	if err == nil {
+		var published int64
+		if err := s.db.WithContext(ctx).Model(&models.PackageVersion{}).
+			Where("package_id = ? AND version = ?", pkg.ID, version).Count(&published).Error; err != nil {
+			return nil, fmt.Errorf("failed to get package version: %w", err)
+		}
+		if published > 0 {
+			return nil, ErrPackageConflict
+		}
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if err := s.store(ctx, spooled); err != nil {
+		return nil, err
+	}
+
+	visibility := models.VisibilityPrivate
+	if doc.Access == "public" {
+		visibility = models.VisibilityPublic
+	}
+	var ver *models.PackageVersion
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var pkg *models.Package
+		var err error
+		pkg, ver, err = ensureVersion(tx, org, actorID, models.PackageTypeNpm, name, version, visibility)
+		if err != nil {
+			return err
+		}
+		ver.Metadata = string(manifest)
+		if err := tx.Model(ver).Update("metadata", ver.Metadata).Error; err != nil {
+			return err
+		}
+		file := models.PackageFile{
+			ID:        uuid.New(),
+			VersionID: ver.ID,
+			Name:      fileName,
+			Size:      spooled.size,
+			SHA256:    spooled.sha256,
+			SHA1:      spooled.sha1,
+			MD5:       spooled.md5,
+		}
+		if err := tx.Create(&file).Error; err != nil {
+			return err
+		}
+
+		tags := distTags(pkg)
+		for tag, tagged := range doc.DistTags {
+			tags[tag] = tagged
+		}
+		if len(tags) == 0 {
+			tags["latest"] = version
+		}
+		return setDistTags(tx, pkg, tags)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish npm package: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"organization_id": org.ID,
+		"package":         name,
+		"version":         version,
+	}).Info("Published npm package")
+	return ver, nil
+}
+
+func (s *packageService) NpmPackument(ctx context.Context, org *models.Organization, actorID *uuid.UUID, name, tarballBaseURL string) (map[string]interface{}, error) {
+	pkg, err := s.readable(ctx, org, actorID, models.PackageTypeNpm, name)
+	if err != nil {
+		return nil, err
+	}
+	var versions []models.PackageVersion
+	if err := s.db.WithContext(ctx).Where("package_id = ?", pkg.ID).Order("created_at").
+		Preload("Files").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get package versions: %w", err)
+	}
+
+	manifests := map[string]interface{}{}
+	times := map[string]string{"created": pkg.CreatedAt.UTC().Format(time.RFC3339), "modified": pkg.UpdatedAt.UTC().Format(time.RFC3339)}
+	for _, ver := range versions {
+		manifest := map[string]interface{}{}
+		if ver.Metadata != "" {
+			json.Unmarshal([]byte(ver.Metadata), &manifest)
+		}
+		manifest["name"] = pkg.Name
+		manifest["version"] = ver.Version
+		dist, _ := manifest["dist"].(map[string]interface{})
+		if dist == nil {
+			dist = map[string]interface{}{}
+		}
+		if len(ver.Files) > 0 {
+			file := ver.Files[0]
+			dist["tarball"] = strings.TrimSuffix(tarballBaseURL, "/") + "/" + pkg.Name + "/-/" + file.Name
+			if _, ok := dist["shasum"]; !ok {
+				dist["shasum"] = file.SHA1
+			}
+		}
+		manifest["dist"] = dist
+		manifests[ver.Version] = manifest
+		times[ver.Version] = ver.CreatedAt.UTC().Format(time.RFC3339)
+	}
+
+	return map[string]interface{}{
+		"_id":       pkg.Name,
+		"name":      pkg.Name,
+		"dist-tags": distTags(pkg),
+		"versions":  manifests,
+		"time":      times,
+	}, nil
+}
@@ -0,0 +1,744 @@
+package services
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrPackageNotFound  = errors.New("package not found")
+	ErrPackageForbidden = errors.New("insufficient permissions for the package")
+	ErrPackageInvalid   = errors.New("invalid package")
+	ErrPackageConflict  = errors.New("package version already published")
+	ErrPackageTooLarge  = errors.New("package file exceeds the maximum size")
+)
+
+var (
+	genericPackageNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,213}$`)
+	npmPackageNamePattern     = regexp.MustCompile(`^(@[a-z0-9][a-z0-9._~-]*/)?[a-z0-9][a-z0-9._~-]{0,213}$`)
+	mavenPackageNamePattern   = regexp.MustCompile(`^[A-Za-z0-9_.-]+:[A-Za-z0-9_.-]+$`)
+	packageVersionPattern     = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.+_-]{0,127}$`)
+	packageFileNamePattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]{0,254}$`)
+)
+
+// PackageRetentionResult reports what applying retention policies removed
+type PackageRetentionResult struct {
+	VersionsDeleted int   `json:"versions_deleted"`
+	FilesDeleted    int   `json:"files_deleted"`
+	BytesFreed      int64 `json:"bytes_freed"`
+}
+
+// PackageService is the package registry of organizations, serving npm,
+// Maven and generic file packages. Organization members may publish and
+// install packages; owners and admins manage them. Public packages can be
+// installed by anyone. Downloads are counted on packages and versions and
+// recorded as analytics events.
+type PackageService interface {
+	// ListPackages returns the packages of org actorID may read, of
+	// packageType or of every type when empty
+	ListPackages(ctx context.Context, org *models.Organization, actorID *uuid.UUID, packageType string) ([]models.Package, error)
+	// GetPackage returns a package with its versions, newest first, and files
+	GetPackage(ctx context.Context, org *models.Organization, actorID *uuid.UUID, id uuid.UUID) (*models.Package, error)
+	SetVisibility(ctx context.Context, org *models.Organization, actorID, id uuid.UUID, visibility models.Visibility) (*models.Package, error)
+	DeletePackage(ctx context.Context, org *models.Organization, actorID, id uuid.UUID) error
+	DeleteVersion(ctx context.Context, org *models.Organization, actorID, id uuid.UUID, version string) error
+
+	// PublishFile adds a file to a version of a generic or Maven package,
+	// creating both as needed. Publishing a file again with the same
+	// content succeeds; with other content it conflicts.
+	PublishFile(ctx context.Context, org *models.Organization, actorID uuid.UUID, packageType, name, version, fileName string, content io.Reader) (*models.PackageFile, error)
+	// StatFile returns a file of a package version without counting a
+	// download; an empty version matches the file in any version
+	StatFile(ctx context.Context, org *models.Organization, actorID *uuid.UUID, packageType, name, version, fileName string) (*models.PackageFile, error)
+	// OpenFile returns a file of a package version with its content and
+	// records the download
+	OpenFile(ctx context.Context, org *models.Organization, actorID *uuid.UUID, packageType, name, version, fileName string) (*models.PackageFile, io.ReadCloser, error)
+
+	// PublishNpm publishes the version and tarball of an npm publish
+	// document
+	PublishNpm(ctx context.Context, org *models.Organization, actorID uuid.UUID, name string, document []byte) (*models.PackageVersion, error)
+	// NpmPackument returns the npm registry document of a package, with
+	// tarballs served under tarballBaseURL
+	NpmPackument(ctx context.Context, org *models.Organization, actorID *uuid.UUID, name, tarballBaseURL string) (map[string]interface{}, error)
+	// MavenMetadata returns the maven-metadata.xml listing the versions of
+	// a Maven package
+	MavenMetadata(ctx context.Context, org *models.Organization, actorID *uuid.UUID, name string) ([]byte, error)
+
+	GetRetentionPolicy(ctx context.Context, org *models.Organization, actorID uuid.UUID) (*models.PackageRetentionPolicy, error)
+	SetRetentionPolicy(ctx context.Context, org *models.Organization, actorID uuid.UUID, policy *models.PackageRetentionPolicy) (*models.PackageRetentionPolicy, error)
+	// ApplyRetention deletes the package versions the enabled retention
+	// policies no longer keep
+	ApplyRetention(ctx context.Context) (*PackageRetentionResult, error)
+	StartScheduler(ctx context.Context)
+}
+
+type packageService struct {
+	db        *gorm.DB
+	backend   storage.Backend
+	analytics AnalyticsService
+	cfg       config.PackageStorage
+	logger    *logrus.Logger
+}
+
+// NewPackageService creates a new PackageService storing files in backend
+// and recording downloads with analytics
+func NewPackageService(db *gorm.DB, backend storage.Backend, analytics AnalyticsService, cfg config.PackageStorage, logger *logrus.Logger) PackageService {
+	return &packageService{
+		db:        db,
+		backend:   backend,
+		analytics: analytics,
+		cfg:       cfg,
+		logger:    logger,
+	}
+}
+
+// packageFilePath is where content with a SHA-256 is stored in the backend
+func packageFilePath(sha256 string) string {
+	return path.Join("files", sha256[:2], sha256)
+}
+
+// validatePackage checks the type, name and version of a package
+func validatePackage(packageType, name, version string) error {
+	var pattern *regexp.Regexp
+	switch packageType {
+	case models.PackageTypeNpm:
+		pattern = npmPackageNamePattern
+	case models.PackageTypeMaven:
+		pattern = mavenPackageNamePattern
+	case models.PackageTypeGeneric:
+		pattern = genericPackageNamePattern
+	default:
+		return fmt.Errorf("%w: unknown package type %q", ErrPackageInvalid, packageType)
+	}
+	if !pattern.MatchString(name) {
+		return fmt.Errorf("%w: invalid %s package name %q", ErrPackageInvalid, packageType, name)
+	}
+	if !packageVersionPattern.MatchString(version) {
+		return fmt.Errorf("%w: invalid version %q", ErrPackageInvalid, version)
+	}
+	return nil
+}
+
+// role returns the organization role of userID, empty when the user is
+// not a member
+func (s *packageService) role(ctx context.Context, org *models.Organization, userID *uuid.UUID) (models.OrganizationRole, error) {
+	if userID == nil {
+		return "", nil
+	}
+	var member models.OrganizationMember
+	err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", org.ID, *userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get organization membership: %w", err)
+	}
+	return member.Role, nil
+}
+
+// authorize checks userID may publish packages to org, or manage them
+func (s *packageService) authorize(ctx context.Context, org *models.Organization, userID uuid.UUID, manage bool) error {
+	role, err := s.role(ctx, org, &userID)
+	if err != nil {
+		return err
+	}
+	switch role {
+	case models.OrgRoleOwner, models.OrgRoleAdmin:
+		return nil
+	case models.OrgRoleMember, models.OrgRoleCustom:
+		if !manage {
+			return nil
+		}
+	}
+	return ErrPackageForbidden
+}
+
+// isReader reports whether userID may read the private packages of org
+func (s *packageService) isReader(ctx context.Context, org *models.Organization, userID *uuid.UUID) (bool, error) {
+	role, err := s.role(ctx, org, userID)
+	return role != "" && role != models.OrgRoleBilling, err
+}
+
+// readable returns a package of org actorID may read. Anonymous requests
+// for private or unknown packages are forbidden rather than not found, so
+// clients send their credentials.
+func (s *packageService) readable(ctx context.Context, org *models.Organization, actorID *uuid.UUID, packageType, name string) (*models.Package, error) {
+	var pkg models.Package
+	err := s.db.WithContext(ctx).Where("organization_id = ? AND type = ? AND name = ?", org.ID, packageType, name).First(&pkg).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	found := err == nil
+	if found && pkg.Visibility == models.VisibilityPublic {
+		return &pkg, nil
+	}
+	if actorID == nil {
+		return nil, ErrPackageForbidden
+	}
+	reader, err := s.isReader(ctx, org, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if !reader || !found {
+		return nil, ErrPackageNotFound
+	}
+	return &pkg, nil
+}
+
+func (s *packageService) ListPackages(ctx context.Context, org *models.Organization, actorID *uuid.UUID, packageType string) ([]models.Package, error) {
+	query := s.db.WithContext(ctx).Where("organization_id = ?", org.ID)
+	if packageType != "" {
+		query = query.Where("type = ?", packageType)
+	}
+	reader, err := s.isReader(ctx, org, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if !reader {
+		query = query.Where("visibility = ?", models.VisibilityPublic)
+	}
+
+	var packages []models.Package
+	if err := query.Order("type, name").Find(&packages).Error; err != nil {
+		return nil, fmt.Errorf("failed to list packages: %w", err)
+	}
+	return packages, nil
+}
+
+func (s *packageService) GetPackage(ctx context.Context, org *models.Organization, actorID *uuid.UUID, id uuid.UUID) (*models.Package, error) {
+	var pkg models.Package
+	err := s.db.WithContext(ctx).Where("id = ? AND organization_id = ?", id, org.ID).First(&pkg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPackageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	if _, err := s.readable(ctx, org, actorID, pkg.Type, pkg.Name); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Where("package_id = ?", pkg.ID).Order("created_at DESC").
+		Preload("Files").Find(&pkg.Versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to get package versions: %w", err)
+	}
+	return &pkg, nil
+}
+
+// managed returns a package of org after checking actorID manages packages
+func (s *packageService) managed(ctx context.Context, org *models.Organization, actorID, id uuid.UUID) (*models.Package, error) {
+	if err := s.authorize(ctx, org, actorID, true); err != nil {
+		return nil, err
+	}
+	var pkg models.Package
+	err := s.db.WithContext(ctx).Where("id = ? AND organization_id = ?", id, org.ID).First(&pkg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPackageNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get package: %w", err)
+	}
+	return &pkg, nil
+}
+
+func (s *packageService) SetVisibility(ctx context.Context, org *models.Organization, actorID, id uuid.UUID, visibility models.Visibility) (*models.Package, error) {
+	if visibility != models.VisibilityPublic && visibility != models.VisibilityPrivate {
+		return nil, fmt.Errorf("%w: visibility must be public or private", ErrPackageInvalid)
+	}
+	pkg, err := s.managed(ctx, org, actorID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Model(pkg).Update("visibility", visibility).Error; err != nil {
+		return nil, fmt.Errorf("failed to update package: %w", err)
+	}
+	return pkg, nil
+}
+
+func (s *packageService) DeletePackage(ctx context.Context, org *models.Organization, actorID, id uuid.UUID) error {
+	pkg, err := s.managed(ctx, org, actorID, id)
+	if err != nil {
+		return err
+	}
+	var versions []models.PackageVersion
+	if err := s.db.WithContext(ctx).Where("package_id = ?", pkg.ID).Find(&versions).Error; err != nil {
+		return fmt.Errorf("failed to get package versions: %w", err)
+	}
+	for i := range versions {
+		if _, err := s.deleteVersion(ctx, pkg, &versions[i]); err != nil {
+			return err
+		}
+	}
+	if err := s.db.WithContext(ctx).Delete(pkg).Error; err != nil {
+		return fmt.Errorf("failed to delete package: %w", err)
+	}
+	return nil
+}
+
+func (s *packageService) DeleteVersion(ctx context.Context, org *models.Organization, actorID, id uuid.UUID, version string) error {
+	pkg, err := s.managed(ctx, org, actorID, id)
+	if err != nil {
+		return err
+	}
+	var ver models.PackageVersion
+	err = s.db.WithContext(ctx).Where("package_id = ? AND version = ?", pkg.ID, version).First(&ver).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrPackageNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get package version: %w", err)
+	}
+	_, err = s.deleteVersion(ctx, pkg, &ver)
+	return err
+}
+
+// deleteVersion removes a version with its files, and the stored content
+// no other file uses. npm dist-tags pointing at the version are removed.
+func (s *packageService) deleteVersion(ctx context.Context, pkg *models.Package, ver *models.PackageVersion) (*PackageRetentionResult, error) {
+	var files []models.PackageFile
+	if err := s.db.WithContext(ctx).Where("version_id = ?", ver.ID).Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("failed to get package files: %w", err)
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("version_id = ?", ver.ID).Delete(&models.PackageFile{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(ver).Error; err != nil {
+			return err
+		}
+		tags, untagged := distTags(pkg), false
+		for tag, version := range tags {
+			if version == ver.Version {
+				delete(tags, tag)
+				untagged = true
+			}
+		}
+		if !untagged {
+			return nil
+		}
+		return setDistTags(tx, pkg, tags)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete package version: %w", err)
+	}
+
+	result := &PackageRetentionResult{VersionsDeleted: 1, FilesDeleted: len(files)}
+	for _, file := range files {
+		var users int64
+		if err := s.db.WithContext(ctx).Model(&models.PackageFile{}).Where("sha256 = ?", file.SHA256).Count(&users).Error; err != nil {
+			return nil, fmt.Errorf("failed to check stored content: %w", err)
+		}
+		if users > 0 {
+			continue
+		}
+		if err := s.backend.Delete(ctx, packageFilePath(file.SHA256)); err != nil {
+			s.logger.WithError(err).WithField("sha256", file.SHA256).Warn("Failed to delete package content")
+			continue
+		}
+		result.BytesFreed += file.Size
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"package_id": pkg.ID,
+		"version":    ver.Version,
+		"files":      len(files),
+	}).Info("Deleted package version")
+	return result, nil
+}
+
+// spooledFile is content received for a package file, spooled to a
+// temporary file while its size and checksums are computed
+type spooledFile struct {
+	file   *os.File
+	size   int64
+	sha256 string
+	sha1   string
+	md5    string
+}
+
+func (f *spooledFile) Close() {
+	f.file.Close()
+	os.Remove(f.file.Name())
+}
+
+// spool reads content to a temporary file, failing past MaxFileSizeMB
+func (s *packageService) spool(content io.Reader) (*spooledFile, error) {
+	file, err := os.CreateTemp("", "hub-package-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to spool package file: %w", err)
+	}
+	spooled := &spooledFile{file: file}
+
+	limit := s.cfg.MaxFileSizeMB << 20
+	if limit > 0 {
+		content = io.LimitReader(content, limit+1)
+	}
+	h256, h1, h5 := sha256.New(), sha1.New(), md5.New()
+	spooled.size, err = io.Copy(io.MultiWriter(file, h256, h1, h5), content)
+	if err == nil && limit > 0 && spooled.size > limit {
+		err = ErrPackageTooLarge
+	}
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		spooled.Close()
+		if errors.Is(err, ErrPackageTooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to spool package file: %w", err)
+	}
+	spooled.sha256 = hex.EncodeToString(h256.Sum(nil))
+	spooled.sha1 = hex.EncodeToString(h1.Sum(nil))
+	spooled.md5 = hex.EncodeToString(h5.Sum(nil))
+	return spooled, nil
+}
+
+// store uploads spooled content unless content with its SHA-256 is stored
+func (s *packageService) store(ctx context.Context, spooled *spooledFile) error {
+	p := packageFilePath(spooled.sha256)
+	exists, err := s.backend.Exists(ctx, p)
+	if err != nil {
+		return fmt.Errorf("failed to check stored content: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	if err := s.backend.Upload(ctx, p, spooled.file, spooled.size); err != nil {
+		return fmt.Errorf("failed to store package file: %w", err)
+	}
+	return nil
+}
+
+// ensureVersion returns a version of a package of org, creating both as
+// needed within tx
+func ensureVersion(tx *gorm.DB, org *models.Organization, actorID uuid.UUID, packageType, name, version string, visibility models.Visibility) (*models.Package, *models.PackageVersion, error) {
+	var pkg models.Package
+	err := tx.Where("organization_id = ? AND type = ? AND name = ?", org.ID, packageType, name).First(&pkg).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pkg = models.Package{ID: uuid.New(), OrganizationID: org.ID, Type: packageType, Name: name, Visibility: visibility}
+		err = tx.Create(&pkg).Error
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ver models.PackageVersion
+	err = tx.Where("package_id = ? AND version = ?", pkg.ID, version).First(&ver).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ver = models.PackageVersion{ID: uuid.New(), PackageID: pkg.ID, Version: version, PublisherID: &actorID}
+		err = tx.Create(&ver).Error
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &pkg, &ver, nil
+}
+
+func (s *packageService) PublishFile(ctx context.Context, org *models.Organization, actorID uuid.UUID, packageType, name, version, fileName string, content io.Reader) (*models.PackageFile, error) {
+	if packageType == models.PackageTypeNpm {
+		return nil, fmt.Errorf("%w: npm packages are published with npm", ErrPackageInvalid)
+	}
+	if err := validatePackage(packageType, name, version); err != nil {
+		return nil, err
+	}
+	if !packageFileNamePattern.MatchString(fileName) {
+		return nil, fmt.Errorf("%w: invalid file name %q", ErrPackageInvalid, fileName)
+	}
+	if err := s.authorize(ctx, org, actorID, false); err != nil {
+		return nil, err
+	}
+
+	spooled, err := s.spool(content)
+	if err != nil {
+		return nil, err
+	}
+	defer spooled.Close()
+	if err := s.store(ctx, spooled); err != nil {
+		return nil, err
+	}
+
+	var file models.PackageFile
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		_, ver, err := ensureVersion(tx, org, actorID, packageType, name, version, models.VisibilityPrivate)
+		if err != nil {
+			return err
+		}
+		err = tx.Where("version_id = ? AND name = ?", ver.ID, fileName).First(&file).Error
+		if err == nil {
+			if file.SHA256 != spooled.sha256 {
+				return ErrPackageConflict
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		file = models.PackageFile{
+			ID:        uuid.New(),
+			VersionID: ver.ID,
+			Name:      fileName,
+			Size:      spooled.size,
+			SHA256:    spooled.sha256,
+			SHA1:      spooled.sha1,
+			MD5:       spooled.md5,
+		}
+		return tx.Create(&file).Error
+	})
+	if errors.Is(err, ErrPackageConflict) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish package file: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"organization_id": org.ID,
+		"type":            packageType,
+		"package":         name,
+		"version":         version,
+		"file":            fileName,
+	}).Info("Published package file")
+	return &file, nil
+}
+
+// file returns a file of a version of pkg, or of any version when version
+// is empty, with its version
+func (s *packageService) file(ctx context.Context, pkg *models.Package, version, fileName string) (*models.PackageFile, *models.PackageVersion, error) {
+	query := s.db.WithContext(ctx).Model(&models.PackageFile{}).
+		Joins("JOIN package_versions ON package_versions.id = package_files.version_id").
+		Where("package_versions.package_id = ? AND package_files.name = ?", pkg.ID, fileName)
+	if version != "" {
+		query = query.Where("package_versions.version = ?", version)
+	}
+	var file models.PackageFile
+	err := query.First(&file).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrPackageNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get package file: %w", err)
+	}
+	var ver models.PackageVersion
+	if err := s.db.WithContext(ctx).First(&ver, "id = ?", file.VersionID).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to get package version: %w", err)
+	}
+	return &file, &ver, nil
+}
+
+func (s *packageService) StatFile(ctx context.Context, org *models.Organization, actorID *uuid.UUID, packageType, name, version, fileName string) (*models.PackageFile, error) {
+	pkg, err := s.readable(ctx, org, actorID, packageType, name)
+	if err != nil {
+		return nil, err
+	}
+	file, _, err := s.file(ctx, pkg, version, fileName)
+	return file, err
+}
+
+func (s *packageService) OpenFile(ctx context.Context, org *models.Organization, actorID *uuid.UUID, packageType, name, version, fileName string) (*models.PackageFile, io.ReadCloser, error) {
+	pkg, err := s.readable(ctx, org, actorID, packageType, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, ver, err := s.file(ctx, pkg, version, fileName)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := s.backend.Download(ctx, packageFilePath(file.SHA256))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open package file: %w", err)
+	}
+	s.recordDownload(ctx, org, pkg, ver, file, actorID)
+	return file, content, nil
+}
+
+// recordDownload counts a download of file on its package and version and
+// records it as an analytics event
+func (s *packageService) recordDownload(ctx context.Context, org *models.Organization, pkg *models.Package, ver *models.PackageVersion, file *models.PackageFile, actorID *uuid.UUID) {
+	db := s.db.WithContext(ctx)
+	if err := db.Model(&models.Package{}).Where("id = ?", pkg.ID).
+		UpdateColumn("download_count", gorm.Expr("download_count + 1")).Error; err != nil {
+		s.logger.WithError(err).WithField("package_id", pkg.ID).Warn("Failed to count package download")
+	}
+	if err := db.Model(&models.PackageVersion{}).Where("id = ?", ver.ID).UpdateColumns(map[string]interface{}{
+		"download_count":     gorm.Expr("download_count + 1"),
+		"last_downloaded_at": time.Now(),
+	}).Error; err != nil {
+		s.logger.WithError(err).WithField("package_id", pkg.ID).Warn("Failed to count package download")
+	}
+
+	if s.analytics == nil {
+		return
+	}
+	metadata, _ := json.Marshal(map[string]string{
+		"type":    pkg.Type,
+		"name":    pkg.Name,
+		"version": ver.Version,
+		"file":    file.Name,
+	})
+	actorType := "anonymous"
+	if actorID != nil {
+		actorType = "user"
+	}
+	event := &models.AnalyticsEvent{
+		ID:             uuid.New(),
+		EventType:      models.EventPackageDownloaded,
+		ActorID:        actorID,
+		ActorType:      actorType,
+		TargetType:     "package",
+		TargetID:       &pkg.ID,
+		OrganizationID: &org.ID,
+		Metadata:       string(metadata),
+		Size:           &file.Size,
+		Status:         "success",
+	}
+	if err := s.analytics.RecordEvent(ctx, event); err != nil {
+		s.logger.WithError(err).WithField("package_id", pkg.ID).Warn("Failed to record package download")
+	}
+}
+
+// distTags returns the npm dist-tags of pkg
+func distTags(pkg *models.Package) map[string]string {
+	tags := map[string]string{}
+	if pkg.DistTags != "" {
+		json.Unmarshal([]byte(pkg.DistTags), &tags)
+	}
+	return tags
+}
+
+func setDistTags(tx *gorm.DB, pkg *models.Package, tags map[string]string) error {
+	data, _ := json.Marshal(tags)
+	pkg.DistTags = string(data)
+	return tx.Model(pkg).Update("dist_tags", pkg.DistTags).Error
+}
+
+func (s *packageService) GetRetentionPolicy(ctx context.Context, org *models.Organization, actorID uuid.UUID) (*models.PackageRetentionPolicy, error) {
+	if err := s.authorize(ctx, org, actorID, true); err != nil {
+		return nil, err
+	}
+	policy := models.PackageRetentionPolicy{OrganizationID: org.ID, KeepVersions: 10, MaxAgeDays: 90}
+	err := s.db.WithContext(ctx).Where("organization_id = ?", org.ID).First(&policy).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get package retention policy: %w", err)
+	}
+	return &policy, nil
+}
+
+func (s *packageService) SetRetentionPolicy(ctx context.Context, org *models.Organization, actorID uuid.UUID, policy *models.PackageRetentionPolicy) (*models.PackageRetentionPolicy, error) {
+	if policy.KeepVersions < 1 || policy.MaxAgeDays < 0 || policy.KeepDownloadedDays < 0 {
+		return nil, fmt.Errorf("%w: keep_versions must be at least 1 and days cannot be negative", ErrPackageInvalid)
+	}
+	current, err := s.GetRetentionPolicy(ctx, org, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if current.ID == uuid.Nil {
+		current.ID = uuid.New()
+	}
+	current.Enabled = policy.Enabled
+	current.KeepVersions = policy.KeepVersions
+	current.MaxAgeDays = policy.MaxAgeDays
+	current.KeepDownloadedDays = policy.KeepDownloadedDays
+	if err := s.db.WithContext(ctx).Save(current).Error; err != nil {
+		return nil, fmt.Errorf("failed to save package retention policy: %w", err)
+	}
+	return current, nil
+}
+
+func (s *packageService) ApplyRetention(ctx context.Context) (*PackageRetentionResult, error) {
+	var policies []models.PackageRetentionPolicy
+	if err := s.db.WithContext(ctx).Where("enabled = ?", true).Find(&policies).Error; err != nil {
+		return nil, fmt.Errorf("failed to list package retention policies: %w", err)
+	}
+
+	result := &PackageRetentionResult{}
+	now := time.Now()
+	for _, policy := range policies {
+		var packages []models.Package
+		if err := s.db.WithContext(ctx).Where("organization_id = ?", policy.OrganizationID).Find(&packages).Error; err != nil {
+			return nil, fmt.Errorf("failed to list packages: %w", err)
+		}
+		for i := range packages {
+			var versions []models.PackageVersion
+			if err := s.db.WithContext(ctx).Where("package_id = ?", packages[i].ID).
+				Order("created_at DESC").Offset(policy.KeepVersions).Find(&versions).Error; err != nil {
+				return nil, fmt.Errorf("failed to list package versions: %w", err)
+			}
+			for j := range versions {
+				ver := &versions[j]
+				if policy.MaxAgeDays > 0 && ver.CreatedAt.After(now.AddDate(0, 0, -policy.MaxAgeDays)) {
+					continue
+				}
+				if policy.KeepDownloadedDays > 0 && ver.LastDownloadedAt != nil &&
+					ver.LastDownloadedAt.After(now.AddDate(0, 0, -policy.KeepDownloadedDays)) {
+					continue
+				}
+				deleted, err := s.deleteVersion(ctx, &packages[i], ver)
+				if err != nil {
+					return nil, err
+				}
+				result.VersionsDeleted += deleted.VersionsDeleted
+				result.FilesDeleted += deleted.FilesDeleted
+				result.BytesFreed += deleted.BytesFreed
+			}
+		}
+	}
+
+	if result.VersionsDeleted > 0 {
+		s.logger.WithFields(logrus.Fields{
+			"versions": result.VersionsDeleted,
+			"files":    result.FilesDeleted,
+			"bytes":    result.BytesFreed,
+		}).Info("Applied package retention policies")
+	}
+	return result, nil
+}
+
+// StartScheduler applies retention policies every RetentionIntervalHours
+// until ctx is cancelled. It returns immediately when packages or
+// scheduled retention are disabled.
+func (s *packageService) StartScheduler(ctx context.Context) {
+	if !s.cfg.Enabled || s.cfg.RetentionIntervalHours <= 0 {
+		return
+	}
+
+	interval := time.Duration(s.cfg.RetentionIntervalHours) * time.Hour
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job := jobs.Job{Name: "package_retention", Class: jobs.ClassGC, Interval: interval}
+			if jobs.DefaultGate().Wait(ctx, job) != nil {
+				return
+			}
+			func() {
+				defer errorreporting.Default().Recover("package_retention", nil)
+				if _, err := s.ApplyRetention(ctx); err != nil {
+					s.logger.WithError(err).Error("Failed to apply package retention policies")
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackageService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Organization{}, &models.OrganizationMember{}, &models.Package{},
+		&models.PackageVersion{}, &models.PackageFile{}, &models.PackageRetentionPolicy{}, &models.AnalyticsEvent{})
+
+	ctx := context.Background()
+	backend, err := storage.NewFilesystemBackend(storage.FilesystemConfig{BasePath: t.TempDir()})
+	require.NoError(t, err)
+	svc := NewPackageService(db, backend, NewAnalyticsService(db, nil, logrus.New()),
+		config.PackageStorage{Enabled: true, MaxFileSizeMB: 1}, logrus.New())
+
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	owner, member, outsider := uuid.New(), uuid.New(), uuid.New()
+	require.NoError(t, db.Create([]*models.OrganizationMember{
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: owner, Role: models.OrgRoleOwner},
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: member, Role: models.OrgRoleMember},
+	}).Error)
+
+	// Generic files: republishing the same content succeeds, other content
+	// conflicts
+	file, err := svc.PublishFile(ctx, org, member, models.PackageTypeGeneric, "tools", "1.0.0", "tool.tar.gz", strings.NewReader("tool v1"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(len("tool v1")), file.Size)
+	_, err = svc.PublishFile(ctx, org, member, models.PackageTypeGeneric, "tools", "1.0.0", "tool.tar.gz", strings.NewReader("tool v1"))
+	assert.NoError(t, err)
+	_, err = svc.PublishFile(ctx, org, member, models.PackageTypeGeneric, "tools", "1.0.0", "tool.tar.gz", strings.NewReader("changed"))
+	assert.ErrorIs(t, err, ErrPackageConflict)
+	_, err = svc.PublishFile(ctx, org, outsider, models.PackageTypeGeneric, "tools", "1.0.1", "tool.tar.gz", strings.NewReader("tool"))
+	assert.ErrorIs(t, err, ErrPackageForbidden)
+	_, err = svc.PublishFile(ctx, org, member, models.PackageTypeGeneric, "tools", "../1", "tool.tar.gz", strings.NewReader("tool"))
+	assert.ErrorIs(t, err, ErrPackageInvalid)
+	_, err = svc.PublishFile(ctx, org, member, models.PackageTypeGeneric, "tools", "2.0.0", "big.bin", strings.NewReader(strings.Repeat("x", 1024*1024+1)))
+	assert.ErrorIs(t, err, ErrPackageTooLarge)
+
+	// Private packages are hidden from outsiders and challenge anonymous
+	// clients
+	_, content, err := svc.OpenFile(ctx, org, &member, models.PackageTypeGeneric, "tools", "1.0.0", "tool.tar.gz")
+	require.NoError(t, err)
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+	content.Close()
+	assert.Equal(t, "tool v1", string(data))
+	_, err = svc.StatFile(ctx, org, &outsider, models.PackageTypeGeneric, "tools", "1.0.0", "tool.tar.gz")
+	assert.ErrorIs(t, err, ErrPackageNotFound)
+	_, err = svc.StatFile(ctx, org, nil, models.PackageTypeGeneric, "tools", "1.0.0", "tool.tar.gz")
+	assert.ErrorIs(t, err, ErrPackageForbidden)
+
+	packages, err := svc.ListPackages(ctx, org, &member, "")
+	require.NoError(t, err)
+	require.Len(t, packages, 1)
+	tools := packages[0]
+	_, err = svc.SetVisibility(ctx, org, member, tools.ID, models.VisibilityPublic)
+	assert.ErrorIs(t, err, ErrPackageForbidden, "members cannot manage packages")
+	_, err = svc.SetVisibility(ctx, org, owner, tools.ID, models.VisibilityPublic)
+	require.NoError(t, err)
+	_, content, err = svc.OpenFile(ctx, org, nil, models.PackageTypeGeneric, "tools", "1.0.0", "tool.tar.gz")
+	require.NoError(t, err)
+	content.Close()
+
+	// Downloads are counted and recorded as analytics events
+	pkg, err := svc.GetPackage(ctx, org, &member, tools.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), pkg.DownloadCount)
+	require.Len(t, pkg.Versions, 1)
+	assert.Equal(t, int64(2), pkg.Versions[0].DownloadCount)
+	assert.NotNil(t, pkg.Versions[0].LastDownloadedAt)
+	var events int64
+	require.NoError(t, db.Model(&models.AnalyticsEvent{}).Where("event_type = ? AND target_id = ?",
+		models.EventPackageDownloaded, tools.ID).Count(&events).Error)
+	assert.Equal(t, int64(2), events)
+
+	// npm publish documents carry one version and its tarball
+	publish := func(version string) []byte {
+		doc, err := json.Marshal(map[string]interface{}{
+			"name":      "@acme/ui",
+			"dist-tags": map[string]string{"latest": version},
+			"versions": map[string]interface{}{
+				version: map[string]interface{}{"name": "@acme/ui", "version": version, "main": "index.js"},
+			},
+			"_attachments": map[string]interface{}{
+				"ui-" + version + ".tgz": map[string]interface{}{"data": base64.StdEncoding.EncodeToString([]byte("tarball " + version))},
+			},
+		})
+		require.NoError(t, err)
+		return doc
+	}
+	_, err = svc.PublishNpm(ctx, org, member, "@acme/ui", publish("1.0.0"))
+	require.NoError(t, err)
+	_, err = svc.PublishNpm(ctx, org, member, "@acme/ui", publish("1.1.0"))
+	require.NoError(t, err)
+	_, err = svc.PublishNpm(ctx, org, member, "@acme/ui", publish("1.1.0"))
+	assert.ErrorIs(t, err, ErrPackageConflict)
+	_, err = svc.PublishNpm(ctx, org, member, "@acme/other", publish("1.0.0"))
+	assert.ErrorIs(t, err, ErrPackageInvalid)
+
+	packument, err := svc.NpmPackument(ctx, org, &member, "@acme/ui", "https://hub.example.com/api/v1/orgs/acme/packages/npm")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"latest": "1.1.0"}, packument["dist-tags"])
+	versions := packument["versions"].(map[string]interface{})
+	require.Len(t, versions, 2)
+	dist := versions["1.0.0"].(map[string]interface{})["dist"].(map[string]interface{})
+	assert.Equal(t, "https://hub.example.com/api/v1/orgs/acme/packages/npm/@acme/ui/-/ui-1.0.0.tgz", dist["tarball"])
+	assert.NotEmpty(t, dist["shasum"])
+	_, content, err = svc.OpenFile(ctx, org, &member, models.PackageTypeNpm, "@acme/ui", "", "ui-1.0.0.tgz")
+	require.NoError(t, err)
+	data, err = io.ReadAll(content)
+	require.NoError(t, err)
+	content.Close()
+	assert.Equal(t, "tarball 1.0.0", string(data))
+
+	// Maven metadata lists the published versions
+	mavenPath, err := ParseMavenPath("/com/acme/lib/1.0/lib-1.0.jar")
+	require.NoError(t, err)
+	assert.Equal(t, MavenPath{Name: "com.acme:lib", Version: "1.0", File: "lib-1.0.jar"}, *mavenPath)
+	mavenPath, err = ParseMavenPath("com/acme/lib/maven-metadata.xml.sha1")
+	require.NoError(t, err)
+	assert.Equal(t, MavenPath{Name: "com.acme:lib", File: "maven-metadata.xml.sha1"}, *mavenPath)
+	target, algorithm, ok := SplitMavenChecksum("lib-1.0.jar.sha1")
+	assert.True(t, ok)
+	assert.Equal(t, "lib-1.0.jar", target)
+	assert.Equal(t, "sha1", algorithm)
+
+	for _, version := range []string{"1.0", "1.1", "2.0-SNAPSHOT"} {
+		_, err := svc.PublishFile(ctx, org, member, models.PackageTypeMaven, "com.acme:lib", version, "lib-"+version+".jar", strings.NewReader("jar "+version))
+		require.NoError(t, err)
+	}
+	metadata, err := svc.MavenMetadata(ctx, org, &member, "com.acme:lib")
+	require.NoError(t, err)
+	assert.Contains(t, string(metadata), "<groupId>com.acme</groupId>")
+	assert.Contains(t, string(metadata), "<latest>2.0-SNAPSHOT</latest>")
+	assert.Contains(t, string(metadata), "<release>1.1</release>")
+	jar, err := svc.StatFile(ctx, org, &member, models.PackageTypeMaven, "com.acme:lib", "1.0", "lib-1.0.jar")
+	require.NoError(t, err)
+	assert.Len(t, MavenChecksum(jar, "sha1"), 40)
+
+	// Deleting the tagged npm version drops its dist-tag
+	packages, err = svc.ListPackages(ctx, org, &member, models.PackageTypeNpm)
+	require.NoError(t, err)
+	require.Len(t, packages, 1)
+	require.NoError(t, svc.DeleteVersion(ctx, org, owner, packages[0].ID, "1.1.0"))
+	packument, err = svc.NpmPackument(ctx, org, &member, "@acme/ui", "https://hub.example.com")
+	require.NoError(t, err)
+	assert.Empty(t, packument["dist-tags"])
+	assert.ErrorIs(t, svc.DeleteVersion(ctx, org, owner, packages[0].ID, "1.1.0"), ErrPackageNotFound)
+
+	// Retention keeps the newest versions and recently downloaded ones
+	_, err = svc.SetRetentionPolicy(ctx, org, member, &models.PackageRetentionPolicy{Enabled: true, KeepVersions: 1})
+	assert.ErrorIs(t, err, ErrPackageForbidden)
+	_, err = svc.SetRetentionPolicy(ctx, org, owner, &models.PackageRetentionPolicy{Enabled: true, KeepVersions: 1, KeepDownloadedDays: 7})
+	require.NoError(t, err)
+	old := time.Now().AddDate(0, 0, -30)
+	require.NoError(t, db.Model(&models.PackageVersion{}).Where("version = ?", "1.0").Update("created_at", old).Error)
+	require.NoError(t, db.Model(&models.PackageVersion{}).Where("version = ?", "1.1").Update("created_at", old.Add(time.Hour)).Error)
+	_, content, err = svc.OpenFile(ctx, org, &member, models.PackageTypeMaven, "com.acme:lib", "1.1", "lib-1.1.jar")
+	require.NoError(t, err)
+	content.Close()
+
+	result, err := svc.ApplyRetention(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.VersionsDeleted, "only the old maven version that was never downloaded is deleted")
+	assert.Equal(t, int64(len("jar 1.0")), result.BytesFreed)
+	_, err = svc.StatFile(ctx, org, &member, models.PackageTypeMaven, "com.acme:lib", "1.0", "lib-1.0.jar")
+	assert.ErrorIs(t, err, ErrPackageNotFound)
+	_, err = svc.StatFile(ctx, org, &member, models.PackageTypeMaven, "com.acme:lib", "1.1", "lib-1.1.jar")
+	assert.NoError(t, err)
+}
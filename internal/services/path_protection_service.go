@@ -0,0 +1,923 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// PathProtectionService manages sensitive path rules and evaluates the
+// review requirements a pull request must meet before it can be merged
+type PathProtectionService interface {
+	ListRules(ctx context.Context, repoID uuid.UUID) ([]*models.PathProtectionRule, error)
+	CreateRule(ctx context.Context, repoID uuid.UUID, req PathProtectionRuleRequest) (*models.PathProtectionRule, error)
+	UpdateRule(ctx context.Context, repoID, ruleID uuid.UUID, req PathProtectionRuleRequest) (*models.PathProtectionRule, error)
+	DeleteRule(ctx context.Context, repoID, ruleID uuid.UUID) error
+
+	EvaluatePullRequest(ctx context.Context, pr *models.PullRequest) (*ReviewRequirements, error)
+	// SimulateProtection reports what a pull request into branch would have
+	// to satisfy, as if it had no reviews or checks yet
+	SimulateProtection(ctx context.Context, repo *models.Repository, branch string, req ProtectionSimulationRequest) (*ProtectionSimulation, error)
+	// ValidateCodeOwners checks CODEOWNERS content, or the repository's
+	// CODEOWNERS file on ref when content is nil
+	ValidateCodeOwners(ctx context.Context, repo *models.Repository, ref string, content *string) (*CodeOwnersValidation, error)
+}
+
+// PathProtectionRuleRequest creates or updates a path protection rule
+type PathProtectionRuleRequest struct {
+	Pattern           *string   `json:"pattern"`
+	BranchPattern     *string   `json:"branch_pattern"`
+	RequiredApprovals *int      `json:"required_approvals"`
+	RequiredTeams     *[]string `json:"required_teams"`
+	Description       *string   `json:"description"`
+}
+
+// ReviewRequirements is the outcome of evaluating a pull request against
+// branch protection, path protection rules and the merge checklist
+type ReviewRequirements struct {
+	Approvals               int                    `json:"approvals"`
+	Approvers               []string               `json:"approvers"`
+	BranchRequiredApprovals int                    `json:"branch_required_approvals"`
+	PathRules               []*PathRuleEvaluation  `json:"path_rules"`
+	Checklist               []*ChecklistItemStatus `json:"checklist"`
+	// UnresolvedThreads is only counted when branch protection requires
+	// conversations to be resolved
+	RequireConversationResolution bool  `json:"require_conversation_resolution"`
+	UnresolvedThreads             int64 `json:"unresolved_threads"`
+	// UnverifiedCommits is only listed when branch protection requires
+	// signed commits
+	RequireSignedCommits bool     `json:"require_signed_commits"`
+	UnverifiedCommits    []string `json:"unverified_commits,omitempty"`
+	// StatusChecks is only set when the base branch requires status checks
+	StatusChecks *StatusCheckEvaluation `json:"status_checks,omitempty"`
+	// ChangeTicket is only set when the base branch requires change tickets
+	ChangeTicket *ChangeTicketEvaluation `json:"change_ticket,omitempty"`
+	Satisfied    bool                    `json:"satisfied"`
+	Reasons      []string                `json:"reasons,omitempty"`
+}
+
+// PathRuleEvaluation reports how a pull request fares against one path rule
+type PathRuleEvaluation struct {
+	Rule         *models.PathProtectionRule `json:"rule"`
+	MatchedPaths []string                   `json:"matched_paths"`
+	MissingTeams []string                   `json:"missing_teams,omitempty"`
+	Satisfied    bool                       `json:"satisfied"`
+}
+
+// MergeBlockedError is returned when a pull request does not yet meet its
+// review requirements
+type MergeBlockedError struct {
+	Requirements *ReviewRequirements
+}
+
+func (e *MergeBlockedError) Error() string {
+	return "pull request does not meet review requirements: " + strings.Join(e.Requirements.Reasons, "; ")
+}
+
+// ProtectionSimulationRequest describes a hypothetical pull request. The
+// changed files are listed explicitly or taken from comparing Head with the
+// protected branch.
+type ProtectionSimulationRequest struct {
+	Head  string   `json:"head"`
+	Files []string `json:"files"`
+}
+
+// ProtectionSimulation is what a hypothetical pull request must satisfy
+type ProtectionSimulation struct {
+	Branch                        string                `json:"branch"`
+	Protected                     bool                  `json:"protected"`
+	EnforceAdmins                 bool                  `json:"enforce_admins"`
+	RequiredStatusChecks          []string              `json:"required_status_checks"`
+	StrictStatusChecks            bool                  `json:"strict_status_checks"`
+	RequiredApprovals             int                   `json:"required_approvals"`
+	BranchRequiredApprovals       int                   `json:"branch_required_approvals"`
+	DismissStaleReviews           bool                  `json:"dismiss_stale_reviews"`
+	RequireCodeOwnerReviews       bool                  `json:"require_code_owner_reviews"`
+	RequireConversationResolution bool                  `json:"require_conversation_resolution"`
+	RequireSignedCommits          bool                  `json:"require_signed_commits"`
+	CodeOwnersPath                string                `json:"codeowners_path,omitempty"`
+	CodeOwners                    []*CodeOwnerReview    `json:"code_owners"`
+	UnownedPaths                  []string              `json:"unowned_paths,omitempty"`
+	PathRules                     []*PathRuleEvaluation `json:"path_rules"`
+	Files                         []string              `json:"files"`
+	Requirements                  []string              `json:"requirements"`
+}
+
+// CodeOwnerReview is a set of owners, any of whom can approve the paths they own
+type CodeOwnerReview struct {
+	Owners []string `json:"owners"`
+	Paths  []string `json:"paths"`
+}
+
+// CodeOwnersValidation is the outcome of validating a CODEOWNERS file
+type CodeOwnersValidation struct {
+	Path     string              `json:"path,omitempty"`
+	Valid    bool                `json:"valid"`
+	Rules    []CodeOwnersRule    `json:"rules"`
+	Problems []CodeOwnersProblem `json:"problems"`
+}
+
+var (
+	ErrPathRuleNotFound   = errors.New("path protection rule not found")
+	ErrInvalidPathRule    = errors.New("invalid path protection rule")
+	ErrCodeOwnersNotFound = errors.New("CODEOWNERS file not found")
+)
+
+type pathProtectionService struct {
+	db           *gorm.DB
+	gitService   git.GitService
+	repoService  RepositoryService
+	checklist    MergeChecklistService
+	statusChecks RequiredStatusCheckService
+	changes      ChangeManagementService
+	signing      SigningKeyService
+	logger       *logrus.Logger
+}
+
+// NewPathProtectionService creates a new PathProtectionService
+func NewPathProtectionService(db *gorm.DB, gitService git.GitService, repoService RepositoryService, logger *logrus.Logger) PathProtectionService {
+	return &pathProtectionService{
+		db:           db,
+		gitService:   gitService,
+		repoService:  repoService,
+		checklist:    NewMergeChecklistService(db, logger),
+		statusChecks: NewRequiredStatusCheckService(db, logger),
+		changes:      NewChangeManagementService(db, logger),
+		signing:      NewSigningKeyService(db, logger),
+		logger:       logger,
+	}
+}
+
+func (s *pathProtectionService) ListRules(ctx context.Context, repoID uuid.UUID) ([]*models.PathProtectionRule, error) {
+	var rules []*models.PathProtectionRule
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repoID).Order("pattern ASC").Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to list path protection rules: %w", err)
+	}
+	return rules, nil
+}
+
+func (s *pathProtectionService) CreateRule(ctx context.Context, repoID uuid.UUID, req PathProtectionRuleRequest) (*models.PathProtectionRule, error) {
+	if req.Pattern == nil {
+		return nil, fmt.Errorf("%w: pattern is required", ErrInvalidPathRule)
+	}
+
+	rule := &models.PathProtectionRule{
+		ID:                uuid.New(),
+		RepositoryID:      repoID,
+		BranchPattern:     "*",
+		RequiredApprovals: 2,
+	}
+	if err := s.applyRuleRequest(ctx, rule, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(rule).Error; err != nil {
+		return nil, fmt.Errorf("failed to create path protection rule: %w", err)
+	}
+	return rule, nil
+}
+
+func (s *pathProtectionService) UpdateRule(ctx context.Context, repoID, ruleID uuid.UUID, req PathProtectionRuleRequest) (*models.PathProtectionRule, error) {
+	var rule models.PathProtectionRule
+	if err := s.db.WithContext(ctx).Where("id = ? AND repository_id = ?", ruleID, repoID).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrPathRuleNotFound
+		}
+		return nil, err
+	}
+	if err := s.applyRuleRequest(ctx, &rule, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Save(&rule).Error; err != nil {
+		return nil, fmt.Errorf("failed to update path protection rule: %w", err)
+	}
+	return &rule, nil
+}
+
+func (s *pathProtectionService) DeleteRule(ctx context.Context, repoID, ruleID uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND repository_id = ?", ruleID, repoID).Delete(&models.PathProtectionRule{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete path protection rule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrPathRuleNotFound
+	}
+	return nil
+}
+
+func (s *pathProtectionService) applyRuleRequest(ctx context.Context, rule *models.PathProtectionRule, req PathProtectionRuleRequest) error {
+	if req.Pattern != nil {
+		pattern := strings.TrimSpace(*req.Pattern)
+		if pattern == "" || pattern == "/" {
+			return fmt.Errorf("%w: pattern is required", ErrInvalidPathRule)
+		}
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return fmt.Errorf("%w: malformed pattern %q", ErrInvalidPathRule, pattern)
+		}
+		rule.Pattern = pattern
+	}
+	if req.BranchPattern != nil {
+		rule.BranchPattern = strings.TrimSpace(*req.BranchPattern)
+		if rule.BranchPattern == "" {
+			rule.BranchPattern = "*"
+		}
+	}
+	if req.RequiredApprovals != nil {
+		if *req.RequiredApprovals < 0 || *req.RequiredApprovals > 10 {
+			return fmt.Errorf("%w: required_approvals must be between 0 and 10", ErrInvalidPathRule)
+		}
+		rule.RequiredApprovals = *req.RequiredApprovals
+	}
+	if req.RequiredTeams != nil {
+		if err := s.validateTeams(ctx, rule.RepositoryID, *req.RequiredTeams); err != nil {
+			return err
+		}
+		rule.RequiredTeams = *req.RequiredTeams
+	}
+	if req.Description != nil {
+		rule.Description = *req.Description
+	}
+	if rule.RequiredApprovals == 0 && len(rule.RequiredTeams) == 0 {
+		return fmt.Errorf("%w: a rule must require approvals or team review", ErrInvalidPathRule)
+	}
+	return nil
+}
+
+// validateTeams checks that every team exists in the organization owning the repository
+func (s *pathProtectionService) validateTeams(ctx context.Context, repoID uuid.UUID, teams []string) error {
+	if len(teams) == 0 {
+		return nil
+	}
+
+	var repo models.Repository
+	if err := s.db.WithContext(ctx).First(&repo, "id = ?", repoID).Error; err != nil {
+		return fmt.Errorf("failed to load repository: %w", err)
+	}
+	if repo.OwnerType != models.OwnerTypeOrganization {
+		return fmt.Errorf("%w: team review requires an organization repository", ErrInvalidPathRule)
+	}
+
+	var found []string
+	if err := s.db.WithContext(ctx).Model(&models.Team{}).
+		Where("organization_id = ? AND name IN ?", repo.OwnerID, teams).
+		Pluck("name", &found).Error; err != nil {
+		return fmt.Errorf("failed to look up teams: %w", err)
+	}
+	known := make(map[string]bool, len(found))
+	for _, name := range found {
+		known[name] = true
+	}
+	for _, name := range teams {
+		if !known[name] {
+			return fmt.Errorf("%w: team %q not found", ErrInvalidPathRule, name)
+		}
+	}
+	return nil
+}
+
+// EvaluatePullRequest checks the pull request's approvals against the branch
+// protection rule of its base branch and every path rule its changes match,
+// and checks that every applicable merge checklist item is checked off
+func (s *pathProtectionService) EvaluatePullRequest(ctx context.Context, pr *models.PullRequest) (*ReviewRequirements, error) {
+	reqs := &ReviewRequirements{
+		PathRules: []*PathRuleEvaluation{},
+		Satisfied: true,
+	}
+
+	// Branch protection
+	dismissStale := false
+	var branchRules []*models.BranchProtectionRule
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", pr.RepositoryID).Find(&branchRules).Error; err != nil {
+		return nil, fmt.Errorf("failed to load branch protection: %w", err)
+	}
+	for _, rule := range branchRules {
+		if !matchPattern(rule.Pattern, pr.BaseBranch) {
+			continue
+		}
+		reqs.RequireConversationResolution = reqs.RequireConversationResolution || rule.RequireConversationResolution
+		reqs.RequireSignedCommits = reqs.RequireSignedCommits || rule.RequireSignedCommits
+		if rule.RequiredPullRequestReviews == "" {
+			continue
+		}
+		var reviews RequiredPullRequestReviews
+		if err := json.Unmarshal([]byte(rule.RequiredPullRequestReviews), &reviews); err != nil {
+			continue
+		}
+		if reviews.RequiredApprovingReviewCount > reqs.BranchRequiredApprovals {
+			reqs.BranchRequiredApprovals = reviews.RequiredApprovingReviewCount
+		}
+		dismissStale = dismissStale || reviews.DismissStaleReviews
+	}
+
+	// Approvals on an older head commit do not count when stale reviews are
+	// dismissed
+	currentSHA := ""
+	if dismissStale {
+		currentSHA = s.headSHA(ctx, pr)
+	}
+	approvers, requesters, err := s.verdicts(ctx, pr, currentSHA)
+	if err != nil {
+		return nil, err
+	}
+	reqs.Approvals = len(approvers)
+	reqs.Approvers = make([]string, 0, len(approvers))
+	for _, username := range approvers {
+		reqs.Approvers = append(reqs.Approvers, username)
+	}
+	sort.Strings(reqs.Approvers)
+
+	if reqs.BranchRequiredApprovals > 0 {
+		names := make([]string, 0, len(requesters))
+		for _, username := range requesters {
+			names = append(names, username)
+		}
+		sort.Strings(names)
+		for _, username := range names {
+			reqs.Satisfied = false
+			reqs.Reasons = append(reqs.Reasons, fmt.Sprintf("changes requested by %s", username))
+		}
+	}
+	if reqs.Approvals < reqs.BranchRequiredApprovals {
+		reqs.Satisfied = false
+		reqs.Reasons = append(reqs.Reasons, fmt.Sprintf("branch %s requires %d approving reviews, has %d", pr.BaseBranch, reqs.BranchRequiredApprovals, reqs.Approvals))
+	}
+	if reqs.RequireConversationResolution {
+		counts, err := unresolvedReviewThreads(ctx, s.db, pr.ID)
+		if err != nil {
+			return nil, err
+		}
+		reqs.UnresolvedThreads = counts[pr.ID]
+		if reqs.UnresolvedThreads > 0 {
+			reqs.Satisfied = false
+			reqs.Reasons = append(reqs.Reasons, fmt.Sprintf("branch %s requires all conversations to be resolved, %d unresolved", pr.BaseBranch, reqs.UnresolvedThreads))
+		}
+	}
+	if reqs.RequireSignedCommits {
+		reqs.UnverifiedCommits, err = s.unverifiedCommits(ctx, pr)
+		if err != nil {
+			return nil, err
+		}
+		if len(reqs.UnverifiedCommits) > 0 {
+			reqs.Satisfied = false
+			reqs.Reasons = append(reqs.Reasons, fmt.Sprintf("branch %s requires signed commits, %d not verified", pr.BaseBranch, len(reqs.UnverifiedCommits)))
+		}
+	}
+
+	// Required status checks, against the statuses of the head commit
+	required, err := s.statusChecks.Required(ctx, pr.RepositoryID, pr.BaseBranch)
+	if err != nil {
+		return nil, err
+	}
+	if len(required) > 0 {
+		reqs.StatusChecks, err = s.statusChecks.Evaluate(ctx, pr.RepositoryID, s.headSHA(ctx, pr), required)
+		if err != nil {
+			return nil, err
+		}
+		for _, check := range reqs.StatusChecks.Checks {
+			if !check.Satisfied {
+				reqs.Satisfied = false
+				reqs.Reasons = append(reqs.Reasons, fmt.Sprintf("status check %s is %s", check.Pattern, check.State))
+			}
+		}
+	}
+
+	// Merge checklist
+	reqs.Checklist, err = s.checklist.Evaluate(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range reqs.Checklist {
+		if !status.Checked {
+			reqs.Satisfied = false
+			reqs.Reasons = append(reqs.Reasons, fmt.Sprintf("checklist item %q is not checked", status.Item.Title))
+		}
+	}
+
+	// Change ticket
+	reqs.ChangeTicket, err = s.changes.Evaluate(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+	if reqs.ChangeTicket != nil && !reqs.ChangeTicket.Satisfied {
+		reqs.Satisfied = false
+		reqs.Reasons = append(reqs.Reasons, reqs.ChangeTicket.Reason)
+	}
+
+	// Path protection
+	rules, err := s.ListRules(ctx, pr.RepositoryID)
+	if err != nil {
+		return nil, err
+	}
+	var applicable []*models.PathProtectionRule
+	for _, rule := range rules {
+		if matchPattern(rule.BranchPattern, pr.BaseBranch) {
+			applicable = append(applicable, rule)
+		}
+	}
+	if len(applicable) == 0 {
+		return reqs, nil
+	}
+
+	files, err := s.changedFiles(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range applicable {
+		eval := &PathRuleEvaluation{Rule: rule, Satisfied: true}
+		for _, file := range files {
+			if MatchPathPattern(rule.Pattern, file) {
+				eval.MatchedPaths = append(eval.MatchedPaths, file)
+			}
+		}
+		if len(eval.MatchedPaths) == 0 {
+			continue
+		}
+
+		if reqs.Approvals < rule.RequiredApprovals {
+			eval.Satisfied = false
+			reqs.Reasons = append(reqs.Reasons, fmt.Sprintf("changes to %s require %d approving reviews, has %d", rule.Pattern, rule.RequiredApprovals, reqs.Approvals))
+		}
+		if len(rule.RequiredTeams) > 0 {
+			eval.MissingTeams, err = s.missingTeams(ctx, pr.RepositoryID, rule.RequiredTeams, approvers)
+			if err != nil {
+				return nil, err
+			}
+			if len(eval.MissingTeams) > 0 {
+				eval.Satisfied = false
+				reqs.Reasons = append(reqs.Reasons, fmt.Sprintf("changes to %s require review from %s", rule.Pattern, strings.Join(eval.MissingTeams, ", ")))
+			}
+		}
+		if !eval.Satisfied {
+			reqs.Satisfied = false
+		}
+		reqs.PathRules = append(reqs.PathRules, eval)
+	}
+
+	return reqs, nil
+}
+
+func (s *pathProtectionService) SimulateProtection(ctx context.Context, repo *models.Repository, branch string, req ProtectionSimulationRequest) (*ProtectionSimulation, error) {
+	sim := &ProtectionSimulation{
+		Branch:               branch,
+		RequiredStatusChecks: []string{},
+		CodeOwners:           []*CodeOwnerReview{},
+		PathRules:            []*PathRuleEvaluation{},
+		Files:                req.Files,
+		Requirements:         []string{},
+	}
+	if sim.Files == nil && req.Head != "" {
+		files, err := s.changedFiles(ctx, &models.PullRequest{RepositoryID: repo.ID, BaseBranch: branch, HeadBranch: req.Head})
+		if err != nil {
+			return nil, err
+		}
+		sim.Files = files
+	}
+	if sim.Files == nil {
+		sim.Files = []string{}
+	}
+
+	// Branch protection
+	var branchRules []*models.BranchProtectionRule
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repo.ID).Find(&branchRules).Error; err != nil {
+		return nil, fmt.Errorf("failed to load branch protection: %w", err)
+	}
+	checks := make(map[string]bool)
+	for _, rule := range branchRules {
+		if !matchPattern(rule.Pattern, branch) {
+			continue
+		}
+		sim.Protected = true
+		sim.EnforceAdmins = sim.EnforceAdmins || rule.EnforceAdmins
+		sim.RequireConversationResolution = sim.RequireConversationResolution || rule.RequireConversationResolution
+		sim.RequireSignedCommits = sim.RequireSignedCommits || rule.RequireSignedCommits
+		var statusChecks RequiredStatusChecks
+		if rule.RequiredStatusChecks != "" && json.Unmarshal([]byte(rule.RequiredStatusChecks), &statusChecks) == nil {
+			sim.StrictStatusChecks = sim.StrictStatusChecks || statusChecks.Strict
+			for _, check := range statusChecks.Contexts {
+				if !checks[check] {
+					checks[check] = true
+					sim.RequiredStatusChecks = append(sim.RequiredStatusChecks, check)
+				}
+			}
+		}
+		var reviews RequiredPullRequestReviews
+		if rule.RequiredPullRequestReviews != "" && json.Unmarshal([]byte(rule.RequiredPullRequestReviews), &reviews) == nil {
+			if reviews.RequiredApprovingReviewCount > sim.BranchRequiredApprovals {
+				sim.BranchRequiredApprovals = reviews.RequiredApprovingReviewCount
+			}
+			sim.DismissStaleReviews = sim.DismissStaleReviews || reviews.DismissStaleReviews
+			sim.RequireCodeOwnerReviews = sim.RequireCodeOwnerReviews || reviews.RequireCodeOwnerReviews
+		}
+	}
+	required, err := s.statusChecks.Required(ctx, repo.ID, branch)
+	if err != nil {
+		return nil, err
+	}
+	for _, check := range required {
+		if !checks[check.Pattern] {
+			checks[check.Pattern] = true
+			sim.RequiredStatusChecks = append(sim.RequiredStatusChecks, check.Pattern)
+		}
+	}
+	sort.Strings(sim.RequiredStatusChecks)
+	sim.RequiredApprovals = sim.BranchRequiredApprovals
+	for _, check := range sim.RequiredStatusChecks {
+		sim.Requirements = append(sim.Requirements, fmt.Sprintf("status check %s must pass", check))
+	}
+	if sim.StrictStatusChecks {
+		sim.Requirements = append(sim.Requirements, fmt.Sprintf("head branch must be up to date with %s", branch))
+	}
+	if sim.RequireConversationResolution {
+		sim.Requirements = append(sim.Requirements, "all review conversations must be resolved")
+	}
+	if sim.RequireSignedCommits {
+		sim.Requirements = append(sim.Requirements, "commits must have verified signatures")
+	}
+
+	// Path protection
+	rules, err := s.ListRules(ctx, repo.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if !matchPattern(rule.BranchPattern, branch) {
+			continue
+		}
+		eval := &PathRuleEvaluation{Rule: rule, MissingTeams: rule.RequiredTeams}
+		for _, file := range sim.Files {
+			if MatchPathPattern(rule.Pattern, file) {
+				eval.MatchedPaths = append(eval.MatchedPaths, file)
+			}
+		}
+		if len(eval.MatchedPaths) == 0 {
+			continue
+		}
+		eval.Satisfied = rule.RequiredApprovals == 0 && len(rule.RequiredTeams) == 0
+		if rule.RequiredApprovals > sim.RequiredApprovals {
+			sim.RequiredApprovals = rule.RequiredApprovals
+		}
+		if len(rule.RequiredTeams) > 0 {
+			sim.Requirements = append(sim.Requirements, fmt.Sprintf("changes to %s require review from %s", rule.Pattern, strings.Join(rule.RequiredTeams, ", ")))
+		}
+		sim.PathRules = append(sim.PathRules, eval)
+	}
+	if sim.RequiredApprovals > 0 {
+		sim.Requirements = append(sim.Requirements, fmt.Sprintf("%d approving reviews", sim.RequiredApprovals))
+	}
+
+	// Code owners
+	owners, path, err := s.loadCodeOwners(ctx, repo, branch)
+	if err != nil && !errors.Is(err, ErrCodeOwnersNotFound) {
+		return nil, err
+	}
+	if owners != nil {
+		sim.CodeOwnersPath = path
+		groups := make(map[string]*CodeOwnerReview)
+		for _, file := range sim.Files {
+			fileOwners := owners.OwnersOf(file)
+			if len(fileOwners) == 0 {
+				sim.UnownedPaths = append(sim.UnownedPaths, file)
+				continue
+			}
+			key := strings.Join(fileOwners, " ")
+			if groups[key] == nil {
+				groups[key] = &CodeOwnerReview{Owners: fileOwners}
+				sim.CodeOwners = append(sim.CodeOwners, groups[key])
+			}
+			groups[key].Paths = append(groups[key].Paths, file)
+		}
+	}
+	if sim.RequireCodeOwnerReviews {
+		for _, review := range sim.CodeOwners {
+			sim.Requirements = append(sim.Requirements, fmt.Sprintf("code owner review from %s for %s", strings.Join(review.Owners, " or "), strings.Join(review.Paths, ", ")))
+		}
+	}
+
+	return sim, nil
+}
+
+func (s *pathProtectionService) ValidateCodeOwners(ctx context.Context, repo *models.Repository, ref string, content *string) (*CodeOwnersValidation, error) {
+	validation := &CodeOwnersValidation{}
+	if content == nil {
+		if ref == "" {
+			ref = repo.DefaultBranch
+		}
+		path, text, err := s.readCodeOwners(ctx, repo, ref)
+		if err != nil {
+			return nil, err
+		}
+		validation.Path = path
+		content = &text
+	}
+
+	owners, problems := ParseCodeOwners(*content)
+	validation.Rules = owners.Rules
+	if validation.Rules == nil {
+		validation.Rules = []CodeOwnersRule{}
+	}
+
+	resolved := make(map[string]string)
+	for _, rule := range owners.Rules {
+		for _, owner := range rule.Owners {
+			message, seen := resolved[owner]
+			if !seen {
+				var err error
+				if message, err = s.checkCodeOwner(ctx, repo, owner); err != nil {
+					return nil, err
+				}
+				resolved[owner] = message
+			}
+			if message != "" {
+				problems = append(problems, CodeOwnersProblem{Line: rule.Line, Kind: "unknown_owner", Owner: owner, Source: rule.Pattern + " " + strings.Join(rule.Owners, " "), Message: message})
+			}
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Line < problems[j].Line })
+
+	validation.Problems = problems
+	if validation.Problems == nil {
+		validation.Problems = []CodeOwnersProblem{}
+	}
+	validation.Valid = len(validation.Problems) == 0
+	return validation, nil
+}
+
+// checkCodeOwner returns why an owner cannot own code in the repository,
+// or "" if it can
+func (s *pathProtectionService) checkCodeOwner(ctx context.Context, repo *models.Repository, owner string) (string, error) {
+	db := s.db.WithContext(ctx)
+	var count int64
+
+	if !strings.HasPrefix(owner, "@") {
+		if err := db.Model(&models.User{}).Where("LOWER(email) = ?", strings.ToLower(owner)).Count(&count).Error; err != nil {
+			return "", fmt.Errorf("failed to look up code owner: %w", err)
+		}
+		if count == 0 {
+			return "no user has the email address " + owner, nil
+		}
+		return "", nil
+	}
+
+	name := strings.TrimPrefix(owner, "@")
+	if org, team, ok := strings.Cut(name, "/"); ok {
+		var organization models.Organization
+		if err := db.Where("LOWER(name) = ?", strings.ToLower(org)).First(&organization).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "organization " + org + " does not exist", nil
+			}
+			return "", fmt.Errorf("failed to look up code owner: %w", err)
+		}
+		if repo.OwnerType != models.OwnerTypeOrganization || repo.OwnerID != organization.ID {
+			return "team " + owner + " does not belong to the repository's organization", nil
+		}
+		if err := db.Model(&models.Team{}).Where("organization_id = ? AND LOWER(name) = ?", organization.ID, strings.ToLower(team)).Count(&count).Error; err != nil {
+			return "", fmt.Errorf("failed to look up code owner: %w", err)
+		}
+		if count == 0 {
+			return "team " + owner + " does not exist", nil
+		}
+		return "", nil
+	}
+
+	if err := db.Model(&models.User{}).Where("LOWER(username) = ?", strings.ToLower(name)).Count(&count).Error; err != nil {
+		return "", fmt.Errorf("failed to look up code owner: %w", err)
+	}
+	if count > 0 {
+		return "", nil
+	}
+	if err := db.Model(&models.Organization{}).Where("LOWER(name) = ?", strings.ToLower(name)).Count(&count).Error; err != nil {
+		return "", fmt.Errorf("failed to look up code owner: %w", err)
+	}
+	if count > 0 {
+		return owner + " is an organization; name one of its teams instead", nil
+	}
+	return "user " + owner + " does not exist", nil
+}
+
+// loadCodeOwners parses the repository's CODEOWNERS file on ref
+func (s *pathProtectionService) loadCodeOwners(ctx context.Context, repo *models.Repository, ref string) (*CodeOwners, string, error) {
+	path, content, err := s.readCodeOwners(ctx, repo, ref)
+	if err != nil {
+		return nil, "", err
+	}
+	owners, _ := ParseCodeOwners(content)
+	return owners, path, nil
+}
+
+// readCodeOwners returns the first CODEOWNERS file found on ref
+func (s *pathProtectionService) readCodeOwners(ctx context.Context, repo *models.Repository, ref string) (string, string, error) {
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, repo.ID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get repository path: %w", err)
+	}
+	for _, path := range CodeOwnersPaths {
+		file, err := s.gitService.GetFile(ctx, repoPath, ref, path)
+		if err != nil {
+			continue
+		}
+		if file.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(file.Content)
+			if err != nil {
+				continue
+			}
+			return path, string(decoded), nil
+		}
+		return path, file.Content, nil
+	}
+	return "", "", ErrCodeOwnersNotFound
+}
+
+// verdicts returns the users whose latest review approves the pull request
+// and those whose latest review requests changes, keyed by user ID. The
+// author's own reviews never count, and when headSHA is set neither do
+// approvals of other commits.
+func (s *pathProtectionService) verdicts(ctx context.Context, pr *models.PullRequest, headSHA string) (map[uuid.UUID]string, map[uuid.UUID]string, error) {
+	var reviews []*models.Review
+	err := s.db.WithContext(ctx).Preload("User").
+		Where("pull_request_id = ? AND user_id IS NOT NULL AND state <> ?", pr.ID, models.ReviewStatePending).
+		Order("created_at ASC").
+		Find(&reviews).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load reviews: %w", err)
+	}
+
+	latest := make(map[uuid.UUID]*models.Review)
+	for _, review := range reviews {
+		// Comments do not change a reviewer's verdict
+		if review.State == models.ReviewStateCommented {
+			continue
+		}
+		latest[*review.UserID] = review
+	}
+
+	approvers := make(map[uuid.UUID]string)
+	requesters := make(map[uuid.UUID]string)
+	for userID, review := range latest {
+		if pr.UserID != nil && *pr.UserID == userID {
+			continue
+		}
+		name := userID.String()
+		if review.User != nil {
+			name = review.User.Username
+		}
+		switch review.State {
+		case models.ReviewStateApproved:
+			if headSHA == "" || review.CommitSHA == headSHA {
+				approvers[userID] = name
+			}
+		case models.ReviewStateRequestChanges:
+			requesters[userID] = name
+		}
+	}
+	return approvers, requesters, nil
+}
+
+// missingTeams returns the teams with no member among the approvers
+func (s *pathProtectionService) missingTeams(ctx context.Context, repoID uuid.UUID, teams []string, approvers map[uuid.UUID]string) ([]string, error) {
+	approverIDs := make([]uuid.UUID, 0, len(approvers))
+	for id := range approvers {
+		approverIDs = append(approverIDs, id)
+	}
+
+	satisfied := make(map[string]bool)
+	if len(approverIDs) > 0 {
+		var names []string
+		err := s.db.WithContext(ctx).Model(&models.Team{}).
+			Joins("JOIN repositories ON repositories.owner_id = teams.organization_id AND repositories.id = ?", repoID).
+			Joins("JOIN team_members ON team_members.team_id = teams.id AND team_members.deleted_at IS NULL").
+			Where("teams.name IN ? AND team_members.user_id IN ?", teams, approverIDs).
+			Distinct().
+			Pluck("teams.name", &names).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to check team reviews: %w", err)
+		}
+		for _, name := range names {
+			satisfied[name] = true
+		}
+	}
+
+	var missing []string
+	for _, team := range teams {
+		if !satisfied[team] {
+			missing = append(missing, team)
+		}
+	}
+	return missing, nil
+}
+
+// headSHA returns the commit the head branch of a pull request points at,
+// or an empty string when it cannot be resolved
+func (s *pathProtectionService) headSHA(ctx context.Context, pr *models.PullRequest) string {
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, pr.RepositoryID)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to get repository path for status checks")
+		return ""
+	}
+	branch, err := s.gitService.GetBranch(ctx, repoPath, pr.HeadBranch)
+	if err != nil {
+		s.logger.WithError(err).WithField("branch", pr.HeadBranch).Warn("Failed to resolve pull request head for status checks")
+		return ""
+	}
+	return branch.SHA
+}
+
+// unverifiedCommits lists the commits of the pull request whose signatures
+// are missing or do not verify
+func (s *pathProtectionService) unverifiedCommits(ctx context.Context, pr *models.PullRequest) ([]string, error) {
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, pr.RepositoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository path: %w", err)
+	}
+	comparison, err := s.gitService.CompareRefs(repoPath, pr.BaseBranch, pr.HeadBranch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare branches: %w", err)
+	}
+
+	s.signing.VerifyCommits(ctx, comparison.Commits)
+	var unverified []string
+	for _, commit := range comparison.Commits {
+		if commit.Verification == nil || !commit.Verification.Verified {
+			unverified = append(unverified, commit.SHA)
+		}
+	}
+	sort.Strings(unverified)
+	return unverified, nil
+}
+
+// changedFiles lists the paths the pull request changes relative to its base
+func (s *pathProtectionService) changedFiles(ctx context.Context, pr *models.PullRequest) ([]string, error) {
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, pr.RepositoryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository path: %w", err)
+	}
+
+	comparison, err := s.gitService.CompareRefs(repoPath, pr.BaseBranch, pr.HeadBranch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare branches: %w", err)
+	}
+
+	files := make([]string, 0, len(comparison.Files))
+	for _, f := range comparison.Files {
+		files = append(files, f.Path)
+		// A rename out of a protected directory touches it as well
+		if f.PrevPath != "" && f.PrevPath != f.Path {
+			files = append(files, f.PrevPath)
+		}
+	}
+	return files, nil
+}
+
+// MatchPathPattern reports whether a repository-relative file path matches a
+// path protection pattern. A leading "/" anchors the pattern at the
+// repository root, "**" matches any number of directories, a trailing "/"
+// matches everything below a directory, and patterns without a slash match
+// a file name in any directory.
+func MatchPathPattern(pattern, file string) bool {
+	file = strings.TrimPrefix(file, "/")
+
+	if strings.HasSuffix(pattern, "/") {
+		pattern += "**"
+	}
+	if !strings.Contains(strings.TrimSuffix(pattern, "/**"), "/") && !strings.HasPrefix(pattern, "/") {
+		pattern = "**/" + pattern
+	}
+	pattern = strings.TrimPrefix(pattern, "/")
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(file, "/"))
+}
+
+func matchSegments(pattern, file []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			rest := pattern[1:]
+			if len(rest) == 0 {
+				return len(file) > 0
+			}
+			for i := 0; i <= len(file); i++ {
+				if matchSegments(rest, file[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(file) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], file[0]); !ok {
+			return false
+		}
+		pattern, file = pattern[1:], file[1:]
+	}
+	return len(file) == 0
+}
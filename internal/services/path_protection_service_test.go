@@ -0,0 +1,37 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchPathPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		file    string
+		want    bool
+	}{
+		{"/terraform/**", "terraform/main.tf", true},
+		{"/terraform/**", "terraform/modules/vpc/main.tf", true},
+		{"/terraform/**", "infra/terraform/main.tf", false},
+		{"/internal/auth/**", "internal/auth/jwt.go", true},
+		{"/internal/auth/**", "internal/authz/jwt.go", false},
+		{"*.tf", "main.tf", true},
+		{"*.tf", "deploy/prod/main.tf", true},
+		{"*.tf", "main.tf.bak", false},
+		{"docs/", "docs/index.md", true},
+		{"docs/", "site/docs/guide/intro.md", true},
+		{"docs/", "docs", false},
+		{"/Makefile", "Makefile", true},
+		{"/Makefile", "build/Makefile", false},
+		{"cmd/*/main.go", "cmd/server/main.go", true},
+		{"cmd/*/main.go", "cmd/server/sub/main.go", false},
+		{"/**/secrets.yaml", "secrets.yaml", true},
+		{"/**/secrets.yaml", "deploy/k8s/secrets.yaml", true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, MatchPathPattern(tt.pattern, tt.file), "%s vs %s", tt.pattern, tt.file)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/a5c-ai/hub/internal/audit"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
@@ -17,6 +18,9 @@ type PermissionService interface {
 	GetRepositoryPermissions(ctx context.Context, repoID uuid.UUID) ([]*models.RepositoryPermission, error)
 	GetUserRepositoryPermission(ctx context.Context, userID uuid.UUID, repoID uuid.UUID) (models.Permission, error)
 	CalculateUserPermission(ctx context.Context, userID uuid.UUID, repoID uuid.UUID) (models.Permission, error)
+	// ExplainUserPermission resolves the permission like CalculateUserPermission
+	// and also returns every check made along the way
+	ExplainUserPermission(ctx context.Context, userID uuid.UUID, repoID uuid.UUID) (models.Permission, []models.AuthorizationTraceStep, error)
 }
 
 // Permission Service Implementation
@@ -56,6 +60,14 @@ func (s *permissionService) GrantRepositoryPermission(ctx context.Context, repoI
 		return fmt.Errorf("failed to check existing permission: %w", err)
 	}
 
+	audit.Record(ctx, audit.Event{
+		Action:       audit.ActionPermissionGrant,
+		RepositoryID: &repoID,
+		TargetType:   string(subjectType),
+		TargetID:     subjectID.String(),
+		Metadata:     map[string]interface{}{"permission": permission},
+	})
+
 	// Log activity
 	if s.as != nil {
 		go func() {
@@ -84,6 +96,13 @@ func (s *permissionService) RevokeRepositoryPermission(ctx context.Context, repo
 		return fmt.Errorf("permission not found")
 	}
 
+	audit.Record(ctx, audit.Event{
+		Action:       audit.ActionPermissionRevoke,
+		RepositoryID: &repoID,
+		TargetType:   string(subjectType),
+		TargetID:     subjectID.String(),
+	})
+
 	// Log activity
 	if s.as != nil {
 		go func() {
@@ -129,6 +148,29 @@ func (s *permissionService) GetUserRepositoryPermission(ctx context.Context, use
 }
 
 func (s *permissionService) CalculateUserPermission(ctx context.Context, userID uuid.UUID, repoID uuid.UUID) (models.Permission, error) {
+	return s.resolvePermission(ctx, userID, repoID, nil)
+}
+
+func (s *permissionService) ExplainUserPermission(ctx context.Context, userID uuid.UUID, repoID uuid.UUID) (models.Permission, []models.AuthorizationTraceStep, error) {
+	trace := &permissionTrace{steps: []models.AuthorizationTraceStep{}}
+	permission, err := s.resolvePermission(ctx, userID, repoID, trace)
+	return permission, trace.steps, err
+}
+
+// permissionTrace collects the checks made by resolvePermission; a nil trace
+// records nothing
+type permissionTrace struct {
+	steps []models.AuthorizationTraceStep
+}
+
+func (t *permissionTrace) add(source, detail string, permission models.Permission, matched bool) {
+	if t == nil {
+		return
+	}
+	t.steps = append(t.steps, models.AuthorizationTraceStep{Source: source, Detail: detail, Permission: permission, Matched: matched})
+}
+
+func (s *permissionService) resolvePermission(ctx context.Context, userID uuid.UUID, repoID uuid.UUID, trace *permissionTrace) (models.Permission, error) {
 	// Get repository information
 	var repo models.Repository
 	if err := s.db.First(&repo, repoID).Error; err != nil {
@@ -136,8 +178,12 @@ func (s *permissionService) CalculateUserPermission(ctx context.Context, userID
 	}
 
 	// 1. Check if user owns the repository (personal repo)
-	if repo.OwnerType == models.OwnerTypeUser && repo.OwnerID == userID {
-		return models.PermissionAdmin, nil
+	if repo.OwnerType == models.OwnerTypeUser {
+		if repo.OwnerID == userID {
+			trace.add(models.AuthzSourceOwner, "user owns the repository", models.PermissionAdmin, true)
+			return models.PermissionAdmin, nil
+		}
+		trace.add(models.AuthzSourceOwner, "repository is owned by another user", "", false)
 	}
 
 	// 2. Check direct user permission
@@ -146,12 +192,24 @@ func (s *permissionService) CalculateUserPermission(ctx context.Context, userID
 		return "", err
 	}
 	if directPerm != "" {
+		trace.add(models.AuthzSourceCollaborator, "user is a collaborator on the repository", directPerm, true)
 		return directPerm, nil
 	}
+	trace.add(models.AuthzSourceCollaborator, "user is not a collaborator on the repository", "", false)
+
+	// 3. External collaborators only see repositories shared with them
+	external, err := isExternalUser(s.db.WithContext(ctx), userID)
+	if err != nil {
+		return "", err
+	}
+	if external {
+		trace.add(models.AuthzSourceVisibility, "user is an external collaborator", "", false)
+		return "", nil
+	}
 
-	// 3. For organization repositories, check organization and team permissions
+	// 4. For organization repositories, check organization and team permissions
 	if repo.OwnerType == models.OwnerTypeOrganization {
-		orgPermission, err := s.calculateOrganizationPermission(ctx, userID, repo.OwnerID, repoID)
+		orgPermission, err := s.calculateOrganizationPermission(ctx, userID, repo.OwnerID, repoID, trace)
 		if err != nil {
 			return "", err
 		}
@@ -160,28 +218,32 @@ func (s *permissionService) CalculateUserPermission(ctx context.Context, userID
 		}
 	}
 
-	// 4. Check public repository access
+	// 5. Check public repository access
 	if repo.Visibility == models.VisibilityPublic {
+		trace.add(models.AuthzSourceVisibility, "repository is public", models.PermissionRead, true)
 		return models.PermissionRead, nil
 	}
 
-	// 5. Check internal repository access for organization members
+	// 6. Check internal repository access for organization members
 	if repo.Visibility == models.VisibilityInternal && repo.OwnerType == models.OwnerTypeOrganization {
 		var orgMember models.OrganizationMember
 		if err := s.db.Where("organization_id = ? AND user_id = ?", repo.OwnerID, userID).First(&orgMember).Error; err == nil {
+			trace.add(models.AuthzSourceVisibility, "repository is internal and the user is an organization member", models.PermissionRead, true)
 			return models.PermissionRead, nil
 		}
 	}
 
 	// No permission found
+	trace.add(models.AuthzSourceVisibility, fmt.Sprintf("repository is %s", repo.Visibility), "", false)
 	return "", nil
 }
 
-func (s *permissionService) calculateOrganizationPermission(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, repoID uuid.UUID) (models.Permission, error) {
+func (s *permissionService) calculateOrganizationPermission(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, repoID uuid.UUID, trace *permissionTrace) (models.Permission, error) {
 	// Check if user is organization owner/admin
 	var orgMember models.OrganizationMember
 	if err := s.db.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&orgMember).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
+			trace.add(models.AuthzSourceOrganizationRole, "user is not a member of the organization", "", false)
 			return "", nil // Not an organization member
 		}
 		return "", fmt.Errorf("failed to check organization membership: %w", err)
@@ -189,11 +251,13 @@ func (s *permissionService) calculateOrganizationPermission(ctx context.Context,
 
 	// Organization owners and admins have admin access to all repos
 	if orgMember.Role == models.OrgRoleOwner || orgMember.Role == models.OrgRoleAdmin {
+		trace.add(models.AuthzSourceOrganizationRole, fmt.Sprintf("user is an organization %s", orgMember.Role), models.PermissionAdmin, true)
 		return models.PermissionAdmin, nil
 	}
+	trace.add(models.AuthzSourceOrganizationRole, fmt.Sprintf("organization %s role grants no repository access by itself", orgMember.Role), "", false)
 
 	// Check team permissions
-	teamPerm, err := s.getHighestTeamPermission(ctx, userID, orgID, repoID)
+	teamPerm, err := s.getHighestTeamPermission(ctx, userID, orgID, repoID, trace)
 	if err != nil {
 		return "", err
 	}
@@ -201,28 +265,37 @@ func (s *permissionService) calculateOrganizationPermission(ctx context.Context,
 	return teamPerm, nil
 }
 
-func (s *permissionService) getHighestTeamPermission(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, repoID uuid.UUID) (models.Permission, error) {
+func (s *permissionService) getHighestTeamPermission(ctx context.Context, userID uuid.UUID, orgID uuid.UUID, repoID uuid.UUID, trace *permissionTrace) (models.Permission, error) {
 	// Get all teams the user belongs to in this organization
-	var teamMembers []models.TeamMember
+	var teams []struct {
+		TeamID uuid.UUID
+		Name   string
+	}
 	if err := s.db.Table("team_members").
+		Select("team_members.team_id, teams.name").
 		Joins("JOIN teams ON team_members.team_id = teams.id").
 		Where("teams.organization_id = ? AND team_members.user_id = ?", orgID, userID).
-		Find(&teamMembers).Error; err != nil {
+		Find(&teams).Error; err != nil {
 		return "", fmt.Errorf("failed to get user teams: %w", err)
 	}
+	if len(teams) == 0 {
+		trace.add(models.AuthzSourceTeam, "user is not on any team in the organization", "", false)
+	}
 
 	var highestPermission models.Permission
 
-	for _, teamMember := range teamMembers {
+	for _, team := range teams {
 		// Get repository permissions for this team
 		var repoPermission models.RepositoryPermission
 		if err := s.db.Where("repository_id = ? AND subject_id = ? AND subject_type = ?",
-			repoID, teamMember.TeamID, models.SubjectTypeTeam).First(&repoPermission).Error; err != nil {
+			repoID, team.TeamID, models.SubjectTypeTeam).First(&repoPermission).Error; err != nil {
 			if err != gorm.ErrRecordNotFound {
 				return "", fmt.Errorf("failed to get team permission: %w", err)
 			}
+			trace.add(models.AuthzSourceTeam, fmt.Sprintf("team %s has no access to the repository", team.Name), "", false)
 			continue // No permission found for this team
 		}
+		trace.add(models.AuthzSourceTeam, fmt.Sprintf("team %s has access to the repository", team.Name), repoPermission.Permission, true)
 
 		// Check if this is the highest permission so far
 		if isHigherPermission(repoPermission.Permission, highestPermission) {
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// Pull request event actions
+const (
+	PullRequestActionOpened = "opened"
+)
+
+// PullRequestEvent describes a change to a pull request
+type PullRequestEvent struct {
+	Action      string
+	PullRequest *models.PullRequest
+	ActorID     uuid.UUID
+}
+
+// PullRequestListener is notified in the background of pull request events
+type PullRequestListener func(ctx context.Context, event PullRequestEvent)
+
+func (s *pullRequestService) Subscribe(listener PullRequestListener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+// emit starts every listener without delaying the request that caused the event
+func (s *pullRequestService) emit(event PullRequestEvent) {
+	s.mu.RLock()
+	listeners := make([]PullRequestListener, len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.RUnlock()
+
+	tags := map[string]string{"pull_request_id": event.PullRequest.ID.String()}
+	for _, listener := range listeners {
+		go func(l PullRequestListener) {
+			defer errorreporting.Default().Recover("pull_request_listener", tags)
+			l(context.Background(), event)
+		}(listener)
+	}
+}
+
+// refreshMergeability records whether the pull request merges cleanly and
+// how much it changes. It runs after the request that opened the pull request
+// has been answered, so failures only leave the state unknown.
+func (s *pullRequestService) refreshMergeability(ctx context.Context, pr *models.PullRequest) {
+	log := s.logger.WithField("pull_request_id", pr.ID)
+
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, pr.RepositoryID)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get repository path for mergeability")
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if mergeable, err := s.gitService.CanMerge(repoPath, pr.BaseBranch, pr.HeadBranch); err != nil {
+		log.WithError(err).Warn("Failed to compute pull request mergeability")
+	} else {
+		updates["mergeable"] = mergeable
+		updates["mergeable_state"] = models.MergeableStateConflicting
+		if mergeable {
+			updates["mergeable_state"] = models.MergeableStateClean
+		}
+	}
+
+	// Computing the stats also warms the files cache for the first review
+	if files, err := s.ListFiles(ctx, pr, PullRequestFilesOptions{Page: 1, PerPage: 1}); err != nil {
+		log.WithError(err).Warn("Failed to compute pull request diff stats")
+	} else {
+		updates["changed_files"] = files.TotalFiles
+		updates["additions"] = files.Additions
+		updates["deletions"] = files.Deletions
+	}
+
+	if len(updates) == 0 {
+		return
+	}
+	if err := s.db.WithContext(ctx).Model(&models.PullRequest{}).Where("id = ?", pr.ID).
+		UpdateColumns(updates).Error; err != nil {
+		log.WithError(err).Warn("Failed to store pull request mergeability")
+	}
+}
+
+// PullRequestNotifier delivers pull request events to repository webhooks
+// and to the users a pull request was assigned to or needs a review from
+type PullRequestNotifier struct {
+	webhooks      *WebhookDeliveryService
+	notifications NotificationService
+	logger        *logrus.Logger
+}
+
+// NewPullRequestNotifier creates a notifier; either destination may be nil
+func NewPullRequestNotifier(webhooks *WebhookDeliveryService, notifications NotificationService, logger *logrus.Logger) *PullRequestNotifier {
+	return &PullRequestNotifier{webhooks: webhooks, notifications: notifications, logger: logger}
+}
+
+// HandlePullRequest is a PullRequestListener
+func (n *PullRequestNotifier) HandlePullRequest(ctx context.Context, event PullRequestEvent) {
+	pr := event.PullRequest
+
+	if n.webhooks != nil {
+		err := n.webhooks.TriggerWebhooks(ctx, pr.RepositoryID, WebhookEventPullRequest, map[string]interface{}{
+			"action":       event.Action,
+			"number":       pr.Number,
+			"pull_request": exportPullRequest(pr),
+		})
+		if err != nil {
+			n.logger.WithError(err).WithField("pull_request_id", pr.ID).Error("Failed to trigger pull request webhooks")
+		}
+	}
+
+	if n.notifications == nil || event.Action != PullRequestActionOpened {
+		return
+	}
+	payload := map[string]interface{}{
+		"repository_id": pr.RepositoryID.String(),
+		"pull_request":  exportPullRequest(pr),
+	}
+	for _, reviewer := range pr.RequestedReviewers {
+		if reviewer.ID == event.ActorID {
+			continue
+		}
+		n.notifications.Publish(reviewer.ID, Notification{
+			ID: uuid.New(), Type: "pull_request_review_requested", Payload: payload, Timestamp: time.Now(),
+		})
+	}
+	for _, assignee := range pr.Assignees {
+		if assignee.ID == event.ActorID {
+			continue
+		}
+		n.notifications.Publish(assignee.ID, Notification{
+			ID: uuid.New(), Type: "pull_request_assigned", Payload: payload, Timestamp: time.Now(),
+		})
+	}
+}
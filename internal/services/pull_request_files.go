@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	// pullRequestPatchMaxBytes bounds the patch kept for a single file
+	pullRequestPatchMaxBytes = 64 * 1024
+	// pullRequestPatchBudgetBytes bounds the patches kept for a whole pull
+	// request; files past it are listed without a patch
+	pullRequestPatchBudgetBytes = 4 * 1024 * 1024
+	// pullRequestFilesBatchSize is the number of cached rows inserted at once
+	pullRequestFilesBatchSize = 200
+)
+
+// PullRequestFilesOptions selects a page of a pull request's changed files
+type PullRequestFilesOptions struct {
+	Page    int
+	PerPage int
+}
+
+// PullRequestFiles is one page of a pull request's changed files together
+// with the diffstat of the whole pull request
+type PullRequestFiles struct {
+	HeadSHA    string                    `json:"head_sha"`
+	BaseSHA    string                    `json:"base_sha"`
+	TotalFiles int64                     `json:"total_files"`
+	Additions  int64                     `json:"additions"`
+	Deletions  int64                     `json:"deletions"`
+	Page       int                       `json:"page"`
+	PerPage    int                       `json:"per_page"`
+	Files      []*models.PullRequestFile `json:"files"`
+}
+
+func (s *pullRequestService) ListFiles(ctx context.Context, pr *models.PullRequest, opts PullRequestFilesOptions) (*PullRequestFiles, error) {
+	if opts.Page < 1 {
+		opts.Page = 1
+	}
+	if opts.PerPage < 1 || opts.PerPage > 100 {
+		opts.PerPage = 30
+	}
+
+	headSHA, baseSHA, err := s.cachedFileDiff(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PullRequestFiles{HeadSHA: headSHA, BaseSHA: baseSHA, Page: opts.Page, PerPage: opts.PerPage}
+	scope := s.db.WithContext(ctx).Model(&models.PullRequestFile{}).
+		Where("pull_request_id = ? AND head_sha = ? AND base_sha = ?", pr.ID, headSHA, baseSHA)
+
+	var stat struct {
+		Files     int64
+		Additions int64
+		Deletions int64
+	}
+	if err := scope.Session(&gorm.Session{}).
+		Select("COUNT(*) AS files, COALESCE(SUM(additions), 0) AS additions, COALESCE(SUM(deletions), 0) AS deletions").
+		Scan(&stat).Error; err != nil {
+		return nil, fmt.Errorf("failed to summarize pull request files: %w", err)
+	}
+	result.TotalFiles, result.Additions, result.Deletions = stat.Files, stat.Additions, stat.Deletions
+
+	if err := scope.Session(&gorm.Session{}).Order("position ASC").
+		Offset((opts.Page - 1) * opts.PerPage).Limit(opts.PerPage).
+		Find(&result.Files).Error; err != nil {
+		return nil, fmt.Errorf("failed to list pull request files: %w", err)
+	}
+	return result, nil
+}
+
+// cachedFileDiff makes sure the changed files between the pull request's
+// current base and head are cached and returns the commits they are keyed
+// on. When a branch no longer resolves, such as after a merged head branch
+// is deleted, the most recent cached diff is used instead.
+func (s *pullRequestService) cachedFileDiff(ctx context.Context, pr *models.PullRequest) (string, string, error) {
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, pr.RepositoryID)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get repository path: %w", err)
+	}
+
+	headSHA, headErr := s.gitService.ResolveSHA(ctx, repoPath, pr.HeadBranch)
+	baseSHA, baseErr := s.gitService.ResolveSHA(ctx, repoPath, pr.BaseBranch)
+	if headErr != nil || baseErr != nil {
+		var latest models.PullRequestFile
+		if err := s.db.WithContext(ctx).Where("pull_request_id = ? AND head_sha <> ''", pr.ID).
+			Order("created_at DESC").First(&latest).Error; err != nil {
+			if headErr != nil {
+				return "", "", headErr
+			}
+			return "", "", baseErr
+		}
+		return latest.HeadSHA, latest.BaseSHA, nil
+	}
+
+	var cached int64
+	if err := s.db.WithContext(ctx).Model(&models.PullRequestFile{}).
+		Where("pull_request_id = ? AND head_sha = ? AND base_sha = ?", pr.ID, headSHA, baseSHA).
+		Count(&cached).Error; err != nil {
+		return "", "", fmt.Errorf("failed to check cached pull request files: %w", err)
+	}
+	if cached > 0 {
+		return headSHA, baseSHA, nil
+	}
+
+	comparison, err := s.gitService.CompareRefs(repoPath, baseSHA, headSHA)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to compare branches: %w", err)
+	}
+
+	files := make([]*models.PullRequestFile, 0, len(comparison.Files))
+	budget := pullRequestPatchBudgetBytes
+	for i, f := range comparison.Files {
+		file := &models.PullRequestFile{
+			ID:            uuid.New(),
+			PullRequestID: pr.ID,
+			Filename:      f.Path,
+			Status:        f.Status,
+			Additions:     f.Additions,
+			Deletions:     f.Deletions,
+			Changes:       f.Changes,
+			HeadSHA:       headSHA,
+			BaseSHA:       baseSHA,
+			Position:      i,
+		}
+		if f.Status == "renamed" && f.PrevPath != "" {
+			previous := f.PrevPath
+			file.PreviousFilename = &previous
+		}
+		file.Patch, file.PatchTruncated = truncatePatch(f.Patch, min(pullRequestPatchMaxBytes, budget))
+		budget -= len(file.Patch)
+		files = append(files, file)
+	}
+
+	// Older diffs of the pull request are replaced. An empty diff leaves no
+	// rows and is recomputed next time, which is cheap.
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("pull_request_id = ?", pr.ID).Delete(&models.PullRequestFile{}).Error; err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(files, pullRequestFilesBatchSize).Error
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to cache pull request files: %w", err)
+	}
+	return headSHA, baseSHA, nil
+}
+
+// truncatePatch cuts patch at the last complete line within limit bytes
+func truncatePatch(patch string, limit int) (string, bool) {
+	if len(patch) <= limit {
+		return patch, false
+	}
+	if limit <= 0 {
+		return "", true
+	}
+	cut := patch[:limit]
+	if i := strings.LastIndexByte(cut, '\n'); i >= 0 {
+		cut = cut[:i+1]
+	} else {
+		cut = ""
+	}
+	return cut, true
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncatePatch(t *testing.T) {
+	patch, truncated := truncatePatch("@@ -1 +1 @@\n-a\n+b\n", 100)
+	assert.False(t, truncated)
+	assert.Equal(t, "@@ -1 +1 @@\n-a\n+b\n", patch)
+
+	patch, truncated = truncatePatch("@@ -1 +1 @@\n-a\n+b\n", 16)
+	assert.True(t, truncated)
+	assert.Equal(t, "@@ -1 +1 @@\n-a\n", patch)
+
+	patch, truncated = truncatePatch("@@ -1 +1 @@\n", 0)
+	assert.True(t, truncated)
+	assert.Empty(t, patch)
+}
+
+func TestPullRequestService_ListFiles(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Repository{}, &models.PullRequest{}, &models.PullRequestFile{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	base := t.TempDir()
+	gitService := git.NewGitService(logger)
+	repoService := NewRepositoryService(db, gitService, logger, base)
+	svc := NewPullRequestService(db, gitService, repoService, logger, base)
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	fixture := testutil.NewGitRepo(t, testutil.RepositoryPath(base, repo))
+	fixture.Commit("main", "initial", map[string]string{"README.md": "hello\n"})
+	fixture.Branch("feature", "main")
+
+	files := map[string]string{"big.txt": strings.Repeat("line\n", 20000)}
+	for i := 0; i < 5; i++ {
+		files[fmt.Sprintf("pkg/file%d.go", i)] = "package pkg\n"
+	}
+	fixture.Commit("feature", "add files", files)
+
+	pr := &models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 1, Title: "Add files", HeadBranch: "feature", BaseBranch: "main", State: models.PullRequestStateOpen}
+	require.NoError(t, db.Create(pr).Error)
+
+	page, err := svc.ListFiles(ctx, pr, PullRequestFilesOptions{Page: 1, PerPage: 4})
+	require.NoError(t, err)
+	assert.EqualValues(t, 6, page.TotalFiles)
+	assert.EqualValues(t, 20005, page.Additions)
+	assert.Len(t, page.Files, 4)
+	headSHA := page.HeadSHA
+
+	page, err = svc.ListFiles(ctx, pr, PullRequestFilesOptions{Page: 2, PerPage: 4})
+	require.NoError(t, err)
+	require.Len(t, page.Files, 2)
+
+	for _, f := range page.Files {
+		assert.Equal(t, "added", f.Status)
+	}
+
+	var all []*models.PullRequestFile
+	require.NoError(t, db.Where("pull_request_id = ?", pr.ID).Find(&all).Error)
+	require.Len(t, all, 6)
+	var big *models.PullRequestFile
+	for _, f := range all {
+		if f.Filename == "big.txt" {
+			big = f
+		}
+	}
+	require.NotNil(t, big)
+	assert.True(t, big.PatchTruncated)
+	assert.LessOrEqual(t, len(big.Patch), pullRequestPatchMaxBytes)
+	assert.Equal(t, 20000, big.Additions)
+
+	// A new push to the head replaces the cached diff
+	fixture.Commit("feature", "edit readme", map[string]string{"README.md": "hello world\n"})
+	page, err = svc.ListFiles(ctx, pr, PullRequestFilesOptions{PerPage: 100})
+	require.NoError(t, err)
+	assert.NotEqual(t, headSHA, page.HeadSHA)
+	assert.EqualValues(t, 7, page.TotalFiles)
+	var cached int64
+	require.NoError(t, db.Model(&models.PullRequestFile{}).Where("pull_request_id = ?", pr.ID).Count(&cached).Error)
+	assert.EqualValues(t, 7, cached)
+
+	// Once the head branch is deleted the last cached diff is served
+	fixture.DeleteBranch("feature")
+	page, err = svc.ListFiles(ctx, pr, PullRequestFilesOptions{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 7, page.TotalFiles)
+	assert.Len(t, page.Files, 7)
+}
@@ -2,8 +2,14 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/a5c-ai/hub/internal/audit"
+	"github.com/a5c-ai/hub/internal/errorreporting"
 	"github.com/a5c-ai/hub/internal/git"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/google/uuid"
@@ -14,18 +20,35 @@ import (
 type PullRequestService interface {
 	Create(ctx context.Context, repoID uuid.UUID, userID uuid.UUID, req CreatePullRequestRequest) (*models.PullRequest, error)
 	Get(ctx context.Context, owner, repo string, number int) (*models.PullRequest, error)
+	GetByNumber(ctx context.Context, repoID uuid.UUID, number int) (*models.PullRequest, error)
 	List(ctx context.Context, repoID uuid.UUID, filter PullRequestFilter) ([]*models.PullRequest, error)
 	Update(ctx context.Context, id uuid.UUID, req UpdatePullRequestRequest) (*models.PullRequest, error)
 	Close(ctx context.Context, id uuid.UUID) error
 	Merge(ctx context.Context, id uuid.UUID, req MergePullRequestRequest) error
+	// ListFiles returns a page of the files a pull request changes. The diff
+	// is computed on first request and cached until the head or base moves.
+	ListFiles(ctx context.Context, pr *models.PullRequest, opts PullRequestFilesOptions) (*PullRequestFiles, error)
+	// Subscribe registers a listener for pull request events such as opening
+	Subscribe(listener PullRequestListener)
 }
 
+var (
+	ErrPullRequestRefsInvalid     = errors.New("pull request head and base must be existing, different branches")
+	ErrPullRequestMetadataInvalid = errors.New("invalid pull request reviewers, assignees or labels")
+)
+
 type pullRequestService struct {
-	db           *gorm.DB
-	gitService   git.GitService
-	repoService  RepositoryService
-	logger       *logrus.Logger
-	repoBasePath string
+	db             *gorm.DB
+	gitService     git.GitService
+	repoService    RepositoryService
+	pathProtection PathProtectionService
+	issueLinks     IssueLinkService
+	changes        ChangeManagementService
+	logger         *logrus.Logger
+	repoBasePath   string
+
+	mu        sync.RWMutex
+	listeners []PullRequestListener
 }
 
 type CreatePullRequestRequest struct {
@@ -36,6 +59,11 @@ type CreatePullRequestRequest struct {
 	HeadRepositoryID    *uuid.UUID `json:"head_repository_id"`
 	Draft               bool       `json:"draft"`
 	MaintainerCanModify bool       `json:"maintainer_can_modify"`
+	// Reviewers and Assignees are usernames, Labels are names of the
+	// repository's labels
+	Reviewers []string `json:"reviewers"`
+	Assignees []string `json:"assignees"`
+	Labels    []string `json:"labels"`
 }
 
 type UpdatePullRequestRequest struct {
@@ -61,11 +89,14 @@ type PullRequestFilter struct {
 
 func NewPullRequestService(db *gorm.DB, gitService git.GitService, repoService RepositoryService, logger *logrus.Logger, repoBasePath string) PullRequestService {
 	return &pullRequestService{
-		db:           db,
-		gitService:   gitService,
-		repoService:  repoService,
-		logger:       logger,
-		repoBasePath: repoBasePath,
+		db:             db,
+		gitService:     gitService,
+		repoService:    repoService,
+		pathProtection: NewPathProtectionService(db, gitService, repoService, logger),
+		issueLinks:     NewIssueLinkService(db, gitService, repoService, logger),
+		changes:        NewChangeManagementService(db, logger),
+		logger:         logger,
+		repoBasePath:   repoBasePath,
 	}
 }
 
@@ -75,10 +106,7 @@ func (s *pullRequestService) Create(ctx context.Context, repoID uuid.UUID, userI
 	if err := s.db.First(&repo, "id = ?", repoID).Error; err != nil {
 		return nil, fmt.Errorf("repository not found: %w", err)
 	}
-
-	// Get the next PR number
-	nextNumber, err := s.getNextPRNumber(repoID)
-	if err != nil {
+	if err := CheckPullRequestTitle(&repo, req.Title); err != nil {
 		return nil, err
 	}
 
@@ -87,31 +115,153 @@ func (s *pullRequestService) Create(ctx context.Context, repoID uuid.UUID, userI
 	if req.HeadRepositoryID != nil {
 		headRepoID = *req.HeadRepositoryID
 	}
+	if err := s.validateRefs(ctx, repoID, headRepoID, req.Base, req.Head); err != nil {
+		return nil, err
+	}
+
+	reviewers, err := s.findUsers(ctx, req.Reviewers)
+	if err != nil {
+		return nil, err
+	}
+	for _, reviewer := range reviewers {
+		if reviewer.ID == userID {
+			return nil, fmt.Errorf("%w: review cannot be requested from the author", ErrPullRequestMetadataInvalid)
+		}
+	}
+	assignees, err := s.findUsers(ctx, req.Assignees)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := s.findLabels(ctx, repoID, req.Labels)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the next PR number
+	nextNumber, err := s.getNextPRNumber(repoID)
+	if err != nil {
+		return nil, err
+	}
 
 	// Create the pull request
 	pr := models.PullRequest{
-		RepositoryID:     repoID,
-		Number:           nextNumber,
-		Title:            req.Title,
-		Body:             req.Body,
-		UserID:           &userID,
-		HeadRepositoryID: &headRepoID,
-		HeadBranch:       req.Head,
-		BaseBranch:       req.Base,
-		State:            models.PullRequestStateOpen,
-		Draft:            req.Draft,
-	}
-
-	if err := s.db.Create(&pr).Error; err != nil {
+		ID:                 uuid.New(),
+		RepositoryID:       repoID,
+		Number:             nextNumber,
+		Title:              req.Title,
+		Body:               req.Body,
+		UserID:             &userID,
+		HeadRepositoryID:   &headRepoID,
+		BaseRepositoryID:   repoID,
+		HeadBranch:         req.Head,
+		BaseBranch:         req.Base,
+		State:              models.PullRequestStateOpen,
+		Draft:              req.Draft,
+		MergeableState:     models.MergeableStateUnknown,
+		Labels:             labels,
+		Assignees:          assignees,
+		RequestedReviewers: reviewers,
+	}
+
+	if err := s.db.WithContext(ctx).Create(&pr).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Preload("User").First(&pr, "id = ?", pr.ID).Error; err != nil {
 		return nil, err
 	}
 
+	if err := s.issueLinks.LinkPullRequest(ctx, &pr); err != nil {
+		s.logger.WithError(err).WithField("pull_request_id", pr.ID).Warn("Failed to link pull request to issues")
+	}
+	if pr.ChangeTicket, err = s.changes.LinkPullRequest(ctx, &pr); err != nil {
+		s.logger.WithError(err).WithField("pull_request_id", pr.ID).Warn("Failed to link pull request to change ticket")
+	}
+
+	// Listeners and the background refresh get their own copy so the caller
+	// can keep using the returned pull request
+	opened := pr
+	go func() {
+		defer errorreporting.Default().Recover("pull_request_mergeability", map[string]string{"pull_request_id": opened.ID.String()})
+		s.refreshMergeability(context.Background(), &opened)
+	}()
+	event := pr
+	s.emit(PullRequestEvent{Action: PullRequestActionOpened, PullRequest: &event, ActorID: userID})
+
 	return &pr, nil
 }
 
+// validateRefs checks that both branches of a new pull request exist and
+// that it does not merge a branch into itself
+func (s *pullRequestService) validateRefs(ctx context.Context, repoID, headRepoID uuid.UUID, base, head string) error {
+	if headRepoID == repoID && head == base {
+		return fmt.Errorf("%w: head and base are both %q", ErrPullRequestRefsInvalid, head)
+	}
+
+	basePath, err := s.repoService.GetRepositoryPath(ctx, repoID)
+	if err != nil {
+		return fmt.Errorf("failed to get repository path: %w", err)
+	}
+	if _, err := s.gitService.GetBranch(ctx, basePath, base); err != nil {
+		return fmt.Errorf("%w: base branch %q does not exist", ErrPullRequestRefsInvalid, base)
+	}
+
+	headPath := basePath
+	if headRepoID != repoID {
+		if headPath, err = s.repoService.GetRepositoryPath(ctx, headRepoID); err != nil {
+			return fmt.Errorf("%w: head repository not found", ErrPullRequestRefsInvalid)
+		}
+	}
+	if _, err := s.gitService.GetBranch(ctx, headPath, head); err != nil {
+		return fmt.Errorf("%w: head branch %q does not exist", ErrPullRequestRefsInvalid, head)
+	}
+	return nil
+}
+
+// findUsers loads users by username, failing on the first unknown name
+func (s *pullRequestService) findUsers(ctx context.Context, usernames []string) ([]models.User, error) {
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+	var users []models.User
+	if err := s.db.WithContext(ctx).Where("username IN ?", usernames).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to find users: %w", err)
+	}
+	found := make(map[string]bool, len(users))
+	for _, user := range users {
+		found[user.Username] = true
+	}
+	for _, username := range usernames {
+		if !found[username] {
+			return nil, fmt.Errorf("%w: user %q not found", ErrPullRequestMetadataInvalid, username)
+		}
+	}
+	return users, nil
+}
+
+// findLabels loads labels of the repository by name
+func (s *pullRequestService) findLabels(ctx context.Context, repoID uuid.UUID, names []string) ([]models.Label, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	var labels []models.Label
+	if err := s.db.WithContext(ctx).Where("repository_id = ? AND name IN ?", repoID, names).Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("failed to find labels: %w", err)
+	}
+	found := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		found[label.Name] = true
+	}
+	for _, name := range names {
+		if !found[name] {
+			return nil, fmt.Errorf("%w: label %q not found", ErrPullRequestMetadataInvalid, name)
+		}
+	}
+	return labels, nil
+}
+
 func (s *pullRequestService) Get(ctx context.Context, owner, repo string, number int) (*models.PullRequest, error) {
 	var pr models.PullRequest
-	err := s.db.Preload("Repository").Preload("User").
+	err := s.db.Preload("Repository").Preload("User").Preload("ChangeTicket").
 		Joins("JOIN repositories ON repositories.id = pull_requests.repository_id").
 		Joins("JOIN users ON users.id = repositories.owner_id").
 		Where("users.username = ? AND repositories.name = ? AND pull_requests.number = ?", owner, repo, number).
@@ -120,10 +270,26 @@ func (s *pullRequestService) Get(ctx context.Context, owner, repo string, number
 	if err != nil {
 		return nil, err
 	}
+	if err := s.withUnresolvedReviewThreads(ctx, &pr); err != nil {
+		return nil, err
+	}
 
 	return &pr, nil
 }
 
+// GetByNumber finds a pull request of an already resolved repository,
+// whether it is owned by a user or an organization
+func (s *pullRequestService) GetByNumber(ctx context.Context, repoID uuid.UUID, number int) (*models.PullRequest, error) {
+	var pr models.PullRequest
+	err := s.db.WithContext(ctx).Preload("Repository").Preload("User").Preload("ChangeTicket").
+		Where("repository_id = ? AND number = ?", repoID, number).
+		First(&pr).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
 func (s *pullRequestService) List(ctx context.Context, repoID uuid.UUID, filter PullRequestFilter) ([]*models.PullRequest, error) {
 	query := s.db.Where("repository_id = ?", repoID)
 
@@ -147,8 +313,29 @@ func (s *pullRequestService) List(ctx context.Context, repoID uuid.UUID, filter
 	}
 
 	var prs []*models.PullRequest
-	err := query.Preload("User").Order("created_at DESC").Limit(pageSize).Offset(offset).Find(&prs).Error
-	return prs, err
+	if err := query.Preload("User").Preload("ChangeTicket").Order("created_at DESC").Limit(pageSize).Offset(offset).Find(&prs).Error; err != nil {
+		return nil, err
+	}
+	if err := s.withUnresolvedReviewThreads(ctx, prs...); err != nil {
+		return nil, err
+	}
+	return prs, nil
+}
+
+// withUnresolvedReviewThreads fills in the unresolved review thread counts
+func (s *pullRequestService) withUnresolvedReviewThreads(ctx context.Context, prs ...*models.PullRequest) error {
+	ids := make([]uuid.UUID, len(prs))
+	for i, pr := range prs {
+		ids[i] = pr.ID
+	}
+	counts, err := unresolvedReviewThreads(ctx, s.db, ids...)
+	if err != nil {
+		return err
+	}
+	for _, pr := range prs {
+		pr.UnresolvedReviewThreads = counts[pr.ID]
+	}
+	return nil
 }
 
 func (s *pullRequestService) Update(ctx context.Context, id uuid.UUID, req UpdatePullRequestRequest) (*models.PullRequest, error) {
@@ -159,6 +346,13 @@ func (s *pullRequestService) Update(ctx context.Context, id uuid.UUID, req Updat
 
 	updates := make(map[string]interface{})
 	if req.Title != nil {
+		var repo models.Repository
+		if err := s.db.First(&repo, "id = ?", pr.RepositoryID).Error; err != nil {
+			return nil, fmt.Errorf("repository not found: %w", err)
+		}
+		if err := CheckPullRequestTitle(&repo, *req.Title); err != nil {
+			return nil, err
+		}
 		updates["title"] = *req.Title
 	}
 	if req.Body != nil {
@@ -174,6 +368,16 @@ func (s *pullRequestService) Update(ctx context.Context, id uuid.UUID, req Updat
 		}
 	}
 
+	if req.Title != nil || req.Body != nil {
+		if err := s.issueLinks.LinkPullRequest(ctx, &pr); err != nil {
+			s.logger.WithError(err).WithField("pull_request_id", pr.ID).Warn("Failed to link pull request to issues")
+		}
+		var err error
+		if pr.ChangeTicket, err = s.changes.LinkPullRequest(ctx, &pr); err != nil {
+			s.logger.WithError(err).WithField("pull_request_id", pr.ID).Warn("Failed to link pull request to change ticket")
+		}
+	}
+
 	return &pr, nil
 }
 
@@ -182,13 +386,131 @@ func (s *pullRequestService) Close(ctx context.Context, id uuid.UUID) error {
 		Update("state", models.PullRequestStateClosed).Error
 }
 
+// Merge merges a pull request once branch protection and path protection
+// review requirements are met; otherwise it returns a *MergeBlockedError.
+// A commit title or message left empty is rendered from the repository's
+// merge commit templates.
 func (s *pullRequestService) Merge(ctx context.Context, id uuid.UUID, req MergePullRequestRequest) error {
-	// Simplified merge - just update state
-	return s.db.Model(&models.PullRequest{}).Where("id = ?", id).
-		Updates(map[string]interface{}{
-			"state":  models.PullRequestStateMerged,
-			"merged": true,
+	method := models.MergeMethod(req.MergeMethod)
+	switch method {
+	case "":
+		method = models.MergeMethodMerge
+	case models.MergeMethodMerge, models.MergeMethodSquash, models.MergeMethodRebase:
+	default:
+		return ErrInvalidMergeMethod
+	}
+
+	var pr models.PullRequest
+	if err := s.db.WithContext(ctx).Preload("User").First(&pr, "id = ?", id).Error; err != nil {
+		return err
+	}
+
+	requirements, err := s.pathProtection.EvaluatePullRequest(ctx, &pr)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate review requirements: %w", err)
+	}
+	if !requirements.Satisfied {
+		return &MergeBlockedError{Requirements: requirements}
+	}
+
+	var repo models.Repository
+	if err := s.db.WithContext(ctx).First(&repo, "id = ?", pr.RepositoryID).Error; err != nil {
+		return fmt.Errorf("repository not found: %w", err)
+	}
+	title, message := MergeCommitMessage(&repo, method, s.mergeMessageData(ctx, &repo, &pr))
+	if req.CommitTitle != "" {
+		title = req.CommitTitle
+	}
+	if req.CommitMessage != "" {
+		message = req.CommitMessage
+	}
+
+	// Simplified merge - just update state and record the merge commit
+	now := time.Now()
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.PullRequest{}).Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"state":     models.PullRequestStateMerged,
+				"merged":    true,
+				"merged_at": now,
+			}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&models.PullRequestMerge{
+			ID:            uuid.New(),
+			PullRequestID: pr.ID,
+			MergeMethod:   method,
+			CommitTitle:   title,
+			CommitMessage: message,
+			MergedAt:      now,
 		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	metadata := map[string]interface{}{"number": pr.Number, "merge_method": string(method), "base": pr.BaseBranch}
+	if ticket := requirements.ChangeTicket; ticket != nil && ticket.Ticket != nil {
+		metadata["change_ticket"] = ticket.Ticket.TicketKey
+		if err := s.changes.MarkMerged(ctx, &pr, now); err != nil {
+			s.logger.WithError(err).WithField("pull_request_id", pr.ID).Warn("Failed to record change ticket merge")
+		}
+	}
+	audit.Record(ctx, audit.Event{
+		Action:       audit.ActionPullRequestMerge,
+		RepositoryID: &pr.RepositoryID,
+		TargetType:   "pull_request",
+		TargetID:     pr.ID.String(),
+		TargetName:   fmt.Sprintf("#%d", pr.Number),
+		Metadata:     metadata,
+	})
+
+	if err := s.issueLinks.CloseLinkedIssues(ctx, &pr); err != nil {
+		s.logger.WithError(err).WithField("pull_request_id", pr.ID).Warn("Failed to close issues linked to pull request")
+	}
+	return nil
+}
+
+// mergeMessageData gathers what merge commit templates expand to; the
+// commit list and co-authors are left empty if the branches cannot be compared
+func (s *pullRequestService) mergeMessageData(ctx context.Context, repo *models.Repository, pr *models.PullRequest) MergeMessageData {
+	data := MergeMessageData{
+		Number:     pr.Number,
+		Title:      pr.Title,
+		Body:       pr.Body,
+		HeadBranch: pr.HeadBranch,
+		BaseBranch: pr.BaseBranch,
+	}
+	authorEmail := ""
+	if pr.User != nil {
+		data.Author = pr.User.Username
+		authorEmail = strings.ToLower(pr.User.Email)
+	}
+	if s.gitService == nil || s.repoService == nil {
+		return data
+	}
+
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, repo.ID)
+	if err != nil {
+		return data
+	}
+	comparison, err := s.gitService.CompareRefs(repoPath, pr.BaseBranch, pr.HeadBranch)
+	if err != nil {
+		s.logger.WithError(err).WithField("pull_request_id", pr.ID).Debug("Failed to list pull request commits for merge message")
+		return data
+	}
+
+	seen := map[string]bool{authorEmail: true}
+	for _, commit := range comparison.Commits {
+		data.Commits = append(data.Commits, strings.SplitN(commit.Message, "\n", 2)[0])
+		email := strings.ToLower(commit.Author.Email)
+		if !seen[email] {
+			seen[email] = true
+			data.CoAuthors = append(data.CoAuthors, fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email))
+		}
+	}
+	return data
 }
 
 func (s *pullRequestService) getNextPRNumber(repoID uuid.UUID) (int, error) {
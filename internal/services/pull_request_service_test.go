@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullRequestService_Create(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.Label{},
+		&models.PullRequest{}, &models.PullRequestFile{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	base := t.TempDir()
+	gitService := git.NewGitService(logger)
+	repoService := NewRepositoryService(db, gitService, logger, base)
+	svc := NewPullRequestService(db, gitService, repoService, logger, base)
+
+	newUser := func(name string) *models.User {
+		user := &models.User{ID: uuid.New(), Username: name, Email: name + "@example.com", PasswordHash: "x"}
+		require.NoError(t, db.Create(user).Error)
+		return user
+	}
+	author, reviewer, assignee := newUser("author"), newUser("reviewer"), newUser("assignee")
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: author.ID, OwnerType: models.OwnerTypeUser, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	label := &models.Label{ID: uuid.New(), RepositoryID: repo.ID, Name: "bug", Color: "#ff0000"}
+	require.NoError(t, db.Create(label).Error)
+
+	fixture := testutil.NewGitRepo(t, testutil.RepositoryPath(base, repo))
+	fixture.Commit("main", "initial", map[string]string{"README.md": "hello\n"})
+	fixture.Branch("feature", "main")
+	fixture.Commit("feature", "add code", map[string]string{"main.go": "package main\n", "README.md": "hello\nworld\n"})
+
+	notifications := NewNotificationService()
+	reviewerInbox, cancelReviewer := notifications.Subscribe(reviewer.ID)
+	defer cancelReviewer()
+	assigneeInbox, cancelAssignee := notifications.Subscribe(assignee.ID)
+	defer cancelAssignee()
+	events := make(chan PullRequestEvent, 1)
+	svc.Subscribe(func(ctx context.Context, event PullRequestEvent) {
+		NewPullRequestNotifier(nil, notifications, logger).HandlePullRequest(ctx, event)
+		events <- event
+	})
+
+	t.Run("rejects invalid refs", func(t *testing.T) {
+		for _, req := range []CreatePullRequestRequest{
+			{Title: "Same", Head: "main", Base: "main"},
+			{Title: "No head", Head: "missing", Base: "main"},
+			{Title: "No base", Head: "feature", Base: "missing"},
+		} {
+			_, err := svc.Create(ctx, repo.ID, author.ID, req)
+			assert.ErrorIs(t, err, ErrPullRequestRefsInvalid, req.Title)
+		}
+	})
+
+	t.Run("rejects unknown metadata", func(t *testing.T) {
+		for _, req := range []CreatePullRequestRequest{
+			{Title: "Reviewer", Head: "feature", Base: "main", Reviewers: []string{"nobody"}},
+			{Title: "Self review", Head: "feature", Base: "main", Reviewers: []string{"author"}},
+			{Title: "Label", Head: "feature", Base: "main", Labels: []string{"missing"}},
+		} {
+			_, err := svc.Create(ctx, repo.ID, author.ID, req)
+			assert.ErrorIs(t, err, ErrPullRequestMetadataInvalid, req.Title)
+		}
+	})
+
+	pr, err := svc.Create(ctx, repo.ID, author.ID, CreatePullRequestRequest{
+		Title: "Add code", Head: "feature", Base: "main", Draft: true,
+		Reviewers: []string{"reviewer"}, Assignees: []string{"assignee"}, Labels: []string{"bug"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, pr.Number)
+	assert.True(t, pr.Draft)
+	assert.Equal(t, repo.ID, pr.BaseRepositoryID)
+	assert.Equal(t, models.MergeableStateUnknown, pr.MergeableState)
+	require.NotNil(t, pr.User)
+	assert.Equal(t, "author", pr.User.Username)
+
+	var stored models.PullRequest
+	require.NoError(t, db.Preload("Labels").Preload("Assignees").Preload("RequestedReviewers").First(&stored, "id = ?", pr.ID).Error)
+	require.Len(t, stored.Labels, 1)
+	assert.Equal(t, "bug", stored.Labels[0].Name)
+	require.Len(t, stored.Assignees, 1)
+	assert.Equal(t, assignee.ID, stored.Assignees[0].ID)
+	require.Len(t, stored.RequestedReviewers, 1)
+	assert.Equal(t, reviewer.ID, stored.RequestedReviewers[0].ID)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, PullRequestActionOpened, event.Action)
+		assert.Equal(t, author.ID, event.ActorID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("opened event was not emitted")
+	}
+	assert.Equal(t, "pull_request_review_requested", (<-reviewerInbox).Type)
+	assert.Equal(t, "pull_request_assigned", (<-assigneeInbox).Type)
+
+	require.Eventually(t, func() bool {
+		var refreshed models.PullRequest
+		require.NoError(t, db.First(&refreshed, "id = ?", pr.ID).Error)
+		return refreshed.MergeableState != models.MergeableStateUnknown
+	}, 5*time.Second, 20*time.Millisecond)
+
+	var refreshed models.PullRequest
+	require.NoError(t, db.First(&refreshed, "id = ?", pr.ID).Error)
+	assert.Equal(t, models.MergeableStateClean, refreshed.MergeableState)
+	require.NotNil(t, refreshed.Mergeable)
+	assert.True(t, *refreshed.Mergeable)
+	assert.Equal(t, 2, refreshed.ChangedFiles)
+	assert.Equal(t, 2, refreshed.Additions)
+	assert.Equal(t, 0, refreshed.Deletions)
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// pushBulkThreshold is the number of ref updates above which a push is
+	// delivered as one grouped webhook instead of one webhook per ref
+	pushBulkThreshold = 10
+	// pushDedupWindow is how long an identical ref update is suppressed, so
+	// retried mirror syncs do not fan out twice
+	pushDedupWindow = 10 * time.Minute
+)
+
+// PushGroup is the grouped payload of a single push, shared by webhooks and
+// the repository events API
+type PushGroup struct {
+	PushID       uuid.UUID   `json:"push_id"`
+	RepositoryID uuid.UUID   `json:"repository_id"`
+	PusherID     *uuid.UUID  `json:"pusher_id,omitempty"`
+	RefCount     int         `json:"ref_count"`
+	Created      int         `json:"created"`
+	Updated      int         `json:"updated"`
+	Deleted      int         `json:"deleted"`
+	Bulk         bool        `json:"bulk"`
+	Branches     []RefUpdate `json:"branches"`
+	Tags         []RefUpdate `json:"tags"`
+	Other        []RefUpdate `json:"other"`
+}
+
+// Refs returns every ref update of the group, branches first
+func (g *PushGroup) Refs() []RefUpdate {
+	refs := make([]RefUpdate, 0, g.RefCount)
+	refs = append(refs, g.Branches...)
+	refs = append(refs, g.Tags...)
+	return append(refs, g.Other...)
+}
+
+// GroupPush builds the grouped payload of a push. Refs are sorted by name so
+// the payload is stable regardless of the order the updates were applied in
+func GroupPush(event PushEvent) *PushGroup {
+	group := &PushGroup{
+		PushID:   uuid.New(),
+		PusherID: event.PusherID,
+		Branches: []RefUpdate{},
+		Tags:     []RefUpdate{},
+		Other:    []RefUpdate{},
+	}
+	if event.Repository != nil {
+		group.RepositoryID = event.Repository.ID
+	}
+
+	updates := make([]RefUpdate, len(event.Updates))
+	copy(updates, event.Updates)
+	sort.Slice(updates, func(i, j int) bool { return updates[i].Ref < updates[j].Ref })
+
+	for _, update := range updates {
+		switch {
+		case update.IsDelete():
+			group.Deleted++
+		case update.OldSHA == "" || update.OldSHA == zeroSHA:
+			group.Created++
+		default:
+			group.Updated++
+		}
+
+		switch {
+		case strings.HasPrefix(update.Ref, "refs/heads/"):
+			group.Branches = append(group.Branches, update)
+		case strings.HasPrefix(update.Ref, "refs/tags/"):
+			group.Tags = append(group.Tags, update)
+		default:
+			group.Other = append(group.Other, update)
+		}
+	}
+	group.RefCount = len(updates)
+	group.Bulk = group.RefCount > pushBulkThreshold
+	return group
+}
+
+// PushAggregator groups the ref updates of each push, drops updates that were
+// already seen recently, and fans the result out once per push
+type PushAggregator struct {
+	db       *gorm.DB
+	webhooks *WebhookDeliveryService
+	logger   *logrus.Logger
+	window   time.Duration
+	now      func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// NewPushAggregator creates a new PushAggregator; webhooks may be nil
+func NewPushAggregator(db *gorm.DB, webhooks *WebhookDeliveryService, logger *logrus.Logger) *PushAggregator {
+	return &PushAggregator{
+		db:       db,
+		webhooks: webhooks,
+		logger:   logger,
+		window:   pushDedupWindow,
+		now:      time.Now,
+		seen:     make(map[string]time.Time),
+	}
+}
+
+// HandlePush is a PushListener that records and delivers the grouped push
+func (a *PushAggregator) HandlePush(ctx context.Context, event PushEvent) {
+	if event.Repository == nil {
+		return
+	}
+
+	event.Updates = a.dedupe(event.Repository.ID, event.Updates)
+	if len(event.Updates) == 0 {
+		return
+	}
+	group := GroupPush(event)
+
+	logger := a.logger.WithFields(logrus.Fields{
+		"repository_id": group.RepositoryID,
+		"push_id":       group.PushID,
+		"ref_count":     group.RefCount,
+	})
+	if err := a.record(ctx, event.Repository, group); err != nil {
+		logger.WithError(err).Warn("Failed to record push event")
+	}
+	if err := a.deliver(ctx, group); err != nil {
+		logger.WithError(err).Warn("Failed to trigger push webhooks")
+	}
+}
+
+// dedupe drops updates already handled within the window and remembers the rest
+func (a *PushAggregator) dedupe(repoID uuid.UUID, updates []RefUpdate) []RefUpdate {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	for key, seenAt := range a.seen {
+		if now.Sub(seenAt) > a.window {
+			delete(a.seen, key)
+		}
+	}
+
+	fresh := make([]RefUpdate, 0, len(updates))
+	inPush := make(map[string]bool, len(updates))
+	for _, update := range updates {
+		key := fmt.Sprintf("%s|%s|%s|%s", repoID, update.Ref, update.OldSHA, update.NewSHA)
+		if _, ok := a.seen[key]; ok || inPush[key] {
+			continue
+		}
+		inPush[key] = true
+		fresh = append(fresh, update)
+	}
+	for key := range inPush {
+		a.seen[key] = now
+	}
+	return fresh
+}
+
+// record stores a single repository.push analytics event carrying the group
+func (a *PushAggregator) record(ctx context.Context, repo *models.Repository, group *PushGroup) error {
+	metadata, err := json.Marshal(group)
+	if err != nil {
+		return fmt.Errorf("failed to encode push group: %w", err)
+	}
+
+	repoID := repo.ID
+	event := &models.AnalyticsEvent{
+		ID:           uuid.New(),
+		EventType:    models.EventRepositoryPush,
+		ActorID:      group.PusherID,
+		ActorType:    "system",
+		TargetType:   "repository",
+		TargetID:     &repoID,
+		RepositoryID: &repoID,
+		Metadata:     string(metadata),
+	}
+	if group.PusherID != nil {
+		event.ActorType = "user"
+	}
+	if repo.OwnerType == models.OwnerTypeOrganization {
+		orgID := repo.OwnerID
+		event.OrganizationID = &orgID
+	}
+	return a.db.WithContext(ctx).Create(event).Error
+}
+
+// deliver triggers one push webhook per ref for small pushes and a single
+// grouped webhook for bulk pushes
+func (a *PushAggregator) deliver(ctx context.Context, group *PushGroup) error {
+	if a.webhooks == nil {
+		return nil
+	}
+
+	var sender map[string]interface{}
+	if group.PusherID != nil {
+		sender = map[string]interface{}{"id": group.PusherID.String()}
+	}
+	payload := func(data map[string]interface{}) map[string]interface{} {
+		p := map[string]interface{}{"action": "pushed", "data": data}
+		if sender != nil {
+			p["sender"] = sender
+		}
+		return p
+	}
+
+	if group.Bulk {
+		return a.webhooks.TriggerWebhooks(ctx, group.RepositoryID, "push", payload(map[string]interface{}{
+			"push_id":   group.PushID.String(),
+			"bulk":      true,
+			"ref_count": group.RefCount,
+			"created":   group.Created,
+			"updated":   group.Updated,
+			"deleted":   group.Deleted,
+			"branches":  group.Branches,
+			"tags":      group.Tags,
+			"other":     group.Other,
+		}))
+	}
+
+	for _, update := range group.Refs() {
+		err := a.webhooks.TriggerWebhooks(ctx, group.RepositoryID, "push", payload(map[string]interface{}{
+			"push_id":   group.PushID.String(),
+			"ref_count": group.RefCount,
+			"ref":       update.Ref,
+			"before":    update.OldSHA,
+			"after":     update.NewSHA,
+			"created":   update.OldSHA == "" || update.OldSHA == zeroSHA,
+			"deleted":   update.IsDelete(),
+		}))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupPush(t *testing.T) {
+	repo := &models.Repository{ID: uuid.New()}
+	sha := "1111111111111111111111111111111111111111"
+	group := GroupPush(PushEvent{Repository: repo, Updates: []RefUpdate{
+		{Ref: "refs/tags/v1.0.0", OldSHA: zeroSHA, NewSHA: sha},
+		{Ref: "refs/heads/main", OldSHA: sha, NewSHA: "2222222222222222222222222222222222222222"},
+		{Ref: "refs/heads/old", OldSHA: sha, NewSHA: zeroSHA},
+		{Ref: "refs/notes/commits", OldSHA: zeroSHA, NewSHA: sha},
+	}})
+
+	assert.Equal(t, repo.ID, group.RepositoryID)
+	assert.Equal(t, 4, group.RefCount)
+	assert.Equal(t, 2, group.Created)
+	assert.Equal(t, 1, group.Updated)
+	assert.Equal(t, 1, group.Deleted)
+	assert.False(t, group.Bulk)
+	assert.Equal(t, "refs/heads/main", group.Branches[0].Ref)
+	assert.Equal(t, "refs/heads/old", group.Branches[1].Ref)
+	assert.Len(t, group.Tags, 1)
+	assert.Len(t, group.Other, 1)
+	assert.Equal(t, "refs/heads/main", group.Refs()[0].Ref)
+
+	var tags []RefUpdate
+	for i := 0; i <= pushBulkThreshold; i++ {
+		tags = append(tags, RefUpdate{Ref: fmt.Sprintf("refs/tags/v%d", i), OldSHA: zeroSHA, NewSHA: sha})
+	}
+	assert.True(t, GroupPush(PushEvent{Repository: repo, Updates: tags}).Bulk)
+}
+
+func TestPushAggregator_RecordsOneDedupedEventPerPush(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.AnalyticsEvent{})
+
+	aggregator := NewPushAggregator(db, nil, logrus.New())
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	aggregator.now = func() time.Time { return now }
+
+	orgID := uuid.New()
+	pusherID := uuid.New()
+	repo := &models.Repository{ID: uuid.New(), OwnerID: orgID, OwnerType: models.OwnerTypeOrganization}
+	update := RefUpdate{Ref: "refs/heads/main", OldSHA: zeroSHA, NewSHA: "1111111111111111111111111111111111111111"}
+	event := PushEvent{Repository: repo, PusherID: &pusherID, Updates: []RefUpdate{update, update}}
+
+	aggregator.HandlePush(context.Background(), event)
+	// A retried sync of the same update inside the window is dropped
+	aggregator.HandlePush(context.Background(), event)
+
+	var events []models.AnalyticsEvent
+	require.NoError(t, db.Find(&events).Error)
+	require.Len(t, events, 1)
+	assert.Equal(t, models.EventRepositoryPush, events[0].EventType)
+	assert.Equal(t, "user", events[0].ActorType)
+	assert.Equal(t, orgID, *events[0].OrganizationID)
+
+	var group PushGroup
+	require.NoError(t, json.Unmarshal([]byte(events[0].Metadata), &group))
+	assert.Equal(t, 1, group.RefCount)
+	assert.Equal(t, 1, group.Created)
+	assert.Equal(t, []RefUpdate{update}, group.Branches)
+
+	now = now.Add(pushDedupWindow + time.Minute)
+	aggregator.HandlePush(context.Background(), event)
+	var count int64
+	require.NoError(t, db.Model(&models.AnalyticsEvent{}).Count(&count).Error)
+	assert.Equal(t, int64(2), count)
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+const zeroSHA = "0000000000000000000000000000000000000000"
+
+// RefUpdate is a single ref change applied by a push
+type RefUpdate struct {
+	Ref    string `json:"ref"`
+	OldSHA string `json:"before"`
+	NewSHA string `json:"after"`
+}
+
+// IsDelete reports whether the push removed the ref
+func (u RefUpdate) IsDelete() bool {
+	return u.NewSHA == "" || u.NewSHA == zeroSHA
+}
+
+// BranchName returns the short branch name, or "" for non-branch refs
+func (u RefUpdate) BranchName() string {
+	if !strings.HasPrefix(u.Ref, "refs/heads/") {
+		return ""
+	}
+	return strings.TrimPrefix(u.Ref, "refs/heads/")
+}
+
+// PushEvent describes a completed push to a repository
+type PushEvent struct {
+	Repository *models.Repository
+	PusherID   *uuid.UUID
+	Updates    []RefUpdate
+}
+
+// PushListener reacts to a completed push
+type PushListener func(ctx context.Context, event PushEvent)
+
+// PushDispatcher fans completed pushes out to post-receive listeners
+type PushDispatcher struct {
+	mu        sync.RWMutex
+	listeners []PushListener
+	logger    *logrus.Logger
+}
+
+// NewPushDispatcher creates an empty dispatcher
+func NewPushDispatcher(logger *logrus.Logger) *PushDispatcher {
+	return &PushDispatcher{logger: logger}
+}
+
+// Subscribe registers a listener for every subsequent push
+func (d *PushDispatcher) Subscribe(listener PushListener) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.listeners = append(d.listeners, listener)
+}
+
+// Dispatch runs every listener in the background so the push response is not delayed
+func (d *PushDispatcher) Dispatch(event PushEvent) {
+	if len(event.Updates) == 0 {
+		return
+	}
+
+	d.mu.RLock()
+	listeners := make([]PushListener, len(d.listeners))
+	copy(listeners, d.listeners)
+	d.mu.RUnlock()
+
+	tags := map[string]string{}
+	if event.Repository != nil {
+		tags["repository_id"] = event.Repository.ID.String()
+	}
+	for _, listener := range listeners {
+		go func(l PushListener) {
+			defer errorreporting.Default().Recover("push_listener", tags)
+			l(context.Background(), event)
+		}(listener)
+	}
+}
+
+// DiffRefs returns the updates between two ref snapshots (ref name to SHA)
+func DiffRefs(before, after map[string]string) []RefUpdate {
+	var updates []RefUpdate
+	for ref, newSHA := range after {
+		if oldSHA := before[ref]; oldSHA != newSHA {
+			if oldSHA == "" {
+				oldSHA = zeroSHA
+			}
+			updates = append(updates, RefUpdate{Ref: ref, OldSHA: oldSHA, NewSHA: newSHA})
+		}
+	}
+	for ref, oldSHA := range before {
+		if _, ok := after[ref]; !ok {
+			updates = append(updates, RefUpdate{Ref: ref, OldSHA: oldSHA, NewSHA: zeroSHA})
+		}
+	}
+	return updates
+}
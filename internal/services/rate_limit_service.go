@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// Rate limit categories
+const (
+	RateLimitCore    = "core"
+	RateLimitSearch  = "search"
+	RateLimitGit     = "git"
+	RateLimitExports = "exports"
+)
+
+// RateLimitCategories lists the categories in the order they are reported
+var RateLimitCategories = []string{RateLimitCore, RateLimitSearch, RateLimitGit, RateLimitExports}
+
+// rateLimitPruneInterval is how often windows that ended are forgotten
+const rateLimitPruneInterval = 5 * time.Minute
+
+// RateLimitCaller identifies who requests are counted against: a user when
+// authenticated, a client IP otherwise
+type RateLimitCaller struct {
+	Key           string
+	Authenticated bool
+}
+
+// RateLimitStatus is the state of a caller's limit in one category. Reset
+// is the Unix time the current window ends.
+type RateLimitStatus struct {
+	Resource  string `json:"resource"`
+	Limit     int    `json:"limit"`
+	Used      int    `json:"used"`
+	Remaining int    `json:"remaining"`
+	Reset     int64  `json:"reset"`
+}
+
+// RateLimitService counts the requests of callers per category
+type RateLimitService interface {
+	// Enabled reports whether requests are limited at all
+	Enabled() bool
+	// Take counts a request of caller in category, returning false when
+	// the limit was already used up. Unlimited categories always allow.
+	Take(category string, caller RateLimitCaller) (RateLimitStatus, bool)
+	// Status returns the limits of caller in every limited category
+	Status(caller RateLimitCaller) []RateLimitStatus
+}
+
+type rateWindow struct {
+	used    int
+	resetAt time.Time
+}
+
+// rateLimitStore keeps the request counts of rate limit windows by key
+type rateLimitStore interface {
+	// take counts a request in the window of key unless limit requests were
+	// counted already, starting a new window when the last one ended
+	take(key string, limit int, window time.Duration, now time.Time) (rateWindow, bool, error)
+	// peek returns the window of key without counting a request
+	peek(key string, window time.Duration, now time.Time) (rateWindow, error)
+}
+
+type rateLimitService struct {
+	cfg    config.RateLimits
+	now    func() time.Time
+	store  rateLimitStore
+	logger *logrus.Logger
+}
+
+// NewRateLimitService creates a rate limit service that keeps its windows
+// in the memory of this replica
+func NewRateLimitService(cfg config.RateLimits) RateLimitService {
+	return &rateLimitService{
+		cfg:   cfg,
+		now:   time.Now,
+		store: &memoryRateLimitStore{windows: make(map[string]*rateWindow)},
+	}
+}
+
+// NewRedisRateLimitService creates a rate limit service that counts in
+// Redis, so that every replica shares the windows of a caller. Requests are
+// allowed while Redis cannot be reached.
+func NewRedisRateLimitService(cfg config.RateLimits, client redis.Scripter, logger *logrus.Logger) RateLimitService {
+	return &rateLimitService{
+		cfg:    cfg,
+		now:    time.Now,
+		store:  &redisRateLimitStore{client: client},
+		logger: logger,
+	}
+}
+
+func (s *rateLimitService) Enabled() bool {
+	return s.cfg.Enabled
+}
+
+// category returns the configuration of a category
+func (s *rateLimitService) category(name string) config.RateLimitCategory {
+	switch name {
+	case RateLimitSearch:
+		return s.cfg.Search
+	case RateLimitGit:
+		return s.cfg.Git
+	case RateLimitExports:
+		return s.cfg.Exports
+	default:
+		return s.cfg.Core
+	}
+}
+
+// limit returns the limit and window of caller in a category, with a zero
+// limit for unlimited categories
+func (s *rateLimitService) limit(name string, caller RateLimitCaller) (int, time.Duration) {
+	if !s.cfg.Enabled {
+		return 0, 0
+	}
+	cfg := s.category(name)
+	limit := cfg.Limit
+	if !caller.Authenticated {
+		limit = cfg.AnonymousLimit
+	}
+	window := time.Duration(cfg.WindowSeconds) * time.Second
+	if window <= 0 {
+		window = time.Hour
+	}
+	return limit, window
+}
+
+func (s *rateLimitService) Take(category string, caller RateLimitCaller) (RateLimitStatus, bool) {
+	limit, window := s.limit(category, caller)
+	if limit <= 0 {
+		return RateLimitStatus{Resource: category}, true
+	}
+
+	now := s.now()
+	w, allowed, err := s.store.take(category+":"+caller.Key, limit, window, now)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to count request against rate limit")
+		return rateLimitStatus(category, limit, rateWindow{resetAt: now.Add(window)}), true
+	}
+	return rateLimitStatus(category, limit, w), allowed
+}
+
+func (s *rateLimitService) Status(caller RateLimitCaller) []RateLimitStatus {
+	now := s.now()
+
+	var statuses []RateLimitStatus
+	for _, category := range RateLimitCategories {
+		limit, window := s.limit(category, caller)
+		if limit <= 0 {
+			continue
+		}
+		w, err := s.store.peek(category+":"+caller.Key, window, now)
+		if err != nil {
+			s.logger.WithError(err).Warn("Failed to read rate limit")
+			w = rateWindow{resetAt: now.Add(window)}
+		}
+		statuses = append(statuses, rateLimitStatus(category, limit, w))
+	}
+	return statuses
+}
+
+func rateLimitStatus(category string, limit int, w rateWindow) RateLimitStatus {
+	return RateLimitStatus{
+		Resource:  category,
+		Limit:     limit,
+		Used:      w.used,
+		Remaining: limit - w.used,
+		Reset:     w.resetAt.Unix(),
+	}
+}
+
+// memoryRateLimitStore keeps windows in the memory of this replica
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	windows   map[string]*rateWindow
+	nextPrune time.Time
+}
+
+// window returns the current window of key, creating it when there is none
+// or the last one ended. m.mu must be held.
+func (m *memoryRateLimitStore) window(key string, window time.Duration, now time.Time) *rateWindow {
+	if now.After(m.nextPrune) {
+		for k, w := range m.windows {
+			if !now.Before(w.resetAt) {
+				delete(m.windows, k)
+			}
+		}
+		m.nextPrune = now.Add(rateLimitPruneInterval)
+	}
+
+	w, ok := m.windows[key]
+	if !ok || !now.Before(w.resetAt) {
+		w = &rateWindow{resetAt: now.Add(window)}
+		m.windows[key] = w
+	}
+	return w
+}
+
+func (m *memoryRateLimitStore) take(key string, limit int, window time.Duration, now time.Time) (rateWindow, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	w := m.window(key, window, now)
+	allowed := w.used < limit
+	if allowed {
+		w.used++
+	}
+	return *w, allowed, nil
+}
+
+func (m *memoryRateLimitStore) peek(key string, window time.Duration, now time.Time) (rateWindow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return *m.window(key, window, now), nil
+}
+
+// redisRateLimitKeyPrefix namespaces rate limit windows in Redis
+const redisRateLimitKeyPrefix = "hub:ratelimit:"
+
+// redisRateLimitTake starts a window of ARGV[2] milliseconds when KEYS[1]
+// has none and counts a request unless ARGV[1] were counted already. It
+// returns the count, the milliseconds left in the window and 1 when the
+// request was counted.
+var redisRateLimitTake = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	redis.call('SET', KEYS[1], 0, 'PX', ARGV[2])
+	ttl = tonumber(ARGV[2])
+end
+local used = tonumber(redis.call('GET', KEYS[1]))
+local allowed = 0
+if used < tonumber(ARGV[1]) then
+	used = redis.call('INCR', KEYS[1])
+	allowed = 1
+end
+return {used, ttl, allowed}
+`)
+
+// redisRateLimitPeek returns the count of KEYS[1] and the milliseconds left
+// in its window, or -1 when there is no window
+var redisRateLimitPeek = redis.NewScript(`
+local ttl = redis.call('PTTL', KEYS[1])
+if ttl < 0 then
+	return {0, -1}
+end
+return {tonumber(redis.call('GET', KEYS[1])), ttl}
+`)
+
+// redisRateLimitTimeout bounds each Redis round trip so an unreachable
+// Redis delays requests only briefly
+const redisRateLimitTimeout = 500 * time.Millisecond
+
+// redisRateLimitStore keeps windows in Redis as counters that expire when
+// the window ends
+type redisRateLimitStore struct {
+	client redis.Scripter
+}
+
+func (r *redisRateLimitStore) run(script *redis.Script, key string, args ...interface{}) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisRateLimitTimeout)
+	defer cancel()
+	values, err := script.Run(ctx, r.client, []string{redisRateLimitKeyPrefix + key}, args...).Int64Slice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run rate limit script: %w", err)
+	}
+	return values, nil
+}
+
+func (r *redisRateLimitStore) take(key string, limit int, window time.Duration, now time.Time) (rateWindow, bool, error) {
+	values, err := r.run(redisRateLimitTake, key, limit, window.Milliseconds())
+	if err != nil {
+		return rateWindow{}, false, err
+	}
+	if len(values) != 3 {
+		return rateWindow{}, false, fmt.Errorf("unexpected rate limit script result %v", values)
+	}
+	w := rateWindow{used: int(values[0]), resetAt: now.Add(time.Duration(values[1]) * time.Millisecond)}
+	return w, values[2] == 1, nil
+}
+
+func (r *redisRateLimitStore) peek(key string, window time.Duration, now time.Time) (rateWindow, error) {
+	values, err := r.run(redisRateLimitPeek, key)
+	if err != nil {
+		return rateWindow{}, err
+	}
+	if len(values) != 2 {
+		return rateWindow{}, fmt.Errorf("unexpected rate limit script result %v", values)
+	}
+	if values[1] < 0 {
+		return rateWindow{resetAt: now.Add(window)}, nil
+	}
+	return rateWindow{used: int(values[0]), resetAt: now.Add(time.Duration(values[1]) * time.Millisecond)}, nil
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitService(t *testing.T) {
+	svc := NewRateLimitService(config.RateLimits{
+		Enabled: true,
+		Core:    config.RateLimitCategory{Limit: 2, AnonymousLimit: 1, WindowSeconds: 60},
+		Search:  config.RateLimitCategory{Limit: 5, WindowSeconds: 10},
+	}).(*rateLimitService)
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	user := RateLimitCaller{Key: "user:1", Authenticated: true}
+
+	status, ok := svc.Take(RateLimitCore, user)
+	require.True(t, ok)
+	assert.Equal(t, RateLimitStatus{Resource: RateLimitCore, Limit: 2, Used: 1, Remaining: 1, Reset: now.Add(time.Minute).Unix()}, status)
+	_, ok = svc.Take(RateLimitCore, user)
+	require.True(t, ok)
+	status, ok = svc.Take(RateLimitCore, user)
+	assert.False(t, ok)
+	assert.Equal(t, 0, status.Remaining)
+
+	// Categories without a limit for the caller are not reported
+	statuses := svc.Status(RateLimitCaller{Key: "ip:10.0.0.1"})
+	require.Len(t, statuses, 1)
+	assert.Equal(t, RateLimitCore, statuses[0].Resource)
+	_, ok = svc.Take(RateLimitSearch, RateLimitCaller{Key: "ip:10.0.0.1"})
+	assert.True(t, ok)
+
+	statuses = svc.Status(user)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, 2, statuses[0].Used)
+	assert.Equal(t, RateLimitSearch, statuses[1].Resource)
+	assert.Equal(t, 5, statuses[1].Remaining)
+
+	// A new window starts once the last one ended
+	now = now.Add(time.Minute)
+	status, ok = svc.Take(RateLimitCore, user)
+	assert.True(t, ok)
+	assert.Equal(t, 1, status.Used)
+	assert.Equal(t, now.Add(time.Minute).Unix(), status.Reset)
+}
+
+// fakeRateLimitRedis runs the rate limit scripts against counters in
+// memory, the way Redis would
+type fakeRateLimitRedis struct {
+	redis.Scripter
+	now      func() time.Time
+	down     bool
+	counters map[string]int64
+	expiry   map[string]time.Time
+}
+
+// EvalSha tells the scripts apart by their arguments: only the take
+// script has any
+func (f *fakeRateLimitRedis) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	cmd := redis.NewCmd(ctx)
+	if f.down {
+		cmd.SetErr(errors.New("dial tcp: connection refused"))
+		return cmd
+	}
+	key, now := keys[0], f.now()
+	ttl := int64(-1)
+	if expiry, ok := f.expiry[key]; ok && now.Before(expiry) {
+		ttl = expiry.Sub(now).Milliseconds()
+	}
+	if len(args) == 0 {
+		cmd.SetVal([]interface{}{f.counters[key], ttl})
+		return cmd
+	}
+
+	limit, window := int64(args[0].(int)), args[1].(int64)
+	if ttl < 0 {
+		f.counters[key], f.expiry[key], ttl = 0, now.Add(time.Duration(window)*time.Millisecond), window
+	}
+	allowed := int64(0)
+	if f.counters[key] < limit {
+		f.counters[key]++
+		allowed = 1
+	}
+	cmd.SetVal([]interface{}{f.counters[key], ttl, allowed})
+	return cmd
+}
+
+func TestRedisRateLimitService(t *testing.T) {
+	cfg := config.RateLimits{Enabled: true, Core: config.RateLimitCategory{Limit: 3, WindowSeconds: 60}}
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := &fakeRateLimitRedis{now: func() time.Time { return now }, counters: map[string]int64{}, expiry: map[string]time.Time{}}
+	replicas := []*rateLimitService{
+		NewRedisRateLimitService(cfg, store, logrus.New()).(*rateLimitService),
+		NewRedisRateLimitService(cfg, store, logrus.New()).(*rateLimitService),
+	}
+	for _, replica := range replicas {
+		replica.now = func() time.Time { return now }
+	}
+	user := RateLimitCaller{Key: "user:1", Authenticated: true}
+
+	// Replicas count against the same window
+	for i := 0; i < 3; i++ {
+		status, ok := replicas[i%2].Take(RateLimitCore, user)
+		require.True(t, ok)
+		assert.Equal(t, i+1, status.Used)
+		assert.Equal(t, now.Add(time.Minute).Unix(), status.Reset)
+	}
+	_, ok := replicas[1].Take(RateLimitCore, user)
+	assert.False(t, ok)
+	statuses := replicas[0].Status(user)
+	require.Len(t, statuses, 1)
+	assert.Zero(t, statuses[0].Remaining)
+
+	now = now.Add(time.Minute)
+	status, ok := replicas[0].Take(RateLimitCore, user)
+	assert.True(t, ok)
+	assert.Equal(t, 1, status.Used)
+
+	// Requests are allowed while Redis is unreachable
+	store.down = true
+	status, ok = replicas[1].Take(RateLimitCore, user)
+	assert.True(t, ok)
+	assert.Equal(t, 3, status.Remaining)
+}
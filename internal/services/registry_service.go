@@ -0,0 +1,824 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrRegistryNameUnknown         = errors.New("image is not known to the registry")
+	ErrRegistryBlobUnknown         = errors.New("blob is not known to the registry")
+	ErrRegistryUploadUnknown       = errors.New("blob upload is not known to the registry")
+	ErrRegistryUploadInvalid       = errors.New("blob upload invalid")
+	ErrRegistryBlobTooLarge        = errors.New("blob exceeds the maximum size")
+	ErrRegistryDigestInvalid       = errors.New("digest invalid")
+	ErrRegistryManifestUnknown     = errors.New("manifest is not known to the registry")
+	ErrRegistryManifestInvalid     = errors.New("manifest invalid")
+	ErrRegistryManifestBlobUnknown = errors.New("manifest references unknown blobs")
+)
+
+// MaxManifestSize is the largest manifest the registry accepts
+const MaxManifestSize = 4 << 20
+
+// Manifest media types the registry recognizes when a client does not say
+const (
+	MediaTypeOCIManifest = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeOCIIndex    = "application/vnd.oci.image.index.v1+json"
+)
+
+var (
+	registryDigestPattern = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	registryTagPattern    = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+)
+
+// RegistryGCResult reports what a garbage collection run removed
+type RegistryGCResult struct {
+	// ImagesDeleted counts images of repositories that no longer exist
+	ImagesDeleted int `json:"images_deleted"`
+	// BlobsUnlinked counts blobs no manifest of their image refers to
+	BlobsUnlinked int64 `json:"blobs_unlinked"`
+	// ObjectsDeleted counts stored blobs and manifests no image uses any more
+	ObjectsDeleted int   `json:"objects_deleted"`
+	BytesFreed     int64 `json:"bytes_freed"`
+	UploadsExpired int   `json:"uploads_expired"`
+}
+
+// RegistryService implements the OCI distribution API. Blob and manifest
+// content is stored once per digest in the registry storage backend and
+// linked to the images it was pushed to; images can only pull what is
+// linked to them. Every image belongs to a repository, whose permissions
+// the handlers check.
+type RegistryService interface {
+	// ListImages returns the images of a repository with their tags
+	ListImages(ctx context.Context, repo *models.Repository) ([]*models.ContainerImage, error)
+
+	StatBlob(ctx context.Context, repo *models.Repository, name, digest string) (*models.ContainerBlob, error)
+	OpenBlob(ctx context.Context, repo *models.Repository, name, digest string) (*models.ContainerBlob, io.ReadCloser, error)
+
+	// StartUpload opens a blob upload, creating the image on its first push
+	StartUpload(ctx context.Context, repo *models.Repository, name string) (*models.ContainerUpload, error)
+	GetUpload(ctx context.Context, repo *models.Repository, name string, id uuid.UUID) (*models.ContainerUpload, error)
+	// AppendUpload adds a chunk to an upload. offset is where the client
+	// says the chunk starts, or -1 when it did not say.
+	AppendUpload(ctx context.Context, repo *models.Repository, name string, id uuid.UUID, offset int64, content io.Reader) (*models.ContainerUpload, error)
+	// CompleteUpload appends a last chunk and stores the upload as a blob
+	// after checking its content matches digest
+	CompleteUpload(ctx context.Context, repo *models.Repository, name string, id uuid.UUID, digest string, content io.Reader) (*models.ContainerBlob, error)
+	CancelUpload(ctx context.Context, repo *models.Repository, name string, id uuid.UUID) error
+
+	// PutManifest stores a manifest whose blobs and child manifests were
+	// pushed to the image; reference is a tag or the manifest digest
+	PutManifest(ctx context.Context, repo *models.Repository, name, reference, mediaType string, content []byte) (*models.ContainerManifest, error)
+	GetManifest(ctx context.Context, repo *models.Repository, name, reference string) (*models.ContainerManifest, []byte, error)
+	// DeleteManifest removes a tag, or a manifest and the tags pointing at it
+	// when reference is a digest. Content is removed by garbage collection.
+	DeleteManifest(ctx context.Context, repo *models.Repository, name, reference string) error
+	// ListTags returns up to n tags after last in lexical order; n <= 0
+	// returns them all
+	ListTags(ctx context.Context, repo *models.Repository, name string, n int, last string) ([]string, error)
+
+	// CollectGarbage removes blobs no manifest refers to, images of deleted
+	// repositories and abandoned uploads
+	CollectGarbage(ctx context.Context) (*RegistryGCResult, error)
+	StartScheduler(ctx context.Context)
+}
+
+type registryService struct {
+	db      *gorm.DB
+	backend storage.Backend
+	cfg     config.RegistryStorage
+	logger  *logrus.Logger
+}
+
+// NewRegistryService creates a new RegistryService storing content in backend
+func NewRegistryService(db *gorm.DB, backend storage.Backend, cfg config.RegistryStorage, logger *logrus.Logger) RegistryService {
+	return &registryService{
+		db:      db,
+		backend: backend,
+		cfg:     cfg,
+		logger:  logger,
+	}
+}
+
+// blobPath is where content with digest is stored in the backend
+func blobPath(digest string) string {
+	algorithm, hash, _ := strings.Cut(digest, ":")
+	return path.Join("blobs", algorithm, hash[:2], hash)
+}
+
+// digestFromPath reverses blobPath
+func digestFromPath(p string) (string, bool) {
+	parts := strings.Split(p, "/")
+	if len(parts) != 4 || parts[0] != "blobs" {
+		return "", false
+	}
+	digest := parts[1] + ":" + parts[3]
+	return digest, registryDigestPattern.MatchString(digest)
+}
+
+func (s *registryService) uploadDir() string {
+	if s.cfg.UploadPath != "" {
+		return s.cfg.UploadPath
+	}
+	return filepath.Join(os.TempDir(), "hub-registry-uploads")
+}
+
+func (s *registryService) uploadFile(id uuid.UUID) string {
+	return filepath.Join(s.uploadDir(), id.String())
+}
+
+func validDigest(digest string) error {
+	if !registryDigestPattern.MatchString(digest) {
+		return fmt.Errorf("%w: %q is not a sha256 digest", ErrRegistryDigestInvalid, digest)
+	}
+	return nil
+}
+
+// image returns the image named name in repo; ErrRegistryNameUnknown when
+// nothing was pushed to it
+func (s *registryService) image(ctx context.Context, repo *models.Repository, name string) (*models.ContainerImage, error) {
+	var image models.ContainerImage
+	err := s.db.WithContext(ctx).Where("name = ? AND repository_id = ?", name, repo.ID).First(&image).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRegistryNameUnknown
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+	return &image, nil
+}
+
+// ensureImage returns the image named name in repo, creating it when the
+// first content is pushed to it
+func (s *registryService) ensureImage(ctx context.Context, repo *models.Repository, name string) (*models.ContainerImage, error) {
+	image, err := s.image(ctx, repo, name)
+	if !errors.Is(err, ErrRegistryNameUnknown) {
+		return image, err
+	}
+
+	// An image left behind by a deleted repository of the same name that
+	// garbage collection has not removed yet is replaced
+	var stale models.ContainerImage
+	if err := s.db.WithContext(ctx).Where("name = ?", name).First(&stale).Error; err == nil {
+		if err := s.deleteImage(ctx, &stale); err != nil {
+			return nil, err
+		}
+	}
+
+	image = &models.ContainerImage{ID: uuid.New(), RepositoryID: repo.ID, Name: name}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(image).Error; err != nil {
+		return nil, fmt.Errorf("failed to create image: %w", err)
+	}
+	return s.image(ctx, repo, name)
+}
+
+func (s *registryService) ListImages(ctx context.Context, repo *models.Repository) ([]*models.ContainerImage, error) {
+	var images []*models.ContainerImage
+	err := s.db.WithContext(ctx).Where("repository_id = ?", repo.ID).
+		Preload("Tags", func(db *gorm.DB) *gorm.DB { return db.Order("name") }).
+		Preload("Tags.Manifest").
+		Order("name").Find(&images).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	return images, nil
+}
+
+func (s *registryService) StatBlob(ctx context.Context, repo *models.Repository, name, digest string) (*models.ContainerBlob, error) {
+	if err := validDigest(digest); err != nil {
+		return nil, err
+	}
+	image, err := s.image(ctx, repo, name)
+	if err != nil {
+		return nil, err
+	}
+	var blob models.ContainerBlob
+	err = s.db.WithContext(ctx).Where("image_id = ? AND digest = ?", image.ID, digest).First(&blob).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRegistryBlobUnknown
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get blob: %w", err)
+	}
+	return &blob, nil
+}
+
+func (s *registryService) OpenBlob(ctx context.Context, repo *models.Repository, name, digest string) (*models.ContainerBlob, io.ReadCloser, error) {
+	blob, err := s.StatBlob(ctx, repo, name, digest)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := s.backend.Download(ctx, blobPath(blob.Digest))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read blob: %w", err)
+	}
+	return blob, content, nil
+}
+
+func (s *registryService) StartUpload(ctx context.Context, repo *models.Repository, name string) (*models.ContainerUpload, error) {
+	image, err := s.ensureImage(ctx, repo, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(s.uploadDir(), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	upload := &models.ContainerUpload{ID: uuid.New(), ImageID: image.ID}
+	file, err := os.OpenFile(s.uploadFile(upload.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create upload: %w", err)
+	}
+	file.Close()
+	if err := s.db.WithContext(ctx).Create(upload).Error; err != nil {
+		os.Remove(s.uploadFile(upload.ID))
+		return nil, fmt.Errorf("failed to record upload: %w", err)
+	}
+	return upload, nil
+}
+
+func (s *registryService) GetUpload(ctx context.Context, repo *models.Repository, name string, id uuid.UUID) (*models.ContainerUpload, error) {
+	image, err := s.image(ctx, repo, name)
+	if errors.Is(err, ErrRegistryNameUnknown) {
+		return nil, ErrRegistryUploadUnknown
+	}
+	if err != nil {
+		return nil, err
+	}
+	var upload models.ContainerUpload
+	err = s.db.WithContext(ctx).Where("id = ? AND image_id = ?", id, image.ID).First(&upload).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRegistryUploadUnknown
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get upload: %w", err)
+	}
+	return &upload, nil
+}
+
+func (s *registryService) AppendUpload(ctx context.Context, repo *models.Repository, name string, id uuid.UUID, offset int64, content io.Reader) (*models.ContainerUpload, error) {
+	upload, err := s.GetUpload(ctx, repo, name, id)
+	if err != nil {
+		return nil, err
+	}
+	if offset >= 0 && offset != upload.Size {
+		return nil, fmt.Errorf("%w: chunk starts at %d but %d bytes were received", ErrRegistryUploadInvalid, offset, upload.Size)
+	}
+	if err := s.appendChunk(ctx, upload, content); err != nil {
+		return nil, err
+	}
+	return upload, nil
+}
+
+// appendChunk writes content to the end of the upload file, rolling the
+// file back when the chunk would take the blob over the size limit
+func (s *registryService) appendChunk(ctx context.Context, upload *models.ContainerUpload, content io.Reader) error {
+	if content == nil {
+		return nil
+	}
+	file, err := os.OpenFile(s.uploadFile(upload.ID), os.O_WRONLY|os.O_APPEND, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: upload data is missing", ErrRegistryUploadUnknown)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open upload: %w", err)
+	}
+	defer file.Close()
+
+	reader := content
+	limit := s.cfg.MaxBlobSizeMB * 1024 * 1024
+	if limit > 0 {
+		reader = io.LimitReader(content, limit-upload.Size+1)
+	}
+	written, err := io.Copy(file, reader)
+	if err != nil {
+		file.Truncate(upload.Size)
+		return fmt.Errorf("failed to receive blob chunk: %w", err)
+	}
+	if limit > 0 && upload.Size+written > limit {
+		file.Truncate(upload.Size)
+		return fmt.Errorf("%w of %d MB", ErrRegistryBlobTooLarge, s.cfg.MaxBlobSizeMB)
+	}
+	if written == 0 {
+		return nil
+	}
+
+	upload.Size += written
+	if err := s.db.WithContext(ctx).Model(upload).Update("size", upload.Size).Error; err != nil {
+		return fmt.Errorf("failed to record upload progress: %w", err)
+	}
+	return nil
+}
+
+func (s *registryService) CompleteUpload(ctx context.Context, repo *models.Repository, name string, id uuid.UUID, digest string, content io.Reader) (*models.ContainerBlob, error) {
+	if err := validDigest(digest); err != nil {
+		return nil, err
+	}
+	upload, err := s.GetUpload(ctx, repo, name, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.appendChunk(ctx, upload, content); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(s.uploadFile(upload.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload: %w", err)
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return nil, fmt.Errorf("failed to hash upload: %w", err)
+	}
+	if "sha256:"+hex.EncodeToString(hash.Sum(nil)) != digest {
+		s.removeUpload(ctx, upload)
+		return nil, fmt.Errorf("%w: content does not match %s", ErrRegistryDigestInvalid, digest)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	if err := s.store(ctx, digest, file, upload.Size); err != nil {
+		return nil, err
+	}
+	blob := &models.ContainerBlob{ID: uuid.New(), ImageID: upload.ImageID, Digest: digest, Size: upload.Size}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(blob).Error; err != nil {
+		return nil, fmt.Errorf("failed to record blob: %w", err)
+	}
+	s.removeUpload(ctx, upload)
+
+	s.logger.WithFields(logrus.Fields{
+		"image":  name,
+		"digest": digest,
+		"size":   upload.Size,
+	}).Info("Stored container blob")
+	return s.StatBlob(ctx, repo, name, digest)
+}
+
+func (s *registryService) CancelUpload(ctx context.Context, repo *models.Repository, name string, id uuid.UUID) error {
+	upload, err := s.GetUpload(ctx, repo, name, id)
+	if err != nil {
+		return err
+	}
+	s.removeUpload(ctx, upload)
+	return nil
+}
+
+func (s *registryService) removeUpload(ctx context.Context, upload *models.ContainerUpload) {
+	if err := os.Remove(s.uploadFile(upload.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.logger.WithError(err).WithField("upload_id", upload.ID).Warn("Failed to remove container upload data")
+	}
+	if err := s.db.WithContext(ctx).Delete(&models.ContainerUpload{}, "id = ?", upload.ID).Error; err != nil {
+		s.logger.WithError(err).WithField("upload_id", upload.ID).Warn("Failed to remove container upload")
+	}
+}
+
+// store writes content to the backend unless the digest is stored already
+func (s *registryService) store(ctx context.Context, digest string, content io.Reader, size int64) error {
+	exists, err := s.backend.Exists(ctx, blobPath(digest))
+	if err != nil {
+		return fmt.Errorf("failed to check stored content: %w", err)
+	}
+	if exists {
+		return nil
+	}
+	if err := s.backend.Upload(ctx, blobPath(digest), content, size); err != nil {
+		return fmt.Errorf("failed to store content: %w", err)
+	}
+	return nil
+}
+
+// ociDescriptor and ociManifest hold the fields of image manifests and
+// indexes the registry checks
+type ociDescriptor struct {
+	MediaType string   `json:"mediaType"`
+	Digest    string   `json:"digest"`
+	Size      int64    `json:"size"`
+	URLs      []string `json:"urls"`
+}
+
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Config        *ociDescriptor  `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+func (s *registryService) PutManifest(ctx context.Context, repo *models.Repository, name, reference, mediaType string, content []byte) (*models.ContainerManifest, error) {
+	if len(content) > MaxManifestSize {
+		return nil, fmt.Errorf("%w: manifests are limited to %d bytes", ErrRegistryManifestInvalid, MaxManifestSize)
+	}
+	sum := sha256.Sum256(content)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	tag := ""
+	if strings.Contains(reference, ":") {
+		if reference != digest {
+			return nil, fmt.Errorf("%w: content does not match %s", ErrRegistryDigestInvalid, reference)
+		}
+	} else if !registryTagPattern.MatchString(reference) {
+		return nil, fmt.Errorf("%w: %q is not a valid tag", ErrRegistryManifestInvalid, reference)
+	} else {
+		tag = reference
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRegistryManifestInvalid, err)
+	}
+	if manifest.SchemaVersion != 2 {
+		return nil, fmt.Errorf("%w: schemaVersion must be 2", ErrRegistryManifestInvalid)
+	}
+	if mediaType == "" {
+		mediaType = manifest.MediaType
+	}
+	if mediaType == "" {
+		mediaType = MediaTypeOCIManifest
+		if manifest.Manifests != nil {
+			mediaType = MediaTypeOCIIndex
+		}
+	}
+
+	// Layers with URLs are not distributed by the registry and need not exist
+	var blobs, children []string
+	if manifest.Config != nil {
+		blobs = append(blobs, manifest.Config.Digest)
+	}
+	for _, layer := range manifest.Layers {
+		if len(layer.URLs) == 0 {
+			blobs = append(blobs, layer.Digest)
+		}
+	}
+	for _, child := range manifest.Manifests {
+		children = append(children, child.Digest)
+	}
+	refs := append(append([]string{}, blobs...), children...)
+	for _, ref := range refs {
+		if err := validDigest(ref); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrRegistryManifestInvalid, err)
+		}
+	}
+
+	image, err := s.ensureImage(ctx, repo, name)
+	if err != nil {
+		return nil, err
+	}
+	missing, err := s.missing(ctx, &models.ContainerBlob{}, image.ID, blobs)
+	if err != nil {
+		return nil, err
+	}
+	missingChildren, err := s.missing(ctx, &models.ContainerManifest{}, image.ID, children)
+	if err != nil {
+		return nil, err
+	}
+	if missing = append(missing, missingChildren...); len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRegistryManifestBlobUnknown, strings.Join(missing, ", "))
+	}
+
+	if err := s.store(ctx, digest, bytes.NewReader(content), int64(len(content))); err != nil {
+		return nil, err
+	}
+
+	record := &models.ContainerManifest{ID: uuid.New(), ImageID: image.ID, Digest: digest, MediaType: mediaType, Size: int64(len(content))}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.ContainerManifest
+		err := tx.Where("image_id = ? AND digest = ?", image.ID, digest).First(&existing).Error
+		switch {
+		case err == nil:
+			record = &existing
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(record).Error; err != nil {
+				return err
+			}
+			for _, ref := range refs {
+				if err := tx.Create(&models.ContainerManifestReference{ID: uuid.New(), ManifestID: record.ID, Digest: ref}).Error; err != nil {
+					return err
+				}
+			}
+		default:
+			return err
+		}
+
+		if tag == "" {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "image_id"}, {Name: "name"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"manifest_id": record.ID, "updated_at": time.Now()}),
+		}).Create(&models.ContainerTag{ID: uuid.New(), ImageID: image.ID, Name: tag, ManifestID: record.ID}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record manifest: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"image":  name,
+		"digest": digest,
+		"tag":    tag,
+	}).Info("Stored container manifest")
+	return record, nil
+}
+
+// missing returns the digests of digests that have no row of model in image
+func (s *registryService) missing(ctx context.Context, model interface{}, imageID uuid.UUID, digests []string) ([]string, error) {
+	if len(digests) == 0 {
+		return nil, nil
+	}
+	var found []string
+	if err := s.db.WithContext(ctx).Model(model).Where("image_id = ? AND digest IN ?", imageID, digests).
+		Pluck("digest", &found).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up manifest references: %w", err)
+	}
+	present := make(map[string]bool, len(found))
+	for _, digest := range found {
+		present[digest] = true
+	}
+	var missing []string
+	for _, digest := range digests {
+		if !present[digest] {
+			missing = append(missing, digest)
+			present[digest] = true
+		}
+	}
+	return missing, nil
+}
+
+// manifest resolves reference, a tag or digest, to a manifest of image
+func (s *registryService) manifest(ctx context.Context, image *models.ContainerImage, reference string) (*models.ContainerManifest, error) {
+	query := s.db.WithContext(ctx).Where("image_id = ?", image.ID)
+	if strings.Contains(reference, ":") {
+		query = query.Where("digest = ?", reference)
+	} else {
+		query = query.Where("id = (?)", s.db.Model(&models.ContainerTag{}).Select("manifest_id").
+			Where("image_id = ? AND name = ?", image.ID, reference))
+	}
+	var manifest models.ContainerManifest
+	err := query.First(&manifest).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRegistryManifestUnknown
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get manifest: %w", err)
+	}
+	return &manifest, nil
+}
+
+func (s *registryService) GetManifest(ctx context.Context, repo *models.Repository, name, reference string) (*models.ContainerManifest, []byte, error) {
+	image, err := s.image(ctx, repo, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	manifest, err := s.manifest(ctx, image, reference)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err := s.backend.Download(ctx, blobPath(manifest.Digest))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	return manifest, content, nil
+}
+
+func (s *registryService) DeleteManifest(ctx context.Context, repo *models.Repository, name, reference string) error {
+	image, err := s.image(ctx, repo, name)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(reference, ":") {
+		result := s.db.WithContext(ctx).Where("image_id = ? AND name = ?", image.ID, reference).Delete(&models.ContainerTag{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete tag: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrRegistryManifestUnknown
+		}
+		return nil
+	}
+
+	manifest, err := s.manifest(ctx, image, reference)
+	if err != nil {
+		return err
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("manifest_id = ?", manifest.ID).Delete(&models.ContainerTag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("manifest_id = ?", manifest.ID).Delete(&models.ContainerManifestReference{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.ContainerManifest{}, "id = ?", manifest.ID).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete manifest: %w", err)
+	}
+	return nil
+}
+
+func (s *registryService) ListTags(ctx context.Context, repo *models.Repository, name string, n int, last string) ([]string, error) {
+	image, err := s.image(ctx, repo, name)
+	if err != nil {
+		return nil, err
+	}
+	query := s.db.WithContext(ctx).Model(&models.ContainerTag{}).Where("image_id = ?", image.ID)
+	if last != "" {
+		query = query.Where("name > ?", last)
+	}
+	if n > 0 {
+		query = query.Limit(n)
+	}
+	tags := []string{}
+	if err := query.Order("name").Pluck("name", &tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	return tags, nil
+}
+
+// CollectGarbage leaves content pushed within the grace period alone, as
+// the manifest referring to it may still be on its way
+func (s *registryService) CollectGarbage(ctx context.Context) (*RegistryGCResult, error) {
+	result := &RegistryGCResult{}
+	cutoff := time.Now().Add(-time.Duration(s.cfg.GCGracePeriodHours) * time.Hour)
+	db := s.db.WithContext(ctx)
+
+	// Images of deleted repositories go with everything pushed to them
+	var orphaned []models.ContainerImage
+	if err := db.Where("repository_id NOT IN (?)", db.Model(&models.Repository{}).Select("id")).
+		Find(&orphaned).Error; err != nil {
+		return nil, fmt.Errorf("failed to find orphaned images: %w", err)
+	}
+	for i := range orphaned {
+		if err := s.deleteImage(ctx, &orphaned[i]); err != nil {
+			return nil, err
+		}
+		result.ImagesDeleted++
+	}
+
+	unreferenced := db.Where("created_at < ? AND NOT EXISTS (?)", cutoff,
+		s.db.Table("container_manifest_references AS r").Select("1").
+			Joins("JOIN container_manifests AS m ON m.id = r.manifest_id").
+			Where("m.image_id = container_blobs.image_id AND r.digest = container_blobs.digest")).
+		Delete(&models.ContainerBlob{})
+	if unreferenced.Error != nil {
+		return nil, fmt.Errorf("failed to unlink unreferenced blobs: %w", unreferenced.Error)
+	}
+	result.BlobsUnlinked = unreferenced.RowsAffected
+
+	var stale []models.ContainerUpload
+	if err := db.Where("updated_at < ?", cutoff).Find(&stale).Error; err != nil {
+		return nil, fmt.Errorf("failed to find abandoned uploads: %w", err)
+	}
+	for i := range stale {
+		s.removeUpload(ctx, &stale[i])
+		result.UploadsExpired++
+	}
+
+	if err := s.deleteUnusedContent(ctx, cutoff, result); err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"images_deleted":  result.ImagesDeleted,
+		"blobs_unlinked":  result.BlobsUnlinked,
+		"objects_deleted": result.ObjectsDeleted,
+		"bytes_freed":     result.BytesFreed,
+		"uploads_expired": result.UploadsExpired,
+	}).Info("Container registry garbage collection completed")
+	return result, nil
+}
+
+// deleteUnusedContent removes stored content no blob or manifest row uses
+func (s *registryService) deleteUnusedContent(ctx context.Context, cutoff time.Time, result *RegistryGCResult) error {
+	paths, err := s.backend.List(ctx, "blobs/")
+	if err != nil {
+		return fmt.Errorf("failed to list stored content: %w", err)
+	}
+	digests := make(map[string]string, len(paths))
+	for _, p := range paths {
+		if digest, ok := digestFromPath(p); ok {
+			digests[digest] = p
+		}
+	}
+	if len(digests) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(digests))
+	for digest := range digests {
+		keys = append(keys, digest)
+	}
+	sort.Strings(keys)
+	for _, model := range []interface{}{&models.ContainerBlob{}, &models.ContainerManifest{}} {
+		var used []string
+		if err := s.db.WithContext(ctx).Model(model).Distinct("digest").Where("digest IN ?", keys).
+			Pluck("digest", &used).Error; err != nil {
+			return fmt.Errorf("failed to find used content: %w", err)
+		}
+		for _, digest := range used {
+			delete(digests, digest)
+		}
+	}
+
+	for _, digest := range keys {
+		p, unused := digests[digest]
+		if !unused {
+			continue
+		}
+		if modified, err := s.backend.GetLastModified(ctx, p); err != nil || !modified.Before(cutoff) {
+			continue
+		}
+		size, _ := s.backend.GetSize(ctx, p)
+		if err := s.backend.Delete(ctx, p); err != nil {
+			s.logger.WithError(err).WithField("digest", digest).Warn("Failed to delete unused container content")
+			continue
+		}
+		result.ObjectsDeleted++
+		result.BytesFreed += size
+	}
+	return nil
+}
+
+func (s *registryService) deleteImage(ctx context.Context, image *models.ContainerImage) error {
+	var uploads []models.ContainerUpload
+	if err := s.db.WithContext(ctx).Where("image_id = ?", image.ID).Find(&uploads).Error; err != nil {
+		return fmt.Errorf("failed to find image uploads: %w", err)
+	}
+	for i := range uploads {
+		s.removeUpload(ctx, &uploads[i])
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		manifests := tx.Model(&models.ContainerManifest{}).Select("id").Where("image_id = ?", image.ID)
+		if err := tx.Where("manifest_id IN (?)", manifests).Delete(&models.ContainerManifestReference{}).Error; err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&models.ContainerTag{}, &models.ContainerManifest{}, &models.ContainerBlob{}} {
+			if err := tx.Where("image_id = ?", image.ID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&models.ContainerImage{}, "id = ?", image.ID).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete image %s: %w", image.Name, err)
+	}
+	return nil
+}
+
+// StartScheduler collects garbage every GCIntervalHours until ctx is
+// cancelled. It returns immediately when the registry or scheduled
+// collection is disabled.
+func (s *registryService) StartScheduler(ctx context.Context) {
+	if !s.cfg.Enabled || s.cfg.GCIntervalHours <= 0 {
+		return
+	}
+
+	interval := time.Duration(s.cfg.GCIntervalHours) * time.Hour
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			job := jobs.Job{Name: "registry_gc", Class: jobs.ClassGC, Interval: interval}
+			if jobs.DefaultGate().Wait(ctx, job) != nil {
+				return
+			}
+			func() {
+				defer errorreporting.Default().Recover("registry_gc", nil)
+				if _, err := s.CollectGarbage(ctx); err != nil {
+					s.logger.WithError(err).Error("Failed to collect container registry garbage")
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sha256Digest(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestRegistryService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Repository{}, &models.ContainerImage{}, &models.ContainerBlob{},
+		&models.ContainerManifest{}, &models.ContainerManifestReference{}, &models.ContainerTag{}, &models.ContainerUpload{})
+
+	ctx := context.Background()
+	backend, err := storage.NewFilesystemBackend(storage.FilesystemConfig{BasePath: t.TempDir()})
+	require.NoError(t, err)
+	svc := NewRegistryService(db, backend, config.RegistryStorage{Enabled: true, UploadPath: t.TempDir(), MaxBlobSizeMB: 1}, logrus.New())
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeOrganization, Name: "api",
+		DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	const name = "acme/api"
+
+	// A layer pushed in two chunks
+	layer := "layer contents"
+	upload, err := svc.StartUpload(ctx, repo, name)
+	require.NoError(t, err)
+	upload, err = svc.AppendUpload(ctx, repo, name, upload.ID, 0, strings.NewReader(layer[:5]))
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), upload.Size)
+	_, err = svc.AppendUpload(ctx, repo, name, upload.ID, 2, strings.NewReader(layer[5:]))
+	assert.ErrorIs(t, err, ErrRegistryUploadInvalid, "chunks must continue where the upload left off")
+	_, err = svc.CompleteUpload(ctx, repo, name, upload.ID, sha256Digest(layer), strings.NewReader(layer[5:]))
+	require.NoError(t, err)
+	_, err = svc.GetUpload(ctx, repo, name, upload.ID)
+	assert.ErrorIs(t, err, ErrRegistryUploadUnknown)
+
+	// A config pushed at once; a wrong digest is rejected
+	cfg := `{"architecture":"amd64"}`
+	upload, err = svc.StartUpload(ctx, repo, name)
+	require.NoError(t, err)
+	_, err = svc.CompleteUpload(ctx, repo, name, upload.ID, sha256Digest("other"), strings.NewReader(cfg))
+	assert.ErrorIs(t, err, ErrRegistryDigestInvalid)
+	upload, err = svc.StartUpload(ctx, repo, name)
+	require.NoError(t, err)
+	_, err = svc.CompleteUpload(ctx, repo, name, upload.ID, sha256Digest(cfg), strings.NewReader(cfg))
+	require.NoError(t, err)
+
+	upload, err = svc.StartUpload(ctx, repo, name)
+	require.NoError(t, err)
+	_, err = svc.AppendUpload(ctx, repo, name, upload.ID, -1, strings.NewReader(strings.Repeat("x", 1024*1024+1)))
+	assert.ErrorIs(t, err, ErrRegistryBlobTooLarge)
+
+	blob, content, err := svc.OpenBlob(ctx, repo, name, sha256Digest(layer))
+	require.NoError(t, err)
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+	content.Close()
+	assert.Equal(t, layer, string(data))
+	assert.Equal(t, int64(len(layer)), blob.Size)
+	_, err = svc.StatBlob(ctx, repo, "acme/api/other", sha256Digest(layer))
+	assert.ErrorIs(t, err, ErrRegistryNameUnknown, "blobs are only pulled from the images they were pushed to")
+
+	manifestFor := func(layers ...string) string {
+		descriptors := make([]string, len(layers))
+		for i, l := range layers {
+			descriptors[i] = fmt.Sprintf(`{"mediaType":"application/vnd.oci.image.layer.v1.tar+gzip","digest":%q,"size":%d}`, sha256Digest(l), len(l))
+		}
+		return fmt.Sprintf(`{"schemaVersion":2,"mediaType":%q,"config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":%q,"size":%d},"layers":[%s]}`,
+			MediaTypeOCIManifest, sha256Digest(cfg), len(cfg), strings.Join(descriptors, ","))
+	}
+
+	_, err = svc.PutManifest(ctx, repo, name, "v1", "", []byte(manifestFor(layer, "never pushed")))
+	assert.ErrorIs(t, err, ErrRegistryManifestBlobUnknown)
+	_, err = svc.PutManifest(ctx, repo, name, "bad tag!", "", []byte(manifestFor(layer)))
+	assert.ErrorIs(t, err, ErrRegistryManifestInvalid)
+	_, err = svc.PutManifest(ctx, repo, name, "v1", "", []byte(`{"schemaVersion":1}`))
+	assert.ErrorIs(t, err, ErrRegistryManifestInvalid)
+
+	body := manifestFor(layer)
+	manifest, err := svc.PutManifest(ctx, repo, name, "v1", "", []byte(body))
+	require.NoError(t, err)
+	assert.Equal(t, sha256Digest(body), manifest.Digest)
+	assert.Equal(t, MediaTypeOCIManifest, manifest.MediaType)
+	_, err = svc.PutManifest(ctx, repo, name, "latest", "", []byte(body))
+	require.NoError(t, err)
+	_, err = svc.PutManifest(ctx, repo, name, sha256Digest("something else"), "", []byte(body))
+	assert.ErrorIs(t, err, ErrRegistryDigestInvalid)
+
+	for _, reference := range []string{"v1", manifest.Digest} {
+		got, content, err := svc.GetManifest(ctx, repo, name, reference)
+		require.NoError(t, err)
+		assert.Equal(t, manifest.ID, got.ID)
+		assert.Equal(t, body, string(content))
+	}
+	tags, err := svc.ListTags(ctx, repo, name, 1, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"latest"}, tags)
+	tags, err = svc.ListTags(ctx, repo, name, 1, "latest")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1"}, tags)
+
+	images, err := svc.ListImages(ctx, repo)
+	require.NoError(t, err)
+	require.Len(t, images, 1)
+	assert.Len(t, images[0].Tags, 2)
+
+	// Deleting a tag keeps the manifest; deleting the manifest removes the
+	// remaining tags and leaves its blobs to garbage collection
+	require.NoError(t, svc.DeleteManifest(ctx, repo, name, "latest"))
+	assert.ErrorIs(t, svc.DeleteManifest(ctx, repo, name, "latest"), ErrRegistryManifestUnknown)
+	_, _, err = svc.GetManifest(ctx, repo, name, manifest.Digest)
+	require.NoError(t, err)
+
+	result, err := svc.CollectGarbage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.BlobsUnlinked, "blobs of tagged manifests are kept")
+	assert.Equal(t, 1, result.UploadsExpired, "the upload that failed is abandoned")
+
+	require.NoError(t, svc.DeleteManifest(ctx, repo, name, manifest.Digest))
+	tags, err = svc.ListTags(ctx, repo, name, 0, "")
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+
+	result, err = svc.CollectGarbage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), result.BlobsUnlinked)
+	assert.Equal(t, 3, result.ObjectsDeleted, "the layer, the config and the manifest")
+	_, err = svc.StatBlob(ctx, repo, name, sha256Digest(layer))
+	assert.ErrorIs(t, err, ErrRegistryBlobUnknown)
+	exists, err := backend.Exists(ctx, blobPath(sha256Digest(layer)))
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// Images of deleted repositories are removed
+	require.NoError(t, db.Delete(repo).Error)
+	result, err = svc.CollectGarbage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.ImagesDeleted)
+}
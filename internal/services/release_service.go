@@ -0,0 +1,536 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReleaseNotFound      = errors.New("release not found")
+	ErrReleaseExists        = errors.New("a release already exists for this tag")
+	ErrInvalidRelease       = errors.New("invalid release")
+	ErrReleaseAssetNotFound = errors.New("release asset not found")
+	ErrReleaseAssetExists   = errors.New("the release already has an asset with this name")
+	ErrReleaseAssetTooLarge = errors.New("release asset exceeds the maximum size")
+)
+
+// CreateReleaseRequest publishes a tag. A tag that does not exist yet is
+// created from TargetCommitish, or from the default branch.
+type CreateReleaseRequest struct {
+	TagName         string `json:"tag_name" binding:"required"`
+	TargetCommitish string `json:"target_commitish"`
+	Name            string `json:"name"`
+	Body            string `json:"body"`
+	Draft           bool   `json:"draft"`
+	Prerelease      bool   `json:"prerelease"`
+	// GenerateReleaseNotes appends notes listing the pull requests merged
+	// since the previous tag to Body
+	GenerateReleaseNotes bool   `json:"generate_release_notes"`
+	PreviousTagName      string `json:"previous_tag_name"`
+}
+
+// UpdateReleaseRequest edits a release; publishing a draft sets its
+// publication time
+type UpdateReleaseRequest struct {
+	Name       *string `json:"name"`
+	Body       *string `json:"body"`
+	Draft      *bool   `json:"draft"`
+	Prerelease *bool   `json:"prerelease"`
+}
+
+// GenerateReleaseNotesRequest asks for the notes of a tag without creating a
+// release. PreviousTagName defaults to the newest tag older than TagName.
+type GenerateReleaseNotesRequest struct {
+	TagName         string `json:"tag_name" binding:"required"`
+	TargetCommitish string `json:"target_commitish"`
+	PreviousTagName string `json:"previous_tag_name"`
+}
+
+// ReleaseNotes are generated from the pull requests merged between two tags
+type ReleaseNotes struct {
+	Name string `json:"name"`
+	Body string `json:"body"`
+}
+
+// UploadReleaseAssetRequest describes an asset being attached to a release
+type UploadReleaseAssetRequest struct {
+	Name        string
+	Label       string
+	ContentType string
+}
+
+// ReleaseService manages releases and their assets. Asset content is kept
+// in the release storage backend; uploads are scanned for malware and
+// quarantined assets cannot be downloaded.
+type ReleaseService interface {
+	Create(ctx context.Context, repo *models.Repository, authorID uuid.UUID, req CreateReleaseRequest) (*models.Release, error)
+	Update(ctx context.Context, repoID, releaseID uuid.UUID, req UpdateReleaseRequest) (*models.Release, error)
+	// Delete removes a release and its assets; the tag is kept
+	Delete(ctx context.Context, repoID, releaseID uuid.UUID) error
+	Get(ctx context.Context, repoID, releaseID uuid.UUID) (*models.Release, error)
+	GetByTag(ctx context.Context, repoID uuid.UUID, tagName string) (*models.Release, error)
+	// Latest returns the newest published release that is not a prerelease
+	Latest(ctx context.Context, repoID uuid.UUID) (*models.Release, error)
+	// List returns releases newest first
+	List(ctx context.Context, repoID uuid.UUID, includeDrafts bool) ([]*models.Release, error)
+	GenerateNotes(ctx context.Context, repo *models.Repository, req GenerateReleaseNotesRequest) (*ReleaseNotes, error)
+
+	UploadAsset(ctx context.Context, repoID, releaseID, uploaderID uuid.UUID, req UploadReleaseAssetRequest, content io.Reader) (*models.ReleaseAsset, error)
+	GetAsset(ctx context.Context, repoID, assetID uuid.UUID) (*models.ReleaseAsset, error)
+	// OpenAsset returns the content of an asset and counts the download
+	OpenAsset(ctx context.Context, repoID, assetID uuid.UUID) (*models.ReleaseAsset, io.ReadCloser, error)
+	DeleteAsset(ctx context.Context, repoID, assetID uuid.UUID) error
+}
+
+type releaseService struct {
+	db          *gorm.DB
+	gitService  git.GitService
+	repoService RepositoryService
+	backend     storage.Backend
+	malware     MalwareScanService
+	cfg         config.ReleaseStorage
+	logger      *logrus.Logger
+}
+
+// NewReleaseService creates a new ReleaseService
+func NewReleaseService(db *gorm.DB, gitService git.GitService, repoService RepositoryService, backend storage.Backend, malware MalwareScanService, cfg config.ReleaseStorage, logger *logrus.Logger) ReleaseService {
+	return &releaseService{
+		db:          db,
+		gitService:  gitService,
+		repoService: repoService,
+		backend:     backend,
+		malware:     malware,
+		cfg:         cfg,
+		logger:      logger,
+	}
+}
+
+func (s *releaseService) Create(ctx context.Context, repo *models.Repository, authorID uuid.UUID, req CreateReleaseRequest) (*models.Release, error) {
+	if !git.ValidTagName(req.TagName) {
+		return nil, fmt.Errorf("%w: %q is not a valid tag name", ErrInvalidRelease, req.TagName)
+	}
+	if _, err := s.GetByTag(ctx, repo.ID, req.TagName); err == nil {
+		return nil, ErrReleaseExists
+	} else if !errors.Is(err, ErrReleaseNotFound) {
+		return nil, err
+	}
+
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, repo.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository path: %w", err)
+	}
+	target := req.TargetCommitish
+	if target == "" {
+		target = repo.DefaultBranch
+	}
+
+	// Notes are generated before a new tag exists so they run up to now
+	var notes *ReleaseNotes
+	if req.GenerateReleaseNotes {
+		notes, err = s.GenerateNotes(ctx, repo, GenerateReleaseNotesRequest{
+			TagName:         req.TagName,
+			TargetCommitish: target,
+			PreviousTagName: req.PreviousTagName,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := s.gitService.GetTag(ctx, repoPath, req.TagName); err != nil {
+		if err := s.gitService.CreateTag(ctx, repoPath, req.TagName, target, ""); err != nil {
+			return nil, fmt.Errorf("%w: cannot tag %s: %v", ErrInvalidRelease, target, err)
+		}
+	}
+
+	release := &models.Release{
+		ID:              uuid.New(),
+		RepositoryID:    repo.ID,
+		TagName:         req.TagName,
+		TargetCommitish: target,
+		Name:            req.Name,
+		Body:            req.Body,
+		Draft:           req.Draft,
+		Prerelease:      req.Prerelease,
+		AuthorID:        &authorID,
+	}
+	if notes != nil {
+		if release.Name == "" {
+			release.Name = notes.Name
+		}
+		if release.Body != "" {
+			release.Body += "\n\n"
+		}
+		release.Body += notes.Body
+	}
+	if release.Name == "" {
+		release.Name = req.TagName
+	}
+	if !release.Draft {
+		now := time.Now()
+		release.PublishedAt = &now
+	}
+
+	if err := s.db.WithContext(ctx).Create(release).Error; err != nil {
+		return nil, fmt.Errorf("failed to create release: %w", err)
+	}
+	release.Assets = []models.ReleaseAsset{}
+
+	s.logger.WithFields(logrus.Fields{
+		"repository_id": repo.ID,
+		"tag":           release.TagName,
+		"draft":         release.Draft,
+	}).Info("Created release")
+	return release, nil
+}
+
+func (s *releaseService) Update(ctx context.Context, repoID, releaseID uuid.UUID, req UpdateReleaseRequest) (*models.Release, error) {
+	release, err := s.Get(ctx, repoID, releaseID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		release.Name = *req.Name
+	}
+	if req.Body != nil {
+		release.Body = *req.Body
+	}
+	if req.Prerelease != nil {
+		release.Prerelease = *req.Prerelease
+	}
+	if req.Draft != nil {
+		release.Draft = *req.Draft
+		if !release.Draft && release.PublishedAt == nil {
+			now := time.Now()
+			release.PublishedAt = &now
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Omit("Assets", "Author").Save(release).Error; err != nil {
+		return nil, fmt.Errorf("failed to update release: %w", err)
+	}
+	return release, nil
+}
+
+func (s *releaseService) Delete(ctx context.Context, repoID, releaseID uuid.UUID) error {
+	release, err := s.Get(ctx, repoID, releaseID)
+	if err != nil {
+		return err
+	}
+	for i := range release.Assets {
+		if err := s.removeAsset(ctx, &release.Assets[i]); err != nil {
+			return err
+		}
+	}
+	if err := s.db.WithContext(ctx).Delete(&models.Release{}, "id = ?", release.ID).Error; err != nil {
+		return fmt.Errorf("failed to delete release: %w", err)
+	}
+	return nil
+}
+
+func (s *releaseService) Get(ctx context.Context, repoID, releaseID uuid.UUID) (*models.Release, error) {
+	return s.find(ctx, "repository_id = ? AND id = ?", repoID, releaseID)
+}
+
+func (s *releaseService) GetByTag(ctx context.Context, repoID uuid.UUID, tagName string) (*models.Release, error) {
+	return s.find(ctx, "repository_id = ? AND tag_name = ?", repoID, tagName)
+}
+
+func (s *releaseService) Latest(ctx context.Context, repoID uuid.UUID) (*models.Release, error) {
+	var release models.Release
+	err := s.releases(ctx).
+		Where("repository_id = ? AND draft = ? AND prerelease = ?", repoID, false, false).
+		Order("published_at DESC").First(&release).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReleaseNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest release: %w", err)
+	}
+	return &release, nil
+}
+
+func (s *releaseService) List(ctx context.Context, repoID uuid.UUID, includeDrafts bool) ([]*models.Release, error) {
+	query := s.releases(ctx).Where("repository_id = ?", repoID)
+	if !includeDrafts {
+		query = query.Where("draft = ?", false)
+	}
+	var releases []*models.Release
+	if err := query.Order("created_at DESC").Find(&releases).Error; err != nil {
+		return nil, fmt.Errorf("failed to list releases: %w", err)
+	}
+	return releases, nil
+}
+
+// releases preloads what every release response carries
+func (s *releaseService) releases(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Preload("Author").Preload("Assets", func(db *gorm.DB) *gorm.DB {
+		return db.Order("name")
+	})
+}
+
+func (s *releaseService) find(ctx context.Context, query string, args ...interface{}) (*models.Release, error) {
+	var release models.Release
+	err := s.releases(ctx).Where(query, args...).First(&release).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReleaseNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release: %w", err)
+	}
+	return &release, nil
+}
+
+// GenerateNotes lists the pull requests merged after the commit of the
+// previous tag and up to the commit of the tag. A tag that does not exist
+// yet covers pull requests merged up to now.
+func (s *releaseService) GenerateNotes(ctx context.Context, repo *models.Repository, req GenerateReleaseNotesRequest) (*ReleaseNotes, error) {
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, repo.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository path: %w", err)
+	}
+
+	until := time.Now()
+	if _, err := s.gitService.GetTag(ctx, repoPath, req.TagName); err == nil {
+		if until, err = s.commitTime(ctx, repoPath, "refs/tags/"+req.TagName); err != nil {
+			return nil, err
+		}
+	} else {
+		target := req.TargetCommitish
+		if target == "" {
+			target = repo.DefaultBranch
+		}
+		if _, err := s.commitTime(ctx, repoPath, target); err != nil {
+			return nil, err
+		}
+	}
+
+	previous := req.PreviousTagName
+	var since time.Time
+	if previous != "" {
+		if since, err = s.commitTime(ctx, repoPath, "refs/tags/"+previous); err != nil {
+			return nil, err
+		}
+	} else if previous, since, err = s.previousTag(ctx, repoPath, req.TagName, until); err != nil {
+		return nil, err
+	}
+
+	query := s.db.WithContext(ctx).Preload("User").
+		Where("repository_id = ? AND merged = ? AND merged_at <= ?", repo.ID, true, until)
+	if previous != "" {
+		query = query.Where("merged_at > ?", since)
+	}
+	var pulls []models.PullRequest
+	if err := query.Order("merged_at").Find(&pulls).Error; err != nil {
+		return nil, fmt.Errorf("failed to load merged pull requests: %w", err)
+	}
+
+	var body strings.Builder
+	if len(pulls) > 0 {
+		body.WriteString("## What's Changed\n\n")
+		for _, pr := range pulls {
+			fmt.Fprintf(&body, "* %s", pr.Title)
+			if pr.User != nil {
+				fmt.Fprintf(&body, " by @%s", pr.User.Username)
+			}
+			fmt.Fprintf(&body, " in #%d\n", pr.Number)
+		}
+	} else {
+		body.WriteString("No pull requests were merged in this release.\n")
+	}
+	if previous != "" {
+		fmt.Fprintf(&body, "\n**Full Changelog**: %s...%s\n", previous, req.TagName)
+	}
+
+	return &ReleaseNotes{Name: req.TagName, Body: strings.TrimSuffix(body.String(), "\n")}, nil
+}
+
+// commitTime returns when the commit ref points at was committed
+func (s *releaseService) commitTime(ctx context.Context, repoPath, ref string) (time.Time, error) {
+	commits, err := s.gitService.GetCommits(ctx, repoPath, git.CommitOptions{Branch: ref, PerPage: 1})
+	if err != nil || len(commits) == 0 {
+		return time.Time{}, fmt.Errorf("%w: %s does not name a commit", ErrInvalidRelease, strings.TrimPrefix(ref, "refs/tags/"))
+	}
+	return commits[0].Committer.Date, nil
+}
+
+// previousTag finds the newest tag other than tagName committed before
+// until; it returns an empty name when there is none
+func (s *releaseService) previousTag(ctx context.Context, repoPath, tagName string, until time.Time) (string, time.Time, error) {
+	tags, err := s.gitService.GetTags(ctx, repoPath)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to list tags: %w", err)
+	}
+	var name string
+	var newest time.Time
+	for _, tag := range tags {
+		if tag.Name == tagName {
+			continue
+		}
+		committed, err := s.commitTime(ctx, repoPath, "refs/tags/"+tag.Name)
+		if err != nil || !committed.Before(until) {
+			continue
+		}
+		if name == "" || committed.After(newest) {
+			name, newest = tag.Name, committed
+		}
+	}
+	return name, newest, nil
+}
+
+// UploadAsset stores an asset under releases/{release}/{asset}. Content is
+// spooled to disk first so the size limit is enforced before anything is
+// stored.
+func (s *releaseService) UploadAsset(ctx context.Context, repoID, releaseID, uploaderID uuid.UUID, req UploadReleaseAssetRequest, content io.Reader) (*models.ReleaseAsset, error) {
+	release, err := s.Get(ctx, repoID, releaseID)
+	if err != nil {
+		return nil, err
+	}
+	name := path.Base(strings.ReplaceAll(req.Name, "\\", "/"))
+	if name == "" || name == "." || name == "/" || len(name) > 255 {
+		return nil, fmt.Errorf("%w: asset name is required", ErrInvalidRelease)
+	}
+	for _, asset := range release.Assets {
+		if asset.Name == name {
+			return nil, ErrReleaseAssetExists
+		}
+	}
+
+	tmp, err := os.CreateTemp("", "hub-release-asset-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	limit := s.cfg.MaxAssetSizeMB * 1024 * 1024
+	reader := content
+	if limit > 0 {
+		reader = io.LimitReader(content, limit+1)
+	}
+	size, err := io.Copy(tmp, reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive release asset: %w", err)
+	}
+	if limit > 0 && size > limit {
+		return nil, fmt.Errorf("%w of %d MB", ErrReleaseAssetTooLarge, s.cfg.MaxAssetSizeMB)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(name))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	asset := &models.ReleaseAsset{
+		ID:          uuid.New(),
+		ReleaseID:   release.ID,
+		Name:        name,
+		Label:       req.Label,
+		ContentType: contentType,
+		Size:        size,
+		UploaderID:  &uploaderID,
+	}
+	asset.StoragePath = path.Join("releases", release.ID.String(), asset.ID.String())
+
+	target := MalwareScanTarget{Kind: models.MalwareContentReleaseAsset, ContentID: asset.ID.String(), RepositoryID: repoID, Name: name, Size: size, UploaderID: &uploaderID}
+	if _, err := s.malware.Scan(ctx, target, tmp); err != nil {
+		return nil, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if err := s.backend.Upload(ctx, asset.StoragePath, tmp, size); err != nil {
+		return nil, fmt.Errorf("failed to store release asset: %w", err)
+	}
+	if err := s.db.WithContext(ctx).Create(asset).Error; err != nil {
+		if delErr := s.backend.Delete(ctx, asset.StoragePath); delErr != nil {
+			s.logger.WithError(delErr).WithField("path", asset.StoragePath).Warn("Failed to remove orphaned release asset")
+		}
+		return nil, fmt.Errorf("failed to record release asset: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"release_id": release.ID,
+		"asset":      asset.Name,
+		"size":       asset.Size,
+	}).Info("Uploaded release asset")
+	return asset, nil
+}
+
+func (s *releaseService) GetAsset(ctx context.Context, repoID, assetID uuid.UUID) (*models.ReleaseAsset, error) {
+	var asset models.ReleaseAsset
+	err := s.db.WithContext(ctx).
+		Joins("JOIN releases ON releases.id = release_assets.release_id AND releases.deleted_at IS NULL").
+		Where("release_assets.id = ? AND releases.repository_id = ?", assetID, repoID).
+		First(&asset).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReleaseAssetNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get release asset: %w", err)
+	}
+	return &asset, nil
+}
+
+func (s *releaseService) OpenAsset(ctx context.Context, repoID, assetID uuid.UUID) (*models.ReleaseAsset, io.ReadCloser, error) {
+	asset, err := s.GetAsset(ctx, repoID, assetID)
+	if err != nil {
+		return nil, nil, err
+	}
+	quarantined, err := s.malware.Quarantined(ctx, models.MalwareContentReleaseAsset, asset.ID.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	if quarantined[asset.ID.String()] {
+		return nil, nil, ErrMalwareQuarantined
+	}
+	content, err := s.backend.Download(ctx, asset.StoragePath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read release asset: %w", err)
+	}
+	err = s.db.WithContext(ctx).Model(&models.ReleaseAsset{}).Where("id = ?", asset.ID).
+		UpdateColumn("download_count", gorm.Expr("download_count + 1")).Error
+	if err != nil {
+		s.logger.WithError(err).WithField("asset_id", asset.ID).Warn("Failed to count release asset download")
+	}
+	return asset, content, nil
+}
+
+func (s *releaseService) DeleteAsset(ctx context.Context, repoID, assetID uuid.UUID) error {
+	asset, err := s.GetAsset(ctx, repoID, assetID)
+	if err != nil {
+		return err
+	}
+	return s.removeAsset(ctx, asset)
+}
+
+func (s *releaseService) removeAsset(ctx context.Context, asset *models.ReleaseAsset) error {
+	if err := s.db.WithContext(ctx).Delete(&models.ReleaseAsset{}, "id = ?", asset.ID).Error; err != nil {
+		return fmt.Errorf("failed to delete release asset: %w", err)
+	}
+	if err := s.backend.Delete(ctx, asset.StoragePath); err != nil {
+		s.logger.WithError(err).WithField("path", asset.StoragePath).Warn("Failed to remove release asset content")
+	}
+	return nil
+}
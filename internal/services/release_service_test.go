@@ -0,0 +1,131 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/storage"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.PullRequest{}, &models.Release{}, &models.ReleaseAsset{}, &models.MalwareDetection{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	base := t.TempDir()
+	gitService := git.NewGitService(logger)
+	repoService := NewRepositoryService(db, gitService, logger, base)
+	backend, err := storage.NewFilesystemBackend(storage.FilesystemConfig{BasePath: t.TempDir()})
+	require.NoError(t, err)
+	svc := NewReleaseService(db, gitService, repoService, backend, NewMalwareScanService(db, nil, config.MalwareScanning{}, logger), config.ReleaseStorage{MaxAssetSizeMB: 1}, logger)
+
+	author := &models.User{ID: uuid.New(), Username: "octo", Email: "octo@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create(author).Error)
+	repo := &models.Repository{ID: uuid.New(), OwnerID: author.ID, OwnerType: models.OwnerTypeUser, Name: "app",
+		DefaultBranch: "main", Visibility: models.VisibilityPublic}
+	require.NoError(t, db.Create(repo).Error)
+
+	now := time.Now()
+	fixture := testutil.NewGitRepo(t, testutil.RepositoryPath(base, repo))
+	fixture.Author.Date = now.Add(-3 * time.Hour)
+	fixture.Commit("main", "initial", map[string]string{"README.md": "app\n"})
+	fixture.Tag("v1.0", "main", "")
+	fixture.Author.Date = now.Add(-time.Hour)
+	fixture.Commit("main", "feature", map[string]string{"app.go": "package app\n"})
+
+	merged := func(number int, title string, ago time.Duration) *models.PullRequest {
+		at := now.Add(-ago)
+		return &models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: number, Title: title,
+			UserID: &author.ID, BaseBranch: "main", HeadBranch: "topic", State: models.PullRequestStateMerged, Merged: true, MergedAt: &at}
+	}
+	require.NoError(t, db.Create([]*models.PullRequest{
+		merged(1, "Add feature", 2*time.Hour),
+		merged(2, "Before the last release", 4*time.Hour),
+		merged(3, "Fix typo", 30*time.Minute),
+		{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 4, Title: "Still open",
+			BaseBranch: "main", HeadBranch: "open", State: models.PullRequestStateOpen},
+	}).Error)
+
+	// Notes of a new tag cover pull requests merged since the previous tag
+	notes, err := svc.GenerateNotes(ctx, repo, GenerateReleaseNotesRequest{TagName: "v1.1"})
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1", notes.Name)
+	assert.Equal(t, "## What's Changed\n\n* Add feature by @octo in #1\n* Fix typo by @octo in #3\n\n**Full Changelog**: v1.0...v1.1", notes.Body)
+
+	_, err = svc.Create(ctx, repo, author.ID, CreateReleaseRequest{TagName: "bad..tag"})
+	assert.ErrorIs(t, err, ErrInvalidRelease)
+	_, err = svc.Create(ctx, repo, author.ID, CreateReleaseRequest{TagName: "v2.0", TargetCommitish: "missing"})
+	assert.ErrorIs(t, err, ErrInvalidRelease)
+
+	release, err := svc.Create(ctx, repo, author.ID, CreateReleaseRequest{TagName: "v1.1", Body: "Highlights", GenerateReleaseNotes: true})
+	require.NoError(t, err)
+	assert.Equal(t, "v1.1", release.Name)
+	assert.True(t, strings.HasPrefix(release.Body, "Highlights\n\n## What's Changed"))
+	assert.NotNil(t, release.PublishedAt)
+	assert.Equal(t, fixture.SHA("main"), fixture.SHA("v1.1"), "the tag is created from the default branch")
+	_, err = svc.Create(ctx, repo, author.ID, CreateReleaseRequest{TagName: "v1.1"})
+	assert.ErrorIs(t, err, ErrReleaseExists)
+
+	// Existing tags are released as they are; drafts stay unpublished
+	draft, err := svc.Create(ctx, repo, author.ID, CreateReleaseRequest{TagName: "v1.0", Name: "First", Draft: true})
+	require.NoError(t, err)
+	assert.Nil(t, draft.PublishedAt)
+	public, err := svc.List(ctx, repo.ID, false)
+	require.NoError(t, err)
+	require.Len(t, public, 1)
+	all, err := svc.List(ctx, repo.ID, true)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+	latest, err := svc.Latest(ctx, repo.ID)
+	require.NoError(t, err)
+	assert.Equal(t, release.ID, latest.ID)
+
+	published := false
+	draft, err = svc.Update(ctx, repo.ID, draft.ID, UpdateReleaseRequest{Draft: &published})
+	require.NoError(t, err)
+	assert.NotNil(t, draft.PublishedAt)
+
+	// Assets are stored in the backend and counted on download
+	asset, err := svc.UploadAsset(ctx, repo.ID, release.ID, author.ID, UploadReleaseAssetRequest{Name: "dist/app.tar.gz", Label: "Linux"}, strings.NewReader("binary"))
+	require.NoError(t, err)
+	assert.Equal(t, "app.tar.gz", asset.Name)
+	assert.Equal(t, int64(6), asset.Size)
+	assert.NotEmpty(t, asset.ContentType)
+	_, err = svc.UploadAsset(ctx, repo.ID, release.ID, author.ID, UploadReleaseAssetRequest{Name: "app.tar.gz"}, strings.NewReader("again"))
+	assert.ErrorIs(t, err, ErrReleaseAssetExists)
+	_, err = svc.UploadAsset(ctx, repo.ID, release.ID, author.ID, UploadReleaseAssetRequest{Name: "huge.bin"}, bytes.NewReader(make([]byte, 1024*1024+1)))
+	assert.ErrorIs(t, err, ErrReleaseAssetTooLarge)
+
+	_, content, err := svc.OpenAsset(ctx, repo.ID, asset.ID)
+	require.NoError(t, err)
+	data, err := io.ReadAll(content)
+	require.NoError(t, err)
+	content.Close()
+	assert.Equal(t, "binary", string(data))
+	asset, err = svc.GetAsset(ctx, repo.ID, asset.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), asset.DownloadCount)
+	_, err = svc.GetAsset(ctx, uuid.New(), asset.ID)
+	assert.ErrorIs(t, err, ErrReleaseAssetNotFound)
+
+	// Deleting a release removes its assets but keeps the tag
+	require.NoError(t, svc.Delete(ctx, repo.ID, release.ID))
+	exists, err := backend.Exists(ctx, asset.StoragePath)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	_, err = svc.Get(ctx, repo.ID, release.ID)
+	assert.ErrorIs(t, err, ErrReleaseNotFound)
+	assert.NotEmpty(t, fixture.SHA("v1.1"))
+}
@@ -0,0 +1,390 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/logging"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRepositoryApprovalNotFound   = errors.New("repository approval request not found")
+	ErrRepositoryApprovalForbidden  = errors.New("only organization owners and admins can review repository approval requests")
+	ErrRepositoryApprovalNotPending = errors.New("repository approval request is no longer pending")
+	ErrRepositoryApprovalDuplicate  = errors.New("a matching repository approval request is already pending")
+)
+
+// Notification types sent about repository approval requests
+const (
+	NotificationRepositoryApprovalRequested = "repository_approval_requested"
+	NotificationRepositoryApprovalReviewed  = "repository_approval_reviewed"
+)
+
+// RepositoryApprovalService holds repository creations and deletions in
+// organizations whose repository policy requires approval until an owner
+// or admin approves or rejects them
+type RepositoryApprovalService interface {
+	// RequiresApproval reports whether actorID must ask before taking action
+	// on a repository of the owner. Organization owners and admins never do.
+	RequiresApproval(ctx context.Context, ownerID uuid.UUID, ownerType models.OwnerType, actorID uuid.UUID, action models.RepositoryApprovalAction) (bool, error)
+	RequestCreation(ctx context.Context, actorID uuid.UUID, req CreateRepositoryRequest, reason string) (*models.RepositoryApprovalRequest, error)
+	RequestDeletion(ctx context.Context, actorID uuid.UUID, repo *models.Repository, reason string) (*models.RepositoryApprovalRequest, error)
+
+	// List returns the requests of an organization, newest first. Owners
+	// and admins see every request, other members their own.
+	List(ctx context.Context, orgName string, actorID uuid.UUID, status models.RepositoryApprovalStatus) ([]*models.RepositoryApprovalRequest, error)
+	Get(ctx context.Context, orgName string, actorID, requestID uuid.UUID) (*models.RepositoryApprovalRequest, error)
+	// Approve carries out the requested creation or deletion
+	Approve(ctx context.Context, orgName string, actorID, requestID uuid.UUID, comment string) (*models.RepositoryApprovalRequest, error)
+	Reject(ctx context.Context, orgName string, actorID, requestID uuid.UUID, comment string) (*models.RepositoryApprovalRequest, error)
+}
+
+type repositoryApprovalService struct {
+	db            *gorm.DB
+	repoService   RepositoryService
+	activity      ActivityService
+	notifications NotificationService
+	logger        *logrus.Logger
+}
+
+// NewRepositoryApprovalService creates a new RepositoryApprovalService
+func NewRepositoryApprovalService(db *gorm.DB, repoService RepositoryService, activity ActivityService, notifications NotificationService, logger *logrus.Logger) RepositoryApprovalService {
+	return &repositoryApprovalService{
+		db:            db,
+		repoService:   repoService,
+		activity:      activity,
+		notifications: notifications,
+		logger:        logger,
+	}
+}
+
+// role returns the role of userID in the organization, empty for non-members
+func (s *repositoryApprovalService) role(ctx context.Context, orgID, userID uuid.UUID) (models.OrganizationRole, error) {
+	var member models.OrganizationMember
+	err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get organization membership: %w", err)
+	}
+	return member.Role, nil
+}
+
+func isOrgAdminRole(role models.OrganizationRole) bool {
+	return role == models.OrgRoleOwner || role == models.OrgRoleAdmin
+}
+
+func (s *repositoryApprovalService) RequiresApproval(ctx context.Context, ownerID uuid.UUID, ownerType models.OwnerType, actorID uuid.UUID, action models.RepositoryApprovalAction) (bool, error) {
+	if ownerType != models.OwnerTypeOrganization {
+		return false, nil
+	}
+	policy, err := loadRepositoryPolicy(ctx, s.db, ownerID)
+	if err != nil {
+		return false, err
+	}
+	if (action == models.RepositoryApprovalCreate && !policy.RequireCreationApproval) ||
+		(action == models.RepositoryApprovalDelete && !policy.RequireDeletionApproval) {
+		return false, nil
+	}
+	role, err := s.role(ctx, ownerID, actorID)
+	if err != nil {
+		return false, err
+	}
+	return !isOrgAdminRole(role), nil
+}
+
+func (s *repositoryApprovalService) RequestCreation(ctx context.Context, actorID uuid.UUID, req CreateRepositoryRequest, reason string) (*models.RepositoryApprovalRequest, error) {
+	if req.OwnerType != models.OwnerTypeOrganization || req.Name == "" {
+		return nil, fmt.Errorf("an organization and a repository name are required")
+	}
+	role, err := s.role(ctx, req.OwnerID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		return nil, fmt.Errorf("only organization members can request repositories")
+	}
+
+	var existing int64
+	if err := s.db.WithContext(ctx).Model(&models.Repository{}).
+		Where("owner_id = ? AND owner_type = ? AND name = ?", req.OwnerID, req.OwnerType, req.Name).
+		Count(&existing).Error; err != nil {
+		return nil, fmt.Errorf("failed to check existing repository: %w", err)
+	}
+	if existing > 0 {
+		return nil, fmt.Errorf("repository %s already exists", req.Name)
+	}
+	settings, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode repository settings: %w", err)
+	}
+
+	return s.request(ctx, &models.RepositoryApprovalRequest{
+		OrganizationID: req.OwnerID,
+		Action:         models.RepositoryApprovalCreate,
+		RepositoryName: req.Name,
+		Settings:       string(settings),
+		Reason:         reason,
+		RequesterID:    actorID,
+	})
+}
+
+func (s *repositoryApprovalService) RequestDeletion(ctx context.Context, actorID uuid.UUID, repo *models.Repository, reason string) (*models.RepositoryApprovalRequest, error) {
+	if repo.OwnerType != models.OwnerTypeOrganization {
+		return nil, fmt.Errorf("only organization repositories need approval to delete")
+	}
+	return s.request(ctx, &models.RepositoryApprovalRequest{
+		OrganizationID: repo.OwnerID,
+		Action:         models.RepositoryApprovalDelete,
+		RepositoryName: repo.Name,
+		RepositoryID:   &repo.ID,
+		Reason:         reason,
+		RequesterID:    actorID,
+	})
+}
+
+// request stores a pending request unless the same one is already pending,
+// then tells the organization's owners and admins about it
+func (s *repositoryApprovalService) request(ctx context.Context, req *models.RepositoryApprovalRequest) (*models.RepositoryApprovalRequest, error) {
+	req.ID = uuid.New()
+	req.Status = models.RepositoryApprovalPending
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var pending int64
+		if err := tx.Model(&models.RepositoryApprovalRequest{}).
+			Where("organization_id = ? AND action = ? AND repository_name = ? AND status = ?",
+				req.OrganizationID, req.Action, req.RepositoryName, models.RepositoryApprovalPending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrRepositoryApprovalDuplicate
+		}
+		return tx.Create(req).Error
+	})
+	if errors.Is(err, ErrRepositoryApprovalDuplicate) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create repository approval request: %w", err)
+	}
+
+	s.record(ctx, req, req.RequesterID, models.ActivityRepositoryApprovalRequested, "Repository approval requested")
+
+	var admins []uuid.UUID
+	if err := s.db.WithContext(ctx).Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND role IN ?", req.OrganizationID, []models.OrganizationRole{models.OrgRoleOwner, models.OrgRoleAdmin}).
+		Pluck("user_id", &admins).Error; err != nil {
+		s.logger.WithError(err).WithField("request_id", req.ID).Warn("Failed to find organization admins to notify")
+	}
+	for _, admin := range admins {
+		s.notify(admin, NotificationRepositoryApprovalRequested, req)
+	}
+	return req, nil
+}
+
+// record writes a decision to the organization activity and the audit log
+func (s *repositoryApprovalService) record(ctx context.Context, req *models.RepositoryApprovalRequest, actorID uuid.UUID, action models.ActivityAction, message string) {
+	metadata := map[string]interface{}{
+		"request_id":   req.ID.String(),
+		"action":       req.Action,
+		"repository":   req.RepositoryName,
+		"requester_id": req.RequesterID.String(),
+	}
+	if req.ReviewComment != "" {
+		metadata["comment"] = req.ReviewComment
+	}
+	if s.activity != nil {
+		if err := s.activity.LogActivity(ctx, req.OrganizationID, actorID, action, "repository", req.RepositoryID, metadata); err != nil {
+			s.logger.WithError(err).WithField("request_id", req.ID).Warn("Failed to log repository approval activity")
+		}
+	}
+	logging.Default().Audit(s.logger).WithFields(logrus.Fields{
+		"organization_id": req.OrganizationID,
+		"actor_id":        actorID,
+		"request_id":      req.ID,
+		"action":          req.Action,
+		"repository":      req.RepositoryName,
+		"status":          req.Status,
+	}).Info(message)
+}
+
+func (s *repositoryApprovalService) notify(userID uuid.UUID, notificationType string, req *models.RepositoryApprovalRequest) {
+	if s.notifications == nil {
+		return
+	}
+	s.notifications.Publish(userID, Notification{
+		ID:   uuid.New(),
+		Type: notificationType,
+		Payload: map[string]interface{}{
+			"request_id":      req.ID.String(),
+			"organization_id": req.OrganizationID.String(),
+			"action":          req.Action,
+			"repository":      req.RepositoryName,
+			"status":          req.Status,
+		},
+		Timestamp: time.Now(),
+	})
+}
+
+func (s *repositoryApprovalService) organization(ctx context.Context, orgName string) (*models.Organization, error) {
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("organization not found: %w", err)
+	}
+	return &org, nil
+}
+
+func (s *repositoryApprovalService) List(ctx context.Context, orgName string, actorID uuid.UUID, status models.RepositoryApprovalStatus) ([]*models.RepositoryApprovalRequest, error) {
+	org, err := s.organization(ctx, orgName)
+	if err != nil {
+		return nil, err
+	}
+	role, err := s.role(ctx, org.ID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if role == "" {
+		return nil, ErrRepositoryApprovalForbidden
+	}
+
+	query := s.db.WithContext(ctx).Preload("Requester").Preload("Reviewer").Where("organization_id = ?", org.ID)
+	if !isOrgAdminRole(role) {
+		query = query.Where("requester_id = ?", actorID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var requests []*models.RepositoryApprovalRequest
+	if err := query.Order("created_at DESC").Find(&requests).Error; err != nil {
+		return nil, fmt.Errorf("failed to list repository approval requests: %w", err)
+	}
+	return requests, nil
+}
+
+func (s *repositoryApprovalService) Get(ctx context.Context, orgName string, actorID, requestID uuid.UUID) (*models.RepositoryApprovalRequest, error) {
+	org, err := s.organization(ctx, orgName)
+	if err != nil {
+		return nil, err
+	}
+	req, err := s.load(ctx, org.ID, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if req.RequesterID != actorID {
+		role, err := s.role(ctx, org.ID, actorID)
+		if err != nil {
+			return nil, err
+		}
+		if !isOrgAdminRole(role) {
+			return nil, ErrRepositoryApprovalNotFound
+		}
+	}
+	return req, nil
+}
+
+func (s *repositoryApprovalService) load(ctx context.Context, orgID, requestID uuid.UUID) (*models.RepositoryApprovalRequest, error) {
+	var req models.RepositoryApprovalRequest
+	err := s.db.WithContext(ctx).Preload("Requester").Preload("Reviewer").
+		Where("id = ? AND organization_id = ?", requestID, orgID).First(&req).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRepositoryApprovalNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository approval request: %w", err)
+	}
+	return &req, nil
+}
+
+// review claims a pending request for actorID, an owner or admin, so that
+// concurrent reviews cannot both act on it
+func (s *repositoryApprovalService) review(ctx context.Context, orgName string, actorID, requestID uuid.UUID, status models.RepositoryApprovalStatus, comment string) (*models.RepositoryApprovalRequest, error) {
+	org, err := s.organization(ctx, orgName)
+	if err != nil {
+		return nil, err
+	}
+	role, err := s.role(ctx, org.ID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if !isOrgAdminRole(role) {
+		return nil, ErrRepositoryApprovalForbidden
+	}
+	req, err := s.load(ctx, org.ID, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	result := s.db.WithContext(ctx).Model(&models.RepositoryApprovalRequest{}).
+		Where("id = ? AND status = ?", req.ID, models.RepositoryApprovalPending).
+		Updates(map[string]interface{}{"status": status, "reviewer_id": actorID, "review_comment": comment, "reviewed_at": now})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to update repository approval request: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrRepositoryApprovalNotPending
+	}
+	req.Status, req.ReviewerID, req.ReviewComment, req.ReviewedAt = status, &actorID, comment, &now
+	return req, nil
+}
+
+func (s *repositoryApprovalService) Approve(ctx context.Context, orgName string, actorID, requestID uuid.UUID, comment string) (*models.RepositoryApprovalRequest, error) {
+	req, err := s.review(ctx, orgName, actorID, requestID, models.RepositoryApprovalApproved, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.carryOut(ctx, req); err != nil {
+		// Put the request back so it can be approved again once the cause is fixed
+		s.db.WithContext(ctx).Model(req).Updates(map[string]interface{}{
+			"status": models.RepositoryApprovalPending, "reviewer_id": nil, "review_comment": "", "reviewed_at": nil,
+		})
+		return nil, err
+	}
+
+	s.record(ctx, req, actorID, models.ActivityRepositoryApprovalApproved, "Repository approval request approved")
+	s.notify(req.RequesterID, NotificationRepositoryApprovalReviewed, req)
+	return req, nil
+}
+
+// carryOut creates or deletes the repository of an approved request
+func (s *repositoryApprovalService) carryOut(ctx context.Context, req *models.RepositoryApprovalRequest) error {
+	switch req.Action {
+	case models.RepositoryApprovalCreate:
+		var settings CreateRepositoryRequest
+		if err := json.Unmarshal([]byte(req.Settings), &settings); err != nil {
+			return fmt.Errorf("failed to decode repository settings: %w", err)
+		}
+		repo, err := s.repoService.Create(ctx, settings)
+		if err != nil {
+			return err
+		}
+		req.RepositoryID = &repo.ID
+		return s.db.WithContext(ctx).Model(req).Update("repository_id", repo.ID).Error
+	case models.RepositoryApprovalDelete:
+		if req.RepositoryID == nil {
+			return fmt.Errorf("the request names no repository")
+		}
+		return s.repoService.Delete(ctx, *req.RepositoryID)
+	}
+	return fmt.Errorf("unknown repository approval action %q", req.Action)
+}
+
+func (s *repositoryApprovalService) Reject(ctx context.Context, orgName string, actorID, requestID uuid.UUID, comment string) (*models.RepositoryApprovalRequest, error) {
+	req, err := s.review(ctx, orgName, actorID, requestID, models.RepositoryApprovalRejected, comment)
+	if err != nil {
+		return nil, err
+	}
+	s.record(ctx, req, actorID, models.ActivityRepositoryApprovalRejected, "Repository approval request rejected")
+	s.notify(req.RequesterID, NotificationRepositoryApprovalReviewed, req)
+	return req, nil
+}
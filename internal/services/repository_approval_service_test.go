@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryApprovalService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{}, &models.OrganizationSettings{},
+		&models.Repository{}, &models.RepositoryApprovalRequest{})
+
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	admin, member, outsider := uuid.New(), uuid.New(), uuid.New()
+	for id, role := range map[uuid.UUID]models.OrganizationRole{admin: models.OrgRoleAdmin, member: models.OrgRoleMember} {
+		require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: id, Role: role}).Error)
+	}
+
+	ctx := context.Background()
+	logger := logrus.New()
+	repoService := NewRepositoryService(db, git.NewGitService(logger), logger, t.TempDir())
+	notifications := NewNotificationService()
+	adminInbox, stop := notifications.Subscribe(admin)
+	defer stop()
+	memberInbox, stopMember := notifications.Subscribe(member)
+	defer stopMember()
+	svc := NewRepositoryApprovalService(db, repoService, nil, notifications, logger)
+	policies := NewRepositoryPolicyService(db, logger)
+
+	// Without the policy nobody needs approval
+	required, err := svc.RequiresApproval(ctx, org.ID, models.OwnerTypeOrganization, member, models.RepositoryApprovalCreate)
+	require.NoError(t, err)
+	assert.False(t, required)
+
+	enabled := true
+	_, err = policies.Update(ctx, "acme", admin, UpdateRepositoryPolicyRequest{RequireCreationApproval: &enabled, RequireDeletionApproval: &enabled})
+	require.NoError(t, err)
+	for actor, want := range map[uuid.UUID]bool{member: true, admin: false} {
+		required, err = svc.RequiresApproval(ctx, org.ID, models.OwnerTypeOrganization, actor, models.RepositoryApprovalCreate)
+		require.NoError(t, err)
+		assert.Equal(t, want, required)
+	}
+	required, err = svc.RequiresApproval(ctx, member, models.OwnerTypeUser, member, models.RepositoryApprovalDelete)
+	require.NoError(t, err)
+	assert.False(t, required, "personal repositories are not governed by organizations")
+
+	// A member asks for a repository and an admin approves it
+	create := CreateRepositoryRequest{OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "ledger", Visibility: models.VisibilityPrivate, AutoInit: true}
+	_, err = svc.RequestCreation(ctx, outsider, create, "")
+	assert.Error(t, err, "only members may ask")
+	request, err := svc.RequestCreation(ctx, member, create, "quarterly reports")
+	require.NoError(t, err)
+	assert.Equal(t, models.RepositoryApprovalPending, request.Status)
+	_, err = svc.RequestCreation(ctx, member, create, "again")
+	assert.ErrorIs(t, err, ErrRepositoryApprovalDuplicate)
+	notification := <-adminInbox
+	assert.Equal(t, NotificationRepositoryApprovalRequested, notification.Type)
+
+	_, err = svc.Approve(ctx, "acme", member, request.ID, "")
+	assert.ErrorIs(t, err, ErrRepositoryApprovalForbidden)
+	approved, err := svc.Approve(ctx, "acme", admin, request.ID, "ok")
+	require.NoError(t, err)
+	assert.Equal(t, models.RepositoryApprovalApproved, approved.Status)
+	require.NotNil(t, approved.RepositoryID)
+	repo, err := repoService.GetByID(ctx, *approved.RepositoryID)
+	require.NoError(t, err)
+	assert.Equal(t, "ledger", repo.Name)
+	assert.Equal(t, NotificationRepositoryApprovalReviewed, (<-memberInbox).Type)
+	_, err = svc.Reject(ctx, "acme", admin, request.ID, "")
+	assert.ErrorIs(t, err, ErrRepositoryApprovalNotPending)
+
+	// A deletion that is rejected leaves the repository in place
+	deletion, err := svc.RequestDeletion(ctx, member, repo, "no longer needed")
+	require.NoError(t, err)
+	rejected, err := svc.Reject(ctx, "acme", admin, deletion.ID, "auditors need it")
+	require.NoError(t, err)
+	assert.Equal(t, models.RepositoryApprovalRejected, rejected.Status)
+	_, err = repoService.GetByID(ctx, repo.ID)
+	require.NoError(t, err)
+
+	deletion, err = svc.RequestDeletion(ctx, member, repo, "retention period over")
+	require.NoError(t, err)
+	_, err = svc.Approve(ctx, "acme", admin, deletion.ID, "")
+	require.NoError(t, err)
+	_, err = repoService.GetByID(ctx, repo.ID)
+	assert.Error(t, err)
+
+	// Members see their own requests, admins all of them
+	other, err := svc.RequestCreation(ctx, member, CreateRepositoryRequest{OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "tmp", Visibility: models.VisibilityPrivate}, "")
+	require.NoError(t, err)
+	pending, err := svc.List(ctx, "acme", admin, models.RepositoryApprovalPending)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	assert.Equal(t, other.ID, pending[0].ID)
+	all, err := svc.List(ctx, "acme", member, "")
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+	_, err = svc.List(ctx, "acme", outsider, "")
+	assert.ErrorIs(t, err, ErrRepositoryApprovalForbidden)
+	_, err = svc.Get(ctx, "acme", outsider, other.ID)
+	assert.ErrorIs(t, err, ErrRepositoryApprovalNotFound)
+
+}
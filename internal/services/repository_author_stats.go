@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+)
+
+// defaultAuthorStatsDays is the window used without a start date
+const defaultAuthorStatsDays = 90
+
+// ErrAuthorStatsForbidden is returned to organization members who may not
+// view per-author statistics
+var ErrAuthorStatsForbidden = errors.New("only organization owners and admins can view author statistics")
+
+// AuthorCodeFrequency is the weekly activity of each commit author of one
+// or more repositories, most active authors first
+type AuthorCodeFrequency struct {
+	StartDate time.Time          `json:"start_date"`
+	EndDate   time.Time          `json:"end_date"`
+	Authors   []*AuthorFrequency `json:"authors"`
+}
+
+// AuthorFrequency totals the commits of one author, identified by email.
+// Login is set when the email belongs to a Hub account.
+type AuthorFrequency struct {
+	Email     string        `json:"email"`
+	Name      string        `json:"name"`
+	Login     string        `json:"login,omitempty"`
+	Additions int64         `json:"additions"`
+	Deletions int64         `json:"deletions"`
+	Commits   int64         `json:"commits"`
+	Weeks     []*AuthorWeek `json:"weeks"`
+}
+
+// AuthorWeek is an author's activity in the week starting on Week (a
+// Sunday, UTC); weeks without commits are left out
+type AuthorWeek struct {
+	Week      time.Time `json:"week"`
+	Additions int64     `json:"additions"`
+	Deletions int64     `json:"deletions"`
+	Commits   int64     `json:"commits"`
+}
+
+// authorWeeks is the cached weekly activity of one author over the whole
+// history of a repository
+type authorWeeks struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+	// Weeks holds [unix week start, additions, deletions, commits] tuples
+	Weeks [][4]int64 `json:"weeks"`
+}
+
+// computeAuthorFrequency buckets commits by lower-cased author email and
+// week from commits sorted by author date
+func computeAuthorFrequency(commits []statsCommit) []authorWeeks {
+	authors := []authorWeeks{}
+	index := map[string]int{}
+	for _, c := range commits {
+		email := strings.ToLower(c.AuthorEmail)
+		i, ok := index[email]
+		if !ok {
+			i = len(authors)
+			index[email] = i
+			authors = append(authors, authorWeeks{Email: email})
+		}
+		author := &authors[i]
+		// The most recent name wins
+		author.Name = c.AuthorName
+
+		week := statsWeek(c.AuthorDate).Unix()
+		if n := len(author.Weeks); n == 0 || author.Weeks[n-1][0] != week {
+			author.Weeks = append(author.Weeks, [4]int64{week})
+		}
+		bucket := &author.Weeks[len(author.Weeks)-1]
+		bucket[1] += int64(c.Additions)
+		bucket[2] += int64(c.Deletions)
+		bucket[3]++
+	}
+	return authors
+}
+
+func (s *repositoryStatsService) AuthorCodeFrequency(ctx context.Context, repoID uuid.UUID, since, until time.Time) (*AuthorCodeFrequency, bool, error) {
+	return s.authorCodeFrequency(ctx, []uuid.UUID{repoID}, since, until)
+}
+
+func (s *repositoryStatsService) OrganizationAuthorCodeFrequency(ctx context.Context, orgName string, actorID uuid.UUID, since, until time.Time) (*AuthorCodeFrequency, bool, error) {
+	db := s.db.WithContext(ctx)
+	var org models.Organization
+	if err := db.Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, false, fmt.Errorf("organization not found: %w", err)
+	}
+	var member models.OrganizationMember
+	err := db.Where("organization_id = ? AND user_id = ?", org.ID, actorID).First(&member).Error
+	if err != nil || (member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin) {
+		return nil, false, ErrAuthorStatsForbidden
+	}
+
+	var repoIDs []uuid.UUID
+	if err := db.Model(&models.Repository{}).Where("owner_id = ? AND owner_type = ?", org.ID, models.OwnerTypeOrganization).
+		Pluck("id", &repoIDs).Error; err != nil {
+		return nil, false, fmt.Errorf("failed to list repositories: %w", err)
+	}
+	return s.authorCodeFrequency(ctx, repoIDs, since, until)
+}
+
+// authorCodeFrequency merges the cached author stats of repoIDs. It is not
+// ready until every repository's stats are cached; the missing ones are
+// computed in the background meanwhile.
+func (s *repositoryStatsService) authorCodeFrequency(ctx context.Context, repoIDs []uuid.UUID, since, until time.Time) (*AuthorCodeFrequency, bool, error) {
+	if until.IsZero() {
+		until = s.now()
+	}
+	if since.IsZero() {
+		since = until.AddDate(0, 0, -defaultAuthorStatsDays)
+	}
+	first, last := statsWeek(since).Unix(), until.Unix()
+
+	ready := true
+	byEmail := map[string]*AuthorFrequency{}
+	weeks := map[string]map[int64]*AuthorWeek{}
+	for _, repoID := range repoIDs {
+		var authors []authorWeeks
+		cached, err := s.cached(ctx, repoID, models.StatsKindAuthorFrequency, &authors)
+		if err != nil {
+			return nil, false, err
+		}
+		if !cached {
+			// Keep going so that every missing repository is refreshed
+			ready = false
+			continue
+		}
+		for _, a := range authors {
+			for _, w := range a.Weeks {
+				if w[0] < first || w[0] > last {
+					continue
+				}
+				author, ok := byEmail[a.Email]
+				if !ok {
+					author = &AuthorFrequency{Email: a.Email, Name: a.Name, Weeks: []*AuthorWeek{}}
+					byEmail[a.Email] = author
+					weeks[a.Email] = map[int64]*AuthorWeek{}
+				}
+				week, ok := weeks[a.Email][w[0]]
+				if !ok {
+					week = &AuthorWeek{Week: time.Unix(w[0], 0).UTC()}
+					weeks[a.Email][w[0]] = week
+					author.Weeks = append(author.Weeks, week)
+				}
+				week.Additions += w[1]
+				week.Deletions += w[2]
+				week.Commits += w[3]
+				author.Additions += w[1]
+				author.Deletions += w[2]
+				author.Commits += w[3]
+			}
+		}
+	}
+	if !ready {
+		return nil, false, nil
+	}
+
+	report := &AuthorCodeFrequency{StartDate: since, EndDate: until, Authors: []*AuthorFrequency{}}
+	emails := make([]string, 0, len(byEmail))
+	for email, author := range byEmail {
+		sort.Slice(author.Weeks, func(i, j int) bool { return author.Weeks[i].Week.Before(author.Weeks[j].Week) })
+		report.Authors = append(report.Authors, author)
+		emails = append(emails, email)
+	}
+	sort.Slice(report.Authors, func(i, j int) bool {
+		a, b := report.Authors[i], report.Authors[j]
+		if a.Commits != b.Commits {
+			return a.Commits > b.Commits
+		}
+		return a.Email < b.Email
+	})
+
+	if len(emails) > 0 {
+		var users []models.User
+		if err := s.db.WithContext(ctx).Select("username, email").Where("LOWER(email) IN ?", emails).Find(&users).Error; err != nil {
+			return nil, false, fmt.Errorf("failed to resolve authors: %w", err)
+		}
+		for _, u := range users {
+			if author, ok := byEmail[strings.ToLower(u.Email)]; ok {
+				author.Login = u.Username
+			}
+		}
+	}
+	return report, true, nil
+}
+
+// CSV renders one row per author and week
+func (r *AuthorCodeFrequency) CSV() ([]byte, error) {
+	var output strings.Builder
+	writer := csv.NewWriter(&output)
+
+	writer.Write([]string{"Login", "Email", "Name", "Week", "Additions", "Deletions", "Commits"})
+	for _, a := range r.Authors {
+		for _, w := range a.Weeks {
+			writer.Write([]string{
+				a.Login,
+				a.Email,
+				a.Name,
+				w.Week.Format("2006-01-02"),
+				strconv.FormatInt(w.Additions, 10),
+				strconv.FormatInt(w.Deletions, 10),
+				strconv.FormatInt(w.Commits, 10),
+			})
+		}
+	}
+
+	writer.Flush()
+	return []byte(output.String()), writer.Error()
+}
@@ -0,0 +1,592 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRepositoryBaseline   = errors.New("invalid repository baseline")
+	ErrRepositoryBaselineForbidden = errors.New("only organization owners and admins can manage the repository baseline")
+	ErrRepositoryBaselineNotOwned  = errors.New("repository is not owned by an organization")
+)
+
+// Drift categories, which remediation is selected by
+const (
+	DriftCategorySettings    = "settings"
+	DriftCategoryPolicies    = "policies"
+	DriftCategoryProtections = "protections"
+	DriftCategoryWebhooks    = "webhooks"
+	DriftCategoryLabels      = "labels"
+)
+
+// DriftCategories lists the drift categories in report order
+var DriftCategories = []string{DriftCategorySettings, DriftCategoryPolicies, DriftCategoryProtections, DriftCategoryWebhooks, DriftCategoryLabels}
+
+var baselineLabelColorPattern = regexp.MustCompile(`^#?[0-9A-Fa-f]{6}$`)
+
+// RepositoryDrift is a setting of a repository that differs from the
+// organization baseline. Actual is nil when the repository lacks the
+// protection rule, webhook or label altogether.
+type RepositoryDrift struct {
+	Category    string      `json:"category"`
+	Setting     string      `json:"setting"`
+	Expected    interface{} `json:"expected"`
+	Actual      interface{} `json:"actual"`
+	Remediation string      `json:"remediation"`
+	// Fixable reports whether remediation can apply the fix
+	Fixable bool `json:"fixable"`
+
+	// fix applies the remediation within a transaction
+	fix func(tx *gorm.DB) error
+}
+
+// RepositoryDriftReport compares a repository with its organization baseline
+type RepositoryDriftReport struct {
+	Repository string            `json:"repository"`
+	BaselineID *uuid.UUID        `json:"baseline_id,omitempty"`
+	Compliant  bool              `json:"compliant"`
+	Drift      []RepositoryDrift `json:"drift"`
+	// Applied lists the drift remediation fixed, most recent request only
+	Applied   []RepositoryDrift `json:"applied,omitempty"`
+	CheckedAt time.Time         `json:"checked_at"`
+}
+
+// RepositoryBaselineService manages the repository settings baseline of
+// organizations and reports how repositories drift from it: settings,
+// policies, branch protections, webhooks and labels. Repositories may have
+// more protections, webhooks and labels than the baseline asks for.
+type RepositoryBaselineService interface {
+	// Get returns the baseline of an organization, empty when none is set
+	Get(ctx context.Context, orgName string) (*models.RepositoryBaseline, error)
+	Set(ctx context.Context, orgName string, actorID uuid.UUID, baseline *models.RepositoryBaseline) (*models.RepositoryBaseline, error)
+	// Drift compares repo with the baseline of its organization
+	Drift(ctx context.Context, repo *models.Repository) (*RepositoryDriftReport, error)
+	// Remediate fixes the drift of repo in categories, every category when
+	// empty, and reports the drift that remains
+	Remediate(ctx context.Context, repo *models.Repository, actorID uuid.UUID, categories []string) (*RepositoryDriftReport, error)
+}
+
+type repositoryBaselineService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+// NewRepositoryBaselineService creates a new RepositoryBaselineService
+func NewRepositoryBaselineService(db *gorm.DB, logger *logrus.Logger) RepositoryBaselineService {
+	return &repositoryBaselineService{db: db, logger: logger}
+}
+
+// loadBaseline returns the baseline of an organization, empty when none is set
+func (s *repositoryBaselineService) loadBaseline(ctx context.Context, orgID uuid.UUID) (*models.RepositoryBaseline, error) {
+	var baseline models.RepositoryBaseline
+	err := s.db.WithContext(ctx).Where("organization_id = ?", orgID).First(&baseline).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.RepositoryBaseline{OrganizationID: orgID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository baseline: %w", err)
+	}
+	return &baseline, nil
+}
+
+func (s *repositoryBaselineService) Get(ctx context.Context, orgName string) (*models.RepositoryBaseline, error) {
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("organization not found: %w", err)
+	}
+	return s.loadBaseline(ctx, org.ID)
+}
+
+// validateBaseline checks the baseline and normalizes label colors
+func validateBaseline(baseline *models.RepositoryBaseline) error {
+	if baseline.Settings.DefaultBranch != nil && strings.TrimSpace(*baseline.Settings.DefaultBranch) == "" {
+		return fmt.Errorf("%w: default_branch cannot be empty", ErrInvalidRepositoryBaseline)
+	}
+	if pattern := baseline.Policies.PullRequestTitlePattern; pattern != nil && *pattern != "" {
+		if _, err := regexp.Compile(*pattern); err != nil {
+			return fmt.Errorf("%w: invalid pull_request_title_pattern: %v", ErrInvalidRepositoryBaseline, err)
+		}
+	}
+	if days := baseline.Policies.StaleBranchDays; days != nil && (*days < 1 || *days > 3650) {
+		return fmt.Errorf("%w: stale_branch_days must be between 1 and 3650", ErrInvalidRepositoryBaseline)
+	}
+
+	patterns := map[string]bool{}
+	for _, protection := range baseline.Protections {
+		if protection.Pattern == "" || patterns[protection.Pattern] {
+			return fmt.Errorf("%w: protection patterns must be set and unique", ErrInvalidRepositoryBaseline)
+		}
+		if protection.RequiredApprovingReviewCount < 0 || protection.RequiredApprovingReviewCount > 10 {
+			return fmt.Errorf("%w: required_approving_review_count must be between 0 and 10", ErrInvalidRepositoryBaseline)
+		}
+		patterns[protection.Pattern] = true
+	}
+	urls := map[string]bool{}
+	for _, webhook := range baseline.Webhooks {
+		u, err := url.Parse(webhook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%w: invalid webhook url %q", ErrInvalidRepositoryBaseline, webhook.URL)
+		}
+		if urls[webhook.URL] || len(webhook.Events) == 0 {
+			return fmt.Errorf("%w: webhook urls must be unique and have events", ErrInvalidRepositoryBaseline)
+		}
+		urls[webhook.URL] = true
+	}
+	names := map[string]bool{}
+	for i, label := range baseline.Labels {
+		if label.Name == "" || names[strings.ToLower(label.Name)] {
+			return fmt.Errorf("%w: label names must be set and unique", ErrInvalidRepositoryBaseline)
+		}
+		if !baselineLabelColorPattern.MatchString(label.Color) {
+			return fmt.Errorf("%w: label %q needs a hex color", ErrInvalidRepositoryBaseline, label.Name)
+		}
+		baseline.Labels[i].Color = "#" + strings.ToLower(strings.TrimPrefix(label.Color, "#"))
+		names[strings.ToLower(label.Name)] = true
+	}
+	return nil
+}
+
+func (s *repositoryBaselineService) Set(ctx context.Context, orgName string, actorID uuid.UUID, baseline *models.RepositoryBaseline) (*models.RepositoryBaseline, error) {
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("organization not found: %w", err)
+	}
+	var member models.OrganizationMember
+	err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", org.ID, actorID).First(&member).Error
+	if err != nil || (member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin) {
+		return nil, ErrRepositoryBaselineForbidden
+	}
+	if err := validateBaseline(baseline); err != nil {
+		return nil, err
+	}
+
+	current, err := s.loadBaseline(ctx, org.ID)
+	if err != nil {
+		return nil, err
+	}
+	if current.ID == uuid.Nil {
+		current.ID = uuid.New()
+	}
+	current.Settings = baseline.Settings
+	current.Policies = baseline.Policies
+	current.Protections = baseline.Protections
+	current.Webhooks = baseline.Webhooks
+	current.Labels = baseline.Labels
+	current.UpdatedByID = &actorID
+	if err := s.db.WithContext(ctx).Save(current).Error; err != nil {
+		return nil, fmt.Errorf("failed to save repository baseline: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"organization": orgName,
+		"actor_id":     actorID,
+	}).Info("Updated organization repository baseline")
+	return current, nil
+}
+
+func (s *repositoryBaselineService) Drift(ctx context.Context, repo *models.Repository) (*RepositoryDriftReport, error) {
+	if repo.OwnerType != models.OwnerTypeOrganization {
+		return nil, ErrRepositoryBaselineNotOwned
+	}
+	baseline, err := s.loadBaseline(ctx, repo.OwnerID)
+	if err != nil {
+		return nil, err
+	}
+	// Compare against the stored repository, which may be newer than repo
+	var current models.Repository
+	if err := s.db.WithContext(ctx).First(&current, "id = ?", repo.ID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get repository: %w", err)
+	}
+
+	report := &RepositoryDriftReport{Repository: repo.Name, Drift: []RepositoryDrift{}, CheckedAt: time.Now()}
+	if baseline.ID != uuid.Nil {
+		report.BaselineID = &baseline.ID
+	}
+	report.Drift = append(report.Drift, settingsDrift(&current, baseline)...)
+	for _, check := range []func(context.Context, *models.Repository, *models.RepositoryBaseline) ([]RepositoryDrift, error){
+		s.protectionDrift, s.webhookDrift, s.labelDrift,
+	} {
+		drift, err := check(ctx, &current, baseline)
+		if err != nil {
+			return nil, err
+		}
+		report.Drift = append(report.Drift, drift...)
+	}
+	report.Compliant = len(report.Drift) == 0
+	return report, nil
+}
+
+// repositoryColumnDrift reports a repository column differing from the
+// baseline value
+func repositoryColumnDrift(repo *models.Repository, category, column string, expected, actual interface{}) RepositoryDrift {
+	return RepositoryDrift{
+		Category:    category,
+		Setting:     column,
+		Expected:    expected,
+		Actual:      actual,
+		Remediation: fmt.Sprintf("Set %s to %v", column, expected),
+		Fixable:     true,
+		fix: func(tx *gorm.DB) error {
+			return updateVersioned(tx.Model(&models.Repository{}).Where("id = ?", repo.ID), map[string]interface{}{column: expected}, nil)
+		},
+	}
+}
+
+func settingsDrift(repo *models.Repository, baseline *models.RepositoryBaseline) []RepositoryDrift {
+	var drift []RepositoryDrift
+	settings, policies := baseline.Settings, baseline.Policies
+
+	if settings.DefaultBranch != nil && *settings.DefaultBranch != repo.DefaultBranch {
+		// The branch may not exist, so this is left to the repository admins
+		drift = append(drift, RepositoryDrift{
+			Category:    DriftCategorySettings,
+			Setting:     "default_branch",
+			Expected:    *settings.DefaultBranch,
+			Actual:      repo.DefaultBranch,
+			Remediation: fmt.Sprintf("Rename the default branch to %s", *settings.DefaultBranch),
+		})
+	}
+	for _, setting := range []struct {
+		column   string
+		expected *bool
+		actual   bool
+	}{
+		{"has_wiki", settings.HasWiki, repo.HasWiki},
+		{"has_downloads", settings.HasDownloads, repo.HasDownloads},
+		{"auto_close_issues", settings.AutoCloseIssues, repo.AutoCloseIssues},
+		{"allow_merge_commit", settings.AllowMergeCommit, repo.AllowMergeCommit},
+		{"allow_squash_merge", settings.AllowSquashMerge, repo.AllowSquashMerge},
+		{"allow_rebase_merge", settings.AllowRebaseMerge, repo.AllowRebaseMerge},
+		{"delete_branch_on_merge", settings.DeleteBranchOnMerge, repo.DeleteBranchOnMerge},
+	} {
+		if setting.expected != nil && *setting.expected != setting.actual {
+			drift = append(drift, repositoryColumnDrift(repo, DriftCategorySettings, setting.column, *setting.expected, setting.actual))
+		}
+	}
+
+	if policies.PullRequestTitlePattern != nil && *policies.PullRequestTitlePattern != repo.PullRequestTitlePattern {
+		drift = append(drift, repositoryColumnDrift(repo, DriftCategoryPolicies, "pull_request_title_pattern", *policies.PullRequestTitlePattern, repo.PullRequestTitlePattern))
+	}
+	if policies.AutoDeleteMergedBranches != nil && *policies.AutoDeleteMergedBranches != repo.AutoDeleteMergedBranches {
+		drift = append(drift, repositoryColumnDrift(repo, DriftCategoryPolicies, "auto_delete_merged_branches", *policies.AutoDeleteMergedBranches, repo.AutoDeleteMergedBranches))
+	}
+	if policies.StaleBranchDays != nil && *policies.StaleBranchDays != repo.StaleBranchDays {
+		drift = append(drift, repositoryColumnDrift(repo, DriftCategoryPolicies, "stale_branch_days", *policies.StaleBranchDays, repo.StaleBranchDays))
+	}
+	return drift
+}
+
+// missingStrings returns the values of want that have does not contain
+func missingStrings(want, have []string) []string {
+	present := make(map[string]bool, len(have))
+	for _, value := range have {
+		present[value] = true
+	}
+	var missing []string
+	for _, value := range want {
+		if !present[value] {
+			missing = append(missing, value)
+		}
+	}
+	return missing
+}
+
+func (s *repositoryBaselineService) protectionDrift(ctx context.Context, repo *models.Repository, baseline *models.RepositoryBaseline) ([]RepositoryDrift, error) {
+	var rules []models.BranchProtectionRule
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repo.ID).Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to get branch protection rules: %w", err)
+	}
+	byPattern := make(map[string]*models.BranchProtectionRule, len(rules))
+	for i := range rules {
+		byPattern[rules[i].Pattern] = &rules[i]
+	}
+
+	var drift []RepositoryDrift
+	for _, protection := range baseline.Protections {
+		protection := protection
+		rule := byPattern[protection.Pattern]
+		if rule == nil {
+			drift = append(drift, RepositoryDrift{
+				Category:    DriftCategoryProtections,
+				Setting:     protection.Pattern,
+				Expected:    protection,
+				Remediation: fmt.Sprintf("Protect branches matching %s", protection.Pattern),
+				Fixable:     true,
+				fix: func(tx *gorm.DB) error {
+					rule := &models.BranchProtectionRule{ID: uuid.New(), RepositoryID: repo.ID, Pattern: protection.Pattern}
+					applyBaselineProtection(rule, protection)
+					return tx.Create(rule).Error
+				},
+			})
+			continue
+		}
+
+		actual := protectionOf(rule)
+		weaker := actual.RequiredApprovingReviewCount < protection.RequiredApprovingReviewCount ||
+			len(missingStrings(protection.RequiredStatusChecks, actual.RequiredStatusChecks)) > 0 ||
+			(protection.EnforceAdmins && !actual.EnforceAdmins) ||
+			(protection.RequireConversationResolution && !actual.RequireConversationResolution) ||
+			(protection.RequireSignedCommits && !actual.RequireSignedCommits)
+		if !weaker {
+			continue
+		}
+		drift = append(drift, RepositoryDrift{
+			Category:    DriftCategoryProtections,
+			Setting:     protection.Pattern,
+			Expected:    protection,
+			Actual:      actual,
+			Remediation: fmt.Sprintf("Raise the protection of %s to the baseline", protection.Pattern),
+			Fixable:     true,
+			fix: func(tx *gorm.DB) error {
+				applyBaselineProtection(rule, protection)
+				return tx.Model(rule).Updates(map[string]interface{}{
+					"required_status_checks":          rule.RequiredStatusChecks,
+					"enforce_admins":                  rule.EnforceAdmins,
+					"required_pull_request_reviews":   rule.RequiredPullRequestReviews,
+					"require_conversation_resolution": rule.RequireConversationResolution,
+					"require_signed_commits":          rule.RequireSignedCommits,
+				}).Error
+			},
+		})
+	}
+	return drift, nil
+}
+
+// protectionOf returns the parts of a protection rule the baseline checks
+func protectionOf(rule *models.BranchProtectionRule) models.BaselineProtection {
+	protection := models.BaselineProtection{
+		Pattern:                       rule.Pattern,
+		EnforceAdmins:                 rule.EnforceAdmins,
+		RequireConversationResolution: rule.RequireConversationResolution,
+		RequireSignedCommits:          rule.RequireSignedCommits,
+	}
+	var reviews RequiredPullRequestReviews
+	if rule.RequiredPullRequestReviews != "" && json.Unmarshal([]byte(rule.RequiredPullRequestReviews), &reviews) == nil {
+		protection.RequiredApprovingReviewCount = reviews.RequiredApprovingReviewCount
+	}
+	var checks RequiredStatusChecks
+	if rule.RequiredStatusChecks != "" && json.Unmarshal([]byte(rule.RequiredStatusChecks), &checks) == nil {
+		protection.RequiredStatusChecks = checks.Contexts
+	}
+	return protection
+}
+
+// applyBaselineProtection raises rule to protection, keeping whatever it
+// requires beyond the baseline
+func applyBaselineProtection(rule *models.BranchProtectionRule, protection models.BaselineProtection) {
+	rule.EnforceAdmins = rule.EnforceAdmins || protection.EnforceAdmins
+	rule.RequireConversationResolution = rule.RequireConversationResolution || protection.RequireConversationResolution
+	rule.RequireSignedCommits = rule.RequireSignedCommits || protection.RequireSignedCommits
+
+	var reviews RequiredPullRequestReviews
+	if rule.RequiredPullRequestReviews != "" {
+		json.Unmarshal([]byte(rule.RequiredPullRequestReviews), &reviews)
+	}
+	if reviews.RequiredApprovingReviewCount < protection.RequiredApprovingReviewCount {
+		reviews.RequiredApprovingReviewCount = protection.RequiredApprovingReviewCount
+		data, _ := json.Marshal(reviews)
+		rule.RequiredPullRequestReviews = string(data)
+	}
+
+	var checks RequiredStatusChecks
+	if rule.RequiredStatusChecks != "" {
+		json.Unmarshal([]byte(rule.RequiredStatusChecks), &checks)
+	}
+	if missing := missingStrings(protection.RequiredStatusChecks, checks.Contexts); len(missing) > 0 {
+		checks.Contexts = append(checks.Contexts, missing...)
+		data, _ := json.Marshal(checks)
+		rule.RequiredStatusChecks = string(data)
+	}
+}
+
+func (s *repositoryBaselineService) webhookDrift(ctx context.Context, repo *models.Repository, baseline *models.RepositoryBaseline) ([]RepositoryDrift, error) {
+	var hooks []models.Webhook
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repo.ID).Find(&hooks).Error; err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	byURL := make(map[string]*models.Webhook, len(hooks))
+	for i := range hooks {
+		byURL[hooks[i].URL] = &hooks[i]
+	}
+
+	var drift []RepositoryDrift
+	for _, want := range baseline.Webhooks {
+		want := want
+		contentType := want.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		hook := byURL[want.URL]
+		if hook == nil {
+			drift = append(drift, RepositoryDrift{
+				Category:    DriftCategoryWebhooks,
+				Setting:     want.URL,
+				Expected:    want,
+				Remediation: fmt.Sprintf("Add a webhook delivering %s to %s", strings.Join(want.Events, ", "), want.URL),
+				Fixable:     true,
+				fix: func(tx *gorm.DB) error {
+					hook := &models.Webhook{
+						ID:             uuid.New(),
+						RepositoryID:   &repo.ID,
+						Name:           "web",
+						URL:            want.URL,
+						ContentType:    contentType,
+						Active:         true,
+						PayloadVersion: WebhookPayloadVersion1,
+					}
+					hook.SetEventsSlice(want.Events)
+					return tx.Create(hook).Error
+				},
+			})
+			continue
+		}
+
+		events := hook.GetEventsSlice()
+		missing := missingStrings(want.Events, events)
+		if len(missing) == 0 && hook.Active && hook.ContentType == contentType {
+			continue
+		}
+		actual := models.BaselineWebhook{URL: hook.URL, Events: events, ContentType: hook.ContentType}
+		remediation := fmt.Sprintf("Update the webhook to %s", want.URL)
+		if !hook.Active {
+			remediation += " and reactivate it"
+		}
+		drift = append(drift, RepositoryDrift{
+			Category:    DriftCategoryWebhooks,
+			Setting:     want.URL,
+			Expected:    want,
+			Actual:      actual,
+			Remediation: remediation,
+			Fixable:     true,
+			fix: func(tx *gorm.DB) error {
+				hook.SetEventsSlice(append(events, missing...))
+				return tx.Model(hook).Updates(map[string]interface{}{
+					"events":               hook.Events,
+					"content_type":         contentType,
+					"active":               true,
+					"consecutive_failures": 0,
+					"disabled_at":          nil,
+					"disabled_reason":      "",
+				}).Error
+			},
+		})
+	}
+	return drift, nil
+}
+
+func (s *repositoryBaselineService) labelDrift(ctx context.Context, repo *models.Repository, baseline *models.RepositoryBaseline) ([]RepositoryDrift, error) {
+	var labels []models.Label
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repo.ID).Find(&labels).Error; err != nil {
+		return nil, fmt.Errorf("failed to get labels: %w", err)
+	}
+	byName := make(map[string]*models.Label, len(labels))
+	for i := range labels {
+		byName[strings.ToLower(labels[i].Name)] = &labels[i]
+	}
+
+	var drift []RepositoryDrift
+	for _, want := range baseline.Labels {
+		want := want
+		label := byName[strings.ToLower(want.Name)]
+		if label == nil {
+			drift = append(drift, RepositoryDrift{
+				Category:    DriftCategoryLabels,
+				Setting:     want.Name,
+				Expected:    want,
+				Remediation: fmt.Sprintf("Create the label %s", want.Name),
+				Fixable:     true,
+				fix: func(tx *gorm.DB) error {
+					return tx.Create(&models.Label{ID: uuid.New(), RepositoryID: repo.ID, Name: want.Name, Color: want.Color, Description: want.Description}).Error
+				},
+			})
+			continue
+		}
+		if strings.EqualFold(label.Color, want.Color) && (want.Description == "" || label.Description == want.Description) {
+			continue
+		}
+		drift = append(drift, RepositoryDrift{
+			Category:    DriftCategoryLabels,
+			Setting:     want.Name,
+			Expected:    want,
+			Actual:      models.BaselineLabel{Name: label.Name, Color: label.Color, Description: label.Description},
+			Remediation: fmt.Sprintf("Update the color and description of the label %s", label.Name),
+			Fixable:     true,
+			fix: func(tx *gorm.DB) error {
+				updates := map[string]interface{}{"color": want.Color}
+				if want.Description != "" {
+					updates["description"] = want.Description
+				}
+				return tx.Model(label).Updates(updates).Error
+			},
+		})
+	}
+	return drift, nil
+}
+
+func (s *repositoryBaselineService) Remediate(ctx context.Context, repo *models.Repository, actorID uuid.UUID, categories []string) (*RepositoryDriftReport, error) {
+	selected := map[string]bool{}
+	for _, category := range categories {
+		known := false
+		for _, c := range DriftCategories {
+			known = known || c == category
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: unknown drift category %q", ErrInvalidRepositoryBaseline, category)
+		}
+		selected[category] = true
+	}
+
+	report, err := s.Drift(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	var applied []RepositoryDrift
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, drift := range report.Drift {
+			if !drift.Fixable || (len(selected) > 0 && !selected[drift.Category]) {
+				continue
+			}
+			if err := drift.fix(tx); err != nil {
+				return fmt.Errorf("failed to fix %s %s: %w", drift.Category, drift.Setting, err)
+			}
+			applied = append(applied, drift)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(applied) > 0 {
+		fixed := make([]string, 0, len(applied))
+		for _, drift := range applied {
+			fixed = append(fixed, drift.Category+":"+drift.Setting)
+		}
+		sort.Strings(fixed)
+		s.logger.WithFields(logrus.Fields{
+			"repository_id": repo.ID,
+			"actor_id":      actorID,
+			"fixed":         fixed,
+		}).Info("Remediated repository baseline drift")
+	}
+
+	report, err = s.Drift(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	report.Applied = applied
+	return report, nil
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryBaselineService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Organization{}, &models.OrganizationMember{}, &models.Repository{},
+		&models.BranchProtectionRule{}, &models.Webhook{}, &models.Label{}, &models.RepositoryBaseline{})
+	ctx := context.Background()
+	svc := NewRepositoryBaselineService(db, logrus.New())
+
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	owner, member := uuid.New(), uuid.New()
+	require.NoError(t, db.Create([]*models.OrganizationMember{
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: owner, Role: models.OrgRoleOwner},
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: member, Role: models.OrgRoleMember},
+	}).Error)
+	repo := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "api",
+		DefaultBranch: "master", Visibility: models.VisibilityPrivate, HasWiki: true, AllowMergeCommit: true}
+	require.NoError(t, db.Create(repo).Error)
+	require.NoError(t, db.Create(&models.BranchProtectionRule{ID: uuid.New(), RepositoryID: repo.ID, Pattern: "main",
+		RequiredPullRequestReviews: `{"required_approving_review_count":1,"dismiss_stale_reviews":true}`}).Error)
+	require.NoError(t, db.Create(&models.Label{ID: uuid.New(), RepositoryID: repo.ID, Name: "Bug", Color: "#000000"}).Error)
+
+	// Without a baseline nothing drifts
+	report, err := svc.Drift(ctx, repo)
+	require.NoError(t, err)
+	assert.True(t, report.Compliant)
+	assert.Nil(t, report.BaselineID)
+
+	main, no, yes, staleDays := "main", false, true, 30
+	baseline := &models.RepositoryBaseline{
+		Settings: models.BaselineSettings{DefaultBranch: &main, HasWiki: &no, AllowMergeCommit: &yes},
+		Policies: models.BaselinePolicies{StaleBranchDays: &staleDays},
+		Protections: []models.BaselineProtection{
+			{Pattern: "main", RequiredApprovingReviewCount: 2, RequiredStatusChecks: []string{"ci"}},
+			{Pattern: "release/*", RequireSignedCommits: true},
+		},
+		Webhooks: []models.BaselineWebhook{{URL: "https://audit.example.com/hook", Events: []string{"push"}}},
+		Labels:   []models.BaselineLabel{{Name: "bug", Color: "D73A4A"}, {Name: "security", Color: "#b60205", Description: "Security issue"}},
+	}
+	_, err = svc.Set(ctx, "acme", member, baseline)
+	assert.ErrorIs(t, err, ErrRepositoryBaselineForbidden)
+	_, err = svc.Set(ctx, "acme", owner, &models.RepositoryBaseline{Labels: []models.BaselineLabel{{Name: "bug", Color: "red"}}})
+	assert.ErrorIs(t, err, ErrInvalidRepositoryBaseline)
+	saved, err := svc.Set(ctx, "acme", owner, baseline)
+	require.NoError(t, err)
+	assert.Equal(t, "#d73a4a", saved.Labels[0].Color)
+
+	report, err = svc.Drift(ctx, repo)
+	require.NoError(t, err)
+	assert.False(t, report.Compliant)
+	require.NotNil(t, report.BaselineID)
+	drifted := map[string]RepositoryDrift{}
+	for _, drift := range report.Drift {
+		drifted[drift.Category+":"+drift.Setting] = drift
+	}
+	assert.Len(t, drifted, 8)
+	assert.False(t, drifted["settings:default_branch"].Fixable, "renaming the default branch is left to admins")
+	assert.Equal(t, false, drifted["settings:has_wiki"].Expected)
+	assert.Contains(t, drifted, "policies:stale_branch_days")
+	assert.NotNil(t, drifted["protections:main"].Actual)
+	assert.Nil(t, drifted["protections:release/*"].Actual)
+	assert.Contains(t, drifted, "webhooks:https://audit.example.com/hook")
+	assert.Contains(t, drifted, "labels:bug", "label names match case-insensitively")
+	assert.Contains(t, drifted, "labels:security")
+
+	_, err = svc.Remediate(ctx, repo, owner, []string{"branding"})
+	assert.ErrorIs(t, err, ErrInvalidRepositoryBaseline)
+
+	// Fixing selected categories leaves the others drifting
+	report, err = svc.Remediate(ctx, repo, owner, []string{DriftCategorySettings, DriftCategoryProtections})
+	require.NoError(t, err)
+	assert.Len(t, report.Applied, 3)
+	categories := map[string]int{}
+	for _, drift := range report.Drift {
+		categories[drift.Category]++
+	}
+	assert.Equal(t, map[string]int{DriftCategorySettings: 1, DriftCategoryPolicies: 1, DriftCategoryWebhooks: 1, DriftCategoryLabels: 2}, categories)
+
+	var rule models.BranchProtectionRule
+	require.NoError(t, db.Where("repository_id = ? AND pattern = ?", repo.ID, "main").First(&rule).Error)
+	assert.JSONEq(t, `{"required_approving_review_count":2,"dismiss_stale_reviews":true,"require_code_owner_reviews":false,"restrict_pushes_to_code_owners":false}`,
+		rule.RequiredPullRequestReviews, "stricter settings of the rule are kept")
+	var updated models.Repository
+	require.NoError(t, db.First(&updated, "id = ?", repo.ID).Error)
+	assert.False(t, updated.HasWiki)
+	assert.Equal(t, int64(2), updated.Version, "settings fixes bump the repository version")
+
+	report, err = svc.Remediate(ctx, repo, owner, nil)
+	require.NoError(t, err)
+	assert.Len(t, report.Applied, 4)
+	require.Len(t, report.Drift, 1)
+	assert.Equal(t, "default_branch", report.Drift[0].Setting)
+
+	var hook models.Webhook
+	require.NoError(t, db.Where("repository_id = ?", repo.ID).First(&hook).Error)
+	assert.Equal(t, []string{"push"}, hook.GetEventsSlice())
+	var label models.Label
+	require.NoError(t, db.Where("repository_id = ? AND name = ?", repo.ID, "Bug").First(&label).Error)
+	assert.Equal(t, "#d73a4a", label.Color)
+
+	// Repositories of users have no baseline
+	_, err = svc.Drift(ctx, &models.Repository{ID: uuid.New(), OwnerType: models.OwnerTypeUser})
+	assert.ErrorIs(t, err, ErrRepositoryBaselineNotOwned)
+}
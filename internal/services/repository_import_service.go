@@ -0,0 +1,517 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Archive formats accepted for repository imports
+const (
+	ImportFormatTar   = "tar"
+	ImportFormatTarGz = "tar.gz"
+	ImportFormatZip   = "zip"
+)
+
+const (
+	// importProgressEvery is how many extracted files pass between progress updates
+	importProgressEvery = 100
+	// importStaleAfter is how long an unfinished import may go without
+	// progress before it is treated as interrupted by a restart
+	importStaleAfter = 2 * time.Hour
+)
+
+var (
+	ErrRepositoryImportNotFound = errors.New("repository import not found")
+	ErrInvalidImportArchive     = errors.New("invalid import archive")
+	ErrImportArchiveTooLarge    = errors.New("import archive too large")
+	ErrImportRepositoryExists   = errors.New("repository already exists")
+)
+
+// RepositoryImportService creates repositories from uploaded tar or zip
+// archives of existing projects
+type RepositoryImportService interface {
+	// Start stores the archive and imports it. Archives up to the inline
+	// size are imported before Start returns; larger ones in the background.
+	Start(ctx context.Context, userID uuid.UUID, req StartRepositoryImportRequest, archive io.Reader) (*models.RepositoryArchiveImport, error)
+	// Get returns an import of the user with its progress
+	Get(ctx context.Context, userID, importID uuid.UUID) (*models.RepositoryArchiveImport, error)
+}
+
+// StartRepositoryImportRequest is the repository to create and how to
+// read the archive. With PreserveHistory the branches and tags of a .git
+// directory in the archive are kept; otherwise, or when the archive has
+// none, its files become a single commit.
+type StartRepositoryImportRequest struct {
+	Repository      CreateRepositoryRequest
+	PreserveHistory bool
+}
+
+type repositoryImportService struct {
+	db          *gorm.DB
+	gitService  git.GitService
+	repoService RepositoryService
+	cfg         config.ImportLimits
+	logger      *logrus.Logger
+	now         func() time.Time
+}
+
+func NewRepositoryImportService(db *gorm.DB, gitService git.GitService, repoService RepositoryService, cfg config.ImportLimits, logger *logrus.Logger) RepositoryImportService {
+	return &repositoryImportService{
+		db:          db,
+		gitService:  gitService,
+		repoService: repoService,
+		cfg:         cfg,
+		logger:      logger,
+		now:         time.Now,
+	}
+}
+
+func (s *repositoryImportService) workDir(importID uuid.UUID) string {
+	root := s.cfg.WorkPath
+	if root == "" {
+		root = filepath.Join(os.TempDir(), "hub-imports")
+	}
+	return filepath.Join(root, importID.String())
+}
+
+func (s *repositoryImportService) Start(ctx context.Context, userID uuid.UUID, req StartRepositoryImportRequest, archive io.Reader) (*models.RepositoryArchiveImport, error) {
+	repoReq := req.Repository
+	if repoReq.Name == "" || repoReq.OwnerID == uuid.Nil || repoReq.OwnerType == "" || repoReq.Visibility == "" {
+		return nil, fmt.Errorf("name, owner and visibility are required")
+	}
+	var taken int64
+	if err := s.db.WithContext(ctx).Model(&models.Repository{}).
+		Where("owner_id = ? AND owner_type = ? AND name = ?", repoReq.OwnerID, repoReq.OwnerType, repoReq.Name).
+		Count(&taken).Error; err != nil {
+		return nil, fmt.Errorf("failed to check existing repository: %w", err)
+	}
+	if taken > 0 {
+		return nil, ErrImportRepositoryExists
+	}
+	request, err := json.Marshal(repoReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode repository request: %w", err)
+	}
+
+	imp := &models.RepositoryArchiveImport{
+		ID:              uuid.New(),
+		UserID:          userID,
+		Name:            repoReq.Name,
+		PreserveHistory: req.PreserveHistory,
+		Status:          models.ArchiveImportQueued,
+		Request:         string(request),
+	}
+
+	// The upload is spooled to disk first so its size is known
+	dir := s.workDir(imp.ID)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create import directory: %w", err)
+	}
+	archivePath := filepath.Join(dir, "archive")
+	size, err := s.spool(archive, archivePath)
+	if err == nil {
+		imp.Format, err = detectArchiveFormat(archivePath)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	imp.ArchiveSize = size
+
+	if err := s.db.WithContext(ctx).Create(imp).Error; err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to create repository import: %w", err)
+	}
+
+	if size <= s.cfg.InlineSizeMB<<20 {
+		s.run(ctx, imp)
+		return s.Get(ctx, userID, imp.ID)
+	}
+	go func() {
+		defer errorreporting.Default().Recover("repository_import", map[string]string{"import_id": imp.ID.String()})
+		s.run(context.Background(), imp)
+	}()
+	return imp, nil
+}
+
+func (s *repositoryImportService) spool(archive io.Reader, archivePath string) (int64, error) {
+	file, err := os.OpenFile(archivePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, fmt.Errorf("failed to store archive: %w", err)
+	}
+	defer file.Close()
+
+	limit := s.cfg.MaxArchiveSizeMB << 20
+	size, err := io.Copy(file, io.LimitReader(archive, limit+1))
+	if err != nil {
+		return 0, fmt.Errorf("failed to store archive: %w", err)
+	}
+	if size > limit {
+		return 0, fmt.Errorf("%w: archives are limited to %d MB", ErrImportArchiveTooLarge, s.cfg.MaxArchiveSizeMB)
+	}
+	return size, nil
+}
+
+// detectArchiveFormat reads the format from the archive's magic bytes
+func detectArchiveFormat(archivePath string) (string, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer file.Close()
+
+	header := make([]byte, 512)
+	n, _ := io.ReadFull(file, header)
+	header = header[:n]
+	switch {
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		return ImportFormatTarGz, nil
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")), bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return ImportFormatZip, nil
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return ImportFormatTar, nil
+	}
+	return "", fmt.Errorf("%w: expected a tar, tar.gz or zip archive", ErrInvalidImportArchive)
+}
+
+// run extracts the archive, turns it into history and creates the
+// repository from it, recording progress and the outcome on the import
+func (s *repositoryImportService) run(ctx context.Context, imp *models.RepositoryArchiveImport) {
+	dir := s.workDir(imp.ID)
+	defer os.RemoveAll(dir)
+	log := s.logger.WithFields(logrus.Fields{"import_id": imp.ID, "name": imp.Name})
+
+	repo, err := s.importArchive(ctx, imp, dir)
+	now := s.now()
+	updates := map[string]interface{}{"completed_at": now}
+	if err != nil {
+		log.WithError(err).Warn("Repository import failed")
+		updates["status"], updates["error"] = models.ArchiveImportFailed, err.Error()
+	} else {
+		log.WithField("repository_id", repo.ID).Info("Imported repository from archive")
+		updates["status"], updates["repository_id"] = models.ArchiveImportCompleted, repo.ID
+	}
+	if err := s.db.WithContext(ctx).Model(imp).Updates(updates).Error; err != nil {
+		log.WithError(err).Error("Failed to record repository import outcome")
+	}
+}
+
+func (s *repositoryImportService) importArchive(ctx context.Context, imp *models.RepositoryArchiveImport, dir string) (*models.Repository, error) {
+	var req CreateRepositoryRequest
+	if err := json.Unmarshal([]byte(imp.Request), &req); err != nil {
+		return nil, fmt.Errorf("failed to decode repository request: %w", err)
+	}
+
+	s.setStatus(ctx, imp, models.ArchiveImportExtracting)
+	root := filepath.Join(dir, "tree")
+	if err := s.extract(ctx, imp, filepath.Join(dir, "archive"), root); err != nil {
+		return nil, err
+	}
+	tree, err := projectRoot(root)
+	if err != nil {
+		return nil, err
+	}
+
+	s.setStatus(ctx, imp, models.ArchiveImportCommitting)
+	info, err := os.Lstat(filepath.Join(tree, ".git"))
+	if imp.PreserveHistory && err == nil && info.IsDir() {
+		if req.DefaultBranch == "" {
+			repoInfo, err := s.gitService.GetRepositoryInfo(ctx, tree)
+			if err != nil {
+				return nil, fmt.Errorf("%w: .git is not a readable repository: %v", ErrInvalidImportArchive, err)
+			}
+			req.DefaultBranch = repoInfo.DefaultBranch
+		}
+		if _, err := s.gitService.GetBranch(ctx, tree, req.DefaultBranch); err != nil {
+			return nil, fmt.Errorf("%w: branch %s is not in the archive's history", ErrInvalidImportArchive, req.DefaultBranch)
+		}
+	} else {
+		if err := removeGitDirectories(tree); err != nil {
+			return nil, err
+		}
+		if req.DefaultBranch == "" {
+			req.DefaultBranch = "main"
+		}
+		if _, err := s.gitService.SnapshotDirectory(ctx, tree, git.SnapshotRequest{
+			Branch:  req.DefaultBranch,
+			Message: "Import " + req.Name,
+			Author:  s.author(ctx, imp.UserID),
+		}); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidImportArchive, err)
+		}
+	}
+
+	req.AutoInit = false
+	req.ImportFrom = tree
+	return s.repoService.Create(ctx, req)
+}
+
+func (s *repositoryImportService) setStatus(ctx context.Context, imp *models.RepositoryArchiveImport, status models.RepositoryArchiveImportStatus) {
+	imp.Status = status
+	if err := s.db.WithContext(ctx).Model(imp).Update("status", status).Error; err != nil {
+		s.logger.WithError(err).WithField("import_id", imp.ID).Warn("Failed to update repository import status")
+	}
+}
+
+func (s *repositoryImportService) author(ctx context.Context, userID uuid.UUID) git.CommitAuthor {
+	var user models.User
+	if err := s.db.WithContext(ctx).First(&user, "id = ?", userID).Error; err != nil {
+		return git.CommitAuthor{Name: "System", Email: "noreply@hub.local"}
+	}
+	author := git.CommitAuthor{Name: user.FullName, Email: user.Email}
+	if author.Name == "" {
+		author.Name = user.Username
+	}
+	return author
+}
+
+// archiveExtractor writes archive entries under dest within the import limits
+type archiveExtractor struct {
+	dest     string
+	maxBytes int64
+	maxFiles int
+	written  int64
+	files    int
+	progress func(files int)
+}
+
+// add writes one entry. Only directories and regular files are extracted;
+// links and devices are skipped so nothing can point outside dest.
+func (x *archiveExtractor) add(name string, mode fs.FileMode, r io.Reader) error {
+	clean := strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+	if clean == "" {
+		return nil
+	}
+	target := filepath.Join(x.dest, filepath.FromSlash(clean))
+
+	switch {
+	case mode.IsDir():
+		return os.MkdirAll(target, 0o755)
+	case !mode.IsRegular():
+		return nil
+	}
+
+	x.files++
+	if x.files > x.maxFiles {
+		return fmt.Errorf("%w: archives are limited to %d files", ErrImportArchiveTooLarge, x.maxFiles)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	perm := fs.FileMode(0o644)
+	if mode&0o111 != 0 {
+		perm = 0o755
+	}
+	file, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	remaining := x.maxBytes - x.written
+	n, err := io.Copy(file, io.LimitReader(r, remaining+1))
+	x.written += n
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImportArchive, err)
+	}
+	if n > remaining {
+		return fmt.Errorf("%w: extracted files are limited to %d MB", ErrImportArchiveTooLarge, x.maxBytes>>20)
+	}
+	if x.files%importProgressEvery == 0 {
+		x.progress(x.files)
+	}
+	return nil
+}
+
+func (s *repositoryImportService) extract(ctx context.Context, imp *models.RepositoryArchiveImport, archivePath, dest string) error {
+	x := &archiveExtractor{
+		dest:     dest,
+		maxBytes: s.cfg.MaxExtractedSizeMB << 20,
+		maxFiles: s.cfg.MaxFiles,
+		progress: func(files int) {
+			s.db.WithContext(ctx).Model(imp).Update("files_processed", files)
+		},
+	}
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+
+	var err error
+	if imp.Format == ImportFormatZip {
+		err = s.extractZip(ctx, imp, archivePath, x)
+	} else {
+		err = extractTar(ctx, imp.Format, archivePath, x)
+	}
+	if err != nil {
+		return err
+	}
+
+	imp.FilesTotal, imp.FilesProcessed = x.files, x.files
+	return s.db.WithContext(ctx).Model(imp).Updates(map[string]interface{}{
+		"files_total": x.files, "files_processed": x.files,
+	}).Error
+}
+
+func (s *repositoryImportService) extractZip(ctx context.Context, imp *models.RepositoryArchiveImport, archivePath string, x *archiveExtractor) error {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidImportArchive, err)
+	}
+	defer archive.Close()
+
+	// Zip archives list their files up front
+	total := 0
+	for _, f := range archive.File {
+		if f.Mode().IsRegular() {
+			total++
+		}
+	}
+	imp.FilesTotal = total
+	s.db.WithContext(ctx).Model(imp).Update("files_total", total)
+
+	for _, f := range archive.File {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !f.Mode().IsRegular() {
+			if err := x.add(f.Name, f.Mode(), nil); err != nil {
+				return err
+			}
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrInvalidImportArchive, f.Name, err)
+		}
+		err = x.add(f.Name, f.Mode(), r)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTar(ctx context.Context, format, archivePath string, x *archiveExtractor) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if format == ImportFormatTarGz {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidImportArchive, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidImportArchive, err)
+		}
+		if err := x.add(hdr.Name, hdr.FileInfo().Mode(), tr); err != nil {
+			return err
+		}
+	}
+}
+
+// projectRoot returns the directory holding the project: archives made from
+// a project folder wrap everything in one top-level directory
+func projectRoot(root string) (string, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "", fmt.Errorf("%w: the archive is empty", ErrInvalidImportArchive)
+	}
+	if len(entries) == 1 && entries[0].IsDir() && entries[0].Name() != ".git" {
+		return filepath.Join(root, entries[0].Name()), nil
+	}
+	return root, nil
+}
+
+// removeGitDirectories drops every .git entry, including those of nested
+// repositories, before the files are committed
+func removeGitDirectories(tree string) error {
+	var found []string
+	err := filepath.WalkDir(tree, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Name() == ".git" {
+			found = append(found, p)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range found {
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *repositoryImportService) Get(ctx context.Context, userID, importID uuid.UUID) (*models.RepositoryArchiveImport, error) {
+	var imp models.RepositoryArchiveImport
+	if err := s.db.WithContext(ctx).Preload("Repository").
+		First(&imp, "id = ? AND user_id = ?", importID, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRepositoryImportNotFound
+		}
+		return nil, err
+	}
+
+	// An import that stopped making progress was cut short by a restart
+	finished := imp.Status == models.ArchiveImportCompleted || imp.Status == models.ArchiveImportFailed
+	if !finished && s.now().Sub(imp.UpdatedAt) > importStaleAfter {
+		now := s.now()
+		imp.Status, imp.Error, imp.CompletedAt = models.ArchiveImportFailed, "import was interrupted", &now
+		if err := s.db.WithContext(ctx).Model(&imp).Updates(map[string]interface{}{
+			"status": imp.Status, "error": imp.Error, "completed_at": now,
+		}).Error; err != nil {
+			return nil, fmt.Errorf("failed to update repository import: %w", err)
+		}
+		os.RemoveAll(s.workDir(imp.ID))
+	}
+	return &imp, nil
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	// Links are skipped rather than followed
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "project/passwd", Linkname: "/etc/passwd", Typeflag: tar.TypeSymlink}))
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// zipDirectory archives everything under dir, including a .git directory
+func zipDirectory(t *testing.T, dir string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	require.NoError(t, filepath.WalkDir(dir, func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, p)
+		w, err := zw.Create(filepath.ToSlash(rel))
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	}))
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func TestRepositoryImportService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.Repository{}, &models.RepositoryArchiveImport{})
+
+	user := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", FullName: "Alice"}
+	require.NoError(t, db.Create(user).Error)
+
+	logger := logrus.New()
+	gitService := git.NewGitService(logger)
+	repoService := NewRepositoryService(db, gitService, logger, t.TempDir())
+	svc := NewRepositoryImportService(db, gitService, repoService, config.ImportLimits{
+		MaxArchiveSizeMB: 1, MaxExtractedSizeMB: 1, MaxFiles: 10, InlineSizeMB: 1, WorkPath: t.TempDir(),
+	}, logger)
+	ctx := context.Background()
+	request := func(name string, preserve bool) StartRepositoryImportRequest {
+		return StartRepositoryImportRequest{
+			Repository:      CreateRepositoryRequest{OwnerID: user.ID, OwnerType: models.OwnerTypeUser, Name: name, Visibility: models.VisibilityPrivate},
+			PreserveHistory: preserve,
+		}
+	}
+	files := func(repo *models.Repository, ref string) map[string]string {
+		repoPath, err := repoService.GetRepositoryPath(ctx, repo.ID)
+		require.NoError(t, err)
+		found := map[string]string{}
+		require.NoError(t, gitService.WalkFiles(ctx, repoPath, ref, 0, func(path string, content []byte) error {
+			found[path] = string(content)
+			return nil
+		}))
+		return found
+	}
+
+	// A project folder is committed as one snapshot, without its .git
+	imp, err := svc.Start(ctx, user.ID, request("app", false), bytes.NewReader(tarGzArchive(t, map[string]string{
+		"project/README.md":               "hello",
+		"project/src/main.go":             "package main",
+		"project/.git/HEAD":               "ref: refs/heads/main\n",
+		"project/../../project/notes.txt": "contained",
+	})))
+	require.NoError(t, err)
+	assert.Equal(t, models.ArchiveImportCompleted, imp.Status, imp.Error)
+	assert.Equal(t, ImportFormatTarGz, imp.Format)
+	assert.Equal(t, 4, imp.FilesTotal)
+	require.NotNil(t, imp.Repository)
+	assert.Equal(t, "main", imp.Repository.DefaultBranch)
+	assert.Equal(t, map[string]string{"README.md": "hello", "src/main.go": "package main", "notes.txt": "contained"}, files(imp.Repository, "main"))
+
+	// With history preserved the archive's branches and tags are kept
+	project := t.TempDir()
+	writeFile := func(name, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(project, name)), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(project, name), []byte(content), 0o644))
+	}
+	writeFile("go.mod", "module example.com/lib\n")
+	commit, err := gitService.SnapshotDirectory(ctx, project, git.SnapshotRequest{Branch: "trunk", Message: "First", Author: git.CommitAuthor{Name: "bob", Email: "bob@example.com"}})
+	require.NoError(t, err)
+	require.NoError(t, gitService.CreateTag(ctx, project, "v0.1.0", "trunk", ""))
+
+	imp, err = svc.Start(ctx, user.ID, request("lib", true), bytes.NewReader(zipDirectory(t, project)))
+	require.NoError(t, err)
+	assert.Equal(t, models.ArchiveImportCompleted, imp.Status, imp.Error)
+	assert.Equal(t, ImportFormatZip, imp.Format)
+	assert.Equal(t, "trunk", imp.Repository.DefaultBranch)
+	repoPath, err := repoService.GetRepositoryPath(ctx, imp.Repository.ID)
+	require.NoError(t, err)
+	tag, err := gitService.GetTag(ctx, repoPath, "v0.1.0")
+	require.NoError(t, err)
+	assert.Equal(t, commit.SHA, tag.SHA)
+
+	got, err := svc.Get(ctx, user.ID, imp.ID)
+	require.NoError(t, err)
+	assert.Equal(t, imp.Repository.ID, *got.RepositoryID)
+	_, err = svc.Get(ctx, uuid.New(), imp.ID)
+	assert.True(t, errors.Is(err, ErrRepositoryImportNotFound), "imports are private to their user")
+
+	// Invalid archives and taken names are refused before anything is stored
+	_, err = svc.Start(ctx, user.ID, request("junk", false), bytes.NewReader([]byte("not an archive")))
+	assert.True(t, errors.Is(err, ErrInvalidImportArchive))
+	_, err = svc.Start(ctx, user.ID, request("app", false), bytes.NewReader(zipDirectory(t, project)))
+	assert.True(t, errors.Is(err, ErrImportRepositoryExists))
+	_, err = svc.Start(ctx, user.ID, request("big", false), bytes.NewReader(make([]byte, 2<<20)))
+	assert.True(t, errors.Is(err, ErrImportArchiveTooLarge))
+
+	// Limits on the extracted files fail the import and leave no repository
+	many := map[string]string{}
+	for _, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k"} {
+		many[name] = name
+	}
+	imp, err = svc.Start(ctx, user.ID, request("many", false), bytes.NewReader(tarGzArchive(t, many)))
+	require.NoError(t, err)
+	assert.Equal(t, models.ArchiveImportFailed, imp.Status)
+	assert.Contains(t, imp.Error, "limited to 10 files")
+	var count int64
+	require.NoError(t, db.Model(&models.Repository{}).Where("name = ?", "many").Count(&count).Error)
+	assert.Zero(t, count)
+
+	bomb := map[string]string{"zeros": string(make([]byte, 2<<20))}
+	imp, err = svc.Start(ctx, user.ID, request("bomb", false), bytes.NewReader(tarGzArchive(t, bomb)))
+	require.NoError(t, err)
+	assert.Equal(t, models.ArchiveImportFailed, imp.Status)
+	assert.Contains(t, imp.Error, "limited to 1 MB")
+}
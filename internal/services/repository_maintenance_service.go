@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ErrRepositoryNotOnDisk is returned for repositories that have never been
+// initialized on the filesystem
+var ErrRepositoryNotOnDisk = errors.New("repository has no object database")
+
+// RepositoryMaintenanceService reports repository pack layouts and repacks
+// repositories with options tuned to how they are used
+type RepositoryMaintenanceService interface {
+	// PackStatistics describes a repository's packs together with its recent
+	// access pattern and the repack the scheduler would run
+	PackStatistics(ctx context.Context, repoID uuid.UUID, withDeltas bool) (*PackReport, error)
+	// Maintain repacks a repository when it needs it, or unconditionally
+	// when force is set
+	Maintain(ctx context.Context, repoID uuid.UUID, force bool) (*MaintenanceResult, error)
+	RunScheduled(ctx context.Context)
+}
+
+// PackReport is the pack statistics of one repository
+type PackReport struct {
+	RepositoryID uuid.UUID          `json:"repository_id"`
+	Stats        *git.PackStats     `json:"stats"`
+	Access       git.AccessPattern  `json:"access"`
+	AccessDays   int                `json:"access_window_days"`
+	NeedsRepack  bool               `json:"needs_repack"`
+	Repack       *git.RepackOptions `json:"recommended_repack,omitempty"`
+}
+
+// MaintenanceResult summarizes one maintenance run of a repository
+type MaintenanceResult struct {
+	RepositoryID uuid.UUID          `json:"repository_id"`
+	Repacked     bool               `json:"repacked"`
+	Options      *git.RepackOptions `json:"options,omitempty"`
+	Before       *git.PackStats     `json:"before"`
+	After        *git.PackStats     `json:"after,omitempty"`
+	Duration     time.Duration      `json:"duration_ns"`
+}
+
+// maintenanceBatchSize bounds the repositories loaded per scheduler query
+const maintenanceBatchSize = 100
+
+type repositoryMaintenanceService struct {
+	db                *gorm.DB
+	repositoryService RepositoryService
+	packs             *git.PackStore
+	cfg               config.Maintenance
+	logger            *logrus.Logger
+	now               func() time.Time
+}
+
+// NewRepositoryMaintenanceService creates a new RepositoryMaintenanceService
+func NewRepositoryMaintenanceService(db *gorm.DB, repositoryService RepositoryService, packs *git.PackStore, cfg config.Maintenance, logger *logrus.Logger) RepositoryMaintenanceService {
+	return &repositoryMaintenanceService{
+		db:                db,
+		repositoryService: repositoryService,
+		packs:             packs,
+		cfg:               cfg,
+		logger:            logger,
+		now:               time.Now,
+	}
+}
+
+func (s *repositoryMaintenanceService) thresholds() git.MaintenanceThresholds {
+	return git.MaintenanceThresholds{
+		MaxLooseObjects:   s.cfg.MaxLooseObjects,
+		MaxPacks:          s.cfg.MaxPacks,
+		BusyFetchesPerDay: s.cfg.BusyFetchesPerDay,
+		BusyPushesPerDay:  s.cfg.BusyPushesPerDay,
+	}
+}
+
+func (s *repositoryMaintenanceService) accessWindowDays() int {
+	if s.cfg.AccessWindowDays <= 0 {
+		return 7
+	}
+	return s.cfg.AccessWindowDays
+}
+
+// accessPattern counts the clone and push analytics events of a repository
+// within the access window
+func (s *repositoryMaintenanceService) accessPattern(ctx context.Context, repoID uuid.UUID) (git.AccessPattern, error) {
+	days := s.accessWindowDays()
+	access := git.AccessPattern{Window: time.Duration(days) * 24 * time.Hour}
+
+	var rows []struct {
+		EventType models.EventType
+		Count     int64
+	}
+	err := s.db.WithContext(ctx).Model(&models.AnalyticsEvent{}).
+		Select("event_type, COUNT(*) AS count").
+		Where("repository_id = ? AND event_type IN ? AND created_at >= ?", repoID,
+			[]models.EventType{models.EventRepositoryClone, models.EventRepositoryPush}, s.now().AddDate(0, 0, -days)).
+		Group("event_type").
+		Scan(&rows).Error
+	if err != nil {
+		return access, fmt.Errorf("failed to count repository access: %w", err)
+	}
+	for _, row := range rows {
+		switch row.EventType {
+		case models.EventRepositoryClone:
+			access.Fetches = row.Count
+		case models.EventRepositoryPush:
+			access.Pushes = row.Count
+		}
+	}
+	return access, nil
+}
+
+func (s *repositoryMaintenanceService) repositoryPath(ctx context.Context, repoID uuid.UUID) (string, error) {
+	repoPath, err := s.repositoryService.GetRepositoryPath(ctx, repoID)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(repoPath); os.IsNotExist(err) {
+		return "", ErrRepositoryNotOnDisk
+	}
+	return repoPath, nil
+}
+
+func (s *repositoryMaintenanceService) PackStatistics(ctx context.Context, repoID uuid.UUID, withDeltas bool) (*PackReport, error) {
+	repoPath, err := s.repositoryPath(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := git.PackStatistics(ctx, s.packs, repoPath, withDeltas)
+	if err != nil {
+		return nil, err
+	}
+	access, err := s.accessPattern(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PackReport{RepositoryID: repoID, Stats: stats, Access: access, AccessDays: s.accessWindowDays()}
+	if opts, needed := git.TuneRepack(stats, access, s.thresholds()); needed {
+		report.NeedsRepack = true
+		report.Repack = &opts
+	}
+	return report, nil
+}
+
+func (s *repositoryMaintenanceService) Maintain(ctx context.Context, repoID uuid.UUID, force bool) (*MaintenanceResult, error) {
+	repoPath, err := s.repositoryPath(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+	before, err := git.PackStatistics(ctx, s.packs, repoPath, false)
+	if err != nil {
+		return nil, err
+	}
+	access, err := s.accessPattern(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MaintenanceResult{RepositoryID: repoID, Before: before}
+	opts, needed := git.TuneRepack(before, access, s.thresholds())
+	if !needed {
+		if !force {
+			return result, nil
+		}
+		// A forced repack of an idle repository consolidates it fully
+		opts = git.RepackOptions{WriteBitmaps: true}
+	}
+
+	start := s.now()
+	if err := git.Repack(ctx, s.packs, repoPath, opts); err != nil {
+		return nil, err
+	}
+	result.Duration = s.now().Sub(start)
+	result.Repacked = true
+	result.Options = &opts
+	if result.After, err = git.PackStatistics(ctx, s.packs, repoPath, false); err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"repository_id": repoID,
+		"geometric":     opts.Geometric,
+		"bitmaps":       opts.WriteBitmaps,
+		"packs_before":  before.PackCount,
+		"packs_after":   result.After.PackCount,
+		"duration":      result.Duration,
+	}).Info("Repository repacked")
+	return result, nil
+}
+
+// RunScheduled checks every repository once, repacking those whose layout
+// calls for it
+func (s *repositoryMaintenanceService) RunScheduled(ctx context.Context) {
+	var repacked, failed int
+	var repos []models.Repository
+	err := s.db.WithContext(ctx).Select("id").Order("id").
+		FindInBatches(&repos, maintenanceBatchSize, func(tx *gorm.DB, batch int) error {
+			for _, repo := range repos {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				result, err := s.Maintain(ctx, repo.ID, false)
+				switch {
+				case errors.Is(err, ErrRepositoryNotOnDisk):
+				case err != nil:
+					failed++
+					s.logger.WithError(err).WithField("repository_id", repo.ID).Warn("Failed to maintain repository")
+				case result.Repacked:
+					repacked++
+				}
+			}
+			return nil
+		}).Error
+	if err != nil && !errors.Is(err, context.Canceled) {
+		s.logger.WithError(err).Error("Failed to scan repositories for maintenance")
+	}
+	s.logger.WithFields(logrus.Fields{"repacked": repacked, "failed": failed}).Info("Repository maintenance run completed")
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryMaintenanceService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Repository{}, &models.AnalyticsEvent{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	base := t.TempDir()
+	gitService := git.NewGitService(logger)
+	repoService := NewRepositoryService(db, gitService, logger, base)
+	svc := NewRepositoryMaintenanceService(db, repoService, nil, config.Maintenance{
+		MaxLooseObjects:   3,
+		MaxPacks:          10,
+		BusyFetchesPerDay: 100,
+		BusyPushesPerDay:  1,
+		AccessWindowDays:  7,
+	}, logger)
+
+	ownerID := uuid.New()
+	repo := &models.Repository{ID: uuid.New(), OwnerID: ownerID, OwnerType: models.OwnerTypeUser, Name: "app", Visibility: models.VisibilityPrivate}
+	missing := &models.Repository{ID: uuid.New(), OwnerID: ownerID, OwnerType: models.OwnerTypeUser, Name: "empty", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create([]*models.Repository{repo, missing}).Error)
+
+	_, err := svc.PackStatistics(ctx, missing.ID, false)
+	assert.ErrorIs(t, err, ErrRepositoryNotOnDisk)
+
+	repoPath := filepath.Join(base, "user", ownerID.String(), "app.git")
+	require.NoError(t, gitService.InitRepository(ctx, repoPath, true))
+	_, err = gitService.CommitFiles(ctx, repoPath, git.CommitFilesRequest{
+		Files:   []git.CommitFileEntry{{Path: "main.go", Content: []byte("package main\n")}},
+		Message: "initial",
+		Branch:  "main",
+		Author:  git.CommitAuthor{Name: "Alice", Email: "alice@example.com"},
+	})
+	require.NoError(t, err)
+
+	// Pushes in the window make the repository busy; older ones are ignored
+	for _, at := range []time.Time{time.Now().Add(-time.Hour), time.Now().AddDate(0, 0, -1), time.Now().AddDate(0, 0, -30)} {
+		for i := 0; i < 4; i++ {
+			require.NoError(t, db.Create(&models.AnalyticsEvent{ID: uuid.New(), CreatedAt: at, EventType: models.EventRepositoryPush, RepositoryID: &repo.ID}).Error)
+		}
+	}
+	require.NoError(t, db.Create(&models.AnalyticsEvent{ID: uuid.New(), EventType: models.EventRepositoryClone, RepositoryID: &repo.ID}).Error)
+
+	report, err := svc.PackStatistics(ctx, repo.ID, false)
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), report.Access.Pushes)
+	assert.Equal(t, int64(1), report.Access.Fetches)
+	assert.Equal(t, 3, report.Stats.LooseObjects)
+	require.True(t, report.NeedsRepack)
+	assert.Equal(t, git.RepackOptions{Geometric: 2}, *report.Repack)
+
+	result, err := svc.Maintain(ctx, repo.ID, false)
+	require.NoError(t, err)
+	assert.True(t, result.Repacked)
+	assert.Equal(t, 1, result.After.PackCount)
+	assert.Zero(t, result.After.LooseObjects)
+
+	// A tidy repository is left alone unless forced
+	result, err = svc.Maintain(ctx, repo.ID, false)
+	require.NoError(t, err)
+	assert.False(t, result.Repacked)
+	result, err = svc.Maintain(ctx, repo.ID, true)
+	require.NoError(t, err)
+	assert.True(t, result.Repacked)
+	assert.True(t, result.After.HasBitmap)
+}
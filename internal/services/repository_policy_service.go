@@ -0,0 +1,334 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrForkingRestricted          = errors.New("forking is restricted by organization policy")
+	ErrVisibilityChangeRestricted = errors.New("changing repository visibility is restricted by organization policy")
+	ErrInvalidRepositoryPolicy    = errors.New("invalid repository policy")
+	ErrRepositoryPolicyForbidden  = errors.New("only organization owners and admins can manage repository policies")
+)
+
+// Repository policy rules reported by compliance checks
+const (
+	PolicyRuleForking                 = "forking_disabled"
+	PolicyRulePrivateForking          = "private_forking_disabled"
+	PolicyRuleForkOutsideOrganization = "fork_outside_organization"
+)
+
+// RepositoryPolicy controls forking and visibility changes of an
+// organization's repositories, and whether creating or deleting them needs
+// an owner or admin to approve
+type RepositoryPolicy struct {
+	AllowForking                  bool                          `json:"allow_forking"`
+	AllowPrivateForking           bool                          `json:"allow_private_forking"`
+	AllowForksOutsideOrganization bool                          `json:"allow_forks_outside_organization"`
+	VisibilityChangePolicy        models.VisibilityChangePolicy `json:"visibility_change_policy"`
+	RequireCreationApproval       bool                          `json:"require_creation_approval"`
+	RequireDeletionApproval       bool                          `json:"require_deletion_approval"`
+}
+
+// UpdateRepositoryPolicyRequest changes the fields that are set
+type UpdateRepositoryPolicyRequest struct {
+	AllowForking                  *bool                          `json:"allow_forking,omitempty"`
+	AllowPrivateForking           *bool                          `json:"allow_private_forking,omitempty"`
+	AllowForksOutsideOrganization *bool                          `json:"allow_forks_outside_organization,omitempty"`
+	VisibilityChangePolicy        *models.VisibilityChangePolicy `json:"visibility_change_policy,omitempty"`
+	RequireCreationApproval       *bool                          `json:"require_creation_approval,omitempty"`
+	RequireDeletionApproval       *bool                          `json:"require_deletion_approval,omitempty"`
+}
+
+// RepositoryPolicyViolation is an existing fork that the current policy
+// would not allow
+type RepositoryPolicyViolation struct {
+	Rule         string    `json:"rule"`
+	RepositoryID uuid.UUID `json:"repository_id"`
+	Repository   string    `json:"repository"`
+	ForkID       uuid.UUID `json:"fork_id"`
+	Fork         string    `json:"fork"`
+	Message      string    `json:"message"`
+}
+
+// RepositoryPolicyCompliance reports how an organization's repositories
+// conform to its repository policy
+type RepositoryPolicyCompliance struct {
+	Organization string                      `json:"organization"`
+	Policy       RepositoryPolicy            `json:"policy"`
+	Compliant    bool                        `json:"compliant"`
+	Violations   []RepositoryPolicyViolation `json:"violations"`
+	CheckedAt    time.Time                   `json:"checked_at"`
+}
+
+// RepositoryPolicyService manages organization forking, visibility and
+// approval policies. The repository service enforces the first two on fork
+// and update; RepositoryApprovalService handles approvals.
+type RepositoryPolicyService interface {
+	Get(ctx context.Context, orgName string) (*RepositoryPolicy, error)
+	Update(ctx context.Context, orgName string, actorID uuid.UUID, req UpdateRepositoryPolicyRequest) (*RepositoryPolicy, error)
+	// Compliance lists existing forks that violate the policy
+	Compliance(ctx context.Context, orgName string, actorID uuid.UUID) (*RepositoryPolicyCompliance, error)
+}
+
+type repositoryPolicyService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+// NewRepositoryPolicyService creates a new RepositoryPolicyService
+func NewRepositoryPolicyService(db *gorm.DB, logger *logrus.Logger) RepositoryPolicyService {
+	return &repositoryPolicyService{db: db, logger: logger}
+}
+
+// defaultRepositoryPolicy applies to organizations without settings
+var defaultRepositoryPolicy = RepositoryPolicy{
+	AllowForking:                  true,
+	AllowPrivateForking:           true,
+	AllowForksOutsideOrganization: true,
+	VisibilityChangePolicy:        models.VisibilityChangeRepositoryAdmins,
+}
+
+// loadRepositoryPolicy reads the repository policy of an organization
+func loadRepositoryPolicy(ctx context.Context, db *gorm.DB, orgID uuid.UUID) (RepositoryPolicy, error) {
+	var settings models.OrganizationSettings
+	err := db.WithContext(ctx).Where("organization_id = ?", orgID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return defaultRepositoryPolicy, nil
+	}
+	if err != nil {
+		return RepositoryPolicy{}, fmt.Errorf("failed to get organization settings: %w", err)
+	}
+	policy := RepositoryPolicy{
+		AllowForking:                  settings.AllowForking,
+		AllowPrivateForking:           settings.AllowPrivateForking,
+		AllowForksOutsideOrganization: settings.AllowForksOutsideOrganization,
+		VisibilityChangePolicy:        settings.VisibilityChangePolicy,
+		RequireCreationApproval:       settings.RequireRepositoryCreationApproval,
+		RequireDeletionApproval:       settings.RequireRepositoryDeletionApproval,
+	}
+	if policy.VisibilityChangePolicy == "" {
+		policy.VisibilityChangePolicy = models.VisibilityChangeRepositoryAdmins
+	}
+	return policy, nil
+}
+
+// forkViolation returns the rule a fork of source owned by ownerID breaks, if any
+func (p RepositoryPolicy) forkViolation(source *models.Repository, ownerID uuid.UUID, ownerType models.OwnerType) string {
+	switch {
+	case !p.AllowForking:
+		return PolicyRuleForking
+	case source.Visibility != models.VisibilityPublic && !p.AllowPrivateForking:
+		return PolicyRulePrivateForking
+	case !p.AllowForksOutsideOrganization && (ownerType != models.OwnerTypeOrganization || ownerID != source.OwnerID):
+		return PolicyRuleForkOutsideOrganization
+	}
+	return ""
+}
+
+var forkRuleMessages = map[string]string{
+	PolicyRuleForking:                 "forking is disabled",
+	PolicyRulePrivateForking:          "private and internal repositories cannot be forked",
+	PolicyRuleForkOutsideOrganization: "forks must stay in the organization",
+}
+
+// checkForkPolicy enforces the policy of the organization owning source
+func checkForkPolicy(ctx context.Context, db *gorm.DB, source *models.Repository, ownerID uuid.UUID, ownerType models.OwnerType) error {
+	if source.OwnerType != models.OwnerTypeOrganization {
+		return nil
+	}
+	policy, err := loadRepositoryPolicy(ctx, db, source.OwnerID)
+	if err != nil {
+		return err
+	}
+	if rule := policy.forkViolation(source, ownerID, ownerType); rule != "" {
+		return fmt.Errorf("%w: %s", ErrForkingRestricted, forkRuleMessages[rule])
+	}
+	return nil
+}
+
+// checkVisibilityPolicy enforces who may change the visibility of an
+// organization repository. Requests without an authenticated tenant are
+// internal and not restricted.
+func checkVisibilityPolicy(ctx context.Context, db *gorm.DB, repo *models.Repository) error {
+	if repo.OwnerType != models.OwnerTypeOrganization {
+		return nil
+	}
+	t, ok := tenant.FromContext(ctx)
+	if !ok || !t.IsAuthenticated() || t.IsAdmin {
+		return nil
+	}
+
+	policy, err := loadRepositoryPolicy(ctx, db, repo.OwnerID)
+	if err != nil {
+		return err
+	}
+	if policy.VisibilityChangePolicy == models.VisibilityChangeRepositoryAdmins &&
+		t.Repository != nil && t.Repository.ID == repo.ID && t.HasPermission(models.PermissionAdmin) {
+		return nil
+	}
+
+	var member models.OrganizationMember
+	err = db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", repo.OwnerID, *t.UserID).First(&member).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to get organization membership: %w", err)
+	}
+	switch {
+	case member.Role == models.OrgRoleOwner:
+		return nil
+	case member.Role == models.OrgRoleAdmin && policy.VisibilityChangePolicy != models.VisibilityChangeOwners:
+		return nil
+	}
+	return ErrVisibilityChangeRestricted
+}
+
+// authorize resolves the organization and requires actorID to be one of
+// its owners or admins
+func (s *repositoryPolicyService) authorize(ctx context.Context, orgName string, actorID uuid.UUID) (*models.Organization, error) {
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("organization not found: %w", err)
+	}
+	var member models.OrganizationMember
+	err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", org.ID, actorID).First(&member).Error
+	if err != nil || (member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin) {
+		return nil, ErrRepositoryPolicyForbidden
+	}
+	return &org, nil
+}
+
+func (s *repositoryPolicyService) Get(ctx context.Context, orgName string) (*RepositoryPolicy, error) {
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("organization not found: %w", err)
+	}
+	policy, err := loadRepositoryPolicy(ctx, s.db, org.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (s *repositoryPolicyService) Update(ctx context.Context, orgName string, actorID uuid.UUID, req UpdateRepositoryPolicyRequest) (*RepositoryPolicy, error) {
+	org, err := s.authorize(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]interface{})
+	if req.AllowForking != nil {
+		updates["allow_forking"] = *req.AllowForking
+	}
+	if req.AllowPrivateForking != nil {
+		updates["allow_private_forking"] = *req.AllowPrivateForking
+	}
+	if req.AllowForksOutsideOrganization != nil {
+		updates["allow_forks_outside_organization"] = *req.AllowForksOutsideOrganization
+	}
+	if req.VisibilityChangePolicy != nil {
+		switch *req.VisibilityChangePolicy {
+		case models.VisibilityChangeOwners, models.VisibilityChangeAdmins, models.VisibilityChangeRepositoryAdmins:
+		default:
+			return nil, fmt.Errorf("%w: unknown visibility change policy %q", ErrInvalidRepositoryPolicy, *req.VisibilityChangePolicy)
+		}
+		updates["visibility_change_policy"] = *req.VisibilityChangePolicy
+	}
+	if req.RequireCreationApproval != nil {
+		updates["require_repository_creation_approval"] = *req.RequireCreationApproval
+	}
+	if req.RequireDeletionApproval != nil {
+		updates["require_repository_deletion_approval"] = *req.RequireDeletionApproval
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var settings models.OrganizationSettings
+		err := tx.Where(models.OrganizationSettings{OrganizationID: org.ID}).
+			Attrs(models.OrganizationSettings{ID: uuid.New()}).FirstOrCreate(&settings).Error
+		if err != nil {
+			return err
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		// A map update, so that false is written rather than skipped
+		return tx.Model(&settings).Updates(updates).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update repository policy: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"organization": orgName,
+		"actor_id":     actorID,
+		"changes":      updates,
+	}).Info("Updated organization repository policy")
+
+	policy, err := loadRepositoryPolicy(ctx, s.db, org.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func (s *repositoryPolicyService) Compliance(ctx context.Context, orgName string, actorID uuid.UUID) (*RepositoryPolicyCompliance, error) {
+	org, err := s.authorize(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+	policy, err := loadRepositoryPolicy(ctx, s.db, org.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources []*models.Repository
+	if err := s.db.WithContext(ctx).Where("owner_id = ? AND owner_type = ?", org.ID, models.OwnerTypeOrganization).
+		Find(&sources).Error; err != nil {
+		return nil, fmt.Errorf("failed to list organization repositories: %w", err)
+	}
+	byID := make(map[uuid.UUID]*models.Repository, len(sources))
+	sourceIDs := make([]uuid.UUID, 0, len(sources))
+	for _, repo := range sources {
+		byID[repo.ID] = repo
+		sourceIDs = append(sourceIDs, repo.ID)
+	}
+
+	result := &RepositoryPolicyCompliance{
+		Organization: org.Name,
+		Policy:       policy,
+		Violations:   []RepositoryPolicyViolation{},
+		CheckedAt:    time.Now(),
+	}
+	if len(sourceIDs) > 0 {
+		var forks []*models.Repository
+		if err := s.db.WithContext(ctx).Where("parent_id IN ?", sourceIDs).Order("created_at ASC").Find(&forks).Error; err != nil {
+			return nil, fmt.Errorf("failed to list forks: %w", err)
+		}
+		names := ownerNames(ctx, s.db, s.logger, forks)
+		for _, fork := range forks {
+			source := byID[*fork.ParentID]
+			rule := policy.forkViolation(source, fork.OwnerID, fork.OwnerType)
+			if rule == "" {
+				continue
+			}
+			result.Violations = append(result.Violations, RepositoryPolicyViolation{
+				Rule:         rule,
+				RepositoryID: source.ID,
+				Repository:   org.Name + "/" + source.Name,
+				ForkID:       fork.ID,
+				Fork:         names[fork.OwnerID] + "/" + fork.Name,
+				Message:      forkRuleMessages[rule],
+			})
+		}
+	}
+	result.Compliant = len(result.Violations) == 0
+	return result, nil
+}
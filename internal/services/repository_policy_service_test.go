@@ -0,0 +1,88 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/tenant"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryPolicyService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{},
+		&models.OrganizationSettings{}, &models.Repository{})
+
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	owner, admin, member := uuid.New(), uuid.New(), uuid.New()
+	for id, role := range map[uuid.UUID]models.OrganizationRole{owner: models.OrgRoleOwner, admin: models.OrgRoleAdmin, member: models.OrgRoleMember} {
+		require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: id, Role: role}).Error)
+	}
+	require.NoError(t, db.Create(&models.User{ID: member, Username: "mallory", Email: "mallory@example.com"}).Error)
+
+	private := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "core", Visibility: models.VisibilityPrivate}
+	public := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "sdk", Visibility: models.VisibilityPublic}
+	require.NoError(t, db.Create(private).Error)
+	require.NoError(t, db.Create(public).Error)
+	fork := &models.Repository{ID: uuid.New(), OwnerID: member, OwnerType: models.OwnerTypeUser, Name: "core",
+		Visibility: models.VisibilityPrivate, IsFork: true, ParentID: &private.ID}
+	require.NoError(t, db.Create(fork).Error)
+
+	ctx := context.Background()
+	svc := NewRepositoryPolicyService(db, logrus.New())
+
+	// Organizations without settings allow everything
+	policy, err := svc.Get(ctx, "acme")
+	require.NoError(t, err)
+	assert.Equal(t, defaultRepositoryPolicy, *policy)
+	require.NoError(t, checkForkPolicy(ctx, db, private, member, models.OwnerTypeUser))
+
+	_, err = svc.Update(ctx, "acme", member, UpdateRepositoryPolicyRequest{})
+	assert.ErrorIs(t, err, ErrRepositoryPolicyForbidden)
+	invalid := models.VisibilityChangePolicy("everyone")
+	_, err = svc.Update(ctx, "acme", admin, UpdateRepositoryPolicyRequest{VisibilityChangePolicy: &invalid})
+	assert.ErrorIs(t, err, ErrInvalidRepositoryPolicy)
+
+	disabled, owners := false, models.VisibilityChangeOwners
+	policy, err = svc.Update(ctx, "acme", admin, UpdateRepositoryPolicyRequest{
+		AllowPrivateForking:           &disabled,
+		AllowForksOutsideOrganization: &disabled,
+		VisibilityChangePolicy:        &owners,
+	})
+	require.NoError(t, err)
+	assert.True(t, policy.AllowForking)
+	assert.False(t, policy.AllowPrivateForking)
+	assert.False(t, policy.AllowForksOutsideOrganization)
+
+	// Forks are checked against the source organization's policy
+	assert.ErrorIs(t, checkForkPolicy(ctx, db, private, org.ID, models.OwnerTypeOrganization), ErrForkingRestricted)
+	assert.ErrorIs(t, checkForkPolicy(ctx, db, public, member, models.OwnerTypeUser), ErrForkingRestricted)
+	require.NoError(t, checkForkPolicy(ctx, db, public, org.ID, models.OwnerTypeOrganization))
+
+	compliance, err := svc.Compliance(ctx, "acme", owner)
+	require.NoError(t, err)
+	assert.False(t, compliance.Compliant)
+	require.Len(t, compliance.Violations, 1)
+	assert.Equal(t, PolicyRulePrivateForking, compliance.Violations[0].Rule)
+	assert.Equal(t, "acme/core", compliance.Violations[0].Repository)
+	assert.Equal(t, "mallory/core", compliance.Violations[0].Fork)
+
+	// Only owners may change visibility under the owners policy
+	asUser := func(userID uuid.UUID, permission models.Permission) context.Context {
+		return tenant.NewContext(ctx, &tenant.Context{UserID: &userID, Repository: private, Permission: permission})
+	}
+	assert.ErrorIs(t, checkVisibilityPolicy(asUser(admin, models.PermissionAdmin), db, private), ErrVisibilityChangeRestricted)
+	require.NoError(t, checkVisibilityPolicy(asUser(owner, models.PermissionAdmin), db, private))
+	require.NoError(t, checkVisibilityPolicy(ctx, db, private))
+
+	repoAdmins := models.VisibilityChangeRepositoryAdmins
+	_, err = svc.Update(ctx, "acme", owner, UpdateRepositoryPolicyRequest{VisibilityChangePolicy: &repoAdmins})
+	require.NoError(t, err)
+	require.NoError(t, checkVisibilityPolicy(asUser(member, models.PermissionAdmin), db, private))
+	assert.ErrorIs(t, checkVisibilityPolicy(asUser(member, models.PermissionWrite), db, private), ErrVisibilityChangeRestricted)
+}
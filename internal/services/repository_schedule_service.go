@@ -0,0 +1,397 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/cron"
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RepositoryScheduleService manages per-repository cron schedules and fires
+// a schedule event through the webhook system whenever one comes due
+type RepositoryScheduleService interface {
+	ListSchedules(ctx context.Context, repoID uuid.UUID) ([]*models.RepositorySchedule, error)
+	GetSchedule(ctx context.Context, repoID, scheduleID uuid.UUID) (*models.RepositorySchedule, error)
+	CreateSchedule(ctx context.Context, repoID uuid.UUID, createdBy *uuid.UUID, req RepositoryScheduleRequest) (*models.RepositorySchedule, error)
+	UpdateSchedule(ctx context.Context, repoID, scheduleID uuid.UUID, req RepositoryScheduleRequest) (*models.RepositorySchedule, error)
+	DeleteSchedule(ctx context.Context, repoID, scheduleID uuid.UUID) error
+	ListRuns(ctx context.Context, repoID, scheduleID uuid.UUID, limit int) ([]*models.RepositoryScheduleRun, error)
+
+	// Subscribe registers a listener called for every schedule that fires
+	Subscribe(listener ScheduleListener)
+	// RunDue fires every schedule whose next run has passed and returns how
+	// many fired
+	RunDue(ctx context.Context) (int, error)
+	StartScheduler(ctx context.Context)
+}
+
+// RepositoryScheduleRequest creates or updates a schedule
+type RepositoryScheduleRequest struct {
+	Name     *string `json:"name"`
+	Cron     *string `json:"cron"`
+	Timezone *string `json:"timezone"`
+	Ref      *string `json:"ref"`
+	Active   *bool   `json:"active"`
+}
+
+// ScheduleEvent describes one firing of a repository schedule
+type ScheduleEvent struct {
+	Schedule     *models.RepositorySchedule
+	ScheduledFor time.Time
+	Missed       int
+}
+
+// ScheduleListener reacts to a schedule firing
+type ScheduleListener func(ctx context.Context, event ScheduleEvent)
+
+var (
+	ErrScheduleNotFound = errors.New("schedule not found")
+	ErrInvalidSchedule  = errors.New("invalid schedule")
+)
+
+const (
+	// scheduleBatchSize bounds the due schedules claimed per scheduler tick
+	scheduleBatchSize = 100
+	// scheduleIntervalSpan is how far ahead the minimum interval between
+	// runs is checked, long enough to cover weekly patterns
+	scheduleIntervalSpan = 8 * 24 * time.Hour
+	// maxMissedCount caps how far back missed activations are counted
+	maxMissedCount = 1000
+)
+
+type repositoryScheduleService struct {
+	db       *gorm.DB
+	webhooks *WebhookDeliveryService
+	cfg      config.Schedules
+	logger   *logrus.Logger
+	now      func() time.Time
+
+	mu        sync.RWMutex
+	listeners []ScheduleListener
+}
+
+// NewRepositoryScheduleService creates a new RepositoryScheduleService
+func NewRepositoryScheduleService(db *gorm.DB, webhooks *WebhookDeliveryService, cfg config.Schedules, logger *logrus.Logger) RepositoryScheduleService {
+	return &repositoryScheduleService{
+		db:       db,
+		webhooks: webhooks,
+		cfg:      cfg,
+		logger:   logger,
+		now:      time.Now,
+	}
+}
+
+func (s *repositoryScheduleService) ListSchedules(ctx context.Context, repoID uuid.UUID) ([]*models.RepositorySchedule, error) {
+	var schedules []*models.RepositorySchedule
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repoID).Order("name ASC").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("failed to list schedules: %w", err)
+	}
+	return schedules, nil
+}
+
+func (s *repositoryScheduleService) GetSchedule(ctx context.Context, repoID, scheduleID uuid.UUID) (*models.RepositorySchedule, error) {
+	var schedule models.RepositorySchedule
+	if err := s.db.WithContext(ctx).Where("id = ? AND repository_id = ?", scheduleID, repoID).First(&schedule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrScheduleNotFound
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (s *repositoryScheduleService) CreateSchedule(ctx context.Context, repoID uuid.UUID, createdBy *uuid.UUID, req RepositoryScheduleRequest) (*models.RepositorySchedule, error) {
+	if req.Name == nil || req.Cron == nil {
+		return nil, fmt.Errorf("%w: name and cron are required", ErrInvalidSchedule)
+	}
+	if s.cfg.MaxPerRepository > 0 {
+		var count int64
+		if err := s.db.WithContext(ctx).Model(&models.RepositorySchedule{}).Where("repository_id = ?", repoID).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to count schedules: %w", err)
+		}
+		if count >= int64(s.cfg.MaxPerRepository) {
+			return nil, fmt.Errorf("%w: a repository can have at most %d schedules", ErrInvalidSchedule, s.cfg.MaxPerRepository)
+		}
+	}
+
+	schedule := &models.RepositorySchedule{
+		ID:           uuid.New(),
+		RepositoryID: repoID,
+		Timezone:     "UTC",
+		Active:       true,
+		CreatedByID:  createdBy,
+	}
+	if err := s.applyScheduleRequest(schedule, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(schedule).Error; err != nil {
+		return nil, fmt.Errorf("failed to create schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+func (s *repositoryScheduleService) UpdateSchedule(ctx context.Context, repoID, scheduleID uuid.UUID, req RepositoryScheduleRequest) (*models.RepositorySchedule, error) {
+	schedule, err := s.GetSchedule(ctx, repoID, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyScheduleRequest(schedule, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Save(schedule).Error; err != nil {
+		return nil, fmt.Errorf("failed to update schedule: %w", err)
+	}
+	return schedule, nil
+}
+
+func (s *repositoryScheduleService) DeleteSchedule(ctx context.Context, repoID, scheduleID uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND repository_id = ?", scheduleID, repoID).Delete(&models.RepositorySchedule{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to delete schedule: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return ErrScheduleNotFound
+		}
+		if err := tx.Where("schedule_id = ?", scheduleID).Delete(&models.RepositoryScheduleRun{}).Error; err != nil {
+			return fmt.Errorf("failed to delete schedule runs: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *repositoryScheduleService) ListRuns(ctx context.Context, repoID, scheduleID uuid.UUID, limit int) ([]*models.RepositoryScheduleRun, error) {
+	if _, err := s.GetSchedule(ctx, repoID, scheduleID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 30
+	}
+	var runs []*models.RepositoryScheduleRun
+	if err := s.db.WithContext(ctx).Where("schedule_id = ?", scheduleID).
+		Order("created_at DESC").Limit(limit).Find(&runs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list schedule runs: %w", err)
+	}
+	return runs, nil
+}
+
+// applyScheduleRequest validates the request onto schedule and recomputes
+// its next run
+func (s *repositoryScheduleService) applyScheduleRequest(schedule *models.RepositorySchedule, req RepositoryScheduleRequest) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || len(name) > 255 {
+			return fmt.Errorf("%w: name must be between 1 and 255 characters", ErrInvalidSchedule)
+		}
+		schedule.Name = name
+	}
+	if req.Cron != nil {
+		schedule.Cron = strings.Join(strings.Fields(*req.Cron), " ")
+	}
+	if req.Timezone != nil {
+		schedule.Timezone = strings.TrimSpace(*req.Timezone)
+		if schedule.Timezone == "" {
+			schedule.Timezone = "UTC"
+		}
+	}
+	if req.Ref != nil {
+		schedule.Ref = strings.TrimSpace(*req.Ref)
+	}
+	if req.Active != nil {
+		schedule.Active = *req.Active
+	}
+
+	expr, loc, err := s.parse(schedule)
+	if err != nil {
+		return err
+	}
+	now := s.now().In(loc)
+	if minInterval := time.Duration(s.cfg.MinIntervalMinutes) * time.Minute; minInterval > 0 {
+		if gap := expr.MinInterval(now, scheduleIntervalSpan); gap > 0 && gap < minInterval {
+			return fmt.Errorf("%w: schedules may run at most every %d minutes, %q runs %s apart",
+				ErrInvalidSchedule, s.cfg.MinIntervalMinutes, schedule.Cron, gap)
+		}
+	}
+
+	schedule.NextRunAt = nil
+	if schedule.Active {
+		next := expr.Next(now)
+		if next.IsZero() {
+			return fmt.Errorf("%w: %q never runs", ErrInvalidSchedule, schedule.Cron)
+		}
+		next = next.UTC()
+		schedule.NextRunAt = &next
+	}
+	return nil
+}
+
+// parse returns the cron expression and location of a schedule
+func (s *repositoryScheduleService) parse(schedule *models.RepositorySchedule) (*cron.Schedule, *time.Location, error) {
+	expr, err := cron.Parse(schedule.Cron)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidSchedule, schedule.Timezone)
+	}
+	return expr, loc, nil
+}
+
+func (s *repositoryScheduleService) Subscribe(listener ScheduleListener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *repositoryScheduleService) RunDue(ctx context.Context) (int, error) {
+	now := s.now()
+	var due []*models.RepositorySchedule
+	if err := s.db.WithContext(ctx).
+		Where("active = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Order("next_run_at ASC").Limit(scheduleBatchSize).Find(&due).Error; err != nil {
+		return 0, fmt.Errorf("failed to load due schedules: %w", err)
+	}
+
+	fired := 0
+	for _, schedule := range due {
+		ok, err := s.fire(ctx, schedule, now)
+		if err != nil {
+			s.logger.WithError(err).WithField("schedule_id", schedule.ID).Error("Failed to run schedule")
+			continue
+		}
+		if ok {
+			fired++
+		}
+	}
+	return fired, nil
+}
+
+// fire claims a due schedule by moving its next run forward, then emits its
+// event once however many activations were missed. It reports false when
+// another replica claimed the schedule first.
+func (s *repositoryScheduleService) fire(ctx context.Context, schedule *models.RepositorySchedule, now time.Time) (bool, error) {
+	scheduledFor := *schedule.NextRunAt
+	updates := map[string]interface{}{"last_run_at": now}
+
+	expr, loc, err := s.parse(schedule)
+	missed := 0
+	if err != nil {
+		// The expression or timezone stopped being valid, such as a removed
+		// zone; park the schedule rather than retrying every tick
+		updates["next_run_at"] = nil
+		updates["active"] = false
+	} else {
+		next := expr.Next(scheduledFor.In(loc))
+		for !next.IsZero() && !next.After(now) && missed < maxMissedCount {
+			missed++
+			next = expr.Next(next)
+		}
+		if next.IsZero() {
+			updates["next_run_at"] = nil
+		} else {
+			updates["next_run_at"] = next.UTC()
+		}
+	}
+
+	result := s.db.WithContext(ctx).Model(&models.RepositorySchedule{}).
+		Where("id = ? AND next_run_at = ?", schedule.ID, scheduledFor).
+		Updates(updates)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim schedule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	run := &models.RepositoryScheduleRun{
+		ID:           uuid.New(),
+		ScheduleID:   schedule.ID,
+		RepositoryID: schedule.RepositoryID,
+		ScheduledFor: scheduledFor,
+		Status:       models.ScheduleRunTriggered,
+		Missed:       missed,
+	}
+	if err == nil {
+		err = s.emit(ctx, ScheduleEvent{Schedule: schedule, ScheduledFor: scheduledFor, Missed: missed})
+	}
+	if err != nil {
+		run.Status = models.ScheduleRunFailed
+		run.Error = err.Error()
+	}
+
+	if err := s.db.WithContext(ctx).Create(run).Error; err != nil {
+		return true, fmt.Errorf("failed to record schedule run: %w", err)
+	}
+	if err := s.db.WithContext(ctx).Model(&models.RepositorySchedule{}).
+		Where("id = ?", schedule.ID).Update("last_status", run.Status).Error; err != nil {
+		return true, fmt.Errorf("failed to update schedule status: %w", err)
+	}
+	return true, nil
+}
+
+// emit delivers the schedule event to webhooks and starts every listener
+func (s *repositoryScheduleService) emit(ctx context.Context, event ScheduleEvent) error {
+	s.mu.RLock()
+	listeners := make([]ScheduleListener, len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.RUnlock()
+
+	tags := map[string]string{"repository_id": event.Schedule.RepositoryID.String()}
+	for _, listener := range listeners {
+		go func(l ScheduleListener) {
+			defer errorreporting.Default().Recover("schedule_listener", tags)
+			l(context.Background(), event)
+		}(listener)
+	}
+
+	if s.webhooks == nil {
+		return nil
+	}
+	return s.webhooks.TriggerWebhooks(ctx, event.Schedule.RepositoryID, "schedule", map[string]interface{}{
+		"action": "triggered",
+		"data": map[string]interface{}{
+			"schedule_id":   event.Schedule.ID.String(),
+			"name":          event.Schedule.Name,
+			"cron":          event.Schedule.Cron,
+			"timezone":      event.Schedule.Timezone,
+			"ref":           event.Schedule.Ref,
+			"scheduled_for": event.ScheduledFor.UTC().Format(time.RFC3339),
+			"missed":        event.Missed,
+		},
+	})
+}
+
+// StartScheduler fires due schedules every minute until ctx is cancelled. It
+// returns immediately when schedules are disabled.
+func (s *repositoryScheduleService) StartScheduler(ctx context.Context) {
+	if !s.cfg.Enabled {
+		return
+	}
+
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			func() {
+				defer errorreporting.Default().Recover("repository_schedules", nil)
+				if _, err := s.RunDue(ctx); err != nil {
+					s.logger.WithError(err).Error("Failed to run repository schedules")
+				}
+			}()
+		}
+	}
+}
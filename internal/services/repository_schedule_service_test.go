@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryScheduleService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.RepositorySchedule{}, &models.RepositoryScheduleRun{})
+
+	ctx := context.Background()
+	svc := NewRepositoryScheduleService(db, nil, config.Schedules{Enabled: true, MinIntervalMinutes: 5, MaxPerRepository: 2}, logrus.New()).(*repositoryScheduleService)
+	now := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	repoID := uuid.New()
+	str := func(s string) *string { return &s }
+
+	for _, tc := range []struct{ cron, timezone string }{
+		{"* * * * *", "UTC"},
+		{"0,2 * * * *", "UTC"},
+		{"0 9 * *", "UTC"},
+		{"0 9 * * *", "Mars/Olympus"},
+		{"0 0 30 2 *", "UTC"},
+	} {
+		_, err := svc.CreateSchedule(ctx, repoID, nil, RepositoryScheduleRequest{Name: str("bad"), Cron: str(tc.cron), Timezone: str(tc.timezone)})
+		assert.ErrorIs(t, err, ErrInvalidSchedule, tc.cron)
+	}
+
+	// 09:00 in Berlin is 08:00 UTC in winter
+	nightly, err := svc.CreateSchedule(ctx, repoID, nil, RepositoryScheduleRequest{Name: str("nightly"), Cron: str("0 9 * * *"), Timezone: str("Europe/Berlin")})
+	require.NoError(t, err)
+	require.NotNil(t, nightly.NextRunAt)
+	assert.Equal(t, time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC), *nightly.NextRunAt)
+
+	paused, err := svc.CreateSchedule(ctx, repoID, nil, RepositoryScheduleRequest{Name: str("paused"), Cron: str("@hourly"), Active: new(bool)})
+	require.NoError(t, err)
+	assert.Nil(t, paused.NextRunAt)
+
+	_, err = svc.CreateSchedule(ctx, repoID, nil, RepositoryScheduleRequest{Name: str("third"), Cron: str("@daily")})
+	assert.ErrorIs(t, err, ErrInvalidSchedule)
+
+	events := make(chan ScheduleEvent, 4)
+	svc.Subscribe(func(_ context.Context, event ScheduleEvent) { events <- event })
+
+	fired, err := svc.RunDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, fired)
+
+	// Three days later the schedule fires once, counting the runs it missed
+	now = time.Date(2024, 3, 4, 8, 30, 0, 0, time.UTC)
+	fired, err = svc.RunDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, fired)
+
+	select {
+	case event := <-events:
+		assert.Equal(t, nightly.ID, event.Schedule.ID)
+		assert.Equal(t, time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC), event.ScheduledFor.UTC())
+		assert.Equal(t, 2, event.Missed)
+	case <-time.After(time.Second):
+		t.Fatal("schedule listener was not called")
+	}
+
+	stored, err := svc.GetSchedule(ctx, repoID, nightly.ID)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 5, 8, 0, 0, 0, time.UTC), stored.NextRunAt.UTC())
+	assert.Equal(t, models.ScheduleRunTriggered, stored.LastStatus)
+
+	fired, err = svc.RunDue(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, fired)
+
+	runs, err := svc.ListRuns(ctx, repoID, nightly.ID, 0)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, 2, runs[0].Missed)
+
+	// Pausing clears the next run and resuming computes it again
+	_, err = svc.UpdateSchedule(ctx, repoID, nightly.ID, RepositoryScheduleRequest{Active: new(bool)})
+	require.NoError(t, err)
+	stored, err = svc.GetSchedule(ctx, repoID, nightly.ID)
+	require.NoError(t, err)
+	assert.Nil(t, stored.NextRunAt)
+
+	require.NoError(t, svc.DeleteSchedule(ctx, repoID, nightly.ID))
+	assert.ErrorIs(t, svc.DeleteSchedule(ctx, repoID, nightly.ID), ErrScheduleNotFound)
+	_, err = svc.ListRuns(ctx, repoID, nightly.ID, 0)
+	assert.ErrorIs(t, err, ErrScheduleNotFound)
+}
@@ -2,14 +2,17 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/a5c-ai/hub/internal/audit"
 	"github.com/a5c-ai/hub/internal/git"
 	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/tenant"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
@@ -38,6 +41,9 @@ type RepositoryService interface {
 
 	// Statistics and language detection
 	UpdateRepositoryStats(ctx context.Context, repoID uuid.UUID) error
+	// RefreshStaleRepositoryStats updates the statistics of up to limit
+	// repositories whose statistics are missing or older than maxAge
+	RefreshStaleRepositoryStats(ctx context.Context, maxAge time.Duration, limit int) (int, error)
 	GetLanguages(ctx context.Context, repoID uuid.UUID) (map[string]git.LanguageStats, error)
 	GetRepositoryStatistics(ctx context.Context, repoID uuid.UUID) (*git.RepositoryStats, error)
 
@@ -51,6 +57,9 @@ type RepositoryService interface {
 	CreateTemplate(ctx context.Context, repoID uuid.UUID, req CreateTemplateRequest) (*models.RepositoryTemplate, error)
 	GetTemplates(ctx context.Context, filters TemplateFilters) ([]*models.RepositoryTemplate, error)
 	UseTemplate(ctx context.Context, templateID uuid.UUID, req CreateRepositoryRequest) (*models.Repository, error)
+
+	// Storage maintenance
+	CleanupRepositoryStorage(ctx context.Context) error
 }
 
 // CreateRepositoryRequest represents a request to create a repository
@@ -71,6 +80,10 @@ type CreateRepositoryRequest struct {
 	AllowRebaseMerge    bool `json:"allow_rebase_merge"`
 	DeleteBranchOnMerge bool `json:"delete_branch_on_merge"`
 	AutoInit            bool `json:"auto_init"` // Initialize with README
+
+	// ImportFrom is a local repository whose branches and tags become the
+	// initial history instead of AutoInit's README
+	ImportFrom string `json:"-"`
 }
 
 // UpdateRepositoryRequest represents a request to update a repository
@@ -88,6 +101,20 @@ type UpdateRepositoryRequest struct {
 	AllowSquashMerge    *bool `json:"allow_squash_merge,omitempty"`
 	AllowRebaseMerge    *bool `json:"allow_rebase_merge,omitempty"`
 	DeleteBranchOnMerge *bool `json:"delete_branch_on_merge,omitempty"`
+	AutoCloseIssues     *bool `json:"auto_close_issues,omitempty"`
+
+	MergeCommitTitleTemplate    *string `json:"merge_commit_title_template,omitempty"`
+	MergeCommitMessageTemplate  *string `json:"merge_commit_message_template,omitempty"`
+	SquashCommitTitleTemplate   *string `json:"squash_commit_title_template,omitempty"`
+	SquashCommitMessageTemplate *string `json:"squash_commit_message_template,omitempty"`
+	PullRequestTitlePattern     *string `json:"pull_request_title_pattern,omitempty"`
+
+	AutoDeleteMergedBranches *bool `json:"auto_delete_merged_branches,omitempty"`
+	StaleBranchDays          *int  `json:"stale_branch_days,omitempty"`
+
+	// IfVersion, taken from the If-Match header, rejects the update with
+	// ErrVersionConflict unless it matches the current version
+	IfVersion *int64 `json:"-"`
 }
 
 // ForkRequest represents a request to fork a repository
@@ -158,21 +185,36 @@ type TemplateFilters struct {
 	PerPage    int    `json:"per_page,omitempty"`
 }
 
+// ErrRepositoryObjectsShared is returned when deleting a repository would
+// take objects that other repositories borrow with it
+var ErrRepositoryObjectsShared = errors.New("repository objects are borrowed by another repository")
+
 // repositoryService implements the RepositoryService interface
 type repositoryService struct {
 	db           *gorm.DB
 	gitService   git.GitService
 	logger       *logrus.Logger
 	repoBasePath string // Base path where repositories are stored
+	signingKeys  *signingKeyService
+	// pools shares objects between forks; nil when fork pools are disabled
+	pools *git.ObjectPools
 }
 
 // NewRepositoryService creates a new repository service
 func NewRepositoryService(db *gorm.DB, gitService git.GitService, logger *logrus.Logger, repoBasePath string) RepositoryService {
+	return NewRepositoryServiceWithObjectPools(db, gitService, nil, logger, repoBasePath)
+}
+
+// NewRepositoryServiceWithObjectPools creates a repository service whose
+// forks share objects through pools
+func NewRepositoryServiceWithObjectPools(db *gorm.DB, gitService git.GitService, pools *git.ObjectPools, logger *logrus.Logger, repoBasePath string) RepositoryService {
 	return &repositoryService{
 		db:           db,
 		gitService:   gitService,
 		logger:       logger,
 		repoBasePath: repoBasePath,
+		signingKeys:  &signingKeyService{db: db, logger: logger},
+		pools:        pools,
 	}
 }
 
@@ -189,6 +231,9 @@ func (s *repositoryService) Create(ctx context.Context, req CreateRepositoryRequ
 	if err := s.validateCreateRequest(req); err != nil {
 		return nil, err
 	}
+	if err := checkExternalOwner(ctx, s.db, req.OwnerID, req.OwnerType); err != nil {
+		return nil, err
+	}
 
 	// Check if repository already exists
 	var existing models.Repository
@@ -206,6 +251,7 @@ func (s *repositoryService) Create(ctx context.Context, req CreateRepositoryRequ
 
 	// Create repository model
 	repo := &models.Repository{
+		ID:            uuid.New(),
 		OwnerID:       req.OwnerID,
 		OwnerType:     req.OwnerType,
 		Name:          req.Name,
@@ -234,18 +280,27 @@ func (s *repositoryService) Create(ctx context.Context, req CreateRepositoryRequ
 		return nil, fmt.Errorf("failed to initialize Git repository: %w", err)
 	}
 
-	// Auto-initialize with README if requested
-	if req.AutoInit {
+	repoPath, err := s.GetRepositoryPath(ctx, repo.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository path for settings branch: %w", err)
+	}
+
+	switch {
+	case req.ImportFrom != "":
+		if err := s.gitService.ImportHistory(ctx, repoPath, req.ImportFrom); err != nil {
+			// Remove the repository for good so the import can be retried under the same name
+			s.gitService.DeleteRepository(ctx, repoPath)
+			s.db.Unscoped().Delete(repo)
+			return nil, fmt.Errorf("failed to import repository history: %w", err)
+		}
+	case req.AutoInit:
+		// Auto-initialize with README if requested
 		if err := s.createInitialCommit(ctx, repo); err != nil {
 			s.logger.WithError(err).Warn("Failed to create initial commit")
 		}
 	}
 
 	// Create 'settings' branch and default settings file
-	repoPath, err := s.GetRepositoryPath(ctx, repo.ID)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get repository path for settings branch: %w", err)
-	}
 	// Create settings branch from default branch
 	if err := s.gitService.CreateBranch(ctx, repoPath, "settings", repo.DefaultBranch); err != nil {
 		return nil, fmt.Errorf("failed to create settings branch: %w", err)
@@ -277,14 +332,21 @@ func (s *repositoryService) Create(ctx context.Context, req CreateRepositoryRequ
 
 // Get retrieves a repository by owner and name
 func (s *repositoryService) Get(ctx context.Context, owner, name string) (*models.Repository, error) {
+	// Reuse the repository already resolved by the tenant middleware for this request
+	if t, ok := tenant.FromContext(ctx); ok && t.MatchesRepository(owner, name) {
+		repo := *t.Repository
+		return &repo, nil
+	}
+
 	// First, resolve the owner name to owner ID and type
 	var ownerID uuid.UUID
 	var ownerType models.OwnerType
 	var ownerEntity *models.OwnerEntity
 
-	// Try to find a user with this username
+	// Try to find a user with this username. Names are matched
+	// case-insensitively, as tenant.MatchesRepository does
 	var user models.User
-	err := s.db.Where("username = ?", owner).First(&user).Error
+	err := s.db.Where("LOWER(username) = LOWER(?)", owner).First(&user).Error
 	if err == nil {
 		ownerID = user.ID
 		ownerType = models.OwnerTypeUser
@@ -300,7 +362,7 @@ func (s *repositoryService) Get(ctx context.Context, owner, name string) (*model
 	} else if err == gorm.ErrRecordNotFound {
 		// Try to find an organization with this name
 		var org models.Organization
-		err = s.db.Where("name = ?", owner).First(&org).Error
+		err = s.db.Where("LOWER(name) = LOWER(?)", owner).First(&org).Error
 		if err == nil {
 			ownerID = org.ID
 			ownerType = models.OwnerTypeOrganization
@@ -324,7 +386,7 @@ func (s *repositoryService) Get(ctx context.Context, owner, name string) (*model
 
 	// Now find the repository with the resolved owner ID
 	var repo models.Repository
-	err = s.db.Where("owner_id = ? AND owner_type = ? AND name = ?", ownerID, ownerType, name).First(&repo).Error
+	err = s.db.Where("owner_id = ? AND owner_type = ? AND LOWER(name) = LOWER(?)", ownerID, ownerType, name).First(&repo).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return nil, fmt.Errorf("repository not found")
@@ -393,6 +455,9 @@ func (s *repositoryService) Update(ctx context.Context, id uuid.UUID, req Update
 	if err != nil {
 		return nil, err
 	}
+	if err := checkVersion(repo.Version, req.IfVersion); err != nil {
+		return nil, err
+	}
 
 	// Update fields if provided
 	updates := make(map[string]interface{})
@@ -405,7 +470,10 @@ func (s *repositoryService) Update(ctx context.Context, id uuid.UUID, req Update
 	if req.DefaultBranch != nil {
 		updates["default_branch"] = *req.DefaultBranch
 	}
-	if req.Visibility != nil {
+	if req.Visibility != nil && *req.Visibility != repo.Visibility {
+		if err := checkVisibilityPolicy(ctx, s.db, repo); err != nil {
+			return nil, err
+		}
 		updates["visibility"] = *req.Visibility
 	}
 	if req.IsTemplate != nil {
@@ -433,12 +501,55 @@ func (s *repositoryService) Update(ctx context.Context, id uuid.UUID, req Update
 	if req.DeleteBranchOnMerge != nil {
 		updates["delete_branch_on_merge"] = *req.DeleteBranchOnMerge
 	}
+	if req.AutoCloseIssues != nil {
+		updates["auto_close_issues"] = *req.AutoCloseIssues
+	}
+	for column, template := range map[string]*string{
+		"merge_commit_title_template":    req.MergeCommitTitleTemplate,
+		"merge_commit_message_template":  req.MergeCommitMessageTemplate,
+		"squash_commit_title_template":   req.SquashCommitTitleTemplate,
+		"squash_commit_message_template": req.SquashCommitMessageTemplate,
+	} {
+		if template == nil {
+			continue
+		}
+		if err := ValidateMergeTemplate(*template); err != nil {
+			return nil, err
+		}
+		updates[column] = *template
+	}
+	if req.PullRequestTitlePattern != nil {
+		if err := ValidateTitlePattern(*req.PullRequestTitlePattern); err != nil {
+			return nil, err
+		}
+		updates["pull_request_title_pattern"] = *req.PullRequestTitlePattern
+	}
+	if req.AutoDeleteMergedBranches != nil {
+		updates["auto_delete_merged_branches"] = *req.AutoDeleteMergedBranches
+	}
+	if req.StaleBranchDays != nil {
+		if *req.StaleBranchDays < 1 || *req.StaleBranchDays > 3650 {
+			return nil, fmt.Errorf("%w: stale_branch_days must be between 1 and 3650", ErrInvalidBranchCleanup)
+		}
+		updates["stale_branch_days"] = *req.StaleBranchDays
+	}
 
 	if len(updates) > 0 {
 		updates["updated_at"] = time.Now()
-		if err := s.db.Model(repo).Updates(updates).Error; err != nil {
+		if err := updateVersioned(s.db.Model(&models.Repository{}).Where("id = ?", repo.ID), updates, req.IfVersion); err != nil {
+			if errors.Is(err, ErrVersionConflict) {
+				return nil, err
+			}
 			return nil, fmt.Errorf("failed to update repository: %w", err)
 		}
+		updated, err := s.GetByID(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if t, ok := tenant.FromContext(ctx); ok {
+			t.RefreshRepository(updated)
+		}
+		return updated, nil
 	}
 
 	return repo, nil
@@ -454,6 +565,9 @@ func (s *repositoryService) Delete(ctx context.Context, id uuid.UUID) error {
 	// Delete Git repository from filesystem
 	repoPath, err := s.GetRepositoryPath(ctx, id)
 	if err == nil {
+		if err := s.releaseSharedObjects(ctx, repo, repoPath); err != nil {
+			return err
+		}
 		if err := s.gitService.DeleteRepository(ctx, repoPath); err != nil {
 			s.logger.WithError(err).Warn("Failed to delete Git repository from filesystem")
 		}
@@ -464,6 +578,18 @@ func (s *repositoryService) Delete(ctx context.Context, id uuid.UUID) error {
 		return fmt.Errorf("failed to delete repository: %w", err)
 	}
 
+	targetName := repo.Name
+	if repo.Owner != nil {
+		targetName = repo.Owner.Username + "/" + repo.Name
+	}
+	audit.Record(ctx, audit.Event{
+		Action:       audit.ActionRepositoryDelete,
+		RepositoryID: &repo.ID,
+		TargetType:   "repository",
+		TargetID:     repo.ID.String(),
+		TargetName:   targetName,
+	})
+
 	return nil
 }
 
@@ -585,8 +711,13 @@ func (s *repositoryService) GetRepositoryPath(ctx context.Context, repoID uuid.U
 		return "", err
 	}
 
-	// Generate path: /repos/{owner_type}/{owner_id}/{repo_name}.git
-	return filepath.Join(s.repoBasePath, string(repo.OwnerType), repo.OwnerID.String(), repo.Name+".git"), nil
+	return s.pathOf(repo), nil
+}
+
+// pathOf returns where a repository is stored:
+// /repos/{owner_type}/{owner_id}/{repo_name}.git
+func (s *repositoryService) pathOf(repo *models.Repository) string {
+	return filepath.Join(s.repoBasePath, string(repo.OwnerType), repo.OwnerID.String(), repo.Name+".git")
 }
 
 // Helper methods
@@ -615,8 +746,10 @@ func (s *repositoryService) validateForkRequest(ctx context.Context, sourceRepo
 		return fmt.Errorf("owner_type is required")
 	}
 
-	// Check if trying to fork to the same owner
-	if sourceRepo.OwnerID == req.OwnerID && sourceRepo.OwnerType == req.OwnerType {
+	// Check if trying to fork to the same owner; organizations may keep
+	// forks in-house under a new name
+	sameOwner := sourceRepo.OwnerID == req.OwnerID && sourceRepo.OwnerType == req.OwnerType
+	if sameOwner && (req.OwnerType != models.OwnerTypeOrganization || req.Name == "" || req.Name == sourceRepo.Name) {
 		return fmt.Errorf("cannot fork repository to the same owner")
 	}
 
@@ -754,6 +887,59 @@ func (s *repositoryService) cloneRepository(ctx context.Context, sourceRepo, for
 	return nil
 }
 
+// createForkStorage gives a fork the objects of its source through the
+// fork network's object pool, falling back to a full clone when pools are
+// disabled or sharing fails
+func (s *repositoryService) createForkStorage(ctx context.Context, sourceRepo, forkRepo *models.Repository) error {
+	pools := s.pools
+	if pools == nil {
+		return s.cloneRepository(ctx, sourceRepo, forkRepo)
+	}
+
+	sourcePath, forkPath := s.pathOf(sourceRepo), s.pathOf(forkRepo)
+	network, ok := pools.PoolOf(sourcePath)
+	if !ok {
+		network = sourceRepo.ID.String()
+	}
+	err := pools.Join(ctx, sourcePath, network, sourceRepo.ID.String())
+	if err == nil {
+		if err = os.MkdirAll(filepath.Dir(forkPath), 0755); err == nil {
+			err = pools.Fork(ctx, sourcePath, forkPath, forkRepo.ID.String())
+		}
+	}
+	if err == nil {
+		s.logger.WithFields(logrus.Fields{
+			"network":   network,
+			"fork_path": forkPath,
+		}).Info("Fork shares objects through the network pool")
+		return nil
+	}
+
+	s.logger.WithError(err).WithField("fork_path", forkPath).Warn("Failed to share objects with fork, cloning instead")
+	if err := os.RemoveAll(forkPath); err != nil {
+		return fmt.Errorf("failed to clean up fork repository: %w", err)
+	}
+	return s.cloneRepository(ctx, sourceRepo, forkRepo)
+}
+
+// releaseSharedObjects makes sure no fork borrows objects straight from a
+// repository about to be deleted, then takes it out of its network's pool
+func (s *repositoryService) releaseSharedObjects(ctx context.Context, repo *models.Repository, repoPath string) error {
+	var forks []*models.Repository
+	if err := s.db.WithContext(ctx).Where("parent_id = ?", repo.ID).Find(&forks).Error; err != nil {
+		return fmt.Errorf("failed to list forks: %w", err)
+	}
+	for _, fork := range forks {
+		if git.BorrowsFrom(s.pathOf(fork), repoPath) {
+			return fmt.Errorf("%w: fork %s", ErrRepositoryObjectsShared, fork.Name)
+		}
+	}
+	if err := s.pools.Leave(ctx, repoPath, repo.ID.String()); err != nil {
+		return fmt.Errorf("failed to leave object pool: %w", err)
+	}
+	return nil
+}
+
 func (s *repositoryService) createInitialCommit(ctx context.Context, repo *models.Repository) error {
 	repoPath, err := s.GetRepositoryPath(ctx, repo.ID)
 	if err != nil {
@@ -763,10 +949,10 @@ func (s *repositoryService) createInitialCommit(ctx context.Context, repo *model
 	// Create README content
 	readmeContent := fmt.Sprintf("# %s\n\n%s\n", repo.Name, repo.Description)
 
-	// Create initial commit with README
-	createReq := git.CreateFileRequest{
-		Path:    "README.md",
-		Content: readmeContent,
+	// Create initial commit with README. CommitFiles is used because the
+	// default branch does not exist yet
+	_, err = s.gitService.CommitFiles(ctx, repoPath, git.CommitFilesRequest{
+		Files:   []git.CommitFileEntry{{Path: "README.md", Content: []byte(readmeContent)}},
 		Message: "Initial commit",
 		Branch:  repo.DefaultBranch,
 		Author: git.CommitAuthor{
@@ -774,9 +960,7 @@ func (s *repositoryService) createInitialCommit(ctx context.Context, repo *model
 			Email: "noreply@hub.local",
 			Date:  time.Now(),
 		},
-	}
-
-	_, err = s.gitService.CreateFile(ctx, repoPath, createReq)
+	})
 	return err
 }
 
@@ -800,6 +984,12 @@ func (s *repositoryService) Fork(ctx context.Context, id uuid.UUID, req ForkRequ
 	if err := s.validateForkRequest(ctx, sourceRepo, req); err != nil {
 		return nil, err
 	}
+	if err := checkForkPolicy(ctx, s.db, sourceRepo, req.OwnerID, req.OwnerType); err != nil {
+		return nil, err
+	}
+	if err := checkExternalOwner(ctx, s.db, req.OwnerID, req.OwnerType); err != nil {
+		return nil, err
+	}
 
 	// Set fork name (default to source repo name if not provided)
 	forkName := req.Name
@@ -818,6 +1008,7 @@ func (s *repositoryService) Fork(ctx context.Context, id uuid.UUID, req ForkRequ
 
 	// Create fork repository in database
 	fork := &models.Repository{
+		ID:            uuid.New(),
 		OwnerID:       req.OwnerID,
 		OwnerType:     req.OwnerType,
 		Name:          forkName,
@@ -841,8 +1032,8 @@ func (s *repositoryService) Fork(ctx context.Context, id uuid.UUID, req ForkRequ
 		return nil, fmt.Errorf("failed to create fork in database: %w", err)
 	}
 
-	// Clone the Git repository
-	if err := s.cloneRepository(ctx, sourceRepo, fork); err != nil {
+	// Share or clone the Git repository
+	if err := s.createForkStorage(ctx, sourceRepo, fork); err != nil {
 		// Rollback database changes if Git cloning fails
 		s.db.Delete(fork)
 		return nil, fmt.Errorf("failed to clone Git repository: %w", err)
@@ -878,6 +1069,9 @@ func (s *repositoryService) Transfer(ctx context.Context, id uuid.UUID, req Tran
 	if err := s.validateTransferRequest(ctx, repo, req); err != nil {
 		return err
 	}
+	if err := checkExternalOwner(ctx, s.db, req.NewOwnerID, req.NewOwnerType); err != nil {
+		return err
+	}
 
 	// Check if a repository with the same name already exists for the new owner
 	var existing models.Repository
@@ -931,6 +1125,12 @@ func (s *repositoryService) Transfer(ctx context.Context, id uuid.UUID, req Tran
 		}
 	}
 
+	if t, ok := tenant.FromContext(ctx); ok {
+		if transferred, err := s.GetByID(ctx, id); err == nil {
+			t.RefreshRepository(transferred)
+		}
+	}
+
 	s.logger.WithFields(logrus.Fields{
 		"repo_id":  id,
 		"old_path": oldRepoPath,
@@ -1057,6 +1257,7 @@ func (s *repositoryService) syncCommitBatch(ctx context.Context, repoID uuid.UUI
 		}
 
 		newCommit := models.Commit{
+			ID:             uuid.New(),
 			RepositoryID:   repoID,
 			SHA:            gitCommit.SHA,
 			Message:        gitCommit.Message,
@@ -1072,6 +1273,10 @@ func (s *repositoryService) syncCommitBatch(ctx context.Context, repoID uuid.UUI
 			Deletions:      deletions,
 			Changes:        changes,
 		}
+		verification, signerID := s.signingKeys.verify(ctx, gitCommit.Signature, gitCommit.Committer.Email)
+		newCommit.Verified = verification.Verified
+		newCommit.VerificationReason = verification.Reason
+		newCommit.SignerID = signerID
 
 		newCommits = append(newCommits, newCommit)
 	}
@@ -1088,6 +1293,46 @@ func (s *repositoryService) syncCommitBatch(ctx context.Context, repoID uuid.UUI
 		}).Debug("Inserted new commits to database")
 	}
 
+	return s.syncCommitTrailers(ctx, existingCommits, newCommits)
+}
+
+// syncCommitTrailers records the message trailers of new commits, and of
+// existing commits synced before trailers were recorded
+func (s *repositoryService) syncCommitTrailers(ctx context.Context, existing, created []models.Commit) error {
+	var trailers []models.CommitTrailer
+	if len(existing) > 0 {
+		ids := make([]uuid.UUID, len(existing))
+		for i := range existing {
+			ids[i] = existing[i].ID
+		}
+		var recorded []uuid.UUID
+		if err := s.db.WithContext(ctx).Model(&models.CommitTrailer{}).Where("commit_id IN ?", ids).
+			Distinct().Pluck("commit_id", &recorded).Error; err != nil {
+			return fmt.Errorf("failed to check commit trailers: %w", err)
+		}
+		done := make(map[uuid.UUID]bool, len(recorded))
+		for _, id := range recorded {
+			done[id] = true
+		}
+		for i := range existing {
+			if !done[existing[i].ID] {
+				trailers = append(trailers, commitTrailerRows(&existing[i])...)
+			}
+		}
+	}
+	for i := range created {
+		trailers = append(trailers, commitTrailerRows(&created[i])...)
+	}
+
+	if len(trailers) == 0 {
+		return nil
+	}
+	for i := range trailers {
+		trailers[i].ID = uuid.New()
+	}
+	if err := s.db.WithContext(ctx).CreateInBatches(trailers, 100).Error; err != nil {
+		return fmt.Errorf("failed to insert commit trailers: %w", err)
+	}
 	return nil
 }
 
@@ -1157,6 +1402,33 @@ func (s *repositoryService) UpdateRepositoryStats(ctx context.Context, repoID uu
 	return nil
 }
 
+// RefreshStaleRepositoryStats updates the statistics of up to limit
+// repositories, those never computed first and then the oldest ones. A
+// repository that fails is logged and skipped so it cannot stall the rest.
+func (s *repositoryService) RefreshStaleRepositoryStats(ctx context.Context, maxAge time.Duration, limit int) (int, error) {
+	var repoIDs []uuid.UUID
+	if err := s.db.WithContext(ctx).Model(&models.Repository{}).
+		Joins("LEFT JOIN repository_statistics ON repository_statistics.repository_id = repositories.id AND repository_statistics.deleted_at IS NULL").
+		Where("repository_statistics.id IS NULL OR repository_statistics.updated_at < ?", time.Now().Add(-maxAge)).
+		Order("repository_statistics.updated_at IS NOT NULL, repository_statistics.updated_at").
+		Limit(limit).Pluck("repositories.id", &repoIDs).Error; err != nil {
+		return 0, fmt.Errorf("failed to find stale repository statistics: %w", err)
+	}
+
+	refreshed := 0
+	for _, repoID := range repoIDs {
+		if err := ctx.Err(); err != nil {
+			return refreshed, err
+		}
+		if err := s.UpdateRepositoryStats(ctx, repoID); err != nil {
+			s.logger.WithError(err).WithField("repo_id", repoID).Warn("Failed to refresh repository statistics")
+			continue
+		}
+		refreshed++
+	}
+	return refreshed, nil
+}
+
 // GetLanguages returns the programming languages used in a repository
 func (s *repositoryService) GetLanguages(ctx context.Context, repoID uuid.UUID) (map[string]git.LanguageStats, error) {
 	var languages []models.RepositoryLanguage
@@ -1235,7 +1507,27 @@ func (s *repositoryService) updateRepositoryLanguages(ctx context.Context, repoI
 		}
 	}
 
-	return nil
+	// Keep one snapshot per day for language trends
+	now := time.Now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("repository_id = ? AND date = ?", repoID, day).Delete(&models.RepositoryLanguageSnapshot{}).Error; err != nil {
+			return fmt.Errorf("failed to replace language snapshot: %w", err)
+		}
+		for language, stats := range languages {
+			snapshot := models.RepositoryLanguageSnapshot{
+				ID:           uuid.New(),
+				RepositoryID: repoID,
+				Date:         day,
+				Language:     language,
+				Bytes:        stats.Bytes,
+			}
+			if err := tx.Create(&snapshot).Error; err != nil {
+				return fmt.Errorf("failed to record language snapshot for %s: %w", language, err)
+			}
+		}
+		return nil
+	})
 }
 
 // Git hooks management methods
@@ -1626,6 +1918,10 @@ func (s *repositoryService) setupRepositoryHooks(ctx context.Context, repoPath s
 	return nil
 }
 
+// orphanedStorageGracePeriod keeps directories modified this recently, so
+// a repository that is being created is not mistaken for an orphan
+const orphanedStorageGracePeriod = time.Hour
+
 // CleanupRepositoryStorage removes orphaned repository directories
 func (s *repositoryService) CleanupRepositoryStorage(ctx context.Context) error {
 	s.logger.Info("Starting repository storage cleanup")
@@ -1635,21 +1931,29 @@ func (s *repositoryService) CleanupRepositoryStorage(ctx context.Context) error
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
 		// Skip if not a .git directory
 		if !info.IsDir() || !strings.HasSuffix(path, ".git") {
 			return nil
 		}
+		// Repositories are never descended into; recent ones may still be
+		// being created
+		if info.ModTime().After(time.Now().Add(-orphanedStorageGracePeriod)) {
+			return filepath.SkipDir
+		}
 
 		// Extract repository info from path
 		relPath, err := filepath.Rel(s.repoBasePath, path)
 		if err != nil {
-			return nil // Skip this path
+			return filepath.SkipDir
 		}
 
 		parts := strings.Split(relPath, string(filepath.Separator))
 		if len(parts) != 3 { // owner_type/owner_id/repo_name.git
-			return nil // Skip malformed paths
+			return filepath.SkipDir // Skip malformed paths
 		}
 
 		ownerType := parts[0]
@@ -1659,17 +1963,17 @@ func (s *repositoryService) CleanupRepositoryStorage(ctx context.Context) error
 		// Parse owner ID
 		ownerID, err := uuid.Parse(ownerIDStr)
 		if err != nil {
-			return nil // Skip invalid UUIDs
+			return filepath.SkipDir // Skip invalid UUIDs
 		}
 
 		// Check if repository exists in database
 		var count int64
-		err = s.db.Model(&models.Repository{}).
+		err = s.db.WithContext(ctx).Model(&models.Repository{}).
 			Where("owner_id = ? AND owner_type = ? AND name = ?", ownerID, ownerType, repoName).
 			Count(&count).Error
 		if err != nil {
 			s.logger.WithError(err).Warn("Failed to check repository existence")
-			return nil
+			return filepath.SkipDir
 		}
 
 		// Remove orphaned directory
@@ -1680,7 +1984,7 @@ func (s *repositoryService) CleanupRepositoryStorage(ctx context.Context) error
 			}
 		}
 
-		return nil
+		return filepath.SkipDir
 	})
 
 	if err != nil {
@@ -0,0 +1,469 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RepositoryStatsService serves repository statistics computed in the
+// background from synced commit data. Reads never compute: a missing or
+// stale cache schedules a refresh and reports the stats as not ready.
+type RepositoryStatsService interface {
+	// CodeFrequency returns weekly [week, additions, -deletions] tuples
+	CodeFrequency(ctx context.Context, repoID uuid.UUID) ([][3]int64, bool, error)
+	// Participation returns weekly commit counts for the last 52 weeks
+	Participation(ctx context.Context, repoID uuid.UUID) (*Participation, bool, error)
+	// Summary returns the dashboard card numbers of a repository. Unlike the
+	// other stats, a stale summary is still served while it is refreshed.
+	Summary(ctx context.Context, repoID uuid.UUID) (*InsightsSummary, bool, error)
+	Refresh(ctx context.Context, repoID uuid.UUID) error
+	RefreshSummary(ctx context.Context, repoID uuid.UUID) error
+	// AuthorCodeFrequency returns the weekly additions, deletions and
+	// commits of each author of a repository between since and until
+	AuthorCodeFrequency(ctx context.Context, repoID uuid.UUID, since, until time.Time) (*AuthorCodeFrequency, bool, error)
+	// OrganizationAuthorCodeFrequency is AuthorCodeFrequency across the
+	// repositories of an organization, for its owners and admins
+	OrganizationAuthorCodeFrequency(ctx context.Context, orgName string, actorID uuid.UUID, since, until time.Time) (*AuthorCodeFrequency, bool, error)
+
+	// HandlePush is a PushListener that re-syncs commits and refreshes stats
+	// when the default branch moves
+	HandlePush(ctx context.Context, event PushEvent)
+	// UseQueue runs the refreshes HandlePush triggers as queued jobs, which
+	// are retried when they fail and survive restarts
+	UseQueue(queue *jobs.Queue)
+}
+
+// JobRefreshRepositoryStats is the queued job kind HandlePush enqueues
+const JobRefreshRepositoryStats = "repository.refresh_stats"
+
+// Participation splits weekly commit counts between the repository owner
+// and everyone, oldest week first
+type Participation struct {
+	All   []int `json:"all"`
+	Owner []int `json:"owner"`
+}
+
+// InsightsSummary is a compact set of pre-aggregated repository numbers for
+// dashboard cards. Deltas and counts cover the last summaryWindow.
+type InsightsSummary struct {
+	Stars            int             `json:"stars"`
+	StarsDelta       int64           `json:"stars_delta"`
+	OpenIssues       int64           `json:"open_issues"`
+	OpenPullRequests int64           `json:"open_pull_requests"`
+	WeeklyCommits    int64           `json:"weekly_commits"`
+	LastRelease      *SummaryRelease `json:"last_release"`
+	CI               *SummaryCI      `json:"ci"`
+	ComputedAt       time.Time       `json:"computed_at"`
+}
+
+// SummaryRelease is the latest published, non-prerelease release
+type SummaryRelease struct {
+	TagName     string     `json:"tag_name"`
+	Name        string     `json:"name"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+// SummaryCI is the latest workflow run on the default branch
+type SummaryCI struct {
+	RunNumber  int                       `json:"run_number"`
+	HeadSHA    string                    `json:"head_sha"`
+	Status     models.WorkflowStatus     `json:"status"`
+	Conclusion models.WorkflowConclusion `json:"conclusion,omitempty"`
+	UpdatedAt  time.Time                 `json:"updated_at"`
+}
+
+// participationWeeks is the window covered by participation stats
+const participationWeeks = 52
+
+// statsCacheTTL bounds how long cached stats are served; the rolling
+// participation window moves even without pushes
+const statsCacheTTL = 24 * time.Hour
+
+// summaryCacheTTL bounds how long an insights summary is served before a
+// refresh is scheduled; issue and pull request counts change without pushes
+const summaryCacheTTL = 5 * time.Minute
+
+// summaryWindow is the period the summary deltas and counts cover
+const summaryWindow = 7 * 24 * time.Hour
+
+type repositoryStatsService struct {
+	db                *gorm.DB
+	repositoryService RepositoryService
+	logger            *logrus.Logger
+	now               func() time.Time
+	queue             *jobs.Queue
+
+	mu       sync.Mutex
+	inFlight map[string]bool
+}
+
+// NewRepositoryStatsService creates a new RepositoryStatsService
+func NewRepositoryStatsService(db *gorm.DB, repositoryService RepositoryService, logger *logrus.Logger) RepositoryStatsService {
+	return &repositoryStatsService{
+		db:                db,
+		repositoryService: repositoryService,
+		logger:            logger,
+		now:               time.Now,
+		inFlight:          make(map[string]bool),
+	}
+}
+
+func (s *repositoryStatsService) CodeFrequency(ctx context.Context, repoID uuid.UUID) ([][3]int64, bool, error) {
+	var weeks [][3]int64
+	ready, err := s.cached(ctx, repoID, models.StatsKindCodeFrequency, &weeks)
+	return weeks, ready, err
+}
+
+func (s *repositoryStatsService) Participation(ctx context.Context, repoID uuid.UUID) (*Participation, bool, error) {
+	var participation Participation
+	ready, err := s.cached(ctx, repoID, models.StatsKindParticipation, &participation)
+	if !ready || err != nil {
+		return nil, ready, err
+	}
+	return &participation, true, nil
+}
+
+func (s *repositoryStatsService) Summary(ctx context.Context, repoID uuid.UUID) (*InsightsSummary, bool, error) {
+	var entry models.RepositoryStatsCache
+	err := s.db.WithContext(ctx).Where("repository_id = ? AND kind = ?", repoID, models.StatsKindInsightsSummary).First(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.refreshAsync(repoID, models.StatsKindInsightsSummary, s.RefreshSummary)
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load insights summary: %w", err)
+	}
+	if s.now().Sub(entry.ComputedAt) > summaryCacheTTL {
+		s.refreshAsync(repoID, models.StatsKindInsightsSummary, s.RefreshSummary)
+	}
+
+	var summary InsightsSummary
+	if err := json.Unmarshal([]byte(entry.Data), &summary); err != nil {
+		return nil, false, fmt.Errorf("failed to decode insights summary: %w", err)
+	}
+	return &summary, true, nil
+}
+
+// cached decodes a fresh cache entry into dest, or schedules a refresh and
+// returns false
+func (s *repositoryStatsService) cached(ctx context.Context, repoID uuid.UUID, kind string, dest interface{}) (bool, error) {
+	var entry models.RepositoryStatsCache
+	err := s.db.WithContext(ctx).Where("repository_id = ? AND kind = ?", repoID, kind).First(&entry).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, fmt.Errorf("failed to load %s stats: %w", kind, err)
+	}
+	if err != nil || s.now().Sub(entry.ComputedAt) > statsCacheTTL {
+		s.refreshAsync(repoID, "stats", s.Refresh)
+		return false, nil
+	}
+
+	if err := json.Unmarshal([]byte(entry.Data), dest); err != nil {
+		return false, fmt.Errorf("failed to decode %s stats: %w", kind, err)
+	}
+	return true, nil
+}
+
+// refreshAsync runs refresh for a repository in the background, coalescing
+// concurrent requests for the same repository and job
+func (s *repositoryStatsService) refreshAsync(repoID uuid.UUID, job string, refresh func(context.Context, uuid.UUID) error) {
+	key := job + ":" + repoID.String()
+	s.mu.Lock()
+	if s.inFlight[key] {
+		s.mu.Unlock()
+		return
+	}
+	s.inFlight[key] = true
+	s.mu.Unlock()
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.inFlight, key)
+			s.mu.Unlock()
+		}()
+		defer errorreporting.Default().Recover("repository_stats", map[string]string{"repository_id": repoID.String(), "job": job})
+		if err := refresh(context.Background(), repoID); err != nil {
+			s.logger.WithError(err).WithFields(logrus.Fields{"repository_id": repoID, "job": job}).Warn("Failed to compute repository stats")
+		}
+	}()
+}
+
+// statsCommit is the subset of a synced commit the stats are built from
+type statsCommit struct {
+	AuthorName  string
+	AuthorEmail string
+	AuthorDate  time.Time
+	Additions   int
+	Deletions   int
+}
+
+// Refresh recomputes and stores every statistic of a repository
+func (s *repositoryStatsService) Refresh(ctx context.Context, repoID uuid.UUID) error {
+	var commits []statsCommit
+	err := s.db.WithContext(ctx).Model(&models.Commit{}).
+		Select("author_name, author_email, author_date, additions, deletions").
+		Where("repository_id = ?", repoID).
+		Order("author_date ASC").
+		Find(&commits).Error
+	if err != nil {
+		return fmt.Errorf("failed to load commits: %w", err)
+	}
+
+	ownerEmails, err := s.ownerEmails(ctx, repoID)
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	return s.store(ctx, repoID, now, map[string]interface{}{
+		models.StatsKindCodeFrequency:   computeCodeFrequency(commits),
+		models.StatsKindParticipation:   computeParticipation(commits, ownerEmails, now),
+		models.StatsKindAuthorFrequency: computeAuthorFrequency(commits),
+	})
+}
+
+// RefreshSummary recomputes and stores the insights summary of a repository
+// with a handful of indexed counts
+func (s *repositoryStatsService) RefreshSummary(ctx context.Context, repoID uuid.UUID) error {
+	var repo models.Repository
+	if err := s.db.WithContext(ctx).Select("id, default_branch, stars_count").First(&repo, "id = ?", repoID).Error; err != nil {
+		return fmt.Errorf("failed to load repository: %w", err)
+	}
+
+	now := s.now()
+	since := now.Add(-summaryWindow)
+	db := s.db.WithContext(ctx)
+	summary := &InsightsSummary{Stars: repo.StarsCount, ComputedAt: now}
+
+	// Unstarring soft-deletes, so the net change is stars added minus stars
+	// removed within the window
+	var starred, unstarred int64
+	if err := db.Unscoped().Model(&models.Star{}).Where("repository_id = ? AND created_at >= ?", repoID, since).Count(&starred).Error; err != nil {
+		return fmt.Errorf("failed to count stars: %w", err)
+	}
+	if err := db.Unscoped().Model(&models.Star{}).Where("repository_id = ? AND deleted_at >= ?", repoID, since).Count(&unstarred).Error; err != nil {
+		return fmt.Errorf("failed to count stars: %w", err)
+	}
+	summary.StarsDelta = starred - unstarred
+
+	if err := db.Model(&models.Issue{}).Where("repository_id = ? AND state = ?", repoID, models.IssueStateOpen).Count(&summary.OpenIssues).Error; err != nil {
+		return fmt.Errorf("failed to count open issues: %w", err)
+	}
+	if err := db.Model(&models.PullRequest{}).Where("repository_id = ? AND state = ?", repoID, models.PullRequestStateOpen).Count(&summary.OpenPullRequests).Error; err != nil {
+		return fmt.Errorf("failed to count open pull requests: %w", err)
+	}
+	if err := db.Model(&models.Commit{}).Where("repository_id = ? AND author_date >= ?", repoID, since).Count(&summary.WeeklyCommits).Error; err != nil {
+		return fmt.Errorf("failed to count commits: %w", err)
+	}
+
+	var release models.Release
+	err := db.Where("repository_id = ? AND draft = ? AND prerelease = ?", repoID, false, false).
+		Order("published_at DESC, created_at DESC").First(&release).Error
+	switch {
+	case err == nil:
+		summary.LastRelease = &SummaryRelease{TagName: release.TagName, Name: release.Name, PublishedAt: release.PublishedAt}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("failed to load latest release: %w", err)
+	}
+
+	var run models.WorkflowRun
+	err = db.Where("repository_id = ? AND head_branch = ? AND pull_request_id IS NULL", repoID, repo.DefaultBranch).
+		Order("created_at DESC").First(&run).Error
+	switch {
+	case err == nil:
+		summary.CI = &SummaryCI{RunNumber: run.RunNumber, HeadSHA: run.HeadSHA, Status: run.Status, Conclusion: run.Conclusion, UpdatedAt: run.UpdatedAt}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Errorf("failed to load latest workflow run: %w", err)
+	}
+
+	return s.store(ctx, repoID, now, map[string]interface{}{models.StatsKindInsightsSummary: summary})
+}
+
+// store replaces the cache entries of a repository with payloads by kind
+func (s *repositoryStatsService) store(ctx context.Context, repoID uuid.UUID, now time.Time, payloads map[string]interface{}) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for kind, payload := range payloads {
+			data, err := json.Marshal(payload)
+			if err != nil {
+				return err
+			}
+			if err := tx.Where("repository_id = ? AND kind = ?", repoID, kind).Delete(&models.RepositoryStatsCache{}).Error; err != nil {
+				return err
+			}
+			entry := &models.RepositoryStatsCache{
+				ID:           uuid.New(),
+				RepositoryID: repoID,
+				Kind:         kind,
+				Data:         string(data),
+				ComputedAt:   now,
+			}
+			if err := tx.Create(entry).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ownerEmails returns the lower-cased emails whose commits count as the
+// owner's: the owning user, or the owners of the owning organization
+func (s *repositoryStatsService) ownerEmails(ctx context.Context, repoID uuid.UUID) (map[string]bool, error) {
+	var repo models.Repository
+	if err := s.db.WithContext(ctx).Select("id, owner_id, owner_type").First(&repo, "id = ?", repoID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load repository: %w", err)
+	}
+
+	var emails []string
+	query := s.db.WithContext(ctx).Model(&models.User{})
+	if repo.OwnerType == models.OwnerTypeOrganization {
+		query = query.Joins("JOIN organization_members ON organization_members.user_id = users.id").
+			Where("organization_members.organization_id = ? AND organization_members.role = ?", repo.OwnerID, models.OrgRoleOwner)
+	} else {
+		query = query.Where("users.id = ?", repo.OwnerID)
+	}
+	if err := query.Pluck("users.email", &emails).Error; err != nil {
+		return nil, fmt.Errorf("failed to load owner emails: %w", err)
+	}
+
+	owners := make(map[string]bool, len(emails))
+	for _, e := range emails {
+		owners[strings.ToLower(e)] = true
+	}
+	return owners, nil
+}
+
+// statsWeek returns the start of the UTC week (Sunday) containing t
+func statsWeek(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.AddDate(0, 0, -int(day.Weekday()))
+}
+
+// computeCodeFrequency buckets additions and deletions by week from the
+// first commit to the last, including empty weeks
+func computeCodeFrequency(commits []statsCommit) [][3]int64 {
+	weeks := [][3]int64{}
+	if len(commits) == 0 {
+		return weeks
+	}
+
+	totals := make(map[int64]*[3]int64)
+	first, last := statsWeek(commits[0].AuthorDate), statsWeek(commits[0].AuthorDate)
+	for _, c := range commits {
+		week := statsWeek(c.AuthorDate)
+		if week.Before(first) {
+			first = week
+		}
+		if week.After(last) {
+			last = week
+		}
+		bucket, ok := totals[week.Unix()]
+		if !ok {
+			bucket = &[3]int64{week.Unix()}
+			totals[week.Unix()] = bucket
+		}
+		bucket[1] += int64(c.Additions)
+		bucket[2] -= int64(c.Deletions)
+	}
+
+	for week := first; !week.After(last); week = week.AddDate(0, 0, 7) {
+		if bucket, ok := totals[week.Unix()]; ok {
+			weeks = append(weeks, *bucket)
+		} else {
+			weeks = append(weeks, [3]int64{week.Unix(), 0, 0})
+		}
+	}
+	return weeks
+}
+
+// computeParticipation counts commits per week over the participationWeeks
+// ending with the current week
+func computeParticipation(commits []statsCommit, ownerEmails map[string]bool, now time.Time) *Participation {
+	p := &Participation{
+		All:   make([]int, participationWeeks),
+		Owner: make([]int, participationWeeks),
+	}
+	start := statsWeek(now).AddDate(0, 0, -7*(participationWeeks-1))
+	for _, c := range commits {
+		week := statsWeek(c.AuthorDate)
+		if week.Before(start) {
+			continue
+		}
+		idx := int(week.Sub(start).Hours() / (24 * 7))
+		if idx >= participationWeeks {
+			continue
+		}
+		p.All[idx]++
+		if ownerEmails[strings.ToLower(c.AuthorEmail)] {
+			p.Owner[idx]++
+		}
+	}
+	return p
+}
+
+func (s *repositoryStatsService) HandlePush(ctx context.Context, event PushEvent) {
+	if event.Repository == nil {
+		return
+	}
+
+	moved := false
+	for _, update := range event.Updates {
+		if update.BranchName() == event.Repository.DefaultBranch && !update.IsDelete() {
+			moved = true
+			break
+		}
+	}
+	if !moved {
+		return
+	}
+
+	if s.queue == nil {
+		if err := s.refreshAfterPush(ctx, event.Repository.ID); err != nil {
+			s.logger.WithError(err).WithField("repository_id", event.Repository.ID).Warn("Failed to refresh repository stats")
+		}
+		return
+	}
+	// Pushes in quick succession share one pending refresh
+	payload := map[string]uuid.UUID{"repository_id": event.Repository.ID}
+	if _, err := s.queue.Enqueue(ctx, JobRefreshRepositoryStats, payload, jobs.EnqueueOptions{DedupeKey: event.Repository.ID.String()}); err != nil {
+		s.logger.WithError(err).WithField("repository_id", event.Repository.ID).Warn("Failed to enqueue repository stats refresh")
+	}
+}
+
+func (s *repositoryStatsService) UseQueue(queue *jobs.Queue) {
+	s.queue = queue
+	queue.Register(JobRefreshRepositoryStats, jobs.RetryPolicy{}, func(ctx context.Context, payload json.RawMessage) error {
+		var args struct {
+			RepositoryID uuid.UUID `json:"repository_id"`
+		}
+		if err := json.Unmarshal(payload, &args); err != nil {
+			return jobs.Permanent(err)
+		}
+		return s.refreshAfterPush(ctx, args.RepositoryID)
+	})
+}
+
+func (s *repositoryStatsService) refreshAfterPush(ctx context.Context, repoID uuid.UUID) error {
+	if err := s.repositoryService.SyncCommits(ctx, repoID); err != nil {
+		return fmt.Errorf("failed to sync commits for stats: %w", err)
+	}
+	if err := s.Refresh(ctx, repoID); err != nil {
+		return fmt.Errorf("failed to refresh repository stats: %w", err)
+	}
+	if err := s.RefreshSummary(ctx, repoID); err != nil {
+		return fmt.Errorf("failed to refresh repository insights summary: %w", err)
+	}
+	return nil
+}
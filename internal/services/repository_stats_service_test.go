@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestComputeCodeFrequency(t *testing.T) {
+	// 2026-01-04 is a Sunday
+	commits := []statsCommit{
+		{AuthorDate: time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC), Additions: 10, Deletions: 2},
+		{AuthorDate: time.Date(2026, 1, 10, 23, 0, 0, 0, time.UTC), Additions: 5, Deletions: 1},
+		{AuthorDate: time.Date(2026, 1, 20, 8, 0, 0, 0, time.UTC), Additions: 1, Deletions: 7},
+	}
+
+	weeks := computeCodeFrequency(commits)
+	require.Len(t, weeks, 3)
+	assert.Equal(t, [3]int64{time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC).Unix(), 15, -3}, weeks[0])
+	assert.Equal(t, [3]int64{time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC).Unix(), 0, 0}, weeks[1])
+	assert.Equal(t, [3]int64{time.Date(2026, 1, 18, 0, 0, 0, 0, time.UTC).Unix(), 1, -7}, weeks[2])
+
+	assert.Empty(t, computeCodeFrequency(nil))
+}
+
+func TestComputeParticipation(t *testing.T) {
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	commits := []statsCommit{
+		{AuthorEmail: "Owner@example.com", AuthorDate: now.Add(-time.Hour)},
+		{AuthorEmail: "other@example.com", AuthorDate: now.Add(-2 * time.Hour)},
+		{AuthorEmail: "owner@example.com", AuthorDate: now.AddDate(0, 0, -7)},
+		{AuthorEmail: "owner@example.com", AuthorDate: now.AddDate(-2, 0, 0)},
+	}
+
+	p := computeParticipation(commits, map[string]bool{"owner@example.com": true}, now)
+	require.Len(t, p.All, participationWeeks)
+	require.Len(t, p.Owner, participationWeeks)
+	assert.Equal(t, 2, p.All[participationWeeks-1])
+	assert.Equal(t, 1, p.Owner[participationWeeks-1])
+	assert.Equal(t, 1, p.All[participationWeeks-2])
+	assert.Equal(t, 1, p.Owner[participationWeeks-2])
+
+	total := 0
+	for _, n := range p.All {
+		total += n
+	}
+	assert.Equal(t, 3, total, "commits outside the window are ignored")
+}
+
+func TestRepositoryStatsSummary(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Repository{}, &models.Star{}, &models.Issue{}, &models.PullRequest{},
+		&models.Commit{}, &models.Release{}, &models.WorkflowRun{}, &models.RepositoryStatsCache{})
+	ctx := context.Background()
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	svc := NewRepositoryStatsService(db, nil, logrus.New()).(*repositoryStatsService)
+	svc.now = func() time.Time { return now }
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "app",
+		DefaultBranch: "main", Visibility: models.VisibilityPublic, StarsCount: 2}
+	require.NoError(t, db.Create(repo).Error)
+
+	old, recent := now.AddDate(0, 0, -30), now.AddDate(0, 0, -1)
+	for _, star := range []*models.Star{
+		{CreatedAt: old},
+		{CreatedAt: recent},
+		{CreatedAt: recent},
+		{CreatedAt: old, DeletedAt: gorm.DeletedAt{Time: recent, Valid: true}},
+	} {
+		star.ID, star.UserID, star.RepositoryID = uuid.New(), uuid.New(), repo.ID
+		require.NoError(t, db.Create(star).Error)
+	}
+	require.NoError(t, db.Create(&models.Issue{ID: uuid.New(), RepositoryID: repo.ID, Number: 1, Title: "bug", State: models.IssueStateOpen}).Error)
+	require.NoError(t, db.Create(&models.Issue{ID: uuid.New(), RepositoryID: repo.ID, Number: 2, Title: "done", State: models.IssueStateClosed}).Error)
+	require.NoError(t, db.Create(&models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 3,
+		Title: "fix", BaseBranch: "main", HeadBranch: "fix", State: models.PullRequestStateOpen}).Error)
+	for _, date := range []time.Time{recent, old} {
+		require.NoError(t, db.Create(&models.Commit{ID: uuid.New(), RepositoryID: repo.ID, SHA: uuid.NewString()[:8],
+			AuthorName: "a", AuthorEmail: "a@example.com", AuthorDate: date, CommitterName: "a",
+			CommitterEmail: "a@example.com", CommitterDate: date, TreeSHA: "t"}).Error)
+	}
+	published := recent
+	require.NoError(t, db.Create(&models.Release{ID: uuid.New(), RepositoryID: repo.ID, TagName: "v1.0.0", PublishedAt: &published}).Error)
+	require.NoError(t, db.Create(&models.Release{ID: uuid.New(), RepositoryID: repo.ID, TagName: "v2.0.0-rc1", Prerelease: true, PublishedAt: &now}).Error)
+	require.NoError(t, db.Create(&models.WorkflowRun{ID: uuid.New(), RepositoryID: repo.ID, RunNumber: 7, Name: "ci", WorkflowPath: ".hub/workflows/ci.yml",
+		Event: "push", HeadBranch: "main", HeadSHA: "abc", Status: models.WorkflowStatusCompleted, Conclusion: models.WorkflowConclusionFailure}).Error)
+
+	require.NoError(t, svc.RefreshSummary(ctx, repo.ID))
+	summary, ready, err := svc.Summary(ctx, repo.ID)
+	require.NoError(t, err)
+	require.True(t, ready)
+	assert.Equal(t, 2, summary.Stars)
+	assert.EqualValues(t, 1, summary.StarsDelta, "two stars added and one removed this week")
+	assert.EqualValues(t, 1, summary.OpenIssues)
+	assert.EqualValues(t, 1, summary.OpenPullRequests)
+	assert.EqualValues(t, 1, summary.WeeklyCommits)
+	require.NotNil(t, summary.LastRelease)
+	assert.Equal(t, "v1.0.0", summary.LastRelease.TagName, "prereleases are not the latest release")
+	require.NotNil(t, summary.CI)
+	assert.Equal(t, 7, summary.CI.RunNumber)
+	assert.Equal(t, models.WorkflowConclusionFailure, summary.CI.Conclusion)
+}
+
+func TestRepositoryStatsAuthorCodeFrequency(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{}, &models.Repository{},
+		&models.Commit{}, &models.RepositoryStatsCache{})
+	ctx := context.Background()
+	// 2026-03-01 is a Sunday
+	now := time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+	svc := NewRepositoryStatsService(db, nil, logrus.New()).(*repositoryStatsService)
+	svc.now = func() time.Time { return now }
+
+	owner := &models.User{ID: uuid.New(), Username: "olivia", Email: "olivia@example.com"}
+	member := &models.User{ID: uuid.New(), Username: "mo", Email: "mo@example.com"}
+	require.NoError(t, db.Create([]*models.User{owner, member}).Error)
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	require.NoError(t, db.Create([]*models.OrganizationMember{
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: owner.ID, Role: models.OrgRoleOwner},
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: member.ID, Role: models.OrgRoleMember},
+	}).Error)
+	api := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "api", Visibility: models.VisibilityPrivate}
+	web := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "web", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create([]*models.Repository{api, web}).Error)
+
+	commit := func(repo *models.Repository, name, email string, date time.Time, additions, deletions int) {
+		require.NoError(t, db.Create(&models.Commit{ID: uuid.New(), RepositoryID: repo.ID, SHA: uuid.NewString()[:8],
+			AuthorName: name, AuthorEmail: email, AuthorDate: date, CommitterName: name, CommitterEmail: email,
+			CommitterDate: date, TreeSHA: "t", Additions: additions, Deletions: deletions}).Error)
+	}
+	commit(api, "Olivia", "Olivia@example.com", time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC), 10, 1)
+	commit(api, "Olivia O.", "olivia@example.com", time.Date(2026, 3, 3, 9, 0, 0, 0, time.UTC), 5, 5)
+	commit(api, "Sam", "sam@example.com", time.Date(2026, 2, 16, 9, 0, 0, 0, time.UTC), 2, 0)
+	commit(api, "Sam", "sam@example.com", time.Date(2025, 1, 6, 9, 0, 0, 0, time.UTC), 100, 0)
+	commit(web, "Olivia O.", "olivia@example.com", time.Date(2026, 2, 24, 9, 0, 0, 0, time.UTC), 3, 0)
+
+	// Nothing is cached yet
+	_, ready, err := svc.AuthorCodeFrequency(ctx, api.ID, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.False(t, ready)
+	require.NoError(t, svc.Refresh(ctx, api.ID))
+
+	report, ready, err := svc.AuthorCodeFrequency(ctx, api.ID, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.True(t, ready)
+	require.Len(t, report.Authors, 2, "commits before the default window are left out")
+	olivia := report.Authors[0]
+	assert.Equal(t, "olivia", olivia.Login)
+	assert.Equal(t, "Olivia O.", olivia.Name)
+	assert.EqualValues(t, 2, olivia.Commits)
+	require.Len(t, olivia.Weeks, 1)
+	assert.Equal(t, AuthorWeek{Week: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Additions: 15, Deletions: 6, Commits: 2}, *olivia.Weeks[0])
+	assert.Empty(t, report.Authors[1].Login)
+
+	_, _, err = svc.OrganizationAuthorCodeFrequency(ctx, "acme", member.ID, time.Time{}, time.Time{})
+	assert.ErrorIs(t, err, ErrAuthorStatsForbidden)
+	_, ready, err = svc.OrganizationAuthorCodeFrequency(ctx, "acme", owner.ID, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.False(t, ready, "web has no cached stats yet")
+	require.NoError(t, svc.Refresh(ctx, web.ID))
+
+	since := time.Date(2026, 2, 25, 0, 0, 0, 0, time.UTC)
+	report, ready, err = svc.OrganizationAuthorCodeFrequency(ctx, "acme", owner.ID, since, now)
+	require.NoError(t, err)
+	require.True(t, ready)
+	require.Len(t, report.Authors, 1, "weeks before the start date's week are left out")
+	olivia = report.Authors[0]
+	assert.EqualValues(t, 3, olivia.Commits)
+	assert.EqualValues(t, 18, olivia.Additions)
+	require.Len(t, olivia.Weeks, 2)
+
+	data, err := report.CSV()
+	require.NoError(t, err)
+	assert.Equal(t, "Login,Email,Name,Week,Additions,Deletions,Commits\n"+
+		"olivia,olivia@example.com,Olivia O.,2026-02-22,3,0,1\n"+
+		"olivia,olivia@example.com,Olivia O.,2026-03-01,15,6,2\n", string(data))
+}
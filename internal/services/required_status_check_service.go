@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRequiredStatusCheckNotFound = errors.New("required status check not found")
+	ErrInvalidRequiredStatusCheck  = errors.New("invalid required status check")
+)
+
+// Where a required status check pattern comes from
+const (
+	StatusCheckSourceRepository       = "repository"
+	StatusCheckSourceBranchProtection = "branch_protection"
+)
+
+// StatusCheckStateExpected is the state of a required check no matching
+// status was reported for
+const StatusCheckStateExpected = "expected"
+
+// RequiredStatusCheckRequest creates or updates a required status check
+type RequiredStatusCheckRequest struct {
+	Pattern       *string `json:"pattern"`
+	BranchPattern *string `json:"branch_pattern"`
+	Description   *string `json:"description"`
+}
+
+// StatusCheckPatternTest matches a pattern against the contexts reported
+// for SHA, or every context reported in the repository when SHA is empty
+type StatusCheckPatternTest struct {
+	Pattern string `json:"pattern" binding:"required"`
+	SHA     string `json:"sha"`
+}
+
+// StatusCheckPatternTestResult lists the contexts a pattern matches and,
+// when tested against a commit, how it fares
+type StatusCheckPatternTestResult struct {
+	Pattern  string                `json:"pattern"`
+	SHA      string                `json:"sha,omitempty"`
+	Contexts []string              `json:"contexts"`
+	Result   *StatusCheckResult    `json:"result,omitempty"`
+	Statuses []models.CommitStatus `json:"statuses,omitempty"`
+}
+
+// RequiredCheckPattern is a pattern a branch requires and where it is configured
+type RequiredCheckPattern struct {
+	Pattern string `json:"pattern"`
+	Source  string `json:"source"`
+}
+
+// StatusCheckResult is how the statuses of a commit fare against one
+// required pattern. It is satisfied when at least one context matches and
+// the latest status of every matching context succeeded.
+type StatusCheckResult struct {
+	Pattern   string   `json:"pattern"`
+	Source    string   `json:"source"`
+	State     string   `json:"state"`
+	Contexts  []string `json:"contexts"`
+	Satisfied bool     `json:"satisfied"`
+}
+
+// StatusCheckEvaluation is the outcome of checking a commit against every
+// required status check of a branch
+type StatusCheckEvaluation struct {
+	SHA         string               `json:"sha"`
+	Checks      []*StatusCheckResult `json:"checks"`
+	Unsatisfied []string             `json:"unsatisfied"`
+	Satisfied   bool                 `json:"satisfied"`
+}
+
+// RequiredStatusCheckService manages repository-level required status check
+// patterns and evaluates commits against them together with the contexts
+// required by branch protection
+type RequiredStatusCheckService interface {
+	List(ctx context.Context, repoID uuid.UUID) ([]*models.RequiredStatusCheck, error)
+	Create(ctx context.Context, repoID uuid.UUID, req RequiredStatusCheckRequest) (*models.RequiredStatusCheck, error)
+	Update(ctx context.Context, repoID, checkID uuid.UUID, req RequiredStatusCheckRequest) (*models.RequiredStatusCheck, error)
+	Delete(ctx context.Context, repoID, checkID uuid.UUID) error
+
+	// Required returns the patterns pull requests into branch must satisfy
+	Required(ctx context.Context, repoID uuid.UUID, branch string) ([]RequiredCheckPattern, error)
+	// Evaluate checks the statuses reported for sha against required
+	Evaluate(ctx context.Context, repoID uuid.UUID, sha string, required []RequiredCheckPattern) (*StatusCheckEvaluation, error)
+	TestPattern(ctx context.Context, repoID uuid.UUID, req StatusCheckPatternTest) (*StatusCheckPatternTestResult, error)
+}
+
+type requiredStatusCheckService struct {
+	db       *gorm.DB
+	statuses CommitStatusService
+	logger   *logrus.Logger
+}
+
+// NewRequiredStatusCheckService creates a new RequiredStatusCheckService
+func NewRequiredStatusCheckService(db *gorm.DB, logger *logrus.Logger) RequiredStatusCheckService {
+	return &requiredStatusCheckService{db: db, statuses: NewCommitStatusService(db, logger), logger: logger}
+}
+
+func (s *requiredStatusCheckService) List(ctx context.Context, repoID uuid.UUID) ([]*models.RequiredStatusCheck, error) {
+	var checks []*models.RequiredStatusCheck
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repoID).Order("pattern ASC").Find(&checks).Error; err != nil {
+		return nil, fmt.Errorf("failed to list required status checks: %w", err)
+	}
+	return checks, nil
+}
+
+func (s *requiredStatusCheckService) Create(ctx context.Context, repoID uuid.UUID, req RequiredStatusCheckRequest) (*models.RequiredStatusCheck, error) {
+	if req.Pattern == nil {
+		return nil, fmt.Errorf("%w: pattern is required", ErrInvalidRequiredStatusCheck)
+	}
+
+	check := &models.RequiredStatusCheck{
+		ID:            uuid.New(),
+		RepositoryID:  repoID,
+		BranchPattern: "*",
+	}
+	if err := applyRequiredStatusCheckRequest(check, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(check).Error; err != nil {
+		return nil, fmt.Errorf("failed to create required status check: %w", err)
+	}
+	return check, nil
+}
+
+func (s *requiredStatusCheckService) Update(ctx context.Context, repoID, checkID uuid.UUID, req RequiredStatusCheckRequest) (*models.RequiredStatusCheck, error) {
+	var check models.RequiredStatusCheck
+	if err := s.db.WithContext(ctx).Where("id = ? AND repository_id = ?", checkID, repoID).First(&check).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRequiredStatusCheckNotFound
+		}
+		return nil, err
+	}
+	if err := applyRequiredStatusCheckRequest(&check, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Save(&check).Error; err != nil {
+		return nil, fmt.Errorf("failed to update required status check: %w", err)
+	}
+	return &check, nil
+}
+
+func (s *requiredStatusCheckService) Delete(ctx context.Context, repoID, checkID uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND repository_id = ?", checkID, repoID).Delete(&models.RequiredStatusCheck{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete required status check: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrRequiredStatusCheckNotFound
+	}
+	return nil
+}
+
+func applyRequiredStatusCheckRequest(check *models.RequiredStatusCheck, req RequiredStatusCheckRequest) error {
+	if req.Pattern != nil {
+		pattern := strings.TrimSpace(*req.Pattern)
+		if pattern == "" || len(pattern) > 255 {
+			return fmt.Errorf("%w: pattern must be between 1 and 255 characters", ErrInvalidRequiredStatusCheck)
+		}
+		check.Pattern = pattern
+	}
+	if req.BranchPattern != nil {
+		check.BranchPattern = strings.TrimSpace(*req.BranchPattern)
+		if check.BranchPattern == "" {
+			check.BranchPattern = "*"
+		}
+	}
+	if req.Description != nil {
+		check.Description = *req.Description
+	}
+	return nil
+}
+
+func (s *requiredStatusCheckService) Required(ctx context.Context, repoID uuid.UUID, branch string) ([]RequiredCheckPattern, error) {
+	var required []RequiredCheckPattern
+	seen := make(map[string]bool)
+	add := func(pattern, source string) {
+		if !seen[pattern] {
+			seen[pattern] = true
+			required = append(required, RequiredCheckPattern{Pattern: pattern, Source: source})
+		}
+	}
+
+	// Contexts named by branch protection are patterns matching themselves
+	var branchRules []*models.BranchProtectionRule
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repoID).Find(&branchRules).Error; err != nil {
+		return nil, fmt.Errorf("failed to load branch protection: %w", err)
+	}
+	for _, rule := range branchRules {
+		if !matchPattern(rule.Pattern, branch) || rule.RequiredStatusChecks == "" {
+			continue
+		}
+		var statusChecks RequiredStatusChecks
+		if json.Unmarshal([]byte(rule.RequiredStatusChecks), &statusChecks) != nil {
+			continue
+		}
+		for _, name := range statusChecks.Contexts {
+			add(name, StatusCheckSourceBranchProtection)
+		}
+	}
+
+	checks, err := s.List(ctx, repoID)
+	if err != nil {
+		return nil, err
+	}
+	for _, check := range checks {
+		if matchPattern(check.BranchPattern, branch) {
+			add(check.Pattern, StatusCheckSourceRepository)
+		}
+	}
+	return required, nil
+}
+
+func (s *requiredStatusCheckService) Evaluate(ctx context.Context, repoID uuid.UUID, sha string, required []RequiredCheckPattern) (*StatusCheckEvaluation, error) {
+	eval := &StatusCheckEvaluation{SHA: sha, Checks: []*StatusCheckResult{}, Unsatisfied: []string{}, Satisfied: true}
+	if len(required) == 0 {
+		return eval, nil
+	}
+
+	var latest []models.CommitStatus
+	if sha != "" {
+		combined, err := s.statuses.Combined(ctx, repoID, sha)
+		if err != nil {
+			return nil, err
+		}
+		latest = combined.Statuses
+	}
+
+	for _, req := range required {
+		result := evaluateStatusCheck(req, latest)
+		eval.Checks = append(eval.Checks, result)
+		if !result.Satisfied {
+			eval.Satisfied = false
+			eval.Unsatisfied = append(eval.Unsatisfied, req.Pattern)
+		}
+	}
+	return eval, nil
+}
+
+// evaluateStatusCheck rolls the latest statuses of the contexts matching a
+// pattern up into one state the way combined statuses are
+func evaluateStatusCheck(req RequiredCheckPattern, latest []models.CommitStatus) *StatusCheckResult {
+	result := &StatusCheckResult{Pattern: req.Pattern, Source: req.Source, State: StatusCheckStateExpected, Contexts: []string{}}
+	for _, status := range latest {
+		if !MatchStatusCheckPattern(req.Pattern, status.Context) {
+			continue
+		}
+		result.Contexts = append(result.Contexts, status.Context)
+		switch {
+		case status.State == models.CommitStatusFailure || status.State == models.CommitStatusError:
+			result.State = string(models.CommitStatusFailure)
+		case status.State == models.CommitStatusPending && result.State != string(models.CommitStatusFailure):
+			result.State = string(models.CommitStatusPending)
+		case result.State == StatusCheckStateExpected:
+			result.State = string(models.CommitStatusSuccess)
+		}
+	}
+	sort.Strings(result.Contexts)
+	result.Satisfied = result.State == string(models.CommitStatusSuccess)
+	return result
+}
+
+func (s *requiredStatusCheckService) TestPattern(ctx context.Context, repoID uuid.UUID, req StatusCheckPatternTest) (*StatusCheckPatternTestResult, error) {
+	pattern := strings.TrimSpace(req.Pattern)
+	if pattern == "" || len(pattern) > 255 {
+		return nil, fmt.Errorf("%w: pattern must be between 1 and 255 characters", ErrInvalidRequiredStatusCheck)
+	}
+	result := &StatusCheckPatternTestResult{Pattern: pattern, SHA: req.SHA, Contexts: []string{}}
+
+	if req.SHA != "" {
+		if !commitSHAPattern.MatchString(req.SHA) {
+			return nil, fmt.Errorf("%w: sha must be a full 40 character commit id", ErrInvalidRequiredStatusCheck)
+		}
+		combined, err := s.statuses.Combined(ctx, repoID, req.SHA)
+		if err != nil {
+			return nil, err
+		}
+		result.Result = evaluateStatusCheck(RequiredCheckPattern{Pattern: pattern}, combined.Statuses)
+		result.Contexts = result.Result.Contexts
+		for _, status := range combined.Statuses {
+			if MatchStatusCheckPattern(pattern, status.Context) {
+				result.Statuses = append(result.Statuses, status)
+			}
+		}
+		return result, nil
+	}
+
+	var contexts []string
+	if err := s.db.WithContext(ctx).Model(&models.CommitStatus{}).Where("repository_id = ?", repoID).
+		Distinct("context").Order("context ASC").Pluck("context", &contexts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list status contexts: %w", err)
+	}
+	for _, name := range contexts {
+		if MatchStatusCheckPattern(pattern, name) {
+			result.Contexts = append(result.Contexts, name)
+		}
+	}
+	return result, nil
+}
+
+// MatchStatusCheckPattern reports whether a status context matches a
+// required check pattern. "*" matches any run of characters, including
+// "/", and "?" any single character; everything else matches itself.
+func MatchStatusCheckPattern(pattern, name string) bool {
+	if !strings.ContainsAny(pattern, "*?") {
+		return pattern == name
+	}
+	var expr strings.Builder
+	expr.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expr.WriteString(".*")
+		case '?':
+			expr.WriteString(".")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String()).MatchString(name)
+}
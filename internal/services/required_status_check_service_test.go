@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchStatusCheckPattern(t *testing.T) {
+	cases := []struct {
+		pattern, context string
+		want             bool
+	}{
+		{"ci/build", "ci/build", true},
+		{"ci/build", "ci/build-linux", false},
+		{"ci/build-*", "ci/build-linux", true},
+		{"ci/build-*", "ci/build-", true},
+		{"ci/build-*", "ci/test-linux", false},
+		{"ci/*", "ci/build/linux", true},
+		{"lint?", "lint1", true},
+		{"lint?", "lint", false},
+		{"deploy (prod)", "deploy (prod)", true},
+		{"*.check", "unit.check", true},
+		{"*.check", "unitxcheck", false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.want, MatchStatusCheckPattern(tc.pattern, tc.context), "%s ~ %s", tc.pattern, tc.context)
+	}
+}
+
+func TestRequiredStatusCheckService_Evaluate(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.BranchProtectionRule{}, &models.RequiredStatusCheck{}, &models.CommitStatus{}, &models.CheckRun{})
+
+	ctx := context.Background()
+	svc := NewRequiredStatusCheckService(db, logrus.New())
+	statuses := NewCommitStatusService(db, logrus.New())
+	repoID := uuid.New()
+	sha := strings.Repeat("a", 40)
+
+	pattern := func(s string) *string { return &s }
+	_, err := svc.Create(ctx, repoID, RequiredStatusCheckRequest{Pattern: pattern(" ")})
+	assert.ErrorIs(t, err, ErrInvalidRequiredStatusCheck)
+	build, err := svc.Create(ctx, repoID, RequiredStatusCheckRequest{Pattern: pattern("ci/build-*")})
+	require.NoError(t, err)
+	assert.Equal(t, "*", build.BranchPattern)
+	_, err = svc.Create(ctx, repoID, RequiredStatusCheckRequest{Pattern: pattern("deploy/*"), BranchPattern: pattern("release/*")})
+	require.NoError(t, err)
+	checks, _ := json.Marshal(RequiredStatusChecks{Contexts: []string{"lint"}})
+	require.NoError(t, db.Create(&models.BranchProtectionRule{ID: uuid.New(), RepositoryID: repoID, Pattern: "main",
+		RequiredStatusChecks: string(checks)}).Error)
+
+	required, err := svc.Required(ctx, repoID, "main")
+	require.NoError(t, err)
+	assert.Equal(t, []RequiredCheckPattern{
+		{Pattern: "lint", Source: StatusCheckSourceBranchProtection},
+		{Pattern: "ci/build-*", Source: StatusCheckSourceRepository},
+	}, required)
+
+	// Nothing reported yet
+	eval, err := svc.Evaluate(ctx, repoID, sha, required)
+	require.NoError(t, err)
+	assert.False(t, eval.Satisfied)
+	assert.Equal(t, []string{"lint", "ci/build-*"}, eval.Unsatisfied)
+	assert.Equal(t, StatusCheckStateExpected, eval.Checks[1].State)
+
+	report := func(context string, state models.CommitStatusState) {
+		_, err := statuses.Create(ctx, repoID, sha, nil, CreateCommitStatusRequest{State: state, Context: context})
+		require.NoError(t, err)
+	}
+	report("lint", models.CommitStatusSuccess)
+	report("ci/build-linux", models.CommitStatusSuccess)
+	report("ci/build-macos", models.CommitStatusPending)
+	eval, err = svc.Evaluate(ctx, repoID, sha, required)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ci/build-*"}, eval.Unsatisfied)
+	assert.Equal(t, string(models.CommitStatusPending), eval.Checks[1].State)
+	assert.Equal(t, []string{"ci/build-linux", "ci/build-macos"}, eval.Checks[1].Contexts)
+
+	report("ci/build-macos", models.CommitStatusSuccess)
+	eval, err = svc.Evaluate(ctx, repoID, sha, required)
+	require.NoError(t, err)
+	assert.True(t, eval.Satisfied)
+	assert.Empty(t, eval.Unsatisfied)
+
+	tested, err := svc.TestPattern(ctx, repoID, StatusCheckPatternTest{Pattern: "ci/*"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ci/build-linux", "ci/build-macos"}, tested.Contexts)
+	tested, err = svc.TestPattern(ctx, repoID, StatusCheckPatternTest{Pattern: "ci/build-mac*", SHA: sha})
+	require.NoError(t, err)
+	require.NotNil(t, tested.Result)
+	assert.True(t, tested.Result.Satisfied)
+	assert.Equal(t, []string{"ci/build-macos"}, tested.Contexts)
+	_, err = svc.TestPattern(ctx, repoID, StatusCheckPatternTest{Pattern: "ci/*", SHA: "main"})
+	assert.ErrorIs(t, err, ErrInvalidRequiredStatusCheck)
+
+	require.NoError(t, svc.Delete(ctx, repoID, build.ID))
+	assert.ErrorIs(t, svc.Delete(ctx, repoID, build.ID), ErrRequiredStatusCheckNotFound)
+}
@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RetentionService purges analytics data past its retention period and
+// anonymizes user identifiers in the data that is kept
+type RetentionService interface {
+	Policy() config.Retention
+	RunPurge(ctx context.Context, trigger models.RetentionPurgeTrigger, actorID *uuid.UUID) (*models.RetentionPurgeRun, error)
+	ListRuns(ctx context.Context, limit, offset int) ([]*models.RetentionPurgeRun, int64, error)
+	StartScheduler(ctx context.Context)
+}
+
+type retentionService struct {
+	db     *gorm.DB
+	cfg    config.Retention
+	logger *logrus.Logger
+	now    func() time.Time
+}
+
+// NewRetentionService creates a new RetentionService
+func NewRetentionService(db *gorm.DB, cfg config.Retention, logger *logrus.Logger) RetentionService {
+	return &retentionService{db: db, cfg: cfg, logger: logger, now: time.Now}
+}
+
+func (s *retentionService) Policy() config.Retention {
+	return s.cfg
+}
+
+// retentionCutoff returns the time before which data of a class expires, or
+// nil when days is zero and the class is kept forever
+func retentionCutoff(now time.Time, days int) *time.Time {
+	if days <= 0 {
+		return nil
+	}
+	cutoff := now.AddDate(0, 0, -days)
+	return &cutoff
+}
+
+// RunPurge applies the retention policy once and records the run. Expired
+// rows are deleted permanently, bypassing soft deletes.
+func (s *retentionService) RunPurge(ctx context.Context, trigger models.RetentionPurgeTrigger, actorID *uuid.UUID) (*models.RetentionPurgeRun, error) {
+	now := s.now()
+	run := &models.RetentionPurgeRun{
+		ID:                 uuid.New(),
+		CreatedAt:          now,
+		Trigger:            trigger,
+		TriggeredByID:      actorID,
+		Status:             "running",
+		EventsDays:         s.cfg.EventsDays,
+		PerformanceLogDays: s.cfg.PerformanceLogDays,
+		AuditLogDays:       s.cfg.AuditLogDays,
+		AnonymizeAfterDays: s.cfg.AnonymizeAfterDays,
+	}
+	if err := s.db.WithContext(ctx).Create(run).Error; err != nil {
+		return nil, fmt.Errorf("failed to record purge run: %w", err)
+	}
+
+	purgeErr := s.purge(ctx, run, now)
+
+	finished := s.now()
+	run.FinishedAt = &finished
+	run.Status = "success"
+	if purgeErr != nil {
+		run.Status = "error"
+		run.ErrorMessage = purgeErr.Error()
+	}
+	if err := s.db.WithContext(ctx).Save(run).Error; err != nil {
+		return run, fmt.Errorf("failed to record purge run: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"trigger":                     trigger,
+		"events_purged":               run.EventsPurged,
+		"performance_logs_purged":     run.PerformanceLogsPurged,
+		"audit_logs_purged":           run.AuditLogsPurged,
+		"events_anonymized":           run.EventsAnonymized,
+		"performance_logs_anonymized": run.PerformanceLogsAnonymized,
+	}).Info("Applied analytics retention policy")
+
+	return run, purgeErr
+}
+
+func (s *retentionService) purge(ctx context.Context, run *models.RetentionPurgeRun, now time.Time) error {
+	db := s.db.WithContext(ctx).Unscoped().Session(&gorm.Session{})
+
+	if cutoff := retentionCutoff(now, s.cfg.EventsDays); cutoff != nil {
+		result := db.Where("created_at < ?", *cutoff).Delete(&models.AnalyticsEvent{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to purge analytics events: %w", result.Error)
+		}
+		run.EventsPurged = result.RowsAffected
+	}
+
+	if cutoff := retentionCutoff(now, s.cfg.PerformanceLogDays); cutoff != nil {
+		result := db.Where("created_at < ?", *cutoff).Delete(&models.PerformanceLog{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to purge performance logs: %w", result.Error)
+		}
+		run.PerformanceLogsPurged = result.RowsAffected
+	}
+
+	// Organization audit logs; per-organization retention settings may purge earlier
+	if cutoff := retentionCutoff(now, s.cfg.AuditLogDays); cutoff != nil {
+		result := db.Where("created_at < ?", *cutoff).Delete(&models.OrganizationActivity{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to purge audit logs: %w", result.Error)
+		}
+		run.AuditLogsPurged = result.RowsAffected
+	}
+
+	if cutoff := retentionCutoff(now, s.cfg.AnonymizeAfterDays); cutoff != nil {
+		result := db.Model(&models.AnalyticsEvent{}).
+			Where("created_at < ?", *cutoff).
+			Where("actor_id IS NOT NULL OR ip_address <> '' OR user_agent <> '' OR session_id <> ''").
+			UpdateColumns(map[string]interface{}{"actor_id": nil, "ip_address": "", "user_agent": "", "session_id": ""})
+		if result.Error != nil {
+			return fmt.Errorf("failed to anonymize analytics events: %w", result.Error)
+		}
+		run.EventsAnonymized = result.RowsAffected
+
+		result = db.Model(&models.PerformanceLog{}).
+			Where("created_at < ?", *cutoff).
+			Where("user_id IS NOT NULL OR ip_address <> '' OR user_agent <> ''").
+			UpdateColumns(map[string]interface{}{"user_id": nil, "ip_address": "", "user_agent": ""})
+		if result.Error != nil {
+			return fmt.Errorf("failed to anonymize performance logs: %w", result.Error)
+		}
+		run.PerformanceLogsAnonymized = result.RowsAffected
+	}
+
+	return nil
+}
+
+func (s *retentionService) ListRuns(ctx context.Context, limit, offset int) ([]*models.RetentionPurgeRun, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.RetentionPurgeRun{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count purge runs: %w", err)
+	}
+
+	var runs []*models.RetentionPurgeRun
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&runs).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list purge runs: %w", err)
+	}
+	return runs, total, nil
+}
+
+// StartScheduler applies the retention policy every IntervalHours until ctx
+// is cancelled. It returns immediately when retention is disabled.
+func (s *retentionService) StartScheduler(ctx context.Context) {
+	if !s.cfg.Enabled {
+		return
+	}
+	interval := time.Duration(s.cfg.IntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			func() {
+				defer errorreporting.Default().Recover("retention_scheduler", nil)
+				if _, err := s.RunPurge(ctx, models.RetentionPurgeScheduled, nil); err != nil {
+					s.logger.WithError(err).Error("Failed to apply analytics retention policy")
+				}
+			}()
+		}
+	}
+}
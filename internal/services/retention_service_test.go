@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetentionService_RunPurge(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.AnalyticsEvent{}, &models.PerformanceLog{}, &models.OrganizationActivity{}, &models.RetentionPurgeRun{})
+
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	daysAgo := func(d int) time.Time { return now.AddDate(0, 0, -d) }
+	actor := uuid.New()
+
+	events := []*models.AnalyticsEvent{
+		{ID: uuid.New(), CreatedAt: daysAgo(400), EventType: models.EventPageView, ActorID: &actor, IPAddress: "10.0.0.1"},
+		{ID: uuid.New(), CreatedAt: daysAgo(100), EventType: models.EventPageView, ActorID: &actor, IPAddress: "10.0.0.2", SessionID: "s1"},
+		{ID: uuid.New(), CreatedAt: daysAgo(1), EventType: models.EventPageView, ActorID: &actor, IPAddress: "10.0.0.3"},
+	}
+	require.NoError(t, db.Create(events).Error)
+	require.NoError(t, db.Create([]*models.PerformanceLog{
+		{ID: uuid.New(), CreatedAt: daysAgo(60), Method: "GET", Path: "/", StatusCode: 200},
+		{ID: uuid.New(), CreatedAt: daysAgo(2), Method: "GET", Path: "/", StatusCode: 200, UserID: &actor},
+	}).Error)
+	require.NoError(t, db.Create(&models.OrganizationActivity{ID: uuid.New(), CreatedAt: daysAgo(800), OrganizationID: uuid.New(), ActorID: actor, Action: "member.added"}).Error)
+
+	svc := &retentionService{
+		db:     db,
+		cfg:    config.Retention{EventsDays: 365, PerformanceLogDays: 30, AuditLogDays: 730, AnonymizeAfterDays: 90},
+		logger: logrus.New(),
+		now:    func() time.Time { return now },
+	}
+
+	run, err := svc.RunPurge(context.Background(), models.RetentionPurgeManual, &actor)
+	require.NoError(t, err)
+	assert.Equal(t, "success", run.Status)
+	assert.EqualValues(t, 1, run.EventsPurged)
+	assert.EqualValues(t, 1, run.PerformanceLogsPurged)
+	assert.EqualValues(t, 1, run.AuditLogsPurged)
+	assert.EqualValues(t, 1, run.EventsAnonymized)
+	assert.EqualValues(t, 0, run.PerformanceLogsAnonymized)
+
+	var anonymized models.AnalyticsEvent
+	require.NoError(t, db.First(&anonymized, "id = ?", events[1].ID).Error)
+	assert.Nil(t, anonymized.ActorID)
+	assert.Empty(t, anonymized.IPAddress)
+	assert.Empty(t, anonymized.SessionID)
+
+	var recent models.AnalyticsEvent
+	require.NoError(t, db.First(&recent, "id = ?", events[2].ID).Error)
+	assert.Equal(t, actor, *recent.ActorID)
+
+	runs, total, err := svc.ListRuns(context.Background(), 10, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	assert.Equal(t, run.ID, runs[0].ID)
+}
@@ -0,0 +1,613 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReviewCommentNotFound = errors.New("review comment not found")
+	ErrInvalidReviewComment  = errors.New("invalid review comment")
+	ErrInvalidSuggestion     = errors.New("suggestion cannot be applied")
+	ErrSuggestionOutdated    = errors.New("the lines a suggestion changes have been modified since it was made")
+)
+
+var (
+	suggestionOpenPattern  = regexp.MustCompile("^\\s*```suggestion\\s*$")
+	suggestionClosePattern = regexp.MustCompile("^\\s*```\\s*$")
+)
+
+// CreateReviewCommentRequest is a line comment on a pull request diff. The
+// line is given directly or as a position in the file's diff, counted from
+// the line after its first hunk header.
+type CreateReviewCommentRequest struct {
+	Body        string     `json:"body" binding:"required"`
+	Path        string     `json:"path"`
+	CommitSHA   string     `json:"commit_id"`
+	Line        *int       `json:"line"`
+	StartLine   *int       `json:"start_line"`
+	Side        string     `json:"side"`
+	Position    *int       `json:"position"`
+	InReplyToID *uuid.UUID `json:"in_reply_to"`
+
+	// ReviewID is set for comments submitted as part of a review
+	ReviewID *uuid.UUID `json:"-"`
+}
+
+// ApplySuggestionsRequest applies one or more suggestions in a single commit
+type ApplySuggestionsRequest struct {
+	CommentIDs []uuid.UUID `json:"comment_ids" binding:"required"`
+	Message    string      `json:"message"`
+}
+
+// ReviewCommentService manages pull request line comments and applies the
+// suggested changes they carry
+type ReviewCommentService interface {
+	List(ctx context.Context, pr *models.PullRequest) ([]models.ReviewComment, error)
+	Create(ctx context.Context, pr *models.PullRequest, userID uuid.UUID, req CreateReviewCommentRequest) (*models.ReviewComment, error)
+	// ApplySuggestions commits the suggestions of the given comments to the
+	// head branch, crediting their authors as co-authors
+	ApplySuggestions(ctx context.Context, pr *models.PullRequest, userID uuid.UUID, req ApplySuggestionsRequest) (*git.Commit, error)
+	// ResolveThread marks the thread the comment belongs to as resolved
+	ResolveThread(ctx context.Context, pr *models.PullRequest, commentID, userID uuid.UUID) (*models.ReviewComment, error)
+	// UnresolveThread reopens the thread the comment belongs to
+	UnresolveThread(ctx context.Context, pr *models.PullRequest, commentID, userID uuid.UUID) (*models.ReviewComment, error)
+	// ThreadHistory lists who resolved and reopened the thread, oldest first
+	ThreadHistory(ctx context.Context, pr *models.PullRequest, commentID uuid.UUID) ([]*models.ReviewThreadEvent, error)
+}
+
+type reviewCommentService struct {
+	db          *gorm.DB
+	gitService  git.GitService
+	repoService RepositoryService
+	logger      *logrus.Logger
+}
+
+// NewReviewCommentService creates a new ReviewCommentService
+func NewReviewCommentService(db *gorm.DB, gitService git.GitService, repoService RepositoryService, logger *logrus.Logger) ReviewCommentService {
+	return &reviewCommentService{db: db, gitService: gitService, repoService: repoService, logger: logger}
+}
+
+// ParseSuggestion returns the replacement text of the first ```suggestion
+// block in a comment body. An empty block suggests deleting the lines.
+func ParseSuggestion(body string) (string, bool) {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if !suggestionOpenPattern.MatchString(line) {
+			continue
+		}
+		for j := i + 1; j < len(lines); j++ {
+			if suggestionClosePattern.MatchString(lines[j]) {
+				return strings.Join(lines[i+1:j], "\n"), true
+			}
+		}
+		return "", false
+	}
+	return "", false
+}
+
+func withSuggestion(comment *models.ReviewComment) {
+	if suggestion, ok := ParseSuggestion(comment.Body); ok && comment.InReplyToID == nil {
+		comment.Suggestion = &suggestion
+	}
+}
+
+func (s *reviewCommentService) List(ctx context.Context, pr *models.PullRequest) ([]models.ReviewComment, error) {
+	var comments []models.ReviewComment
+	err := s.db.WithContext(ctx).Preload("User").Preload("ResolvedBy").
+		Where("pull_request_id = ?", pr.ID).
+		Order("created_at ASC").
+		Find(&comments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list review comments: %w", err)
+	}
+	for i := range comments {
+		withSuggestion(&comments[i])
+	}
+	return comments, nil
+}
+
+func (s *reviewCommentService) Create(ctx context.Context, pr *models.PullRequest, userID uuid.UUID, req CreateReviewCommentRequest) (*models.ReviewComment, error) {
+	if strings.TrimSpace(req.Body) == "" {
+		return nil, fmt.Errorf("%w: body is required", ErrInvalidReviewComment)
+	}
+
+	comment := &models.ReviewComment{
+		ID:            uuid.New(),
+		PullRequestID: pr.ID,
+		UserID:        &userID,
+		Body:          req.Body,
+	}
+
+	// Replies inherit the position of the comment they answer
+	if req.InReplyToID != nil {
+		var parent models.ReviewComment
+		err := s.db.WithContext(ctx).Where("id = ? AND pull_request_id = ?", *req.InReplyToID, pr.ID).First(&parent).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReviewCommentNotFound
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get review comment: %w", err)
+		}
+		comment.InReplyToID = &parent.ID
+		comment.ReviewID = parent.ReviewID
+		comment.CommitSHA = parent.CommitSHA
+		comment.Path = parent.Path
+		comment.Line = parent.Line
+		comment.StartLine = parent.StartLine
+		comment.Side = parent.Side
+		comment.StartSide = parent.StartSide
+		if err := s.db.WithContext(ctx).Create(comment).Error; err != nil {
+			return nil, fmt.Errorf("failed to create review comment: %w", err)
+		}
+		return comment, nil
+	}
+
+	if req.Path == "" || (req.Line == nil && req.Position == nil) {
+		return nil, fmt.Errorf("%w: path and line or position are required", ErrInvalidReviewComment)
+	}
+
+	repoPath, err := s.headRepositoryPath(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+	commitSHA := req.CommitSHA
+	if commitSHA == "" {
+		if commitSHA, err = s.gitService.ResolveSHA(ctx, repoPath, pr.HeadBranch); err != nil {
+			return nil, fmt.Errorf("failed to resolve head branch: %w", err)
+		}
+	}
+
+	if req.Line == nil {
+		line, side, err := s.positionLine(repoPath, pr, commitSHA, req.Path, *req.Position)
+		if err != nil {
+			return nil, err
+		}
+		req.Line, req.Side, req.StartLine = &line, side, nil
+		comment.Position = req.Position
+		comment.OriginalPosition = req.Position
+	}
+	if *req.Line < 1 {
+		return nil, fmt.Errorf("%w: line must be positive", ErrInvalidReviewComment)
+	}
+	side := strings.ToUpper(req.Side)
+	if side == "" {
+		side = "RIGHT"
+	}
+	if side != "LEFT" && side != "RIGHT" {
+		return nil, fmt.Errorf("%w: side must be LEFT or RIGHT", ErrInvalidReviewComment)
+	}
+	startLine := *req.Line
+	if req.StartLine != nil {
+		startLine = *req.StartLine
+	}
+	if startLine < 1 || startLine > *req.Line {
+		return nil, fmt.Errorf("%w: start_line must not be after line", ErrInvalidReviewComment)
+	}
+
+	comment.ReviewID = req.ReviewID
+	comment.CommitSHA = commitSHA
+	comment.Path = req.Path
+	comment.Line = req.Line
+	comment.OriginalLine = req.Line
+	comment.StartLine = &startLine
+	comment.Side = side
+	comment.StartSide = side
+
+	if _, ok := ParseSuggestion(req.Body); ok {
+		if side != "RIGHT" {
+			return nil, fmt.Errorf("%w: suggestions must be made on the RIGHT side", ErrInvalidReviewComment)
+		}
+		lines, err := s.fileLines(ctx, repoPath, commitSHA, req.Path)
+		if err != nil {
+			return nil, err
+		}
+		if *req.Line > lineCount(lines) {
+			return nil, fmt.Errorf("%w: line %d is outside %s", ErrInvalidReviewComment, *req.Line, req.Path)
+		}
+		comment.OriginalLines = strings.Join(lines[startLine-1:*req.Line], "\n")
+	}
+
+	if err := s.db.WithContext(ctx).Create(comment).Error; err != nil {
+		return nil, fmt.Errorf("failed to create review comment: %w", err)
+	}
+	withSuggestion(comment)
+	return comment, nil
+}
+
+func (s *reviewCommentService) ApplySuggestions(ctx context.Context, pr *models.PullRequest, userID uuid.UUID, req ApplySuggestionsRequest) (*git.Commit, error) {
+	if len(req.CommentIDs) == 0 {
+		return nil, fmt.Errorf("%w: no comments given", ErrInvalidSuggestion)
+	}
+	if pr.State != models.PullRequestStateOpen {
+		return nil, fmt.Errorf("%w: pull request is not open", ErrInvalidSuggestion)
+	}
+
+	var comments []models.ReviewComment
+	err := s.db.WithContext(ctx).Preload("User").
+		Where("pull_request_id = ? AND id IN ?", pr.ID, req.CommentIDs).
+		Find(&comments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review comments: %w", err)
+	}
+	if len(comments) != len(uniqueIDs(req.CommentIDs)) {
+		return nil, ErrReviewCommentNotFound
+	}
+
+	var applier models.User
+	if err := s.db.WithContext(ctx).First(&applier, "id = ?", userID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	repoPath, err := s.headRepositoryPath(ctx, pr)
+	if err != nil {
+		return nil, err
+	}
+
+	byPath := map[string][]*models.ReviewComment{}
+	for i := range comments {
+		comment := &comments[i]
+		withSuggestion(comment)
+		switch {
+		case comment.Suggestion == nil || comment.Line == nil:
+			return nil, fmt.Errorf("%w: comment %s has no suggestion", ErrInvalidSuggestion, comment.ID)
+		case comment.SuggestionAppliedSHA != "":
+			return nil, fmt.Errorf("%w: comment %s was already applied", ErrInvalidSuggestion, comment.ID)
+		}
+		byPath[comment.Path] = append(byPath[comment.Path], comment)
+	}
+
+	paths := make([]string, 0, len(byPath))
+	for path := range byPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var files []git.CommitFileEntry
+	for _, path := range paths {
+		lines, err := s.fileLines(ctx, repoPath, pr.HeadBranch, path)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := applyToLines(lines, byPath[path])
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, git.CommitFileEntry{Path: path, Content: []byte(strings.Join(updated, "\n"))})
+	}
+
+	message := req.Message
+	if message == "" {
+		message = "Apply suggestions from code review"
+		if len(comments) == 1 {
+			message = "Apply suggestion from code review"
+		}
+	}
+	if trailers := coAuthorTrailers(&applier, comments); trailers != "" {
+		message = strings.TrimRight(message, "\n") + "\n\n" + trailers
+	}
+
+	commit, err := s.gitService.CommitFiles(ctx, repoPath, git.CommitFilesRequest{
+		Files:   files,
+		Message: message,
+		Branch:  pr.HeadBranch,
+		Author:  git.CommitAuthor{Name: displayName(&applier), Email: applier.Email},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit suggestions: %w", err)
+	}
+
+	now := time.Now()
+	err = s.db.WithContext(ctx).Model(&models.ReviewComment{}).
+		Where("id IN ?", req.CommentIDs).
+		Updates(map[string]interface{}{"suggestion_applied_sha": commit.SHA, "suggestion_applied_at": now}).Error
+	if err != nil {
+		s.logger.WithError(err).WithField("pull_request_id", pr.ID).Warn("Failed to mark suggestions as applied")
+	}
+	return commit, nil
+}
+
+// applyToLines replaces the lines of each suggestion, bottom-up so earlier
+// line numbers stay valid. Suggestions on one file must not overlap.
+func applyToLines(lines []string, comments []*models.ReviewComment) ([]string, error) {
+	sort.Slice(comments, func(i, j int) bool { return startLine(comments[i]) > startLine(comments[j]) })
+
+	for i, comment := range comments {
+		start, end := startLine(comment), *comment.Line
+		if i > 0 && end >= startLine(comments[i-1]) {
+			return nil, fmt.Errorf("%w: suggestions on %s overlap", ErrInvalidSuggestion, comment.Path)
+		}
+		if end > lineCount(lines) || strings.Join(lines[start-1:end], "\n") != comment.OriginalLines {
+			return nil, fmt.Errorf("%w: %s lines %d-%d", ErrSuggestionOutdated, comment.Path, start, end)
+		}
+
+		var replacement []string
+		if *comment.Suggestion != "" {
+			replacement = strings.Split(*comment.Suggestion, "\n")
+		}
+		updated := make([]string, 0, len(lines)-(end-start+1)+len(replacement))
+		updated = append(updated, lines[:start-1]...)
+		updated = append(updated, replacement...)
+		lines = append(updated, lines[end:]...)
+	}
+	return lines, nil
+}
+
+// lineCount ignores the empty element a trailing newline leaves behind
+func lineCount(lines []string) int {
+	if n := len(lines); n > 0 && lines[n-1] == "" {
+		return n - 1
+	}
+	return len(lines)
+}
+
+func startLine(comment *models.ReviewComment) int {
+	if comment.StartLine != nil {
+		return *comment.StartLine
+	}
+	return *comment.Line
+}
+
+// coAuthorTrailers credits every suggestion author other than the applier
+func coAuthorTrailers(applier *models.User, comments []models.ReviewComment) string {
+	seen := map[string]bool{strings.ToLower(applier.Email): true}
+	var trailers []string
+	for _, comment := range comments {
+		if comment.User == nil || seen[strings.ToLower(comment.User.Email)] {
+			continue
+		}
+		seen[strings.ToLower(comment.User.Email)] = true
+		trailers = append(trailers, fmt.Sprintf("Co-authored-by: %s <%s>", displayName(comment.User), comment.User.Email))
+	}
+	return strings.Join(trailers, "\n")
+}
+
+func displayName(user *models.User) string {
+	if user.FullName != "" {
+		return user.FullName
+	}
+	return user.Username
+}
+
+func uniqueIDs(ids []uuid.UUID) map[uuid.UUID]bool {
+	unique := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		unique[id] = true
+	}
+	return unique
+}
+
+// headRepositoryPath returns the repository the pull request's head branch lives in
+// threadRoot returns the first comment of the thread commentID belongs to
+func (s *reviewCommentService) threadRoot(ctx context.Context, pr *models.PullRequest, commentID uuid.UUID) (*models.ReviewComment, error) {
+	var comment models.ReviewComment
+	err := s.db.WithContext(ctx).Where("id = ? AND pull_request_id = ?", commentID, pr.ID).First(&comment).Error
+	for err == nil && comment.InReplyToID != nil {
+		parentID := *comment.InReplyToID
+		comment = models.ReviewComment{}
+		err = s.db.WithContext(ctx).Where("id = ? AND pull_request_id = ?", parentID, pr.ID).First(&comment).Error
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReviewCommentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review comment: %w", err)
+	}
+	return &comment, nil
+}
+
+func (s *reviewCommentService) ResolveThread(ctx context.Context, pr *models.PullRequest, commentID, userID uuid.UUID) (*models.ReviewComment, error) {
+	return s.setResolved(ctx, pr, commentID, userID, true)
+}
+
+func (s *reviewCommentService) UnresolveThread(ctx context.Context, pr *models.PullRequest, commentID, userID uuid.UUID) (*models.ReviewComment, error) {
+	return s.setResolved(ctx, pr, commentID, userID, false)
+}
+
+func (s *reviewCommentService) setResolved(ctx context.Context, pr *models.PullRequest, commentID, userID uuid.UUID, resolved bool) (*models.ReviewComment, error) {
+	root, err := s.threadRoot(ctx, pr, commentID)
+	if err != nil {
+		return nil, err
+	}
+	if root.Resolved == resolved {
+		return s.loadComment(ctx, root.ID)
+	}
+
+	action := models.ReviewThreadUnresolved
+	updates := map[string]interface{}{"resolved": resolved, "resolved_by_id": nil, "resolved_at": nil}
+	if resolved {
+		action = models.ReviewThreadResolved
+		updates["resolved_by_id"] = userID
+		updates["resolved_at"] = time.Now()
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(root).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.ReviewThreadEvent{
+			ID:            uuid.New(),
+			CommentID:     root.ID,
+			PullRequestID: pr.ID,
+			ActorID:       userID,
+			Action:        action,
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update review thread: %w", err)
+	}
+	return s.loadComment(ctx, root.ID)
+}
+
+func (s *reviewCommentService) loadComment(ctx context.Context, id uuid.UUID) (*models.ReviewComment, error) {
+	var comment models.ReviewComment
+	if err := s.db.WithContext(ctx).Preload("User").Preload("ResolvedBy").First(&comment, "id = ?", id).Error; err != nil {
+		return nil, fmt.Errorf("failed to get review comment: %w", err)
+	}
+	withSuggestion(&comment)
+	return &comment, nil
+}
+
+func (s *reviewCommentService) ThreadHistory(ctx context.Context, pr *models.PullRequest, commentID uuid.UUID) ([]*models.ReviewThreadEvent, error) {
+	root, err := s.threadRoot(ctx, pr, commentID)
+	if err != nil {
+		return nil, err
+	}
+	var events []*models.ReviewThreadEvent
+	err = s.db.WithContext(ctx).Preload("Actor").
+		Where("comment_id = ?", root.ID).
+		Order("created_at ASC").
+		Find(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list review thread events: %w", err)
+	}
+	return events, nil
+}
+
+// unresolvedReviewThreads counts the unresolved review threads of each pull request
+func unresolvedReviewThreads(ctx context.Context, db *gorm.DB, prIDs ...uuid.UUID) (map[uuid.UUID]int64, error) {
+	counts := make(map[uuid.UUID]int64, len(prIDs))
+	if len(prIDs) == 0 {
+		return counts, nil
+	}
+	var rows []struct {
+		PullRequestID uuid.UUID
+		Count         int64
+	}
+	err := db.WithContext(ctx).Model(&models.ReviewComment{}).
+		Select("pull_request_id, COUNT(*) AS count").
+		Where("pull_request_id IN ? AND in_reply_to_id IS NULL AND resolved = ?", prIDs, false).
+		Group("pull_request_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to count unresolved review threads: %w", err)
+	}
+	for _, row := range rows {
+		counts[row.PullRequestID] = row.Count
+	}
+	return counts, nil
+}
+
+// positionLine finds the line and side a position in the diff of path
+// between the base branch and commitSHA points at
+func (s *reviewCommentService) positionLine(repoPath string, pr *models.PullRequest, commitSHA, path string, position int) (int, string, error) {
+	comparison, err := s.gitService.CompareRefs(repoPath, pr.BaseBranch, commitSHA)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to compare branches: %w", err)
+	}
+	for _, file := range comparison.Files {
+		if file.Path != path {
+			continue
+		}
+		line, side, ok := DiffPositionLine(file.Patch, position)
+		if !ok {
+			return 0, "", fmt.Errorf("%w: position %d is not a line of the diff of %s", ErrInvalidReviewComment, position, path)
+		}
+		return line, side, nil
+	}
+	return 0, "", fmt.Errorf("%w: %s is not changed by the pull request", ErrInvalidReviewComment, path)
+}
+
+// DiffPositionLine maps a position in a file's unified diff to the line it
+// is on: the new file's line for added and context lines (RIGHT), the old
+// file's for removed ones (LEFT). Position 1 is the line below the first
+// hunk header; later hunk headers count as positions but cannot be
+// commented on.
+func DiffPositionLine(patch string, position int) (int, string, bool) {
+	if position < 1 {
+		return 0, "", false
+	}
+	current := 0
+	oldLine, newLine := 0, 0
+	inHunk := false
+	for _, line := range strings.Split(patch, "\n") {
+		if strings.HasPrefix(line, "@@") {
+			oldLine, newLine = hunkStart(line, "-"), hunkStart(line, "+")
+			if inHunk {
+				current++
+				if current == position {
+					return 0, "", false
+				}
+			}
+			inHunk = true
+			continue
+		}
+		if !inHunk {
+			continue
+		}
+		current++
+		switch {
+		case strings.HasPrefix(line, "+"):
+			if current == position {
+				return newLine, "RIGHT", true
+			}
+			newLine++
+		case strings.HasPrefix(line, "-"):
+			if current == position {
+				return oldLine, "LEFT", true
+			}
+			oldLine++
+		case strings.HasPrefix(line, " "):
+			if current == position {
+				return newLine, "RIGHT", true
+			}
+			oldLine++
+			newLine++
+		default:
+			// "\ No newline at end of file" and the trailing empty line
+			if current == position {
+				return 0, "", false
+			}
+		}
+	}
+	return 0, "", false
+}
+
+// hunkStart reads the start line of one side of a hunk header such as
+// "@@ -12,7 +12,9 @@"; the count is left out for one line ranges
+func hunkStart(header, prefix string) int {
+	for _, field := range strings.Fields(header) {
+		if strings.HasPrefix(field, prefix) {
+			start, _, _ := strings.Cut(field[1:], ",")
+			n, _ := strconv.Atoi(start)
+			return n
+		}
+	}
+	return 0
+}
+
+func (s *reviewCommentService) headRepositoryPath(ctx context.Context, pr *models.PullRequest) (string, error) {
+	repoID := pr.RepositoryID
+	if pr.HeadRepositoryID != nil {
+		repoID = *pr.HeadRepositoryID
+	}
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, repoID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get repository path: %w", err)
+	}
+	return repoPath, nil
+}
+
+// fileLines reads a text file at ref and splits it into lines; a trailing
+// newline shows up as a final empty line and is preserved on rejoin
+func (s *reviewCommentService) fileLines(ctx context.Context, repoPath, ref, path string) ([]string, error) {
+	file, err := s.gitService.GetFile(ctx, repoPath, ref, path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s not found at %s", ErrInvalidSuggestion, path, ref)
+	}
+	if file.Encoding == "base64" {
+		return nil, fmt.Errorf("%w: %s is a binary file", ErrInvalidSuggestion, path)
+	}
+	return strings.Split(file.Content, "\n"), nil
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSuggestion(t *testing.T) {
+	suggestion, ok := ParseSuggestion("Rename this:\r\n```suggestion\r\nfunc Bar() {\r\n```\r\nthanks")
+	assert.True(t, ok)
+	assert.Equal(t, "func Bar() {", suggestion)
+
+	suggestion, ok = ParseSuggestion("```suggestion\n```")
+	assert.True(t, ok)
+	assert.Equal(t, "", suggestion)
+
+	_, ok = ParseSuggestion("```go\nfoo()\n```")
+	assert.False(t, ok)
+	_, ok = ParseSuggestion("```suggestion\nunterminated")
+	assert.False(t, ok)
+}
+
+func TestReviewCommentService_ApplySuggestions(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.PullRequest{}, &models.ReviewComment{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	base := t.TempDir()
+	gitService := git.NewGitService(logger)
+	repoService := NewRepositoryService(db, gitService, logger, base)
+	svc := NewReviewCommentService(db, gitService, repoService, logger)
+
+	author := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", FullName: "Alice Doe"}
+	reviewer := &models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com"}
+	require.NoError(t, db.Create([]*models.User{author, reviewer}).Error)
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: author.ID, OwnerType: models.OwnerTypeUser, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	repoPath := filepath.Join(base, "user", author.ID.String(), "app.git")
+	require.NoError(t, gitService.InitRepository(ctx, repoPath, true))
+	_, err := gitService.CommitFiles(ctx, repoPath, git.CommitFilesRequest{
+		Files:   []git.CommitFileEntry{{Path: "main.go", Content: []byte("package main\n\nfunc foo() {\n\tprintln(\"hi\")\n}\n")}},
+		Message: "initial",
+		Branch:  "feature",
+		Author:  git.CommitAuthor{Name: "Alice", Email: author.Email},
+	})
+	require.NoError(t, err)
+
+	pr := &models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, Number: 1, Title: "Add foo", UserID: &author.ID, HeadBranch: "feature", BaseBranch: "main", State: models.PullRequestStateOpen}
+	require.NoError(t, db.Create(pr).Error)
+
+	line3, line4 := 3, 4
+	rename, err := svc.Create(ctx, pr, reviewer.ID, CreateReviewCommentRequest{
+		Body: "```suggestion\nfunc bar() {\n```", Path: "main.go", Line: &line3,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, rename.Suggestion)
+	assert.Equal(t, "func foo() {", rename.OriginalLines)
+
+	drop, err := svc.Create(ctx, pr, reviewer.ID, CreateReviewCommentRequest{
+		Body: "Not needed\n```suggestion\n```", Path: "main.go", Line: &line4,
+	})
+	require.NoError(t, err)
+
+	span, err := svc.Create(ctx, pr, reviewer.ID, CreateReviewCommentRequest{
+		Body: "```suggestion\nx\n```", Path: "main.go", StartLine: &line3, Line: &line4,
+	})
+	require.NoError(t, err)
+	overlapping := span.ID
+	comments, err := svc.List(ctx, pr)
+	require.NoError(t, err)
+	assert.Len(t, comments, 3)
+
+	_, err = svc.ApplySuggestions(ctx, pr, author.ID, ApplySuggestionsRequest{CommentIDs: []uuid.UUID{rename.ID, overlapping}})
+	assert.ErrorIs(t, err, ErrInvalidSuggestion)
+
+	commit, err := svc.ApplySuggestions(ctx, pr, author.ID, ApplySuggestionsRequest{CommentIDs: []uuid.UUID{rename.ID, drop.ID}})
+	require.NoError(t, err)
+	assert.Equal(t, "Alice Doe", commit.Author.Name)
+	assert.Contains(t, commit.Message, "Apply suggestions from code review\n\nCo-authored-by: bob <bob@example.com>")
+
+	file, err := gitService.GetFile(ctx, repoPath, "feature", "main.go")
+	require.NoError(t, err)
+	assert.Equal(t, "package main\n\nfunc bar() {\n}\n", file.Content)
+
+	var applied models.ReviewComment
+	require.NoError(t, db.First(&applied, "id = ?", rename.ID).Error)
+	assert.Equal(t, commit.SHA, applied.SuggestionAppliedSHA)
+
+	// The overlapping suggestion now targets lines that changed
+	_, err = svc.ApplySuggestions(ctx, pr, author.ID, ApplySuggestionsRequest{CommentIDs: []uuid.UUID{overlapping}})
+	assert.ErrorIs(t, err, ErrSuggestionOutdated)
+
+	_, err = svc.ApplySuggestions(ctx, pr, author.ID, ApplySuggestionsRequest{CommentIDs: []uuid.UUID{rename.ID}})
+	assert.ErrorIs(t, err, ErrInvalidSuggestion)
+}
+
+func TestReviewCommentService_ResolveThreads(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Team{}, &models.TeamMember{}, &models.Repository{}, &models.PullRequest{},
+		&models.Review{}, &models.ReviewComment{}, &models.ReviewThreadEvent{}, &models.BranchProtectionRule{}, &models.PathProtectionRule{},
+		&models.MergeChecklistItem{}, &models.PullRequestChecklistCheck{}, &models.RequiredStatusCheck{},
+		&models.ChangeManagementPolicy{}, &models.PullRequestChangeTicket{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	svc := NewReviewCommentService(db, nil, nil, logger)
+	prService := NewPullRequestService(db, nil, nil, logger, "")
+
+	author := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", PasswordHash: "x"}
+	reviewer := &models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create([]*models.User{author, reviewer}).Error)
+	repo := &models.Repository{ID: uuid.New(), OwnerID: author.ID, OwnerType: models.OwnerTypeUser, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	pr := &models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 1, Title: "Add foo", UserID: &author.ID,
+		HeadBranch: "feature", BaseBranch: "main", State: models.PullRequestStateOpen}
+	require.NoError(t, db.Create(pr).Error)
+	require.NoError(t, db.Create(&models.BranchProtectionRule{ID: uuid.New(), RepositoryID: repo.ID, Pattern: "main", RequireConversationResolution: true}).Error)
+
+	newComment := func(inReplyTo *uuid.UUID) *models.ReviewComment {
+		line := 3
+		comment := &models.ReviewComment{ID: uuid.New(), PullRequestID: pr.ID, UserID: &reviewer.ID, CommitSHA: "abc", Path: "main.go",
+			Line: &line, Side: "RIGHT", StartSide: "RIGHT", Body: "please fix", InReplyToID: inReplyTo}
+		require.NoError(t, db.Create(comment).Error)
+		return comment
+	}
+	first, second := newComment(nil), newComment(nil)
+	reply := newComment(&first.ID)
+
+	fetched, err := prService.Get(ctx, "alice", "app", 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, fetched.UnresolvedReviewThreads, "replies are not threads of their own")
+
+	reqs, err := NewPathProtectionService(db, nil, nil, logger).EvaluatePullRequest(ctx, pr)
+	require.NoError(t, err)
+	assert.False(t, reqs.Satisfied)
+	assert.EqualValues(t, 2, reqs.UnresolvedThreads)
+
+	resolved, err := svc.ResolveThread(ctx, pr, reply.ID, author.ID)
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, resolved.ID, "resolving a reply resolves its thread")
+	assert.True(t, resolved.Resolved)
+	require.NotNil(t, resolved.ResolvedBy)
+	assert.Equal(t, "alice", resolved.ResolvedBy.Username)
+
+	_, err = svc.ResolveThread(ctx, pr, second.ID, reviewer.ID)
+	require.NoError(t, err)
+	_, err = svc.ResolveThread(ctx, pr, second.ID, reviewer.ID)
+	require.NoError(t, err, "resolving twice is a no-op")
+
+	reqs, err = NewPathProtectionService(db, nil, nil, logger).EvaluatePullRequest(ctx, pr)
+	require.NoError(t, err)
+	assert.True(t, reqs.Satisfied)
+
+	reopened, err := svc.UnresolveThread(ctx, pr, first.ID, reviewer.ID)
+	require.NoError(t, err)
+	assert.False(t, reopened.Resolved)
+	assert.Nil(t, reopened.ResolvedByID)
+
+	history, err := svc.ThreadHistory(ctx, pr, reply.ID)
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, models.ReviewThreadResolved, history[0].Action)
+	assert.Equal(t, models.ReviewThreadUnresolved, history[1].Action)
+	require.NotNil(t, history[1].Actor)
+	assert.Equal(t, "bob", history[1].Actor.Username)
+
+	prs, err := prService.List(ctx, repo.ID, PullRequestFilter{})
+	require.NoError(t, err)
+	require.Len(t, prs, 1)
+	assert.EqualValues(t, 1, prs[0].UnresolvedReviewThreads)
+
+	_, err = svc.ResolveThread(ctx, pr, uuid.New(), author.ID)
+	assert.ErrorIs(t, err, ErrReviewCommentNotFound)
+}
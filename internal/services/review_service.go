@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReviewNotFound = errors.New("review not found")
+	ErrInvalidReview  = errors.New("invalid review")
+)
+
+// Review events, named as in GitHub's API
+const (
+	ReviewEventApprove        = "APPROVE"
+	ReviewEventRequestChanges = "REQUEST_CHANGES"
+	ReviewEventComment        = "COMMENT"
+)
+
+// SubmitReviewRequest submits a review with any number of line comments
+type SubmitReviewRequest struct {
+	Event     string                       `json:"event" binding:"required"`
+	Body      string                       `json:"body"`
+	CommitSHA string                       `json:"commit_id"`
+	Comments  []CreateReviewCommentRequest `json:"comments"`
+}
+
+// DismissReviewRequest dismisses an approval or change request
+type DismissReviewRequest struct {
+	Message string `json:"message" binding:"required"`
+}
+
+// ReviewService manages pull request reviews. The latest approval or change
+// request of each reviewer is what branch protection counts.
+type ReviewService interface {
+	List(ctx context.Context, pr *models.PullRequest) ([]*models.Review, error)
+	Get(ctx context.Context, pr *models.PullRequest, reviewID uuid.UUID) (*models.Review, error)
+	// Submit records a review and its line comments together. Authors can
+	// comment on their own pull requests but not approve them or request
+	// changes.
+	Submit(ctx context.Context, pr *models.PullRequest, userID uuid.UUID, req SubmitReviewRequest) (*models.Review, error)
+	// Dismiss withdraws an approval or change request
+	Dismiss(ctx context.Context, pr *models.PullRequest, reviewID, userID uuid.UUID, req DismissReviewRequest) (*models.Review, error)
+	ListComments(ctx context.Context, pr *models.PullRequest, reviewID uuid.UUID) ([]models.ReviewComment, error)
+}
+
+type reviewService struct {
+	db          *gorm.DB
+	gitService  git.GitService
+	repoService RepositoryService
+	logger      *logrus.Logger
+}
+
+// NewReviewService creates a new ReviewService
+func NewReviewService(db *gorm.DB, gitService git.GitService, repoService RepositoryService, logger *logrus.Logger) ReviewService {
+	return &reviewService{db: db, gitService: gitService, repoService: repoService, logger: logger}
+}
+
+func (s *reviewService) List(ctx context.Context, pr *models.PullRequest) ([]*models.Review, error) {
+	var reviews []*models.Review
+	err := s.db.WithContext(ctx).Preload("User").
+		Where("pull_request_id = ? AND state <> ?", pr.ID, models.ReviewStatePending).
+		Order("submitted_at ASC").
+		Find(&reviews).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reviews: %w", err)
+	}
+	return reviews, nil
+}
+
+func (s *reviewService) Get(ctx context.Context, pr *models.PullRequest, reviewID uuid.UUID) (*models.Review, error) {
+	var review models.Review
+	err := s.db.WithContext(ctx).Preload("User").
+		Where("id = ? AND pull_request_id = ?", reviewID, pr.ID).
+		First(&review).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReviewNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review: %w", err)
+	}
+	return &review, nil
+}
+
+func (s *reviewService) Submit(ctx context.Context, pr *models.PullRequest, userID uuid.UUID, req SubmitReviewRequest) (*models.Review, error) {
+	var state models.ReviewState
+	switch strings.ToUpper(req.Event) {
+	case ReviewEventApprove:
+		state = models.ReviewStateApproved
+	case ReviewEventRequestChanges:
+		state = models.ReviewStateRequestChanges
+		if strings.TrimSpace(req.Body) == "" {
+			return nil, fmt.Errorf("%w: requesting changes needs a body", ErrInvalidReview)
+		}
+	case ReviewEventComment:
+		state = models.ReviewStateCommented
+		if strings.TrimSpace(req.Body) == "" && len(req.Comments) == 0 {
+			return nil, fmt.Errorf("%w: a comment review needs a body or line comments", ErrInvalidReview)
+		}
+	default:
+		return nil, fmt.Errorf("%w: event must be APPROVE, REQUEST_CHANGES or COMMENT", ErrInvalidReview)
+	}
+	if pr.State != models.PullRequestStateOpen {
+		return nil, fmt.Errorf("%w: pull request is %s", ErrInvalidReview, pr.State)
+	}
+	if state != models.ReviewStateCommented && pr.UserID != nil && *pr.UserID == userID {
+		return nil, fmt.Errorf("%w: authors cannot approve or request changes on their own pull requests", ErrInvalidReview)
+	}
+
+	commitSHA := req.CommitSHA
+	if commitSHA == "" {
+		comments := &reviewCommentService{db: s.db, gitService: s.gitService, repoService: s.repoService, logger: s.logger}
+		repoPath, err := comments.headRepositoryPath(ctx, pr)
+		if err != nil {
+			return nil, err
+		}
+		if commitSHA, err = s.gitService.ResolveSHA(ctx, repoPath, pr.HeadBranch); err != nil {
+			return nil, fmt.Errorf("failed to resolve head branch: %w", err)
+		}
+	}
+
+	now := time.Now()
+	review := &models.Review{
+		ID:            uuid.New(),
+		PullRequestID: pr.ID,
+		UserID:        &userID,
+		CommitSHA:     commitSHA,
+		State:         state,
+		Body:          req.Body,
+		SubmittedAt:   &now,
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(review).Error; err != nil {
+			return fmt.Errorf("failed to create review: %w", err)
+		}
+		comments := &reviewCommentService{db: tx, gitService: s.gitService, repoService: s.repoService, logger: s.logger}
+		for _, comment := range req.Comments {
+			comment.ReviewID = &review.ID
+			if comment.CommitSHA == "" {
+				comment.CommitSHA = commitSHA
+			}
+			if _, err := comments.Create(ctx, pr, userID, comment); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"pull_request_id": pr.ID,
+		"review_id":       review.ID,
+		"state":           state,
+	}).Info("Review submitted")
+	return s.Get(ctx, pr, review.ID)
+}
+
+func (s *reviewService) Dismiss(ctx context.Context, pr *models.PullRequest, reviewID, userID uuid.UUID, req DismissReviewRequest) (*models.Review, error) {
+	if strings.TrimSpace(req.Message) == "" {
+		return nil, fmt.Errorf("%w: a dismissal needs a message", ErrInvalidReview)
+	}
+	review, err := s.Get(ctx, pr, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.State != models.ReviewStateApproved && review.State != models.ReviewStateRequestChanges {
+		return nil, fmt.Errorf("%w: only approvals and change requests can be dismissed", ErrInvalidReview)
+	}
+
+	err = s.db.WithContext(ctx).Model(review).Updates(map[string]interface{}{
+		"state":             models.ReviewStateDismissed,
+		"dismissed_by_id":   userID,
+		"dismissed_at":      time.Now(),
+		"dismissal_message": req.Message,
+	}).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to dismiss review: %w", err)
+	}
+	return s.Get(ctx, pr, reviewID)
+}
+
+func (s *reviewService) ListComments(ctx context.Context, pr *models.PullRequest, reviewID uuid.UUID) ([]models.ReviewComment, error) {
+	if _, err := s.Get(ctx, pr, reviewID); err != nil {
+		return nil, err
+	}
+	var comments []models.ReviewComment
+	err := s.db.WithContext(ctx).Preload("User").
+		Where("pull_request_id = ? AND review_id = ?", pr.ID, reviewID).
+		Order("created_at ASC").
+		Find(&comments).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list review comments: %w", err)
+	}
+	for i := range comments {
+		withSuggestion(&comments[i])
+	}
+	return comments, nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffPositionLine(t *testing.T) {
+	patch := "@@ -1,3 +1,4 @@\n package main\n-func foo() {}\n+func bar() {}\n+func baz() {}\n \n@@ -10,2 +11,2 @@\n-x\n+y\n"
+
+	cases := []struct {
+		position int
+		line     int
+		side     string
+		ok       bool
+	}{
+		{1, 1, "RIGHT", true},
+		{2, 2, "LEFT", true},
+		{3, 2, "RIGHT", true},
+		{5, 4, "RIGHT", true},
+		{6, 0, "", false}, // the second hunk header
+		{7, 10, "LEFT", true},
+		{8, 11, "RIGHT", true},
+		{9, 0, "", false},
+		{0, 0, "", false},
+	}
+	for _, tc := range cases {
+		line, side, ok := DiffPositionLine(patch, tc.position)
+		assert.Equal(t, tc.ok, ok, "position %d", tc.position)
+		if tc.ok {
+			assert.Equal(t, tc.line, line, "position %d", tc.position)
+			assert.Equal(t, tc.side, side, "position %d", tc.position)
+		}
+	}
+}
+
+func TestReviewService_SubmitAndDismiss(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Team{}, &models.TeamMember{}, &models.Repository{}, &models.PullRequest{},
+		&models.Review{}, &models.ReviewComment{}, &models.BranchProtectionRule{}, &models.PathProtectionRule{},
+		&models.MergeChecklistItem{}, &models.PullRequestChecklistCheck{}, &models.RequiredStatusCheck{},
+		&models.ChangeManagementPolicy{}, &models.PullRequestChangeTicket{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	base := t.TempDir()
+	gitService := git.NewGitService(logger)
+	repoService := NewRepositoryService(db, gitService, logger, base)
+	svc := NewReviewService(db, gitService, repoService, logger)
+	protection := NewPathProtectionService(db, gitService, repoService, logger)
+
+	author := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", PasswordHash: "x"}
+	bob := &models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com", PasswordHash: "x"}
+	carol := &models.User{ID: uuid.New(), Username: "carol", Email: "carol@example.com", PasswordHash: "x"}
+	require.NoError(t, db.Create([]*models.User{author, bob, carol}).Error)
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: author.ID, OwnerType: models.OwnerTypeUser, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	repoPath := filepath.Join(base, "user", author.ID.String(), "app.git")
+	require.NoError(t, gitService.InitRepository(ctx, repoPath, true))
+	commit := func(branch, content string) string {
+		c, err := gitService.CommitFiles(ctx, repoPath, git.CommitFilesRequest{
+			Files:   []git.CommitFileEntry{{Path: "main.go", Content: []byte(content)}},
+			Message: "update",
+			Branch:  branch,
+			Author:  git.CommitAuthor{Name: "Alice", Email: author.Email},
+		})
+		require.NoError(t, err)
+		return c.SHA
+	}
+	commit("main", "package main\n\nfunc foo() {}\n")
+	require.NoError(t, gitService.CreateBranch(ctx, repoPath, "feature", "main"))
+	firstSHA := commit("feature", "package main\n\nfunc bar() {}\n")
+
+	pr := &models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 1, Title: "Rename foo", UserID: &author.ID,
+		HeadBranch: "feature", BaseBranch: "main", State: models.PullRequestStateOpen}
+	require.NoError(t, db.Create(pr).Error)
+	require.NoError(t, db.Create(&models.BranchProtectionRule{ID: uuid.New(), RepositoryID: repo.ID, Pattern: "main",
+		RequiredPullRequestReviews: `{"required_approving_review_count":1,"dismiss_stale_reviews":true}`}).Error)
+
+	_, err := svc.Submit(ctx, pr, author.ID, SubmitReviewRequest{Event: ReviewEventApprove})
+	assert.ErrorIs(t, err, ErrInvalidReview, "authors cannot approve their own pull requests")
+	_, err = svc.Submit(ctx, pr, bob.ID, SubmitReviewRequest{Event: ReviewEventRequestChanges})
+	assert.ErrorIs(t, err, ErrInvalidReview, "requesting changes needs a body")
+	_, err = svc.Submit(ctx, pr, bob.ID, SubmitReviewRequest{Event: "MERGE"})
+	assert.ErrorIs(t, err, ErrInvalidReview)
+
+	// Position 4 is the added line, after two context lines and the removal
+	position := 4
+	changes, err := svc.Submit(ctx, pr, bob.ID, SubmitReviewRequest{
+		Event: ReviewEventRequestChanges,
+		Body:  "Please keep the old name",
+		Comments: []CreateReviewCommentRequest{{
+			Body: "Why rename?", Path: "main.go", Position: &position,
+		}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.ReviewStateRequestChanges, changes.State)
+	assert.Equal(t, firstSHA, changes.CommitSHA)
+
+	comments, err := svc.ListComments(ctx, pr, changes.ID)
+	require.NoError(t, err)
+	require.Len(t, comments, 1)
+	require.NotNil(t, comments[0].Line)
+	assert.Equal(t, 3, *comments[0].Line)
+	assert.Equal(t, "RIGHT", comments[0].Side)
+
+	_, err = svc.Submit(ctx, pr, carol.ID, SubmitReviewRequest{Event: ReviewEventApprove})
+	require.NoError(t, err)
+
+	reqs, err := protection.EvaluatePullRequest(ctx, pr)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reqs.Approvals)
+	assert.False(t, reqs.Satisfied)
+	assert.Contains(t, reqs.Reasons, "changes requested by bob")
+
+	_, err = svc.Dismiss(ctx, pr, changes.ID, author.ID, DismissReviewRequest{Message: "Discussed offline"})
+	require.NoError(t, err)
+	dismissed, err := svc.Get(ctx, pr, changes.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ReviewStateDismissed, dismissed.State)
+	assert.Equal(t, "Discussed offline", dismissed.DismissalMessage)
+	require.NotNil(t, dismissed.DismissedByID)
+	assert.Equal(t, author.ID, *dismissed.DismissedByID)
+	_, err = svc.Dismiss(ctx, pr, changes.ID, author.ID, DismissReviewRequest{Message: "again"})
+	assert.ErrorIs(t, err, ErrInvalidReview)
+
+	reqs, err = protection.EvaluatePullRequest(ctx, pr)
+	require.NoError(t, err)
+	assert.True(t, reqs.Satisfied)
+
+	// A new push makes carol's approval stale
+	commit("feature", "package main\n\nfunc baz() {}\n")
+	reqs, err = protection.EvaluatePullRequest(ctx, pr)
+	require.NoError(t, err)
+	assert.Equal(t, 0, reqs.Approvals)
+	assert.False(t, reqs.Satisfied)
+
+	reviews, err := svc.List(ctx, pr)
+	require.NoError(t, err)
+	assert.Len(t, reviews, 2)
+}
@@ -0,0 +1,599 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RunnerService manages self-hosted runners, their organization groups and
+// the queue of jobs routed to them by label
+type RunnerService interface {
+	// Registration
+	CreateRegistrationToken(ctx context.Context, target RunnerTarget, actorID *uuid.UUID) (string, *models.RunnerRegistrationToken, error)
+	Register(ctx context.Context, req RegisterRunnerRequest) (*models.Runner, string, error)
+	Authenticate(ctx context.Context, token string) (*models.Runner, error)
+	Heartbeat(ctx context.Context, runner *models.Runner, busy bool) error
+
+	// Runner management
+	ListRunners(ctx context.Context, target RunnerTarget) ([]*models.Runner, error)
+	GetRunner(ctx context.Context, target RunnerTarget, runnerID uuid.UUID) (*models.Runner, error)
+	RemoveRunner(ctx context.Context, target RunnerTarget, runnerID uuid.UUID) error
+	SetRunnerGroup(ctx context.Context, orgID, runnerID uuid.UUID, groupID *uuid.UUID) (*models.Runner, error)
+
+	// Runner groups
+	ListGroups(ctx context.Context, orgID uuid.UUID) ([]*models.RunnerGroup, error)
+	CreateGroup(ctx context.Context, orgID uuid.UUID, req RunnerGroupRequest) (*models.RunnerGroup, error)
+	UpdateGroup(ctx context.Context, orgID, groupID uuid.UUID, req RunnerGroupRequest) (*models.RunnerGroup, error)
+	DeleteGroup(ctx context.Context, orgID, groupID uuid.UUID) error
+	SetGroupRepositories(ctx context.Context, orgID, groupID uuid.UUID, repositoryNames []string) (*models.RunnerGroup, error)
+
+	// Job routing
+	EnqueueJob(ctx context.Context, repoID uuid.UUID, labels []string, payload string) (*models.RunnerJob, error)
+	AcquireJob(ctx context.Context, runner *models.Runner) (*models.RunnerJob, error)
+	CompleteJob(ctx context.Context, runner *models.Runner, jobID uuid.UUID, succeeded bool) (*models.RunnerJob, error)
+	// Subscribe registers a listener called when a job is assigned to a
+	// runner and when it completes
+	Subscribe(listener RunnerJobListener)
+}
+
+// RunnerJobListener is called with a job after its status changed. It runs
+// before the runner gets its response, so jobs the listener enqueues can be
+// acquired right away.
+type RunnerJobListener func(ctx context.Context, job *models.RunnerJob)
+
+// RunnerTarget identifies the scope a runner is registered at
+type RunnerTarget struct {
+	Scope          models.RunnerScope
+	OrganizationID *uuid.UUID
+	RepositoryID   *uuid.UUID
+}
+
+// RegisterRunnerRequest is sent by a runner exchanging a registration token
+// for its own credentials
+type RegisterRunnerRequest struct {
+	Token         string     `json:"token" binding:"required"`
+	Name          string     `json:"name" binding:"required"`
+	Labels        []string   `json:"labels"`
+	OS            string     `json:"os"`
+	Architecture  string     `json:"architecture"`
+	Version       string     `json:"version"`
+	RunnerGroupID *uuid.UUID `json:"runner_group_id"`
+}
+
+// RunnerGroupRequest creates or updates a runner group
+type RunnerGroupRequest struct {
+	Name                    *string                       `json:"name"`
+	Visibility              *models.RunnerGroupVisibility `json:"visibility"`
+	AllowPublicRepositories *bool                         `json:"allow_public_repositories"`
+}
+
+var (
+	ErrRunnerNotFound          = errors.New("runner not found")
+	ErrRunnerGroupNotFound     = errors.New("runner group not found")
+	ErrRunnerJobNotFound       = errors.New("runner job not found")
+	ErrInvalidRunnerToken      = errors.New("invalid or expired runner token")
+	ErrInvalidRunnerTarget     = errors.New("invalid runner scope")
+	ErrInvalidRunnerGroup      = errors.New("invalid runner group")
+	ErrRunnerGroupRepoNotInOrg = errors.New("repository does not belong to the organization")
+	ErrRunnerJobNotAssigned    = errors.New("job is not assigned to this runner")
+)
+
+// runnerRegistrationTTL is how long a registration token may be exchanged
+const runnerRegistrationTTL = time.Hour
+
+type runnerService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+	now    func() time.Time
+
+	mu        sync.RWMutex
+	listeners []RunnerJobListener
+}
+
+// NewRunnerService creates a new RunnerService
+func NewRunnerService(db *gorm.DB, logger *logrus.Logger) RunnerService {
+	return &runnerService{db: db, logger: logger, now: time.Now}
+}
+
+func (s *runnerService) Subscribe(listener RunnerJobListener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *runnerService) emit(ctx context.Context, job *models.RunnerJob) {
+	s.mu.RLock()
+	listeners := make([]RunnerJobListener, len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(ctx, job)
+	}
+}
+
+func hashRunnerToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (t RunnerTarget) validate() error {
+	switch t.Scope {
+	case models.RunnerScopeRepository:
+		if t.RepositoryID == nil {
+			return ErrInvalidRunnerTarget
+		}
+	case models.RunnerScopeOrganization:
+		if t.OrganizationID == nil {
+			return ErrInvalidRunnerTarget
+		}
+	case models.RunnerScopeInstance:
+	default:
+		return ErrInvalidRunnerTarget
+	}
+	return nil
+}
+
+// scoped restricts a runner query to target
+func (t RunnerTarget) scoped(query *gorm.DB) *gorm.DB {
+	query = query.Where("scope = ?", t.Scope)
+	switch t.Scope {
+	case models.RunnerScopeRepository:
+		query = query.Where("repository_id = ?", *t.RepositoryID)
+	case models.RunnerScopeOrganization:
+		query = query.Where("organization_id = ?", *t.OrganizationID)
+	}
+	return query
+}
+
+func normalizeRunnerLabels(labels []string) []string {
+	seen := make(map[string]bool, len(labels))
+	normalized := make([]string, 0, len(labels))
+	for _, l := range labels {
+		l = strings.ToLower(strings.TrimSpace(l))
+		if l == "" || seen[l] {
+			continue
+		}
+		seen[l] = true
+		normalized = append(normalized, l)
+	}
+	return normalized
+}
+
+func (s *runnerService) CreateRegistrationToken(ctx context.Context, target RunnerTarget, actorID *uuid.UUID) (string, *models.RunnerRegistrationToken, error) {
+	if err := target.validate(); err != nil {
+		return "", nil, err
+	}
+
+	token, err := generateSecureToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	record := &models.RunnerRegistrationToken{
+		ID:             uuid.New(),
+		Scope:          target.Scope,
+		OrganizationID: target.OrganizationID,
+		RepositoryID:   target.RepositoryID,
+		TokenHash:      hashRunnerToken(token),
+		ExpiresAt:      s.now().Add(runnerRegistrationTTL),
+		CreatedByID:    actorID,
+	}
+	if err := s.db.WithContext(ctx).Create(record).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to create registration token: %w", err)
+	}
+	return token, record, nil
+}
+
+// Register consumes a registration token and creates a runner. The returned
+// token authenticates the runner for heartbeats and job acquisition.
+func (s *runnerService) Register(ctx context.Context, req RegisterRunnerRequest) (*models.Runner, string, error) {
+	runnerToken, err := generateSecureToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+
+	var runner *models.Runner
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var registration models.RunnerRegistrationToken
+		if err := tx.Where("token_hash = ? AND expires_at > ?", hashRunnerToken(req.Token), s.now()).First(&registration).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRunnerToken
+			}
+			return err
+		}
+
+		now := s.now()
+		runner = &models.Runner{
+			ID:             uuid.New(),
+			Name:           req.Name,
+			Scope:          registration.Scope,
+			OrganizationID: registration.OrganizationID,
+			RepositoryID:   registration.RepositoryID,
+			Labels:         normalizeRunnerLabels(append([]string{"self-hosted", req.OS, req.Architecture}, req.Labels...)),
+			OS:             req.OS,
+			Architecture:   req.Architecture,
+			Version:        req.Version,
+			LastSeenAt:     &now,
+			TokenHash:      hashRunnerToken(runnerToken),
+		}
+
+		if req.RunnerGroupID != nil {
+			if runner.Scope != models.RunnerScopeOrganization {
+				return ErrInvalidRunnerGroup
+			}
+			var group models.RunnerGroup
+			if err := tx.Where("id = ? AND organization_id = ?", *req.RunnerGroupID, *runner.OrganizationID).First(&group).Error; err != nil {
+				return ErrInvalidRunnerGroup
+			}
+			runner.RunnerGroupID = &group.ID
+		}
+
+		if err := tx.Create(runner).Error; err != nil {
+			return err
+		}
+		// Registration tokens are single use. A concurrent registration may
+		// have consumed the token since it was read, in which case this one
+		// fails and its runner is rolled back.
+		result := tx.Where("id = ?", registration.ID).Delete(&models.RunnerRegistrationToken{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidRunnerToken
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidRunnerToken) || errors.Is(err, ErrInvalidRunnerGroup) {
+			return nil, "", err
+		}
+		return nil, "", fmt.Errorf("failed to register runner: %w", err)
+	}
+
+	runner.Status = runner.ComputeStatus(s.now())
+	s.logger.WithFields(logrus.Fields{
+		"runner_id": runner.ID,
+		"scope":     runner.Scope,
+		"labels":    runner.Labels,
+	}).Info("Registered self-hosted runner")
+	return runner, runnerToken, nil
+}
+
+func (s *runnerService) Authenticate(ctx context.Context, token string) (*models.Runner, error) {
+	if token == "" {
+		return nil, ErrInvalidRunnerToken
+	}
+	var runner models.Runner
+	if err := s.db.WithContext(ctx).Where("token_hash = ?", hashRunnerToken(token)).First(&runner).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidRunnerToken
+		}
+		return nil, err
+	}
+	return &runner, nil
+}
+
+func (s *runnerService) Heartbeat(ctx context.Context, runner *models.Runner, busy bool) error {
+	now := s.now()
+	err := s.db.WithContext(ctx).Model(&models.Runner{}).Where("id = ?", runner.ID).
+		Updates(map[string]interface{}{"last_seen_at": now, "busy": busy}).Error
+	if err != nil {
+		return fmt.Errorf("failed to record heartbeat: %w", err)
+	}
+	runner.LastSeenAt = &now
+	runner.Busy = busy
+	runner.Status = runner.ComputeStatus(now)
+	return nil
+}
+
+func (s *runnerService) ListRunners(ctx context.Context, target RunnerTarget) ([]*models.Runner, error) {
+	if err := target.validate(); err != nil {
+		return nil, err
+	}
+
+	var runners []*models.Runner
+	if err := target.scoped(s.db.WithContext(ctx)).Order("name ASC").Find(&runners).Error; err != nil {
+		return nil, fmt.Errorf("failed to list runners: %w", err)
+	}
+	now := s.now()
+	for _, r := range runners {
+		r.Status = r.ComputeStatus(now)
+	}
+	return runners, nil
+}
+
+func (s *runnerService) GetRunner(ctx context.Context, target RunnerTarget, runnerID uuid.UUID) (*models.Runner, error) {
+	if err := target.validate(); err != nil {
+		return nil, err
+	}
+
+	var runner models.Runner
+	if err := target.scoped(s.db.WithContext(ctx)).Where("id = ?", runnerID).First(&runner).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRunnerNotFound
+		}
+		return nil, err
+	}
+	runner.Status = runner.ComputeStatus(s.now())
+	return &runner, nil
+}
+
+// RemoveRunner deletes a runner and requeues any job it was running
+func (s *runnerService) RemoveRunner(ctx context.Context, target RunnerTarget, runnerID uuid.UUID) error {
+	runner, err := s.GetRunner(ctx, target, runnerID)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.RunnerJob{}).
+			Where("runner_id = ? AND status = ?", runner.ID, models.RunnerJobStatusAssigned).
+			Updates(map[string]interface{}{"status": models.RunnerJobStatusQueued, "runner_id": nil, "assigned_at": nil}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(runner).Error
+	})
+}
+
+func (s *runnerService) SetRunnerGroup(ctx context.Context, orgID, runnerID uuid.UUID, groupID *uuid.UUID) (*models.Runner, error) {
+	runner, err := s.GetRunner(ctx, RunnerTarget{Scope: models.RunnerScopeOrganization, OrganizationID: &orgID}, runnerID)
+	if err != nil {
+		return nil, err
+	}
+	if groupID != nil {
+		if _, err := s.getGroup(ctx, orgID, *groupID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Model(runner).Update("runner_group_id", groupID).Error; err != nil {
+		return nil, fmt.Errorf("failed to update runner group: %w", err)
+	}
+	runner.RunnerGroupID = groupID
+	return runner, nil
+}
+
+func (s *runnerService) getGroup(ctx context.Context, orgID, groupID uuid.UUID) (*models.RunnerGroup, error) {
+	var group models.RunnerGroup
+	err := s.db.WithContext(ctx).Preload("Repositories").
+		Where("id = ? AND organization_id = ?", groupID, orgID).First(&group).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRunnerGroupNotFound
+		}
+		return nil, err
+	}
+	return &group, nil
+}
+
+func (s *runnerService) ListGroups(ctx context.Context, orgID uuid.UUID) ([]*models.RunnerGroup, error) {
+	var groups []*models.RunnerGroup
+	if err := s.db.WithContext(ctx).Preload("Repositories").Where("organization_id = ?", orgID).Order("name ASC").Find(&groups).Error; err != nil {
+		return nil, fmt.Errorf("failed to list runner groups: %w", err)
+	}
+	return groups, nil
+}
+
+func (s *runnerService) CreateGroup(ctx context.Context, orgID uuid.UUID, req RunnerGroupRequest) (*models.RunnerGroup, error) {
+	if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidRunnerGroup)
+	}
+
+	group := &models.RunnerGroup{
+		ID:             uuid.New(),
+		OrganizationID: orgID,
+		Name:           strings.TrimSpace(*req.Name),
+		Visibility:     models.RunnerGroupVisibilityAll,
+	}
+	if err := applyRunnerGroupRequest(group, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Create(group).Error; err != nil {
+		return nil, fmt.Errorf("failed to create runner group: %w", err)
+	}
+	return group, nil
+}
+
+func (s *runnerService) UpdateGroup(ctx context.Context, orgID, groupID uuid.UUID, req RunnerGroupRequest) (*models.RunnerGroup, error) {
+	group, err := s.getGroup(ctx, orgID, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyRunnerGroupRequest(group, req); err != nil {
+		return nil, err
+	}
+	if err := s.db.WithContext(ctx).Omit("Repositories").Save(group).Error; err != nil {
+		return nil, fmt.Errorf("failed to update runner group: %w", err)
+	}
+	return group, nil
+}
+
+func applyRunnerGroupRequest(group *models.RunnerGroup, req RunnerGroupRequest) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" {
+			return fmt.Errorf("%w: name is required", ErrInvalidRunnerGroup)
+		}
+		group.Name = name
+	}
+	if req.Visibility != nil {
+		switch *req.Visibility {
+		case models.RunnerGroupVisibilityAll, models.RunnerGroupVisibilitySelected:
+			group.Visibility = *req.Visibility
+		default:
+			return fmt.Errorf("%w: visibility must be 'all' or 'selected'", ErrInvalidRunnerGroup)
+		}
+	}
+	if req.AllowPublicRepositories != nil {
+		group.AllowPublicRepositories = *req.AllowPublicRepositories
+	}
+	return nil
+}
+
+// DeleteGroup removes a group; its runners fall back to serving every
+// repository of the organization
+func (s *runnerService) DeleteGroup(ctx context.Context, orgID, groupID uuid.UUID) error {
+	group, err := s.getGroup(ctx, orgID, groupID)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Runner{}).Where("runner_group_id = ?", group.ID).Update("runner_group_id", nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(group).Association("Repositories").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(group).Error
+	})
+}
+
+// SetGroupRepositories replaces the repositories a "selected" group may serve
+func (s *runnerService) SetGroupRepositories(ctx context.Context, orgID, groupID uuid.UUID, repositoryNames []string) (*models.RunnerGroup, error) {
+	group, err := s.getGroup(ctx, orgID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	repos := make([]models.Repository, 0, len(repositoryNames))
+	if len(repositoryNames) > 0 {
+		err := s.db.WithContext(ctx).
+			Where("owner_id = ? AND owner_type = ? AND name IN ?", orgID, models.OwnerTypeOrganization, repositoryNames).
+			Find(&repos).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up repositories: %w", err)
+		}
+		unique := make(map[string]bool, len(repositoryNames))
+		for _, name := range repositoryNames {
+			unique[name] = true
+		}
+		if len(repos) != len(unique) {
+			return nil, ErrRunnerGroupRepoNotInOrg
+		}
+	}
+
+	if err := s.db.WithContext(ctx).Model(group).Association("Repositories").Replace(repos); err != nil {
+		return nil, fmt.Errorf("failed to update runner group repositories: %w", err)
+	}
+	group.Repositories = repos
+	return group, nil
+}
+
+func (s *runnerService) EnqueueJob(ctx context.Context, repoID uuid.UUID, labels []string, payload string) (*models.RunnerJob, error) {
+	job := &models.RunnerJob{
+		ID:           uuid.New(),
+		RepositoryID: repoID,
+		Labels:       normalizeRunnerLabels(labels),
+		Payload:      payload,
+		Status:       models.RunnerJobStatusQueued,
+	}
+	if err := s.db.WithContext(ctx).Create(job).Error; err != nil {
+		return nil, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	return job, nil
+}
+
+// AcquireJob assigns the oldest queued job the runner is eligible for: the
+// runner must carry every job label and be allowed to serve the job's
+// repository. Returns nil when nothing is available.
+func (s *runnerService) AcquireJob(ctx context.Context, runner *models.Runner) (*models.RunnerJob, error) {
+	query := s.db.WithContext(ctx).Model(&models.RunnerJob{}).
+		Joins("JOIN repositories ON repositories.id = runner_jobs.repository_id").
+		Where("runner_jobs.status = ? AND repositories.deleted_at IS NULL", models.RunnerJobStatusQueued)
+
+	switch runner.Scope {
+	case models.RunnerScopeRepository:
+		query = query.Where("runner_jobs.repository_id = ?", *runner.RepositoryID)
+	case models.RunnerScopeOrganization:
+		query = query.Where("repositories.owner_id = ? AND repositories.owner_type = ?", *runner.OrganizationID, models.OwnerTypeOrganization)
+		if runner.RunnerGroupID != nil {
+			var group models.RunnerGroup
+			if err := s.db.WithContext(ctx).First(&group, "id = ?", *runner.RunnerGroupID).Error; err != nil {
+				return nil, fmt.Errorf("failed to load runner group: %w", err)
+			}
+			if !group.AllowPublicRepositories {
+				query = query.Where("repositories.visibility <> ?", models.VisibilityPublic)
+			}
+			if group.Visibility == models.RunnerGroupVisibilitySelected {
+				query = query.Where("runner_jobs.repository_id IN (?)",
+					s.db.Table("runner_group_repositories").Select("repository_id").Where("runner_group_id = ?", group.ID))
+			}
+		}
+	}
+
+	var candidates []*models.RunnerJob
+	if err := query.Select("runner_jobs.*").Order("runner_jobs.created_at ASC").Limit(100).Find(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("failed to find queued jobs: %w", err)
+	}
+
+	now := s.now()
+	for _, job := range candidates {
+		if !runner.HasLabels(job.Labels) {
+			continue
+		}
+
+		// Claim the job; another runner may have taken it since the query
+		result := s.db.WithContext(ctx).Model(&models.RunnerJob{}).
+			Where("id = ? AND status = ?", job.ID, models.RunnerJobStatusQueued).
+			Updates(map[string]interface{}{"status": models.RunnerJobStatusAssigned, "runner_id": runner.ID, "assigned_at": now})
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to assign job: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+
+		job.Status = models.RunnerJobStatusAssigned
+		job.RunnerID = &runner.ID
+		job.AssignedAt = &now
+		if err := s.Heartbeat(ctx, runner, true); err != nil {
+			s.logger.WithError(err).WithField("runner_id", runner.ID).Warn("Failed to mark runner busy")
+		}
+		s.emit(ctx, job)
+		return job, nil
+	}
+
+	return nil, nil
+}
+
+func (s *runnerService) CompleteJob(ctx context.Context, runner *models.Runner, jobID uuid.UUID, succeeded bool) (*models.RunnerJob, error) {
+	var job models.RunnerJob
+	if err := s.db.WithContext(ctx).First(&job, "id = ?", jobID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRunnerJobNotFound
+		}
+		return nil, err
+	}
+	if job.Status != models.RunnerJobStatusAssigned || job.RunnerID == nil || *job.RunnerID != runner.ID {
+		return nil, ErrRunnerJobNotAssigned
+	}
+
+	now := s.now()
+	job.Status = models.RunnerJobStatusFailed
+	if succeeded {
+		job.Status = models.RunnerJobStatusCompleted
+	}
+	job.CompletedAt = &now
+	if err := s.db.WithContext(ctx).Model(&job).Updates(map[string]interface{}{"status": job.Status, "completed_at": now}).Error; err != nil {
+		return nil, fmt.Errorf("failed to complete job: %w", err)
+	}
+	if err := s.Heartbeat(ctx, runner, false); err != nil {
+		s.logger.WithError(err).WithField("runner_id", runner.ID).Warn("Failed to mark runner idle")
+	}
+	s.emit(ctx, &job)
+	return &job, nil
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeRunnerLabels(t *testing.T) {
+	labels := normalizeRunnerLabels([]string{"self-hosted", "Linux", " linux ", "", "X64", "gpu"})
+	assert.Equal(t, []string{"self-hosted", "linux", "x64", "gpu"}, labels)
+}
+
+func TestRunnerLabelRoutingAndStatus(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	recent := now.Add(-30 * time.Second)
+	stale := now.Add(-5 * time.Minute)
+
+	runner := &models.Runner{Labels: []string{"self-hosted", "linux", "x64"}, LastSeenAt: &recent}
+	assert.True(t, runner.HasLabels([]string{"self-hosted", "Linux"}))
+	assert.True(t, runner.HasLabels(nil))
+	assert.False(t, runner.HasLabels([]string{"linux", "gpu"}))
+
+	assert.Equal(t, models.RunnerStatusOnline, runner.ComputeStatus(now))
+	runner.Busy = true
+	assert.Equal(t, models.RunnerStatusBusy, runner.ComputeStatus(now))
+	runner.LastSeenAt = &stale
+	assert.Equal(t, models.RunnerStatusOffline, runner.ComputeStatus(now))
+	runner.LastSeenAt = nil
+	assert.Equal(t, models.RunnerStatusOffline, runner.ComputeStatus(now))
+}
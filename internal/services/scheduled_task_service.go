@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/jobs"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// scheduledTaskLockTTL is how long a claimed task stays locked without
+	// renewal; a replica that dies mid-run frees its task after at most this long
+	scheduledTaskLockTTL = 5 * time.Minute
+	// scheduledTaskTick is how often each task checks whether it is due
+	scheduledTaskTick = time.Minute
+)
+
+var (
+	ErrScheduledTaskNotFound = errors.New("scheduled task not found")
+	ErrScheduledTaskRunning  = errors.New("scheduled task is already running")
+)
+
+// MaintenanceTask is a periodic task run by the ScheduledTaskService
+type MaintenanceTask struct {
+	Name     string
+	Interval time.Duration
+	// Class is the job class whose maintenance window policy the task
+	// waits for; tasks without one run as soon as they are due
+	Class string
+	Run   func(ctx context.Context) error
+}
+
+// ScheduledTaskStatus is a registered task and its last run
+type ScheduledTaskStatus struct {
+	Name            string     `json:"name"`
+	Class           string     `json:"class,omitempty"`
+	IntervalSeconds int64      `json:"interval_seconds"`
+	Running         bool       `json:"running"`
+	LastStartedAt   *time.Time `json:"last_started_at"`
+	LastFinishedAt  *time.Time `json:"last_finished_at"`
+	LastDurationMS  int64      `json:"last_duration_ms"`
+	LastError       string     `json:"last_error,omitempty"`
+	NextRunAt       time.Time  `json:"next_run_at"`
+}
+
+// ScheduledTaskService runs periodic maintenance tasks on configurable
+// intervals. Every replica runs the scheduler; a due run is claimed with a
+// conditional update of the task's row, so each run happens on exactly
+// one replica and survives restarts of the others.
+type ScheduledTaskService interface {
+	Register(task MaintenanceTask)
+	List(ctx context.Context) ([]*ScheduledTaskStatus, error)
+	// Trigger starts a task now in the background unless it is running
+	Trigger(ctx context.Context, name string) error
+	StartScheduler(ctx context.Context)
+}
+
+type scheduledTaskService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+	holder string
+	now    func() time.Time
+
+	mu    sync.RWMutex
+	tasks map[string]MaintenanceTask
+}
+
+// NewScheduledTaskService creates a new ScheduledTaskService
+func NewScheduledTaskService(db *gorm.DB, logger *logrus.Logger) ScheduledTaskService {
+	return &scheduledTaskService{
+		db:     db,
+		logger: logger,
+		holder: jobs.Holder(),
+		now:    time.Now,
+		tasks:  map[string]MaintenanceTask{},
+	}
+}
+
+// Register adds a task; it must be called before StartScheduler
+func (s *scheduledTaskService) Register(task MaintenanceTask) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tasks[task.Name] = task
+}
+
+func (s *scheduledTaskService) task(name string) (MaintenanceTask, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	task, ok := s.tasks[name]
+	return task, ok
+}
+
+func (s *scheduledTaskService) List(ctx context.Context) ([]*ScheduledTaskStatus, error) {
+	var rows []models.ScheduledTask
+	if err := s.db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load scheduled tasks: %w", err)
+	}
+	byName := make(map[string]models.ScheduledTask, len(rows))
+	for _, row := range rows {
+		byName[row.Name] = row
+	}
+
+	now := s.now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	statuses := make([]*ScheduledTaskStatus, 0, len(s.tasks))
+	for name, task := range s.tasks {
+		row := byName[name]
+		status := &ScheduledTaskStatus{
+			Name:            name,
+			Class:           task.Class,
+			IntervalSeconds: int64(task.Interval / time.Second),
+			Running:         row.LockedUntil != nil && row.LockedUntil.After(now),
+			LastStartedAt:   row.LastStartedAt,
+			LastFinishedAt:  row.LastFinishedAt,
+			LastDurationMS:  row.LastDurationMS,
+			LastError:       row.LastError,
+			NextRunAt:       now,
+		}
+		if row.LastStartedAt != nil && row.LastStartedAt.Add(task.Interval).After(now) {
+			status.NextRunAt = row.LastStartedAt.Add(task.Interval)
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses, nil
+}
+
+func (s *scheduledTaskService) Trigger(ctx context.Context, name string) error {
+	task, ok := s.task(name)
+	if !ok {
+		return ErrScheduledTaskNotFound
+	}
+	claimed, err := s.claim(ctx, task, true)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return ErrScheduledTaskRunning
+	}
+	go s.execute(context.Background(), task)
+	return nil
+}
+
+// StartScheduler checks every task for being due each minute until ctx is
+// cancelled. Tasks are checked independently, so a long run does not hold
+// back the others.
+func (s *scheduledTaskService) StartScheduler(ctx context.Context) {
+	s.mu.RLock()
+	tasks := make([]MaintenanceTask, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, task)
+	}
+	s.mu.RUnlock()
+
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		go func(task MaintenanceTask) {
+			defer wg.Done()
+			ticker := time.NewTicker(scheduledTaskTick)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					s.runIfDue(ctx, task)
+				}
+			}
+		}(task)
+	}
+	wg.Wait()
+}
+
+// runIfDue runs task when its interval has passed since it last started
+// and no other replica claimed it first
+func (s *scheduledTaskService) runIfDue(ctx context.Context, task MaintenanceTask) {
+	due, err := s.due(ctx, task)
+	if err != nil {
+		s.logger.WithError(err).WithField("task", task.Name).Warn("Failed to check scheduled task")
+		return
+	}
+	if !due {
+		return
+	}
+	if task.Class != "" {
+		job := jobs.Job{Name: task.Name, Class: task.Class, Interval: task.Interval}
+		if jobs.DefaultGate().Wait(ctx, job) != nil {
+			return
+		}
+	}
+	claimed, err := s.claim(ctx, task, false)
+	if err != nil {
+		s.logger.WithError(err).WithField("task", task.Name).Warn("Failed to claim scheduled task")
+		return
+	}
+	if claimed {
+		s.execute(ctx, task)
+	}
+}
+
+func (s *scheduledTaskService) due(ctx context.Context, task MaintenanceTask) (bool, error) {
+	var row models.ScheduledTask
+	err := s.db.WithContext(ctx).Where("name = ?", task.Name).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return row.LastStartedAt == nil || !row.LastStartedAt.Add(task.Interval).After(s.now()), nil
+}
+
+// claim locks task for this replica. Unless force is set the task must
+// also be due; the check and the lock are one update, so only one replica
+// wins a run.
+func (s *scheduledTaskService) claim(ctx context.Context, task MaintenanceTask, force bool) (bool, error) {
+	db := s.db.WithContext(ctx)
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ScheduledTask{Name: task.Name}).Error; err != nil {
+		return false, fmt.Errorf("failed to create scheduled task: %w", err)
+	}
+
+	now := s.now()
+	query := db.Model(&models.ScheduledTask{}).
+		Where("name = ? AND (locked_until IS NULL OR locked_until < ?)", task.Name, now)
+	if !force {
+		query = query.Where("last_started_at IS NULL OR last_started_at <= ?", now.Add(-task.Interval))
+	}
+	result := query.Updates(map[string]interface{}{
+		"locked_by":       s.holder,
+		"locked_until":    now.Add(scheduledTaskLockTTL),
+		"last_started_at": now,
+	})
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim scheduled task: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// execute runs a claimed task, renewing its lock until it returns, and
+// records the outcome
+func (s *scheduledTaskService) execute(ctx context.Context, task MaintenanceTask) {
+	logger := s.logger.WithField("task", task.Name)
+	started := s.now()
+
+	renewCtx, stopRenewing := context.WithCancel(ctx)
+	go s.renew(renewCtx, task.Name)
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				event := errorreporting.Default().NewPanicEvent(r)
+				event.Logger = "scheduled_tasks"
+				event.Tags["task"] = task.Name
+				errorreporting.Default().Capture(event)
+				err = fmt.Errorf("task panicked: %v", r)
+			}
+		}()
+		return task.Run(ctx)
+	}()
+	stopRenewing()
+
+	finished := s.now()
+	updates := map[string]interface{}{
+		"last_finished_at": finished,
+		"last_duration_ms": finished.Sub(started).Milliseconds(),
+		"last_error":       "",
+		"locked_by":        "",
+		"locked_until":     nil,
+	}
+	if err != nil {
+		updates["last_error"] = err.Error()
+		logger.WithError(err).Error("Scheduled task failed")
+	} else {
+		logger.WithField("duration", finished.Sub(started)).Info("Scheduled task finished")
+	}
+
+	// The task's own context may be cancelled by now
+	saveCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.db.WithContext(saveCtx).Model(&models.ScheduledTask{}).
+		Where("name = ? AND locked_by = ?", task.Name, s.holder).Updates(updates).Error; err != nil {
+		logger.WithError(err).Error("Failed to record scheduled task run")
+	}
+}
+
+// renew extends the lock of a running task until ctx is cancelled
+func (s *scheduledTaskService) renew(ctx context.Context, name string) {
+	ticker := time.NewTicker(scheduledTaskLockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.db.WithContext(ctx).Model(&models.ScheduledTask{}).
+				Where("name = ? AND locked_by = ?", name, s.holder).
+				Update("locked_until", s.now().Add(scheduledTaskLockTTL)).Error; err != nil && ctx.Err() == nil {
+				s.logger.WithError(err).WithField("task", name).Warn("Failed to renew scheduled task lock")
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledTaskService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.ScheduledTask{})
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	// Two replicas sharing the database
+	replica := func(holder string) *scheduledTaskService {
+		svc := NewScheduledTaskService(db, logrus.New()).(*scheduledTaskService)
+		svc.holder = holder
+		svc.now = func() time.Time { return now }
+		return svc
+	}
+	a, b := replica("a"), replica("b")
+
+	runs := 0
+	failing := MaintenanceTask{Name: "aggregate", Interval: time.Hour, Run: func(ctx context.Context) error {
+		runs++
+		return errors.New("database unavailable")
+	}}
+	panicking := MaintenanceTask{Name: "cleanup", Interval: time.Hour, Run: func(ctx context.Context) error {
+		panic("boom")
+	}}
+	for _, svc := range []*scheduledTaskService{a, b} {
+		svc.Register(failing)
+		svc.Register(panicking)
+	}
+
+	claimed, err := a.claim(ctx, failing, false)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	claimed, err = b.claim(ctx, failing, false)
+	require.NoError(t, err)
+	assert.False(t, claimed, "a run is claimed by one replica only")
+	assert.ErrorIs(t, b.Trigger(ctx, "aggregate"), ErrScheduledTaskRunning)
+	assert.ErrorIs(t, b.Trigger(ctx, "unknown"), ErrScheduledTaskNotFound)
+
+	statuses, err := b.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	assert.Equal(t, "aggregate", statuses[0].Name)
+	assert.True(t, statuses[0].Running)
+	assert.False(t, statuses[1].Running)
+
+	stored := func(name string) models.ScheduledTask {
+		var row models.ScheduledTask
+		require.NoError(t, db.First(&row, "name = ?", name).Error)
+		return row
+	}
+	a.execute(ctx, failing)
+	assert.Equal(t, 1, runs)
+	row := stored("aggregate")
+	assert.Equal(t, "database unavailable", row.LastError)
+	assert.Nil(t, row.LockedUntil)
+	assert.Empty(t, row.LockedBy)
+
+	// Released, but not due again until the interval passed
+	claimed, err = b.claim(ctx, failing, false)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	due, err := b.due(ctx, failing)
+	require.NoError(t, err)
+	assert.False(t, due)
+	now = now.Add(time.Hour)
+	due, err = b.due(ctx, failing)
+	require.NoError(t, err)
+	assert.True(t, due)
+
+	claimed, err = b.claim(ctx, panicking, false)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	b.execute(ctx, panicking)
+	assert.Contains(t, stored("cleanup").LastError, "boom")
+
+	// A replica that died mid-run frees the task once its lock lapses
+	claimed, err = a.claim(ctx, panicking, true)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	claimed, err = b.claim(ctx, panicking, true)
+	require.NoError(t, err)
+	assert.False(t, claimed)
+	now = now.Add(scheduledTaskLockTTL + time.Second)
+	claimed, err = b.claim(ctx, panicking, true)
+	require.NoError(t, err)
+	assert.True(t, claimed)
+	assert.Equal(t, "b", stored("cleanup").LockedBy)
+}
@@ -0,0 +1,1125 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/auth"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSCIMForbidden    = errors.New("only organization owners and admins can manage SCIM provisioning")
+	ErrSCIMUnauthorized = errors.New("invalid SCIM provisioning token")
+	ErrSCIMNotFound     = errors.New("resource not found")
+	ErrSCIMConflict     = errors.New("resource already exists")
+	ErrSCIMInvalid      = errors.New("invalid value")
+	ErrSCIMInvalidPath  = errors.New("invalid path")
+	ErrSCIMFilter       = errors.New("unsupported filter")
+)
+
+// SCIM schema and message URNs (RFC 7643, RFC 7644)
+const (
+	SCIMUserSchema     = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMGroupSchema    = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCIMListSchema     = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMPatchSchema    = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMErrorSchema    = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SCIMProviderSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+// maxSCIMPageSize caps the count of a list request
+const maxSCIMPageSize = 200
+
+type SCIMName struct {
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	Formatted  string `json:"formatted,omitempty"`
+}
+
+type SCIMEmail struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location"`
+}
+
+// SCIMUserResource is the SCIM representation of a provisioned user
+type SCIMUserResource struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id,omitempty"`
+	ExternalID  string      `json:"externalId,omitempty"`
+	UserName    string      `json:"userName"`
+	Name        *SCIMName   `json:"name,omitempty"`
+	DisplayName string      `json:"displayName,omitempty"`
+	Emails      []SCIMEmail `json:"emails,omitempty"`
+	// Active defaults to true when a user is created without it
+	Active *bool     `json:"active,omitempty"`
+	Meta   *SCIMMeta `json:"meta,omitempty"`
+}
+
+// email returns the primary email, falling back to the first one and then
+// to a userName that is an email address
+func (r *SCIMUserResource) email() string {
+	for _, e := range r.Emails {
+		if e.Primary && e.Value != "" {
+			return e.Value
+		}
+	}
+	for _, e := range r.Emails {
+		if e.Value != "" {
+			return e.Value
+		}
+	}
+	if strings.Contains(r.UserName, "@") {
+		return r.UserName
+	}
+	return ""
+}
+
+type SCIMMember struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+}
+
+// SCIMGroupResource is the SCIM representation of a team linked to an
+// identity provider group
+type SCIMGroupResource struct {
+	Schemas     []string     `json:"schemas"`
+	ID          string       `json:"id,omitempty"`
+	ExternalID  string       `json:"externalId,omitempty"`
+	DisplayName string       `json:"displayName"`
+	Members     []SCIMMember `json:"members,omitempty"`
+	Meta        *SCIMMeta    `json:"meta,omitempty"`
+}
+
+type SCIMListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int64       `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+// SCIMPatchOp is one operation of a PATCH request. Value is kept raw since
+// identity providers disagree on its shape.
+type SCIMPatchOp struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// SCIMListOptions selects a page of a list. StartIndex is 1-based and a
+// Count of zero only reports the total.
+type SCIMListOptions struct {
+	Filter     string
+	StartIndex int
+	Count      int
+}
+
+// SCIMService implements SCIM 2.0 provisioning of organization members and
+// team memberships, authenticated by per-organization tokens. Removing a
+// user at the identity provider deactivates it: its memberships are
+// dropped and accounts SCIM created are disabled, but nothing is erased.
+type SCIMService interface {
+	// CreateToken issues a provisioning token and returns its secret, which
+	// is not stored
+	CreateToken(ctx context.Context, orgName string, actorID uuid.UUID, description string) (*models.SCIMToken, string, error)
+	ListTokens(ctx context.Context, orgName string, actorID uuid.UUID) ([]models.SCIMToken, error)
+	DeleteToken(ctx context.Context, orgName string, actorID, tokenID uuid.UUID) error
+	// Authenticate resolves a provisioning token to its organization
+	Authenticate(ctx context.Context, token string) (*models.Organization, error)
+
+	ListUsers(ctx context.Context, org *models.Organization, opts SCIMListOptions) (*SCIMListResponse, error)
+	GetUser(ctx context.Context, org *models.Organization, id string) (*SCIMUserResource, error)
+	CreateUser(ctx context.Context, org *models.Organization, req *SCIMUserResource) (*SCIMUserResource, error)
+	ReplaceUser(ctx context.Context, org *models.Organization, id string, req *SCIMUserResource) (*SCIMUserResource, error)
+	PatchUser(ctx context.Context, org *models.Organization, id string, ops []SCIMPatchOp) (*SCIMUserResource, error)
+	DeleteUser(ctx context.Context, org *models.Organization, id string) error
+
+	ListGroups(ctx context.Context, org *models.Organization, opts SCIMListOptions, withMembers bool) (*SCIMListResponse, error)
+	GetGroup(ctx context.Context, org *models.Organization, id string) (*SCIMGroupResource, error)
+	CreateGroup(ctx context.Context, org *models.Organization, req *SCIMGroupResource) (*SCIMGroupResource, error)
+	ReplaceGroup(ctx context.Context, org *models.Organization, id string, req *SCIMGroupResource) (*SCIMGroupResource, error)
+	PatchGroup(ctx context.Context, org *models.Organization, id string, ops []SCIMPatchOp) (*SCIMGroupResource, error)
+	DeleteGroup(ctx context.Context, org *models.Organization, id string) error
+}
+
+type scimService struct {
+	db             *gorm.DB
+	sessionService *auth.SessionService
+	baseURL        string
+	logger         *logrus.Logger
+	now            func() time.Time
+}
+
+// NewSCIMService creates a new SCIMService
+func NewSCIMService(db *gorm.DB, sessionService *auth.SessionService, baseURL string, logger *logrus.Logger) SCIMService {
+	return &scimService{
+		db:             db,
+		sessionService: sessionService,
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		logger:         logger,
+		now:            time.Now,
+	}
+}
+
+// manageableOrganization returns the organization when actorID is one of
+// its owners or admins
+func (s *scimService) manageableOrganization(ctx context.Context, orgName string, actorID uuid.UUID) (*models.Organization, error) {
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("organization not found: %w", err)
+	}
+	var member models.OrganizationMember
+	err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", org.ID, actorID).First(&member).Error
+	if err != nil || (member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin) {
+		return nil, ErrSCIMForbidden
+	}
+	return &org, nil
+}
+
+func (s *scimService) CreateToken(ctx context.Context, orgName string, actorID uuid.UUID, description string) (*models.SCIMToken, string, error) {
+	org, err := s.manageableOrganization(ctx, orgName, actorID)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := generateSecureToken()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	secret = models.SCIMTokenPrefix + secret
+
+	token := &models.SCIMToken{
+		ID:             uuid.New(),
+		OrganizationID: org.ID,
+		Description:    description,
+		TokenHash:      hashFineGrainedToken(secret),
+		TokenLastEight: secret[len(secret)-8:],
+		CreatedByID:    actorID,
+	}
+	if err := s.db.WithContext(ctx).Create(token).Error; err != nil {
+		return nil, "", fmt.Errorf("failed to create SCIM token: %w", err)
+	}
+	s.logger.WithFields(logrus.Fields{"organization": org.Name, "token_id": token.ID}).Info("SCIM provisioning token created")
+	return token, secret, nil
+}
+
+func (s *scimService) ListTokens(ctx context.Context, orgName string, actorID uuid.UUID) ([]models.SCIMToken, error) {
+	org, err := s.manageableOrganization(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+	var tokens []models.SCIMToken
+	err = s.db.WithContext(ctx).Where("organization_id = ?", org.ID).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+func (s *scimService) DeleteToken(ctx context.Context, orgName string, actorID, tokenID uuid.UUID) error {
+	org, err := s.manageableOrganization(ctx, orgName, actorID)
+	if err != nil {
+		return err
+	}
+	result := s.db.WithContext(ctx).Where("id = ? AND organization_id = ?", tokenID, org.ID).Delete(&models.SCIMToken{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete SCIM token: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSCIMNotFound
+	}
+	return nil
+}
+
+func (s *scimService) Authenticate(ctx context.Context, token string) (*models.Organization, error) {
+	if !strings.HasPrefix(token, models.SCIMTokenPrefix) {
+		return nil, ErrSCIMUnauthorized
+	}
+	var stored models.SCIMToken
+	err := s.db.WithContext(ctx).Where("token_hash = ?", hashFineGrainedToken(token)).First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSCIMUnauthorized
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up SCIM token: %w", err)
+	}
+	var org models.Organization
+	if err := s.db.WithContext(ctx).First(&org, "id = ?", stored.OrganizationID).Error; err != nil {
+		return nil, ErrSCIMUnauthorized
+	}
+	s.db.WithContext(ctx).Model(&stored).Update("last_used_at", s.now())
+	return &org, nil
+}
+
+var scimFilterPattern = regexp.MustCompile(`(?i)^\s*([a-z.]+)\s+eq\s+("(?:[^"\\]|\\.)*")\s*$`)
+
+// parseSCIMFilter parses the `attribute eq "value"` filters identity
+// providers use to look resources up, returning the attribute as written
+// among allowed
+func parseSCIMFilter(filter string, allowed ...string) (string, string, error) {
+	m := scimFilterPattern.FindStringSubmatch(filter)
+	if m == nil {
+		return "", "", fmt.Errorf("%w: only `attribute eq \"value\"` filters are supported", ErrSCIMFilter)
+	}
+	value, err := strconv.Unquote(m[2])
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrSCIMFilter, err)
+	}
+	for _, attr := range allowed {
+		if strings.EqualFold(attr, m[1]) {
+			return attr, value, nil
+		}
+	}
+	return "", "", fmt.Errorf("%w: cannot filter on %s", ErrSCIMFilter, m[1])
+}
+
+// scimPage applies SCIM pagination to query, preloading preload into the
+// page found, and fills in the list envelope
+func scimPage(query *gorm.DB, opts SCIMListOptions, preload string, dest interface{}) (*SCIMListResponse, error) {
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+	opts.StartIndex = max(opts.StartIndex, 1)
+	opts.Count = min(max(opts.Count, 0), maxSCIMPageSize)
+	if opts.Count > 0 {
+		if err := query.Preload(preload).Offset(opts.StartIndex - 1).Limit(opts.Count).Find(dest).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &SCIMListResponse{Schemas: []string{SCIMListSchema}, TotalResults: total, StartIndex: opts.StartIndex}, nil
+}
+
+func (s *scimService) location(kind, id string) string {
+	return s.baseURL + "/scim/v2/" + kind + "/" + id
+}
+
+func (s *scimService) userResource(u *models.SCIMUser) *SCIMUserResource {
+	active := u.Active
+	r := &SCIMUserResource{
+		Schemas:     []string{SCIMUserSchema},
+		ID:          u.ID.String(),
+		ExternalID:  u.ExternalID,
+		UserName:    u.UserName,
+		DisplayName: u.User.FullName,
+		Active:      &active,
+		Meta:        &SCIMMeta{ResourceType: "User", Created: u.CreatedAt, LastModified: u.UpdatedAt, Location: s.location("Users", u.ID.String())},
+	}
+	if u.GivenName != "" || u.FamilyName != "" || u.User.FullName != "" {
+		r.Name = &SCIMName{GivenName: u.GivenName, FamilyName: u.FamilyName, Formatted: u.User.FullName}
+	}
+	if u.User.Email != "" {
+		r.Emails = []SCIMEmail{{Value: u.User.Email, Type: "work", Primary: true}}
+	}
+	return r
+}
+
+func (s *scimService) loadUser(tx *gorm.DB, org *models.Organization, id string) (*models.SCIMUser, error) {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrSCIMNotFound
+	}
+	var u models.SCIMUser
+	err = tx.Preload("User").Where("id = ? AND organization_id = ?", userID, org.ID).First(&u).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSCIMNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SCIM user: %w", err)
+	}
+	return &u, nil
+}
+
+func (s *scimService) ListUsers(ctx context.Context, org *models.Organization, opts SCIMListOptions) (*SCIMListResponse, error) {
+	query := s.db.WithContext(ctx).Model(&models.SCIMUser{}).Where("organization_id = ?", org.ID)
+	if opts.Filter != "" {
+		attr, value, err := parseSCIMFilter(opts.Filter, "userName", "externalId")
+		if err != nil {
+			return nil, err
+		}
+		if attr == "userName" {
+			query = query.Where("LOWER(user_name) = ?", strings.ToLower(value))
+		} else {
+			query = query.Where("external_id = ?", value)
+		}
+	}
+	var users []models.SCIMUser
+	list, err := scimPage(query.Order("created_at ASC"), opts, "User", &users)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM users: %w", err)
+	}
+	resources := make([]*SCIMUserResource, 0, len(users))
+	for i := range users {
+		resources = append(resources, s.userResource(&users[i]))
+	}
+	list.Resources, list.ItemsPerPage = resources, len(resources)
+	return list, nil
+}
+
+func (s *scimService) GetUser(ctx context.Context, org *models.Organization, id string) (*SCIMUserResource, error) {
+	u, err := s.loadUser(s.db.WithContext(ctx), org, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.userResource(u), nil
+}
+
+// checkUserName refuses a userName another provisioned user of the
+// organization already has
+func checkUserName(tx *gorm.DB, orgID uuid.UUID, userName string, except uuid.UUID) error {
+	if strings.TrimSpace(userName) == "" {
+		return fmt.Errorf("%w: userName is required", ErrSCIMInvalid)
+	}
+	var count int64
+	err := tx.Model(&models.SCIMUser{}).
+		Where("organization_id = ? AND LOWER(user_name) = ? AND id <> ?", orgID, strings.ToLower(userName), except).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: userName %s is already provisioned", ErrSCIMConflict, userName)
+	}
+	return nil
+}
+
+func (s *scimService) CreateUser(ctx context.Context, org *models.Organization, req *SCIMUserResource) (*SCIMUserResource, error) {
+	email := req.email()
+	if email == "" {
+		return nil, fmt.Errorf("%w: an email is required", ErrSCIMInvalid)
+	}
+	active := req.Active == nil || *req.Active
+
+	var scimUser models.SCIMUser
+	var disabled []uuid.UUID
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := checkUserName(tx, org.ID, req.UserName, uuid.Nil); err != nil {
+			return err
+		}
+
+		var user models.User
+		err := tx.Where("LOWER(email) = ?", strings.ToLower(email)).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			username, err := provisionedUsername(tx, req.UserName, email)
+			if err != nil {
+				return err
+			}
+			user = models.User{ID: uuid.New(), Username: username, Email: email, EmailVerified: true, IsActive: true}
+			if err := tx.Create(&user).Error; err != nil {
+				return fmt.Errorf("failed to provision user: %w", err)
+			}
+			scimUser = models.SCIMUser{ID: uuid.New(), OrganizationID: org.ID, UserID: user.ID, Provisioned: true}
+		case err != nil:
+			return err
+		default:
+			// A user removed earlier comes back as the same resource
+			err := tx.Unscoped().Where("organization_id = ? AND user_id = ?", org.ID, user.ID).First(&scimUser).Error
+			switch {
+			case err == nil && scimUser.DeletedAt.Valid:
+				scimUser.DeletedAt = gorm.DeletedAt{}
+			case err == nil:
+				return fmt.Errorf("%w: %s is already provisioned", ErrSCIMConflict, email)
+			case !errors.Is(err, gorm.ErrRecordNotFound):
+				return err
+			default:
+				// Existing accounts are only taken over once they joined the
+				// organization themselves
+				var members int64
+				if err := tx.Model(&models.OrganizationMember{}).Where("organization_id = ? AND user_id = ?", org.ID, user.ID).Count(&members).Error; err != nil {
+					return err
+				}
+				if members == 0 {
+					return fmt.Errorf("%w: an account with email %s exists and is not a member of the organization", ErrSCIMConflict, email)
+				}
+				scimUser = models.SCIMUser{ID: uuid.New(), OrganizationID: org.ID, UserID: user.ID}
+			}
+		}
+
+		scimUser.User = user
+		s.applyUser(&scimUser, req)
+		if err := s.saveUser(tx, &scimUser); err != nil {
+			return err
+		}
+		disabled, err = s.setActive(tx, org, &scimUser, active)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.revokeSessions(disabled)
+	s.logger.WithFields(logrus.Fields{"organization": org.Name, "user": scimUser.User.Username, "provisioned": scimUser.Provisioned}).Info("SCIM user provisioned")
+	return s.GetUser(ctx, org, scimUser.ID.String())
+}
+
+// applyUser copies the attributes of req onto a provisioned user. The email
+// and name of accounts SCIM did not create stay under their owner's control.
+func (s *scimService) applyUser(u *models.SCIMUser, req *SCIMUserResource) {
+	u.UserName = req.UserName
+	u.ExternalID = req.ExternalID
+	u.GivenName, u.FamilyName = "", ""
+	if req.Name != nil {
+		u.GivenName, u.FamilyName = req.Name.GivenName, req.Name.FamilyName
+	}
+	if !u.Provisioned {
+		return
+	}
+	if email := req.email(); email != "" {
+		u.User.Email = email
+	}
+	switch {
+	case req.DisplayName != "":
+		u.User.FullName = req.DisplayName
+	case req.Name != nil && req.Name.Formatted != "":
+		u.User.FullName = req.Name.Formatted
+	case req.Name != nil:
+		u.User.FullName = strings.TrimSpace(req.Name.GivenName + " " + req.Name.FamilyName)
+	}
+}
+
+func (s *scimService) saveUser(tx *gorm.DB, u *models.SCIMUser) error {
+	if u.Provisioned {
+		err := tx.Model(&models.User{}).Where("id = ?", u.UserID).
+			Updates(map[string]interface{}{"email": u.User.Email, "full_name": u.User.FullName}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update user: %w", err)
+		}
+	}
+	if err := tx.Unscoped().Omit("User").Save(u).Error; err != nil {
+		return fmt.Errorf("failed to save SCIM user: %w", err)
+	}
+	return nil
+}
+
+// setActive grants or withdraws a provisioned user's place in the
+// organization and returns the accounts it disabled, whose sessions must
+// be revoked once the transaction commits
+func (s *scimService) setActive(tx *gorm.DB, org *models.Organization, u *models.SCIMUser, active bool) ([]uuid.UUID, error) {
+	if active {
+		var member models.OrganizationMember
+		err := tx.Where("organization_id = ? AND user_id = ?", org.ID, u.UserID).First(&member).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			member = models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: u.UserID, Role: s.defaultRole(tx, org.ID)}
+			err = tx.Create(&member).Error
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to add organization member: %w", err)
+		}
+		if u.Provisioned {
+			if err := tx.Model(&models.User{}).Where("id = ?", u.UserID).Update("is_active", true).Error; err != nil {
+				return nil, err
+			}
+		}
+		if !u.Active {
+			u.Active, u.DeactivatedAt = true, nil
+			return nil, tx.Unscoped().Omit("User").Save(u).Error
+		}
+		return nil, nil
+	}
+
+	var member models.OrganizationMember
+	err := tx.Where("organization_id = ? AND user_id = ?", org.ID, u.UserID).First(&member).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil && member.Role == models.OrgRoleOwner {
+		var owners int64
+		if err := tx.Model(&models.OrganizationMember{}).Where("organization_id = ? AND role = ?", org.ID, models.OrgRoleOwner).Count(&owners).Error; err != nil {
+			return nil, err
+		}
+		if owners <= 1 {
+			return nil, fmt.Errorf("%w: the last owner of the organization cannot be deactivated", ErrSCIMInvalid)
+		}
+	}
+	if err := tx.Where("organization_id = ? AND user_id = ?", org.ID, u.UserID).Delete(&models.OrganizationMember{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to remove organization member: %w", err)
+	}
+	teams := tx.Model(&models.Team{}).Select("id").Where("organization_id = ?", org.ID)
+	if err := tx.Where("user_id = ? AND team_id IN (?)", u.UserID, teams).Delete(&models.TeamMember{}).Error; err != nil {
+		return nil, fmt.Errorf("failed to remove team memberships: %w", err)
+	}
+	if err := tx.Model(&models.OrganizationSSOIdentity{}).Where("organization_id = ? AND user_id = ?", org.ID, u.UserID).
+		Update("session_expires_at", s.now()).Error; err != nil {
+		return nil, err
+	}
+
+	var disabled []uuid.UUID
+	if u.Provisioned {
+		if err := tx.Model(&models.User{}).Where("id = ?", u.UserID).Update("is_active", false).Error; err != nil {
+			return nil, err
+		}
+		disabled = append(disabled, u.UserID)
+	}
+	if u.Active || u.DeactivatedAt == nil {
+		now := s.now()
+		u.Active, u.DeactivatedAt = false, &now
+		if err := tx.Unscoped().Omit("User").Save(u).Error; err != nil {
+			return nil, err
+		}
+	}
+	return disabled, nil
+}
+
+// defaultRole is the role new members get: the SSO default when SSO is set
+// up, plain membership otherwise
+func (s *scimService) defaultRole(tx *gorm.DB, orgID uuid.UUID) models.OrganizationRole {
+	var cfg models.OrganizationSSOConfig
+	if err := tx.Select("default_role").Where("organization_id = ?", orgID).First(&cfg).Error; err == nil && cfg.DefaultRole != "" {
+		return cfg.DefaultRole
+	}
+	return models.OrgRoleMember
+}
+
+func (s *scimService) revokeSessions(userIDs []uuid.UUID) {
+	for _, userID := range userIDs {
+		if err := s.sessionService.RevokeUserSessions(userID); err != nil {
+			s.logger.WithError(err).WithField("user_id", userID).Warn("Failed to revoke sessions of deprovisioned user")
+		}
+	}
+}
+
+func (s *scimService) ReplaceUser(ctx context.Context, org *models.Organization, id string, req *SCIMUserResource) (*SCIMUserResource, error) {
+	var disabled []uuid.UUID
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		u, err := s.loadUser(tx, org, id)
+		if err != nil {
+			return err
+		}
+		if err := checkUserName(tx, org.ID, req.UserName, u.ID); err != nil {
+			return err
+		}
+		s.applyUser(u, req)
+		if err := s.saveUser(tx, u); err != nil {
+			return err
+		}
+		active := u.Active
+		if req.Active != nil {
+			active = *req.Active
+		}
+		disabled, err = s.setActive(tx, org, u, active)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.revokeSessions(disabled)
+	return s.GetUser(ctx, org, id)
+}
+
+func (s *scimService) PatchUser(ctx context.Context, org *models.Organization, id string, ops []SCIMPatchOp) (*SCIMUserResource, error) {
+	current, err := s.GetUser(ctx, org, id)
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		if err := patchUser(current, op); err != nil {
+			return nil, err
+		}
+	}
+	return s.ReplaceUser(ctx, org, id, current)
+}
+
+// scimEmailPath matches the email value paths identity providers patch,
+// such as emails[type eq "work"].value
+var scimEmailPath = regexp.MustCompile(`(?i)^emails(\[.*\])?\.value$`)
+
+// patchUser applies a PATCH operation to a user resource. Operations
+// without a path carry an object of attributes to set.
+func patchUser(r *SCIMUserResource, op SCIMPatchOp) error {
+	kind := strings.ToLower(op.Op)
+	if kind != "add" && kind != "replace" && kind != "remove" {
+		return fmt.Errorf("%w: unknown operation %q", ErrSCIMInvalid, op.Op)
+	}
+	if op.Path == "" {
+		if kind == "remove" {
+			return fmt.Errorf("%w: remove requires a path", ErrSCIMInvalidPath)
+		}
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return fmt.Errorf("%w: value must be an object of attributes", ErrSCIMInvalid)
+		}
+		for path, value := range attrs {
+			if err := patchUser(r, SCIMPatchOp{Op: kind, Path: path, Value: value}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// str decodes a string value; removing an attribute clears it
+	str := func(dest *string) error {
+		if kind == "remove" {
+			*dest = ""
+			return nil
+		}
+		if err := json.Unmarshal(op.Value, dest); err != nil {
+			return fmt.Errorf("%w: %s must be a string", ErrSCIMInvalid, op.Path)
+		}
+		return nil
+	}
+	name := func() *SCIMName {
+		if r.Name == nil {
+			r.Name = &SCIMName{}
+		}
+		return r.Name
+	}
+
+	switch path := strings.ToLower(op.Path); {
+	case path == "active":
+		if kind == "remove" {
+			return fmt.Errorf("%w: active cannot be removed", ErrSCIMInvalidPath)
+		}
+		active, err := scimBool(op.Value)
+		if err != nil {
+			return err
+		}
+		r.Active = &active
+	case path == "username":
+		if kind == "remove" {
+			return fmt.Errorf("%w: userName cannot be removed", ErrSCIMInvalidPath)
+		}
+		return str(&r.UserName)
+	case path == "externalid":
+		return str(&r.ExternalID)
+	case path == "displayname":
+		return str(&r.DisplayName)
+	case path == "name":
+		if kind == "remove" {
+			r.Name = nil
+			break
+		}
+		var n SCIMName
+		if err := json.Unmarshal(op.Value, &n); err != nil {
+			return fmt.Errorf("%w: name must be an object", ErrSCIMInvalid)
+		}
+		r.Name = &n
+	case path == "name.givenname":
+		return str(&name().GivenName)
+	case path == "name.familyname":
+		return str(&name().FamilyName)
+	case path == "name.formatted":
+		return str(&name().Formatted)
+	case path == "emails":
+		if kind == "remove" {
+			r.Emails = nil
+			break
+		}
+		var emails []SCIMEmail
+		if err := json.Unmarshal(op.Value, &emails); err != nil {
+			return fmt.Errorf("%w: emails must be a list", ErrSCIMInvalid)
+		}
+		r.Emails = emails
+	case scimEmailPath.MatchString(path):
+		if kind == "remove" {
+			return fmt.Errorf("%w: the email cannot be removed", ErrSCIMInvalidPath)
+		}
+		email := SCIMEmail{Type: "work", Primary: true}
+		if err := str(&email.Value); err != nil {
+			return err
+		}
+		r.Emails = []SCIMEmail{email}
+	default:
+		return fmt.Errorf("%w: %s", ErrSCIMInvalidPath, op.Path)
+	}
+	return nil
+}
+
+// scimBool decodes a boolean, which some identity providers send as the
+// string "True" or "False"
+func scimBool(raw json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(raw, &b); err == nil {
+		return b, nil
+	}
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		if b, err := strconv.ParseBool(str); err == nil {
+			return b, nil
+		}
+	}
+	return false, fmt.Errorf("%w: active must be a boolean", ErrSCIMInvalid)
+}
+
+// DeleteUser deactivates the user and removes the resource; the account
+// itself is kept so the identity provider can provision it again
+func (s *scimService) DeleteUser(ctx context.Context, org *models.Organization, id string) error {
+	var disabled []uuid.UUID
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		u, err := s.loadUser(tx, org, id)
+		if err != nil {
+			return err
+		}
+		if disabled, err = s.setActive(tx, org, u, false); err != nil {
+			return err
+		}
+		return tx.Delete(&models.SCIMUser{}, "id = ?", u.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.revokeSessions(disabled)
+	s.logger.WithFields(logrus.Fields{"organization": org.Name, "scim_user_id": id}).Info("SCIM user deprovisioned")
+	return nil
+}
+
+func (s *scimService) groupResource(tx *gorm.DB, g *models.SCIMGroup, withMembers bool) (*SCIMGroupResource, error) {
+	r := &SCIMGroupResource{
+		Schemas:     []string{SCIMGroupSchema},
+		ID:          g.ID.String(),
+		ExternalID:  g.ExternalID,
+		DisplayName: g.Team.Name,
+		Meta:        &SCIMMeta{ResourceType: "Group", Created: g.CreatedAt, LastModified: g.UpdatedAt, Location: s.location("Groups", g.ID.String())},
+	}
+	if !withMembers {
+		return r, nil
+	}
+	var members []models.SCIMUser
+	err := tx.Joins("JOIN team_members ON team_members.user_id = scim_users.user_id").
+		Where("team_members.team_id = ? AND team_members.deleted_at IS NULL AND scim_users.organization_id = ?", g.TeamID, g.OrganizationID).
+		Order("scim_users.user_name ASC").Find(&members).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to load group members: %w", err)
+	}
+	for _, m := range members {
+		r.Members = append(r.Members, SCIMMember{Value: m.ID.String(), Display: m.UserName})
+	}
+	return r, nil
+}
+
+func (s *scimService) loadGroup(tx *gorm.DB, org *models.Organization, id string) (*models.SCIMGroup, error) {
+	groupID, err := uuid.Parse(id)
+	if err != nil {
+		return nil, ErrSCIMNotFound
+	}
+	var g models.SCIMGroup
+	err = tx.Preload("Team").Where("id = ? AND organization_id = ?", groupID, org.ID).First(&g).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSCIMNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SCIM group: %w", err)
+	}
+	return &g, nil
+}
+
+func (s *scimService) ListGroups(ctx context.Context, org *models.Organization, opts SCIMListOptions, withMembers bool) (*SCIMListResponse, error) {
+	db := s.db.WithContext(ctx)
+	query := db.Model(&models.SCIMGroup{}).Where("scim_groups.organization_id = ?", org.ID)
+	if opts.Filter != "" {
+		attr, value, err := parseSCIMFilter(opts.Filter, "displayName", "externalId")
+		if err != nil {
+			return nil, err
+		}
+		if attr == "displayName" {
+			query = query.Joins("JOIN teams ON teams.id = scim_groups.team_id").Where("teams.name = ?", value)
+		} else {
+			query = query.Where("scim_groups.external_id = ?", value)
+		}
+	}
+	var groups []models.SCIMGroup
+	list, err := scimPage(query.Order("scim_groups.created_at ASC"), opts, "Team", &groups)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM groups: %w", err)
+	}
+	resources := make([]*SCIMGroupResource, 0, len(groups))
+	for i := range groups {
+		r, err := s.groupResource(db, &groups[i], withMembers)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, r)
+	}
+	list.Resources, list.ItemsPerPage = resources, len(resources)
+	return list, nil
+}
+
+func (s *scimService) GetGroup(ctx context.Context, org *models.Organization, id string) (*SCIMGroupResource, error) {
+	db := s.db.WithContext(ctx)
+	g, err := s.loadGroup(db, org, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.groupResource(db, g, true)
+}
+
+// renameTeam gives the team of a group its display name, refusing names of
+// other teams in the organization
+func renameTeam(tx *gorm.DB, team *models.Team, name string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("%w: displayName is required", ErrSCIMInvalid)
+	}
+	if name == team.Name {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.Team{}).Where("organization_id = ? AND name = ? AND id <> ?", team.OrganizationID, name, team.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: team %s already exists", ErrSCIMConflict, name)
+	}
+	team.Name = name
+	return tx.Model(team).Updates(map[string]interface{}{"name": name, "version": gorm.Expr("version + 1")}).Error
+}
+
+// CreateGroup links the team named like the group, creating it when the
+// organization has none
+func (s *scimService) CreateGroup(ctx context.Context, org *models.Organization, req *SCIMGroupResource) (*SCIMGroupResource, error) {
+	name := strings.TrimSpace(req.DisplayName)
+	if name == "" {
+		return nil, fmt.Errorf("%w: displayName is required", ErrSCIMInvalid)
+	}
+	var group models.SCIMGroup
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var team models.Team
+		err := tx.Where("organization_id = ? AND name = ?", org.ID, name).First(&team).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			team = models.Team{ID: uuid.New(), OrganizationID: org.ID, Name: name, Privacy: models.TeamPrivacyClosed}
+			if err := tx.Create(&team).Error; err != nil {
+				return fmt.Errorf("failed to create team: %w", err)
+			}
+		case err != nil:
+			return err
+		default:
+			var linked int64
+			if err := tx.Model(&models.SCIMGroup{}).Where("team_id = ?", team.ID).Count(&linked).Error; err != nil {
+				return err
+			}
+			if linked > 0 {
+				return fmt.Errorf("%w: group %s is already provisioned", ErrSCIMConflict, name)
+			}
+		}
+		group = models.SCIMGroup{ID: uuid.New(), OrganizationID: org.ID, TeamID: team.ID, ExternalID: req.ExternalID, Team: team}
+		if err := tx.Omit("Team").Create(&group).Error; err != nil {
+			return fmt.Errorf("failed to create SCIM group: %w", err)
+		}
+		return s.setMembers(tx, &group, req.Members)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.logger.WithFields(logrus.Fields{"organization": org.Name, "team": name}).Info("SCIM group provisioned")
+	return s.GetGroup(ctx, org, group.ID.String())
+}
+
+// setMembers makes the members of the group's team exactly the given
+// provisioned users. Deactivated users are left out until reactivated.
+func (s *scimService) setMembers(tx *gorm.DB, g *models.SCIMGroup, members []SCIMMember) error {
+	ids := make([]uuid.UUID, 0, len(members))
+	for _, m := range members {
+		id, err := uuid.Parse(m.Value)
+		if err != nil {
+			return fmt.Errorf("%w: unknown member %s", ErrSCIMInvalid, m.Value)
+		}
+		ids = append(ids, id)
+	}
+	var users []models.SCIMUser
+	if len(ids) > 0 {
+		if err := tx.Where("organization_id = ? AND id IN ?", g.OrganizationID, ids).Find(&users).Error; err != nil {
+			return err
+		}
+	}
+	if len(users) != len(uniqueUUIDs(ids)) {
+		return fmt.Errorf("%w: members must be users provisioned to the organization", ErrSCIMInvalid)
+	}
+
+	wanted := make(map[uuid.UUID]bool, len(users))
+	for _, u := range users {
+		if u.Active {
+			wanted[u.UserID] = true
+		}
+	}
+	var current []models.TeamMember
+	if err := tx.Where("team_id = ?", g.TeamID).Find(&current).Error; err != nil {
+		return err
+	}
+	for _, m := range current {
+		if wanted[m.UserID] {
+			delete(wanted, m.UserID)
+			continue
+		}
+		if err := tx.Delete(&models.TeamMember{}, "id = ?", m.ID).Error; err != nil {
+			return fmt.Errorf("failed to remove team member: %w", err)
+		}
+	}
+	for userID := range wanted {
+		member := models.TeamMember{ID: uuid.New(), TeamID: g.TeamID, UserID: userID, Role: models.TeamRoleMember}
+		if err := tx.Create(&member).Error; err != nil {
+			return fmt.Errorf("failed to add team member: %w", err)
+		}
+	}
+	return nil
+}
+
+func uniqueUUIDs(ids []uuid.UUID) map[uuid.UUID]bool {
+	set := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+func (s *scimService) ReplaceGroup(ctx context.Context, org *models.Organization, id string, req *SCIMGroupResource) (*SCIMGroupResource, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		g, err := s.loadGroup(tx, org, id)
+		if err != nil {
+			return err
+		}
+		if err := renameTeam(tx, &g.Team, req.DisplayName); err != nil {
+			return err
+		}
+		g.ExternalID = req.ExternalID
+		if err := tx.Omit("Team").Save(g).Error; err != nil {
+			return err
+		}
+		return s.setMembers(tx, g, req.Members)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetGroup(ctx, org, id)
+}
+
+// scimMemberPath matches the member filter paths of remove operations, as
+// in members[value eq "id"]
+var scimMemberPath = regexp.MustCompile(`(?i)^members\[\s*value\s+eq\s+"([^"]*)"\s*\]$`)
+
+func (s *scimService) PatchGroup(ctx context.Context, org *models.Organization, id string, ops []SCIMPatchOp) (*SCIMGroupResource, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		g, err := s.loadGroup(tx, org, id)
+		if err != nil {
+			return err
+		}
+		current, err := s.groupResource(tx, g, true)
+		if err != nil {
+			return err
+		}
+		members := make(map[string]bool, len(current.Members))
+		for _, m := range current.Members {
+			members[m.Value] = true
+		}
+
+		for _, op := range ops {
+			if err := patchGroup(current, members, op); err != nil {
+				return err
+			}
+		}
+
+		if err := renameTeam(tx, &g.Team, current.DisplayName); err != nil {
+			return err
+		}
+		g.ExternalID = current.ExternalID
+		if err := tx.Omit("Team").Save(g).Error; err != nil {
+			return err
+		}
+		list := make([]SCIMMember, 0, len(members))
+		for value := range members {
+			list = append(list, SCIMMember{Value: value})
+		}
+		return s.setMembers(tx, g, list)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetGroup(ctx, org, id)
+}
+
+// patchGroup applies a PATCH operation to a group resource and its member
+// set, keyed by SCIM user id
+func patchGroup(r *SCIMGroupResource, members map[string]bool, op SCIMPatchOp) error {
+	kind := strings.ToLower(op.Op)
+	decodeMembers := func() ([]SCIMMember, error) {
+		var list []SCIMMember
+		if err := json.Unmarshal(op.Value, &list); err != nil {
+			return nil, fmt.Errorf("%w: members must be a list", ErrSCIMInvalid)
+		}
+		return list, nil
+	}
+
+	switch path := strings.ToLower(op.Path); {
+	case kind != "add" && kind != "replace" && kind != "remove":
+		return fmt.Errorf("%w: unknown operation %q", ErrSCIMInvalid, op.Op)
+	case path == "" && kind != "remove":
+		var attrs map[string]json.RawMessage
+		if err := json.Unmarshal(op.Value, &attrs); err != nil {
+			return fmt.Errorf("%w: value must be an object of attributes", ErrSCIMInvalid)
+		}
+		for attr, value := range attrs {
+			if err := patchGroup(r, members, SCIMPatchOp{Op: kind, Path: attr, Value: value}); err != nil {
+				return err
+			}
+		}
+	case path == "displayname" && kind != "remove":
+		if err := json.Unmarshal(op.Value, &r.DisplayName); err != nil {
+			return fmt.Errorf("%w: displayName must be a string", ErrSCIMInvalid)
+		}
+	case path == "externalid":
+		r.ExternalID = ""
+		if kind != "remove" {
+			if err := json.Unmarshal(op.Value, &r.ExternalID); err != nil {
+				return fmt.Errorf("%w: externalId must be a string", ErrSCIMInvalid)
+			}
+		}
+	case path == "members" && kind == "remove" && len(op.Value) == 0:
+		clear(members)
+	case path == "members":
+		list, err := decodeMembers()
+		if err != nil {
+			return err
+		}
+		if kind == "replace" {
+			clear(members)
+		}
+		for _, m := range list {
+			if kind == "remove" {
+				delete(members, m.Value)
+			} else {
+				members[m.Value] = true
+			}
+		}
+	case kind == "remove" && scimMemberPath.MatchString(op.Path):
+		delete(members, scimMemberPath.FindStringSubmatch(op.Path)[1])
+	default:
+		return fmt.Errorf("%w: %s", ErrSCIMInvalidPath, op.Path)
+	}
+	return nil
+}
+
+// DeleteGroup removes the team linked to the group along with its
+// memberships
+func (s *scimService) DeleteGroup(ctx context.Context, org *models.Organization, id string) error {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		g, err := s.loadGroup(tx, org, id)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("team_id = ?", g.TeamID).Delete(&models.TeamMember{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Team{}, "id = ?", g.TeamID).Error; err != nil {
+			return fmt.Errorf("failed to delete team: %w", err)
+		}
+		return tx.Delete(&models.SCIMGroup{}, "id = ?", g.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+	s.logger.WithFields(logrus.Fields{"organization": org.Name, "scim_group_id": id}).Info("SCIM group deprovisioned")
+	return nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/auth"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSCIMService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{}, &models.Team{},
+		&models.TeamMember{}, &models.OrganizationSSOConfig{}, &models.OrganizationSSOIdentity{},
+		&models.SCIMToken{}, &models.SCIMUser{}, &models.SCIMGroup{}, &auth.Session{})
+	ctx := context.Background()
+	svc := NewSCIMService(db, auth.NewSessionService(db), "https://hub.example.com", logrus.New())
+
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	owner := &models.User{ID: uuid.New(), Username: "owner", Email: "owner@acme.com", IsActive: true}
+	outsider := &models.User{ID: uuid.New(), Username: "outsider", Email: "outsider@example.com", IsActive: true}
+	require.NoError(t, db.Create([]*models.User{owner, outsider}).Error)
+	require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: owner.ID, Role: models.OrgRoleOwner}).Error)
+
+	_, _, err := svc.CreateToken(ctx, "acme", outsider.ID, "okta")
+	assert.ErrorIs(t, err, ErrSCIMForbidden)
+	_, secret, err := svc.CreateToken(ctx, "acme", owner.ID, "okta")
+	require.NoError(t, err)
+	authenticated, err := svc.Authenticate(ctx, secret)
+	require.NoError(t, err)
+	assert.Equal(t, org.ID, authenticated.ID)
+	_, err = svc.Authenticate(ctx, models.SCIMTokenPrefix+"wrong")
+	assert.ErrorIs(t, err, ErrSCIMUnauthorized)
+
+	isMember := func(userID uuid.UUID) bool {
+		var count int64
+		db.Model(&models.OrganizationMember{}).Where("organization_id = ? AND user_id = ?", org.ID, userID).Count(&count)
+		return count > 0
+	}
+	patch := func(op, path, value string) []SCIMPatchOp {
+		return []SCIMPatchOp{{Op: op, Path: path, Value: json.RawMessage(value)}}
+	}
+
+	// New users get an account and membership
+	jane, err := svc.CreateUser(ctx, org, &SCIMUserResource{UserName: "jane@acme.com", ExternalID: "00u1",
+		Name: &SCIMName{GivenName: "Jane", FamilyName: "Doe"}})
+	require.NoError(t, err)
+	assert.True(t, *jane.Active)
+	var janeUser models.User
+	require.NoError(t, db.Where("email = ?", "jane@acme.com").First(&janeUser).Error)
+	assert.Equal(t, "jane", janeUser.Username)
+	assert.Equal(t, "Jane Doe", janeUser.FullName)
+	assert.True(t, isMember(janeUser.ID))
+
+	_, err = svc.CreateUser(ctx, org, &SCIMUserResource{UserName: "JANE@acme.com"})
+	assert.ErrorIs(t, err, ErrSCIMConflict)
+	_, err = svc.CreateUser(ctx, org, &SCIMUserResource{UserName: "outsider@example.com"})
+	assert.ErrorIs(t, err, ErrSCIMConflict, "accounts outside the organization are not taken over")
+	linked, err := svc.CreateUser(ctx, org, &SCIMUserResource{UserName: "owner@acme.com"})
+	require.NoError(t, err, "existing members are linked")
+
+	list, err := svc.ListUsers(ctx, org, SCIMListOptions{Filter: `userName eq "Jane@acme.com"`, Count: 10})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, list.TotalResults)
+	_, err = svc.ListUsers(ctx, org, SCIMListOptions{Filter: `title co "x"`})
+	assert.ErrorIs(t, err, ErrSCIMFilter)
+
+	// Groups manage the membership of the team named like them
+	group, err := svc.CreateGroup(ctx, org, &SCIMGroupResource{DisplayName: "platform",
+		Members: []SCIMMember{{Value: jane.ID}, {Value: linked.ID}}})
+	require.NoError(t, err)
+	assert.Len(t, group.Members, 2)
+	group, err = svc.PatchGroup(ctx, org, group.ID, patch("remove", `members[value eq "`+linked.ID+`"]`, ""))
+	require.NoError(t, err)
+	require.Len(t, group.Members, 1)
+	assert.Equal(t, jane.ID, group.Members[0].Value)
+
+	// Deactivation drops memberships and disables accounts SCIM created
+	jane, err = svc.PatchUser(ctx, org, jane.ID, patch("Replace", "active", `"False"`))
+	require.NoError(t, err)
+	assert.False(t, *jane.Active)
+	assert.False(t, isMember(janeUser.ID))
+	require.NoError(t, db.First(&janeUser, "id = ?", janeUser.ID).Error)
+	assert.False(t, janeUser.IsActive)
+	group, err = svc.GetGroup(ctx, org, group.ID)
+	require.NoError(t, err)
+	assert.Empty(t, group.Members)
+
+	jane, err = svc.PatchUser(ctx, org, jane.ID, patch("replace", "", `{"active": true}`))
+	require.NoError(t, err)
+	assert.True(t, *jane.Active)
+	assert.True(t, isMember(janeUser.ID))
+
+	_, err = svc.PatchUser(ctx, org, linked.ID, patch("replace", "active", "false"))
+	assert.ErrorIs(t, err, ErrSCIMInvalid, "the last owner stays")
+
+	// Deleting keeps the account deactivated; provisioning it again restores it
+	require.NoError(t, svc.DeleteUser(ctx, org, jane.ID))
+	_, err = svc.GetUser(ctx, org, jane.ID)
+	assert.ErrorIs(t, err, ErrSCIMNotFound)
+	require.NoError(t, db.First(&janeUser, "id = ?", janeUser.ID).Error)
+	assert.False(t, janeUser.IsActive)
+	restored, err := svc.CreateUser(ctx, org, &SCIMUserResource{UserName: "jane@acme.com"})
+	require.NoError(t, err)
+	assert.Equal(t, jane.ID, restored.ID)
+	require.NoError(t, db.First(&janeUser, "id = ?", janeUser.ID).Error)
+	assert.True(t, janeUser.IsActive)
+
+	require.NoError(t, svc.DeleteGroup(ctx, org, group.ID))
+	var teams int64
+	db.Model(&models.Team{}).Where("organization_id = ?", org.ID).Count(&teams)
+	assert.Zero(t, teams)
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidSearchQuery is returned for queries with malformed qualifiers
+var ErrInvalidSearchQuery = errors.New("invalid search query")
+
+// Result types of the unified search
+const (
+	SearchTypeRepository   = "repository"
+	SearchTypeIssue        = "issue"
+	SearchTypePullRequest  = "pull_request"
+	SearchTypeUser         = "user"
+	SearchTypeOrganization = "organization"
+	SearchTypeCommit       = "commit"
+)
+
+var searchTypes = []string{SearchTypeRepository, SearchTypeIssue, SearchTypePullRequest, SearchTypeUser, SearchTypeOrganization, SearchTypeCommit}
+
+// searchTypeAliases maps the type names accepted in the type parameter
+var searchTypeAliases = map[string]string{
+	"repository": SearchTypeRepository, "repositories": SearchTypeRepository, "repo": SearchTypeRepository, "repos": SearchTypeRepository,
+	"issue": SearchTypeIssue, "issues": SearchTypeIssue,
+	"pull_request": SearchTypePullRequest, "pull_requests": SearchTypePullRequest, "pr": SearchTypePullRequest, "prs": SearchTypePullRequest, "pulls": SearchTypePullRequest,
+	"user": SearchTypeUser, "users": SearchTypeUser,
+	"organization": SearchTypeOrganization, "organizations": SearchTypeOrganization, "org": SearchTypeOrganization, "orgs": SearchTypeOrganization,
+	"commit": SearchTypeCommit, "commits": SearchTypeCommit,
+}
+
+// SearchQuery is a search string split into free text terms and
+// GitHub-style qualifiers such as repo:owner/name or label:"help wanted".
+// Values of one qualifier are alternatives, except labels, which must all
+// be present.
+type SearchQuery struct {
+	Terms      []string `json:"terms"`
+	Repos      []string `json:"repos,omitempty"`
+	Orgs       []string `json:"orgs,omitempty"`
+	Users      []string `json:"users,omitempty"`
+	Authors    []string `json:"authors,omitempty"`
+	Labels     []string `json:"labels,omitempty"`
+	Languages  []string `json:"languages,omitempty"`
+	States     []string `json:"states,omitempty"`
+	Types      []string `json:"types,omitempty"`
+	Visibility string   `json:"visibility,omitempty"`
+}
+
+// ParseSearchQuery parses raw into terms and qualifiers. Terms and values
+// can be quoted to include spaces; words that look like qualifiers but use
+// an unknown key are searched as text.
+func ParseSearchQuery(raw string) (*SearchQuery, error) {
+	q := &SearchQuery{}
+	for _, token := range splitSearchQuery(raw) {
+		key, value, ok := strings.Cut(token, ":")
+		if !ok || token[0] == '"' {
+			if term := strings.ToLower(strings.Trim(token, `"`)); term != "" {
+				q.Terms = append(q.Terms, term)
+			}
+			continue
+		}
+		key = strings.ToLower(key)
+		value = strings.ToLower(strings.Trim(value, `"`))
+		if !isSearchQualifier(key) {
+			q.Terms = append(q.Terms, strings.ToLower(token))
+			continue
+		}
+		if value == "" {
+			return nil, fmt.Errorf("%w: %s: needs a value", ErrInvalidSearchQuery, key)
+		}
+		if err := q.add(key, value); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+func isSearchQualifier(key string) bool {
+	switch key {
+	case "repo", "org", "user", "author", "label", "language", "state", "is":
+		return true
+	}
+	return false
+}
+
+func (q *SearchQuery) add(key, value string) error {
+	switch key {
+	case "repo":
+		if owner, name, ok := strings.Cut(value, "/"); !ok || owner == "" || name == "" {
+			return fmt.Errorf("%w: repo: must be owner/name", ErrInvalidSearchQuery)
+		}
+		q.Repos = append(q.Repos, value)
+	case "org":
+		q.Orgs = append(q.Orgs, value)
+	case "user":
+		q.Users = append(q.Users, value)
+	case "author":
+		q.Authors = append(q.Authors, value)
+	case "label":
+		q.Labels = append(q.Labels, value)
+	case "language":
+		q.Languages = append(q.Languages, value)
+	case "state":
+		if value != "open" && value != "closed" {
+			return fmt.Errorf("%w: state: must be open or closed", ErrInvalidSearchQuery)
+		}
+		q.States = append(q.States, value)
+	case "is":
+		switch value {
+		case "pr", "pull-request", "pull_request":
+			q.Types = append(q.Types, SearchTypePullRequest)
+		case "issue":
+			q.Types = append(q.Types, SearchTypeIssue)
+		case "open", "closed":
+			q.States = append(q.States, value)
+		case "merged":
+			q.Types = append(q.Types, SearchTypePullRequest)
+			q.States = append(q.States, value)
+		case "public", "private", "internal":
+			q.Visibility = value
+		default:
+			return fmt.Errorf("%w: unknown is:%s", ErrInvalidSearchQuery, value)
+		}
+	}
+	return nil
+}
+
+// IsEmpty reports whether the query has neither terms nor qualifiers
+func (q *SearchQuery) IsEmpty() bool {
+	return len(q.Terms) == 0 && len(q.Repos) == 0 && len(q.Orgs) == 0 && len(q.Users) == 0 && len(q.Authors) == 0 &&
+		len(q.Labels) == 0 && len(q.Languages) == 0 && len(q.States) == 0 && len(q.Types) == 0 && q.Visibility == ""
+}
+
+// resultTypes returns the result types the query covers: the requested
+// type, or all of them, narrowed by is: and by the qualifiers that only
+// make sense for some types
+func (q *SearchQuery) resultTypes(requested string) ([]string, error) {
+	types := searchTypes
+	if requested != "" {
+		canonical, ok := searchTypeAliases[strings.ToLower(requested)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidSearchQuery, requested)
+		}
+		types = []string{canonical}
+	}
+
+	issueQualifiers := len(q.Authors) > 0 || len(q.Labels) > 0 || len(q.States) > 0
+	repositoryQualifiers := len(q.Repos) > 0 || len(q.Orgs) > 0 || len(q.Users) > 0 || len(q.Languages) > 0 || q.Visibility != ""
+	var covered []string
+	for _, t := range types {
+		if len(q.Types) > 0 && !slices.Contains(q.Types, t) {
+			continue
+		}
+		isIssue := t == SearchTypeIssue || t == SearchTypePullRequest
+		if issueQualifiers && !isIssue {
+			continue
+		}
+		if repositoryQualifiers && !isIssue && t != SearchTypeRepository && t != SearchTypeCommit {
+			continue
+		}
+		covered = append(covered, t)
+	}
+	return covered, nil
+}
+
+// splitSearchQuery splits raw on whitespace outside double quotes
+func splitSearchQuery(raw string) []string {
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case !quoted && (r == ' ' || r == '\t' || r == '\n'):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
@@ -3,7 +3,10 @@ package services
 import (
 	"context"
 	"fmt"
+	"math"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/google/uuid"
@@ -11,24 +14,41 @@ import (
 	"gorm.io/gorm"
 )
 
+// searchCandidateLimit caps the matches of each type that are ranked for a
+// search; results beyond it are reported as incomplete
+const searchCandidateLimit = 1000
+
 type SearchService struct {
 	db     *gorm.DB
 	logger *logrus.Logger
 }
 
-// SearchResults represents the aggregated search results
+// SearchResults represents the aggregated search results. Items lists the
+// page's results in rank order and the typed lists hold the same results
+// grouped by type.
 type SearchResults struct {
-	Users         []models.User         `json:"users"`
-	Repositories  []models.Repository   `json:"repositories"`
-	Organizations []models.Organization `json:"organizations"`
-	Commits       []models.Commit       `json:"commits"`
-	TotalCount    int64                 `json:"total_count"`
+	Users             []models.User         `json:"users"`
+	Repositories      []models.Repository   `json:"repositories"`
+	Organizations     []models.Organization `json:"organizations"`
+	Commits           []models.Commit       `json:"commits"`
+	Issues            []models.Issue        `json:"issues"`
+	PullRequests      []models.PullRequest  `json:"pull_requests"`
+	Items             []SearchResultItem    `json:"items"`
+	TotalCount        int64                 `json:"total_count"`
+	IncompleteResults bool                  `json:"incomplete_results"`
+}
+
+// SearchResultItem identifies one ranked result in its typed list
+type SearchResultItem struct {
+	Type  string    `json:"type"`
+	ID    uuid.UUID `json:"id"`
+	Score float64   `json:"score"`
 }
 
 // SearchFilter represents search filtering options
 type SearchFilter struct {
 	Query     string     `json:"query"`
-	Type      string     `json:"type"`      // user, repository, organization, commit, code
+	Type      string     `json:"type"`      // repository, issue, pull_request, user, organization, commit
 	Sort      string     `json:"sort"`      // relevance, created, updated, stars, forks
 	Direction string     `json:"direction"` // asc, desc
 	Page      int        `json:"page"`
@@ -36,6 +56,16 @@ type SearchFilter struct {
 	UserID    *uuid.UUID `json:"user_id,omitempty"` // For permission filtering
 }
 
+// searchHit is a ranked candidate with the fields results can be sorted by
+type searchHit struct {
+	item    SearchResultItem
+	created time.Time
+	updated time.Time
+	stars   int
+	forks   int
+	value   interface{}
+}
+
 func NewSearchService(db *gorm.DB, elasticsearch interface{}, logger *logrus.Logger) *SearchService {
 	return &SearchService{
 		db:     db,
@@ -43,165 +73,349 @@ func NewSearchService(db *gorm.DB, elasticsearch interface{}, logger *logrus.Log
 	}
 }
 
-// GlobalSearch performs a global search across all content types
+// GlobalSearch searches every type the query covers, ranks the matches
+// together and returns the requested page. Queries take GitHub-style
+// qualifiers, see ParseSearchQuery.
 func (s *SearchService) GlobalSearch(ctx context.Context, filter SearchFilter) (*SearchResults, error) {
-	if filter.Query == "" {
+	query, err := ParseSearchQuery(filter.Query)
+	if err != nil {
+		return nil, err
+	}
+	if query.IsEmpty() {
 		return nil, fmt.Errorf("search query cannot be empty")
 	}
-	results := &SearchResults{}
+	types, err := query.resultTypes(filter.Type)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PerPage < 1 || filter.PerPage > 100 {
+		filter.PerPage = 30
+	}
 
-	// Simple database search
-	switch filter.Type {
-	case "user":
-		users, err := s.searchUsers(filter, (filter.Page-1)*filter.PerPage)
+	candidates := filter
+	candidates.PerPage = searchCandidateLimit
+	results := &SearchResults{Items: []SearchResultItem{}}
+	var hits []*searchHit
+	for _, t := range types {
+		found, err := s.searchType(ctx, t, query, candidates)
 		if err != nil {
 			return nil, err
 		}
-		results.Users = users
-	case "repository":
-		repos, err := s.searchRepositories(filter, (filter.Page-1)*filter.PerPage)
+		if len(found) == searchCandidateLimit {
+			results.IncompleteResults = true
+		}
+		hits = append(hits, found...)
+	}
+	sortSearchHits(hits, filter.Sort, filter.Direction)
+
+	results.TotalCount = int64(len(hits))
+	start := (filter.Page - 1) * filter.PerPage
+	if start >= len(hits) {
+		return results, nil
+	}
+	end := min(start+filter.PerPage, len(hits))
+	for _, hit := range hits[start:end] {
+		results.Items = append(results.Items, hit.item)
+		switch v := hit.value.(type) {
+		case models.Repository:
+			results.Repositories = append(results.Repositories, v)
+		case models.Issue:
+			results.Issues = append(results.Issues, v)
+		case models.PullRequest:
+			results.PullRequests = append(results.PullRequests, v)
+		case models.User:
+			results.Users = append(results.Users, v)
+		case models.Organization:
+			results.Organizations = append(results.Organizations, v)
+		case models.Commit:
+			results.Commits = append(results.Commits, v)
+		}
+	}
+	return results, nil
+}
+
+// searchType finds and scores the candidates of one result type
+func (s *SearchService) searchType(ctx context.Context, t string, query *SearchQuery, filter SearchFilter) ([]*searchHit, error) {
+	scoped := &SearchService{db: s.db.WithContext(ctx), logger: s.logger}
+	terms := query.Terms
+	var hits []*searchHit
+	switch t {
+	case SearchTypeRepository:
+		repos, err := scoped.searchRepositories(filter, 0)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to search repositories: %w", err)
+		}
+		for _, repo := range repos {
+			score := searchScore(terms, 3, repo.Name) + searchScore(terms, 1, repo.Description) + math.Log1p(float64(repo.StarsCount))/4
+			hits = append(hits, &searchHit{item: SearchResultItem{Type: t, ID: repo.ID, Score: score},
+				created: repo.CreatedAt, updated: repo.UpdatedAt, stars: repo.StarsCount, forks: repo.ForksCount, value: repo})
 		}
-		results.Repositories = repos
-	case "organization":
-		orgs, err := s.searchOrganizations(filter, (filter.Page-1)*filter.PerPage)
+	case SearchTypeIssue:
+		issues, err := scoped.searchIssues(filter, 0)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to search issues: %w", err)
+		}
+		for _, issue := range issues {
+			score := searchScore(terms, 2, issue.Title) + searchScore(terms, 1, issue.Body)
+			hits = append(hits, &searchHit{item: SearchResultItem{Type: t, ID: issue.ID, Score: score},
+				created: issue.CreatedAt, updated: issue.UpdatedAt, value: issue})
 		}
-		results.Organizations = orgs
-	case "commit":
-		commits, err := s.searchCommits(filter, (filter.Page-1)*filter.PerPage)
+	case SearchTypePullRequest:
+		pulls, err := scoped.searchPullRequests(filter, 0)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to search pull requests: %w", err)
 		}
-		results.Commits = commits
-	default:
-		// Search all types for a general search
-		users, _ := s.searchUsers(filter, 0)
-		repos, _ := s.searchRepositories(filter, 0)
-		orgs, _ := s.searchOrganizations(filter, 0)
-		commits, _ := s.searchCommits(filter, 0)
-
-		// Limit results for overview
-		if len(users) > 5 {
-			users = users[:5]
+		for _, pr := range pulls {
+			score := searchScore(terms, 2, pr.Title) + searchScore(terms, 1, pr.Body)
+			hits = append(hits, &searchHit{item: SearchResultItem{Type: t, ID: pr.ID, Score: score},
+				created: pr.CreatedAt, updated: pr.UpdatedAt, value: pr})
+		}
+	case SearchTypeUser:
+		users, err := scoped.searchUsers(filter, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search users: %w", err)
 		}
-		if len(repos) > 5 {
-			repos = repos[:5]
+		for _, user := range users {
+			score := searchScore(terms, 3, user.Username) + searchScore(terms, 2, user.FullName) +
+				searchScore(terms, 1, user.Email) + searchScore(terms, 1, user.Bio) + searchScore(terms, 1, user.Company)
+			hits = append(hits, &searchHit{item: SearchResultItem{Type: t, ID: user.ID, Score: score},
+				created: user.CreatedAt, updated: user.UpdatedAt, value: user})
 		}
-		if len(orgs) > 5 {
-			orgs = orgs[:5]
+	case SearchTypeOrganization:
+		orgs, err := scoped.searchOrganizations(filter, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search organizations: %w", err)
 		}
-		if len(commits) > 5 {
-			commits = commits[:5]
+		for _, org := range orgs {
+			score := searchScore(terms, 3, org.Name) + searchScore(terms, 2, org.DisplayName) + searchScore(terms, 1, org.Description)
+			hits = append(hits, &searchHit{item: SearchResultItem{Type: t, ID: org.ID, Score: score},
+				created: org.CreatedAt, updated: org.UpdatedAt, value: org})
+		}
+	case SearchTypeCommit:
+		commits, err := scoped.searchCommits(filter, 0)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search commits: %w", err)
+		}
+		for _, commit := range commits {
+			score := searchScore(terms, 1, commit.Message) + searchScore(terms, 1, commit.AuthorName)
+			hits = append(hits, &searchHit{item: SearchResultItem{Type: t, ID: commit.ID, Score: score},
+				created: commit.CreatedAt, updated: commit.UpdatedAt, value: commit})
 		}
-
-		results.Users = users
-		results.Repositories = repos
-		results.Organizations = orgs
-		results.Commits = commits
 	}
-
-	results.TotalCount = int64(len(results.Users) + len(results.Repositories) + len(results.Organizations) + len(results.Commits))
-	return results, nil
+	return hits, nil
 }
 
-func (s *SearchService) searchUsers(filter SearchFilter, offset int) ([]models.User, error) {
-	var users []models.User
-	query := s.db.Model(&models.User{})
+// searchScore scores how well text matches terms: an exact match counts
+// most, then a prefix, then a match anywhere, all scaled by weight
+func searchScore(terms []string, weight float64, text string) float64 {
+	text = strings.ToLower(text)
+	score := 0.0
+	for _, term := range terms {
+		switch {
+		case text == term:
+			score += 4 * weight
+		case strings.HasPrefix(text, term):
+			score += 2 * weight
+		case strings.Contains(text, term):
+			score += weight
+		}
+	}
+	return score
+}
 
-	if filter.Query != "" {
-		q := "%" + strings.ToLower(filter.Query) + "%"
-		query = query.Where(
-			"lower(username) LIKE ? OR lower(full_name) LIKE ? OR lower(email) LIKE ? OR lower(bio) LIKE ? OR lower(company) LIKE ?",
-			q, q, q, q, q,
-		)
+// sortSearchHits orders hits by relevance, or by the requested sort field,
+// breaking ties by the most recently updated
+func sortSearchHits(hits []*searchHit, sort, direction string) {
+	sign := -1
+	if direction == "asc" {
+		sign = 1
 	}
+	slices.SortStableFunc(hits, func(a, b *searchHit) int {
+		var c int
+		switch sort {
+		case "created":
+			c = sign * a.created.Compare(b.created)
+		case "updated":
+			c = sign * a.updated.Compare(b.updated)
+		case "stars":
+			c = sign * (a.stars - b.stars)
+		case "forks":
+			c = sign * (a.forks - b.forks)
+		default:
+			if a.item.Score != b.item.Score {
+				c = -1
+				if a.item.Score < b.item.Score {
+					c = 1
+				}
+			}
+		}
+		if c == 0 {
+			c = b.updated.Compare(a.updated)
+		}
+		if c == 0 {
+			c = strings.Compare(a.item.ID.String(), b.item.ID.String())
+		}
+		return c
+	})
+}
 
-	switch filter.Sort {
-	case "created":
-		if filter.Direction == "asc" {
-			query = query.Order("created_at ASC")
-		} else {
-			query = query.Order("created_at DESC")
+// matchSearchTerms requires every term to appear in at least one of columns
+func matchSearchTerms(query *gorm.DB, terms []string, columns ...string) *gorm.DB {
+	for _, term := range terms {
+		like := "%" + escapeLikePattern(term) + "%"
+		conditions := make([]string, len(columns))
+		args := make([]interface{}, len(columns))
+		for i, column := range columns {
+			conditions[i] = "LOWER(" + column + ") LIKE ? ESCAPE '\\'"
+			args[i] = like
 		}
-	default:
-		query = query.Order("username ASC")
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
 	}
-
-	query = query.Offset(offset).Limit(filter.PerPage)
-	return users, query.Find(&users).Error
+	return query
 }
 
-func (s *SearchService) searchRepositories(filter SearchFilter, offset int) ([]models.Repository, error) {
-	var repos []models.Repository
-	query := s.db.Model(&models.Repository{})
-
-	// Only show public repositories for unauthenticated users
-	if filter.UserID == nil {
-		query = query.Where("visibility = 'public'")
-	} else {
-		query = query.Where("visibility = 'public' OR owner_id = ?", *filter.UserID)
+// readableRepositories narrows repos, a query on repositories, to those
+// userID can read; anonymous searches only see public repositories and
+// external collaborators only those shared with them
+func readableRepositories(db, repos *gorm.DB, userID *uuid.UUID) *gorm.DB {
+	if userID == nil {
+		return repos.Where("visibility = ?", models.VisibilityPublic)
+	}
+	if external, err := isExternalUser(db, *userID); err != nil || external {
+		shared := db.Model(&models.RepositoryPermission{}).Select("repository_id").
+			Where("subject_type = ? AND subject_id = ?", models.SubjectTypeUser, *userID)
+		return repos.Where("id IN (?)", shared)
 	}
+	memberships := db.Model(&models.OrganizationMember{}).Select("organization_id").Where("user_id = ?", *userID)
+	administered := db.Model(&models.OrganizationMember{}).Select("organization_id").
+		Where("user_id = ? AND role IN ?", *userID, []models.OrganizationRole{models.OrgRoleOwner, models.OrgRoleAdmin})
+	teamIDs := db.Model(&models.TeamMember{}).Select("team_id").Where("user_id = ?", *userID)
+	granted := db.Model(&models.RepositoryPermission{}).Select("repository_id").
+		Where("(subject_type = ? AND subject_id = ?) OR (subject_type = ? AND subject_id IN (?))",
+			models.SubjectTypeUser, *userID, models.SubjectTypeTeam, teamIDs)
+	return repos.Where("visibility = ? OR (visibility = ? AND owner_id IN (?)) OR owner_id = ? OR owner_id IN (?) OR id IN (?)",
+		models.VisibilityPublic, models.VisibilityInternal, memberships, *userID, administered, granted)
+}
 
-	if filter.Query != "" {
-		q := "%" + strings.ToLower(filter.Query) + "%"
-		query = query.Where(
-			"lower(name) LIKE ? OR lower(description) LIKE ?",
-			q, q,
-		)
+// repositoryScope returns the readable repositories the query's repo:,
+// org:, user:, language: and is: qualifiers select
+func (s *SearchService) repositoryScope(query *SearchQuery, userID *uuid.UUID) *gorm.DB {
+	repos := readableRepositories(s.db, s.db.Model(&models.Repository{}), userID)
+	userIDs := func(names []string) *gorm.DB {
+		return s.db.Model(&models.User{}).Select("id").Where("LOWER(username) IN ?", names)
+	}
+	orgIDs := func(names []string) *gorm.DB {
+		return s.db.Model(&models.Organization{}).Select("id").Where("LOWER(name) IN ?", names)
 	}
 
-	switch filter.Sort {
-	case "updated":
-		if filter.Direction == "asc" {
-			query = query.Order("updated_at ASC")
-		} else {
-			query = query.Order("updated_at DESC")
+	if len(query.Repos) > 0 {
+		conditions := make([]string, len(query.Repos))
+		var args []interface{}
+		for i, fullName := range query.Repos {
+			owner, name, _ := strings.Cut(fullName, "/")
+			conditions[i] = "(LOWER(name) = ? AND (owner_id IN (?) OR owner_id IN (?)))"
+			args = append(args, name, userIDs([]string{owner}), orgIDs([]string{owner}))
 		}
-	case "stars":
-		query = query.Order("stars_count DESC")
-	case "forks":
-		query = query.Order("forks_count DESC")
-	default:
-		query = query.Order("name ASC")
+		repos = repos.Where("("+strings.Join(conditions, " OR ")+")", args...)
 	}
+	if len(query.Orgs) > 0 {
+		repos = repos.Where("owner_type = ? AND owner_id IN (?)", models.OwnerTypeOrganization, orgIDs(query.Orgs))
+	}
+	if len(query.Users) > 0 {
+		repos = repos.Where("owner_type = ? AND owner_id IN (?)", models.OwnerTypeUser, userIDs(query.Users))
+	}
+	if len(query.Languages) > 0 {
+		languages := s.db.Model(&models.RepositoryLanguage{}).Select("repository_id").Where("LOWER(language) IN ?", query.Languages)
+		repos = repos.Where("id IN (?)", languages)
+	}
+	if query.Visibility != "" {
+		repos = repos.Where("visibility = ?", query.Visibility)
+	}
+	return repos
+}
 
-	query = query.Offset(offset).Limit(filter.PerPage)
-	return repos, query.Find(&repos).Error
+// issueQualifiers applies the state:, author: and label: qualifiers to
+// issues or pull requests, whose labels live in labelTable
+func (s *SearchService) issueQualifiers(items *gorm.DB, query *SearchQuery, labelTable, labelColumn string) *gorm.DB {
+	if len(query.States) > 0 {
+		items = items.Where("state IN ?", query.States)
+	}
+	if len(query.Authors) > 0 {
+		items = items.Where("user_id IN (?)", s.db.Model(&models.User{}).Select("id").Where("LOWER(username) IN ?", query.Authors))
+	}
+	for _, label := range query.Labels {
+		labeled := s.db.Table(labelTable).Select(labelTable+"."+labelColumn).
+			Joins("JOIN labels ON labels.id = "+labelTable+".label_id").Where("LOWER(labels.name) = ?", label)
+		items = items.Where("id IN (?)", labeled)
+	}
+	return items
 }
 
-func (s *SearchService) searchOrganizations(filter SearchFilter, offset int) ([]models.Organization, error) {
-	var orgs []models.Organization
-	query := s.db.Model(&models.Organization{})
+func (s *SearchService) searchUsers(filter SearchFilter, offset int) ([]models.User, error) {
+	query, err := ParseSearchQuery(filter.Query)
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	// External collaborators are not listed
+	found := matchSearchTerms(s.db.Model(&models.User{}).Where("is_external = ?", false), query.Terms, "username", "full_name", "email", "bio", "company")
+	return users, found.Order("updated_at DESC").Offset(offset).Limit(filter.PerPage).Find(&users).Error
+}
 
-	if filter.Query != "" {
-		q := "%" + strings.ToLower(filter.Query) + "%"
-		query = query.Where(
-			"lower(name) LIKE ? OR lower(description) LIKE ?",
-			q, q,
-		)
+func (s *SearchService) searchRepositories(filter SearchFilter, offset int) ([]models.Repository, error) {
+	query, err := ParseSearchQuery(filter.Query)
+	if err != nil {
+		return nil, err
 	}
+	var repos []models.Repository
+	found := matchSearchTerms(s.repositoryScope(query, filter.UserID), query.Terms, "name", "description")
+	return repos, found.Order("updated_at DESC").Offset(offset).Limit(filter.PerPage).Find(&repos).Error
+}
 
-	query = query.Order("name ASC")
-	query = query.Offset(offset).Limit(filter.PerPage)
-	return orgs, query.Find(&orgs).Error
+func (s *SearchService) searchIssues(filter SearchFilter, offset int) ([]models.Issue, error) {
+	query, err := ParseSearchQuery(filter.Query)
+	if err != nil {
+		return nil, err
+	}
+	var issues []models.Issue
+	found := s.db.Model(&models.Issue{}).Where("repository_id IN (?)", s.repositoryScope(query, filter.UserID).Select("id"))
+	found = s.issueQualifiers(matchSearchTerms(found, query.Terms, "title", "body"), query, "issue_labels", "issue_id")
+	return issues, found.Order("updated_at DESC").Offset(offset).Limit(filter.PerPage).
+		Preload("Repository").Preload("User").Preload("Labels").Find(&issues).Error
 }
 
-func (s *SearchService) searchCommits(filter SearchFilter, offset int) ([]models.Commit, error) {
-	var commits []models.Commit
-	query := s.db.Model(&models.Commit{})
+func (s *SearchService) searchPullRequests(filter SearchFilter, offset int) ([]models.PullRequest, error) {
+	query, err := ParseSearchQuery(filter.Query)
+	if err != nil {
+		return nil, err
+	}
+	var pulls []models.PullRequest
+	found := s.db.Model(&models.PullRequest{}).Where("repository_id IN (?)", s.repositoryScope(query, filter.UserID).Select("id"))
+	found = s.issueQualifiers(matchSearchTerms(found, query.Terms, "title", "body"), query, "pull_request_labels", "pull_request_id")
+	return pulls, found.Order("updated_at DESC").Offset(offset).Limit(filter.PerPage).
+		Preload("Repository").Preload("User").Preload("Labels").Find(&pulls).Error
+}
 
-	if filter.Query != "" {
-		q := "%" + strings.ToLower(filter.Query) + "%"
-		query = query.Where(
-			"lower(message) LIKE ? OR lower(author_name) LIKE ?",
-			q, q,
-		)
+func (s *SearchService) searchOrganizations(filter SearchFilter, offset int) ([]models.Organization, error) {
+	query, err := ParseSearchQuery(filter.Query)
+	if err != nil {
+		return nil, err
 	}
+	var orgs []models.Organization
+	found := matchSearchTerms(s.db.Model(&models.Organization{}), query.Terms, "name", "display_name", "description")
+	return orgs, found.Order("updated_at DESC").Offset(offset).Limit(filter.PerPage).Find(&orgs).Error
+}
 
-	query = query.Order("created_at DESC")
-	query = query.Offset(offset).Limit(filter.PerPage)
-	return commits, query.Preload("Repository").Find(&commits).Error
+func (s *SearchService) searchCommits(filter SearchFilter, offset int) ([]models.Commit, error) {
+	query, err := ParseSearchQuery(filter.Query)
+	if err != nil {
+		return nil, err
+	}
+	var commits []models.Commit
+	found := s.db.Model(&models.Commit{}).Where("repository_id IN (?)", s.repositoryScope(query, filter.UserID).Select("id"))
+	found = matchSearchTerms(found, query.Terms, "message", "author_name")
+	return commits, found.Order("created_at DESC").Offset(offset).Limit(filter.PerPage).Preload("Repository").Find(&commits).Error
 }
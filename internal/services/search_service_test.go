@@ -24,6 +24,13 @@ func setupSearchTestDB(t *testing.T) *gorm.DB {
 		&models.Organization{},
 		&models.Repository{},
 		&models.Commit{},
+		&models.OrganizationMember{},
+		&models.TeamMember{},
+		&models.RepositoryPermission{},
+		&models.RepositoryLanguage{},
+		&models.Label{},
+		&models.Issue{},
+		&models.PullRequest{},
 	)
 	require.NoError(t, err)
 
@@ -378,3 +385,102 @@ func TestSearchService_Pagination(t *testing.T) {
 		assert.False(t, user1IDs[user.ID], "User should not appear on both pages")
 	}
 }
+
+func TestSearchService_Qualifiers(t *testing.T) {
+	db := setupSearchTestDB(t)
+	service := NewSearchService(db, nil, logrus.New())
+	ctx := context.Background()
+
+	alice := models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}
+	bob := models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com"}
+	require.NoError(t, db.Create(&[]models.User{alice, bob}).Error)
+	org := models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(&org).Error)
+
+	api := models.Repository{ID: uuid.New(), Name: "api", OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Visibility: models.VisibilityPublic}
+	gateway := models.Repository{ID: uuid.New(), Name: "api-gateway", OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Visibility: models.VisibilityPublic, StarsCount: 500}
+	notes := models.Repository{ID: uuid.New(), Name: "notes", OwnerID: alice.ID, OwnerType: models.OwnerTypeUser, Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(&[]models.Repository{api, gateway, notes}).Error)
+	require.NoError(t, db.Create(&models.RepositoryLanguage{ID: uuid.New(), RepositoryID: api.ID, Language: "Go"}).Error)
+
+	bug := models.Label{ID: uuid.New(), RepositoryID: api.ID, Name: "bug"}
+	require.NoError(t, db.Create(&bug).Error)
+	newIssue := func(repo models.Repository, number int, title string, state models.IssueState, author models.User, labels ...models.Label) models.Issue {
+		issue := models.Issue{ID: uuid.New(), RepositoryID: repo.ID, Number: number, Title: title, State: state, UserID: &author.ID, Labels: labels}
+		require.NoError(t, db.Create(&issue).Error)
+		return issue
+	}
+	loginFails := newIssue(api, 1, "Login fails on Safari", models.IssueStateOpen, alice, bug)
+	newIssue(api, 2, "Redesign login page", models.IssueStateClosed, alice)
+	secret := newIssue(notes, 1, "Login secrets rotation", models.IssueStateOpen, alice)
+	fix := models.PullRequest{ID: uuid.New(), RepositoryID: api.ID, BaseRepositoryID: api.ID, Number: 3, Title: "Fix login on Safari",
+		BaseBranch: "main", HeadBranch: "fix", State: models.PullRequestStateMerged, UserID: &bob.ID, Labels: []models.Label{bug}}
+	require.NoError(t, db.Create(&fix).Error)
+
+	search := func(query string, userID *uuid.UUID) []SearchResultItem {
+		t.Helper()
+		results, err := service.GlobalSearch(ctx, SearchFilter{Query: query, UserID: userID})
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(results.Items)), results.TotalCount)
+		return results.Items
+	}
+	ids := func(items []SearchResultItem) []uuid.UUID {
+		found := []uuid.UUID{}
+		for _, item := range items {
+			found = append(found, item.ID)
+		}
+		return found
+	}
+
+	t.Run("issue qualifiers", func(t *testing.T) {
+		assert.Equal(t, []uuid.UUID{loginFails.ID}, ids(search("login repo:acme/api is:issue state:open", nil)))
+		assert.ElementsMatch(t, []uuid.UUID{loginFails.ID, fix.ID}, ids(search(`login label:"bug"`, nil)))
+		assert.Equal(t, []uuid.UUID{fix.ID}, ids(search("is:pr author:bob", nil)))
+		assert.Equal(t, []uuid.UUID{fix.ID}, ids(search("is:merged", nil)))
+		assert.Empty(t, search("login repo:acme/notes", nil))
+	})
+
+	t.Run("repository qualifiers", func(t *testing.T) {
+		items := search("api language:go", nil)
+		require.Len(t, items, 1)
+		assert.Equal(t, SearchTypeRepository, items[0].Type)
+		assert.Equal(t, api.ID, items[0].ID)
+		assert.Len(t, search("language:go", nil), 4, "issues and pull requests in matching repositories match too")
+		assert.ElementsMatch(t, []uuid.UUID{notes.ID, secret.ID}, ids(search("user:alice is:private", &alice.ID)))
+	})
+
+	t.Run("visibility", func(t *testing.T) {
+		assert.NotContains(t, ids(search("secrets", nil)), secret.ID)
+		assert.Equal(t, []uuid.UUID{secret.ID}, ids(search("secrets", &alice.ID)))
+	})
+
+	t.Run("ranking and pagination", func(t *testing.T) {
+		results, err := service.GlobalSearch(ctx, SearchFilter{Query: "api", Type: "repositories"})
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{api.ID, gateway.ID}, ids(results.Items), "exact name matches outrank popular prefix matches")
+		require.Len(t, results.Repositories, 2)
+
+		results, err = service.GlobalSearch(ctx, SearchFilter{Query: "api", Type: "repository", Sort: "stars", Page: 2, PerPage: 1})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), results.TotalCount)
+		assert.Equal(t, []uuid.UUID{api.ID}, ids(results.Items))
+	})
+
+	t.Run("invalid queries", func(t *testing.T) {
+		for _, filter := range []SearchFilter{{Query: "repo:acme"}, {Query: "state:merged"}, {Query: "is:everything"}, {Query: "label:"}, {Query: "x", Type: "wiki"}} {
+			_, err := service.GlobalSearch(ctx, filter)
+			assert.ErrorIs(t, err, ErrInvalidSearchQuery, filter.Query)
+		}
+	})
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	query, err := ParseSearchQuery(`"Fix Login" label:"good first issue" Repo:Acme/API http://example.com is:open`)
+	require.NoError(t, err)
+	assert.Equal(t, &SearchQuery{
+		Terms:  []string{"fix login", "http://example.com"},
+		Repos:  []string{"acme/api"},
+		Labels: []string{"good first issue"},
+		States: []string{"open"},
+	}, query)
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/a5c-ai/hub/internal/encryption"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// ErrEncryptionDisabled is returned when keys are rotated while secret
+// encryption is not configured
+var ErrEncryptionDisabled = errors.New("secret encryption is not enabled")
+
+// encryptedColumn is a column stored with the encrypted serializer
+type encryptedColumn struct {
+	table  string
+	column string
+}
+
+// encryptedColumns lists every column holding encrypted secrets; key
+// rotation and verification only visit these
+var encryptedColumns = []encryptedColumn{
+	{(&models.Webhook{}).TableName(), "secret"},
+	{(&models.RepositoryHook{}).TableName(), "secret"},
+	{(&models.User{}).TableName(), "two_factor_secret"},
+	{(&models.User{}).TableName(), "two_factor_pending_secret"},
+	{(&models.OrganizationSSOConfig{}).TableName(), "client_secret"},
+}
+
+// EncryptedColumnStatus counts the secrets of one column by how they are stored
+type EncryptedColumnStatus struct {
+	Table     string `json:"table"`
+	Column    string `json:"column"`
+	Total     int64  `json:"total"`
+	Plaintext int64  `json:"plaintext"`
+	// Keys counts encrypted values by master key ID
+	Keys map[string]int64 `json:"keys"`
+}
+
+// EncryptionStatus reports how far secrets are encrypted with the current key
+type EncryptionStatus struct {
+	Enabled      bool                     `json:"enabled"`
+	CurrentKeyID string                   `json:"current_key_id,omitempty"`
+	Columns      []*EncryptedColumnStatus `json:"columns"`
+	// PendingRotation counts plaintext values and values under retired keys
+	PendingRotation int64 `json:"pending_rotation"`
+}
+
+// EncryptionFailure is a stored secret that could not be decrypted
+type EncryptionFailure struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	ID     string `json:"id"`
+	Error  string `json:"error"`
+}
+
+// EncryptionRunResult summarizes a rotation or verification pass
+type EncryptionRunResult struct {
+	Checked  int64               `json:"checked"`
+	Rotated  int64               `json:"rotated"`
+	Failures []EncryptionFailure `json:"failures"`
+}
+
+// SecretEncryptionService reports on and maintains the secrets stored with
+// envelope encryption. Rotation re-encrypts plaintext values and values
+// under retired master keys with the current key, batch by batch.
+type SecretEncryptionService interface {
+	Status(ctx context.Context) (*EncryptionStatus, error)
+	// Rotate re-encrypts every value not encrypted with the current key
+	Rotate(ctx context.Context) (*EncryptionRunResult, error)
+	// Verify decrypts every stored value and reports those failing their
+	// integrity check or encrypted with an unknown key
+	Verify(ctx context.Context) (*EncryptionRunResult, error)
+}
+
+type secretEncryptionService struct {
+	db        *gorm.DB
+	keyring   *encryption.Keyring
+	batchSize int
+	logger    *logrus.Logger
+}
+
+// NewSecretEncryptionService creates a new SecretEncryptionService
+func NewSecretEncryptionService(db *gorm.DB, keyring *encryption.Keyring, batchSize int, logger *logrus.Logger) SecretEncryptionService {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	return &secretEncryptionService{
+		db:        db,
+		keyring:   keyring,
+		batchSize: batchSize,
+		logger:    logger,
+	}
+}
+
+// encryptedRow is an id and raw stored value read past the serializer
+type encryptedRow struct {
+	ID    string
+	Value string
+}
+
+func (s *secretEncryptionService) Status(ctx context.Context) (*EncryptionStatus, error) {
+	status := &EncryptionStatus{
+		Enabled:      s.keyring != nil,
+		CurrentKeyID: s.keyring.CurrentKeyID(),
+	}
+	for _, col := range encryptedColumns {
+		colStatus := &EncryptedColumnStatus{Table: col.table, Column: col.column, Keys: map[string]int64{}}
+		err := s.eachBatch(ctx, col, func(rows []encryptedRow) error {
+			for _, row := range rows {
+				colStatus.Total++
+				if id := encryption.KeyID(row.Value); id != "" {
+					colStatus.Keys[id]++
+				} else {
+					colStatus.Plaintext++
+				}
+				if s.keyring.NeedsRotation(row.Value) {
+					status.PendingRotation++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		status.Columns = append(status.Columns, colStatus)
+	}
+	return status, nil
+}
+
+func (s *secretEncryptionService) Rotate(ctx context.Context) (*EncryptionRunResult, error) {
+	if s.keyring == nil {
+		return nil, ErrEncryptionDisabled
+	}
+
+	result := &EncryptionRunResult{Failures: []EncryptionFailure{}}
+	for _, col := range encryptedColumns {
+		aad := encryption.Context(col.table, col.column)
+		err := s.eachBatch(ctx, col, func(rows []encryptedRow) error {
+			return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+				for _, row := range rows {
+					result.Checked++
+					if !s.keyring.NeedsRotation(row.Value) {
+						continue
+					}
+					plaintext, err := s.keyring.Decrypt(row.Value, aad)
+					if err != nil {
+						result.Failures = append(result.Failures, EncryptionFailure{Table: col.table, Column: col.column, ID: row.ID, Error: err.Error()})
+						continue
+					}
+					sealed, err := s.keyring.Encrypt(plaintext, aad)
+					if err != nil {
+						return err
+					}
+					// Compare with the value read so concurrent writes win
+					res := tx.Table(col.table).Where("id = ? AND "+col.column+" = ?", row.ID, row.Value).
+						UpdateColumn(col.column, sealed)
+					if res.Error != nil {
+						return res.Error
+					}
+					result.Rotated += res.RowsAffected
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to rotate %s.%s: %w", col.table, col.column, err)
+		}
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"key_id":   s.keyring.CurrentKeyID(),
+		"checked":  result.Checked,
+		"rotated":  result.Rotated,
+		"failures": len(result.Failures),
+	}).Info("Rotated secret encryption keys")
+	return result, nil
+}
+
+func (s *secretEncryptionService) Verify(ctx context.Context) (*EncryptionRunResult, error) {
+	result := &EncryptionRunResult{Failures: []EncryptionFailure{}}
+	for _, col := range encryptedColumns {
+		aad := encryption.Context(col.table, col.column)
+		err := s.eachBatch(ctx, col, func(rows []encryptedRow) error {
+			for _, row := range rows {
+				result.Checked++
+				if _, err := s.keyring.Decrypt(row.Value, aad); err != nil {
+					result.Failures = append(result.Failures, EncryptionFailure{Table: col.table, Column: col.column, ID: row.ID, Error: err.Error()})
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify %s.%s: %w", col.table, col.column, err)
+		}
+	}
+	if len(result.Failures) > 0 {
+		s.logger.WithField("failures", len(result.Failures)).Warn("Stored secrets failed integrity verification")
+	}
+	return result, nil
+}
+
+// eachBatch calls fn with the non-empty values of col in id order, reading
+// the raw column so values are not decrypted by the serializer
+func (s *secretEncryptionService) eachBatch(ctx context.Context, col encryptedColumn, fn func([]encryptedRow) error) error {
+	lastID := ""
+	for {
+		var rows []encryptedRow
+		query := s.db.WithContext(ctx).Table(col.table).
+			Select("id, " + col.column + " AS value").
+			Where(col.column + " IS NOT NULL AND " + col.column + " <> ''")
+		if lastID != "" {
+			query = query.Where("id > ?", lastID)
+		}
+		err := query.Order("id").Limit(s.batchSize).Scan(&rows).Error
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if err := fn(rows); err != nil {
+			return err
+		}
+		if len(rows) < s.batchSize {
+			return nil
+		}
+		lastID = rows[len(rows)-1].ID
+	}
+}
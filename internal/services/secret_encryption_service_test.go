@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/encryption"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecretEncryptionService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Webhook{}, &models.RepositoryHook{}, &models.User{}, &models.OrganizationSSOConfig{})
+	ctx := context.Background()
+	logger := logrus.New()
+
+	key := func(b byte) string {
+		return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+	}
+	rawSecret := func(id uuid.UUID) string {
+		var value string
+		require.NoError(t, db.Table("webhooks").Select("secret").Where("id = ?", id).Scan(&value).Error)
+		return value
+	}
+
+	repoID := uuid.New()
+	// Written before encryption was enabled
+	legacy := &models.Webhook{ID: uuid.New(), RepositoryID: &repoID, Name: "legacy", URL: "https://example.com/a", Secret: "legacy-secret"}
+	require.NoError(t, db.Create(legacy).Error)
+	assert.Equal(t, "legacy-secret", rawSecret(legacy.ID))
+
+	oldKeyring, err := encryption.NewKeyring(config.Encryption{Enabled: true, KeyID: "k1", MasterKey: key('a')})
+	require.NoError(t, err)
+	encryption.SetDefault(oldKeyring)
+	t.Cleanup(func() { encryption.SetDefault(nil) })
+
+	webhookService := NewWebhookDeliveryService(db, logger)
+	hook, err := webhookService.CreateWebhook(ctx, uuid.New(), "web", "https://example.com/b", "new-secret", nil, "application/json", false, true)
+	require.NoError(t, err)
+	assert.Equal(t, "k1", encryption.KeyID(rawSecret(hook.ID)))
+
+	t.Run("reads decrypt transparently", func(t *testing.T) {
+		loaded, err := webhookService.GetWebhook(ctx, hook.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "new-secret", loaded.Secret)
+
+		loaded, err = webhookService.GetWebhook(ctx, legacy.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "legacy-secret", loaded.Secret)
+	})
+
+	t.Run("map updates are encrypted", func(t *testing.T) {
+		updated, err := webhookService.UpdateWebhook(ctx, hook.ID, map[string]interface{}{"secret": "changed-secret"})
+		require.NoError(t, err)
+		assert.Equal(t, "changed-secret", updated.Secret)
+		assert.True(t, encryption.IsEncrypted(rawSecret(hook.ID)))
+	})
+
+	// Rotate to a new master key, keeping the old one for reading
+	newKeyring, err := encryption.NewKeyring(config.Encryption{
+		Enabled: true, KeyID: "k2", MasterKey: key('b'),
+		PreviousKeys: map[string]string{"k1": key('a')},
+	})
+	require.NoError(t, err)
+	encryption.SetDefault(newKeyring)
+	svc := NewSecretEncryptionService(db, newKeyring, 1, logger)
+
+	t.Run("status", func(t *testing.T) {
+		status, err := svc.Status(ctx)
+		require.NoError(t, err)
+		assert.True(t, status.Enabled)
+		assert.Equal(t, "k2", status.CurrentKeyID)
+		assert.EqualValues(t, 2, status.PendingRotation)
+		require.Equal(t, "webhooks", status.Columns[0].Table)
+		assert.EqualValues(t, 2, status.Columns[0].Total)
+		assert.EqualValues(t, 1, status.Columns[0].Plaintext)
+		assert.EqualValues(t, 1, status.Columns[0].Keys["k1"])
+	})
+
+	t.Run("rotate", func(t *testing.T) {
+		result, err := svc.Rotate(ctx)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, result.Checked)
+		assert.EqualValues(t, 2, result.Rotated)
+		assert.Empty(t, result.Failures)
+		assert.Equal(t, "k2", encryption.KeyID(rawSecret(hook.ID)))
+		assert.Equal(t, "k2", encryption.KeyID(rawSecret(legacy.ID)))
+
+		loaded, err := webhookService.GetWebhook(ctx, legacy.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "legacy-secret", loaded.Secret)
+
+		again, err := svc.Rotate(ctx)
+		require.NoError(t, err)
+		assert.EqualValues(t, 0, again.Rotated)
+	})
+
+	t.Run("verify reports tampered values", func(t *testing.T) {
+		// A value copied from another column fails its integrity check
+		copied, err := newKeyring.Encrypt("copied", encryption.Context("users", "two_factor_secret"))
+		require.NoError(t, err)
+		require.NoError(t, db.Table("webhooks").Where("id = ?", legacy.ID).UpdateColumn("secret", copied).Error)
+
+		result, err := svc.Verify(ctx)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, result.Checked)
+		require.Len(t, result.Failures, 1)
+		assert.Equal(t, legacy.ID.String(), result.Failures[0].ID)
+
+		_, err = webhookService.GetWebhook(ctx, legacy.ID)
+		assert.ErrorIs(t, err, encryption.ErrIntegrity)
+	})
+
+	t.Run("rotate requires encryption", func(t *testing.T) {
+		_, err := NewSecretEncryptionService(db, nil, 0, logger).Rotate(ctx)
+		assert.ErrorIs(t, err, ErrEncryptionDisabled)
+	})
+}
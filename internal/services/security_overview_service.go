@@ -0,0 +1,492 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Categories the security overview sorts open code scanning alerts into
+const (
+	SecurityAlertSecretScanning = "secret_scanning"
+	SecurityAlertDependency     = "dependency"
+	SecurityAlertCodeScanning   = "code_scanning"
+)
+
+// Components of the security score and the points each is worth; the
+// weights add up to 100
+const (
+	SecurityComponentSecretScanning   = "secret_scanning"
+	SecurityComponentDependencies     = "dependency_vulnerabilities"
+	SecurityComponentCodeScanning     = "code_scanning"
+	SecurityComponentBranchProtection = "branch_protection"
+	SecurityComponentTwoFactor        = "two_factor"
+	SecurityComponentCredentials      = "stale_credentials"
+)
+
+var securityComponentWeights = map[string]float64{
+	SecurityComponentSecretScanning:   25,
+	SecurityComponentDependencies:     20,
+	SecurityComponentCodeScanning:     15,
+	SecurityComponentBranchProtection: 15,
+	SecurityComponentTwoFactor:        15,
+	SecurityComponentCredentials:      10,
+}
+
+// securityAlertPenalty is how much of a repository's share of an alert
+// component one open alert of each severity costs
+var securityAlertPenalty = map[models.CodeScanningSeverity]float64{
+	models.CodeScanningSeverityCritical: 0.5,
+	models.CodeScanningSeverityHigh:     0.25,
+	models.CodeScanningSeverityMedium:   0.1,
+	models.CodeScanningSeverityLow:      0.05,
+}
+
+// Tools whose SARIF uploads report leaked secrets or vulnerable
+// dependencies, matched against the lowercased tool name
+var (
+	secretScanningTools     = []string{"gitleaks", "trufflehog", "detect-secrets", "secret"}
+	dependencyScanningTools = []string{"trivy", "grype", "osv-scanner", "dependency-check", "snyk", "npm audit", "govulncheck", "dependabot"}
+)
+
+var (
+	ErrSecurityOverviewForbidden  = errors.New("only organization owners and admins can view the security overview")
+	ErrSecurityRepositoryNotFound = errors.New("repository not found")
+)
+
+// SecurityOverviewService aggregates an organization's security posture
+// from open scanning alerts, default branch protection, two-factor
+// adoption and unused credentials. Only owners and admins can see it.
+type SecurityOverviewService interface {
+	OrganizationOverview(ctx context.Context, orgName string, actorID uuid.UUID) (*SecurityOverview, error)
+	// ListRepositories returns every repository's share of the overview,
+	// the lowest scores first
+	ListRepositories(ctx context.Context, orgName string, actorID uuid.UUID) ([]*RepositorySecurity, error)
+	// GetRepository returns one repository's share with its open alerts
+	GetRepository(ctx context.Context, orgName, repoName string, actorID uuid.UUID) (*RepositorySecurity, error)
+}
+
+// SecurityAlertCounts counts open alerts by severity
+type SecurityAlertCounts struct {
+	Open     int `json:"open"`
+	Critical int `json:"critical"`
+	High     int `json:"high"`
+	Medium   int `json:"medium"`
+	Low      int `json:"low"`
+}
+
+// SecurityScoreComponent is one part of a security score. Ratio is the
+// share of Weight earned and Points is Weight times Ratio.
+type SecurityScoreComponent struct {
+	Name   string  `json:"name"`
+	Weight float64 `json:"weight"`
+	Ratio  float64 `json:"ratio"`
+	Points float64 `json:"points"`
+	Detail string  `json:"detail"`
+}
+
+// TwoFactorAdoption counts the members with two-factor authentication
+type TwoFactorAdoption struct {
+	Required bool `json:"required"`
+	Members  int  `json:"members"`
+	Enabled  int  `json:"enabled"`
+	// Missing lists the members without two-factor authentication
+	Missing []string `json:"missing"`
+}
+
+// StaleCredentialSummary counts the credentials of the organization's
+// members and repositories unused for InactiveDays
+type StaleCredentialSummary struct {
+	InactiveDays int `json:"inactive_days"`
+	Total        int `json:"total"`
+	Inactive     int `json:"inactive"`
+	NeverUsed    int `json:"never_used"`
+}
+
+// SecurityOverview is an organization's security score and the findings
+// behind it
+type SecurityOverview struct {
+	Organization string                          `json:"organization"`
+	GeneratedAt  time.Time                       `json:"generated_at"`
+	Score        float64                         `json:"score"`
+	Components   []SecurityScoreComponent        `json:"components"`
+	Alerts       map[string]*SecurityAlertCounts `json:"alerts"`
+	Repositories int                             `json:"repositories"`
+	// UnprotectedDefaultBranches names the repositories whose default
+	// branch no protection rule covers
+	UnprotectedDefaultBranches []string               `json:"unprotected_default_branches"`
+	TwoFactor                  TwoFactorAdoption      `json:"two_factor"`
+	Credentials                StaleCredentialSummary `json:"credentials"`
+}
+
+// RepositorySecurity is one repository's part of the security overview.
+// Its score only covers the repository components, scaled to 100.
+type RepositorySecurity struct {
+	ID                     uuid.UUID                       `json:"id"`
+	Name                   string                          `json:"name"`
+	DefaultBranch          string                          `json:"default_branch"`
+	DefaultBranchProtected bool                            `json:"default_branch_protected"`
+	Score                  float64                         `json:"score"`
+	Components             []SecurityScoreComponent        `json:"components"`
+	Alerts                 map[string]*SecurityAlertCounts `json:"alerts"`
+	OpenAlerts             []*models.CodeScanningAlert     `json:"open_alerts,omitempty"`
+
+	ratios map[string]float64
+}
+
+type securityOverviewService struct {
+	db          *gorm.DB
+	credentials *credentialAuditService
+	now         func() time.Time
+}
+
+// NewSecurityOverviewService creates a new SecurityOverviewService
+func NewSecurityOverviewService(db *gorm.DB) SecurityOverviewService {
+	return newSecurityOverviewService(db)
+}
+
+func newSecurityOverviewService(db *gorm.DB) *securityOverviewService {
+	return &securityOverviewService{
+		db:          db,
+		credentials: &credentialAuditService{db: db, now: time.Now},
+		now:         time.Now,
+	}
+}
+
+func (s *securityOverviewService) OrganizationOverview(ctx context.Context, orgName string, actorID uuid.UUID) (*SecurityOverview, error) {
+	org, err := s.organization(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+	return s.overview(ctx, org)
+}
+
+func (s *securityOverviewService) ListRepositories(ctx context.Context, orgName string, actorID uuid.UUID) ([]*RepositorySecurity, error) {
+	org, err := s.organization(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+	repos, err := s.repositories(ctx, org, "", false)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(repos, func(i, j int) bool { return repos[i].Score < repos[j].Score })
+	return repos, nil
+}
+
+func (s *securityOverviewService) GetRepository(ctx context.Context, orgName, repoName string, actorID uuid.UUID) (*RepositorySecurity, error) {
+	org, err := s.organization(ctx, orgName, actorID)
+	if err != nil {
+		return nil, err
+	}
+	repos, err := s.repositories(ctx, org, repoName, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(repos) == 0 {
+		return nil, ErrSecurityRepositoryNotFound
+	}
+	return repos[0], nil
+}
+
+// organization resolves the organization and requires actorID to be one
+// of its owners or admins
+func (s *securityOverviewService) organization(ctx context.Context, orgName string, actorID uuid.UUID) (*models.Organization, error) {
+	var org models.Organization
+	if err := s.db.WithContext(ctx).Where("name = ?", orgName).First(&org).Error; err != nil {
+		return nil, fmt.Errorf("organization not found: %w", err)
+	}
+	var member models.OrganizationMember
+	err := s.db.WithContext(ctx).Where("organization_id = ? AND user_id = ?", org.ID, actorID).First(&member).Error
+	if err != nil || (member.Role != models.OrgRoleOwner && member.Role != models.OrgRoleAdmin) {
+		return nil, ErrSecurityOverviewForbidden
+	}
+	return &org, nil
+}
+
+// overview computes the organization's score: the repository components
+// average over its repositories and the member components cover its
+// members and their credentials
+func (s *securityOverviewService) overview(ctx context.Context, org *models.Organization) (*SecurityOverview, error) {
+	repos, err := s.repositories(ctx, org, "", false)
+	if err != nil {
+		return nil, err
+	}
+	overview := &SecurityOverview{
+		Organization:               org.Name,
+		GeneratedAt:                s.now(),
+		Repositories:               len(repos),
+		Alerts:                     newSecurityAlertCounts(),
+		UnprotectedDefaultBranches: []string{},
+	}
+
+	ratios := map[string]float64{}
+	for _, repo := range repos {
+		for category, counts := range repo.Alerts {
+			overview.Alerts[category].add(counts)
+		}
+		if !repo.DefaultBranchProtected {
+			overview.UnprotectedDefaultBranches = append(overview.UnprotectedDefaultBranches, repo.Name)
+		}
+		for name, ratio := range repo.ratios {
+			ratios[name] += ratio / float64(len(repos))
+		}
+	}
+	if len(repos) == 0 {
+		for _, name := range repositorySecurityComponents {
+			ratios[name] = 1
+		}
+	}
+
+	if overview.TwoFactor, err = s.twoFactorAdoption(ctx, org); err != nil {
+		return nil, err
+	}
+	ratios[SecurityComponentTwoFactor] = 1
+	if overview.TwoFactor.Members > 0 {
+		ratios[SecurityComponentTwoFactor] = float64(overview.TwoFactor.Enabled) / float64(overview.TwoFactor.Members)
+	}
+
+	if overview.Credentials, err = s.staleCredentials(ctx, org); err != nil {
+		return nil, err
+	}
+	ratios[SecurityComponentCredentials] = 1
+	if overview.Credentials.Total > 0 {
+		ratios[SecurityComponentCredentials] = 1 - float64(overview.Credentials.Inactive)/float64(overview.Credentials.Total)
+	}
+
+	details := map[string]string{
+		SecurityComponentSecretScanning:   fmt.Sprintf("%d open secret scanning alerts", overview.Alerts[SecurityAlertSecretScanning].Open),
+		SecurityComponentDependencies:     fmt.Sprintf("%d open dependency alerts", overview.Alerts[SecurityAlertDependency].Open),
+		SecurityComponentCodeScanning:     fmt.Sprintf("%d open code scanning alerts", overview.Alerts[SecurityAlertCodeScanning].Open),
+		SecurityComponentBranchProtection: fmt.Sprintf("%d of %d default branches protected", len(repos)-len(overview.UnprotectedDefaultBranches), len(repos)),
+		SecurityComponentTwoFactor:        fmt.Sprintf("%d of %d members use two-factor authentication", overview.TwoFactor.Enabled, overview.TwoFactor.Members),
+		SecurityComponentCredentials:      fmt.Sprintf("%d of %d credentials unused for %d days", overview.Credentials.Inactive, overview.Credentials.Total, overview.Credentials.InactiveDays),
+	}
+	overview.Components, overview.Score = securityScore(securityComponents, ratios, details)
+	return overview, nil
+}
+
+// repositorySecurityComponents are the components scored per repository
+var repositorySecurityComponents = []string{SecurityComponentSecretScanning, SecurityComponentDependencies, SecurityComponentCodeScanning, SecurityComponentBranchProtection}
+
+var securityComponents = append(append([]string{}, repositorySecurityComponents...), SecurityComponentTwoFactor, SecurityComponentCredentials)
+
+// securityScore weighs ratios into components and sums their points,
+// scaled to 100 when only some components are scored
+func securityScore(names []string, ratios map[string]float64, details map[string]string) ([]SecurityScoreComponent, float64) {
+	components := make([]SecurityScoreComponent, 0, len(names))
+	var points, weights float64
+	for _, name := range names {
+		weight := securityComponentWeights[name]
+		ratio := math.Max(0, math.Min(1, ratios[name]))
+		component := SecurityScoreComponent{Name: name, Weight: weight, Ratio: roundScore(ratio, 3), Points: roundScore(weight*ratio, 1), Detail: details[name]}
+		components = append(components, component)
+		points += weight * ratio
+		weights += weight
+	}
+	return components, roundScore(points*100/weights, 1)
+}
+
+func roundScore(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
+
+// repositories scores the organization's repositories, or the one named,
+// counting the alerts open on each default branch
+func (s *securityOverviewService) repositories(ctx context.Context, org *models.Organization, name string, withAlerts bool) ([]*RepositorySecurity, error) {
+	db := s.db.WithContext(ctx)
+	var repos []models.Repository
+	query := db.Where("owner_id = ? AND owner_type = ?", org.ID, models.OwnerTypeOrganization)
+	if name != "" {
+		query = query.Where("name = ?", name)
+	}
+	if err := query.Order("name").Find(&repos).Error; err != nil {
+		return nil, fmt.Errorf("failed to list repositories: %w", err)
+	}
+	if len(repos) == 0 {
+		return []*RepositorySecurity{}, nil
+	}
+
+	repoIDs := make([]uuid.UUID, len(repos))
+	for i, repo := range repos {
+		repoIDs[i] = repo.ID
+	}
+	var rules []models.BranchProtectionRule
+	if err := db.Where("repository_id IN ?", repoIDs).Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to load branch protection rules: %w", err)
+	}
+	var alerts []*models.CodeScanningAlert
+	if err := db.Where("repository_id IN ? AND state = ?", repoIDs, models.CodeScanningAlertOpen).
+		Order("number").Find(&alerts).Error; err != nil {
+		return nil, fmt.Errorf("failed to load code scanning alerts: %w", err)
+	}
+
+	results := make([]*RepositorySecurity, 0, len(repos))
+	byID := make(map[uuid.UUID]*RepositorySecurity, len(repos))
+	for _, repo := range repos {
+		branch := repo.DefaultBranch
+		if branch == "" {
+			branch = "main"
+		}
+		result := &RepositorySecurity{ID: repo.ID, Name: repo.Name, DefaultBranch: branch, Alerts: newSecurityAlertCounts(),
+			ratios: map[string]float64{SecurityComponentSecretScanning: 1, SecurityComponentDependencies: 1, SecurityComponentCodeScanning: 1}}
+		for _, rule := range rules {
+			if rule.RepositoryID == repo.ID && matchPattern(rule.Pattern, branch) {
+				result.DefaultBranchProtected = true
+			}
+		}
+		if result.DefaultBranchProtected {
+			result.ratios[SecurityComponentBranchProtection] = 1
+		}
+		results = append(results, result)
+		byID[repo.ID] = result
+	}
+
+	// Alerts on other refs are usually pull requests that were never
+	// merged, so only the default branch counts
+	for _, alert := range alerts {
+		result := byID[alert.RepositoryID]
+		if alert.Ref != "refs/heads/"+result.DefaultBranch && alert.Ref != result.DefaultBranch {
+			continue
+		}
+		category := securityAlertCategory(alert)
+		result.Alerts[category].count(alert.Severity)
+		result.ratios[securityAlertComponent[category]] -= securityAlertPenalty[alert.Severity]
+		if withAlerts {
+			result.OpenAlerts = append(result.OpenAlerts, alert)
+		}
+	}
+
+	for _, result := range results {
+		details := map[string]string{
+			SecurityComponentSecretScanning:   fmt.Sprintf("%d open secret scanning alerts", result.Alerts[SecurityAlertSecretScanning].Open),
+			SecurityComponentDependencies:     fmt.Sprintf("%d open dependency alerts", result.Alerts[SecurityAlertDependency].Open),
+			SecurityComponentCodeScanning:     fmt.Sprintf("%d open code scanning alerts", result.Alerts[SecurityAlertCodeScanning].Open),
+			SecurityComponentBranchProtection: fmt.Sprintf("%s is not protected", result.DefaultBranch),
+		}
+		if result.DefaultBranchProtected {
+			details[SecurityComponentBranchProtection] = fmt.Sprintf("%s is protected", result.DefaultBranch)
+		}
+		for name, ratio := range result.ratios {
+			result.ratios[name] = math.Max(0, ratio)
+		}
+		result.Components, result.Score = securityScore(repositorySecurityComponents, result.ratios, details)
+	}
+	return results, nil
+}
+
+// securityAlertComponent maps alert categories to the components they lower
+var securityAlertComponent = map[string]string{
+	SecurityAlertSecretScanning: SecurityComponentSecretScanning,
+	SecurityAlertDependency:     SecurityComponentDependencies,
+	SecurityAlertCodeScanning:   SecurityComponentCodeScanning,
+}
+
+// securityAlertCategory classifies an alert by the tool that reported it,
+// falling back to the rule: CVE and GHSA identifiers are vulnerable
+// dependencies
+func securityAlertCategory(alert *models.CodeScanningAlert) string {
+	tool := strings.ToLower(alert.Tool)
+	rule := strings.ToLower(alert.RuleID)
+	for _, name := range secretScanningTools {
+		if strings.Contains(tool, name) {
+			return SecurityAlertSecretScanning
+		}
+	}
+	for _, name := range dependencyScanningTools {
+		if strings.Contains(tool, name) {
+			return SecurityAlertDependency
+		}
+	}
+	if strings.HasPrefix(rule, "cve-") || strings.HasPrefix(rule, "ghsa-") {
+		return SecurityAlertDependency
+	}
+	return SecurityAlertCodeScanning
+}
+
+func newSecurityAlertCounts() map[string]*SecurityAlertCounts {
+	return map[string]*SecurityAlertCounts{
+		SecurityAlertSecretScanning: {},
+		SecurityAlertDependency:     {},
+		SecurityAlertCodeScanning:   {},
+	}
+}
+
+func (c *SecurityAlertCounts) count(severity models.CodeScanningSeverity) {
+	c.Open++
+	switch severity {
+	case models.CodeScanningSeverityCritical:
+		c.Critical++
+	case models.CodeScanningSeverityHigh:
+		c.High++
+	case models.CodeScanningSeverityMedium:
+		c.Medium++
+	default:
+		c.Low++
+	}
+}
+
+func (c *SecurityAlertCounts) add(other *SecurityAlertCounts) {
+	c.Open += other.Open
+	c.Critical += other.Critical
+	c.High += other.High
+	c.Medium += other.Medium
+	c.Low += other.Low
+}
+
+// twoFactorAdoption counts the organization's members with two-factor
+// authentication enabled
+func (s *securityOverviewService) twoFactorAdoption(ctx context.Context, org *models.Organization) (TwoFactorAdoption, error) {
+	adoption := TwoFactorAdoption{Missing: []string{}}
+	var settings models.OrganizationSettings
+	err := s.db.WithContext(ctx).Where("organization_id = ?", org.ID).First(&settings).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return adoption, fmt.Errorf("failed to load organization settings: %w", err)
+	}
+	adoption.Required = settings.RequireTwoFactor
+
+	var members []models.User
+	if err := s.db.WithContext(ctx).Model(&models.User{}).
+		Where("id IN (?)", s.db.Model(&models.OrganizationMember{}).Select("user_id").Where("organization_id = ?", org.ID)).
+		Order("username").Find(&members).Error; err != nil {
+		return adoption, fmt.Errorf("failed to list organization members: %w", err)
+	}
+	adoption.Members = len(members)
+	for _, member := range members {
+		if member.TwoFactorEnabled {
+			adoption.Enabled++
+		} else {
+			adoption.Missing = append(adoption.Missing, member.Username)
+		}
+	}
+	return adoption, nil
+}
+
+// staleCredentials summarizes the organization's credential report
+func (s *securityOverviewService) staleCredentials(ctx context.Context, org *models.Organization) (StaleCredentialSummary, error) {
+	scope := credentialScope{org: org}
+	if err := s.db.WithContext(ctx).Model(&models.OrganizationMember{}).
+		Where("organization_id = ?", org.ID).Pluck("user_id", &scope.memberIDs).Error; err != nil {
+		return StaleCredentialSummary{}, fmt.Errorf("failed to list organization members: %w", err)
+	}
+	report, err := s.credentials.report(ctx, scope, CredentialReportOptions{})
+	if err != nil {
+		return StaleCredentialSummary{}, err
+	}
+	summary := StaleCredentialSummary{InactiveDays: report.InactiveDays}
+	for _, types := range report.Summary {
+		summary.Total += types.Total
+		summary.Inactive += types.Inactive
+		summary.NeverUsed += types.NeverUsed
+	}
+	return summary, nil
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityOverviewService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{}, &models.OrganizationSettings{},
+		&models.Repository{}, &models.BranchProtectionRule{}, &models.CodeScanningAlert{}, &models.FineGrainedToken{},
+		&models.SSHKey{}, &models.DeployKey{}, &models.OAuthAuthorization{}, &models.OAuthAccessToken{})
+
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(days int) *time.Time { t := now.AddDate(0, 0, -days); return &t }
+	svc := newSecurityOverviewService(db)
+	svc.now = func() time.Time { return now }
+	svc.credentials.now = svc.now
+
+	owner := &models.User{ID: uuid.New(), Username: "olivia", Email: "olivia@example.com", TwoFactorEnabled: true}
+	member := &models.User{ID: uuid.New(), Username: "max", Email: "max@example.com"}
+	require.NoError(t, db.Create([]*models.User{owner, member}).Error)
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	require.NoError(t, db.Create([]*models.OrganizationMember{
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: owner.ID, Role: models.OrgRoleOwner},
+		{ID: uuid.New(), OrganizationID: org.ID, UserID: member.ID, Role: models.OrgRoleMember},
+	}).Error)
+
+	app := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	docs := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "docs", DefaultBranch: "main", Visibility: models.VisibilityPublic}
+	require.NoError(t, db.Create([]*models.Repository{app, docs}).Error)
+	require.NoError(t, db.Create(&models.BranchProtectionRule{ID: uuid.New(), RepositoryID: app.ID, Pattern: "main"}).Error)
+
+	alert := func(number int, ref, tool, rule string, severity models.CodeScanningSeverity, state models.CodeScanningAlertState) *models.CodeScanningAlert {
+		return &models.CodeScanningAlert{ID: uuid.New(), RepositoryID: app.ID, Number: number, Ref: ref, Tool: tool, Fingerprint: uuid.NewString()[:8],
+			CommitSHA: "abc", RuleID: rule, Severity: severity, State: state}
+	}
+	require.NoError(t, db.Create([]*models.CodeScanningAlert{
+		alert(1, "refs/heads/main", "gitleaks", "aws-access-key", models.CodeScanningSeverityHigh, models.CodeScanningAlertOpen),
+		alert(2, "refs/heads/main", "Trivy", "CVE-2024-1234", models.CodeScanningSeverityCritical, models.CodeScanningAlertOpen),
+		// Pull request refs and closed alerts do not count
+		alert(3, "refs/pull/1/merge", "semgrep", "sql-injection", models.CodeScanningSeverityCritical, models.CodeScanningAlertOpen),
+		alert(4, "refs/heads/main", "semgrep", "xss", models.CodeScanningSeverityHigh, models.CodeScanningAlertFixed),
+	}).Error)
+
+	require.NoError(t, db.Create([]*models.SSHKey{
+		{ID: uuid.New(), CreatedAt: *at(400), UserID: member.ID, Title: "old laptop", KeyData: "ssh-ed25519 AAAA1", Fingerprint: "SHA256:old", LastUsedAt: at(200)},
+		{ID: uuid.New(), CreatedAt: *at(400), UserID: member.ID, Title: "laptop", KeyData: "ssh-ed25519 AAAA2", Fingerprint: "SHA256:new", LastUsedAt: at(1)},
+	}).Error)
+
+	_, err := svc.OrganizationOverview(ctx, "acme", member.ID)
+	assert.ErrorIs(t, err, ErrSecurityOverviewForbidden)
+
+	overview, err := svc.OrganizationOverview(ctx, "acme", owner.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, overview.Repositories)
+	assert.Equal(t, 1, overview.Alerts[SecurityAlertSecretScanning].High)
+	assert.Equal(t, 1, overview.Alerts[SecurityAlertDependency].Critical)
+	assert.Equal(t, 0, overview.Alerts[SecurityAlertCodeScanning].Open)
+	assert.Equal(t, []string{"docs"}, overview.UnprotectedDefaultBranches)
+	assert.Equal(t, TwoFactorAdoption{Members: 2, Enabled: 1, Missing: []string{"max"}}, overview.TwoFactor)
+	assert.Equal(t, StaleCredentialSummary{InactiveDays: 90, Total: 2, Inactive: 1}, overview.Credentials)
+
+	// Secrets 25*0.875 + dependencies 20*0.75 + code scanning 15 +
+	// branch protection 15*0.5 + two-factor 15*0.5 + credentials 10*0.5
+	points := map[string]float64{}
+	for _, component := range overview.Components {
+		points[component.Name] = component.Points
+	}
+	assert.Equal(t, map[string]float64{SecurityComponentSecretScanning: 21.9, SecurityComponentDependencies: 15, SecurityComponentCodeScanning: 15,
+		SecurityComponentBranchProtection: 7.5, SecurityComponentTwoFactor: 7.5, SecurityComponentCredentials: 5}, points)
+	assert.Equal(t, 71.9, overview.Score)
+
+	repos, err := svc.ListRepositories(ctx, "acme", owner.ID)
+	require.NoError(t, err)
+	require.Len(t, repos, 2)
+	assert.Equal(t, "app", repos[0].Name, "the lowest scores come first")
+	assert.Equal(t, 78.3, repos[0].Score)
+	assert.True(t, repos[0].DefaultBranchProtected)
+	assert.Empty(t, repos[0].OpenAlerts)
+	assert.Equal(t, 80.0, repos[1].Score)
+
+	repo, err := svc.GetRepository(ctx, "acme", "app", owner.ID)
+	require.NoError(t, err)
+	require.Len(t, repo.OpenAlerts, 2)
+	assert.Equal(t, 1, repo.OpenAlerts[0].Number)
+	_, err = svc.GetRepository(ctx, "acme", "missing", owner.ID)
+	assert.ErrorIs(t, err, ErrSecurityRepositoryNotFound)
+}
@@ -0,0 +1,469 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+	"github.com/a5c-ai/hub/internal/audit"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSigningKeyNotFound is returned when a user has no signing key
+	// with the given ID
+	ErrSigningKeyNotFound = errors.New("signing key not found")
+	// ErrSigningKeyExists is returned when a signing key was already added
+	ErrSigningKeyExists = errors.New("signing key already exists")
+	// ErrInvalidSigningKey is returned for keys that cannot be parsed or
+	// used for signing
+	ErrInvalidSigningKey = errors.New("invalid signing key")
+)
+
+// Verification reasons, as GitHub reports them
+const (
+	VerificationValid                = "valid"
+	VerificationUnsigned             = "unsigned"
+	VerificationUnknownSignatureType = "unknown_signature_type"
+	VerificationMalformedSignature   = "malformed_signature"
+	VerificationNoUser               = "no_user"
+	VerificationUnknownKey           = "unknown_key"
+	VerificationInvalid              = "invalid"
+	VerificationExpiredKey           = "expired_key"
+	VerificationNotSigningKey        = "not_signing_key"
+	VerificationBadEmail             = "bad_email"
+	VerificationUnverifiedEmail      = "unverified_email"
+)
+
+const (
+	pgpSignaturePrefix = "-----BEGIN PGP SIGNATURE-----"
+	sshSignaturePrefix = "-----BEGIN SSH SIGNATURE-----"
+)
+
+// SigningKeyService manages the GPG and SSH keys users sign commits and
+// tags with, and verifies signatures against them
+type SigningKeyService interface {
+	ListGPGKeys(ctx context.Context, userID uuid.UUID) ([]models.GPGKey, error)
+	GetGPGKey(ctx context.Context, userID, id uuid.UUID) (*models.GPGKey, error)
+	AddGPGKey(ctx context.Context, userID uuid.UUID, name, armoredKey string) (*models.GPGKey, error)
+	DeleteGPGKey(ctx context.Context, userID, id uuid.UUID) error
+
+	ListSSHSigningKeys(ctx context.Context, userID uuid.UUID) ([]models.SSHSigningKey, error)
+	GetSSHSigningKey(ctx context.Context, userID, id uuid.UUID) (*models.SSHSigningKey, error)
+	AddSSHSigningKey(ctx context.Context, userID uuid.UUID, title, key string) (*models.SSHSigningKey, error)
+	DeleteSSHSigningKey(ctx context.Context, userID, id uuid.UUID) error
+
+	// Verify checks sig, made by the committer or tagger with email
+	Verify(ctx context.Context, sig *git.Signature, email string) *git.Verification
+	// VerifyCommits sets the Verification of every commit
+	VerifyCommits(ctx context.Context, commits []*git.Commit)
+	// VerifyTags sets the Verification of every tag
+	VerifyTags(ctx context.Context, tags []*git.Tag)
+}
+
+type signingKeyService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+// NewSigningKeyService creates a new signing key service
+func NewSigningKeyService(db *gorm.DB, logger *logrus.Logger) SigningKeyService {
+	return &signingKeyService{db: db, logger: logger}
+}
+
+// ListGPGKeys returns the GPG keys of a user with their subkeys
+func (s *signingKeyService) ListGPGKeys(ctx context.Context, userID uuid.UUID) ([]models.GPGKey, error) {
+	var keys []models.GPGKey
+	if err := s.db.WithContext(ctx).Preload("Subkeys").
+		Where("user_id = ? AND primary_key_id IS NULL", userID).
+		Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list GPG keys: %w", err)
+	}
+	return keys, nil
+}
+
+// GetGPGKey returns a GPG key of a user
+func (s *signingKeyService) GetGPGKey(ctx context.Context, userID, id uuid.UUID) (*models.GPGKey, error) {
+	var key models.GPGKey
+	err := s.db.WithContext(ctx).Preload("Subkeys").
+		Where("id = ? AND user_id = ? AND primary_key_id IS NULL", id, userID).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSigningKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get GPG key: %w", err)
+	}
+	return &key, nil
+}
+
+// AddGPGKey parses an armored OpenPGP public key and stores it with its
+// subkeys for userID
+func (s *signingKeyService) AddGPGKey(ctx context.Context, userID uuid.UUID, name, armoredKey string) (*models.GPGKey, error) {
+	armoredKey = strings.TrimSpace(armoredKey)
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armoredKey))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSigningKey, err)
+	}
+	if len(entities) != 1 {
+		return nil, fmt.Errorf("%w: expected a single public key, got %d", ErrInvalidSigningKey, len(entities))
+	}
+	entity := entities[0]
+	if entity.PrivateKey != nil {
+		return nil, fmt.Errorf("%w: a private key was given", ErrInvalidSigningKey)
+	}
+	selfSig, _ := entity.PrimarySelfSignature()
+	if selfSig == nil {
+		return nil, fmt.Errorf("%w: the key has no self-signature", ErrInvalidSigningKey)
+	}
+
+	key := &models.GPGKey{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Name:        name,
+		KeyID:       gpgKeyID(entity.PrimaryKey.KeyId),
+		Fingerprint: strings.ToUpper(hex.EncodeToString(entity.PrimaryKey.Fingerprint)),
+		PublicKey:   armoredKey,
+		Emails:      []string{},
+		CanSign:     entity.PrimaryKey.PubKeyAlgo.CanSign() && (!selfSig.FlagsValid || selfSig.FlagSign),
+		ExpiresAt:   gpgKeyExpiry(entity.PrimaryKey, selfSig),
+	}
+	for _, identity := range entity.Identities {
+		if identity.UserId != nil && identity.UserId.Email != "" {
+			key.Emails = append(key.Emails, strings.ToLower(identity.UserId.Email))
+		}
+	}
+	for _, subkey := range entity.Subkeys {
+		key.Subkeys = append(key.Subkeys, models.GPGKey{
+			ID:           uuid.New(),
+			UserID:       userID,
+			PrimaryKeyID: &key.ID,
+			KeyID:        gpgKeyID(subkey.PublicKey.KeyId),
+			Fingerprint:  strings.ToUpper(hex.EncodeToString(subkey.PublicKey.Fingerprint)),
+			Emails:       []string{},
+			CanSign:      subkey.PublicKey.PubKeyAlgo.CanSign() && subkey.Sig.FlagsValid && subkey.Sig.FlagSign,
+			ExpiresAt:    gpgKeyExpiry(subkey.PublicKey, subkey.Sig),
+		})
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.GPGKey{}).Where("key_id = ? AND primary_key_id IS NULL", key.KeyID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrSigningKeyExists
+		}
+		return tx.Create(key).Error
+	})
+	if errors.Is(err, ErrSigningKeyExists) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to add GPG key: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id": userID,
+		"key_id":  key.KeyID,
+	}).Info("GPG key added")
+	audit.Record(ctx, audit.Event{
+		Action:     audit.ActionSigningKeyAdd,
+		TargetType: "gpg_key",
+		TargetID:   key.ID.String(),
+		TargetName: key.KeyID,
+		Metadata:   map[string]interface{}{"fingerprint": key.Fingerprint},
+	})
+	return key, nil
+}
+
+// DeleteGPGKey removes a GPG key of a user along with its subkeys
+func (s *signingKeyService) DeleteGPGKey(ctx context.Context, userID, id uuid.UUID) error {
+	var deleted int64
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ? AND primary_key_id IS NULL", id, userID).Delete(&models.GPGKey{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return tx.Where("primary_key_id = ?", id).Delete(&models.GPGKey{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete GPG key: %w", err)
+	}
+	if deleted == 0 {
+		return ErrSigningKeyNotFound
+	}
+	audit.Record(ctx, audit.Event{Action: audit.ActionSigningKeyRemove, TargetType: "gpg_key", TargetID: id.String()})
+	return nil
+}
+
+// ListSSHSigningKeys returns the SSH signing keys of a user
+func (s *signingKeyService) ListSSHSigningKeys(ctx context.Context, userID uuid.UUID) ([]models.SSHSigningKey, error) {
+	var keys []models.SSHSigningKey
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("failed to list SSH signing keys: %w", err)
+	}
+	return keys, nil
+}
+
+// GetSSHSigningKey returns an SSH signing key of a user
+func (s *signingKeyService) GetSSHSigningKey(ctx context.Context, userID, id uuid.UUID) (*models.SSHSigningKey, error) {
+	var key models.SSHSigningKey
+	err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSigningKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SSH signing key: %w", err)
+	}
+	return &key, nil
+}
+
+// AddSSHSigningKey stores an SSH public key in authorized_keys format as a
+// signing key of userID
+func (s *signingKeyService) AddSSHSigningKey(ctx context.Context, userID uuid.UUID, title, key string) (*models.SSHSigningKey, error) {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSigningKey, err)
+	}
+	signingKey := &models.SSHSigningKey{
+		ID:          uuid.New(),
+		UserID:      userID,
+		Title:       title,
+		Key:         strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey))),
+		Fingerprint: ssh.FingerprintSHA256(publicKey),
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.SSHSigningKey{}).Where("user_id = ? AND fingerprint = ?", userID, signingKey.Fingerprint).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrSigningKeyExists
+		}
+		return tx.Create(signingKey).Error
+	})
+	if errors.Is(err, ErrSigningKeyExists) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to add SSH signing key: %w", err)
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"user_id":     userID,
+		"fingerprint": signingKey.Fingerprint,
+	}).Info("SSH signing key added")
+	audit.Record(ctx, audit.Event{
+		Action:     audit.ActionSigningKeyAdd,
+		TargetType: "ssh_signing_key",
+		TargetID:   signingKey.ID.String(),
+		TargetName: signingKey.Title,
+		Metadata:   map[string]interface{}{"fingerprint": signingKey.Fingerprint},
+	})
+	return signingKey, nil
+}
+
+// DeleteSSHSigningKey removes an SSH signing key of a user
+func (s *signingKeyService) DeleteSSHSigningKey(ctx context.Context, userID, id uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.SSHSigningKey{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete SSH signing key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSigningKeyNotFound
+	}
+	audit.Record(ctx, audit.Event{Action: audit.ActionSigningKeyRemove, TargetType: "ssh_signing_key", TargetID: id.String()})
+	return nil
+}
+
+// VerifyCommits sets the Verification of every commit from the signature
+// and the committer email
+func (s *signingKeyService) VerifyCommits(ctx context.Context, commits []*git.Commit) {
+	for _, commit := range commits {
+		commit.Verification = s.Verify(ctx, commit.Signature, commit.Committer.Email)
+	}
+}
+
+// VerifyTags sets the Verification of every tag from the signature and the
+// tagger email
+func (s *signingKeyService) VerifyTags(ctx context.Context, tags []*git.Tag) {
+	for _, tag := range tags {
+		var email string
+		if tag.Tagger != nil {
+			email = tag.Tagger.Email
+		}
+		tag.Verification = s.Verify(ctx, tag.Signature, email)
+	}
+}
+
+// Verify checks sig, made by the committer or tagger with email
+func (s *signingKeyService) Verify(ctx context.Context, sig *git.Signature, email string) *git.Verification {
+	verification, _ := s.verify(ctx, sig, email)
+	return verification
+}
+
+// verify checks sig like Verify, also returning the ID of the signer when
+// the signature is valid. Like GitHub, the signature must be made with a
+// key of the user owning the email, and that email must be verified.
+func (s *signingKeyService) verify(ctx context.Context, sig *git.Signature, email string) (*git.Verification, *uuid.UUID) {
+	if sig == nil {
+		return &git.Verification{Reason: VerificationUnsigned}, nil
+	}
+	verification := &git.Verification{Signature: sig.Armored, Payload: string(sig.Payload)}
+	fail := func(reason string) (*git.Verification, *uuid.UUID) {
+		verification.Reason = reason
+		return verification, nil
+	}
+
+	armored := strings.TrimSpace(sig.Armored)
+	keyType := ""
+	switch {
+	case strings.HasPrefix(armored, pgpSignaturePrefix):
+		keyType = models.SigningKeyTypeGPG
+	case strings.HasPrefix(armored, sshSignaturePrefix):
+		keyType = models.SigningKeyTypeSSH
+	default:
+		return fail(VerificationUnknownSignatureType)
+	}
+
+	var user models.User
+	if email == "" {
+		return fail(VerificationNoUser)
+	}
+	err := s.db.WithContext(ctx).Where("LOWER(email) = ?", strings.ToLower(email)).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fail(VerificationNoUser)
+	}
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to look up signer")
+		return fail(VerificationInvalid)
+	}
+
+	var keyID, reason string
+	if keyType == models.SigningKeyTypeGPG {
+		keyID, reason = s.verifyGPG(ctx, user.ID, armored, sig.Payload, email)
+	} else {
+		keyID, reason = s.verifySSH(ctx, user.ID, armored, sig.Payload)
+	}
+	if reason != "" {
+		return fail(reason)
+	}
+	if !user.EmailVerified {
+		return fail(VerificationUnverifiedEmail)
+	}
+
+	now := time.Now()
+	verification.Verified = true
+	verification.Reason = VerificationValid
+	verification.VerifiedAt = &now
+	verification.Signer = &git.VerificationSigner{Login: user.Username, KeyType: keyType, KeyID: keyID}
+	return verification, &user.ID
+}
+
+// verifyGPG checks an OpenPGP signature against the GPG keys of userID,
+// returning the ID of the signing key or why the signature is not valid
+func (s *signingKeyService) verifyGPG(ctx context.Context, userID uuid.UUID, armored string, payload []byte, email string) (string, string) {
+	block, err := armor.Decode(strings.NewReader(armored))
+	if err != nil {
+		return "", VerificationMalformedSignature
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return "", VerificationMalformedSignature
+	}
+	signature, ok := p.(*packet.Signature)
+	if !ok || signature.IssuerKeyId == nil {
+		return "", VerificationMalformedSignature
+	}
+	keyID := gpgKeyID(*signature.IssuerKeyId)
+
+	var key models.GPGKey
+	err = s.db.WithContext(ctx).Where("user_id = ? AND key_id = ?", userID, keyID).First(&key).Error
+	if err != nil {
+		return keyID, VerificationUnknownKey
+	}
+	primary := key
+	if key.PrimaryKeyID != nil {
+		if err := s.db.WithContext(ctx).First(&primary, "id = ?", *key.PrimaryKeyID).Error; err != nil {
+			return keyID, VerificationUnknownKey
+		}
+	}
+	if !key.CanSign {
+		return keyID, VerificationNotSigningKey
+	}
+
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(primary.PublicKey))
+	if err != nil {
+		s.logger.WithError(err).WithField("key_id", primary.KeyID).Warn("Failed to read stored GPG key")
+		return keyID, VerificationInvalid
+	}
+	_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(payload), strings.NewReader(armored), nil)
+	switch {
+	case err == nil:
+	case errors.Is(err, pgperrors.ErrKeyExpired), errors.Is(err, pgperrors.ErrSignatureExpired):
+		return keyID, VerificationExpiredKey
+	case errors.Is(err, pgperrors.ErrUnknownIssuer):
+		return keyID, VerificationNotSigningKey
+	default:
+		return keyID, VerificationInvalid
+	}
+
+	for _, keyEmail := range primary.Emails {
+		if strings.EqualFold(keyEmail, email) {
+			return keyID, ""
+		}
+	}
+	return keyID, VerificationBadEmail
+}
+
+// verifySSH checks an SSH signature against the SSH signing keys of userID,
+// returning the fingerprint of the signing key or why the signature is not
+// valid
+func (s *signingKeyService) verifySSH(ctx context.Context, userID uuid.UUID, armored string, payload []byte) (string, string) {
+	sshSig, err := parseSSHSignature(armored)
+	if err != nil {
+		return "", VerificationMalformedSignature
+	}
+	fingerprint := ssh.FingerprintSHA256(sshSig.publicKey)
+
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.SSHSigningKey{}).
+		Where("user_id = ? AND fingerprint = ?", userID, fingerprint).Count(&count).Error; err != nil || count == 0 {
+		return fingerprint, VerificationUnknownKey
+	}
+	if err := sshSig.verify(payload); err != nil {
+		return fingerprint, VerificationInvalid
+	}
+	return fingerprint, ""
+}
+
+// gpgKeyID formats an OpenPGP key ID the way gpg --keyid-format long does
+func gpgKeyID(id uint64) string {
+	return fmt.Sprintf("%016X", id)
+}
+
+// gpgKeyExpiry returns when a key expires according to its self-signature
+func gpgKeyExpiry(key *packet.PublicKey, selfSig *packet.Signature) *time.Time {
+	if selfSig == nil || selfSig.KeyLifetimeSecs == nil || *selfSig.KeyLifetimeSecs == 0 {
+		return nil
+	}
+	expiry := key.CreationTime.Add(time.Duration(*selfSig.KeyLifetimeSecs) * time.Second)
+	return &expiry
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// signSSH signs payload the way git does with gpg.format=ssh
+func signSSH(t *testing.T, signer ssh.Signer, namespace string, payload []byte) string {
+	t.Helper()
+	digest := sha512.Sum512(payload)
+	signed := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignedData{
+		Namespace: namespace, HashAlgorithm: "sha512", Hash: digest[:],
+	})...)
+	sig, err := signer.Sign(rand.Reader, signed)
+	require.NoError(t, err)
+	blob := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignatureBlob{
+		Version: sshSignatureVersion, PublicKey: signer.PublicKey().Marshal(), Namespace: namespace,
+		HashAlgorithm: "sha512", Signature: ssh.Marshal(sig),
+	})...)
+	return sshSignaturePrefix + "\n" + base64.StdEncoding.EncodeToString(blob) + "\n" + sshSignatureSuffix + "\n"
+}
+
+func armoredPublicKey(t *testing.T, entity *openpgp.Entity) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(w))
+	require.NoError(t, w.Close())
+	return buf.String()
+}
+
+func TestSigningKeyService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.GPGKey{}, &models.SSHSigningKey{}, &models.Commit{}, &models.CommitTrailer{})
+	ctx := context.Background()
+	logger := logrus.New()
+	svc := NewSigningKeyService(db, logger)
+
+	jane := &models.User{ID: uuid.New(), Username: "jane", Email: "jane@example.com", EmailVerified: true}
+	john := &models.User{ID: uuid.New(), Username: "john", Email: "john@example.com"}
+	require.NoError(t, db.Create([]*models.User{jane, john}).Error)
+
+	entity, err := openpgp.NewEntity("Jane", "", "jane@example.com", nil)
+	require.NoError(t, err)
+	_, sshKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh.NewSignerFromKey(sshKey)
+	require.NoError(t, err)
+
+	t.Run("keys", func(t *testing.T) {
+		gpgKey, err := svc.AddGPGKey(ctx, jane.ID, "laptop", armoredPublicKey(t, entity))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"jane@example.com"}, gpgKey.Emails)
+		assert.True(t, gpgKey.CanSign)
+		assert.Len(t, gpgKey.KeyID, 16)
+		require.Len(t, gpgKey.Subkeys, 1)
+
+		_, err = svc.AddGPGKey(ctx, john.ID, "", armoredPublicKey(t, entity))
+		assert.ErrorIs(t, err, ErrSigningKeyExists)
+		_, err = svc.AddGPGKey(ctx, john.ID, "", "not a key")
+		assert.ErrorIs(t, err, ErrInvalidSigningKey)
+
+		keys, err := svc.ListGPGKeys(ctx, jane.ID)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.Len(t, keys[0].Subkeys, 1)
+
+		_, err = svc.AddSSHSigningKey(ctx, jane.ID, "laptop", string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+		require.NoError(t, err)
+		_, err = svc.AddSSHSigningKey(ctx, jane.ID, "again", string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+		assert.ErrorIs(t, err, ErrSigningKeyExists)
+	})
+
+	t.Run("signed commits", func(t *testing.T) {
+		repoPath := t.TempDir()
+		repo, err := gogit.PlainInit(repoPath, false)
+		require.NoError(t, err)
+		worktree, err := repo.Worktree()
+		require.NoError(t, err)
+		author := &object.Signature{Name: "Jane", Email: "jane@example.com", When: time.Now()}
+		_, err = worktree.Commit("Signed", &gogit.CommitOptions{AllowEmptyCommits: true, Author: author, SignKey: entity})
+		require.NoError(t, err)
+		_, err = worktree.Commit("Unsigned", &gogit.CommitOptions{AllowEmptyCommits: true, Author: author})
+		require.NoError(t, err)
+
+		commits, err := git.NewGitService(logger).GetCommits(ctx, repoPath, git.CommitOptions{Branch: "HEAD", PerPage: 10})
+		require.NoError(t, err)
+		require.Len(t, commits, 2)
+		svc.VerifyCommits(ctx, commits)
+
+		assert.Equal(t, &git.Verification{Reason: VerificationUnsigned}, commits[0].Verification)
+		verification := commits[1].Verification
+		assert.True(t, verification.Verified)
+		assert.Equal(t, VerificationValid, verification.Reason)
+		assert.Contains(t, verification.Payload, "Signed")
+		require.NotNil(t, verification.Signer)
+		assert.Equal(t, "jane", verification.Signer.Login)
+		assert.Equal(t, models.SigningKeyTypeGPG, verification.Signer.KeyType)
+
+		// Synced commits record who signed them
+		repoService := NewRepositoryService(db, git.NewGitService(logger), logger, t.TempDir()).(*repositoryService)
+		repoID := uuid.New()
+		require.NoError(t, repoService.syncCommitBatch(ctx, repoID, commits))
+		var synced models.Commit
+		require.NoError(t, db.Where("repository_id = ? AND sha = ?", repoID, commits[1].SHA).First(&synced).Error)
+		assert.True(t, synced.Verified)
+		assert.Equal(t, VerificationValid, synced.VerificationReason)
+		assert.Equal(t, jane.ID, *synced.SignerID)
+	})
+
+	t.Run("ssh signatures", func(t *testing.T) {
+		payload := []byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n\nSigned with SSH\n")
+		verification := svc.Verify(ctx, &git.Signature{Armored: signSSH(t, signer, "git", payload), Payload: payload}, "Jane@example.com")
+		assert.True(t, verification.Verified)
+		assert.Equal(t, models.SigningKeyTypeSSH, verification.Signer.KeyType)
+		assert.Equal(t, ssh.FingerprintSHA256(signer.PublicKey()), verification.Signer.KeyID)
+
+		tampered := svc.Verify(ctx, &git.Signature{Armored: signSSH(t, signer, "git", payload), Payload: []byte("other")}, "jane@example.com")
+		assert.Equal(t, VerificationInvalid, tampered.Reason)
+		namespace := svc.Verify(ctx, &git.Signature{Armored: signSSH(t, signer, "file", payload), Payload: payload}, "jane@example.com")
+		assert.Equal(t, VerificationInvalid, namespace.Reason)
+	})
+
+	t.Run("failures", func(t *testing.T) {
+		payload := []byte("payload")
+		signature := signSSH(t, signer, "git", payload)
+		reason := func(armored, email string) string {
+			verification := svc.Verify(ctx, &git.Signature{Armored: armored, Payload: payload}, email)
+			assert.False(t, verification.Verified)
+			assert.Nil(t, verification.Signer)
+			return verification.Reason
+		}
+		assert.Equal(t, VerificationNoUser, reason(signature, "nobody@example.com"))
+		assert.Equal(t, VerificationUnknownKey, reason(signature, "john@example.com"))
+		assert.Equal(t, VerificationMalformedSignature, reason(sshSignaturePrefix+"\n!!\n"+sshSignatureSuffix, "jane@example.com"))
+		assert.Equal(t, VerificationUnknownSignatureType, reason("-----BEGIN SIGNED MESSAGE-----", "jane@example.com"))
+
+		var pgp bytes.Buffer
+		require.NoError(t, openpgp.ArmoredDetachSign(&pgp, entity, bytes.NewReader(payload), nil))
+		// The key does not list the committer email
+		require.NoError(t, db.Model(&models.User{}).Where("id = ?", jane.ID).Update("email", "jane@work.example.com").Error)
+		assert.Equal(t, VerificationBadEmail, reason(pgp.String(), "jane@work.example.com"))
+		// Signed with a key of an unverified email
+		_, err := svc.AddSSHSigningKey(ctx, john.ID, "laptop", string(ssh.MarshalAuthorizedKey(signer.PublicKey())))
+		require.NoError(t, err)
+		assert.Equal(t, VerificationUnverifiedEmail, reason(signature, "john@example.com"))
+	})
+
+	t.Run("deleting keys", func(t *testing.T) {
+		keys, err := svc.ListGPGKeys(ctx, jane.ID)
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.ErrorIs(t, svc.DeleteGPGKey(ctx, john.ID, keys[0].ID), ErrSigningKeyNotFound)
+		require.NoError(t, svc.DeleteGPGKey(ctx, jane.ID, keys[0].ID))
+		var remaining int64
+		require.NoError(t, db.Model(&models.GPGKey{}).Count(&remaining).Error)
+		assert.Zero(t, remaining)
+		_, err = svc.GetGPGKey(ctx, jane.ID, keys[0].ID)
+		assert.ErrorIs(t, err, ErrSigningKeyNotFound)
+	})
+}
+
+func TestParseSSHSignatureRejectsGarbage(t *testing.T) {
+	for _, armored := range []string{"", sshSignaturePrefix, sshSignaturePrefix + "\nAAAA\n" + sshSignatureSuffix,
+		sshSignaturePrefix + "\n" + base64.StdEncoding.EncodeToString([]byte("SSHSIG")) + "\n" + sshSignatureSuffix} {
+		_, err := parseSSHSignature(strings.TrimSpace(armored))
+		assert.Error(t, err)
+	}
+}
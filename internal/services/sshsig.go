@@ -0,0 +1,112 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// The SSHSIG format git uses for SSH signatures, see PROTOCOL.sshsig in
+// OpenSSH
+const (
+	sshSignatureMagic     = "SSHSIG"
+	sshSignatureVersion   = 1
+	sshSignatureSuffix    = "-----END SSH SIGNATURE-----"
+	sshSignatureNamespace = "git"
+)
+
+// sshSignature is a parsed SSHSIG signature
+type sshSignature struct {
+	publicKey     ssh.PublicKey
+	namespace     string
+	hashAlgorithm string
+	signature     *ssh.Signature
+}
+
+// sshSignatureBlob is the wire format following the magic preamble
+type sshSignatureBlob struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is what the key actually signs, following the magic
+// preamble
+type sshSignedData struct {
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// parseSSHSignature decodes an armored SSHSIG signature
+func parseSSHSignature(armored string) (*sshSignature, error) {
+	armored = strings.TrimSpace(armored)
+	if !strings.HasPrefix(armored, sshSignaturePrefix) || !strings.HasSuffix(armored, sshSignatureSuffix) {
+		return nil, errors.New("not an armored SSH signature")
+	}
+	body := strings.Join(strings.Fields(armored[len(sshSignaturePrefix):len(armored)-len(sshSignatureSuffix)]), "")
+	raw, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH signature encoding: %w", err)
+	}
+	if !strings.HasPrefix(string(raw), sshSignatureMagic) {
+		return nil, errors.New("missing SSHSIG preamble")
+	}
+
+	var blob sshSignatureBlob
+	if err := ssh.Unmarshal(raw[len(sshSignatureMagic):], &blob); err != nil {
+		return nil, fmt.Errorf("invalid SSH signature: %w", err)
+	}
+	if blob.Version != sshSignatureVersion {
+		return nil, fmt.Errorf("unsupported SSH signature version %d", blob.Version)
+	}
+	publicKey, err := ssh.ParsePublicKey(blob.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SSH signature key: %w", err)
+	}
+	signature := new(ssh.Signature)
+	if err := ssh.Unmarshal(blob.Signature, signature); err != nil {
+		return nil, fmt.Errorf("invalid SSH signature: %w", err)
+	}
+	return &sshSignature{
+		publicKey:     publicKey,
+		namespace:     blob.Namespace,
+		hashAlgorithm: blob.HashAlgorithm,
+		signature:     signature,
+	}, nil
+}
+
+// verify checks that the signature was made over payload in the git
+// namespace
+func (s *sshSignature) verify(payload []byte) error {
+	if s.namespace != sshSignatureNamespace {
+		return fmt.Errorf("signature namespace is %q, not %q", s.namespace, sshSignatureNamespace)
+	}
+	var h hash.Hash
+	switch s.hashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported hash algorithm %q", s.hashAlgorithm)
+	}
+	h.Write(payload)
+
+	signed := append([]byte(sshSignatureMagic), ssh.Marshal(sshSignedData{
+		Namespace:     s.namespace,
+		HashAlgorithm: s.hashAlgorithm,
+		Hash:          h.Sum(nil),
+	})...)
+	return s.publicKey.Verify(signed, s.signature)
+}
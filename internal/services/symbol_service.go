@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SymbolService indexes code symbols per ref and answers code navigation queries
+type SymbolService interface {
+	IndexRef(ctx context.Context, repoID uuid.UUID, ref string) (int, error)
+	DeleteRef(ctx context.Context, repoID uuid.UUID, ref string) error
+	// IndexStatus reports the indexed refs of a repository and whether they
+	// lag behind their branch heads
+	IndexStatus(ctx context.Context, repo *models.Repository) (*RepositoryCodeIndex, error)
+	Search(ctx context.Context, repo *models.Repository, opts SymbolSearchOptions) ([]*models.CodeSymbol, int64, error)
+	Languages() []string
+
+	// HandlePush is a PushListener that re-indexes pushed branches
+	HandlePush(ctx context.Context, event PushEvent)
+}
+
+// SymbolSearchOptions filters a symbol search
+type SymbolSearchOptions struct {
+	Query           string // name prefix, or exact name when Exact is set
+	Exact           bool
+	Kind            string
+	Path            string // restrict to a single file, used by the code viewer
+	Ref             string // defaults to the repository default branch
+	DefinitionsOnly bool
+	// CrossRepository widens the search to the owner's other public repositories
+	CrossRepository bool
+	Page            int
+	PerPage         int
+}
+
+type symbolService struct {
+	db                *gorm.DB
+	gitService        git.GitService
+	repositoryService RepositoryService
+	extractors        *git.SymbolExtractorRegistry
+	cfg               config.Symbols
+	logger            *logrus.Logger
+}
+
+// NewSymbolService creates a new symbol index service
+func NewSymbolService(db *gorm.DB, gitService git.GitService, repositoryService RepositoryService, cfg config.Symbols, logger *logrus.Logger) SymbolService {
+	return &symbolService{
+		db:                db,
+		gitService:        gitService,
+		repositoryService: repositoryService,
+		extractors:        git.NewSymbolExtractorRegistry(cfg.Languages),
+		cfg:               cfg,
+		logger:            logger,
+	}
+}
+
+func (s *symbolService) Languages() []string {
+	return s.extractors.Languages()
+}
+
+// IndexRef replaces the symbol index of ref with definitions and references
+// extracted from the tree at its current commit, and records the run in the
+// ref's index status. Repositories and paths excluded by the owning
+// organization are left out.
+func (s *symbolService) IndexRef(ctx context.Context, repoID uuid.UUID, ref string) (int, error) {
+	started := time.Now()
+	status := &models.CodeIndexStatus{ID: uuid.New(), RepositoryID: repoID, Ref: ref}
+	count, err := s.indexRef(ctx, repoID, ref, status)
+	status.DurationMs = time.Since(started).Milliseconds()
+	if err != nil {
+		status.State = models.CodeIndexStateFailed
+		status.Error = err.Error()
+	}
+	if recordErr := s.recordStatus(ctx, status); recordErr != nil {
+		s.logger.WithError(recordErr).WithField("repository_id", repoID).Warn("Failed to record code index status")
+	}
+	return count, err
+}
+
+func (s *symbolService) indexRef(ctx context.Context, repoID uuid.UUID, ref string, status *models.CodeIndexStatus) (int, error) {
+	var repo models.Repository
+	if err := s.db.WithContext(ctx).First(&repo, "id = ?", repoID).Error; err != nil {
+		return 0, fmt.Errorf("failed to load repository: %w", err)
+	}
+	exclusions, err := loadCodeSearchExclusions(ctx, s.db, &repo)
+	if err != nil {
+		return 0, err
+	}
+	if exclusions.Repository {
+		if err := s.db.WithContext(ctx).Where("repository_id = ? AND ref = ?", repoID, ref).Delete(&models.CodeSymbol{}).Error; err != nil {
+			return 0, fmt.Errorf("failed to drop excluded symbols: %w", err)
+		}
+		status.State = models.CodeIndexStateExcluded
+		return 0, nil
+	}
+
+	repoPath, err := s.repositoryService.GetRepositoryPath(ctx, repoID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get repository path: %w", err)
+	}
+
+	sha, err := s.gitService.ResolveSHA(ctx, repoPath, ref)
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve ref: %w", err)
+	}
+
+	maxSize := int64(s.cfg.MaxFileSizeKB) * 1024
+	detector := git.NewLanguageDetector()
+	var symbols []*models.CodeSymbol
+	defined := make(map[string]bool)
+	files := make(map[string]string) // path -> language, for the reference pass
+
+	// First pass: definitions
+	err = s.gitService.WalkFiles(ctx, repoPath, sha, maxSize, func(path string, content []byte) error {
+		if exclusions.excludes(path) {
+			status.ExcludedFiles++
+			return nil
+		}
+		language := detector.DetectLanguage(path, content)
+		extractor, ok := s.extractors.For(language)
+		if !ok {
+			return nil
+		}
+		files[path] = language
+		status.Files++
+		status.SizeBytes += int64(len(content))
+		for _, sym := range extractor.ExtractDefinitions(content) {
+			defined[sym.Name] = true
+			symbols = append(symbols, &models.CodeSymbol{
+				ID:           uuid.New(),
+				RepositoryID: repoID,
+				Ref:          ref,
+				CommitSHA:    sha,
+				Path:         path,
+				Name:         sym.Name,
+				Kind:         sym.Kind,
+				Language:     language,
+				Line:         sym.Line,
+				Signature:    sym.Signature,
+				IsDefinition: true,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to extract definitions: %w", err)
+	}
+
+	// Second pass: references to names defined somewhere in this ref
+	definitionLines := make(map[string]bool, len(symbols))
+	for _, sym := range symbols {
+		definitionLines[fmt.Sprintf("%s:%d:%s", sym.Path, sym.Line, sym.Name)] = true
+	}
+	err = s.gitService.WalkFiles(ctx, repoPath, sha, maxSize, func(path string, content []byte) error {
+		language, ok := files[path]
+		if !ok {
+			return nil
+		}
+		for _, r := range git.ExtractReferences(content, defined) {
+			if definitionLines[fmt.Sprintf("%s:%d:%s", path, r.Line, r.Name)] {
+				continue
+			}
+			symbols = append(symbols, &models.CodeSymbol{
+				ID:           uuid.New(),
+				RepositoryID: repoID,
+				Ref:          ref,
+				CommitSHA:    sha,
+				Path:         path,
+				Name:         r.Name,
+				Language:     language,
+				Line:         r.Line,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to extract references: %w", err)
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("repository_id = ? AND ref = ?", repoID, ref).Delete(&models.CodeSymbol{}).Error; err != nil {
+			return err
+		}
+		if len(symbols) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(symbols, 500).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to store symbols: %w", err)
+	}
+
+	indexedAt := time.Now()
+	status.State = models.CodeIndexStateIndexed
+	status.CommitSHA = sha
+	status.Symbols = len(symbols)
+	status.IndexedAt = &indexedAt
+	return len(symbols), nil
+}
+
+// recordStatus stores the outcome of an indexing run. A failed run keeps
+// the commit and sizes of the index that is still being served.
+func (s *symbolService) recordStatus(ctx context.Context, status *models.CodeIndexStatus) error {
+	columns := []string{"state", "error", "duration_ms", "updated_at"}
+	if status.State != models.CodeIndexStateFailed {
+		columns = append(columns, "commit_sha", "files", "excluded_files", "symbols", "size_bytes", "indexed_at")
+	}
+	return s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "repository_id"}, {Name: "ref"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(status).Error
+}
+
+func (s *symbolService) DeleteRef(ctx context.Context, repoID uuid.UUID, ref string) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("repository_id = ? AND ref = ?", repoID, ref).Delete(&models.CodeSymbol{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("repository_id = ? AND ref = ?", repoID, ref).Delete(&models.CodeIndexStatus{}).Error
+	})
+}
+
+func (s *symbolService) IndexStatus(ctx context.Context, repo *models.Repository) (*RepositoryCodeIndex, error) {
+	var statuses []*models.CodeIndexStatus
+	if err := s.db.WithContext(ctx).Where("repository_id = ?", repo.ID).Order("ref ASC").Find(&statuses).Error; err != nil {
+		return nil, fmt.Errorf("failed to load code index status: %w", err)
+	}
+
+	index := &RepositoryCodeIndex{Enabled: s.cfg.Enabled, Refs: []*CodeIndexRefStatus{}}
+	exclusions, err := loadCodeSearchExclusions(ctx, s.db, repo)
+	if err != nil {
+		return nil, err
+	}
+	index.Excluded = exclusions.Repository
+
+	repoPath, err := s.repositoryService.GetRepositoryPath(ctx, repo.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository path: %w", err)
+	}
+	defaultIndexed := false
+	for _, status := range statuses {
+		ref := &CodeIndexRefStatus{CodeIndexStatus: status}
+		if status.State == models.CodeIndexStateIndexed {
+			// A ref that no longer resolves has been deleted and is stale too
+			ref.HeadSHA, _ = s.gitService.ResolveSHA(ctx, repoPath, status.Ref)
+			ref.Stale = ref.HeadSHA != status.CommitSHA
+		}
+		if status.Ref == repo.DefaultBranch {
+			defaultIndexed = status.State == models.CodeIndexStateIndexed
+		}
+		if ref.Stale {
+			index.Stale = true
+		}
+		index.Symbols += status.Symbols
+		index.SizeBytes += status.SizeBytes
+		if status.IndexedAt != nil && (index.LastIndexedAt == nil || status.IndexedAt.After(*index.LastIndexedAt)) {
+			index.LastIndexedAt = status.IndexedAt
+		}
+		index.Refs = append(index.Refs, ref)
+	}
+	if !defaultIndexed && !index.Excluded {
+		index.Stale = true
+	}
+	return index, nil
+}
+
+func (s *symbolService) Search(ctx context.Context, repo *models.Repository, opts SymbolSearchOptions) ([]*models.CodeSymbol, int64, error) {
+	ref := opts.Ref
+	if ref == "" {
+		ref = repo.DefaultBranch
+	}
+
+	query := s.db.WithContext(ctx).Model(&models.CodeSymbol{}).Where("code_symbols.ref = ?", ref)
+	if opts.CrossRepository {
+		query = query.Joins("JOIN repositories ON repositories.id = code_symbols.repository_id").
+			Where("repositories.deleted_at IS NULL").
+			Where("code_symbols.repository_id = ? OR (repositories.owner_id = ? AND repositories.owner_type = ? AND repositories.visibility = ?)",
+				repo.ID, repo.OwnerID, repo.OwnerType, models.VisibilityPublic)
+	} else {
+		query = query.Where("code_symbols.repository_id = ?", repo.ID)
+	}
+
+	if opts.Query != "" {
+		if opts.Exact {
+			query = query.Where("code_symbols.name = ?", opts.Query)
+		} else {
+			query = query.Where("code_symbols.name LIKE ? ESCAPE '\\'", escapeLikePattern(opts.Query)+"%")
+		}
+	}
+	if opts.Kind != "" {
+		query = query.Where("code_symbols.kind = ?", opts.Kind)
+	}
+	if opts.Path != "" {
+		query = query.Where("code_symbols.path = ?", opts.Path)
+	}
+	if opts.DefinitionsOnly {
+		query = query.Where("code_symbols.is_definition = ?", true)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if opts.PerPage <= 0 {
+		opts.PerPage = 30
+	}
+	if opts.Page <= 0 {
+		opts.Page = 1
+	}
+
+	var symbols []*models.CodeSymbol
+	err := query.Select("code_symbols.*").
+		Order("code_symbols.is_definition DESC, code_symbols.name ASC, code_symbols.path ASC, code_symbols.line ASC").
+		Offset((opts.Page - 1) * opts.PerPage).
+		Limit(opts.PerPage).
+		Find(&symbols).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return symbols, total, nil
+}
+
+// HandlePush re-indexes pushed branches and drops the index of deleted ones.
+// Only the default branch is indexed unless IndexAllBranches is configured.
+func (s *symbolService) HandlePush(ctx context.Context, event PushEvent) {
+	if !s.cfg.Enabled || event.Repository == nil {
+		return
+	}
+
+	for _, update := range event.Updates {
+		branch := update.BranchName()
+		if branch == "" {
+			continue
+		}
+		logger := s.logger.WithFields(logrus.Fields{
+			"repository_id": event.Repository.ID,
+			"ref":           branch,
+		})
+
+		if update.IsDelete() {
+			if err := s.DeleteRef(ctx, event.Repository.ID, branch); err != nil {
+				logger.WithError(err).Warn("Failed to delete symbol index")
+			}
+			continue
+		}
+		if !s.cfg.IndexAllBranches && branch != event.Repository.DefaultBranch {
+			continue
+		}
+
+		count, err := s.IndexRef(ctx, event.Repository.ID, branch)
+		if err != nil {
+			logger.WithError(err).Warn("Failed to index symbols")
+			continue
+		}
+		logger.WithField("symbols", count).Info("Indexed repository symbols")
+	}
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymbolService_SearchTreatsWildcardsLiterally(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.Repository{}, &models.CodeSymbol{})
+	svc := NewSymbolService(db, nil, nil, config.Symbols{Enabled: true}, logrus.New())
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	for _, name := range []string{"get_user", "getXuser", "percent%done", "percentage"} {
+		require.NoError(t, db.Create(&models.CodeSymbol{ID: uuid.New(), RepositoryID: repo.ID, Ref: "main", CommitSHA: "c0ffee", Path: "main.go", Name: name}).Error)
+	}
+
+	names := func(query string) []string {
+		symbols, _, err := svc.Search(context.Background(), repo, SymbolSearchOptions{Query: query})
+		require.NoError(t, err)
+		var found []string
+		for _, symbol := range symbols {
+			found = append(found, symbol.Name)
+		}
+		return found
+	}
+	assert.Equal(t, []string{"get_user"}, names("get_"))
+	assert.Equal(t, []string{"percent%done"}, names("percent%"))
+	assert.Empty(t, names("%"))
+}
@@ -25,6 +25,10 @@ type UpdateTeamRequest struct {
 	Description  *string             `json:"description,omitempty"`
 	Privacy      *models.TeamPrivacy `json:"privacy,omitempty"`
 	ParentTeamID *uuid.UUID          `json:"parent_team_id,omitempty"`
+
+	// IfVersion, taken from the If-Match header, rejects the update with
+	// ErrVersionConflict unless it matches the current version
+	IfVersion *int64 `json:"-"`
 }
 
 type TeamFilters struct {
@@ -132,6 +136,9 @@ func (s *teamService) Update(ctx context.Context, orgName, teamName string, req
 	if err := s.db.Where("organization_id = ? AND name = ?", org.ID, teamName).First(&team).Error; err != nil {
 		return nil, fmt.Errorf("team not found: %w", err)
 	}
+	if err := checkVersion(team.Version, req.IfVersion); err != nil {
+		return nil, err
+	}
 
 	updates := make(map[string]interface{})
 	if req.Name != nil {
@@ -154,8 +161,13 @@ func (s *teamService) Update(ctx context.Context, orgName, teamName string, req
 		updates["parent_team_id"] = req.ParentTeamID
 	}
 
-	if err := s.db.Model(&team).Updates(updates).Error; err != nil {
-		return nil, fmt.Errorf("failed to update team: %w", err)
+	if len(updates) > 0 {
+		if err := updateVersioned(s.db.Model(&models.Team{}).Where("id = ?", team.ID), updates, req.IfVersion); err != nil {
+			if errors.Is(err, ErrVersionConflict) {
+				return nil, err
+			}
+			return nil, fmt.Errorf("failed to update team: %w", err)
+		}
 	}
 
 	// Load relationships
@@ -300,6 +312,16 @@ func (s *teamMembershipService) AddMember(ctx context.Context, orgName, teamName
 	// Load relationships
 	s.db.Preload("Team").Preload("User").First(member, member.ID)
 
+	// Log activity
+	if s.as != nil {
+		go func() {
+			s.as.LogActivity(context.Background(), org.ID, user.ID, models.ActivityTeamMemberAdded, "team", &team.ID, map[string]interface{}{
+				"user_id": user.ID,
+				"role":    role,
+			})
+		}()
+	}
+
 	return member, nil
 }
 
@@ -323,6 +345,15 @@ func (s *teamMembershipService) RemoveMember(ctx context.Context, orgName, teamN
 		return fmt.Errorf("failed to remove team member: %w", err)
 	}
 
+	// Log activity
+	if s.as != nil {
+		go func() {
+			s.as.LogActivity(context.Background(), org.ID, user.ID, models.ActivityTeamMemberRemoved, "team", &team.ID, map[string]interface{}{
+				"user_id": user.ID,
+			})
+		}()
+	}
+
 	return nil
 }
 
@@ -347,6 +378,7 @@ func (s *teamMembershipService) UpdateMemberRole(ctx context.Context, orgName, t
 		return nil, fmt.Errorf("team member not found: %w", err)
 	}
 
+	oldRole := member.Role
 	member.Role = role
 	if err := s.db.Save(&member).Error; err != nil {
 		return nil, fmt.Errorf("failed to update team member role: %w", err)
@@ -355,6 +387,17 @@ func (s *teamMembershipService) UpdateMemberRole(ctx context.Context, orgName, t
 	// Load relationships
 	s.db.Preload("Team").Preload("User").First(&member, member.ID)
 
+	// Log activity
+	if s.as != nil {
+		go func() {
+			s.as.LogActivity(context.Background(), org.ID, user.ID, models.ActivityTeamMemberRoleChanged, "team", &team.ID, map[string]interface{}{
+				"user_id":  user.ID,
+				"old_role": oldRole,
+				"new_role": role,
+			})
+		}()
+	}
+
 	return &member, nil
 }
 
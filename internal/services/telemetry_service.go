@@ -0,0 +1,301 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrTelemetryDisabled is returned when a report is requested while
+// telemetry is not enabled with an endpoint
+var ErrTelemetryDisabled = errors.New("telemetry is not enabled")
+
+// TelemetryReport is the anonymized usage report of an instance. It holds
+// aggregate counts and configuration switches only, never names, addresses
+// or anything else identifying users, repositories or the instance.
+type TelemetryReport struct {
+	InstanceID  string            `json:"instance_id"`
+	Version     string            `json:"version"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Database    string            `json:"database"`
+	Counts      TelemetryCounts   `json:"counts"`
+	Features    map[string]bool   `json:"features"`
+	Backends    map[string]string `json:"backends"`
+}
+
+// TelemetryCounts are instance wide object counts
+type TelemetryCounts struct {
+	Users               int64 `json:"users"`
+	ActiveUsers30d      int64 `json:"active_users_30d"`
+	Organizations       int64 `json:"organizations"`
+	Teams               int64 `json:"teams"`
+	Repositories        int64 `json:"repositories"`
+	PublicRepositories  int64 `json:"public_repositories"`
+	PrivateRepositories int64 `json:"private_repositories"`
+	Issues              int64 `json:"issues"`
+	PullRequests        int64 `json:"pull_requests"`
+	Webhooks            int64 `json:"webhooks"`
+	Runners             int64 `json:"runners"`
+}
+
+// TelemetryService reports anonymized aggregate usage to a fleet management
+// endpoint when enabled, and lets administrators preview the report
+type TelemetryService interface {
+	Settings() config.Telemetry
+	// Preview builds the report the next submission would send
+	Preview(ctx context.Context) (*TelemetryReport, error)
+	Submit(ctx context.Context, trigger models.TelemetryTrigger) (*models.TelemetrySubmission, error)
+	ListSubmissions(ctx context.Context, limit, offset int) ([]*models.TelemetrySubmission, int64, error)
+	StartScheduler(ctx context.Context)
+}
+
+type telemetryService struct {
+	db         *gorm.DB
+	cfg        *config.Config
+	httpClient *http.Client
+	logger     *logrus.Logger
+	now        func() time.Time
+}
+
+// NewTelemetryService creates a new TelemetryService
+func NewTelemetryService(db *gorm.DB, cfg *config.Config, logger *logrus.Logger) TelemetryService {
+	timeout := time.Duration(cfg.Telemetry.TimeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &telemetryService{
+		db:         db,
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: timeout},
+		logger:     logger,
+		now:        time.Now,
+	}
+}
+
+// Settings returns the telemetry configuration without the endpoint token
+func (s *telemetryService) Settings() config.Telemetry {
+	settings := s.cfg.Telemetry
+	if settings.Token != "" {
+		settings.Token = "********"
+	}
+	return settings
+}
+
+func (s *telemetryService) enabled() bool {
+	return s.cfg.Telemetry.Enabled && s.cfg.Telemetry.Endpoint != ""
+}
+
+func (s *telemetryService) Preview(ctx context.Context) (*TelemetryReport, error) {
+	instanceID, err := s.instanceID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.counts(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &TelemetryReport{
+		InstanceID:  instanceID.String(),
+		Version:     s.cfg.Application.Version,
+		GeneratedAt: s.now().UTC(),
+		Database:    s.db.Dialector.Name(),
+		Counts:      *counts,
+		Features:    telemetryFeatures(s.cfg),
+		Backends: map[string]string{
+			"lfs":       s.cfg.LFS.Backend,
+			"artifacts": s.cfg.Storage.Artifacts.Backend,
+		},
+	}, nil
+}
+
+// instanceID returns the identifier of this instance, creating it on first use
+func (s *telemetryService) instanceID(ctx context.Context) (uuid.UUID, error) {
+	db := s.db.WithContext(ctx)
+	var instance models.TelemetryInstance
+	err := db.Order("created_at ASC").First(&instance).Error
+	if err == nil {
+		return instance.ID, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return uuid.Nil, fmt.Errorf("failed to load telemetry instance: %w", err)
+	}
+
+	// Concurrent first reports may both insert; the oldest row wins
+	instance = models.TelemetryInstance{ID: uuid.New(), CreatedAt: s.now()}
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&instance).Error; err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create telemetry instance: %w", err)
+	}
+	if err := db.Order("created_at ASC").First(&instance).Error; err != nil {
+		return uuid.Nil, fmt.Errorf("failed to load telemetry instance: %w", err)
+	}
+	return instance.ID, nil
+}
+
+func (s *telemetryService) counts(ctx context.Context) (*TelemetryCounts, error) {
+	db := s.db.WithContext(ctx)
+	counts := &TelemetryCounts{}
+	activeSince := s.now().AddDate(0, 0, -30)
+
+	queries := []struct {
+		name   string
+		target *int64
+		query  *gorm.DB
+	}{
+		{"users", &counts.Users, db.Model(&models.User{})},
+		{"active users", &counts.ActiveUsers30d, db.Model(&models.User{}).Where("last_login_at >= ?", activeSince)},
+		{"organizations", &counts.Organizations, db.Model(&models.Organization{})},
+		{"teams", &counts.Teams, db.Model(&models.Team{})},
+		{"repositories", &counts.Repositories, db.Model(&models.Repository{})},
+		{"public repositories", &counts.PublicRepositories, db.Model(&models.Repository{}).Where("visibility = ?", models.VisibilityPublic)},
+		{"private repositories", &counts.PrivateRepositories, db.Model(&models.Repository{}).Where("visibility = ?", models.VisibilityPrivate)},
+		{"issues", &counts.Issues, db.Model(&models.Issue{})},
+		{"pull requests", &counts.PullRequests, db.Model(&models.PullRequest{})},
+		{"webhooks", &counts.Webhooks, db.Model(&models.Webhook{})},
+		{"runners", &counts.Runners, db.Model(&models.Runner{})},
+	}
+	for _, q := range queries {
+		if err := q.query.Count(q.target).Error; err != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", q.name, err)
+		}
+	}
+	return counts, nil
+}
+
+// telemetryFeatures reports which optional subsystems are switched on
+func telemetryFeatures(cfg *config.Config) map[string]bool {
+	return map[string]bool{
+		"redis":                  cfg.Redis.Enabled,
+		"elasticsearch":          cfg.Elasticsearch.Enabled,
+		"ssh":                    cfg.SSH.Enabled,
+		"sftp":                   cfg.SSH.Enabled && cfg.SSH.SFTPEnabled,
+		"saml":                   cfg.SAML.Enabled,
+		"ldap":                   cfg.LDAP.Enabled,
+		"smtp":                   cfg.SMTP.Host != "",
+		"oauth_github":           cfg.OAuth.GitHub.ClientID != "",
+		"oauth_google":           cfg.OAuth.Google.ClientID != "",
+		"oauth_microsoft":        cfg.OAuth.Microsoft.ClientID != "",
+		"oauth_gitlab":           cfg.OAuth.GitLab.ClientID != "",
+		"symbols":                cfg.Symbols.Enabled,
+		"analytics_retention":    cfg.Retention.Enabled,
+		"error_reporting":        cfg.ErrorReporting.Enabled,
+		"repository_schedules":   cfg.Schedules.Enabled,
+		"repository_maintenance": cfg.Storage.Maintenance.Enabled,
+		"pack_offload":           cfg.Storage.Packs.Enabled,
+	}
+}
+
+// Submit sends the current report and records the attempt
+func (s *telemetryService) Submit(ctx context.Context, trigger models.TelemetryTrigger) (*models.TelemetrySubmission, error) {
+	if !s.enabled() {
+		return nil, ErrTelemetryDisabled
+	}
+
+	report, err := s.Preview(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(report)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode telemetry report: %w", err)
+	}
+
+	submission := &models.TelemetrySubmission{
+		ID:        uuid.New(),
+		CreatedAt: s.now(),
+		Trigger:   trigger,
+		Endpoint:  s.cfg.Telemetry.Endpoint,
+		Status:    "success",
+		Payload:   string(payload),
+	}
+	statusCode, sendErr := s.send(ctx, payload)
+	submission.StatusCode = statusCode
+	if sendErr != nil {
+		submission.Status = "error"
+		submission.ErrorMessage = sendErr.Error()
+	}
+
+	if err := s.db.WithContext(ctx).Create(submission).Error; err != nil {
+		return submission, fmt.Errorf("failed to record telemetry submission: %w", err)
+	}
+	return submission, sendErr
+}
+
+func (s *telemetryService) send(ctx context.Context, payload []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Telemetry.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("invalid telemetry endpoint: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "a5c-hub/"+s.cfg.Application.Version)
+	if s.cfg.Telemetry.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.cfg.Telemetry.Token)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send telemetry report: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("telemetry endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (s *telemetryService) ListSubmissions(ctx context.Context, limit, offset int) ([]*models.TelemetrySubmission, int64, error) {
+	query := s.db.WithContext(ctx).Model(&models.TelemetrySubmission{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count telemetry submissions: %w", err)
+	}
+
+	var submissions []*models.TelemetrySubmission
+	if err := query.Order("created_at DESC").Limit(limit).Offset(offset).Find(&submissions).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to list telemetry submissions: %w", err)
+	}
+	return submissions, total, nil
+}
+
+// StartScheduler submits a report every IntervalHours until ctx is
+// cancelled. It returns immediately unless telemetry is enabled.
+func (s *telemetryService) StartScheduler(ctx context.Context) {
+	if !s.enabled() {
+		return
+	}
+	interval := time.Duration(s.cfg.Telemetry.IntervalHours) * time.Hour
+	if interval <= 0 {
+		interval = 24 * time.Hour
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			func() {
+				defer errorreporting.Default().Recover("telemetry_scheduler", nil)
+				if _, err := s.Submit(ctx, models.TelemetryScheduled); err != nil {
+					s.logger.WithError(err).Warn("Failed to submit telemetry report")
+				}
+			}()
+		}
+	}
+}
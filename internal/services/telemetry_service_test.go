@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTelemetryService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.Team{}, &models.Repository{},
+		&models.Issue{}, &models.PullRequest{}, &models.Webhook{}, &models.Runner{},
+		&models.TelemetryInstance{}, &models.TelemetrySubmission{})
+
+	ctx := context.Background()
+	now := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent, stale := now.AddDate(0, 0, -3), now.AddDate(0, 0, -90)
+	require.NoError(t, db.Create([]*models.User{
+		{ID: uuid.New(), Username: "alice", Email: "alice@example.com", PasswordHash: "x", LastLoginAt: &recent},
+		{ID: uuid.New(), Username: "bob", Email: "bob@example.com", PasswordHash: "x", LastLoginAt: &stale},
+	}).Error)
+	require.NoError(t, db.Create([]*models.Repository{
+		{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "public", Visibility: models.VisibilityPublic},
+		{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "private", Visibility: models.VisibilityPrivate},
+		{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "secret", Visibility: models.VisibilityPrivate},
+	}).Error)
+
+	var received []byte
+	var authorization string
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		authorization = r.Header.Get("Authorization")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{
+		Application: config.Application{Version: "1.2.3"},
+		Redis:       config.Redis{Enabled: true},
+		Telemetry:   config.Telemetry{Token: "fleet-token"},
+	}
+	svc := NewTelemetryService(db, cfg, logrus.New()).(*telemetryService)
+	svc.now = func() time.Time { return now }
+
+	t.Run("preview", func(t *testing.T) {
+		report, err := svc.Preview(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "1.2.3", report.Version)
+		assert.Equal(t, "sqlite", report.Database)
+		assert.EqualValues(t, 2, report.Counts.Users)
+		assert.EqualValues(t, 1, report.Counts.ActiveUsers30d)
+		assert.EqualValues(t, 3, report.Counts.Repositories)
+		assert.EqualValues(t, 1, report.Counts.PublicRepositories)
+		assert.EqualValues(t, 2, report.Counts.PrivateRepositories)
+		assert.True(t, report.Features["redis"])
+		assert.False(t, report.Features["saml"])
+
+		again, err := svc.Preview(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, report.InstanceID, again.InstanceID, "instance id must be stable")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		_, err := svc.Submit(ctx, models.TelemetryManual)
+		assert.ErrorIs(t, err, ErrTelemetryDisabled)
+		assert.Nil(t, received)
+		assert.Equal(t, "********", svc.Settings().Token)
+	})
+
+	cfg.Telemetry.Enabled = true
+	cfg.Telemetry.Endpoint = server.URL
+
+	t.Run("submit sends the preview", func(t *testing.T) {
+		preview, err := svc.Preview(ctx)
+		require.NoError(t, err)
+
+		submission, err := svc.Submit(ctx, models.TelemetryManual)
+		require.NoError(t, err)
+		assert.Equal(t, "success", submission.Status)
+		assert.Equal(t, http.StatusAccepted, submission.StatusCode)
+		assert.Equal(t, "Bearer fleet-token", authorization)
+		assert.JSONEq(t, submission.Payload, string(received))
+
+		var sent TelemetryReport
+		require.NoError(t, json.Unmarshal(received, &sent))
+		assert.Equal(t, *preview, sent)
+		assert.NotContains(t, string(received), "alice")
+		assert.NotContains(t, string(received), "secret")
+	})
+
+	t.Run("failed submissions are recorded", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		submission, err := svc.Submit(ctx, models.TelemetryScheduled)
+		require.Error(t, err)
+		require.NotNil(t, submission)
+		assert.Equal(t, "error", submission.Status)
+		assert.Equal(t, http.StatusInternalServerError, submission.StatusCode)
+
+		submissions, total, err := svc.ListSubmissions(ctx, 10, 0)
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, total)
+		assert.Len(t, submissions, 2)
+	})
+}
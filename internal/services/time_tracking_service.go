@@ -0,0 +1,384 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidTimeSpent = errors.New("invalid time tracking duration")
+
+// Time tracking counts working time: a day is 8 hours, a week 5 days and
+// a month 4 weeks
+var timeUnits = map[string]int64{
+	"mo": 4 * 5 * 8 * 3600,
+	"w":  5 * 8 * 3600,
+	"d":  8 * 3600,
+	"h":  3600,
+	"m":  60,
+	"s":  1,
+}
+
+var timeUnitOrder = []string{"mo", "w", "d", "h", "m", "s"}
+
+var timePartPattern = regexp.MustCompile(`^(\d+)(mo|w|d|h|m|s)`)
+
+// ParseTimeDuration parses a /spend style duration such as "1d 4h", "30m"
+// or "-1h30m" into seconds
+func ParseTimeDuration(value string) (int64, error) {
+	rest := strings.ReplaceAll(strings.TrimSpace(value), " ", "")
+	sign := int64(1)
+	if strings.HasPrefix(rest, "-") {
+		sign = -1
+		rest = rest[1:]
+	}
+	if rest == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidTimeSpent, value)
+	}
+
+	var total int64
+	for rest != "" {
+		match := timePartPattern.FindStringSubmatch(rest)
+		if match == nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidTimeSpent, value)
+		}
+		amount, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidTimeSpent, value)
+		}
+		total += amount * timeUnits[match[2]]
+		rest = rest[len(match[0]):]
+	}
+	return sign * total, nil
+}
+
+// FormatTimeDuration renders seconds in the units ParseTimeDuration accepts
+func FormatTimeDuration(seconds int64) string {
+	if seconds == 0 {
+		return "0m"
+	}
+	var parts []string
+	if seconds < 0 {
+		parts = append(parts, "-")
+		seconds = -seconds
+	}
+	for _, unit := range timeUnitOrder {
+		if n := seconds / timeUnits[unit]; n > 0 {
+			parts = append(parts, strconv.FormatInt(n, 10)+unit)
+			seconds %= timeUnits[unit]
+		}
+	}
+	return strings.Replace(strings.Join(parts, " "), "- ", "-", 1)
+}
+
+// TimeStats is the estimate and spent time of an issue
+type TimeStats struct {
+	TimeEstimate        int64  `json:"time_estimate"`
+	TotalTimeSpent      int64  `json:"total_time_spent"`
+	HumanTimeEstimate   string `json:"human_time_estimate"`
+	HumanTotalTimeSpent string `json:"human_total_time_spent"`
+}
+
+func timeStats(issue *models.Issue) *TimeStats {
+	return &TimeStats{
+		TimeEstimate:        issue.TimeEstimate,
+		TotalTimeSpent:      issue.TotalTimeSpent,
+		HumanTimeEstimate:   FormatTimeDuration(issue.TimeEstimate),
+		HumanTotalTimeSpent: FormatTimeDuration(issue.TotalTimeSpent),
+	}
+}
+
+type AddSpentTimeRequest struct {
+	Duration string     `json:"duration" binding:"required"`
+	SpentAt  *time.Time `json:"spent_at,omitempty"`
+	Summary  string     `json:"summary,omitempty"`
+}
+
+// TimeReportFilter scopes a time report to a repository or to every
+// repository of an organization, and to entries spent in [Since, Until)
+type TimeReportFilter struct {
+	RepositoryID   *uuid.UUID
+	OrganizationID *uuid.UUID
+	Since          *time.Time
+	Until          *time.Time
+}
+
+// UserTimeSpent is the time a user logged in a report's window
+type UserTimeSpent struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Username  string    `json:"username"`
+	TimeSpent int64     `json:"time_spent"`
+}
+
+// MilestoneTimeSpent is the estimate of a milestone's issues and the time
+// logged against them in a report's window. Issues without a milestone are
+// reported with a nil MilestoneID.
+type MilestoneTimeSpent struct {
+	MilestoneID  *uuid.UUID `json:"milestone_id"`
+	Title        string     `json:"title"`
+	TimeEstimate int64      `json:"time_estimate"`
+	TimeSpent    int64      `json:"time_spent"`
+}
+
+type TimeReport struct {
+	TotalTimeEstimate int64                `json:"total_time_estimate"`
+	TotalTimeSpent    int64                `json:"total_time_spent"`
+	TrackedIssues     int64                `json:"tracked_issues"`
+	ByUser            []UserTimeSpent      `json:"by_user"`
+	ByMilestone       []MilestoneTimeSpent `json:"by_milestone"`
+}
+
+// TimeTrackingService records estimates and spent time on issues and
+// reports on them
+type TimeTrackingService interface {
+	Stats(issue *models.Issue) *TimeStats
+	SetEstimate(ctx context.Context, issue *models.Issue, duration string) (*TimeStats, error)
+	ResetEstimate(ctx context.Context, issue *models.Issue) (*TimeStats, error)
+	// AddSpentTime logs time; negative durations subtract, but never below zero
+	AddSpentTime(ctx context.Context, issue *models.Issue, userID uuid.UUID, req AddSpentTimeRequest) (*TimeStats, error)
+	// ResetSpentTime logs a negative entry cancelling all spent time
+	ResetSpentTime(ctx context.Context, issue *models.Issue, userID uuid.UUID) (*TimeStats, error)
+	ListEntries(ctx context.Context, issue *models.Issue) ([]*models.TimeEntry, error)
+	Report(ctx context.Context, filter TimeReportFilter) (*TimeReport, error)
+}
+
+type timeTrackingService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+// NewTimeTrackingService creates a new TimeTrackingService
+func NewTimeTrackingService(db *gorm.DB, logger *logrus.Logger) TimeTrackingService {
+	return &timeTrackingService{db: db, logger: logger}
+}
+
+func (s *timeTrackingService) Stats(issue *models.Issue) *TimeStats {
+	return timeStats(issue)
+}
+
+func (s *timeTrackingService) SetEstimate(ctx context.Context, issue *models.Issue, duration string) (*TimeStats, error) {
+	seconds, err := ParseTimeDuration(duration)
+	if err != nil {
+		return nil, err
+	}
+	if seconds < 0 {
+		return nil, fmt.Errorf("%w: estimate cannot be negative", ErrInvalidTimeSpent)
+	}
+	return s.updateEstimate(ctx, issue, seconds)
+}
+
+func (s *timeTrackingService) ResetEstimate(ctx context.Context, issue *models.Issue) (*TimeStats, error) {
+	return s.updateEstimate(ctx, issue, 0)
+}
+
+func (s *timeTrackingService) updateEstimate(ctx context.Context, issue *models.Issue, seconds int64) (*TimeStats, error) {
+	if err := s.db.WithContext(ctx).Model(issue).Update("time_estimate", seconds).Error; err != nil {
+		return nil, fmt.Errorf("failed to update time estimate: %w", err)
+	}
+	issue.TimeEstimate = seconds
+	return timeStats(issue), nil
+}
+
+func (s *timeTrackingService) AddSpentTime(ctx context.Context, issue *models.Issue, userID uuid.UUID, req AddSpentTimeRequest) (*TimeStats, error) {
+	seconds, err := ParseTimeDuration(req.Duration)
+	if err != nil {
+		return nil, err
+	}
+	if seconds == 0 {
+		return nil, fmt.Errorf("%w: duration must not be zero", ErrInvalidTimeSpent)
+	}
+	if len(req.Summary) > 255 {
+		return nil, fmt.Errorf("%w: summary must be at most 255 characters", ErrInvalidTimeSpent)
+	}
+	spentAt := time.Now()
+	if req.SpentAt != nil {
+		spentAt = *req.SpentAt
+	}
+	return s.logTime(ctx, issue, userID, seconds, spentAt, req.Summary)
+}
+
+func (s *timeTrackingService) ResetSpentTime(ctx context.Context, issue *models.Issue, userID uuid.UUID) (*TimeStats, error) {
+	if issue.TotalTimeSpent == 0 {
+		return timeStats(issue), nil
+	}
+	return s.logTime(ctx, issue, userID, -issue.TotalTimeSpent, time.Now(), "Reset spent time")
+}
+
+// logTime records an entry and moves the issue's total in the same
+// transaction, rereading the total so concurrent entries are not lost
+func (s *timeTrackingService) logTime(ctx context.Context, issue *models.Issue, userID uuid.UUID, seconds int64, spentAt time.Time, summary string) (*TimeStats, error) {
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var total int64
+		if err := tx.Model(&models.TimeEntry{}).Where("issue_id = ?", issue.ID).
+			Select("COALESCE(SUM(seconds), 0)").Scan(&total).Error; err != nil {
+			return err
+		}
+		if total+seconds < 0 {
+			return fmt.Errorf("%w: spent time cannot be negative", ErrInvalidTimeSpent)
+		}
+
+		entry := &models.TimeEntry{ID: uuid.New(), IssueID: issue.ID, UserID: userID, Seconds: seconds, SpentAt: spentAt, Summary: summary}
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		issue.TotalTimeSpent = total + seconds
+		return tx.Model(issue).Update("total_time_spent", issue.TotalTimeSpent).Error
+	})
+	if errors.Is(err, ErrInvalidTimeSpent) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to log spent time: %w", err)
+	}
+	return timeStats(issue), nil
+}
+
+func (s *timeTrackingService) ListEntries(ctx context.Context, issue *models.Issue) ([]*models.TimeEntry, error) {
+	var entries []*models.TimeEntry
+	if err := s.db.WithContext(ctx).Preload("User").Where("issue_id = ?", issue.ID).
+		Order("spent_at ASC").Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to list time entries: %w", err)
+	}
+	return entries, nil
+}
+
+func (s *timeTrackingService) Report(ctx context.Context, filter TimeReportFilter) (*TimeReport, error) {
+	return buildTimeReport(ctx, s.db, filter)
+}
+
+// buildTimeReport is shared with the organization analytics rollup
+func buildTimeReport(ctx context.Context, db *gorm.DB, filter TimeReportFilter) (*TimeReport, error) {
+	db = db.WithContext(ctx)
+	scope := func(query *gorm.DB) *gorm.DB {
+		query = query.Where("issues.deleted_at IS NULL")
+		if filter.RepositoryID != nil {
+			query = query.Where("issues.repository_id = ?", *filter.RepositoryID)
+		}
+		if filter.OrganizationID != nil {
+			query = query.Where("issues.repository_id IN (?)", db.Model(&models.Repository{}).Select("id").
+				Where("owner_id = ? AND owner_type = ?", *filter.OrganizationID, models.OwnerTypeOrganization))
+		}
+		return query
+	}
+	entries := func() *gorm.DB {
+		query := scope(db.Table("issue_time_entries").Joins("JOIN issues ON issues.id = issue_time_entries.issue_id"))
+		if filter.Since != nil {
+			query = query.Where("issue_time_entries.spent_at >= ?", *filter.Since)
+		}
+		if filter.Until != nil {
+			query = query.Where("issue_time_entries.spent_at < ?", *filter.Until)
+		}
+		return query
+	}
+
+	report := &TimeReport{ByUser: []UserTimeSpent{}, ByMilestone: []MilestoneTimeSpent{}}
+	var totals struct {
+		Estimate int64
+		Tracked  int64
+	}
+	if err := scope(db.Table("issues")).
+		Select("COALESCE(SUM(time_estimate), 0) AS estimate, COUNT(CASE WHEN time_estimate > 0 OR total_time_spent > 0 THEN 1 END) AS tracked").
+		Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("failed to total time estimates: %w", err)
+	}
+	report.TotalTimeEstimate = totals.Estimate
+	report.TrackedIssues = totals.Tracked
+
+	if err := entries().Select("issue_time_entries.user_id AS user_id, SUM(issue_time_entries.seconds) AS time_spent").
+		Group("issue_time_entries.user_id").Scan(&report.ByUser).Error; err != nil {
+		return nil, fmt.Errorf("failed to report time by user: %w", err)
+	}
+	var userIDs []uuid.UUID
+	for _, row := range report.ByUser {
+		report.TotalTimeSpent += row.TimeSpent
+		userIDs = append(userIDs, row.UserID)
+	}
+	if len(userIDs) > 0 {
+		var users []models.User
+		if err := db.Select("id, username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			return nil, fmt.Errorf("failed to load users: %w", err)
+		}
+		names := make(map[uuid.UUID]string, len(users))
+		for _, user := range users {
+			names[user.ID] = user.Username
+		}
+		for i := range report.ByUser {
+			report.ByUser[i].Username = names[report.ByUser[i].UserID]
+		}
+	}
+	sort.Slice(report.ByUser, func(i, j int) bool {
+		return report.ByUser[i].TimeSpent > report.ByUser[j].TimeSpent
+	})
+
+	var spent []struct {
+		MilestoneID *uuid.UUID
+		TimeSpent   int64
+	}
+	if err := entries().Select("issues.milestone_id AS milestone_id, SUM(issue_time_entries.seconds) AS time_spent").
+		Group("issues.milestone_id").Scan(&spent).Error; err != nil {
+		return nil, fmt.Errorf("failed to report time by milestone: %w", err)
+	}
+	var estimates []struct {
+		MilestoneID  *uuid.UUID
+		TimeEstimate int64
+	}
+	if err := scope(db.Table("issues")).Select("milestone_id, SUM(time_estimate) AS time_estimate").
+		Where("time_estimate > 0").Group("milestone_id").Scan(&estimates).Error; err != nil {
+		return nil, fmt.Errorf("failed to report estimates by milestone: %w", err)
+	}
+
+	rows := map[uuid.UUID]*MilestoneTimeSpent{}
+	row := func(id *uuid.UUID) *MilestoneTimeSpent {
+		key := uuid.Nil
+		if id != nil {
+			key = *id
+		}
+		if rows[key] == nil {
+			rows[key] = &MilestoneTimeSpent{MilestoneID: id}
+		}
+		return rows[key]
+	}
+	for _, r := range spent {
+		row(r.MilestoneID).TimeSpent = r.TimeSpent
+	}
+	for _, r := range estimates {
+		row(r.MilestoneID).TimeEstimate = r.TimeEstimate
+	}
+
+	var milestoneIDs []uuid.UUID
+	for id := range rows {
+		if id != uuid.Nil {
+			milestoneIDs = append(milestoneIDs, id)
+		}
+	}
+	if len(milestoneIDs) > 0 {
+		var milestones []models.Milestone
+		if err := db.Unscoped().Where("id IN ?", milestoneIDs).Find(&milestones).Error; err != nil {
+			return nil, fmt.Errorf("failed to load milestones: %w", err)
+		}
+		for _, milestone := range milestones {
+			rows[milestone.ID].Title = milestone.Title
+		}
+	}
+	for _, r := range rows {
+		report.ByMilestone = append(report.ByMilestone, *r)
+	}
+	sort.Slice(report.ByMilestone, func(i, j int) bool {
+		a, b := report.ByMilestone[i], report.ByMilestone[j]
+		if (a.MilestoneID == nil) != (b.MilestoneID == nil) {
+			return b.MilestoneID == nil
+		}
+		return a.Title < b.Title
+	})
+	return report, nil
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeDuration(t *testing.T) {
+	cases := map[string]int64{
+		"30m":      1800,
+		"1h30m":    5400,
+		"1d 2h":    10 * 3600,
+		"1w":       40 * 3600,
+		"1mo":      160 * 3600,
+		"-45m":     -2700,
+		" 2h 15s ": 7215,
+	}
+	for input, want := range cases {
+		got, err := ParseTimeDuration(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, want, got, input)
+	}
+
+	for _, input := range []string{"", "-", "1x", "h", "1.5h", "1h foo"} {
+		_, err := ParseTimeDuration(input)
+		assert.ErrorIs(t, err, ErrInvalidTimeSpent, input)
+	}
+
+	assert.Equal(t, "0m", FormatTimeDuration(0))
+	assert.Equal(t, "1d 2h 30m", FormatTimeDuration(10*3600+1800))
+	assert.Equal(t, "-1h", FormatTimeDuration(-3600))
+}
+
+func TestTimeTrackingService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.Milestone{}, &models.Issue{}, &models.TimeEntry{}, &models.IssueRedirect{})
+
+	ctx := context.Background()
+	logger := logrus.New()
+	svc := NewTimeTrackingService(db, logger)
+	issues := NewIssueService(db, logger)
+	milestones := NewMilestoneService(db, logger)
+
+	orgID := uuid.New()
+	repo := &models.Repository{ID: uuid.New(), OwnerID: orgID, OwnerType: models.OwnerTypeOrganization, Name: "app", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	alice := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}
+	bob := &models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com"}
+	require.NoError(t, db.Create([]*models.User{alice, bob}).Error)
+
+	milestone, err := milestones.Create(ctx, repo.ID, CreateMilestoneRequest{Title: "v1.0"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, milestone.Number)
+
+	first, err := issues.Create(ctx, repo.ID, alice.ID, CreateIssueRequest{Title: "first"})
+	require.NoError(t, err)
+	second, err := issues.Create(ctx, repo.ID, alice.ID, CreateIssueRequest{Title: "second"})
+	require.NoError(t, err)
+	first, err = issues.Update(ctx, first, alice.ID, UpdateIssueRequest{Milestone: &milestone.Number})
+	require.NoError(t, err)
+	require.NotNil(t, first.MilestoneID)
+	missing := 9
+	_, err = issues.Update(ctx, second, alice.ID, UpdateIssueRequest{Milestone: &missing})
+	assert.ErrorIs(t, err, ErrInvalidIssue)
+
+	stats, err := svc.SetEstimate(ctx, first, "1d")
+	require.NoError(t, err)
+	assert.Equal(t, int64(8*3600), stats.TimeEstimate)
+	assert.Equal(t, "1d", stats.HumanTimeEstimate)
+	_, err = svc.SetEstimate(ctx, first, "-1h")
+	assert.ErrorIs(t, err, ErrInvalidTimeSpent)
+
+	spentAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	_, err = svc.AddSpentTime(ctx, first, alice.ID, AddSpentTimeRequest{Duration: "3h", SpentAt: &spentAt})
+	require.NoError(t, err)
+	stats, err = svc.AddSpentTime(ctx, first, bob.ID, AddSpentTimeRequest{Duration: "-1h", SpentAt: &spentAt})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2*3600), stats.TotalTimeSpent)
+	_, err = svc.AddSpentTime(ctx, first, bob.ID, AddSpentTimeRequest{Duration: "-5h"})
+	assert.ErrorIs(t, err, ErrInvalidTimeSpent)
+	_, err = svc.AddSpentTime(ctx, second, bob.ID, AddSpentTimeRequest{Duration: "30m", SpentAt: &spentAt})
+	require.NoError(t, err)
+
+	report, err := svc.Report(ctx, TimeReportFilter{OrganizationID: &orgID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(8*3600), report.TotalTimeEstimate)
+	assert.Equal(t, int64(2*3600+1800), report.TotalTimeSpent)
+	assert.Equal(t, int64(2), report.TrackedIssues)
+	require.Len(t, report.ByUser, 2)
+	assert.Equal(t, "alice", report.ByUser[0].Username)
+	assert.Equal(t, int64(3*3600), report.ByUser[0].TimeSpent)
+	assert.Equal(t, int64(-3600+1800), report.ByUser[1].TimeSpent)
+	require.Len(t, report.ByMilestone, 2)
+	assert.Equal(t, "v1.0", report.ByMilestone[0].Title)
+	assert.Equal(t, int64(8*3600), report.ByMilestone[0].TimeEstimate)
+	assert.Equal(t, int64(2*3600), report.ByMilestone[0].TimeSpent)
+	assert.Nil(t, report.ByMilestone[1].MilestoneID)
+	assert.Equal(t, int64(1800), report.ByMilestone[1].TimeSpent)
+
+	// Entries outside the window are left out
+	since := spentAt.Add(time.Hour)
+	report, err = svc.Report(ctx, TimeReportFilter{RepositoryID: &repo.ID, Since: &since})
+	require.NoError(t, err)
+	assert.Zero(t, report.TotalTimeSpent)
+
+	stats, err = svc.ResetSpentTime(ctx, first, alice.ID)
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalTimeSpent)
+	entries, err := svc.ListEntries(ctx, first)
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	other := uuid.New()
+	report, err = svc.Report(ctx, TimeReportFilter{OrganizationID: &other})
+	require.NoError(t, err)
+	assert.Zero(t, report.TotalTimeEstimate)
+	assert.Empty(t, report.ByUser)
+}
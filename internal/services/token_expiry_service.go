@@ -0,0 +1,120 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"sort"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/i18n"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// TokenExpiryService warns users by email before their personal access
+// tokens and fine-grained tokens expire
+type TokenExpiryService interface {
+	// SendExpiryNotifications emails every owner of active tokens expiring
+	// within the warning period that were not announced yet, and returns
+	// the number of emails sent
+	SendExpiryNotifications(ctx context.Context, now time.Time) (int, error)
+}
+
+type tokenExpiryService struct {
+	db      *gorm.DB
+	mailer  DigestMailer
+	bundle  *i18n.Bundle
+	logger  *logrus.Logger
+	warning time.Duration
+}
+
+// NewTokenExpiryService creates a new TokenExpiryService that warns the
+// given duration before a token expires
+func NewTokenExpiryService(db *gorm.DB, mailer DigestMailer, bundle *i18n.Bundle, logger *logrus.Logger, warning time.Duration) TokenExpiryService {
+	return &tokenExpiryService{db: db, mailer: mailer, bundle: bundle, logger: logger, warning: warning}
+}
+
+// expiringToken is a token listed in an expiry email
+type expiringToken struct {
+	ID          uuid.UUID
+	Name        string
+	FineGrained bool
+	ExpiresAt   time.Time
+}
+
+func (s *tokenExpiryService) SendExpiryNotifications(ctx context.Context, now time.Time) (int, error) {
+	if s.mailer == nil {
+		return 0, nil
+	}
+	db := s.db.WithContext(ctx)
+	deadline := now.Add(s.warning)
+
+	var expiring []models.FineGrainedToken
+	if err := db.Where("status = ? AND expiry_notified_at IS NULL AND expires_at > ? AND expires_at <= ?", models.FineGrainedTokenActive, now, deadline).
+		Find(&expiring).Error; err != nil {
+		return 0, fmt.Errorf("failed to list expiring tokens: %w", err)
+	}
+
+	byUser := map[uuid.UUID][]expiringToken{}
+	for _, t := range expiring {
+		byUser[t.UserID] = append(byUser[t.UserID], expiringToken{ID: t.ID, Name: t.Name, FineGrained: !t.IsScoped(), ExpiresAt: *t.ExpiresAt})
+	}
+
+	sent := 0
+	for userID, tokens := range byUser {
+		var user models.User
+		if err := db.First(&user, "id = ?", userID).Error; err != nil || !user.IsActive || user.Email == "" {
+			continue
+		}
+		sort.Slice(tokens, func(i, j int) bool { return tokens[i].ExpiresAt.Before(tokens[j].ExpiresAt) })
+
+		localizer := s.bundle.Localizer(user.Locale)
+		body, err := renderTokenExpiryHTML(tokens, localizer)
+		if err != nil {
+			return sent, err
+		}
+		if err := s.mailer.SendDigestEmail(user.Email, localizer.N("token_expiry.subject", len(tokens)), body); err != nil {
+			s.logger.WithError(err).WithField("user_id", userID).Warn("Failed to send token expiry notification")
+			continue
+		}
+		sent++
+
+		ids := make([]uuid.UUID, 0, len(tokens))
+		for _, t := range tokens {
+			ids = append(ids, t.ID)
+		}
+		if err := db.Model(&models.FineGrainedToken{}).Where("id IN ?", ids).UpdateColumn("expiry_notified_at", now).Error; err != nil {
+			return sent, fmt.Errorf("failed to record token expiry notification: %w", err)
+		}
+	}
+	return sent, nil
+}
+
+var tokenExpiryTemplate = template.Must(template.New("token_expiry").Funcs(template.FuncMap{
+	"t": func(string, ...interface{}) string { return "" },
+}).Parse(`<html>
+<body>
+	<h2>{{t "token_expiry.heading"}}</h2>
+	<ul>{{range .}}<li><strong>{{.Name}}</strong>{{if .FineGrained}} ({{t "token_expiry.fine_grained"}}){{end}}: {{t "token_expiry.expires_on" "date" (.ExpiresAt.Format "2006-01-02")}}</li>{{end}}</ul>
+	<p style="font-size: 12px; color: #666;">{{t "token_expiry.footer"}}</p>
+</body>
+</html>`))
+
+// renderTokenExpiryHTML renders the tokens of one expiry email
+func renderTokenExpiryHTML(tokens []expiringToken, localizer *i18n.Localizer) (string, error) {
+	tmpl, err := tokenExpiryTemplate.Clone()
+	if err != nil {
+		return "", fmt.Errorf("failed to render token expiry notification: %w", err)
+	}
+	tmpl.Funcs(template.FuncMap{"t": localizer.T})
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, tokens); err != nil {
+		return "", fmt.Errorf("failed to render token expiry notification: %w", err)
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/i18n"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenExpiryService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.FineGrainedToken{})
+	ctx := context.Background()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	mailer := &recordingDigestMailer{sent: map[string]string{}}
+	svc := NewTokenExpiryService(db, mailer, i18n.MustNewBundle(), logrus.New(), 7*24*time.Hour)
+
+	alice := &models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com", IsActive: true}
+	bob := &models.User{ID: uuid.New(), Username: "bob", Email: "bob@example.com", IsActive: true, Locale: "es"}
+	require.NoError(t, db.Create([]*models.User{alice, bob}).Error)
+
+	in := func(d time.Duration) *time.Time {
+		at := now.Add(d)
+		return &at
+	}
+	day := 24 * time.Hour
+	scopes := []string{models.PATScopeRepoRead}
+	active, revoked := models.FineGrainedTokenActive, models.FineGrainedTokenRevoked
+	require.NoError(t, db.Create([]*models.FineGrainedToken{
+		{ID: uuid.New(), UserID: alice.ID, Name: "deploy", TokenHash: "h1", Scopes: scopes, Status: active, ExpiresAt: in(3 * day)},
+		{ID: uuid.New(), UserID: alice.ID, Name: "later", TokenHash: "h2", Scopes: scopes, Status: active, ExpiresAt: in(30 * day)},
+		{ID: uuid.New(), UserID: alice.ID, Name: "expired", TokenHash: "h3", Scopes: scopes, Status: active, ExpiresAt: in(-day)},
+		{ID: uuid.New(), UserID: alice.ID, Name: "revoked", TokenHash: "h4", Scopes: scopes, Status: revoked, ExpiresAt: in(day)},
+		{ID: uuid.New(), UserID: bob.ID, Name: "ci", TokenHash: "h5", Scopes: scopes, Status: active, ExpiresAt: in(6 * day)},
+		{ID: uuid.New(), UserID: alice.ID, Name: "release", TokenHash: "f1", Status: active, ExpiresAt: in(2 * day)},
+		{ID: uuid.New(), UserID: bob.ID, Name: "pending", TokenHash: "f2", Status: models.FineGrainedTokenPendingApproval, ExpiresAt: in(2 * day)},
+	}).Error)
+
+	sent, err := svc.SendExpiryNotifications(ctx, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, map[string]string{
+		"alice@example.com": "2 of your access tokens expire soon",
+		"bob@example.com":   "1 de tus tokens de acceso caduca pronto",
+	}, mailer.sent)
+
+	// Each token is announced once
+	mailer.sent = map[string]string{}
+	sent, err = svc.SendExpiryNotifications(ctx, now.Add(day))
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+
+	var notified int64
+	require.NoError(t, db.Model(&models.FineGrainedToken{}).Where("expiry_notified_at IS NOT NULL").Count(&notified).Error)
+	assert.Equal(t, int64(3), notified)
+}
+
+func TestRenderTokenExpiryHTML(t *testing.T) {
+	expires := time.Date(2024, 6, 4, 0, 0, 0, 0, time.UTC)
+	body, err := renderTokenExpiryHTML([]expiringToken{
+		{Name: "deploy <prod>", ExpiresAt: expires},
+		{Name: "release", FineGrained: true, ExpiresAt: expires},
+	}, i18n.MustNewBundle().Localizer("en"))
+	require.NoError(t, err)
+	assert.Contains(t, body, "Access tokens expiring soon")
+	assert.Contains(t, body, "deploy &lt;prod&gt;")
+	assert.Contains(t, body, "release</strong> (fine-grained): expires on 2024-06-04")
+}
@@ -0,0 +1,60 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrTwoFactorRequired is returned for members without MFA in organizations
+// that require it
+var ErrTwoFactorRequired = errors.New("organization requires two-factor authentication")
+
+// TwoFactorPolicyService enforces the require_two_factor setting of
+// organizations
+type TwoFactorPolicyService interface {
+	// Authorize returns ErrTwoFactorRequired when the organization requires
+	// MFA and userID is a member who has not enabled it
+	Authorize(ctx context.Context, orgID, userID uuid.UUID) error
+}
+
+type twoFactorPolicyService struct {
+	db *gorm.DB
+}
+
+// NewTwoFactorPolicyService creates a new TwoFactorPolicyService
+func NewTwoFactorPolicyService(db *gorm.DB) TwoFactorPolicyService {
+	return &twoFactorPolicyService{db: db}
+}
+
+func (s *twoFactorPolicyService) Authorize(ctx context.Context, orgID, userID uuid.UUID) error {
+	var settings models.OrganizationSettings
+	err := s.db.WithContext(ctx).Select("require_two_factor").Where("organization_id = ?", orgID).First(&settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !settings.RequireTwoFactor {
+		return nil
+	}
+	var members int64
+	if err := s.db.WithContext(ctx).Model(&models.OrganizationMember{}).Where("organization_id = ? AND user_id = ?", orgID, userID).Count(&members).Error; err != nil {
+		return err
+	}
+	if members == 0 {
+		return nil
+	}
+	var enabled int64
+	if err := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ? AND two_factor_enabled = ?", userID, true).Count(&enabled).Error; err != nil {
+		return err
+	}
+	if enabled == 0 {
+		return ErrTwoFactorRequired
+	}
+	return nil
+}
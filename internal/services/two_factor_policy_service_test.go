@@ -0,0 +1,40 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTwoFactorPolicyService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.OrganizationMember{}, &models.OrganizationSettings{})
+	ctx := context.Background()
+	svc := NewTwoFactorPolicyService(db)
+	orgID := uuid.New()
+
+	member := &models.User{ID: uuid.New(), Username: "member", Email: "member@acme.com", IsActive: true}
+	enrolled := &models.User{ID: uuid.New(), Username: "enrolled", Email: "enrolled@acme.com", IsActive: true, TwoFactorEnabled: true}
+	outsider := &models.User{ID: uuid.New(), Username: "outsider", Email: "outsider@example.com", IsActive: true}
+	for _, user := range []*models.User{member, enrolled, outsider} {
+		require.NoError(t, db.Create(user).Error)
+	}
+	for _, user := range []*models.User{member, enrolled} {
+		require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: orgID, UserID: user.ID, Role: models.OrgRoleMember}).Error)
+	}
+
+	// Organizations without settings or the flag do not require MFA
+	assert.NoError(t, svc.Authorize(ctx, orgID, member.ID))
+	settings := &models.OrganizationSettings{ID: uuid.New(), OrganizationID: orgID}
+	require.NoError(t, db.Create(settings).Error)
+	assert.NoError(t, svc.Authorize(ctx, orgID, member.ID))
+
+	require.NoError(t, db.Model(settings).Update("require_two_factor", true).Error)
+	assert.ErrorIs(t, svc.Authorize(ctx, orgID, member.ID), ErrTwoFactorRequired)
+	assert.NoError(t, svc.Authorize(ctx, orgID, enrolled.ID))
+	assert.NoError(t, svc.Authorize(ctx, orgID, outsider.ID), "outside collaborators are not covered")
+}
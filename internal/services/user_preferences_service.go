@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// UpdateUserPreferencesRequest changes the preferences that are set
+type UpdateUserPreferencesRequest struct {
+	Timezone       *string                 `json:"timezone,omitempty"`
+	DateFormat     *models.DateFormat      `json:"date_format,omitempty"`
+	DiffView       *models.DiffView        `json:"diff_view,omitempty"`
+	EditorTabSize  *int                    `json:"editor_tab_size,omitempty"`
+	EmailFrequency *models.DigestFrequency `json:"email_frequency,omitempty"`
+	// NotificationEmailFrequency is daily, weekly or off
+	NotificationEmailFrequency *models.DigestFrequency `json:"notification_email_frequency,omitempty"`
+}
+
+// UserPreferencesService manages per-user display and notification preferences
+type UserPreferencesService interface {
+	Get(ctx context.Context, userID uuid.UUID) (*models.UserPreferences, error)
+	Update(ctx context.Context, userID uuid.UUID, req UpdateUserPreferencesRequest) (*models.UserPreferences, error)
+	// Location returns the user's timezone, or UTC when it cannot be loaded
+	Location(ctx context.Context, userID uuid.UUID) *time.Location
+}
+
+var ErrInvalidPreference = errors.New("invalid preference")
+
+type userPreferencesService struct {
+	db     *gorm.DB
+	logger *logrus.Logger
+}
+
+// NewUserPreferencesService creates a new UserPreferencesService
+func NewUserPreferencesService(db *gorm.DB, logger *logrus.Logger) UserPreferencesService {
+	return &userPreferencesService{db: db, logger: logger}
+}
+
+// DefaultUserPreferences returns the preferences of a user who has not
+// changed any
+func DefaultUserPreferences(userID uuid.UUID) *models.UserPreferences {
+	return &models.UserPreferences{
+		UserID:         userID,
+		Timezone:       "UTC",
+		DateFormat:     models.DateFormatISO,
+		DiffView:       models.DiffViewUnified,
+		EditorTabSize:  4,
+		EmailFrequency: models.DigestFrequencyDefault,
+		// Unread notifications are emailed once a day unless turned off
+		NotificationEmailFrequency: models.DigestFrequencyDaily,
+	}
+}
+
+func (s *userPreferencesService) Get(ctx context.Context, userID uuid.UUID) (*models.UserPreferences, error) {
+	prefs := DefaultUserPreferences(userID)
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(prefs).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to get user preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+func (s *userPreferencesService) Update(ctx context.Context, userID uuid.UUID, req UpdateUserPreferencesRequest) (*models.UserPreferences, error) {
+	prefs, err := s.Get(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Timezone != nil {
+		if _, err := time.LoadLocation(*req.Timezone); err != nil || *req.Timezone == "" || *req.Timezone == "Local" {
+			return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidPreference, *req.Timezone)
+		}
+		prefs.Timezone = *req.Timezone
+	}
+	if req.DateFormat != nil {
+		switch *req.DateFormat {
+		case models.DateFormatISO, models.DateFormatUS, models.DateFormatEU, models.DateFormatLong:
+			prefs.DateFormat = *req.DateFormat
+		default:
+			return nil, fmt.Errorf("%w: date_format must be iso, us, eu or long", ErrInvalidPreference)
+		}
+	}
+	if req.DiffView != nil {
+		if *req.DiffView != models.DiffViewUnified && *req.DiffView != models.DiffViewSplit {
+			return nil, fmt.Errorf("%w: diff_view must be unified or split", ErrInvalidPreference)
+		}
+		prefs.DiffView = *req.DiffView
+	}
+	if req.EditorTabSize != nil {
+		if *req.EditorTabSize < 1 || *req.EditorTabSize > 16 {
+			return nil, fmt.Errorf("%w: editor_tab_size must be between 1 and 16", ErrInvalidPreference)
+		}
+		prefs.EditorTabSize = *req.EditorTabSize
+	}
+	if req.EmailFrequency != nil {
+		if !validDigestFrequency(*req.EmailFrequency, true) {
+			return nil, fmt.Errorf("%w: email_frequency must be default, daily, weekly or off", ErrInvalidPreference)
+		}
+		prefs.EmailFrequency = *req.EmailFrequency
+	}
+	if req.NotificationEmailFrequency != nil {
+		if !validDigestFrequency(*req.NotificationEmailFrequency, false) {
+			return nil, fmt.Errorf("%w: notification_email_frequency must be daily, weekly or off", ErrInvalidPreference)
+		}
+		prefs.NotificationEmailFrequency = *req.NotificationEmailFrequency
+	}
+
+	if prefs.ID == uuid.Nil {
+		prefs.ID = uuid.New()
+		err = s.db.WithContext(ctx).Create(prefs).Error
+	} else {
+		err = s.db.WithContext(ctx).Save(prefs).Error
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to save user preferences: %w", err)
+	}
+	return prefs, nil
+}
+
+func (s *userPreferencesService) Location(ctx context.Context, userID uuid.UUID) *time.Location {
+	prefs, err := s.Get(ctx, userID)
+	if err != nil {
+		s.logger.WithError(err).WithField("user_id", userID).Warn("Failed to load timezone preference")
+		return time.UTC
+	}
+	return prefs.Location()
+}
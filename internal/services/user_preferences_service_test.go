@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserPreferencesService(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.UserPreferences{})
+
+	svc := NewUserPreferencesService(db, logrus.New())
+	ctx := context.Background()
+	userID := uuid.New()
+
+	prefs, err := svc.Get(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, "UTC", prefs.Timezone)
+	assert.Equal(t, models.DigestFrequencyDefault, prefs.EmailFrequency)
+	assert.Equal(t, time.UTC, svc.Location(ctx, userID))
+
+	tz, format, frequency := "Europe/Berlin", models.DateFormatLong, models.DigestFrequencyDaily
+	prefs, err = svc.Update(ctx, userID, UpdateUserPreferencesRequest{Timezone: &tz, DateFormat: &format, EmailFrequency: &frequency})
+	require.NoError(t, err)
+	assert.Equal(t, "Jan 2, 2006", prefs.DateFormat.Layout())
+	assert.Equal(t, "Europe/Berlin", svc.Location(ctx, userID).String())
+
+	split := models.DiffViewSplit
+	_, err = svc.Update(ctx, userID, UpdateUserPreferencesRequest{DiffView: &split})
+	require.NoError(t, err)
+	prefs, err = svc.Get(ctx, userID)
+	require.NoError(t, err)
+	assert.Equal(t, models.DiffViewSplit, prefs.DiffView)
+	assert.Equal(t, models.DigestFrequencyDaily, prefs.EmailFrequency)
+
+	bad := "Mars/Olympus_Mons"
+	_, err = svc.Update(ctx, userID, UpdateUserPreferencesRequest{Timezone: &bad})
+	assert.True(t, errors.Is(err, ErrInvalidPreference))
+	tabs := 0
+	_, err = svc.Update(ctx, userID, UpdateUserPreferencesRequest{EditorTabSize: &tabs})
+	assert.True(t, errors.Is(err, ErrInvalidPreference))
+}
@@ -0,0 +1,367 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// watchDigestInitialWindow is how far back a digest without a cursor looks
+	watchDigestInitialWindow = 24 * time.Hour
+	// watchDigestMaxWindow bounds how far back an old cursor is honored
+	watchDigestMaxWindow = 30 * 24 * time.Hour
+	// watchDigestMaxReleases caps the release names listed per repository
+	watchDigestMaxReleases = 20
+)
+
+var (
+	ErrWatchNotFound     = errors.New("repository is not watched")
+	ErrInvalidCursor     = errors.New("invalid digest cursor")
+	ErrInvalidWatchEvent = errors.New("invalid watch event")
+)
+
+// WatchDigest summarizes what changed in a user's watched repositories
+// between two polls. NextCursor is passed back on the next poll.
+type WatchDigest struct {
+	Since        time.Time          `json:"since"`
+	Until        time.Time          `json:"until"`
+	NextCursor   string             `json:"next_cursor"`
+	Truncated    bool               `json:"truncated"`
+	HasChanges   bool               `json:"has_changes"`
+	Repositories []RepositoryDigest `json:"repositories"`
+}
+
+// RepositoryDigest is the change summary of one watched repository
+type RepositoryDigest struct {
+	RepositoryID    uuid.UUID `json:"repository_id"`
+	FullName        string    `json:"full_name"`
+	NewCommits      int64     `json:"new_commits"`
+	NewIssues       int64     `json:"new_issues"`
+	NewPullRequests int64     `json:"new_pull_requests"`
+	Releases        []string  `json:"releases"`
+}
+
+// WatchService persists repository watches and builds the polling digest
+// of watched repositories
+type WatchService interface {
+	Get(ctx context.Context, userID, repoID uuid.UUID) (*models.RepositoryWatch, error)
+	// Watch subscribes to a repository, or ignores it when ignored is set
+	Watch(ctx context.Context, userID, repoID uuid.UUID, ignored bool, reason string) (*models.RepositoryWatch, error)
+	Unwatch(ctx context.Context, userID, repoID uuid.UUID) error
+	// SetEvents watches a repository for the listed events only; no events
+	// restores the default of issues and pull requests
+	SetEvents(ctx context.Context, userID, repoID uuid.UUID, events []string) (*models.RepositoryWatch, error)
+	// Digest summarizes changes since cursor; an empty cursor starts a new
+	// poll sequence covering the last day
+	Digest(ctx context.Context, userID uuid.UUID, cursor string, now time.Time) (*WatchDigest, error)
+}
+
+type watchService struct {
+	db          *gorm.DB
+	permissions PermissionService
+	logger      *logrus.Logger
+}
+
+// NewWatchService creates a new WatchService
+func NewWatchService(db *gorm.DB, permissions PermissionService, logger *logrus.Logger) WatchService {
+	return &watchService{db: db, permissions: permissions, logger: logger}
+}
+
+// EncodeWatchCursor returns the opaque cursor for a poll made at t
+func EncodeWatchCursor(t time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("v1:" + strconv.FormatInt(t.UnixNano(), 10)))
+}
+
+// DecodeWatchCursor returns the time a cursor was issued at
+func DecodeWatchCursor(cursor string) (time.Time, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), "v1:") {
+		return time.Time{}, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(strings.TrimPrefix(string(raw), "v1:"), 10, 64)
+	if err != nil {
+		return time.Time{}, ErrInvalidCursor
+	}
+	return time.Unix(0, nanos).UTC(), nil
+}
+
+func (s *watchService) Get(ctx context.Context, userID, repoID uuid.UUID) (*models.RepositoryWatch, error) {
+	var watch models.RepositoryWatch
+	err := s.db.WithContext(ctx).Where("user_id = ? AND repository_id = ?", userID, repoID).First(&watch).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrWatchNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get repository watch: %w", err)
+	}
+	return &watch, nil
+}
+
+func (s *watchService) Watch(ctx context.Context, userID, repoID uuid.UUID, ignored bool, reason string) (*models.RepositoryWatch, error) {
+	if reason == "" {
+		reason = "subscribed"
+		if ignored {
+			reason = "ignored"
+		}
+	}
+
+	var watch *models.RepositoryWatch
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.RepositoryWatch
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND repository_id = ?", userID, repoID).First(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			watch = &models.RepositoryWatch{ID: uuid.New(), UserID: userID, RepositoryID: repoID, Ignored: ignored, Reason: reason}
+			if err := tx.Create(watch).Error; err != nil {
+				return err
+			}
+			if !ignored {
+				return adjustWatchers(tx, repoID, 1)
+			}
+			return nil
+		case err != nil:
+			return err
+		}
+
+		delta := 0
+		if existing.Ignored && !ignored {
+			delta = 1
+		} else if !existing.Ignored && ignored {
+			delta = -1
+		}
+		existing.Ignored = ignored
+		existing.Reason = reason
+		if err := tx.Save(&existing).Error; err != nil {
+			return err
+		}
+		watch = &existing
+		return adjustWatchers(tx, repoID, delta)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to watch repository: %w", err)
+	}
+	return watch, nil
+}
+
+func (s *watchService) Unwatch(ctx context.Context, userID, repoID uuid.UUID) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var watch models.RepositoryWatch
+		err := tx.Where("user_id = ? AND repository_id = ?", userID, repoID).First(&watch).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get repository watch: %w", err)
+		}
+		if err := tx.Delete(&watch).Error; err != nil {
+			return fmt.Errorf("failed to unwatch repository: %w", err)
+		}
+		if !watch.Ignored {
+			return adjustWatchers(tx, repoID, -1)
+		}
+		return nil
+	})
+}
+
+func (s *watchService) SetEvents(ctx context.Context, userID, repoID uuid.UUID, events []string) (*models.RepositoryWatch, error) {
+	selected := make([]string, 0, len(events))
+	for _, event := range events {
+		switch event {
+		case models.WatchEventIssues, models.WatchEventPullRequests, models.WatchEventCI:
+		default:
+			return nil, fmt.Errorf("%w: %q, expected issues, pull_requests or ci", ErrInvalidWatchEvent, event)
+		}
+		if !slices.Contains(selected, event) {
+			selected = append(selected, event)
+		}
+	}
+	sort.Strings(selected)
+
+	reason := ""
+	if len(selected) > 0 {
+		reason = "custom"
+	}
+	watch, err := s.Watch(ctx, userID, repoID, false, reason)
+	if err != nil {
+		return nil, err
+	}
+	watch.Events = strings.Join(selected, ",")
+	if err := s.db.WithContext(ctx).Model(watch).Update("events", watch.Events).Error; err != nil {
+		return nil, fmt.Errorf("failed to update watched events: %w", err)
+	}
+	return watch, nil
+}
+
+func adjustWatchers(tx *gorm.DB, repoID uuid.UUID, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	return tx.Model(&models.Repository{}).Where("id = ?", repoID).
+		Update("watchers_count", gorm.Expr("CASE WHEN watchers_count + ? < 0 THEN 0 ELSE watchers_count + ? END", delta, delta)).Error
+}
+
+func (s *watchService) Digest(ctx context.Context, userID uuid.UUID, cursor string, now time.Time) (*WatchDigest, error) {
+	until := now.UTC()
+	since := until.Add(-watchDigestInitialWindow)
+	digest := &WatchDigest{Until: until, NextCursor: EncodeWatchCursor(until), Repositories: []RepositoryDigest{}}
+	if cursor != "" {
+		var err error
+		if since, err = DecodeWatchCursor(cursor); err != nil {
+			return nil, err
+		}
+		if oldest := until.Add(-watchDigestMaxWindow); since.Before(oldest) {
+			since = oldest
+			digest.Truncated = true
+		}
+	}
+	digest.Since = since
+
+	repos, err := s.watchedRepositories(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(repos) == 0 {
+		return digest, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(repos))
+	for _, repo := range repos {
+		ids = append(ids, repo.ID)
+	}
+	db := s.db.WithContext(ctx)
+	window := "repository_id IN ? AND created_at > ? AND created_at <= ?"
+
+	counts := func(model interface{}) (map[uuid.UUID]int64, error) {
+		var rows []struct {
+			RepositoryID uuid.UUID
+			Count        int64
+		}
+		err := db.Model(model).Select("repository_id, COUNT(*) AS count").
+			Where(window, ids, since, until).Group("repository_id").Scan(&rows).Error
+		result := make(map[uuid.UUID]int64, len(rows))
+		for _, row := range rows {
+			result[row.RepositoryID] = row.Count
+		}
+		return result, err
+	}
+	commits, err := counts(&models.Commit{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count new commits: %w", err)
+	}
+	issues, err := counts(&models.Issue{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count new issues: %w", err)
+	}
+	pulls, err := counts(&models.PullRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to count new pull requests: %w", err)
+	}
+
+	var tags []models.Tag
+	if err := db.Where(window, ids, since, until).Order("created_at ASC").Find(&tags).Error; err != nil {
+		return nil, fmt.Errorf("failed to load releases: %w", err)
+	}
+	releases := map[uuid.UUID][]string{}
+	for _, tag := range tags {
+		if len(releases[tag.RepositoryID]) < watchDigestMaxReleases {
+			releases[tag.RepositoryID] = append(releases[tag.RepositoryID], tag.Name)
+		}
+	}
+
+	owners := ownerNames(ctx, s.db, s.logger, repos)
+	for _, repo := range repos {
+		entry := RepositoryDigest{
+			RepositoryID:    repo.ID,
+			FullName:        owners[repo.OwnerID] + "/" + repo.Name,
+			NewCommits:      commits[repo.ID],
+			NewIssues:       issues[repo.ID],
+			NewPullRequests: pulls[repo.ID],
+			Releases:        releases[repo.ID],
+		}
+		if entry.NewCommits == 0 && entry.NewIssues == 0 && entry.NewPullRequests == 0 && len(entry.Releases) == 0 {
+			continue
+		}
+		if entry.Releases == nil {
+			entry.Releases = []string{}
+		}
+		digest.Repositories = append(digest.Repositories, entry)
+	}
+	sort.Slice(digest.Repositories, func(i, j int) bool {
+		return digest.Repositories[i].FullName < digest.Repositories[j].FullName
+	})
+	digest.HasChanges = len(digest.Repositories) > 0
+	return digest, nil
+}
+
+// watchedRepositories returns the watched, non-ignored repositories the user
+// can still read; access may have been revoked since the watch was made
+func (s *watchService) watchedRepositories(ctx context.Context, userID uuid.UUID) ([]*models.Repository, error) {
+	var repos []*models.Repository
+	err := s.db.WithContext(ctx).
+		Joins("JOIN repository_watches ON repository_watches.repository_id = repositories.id").
+		Where("repository_watches.user_id = ? AND repository_watches.ignored = ?", userID, false).
+		Find(&repos).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list watched repositories: %w", err)
+	}
+
+	readable := repos[:0]
+	for _, repo := range repos {
+		if repo.Visibility != models.VisibilityPublic && s.permissions != nil {
+			ok, err := s.permissions.CheckRepositoryPermission(ctx, userID, repo.ID, models.PermissionRead)
+			if err != nil || !ok {
+				continue
+			}
+		}
+		readable = append(readable, repo)
+	}
+	return readable, nil
+}
+
+// ownerNames maps the owners of repos to their user or organization names;
+// owners that cannot be resolved are left out
+func ownerNames(ctx context.Context, db *gorm.DB, logger *logrus.Logger, repos []*models.Repository) map[uuid.UUID]string {
+	var userIDs, orgIDs []uuid.UUID
+	for _, repo := range repos {
+		if repo.OwnerType == models.OwnerTypeOrganization {
+			orgIDs = append(orgIDs, repo.OwnerID)
+		} else {
+			userIDs = append(userIDs, repo.OwnerID)
+		}
+	}
+
+	names := make(map[uuid.UUID]string)
+	if len(userIDs) > 0 {
+		var users []models.User
+		if err := db.WithContext(ctx).Select("id", "username").Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			logger.WithError(err).Warn("Failed to resolve repository owners")
+		}
+		for _, user := range users {
+			names[user.ID] = user.Username
+		}
+	}
+	if len(orgIDs) > 0 {
+		var orgs []models.Organization
+		if err := db.WithContext(ctx).Select("id", "name").Where("id IN ?", orgIDs).Find(&orgs).Error; err != nil {
+			logger.WithError(err).Warn("Failed to resolve repository owners")
+		}
+		for _, org := range orgs {
+			names[org.ID] = org.Name
+		}
+	}
+	return names
+}
@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchService_WatchAndUnwatch(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.RepositoryWatch{})
+
+	ctx := context.Background()
+	svc := NewWatchService(db, nil, logrus.New())
+	userID := uuid.New()
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "app", Visibility: models.VisibilityPublic}
+	require.NoError(t, db.Create(repo).Error)
+
+	_, err := svc.Get(ctx, userID, repo.ID)
+	assert.ErrorIs(t, err, ErrWatchNotFound)
+
+	watch, err := svc.Watch(ctx, userID, repo.ID, false, "")
+	require.NoError(t, err)
+	assert.Equal(t, "subscribed", watch.Reason)
+	_, err = svc.Watch(ctx, userID, repo.ID, false, "")
+	require.NoError(t, err)
+	require.NoError(t, db.First(repo, "id = ?", repo.ID).Error)
+	assert.Equal(t, 1, repo.WatchersCount)
+
+	// Ignoring a repository no longer counts as watching it
+	watch, err = svc.Watch(ctx, userID, repo.ID, true, "")
+	require.NoError(t, err)
+	assert.True(t, watch.Ignored)
+	require.NoError(t, db.First(repo, "id = ?", repo.ID).Error)
+	assert.Equal(t, 0, repo.WatchersCount)
+
+	require.NoError(t, svc.Unwatch(ctx, userID, repo.ID))
+	require.NoError(t, svc.Unwatch(ctx, userID, repo.ID))
+	_, err = svc.Get(ctx, userID, repo.ID)
+	assert.ErrorIs(t, err, ErrWatchNotFound)
+}
+
+func TestWatchService_Digest(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.RepositoryWatch{},
+		&models.Commit{}, &models.Tag{}, &models.Issue{}, &models.PullRequest{})
+
+	ctx := context.Background()
+	svc := NewWatchService(db, nil, logrus.New())
+	owner := &models.User{ID: uuid.New(), Username: "octo", Email: "octo@example.com"}
+	require.NoError(t, db.Create(owner).Error)
+	watched := &models.Repository{ID: uuid.New(), OwnerID: owner.ID, OwnerType: models.OwnerTypeUser, Name: "app", Visibility: models.VisibilityPublic}
+	quiet := &models.Repository{ID: uuid.New(), OwnerID: owner.ID, OwnerType: models.OwnerTypeUser, Name: "docs", Visibility: models.VisibilityPublic}
+	other := &models.Repository{ID: uuid.New(), OwnerID: owner.ID, OwnerType: models.OwnerTypeUser, Name: "other", Visibility: models.VisibilityPublic}
+	require.NoError(t, db.Create([]*models.Repository{watched, quiet, other}).Error)
+
+	userID := uuid.New()
+	_, err := svc.Watch(ctx, userID, watched.ID, false, "")
+	require.NoError(t, err)
+	_, err = svc.Watch(ctx, userID, quiet.ID, false, "")
+	require.NoError(t, err)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	first, err := svc.Digest(ctx, userID, "", now)
+	require.NoError(t, err)
+	assert.False(t, first.HasChanges)
+	assert.Empty(t, first.Repositories)
+
+	at := now.Add(time.Hour)
+	require.NoError(t, db.Create(&models.Commit{ID: uuid.New(), RepositoryID: watched.ID, SHA: "a1", CreatedAt: at}).Error)
+	require.NoError(t, db.Create(&models.Commit{ID: uuid.New(), RepositoryID: watched.ID, SHA: "a2", CreatedAt: at}).Error)
+	require.NoError(t, db.Create(&models.Commit{ID: uuid.New(), RepositoryID: other.ID, SHA: "b1", CreatedAt: at}).Error)
+	require.NoError(t, db.Create(&models.Issue{ID: uuid.New(), RepositoryID: watched.ID, Number: 1, Title: "bug", State: models.IssueStateOpen, CreatedAt: at}).Error)
+	require.NoError(t, db.Create(&models.Tag{ID: uuid.New(), RepositoryID: watched.ID, Name: "v1.0.0", CreatedAt: at}).Error)
+
+	second, err := svc.Digest(ctx, userID, first.NextCursor, now.Add(2*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, second.HasChanges)
+	assert.Equal(t, now, second.Since)
+	require.Len(t, second.Repositories, 1)
+	entry := second.Repositories[0]
+	assert.Equal(t, "octo/app", entry.FullName)
+	assert.Equal(t, int64(2), entry.NewCommits)
+	assert.Equal(t, int64(1), entry.NewIssues)
+	assert.Equal(t, int64(0), entry.NewPullRequests)
+	assert.Equal(t, []string{"v1.0.0"}, entry.Releases)
+
+	// Changes are reported once per cursor
+	third, err := svc.Digest(ctx, userID, second.NextCursor, now.Add(3*time.Hour))
+	require.NoError(t, err)
+	assert.False(t, third.HasChanges)
+
+	stale, err := svc.Digest(ctx, userID, EncodeWatchCursor(now.Add(-90*24*time.Hour)), now)
+	require.NoError(t, err)
+	assert.True(t, stale.Truncated)
+
+	_, err = svc.Digest(ctx, userID, "not-a-cursor", now)
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
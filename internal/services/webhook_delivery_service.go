@@ -7,6 +7,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
@@ -14,6 +15,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/encryption"
+	"github.com/a5c-ai/hub/internal/errorreporting"
+	"github.com/a5c-ai/hub/internal/jobs"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
@@ -25,8 +30,27 @@ type WebhookDeliveryService struct {
 	db     *gorm.DB
 	logger *logrus.Logger
 	client *http.Client
+
+	// Failure alerts are off until EnableFailureAlerts is called
+	notifications NotificationService
+	alerts        config.IntegrationAlerts
+
+	// queue delivers triggered events; nil delivers them in goroutines
+	queue *jobs.Queue
 }
 
+// JobDeliverWebhook is the queued job kind that delivers one event to one
+// webhook
+const JobDeliverWebhook = "webhook.deliver"
+
+const (
+	// maxDeliveryAttempts bounds the attempts made for a delivery, retries
+	// included
+	maxDeliveryAttempts = 5
+	// maxResponseBodyBytes is how much of a receiver's response is stored
+	maxResponseBodyBytes = 64 * 1024
+)
+
 // NewWebhookDeliveryService creates a new webhook delivery service
 func NewWebhookDeliveryService(db *gorm.DB, logger *logrus.Logger) *WebhookDeliveryService {
 	client := &http.Client{
@@ -42,18 +66,19 @@ func NewWebhookDeliveryService(db *gorm.DB, logger *logrus.Logger) *WebhookDeliv
 
 // WebhookPayload represents the structure of webhook payload
 type WebhookPayload struct {
-	Event      string                 `json:"event"`
-	Action     string                 `json:"action,omitempty"`
-	Repository map[string]interface{} `json:"repository"`
-	Sender     map[string]interface{} `json:"sender,omitempty"`
-	Data       map[string]interface{} `json:"data,omitempty"`
-	Timestamp  time.Time              `json:"timestamp"`
+	Event        string                 `json:"event"`
+	Action       string                 `json:"action,omitempty"`
+	Repository   map[string]interface{} `json:"repository,omitempty"`
+	Organization map[string]interface{} `json:"organization,omitempty"`
+	Sender       map[string]interface{} `json:"sender,omitempty"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+	Timestamp    time.Time              `json:"timestamp"`
 }
 
 // CreateWebhook creates a new webhook configuration
 func (s *WebhookDeliveryService) CreateWebhook(ctx context.Context, repositoryID uuid.UUID, name, url, secret string, events []string, contentType string, insecureSSL, active bool) (*models.Webhook, error) {
 	webhook := &models.Webhook{
-		RepositoryID: repositoryID,
+		RepositoryID: &repositoryID,
 		Name:         name,
 		URL:          url,
 		Secret:       secret,
@@ -61,18 +86,40 @@ func (s *WebhookDeliveryService) CreateWebhook(ctx context.Context, repositoryID
 		InsecureSSL:  insecureSSL,
 		Active:       active,
 	}
+	return s.createWebhook(ctx, webhook, events)
+}
+
+// CreateOrganizationWebhook creates a webhook receiving the events of an
+// organization
+func (s *WebhookDeliveryService) CreateOrganizationWebhook(ctx context.Context, organizationID uuid.UUID, name, url, secret string, events []string, contentType string, insecureSSL, active bool) (*models.Webhook, error) {
+	webhook := &models.Webhook{
+		OrganizationID: &organizationID,
+		Name:           name,
+		URL:            url,
+		Secret:         secret,
+		ContentType:    contentType,
+		InsecureSSL:    insecureSSL,
+		Active:         active,
+	}
+	return s.createWebhook(ctx, webhook, events)
+}
 
+func (s *WebhookDeliveryService) createWebhook(ctx context.Context, webhook *models.Webhook, events []string) (*models.Webhook, error) {
 	webhook.SetEventsSlice(events)
+	if webhook.PayloadVersion == "" {
+		webhook.PayloadVersion = WebhookPayloadVersion1
+	}
 
 	if err := s.db.WithContext(ctx).Create(webhook).Error; err != nil {
 		return nil, fmt.Errorf("failed to create webhook: %w", err)
 	}
 
 	s.logger.WithFields(logrus.Fields{
-		"webhook_id":    webhook.ID,
-		"repository_id": repositoryID,
-		"url":           url,
-		"events":        events,
+		"webhook_id":      webhook.ID,
+		"repository_id":   webhook.RepositoryID,
+		"organization_id": webhook.OrganizationID,
+		"url":             webhook.URL,
+		"events":          events,
 	}).Info("Created webhook")
 
 	return webhook, nil
@@ -99,6 +146,15 @@ func (s *WebhookDeliveryService) ListWebhooks(ctx context.Context, repositoryID
 	return webhooks, nil
 }
 
+// ListOrganizationWebhooks lists all webhooks for an organization
+func (s *WebhookDeliveryService) ListOrganizationWebhooks(ctx context.Context, organizationID uuid.UUID) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	if err := s.db.WithContext(ctx).Where("organization_id = ?", organizationID).Find(&webhooks).Error; err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	return webhooks, nil
+}
+
 // UpdateWebhook updates an existing webhook
 func (s *WebhookDeliveryService) UpdateWebhook(ctx context.Context, webhookID uuid.UUID, updates map[string]interface{}) (*models.Webhook, error) {
 	var webhook models.Webhook
@@ -109,13 +165,50 @@ func (s *WebhookDeliveryService) UpdateWebhook(ctx context.Context, webhookID uu
 		return nil, fmt.Errorf("failed to get webhook: %w", err)
 	}
 
+	fields := make([]string, 0, len(updates))
+	for field := range updates {
+		fields = append(fields, field)
+	}
+
+	version, versionSet := updates["payload_version"].(string)
+	tmpl, templateSet := updates["payload_template"].(string)
+	if versionSet || templateSet {
+		if !versionSet {
+			version = webhookPayloadVersion(&webhook)
+		}
+		if !templateSet {
+			tmpl = webhook.PayloadTemplate
+		}
+		if err := ValidateWebhookPayloadFormat(version, tmpl); err != nil {
+			return nil, err
+		}
+	}
+
+	// Map updates bypass the model serializer, so the secret is sealed here
+	if secret, ok := updates["secret"].(string); ok {
+		sealed, err := encryption.Seal(webhook.TableName(), "secret", secret)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt webhook secret: %w", err)
+		}
+		updates["secret"] = sealed
+	}
+	// Enabling a webhook again starts a new failing streak
+	if active, ok := updates["active"].(bool); ok && active {
+		updates["consecutive_failures"] = 0
+		updates["disabled_at"] = nil
+		updates["disabled_reason"] = ""
+	}
+
 	if err := s.db.WithContext(ctx).Model(&webhook).Updates(updates).Error; err != nil {
 		return nil, fmt.Errorf("failed to update webhook: %w", err)
 	}
+	if err := s.db.WithContext(ctx).First(&webhook, "id = ?", webhookID).Error; err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
 
 	s.logger.WithFields(logrus.Fields{
 		"webhook_id": webhookID,
-		"updates":    updates,
+		"fields":     fields,
 	}).Info("Updated webhook")
 
 	return &webhook, nil
@@ -164,25 +257,88 @@ func (s *WebhookDeliveryService) TriggerWebhooks(ctx context.Context, repository
 		s.logger.WithError(err).Error("Failed to create webhook event record")
 	}
 
-	// Deliver to each webhook
-	for _, webhook := range webhooks {
-		events := webhook.GetEventsSlice()
-
-		// Check if webhook is configured for this event type
-		shouldTrigger := false
-		for _, event := range events {
-			if event == eventType || event == "*" {
-				shouldTrigger = true
-				break
+	s.deliverAll(ctx, webhooks, eventType, payload)
+
+	// Mark event as processed
+	webhookEvent.Processed = true
+	now := time.Now()
+	webhookEvent.ProcessedAt = &now
+	s.db.WithContext(ctx).Save(webhookEvent)
+
+	return nil
+}
+
+// TriggerOrganizationWebhooks triggers all active webhooks of an organization
+// subscribed to the event
+func (s *WebhookDeliveryService) TriggerOrganizationWebhooks(ctx context.Context, organizationID uuid.UUID, eventType string, payload map[string]interface{}) error {
+	var webhooks []models.Webhook
+	if err := s.db.WithContext(ctx).Where("organization_id = ? AND active = ?", organizationID, true).Find(&webhooks).Error; err != nil {
+		return fmt.Errorf("failed to get webhooks: %w", err)
+	}
+
+	s.deliverAll(ctx, webhooks, eventType, payload)
+	return nil
+}
+
+// webhookDeliveryJob is the payload of a JobDeliverWebhook job
+type webhookDeliveryJob struct {
+	WebhookID uuid.UUID              `json:"webhook_id"`
+	EventType string                 `json:"event_type"`
+	Payload   map[string]interface{} `json:"payload"`
+}
+
+// UseQueue delivers triggered events as queued jobs, which survive
+// restarts. Failed deliveries are retried by RetryFailedDeliveries, not
+// by the queue.
+func (s *WebhookDeliveryService) UseQueue(queue *jobs.Queue) {
+	s.queue = queue
+	queue.Register(JobDeliverWebhook, jobs.RetryPolicy{}, func(ctx context.Context, payload json.RawMessage) error {
+		var job webhookDeliveryJob
+		if err := json.Unmarshal(payload, &job); err != nil {
+			return jobs.Permanent(err)
+		}
+		var webhook models.Webhook
+		if err := s.db.WithContext(ctx).First(&webhook, "id = ?", job.WebhookID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
 			}
+			return err
+		}
+		if !webhook.Active {
+			return nil
+		}
+		if err := s.DeliverWebhook(ctx, webhook, job.EventType, job.Payload); err != nil {
+			s.logger.WithError(err).WithFields(logrus.Fields{
+				"webhook_id": webhook.ID,
+				"event_type": job.EventType,
+			}).Error("Failed to deliver webhook")
 		}
+		return nil
+	})
+}
 
-		if !shouldTrigger {
+// deliverAll delivers the event asynchronously to each of webhooks that
+// subscribes to it
+func (s *WebhookDeliveryService) deliverAll(ctx context.Context, webhooks []models.Webhook, eventType string, payload map[string]interface{}) {
+	action, _ := payload["action"].(string)
+	for _, webhook := range webhooks {
+		if !webhook.SubscribesTo(eventType, action) {
+			continue
+		}
+
+		if s.queue != nil {
+			job := webhookDeliveryJob{WebhookID: webhook.ID, EventType: eventType, Payload: payload}
+			if _, err := s.queue.Enqueue(ctx, JobDeliverWebhook, job, jobs.EnqueueOptions{}); err != nil {
+				s.logger.WithError(err).WithFields(logrus.Fields{
+					"webhook_id": webhook.ID,
+					"event_type": eventType,
+				}).Error("Failed to enqueue webhook delivery")
+			}
 			continue
 		}
 
-		// Deliver webhook asynchronously
 		go func(w models.Webhook) {
+			defer errorreporting.Default().Recover("webhook_delivery", map[string]string{"webhook_id": w.ID.String()})
 			if err := s.DeliverWebhook(context.Background(), w, eventType, payload); err != nil {
 				s.logger.WithError(err).WithFields(logrus.Fields{
 					"webhook_id": w.ID,
@@ -191,14 +347,6 @@ func (s *WebhookDeliveryService) TriggerWebhooks(ctx context.Context, repository
 			}
 		}(webhook)
 	}
-
-	// Mark event as processed
-	webhookEvent.Processed = true
-	now := time.Now()
-	webhookEvent.ProcessedAt = &now
-	s.db.WithContext(ctx).Save(webhookEvent)
-
-	return nil
 }
 
 // DeliverWebhook delivers a single webhook
@@ -214,83 +362,77 @@ func (s *WebhookDeliveryService) DeliverWebhook(ctx context.Context, webhook mod
 		Attempts:   1,
 	}
 
-	// Prepare payload
-	webhookPayload := WebhookPayload{
-		Event:      eventType,
-		Repository: payload,
-		Timestamp:  time.Now(),
-	}
-
-	if data, ok := payload["data"]; ok {
-		webhookPayload.Data = data.(map[string]interface{})
-	}
-	if action, ok := payload["action"]; ok {
-		webhookPayload.Action = action.(string)
-	}
-	if sender, ok := payload["sender"]; ok {
-		webhookPayload.Sender = sender.(map[string]interface{})
-	}
-
-	payloadBytes, err := json.Marshal(webhookPayload)
+	payloadBytes, err := s.RenderPayload(&webhook, eventType, deliveryID, payload)
 	if err != nil {
+		// A template failing to render is not retried, but counts against
+		// the webhook's health like any failed delivery
 		delivery.Success = false
-		delivery.ErrorMessage = fmt.Sprintf("Failed to marshal payload: %v", err)
+		delivery.ErrorMessage = fmt.Sprintf("Failed to render payload: %v", err)
 		s.db.WithContext(ctx).Create(delivery)
-		return fmt.Errorf("failed to marshal webhook payload: %w", err)
+		s.recordOutcome(ctx, &webhook, delivery)
+		return fmt.Errorf("failed to render webhook payload: %w", err)
 	}
 
 	delivery.Payload = string(payloadBytes)
 
-	// Attempt delivery
+	s.attempt(&webhook, delivery, payloadBytes)
+
+	if err := s.db.WithContext(ctx).Create(delivery).Error; err != nil {
+		s.logger.WithError(err).Error("Failed to save webhook delivery")
+	}
+	s.recordOutcome(ctx, &webhook, delivery)
+
+	s.logger.WithFields(logrus.Fields{
+		"webhook_id":  webhook.ID,
+		"delivery_id": deliveryID,
+		"event_type":  eventType,
+		"url":         webhook.URL,
+		"success":     delivery.Success,
+		"status_code": delivery.StatusCode,
+		"duration":    delivery.Duration,
+	}).Info("Webhook delivery completed")
+
+	// Receivers that answered are recorded, not reported as errors
+	if !delivery.Success && delivery.StatusCode == 0 {
+		return fmt.Errorf("webhook delivery failed: %s", delivery.ErrorMessage)
+	}
+	return nil
+}
+
+// attempt sends payload for delivery and records the outcome on it. Failed
+// attempts that may succeed later are scheduled for a retry with
+// exponential backoff until maxDeliveryAttempts is reached.
+func (s *WebhookDeliveryService) attempt(webhook *models.Webhook, delivery *models.WebhookDelivery, payload []byte) {
 	startTime := time.Now()
-	statusCode, responseHeaders, responseBody, err := s.sendWebhookRequest(webhook, deliveryID, payloadBytes)
-	duration := time.Since(startTime).Milliseconds()
+	statusCode, responseHeaders, responseBody, err := s.sendWebhookRequest(*webhook, delivery.EventType, delivery.DeliveryID, payload)
 
-	delivery.Duration = duration
+	delivery.Duration = time.Since(startTime).Milliseconds()
 	delivery.StatusCode = statusCode
 	delivery.ResponseHeaders = responseHeaders
 	delivery.ResponseBody = responseBody
+	delivery.NextRetryAt = nil
 
-	if err != nil {
+	switch {
+	case err != nil:
 		delivery.Success = false
 		delivery.ErrorMessage = err.Error()
-
-		// Schedule retry if it's a retryable error
-		if s.isRetryableError(statusCode, err) {
-			nextRetry := s.calculateNextRetry(1)
-			delivery.NextRetryAt = &nextRetry
-		}
-	} else if statusCode >= 200 && statusCode < 300 {
+	case statusCode >= 200 && statusCode < 300:
 		delivery.Success = true
-	} else {
+		delivery.ErrorMessage = ""
+		return
+	default:
 		delivery.Success = false
 		delivery.ErrorMessage = fmt.Sprintf("HTTP %d: %s", statusCode, responseBody)
-
-		if s.isRetryableStatusCode(statusCode) {
-			nextRetry := s.calculateNextRetry(1)
-			delivery.NextRetryAt = &nextRetry
-		}
 	}
 
-	if err := s.db.WithContext(ctx).Create(delivery).Error; err != nil {
-		s.logger.WithError(err).Error("Failed to save webhook delivery")
+	if delivery.Attempts < maxDeliveryAttempts && s.isRetryableError(statusCode, err) {
+		nextRetry := s.calculateNextRetry(delivery.Attempts)
+		delivery.NextRetryAt = &nextRetry
 	}
-
-	s.logger.WithFields(logrus.Fields{
-		"webhook_id":  webhook.ID,
-		"delivery_id": deliveryID,
-		"event_type":  eventType,
-		"url":         webhook.URL,
-		"success":     delivery.Success,
-		"status_code": statusCode,
-		"duration":    duration,
-	}).Info("Webhook delivery completed")
-
-	return err
 }
 
 // sendWebhookRequest sends the actual HTTP request
-func (s *WebhookDeliveryService) sendWebhookRequest(webhook models.Webhook, deliveryID string, payload []byte) (int, string, string, error) {
+func (s *WebhookDeliveryService) sendWebhookRequest(webhook models.Webhook, eventType, deliveryID string, payload []byte) (int, string, string, error) {
 	req, err := http.NewRequest("POST", webhook.URL, bytes.NewBuffer(payload))
 	if err != nil {
 		return 0, "", "", fmt.Errorf("failed to create request: %w", err)
@@ -299,8 +441,10 @@ func (s *WebhookDeliveryService) sendWebhookRequest(webhook models.Webhook, deli
 	// Set headers
 	req.Header.Set("Content-Type", webhook.ContentType)
 	req.Header.Set("User-Agent", "Hub-Webhook/1.0")
-	req.Header.Set("X-Hub-Event", "push") // This should be dynamic based on event type
+	req.Header.Set("X-Hub-Event", eventType)
 	req.Header.Set("X-Hub-Delivery", deliveryID)
+	req.Header.Set("X-Hub-Hook-ID", webhook.ID.String())
+	req.Header.Set("X-Hub-Payload-Version", webhookPayloadVersion(&webhook))
 
 	// Add HMAC signature if secret is configured
 	if webhook.Secret != "" {
@@ -315,8 +459,8 @@ func (s *WebhookDeliveryService) sendWebhookRequest(webhook models.Webhook, deli
 	}
 	defer resp.Body.Close()
 
-	// Read response
-	responseBody, err := io.ReadAll(resp.Body)
+	// Read response, keeping at most maxResponseBodyBytes
+	responseBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodyBytes))
 	if err != nil {
 		return resp.StatusCode, "", "", fmt.Errorf("failed to read response: %w", err)
 	}
@@ -386,7 +530,7 @@ func (s *WebhookDeliveryService) RetryFailedDeliveries(ctx context.Context) erro
 
 	err := s.db.WithContext(ctx).
 		Preload("Webhook").
-		Where("success = ? AND next_retry_at IS NOT NULL AND next_retry_at <= ? AND attempts < ?", false, now, 5).
+		Where("success = ? AND next_retry_at IS NOT NULL AND next_retry_at <= ? AND attempts < ?", false, now, maxDeliveryAttempts).
 		Find(&deliveries).Error
 
 	if err != nil {
@@ -394,64 +538,51 @@ func (s *WebhookDeliveryService) RetryFailedDeliveries(ctx context.Context) erro
 	}
 
 	for _, delivery := range deliveries {
+		// Deliveries of deleted or disabled webhooks are not retried
+		if delivery.Webhook.ID == uuid.Nil || !delivery.Webhook.Active {
+			delivery.NextRetryAt = nil
+			s.db.WithContext(ctx).Model(&delivery).Update("next_retry_at", nil)
+			continue
+		}
+
 		s.logger.WithFields(logrus.Fields{
 			"delivery_id": delivery.ID,
 			"webhook_id":  delivery.WebhookID,
 			"attempt":     delivery.Attempts + 1,
 		}).Info("Retrying webhook delivery")
 
-		// Parse the original payload
-		var payload map[string]interface{}
-		if err := json.Unmarshal([]byte(delivery.Payload), &payload); err != nil {
-			s.logger.WithError(err).Error("Failed to parse payload for retry")
-			continue
-		}
-
-		// Increment attempt counter
 		delivery.Attempts++
+		s.attempt(&delivery.Webhook, &delivery, []byte(delivery.Payload))
 
-		// Retry the delivery
-		startTime := time.Now()
-		statusCode, responseHeaders, responseBody, err := s.sendWebhookRequest(delivery.Webhook, delivery.DeliveryID, []byte(delivery.Payload))
-		duration := time.Since(startTime).Milliseconds()
-
-		delivery.Duration = duration
-		delivery.StatusCode = statusCode
-		delivery.ResponseHeaders = responseHeaders
-		delivery.ResponseBody = responseBody
-		delivery.NextRetryAt = nil
-
-		if err != nil {
-			delivery.Success = false
-			delivery.ErrorMessage = err.Error()
-
-			// Schedule next retry if attempts < max
-			if delivery.Attempts < 5 && s.isRetryableError(statusCode, err) {
-				nextRetry := s.calculateNextRetry(delivery.Attempts)
-				delivery.NextRetryAt = &nextRetry
-			}
-		} else if statusCode >= 200 && statusCode < 300 {
-			delivery.Success = true
-			delivery.ErrorMessage = ""
-		} else {
-			delivery.Success = false
-			delivery.ErrorMessage = fmt.Sprintf("HTTP %d: %s", statusCode, responseBody)
-
-			// Schedule next retry if attempts < max
-			if delivery.Attempts < 5 && s.isRetryableStatusCode(statusCode) {
-				nextRetry := s.calculateNextRetry(delivery.Attempts)
-				delivery.NextRetryAt = &nextRetry
-			}
-		}
-
-		if err := s.db.WithContext(ctx).Save(&delivery).Error; err != nil {
+		if err := s.db.WithContext(ctx).Omit("Webhook").Save(&delivery).Error; err != nil {
 			s.logger.WithError(err).Error("Failed to update delivery after retry")
 		}
+		s.recordOutcome(ctx, &delivery.Webhook, &delivery)
 	}
 
 	return nil
 }
 
+// StartRetryScheduler retries due deliveries every minute until ctx is
+// cancelled
+func (s *WebhookDeliveryService) StartRetryScheduler(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			func() {
+				defer errorreporting.Default().Recover("webhook_retry_scheduler", nil)
+				if err := s.RetryFailedDeliveries(ctx); err != nil {
+					s.logger.WithError(err).Error("Failed to retry webhook deliveries")
+				}
+			}()
+		}
+	}
+}
+
 // PingWebhook sends a ping event to test webhook
 func (s *WebhookDeliveryService) PingWebhook(ctx context.Context, webhookID uuid.UUID) error {
 	webhook, err := s.GetWebhook(ctx, webhookID)
@@ -459,24 +590,11 @@ func (s *WebhookDeliveryService) PingWebhook(ctx context.Context, webhookID uuid
 		return err
 	}
 
-	// Create ping payload
-	payload := map[string]interface{}{
-		"action": "ping",
-		"repository": map[string]interface{}{
-			"id":        webhook.RepositoryID.String(),
-			"full_name": "test/repository",
-		},
-		"sender": map[string]interface{}{
-			"id":    uuid.New().String(),
-			"login": "hub-system",
-		},
-		"timestamp": time.Now(),
-	}
-
+	payload := s.SamplePayload(webhook, "ping")
 	return s.DeliverWebhook(ctx, *webhook, "ping", payload)
 }
 
-// GetDeliveries gets webhook deliveries for a webhook
+// GetDeliveries gets webhook deliveries for a webhook, newest first
 func (s *WebhookDeliveryService) GetDeliveries(ctx context.Context, webhookID uuid.UUID, limit, offset int) ([]models.WebhookDelivery, error) {
 	var deliveries []models.WebhookDelivery
 
@@ -495,3 +613,60 @@ func (s *WebhookDeliveryService) GetDeliveries(ctx context.Context, webhookID uu
 
 	return deliveries, nil
 }
+
+// CountDeliveries returns the number of deliveries recorded for a webhook
+func (s *WebhookDeliveryService) CountDeliveries(ctx context.Context, webhookID uuid.UUID) (int64, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.WebhookDelivery{}).Where("webhook_id = ?", webhookID).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to count deliveries: %w", err)
+	}
+	return count, nil
+}
+
+// GetDelivery retrieves a delivery of a webhook by ID
+func (s *WebhookDeliveryService) GetDelivery(ctx context.Context, webhookID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := s.db.WithContext(ctx).Where("webhook_id = ? AND id = ?", webhookID, deliveryID).First(&delivery).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("delivery not found")
+		}
+		return nil, fmt.Errorf("failed to get delivery: %w", err)
+	}
+	return &delivery, nil
+}
+
+// Redeliver sends the payload of a past delivery again, signed with the
+// webhook's current secret, and records the attempt as a new delivery. The
+// delivery GUID is kept so receivers can recognize the duplicate.
+func (s *WebhookDeliveryService) Redeliver(ctx context.Context, webhookID, deliveryID uuid.UUID) (*models.WebhookDelivery, error) {
+	webhook, err := s.GetWebhook(ctx, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	original, err := s.GetDelivery(ctx, webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := &models.WebhookDelivery{
+		WebhookID:  webhook.ID,
+		EventType:  original.EventType,
+		DeliveryID: original.DeliveryID,
+		URL:        webhook.URL,
+		Payload:    original.Payload,
+		Attempts:   1,
+		Redelivery: true,
+	}
+	s.attempt(webhook, delivery, []byte(original.Payload))
+	if err := s.db.WithContext(ctx).Create(delivery).Error; err != nil {
+		return nil, fmt.Errorf("failed to save webhook delivery: %w", err)
+	}
+	s.recordOutcome(ctx, webhook, delivery)
+
+	s.logger.WithFields(logrus.Fields{
+		"webhook_id":  webhook.ID,
+		"delivery_id": delivery.DeliveryID,
+		"success":     delivery.Success,
+	}).Info("Redelivered webhook")
+	return delivery, nil
+}
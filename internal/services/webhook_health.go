@@ -0,0 +1,238 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// Notification types sent to admins about failing webhooks
+const (
+	NotificationWebhookFailing  = "webhook_failing"
+	NotificationWebhookDisabled = "webhook_disabled"
+)
+
+// Integration health states
+const (
+	IntegrationHealthy  = "healthy"
+	IntegrationFailing  = "failing"
+	IntegrationDisabled = "disabled"
+)
+
+// IntegrationHealth is the delivery health of one of an organization's
+// integrations
+type IntegrationHealth struct {
+	Type                string     `json:"type"`
+	ID                  uuid.UUID  `json:"id"`
+	Name                string     `json:"name"`
+	URL                 string     `json:"url"`
+	Repository          string     `json:"repository,omitempty"`
+	Status              string     `json:"status"`
+	Active              bool       `json:"active"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastFailureAt       *time.Time `json:"last_failure_at,omitempty"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason      string     `json:"disabled_reason,omitempty"`
+}
+
+// EnableFailureAlerts notifies the admins of a webhook through notifications
+// once the configured number of deliveries in a row failed, deactivating
+// the webhook too when cfg.AutoDisable is set
+func (s *WebhookDeliveryService) EnableFailureAlerts(notifications NotificationService, cfg config.IntegrationAlerts) {
+	if !cfg.Enabled || cfg.FailureThreshold <= 0 {
+		return
+	}
+	s.notifications = notifications
+	s.alerts = cfg
+}
+
+// recordOutcome updates the failing streak of webhook after delivery was
+// attempted. Deliveries that will be retried do not count until their last
+// attempt failed.
+func (s *WebhookDeliveryService) recordOutcome(ctx context.Context, webhook *models.Webhook, delivery *models.WebhookDelivery) {
+	db := s.db.WithContext(ctx).Model(&models.Webhook{})
+	if delivery.Success {
+		if err := db.Where("id = ? AND consecutive_failures > 0", webhook.ID).
+			UpdateColumn("consecutive_failures", 0).Error; err != nil {
+			s.logger.WithError(err).WithField("webhook_id", webhook.ID).Warn("Failed to reset webhook failures")
+		}
+		return
+	}
+	if delivery.NextRetryAt != nil {
+		return
+	}
+
+	now := time.Now()
+	if err := db.Where("id = ?", webhook.ID).UpdateColumns(map[string]interface{}{
+		"consecutive_failures": gorm.Expr("consecutive_failures + 1"),
+		"last_failure_at":      now,
+	}).Error; err != nil {
+		s.logger.WithError(err).WithField("webhook_id", webhook.ID).Warn("Failed to record webhook failure")
+		return
+	}
+	if s.notifications == nil {
+		return
+	}
+
+	var failures int
+	if err := s.db.WithContext(ctx).Model(&models.Webhook{}).Where("id = ?", webhook.ID).
+		Pluck("consecutive_failures", &failures).Error; err != nil {
+		s.logger.WithError(err).WithField("webhook_id", webhook.ID).Warn("Failed to read webhook failures")
+		return
+	}
+	// Admins hear about a streak once, when it reaches the threshold
+	if failures != s.alerts.FailureThreshold {
+		return
+	}
+
+	notificationType := NotificationWebhookFailing
+	if s.alerts.AutoDisable {
+		reason := fmt.Sprintf("%d consecutive deliveries failed", failures)
+		if err := s.db.WithContext(ctx).Model(&models.Webhook{}).Where("id = ?", webhook.ID).UpdateColumns(map[string]interface{}{
+			"active":          false,
+			"disabled_at":     now,
+			"disabled_reason": reason,
+		}).Error; err != nil {
+			s.logger.WithError(err).WithField("webhook_id", webhook.ID).Error("Failed to disable failing webhook")
+		} else {
+			notificationType = NotificationWebhookDisabled
+		}
+	}
+
+	s.logger.WithFields(logrus.Fields{
+		"webhook_id": webhook.ID,
+		"failures":   failures,
+		"disabled":   notificationType == NotificationWebhookDisabled,
+	}).Warn("Webhook deliveries keep failing")
+
+	admins, err := s.webhookAdmins(ctx, webhook)
+	if err != nil {
+		s.logger.WithError(err).WithField("webhook_id", webhook.ID).Error("Failed to find webhook admins")
+		return
+	}
+	payload := map[string]interface{}{
+		"webhook_id":           webhook.ID.String(),
+		"name":                 webhook.Name,
+		"url":                  webhook.URL,
+		"consecutive_failures": failures,
+		"last_error":           delivery.ErrorMessage,
+	}
+	if webhook.RepositoryID != nil {
+		payload["repository_id"] = webhook.RepositoryID.String()
+	}
+	if webhook.OrganizationID != nil {
+		payload["organization_id"] = webhook.OrganizationID.String()
+	}
+	for _, admin := range admins {
+		s.notifications.Publish(admin, Notification{
+			ID: uuid.New(), Type: notificationType, Payload: payload, Timestamp: now,
+		})
+	}
+}
+
+// webhookAdmins returns the users administering the repository or
+// organization of webhook
+func (s *WebhookDeliveryService) webhookAdmins(ctx context.Context, webhook *models.Webhook) ([]uuid.UUID, error) {
+	db := s.db.WithContext(ctx)
+	orgAdmins := func(orgID uuid.UUID) ([]uuid.UUID, error) {
+		var ids []uuid.UUID
+		err := db.Model(&models.OrganizationMember{}).
+			Where("organization_id = ? AND role IN ?", orgID, []models.OrganizationRole{models.OrgRoleOwner, models.OrgRoleAdmin}).
+			Pluck("user_id", &ids).Error
+		return ids, err
+	}
+
+	if webhook.OrganizationID != nil {
+		return orgAdmins(*webhook.OrganizationID)
+	}
+
+	var repo models.Repository
+	if err := db.First(&repo, "id = ?", webhook.RepositoryID).Error; err != nil {
+		return nil, err
+	}
+	admins := []uuid.UUID{repo.OwnerID}
+	if repo.OwnerType == models.OwnerTypeOrganization {
+		ids, err := orgAdmins(repo.OwnerID)
+		if err != nil {
+			return nil, err
+		}
+		admins = ids
+	}
+
+	var collaborators []uuid.UUID
+	if err := db.Model(&models.RepositoryCollaborator{}).
+		Where("repository_id = ? AND permission = ?", repo.ID, models.PermissionAdmin).
+		Pluck("user_id", &collaborators).Error; err != nil {
+		return nil, err
+	}
+	seen := make(map[uuid.UUID]bool, len(admins))
+	for _, id := range admins {
+		seen[id] = true
+	}
+	for _, id := range collaborators {
+		if !seen[id] {
+			seen[id] = true
+			admins = append(admins, id)
+		}
+	}
+	return admins, nil
+}
+
+// IntegrationHealth lists the webhooks of an organization and of its
+// repositories with their delivery health, failing ones first
+func (s *WebhookDeliveryService) IntegrationHealth(ctx context.Context, organizationID uuid.UUID) ([]IntegrationHealth, error) {
+	var webhooks []models.Webhook
+	err := s.db.WithContext(ctx).Preload("Repository").
+		Where("organization_id = ? OR repository_id IN (?)", organizationID,
+			s.db.Model(&models.Repository{}).Select("id").
+				Where("owner_id = ? AND owner_type = ?", organizationID, models.OwnerTypeOrganization)).
+		Find(&webhooks).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	health := make([]IntegrationHealth, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		h := IntegrationHealth{
+			Type:                "webhook",
+			ID:                  webhook.ID,
+			Name:                webhook.Name,
+			URL:                 webhook.URL,
+			Status:              IntegrationHealthy,
+			Active:              webhook.Active,
+			ConsecutiveFailures: webhook.ConsecutiveFailures,
+			LastFailureAt:       webhook.LastFailureAt,
+			DisabledAt:          webhook.DisabledAt,
+			DisabledReason:      webhook.DisabledReason,
+		}
+		if webhook.Repository != nil {
+			h.Repository = webhook.Repository.Name
+		}
+		switch {
+		case !webhook.Active:
+			h.Status = IntegrationDisabled
+		case webhook.ConsecutiveFailures > 0:
+			h.Status = IntegrationFailing
+		}
+		health = append(health, h)
+	}
+
+	rank := map[string]int{IntegrationDisabled: 0, IntegrationFailing: 1, IntegrationHealthy: 2}
+	sort.SliceStable(health, func(i, j int) bool {
+		if rank[health[i].Status] != rank[health[j].Status] {
+			return rank[health[i].Status] < rank[health[j].Status]
+		}
+		if health[i].ConsecutiveFailures != health[j].ConsecutiveFailures {
+			return health[i].ConsecutiveFailures > health[j].ConsecutiveFailures
+		}
+		return health[i].Name < health[j].Name
+	})
+	return health, nil
+}
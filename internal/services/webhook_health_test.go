@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/config"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookFailureAlerts(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Organization{}, &models.OrganizationMember{}, &models.Repository{},
+		&models.RepositoryCollaborator{}, &models.Webhook{}, &models.WebhookDelivery{})
+	ctx := context.Background()
+
+	var status atomic.Int32
+	status.Store(http.StatusGone)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+
+	org := &models.Organization{ID: uuid.New(), Name: "acme", DisplayName: "Acme"}
+	require.NoError(t, db.Create(org).Error)
+	owner, admin, member := uuid.New(), uuid.New(), uuid.New()
+	for id, role := range map[uuid.UUID]models.OrganizationRole{owner: models.OrgRoleOwner, member: models.OrgRoleMember} {
+		require.NoError(t, db.Create(&models.OrganizationMember{ID: uuid.New(), OrganizationID: org.ID, UserID: id, Role: role}).Error)
+	}
+	repo := &models.Repository{ID: uuid.New(), OwnerID: org.ID, OwnerType: models.OwnerTypeOrganization, Name: "api",
+		DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	require.NoError(t, db.Create(&models.RepositoryCollaborator{ID: uuid.New(), RepositoryID: repo.ID, UserID: admin,
+		Permission: models.PermissionAdmin}).Error)
+
+	notifications := NewNotificationService()
+	received := func(userID uuid.UUID) <-chan Notification {
+		ch, cancel := notifications.Subscribe(userID)
+		t.Cleanup(cancel)
+		return ch
+	}
+	ownerCh, adminCh, memberCh := received(owner), received(admin), received(member)
+	expect := func(ch <-chan Notification) Notification {
+		select {
+		case n := <-ch:
+			return n
+		case <-time.After(time.Second):
+			t.Fatal("no notification published")
+			return Notification{}
+		}
+	}
+	expectNone := func(ch <-chan Notification) {
+		select {
+		case n := <-ch:
+			t.Fatalf("unexpected %s notification", n.Type)
+		default:
+		}
+	}
+
+	service := NewWebhookDeliveryService(db, logrus.New())
+	service.EnableFailureAlerts(notifications, config.IntegrationAlerts{Enabled: true, FailureThreshold: 3, AutoDisable: true})
+
+	webhook, err := service.CreateWebhook(ctx, repo.ID, "ci", server.URL, "", []string{"push"}, "application/json", false, true)
+	require.NoError(t, err)
+	healthy, err := service.CreateOrganizationWebhook(ctx, org.ID, "directory", server.URL, "", []string{"organization"}, "application/json", false, true)
+	require.NoError(t, err)
+	deliver := func() {
+		current, err := service.GetWebhook(ctx, webhook.ID)
+		require.NoError(t, err)
+		require.NoError(t, service.DeliverWebhook(ctx, *current, "push", map[string]interface{}{}))
+	}
+
+	t.Run("a success ends the streak", func(t *testing.T) {
+		deliver()
+		deliver()
+		status.Store(http.StatusOK)
+		deliver()
+		current, err := service.GetWebhook(ctx, webhook.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 0, current.ConsecutiveFailures)
+		assert.NotNil(t, current.LastFailureAt)
+		expectNone(ownerCh)
+	})
+
+	t.Run("admins are alerted and the webhook disabled", func(t *testing.T) {
+		status.Store(http.StatusGone)
+		for i := 0; i < 3; i++ {
+			deliver()
+		}
+
+		for _, ch := range []<-chan Notification{ownerCh, adminCh} {
+			n := expect(ch)
+			assert.Equal(t, NotificationWebhookDisabled, n.Type)
+			payload := n.Payload.(map[string]interface{})
+			assert.Equal(t, webhook.ID.String(), payload["webhook_id"])
+			assert.Equal(t, 3, payload["consecutive_failures"])
+		}
+		expectNone(memberCh)
+
+		current, err := service.GetWebhook(ctx, webhook.ID)
+		require.NoError(t, err)
+		assert.False(t, current.Active)
+		assert.NotNil(t, current.DisabledAt)
+		assert.Equal(t, "3 consecutive deliveries failed", current.DisabledReason)
+	})
+
+	t.Run("health listing", func(t *testing.T) {
+		health, err := service.IntegrationHealth(ctx, org.ID)
+		require.NoError(t, err)
+		require.Len(t, health, 2)
+		assert.Equal(t, webhook.ID, health[0].ID)
+		assert.Equal(t, IntegrationDisabled, health[0].Status)
+		assert.Equal(t, "api", health[0].Repository)
+		assert.Equal(t, healthy.ID, health[1].ID)
+		assert.Equal(t, IntegrationHealthy, health[1].Status)
+	})
+
+	t.Run("enabling again resets the health", func(t *testing.T) {
+		current, err := service.UpdateWebhook(ctx, webhook.ID, map[string]interface{}{"active": true})
+		require.NoError(t, err)
+		assert.True(t, current.Active)
+		assert.Equal(t, 0, current.ConsecutiveFailures)
+		assert.Nil(t, current.DisabledAt)
+		assert.Empty(t, current.DisabledReason)
+	})
+}
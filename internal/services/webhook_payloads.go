@@ -0,0 +1,236 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+)
+
+// Webhook payload versions. A webhook keeps the version it was configured
+// with, so receivers written against one are not broken by later ones.
+const (
+	// WebhookPayloadVersion1 carries the event payload as the repository of
+	// repository webhooks
+	WebhookPayloadVersion1 = "v1"
+	// WebhookPayloadVersion2 carries the repository, organization and sender
+	// objects separately and identifies the delivery and hook
+	WebhookPayloadVersion2 = "v2"
+)
+
+// WebhookPayloadVersions lists the supported payload versions, oldest first
+var WebhookPayloadVersions = []string{WebhookPayloadVersion1, WebhookPayloadVersion2}
+
+const (
+	// maxWebhookTemplateBytes bounds the size of a payload template
+	maxWebhookTemplateBytes = 64 * 1024
+	// maxRenderedPayloadBytes bounds the size of a body rendered by a template
+	maxRenderedPayloadBytes = 1024 * 1024
+)
+
+// ErrInvalidWebhookPayloadFormat is returned for unknown payload versions
+// and templates that do not parse or render
+var ErrInvalidWebhookPayloadFormat = errors.New("invalid webhook payload format")
+
+// WebhookPayloadV2 is the payload sent to webhooks pinned to version 2
+type WebhookPayloadV2 struct {
+	Version      string                 `json:"version"`
+	Event        string                 `json:"event"`
+	Action       string                 `json:"action,omitempty"`
+	DeliveryID   string                 `json:"delivery_id"`
+	HookID       uuid.UUID              `json:"hook_id"`
+	Repository   map[string]interface{} `json:"repository,omitempty"`
+	Organization map[string]interface{} `json:"organization,omitempty"`
+	Sender       map[string]interface{} `json:"sender,omitempty"`
+	Data         map[string]interface{} `json:"data,omitempty"`
+	Timestamp    time.Time              `json:"timestamp"`
+}
+
+// webhookTemplateFuncs are the functions available to payload templates
+// besides the text/template builtins
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"get": lookupPath,
+	"default": func(fallback, v interface{}) interface{} {
+		if v == nil || v == "" {
+			return fallback
+		}
+		return v
+	},
+	"join": func(sep string, v interface{}) string {
+		items, _ := v.([]interface{})
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i] = fmt.Sprint(item)
+		}
+		return strings.Join(parts, sep)
+	},
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"trim":    strings.TrimSpace,
+	"replace": func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+}
+
+// lookupPath returns the value at a dotted path such as
+// "data.commits.0.id" in a decoded JSON value, or nil when there is none
+func lookupPath(v interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+// ValidateWebhookPayloadFormat checks that version is a supported payload
+// version and that template, when set, parses
+func ValidateWebhookPayloadFormat(version, tmpl string) error {
+	_, err := parseWebhookPayloadFormat(version, tmpl)
+	return err
+}
+
+func parseWebhookPayloadFormat(version, tmpl string) (*template.Template, error) {
+	known := false
+	for _, v := range WebhookPayloadVersions {
+		known = known || v == version
+	}
+	if !known {
+		return nil, fmt.Errorf("%w: unknown payload version %q", ErrInvalidWebhookPayloadFormat, version)
+	}
+	if tmpl == "" {
+		return nil, nil
+	}
+	if len(tmpl) > maxWebhookTemplateBytes {
+		return nil, fmt.Errorf("%w: template is larger than %d bytes", ErrInvalidWebhookPayloadFormat, maxWebhookTemplateBytes)
+	}
+	parsed, err := template.New("payload").Funcs(webhookTemplateFuncs).Parse(tmpl)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhookPayloadFormat, err)
+	}
+	return parsed, nil
+}
+
+// webhookPayloadVersion returns the payload version of webhook; rows
+// created before versions existed have none and get the first
+func webhookPayloadVersion(webhook *models.Webhook) string {
+	if webhook.PayloadVersion == "" {
+		return WebhookPayloadVersion1
+	}
+	return webhook.PayloadVersion
+}
+
+// RenderPayload returns the request body sent to webhook for an event:
+// the payload in the webhook's version, transformed by its template if it
+// has one
+func (s *WebhookDeliveryService) RenderPayload(webhook *models.Webhook, eventType, deliveryID string, payload map[string]interface{}) ([]byte, error) {
+	version := webhookPayloadVersion(webhook)
+	tmpl, err := parseWebhookPayloadFormat(version, webhook.PayloadTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	action, _ := payload["action"].(string)
+	sender, _ := payload["sender"].(map[string]interface{})
+	data, _ := payload["data"].(map[string]interface{})
+	organization, _ := payload["organization"].(map[string]interface{})
+
+	var body []byte
+	switch version {
+	case WebhookPayloadVersion2:
+		repository, _ := payload["repository"].(map[string]interface{})
+		body, err = json.Marshal(WebhookPayloadV2{
+			Version:      version,
+			Event:        eventType,
+			Action:       action,
+			DeliveryID:   deliveryID,
+			HookID:       webhook.ID,
+			Repository:   repository,
+			Organization: organization,
+			Sender:       sender,
+			Data:         data,
+			Timestamp:    time.Now(),
+		})
+	default:
+		// Organization webhooks carry the organization in place of the
+		// repository
+		v1 := WebhookPayload{Event: eventType, Action: action, Sender: sender, Data: data, Timestamp: time.Now()}
+		if webhook.OrganizationID != nil {
+			v1.Organization = organization
+		} else {
+			v1.Repository = payload
+		}
+		body, err = json.Marshal(v1)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	if tmpl == nil {
+		return body, nil
+	}
+
+	// Templates see the payload as the receiver would decode it
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook payload: %w", err)
+	}
+	out := &limitedBuffer{limit: maxRenderedPayloadBytes}
+	if err := tmpl.Execute(out, decoded); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhookPayloadFormat, err)
+	}
+	return out.Bytes(), nil
+}
+
+// SamplePayload returns the event payload used to ping webhook and to
+// preview its payloads
+func (s *WebhookDeliveryService) SamplePayload(webhook *models.Webhook, action string) map[string]interface{} {
+	payload := map[string]interface{}{
+		"action": action,
+		"sender": map[string]interface{}{
+			"id":    uuid.New().String(),
+			"login": "hub-system",
+		},
+		"timestamp": time.Now(),
+	}
+	if webhook.OrganizationID != nil {
+		payload["organization"] = map[string]interface{}{"id": webhook.OrganizationID.String()}
+	} else if webhook.RepositoryID != nil {
+		payload["repository"] = map[string]interface{}{
+			"id":        webhook.RepositoryID.String(),
+			"full_name": "test/repository",
+		}
+	}
+	return payload
+}
+
+// limitedBuffer is a buffer failing writes past limit bytes, so templates
+// cannot render unbounded bodies
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("rendered payload is larger than %d bytes", b.limit)
+	}
+	return b.Buffer.Write(p)
+}
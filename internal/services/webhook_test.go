@@ -2,31 +2,25 @@ package services
 
 import (
 	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
+	"github.com/a5c-ai/hub/internal/jobs"
 	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
 )
 
 func setupWebhookTestDB(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	assert.NoError(t, err)
-
-	// Auto-migrate the schema
-	err = db.AutoMigrate(
-		&models.Webhook{},
-		&models.WebhookDelivery{},
-		&models.DeployKey{},
-		&models.WebhookEvent{},
-	)
-	assert.NoError(t, err)
-
-	return db
+	return testutil.NewTestDB(t, &models.Webhook{}, &models.WebhookDelivery{}, &models.DeployKey{}, &models.WebhookEvent{})
 }
 
 func TestWebhookDeliveryService_CreateWebhook(t *testing.T) {
@@ -51,7 +45,7 @@ func TestWebhookDeliveryService_CreateWebhook(t *testing.T) {
 	assert.NotNil(t, webhook)
 	assert.Equal(t, "test-webhook", webhook.Name)
 	assert.Equal(t, "https://example.com/webhook", webhook.URL)
-	assert.Equal(t, repositoryID, webhook.RepositoryID)
+	assert.Equal(t, &repositoryID, webhook.RepositoryID)
 	assert.True(t, webhook.Active)
 }
 
@@ -95,6 +89,158 @@ func TestWebhookDeliveryService_VerifySignature(t *testing.T) {
 	assert.False(t, isValid)
 }
 
+func TestWebhookDeliveryService_DeliveryRetryAndRedelivery(t *testing.T) {
+	db := setupWebhookTestDB(t)
+	service := NewWebhookDeliveryService(db, logrus.New())
+	ctx := context.Background()
+
+	var received []*http.Request
+	var bodies [][]byte
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	webhook, err := service.CreateWebhook(ctx, uuid.New(), "ci", server.URL, "s3cret", []string{"issues"}, "application/json", false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"issues"}, webhook.GetEventsSlice())
+	assert.False(t, webhook.SubscribesTo("push", ""))
+
+	// A failing receiver is recorded and scheduled for a retry
+	assert.NoError(t, service.DeliverWebhook(ctx, *webhook, "issues", map[string]interface{}{"action": "opened"}))
+	assert.Len(t, received, 1)
+	assert.Equal(t, "issues", received[0].Header.Get("X-Hub-Event"))
+	assert.True(t, service.VerifySignature("s3cret", received[0].Header.Get("X-Hub-Signature-256"), bodies[0]))
+
+	deliveries, err := service.GetDeliveries(ctx, webhook.ID, 0, 0)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.False(t, deliveries[0].Success)
+	assert.NotNil(t, deliveries[0].NextRetryAt)
+
+	// Due retries are sent again with the same delivery GUID
+	status = http.StatusOK
+	past := time.Now().Add(-time.Minute)
+	assert.NoError(t, db.Model(&deliveries[0]).Update("next_retry_at", past).Error)
+	assert.NoError(t, service.RetryFailedDeliveries(ctx))
+	assert.Len(t, received, 2)
+	assert.Equal(t, received[0].Header.Get("X-Hub-Delivery"), received[1].Header.Get("X-Hub-Delivery"))
+
+	retried, err := service.GetDelivery(ctx, webhook.ID, deliveries[0].ID)
+	assert.NoError(t, err)
+	assert.True(t, retried.Success)
+	assert.Equal(t, 2, retried.Attempts)
+	assert.Nil(t, retried.NextRetryAt)
+
+	// Redelivery records a new delivery of the same payload
+	redelivery, err := service.Redeliver(ctx, webhook.ID, deliveries[0].ID)
+	assert.NoError(t, err)
+	assert.True(t, redelivery.Redelivery)
+	assert.True(t, redelivery.Success)
+	assert.Equal(t, bodies[0], bodies[2])
+	count, err := service.CountDeliveries(ctx, webhook.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	_, err = service.Redeliver(ctx, uuid.New(), deliveries[0].ID)
+	assert.Error(t, err)
+}
+
+func TestWebhookDeliveryService_QueuedDelivery(t *testing.T) {
+	db := setupWebhookTestDB(t)
+	assert.NoError(t, db.AutoMigrate(&models.QueuedJob{}))
+	service := NewWebhookDeliveryService(db, logrus.New())
+	queue := jobs.NewQueue(db, nil, time.Second, logrus.New())
+	service.UseQueue(queue)
+	ctx := context.Background()
+
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	defer server.Close()
+
+	repoID := uuid.New()
+	_, err := service.CreateWebhook(ctx, repoID, "ci", server.URL, "", []string{"issues"}, "application/json", false, true)
+	assert.NoError(t, err)
+
+	// Triggering only queues the delivery; a worker sends it
+	assert.NoError(t, service.TriggerWebhooks(ctx, repoID, "issues", map[string]interface{}{"action": "opened"}))
+	assert.Zero(t, received)
+	ran, err := queue.RunNext(ctx, "test")
+	assert.NoError(t, err)
+	assert.True(t, ran)
+	assert.Equal(t, 1, received)
+
+	var job models.QueuedJob
+	assert.NoError(t, db.First(&job, "kind = ?", JobDeliverWebhook).Error)
+	assert.Equal(t, models.JobSucceeded, job.State)
+}
+
+func TestWebhookDeliveryService_PayloadVersionsAndTemplates(t *testing.T) {
+	db := setupWebhookTestDB(t)
+	service := NewWebhookDeliveryService(db, logrus.New())
+	ctx := context.Background()
+
+	var received []*http.Request
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = append(received, r)
+		bodies = append(bodies, string(body))
+	}))
+	defer server.Close()
+
+	webhook, err := service.CreateWebhook(ctx, uuid.New(), "legacy", server.URL, "s3cret", []string{"issues"}, "application/json", false, true)
+	assert.NoError(t, err)
+	assert.Equal(t, WebhookPayloadVersion1, webhook.PayloadVersion)
+
+	_, err = service.UpdateWebhook(ctx, webhook.ID, map[string]interface{}{"payload_version": "v9"})
+	assert.ErrorIs(t, err, ErrInvalidWebhookPayloadFormat)
+	_, err = service.UpdateWebhook(ctx, webhook.ID, map[string]interface{}{"payload_template": "{{.event"})
+	assert.ErrorIs(t, err, ErrInvalidWebhookPayloadFormat)
+
+	// Version 2 carries the repository object alone and names the hook
+	webhook, err = service.UpdateWebhook(ctx, webhook.ID, map[string]interface{}{"payload_version": WebhookPayloadVersion2})
+	assert.NoError(t, err)
+	payload := map[string]interface{}{
+		"action":     "opened",
+		"repository": map[string]interface{}{"full_name": "acme/api"},
+		"data":       map[string]interface{}{"labels": []string{"bug", "ui"}},
+	}
+	assert.NoError(t, service.DeliverWebhook(ctx, *webhook, "issues", payload))
+	assert.Equal(t, "v2", received[0].Header.Get("X-Hub-Payload-Version"))
+	var v2 WebhookPayloadV2
+	assert.NoError(t, json.Unmarshal([]byte(bodies[0]), &v2))
+	assert.Equal(t, webhook.ID, v2.HookID)
+	assert.Equal(t, received[0].Header.Get("X-Hub-Delivery"), v2.DeliveryID)
+	assert.Equal(t, map[string]interface{}{"full_name": "acme/api"}, v2.Repository)
+
+	// Templates shape the body, which is what gets signed
+	webhook, err = service.UpdateWebhook(ctx, webhook.ID, map[string]interface{}{
+		"payload_template": `<issue action="{{.action}}" repo="{{get . "repository.full_name"}}">{{join "," .data.labels}}</issue>`,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, service.DeliverWebhook(ctx, *webhook, "issues", payload))
+	assert.Equal(t, `<issue action="opened" repo="acme/api">bug,ui</issue>`, bodies[1])
+	assert.True(t, service.VerifySignature("s3cret", received[1].Header.Get("X-Hub-Signature-256"), []byte(bodies[1])))
+
+	// A template failing to render is recorded without sending or retrying
+	webhook, err = service.UpdateWebhook(ctx, webhook.ID, map[string]interface{}{"payload_template": `{{template "missing"}}`})
+	assert.NoError(t, err)
+	assert.Error(t, service.DeliverWebhook(ctx, *webhook, "issues", payload))
+	assert.Len(t, received, 2)
+	deliveries, err := service.GetDeliveries(ctx, webhook.ID, 1, 0)
+	assert.NoError(t, err)
+	assert.False(t, deliveries[0].Success)
+	assert.Nil(t, deliveries[0].NextRetryAt)
+	assert.Contains(t, deliveries[0].ErrorMessage, "Failed to render payload")
+}
+
 func TestDeployKeyService_CreateDeployKey(t *testing.T) {
 	db := setupWebhookTestDB(t)
 	logger := logrus.New()
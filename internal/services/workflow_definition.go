@@ -0,0 +1,248 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WorkflowsDirectory holds a repository's workflow files
+const WorkflowsDirectory = ".hub/workflows"
+
+// Events that trigger workflows
+const (
+	WorkflowEventPush        = "push"
+	WorkflowEventPullRequest = "pull_request"
+)
+
+var ErrInvalidWorkflow = errors.New("invalid workflow")
+
+// WorkflowDefinition is a parsed workflow file, in the subset of the GitHub
+// Actions syntax hub understands
+type WorkflowDefinition struct {
+	Name string                            `yaml:"name"`
+	On   WorkflowTriggers                  `yaml:"on"`
+	Env  map[string]string                 `yaml:"env"`
+	Jobs map[string]*WorkflowJobDefinition `yaml:"jobs"`
+}
+
+// WorkflowTriggers lists the events a workflow runs on; a nil filter means
+// the workflow ignores the event
+type WorkflowTriggers struct {
+	Push        *WorkflowBranchFilter
+	PullRequest *WorkflowBranchFilter
+}
+
+// WorkflowBranchFilter restricts an event to branches, the pushed branch for
+// pushes and the base branch for pull requests. Patterns match like path
+// patterns: "*" stays within one segment and "**" spans several.
+type WorkflowBranchFilter struct {
+	Branches       []string `yaml:"branches"`
+	BranchesIgnore []string `yaml:"branches-ignore"`
+}
+
+// WorkflowJobDefinition is a job of a workflow file
+type WorkflowJobDefinition struct {
+	Name   string                    `yaml:"name"`
+	RunsOn yamlStringList            `yaml:"runs-on"`
+	Needs  yamlStringList            `yaml:"needs"`
+	Env    map[string]string         `yaml:"env"`
+	Steps  []*WorkflowStepDefinition `yaml:"steps"`
+}
+
+// WorkflowStepDefinition runs a command or uses an action
+type WorkflowStepDefinition struct {
+	Name string            `yaml:"name" json:"name"`
+	Run  string            `yaml:"run" json:"run,omitempty"`
+	Uses string            `yaml:"uses" json:"uses,omitempty"`
+	With map[string]string `yaml:"with" json:"with,omitempty"`
+	Env  map[string]string `yaml:"env" json:"env,omitempty"`
+}
+
+// DisplayName is the step's name, or what it runs when it has none
+func (s *WorkflowStepDefinition) DisplayName() string {
+	switch {
+	case s.Name != "":
+		return s.Name
+	case s.Uses != "":
+		return "Run " + s.Uses
+	default:
+		line, _, _ := strings.Cut(strings.TrimSpace(s.Run), "\n")
+		return "Run " + line
+	}
+}
+
+// yamlStringList accepts either a single string or a list of strings
+type yamlStringList []string
+
+func (l *yamlStringList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = yamlStringList{node.Value}
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+	return nil
+}
+
+// UnmarshalYAML accepts "on: push", "on: [push, pull_request]" and a map of
+// events to branch filters
+func (t *WorkflowTriggers) UnmarshalYAML(node *yaml.Node) error {
+	filters := make(map[string]*WorkflowBranchFilter)
+	switch node.Kind {
+	case yaml.ScalarNode, yaml.SequenceNode:
+		var events yamlStringList
+		if err := node.Decode(&events); err != nil {
+			return err
+		}
+		for _, event := range events {
+			filters[event] = &WorkflowBranchFilter{}
+		}
+	case yaml.MappingNode:
+		var events map[string]*WorkflowBranchFilter
+		if err := node.Decode(&events); err != nil {
+			return err
+		}
+		for event, filter := range events {
+			if filter == nil {
+				filter = &WorkflowBranchFilter{}
+			}
+			filters[event] = filter
+		}
+	default:
+		return fmt.Errorf("on must be an event, a list of events or a map of events")
+	}
+
+	for event, filter := range filters {
+		switch event {
+		case WorkflowEventPush:
+			t.Push = filter
+		case WorkflowEventPullRequest:
+			t.PullRequest = filter
+		default:
+			return fmt.Errorf("unsupported event %q", event)
+		}
+	}
+	return nil
+}
+
+// ParseWorkflow parses and validates a workflow file. The name defaults to
+// the file's path.
+func ParseWorkflow(path string, content []byte) (*WorkflowDefinition, error) {
+	var def WorkflowDefinition
+	if err := yaml.Unmarshal(content, &def); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidWorkflow, path, err)
+	}
+	if def.Name == "" {
+		def.Name = path
+	}
+	if def.On.Push == nil && def.On.PullRequest == nil {
+		return nil, fmt.Errorf("%w: %s: no events in on", ErrInvalidWorkflow, path)
+	}
+	if len(def.Jobs) == 0 {
+		return nil, fmt.Errorf("%w: %s: no jobs", ErrInvalidWorkflow, path)
+	}
+	for key, job := range def.Jobs {
+		if job == nil {
+			return nil, fmt.Errorf("%w: %s: job %s is empty", ErrInvalidWorkflow, path, key)
+		}
+		if len(job.RunsOn) == 0 {
+			return nil, fmt.Errorf("%w: %s: job %s has no runs-on", ErrInvalidWorkflow, path, key)
+		}
+		if len(job.Steps) == 0 {
+			return nil, fmt.Errorf("%w: %s: job %s has no steps", ErrInvalidWorkflow, path, key)
+		}
+		for i, step := range job.Steps {
+			if step == nil || (step.Run == "") == (step.Uses == "") {
+				return nil, fmt.Errorf("%w: %s: step %d of job %s needs either run or uses", ErrInvalidWorkflow, path, i+1, key)
+			}
+		}
+		for _, need := range job.Needs {
+			if _, ok := def.Jobs[need]; !ok {
+				return nil, fmt.Errorf("%w: %s: job %s needs unknown job %s", ErrInvalidWorkflow, path, key, need)
+			}
+		}
+		if job.Name == "" {
+			job.Name = key
+		}
+	}
+	if _, err := def.JobOrder(); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidWorkflow, path, err)
+	}
+	return &def, nil
+}
+
+// JobOrder returns the job keys so that every job comes after the jobs it
+// needs, breaking ties by key
+func (d *WorkflowDefinition) JobOrder() ([]string, error) {
+	keys := make([]string, 0, len(d.Jobs))
+	for key := range d.Jobs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	order := make([]string, 0, len(keys))
+	done := make(map[string]bool, len(keys))
+	for len(order) < len(keys) {
+		progressed := false
+		for _, key := range keys {
+			if done[key] {
+				continue
+			}
+			ready := true
+			for _, need := range d.Jobs[key].Needs {
+				if !done[need] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				done[key] = true
+				order = append(order, key)
+				progressed = true
+			}
+		}
+		if !progressed {
+			return nil, fmt.Errorf("jobs needs form a cycle")
+		}
+	}
+	return order, nil
+}
+
+// Triggers reports whether the workflow runs for event on branch
+func (d *WorkflowDefinition) Triggers(event, branch string) bool {
+	var filter *WorkflowBranchFilter
+	switch event {
+	case WorkflowEventPush:
+		filter = d.On.Push
+	case WorkflowEventPullRequest:
+		filter = d.On.PullRequest
+	}
+	if filter == nil {
+		return false
+	}
+	for _, pattern := range filter.BranchesIgnore {
+		if matchBranchFilter(pattern, branch) {
+			return false
+		}
+	}
+	if len(filter.Branches) == 0 {
+		return true
+	}
+	for _, pattern := range filter.Branches {
+		if matchBranchFilter(pattern, branch) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchBranchFilter(pattern, branch string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(branch, "/"))
+}
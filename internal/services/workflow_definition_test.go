@@ -0,0 +1,95 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWorkflow(t *testing.T) {
+	def, err := ParseWorkflow(".hub/workflows/ci.yml", []byte(`
+name: CI
+on:
+  push:
+    branches: [main, "release/**"]
+  pull_request:
+env:
+  GO: "1.24"
+jobs:
+  test:
+    runs-on: [self-hosted, linux]
+    needs: build
+    steps:
+      - run: go test ./...
+  build:
+    runs-on: self-hosted
+    steps:
+      - uses: actions/checkout@v4
+      - name: Build
+        run: |
+          go build ./...
+          go vet ./...
+  lint:
+    runs-on: self-hosted
+    steps:
+      - run: golangci-lint run
+`))
+	require.NoError(t, err)
+	assert.Equal(t, "CI", def.Name)
+	assert.Equal(t, "test", def.Jobs["test"].Name, "job names default to their key")
+	assert.Equal(t, []string{"self-hosted", "linux"}, []string(def.Jobs["test"].RunsOn))
+	assert.Equal(t, "Run actions/checkout@v4", def.Jobs["build"].Steps[0].DisplayName())
+	assert.Equal(t, "Build", def.Jobs["build"].Steps[1].DisplayName())
+	assert.Equal(t, "Run go test ./...", def.Jobs["test"].Steps[0].DisplayName())
+
+	order, err := def.JobOrder()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"build", "lint", "test"}, order)
+
+	assert.True(t, def.Triggers(WorkflowEventPush, "main"))
+	assert.True(t, def.Triggers(WorkflowEventPush, "release/v1/hotfix"))
+	assert.False(t, def.Triggers(WorkflowEventPush, "feature"))
+	assert.True(t, def.Triggers(WorkflowEventPullRequest, "anything"))
+
+	def, err = ParseWorkflow(".hub/workflows/docs.yml", []byte(`
+on: [push]
+jobs:
+  docs:
+    runs-on: self-hosted
+    steps:
+      - run: make docs
+`))
+	require.NoError(t, err)
+	assert.Equal(t, ".hub/workflows/docs.yml", def.Name)
+	assert.True(t, def.Triggers(WorkflowEventPush, "feature/x"))
+	assert.False(t, def.Triggers(WorkflowEventPullRequest, "main"))
+
+	def, err = ParseWorkflow("w.yml", []byte(`
+on:
+  push:
+    branches-ignore: ["dependabot/**"]
+jobs:
+  a: {runs-on: x, steps: [{run: "true"}]}
+`))
+	require.NoError(t, err)
+	assert.False(t, def.Triggers(WorkflowEventPush, "dependabot/go/x"))
+	assert.True(t, def.Triggers(WorkflowEventPush, "main"))
+
+	invalid := map[string]string{
+		"syntax":        "on: [push\n",
+		"no events":     "jobs:\n  a: {runs-on: x, steps: [{run: x}]}\n",
+		"unknown event": "on: release\njobs:\n  a: {runs-on: x, steps: [{run: x}]}\n",
+		"no jobs":       "on: push\n",
+		"no runs-on":    "on: push\njobs:\n  a: {steps: [{run: x}]}\n",
+		"no steps":      "on: push\njobs:\n  a: {runs-on: x}\n",
+		"run and uses":  "on: push\njobs:\n  a: {runs-on: x, steps: [{run: x, uses: y}]}\n",
+		"unknown need":  "on: push\njobs:\n  a: {runs-on: x, needs: b, steps: [{run: x}]}\n",
+		"cycle":         "on: push\njobs:\n  a: {runs-on: x, needs: b, steps: [{run: x}]}\n  b: {runs-on: x, needs: a, steps: [{run: x}]}\n",
+	}
+	for name, content := range invalid {
+		_, err := ParseWorkflow("w.yml", []byte(content))
+		assert.True(t, errors.Is(err, ErrInvalidWorkflow), name)
+	}
+}
@@ -0,0 +1,644 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// maxWorkflowJobLog bounds the log kept for a single job; output past it is dropped
+const maxWorkflowJobLog = 4 << 20
+
+var (
+	ErrWorkflowRunNotFound = errors.New("workflow run not found")
+	ErrWorkflowJobNotFound = errors.New("workflow job not found")
+	ErrInvalidWorkflowStep = errors.New("invalid workflow step update")
+)
+
+// WorkflowService creates workflow runs from the workflow files of a
+// repository and dispatches their jobs to self-hosted runners
+type WorkflowService interface {
+	// HandlePush is a PushListener starting push workflows for every pushed
+	// branch, and pull_request workflows for the open pull requests from it
+	HandlePush(ctx context.Context, event PushEvent)
+	// HandlePullRequest is a PullRequestListener starting pull_request
+	// workflows when a pull request is opened
+	HandlePullRequest(ctx context.Context, event PullRequestEvent)
+	// HandleRunnerJob is a RunnerJobListener tracking the jobs runners pick
+	// up and complete, and dispatching the jobs that needed them
+	HandleRunnerJob(ctx context.Context, job *models.RunnerJob)
+	// Subscribe registers a listener called when a run completes
+	Subscribe(listener WorkflowRunListener)
+
+	ListRuns(ctx context.Context, repoID uuid.UUID, filter WorkflowRunFilter) ([]*models.WorkflowRun, error)
+	GetRun(ctx context.Context, repoID, runID uuid.UUID) (*models.WorkflowRun, error)
+	ListJobs(ctx context.Context, run *models.WorkflowRun) ([]*models.WorkflowJob, error)
+	GetJob(ctx context.Context, repoID, jobID uuid.UUID) (*models.WorkflowJob, error)
+
+	// UpdateStep and AppendLog are called by the runner running the job
+	UpdateStep(ctx context.Context, runner *models.Runner, runnerJobID uuid.UUID, req UpdateWorkflowStepRequest) (*models.WorkflowStep, error)
+	AppendLog(ctx context.Context, runner *models.Runner, runnerJobID uuid.UUID, output string) error
+}
+
+// WorkflowRunFilter narrows the runs of a repository
+type WorkflowRunFilter struct {
+	Branch   string
+	Event    string
+	Status   *models.WorkflowStatus
+	Page     int
+	PageSize int
+}
+
+// UpdateWorkflowStepRequest is sent by a runner as it starts and finishes steps
+type UpdateWorkflowStepRequest struct {
+	Number     int                       `json:"number" binding:"required"`
+	Status     models.WorkflowStatus     `json:"status" binding:"required"`
+	Conclusion models.WorkflowConclusion `json:"conclusion"`
+}
+
+// WorkflowJobPayload is the payload of the runner job of a workflow job
+type WorkflowJobPayload struct {
+	RunID    uuid.UUID                 `json:"run_id"`
+	JobID    uuid.UUID                 `json:"job_id"`
+	Workflow string                    `json:"workflow"`
+	Job      string                    `json:"job"`
+	Event    string                    `json:"event"`
+	Ref      string                    `json:"ref"`
+	SHA      string                    `json:"sha"`
+	Env      map[string]string         `json:"env,omitempty"`
+	Steps    []*WorkflowStepDefinition `json:"steps"`
+}
+
+type workflowService struct {
+	db            *gorm.DB
+	gitService    git.GitService
+	repoService   RepositoryService
+	runnerService RunnerService
+	statusService CommitStatusService
+	logger        *logrus.Logger
+	now           func() time.Time
+
+	// advanceMu keeps concurrent job completions of a run from dispatching
+	// the same job twice
+	advanceMu sync.Mutex
+
+	mu        sync.RWMutex
+	listeners []WorkflowRunListener
+}
+
+// WorkflowRunListener is notified when a workflow run completes
+type WorkflowRunListener func(ctx context.Context, run *models.WorkflowRun)
+
+func (s *workflowService) Subscribe(listener WorkflowRunListener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, listener)
+}
+
+func (s *workflowService) emit(ctx context.Context, run *models.WorkflowRun) {
+	s.mu.RLock()
+	listeners := make([]WorkflowRunListener, len(s.listeners))
+	copy(listeners, s.listeners)
+	s.mu.RUnlock()
+
+	for _, listener := range listeners {
+		listener(ctx, run)
+	}
+}
+
+func NewWorkflowService(db *gorm.DB, gitService git.GitService, repoService RepositoryService, runnerService RunnerService, statusService CommitStatusService, logger *logrus.Logger) WorkflowService {
+	return &workflowService{
+		db:            db,
+		gitService:    gitService,
+		repoService:   repoService,
+		runnerService: runnerService,
+		statusService: statusService,
+		logger:        logger,
+		now:           time.Now,
+	}
+}
+
+// workflowFile is a parsed workflow file and its path in the repository
+type workflowFile struct {
+	path string
+	def  *WorkflowDefinition
+}
+
+// loadWorkflows parses the workflow files at sha. Invalid files are logged
+// and skipped so they do not stop the other workflows.
+func (s *workflowService) loadWorkflows(ctx context.Context, repoPath, sha string) []workflowFile {
+	tree, err := s.gitService.GetTree(ctx, repoPath, sha, WorkflowsDirectory)
+	if err != nil {
+		// Most repositories have no workflows directory
+		return nil
+	}
+
+	var files []workflowFile
+	for _, entry := range tree.Entries {
+		ext := path.Ext(entry.Name)
+		if entry.Type != "blob" || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+		filePath := path.Join(WorkflowsDirectory, entry.Name)
+		log := s.logger.WithFields(logrus.Fields{"path": filePath, "sha": sha})
+		file, err := s.gitService.GetFile(ctx, repoPath, sha, filePath)
+		if err != nil {
+			log.WithError(err).Warn("Failed to read workflow file")
+			continue
+		}
+		if file.Encoding == "base64" {
+			log.Warn("Skipping binary workflow file")
+			continue
+		}
+		def, err := ParseWorkflow(filePath, []byte(file.Content))
+		if err != nil {
+			log.WithError(err).Warn("Skipping invalid workflow file")
+			continue
+		}
+		files = append(files, workflowFile{path: filePath, def: def})
+	}
+	return files
+}
+
+func (s *workflowService) HandlePush(ctx context.Context, event PushEvent) {
+	if event.Repository == nil {
+		return
+	}
+	repo := event.Repository
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, repo.ID)
+	if err != nil {
+		s.logger.WithError(err).WithField("repository_id", repo.ID).Warn("Failed to get repository path for workflows")
+		return
+	}
+
+	for _, update := range event.Updates {
+		branch := update.BranchName()
+		if branch == "" || update.IsDelete() {
+			continue
+		}
+		workflows := s.loadWorkflows(ctx, repoPath, update.NewSHA)
+		if len(workflows) == 0 {
+			continue
+		}
+
+		for _, wf := range workflows {
+			if wf.def.Triggers(WorkflowEventPush, branch) {
+				s.startRun(ctx, repo.ID, wf, WorkflowEventPush, branch, update.NewSHA, nil, event.PusherID)
+			}
+		}
+
+		// New commits on the head of an open pull request run its checks again
+		var pulls []*models.PullRequest
+		if err := s.db.WithContext(ctx).
+			Where("repository_id = ? AND head_branch = ? AND state = ?", repo.ID, branch, models.PullRequestStateOpen).
+			Where("head_repository_id IS NULL OR head_repository_id = ?", repo.ID).
+			Find(&pulls).Error; err != nil {
+			s.logger.WithError(err).WithField("repository_id", repo.ID).Warn("Failed to find pull requests for workflows")
+			continue
+		}
+		for _, pr := range pulls {
+			for _, wf := range workflows {
+				if wf.def.Triggers(WorkflowEventPullRequest, pr.BaseBranch) {
+					s.startRun(ctx, repo.ID, wf, WorkflowEventPullRequest, branch, update.NewSHA, &pr.ID, event.PusherID)
+				}
+			}
+		}
+	}
+}
+
+// HandlePullRequest starts workflows for pull requests opened from a branch
+// of the same repository. Pull requests from forks are not run, as their
+// code would execute on the base repository's runners.
+func (s *workflowService) HandlePullRequest(ctx context.Context, event PullRequestEvent) {
+	pr := event.PullRequest
+	if event.Action != PullRequestActionOpened || pr == nil {
+		return
+	}
+	if pr.HeadRepositoryID != nil && *pr.HeadRepositoryID != pr.RepositoryID {
+		return
+	}
+	log := s.logger.WithField("pull_request_id", pr.ID)
+
+	repoPath, err := s.repoService.GetRepositoryPath(ctx, pr.RepositoryID)
+	if err != nil {
+		log.WithError(err).Warn("Failed to get repository path for workflows")
+		return
+	}
+	head, err := s.gitService.GetBranch(ctx, repoPath, pr.HeadBranch)
+	if err != nil {
+		log.WithError(err).Warn("Failed to resolve pull request head for workflows")
+		return
+	}
+
+	actorID := event.ActorID
+	for _, wf := range s.loadWorkflows(ctx, repoPath, head.SHA) {
+		if wf.def.Triggers(WorkflowEventPullRequest, pr.BaseBranch) {
+			s.startRun(ctx, pr.RepositoryID, wf, WorkflowEventPullRequest, pr.HeadBranch, head.SHA, &pr.ID, &actorID)
+		}
+	}
+}
+
+// startRun records a run of the workflow with its jobs and steps, reports
+// every job as pending and dispatches the jobs that need nothing
+func (s *workflowService) startRun(ctx context.Context, repoID uuid.UUID, wf workflowFile, event, branch, sha string, prID, actorID *uuid.UUID) {
+	log := s.logger.WithFields(logrus.Fields{"repository_id": repoID, "path": wf.path, "event": event, "sha": sha})
+
+	order, err := wf.def.JobOrder()
+	if err != nil {
+		log.WithError(err).Warn("Failed to order workflow jobs")
+		return
+	}
+
+	run := &models.WorkflowRun{
+		ID:            uuid.New(),
+		RepositoryID:  repoID,
+		Name:          wf.def.Name,
+		WorkflowPath:  wf.path,
+		Event:         event,
+		HeadBranch:    branch,
+		HeadSHA:       sha,
+		PullRequestID: prID,
+		ActorID:       actorID,
+		Status:        models.WorkflowStatusQueued,
+	}
+	for _, key := range order {
+		def := wf.def.Jobs[key]
+		job := &models.WorkflowJob{
+			ID:     uuid.New(),
+			RunID:  run.ID,
+			Key:    key,
+			Name:   def.Name,
+			RunsOn: def.RunsOn,
+			Needs:  def.Needs,
+			Status: models.WorkflowStatusQueued,
+		}
+
+		env := make(map[string]string, len(wf.def.Env)+len(def.Env))
+		for k, v := range wf.def.Env {
+			env[k] = v
+		}
+		for k, v := range def.Env {
+			env[k] = v
+		}
+		payload, err := json.Marshal(WorkflowJobPayload{
+			RunID:    run.ID,
+			JobID:    job.ID,
+			Workflow: wf.def.Name,
+			Job:      key,
+			Event:    event,
+			Ref:      "refs/heads/" + branch,
+			SHA:      sha,
+			Env:      env,
+			Steps:    def.Steps,
+		})
+		if err != nil {
+			log.WithError(err).Warn("Failed to encode workflow job")
+			return
+		}
+		job.Payload = string(payload)
+
+		for i, step := range def.Steps {
+			job.Steps = append(job.Steps, &models.WorkflowStep{
+				ID:     uuid.New(),
+				JobID:  job.ID,
+				Number: i + 1,
+				Name:   step.DisplayName(),
+				Status: models.WorkflowStatusQueued,
+			})
+		}
+		run.Jobs = append(run.Jobs, job)
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var last int
+		if err := tx.Model(&models.WorkflowRun{}).Where("repository_id = ?", repoID).
+			Select("COALESCE(MAX(run_number), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+		run.RunNumber = last + 1
+		return tx.Create(run).Error
+	})
+	if err != nil {
+		log.WithError(err).Error("Failed to create workflow run")
+		return
+	}
+	log.WithFields(logrus.Fields{"run_id": run.ID, "run_number": run.RunNumber}).Info("Started workflow run")
+
+	for _, job := range run.Jobs {
+		s.reportStatus(ctx, run, job)
+	}
+	s.advance(ctx, run.ID)
+}
+
+// reportStatus reports the job's state as a commit status of the run's head
+func (s *workflowService) reportStatus(ctx context.Context, run *models.WorkflowRun, job *models.WorkflowJob) {
+	req := CreateCommitStatusRequest{Context: run.Name + " / " + job.Name}
+	switch {
+	case job.Status == models.WorkflowStatusQueued:
+		req.State, req.Description = models.CommitStatusPending, "Queued"
+	case job.Status == models.WorkflowStatusInProgress:
+		req.State, req.Description = models.CommitStatusPending, "In progress"
+	case job.Conclusion == models.WorkflowConclusionSuccess:
+		req.State, req.Description = models.CommitStatusSuccess, "Successful"
+	case job.Conclusion == models.WorkflowConclusionSkipped:
+		req.State, req.Description = models.CommitStatusFailure, "Skipped because a needed job did not succeed"
+	default:
+		req.State, req.Description = models.CommitStatusFailure, "Failed"
+	}
+	if _, err := s.statusService.Create(ctx, run.RepositoryID, run.HeadSHA, nil, req); err != nil {
+		s.logger.WithError(err).WithFields(logrus.Fields{"run_id": run.ID, "job_id": job.ID}).Warn("Failed to report workflow job status")
+	}
+}
+
+// advance skips the queued jobs of a run whose needs did not succeed,
+// dispatches those whose needs all did, and completes the run once every
+// job has completed
+func (s *workflowService) advance(ctx context.Context, runID uuid.UUID) {
+	s.advanceMu.Lock()
+	defer s.advanceMu.Unlock()
+
+	var run models.WorkflowRun
+	if err := s.db.WithContext(ctx).Preload("Jobs").First(&run, "id = ?", runID).Error; err != nil {
+		s.logger.WithError(err).WithField("run_id", runID).Warn("Failed to load workflow run")
+		return
+	}
+	log := s.logger.WithField("run_id", run.ID)
+
+	byKey := make(map[string]*models.WorkflowJob, len(run.Jobs))
+	for _, job := range run.Jobs {
+		byKey[job.Key] = job
+	}
+
+	// Jobs are stored in dependency order, so one pass settles every job
+	for _, job := range run.Jobs {
+		if job.Status != models.WorkflowStatusQueued || job.RunnerJobID != nil {
+			continue
+		}
+		ready, blocked := true, false
+		for _, need := range job.Needs {
+			dep := byKey[need]
+			switch {
+			case dep == nil || dep.Status != models.WorkflowStatusCompleted:
+				ready = false
+			case dep.Conclusion != models.WorkflowConclusionSuccess:
+				blocked = true
+			}
+		}
+
+		if blocked {
+			now := s.now()
+			job.Status, job.Conclusion, job.CompletedAt = models.WorkflowStatusCompleted, models.WorkflowConclusionSkipped, &now
+			if err := s.db.WithContext(ctx).Model(job).Updates(map[string]interface{}{
+				"status": job.Status, "conclusion": job.Conclusion, "completed_at": now,
+			}).Error; err != nil {
+				log.WithError(err).Warn("Failed to skip workflow job")
+				continue
+			}
+			s.db.WithContext(ctx).Model(&models.WorkflowStep{}).Where("job_id = ?", job.ID).
+				Updates(map[string]interface{}{"status": models.WorkflowStatusCompleted, "conclusion": models.WorkflowConclusionSkipped})
+			s.reportStatus(ctx, &run, job)
+			continue
+		}
+		if !ready {
+			continue
+		}
+
+		runnerJob, err := s.runnerService.EnqueueJob(ctx, run.RepositoryID, job.RunsOn, job.Payload)
+		if err != nil {
+			log.WithError(err).WithField("job_id", job.ID).Error("Failed to dispatch workflow job")
+			continue
+		}
+		job.RunnerJobID = &runnerJob.ID
+		if err := s.db.WithContext(ctx).Model(job).Update("runner_job_id", runnerJob.ID).Error; err != nil {
+			log.WithError(err).WithField("job_id", job.ID).Error("Failed to record dispatched workflow job")
+		}
+	}
+
+	conclusion := models.WorkflowConclusionSuccess
+	for _, job := range run.Jobs {
+		if job.Status != models.WorkflowStatusCompleted {
+			return
+		}
+		if job.Conclusion != models.WorkflowConclusionSuccess {
+			conclusion = models.WorkflowConclusionFailure
+		}
+	}
+	now := s.now()
+	if err := s.db.WithContext(ctx).Model(&run).Updates(map[string]interface{}{
+		"status": models.WorkflowStatusCompleted, "conclusion": conclusion, "completed_at": now,
+	}).Error; err != nil {
+		log.WithError(err).Warn("Failed to complete workflow run")
+		return
+	}
+	log.WithField("conclusion", conclusion).Info("Completed workflow run")
+	run.Status, run.Conclusion, run.CompletedAt = models.WorkflowStatusCompleted, conclusion, &now
+	s.emit(ctx, &run)
+}
+
+func (s *workflowService) HandleRunnerJob(ctx context.Context, runnerJob *models.RunnerJob) {
+	var job models.WorkflowJob
+	if err := s.db.WithContext(ctx).First(&job, "runner_job_id = ?", runnerJob.ID).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			s.logger.WithError(err).WithField("runner_job_id", runnerJob.ID).Warn("Failed to load workflow job")
+		}
+		// Not every runner job belongs to a workflow
+		return
+	}
+	var run models.WorkflowRun
+	if err := s.db.WithContext(ctx).First(&run, "id = ?", job.RunID).Error; err != nil {
+		s.logger.WithError(err).WithField("run_id", job.RunID).Warn("Failed to load workflow run")
+		return
+	}
+	log := s.logger.WithFields(logrus.Fields{"run_id": run.ID, "job_id": job.ID})
+
+	now := s.now()
+	switch runnerJob.Status {
+	case models.RunnerJobStatusAssigned:
+		job.Status, job.RunnerID, job.StartedAt = models.WorkflowStatusInProgress, runnerJob.RunnerID, &now
+		if err := s.db.WithContext(ctx).Model(&job).Updates(map[string]interface{}{
+			"status": job.Status, "runner_id": job.RunnerID, "started_at": now,
+		}).Error; err != nil {
+			log.WithError(err).Warn("Failed to start workflow job")
+			return
+		}
+		s.db.WithContext(ctx).Model(&models.WorkflowRun{}).
+			Where("id = ? AND status = ?", run.ID, models.WorkflowStatusQueued).
+			Updates(map[string]interface{}{"status": models.WorkflowStatusInProgress, "started_at": now})
+		s.reportStatus(ctx, &run, &job)
+
+	case models.RunnerJobStatusCompleted, models.RunnerJobStatusFailed:
+		job.Status, job.Conclusion, job.CompletedAt = models.WorkflowStatusCompleted, models.WorkflowConclusionFailure, &now
+		if runnerJob.Status == models.RunnerJobStatusCompleted {
+			job.Conclusion = models.WorkflowConclusionSuccess
+		}
+		if err := s.db.WithContext(ctx).Model(&job).Updates(map[string]interface{}{
+			"status": job.Status, "conclusion": job.Conclusion, "completed_at": now,
+		}).Error; err != nil {
+			log.WithError(err).Warn("Failed to complete workflow job")
+			return
+		}
+		// Steps the runner never reported did not run
+		s.db.WithContext(ctx).Model(&models.WorkflowStep{}).
+			Where("job_id = ? AND status <> ?", job.ID, models.WorkflowStatusCompleted).
+			Updates(map[string]interface{}{"status": models.WorkflowStatusCompleted, "conclusion": models.WorkflowConclusionSkipped})
+		s.reportStatus(ctx, &run, &job)
+		s.advance(ctx, run.ID)
+	}
+}
+
+func (s *workflowService) ListRuns(ctx context.Context, repoID uuid.UUID, filter WorkflowRunFilter) ([]*models.WorkflowRun, error) {
+	query := s.db.WithContext(ctx).Where("repository_id = ?", repoID)
+	if filter.Branch != "" {
+		query = query.Where("head_branch = ?", filter.Branch)
+	}
+	if filter.Event != "" {
+		query = query.Where("event = ?", filter.Event)
+	}
+	if filter.Status != nil {
+		query = query.Where("status = ?", *filter.Status)
+	}
+
+	pageSize := 30
+	if filter.PageSize > 0 {
+		pageSize = filter.PageSize
+	}
+	offset := 0
+	if filter.Page > 1 {
+		offset = (filter.Page - 1) * pageSize
+	}
+
+	var runs []*models.WorkflowRun
+	if err := query.Order("run_number DESC").Limit(pageSize).Offset(offset).Find(&runs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list workflow runs: %w", err)
+	}
+	return runs, nil
+}
+
+func (s *workflowService) GetRun(ctx context.Context, repoID, runID uuid.UUID) (*models.WorkflowRun, error) {
+	var run models.WorkflowRun
+	if err := s.db.WithContext(ctx).Preload("Actor").
+		First(&run, "id = ? AND repository_id = ?", runID, repoID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWorkflowRunNotFound
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (s *workflowService) ListJobs(ctx context.Context, run *models.WorkflowRun) ([]*models.WorkflowJob, error) {
+	var jobs []*models.WorkflowJob
+	if err := s.db.WithContext(ctx).Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("number ASC")
+	}).Where("run_id = ?", run.ID).Order("created_at ASC").Find(&jobs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list workflow jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+func (s *workflowService) GetJob(ctx context.Context, repoID, jobID uuid.UUID) (*models.WorkflowJob, error) {
+	var job models.WorkflowJob
+	err := s.db.WithContext(ctx).Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("number ASC")
+	}).Joins("JOIN workflow_runs ON workflow_runs.id = workflow_jobs.run_id").
+		Where("workflow_jobs.id = ? AND workflow_runs.repository_id = ?", jobID, repoID).
+		First(&job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWorkflowJobNotFound
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// runningJob returns the workflow job of a runner job the runner is running
+func (s *workflowService) runningJob(ctx context.Context, runner *models.Runner, runnerJobID uuid.UUID) (*models.WorkflowJob, error) {
+	var job models.WorkflowJob
+	if err := s.db.WithContext(ctx).First(&job, "runner_job_id = ?", runnerJobID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWorkflowJobNotFound
+		}
+		return nil, err
+	}
+	if job.Status != models.WorkflowStatusInProgress || job.RunnerID == nil || *job.RunnerID != runner.ID {
+		return nil, ErrRunnerJobNotAssigned
+	}
+	return &job, nil
+}
+
+func (s *workflowService) UpdateStep(ctx context.Context, runner *models.Runner, runnerJobID uuid.UUID, req UpdateWorkflowStepRequest) (*models.WorkflowStep, error) {
+	job, err := s.runningJob(ctx, runner, runnerJobID)
+	if err != nil {
+		return nil, err
+	}
+
+	var step models.WorkflowStep
+	if err := s.db.WithContext(ctx).First(&step, "job_id = ? AND number = ?", job.ID, req.Number).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: job has no step %d", ErrInvalidWorkflowStep, req.Number)
+		}
+		return nil, err
+	}
+
+	now := s.now()
+	updates := map[string]interface{}{"status": req.Status}
+	switch req.Status {
+	case models.WorkflowStatusInProgress:
+		step.StartedAt = &now
+		updates["started_at"] = now
+	case models.WorkflowStatusCompleted:
+		switch req.Conclusion {
+		case models.WorkflowConclusionSuccess, models.WorkflowConclusionFailure, models.WorkflowConclusionCancelled, models.WorkflowConclusionSkipped:
+		default:
+			return nil, fmt.Errorf("%w: conclusion must be success, failure, cancelled or skipped", ErrInvalidWorkflowStep)
+		}
+		if step.StartedAt == nil {
+			step.StartedAt = &now
+			updates["started_at"] = now
+		}
+		step.Conclusion, step.CompletedAt = req.Conclusion, &now
+		updates["conclusion"], updates["completed_at"] = req.Conclusion, now
+	default:
+		return nil, fmt.Errorf("%w: status must be in_progress or completed", ErrInvalidWorkflowStep)
+	}
+	step.Status = req.Status
+
+	if err := s.db.WithContext(ctx).Model(&step).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to update workflow step: %w", err)
+	}
+	return &step, nil
+}
+
+func (s *workflowService) AppendLog(ctx context.Context, runner *models.Runner, runnerJobID uuid.UUID, output string) error {
+	job, err := s.runningJob(ctx, runner, runnerJobID)
+	if err != nil {
+		return err
+	}
+
+	room := maxWorkflowJobLog - len(job.Log)
+	if room <= 0 {
+		return nil
+	}
+	if len(output) > room {
+		output = output[:room]
+		if i := strings.LastIndexByte(output, '\n'); i >= 0 {
+			output = output[:i+1]
+		}
+	}
+	if err := s.db.WithContext(ctx).Model(job).Update("log", gorm.Expr("log || ?", output)).Error; err != nil {
+		return fmt.Errorf("failed to append workflow job log: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWorkflowGit struct {
+	git.GitService
+	files map[string]string
+}
+
+func (f fakeWorkflowGit) GetTree(ctx context.Context, repoPath, ref, path string) (*git.Tree, error) {
+	tree := &git.Tree{Path: path}
+	for filePath := range f.files {
+		if dir, name, _ := strings.Cut(filePath, "/workflows/"); dir+"/workflows" == path {
+			tree.Entries = append(tree.Entries, &git.TreeEntry{Name: name, Path: filePath, Type: "blob"})
+		}
+	}
+	return tree, nil
+}
+
+func (f fakeWorkflowGit) GetFile(ctx context.Context, repoPath, ref, path string) (*git.File, error) {
+	content, ok := f.files[path]
+	if !ok {
+		return nil, errors.New("file not found")
+	}
+	return &git.File{Path: path, Content: content}, nil
+}
+
+const testWorkflow = `
+name: CI
+on:
+  push:
+    branches: [main]
+  pull_request:
+jobs:
+  build:
+    runs-on: self-hosted
+    steps:
+      - run: make build
+  test:
+    runs-on: [self-hosted, linux]
+    needs: build
+    steps:
+      - run: make test
+      - run: make coverage
+  deploy:
+    runs-on: self-hosted
+    needs: [test]
+    steps:
+      - run: make deploy
+`
+
+func TestWorkflowService_RunLifecycle(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.User{}, &models.Repository{}, &models.PullRequest{}, &models.Runner{}, &models.RunnerJob{},
+		&models.CommitStatus{}, &models.CheckRun{}, &models.WorkflowRun{}, &models.WorkflowJob{}, &models.WorkflowStep{})
+
+	repo := &models.Repository{ID: uuid.New(), OwnerID: uuid.New(), OwnerType: models.OwnerTypeUser, Name: "app", DefaultBranch: "main", Visibility: models.VisibilityPrivate}
+	require.NoError(t, db.Create(repo).Error)
+	runner := &models.Runner{ID: uuid.New(), Name: "r1", Scope: models.RunnerScopeRepository, RepositoryID: &repo.ID, Labels: []string{"self-hosted", "linux"}, TokenHash: "r1"}
+	other := &models.Runner{ID: uuid.New(), Name: "r2", Scope: models.RunnerScopeRepository, RepositoryID: &repo.ID, Labels: []string{"self-hosted"}, TokenHash: "r2"}
+	require.NoError(t, db.Create([]*models.Runner{runner, other}).Error)
+
+	logger := logrus.New()
+	runners := NewRunnerService(db, logger)
+	statuses := NewCommitStatusService(db, logger)
+	gitFake := fakeWorkflowGit{files: map[string]string{
+		".hub/workflows/ci.yml":     testWorkflow,
+		".hub/workflows/broken.yml": "on: push\n",
+	}}
+	svc := NewWorkflowService(db, gitFake, fakeRepositoryPaths{}, runners, statuses, logger)
+	runners.Subscribe(svc.HandleRunnerJob)
+	ctx := context.Background()
+
+	sha := strings.Repeat("a", 40)
+	svc.HandlePush(ctx, PushEvent{Repository: repo, Updates: []RefUpdate{{Ref: "refs/heads/main", OldSHA: zeroSHA, NewSHA: sha}}})
+	svc.HandlePush(ctx, PushEvent{Repository: repo, Updates: []RefUpdate{{Ref: "refs/heads/feature", OldSHA: zeroSHA, NewSHA: sha}}})
+
+	runs, err := svc.ListRuns(ctx, repo.ID, WorkflowRunFilter{})
+	require.NoError(t, err)
+	require.Len(t, runs, 1, "only main pushes trigger the workflow and the invalid file is skipped")
+	run := runs[0]
+	assert.Equal(t, 1, run.RunNumber)
+	assert.Equal(t, WorkflowEventPush, run.Event)
+	assert.Equal(t, models.WorkflowStatusQueued, run.Status)
+
+	jobs, err := svc.ListJobs(ctx, run)
+	require.NoError(t, err)
+	require.Len(t, jobs, 3)
+	assert.Equal(t, []string{"build", "test", "deploy"}, []string{jobs[0].Key, jobs[1].Key, jobs[2].Key})
+	require.Len(t, jobs[1].Steps, 2)
+	assert.Equal(t, "Run make coverage", jobs[1].Steps[1].Name)
+	assert.NotNil(t, jobs[0].RunnerJobID, "jobs without needs are dispatched right away")
+	assert.Nil(t, jobs[1].RunnerJobID)
+
+	// build runs and succeeds
+	acquired, err := runners.AcquireJob(ctx, other)
+	require.NoError(t, err)
+	require.NotNil(t, acquired)
+	assert.Equal(t, *jobs[0].RunnerJobID, acquired.ID)
+	assert.Contains(t, acquired.Payload, `"run":"make build"`)
+
+	run, err = svc.GetRun(ctx, repo.ID, run.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.WorkflowStatusInProgress, run.Status)
+
+	_, err = svc.UpdateStep(ctx, runner, acquired.ID, UpdateWorkflowStepRequest{Number: 1, Status: models.WorkflowStatusInProgress})
+	assert.True(t, errors.Is(err, ErrRunnerJobNotAssigned), "only the runner running the job reports on it")
+	step, err := svc.UpdateStep(ctx, other, acquired.ID, UpdateWorkflowStepRequest{Number: 1, Status: models.WorkflowStatusCompleted, Conclusion: models.WorkflowConclusionSuccess})
+	require.NoError(t, err)
+	assert.NotNil(t, step.StartedAt)
+	_, err = svc.UpdateStep(ctx, other, acquired.ID, UpdateWorkflowStepRequest{Number: 2, Status: models.WorkflowStatusInProgress})
+	assert.True(t, errors.Is(err, ErrInvalidWorkflowStep))
+	require.NoError(t, svc.AppendLog(ctx, other, acquired.ID, "building\n"))
+	require.NoError(t, svc.AppendLog(ctx, other, acquired.ID, "done\n"))
+	_, err = runners.CompleteJob(ctx, other, acquired.ID, true)
+	require.NoError(t, err)
+
+	build, err := svc.GetJob(ctx, repo.ID, jobs[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.WorkflowConclusionSuccess, build.Conclusion)
+	assert.Equal(t, "building\ndone\n", build.Log)
+
+	// test needs a linux runner and fails, so deploy is skipped
+	acquired, err = runners.AcquireJob(ctx, other)
+	require.NoError(t, err)
+	assert.Nil(t, acquired)
+	acquired, err = runners.AcquireJob(ctx, runner)
+	require.NoError(t, err)
+	require.NotNil(t, acquired)
+	_, err = runners.CompleteJob(ctx, runner, acquired.ID, false)
+	require.NoError(t, err)
+
+	jobs, err = svc.ListJobs(ctx, run)
+	require.NoError(t, err)
+	assert.Equal(t, models.WorkflowConclusionFailure, jobs[1].Conclusion)
+	assert.Equal(t, models.WorkflowConclusionSkipped, jobs[1].Steps[0].Conclusion, "steps never reported did not run")
+	assert.Equal(t, models.WorkflowStatusCompleted, jobs[2].Status)
+	assert.Equal(t, models.WorkflowConclusionSkipped, jobs[2].Conclusion)
+	assert.Nil(t, jobs[2].RunnerJobID)
+
+	run, err = svc.GetRun(ctx, repo.ID, run.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.WorkflowStatusCompleted, run.Status)
+	assert.Equal(t, models.WorkflowConclusionFailure, run.Conclusion)
+
+	combined, err := statuses.Combined(ctx, repo.ID, sha)
+	require.NoError(t, err)
+	states := map[string]models.CommitStatusState{}
+	for _, status := range combined.Statuses {
+		states[status.Context] = status.State
+	}
+	assert.Equal(t, map[string]models.CommitStatusState{
+		"CI / build":  models.CommitStatusSuccess,
+		"CI / test":   models.CommitStatusFailure,
+		"CI / deploy": models.CommitStatusFailure,
+	}, states)
+
+	// Pushes to the head of an open pull request run pull_request workflows
+	pr := &models.PullRequest{ID: uuid.New(), RepositoryID: repo.ID, BaseRepositoryID: repo.ID, Number: 1, Title: "Feature",
+		BaseBranch: "main", HeadBranch: "feature", State: models.PullRequestStateOpen}
+	require.NoError(t, db.Create(pr).Error)
+	svc.HandlePush(ctx, PushEvent{Repository: repo, Updates: []RefUpdate{{Ref: "refs/heads/feature", OldSHA: sha, NewSHA: strings.Repeat("b", 40)}}})
+
+	runs, err = svc.ListRuns(ctx, repo.ID, WorkflowRunFilter{Event: WorkflowEventPullRequest})
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, 2, runs[0].RunNumber)
+	assert.Equal(t, &pr.ID, runs[0].PullRequestID)
+	assert.Equal(t, "feature", runs[0].HeadBranch)
+}
@@ -2,10 +2,16 @@ package ssh
 
 import (
 	"context"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/a5c-ai/hub/internal/auth"
+	"github.com/a5c-ai/hub/internal/git"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/a5c-ai/hub/internal/services"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // repositoryServiceAdapter adapts the existing repository service to the SSH interface
@@ -29,3 +35,75 @@ func (a *repositoryServiceAdapter) Get(ctx context.Context, owner, name string)
 func (a *repositoryServiceAdapter) GetRepositoryPath(ctx context.Context, repoID uuid.UUID) (string, error) {
 	return a.repoService.GetRepositoryPath(ctx, repoID)
 }
+
+// repositoryBrowserAdapter adapts the git and permission services to the SFTP browser interface
+type repositoryBrowserAdapter struct {
+	git.GitService
+	permissionService services.PermissionService
+}
+
+// NewRepositoryBrowserAdapter creates a new browser adapter
+func NewRepositoryBrowserAdapter(gitService git.GitService, permissionService services.PermissionService) RepositoryBrowser {
+	return &repositoryBrowserAdapter{
+		GitService:        gitService,
+		permissionService: permissionService,
+	}
+}
+
+// CanRead reports whether the user may read the repository
+func (a *repositoryBrowserAdapter) CanRead(ctx context.Context, userID uuid.UUID, repo *models.Repository) bool {
+	ok, err := a.permissionService.CheckRepositoryPermission(ctx, userID, repo.ID, models.PermissionRead)
+	return err == nil && ok
+}
+
+// lfsAuthenticatorAdapter issues JWTs for the Git LFS API to SSH users
+type lfsAuthenticatorAdapter struct {
+	db                *gorm.DB
+	jwtManager        *auth.JWTManager
+	permissionService services.PermissionService
+	baseURL           string
+	ttl               time.Duration
+}
+
+// NewLFSAuthenticatorAdapter creates a new LFS authenticator whose tokens
+// expire after ttl and point clients at the LFS API under baseURL
+func NewLFSAuthenticatorAdapter(db *gorm.DB, jwtManager *auth.JWTManager, permissionService services.PermissionService, baseURL string, ttl time.Duration) LFSAuthenticator {
+	return &lfsAuthenticatorAdapter{
+		db:                db,
+		jwtManager:        jwtManager,
+		permissionService: permissionService,
+		baseURL:           strings.TrimSuffix(baseURL, "/"),
+		ttl:               ttl,
+	}
+}
+
+// Authenticate checks the user may perform operation on the repository
+// and returns the LFS API location with a token valid for the ttl
+func (a *lfsAuthenticatorAdapter) Authenticate(ctx context.Context, userID uuid.UUID, owner string, repo *models.Repository, operation string) (*LFSAuthentication, error) {
+	required := models.PermissionRead
+	if operation == services.LFSOperationUpload {
+		required = models.PermissionWrite
+	}
+	ok, err := a.permissionService.CheckRepositoryPermission(ctx, userID, repo.ID, required)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("permission denied")
+	}
+
+	var user models.User
+	if err := a.db.WithContext(ctx).First(&user, "id = ?", userID).Error; err != nil {
+		return nil, fmt.Errorf("failed to load user: %w", err)
+	}
+	token, err := a.jwtManager.GenerateTokenWithTTL(&user, a.ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LFSAuthentication{
+		Href:      fmt.Sprintf("%s/%s/%s.git/info/lfs", a.baseURL, owner, repo.Name),
+		Header:    map[string]string{"Authorization": "Bearer " + token},
+		ExpiresIn: int(a.ttl.Seconds()),
+	}, nil
+}
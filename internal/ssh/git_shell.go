@@ -7,17 +7,21 @@ import (
 	"os"
 	"os/exec"
 
+	"github.com/a5c-ai/hub/internal/git"
 	"github.com/sirupsen/logrus"
 )
 
 // gitShellService implements GitShellService for handling git commands
 type gitShellService struct {
+	packs  *git.PackStore
 	logger *logrus.Logger
 }
 
-// NewGitShellService creates a new git shell service
-func NewGitShellService(logger *logrus.Logger) GitShellService {
+// NewGitShellService creates a new git shell service restoring offloaded
+// packs from packs, which may be nil
+func NewGitShellService(packs *git.PackStore, logger *logrus.Logger) GitShellService {
 	return &gitShellService{
+		packs:  packs,
 		logger: logger,
 	}
 }
@@ -40,6 +44,11 @@ func (g *gitShellService) HandleGitCommand(
 		return fmt.Errorf("repository not found: %s", repoPath)
 	}
 
+	// Restore offloaded packs before git reads the object database
+	if err := g.packs.Ensure(ctx, repoPath); err != nil {
+		return fmt.Errorf("failed to restore offloaded packs: %w", err)
+	}
+
 	// Prepare git command
 	var cmd *exec.Cmd
 	switch command {
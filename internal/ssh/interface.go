@@ -3,6 +3,7 @@ package ssh
 import (
 	"context"
 
+	"github.com/a5c-ai/hub/internal/git"
 	"github.com/a5c-ai/hub/internal/models"
 	"github.com/google/uuid"
 )
@@ -12,3 +13,25 @@ type RepositoryService interface {
 	Get(ctx context.Context, owner, name string) (*models.Repository, error)
 	GetRepositoryPath(ctx context.Context, repoID uuid.UUID) (string, error)
 }
+
+// RepositoryBrowser defines the read-only repository access needed by the SFTP subsystem
+type RepositoryBrowser interface {
+	CanRead(ctx context.Context, userID uuid.UUID, repo *models.Repository) bool
+	ResolveSHA(ctx context.Context, repoPath, ref string) (string, error)
+	GetTree(ctx context.Context, repoPath, ref, path string) (*git.Tree, error)
+	GetBlob(ctx context.Context, repoPath, sha string) (*git.Blob, error)
+}
+
+// LFSAuthentication is the git-lfs-authenticate response telling a Git LFS
+// client where the LFS API is and how to authenticate to it
+type LFSAuthentication struct {
+	Href      string            `json:"href"`
+	Header    map[string]string `json:"header"`
+	ExpiresIn int               `json:"expires_in"`
+}
+
+// LFSAuthenticator issues short-lived LFS API credentials to SSH users;
+// operation is "upload" or "download"
+type LFSAuthenticator interface {
+	Authenticate(ctx context.Context, userID uuid.UUID, owner string, repo *models.Repository, operation string) (*LFSAuthentication, error)
+}
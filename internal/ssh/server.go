@@ -6,6 +6,7 @@ import (
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"io"
@@ -14,10 +15,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/a5c-ai/hub/internal/errorreporting"
 	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/ssh"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // SSHServer represents the SSH server for git operations
@@ -26,10 +30,14 @@ type SSHServer struct {
 	listener          net.Listener
 	port              int
 	hostKeyPath       string
+	sharedHostKeys    bool
 	repositoryService RepositoryService
 	gitService        GitShellService
 	logger            *logrus.Logger
 	db                *gorm.DB
+	sftpEnabled       bool
+	browser           RepositoryBrowser
+	lfsAuthenticator  LFSAuthenticator
 }
 
 // GitShellService defines git shell operations
@@ -41,6 +49,10 @@ type GitShellService interface {
 type SSHServerConfig struct {
 	Port        int    `mapstructure:"port"`
 	HostKeyPath string `mapstructure:"host_key_path"`
+	SFTPEnabled bool   `mapstructure:"sftp_enabled"`
+	// SharedHostKeys keeps the host keys in the database, shared by every
+	// replica, instead of in the file at HostKeyPath
+	SharedHostKeys bool `mapstructure:"shared_host_keys"`
 }
 
 // NewSSHServer creates a new SSH server instance
@@ -54,10 +66,12 @@ func NewSSHServer(
 	server := &SSHServer{
 		port:              config.Port,
 		hostKeyPath:       config.HostKeyPath,
+		sharedHostKeys:    config.SharedHostKeys,
 		repositoryService: repositoryService,
 		gitService:        gitService,
 		logger:            logger,
 		db:                db,
+		sftpEnabled:       config.SFTPEnabled,
 	}
 
 	// Initialize SSH server config
@@ -68,6 +82,18 @@ func NewSSHServer(
 	return server, nil
 }
 
+// SetRepositoryBrowser provides the repository access used by the SFTP
+// subsystem; SFTP requests are refused until a browser is set
+func (s *SSHServer) SetRepositoryBrowser(browser RepositoryBrowser) {
+	s.browser = browser
+}
+
+// SetLFSAuthenticator enables git-lfs-authenticate, which hands Git LFS
+// clients credentials for the LFS API; it is refused until one is set
+func (s *SSHServer) SetLFSAuthenticator(authenticator LFSAuthenticator) {
+	s.lfsAuthenticator = authenticator
+}
+
 // initializeConfig sets up the SSH server configuration
 func (s *SSHServer) initializeConfig() error {
 	config := &ssh.ServerConfig{
@@ -75,6 +101,18 @@ func (s *SSHServer) initializeConfig() error {
 		ServerVersion:     "SSH-2.0-Hub-Git-Server",
 	}
 
+	if s.sharedHostKeys {
+		hostKeys, err := s.loadSharedHostKeys()
+		if err != nil {
+			return fmt.Errorf("failed to load shared host keys: %w", err)
+		}
+		for _, hostKey := range hostKeys {
+			config.AddHostKey(hostKey)
+		}
+		s.config = config
+		return nil
+	}
+
 	// Load or generate host key
 	hostKey, err := s.loadOrGenerateHostKey()
 	if err != nil {
@@ -111,27 +149,15 @@ func (s *SSHServer) loadOrGenerateHostKey() (ssh.Signer, error) {
 
 	// Generate new key
 	s.logger.Info("Generating new SSH host key")
-	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	keyPEM, err := generateHostKeyPEM()
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate private key: %w", err)
-	}
-
-	// Convert to PEM format
-	privateKeyPEM := &pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		return nil, err
 	}
 
 	// Save to file
-	keyFile, err := os.Create(s.hostKeyPath)
-	if err != nil {
+	if err := os.WriteFile(s.hostKeyPath, keyPEM, 0600); err != nil {
 		return nil, fmt.Errorf("failed to create host key file: %w", err)
 	}
-	defer keyFile.Close()
-
-	if err := pem.Encode(keyFile, privateKeyPEM); err != nil {
-		return nil, fmt.Errorf("failed to encode private key: %w", err)
-	}
 
 	// Set restrictive permissions
 	if err := os.Chmod(s.hostKeyPath, 0600); err != nil {
@@ -139,7 +165,7 @@ func (s *SSHServer) loadOrGenerateHostKey() (ssh.Signer, error) {
 	}
 
 	// Convert to SSH key
-	signer, err := ssh.NewSignerFromKey(privateKey)
+	signer, err := ssh.ParsePrivateKey(keyPEM)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create signer from private key: %w", err)
 	}
@@ -148,6 +174,60 @@ func (s *SSHServer) loadOrGenerateHostKey() (ssh.Signer, error) {
 	return signer, nil
 }
 
+// generateHostKeyPEM generates a new RSA host key in PEM format
+func generateHostKeyPEM() ([]byte, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+	}), nil
+}
+
+// loadSharedHostKeys loads the host keys kept in the database. The first
+// replica to start stores one: the key in the file at hostKeyPath when
+// there is one, so that clients keep trusting it, or a new key otherwise.
+// When replicas start together, the key stored first wins.
+func (s *SSHServer) loadSharedHostKeys() ([]ssh.Signer, error) {
+	var stored []models.SSHHostKey
+	if err := s.db.Order("algorithm").Find(&stored).Error; err != nil {
+		return nil, fmt.Errorf("failed to fetch host keys: %w", err)
+	}
+
+	if len(stored) == 0 {
+		keyPEM, err := os.ReadFile(s.hostKeyPath)
+		if err == nil {
+			s.logger.Info("Sharing the SSH host key from the host key file")
+		} else if keyPEM, err = generateHostKeyPEM(); err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse host key: %w", err)
+		}
+		key := &models.SSHHostKey{Algorithm: signer.PublicKey().Type(), PrivateKey: string(keyPEM)}
+		if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(key).Error; err != nil {
+			return nil, fmt.Errorf("failed to store host key: %w", err)
+		}
+		if err := s.db.Order("algorithm").Find(&stored).Error; err != nil {
+			return nil, fmt.Errorf("failed to fetch host keys: %w", err)
+		}
+	}
+
+	signers := make([]ssh.Signer, 0, len(stored))
+	for _, key := range stored {
+		signer, err := ssh.ParsePrivateKey([]byte(key.PrivateKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s host key: %w", key.Algorithm, err)
+		}
+		signers = append(signers, signer)
+	}
+	s.logger.WithField("keys", len(signers)).Info("Loaded shared SSH host keys")
+	return signers, nil
+}
+
 // authenticatePublicKey authenticates users by their SSH public keys
 func (s *SSHServer) authenticatePublicKey(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
 	username := conn.User()
@@ -253,6 +333,7 @@ func (s *SSHServer) Stop() error {
 
 // handleConnection handles an SSH connection
 func (s *SSHServer) handleConnection(ctx context.Context, netConn net.Conn) {
+	defer errorreporting.Default().Recover("ssh", map[string]string{"remote": netConn.RemoteAddr().String()})
 	defer netConn.Close()
 
 	// Perform SSH handshake
@@ -283,6 +364,11 @@ func (s *SSHServer) handleConnection(ctx context.Context, netConn net.Conn) {
 
 // handleChannel handles an SSH channel
 func (s *SSHServer) handleChannel(ctx context.Context, newChannel ssh.NewChannel, perms *ssh.Permissions) {
+	defer errorreporting.Default().Recover("ssh", map[string]string{
+		"user_id":  perms.Extensions["user_id"],
+		"username": perms.Extensions["username"],
+	})
+
 	if newChannel.ChannelType() != "session" {
 		newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
 		return
@@ -295,19 +381,52 @@ func (s *SSHServer) handleChannel(ctx context.Context, newChannel ssh.NewChannel
 	}
 	defer channel.Close()
 
-	// Handle channel requests
-	go func() {
-		for req := range requests {
-			switch req.Type {
-			case "exec":
-				s.handleExec(ctx, req, channel, perms)
-			default:
-				if req.WantReply {
-					req.Reply(false, nil)
-				}
+	// Handle channel requests until a command or subsystem has run
+	for req := range requests {
+		switch req.Type {
+		case "exec":
+			s.handleExec(ctx, req, channel, perms)
+			return
+		case "subsystem":
+			if s.handleSubsystem(ctx, req, channel, perms) {
+				return
+			}
+		default:
+			if req.WantReply {
+				req.Reply(false, nil)
 			}
 		}
-	}()
+	}
+}
+
+// handleSubsystem handles SSH subsystem requests; only a read-only sftp
+// subsystem is offered, and only when enabled. It reports whether the
+// subsystem ran.
+func (s *SSHServer) handleSubsystem(ctx context.Context, req *ssh.Request, channel ssh.Channel, perms *ssh.Permissions) bool {
+	var payload struct{ Name string }
+	if err := ssh.Unmarshal(req.Payload, &payload); err != nil || payload.Name != "sftp" || !s.sftpEnabled || s.browser == nil {
+		req.Reply(false, nil)
+		return false
+	}
+
+	userID, err := uuid.Parse(perms.Extensions["user_id"])
+	if err != nil {
+		req.Reply(false, nil)
+		return false
+	}
+	req.Reply(true, nil)
+
+	logger := s.logger.WithField("username", perms.Extensions["username"])
+	logger.Info("SFTP session started")
+
+	session := newSFTPSession(ctx, userID, s.repositoryService, s.browser, logger)
+	status := uint32(0)
+	if err := session.serve(channel); err != nil {
+		logger.WithError(err).Warn("SFTP session failed")
+		status = 1
+	}
+	channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{Status: status}))
+	return true
 }
 
 // handleExec handles SSH exec requests (git commands)
@@ -332,8 +451,11 @@ func (s *SSHServer) handleExec(ctx context.Context, req *ssh.Request, channel ss
 
 	req.Reply(true, nil)
 
-	// Execute git command
-	if err := s.executeGitCommand(ctx, command, channel, perms); err != nil {
+	execute := s.executeGitCommand
+	if strings.HasPrefix(command, lfsAuthenticateCommand+" ") {
+		execute = s.executeLFSAuthenticate
+	}
+	if err := execute(ctx, command, channel, perms); err != nil {
 		s.logger.WithError(err).Error("Failed to execute git command")
 		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{Status: 1}))
 	} else {
@@ -349,6 +471,10 @@ func (s *SSHServer) isValidGitCommand(command string) bool {
 	}
 
 	gitCommand := parts[0]
+	if gitCommand == lfsAuthenticateCommand {
+		return s.lfsAuthenticator != nil && len(parts) == 3 &&
+			(parts[2] == "upload" || parts[2] == "download")
+	}
 	return gitCommand == "git-upload-pack" || gitCommand == "git-receive-pack"
 }
 
@@ -360,7 +486,25 @@ func (s *SSHServer) executeGitCommand(ctx context.Context, command string, chann
 	}
 
 	gitCommand := parts[0]
-	repoPath := strings.Trim(parts[1], "'\"")
+	_, repo, err := s.resolveRepository(ctx, parts[1])
+	if err != nil {
+		return err
+	}
+
+	// Get repository filesystem path
+	actualRepoPath, err := s.repositoryService.GetRepositoryPath(ctx, repo.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get repository path: %w", err)
+	}
+
+	// Execute git command
+	return s.gitService.HandleGitCommand(ctx, gitCommand, actualRepoPath, channel, channel, channel)
+}
+
+// resolveRepository parses an owner/repo argument of a git command, which
+// may be quoted and have a leading slash and .git suffix
+func (s *SSHServer) resolveRepository(ctx context.Context, arg string) (string, *models.Repository, error) {
+	repoPath := strings.Trim(arg, "'\"")
 
 	// Remove leading slash and .git suffix
 	repoPath = strings.TrimPrefix(repoPath, "/")
@@ -369,24 +513,41 @@ func (s *SSHServer) executeGitCommand(ctx context.Context, command string, chann
 	// Parse owner/repo from path
 	pathParts := strings.Split(repoPath, "/")
 	if len(pathParts) != 2 {
-		return fmt.Errorf("invalid repository path format: %s", repoPath)
+		return "", nil, fmt.Errorf("invalid repository path format: %s", repoPath)
 	}
 
 	owner := pathParts[0]
 	repoName := pathParts[1]
 
-	// Get repository
 	repo, err := s.repositoryService.Get(ctx, owner, repoName)
 	if err != nil {
-		return fmt.Errorf("repository not found: %s/%s", owner, repoName)
+		return "", nil, fmt.Errorf("repository not found: %s/%s", owner, repoName)
 	}
+	return owner, repo, nil
+}
 
-	// Get repository filesystem path
-	actualRepoPath, err := s.repositoryService.GetRepositoryPath(ctx, repo.ID)
+// lfsAuthenticateCommand is run by Git LFS clients over SSH as
+// "git-lfs-authenticate <repo> <upload|download>" to get LFS API credentials
+const lfsAuthenticateCommand = "git-lfs-authenticate"
+
+// executeLFSAuthenticate writes the LFS API location and a short-lived
+// token for the repository as JSON
+func (s *SSHServer) executeLFSAuthenticate(ctx context.Context, command string, channel ssh.Channel, perms *ssh.Permissions) error {
+	parts := strings.Fields(command)
+	owner, repo, err := s.resolveRepository(ctx, parts[1])
 	if err != nil {
-		return fmt.Errorf("failed to get repository path: %w", err)
+		fmt.Fprintln(channel.Stderr(), err)
+		return err
 	}
 
-	// Execute git command
-	return s.gitService.HandleGitCommand(ctx, gitCommand, actualRepoPath, channel, channel, channel)
+	userID, err := uuid.Parse(perms.Extensions["user_id"])
+	if err != nil {
+		return fmt.Errorf("invalid user id: %w", err)
+	}
+	authentication, err := s.lfsAuthenticator.Authenticate(ctx, userID, owner, repo, parts[2])
+	if err != nil {
+		fmt.Fprintf(channel.Stderr(), "%s: %v\n", lfsAuthenticateCommand, err)
+		return err
+	}
+	return json.NewEncoder(channel).Encode(authentication)
 }
@@ -0,0 +1,72 @@
+package ssh
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/testutil"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// presentedHostKey returns the host key server presents in a handshake
+func presentedHostKey(t *testing.T, server *SSHServer) ssh.PublicKey {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		ssh.NewServerConn(conn, server.config)
+	}()
+
+	var hostKey ssh.PublicKey
+	ssh.Dial("tcp", listener.Addr().String(), &ssh.ClientConfig{
+		User:    "nobody",
+		Timeout: 5 * time.Second,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return nil
+		},
+	})
+	require.NotNil(t, hostKey)
+	return hostKey
+}
+
+func TestSSHServerSharedHostKeys(t *testing.T) {
+	db := testutil.NewTestDB(t, &models.SSHHostKey{})
+	dir := t.TempDir()
+	keyPEM, err := generateHostKeyPEM()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "existing"), keyPEM, 0600))
+	existing, err := ssh.ParsePrivateKey(keyPEM)
+	require.NoError(t, err)
+
+	// The first replica shares the key it already had, and replicas without
+	// a key file present it too
+	var replicas []*SSHServer
+	for _, path := range []string{"existing", "missing"} {
+		server, err := NewSSHServer(SSHServerConfig{HostKeyPath: filepath.Join(dir, path), SharedHostKeys: true}, nil, nil, logrus.New(), db)
+		require.NoError(t, err)
+		replicas = append(replicas, server)
+	}
+	for _, server := range replicas {
+		assert.True(t, bytes.Equal(existing.PublicKey().Marshal(), presentedHostKey(t, server).Marshal()))
+	}
+	_, err = os.Stat(filepath.Join(dir, "missing"))
+	assert.True(t, os.IsNotExist(err), "shared keys are not written to disk")
+
+	var stored int64
+	require.NoError(t, db.Model(&models.SSHHostKey{}).Count(&stored).Error)
+	assert.EqualValues(t, 1, stored)
+}
@@ -0,0 +1,579 @@
+package ssh
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// The SFTP subsystem exposes repositories as a read-only virtual filesystem
+// synthesized from git trees, so files can be fetched without cloning:
+//
+//	/<owner>/<repo>/<path>        the default branch
+//	/<owner>/<repo>@<ref>/<path>  any branch, tag or commit
+//
+// The root and owner directories exist but list no entries. Only protocol
+// version 3 (draft-ietf-secsh-filexfer-02) is spoken; every request that
+// would modify the filesystem is refused.
+
+// SFTP packet types
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpWrite    = 6
+	sftpLstat    = 7
+	sftpFstat    = 8
+	sftpSetstat  = 9
+	sftpFsetstat = 10
+	sftpOpendir  = 11
+	sftpReaddir  = 12
+	sftpRemove   = 13
+	sftpMkdir    = 14
+	sftpRmdir    = 15
+	sftpRealpath = 16
+	sftpStat     = 17
+	sftpRename   = 18
+	sftpReadlink = 19
+	sftpSymlink  = 20
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpName     = 104
+	sftpAttrs    = 105
+)
+
+// SFTP status codes
+const (
+	sftpOK               = 0
+	sftpEOF              = 1
+	sftpNoSuchFile       = 2
+	sftpPermissionDenied = 3
+	sftpFailure          = 4
+	sftpBadMessage       = 5
+	sftpOpUnsupported    = 8
+)
+
+const (
+	sftpProtocolVersion = 3
+	sftpMaxPacket       = 256 * 1024
+	sftpMaxRead         = 32 * 1024
+	sftpOpenWriteFlags  = 0x02 | 0x04 | 0x08 | 0x10 | 0x20 // WRITE, APPEND, CREAT, TRUNC, EXCL
+	sftpAttrSize        = 0x01
+	sftpAttrPermissions = 0x04
+
+	// Open files are served from memory, so a session may only hold so many
+	// handles and so much file content at once
+	sftpMaxHandles   = 64
+	sftpMaxOpenBytes = 64 * 1024 * 1024
+)
+
+var errSFTPNotFound = errors.New("no such file")
+
+// sftpFile describes an entry of the virtual filesystem
+type sftpFile struct {
+	name string
+	size int64
+	mode uint32
+	sha  string
+}
+
+func (f *sftpFile) isDir() bool {
+	return f.mode&0170000 == 0040000
+}
+
+// sftpTree is a repository pinned to the commit it resolved to when the
+// session first touched it, so a session sees a consistent snapshot
+type sftpTree struct {
+	repoPath string
+	sha      string
+}
+
+type sftpHandleState struct {
+	content []byte
+	dir     []*sftpFile
+	file    *sftpFile
+	listed  bool
+}
+
+// sftpSession serves one SFTP subsystem channel
+type sftpSession struct {
+	ctx          context.Context
+	userID       uuid.UUID
+	repositories RepositoryService
+	browser      RepositoryBrowser
+	logger       *logrus.Entry
+
+	trees      map[string]*sftpTree
+	handles    map[string]*sftpHandleState
+	nextHandle int
+	// openBytes is the file content held by open handles
+	openBytes    int64
+	maxHandles   int
+	maxOpenBytes int64
+}
+
+func newSFTPSession(ctx context.Context, userID uuid.UUID, repositories RepositoryService, browser RepositoryBrowser, logger *logrus.Entry) *sftpSession {
+	return &sftpSession{
+		ctx:          ctx,
+		userID:       userID,
+		repositories: repositories,
+		browser:      browser,
+		logger:       logger,
+		trees:        make(map[string]*sftpTree),
+		handles:      make(map[string]*sftpHandleState),
+		maxHandles:   sftpMaxHandles,
+		maxOpenBytes: sftpMaxOpenBytes,
+	}
+}
+
+// serve reads requests from rw until the client closes the channel
+func (s *sftpSession) serve(rw io.ReadWriter) error {
+	for {
+		packet, err := readSFTPPacket(rw)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if _, err := rw.Write(s.handle(packet)); err != nil {
+			return err
+		}
+	}
+}
+
+func readSFTPPacket(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length == 0 || length > sftpMaxPacket {
+		return nil, fmt.Errorf("invalid sftp packet length %d", length)
+	}
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return nil, err
+	}
+	return packet, nil
+}
+
+// handle answers a single request packet
+func (s *sftpSession) handle(packet []byte) []byte {
+	r := &sftpReader{buf: packet[1:]}
+	kind := packet[0]
+
+	if kind == sftpInit {
+		return newSFTPPacket(sftpVersion).uint32(sftpProtocolVersion).bytes()
+	}
+
+	id := r.uint32()
+	if r.err != nil {
+		return statusPacket(id, sftpBadMessage, "malformed request")
+	}
+
+	switch kind {
+	case sftpRealpath:
+		p := r.string()
+		if r.err != nil {
+			return statusPacket(id, sftpBadMessage, "malformed request")
+		}
+		return newSFTPPacket(sftpName).uint32(id).uint32(1).
+			string(cleanSFTPPath(p)).string(cleanSFTPPath(p)).attrs(&sftpFile{mode: 0040555}).bytes()
+
+	case sftpStat, sftpLstat:
+		p := r.string()
+		if r.err != nil {
+			return statusPacket(id, sftpBadMessage, "malformed request")
+		}
+		file, err := s.stat(cleanSFTPPath(p))
+		if err != nil {
+			return errorPacket(id, err)
+		}
+		return newSFTPPacket(sftpAttrs).uint32(id).attrs(file).bytes()
+
+	case sftpFstat:
+		h, status := s.lookupHandle(id, r)
+		if h == nil {
+			return status
+		}
+		return newSFTPPacket(sftpAttrs).uint32(id).attrs(h.file).bytes()
+
+	case sftpOpen:
+		p := r.string()
+		flags := r.uint32()
+		if r.err != nil {
+			return statusPacket(id, sftpBadMessage, "malformed request")
+		}
+		if flags&sftpOpenWriteFlags != 0 {
+			return statusPacket(id, sftpPermissionDenied, "read-only filesystem")
+		}
+		return s.open(id, cleanSFTPPath(p))
+
+	case sftpOpendir:
+		p := r.string()
+		if r.err != nil {
+			return statusPacket(id, sftpBadMessage, "malformed request")
+		}
+		return s.opendir(id, cleanSFTPPath(p))
+
+	case sftpRead:
+		h, status := s.lookupHandle(id, r)
+		if h == nil {
+			return status
+		}
+		offset := r.uint64()
+		length := r.uint32()
+		if r.err != nil {
+			return statusPacket(id, sftpBadMessage, "malformed request")
+		}
+		if h.file.isDir() {
+			return statusPacket(id, sftpFailure, "is a directory")
+		}
+		if offset >= uint64(len(h.content)) {
+			return statusPacket(id, sftpEOF, "end of file")
+		}
+		if length > sftpMaxRead {
+			length = sftpMaxRead
+		}
+		end := offset + uint64(length)
+		if end > uint64(len(h.content)) {
+			end = uint64(len(h.content))
+		}
+		return newSFTPPacket(sftpData).uint32(id).string(string(h.content[offset:end])).bytes()
+
+	case sftpReaddir:
+		h, status := s.lookupHandle(id, r)
+		if h == nil {
+			return status
+		}
+		if !h.file.isDir() {
+			return statusPacket(id, sftpFailure, "not a directory")
+		}
+		if h.listed || len(h.dir) == 0 {
+			return statusPacket(id, sftpEOF, "end of directory")
+		}
+		h.listed = true
+		p := newSFTPPacket(sftpName).uint32(id).uint32(uint32(len(h.dir)))
+		for _, entry := range h.dir {
+			p.string(entry.name).string(longName(entry)).attrs(entry)
+		}
+		return p.bytes()
+
+	case sftpClose:
+		handle := r.string()
+		if r.err != nil {
+			return statusPacket(id, sftpBadMessage, "malformed request")
+		}
+		h, ok := s.handles[handle]
+		if !ok {
+			return statusPacket(id, sftpFailure, "invalid handle")
+		}
+		s.openBytes -= int64(len(h.content))
+		delete(s.handles, handle)
+		return statusPacket(id, sftpOK, "")
+
+	case sftpWrite, sftpSetstat, sftpFsetstat, sftpRemove, sftpMkdir, sftpRmdir, sftpRename, sftpSymlink:
+		return statusPacket(id, sftpPermissionDenied, "read-only filesystem")
+
+	default:
+		return statusPacket(id, sftpOpUnsupported, "operation not supported")
+	}
+}
+
+func (s *sftpSession) lookupHandle(id uint32, r *sftpReader) (*sftpHandleState, []byte) {
+	handle := r.string()
+	if r.err != nil {
+		return nil, statusPacket(id, sftpBadMessage, "malformed request")
+	}
+	h, ok := s.handles[handle]
+	if !ok {
+		return nil, statusPacket(id, sftpFailure, "invalid handle")
+	}
+	return h, nil
+}
+
+func (s *sftpSession) addHandle(h *sftpHandleState) string {
+	s.nextHandle++
+	handle := strconv.Itoa(s.nextHandle)
+	s.handles[handle] = h
+	s.openBytes += int64(len(h.content))
+	return handle
+}
+
+func (s *sftpSession) open(id uint32, p string) []byte {
+	file, err := s.stat(p)
+	if err != nil {
+		return errorPacket(id, err)
+	}
+	if file.isDir() {
+		return statusPacket(id, sftpFailure, "is a directory")
+	}
+	if len(s.handles) >= s.maxHandles {
+		return statusPacket(id, sftpFailure, "too many open handles")
+	}
+	if file.size > s.maxOpenBytes-s.openBytes {
+		return statusPacket(id, sftpFailure, "file too large; close other files or clone the repository")
+	}
+
+	tree, _, err := s.resolve(p)
+	if err != nil {
+		return errorPacket(id, err)
+	}
+	blob, err := s.browser.GetBlob(s.ctx, tree.repoPath, file.sha)
+	if err != nil {
+		s.logger.WithError(err).WithField("path", p).Warn("Failed to read blob over SFTP")
+		return statusPacket(id, sftpFailure, "failed to read file")
+	}
+	// The tree entry size is only a hint; the budget holds for the content read
+	if int64(len(blob.Content)) > s.maxOpenBytes-s.openBytes {
+		return statusPacket(id, sftpFailure, "file too large; close other files or clone the repository")
+	}
+
+	handle := s.addHandle(&sftpHandleState{content: blob.Content, file: file})
+	return newSFTPPacket(sftpHandle).uint32(id).string(handle).bytes()
+}
+
+func (s *sftpSession) opendir(id uint32, p string) []byte {
+	file, err := s.stat(p)
+	if err != nil {
+		return errorPacket(id, err)
+	}
+	if !file.isDir() {
+		return statusPacket(id, sftpFailure, "not a directory")
+	}
+	if len(s.handles) >= s.maxHandles {
+		return statusPacket(id, sftpFailure, "too many open handles")
+	}
+
+	entries, err := s.readDir(p)
+	if err != nil {
+		return errorPacket(id, err)
+	}
+	handle := s.addHandle(&sftpHandleState{dir: entries, file: file})
+	return newSFTPPacket(sftpHandle).uint32(id).string(handle).bytes()
+}
+
+// stat describes the file at an absolute, cleaned path
+func (s *sftpSession) stat(p string) (*sftpFile, error) {
+	parts := splitSFTPPath(p)
+	if len(parts) < 2 {
+		name := "/"
+		if len(parts) == 1 {
+			name = parts[0]
+		}
+		return &sftpFile{name: name, mode: 0040555}, nil
+	}
+
+	tree, inner, err := s.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	if inner == "" {
+		return &sftpFile{name: parts[1], mode: 0040555}, nil
+	}
+
+	dir, name := path.Split(inner)
+	listing, err := s.browser.GetTree(s.ctx, tree.repoPath, tree.sha, strings.TrimSuffix(dir, "/"))
+	if err != nil {
+		return nil, errSFTPNotFound
+	}
+	for _, entry := range listing.Entries {
+		if entry.Name == name {
+			return treeEntryFile(entry), nil
+		}
+	}
+	return nil, errSFTPNotFound
+}
+
+// readDir lists the directory at an absolute, cleaned path
+func (s *sftpSession) readDir(p string) ([]*sftpFile, error) {
+	if len(splitSFTPPath(p)) < 2 {
+		return nil, nil
+	}
+
+	tree, inner, err := s.resolve(p)
+	if err != nil {
+		return nil, err
+	}
+	listing, err := s.browser.GetTree(s.ctx, tree.repoPath, tree.sha, inner)
+	if err != nil {
+		// Submodules appear as directories but have no tree in this repository
+		return nil, nil
+	}
+
+	entries := make([]*sftpFile, 0, len(listing.Entries))
+	for _, entry := range listing.Entries {
+		entries = append(entries, treeEntryFile(entry))
+	}
+	return entries, nil
+}
+
+// resolve maps a path below /<owner>/<repo>[@ref] to its repository snapshot
+// and the path inside the tree. Repositories the user cannot read are
+// reported as missing so their existence is not disclosed.
+func (s *sftpSession) resolve(p string) (*sftpTree, string, error) {
+	parts := splitSFTPPath(p)
+	owner, name := parts[0], parts[1]
+	inner := strings.Join(parts[2:], "/")
+
+	key := owner + "/" + name
+	if tree, ok := s.trees[key]; ok {
+		return tree, inner, nil
+	}
+
+	repoName, ref, _ := strings.Cut(name, "@")
+	repo, err := s.repositories.Get(s.ctx, owner, strings.TrimSuffix(repoName, ".git"))
+	if err != nil || !s.browser.CanRead(s.ctx, s.userID, repo) {
+		return nil, "", errSFTPNotFound
+	}
+	if ref == "" {
+		ref = repo.DefaultBranch
+	}
+
+	repoPath, err := s.repositories.GetRepositoryPath(s.ctx, repo.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	sha, err := s.browser.ResolveSHA(s.ctx, repoPath, ref)
+	if err != nil {
+		return nil, "", errSFTPNotFound
+	}
+
+	tree := &sftpTree{repoPath: repoPath, sha: sha}
+	s.trees[key] = tree
+	return tree, inner, nil
+}
+
+func treeEntryFile(entry *git.TreeEntry) *sftpFile {
+	file := &sftpFile{name: entry.Name, size: entry.Size, sha: entry.SHA, mode: 0100444}
+	switch {
+	case entry.Type == "tree" || entry.Type == "commit":
+		file.mode = 0040555
+		file.size = 0
+	case entry.Mode == "0100755":
+		file.mode = 0100555
+	}
+	return file
+}
+
+func cleanSFTPPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+func splitSFTPPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// longName formats an entry the way `ls -l` would, as clients display it verbatim
+func longName(f *sftpFile) string {
+	return fmt.Sprintf("%s    1 git      git      %8d Jan  1  1970 %s", os.FileMode(f.mode&0777|modeDirBit(f)).String(), f.size, f.name)
+}
+
+func modeDirBit(f *sftpFile) uint32 {
+	if f.isDir() {
+		return uint32(os.ModeDir)
+	}
+	return 0
+}
+
+func errorPacket(id uint32, err error) []byte {
+	if errors.Is(err, errSFTPNotFound) {
+		return statusPacket(id, sftpNoSuchFile, "no such file")
+	}
+	return statusPacket(id, sftpFailure, "failure")
+}
+
+func statusPacket(id uint32, code uint32, message string) []byte {
+	return newSFTPPacket(sftpStatus).uint32(id).uint32(code).string(message).string("").bytes()
+}
+
+// sftpPacket builds a length-prefixed response packet
+type sftpPacket struct {
+	buf []byte
+}
+
+func newSFTPPacket(kind byte) *sftpPacket {
+	return &sftpPacket{buf: []byte{0, 0, 0, 0, kind}}
+}
+
+func (p *sftpPacket) uint32(v uint32) *sftpPacket {
+	p.buf = binary.BigEndian.AppendUint32(p.buf, v)
+	return p
+}
+
+func (p *sftpPacket) uint64(v uint64) *sftpPacket {
+	p.buf = binary.BigEndian.AppendUint64(p.buf, v)
+	return p
+}
+
+func (p *sftpPacket) string(v string) *sftpPacket {
+	p.uint32(uint32(len(v)))
+	p.buf = append(p.buf, v...)
+	return p
+}
+
+func (p *sftpPacket) attrs(f *sftpFile) *sftpPacket {
+	return p.uint32(sftpAttrSize | sftpAttrPermissions).uint64(uint64(f.size)).uint32(f.mode)
+}
+
+func (p *sftpPacket) bytes() []byte {
+	binary.BigEndian.PutUint32(p.buf, uint32(len(p.buf)-4))
+	return p.buf
+}
+
+// sftpReader decodes request fields, remembering the first error
+type sftpReader struct {
+	buf []byte
+	err error
+}
+
+func (r *sftpReader) take(n int) []byte {
+	if r.err != nil || len(r.buf) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *sftpReader) uint32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *sftpReader) uint64() uint64 {
+	if b := r.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *sftpReader) string() string {
+	n := r.uint32()
+	if n > uint32(len(r.buf)) {
+		r.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	return string(r.take(int(n)))
+}
@@ -0,0 +1,177 @@
+package ssh
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRepositories struct {
+	repos map[string]*models.Repository
+}
+
+func (f *fakeRepositories) Get(ctx context.Context, owner, name string) (*models.Repository, error) {
+	if repo, ok := f.repos[owner+"/"+name]; ok {
+		return repo, nil
+	}
+	return nil, errors.New("repository not found")
+}
+
+func (f *fakeRepositories) GetRepositoryPath(ctx context.Context, repoID uuid.UUID) (string, error) {
+	return "/repos/" + repoID.String(), nil
+}
+
+type fakeBrowser struct {
+	readable map[uuid.UUID]bool
+	trees    map[string][]*git.TreeEntry
+	blobs    map[string][]byte
+	refs     []string
+}
+
+func (f *fakeBrowser) CanRead(ctx context.Context, userID uuid.UUID, repo *models.Repository) bool {
+	return f.readable[repo.ID]
+}
+
+func (f *fakeBrowser) ResolveSHA(ctx context.Context, repoPath, ref string) (string, error) {
+	f.refs = append(f.refs, ref)
+	return "c0ffee", nil
+}
+
+func (f *fakeBrowser) GetTree(ctx context.Context, repoPath, ref, path string) (*git.Tree, error) {
+	entries, ok := f.trees[path]
+	if !ok {
+		return nil, errors.New("tree not found")
+	}
+	return &git.Tree{Path: path, Entries: entries}, nil
+}
+
+func (f *fakeBrowser) GetBlob(ctx context.Context, repoPath, sha string) (*git.Blob, error) {
+	return &git.Blob{SHA: sha, Content: f.blobs[sha]}, nil
+}
+
+func newTestSFTPSession(t *testing.T) (*sftpSession, *fakeBrowser) {
+	t.Helper()
+	public := &models.Repository{ID: uuid.New(), DefaultBranch: "main"}
+	private := &models.Repository{ID: uuid.New(), DefaultBranch: "main"}
+	browser := &fakeBrowser{
+		readable: map[uuid.UUID]bool{public.ID: true},
+		trees: map[string][]*git.TreeEntry{
+			"":     {{Name: "docs", Type: "tree"}, {Name: "README.md", Type: "blob", SHA: "readme", Size: 11, Mode: "0100644"}},
+			"docs": {{Name: "guide.md", Type: "blob", SHA: "guide", Size: 5, Mode: "0100644"}},
+		},
+		blobs: map[string][]byte{"readme": []byte("hello world"), "guide": []byte("guide")},
+	}
+	repos := &fakeRepositories{repos: map[string]*models.Repository{"acme/site": public, "acme/secret": private}}
+	logger := logrus.New()
+	return newSFTPSession(context.Background(), uuid.New(), repos, browser, logrus.NewEntry(logger)), browser
+}
+
+func request(kind byte, id uint32, fields ...interface{}) []byte {
+	p := newSFTPPacket(kind).uint32(id)
+	for _, f := range fields {
+		switch v := f.(type) {
+		case string:
+			p.string(v)
+		case uint32:
+			p.uint32(v)
+		case uint64:
+			p.uint64(v)
+		}
+	}
+	return p.bytes()[4:]
+}
+
+func responseType(resp []byte) byte {
+	return resp[4]
+}
+
+func responseStatus(t *testing.T, resp []byte) uint32 {
+	t.Helper()
+	require.Equal(t, byte(sftpStatus), responseType(resp))
+	return binary.BigEndian.Uint32(resp[9:13])
+}
+
+func responseHandle(t *testing.T, resp []byte) string {
+	t.Helper()
+	require.Equal(t, byte(sftpHandle), responseType(resp))
+	r := &sftpReader{buf: resp[9:]}
+	return r.string()
+}
+
+func TestSFTPSessionReadsFiles(t *testing.T) {
+	s, browser := newTestSFTPSession(t)
+
+	version := s.handle([]byte{sftpInit, 0, 0, 0, 3})
+	assert.Equal(t, byte(sftpVersion), responseType(version))
+
+	resp := s.handle(request(sftpStat, 1, "/acme/site/README.md"))
+	require.Equal(t, byte(sftpAttrs), responseType(resp))
+	r := &sftpReader{buf: resp[9:]}
+	r.uint32()
+	assert.Equal(t, uint64(11), r.uint64())
+	assert.Equal(t, uint32(0100444), r.uint32())
+
+	handle := responseHandle(t, s.handle(request(sftpOpen, 2, "/acme/site@v1.0/README.md", uint32(0x01), uint32(0))))
+	resp = s.handle(request(sftpRead, 3, handle, uint64(6), uint32(100)))
+	require.Equal(t, byte(sftpData), responseType(resp))
+	r = &sftpReader{buf: resp[9:]}
+	assert.Equal(t, "world", r.string())
+	assert.Equal(t, uint32(sftpEOF), responseStatus(t, s.handle(request(sftpRead, 4, handle, uint64(11), uint32(100)))))
+	assert.Equal(t, uint32(sftpOK), responseStatus(t, s.handle(request(sftpClose, 5, handle))))
+	assert.Equal(t, []string{"main", "v1.0"}, browser.refs)
+}
+
+func TestSFTPSessionListsDirectories(t *testing.T) {
+	s, _ := newTestSFTPSession(t)
+
+	handle := responseHandle(t, s.handle(request(sftpOpendir, 1, "/acme/site/docs")))
+	resp := s.handle(request(sftpReaddir, 2, handle))
+	require.Equal(t, byte(sftpName), responseType(resp))
+	r := &sftpReader{buf: resp[9:]}
+	require.Equal(t, uint32(1), r.uint32())
+	assert.Equal(t, "guide.md", r.string())
+	assert.Equal(t, uint32(sftpEOF), responseStatus(t, s.handle(request(sftpReaddir, 3, handle))))
+
+	resp = s.handle(request(sftpRealpath, 4, "acme/site/docs/.."))
+	r = &sftpReader{buf: resp[9:]}
+	r.uint32()
+	assert.Equal(t, "/acme/site", r.string())
+}
+
+func TestSFTPSessionIsReadOnlyAndHidesUnreadableRepositories(t *testing.T) {
+	s, _ := newTestSFTPSession(t)
+
+	assert.Equal(t, uint32(sftpPermissionDenied), responseStatus(t, s.handle(request(sftpOpen, 1, "/acme/site/README.md", uint32(0x02|0x08), uint32(0)))))
+	assert.Equal(t, uint32(sftpPermissionDenied), responseStatus(t, s.handle(request(sftpRemove, 2, "/acme/site/README.md"))))
+	assert.Equal(t, uint32(sftpNoSuchFile), responseStatus(t, s.handle(request(sftpStat, 3, "/acme/secret/README.md"))))
+	assert.Equal(t, uint32(sftpNoSuchFile), responseStatus(t, s.handle(request(sftpStat, 4, "/acme/missing"))))
+	assert.Equal(t, uint32(sftpNoSuchFile), responseStatus(t, s.handle(request(sftpStat, 5, "/acme/site/nope.txt"))))
+}
+
+func TestSFTPSessionLimitsOpenHandles(t *testing.T) {
+	s, _ := newTestSFTPSession(t)
+	s.maxHandles = 2
+	s.maxOpenBytes = 16
+
+	// A second copy of README.md (11 bytes) exceeds the budget, the guide (5) does not
+	readme := responseHandle(t, s.handle(request(sftpOpen, 1, "/acme/site/README.md", uint32(0x01), uint32(0))))
+	assert.Equal(t, uint32(sftpFailure), responseStatus(t, s.handle(request(sftpOpen, 2, "/acme/site/README.md", uint32(0x01), uint32(0)))))
+	guide := responseHandle(t, s.handle(request(sftpOpen, 3, "/acme/site/docs/guide.md", uint32(0x01), uint32(0))))
+
+	// Both handles are in use
+	assert.Equal(t, uint32(sftpFailure), responseStatus(t, s.handle(request(sftpOpendir, 4, "/acme/site/docs"))))
+
+	// Closing a file releases its handle and its content
+	assert.Equal(t, uint32(sftpOK), responseStatus(t, s.handle(request(sftpClose, 5, readme))))
+	assert.Equal(t, uint32(sftpOK), responseStatus(t, s.handle(request(sftpClose, 6, guide))))
+	assert.Zero(t, s.openBytes)
+	responseHandle(t, s.handle(request(sftpOpen, 7, "/acme/site/README.md", uint32(0x01), uint32(0))))
+}
@@ -0,0 +1,112 @@
+// Package tenant carries the request-scoped tenant information (instance,
+// authenticated user, organization, repository and effective permission)
+// resolved once by middleware and consumed by handlers and services.
+package tenant
+
+import (
+	"context"
+	"strings"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+)
+
+type contextKey struct{}
+
+// GinKey is the key the tenant context is stored under in gin.Context
+const GinKey = "tenant"
+
+// Context is the resolved tenant for a single request
+type Context struct {
+	// Instance identifies the hub installation serving the request
+	Instance string
+
+	// ClientIP and UserAgent describe the caller, for audit entries
+	ClientIP  string
+	UserAgent string
+
+	UserID   *uuid.UUID
+	Username string
+	IsAdmin  bool
+
+	Owner        *models.OwnerEntity
+	Organization *models.Organization
+	Repository   *models.Repository
+
+	// Permission is the authenticated user's effective permission on Repository.
+	// It is empty when the request has no repository or the user has no access.
+	Permission models.Permission
+}
+
+// NewContext returns a copy of ctx carrying t
+func NewContext(ctx context.Context, t *Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the tenant carried by ctx, if any
+func FromContext(ctx context.Context) (*Context, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	t, ok := ctx.Value(contextKey{}).(*Context)
+	return t, ok && t != nil
+}
+
+// MatchesRepository reports whether the resolved repository is owner/name
+func (t *Context) MatchesRepository(owner, name string) bool {
+	if t.Repository == nil || t.Owner == nil {
+		return false
+	}
+	return strings.EqualFold(t.Owner.Username, owner) && strings.EqualFold(t.Repository.Name, name)
+}
+
+// MatchesOrganization reports whether the resolved organization is name
+func (t *Context) MatchesOrganization(name string) bool {
+	return t.Organization != nil && t.Organization.Name == name
+}
+
+// RefreshRepository replaces the resolved repository with repo after a
+// change during the request, such as a rename or transfer, so lookups by
+// its old name or owner no longer find it. Other repositories are ignored.
+func (t *Context) RefreshRepository(repo *models.Repository) {
+	if t.Repository == nil || repo == nil || t.Repository.ID != repo.ID {
+		return
+	}
+	refreshed := *repo
+	t.Repository = &refreshed
+	t.Owner = refreshed.Owner
+}
+
+// RefreshOrganization replaces the resolved organization with org after a
+// change during the request. Other organizations are ignored.
+func (t *Context) RefreshOrganization(org *models.Organization) {
+	if t.Organization == nil || org == nil || t.Organization.ID != org.ID {
+		return
+	}
+	refreshed := *org
+	t.Organization = &refreshed
+}
+
+// IsAuthenticated reports whether the request carries a valid user
+func (t *Context) IsAuthenticated() bool {
+	return t.UserID != nil
+}
+
+var permissionLevels = map[models.Permission]int{
+	models.PermissionRead:     1,
+	models.PermissionTriage:   2,
+	models.PermissionWrite:    3,
+	models.PermissionMaintain: 4,
+	models.PermissionAdmin:    5,
+}
+
+// HasPermission reports whether the effective permission satisfies required
+func (t *Context) HasPermission(required models.Permission) bool {
+	if t.IsAdmin {
+		return true
+	}
+	if t.Permission == "" {
+		return false
+	}
+	return permissionLevels[t.Permission] >= permissionLevels[required]
+}
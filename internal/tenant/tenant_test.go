@@ -0,0 +1,60 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextRoundTrip(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	tc := &Context{
+		Owner:      &models.OwnerEntity{Username: "Acme"},
+		Repository: &models.Repository{Name: "api"},
+	}
+	got, ok := FromContext(NewContext(context.Background(), tc))
+	assert.True(t, ok)
+	assert.Same(t, tc, got)
+	assert.True(t, got.MatchesRepository("acme", "api"))
+	assert.True(t, got.MatchesRepository("ACME", "API"))
+	assert.False(t, got.MatchesRepository("acme", "web"))
+	assert.False(t, got.MatchesOrganization("acme"))
+}
+
+func TestHasPermission(t *testing.T) {
+	tc := &Context{Permission: models.PermissionWrite}
+	assert.True(t, tc.HasPermission(models.PermissionRead))
+	assert.True(t, tc.HasPermission(models.PermissionWrite))
+	assert.False(t, tc.HasPermission(models.PermissionAdmin))
+
+	assert.False(t, (&Context{}).HasPermission(models.PermissionRead))
+	assert.True(t, (&Context{IsAdmin: true}).HasPermission(models.PermissionAdmin))
+}
+
+func TestRefresh(t *testing.T) {
+	repoID, orgID := uuid.New(), uuid.New()
+	tc := &Context{
+		Owner:        &models.OwnerEntity{Username: "acme"},
+		Repository:   &models.Repository{ID: repoID, Name: "api"},
+		Organization: &models.Organization{ID: orgID, Name: "acme"},
+	}
+
+	tc.RefreshRepository(&models.Repository{ID: uuid.New(), Name: "other"})
+	assert.True(t, tc.MatchesRepository("acme", "api"), "other repositories are ignored")
+
+	// A rename followed by a transfer
+	tc.RefreshRepository(&models.Repository{ID: repoID, Name: "gateway", Owner: &models.OwnerEntity{Username: "acme"}})
+	assert.False(t, tc.MatchesRepository("acme", "api"))
+	assert.True(t, tc.MatchesRepository("acme", "gateway"))
+	tc.RefreshRepository(&models.Repository{ID: repoID, Name: "gateway", Owner: &models.OwnerEntity{Username: "globex"}})
+	assert.False(t, tc.MatchesRepository("acme", "gateway"))
+	assert.True(t, tc.MatchesRepository("globex", "gateway"))
+
+	tc.RefreshOrganization(&models.Organization{ID: orgID, Name: "acme", DisplayName: "Acme Inc"})
+	assert.Equal(t, "Acme Inc", tc.Organization.DisplayName)
+}
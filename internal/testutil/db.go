@@ -1,19 +1,90 @@
 package testutil
 
 import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
 	"testing"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-// NewTestDB returns a new in-memory SQLite database for testing.
-func NewTestDB(t *testing.T) *gorm.DB {
-	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{
+// PostgresDSNEnv names the environment variable pointing tests at a postgres
+// server instead of SQLite, e.g. one started by CI or docker compose
+const PostgresDSNEnv = "HUB_TEST_POSTGRES_DSN"
+
+// NewTestDB returns an empty database private to the test and migrates the
+// given models into it. It is an in-memory SQLite database unless
+// HUB_TEST_POSTGRES_DSN is set, in which case a throwaway schema is created
+// on that server and dropped when the test ends.
+func NewTestDB(t testing.TB, models ...interface{}) *gorm.DB {
+	t.Helper()
+
+	var db *gorm.DB
+	if dsn := os.Getenv(PostgresDSNEnv); dsn != "" {
+		db = newPostgresDB(t, dsn)
+	} else {
+		db = newSQLiteDB(t)
+	}
+	if len(models) > 0 {
+		require.NoError(t, db.AutoMigrate(models...))
+	}
+	return db
+}
+
+func newSQLiteDB(t testing.TB) *gorm.DB {
+	// A named shared-cache database lets every pooled connection see the same
+	// tables while keeping tests in the same process apart
+	name := fmt.Sprintf("file:%s?mode=memory&cache=shared", uuid.NewString())
+	db, err := gorm.Open(sqlite.Open(name), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
 	return db
 }
+
+func newPostgresDB(t testing.TB, dsn string) *gorm.DB {
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+	require.NoError(t, admin.Exec("CREATE SCHEMA "+schema).Error)
+
+	db, err := gorm.Open(postgres.Open(withSearchPath(dsn, schema)), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// withSearchPath points a URL or keyword/value DSN at schema
+func withSearchPath(dsn, schema string) string {
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		q := u.Query()
+		q.Set("search_path", schema)
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+	return dsn + " search_path=" + schema
+}
@@ -0,0 +1,115 @@
+package fakes
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/google/uuid"
+)
+
+// AnalyticsService records events, metrics and performance logs in memory.
+// Reports and insights are not implemented.
+type AnalyticsService struct {
+	services.AnalyticsService
+
+	mu              sync.Mutex
+	events          []*models.AnalyticsEvent
+	metrics         []*models.AnalyticsMetric
+	performanceLogs []*models.PerformanceLog
+}
+
+// NewAnalyticsService returns an empty in-memory AnalyticsService
+func NewAnalyticsService() *AnalyticsService {
+	return &AnalyticsService{}
+}
+
+func (f *AnalyticsService) RecordEvent(ctx context.Context, event *models.AnalyticsEvent) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+	f.events = append(f.events, event)
+	return nil
+}
+
+// GetEvents applies the type, actor, repository, organization and paging
+// filters to the recorded events, oldest first
+func (f *AnalyticsService) GetEvents(ctx context.Context, filters services.EventFilters) ([]*models.AnalyticsEvent, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var matched []*models.AnalyticsEvent
+	for _, e := range f.events {
+		if len(filters.EventTypes) > 0 && !containsEventType(filters.EventTypes, e.EventType) {
+			continue
+		}
+		if !sameID(filters.ActorID, e.ActorID) || !sameID(filters.RepositoryID, e.RepositoryID) ||
+			!sameID(filters.OrganizationID, e.OrganizationID) {
+			continue
+		}
+		matched = append(matched, e)
+	}
+	total := int64(len(matched))
+	if filters.Offset > 0 {
+		matched = matched[min(filters.Offset, len(matched)):]
+	}
+	if filters.Limit > 0 && len(matched) > filters.Limit {
+		matched = matched[:filters.Limit]
+	}
+	return matched, total, nil
+}
+
+func (f *AnalyticsService) RecordMetric(ctx context.Context, metric *models.AnalyticsMetric) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.metrics = append(f.metrics, metric)
+	return nil
+}
+
+func (f *AnalyticsService) RecordPerformanceLog(ctx context.Context, log *models.PerformanceLog) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.performanceLogs = append(f.performanceLogs, log)
+	return nil
+}
+
+// Events returns every recorded event
+func (f *AnalyticsService) Events() []*models.AnalyticsEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*models.AnalyticsEvent(nil), f.events...)
+}
+
+// Metrics returns every recorded metric
+func (f *AnalyticsService) Metrics() []*models.AnalyticsMetric {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*models.AnalyticsMetric(nil), f.metrics...)
+}
+
+// PerformanceLogs returns every recorded performance log
+func (f *AnalyticsService) PerformanceLogs() []*models.PerformanceLog {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*models.PerformanceLog(nil), f.performanceLogs...)
+}
+
+func containsEventType(types []models.EventType, t models.EventType) bool {
+	for _, candidate := range types {
+		if candidate == t {
+			return true
+		}
+	}
+	return false
+}
+
+// sameID reports whether value matches an optional filter
+func sameID(filter, value *uuid.UUID) bool {
+	return filter == nil || (value != nil && *filter == *value)
+}
@@ -0,0 +1,58 @@
+package fakes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGitService(t *testing.T) {
+	fake := NewGitService()
+	fake.SetRef("main", "abc123")
+	fake.SetFile("main", "docs/README.md", "hello")
+
+	sha, err := fake.ResolveSHA(context.Background(), "/any", "refs/heads/main")
+	require.NoError(t, err)
+	assert.Equal(t, "abc123", sha)
+	_, err = fake.ResolveSHA(context.Background(), "/any", "missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	file, err := fake.GetFile(context.Background(), "/any", "main", "docs/README.md")
+	require.NoError(t, err)
+	assert.Equal(t, "README.md", file.Name)
+	assert.Equal(t, "hello", file.Content)
+}
+
+func TestAnalyticsService(t *testing.T) {
+	fake := NewAnalyticsService()
+	repoID := uuid.New()
+	ctx := context.Background()
+	require.NoError(t, fake.RecordEvent(ctx, &models.AnalyticsEvent{EventType: models.EventType("repo.push"), RepositoryID: &repoID}))
+	require.NoError(t, fake.RecordEvent(ctx, &models.AnalyticsEvent{EventType: models.EventType("user.login")}))
+
+	events, total, err := fake.GetEvents(ctx, services.EventFilters{RepositoryID: &repoID})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	require.Len(t, events, 1)
+	assert.NotEqual(t, uuid.Nil, events[0].ID)
+	assert.Len(t, fake.Events(), 2)
+}
+
+func TestNotificationService(t *testing.T) {
+	fake := NewNotificationService()
+	userID := uuid.New()
+	ch, cancel := fake.Subscribe(userID)
+	defer cancel()
+
+	fake.Publish(userID, services.Notification{Type: "mention"})
+	fake.Publish(uuid.New(), services.Notification{Type: "other"})
+
+	assert.Equal(t, "mention", (<-ch).Type)
+	assert.Len(t, fake.Published(), 2)
+	require.Len(t, fake.PublishedTo(userID), 1)
+}
@@ -0,0 +1,122 @@
+// Package fakes provides in-memory stand-ins for the services handlers and
+// jobs depend on. Each fake embeds the interface it replaces, so methods it
+// does not implement panic when called instead of silently returning zero
+// values; tests that need more behaviour can embed the fake and override.
+package fakes
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/a5c-ai/hub/internal/git"
+)
+
+// ErrNotFound is returned for refs, files and comparisons a fake does not know
+var ErrNotFound = errors.New("not found")
+
+// GitService serves files, refs and comparisons from memory. Repository
+// paths are ignored, so one fake stands for every repository.
+type GitService struct {
+	git.GitService
+
+	mu          sync.RWMutex
+	refs        map[string]string
+	files       map[string]map[string]string
+	comparisons map[string]*git.BranchComparison
+}
+
+// NewGitService returns an empty in-memory GitService
+func NewGitService() *GitService {
+	return &GitService{
+		refs:        make(map[string]string),
+		files:       make(map[string]map[string]string),
+		comparisons: make(map[string]*git.BranchComparison),
+	}
+}
+
+// SetRef points ref at sha
+func (f *GitService) SetRef(ref, sha string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.refs[ref] = sha
+}
+
+// SetFile stores content at path for ref
+func (f *GitService) SetFile(ref, path, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.files[ref] == nil {
+		f.files[ref] = make(map[string]string)
+	}
+	f.files[ref][path] = content
+}
+
+// SetComparison stores the result CompareRefs returns for base and head
+func (f *GitService) SetComparison(base, head string, comparison *git.BranchComparison) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.comparisons[base+"..."+head] = comparison
+}
+
+func (f *GitService) ResolveSHA(ctx context.Context, repoPath, ref string) (string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if sha, ok := f.refs[strings.TrimPrefix(ref, "refs/heads/")]; ok {
+		return sha, nil
+	}
+	return "", ErrNotFound
+}
+
+func (f *GitService) GetBranchCommit(repoPath, branch string) (string, error) {
+	return f.ResolveSHA(context.Background(), repoPath, branch)
+}
+
+func (f *GitService) GetFile(ctx context.Context, repoPath, ref, path string) (*git.File, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	content, ok := f.files[ref][path]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	name := path
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		name = path[i+1:]
+	}
+	return &git.File{Name: name, Path: path, Size: int64(len(content)), Type: "file", Content: content}, nil
+}
+
+func (f *GitService) WalkFiles(ctx context.Context, repoPath, ref string, maxSize int64, fn func(path string, content []byte) error) error {
+	f.mu.RLock()
+	files := make(map[string]string, len(f.files[ref]))
+	for path, content := range f.files[ref] {
+		files[path] = content
+	}
+	f.mu.RUnlock()
+
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if maxSize > 0 && int64(len(files[path])) > maxSize {
+			continue
+		}
+		if err := fn(path, []byte(files[path])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *GitService) CompareRefs(repoPath, base, head string) (*git.BranchComparison, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if comparison, ok := f.comparisons[base+"..."+head]; ok {
+		return comparison, nil
+	}
+	return nil, ErrNotFound
+}
@@ -0,0 +1,55 @@
+package fakes
+
+import (
+	"sync"
+
+	"github.com/a5c-ai/hub/internal/services"
+	"github.com/google/uuid"
+)
+
+// PublishedNotification is a notification seen by NotificationService
+type PublishedNotification struct {
+	UserID       uuid.UUID
+	Notification services.Notification
+}
+
+// NotificationService delivers to subscribers like the real in-memory
+// service and also keeps every published notification for assertions
+type NotificationService struct {
+	services.NotificationService
+
+	mu        sync.Mutex
+	published []PublishedNotification
+}
+
+// NewNotificationService returns a recording NotificationService
+func NewNotificationService() *NotificationService {
+	return &NotificationService{NotificationService: services.NewNotificationService()}
+}
+
+func (f *NotificationService) Publish(userID uuid.UUID, notification services.Notification) {
+	f.mu.Lock()
+	f.published = append(f.published, PublishedNotification{UserID: userID, Notification: notification})
+	f.mu.Unlock()
+	f.NotificationService.Publish(userID, notification)
+}
+
+// Published returns the notifications published so far, oldest first
+func (f *NotificationService) Published() []PublishedNotification {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]PublishedNotification(nil), f.published...)
+}
+
+// PublishedTo returns the notifications published to userID
+func (f *NotificationService) PublishedTo(userID uuid.UUID) []services.Notification {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []services.Notification
+	for _, p := range f.published {
+		if p.UserID == userID {
+			out = append(out, p.Notification)
+		}
+	}
+	return out
+}
@@ -0,0 +1,101 @@
+package testutil
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/a5c-ai/hub/internal/git"
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+)
+
+// DefaultAuthor is the author of commits made through GitRepo
+var DefaultAuthor = git.CommitAuthor{Name: "Test Author", Email: "author@example.com"}
+
+// RepositoryPath returns where the repository service keeps repo's bare
+// repository under base
+func RepositoryPath(base string, repo *models.Repository) string {
+	return filepath.Join(base, string(repo.OwnerType), repo.OwnerID.String(), repo.Name+".git")
+}
+
+// GitRepo builds a bare repository on disk commit by commit. Every helper
+// fails the test on error and returns plain values, so fixtures read as a
+// short script.
+type GitRepo struct {
+	t      testing.TB
+	Git    git.GitService
+	Path   string
+	Author git.CommitAuthor
+}
+
+// NewGitRepo initializes an empty bare repository at path, or in a
+// temporary directory when path is empty
+func NewGitRepo(t testing.TB, path string) *GitRepo {
+	t.Helper()
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "repo.git")
+	}
+	logger := logrus.New()
+	logger.SetLevel(logrus.WarnLevel)
+	r := &GitRepo{t: t, Git: git.NewGitService(logger), Path: path, Author: DefaultAuthor}
+	require.NoError(t, r.Git.InitRepository(context.Background(), path, true))
+	return r
+}
+
+// Commit writes files on branch, creating the branch when it does not exist
+// yet, and returns the new commit SHA
+func (r *GitRepo) Commit(branch, message string, files map[string]string) string {
+	r.t.Helper()
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	entries := make([]git.CommitFileEntry, 0, len(paths))
+	for _, path := range paths {
+		entries = append(entries, git.CommitFileEntry{Path: path, Content: []byte(files[path])})
+	}
+
+	author := r.Author
+	if author.Date.IsZero() {
+		author.Date = time.Now()
+	}
+	commit, err := r.Git.CommitFiles(context.Background(), r.Path, git.CommitFilesRequest{
+		Files:   entries,
+		Message: message,
+		Branch:  branch,
+		Author:  author,
+	})
+	require.NoError(r.t, err)
+	return commit.SHA
+}
+
+// Branch creates branch name pointing at from
+func (r *GitRepo) Branch(name, from string) {
+	r.t.Helper()
+	require.NoError(r.t, r.Git.CreateBranch(context.Background(), r.Path, name, from))
+}
+
+// DeleteBranch removes branch name
+func (r *GitRepo) DeleteBranch(name string) {
+	r.t.Helper()
+	require.NoError(r.t, r.Git.DeleteBranch(context.Background(), r.Path, name))
+}
+
+// Tag creates an annotated tag name at ref
+func (r *GitRepo) Tag(name, ref, message string) {
+	r.t.Helper()
+	require.NoError(r.t, r.Git.CreateTag(context.Background(), r.Path, name, ref, message))
+}
+
+// SHA resolves ref to a commit SHA
+func (r *GitRepo) SHA(ref string) string {
+	r.t.Helper()
+	sha, err := r.Git.ResolveSHA(context.Background(), r.Path, ref)
+	require.NoError(r.t, err)
+	return sha
+}
@@ -0,0 +1,39 @@
+package testutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/a5c-ai/hub/internal/models"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewTestDB_IsolatedPerTest(t *testing.T) {
+	first := NewTestDB(t, &models.User{})
+	require.NoError(t, first.Create(&models.User{ID: uuid.New(), Username: "alice", Email: "alice@example.com"}).Error)
+
+	second := NewTestDB(t, &models.User{})
+	var count int64
+	require.NoError(t, second.Model(&models.User{}).Count(&count).Error)
+	assert.Zero(t, count)
+}
+
+func TestGitRepo(t *testing.T) {
+	repo := NewGitRepo(t, "")
+	first := repo.Commit("main", "initial", map[string]string{"README.md": "hello\n"})
+	repo.Branch("feature", "main")
+	second := repo.Commit("feature", "docs", map[string]string{"docs/guide.md": "# Guide\n"})
+
+	assert.Equal(t, first, repo.SHA("main"))
+	assert.Equal(t, second, repo.SHA("feature"))
+
+	file, err := repo.Git.GetFile(context.Background(), repo.Path, "feature", "docs/guide.md")
+	require.NoError(t, err)
+	assert.Equal(t, "# Guide\n", file.Content)
+
+	repo.DeleteBranch("feature")
+	_, err = repo.Git.ResolveSHA(context.Background(), repo.Path, "feature")
+	assert.Error(t, err)
+}
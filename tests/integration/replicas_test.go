@@ -0,0 +1,95 @@
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// replicaURLs returns the base URLs of two replicas of a shared-nothing
+// deployment, skipping the test unless API_REPLICA_URL is set
+func replicaURLs(t *testing.T) (string, string) {
+	replicaURL := os.Getenv("API_REPLICA_URL")
+	if replicaURL == "" {
+		t.Skip("API_REPLICA_URL not set; see docker-compose.replicas.yml")
+	}
+	baseURL := os.Getenv("API_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+	return baseURL, replicaURL
+}
+
+// usedRateLimit pings a replica anonymously and returns the requests the
+// caller has used in the core category
+func usedRateLimit(t *testing.T, baseURL string) int {
+	resp, err := http.Get(fmt.Sprintf("%s/api/v1/ping", baseURL))
+	if err != nil {
+		t.Fatalf("ping %s: %v", baseURL, err)
+	}
+	resp.Body.Close()
+	used, err := strconv.Atoi(resp.Header.Get("X-RateLimit-Used"))
+	if err != nil {
+		t.Fatalf("ping %s: missing X-RateLimit-Used header", baseURL)
+	}
+	return used
+}
+
+// TestReplicasShareRateLimits verifies that requests to either replica count
+// against the same rate limit window.
+func TestReplicasShareRateLimits(t *testing.T) {
+	baseURL, replicaURL := replicaURLs(t)
+
+	first := usedRateLimit(t, baseURL)
+	if second := usedRateLimit(t, replicaURL); second != first+1 {
+		t.Fatalf("replica counted %d requests after %d on the first replica", second, first)
+	}
+	if third := usedRateLimit(t, baseURL); third != first+2 {
+		t.Fatalf("first replica counted %d requests, want %d", third, first+2)
+	}
+}
+
+// hostKey returns the host key the SSH server at addr presents
+func hostKey(t *testing.T, addr string) ssh.PublicKey {
+	var key ssh.PublicKey
+	ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:    "git",
+		Timeout: 10 * time.Second,
+		HostKeyCallback: func(hostname string, remote net.Addr, k ssh.PublicKey) error {
+			key = k
+			return nil
+		},
+	})
+	if key == nil {
+		t.Fatalf("no host key from %s", addr)
+	}
+	return key
+}
+
+// TestReplicasShareSSHHostKey verifies that SSH clients see the same host
+// key on every replica.
+func TestReplicasShareSSHHostKey(t *testing.T) {
+	replicaAddr := os.Getenv("SSH_REPLICA_ADDR")
+	if replicaAddr == "" {
+		t.Skip("SSH_REPLICA_ADDR not set; see docker-compose.replicas.yml")
+	}
+	addr := os.Getenv("SSH_ADDR")
+	if addr == "" {
+		addr = "localhost:2222"
+	}
+
+	first, second := hostKey(t, addr), hostKey(t, replicaAddr)
+	if !bytes.Equal(first.Marshal(), second.Marshal()) {
+		t.Fatalf("replicas present different host keys: %s and %s", ssh.FingerprintSHA256(first), ssh.FingerprintSHA256(second))
+	}
+}